@@ -0,0 +1,11 @@
+#!/usr/bin/env node
+import { readFile, writeFile } from 'node:fs/promises';
+import { runCli } from '../src/js/cli.js';
+
+process.exitCode = await runCli(process.argv.slice(2), {
+    stdin: process.stdin,
+    stdout: process.stdout,
+    stderr: process.stderr,
+    readFile,
+    writeFile
+});
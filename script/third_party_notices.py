@@ -0,0 +1,116 @@
+#!/usr/bin/env python3
+"""Write the third-party notices the desktop app shows in its About dialog.
+
+Run as a release step before `tauri build`, after `npm ci`. The crates and their
+license texts come from cargo-about (`cargo install --locked cargo-about`), the npm
+packages from package-lock.json and the license files in node_modules. The result is
+src-tauri/third_party_notices.json, which build.rs embeds; without it the app lists
+no notices.
+"""
+
+import json
+import os
+import subprocess
+import sys
+
+ROOT = os.path.dirname(os.path.dirname(os.path.abspath(__file__)))
+MANIFEST = os.path.join(ROOT, 'src-tauri', 'Cargo.toml')
+PACKAGE_LOCK = os.path.join(ROOT, 'package-lock.json')
+OUTPUT = os.path.join(ROOT, 'src-tauri', 'third_party_notices.json')
+
+LICENSE_FILE_PREFIXES = ('license', 'licence', 'copying', 'notice')
+
+
+def notice(ecosystem, name, version, license, repository, license_text):
+    return {
+        'ecosystem': ecosystem,
+        'name': name,
+        'version': version,
+        'license': license,
+        'repository': repository,
+        'licenseText': license_text,
+    }
+
+
+def read_license_files(package_dir):
+    """Text of the license files in a package directory, None if it has none"""
+    try:
+        names = sorted(os.listdir(package_dir))
+    except OSError:
+        return None
+    texts = []
+    for name in names:
+        path = os.path.join(package_dir, name)
+        if name.lower().startswith(LICENSE_FILE_PREFIXES) and os.path.isfile(path):
+            with open(path, encoding='utf-8', errors='replace') as f:
+                texts.append(f.read().strip())
+    return '\n\n'.join(texts) or None
+
+
+def npm_notices():
+    """Runtime (non-dev) packages, i.e. what ends up in the frontend bundle"""
+    with open(PACKAGE_LOCK, encoding='utf-8') as f:
+        packages = json.load(f).get('packages', {})
+
+    notices = []
+    for path, package in packages.items():
+        if not path or package.get('dev'):
+            continue
+        license = package.get('license')
+        notices.append(notice(
+            'npm',
+            path.rsplit('node_modules/', 1)[-1],
+            package.get('version', ''),
+            license if isinstance(license, str) else None,
+            None,
+            read_license_files(os.path.join(ROOT, path)),
+        ))
+    return notices
+
+
+def cargo_notices():
+    """Crates built into the app, with the license texts cargo-about picked for them"""
+    output = subprocess.run(
+        ['cargo', 'about', 'generate', '--format', 'json', '--manifest-path', MANIFEST],
+        check=True,
+        capture_output=True,
+        text=True,
+    ).stdout
+    about = json.loads(output)
+
+    texts = {}
+    for license in about['licenses']:
+        for user in license['used_by']:
+            key = (user['crate']['name'], user['crate']['version'])
+            texts.setdefault(key, []).append(license['text'].strip())
+
+    notices = []
+    for entry in about['crates']:
+        package = entry['package']
+        key = (package['name'], package['version'])
+        notices.append(notice(
+            'cargo',
+            package['name'],
+            package['version'],
+            package.get('license'),
+            package.get('repository'),
+            '\n\n'.join(texts.get(key, [])) or None,
+        ))
+    return notices
+
+
+def main():
+    notices = npm_notices() + cargo_notices()
+    notices.sort(key=lambda n: (n['ecosystem'], n['name'], n['version']))
+    with open(OUTPUT, 'w', encoding='utf-8') as f:
+        json.dump(notices, f, indent=2)
+        f.write('\n')
+
+    missing = sum(1 for n in notices if not n['licenseText'])
+    print(f'Wrote {len(notices)} notices to {OUTPUT}')
+    if missing:
+        print(f'{missing} packages ship no license text', file=sys.stderr)
+
+
+if __name__ == '__main__':
+    main()
@@ -1,3 +1,60 @@
+use std::path::{Path, PathBuf};
+use std::process::Command;
+use std::time::{SystemTime, UNIX_EPOCH};
+
 fn main() {
     tauri_build::build();
+
+    let manifest_dir = PathBuf::from(std::env::var("CARGO_MANIFEST_DIR").unwrap());
+    let out_dir = PathBuf::from(std::env::var("OUT_DIR").unwrap());
+
+    println!("cargo:rustc-env=MSGREADER_COMMIT={}", git_commit(&manifest_dir));
+    println!("cargo:rustc-env=MSGREADER_BUILD_DATE={}", build_date());
+
+    // Written by script/third_party_notices.py as a release step; plain builds embed an
+    // empty list so they need neither the network nor the lockfile
+    let notices = std::fs::read_to_string(manifest_dir.join("third_party_notices.json"))
+        .unwrap_or_else(|_| "[]".to_string());
+    std::fs::write(out_dir.join("third_party_notices.json"), notices)
+        .expect("failed to write third-party notices");
+
+    println!("cargo:rerun-if-changed=third_party_notices.json");
+    println!("cargo:rerun-if-changed=../.git/HEAD");
+    println!("cargo:rerun-if-env-changed=SOURCE_DATE_EPOCH");
+}
+
+/// Short hash of the checked out commit, empty outside a git checkout
+fn git_commit(manifest_dir: &Path) -> String {
+    Command::new("git")
+        .args(["rev-parse", "--short", "HEAD"])
+        .current_dir(manifest_dir)
+        .output()
+        .ok()
+        .filter(|output| output.status.success())
+        .map(|output| String::from_utf8_lossy(&output.stdout).trim().to_string())
+        .unwrap_or_default()
+}
+
+/// Build date as YYYY-MM-DD (UTC), honoring SOURCE_DATE_EPOCH for reproducible builds
+fn build_date() -> String {
+    let seconds = std::env::var("SOURCE_DATE_EPOCH")
+        .ok()
+        .and_then(|value| value.parse::<u64>().ok())
+        .unwrap_or_else(|| {
+            SystemTime::now().duration_since(UNIX_EPOCH).map(|d| d.as_secs()).unwrap_or(0)
+        });
+
+    // Civil date from days since 1970-01-01 (Howard Hinnant's algorithm)
+    let days = (seconds / 86_400) as i64 + 719_468;
+    let era = days.div_euclid(146_097);
+    let day_of_era = days.rem_euclid(146_097);
+    let year_of_era = (day_of_era - day_of_era / 1460 + day_of_era / 36_524
+        - day_of_era / 146_096)
+        / 365;
+    let day_of_year = day_of_era - (365 * year_of_era + year_of_era / 4 - year_of_era / 100);
+    let month_index = (5 * day_of_year + 2) / 153;
+    let day = day_of_year - (153 * month_index + 2) / 5 + 1;
+    let month = if month_index < 10 { month_index + 3 } else { month_index - 9 };
+    let year = year_of_era + era * 400 + i64::from(month <= 2);
+    format!("{:04}-{:02}-{:02}", year, month, day)
 }
@@ -0,0 +1,92 @@
+use tauri::AppHandle;
+
+/// Written by script/third_party_notices.py for release builds, `[]` otherwise
+const THIRD_PARTY_NOTICES: &str =
+    include_str!(concat!(env!("OUT_DIR"), "/third_party_notices.json"));
+
+/// A bundled third-party package, its declared license and the license text it ships
+#[derive(serde::Deserialize, serde::Serialize)]
+#[serde(rename_all = "camelCase")]
+pub struct ThirdPartyNotice {
+    /// "npm" or "cargo"
+    pub ecosystem: String,
+    pub name: String,
+    pub version: String,
+    /// SPDX expression as declared by the package, None if it declares none
+    pub license: Option<String>,
+    pub repository: Option<String>,
+    /// Full license and copyright text, None if the package ships none
+    pub license_text: Option<String>,
+}
+
+/// Build and platform information for the About dialog and diagnostics
+#[derive(serde::Serialize)]
+#[serde(rename_all = "camelCase")]
+pub struct AppInfo {
+    pub name: String,
+    pub version: String,
+    /// Short git commit hash, None for builds outside a git checkout
+    pub commit: Option<String>,
+    /// YYYY-MM-DD (UTC)
+    pub build_date: String,
+    /// "windows", "macos", "linux", ...
+    pub platform: &'static str,
+    pub arch: &'static str,
+    /// How the app was installed, which decides who registers the file associations:
+    /// "msix", "installer" (MSI/NSIS), "app-bundle", "appimage", "flatpak", "system"
+    /// (deb/rpm) or "development"
+    pub packaging: &'static str,
+    pub tauri_version: &'static str,
+    pub third_party_notices: Vec<ThirdPartyNotice>,
+}
+
+/// Detect the package type from the location of the executable and the environment.
+/// File associations come from the package in every case: the MSIX manifest, the
+/// MSI/NSIS registry entries, the bundle's Info.plist (LaunchServices) or the .desktop
+/// file. The app registers nothing itself, except the user-level .desktop entry that
+/// `file_associations::make_default` writes on Linux when the package has none.
+pub(crate) fn packaging() -> &'static str {
+    if cfg!(debug_assertions) {
+        return "development";
+    }
+    let exe = std::env::current_exe()
+        .map(|path| path.to_string_lossy().to_string())
+        .unwrap_or_default();
+
+    if cfg!(windows) {
+        if exe.to_lowercase().contains(r"\windowsapps\") {
+            "msix"
+        } else {
+            "installer"
+        }
+    } else if cfg!(target_os = "macos") {
+        if exe.contains(".app/Contents/MacOS/") {
+            "app-bundle"
+        } else {
+            "system"
+        }
+    } else if std::env::var_os("APPIMAGE").is_some() {
+        "appimage"
+    } else if std::env::var_os("FLATPAK_ID").is_some() {
+        "flatpak"
+    } else {
+        "system"
+    }
+}
+
+pub fn app_info(app: &AppHandle) -> AppInfo {
+    let package = app.package_info();
+    let commit = env!("MSGREADER_COMMIT");
+
+    AppInfo {
+        name: package.name.clone(),
+        version: package.version.to_string(),
+        commit: (!commit.is_empty()).then(|| commit.to_string()),
+        build_date: env!("MSGREADER_BUILD_DATE").to_string(),
+        platform: std::env::consts::OS,
+        arch: std::env::consts::ARCH,
+        packaging: packaging(),
+        tauri_version: tauri::VERSION,
+        third_party_notices: serde_json::from_str(THIRD_PARTY_NOTICES).unwrap_or_default(),
+    }
+}
@@ -0,0 +1,493 @@
+use crate::attachments::{self, SaveResult};
+use crate::headers::CFB_SIGNATURE;
+use crate::message::MessageSummary;
+use crate::{eml, msg};
+use rusqlite::types::Value;
+use rusqlite::{params, params_from_iter, Connection, OptionalExtension};
+use sha2::{Digest, Sha256};
+use std::path::{Path, PathBuf};
+use std::sync::Mutex;
+use std::time::{SystemTime, UNIX_EPOCH};
+
+/// Most entries returned by one `page` call
+pub const MAX_PAGE_SIZE: usize = 200;
+
+/// Name of the archive database in the app's local data directory
+const FILE_NAME: &str = "archive.sqlite";
+
+/// Stored in `PRAGMA user_version`; a newer database is not opened
+const SCHEMA_VERSION: i64 = 1;
+
+/// The original file is kept in `data`, so a message can be exported unchanged after its
+/// file is gone; `body_text` is the plain text body for searching
+const SCHEMA: &str = "
+    CREATE TABLE IF NOT EXISTS messages (
+        id INTEGER PRIMARY KEY,
+        file_name TEXT NOT NULL,
+        folder TEXT NOT NULL DEFAULT '',
+        subject TEXT NOT NULL,
+        sender_name TEXT NOT NULL,
+        sender_email TEXT NOT NULL,
+        date INTEGER,
+        imported_at INTEGER NOT NULL,
+        body_text TEXT NOT NULL,
+        sha256 TEXT NOT NULL UNIQUE,
+        data BLOB NOT NULL
+    );
+    CREATE INDEX IF NOT EXISTS messages_folder ON messages (folder);
+    CREATE TABLE IF NOT EXISTS tags (
+        message_id INTEGER NOT NULL REFERENCES messages (id) ON DELETE CASCADE,
+        tag TEXT NOT NULL,
+        PRIMARY KEY (message_id, tag)
+    );
+    CREATE INDEX IF NOT EXISTS tags_tag ON tags (tag);
+";
+
+/// Columns read into an `ArchiveEntry`, without the tags
+const ENTRY_COLUMNS: &str = "id, file_name, folder, subject, sender_name, sender_email, date, \
+                             imported_at, length(data)";
+
+/// A message in the archive
+#[derive(serde::Serialize)]
+#[serde(rename_all = "camelCase")]
+pub struct ArchiveEntry {
+    pub id: i64,
+    /// Name of the file it was imported from, used when it is exported
+    pub file_name: String,
+    /// Empty for messages in no folder
+    pub folder: String,
+    pub tags: Vec<String>,
+    pub size: u64,
+    /// Unix time in milliseconds
+    pub imported_at: i64,
+    #[serde(flatten)]
+    pub summary: MessageSummary,
+}
+
+#[derive(serde::Serialize)]
+#[serde(rename_all = "camelCase")]
+pub struct ArchiveImport {
+    pub entry: ArchiveEntry,
+    /// False if the same file was archived before; its entry is returned unchanged
+    pub added: bool,
+}
+
+/// Filter of `page`; all given conditions must match
+#[derive(serde::Deserialize, Default)]
+#[serde(rename_all = "camelCase", default)]
+pub struct ArchiveQuery {
+    /// Messages in this folder only, `""` for those in no folder
+    pub folder: Option<String>,
+    pub tag: Option<String>,
+    /// Words that must all appear in the subject, sender, file name or text body
+    /// (case-insensitive for ASCII letters)
+    pub text: String,
+}
+
+#[derive(serde::Serialize)]
+#[serde(rename_all = "camelCase")]
+pub struct ArchivePage {
+    pub offset: usize,
+    pub total: usize,
+    pub entries: Vec<ArchiveEntry>,
+}
+
+/// Folders and tags in use, sorted, for filtering
+#[derive(serde::Serialize)]
+#[serde(rename_all = "camelCase")]
+pub struct ArchiveLabels {
+    pub folders: Vec<String>,
+    pub tags: Vec<String>,
+}
+
+/// Local archive of imported messages (`<local data dir>/archive.sqlite`, one per
+/// profile). Messages are kept with their original file, so they can be searched, opened
+/// and exported without the file they came from.
+pub struct Archive {
+    connection: Mutex<Option<(PathBuf, Connection)>>,
+}
+
+/// Location of the archive for a profile (None for the default profile)
+pub fn file_path(data_dir: &Path, profile: Option<&str>) -> PathBuf {
+    match profile {
+        Some(profile) => data_dir.join(format!("archive-{}.sqlite", profile)),
+        None => data_dir.join(FILE_NAME),
+    }
+}
+
+fn now_millis() -> i64 {
+    SystemTime::now()
+        .duration_since(UNIX_EPOCH)
+        .map(|d| d.as_millis() as i64)
+        .unwrap_or(0)
+}
+
+fn db_error(e: rusqlite::Error) -> String {
+    format!("Archive error: {}", e)
+}
+
+fn open(path: &Path) -> Result<Connection, String> {
+    if let Some(dir) = path.parent() {
+        std::fs::create_dir_all(dir)
+            .map_err(|e| format!("Failed to create data directory: {}", e))?;
+    }
+    let connection =
+        Connection::open(path).map_err(|e| format!("Failed to open archive: {}", e))?;
+    let version: i64 = connection
+        .query_row("PRAGMA user_version", [], |row| row.get(0))
+        .map_err(db_error)?;
+    if version > SCHEMA_VERSION {
+        return Err("The archive was created by a newer version of msgReader".to_string());
+    }
+    connection
+        .execute_batch(&format!(
+            "PRAGMA foreign_keys = ON; {} PRAGMA user_version = {};",
+            SCHEMA, SCHEMA_VERSION
+        ))
+        .map_err(db_error)?;
+    Ok(connection)
+}
+
+/// Trimmed, without empty and repeated tags
+fn clean_tags(tags: &[String]) -> Vec<String> {
+    let mut cleaned: Vec<String> = Vec::new();
+    for tag in tags.iter().map(|tag| tag.trim()).filter(|tag| !tag.is_empty()) {
+        if !cleaned.iter().any(|existing| existing == tag) {
+            cleaned.push(tag.to_string());
+        }
+    }
+    cleaned
+}
+
+/// A LIKE pattern matching `word` anywhere, with `\` as escape character
+fn like_pattern(word: &str) -> String {
+    let mut pattern = String::from("%");
+    for c in word.chars() {
+        if matches!(c, '%' | '_' | '\\') {
+            pattern.push('\\');
+        }
+        pattern.push(c);
+    }
+    pattern.push('%');
+    pattern
+}
+
+/// WHERE clause of a query and its parameters
+fn where_clause(query: &ArchiveQuery) -> (String, Vec<Value>) {
+    const SEARCHED: [&str; 5] =
+        ["subject", "sender_name", "sender_email", "file_name", "body_text"];
+
+    let mut conditions = Vec::new();
+    let mut values = Vec::new();
+    if let Some(folder) = &query.folder {
+        conditions.push("folder = ?".to_string());
+        values.push(Value::Text(folder.trim().to_string()));
+    }
+    if let Some(tag) = query.tag.as_deref().map(str::trim).filter(|tag| !tag.is_empty()) {
+        conditions.push(
+            "EXISTS (SELECT 1 FROM tags WHERE message_id = messages.id AND tag = ?)".to_string(),
+        );
+        values.push(Value::Text(tag.to_string()));
+    }
+    for word in query.text.split_whitespace() {
+        let columns: Vec<String> = SEARCHED
+            .iter()
+            .map(|column| format!("{} LIKE ? ESCAPE '\\'", column))
+            .collect();
+        conditions.push(format!("({})", columns.join(" OR ")));
+        values.extend(SEARCHED.iter().map(|_| Value::Text(like_pattern(word))));
+    }
+
+    if conditions.is_empty() {
+        (String::new(), values)
+    } else {
+        (format!(" WHERE {}", conditions.join(" AND ")), values)
+    }
+}
+
+fn read_entry(row: &rusqlite::Row) -> rusqlite::Result<ArchiveEntry> {
+    Ok(ArchiveEntry {
+        id: row.get(0)?,
+        file_name: row.get(1)?,
+        folder: row.get(2)?,
+        tags: Vec::new(),
+        summary: MessageSummary {
+            subject: row.get(3)?,
+            sender_name: row.get(4)?,
+            sender_email: row.get(5)?,
+            date: row.get(6)?,
+        },
+        imported_at: row.get(7)?,
+        size: row.get::<_, i64>(8)? as u64,
+    })
+}
+
+fn tags_of(connection: &Connection, id: i64) -> rusqlite::Result<Vec<String>> {
+    let mut statement =
+        connection.prepare_cached("SELECT tag FROM tags WHERE message_id = ? ORDER BY tag")?;
+    let tags = statement.query_map([id], |row| row.get(0))?;
+    tags.collect()
+}
+
+fn entry(connection: &Connection, id: i64) -> Result<ArchiveEntry, String> {
+    let mut entry = connection
+        .query_row(
+            &format!("SELECT {} FROM messages WHERE id = ?", ENTRY_COLUMNS),
+            [id],
+            read_entry,
+        )
+        .optional()
+        .map_err(db_error)?
+        .ok_or_else(|| format!("No message {} in the archive", id))?;
+    entry.tags = tags_of(connection, id).map_err(db_error)?;
+    Ok(entry)
+}
+
+impl Archive {
+    pub fn new() -> Self {
+        Archive {
+            connection: Mutex::new(None),
+        }
+    }
+
+    /// Run `f` with the database at `path`, opening (and creating) it first if another
+    /// one or none is open
+    fn with<T>(
+        &self,
+        path: &Path,
+        f: impl FnOnce(&mut Connection) -> Result<T, String>,
+    ) -> Result<T, String> {
+        let mut connection = self.connection.lock().unwrap();
+        if connection.as_ref().map_or(true, |(open_path, _)| open_path != path) {
+            *connection = None;
+            *connection = Some((path.to_path_buf(), open(path)?));
+        }
+        let (_, connection) = connection.as_mut().unwrap();
+        f(connection)
+    }
+
+    /// Store an .msg or .eml file. The same file is stored only once.
+    pub fn import(
+        &self,
+        path: &Path,
+        data: &[u8],
+        file_name: &str,
+        folder: &str,
+    ) -> Result<ArchiveImport, String> {
+        let is_msg = data.starts_with(&CFB_SIGNATURE);
+        let message = if is_msg {
+            msg::parse_bytes(data)?
+        } else {
+            eml::parse_bytes(data)?
+        };
+        let file_name = match attachments::safe_file_name(file_name.trim()) {
+            name if name.is_empty() && is_msg => "message.msg".to_string(),
+            name if name.is_empty() => "message.eml".to_string(),
+            name => name,
+        };
+        let hash: String = Sha256::digest(data)
+            .iter()
+            .map(|byte| format!("{:02x}", byte))
+            .collect();
+
+        self.with(path, |connection| {
+            let added = connection
+                .execute(
+                    "INSERT INTO messages (file_name, folder, subject, sender_name, \
+                     sender_email, date, imported_at, body_text, sha256, data) \
+                     VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?) ON CONFLICT (sha256) DO NOTHING",
+                    params![
+                        file_name,
+                        folder.trim(),
+                        message.subject,
+                        message.sender_name,
+                        message.sender_email,
+                        message.date,
+                        now_millis(),
+                        message.plain_text(),
+                        hash,
+                        data
+                    ],
+                )
+                .map_err(db_error)?
+                > 0;
+            let id: i64 = connection
+                .query_row("SELECT id FROM messages WHERE sha256 = ?", [&hash], |row| {
+                    row.get(0)
+                })
+                .map_err(db_error)?;
+            Ok(ArchiveImport {
+                entry: entry(connection, id)?,
+                added,
+            })
+        })
+    }
+
+    /// Entries matching a query, newest first: `limit` (at most MAX_PAGE_SIZE) starting
+    /// at `offset`
+    pub fn page(
+        &self,
+        path: &Path,
+        query: &ArchiveQuery,
+        offset: usize,
+        limit: usize,
+    ) -> Result<ArchivePage, String> {
+        let (clause, values) = where_clause(query);
+        self.with(path, |connection| {
+            let total: i64 = connection
+                .query_row(
+                    &format!("SELECT count(*) FROM messages{}", clause),
+                    params_from_iter(values.iter()),
+                    |row| row.get(0),
+                )
+                .map_err(db_error)?;
+
+            let mut statement = connection
+                .prepare(&format!(
+                    "SELECT {} FROM messages{} ORDER BY coalesce(date, imported_at) DESC, \
+                     id DESC LIMIT {} OFFSET {}",
+                    ENTRY_COLUMNS,
+                    clause,
+                    limit.min(MAX_PAGE_SIZE),
+                    offset
+                ))
+                .map_err(db_error)?;
+            let mut entries = statement
+                .query_map(params_from_iter(values.iter()), read_entry)
+                .and_then(|rows| rows.collect::<rusqlite::Result<Vec<_>>>())
+                .map_err(db_error)?;
+            for entry in &mut entries {
+                entry.tags = tags_of(connection, entry.id).map_err(db_error)?;
+            }
+
+            Ok(ArchivePage {
+                offset,
+                total: total as usize,
+                entries,
+            })
+        })
+    }
+
+    /// Folders and tags in use
+    pub fn labels(&self, path: &Path) -> Result<ArchiveLabels, String> {
+        let strings = |connection: &Connection, sql: &str| -> rusqlite::Result<Vec<String>> {
+            let mut statement = connection.prepare(sql)?;
+            let rows = statement.query_map([], |row| row.get(0))?;
+            rows.collect()
+        };
+        self.with(path, |connection| {
+            Ok(ArchiveLabels {
+                folders: strings(
+                    connection,
+                    "SELECT DISTINCT folder FROM messages WHERE folder <> '' ORDER BY folder",
+                )
+                .map_err(db_error)?,
+                tags: strings(connection, "SELECT DISTINCT tag FROM tags ORDER BY tag")
+                    .map_err(db_error)?,
+            })
+        })
+    }
+
+    /// Replace the tags of messages
+    pub fn set_tags(&self, path: &Path, ids: &[i64], tags: &[String]) -> Result<(), String> {
+        let tags = clean_tags(tags);
+        self.with(path, |connection| {
+            let transaction = connection.transaction().map_err(db_error)?;
+            for &id in ids {
+                transaction
+                    .execute("DELETE FROM tags WHERE message_id = ?", [id])
+                    .map_err(db_error)?;
+                for tag in &tags {
+                    transaction
+                        .execute(
+                            "INSERT INTO tags (message_id, tag) \
+                             SELECT id, ? FROM messages WHERE id = ?",
+                            params![tag, id],
+                        )
+                        .map_err(db_error)?;
+                }
+            }
+            transaction.commit().map_err(db_error)
+        })
+    }
+
+    /// Move messages to a folder, `""` for none
+    pub fn move_to_folder(&self, path: &Path, ids: &[i64], folder: &str) -> Result<(), String> {
+        self.with(path, |connection| {
+            let transaction = connection.transaction().map_err(db_error)?;
+            for &id in ids {
+                transaction
+                    .execute(
+                        "UPDATE messages SET folder = ? WHERE id = ?",
+                        params![folder.trim(), id],
+                    )
+                    .map_err(db_error)?;
+            }
+            transaction.commit().map_err(db_error)
+        })
+    }
+
+    /// Remove messages from the archive; returns how many were removed
+    pub fn delete(&self, path: &Path, ids: &[i64]) -> Result<usize, String> {
+        self.with(path, |connection| {
+            let transaction = connection.transaction().map_err(db_error)?;
+            let mut removed = 0;
+            for &id in ids {
+                removed += transaction
+                    .execute("DELETE FROM messages WHERE id = ?", [id])
+                    .map_err(db_error)?;
+            }
+            transaction.commit().map_err(db_error)?;
+            Ok(removed)
+        })
+    }
+
+    /// The original file of a message and its name
+    pub fn message(&self, path: &Path, id: i64) -> Result<(String, Vec<u8>), String> {
+        self.with(path, |connection| {
+            connection
+                .query_row("SELECT file_name, data FROM messages WHERE id = ?", [id], |row| {
+                    Ok((row.get(0)?, row.get(1)?))
+                })
+                .optional()
+                .map_err(db_error)?
+                .ok_or_else(|| format!("No message {} in the archive", id))
+        })
+    }
+
+    /// The name of the file a message was imported from, without reading the file
+    pub fn file_name(&self, path: &Path, id: i64) -> Result<String, String> {
+        self.with(path, |connection| {
+            connection
+                .query_row("SELECT file_name FROM messages WHERE id = ?", [id], |row| {
+                    row.get(0)
+                })
+                .optional()
+                .map_err(db_error)?
+                .ok_or_else(|| format!("No message {} in the archive", id))
+        })
+    }
+
+    /// Save the original files of messages to a directory. Existing files are never
+    /// overwritten; every message gets a result, so one failure does not stop the rest.
+    pub fn export(&self, path: &Path, ids: &[i64], dir: &Path) -> Result<Vec<SaveResult>, String> {
+        Ok(ids
+            .iter()
+            .map(|&id| match self.message(path, id) {
+                Ok((file_name, data)) => {
+                    let saved = attachments::write_unique(dir, &file_name, &data);
+                    SaveResult {
+                        file_name,
+                        path: saved.as_ref().ok().map(|path| path.to_string_lossy().to_string()),
+                        error: saved.err(),
+                    }
+                }
+                Err(e) => SaveResult {
+                    file_name: id.to_string(),
+                    path: None,
+                    error: Some(e),
+                },
+            })
+            .collect())
+    }
+}
@@ -0,0 +1,195 @@
+use crate::file_type;
+use crate::overrides::LOG_LEVELS;
+use std::path::{Path, PathBuf};
+
+/// Command-line options that take a value, e.g. `--profile <name>`
+const VALUE_OPTIONS: [&str; 5] = [
+    "--profile",
+    "--data-dir",
+    "--log-level",
+    "--log-file",
+    "--export",
+];
+/// Command-line options without a value
+const SWITCH_OPTIONS: [&str; 9] = [
+    "--offline",
+    "--read-only",
+    "--kiosk",
+    "--verbose",
+    "--no-gui",
+    "--register-associations",
+    "--extract-attachments",
+    "-h",
+    "--help",
+];
+
+/// Formats of `--export`, as for `msgreader convert`
+pub const EXPORT_FORMATS: [&str; 4] = ["eml", "pdf", "html", "json"];
+
+/// Options and files of a start of the app
+#[derive(Clone, Default)]
+pub struct StartupArgs {
+    pub profile: Option<String>,
+    pub data_dir: Option<String>,
+    /// `--log-level`, or `debug` for `--verbose`
+    pub log_level: Option<String>,
+    /// Log to this file instead of the log folder
+    pub log_file: Option<PathBuf>,
+    pub offline: bool,
+    pub read_only: bool,
+    pub kiosk: bool,
+    /// Exit after the actions instead of opening the window
+    pub no_gui: bool,
+    /// Convert the files to this format next to them
+    pub export: Option<String>,
+    /// Save the attachments of the files to a folder next to each
+    pub extract_attachments: bool,
+    pub register_associations: bool,
+    pub help: bool,
+    /// Files and `msgreader://` links in the order given, files with absolute paths
+    pub files: Vec<String>,
+}
+
+impl StartupArgs {
+    /// Whether the options ask for something to be done before the window opens
+    pub fn has_actions(&self) -> bool {
+        self.export.is_some() || self.extract_attachments || self.register_associations
+    }
+
+    /// Whether the files are converted or extracted rather than opened
+    fn processes_files(&self) -> bool {
+        self.export.is_some() || self.extract_attachments
+    }
+}
+
+/// The type a file is opened as: it exists and its content or extension is of a type
+/// the app opens, so a message saved as .tmp opens as well
+fn check_file(path: &Path) -> Result<&'static str, String> {
+    if !path.is_file() {
+        return Err(format!("No such file: {}", path.display()));
+    }
+    file_type::open_type(path).ok_or_else(|| format!("Not a message file: {}", path.display()))
+}
+
+/// Parse the command line of the app (program name first), with relative paths resolved
+/// against `cwd`. Everything after `--` is a file, even if it starts with `-`. Unknown
+/// options, missing values and invalid combinations are errors. Files that do not exist
+/// or that the app cannot open are logged and left out, so one bad path does not keep
+/// the others from opening; with `--export` or `--extract-attachments` they are errors.
+pub fn parse(args: &[String], cwd: &Path) -> Result<StartupArgs, String> {
+    let mut parsed = StartupArgs::default();
+    let mut files = Vec::new();
+    let mut verbose = false;
+
+    let mut rest = args.iter().skip(1);
+    while let Some(arg) = rest.next() {
+        if arg == "--" {
+            files.extend(rest.by_ref().cloned());
+            break;
+        }
+        // macOS adds a process serial number when the app is started from the Finder
+        if arg.starts_with("-psn_") {
+            continue;
+        }
+        if !arg.starts_with('-') {
+            files.push(arg.clone());
+            continue;
+        }
+
+        let (name, inline) = match arg.split_once('=') {
+            Some((name, value)) if arg.starts_with("--") => (name, Some(value.to_string())),
+            _ => (arg.as_str(), None),
+        };
+        if SWITCH_OPTIONS.contains(&name) {
+            if inline.is_some() {
+                return Err(format!("{} does not take a value", name));
+            }
+        } else if !VALUE_OPTIONS.contains(&name) {
+            return Err(format!("Unknown option: {}", name));
+        }
+        let mut value = || {
+            inline
+                .clone()
+                .or_else(|| rest.next().filter(|value| !value.starts_with("--")).cloned())
+                .filter(|value| !value.is_empty())
+                .ok_or_else(|| format!("Missing value for {}", name))
+        };
+
+        match name {
+            "--profile" => parsed.profile = Some(value()?),
+            "--data-dir" => parsed.data_dir = Some(value()?),
+            "--log-level" => {
+                let level = value()?.trim().to_lowercase();
+                if !LOG_LEVELS.contains(&level.as_str()) {
+                    return Err(format!(
+                        "Invalid log level: {} (use {})",
+                        level,
+                        LOG_LEVELS.join(", ")
+                    ));
+                }
+                parsed.log_level = Some(level);
+            }
+            "--log-file" => parsed.log_file = Some(cwd.join(value()?)),
+            "--export" => {
+                let format = value()?.to_lowercase();
+                if !EXPORT_FORMATS.contains(&format.as_str()) {
+                    return Err(format!(
+                        "Unknown export format: {} (use {})",
+                        format,
+                        EXPORT_FORMATS.join(", ")
+                    ));
+                }
+                parsed.export = Some(format);
+            }
+            "--offline" => parsed.offline = true,
+            "--read-only" => parsed.read_only = true,
+            "--kiosk" => parsed.kiosk = true,
+            "--verbose" => verbose = true,
+            "--no-gui" => parsed.no_gui = true,
+            "--register-associations" => parsed.register_associations = true,
+            "--extract-attachments" => parsed.extract_attachments = true,
+            _ => parsed.help = true,
+        }
+    }
+
+    if verbose && parsed.log_level.is_none() {
+        parsed.log_level = Some("debug".to_string());
+    }
+    if parsed.no_gui && !parsed.has_actions() && !parsed.help {
+        return Err(
+            "--no-gui needs --export, --extract-attachments or --register-associations"
+                .to_string(),
+        );
+    }
+
+    for file in files {
+        if crate::deep_link::is_link(&file) {
+            if parsed.processes_files() {
+                return Err(format!("Links cannot be exported or extracted: {}", file));
+            }
+            parsed.files.push(file);
+            continue;
+        }
+        let path = cwd.join(&file);
+        match check_file(&path) {
+            Ok(kind) if parsed.processes_files() && kind != "msg" && kind != "eml" => {
+                return Err(format!(
+                    "Only .msg and .eml files can be exported or extracted: {}",
+                    file
+                ));
+            }
+            Ok(_) => parsed.files.push(path.to_string_lossy().to_string()),
+            Err(e) if parsed.processes_files() => return Err(e),
+            Err(e) => log_warn!("{}", e),
+        }
+    }
+    if parsed.processes_files() && parsed.files.is_empty() {
+        let option = if parsed.export.is_some() {
+            "--export"
+        } else {
+            "--extract-attachments"
+        };
+        return Err(format!("{} needs at least one message file", option));
+    }
+    Ok(parsed)
+}
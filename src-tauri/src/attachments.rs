@@ -0,0 +1,118 @@
+use std::fs::OpenOptions;
+use std::io::{ErrorKind, Write};
+use std::path::{Path, PathBuf};
+
+/// Device names Windows reserves in any directory, with or without an extension
+#[cfg(windows)]
+const RESERVED_NAMES: [&str; 22] = [
+    "CON", "PRN", "AUX", "NUL", "COM1", "COM2", "COM3", "COM4", "COM5", "COM6", "COM7", "COM8",
+    "COM9", "LPT1", "LPT2", "LPT3", "LPT4", "LPT5", "LPT6", "LPT7", "LPT8", "LPT9",
+];
+
+/// Saving conflicting names gives up after this many numbered candidates
+const MAX_DUPLICATES: u32 = 10_000;
+
+/// An attachment to save, as sent by the frontend
+#[derive(serde::Deserialize)]
+#[serde(rename_all = "camelCase")]
+pub struct AttachmentFile {
+    pub file_name: String,
+    pub base64_content: String,
+}
+
+/// Result for one attachment: the saved path or the error
+#[derive(serde::Serialize)]
+#[serde(rename_all = "camelCase")]
+pub struct SaveResult {
+    pub file_name: String,
+    pub path: Option<String>,
+    pub error: Option<String>,
+}
+
+/// Make an attachment name safe for this OS: no path components, no characters the
+/// file system rejects and, on Windows, no reserved device names or trailing dots
+pub fn safe_file_name(name: &str) -> String {
+    let illegal = |c: char| {
+        c.is_control() || c == '/' || c == '\\' || (cfg!(windows) && r#"<>:"|?*"#.contains(c))
+    };
+    let safe: String = name.chars().map(|c| if illegal(c) { '_' } else { c }).collect();
+    #[cfg(windows)]
+    let safe = windows_safe_name(&safe);
+
+    let safe = safe.trim();
+    if safe.is_empty() || safe == "." || safe == ".." {
+        "attachment".to_string()
+    } else {
+        safe.to_string()
+    }
+}
+
+/// Windows drops trailing dots and spaces and cannot create files named like devices
+#[cfg(windows)]
+fn windows_safe_name(name: &str) -> String {
+    let mut safe = name.trim_end_matches(['.', ' ']).to_string();
+    let stem = safe.split('.').next().unwrap_or("").trim().to_uppercase();
+    if RESERVED_NAMES.contains(&stem.as_str()) {
+        safe.insert(0, '_');
+    }
+    safe
+}
+
+/// `invoice.pdf`, `invoice (1).pdf`, `invoice (2).pdf`, ...
+pub(crate) fn numbered_name(name: &str, number: u32) -> String {
+    if number == 0 {
+        return name.to_string();
+    }
+    match name.rfind('.').filter(|&dot| dot > 0) {
+        Some(dot) => format!("{} ({}){}", &name[..dot], number, &name[dot..]),
+        None => format!("{} ({})", name, number),
+    }
+}
+
+/// Write a file under a name that does not exist yet in `dir`, returns its path
+pub fn write_unique(dir: &Path, name: &str, bytes: &[u8]) -> Result<PathBuf, String> {
+    for number in 0..MAX_DUPLICATES {
+        let path = dir.join(numbered_name(name, number));
+        // create_new fails instead of overwriting, also if another program just created it
+        match OpenOptions::new().write(true).create_new(true).open(&path) {
+            Ok(mut file) => {
+                return file
+                    .write_all(bytes)
+                    .map(|_| path)
+                    .map_err(|e| format!("Failed to write file: {}", e));
+            }
+            Err(e) if e.kind() == ErrorKind::AlreadyExists => continue,
+            Err(e) => return Err(format!("Failed to create file: {}", e)),
+        }
+    }
+    Err("Too many files with this name".to_string())
+}
+
+/// Save attachments to a directory without overwriting files; every attachment gets a
+/// result, so one failure does not stop the rest. `check` runs on the decoded content
+/// before it is written and may refuse it (e.g. the antivirus scan).
+pub fn save_all(
+    dir: &Path,
+    files: &[AttachmentFile],
+    check: impl Fn(&str, &[u8]) -> Result<(), String>,
+) -> Vec<SaveResult> {
+    use base64::{engine::general_purpose::STANDARD, Engine as _};
+
+    files
+        .iter()
+        .map(|attachment| {
+            let saved = STANDARD
+                .decode(&attachment.base64_content)
+                .map_err(|e| format!("Failed to decode base64: {}", e))
+                .and_then(|bytes| {
+                    check(&attachment.file_name, &bytes)?;
+                    write_unique(dir, &safe_file_name(&attachment.file_name), &bytes)
+                });
+            SaveResult {
+                file_name: attachment.file_name.clone(),
+                path: saved.as_ref().ok().map(|path| path.to_string_lossy().to_string()),
+                error: saved.err(),
+            }
+        })
+        .collect()
+}
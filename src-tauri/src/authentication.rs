@@ -0,0 +1,897 @@
+use crate::headers::{self, Field};
+use base64::{engine::general_purpose::STANDARD, Engine as _};
+use hickory_resolver::error::ResolveErrorKind;
+use hickory_resolver::Resolver;
+use rsa::pkcs1::DecodeRsaPublicKey;
+use rsa::pkcs8::DecodePublicKey;
+use rsa::{Pkcs1v15Sign, RsaPublicKey};
+use sha2::{Digest, Sha256};
+use std::collections::BTreeMap;
+
+/// Second-level labels under which country code domains are registered (`example.co.uk`)
+const SECOND_LEVEL_LABELS: [&str; 10] = [
+    "ac", "co", "com", "edu", "go", "gov", "ne", "net", "or", "org",
+];
+
+/// Reason given for the checks that need DNS when networking is disabled
+const OFFLINE_REASON: &str = "Not checked (offline)";
+
+/// TXT records of a DNS name, empty if the name has none
+type Lookup<'a> = &'a dyn Fn(&str) -> Result<Vec<String>, String>;
+
+/// A result that a receiving server recorded in an `Authentication-Results` header
+#[derive(serde::Serialize)]
+#[serde(rename_all = "camelCase")]
+pub struct RecordedResult {
+    /// Server that added the header, e.g. `mx.google.com`
+    pub authserv_id: String,
+    /// `dkim`, `spf`, `dmarc`, `arc`, ...
+    pub method: String,
+    pub result: String,
+    pub reason: String,
+    /// Properties such as `header.d` or `smtp.mailfrom`
+    pub properties: BTreeMap<String, String>,
+}
+
+/// Outcome of verifying one `DKIM-Signature` header
+#[derive(serde::Serialize)]
+#[serde(rename_all = "camelCase")]
+pub struct DkimResult {
+    pub domain: String,
+    pub selector: String,
+    pub algorithm: String,
+    /// `pass`, `fail`, `neutral`, `temperror` or `permerror` (RFC 8601)
+    pub result: &'static str,
+    pub reason: String,
+    /// Whether the signing domain matches the From domain as DMARC requires
+    pub aligned: bool,
+}
+
+/// SPF result of the topmost `Received-SPF` header (or `Authentication-Results`)
+#[derive(serde::Serialize)]
+#[serde(rename_all = "camelCase")]
+pub struct SpfResult {
+    pub result: String,
+    /// Domain of the envelope sender (or the HELO name) the result applies to
+    pub domain: String,
+    pub client_ip: String,
+    pub aligned: bool,
+}
+
+#[derive(serde::Serialize)]
+#[serde(rename_all = "camelCase")]
+pub struct DmarcResult {
+    /// `pass`, `fail`, `none` (no DMARC record) or `temperror`
+    pub result: &'static str,
+    /// Published policy (`none`, `quarantine` or `reject`), empty without a record
+    pub policy: String,
+    pub reason: String,
+}
+
+#[derive(serde::Serialize)]
+#[serde(rename_all = "camelCase")]
+pub struct AuthenticationReport {
+    pub from_domain: String,
+    /// Results of all `Authentication-Results` headers, the latest (topmost) first
+    pub recorded: Vec<RecordedResult>,
+    pub dkim: Vec<DkimResult>,
+    pub spf: Option<SpfResult>,
+    pub dmarc: DmarcResult,
+    /// False for .msg files: they keep the headers but not the original body, so DKIM
+    /// body hashes cannot be checked
+    pub body_checked: bool,
+    /// False in offline mode: DKIM keys and DMARC policies were not looked up
+    pub dns_checked: bool,
+}
+
+/// Text without `(comments)`; quoted strings are kept
+fn strip_comments(value: &str) -> String {
+    let mut out = String::with_capacity(value.len());
+    let (mut depth, mut quoted, mut escaped) = (0, false, false);
+    for c in value.chars() {
+        if escaped {
+            escaped = false;
+        } else if c == '\\' {
+            escaped = true;
+        } else if c == '"' && depth == 0 {
+            quoted = !quoted;
+        } else if c == '(' && !quoted {
+            depth += 1;
+            out.push(' ');
+            continue;
+        } else if c == ')' && !quoted && depth > 0 {
+            depth -= 1;
+            continue;
+        }
+        if depth == 0 {
+            out.push(c);
+        }
+    }
+    out
+}
+
+/// Split at separators outside quoted strings, dropping empty parts
+fn split_unquoted(value: &str, separator: impl Fn(char) -> bool) -> Vec<String> {
+    let mut parts = Vec::new();
+    let mut part = String::new();
+    let mut quoted = false;
+    for c in value.chars() {
+        if c == '"' {
+            quoted = !quoted;
+        }
+        if separator(c) && !quoted {
+            parts.push(std::mem::take(&mut part));
+        } else {
+            part.push(c);
+        }
+    }
+    parts.push(part);
+    parts
+        .into_iter()
+        .map(|part| part.trim().to_string())
+        .filter(|part| !part.is_empty())
+        .collect()
+}
+
+fn unquote(value: &str) -> String {
+    let value = value.trim();
+    value
+        .strip_prefix('"')
+        .and_then(|value| value.strip_suffix('"'))
+        .unwrap_or(value)
+        .to_string()
+}
+
+/// The value with the whitespace around `=` removed
+fn tighten(value: &str) -> String {
+    let mut value = value.to_string();
+    while value.contains(" =") || value.contains("= ") {
+        value = value.replace(" =", "=").replace("= ", "=");
+    }
+    value
+}
+
+/// `key=value` pairs of a header value
+fn properties(value: &str) -> BTreeMap<String, String> {
+    split_unquoted(&tighten(value), |c| c == ';' || c.is_whitespace())
+        .iter()
+        .filter_map(|token| token.split_once('='))
+        .map(|(key, value)| (key.to_lowercase(), unquote(value)))
+        .collect()
+}
+
+/// Results of one `Authentication-Results` header (RFC 8601)
+fn authentication_results(value: &str) -> Vec<RecordedResult> {
+    let value = tighten(&strip_comments(value));
+    let mut parts = split_unquoted(&value, |c| c == ';').into_iter();
+    let authserv_id = parts
+        .next()
+        .and_then(|id| id.split_whitespace().next().map(str::to_string))
+        .unwrap_or_default();
+
+    parts
+        .filter_map(|part| {
+            let mut properties = properties(&part);
+            let (method, result) = split_unquoted(&part, char::is_whitespace)
+                .first()?
+                .split_once('=')
+                .map(|(method, result)| (method.to_string(), result.to_lowercase()))?;
+            properties.remove(&method.to_lowercase());
+            let method = method
+                .split('/')
+                .next()
+                .unwrap_or_default()
+                .trim()
+                .to_lowercase();
+            Some(RecordedResult {
+                authserv_id: authserv_id.clone(),
+                reason: properties.remove("reason").unwrap_or_default(),
+                method,
+                result,
+                properties,
+            })
+        })
+        .collect()
+}
+
+/// Domain of an address such as `Name <user@example.com>`, lowercase
+pub(crate) fn address_domain(value: &str) -> String {
+    let address = match (value.rfind('<'), value.rfind('>')) {
+        (Some(start), Some(end)) if start < end => &value[start + 1..end],
+        _ => value,
+    };
+    address
+        .rsplit_once('@')
+        .map(|(_, domain)| domain.trim().trim_end_matches('.').to_lowercase())
+        .unwrap_or_default()
+}
+
+/// Registrable part of a domain, e.g. `mail.example.co.uk` -> `example.co.uk`. Without
+/// the public suffix list this keeps two labels, or three under second-level labels of
+/// country codes.
+pub(crate) fn organizational_domain(domain: &str) -> String {
+    let labels: Vec<&str> = domain
+        .split('.')
+        .filter(|label| !label.is_empty())
+        .collect();
+    let n = labels.len();
+    let keep = if n >= 3 && labels[n - 1].len() == 2 && SECOND_LEVEL_LABELS.contains(&labels[n - 2])
+    {
+        3
+    } else {
+        2
+    };
+    labels[n.saturating_sub(keep)..].join(".")
+}
+
+/// DMARC identifier alignment: the same domain (strict) or organizational domain (relaxed)
+fn is_aligned(domain: &str, from_domain: &str, strict: bool) -> bool {
+    if domain.is_empty() || from_domain.is_empty() {
+        return false;
+    }
+    if strict {
+        domain.eq_ignore_ascii_case(from_domain)
+    } else {
+        organizational_domain(&domain.to_lowercase()) == organizational_domain(from_domain)
+    }
+}
+
+/// Tags of a DKIM signature or key record (`v=1; a=rsa-sha256; ...`)
+fn tags(value: &str) -> BTreeMap<String, String> {
+    value
+        .split(';')
+        .filter_map(|tag| tag.split_once('='))
+        .map(|(name, value)| (name.trim().to_string(), value.trim().to_string()))
+        .collect()
+}
+
+fn without_whitespace(value: &str) -> String {
+    value.chars().filter(|c| !c.is_whitespace()).collect()
+}
+
+fn canonical_header(raw: &[u8], relaxed: bool) -> Vec<u8> {
+    let mut out = Vec::with_capacity(raw.len() + 2);
+    if !relaxed {
+        out.extend_from_slice(raw);
+        out.extend_from_slice(b"\r\n");
+        return out;
+    }
+
+    let colon = raw.iter().position(|&b| b == b':').unwrap_or(raw.len());
+    out.extend_from_slice(
+        String::from_utf8_lossy(&raw[..colon])
+            .trim()
+            .to_lowercase()
+            .as_bytes(),
+    );
+    out.push(b':');
+    let mut space = false;
+    let start = out.len();
+    for &b in raw.get(colon + 1..).unwrap_or_default() {
+        match b {
+            b'\r' | b'\n' => {}
+            b' ' | b'\t' => space = true,
+            _ => {
+                if space && out.len() > start {
+                    out.push(b' ');
+                }
+                space = false;
+                out.push(b);
+            }
+        }
+    }
+    out.extend_from_slice(b"\r\n");
+    out
+}
+
+fn canonical_body(body: &[u8], relaxed: bool) -> Vec<u8> {
+    if !relaxed {
+        let mut end = body.len();
+        while end >= 2 && &body[end - 2..end] == b"\r\n" {
+            end -= 2;
+        }
+        let mut out = body[..end].to_vec();
+        out.extend_from_slice(b"\r\n");
+        return out;
+    }
+
+    let mut out = Vec::with_capacity(body.len());
+    for line in body.split(|&b| b == b'\n') {
+        let line = line.strip_suffix(b"\r").unwrap_or(line);
+        let mut space = false;
+        for &b in line {
+            if b == b' ' || b == b'\t' {
+                space = true;
+            } else {
+                if space {
+                    out.push(b' ');
+                }
+                space = false;
+                out.push(b);
+            }
+        }
+        out.extend_from_slice(b"\r\n");
+    }
+    while out.ends_with(b"\r\n\r\n") {
+        out.truncate(out.len() - 2);
+    }
+    if out == b"\r\n" {
+        out.clear();
+    }
+    out
+}
+
+/// The signature header with the value of its `b=` tag removed, as it is hashed
+fn without_signature(raw: &[u8]) -> Vec<u8> {
+    let mut out = Vec::with_capacity(raw.len());
+    for (i, part) in raw.split(|&b| b == b';').enumerate() {
+        if i > 0 {
+            out.push(b';');
+        }
+        let Some(eq) = part.iter().position(|&b| b == b'=') else {
+            out.extend_from_slice(part);
+            continue;
+        };
+        // The first tag follows the header name
+        let name = match i {
+            0 => part[..eq].rsplit(|&b| b == b':').next().unwrap_or_default(),
+            _ => &part[..eq],
+        };
+        if String::from_utf8_lossy(name).trim() == "b" {
+            out.extend_from_slice(&part[..=eq]);
+        } else {
+            out.extend_from_slice(part);
+        }
+    }
+    out
+}
+
+/// The headers named in `h=`, canonicalized. Repeated names take instances from the
+/// bottom up; names without another instance add nothing.
+fn signed_headers(fields: &[Field], names: &str, relaxed: bool) -> Vec<u8> {
+    let mut used = vec![false; fields.len()];
+    let mut out = Vec::new();
+    for name in names.split(':') {
+        let name = name.trim().to_lowercase();
+        if let Some(i) = (0..fields.len())
+            .rev()
+            .find(|&i| !used[i] && fields[i].name == name)
+        {
+            used[i] = true;
+            out.extend(canonical_header(&fields[i].raw, relaxed));
+        }
+    }
+    out
+}
+
+/// The RSA key published for a selector, or the result to report without one
+fn public_key(lookup: Lookup, name: &str) -> Result<(RsaPublicKey, bool), (&'static str, String)> {
+    let records = lookup(name).map_err(|e| ("temperror", e))?;
+    let Some(record) = records
+        .iter()
+        .map(|record| tags(record))
+        .find(|tags| tags.contains_key("p"))
+    else {
+        return Err(("permerror", format!("No DKIM key is published at {}", name)));
+    };
+
+    if record.get("k").is_some_and(|k| k != "rsa") {
+        return Err(("permerror", format!("Unsupported key type {}", record["k"])));
+    }
+    let key = without_whitespace(&record["p"]);
+    if key.is_empty() {
+        return Err(("permerror", "The key has been revoked".to_string()));
+    }
+    let der = STANDARD.decode(key).map_err(|_| {
+        (
+            "permerror",
+            "The published key is not valid base64".to_string(),
+        )
+    })?;
+    let key = RsaPublicKey::from_public_key_der(&der)
+        .or_else(|_| RsaPublicKey::from_pkcs1_der(&der))
+        .map_err(|_| {
+            (
+                "permerror",
+                "The published key is not an RSA key".to_string(),
+            )
+        })?;
+    let testing = record
+        .get("t")
+        .is_some_and(|flags| flags.split(':').any(|f| f.trim() == "y"));
+    Ok((key, testing))
+}
+
+/// Verify one DKIM signature (RFC 6376). Without a body only the header hash is
+/// checked. The `x=` expiry is ignored: archived messages are read long after delivery.
+fn verify_dkim(
+    fields: &[Field],
+    body: Option<&[u8]>,
+    signature: &Field,
+    lookup: Lookup,
+    from_domain: &str,
+    strict: bool,
+) -> DkimResult {
+    let tags = tags(&signature.value());
+    let domain = tags.get("d").map(|d| d.to_lowercase()).unwrap_or_default();
+    let mut result = DkimResult {
+        aligned: is_aligned(&domain, from_domain, strict),
+        domain,
+        selector: tags.get("s").cloned().unwrap_or_default(),
+        algorithm: tags.get("a").cloned().unwrap_or_default(),
+        result: "permerror",
+        reason: String::new(),
+    };
+
+    if let Some(missing) = ["v", "a", "b", "bh", "d", "h", "s"]
+        .iter()
+        .find(|tag| !tags.contains_key(**tag))
+    {
+        result.reason = format!("The signature has no {}= tag", missing);
+        return result;
+    }
+    if tags["v"] != "1" {
+        result.reason = format!("Unsupported DKIM version {}", tags["v"]);
+        return result;
+    }
+    match result.algorithm.as_str() {
+        "rsa-sha256" => {}
+        "rsa-sha1" => {
+            result.reason = "rsa-sha1 signatures are no longer accepted (RFC 8301)".to_string();
+            return result;
+        }
+        algorithm => {
+            result.reason = format!("Unsupported algorithm {}", algorithm);
+            return result;
+        }
+    }
+    if !tags["h"]
+        .split(':')
+        .any(|name| name.trim().eq_ignore_ascii_case("from"))
+    {
+        result.reason = "The signature does not cover the From header".to_string();
+        return result;
+    }
+
+    let canonicalization = tags.get("c").map(String::as_str).unwrap_or("simple/simple");
+    let (header_c, body_c) = canonicalization
+        .split_once('/')
+        .unwrap_or((canonicalization, "simple"));
+    let (relaxed_header, relaxed_body) = (header_c == "relaxed", body_c == "relaxed");
+
+    if let Some(body) = body {
+        let mut canonical = canonical_body(body, relaxed_body);
+        if let Some(length) = tags.get("l") {
+            match length.parse::<usize>() {
+                Ok(length) if length <= canonical.len() => canonical.truncate(length),
+                _ => {
+                    result.reason = "The l= body length is invalid".to_string();
+                    return result;
+                }
+            }
+        }
+        if STANDARD.encode(Sha256::digest(&canonical)) != without_whitespace(&tags["bh"]) {
+            result.result = "fail";
+            result.reason = "The body was changed after signing".to_string();
+            return result;
+        }
+    }
+
+    let key_name = format!("{}._domainkey.{}", result.selector, result.domain);
+    let (key, testing) = match public_key(lookup, &key_name) {
+        Ok(key) => key,
+        Err((status, reason)) => {
+            result.result = status;
+            result.reason = reason;
+            return result;
+        }
+    };
+
+    let mut hashed = signed_headers(fields, &tags["h"], relaxed_header);
+    let mut header = canonical_header(&without_signature(&signature.raw), relaxed_header);
+    header.truncate(header.len() - 2);
+    hashed.extend(header);
+    let Ok(signature) = STANDARD.decode(without_whitespace(&tags["b"])) else {
+        result.reason = "The signature is not valid base64".to_string();
+        return result;
+    };
+
+    let verified = key
+        .verify(
+            Pkcs1v15Sign::new::<Sha256>(),
+            &Sha256::digest(&hashed),
+            &signature,
+        )
+        .is_ok();
+    (result.result, result.reason) = match (verified, body.is_some()) {
+        (false, _) => (
+            "fail",
+            "The headers were changed after signing, or the key has been replaced since delivery"
+                .to_string(),
+        ),
+        (true, true) => ("pass", String::new()),
+        (true, false) => (
+            "neutral",
+            "The headers are authentic; the body of an .msg file is not in its original form"
+                .to_string(),
+        ),
+    };
+    if testing {
+        result.reason = format!("{} (the domain is testing DKIM)", result.reason)
+            .trim()
+            .to_string();
+    }
+    result
+}
+
+/// SPF from the topmost `Received-SPF` header, or from `Authentication-Results`
+fn spf_result(
+    fields: &[Field],
+    recorded: &[RecordedResult],
+    from_domain: &str,
+    strict: bool,
+) -> Option<SpfResult> {
+    let return_path = fields
+        .iter()
+        .find(|field| field.name == "return-path")
+        .map(|field| address_domain(&field.value()))
+        .unwrap_or_default();
+
+    let (result, domain, client_ip) =
+        if let Some(field) = fields.iter().find(|field| field.name == "received-spf") {
+            let value = strip_comments(&field.value());
+            let result = value
+                .split_whitespace()
+                .next()
+                .unwrap_or_default()
+                .to_lowercase();
+            let properties = properties(&value);
+            let mut domain = properties
+                .get("envelope-from")
+                .map(|from| address_domain(from));
+            if domain.as_deref().map_or(true, str::is_empty)
+                && properties
+                    .get("identity")
+                    .is_some_and(|identity| identity == "helo")
+            {
+                domain = properties.get("helo").map(|helo| helo.to_lowercase());
+            }
+            let client_ip = properties.get("client-ip").cloned().unwrap_or_default();
+            (result, domain.unwrap_or_default(), client_ip)
+        } else {
+            let spf = recorded.iter().find(|result| result.method == "spf")?;
+            let domain = spf
+                .properties
+                .get("smtp.mailfrom")
+                .map(|from| {
+                    if from.contains('@') {
+                        address_domain(from)
+                    } else {
+                        from.to_lowercase()
+                    }
+                })
+                .unwrap_or_default();
+            (spf.result.clone(), domain, String::new())
+        };
+
+    let domain = if domain.is_empty() {
+        return_path
+    } else {
+        domain
+    };
+    Some(SpfResult {
+        aligned: is_aligned(&domain, from_domain, strict),
+        result,
+        domain,
+        client_ip,
+    })
+}
+
+/// The DMARC record of the From domain or, failing that, of its organizational domain.
+/// The flag tells whether the record was inherited from the organizational domain.
+fn dmarc_record(
+    lookup: Lookup,
+    from_domain: &str,
+) -> Result<Option<(BTreeMap<String, String>, bool)>, String> {
+    let organizational = organizational_domain(from_domain);
+    let mut domains = vec![(from_domain.to_string(), false)];
+    if organizational != from_domain {
+        domains.push((organizational, true));
+    }
+
+    for (domain, inherited) in domains {
+        let records = lookup(&format!("_dmarc.{}", domain))?;
+        if let Some(record) = records
+            .iter()
+            .find(|record| record.trim_start().starts_with("v=DMARC1"))
+        {
+            return Ok(Some((tags(record), inherited)));
+        }
+    }
+    Ok(None)
+}
+
+fn report(data: &[u8], has_body: bool, lookup: Option<Lookup>) -> AuthenticationReport {
+    let offline = |_: &str| -> Result<Vec<String>, String> { Err(OFFLINE_REASON.to_string()) };
+    let dns_checked = lookup.is_some();
+    let lookup = lookup.unwrap_or(&offline);
+    let (fields, body) = headers::split_message(data);
+    let body = has_body.then_some(body);
+
+    let from_domain = fields
+        .iter()
+        .find(|field| field.name == "from")
+        .map(|field| address_domain(&field.value()))
+        .unwrap_or_default();
+    let recorded: Vec<RecordedResult> = fields
+        .iter()
+        .filter(|field| field.name == "authentication-results")
+        .flat_map(|field| authentication_results(&field.value()))
+        .collect();
+
+    let record = if from_domain.is_empty() {
+        Ok(None)
+    } else {
+        dmarc_record(lookup, &from_domain)
+    };
+    let (strict_dkim, strict_spf) = match &record {
+        Ok(Some((tags, _))) => (
+            tags.get("adkim").is_some_and(|mode| mode == "s"),
+            tags.get("aspf").is_some_and(|mode| mode == "s"),
+        ),
+        _ => (false, false),
+    };
+
+    let dkim: Vec<DkimResult> = fields
+        .iter()
+        .filter(|field| field.name == "dkim-signature")
+        .map(|signature| verify_dkim(&fields, body, signature, lookup, &from_domain, strict_dkim))
+        .collect();
+    let spf = spf_result(&fields, &recorded, &from_domain, strict_spf);
+
+    let aligned_dkim = dkim
+        .iter()
+        .find(|dkim| dkim.result == "pass" && dkim.aligned);
+    let aligned_spf = spf
+        .as_ref()
+        .filter(|spf| spf.result == "pass" && spf.aligned);
+    let dmarc = match record {
+        _ if from_domain.is_empty() => DmarcResult {
+            result: "none",
+            policy: String::new(),
+            reason: "The message has no From address".to_string(),
+        },
+        Err(e) => DmarcResult {
+            result: "temperror",
+            policy: String::new(),
+            reason: e,
+        },
+        Ok(None) => DmarcResult {
+            result: "none",
+            policy: String::new(),
+            reason: format!("{} publishes no DMARC policy", from_domain),
+        },
+        Ok(Some((tags, inherited))) => {
+            let policy = inherited
+                .then(|| tags.get("sp"))
+                .flatten()
+                .or_else(|| tags.get("p"))
+                .cloned()
+                .unwrap_or_default();
+            let (result, reason) = match (aligned_dkim, aligned_spf) {
+                (Some(dkim), _) => ("pass", format!("Aligned DKIM signature of {}", dkim.domain)),
+                (None, Some(spf)) => (
+                    "pass",
+                    format!("SPF passed for the aligned domain {}", spf.domain),
+                ),
+                (None, None) if body.is_none() => (
+                    "fail",
+                    "No SPF pass for an aligned domain; DKIM cannot be fully checked for .msg files"
+                        .to_string(),
+                ),
+                (None, None) => ("fail", "No aligned DKIM signature or SPF pass".to_string()),
+            };
+            DmarcResult {
+                result,
+                policy,
+                reason,
+            }
+        }
+    };
+
+    AuthenticationReport {
+        from_domain,
+        recorded,
+        dkim,
+        spf,
+        dmarc,
+        body_checked: has_body,
+        dns_checked,
+    }
+}
+
+/// TXT records of a name from the DNS servers of the system
+fn txt_records(resolver: &Resolver, name: &str) -> Result<Vec<String>, String> {
+    match resolver.txt_lookup(format!("{}.", name)) {
+        Ok(lookup) => Ok(lookup
+            .iter()
+            .map(|txt| {
+                txt.txt_data()
+                    .iter()
+                    .map(|part| String::from_utf8_lossy(part))
+                    .collect::<String>()
+            })
+            .collect()),
+        Err(e) if matches!(e.kind(), ResolveErrorKind::NoRecordsFound { .. }) => Ok(Vec::new()),
+        Err(e) => Err(format!("DNS lookup of {} failed: {}", name, e)),
+    }
+}
+
+/// Check the sender authentication of a message: .eml bytes, or an .msg file whose
+/// transport headers are checked. DKIM keys and DMARC policies are looked up in DNS
+/// now, not as they were at delivery, so a key replaced since makes a signature fail.
+/// Offline nothing is looked up: signatures and DMARC are reported as not checked, and
+/// only the recorded results and SPF are shown.
+pub fn analyze(data: &[u8], offline: bool) -> Result<AuthenticationReport, String> {
+    let (message, has_body) = headers::original_message(data)?;
+    if offline {
+        return Ok(report(&message, has_body, None));
+    }
+    let resolver = Resolver::from_system_conf()
+        .map_err(|e| format!("Failed to read the DNS configuration: {}", e))?;
+    let lookup = |name: &str| txt_records(&resolver, name);
+    Ok(report(&message, has_body, Some(&lookup)))
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    /// The header and body of the example in RFC 6376, section 3.4.5
+    const RFC_EXAMPLE: &[u8] = b"A: X\r\nB : Y\t\r\n\tZ  \r\n\r\n C \r\nD \t E\r\n\r\n\r\n";
+
+    #[test]
+    fn authentication_results_are_parsed() {
+        let results = authentication_results(
+            "mx.example.net; dkim=pass (good signature) header.i=@example.com header.s=sel; \
+             spf=softfail smtp.mailfrom=bounce@example.org; \
+             dmarc=FAIL reason=\"no alignment\" header.from=example.com",
+        );
+        assert_eq!(results.len(), 3);
+        assert!(results
+            .iter()
+            .all(|result| result.authserv_id == "mx.example.net"));
+        assert_eq!(
+            (results[0].method.as_str(), results[0].result.as_str()),
+            ("dkim", "pass")
+        );
+        assert_eq!(results[0].properties["header.i"], "@example.com");
+        assert_eq!(results[0].properties["header.s"], "sel");
+        assert!(!results[0].properties.contains_key("dkim"));
+        assert_eq!(results[1].properties["smtp.mailfrom"], "bounce@example.org");
+        assert_eq!(
+            (results[2].result.as_str(), results[2].reason.as_str()),
+            ("fail", "no alignment")
+        );
+
+        assert!(authentication_results("mx.example.net 1; none").is_empty());
+    }
+
+    #[test]
+    fn comments_are_removed_outside_quotes() {
+        assert_eq!(strip_comments("a (b (c)) \"d (e)\" f"), "a    \"d (e)\" f");
+        assert_eq!(unquote(" \"quoted value\" "), "quoted value");
+        assert_eq!(tighten("a = b;c= d"), "a=b;c=d");
+    }
+
+    #[test]
+    fn tags_are_parsed() {
+        let signature = tags("v=1; a=rsa-sha256; d=example.com;\r\n s=sel; h=from:to; b=ab cd");
+        assert_eq!(signature["d"], "example.com");
+        assert_eq!(signature["s"], "sel");
+        assert_eq!(signature["h"], "from:to");
+        assert_eq!(without_whitespace(&signature["b"]), "abcd");
+        assert_eq!(tags("v=DKIM1; k=rsa; p=")["p"], "");
+    }
+
+    #[test]
+    fn headers_are_canonicalized() {
+        let (fields, _) = headers::split_message(RFC_EXAMPLE);
+        let relaxed: Vec<u8> = fields
+            .iter()
+            .flat_map(|f| canonical_header(&f.raw, true))
+            .collect();
+        let simple: Vec<u8> = fields
+            .iter()
+            .flat_map(|f| canonical_header(&f.raw, false))
+            .collect();
+        assert_eq!(relaxed, b"a:X\r\nb:Y Z\r\n");
+        assert_eq!(simple, b"A: X\r\nB : Y\t\r\n\tZ  \r\n");
+    }
+
+    #[test]
+    fn bodies_are_canonicalized() {
+        let (_, body) = headers::split_message(RFC_EXAMPLE);
+        assert_eq!(canonical_body(body, true), b" C\r\nD E\r\n");
+        assert_eq!(canonical_body(body, false), b" C \r\nD \t E\r\n");
+        assert_eq!(canonical_body(b"", true), b"");
+        assert_eq!(canonical_body(b"", false), b"\r\n");
+    }
+
+    #[test]
+    fn signature_value_is_removed() {
+        assert_eq!(
+            without_signature(b"DKIM-Signature: v=1; b=abc; bh=def"),
+            b"DKIM-Signature: v=1; b=; bh=def"
+        );
+    }
+
+    #[test]
+    fn organizational_domains() {
+        assert_eq!(organizational_domain("mail.example.co.uk"), "example.co.uk");
+        assert_eq!(organizational_domain("a.b.example.com"), "example.com");
+        assert_eq!(organizational_domain("www.example.de"), "example.de");
+        assert_eq!(organizational_domain("example.com"), "example.com");
+        assert_eq!(organizational_domain("co.uk"), "co.uk");
+        assert_eq!(organizational_domain(""), "");
+
+        assert!(is_aligned("mail.example.com", "example.com", false));
+        assert!(!is_aligned("mail.example.com", "example.com", true));
+        assert!(!is_aligned("example.org", "example.com", false));
+        assert_eq!(address_domain("Name <User@Example.COM.>"), "example.com");
+    }
+
+    #[test]
+    fn inherited_dmarc_policy_applies_to_subdomains() {
+        let message = b"From: <news@mail.example.co.uk>\r\n\
+            Received-SPF: pass (sender permitted) client-ip=192.0.2.1; \
+            envelope-from=\"bounce@mail.example.co.uk\";\r\n\r\nHello\r\n";
+        let lookup = |name: &str| -> Result<Vec<String>, String> {
+            Ok(match name {
+                "_dmarc.example.co.uk" => vec!["v=DMARC1; p=reject; sp=quarantine".to_string()],
+                _ => Vec::new(),
+            })
+        };
+
+        let report = report(message, true, Some(&lookup));
+        assert_eq!(report.from_domain, "mail.example.co.uk");
+        let spf = report.spf.as_ref().unwrap();
+        assert_eq!(
+            (spf.result.as_str(), spf.client_ip.as_str()),
+            ("pass", "192.0.2.1")
+        );
+        assert!(spf.aligned);
+        assert_eq!(
+            (report.dmarc.result, report.dmarc.policy.as_str()),
+            ("pass", "quarantine")
+        );
+        assert!(report.dns_checked);
+    }
+
+    #[test]
+    fn nothing_is_looked_up_offline() {
+        let body = b"Hello\r\n";
+        let hash = STANDARD.encode(Sha256::digest(canonical_body(body, true)));
+        let message = format!(
+            "Authentication-Results: mx.example.net; dkim=pass header.d=example.com\r\n\
+             DKIM-Signature: v=1; a=rsa-sha256; c=relaxed/relaxed; d=example.com; s=sel;\r\n \
+             h=from:subject; bh={}; b=c2lnbmF0dXJl\r\n\
+             From: <user@example.com>\r\n\r\n",
+            hash
+        );
+        let mut message = message.into_bytes();
+        message.extend_from_slice(body);
+
+        let report = report(&message, true, None);
+        assert!(!report.dns_checked);
+        assert_eq!(report.recorded.len(), 1);
+        assert_eq!(
+            (report.dkim[0].result, report.dkim[0].reason.as_str()),
+            ("temperror", OFFLINE_REASON)
+        );
+        assert_eq!(
+            (report.dmarc.result, report.dmarc.reason.as_str()),
+            ("temperror", OFFLINE_REASON)
+        );
+    }
+}
@@ -0,0 +1,345 @@
+use crate::file_access::FileAccess;
+use crate::message_window::MAIN_LABEL;
+use interprocess::local_socket::{prelude::*, ListenerOptions, Stream};
+use serde_json::{json, Value};
+use std::collections::HashMap;
+use std::io::{BufRead, BufReader, Write};
+use std::path::{Path, PathBuf};
+use std::sync::atomic::{AtomicBool, AtomicU64, Ordering};
+use std::sync::{mpsc, Mutex};
+use std::time::Duration;
+use tauri::{AppHandle, Emitter, Manager};
+
+/// Event used to forward requests to the frontend
+pub const REQUEST_EVENT: &str = "automation-request";
+
+/// Commands accepted on the socket. All but `ping` are handled by the frontend.
+pub const COMMANDS: &[&str] = &[
+    "ping", "list", "open", "search", "find", "timeline", "delivery", "export",
+];
+
+/// How long to wait for the frontend to answer a request
+const RESPONSE_TIMEOUT: Duration = Duration::from_secs(120);
+
+/// Named pipes are visible to every user and session of the machine, so the pipe name
+/// ends in the SID of the user (`msgreader-automation-S-1-5-21-...`)
+#[cfg(windows)]
+const PIPE_PREFIX: &str = "msgreader-automation-";
+
+/// One request line: `{"id": 1, "command": "open", "params": {...}}`
+#[derive(serde::Deserialize)]
+struct Request {
+    #[serde(default)]
+    id: Value,
+    command: String,
+    #[serde(default)]
+    params: Value,
+}
+
+/// Local automation endpoint (Unix socket / named pipe) of the running app
+pub struct Automation {
+    enabled: AtomicBool,
+    started: AtomicBool,
+    next_id: AtomicU64,
+    pending: Mutex<HashMap<u64, mpsc::Sender<Result<Value, String>>>>,
+}
+
+impl Automation {
+    pub fn new() -> Self {
+        Self {
+            enabled: AtomicBool::new(false),
+            started: AtomicBool::new(false),
+            next_id: AtomicU64::new(1),
+            pending: Mutex::new(HashMap::new()),
+        }
+    }
+
+    /// Enable or disable the endpoint. The listener is created on first enable and
+    /// stays bound until the app exits; while disabled every request is rejected.
+    pub fn set_enabled(&self, app: &AppHandle, enabled: bool) -> Result<String, String> {
+        let endpoint = endpoint(app)?;
+        if enabled && !self.started.swap(true, Ordering::SeqCst) {
+            if let Err(e) = listen(app.clone(), endpoint.clone()) {
+                self.started.store(false, Ordering::SeqCst);
+                return Err(e);
+            }
+        }
+        self.enabled.store(enabled, Ordering::SeqCst);
+        Ok(endpoint.to_string_lossy().to_string())
+    }
+
+    /// Deliver the frontend's answer to a forwarded request
+    pub fn respond(&self, request_id: u64, result: Result<Value, String>) {
+        if let Some(sender) = self.pending.lock().unwrap().remove(&request_id) {
+            let _ = sender.send(result);
+        }
+    }
+
+    /// Forward a request to the frontend and wait for its answer
+    fn forward(&self, app: &AppHandle, command: &str, params: Value) -> Result<Value, String> {
+        let request_id = self.next_id.fetch_add(1, Ordering::SeqCst);
+        let (sender, receiver) = mpsc::channel();
+        self.pending.lock().unwrap().insert(request_id, sender);
+
+        let payload = json!({ "requestId": request_id, "command": command, "params": params });
+        if let Err(e) = app.emit_to(MAIN_LABEL, REQUEST_EVENT, payload) {
+            self.pending.lock().unwrap().remove(&request_id);
+            return Err(format!("Failed to forward request: {}", e));
+        }
+
+        let result = receiver
+            .recv_timeout(RESPONSE_TIMEOUT)
+            .unwrap_or_else(|_| Err("Timed out waiting for the app".to_string()));
+        self.pending.lock().unwrap().remove(&request_id);
+        result
+    }
+}
+
+/// The SID of the user the app runs as, e.g. `S-1-5-21-...`
+#[cfg(windows)]
+fn user_sid() -> std::io::Result<String> {
+    use std::ffi::c_void;
+    use std::io::Error;
+    use std::ptr::null_mut;
+
+    #[link(name = "advapi32")]
+    extern "system" {
+        fn OpenProcessToken(process: *mut c_void, access: u32, token: *mut *mut c_void) -> i32;
+        fn GetTokenInformation(
+            token: *mut c_void,
+            class: u32,
+            information: *mut c_void,
+            length: u32,
+            returned: *mut u32,
+        ) -> i32;
+        fn ConvertSidToStringSidW(sid: *mut c_void, string: *mut *mut u16) -> i32;
+    }
+    #[link(name = "kernel32")]
+    extern "system" {
+        fn GetCurrentProcess() -> *mut c_void;
+        fn CloseHandle(handle: *mut c_void) -> i32;
+        fn LocalFree(memory: *mut c_void) -> *mut c_void;
+    }
+    const TOKEN_QUERY: u32 = 0x0008;
+    // TOKEN_INFORMATION_CLASS value of TOKEN_USER
+    const TOKEN_USER: u32 = 1;
+
+    unsafe {
+        let mut token = null_mut();
+        if OpenProcessToken(GetCurrentProcess(), TOKEN_QUERY, &mut token) == 0 {
+            return Err(Error::last_os_error());
+        }
+        // TOKEN_USER starts with a pointer to the SID, which follows it in the buffer.
+        // 512 bytes hold any SID; u64 keeps the pointer aligned.
+        let mut buffer = [0u64; 64];
+        let mut length = 0;
+        let ok = GetTokenInformation(
+            token,
+            TOKEN_USER,
+            buffer.as_mut_ptr().cast(),
+            std::mem::size_of_val(&buffer) as u32,
+            &mut length,
+        );
+        let error = Error::last_os_error();
+        CloseHandle(token);
+        if ok == 0 {
+            return Err(error);
+        }
+
+        let sid = *buffer.as_ptr().cast::<*mut c_void>();
+        let mut string = null_mut();
+        if ConvertSidToStringSidW(sid, &mut string) == 0 {
+            return Err(Error::last_os_error());
+        }
+        let length = (0..).take_while(|&i| *string.add(i) != 0).count();
+        let sid = String::from_utf16_lossy(std::slice::from_raw_parts(string, length));
+        LocalFree(string.cast());
+        Ok(sid)
+    }
+}
+
+/// Socket path (Unix) or pipe path (Windows) of the endpoint
+pub fn endpoint(app: &AppHandle) -> Result<PathBuf, String> {
+    #[cfg(windows)]
+    {
+        let _ = app;
+        let sid = user_sid().map_err(|e| format!("Failed to read the user's SID: {}", e))?;
+        Ok(PathBuf::from(format!(r"\\.\pipe\{}{}", PIPE_PREFIX, sid)))
+    }
+
+    #[cfg(not(windows))]
+    {
+        // The per-user data directory keeps the socket inaccessible to other users
+        crate::overrides::local_data_dir(app).map(|dir| dir.join("automation.sock"))
+    }
+}
+
+fn create_listener(endpoint: &Path) -> std::io::Result<interprocess::local_socket::Listener> {
+    #[cfg(windows)]
+    let listener = {
+        use interprocess::os::windows::local_socket::ListenerOptionsExt;
+        use interprocess::os::windows::security_descriptor::SecurityDescriptor;
+
+        let _ = endpoint;
+        let sid = user_sid()?;
+        let pipe_name = format!("{}{}", PIPE_PREFIX, sid);
+        let name = pipe_name
+            .as_str()
+            .to_ns_name::<interprocess::local_socket::GenericNamespaced>()?;
+        // Protected DACL with full access for the user only: other users cannot connect,
+        // even to a pipe name they know
+        let sddl = widestring::U16CString::from_str(format!("D:P(A;;GA;;;{})", sid))
+            .map_err(|e| std::io::Error::new(std::io::ErrorKind::InvalidInput, e))?;
+        let descriptor = SecurityDescriptor::deserialize(&sddl)?;
+        ListenerOptions::new()
+            .name(name)
+            .security_descriptor(descriptor)
+            .create_sync()?
+    };
+
+    #[cfg(not(windows))]
+    let listener = {
+        if let Some(dir) = endpoint.parent() {
+            std::fs::create_dir_all(dir)?;
+        }
+        // A socket file left behind by a crashed instance would make binding fail
+        let _ = std::fs::remove_file(endpoint);
+        let name = endpoint.to_fs_name::<interprocess::local_socket::GenericFilePath>()?;
+        ListenerOptions::new().name(name).create_sync()?
+    };
+
+    #[cfg(unix)]
+    {
+        use std::os::unix::fs::PermissionsExt;
+        std::fs::set_permissions(endpoint, std::fs::Permissions::from_mode(0o600))?;
+    }
+
+    Ok(listener)
+}
+
+fn listen(app: AppHandle, endpoint: PathBuf) -> Result<(), String> {
+    let listener = create_listener(&endpoint)
+        .map_err(|e| format!("Failed to open automation endpoint {:?}: {}", endpoint, e))?;
+
+    std::thread::spawn(move || {
+        for connection in listener.incoming() {
+            match connection {
+                Ok(stream) => {
+                    let app = app.clone();
+                    std::thread::spawn(move || serve(&app, stream));
+                }
+                Err(e) => log_warn!("Automation connection failed: {}", e),
+            }
+        }
+    });
+
+    Ok(())
+}
+
+/// Answer newline-delimited JSON requests until the client disconnects
+fn serve(app: &AppHandle, stream: Stream) {
+    let mut reader = BufReader::new(stream);
+    let mut line = String::new();
+
+    loop {
+        line.clear();
+        match reader.read_line(&mut line) {
+            Ok(0) | Err(_) => break,
+            Ok(_) if line.trim().is_empty() => continue,
+            Ok(_) => {}
+        }
+
+        let response = match serde_json::from_str::<Request>(line.trim()) {
+            Ok(request) => {
+                let id = request.id.clone();
+                match handle(app, request) {
+                    Ok(result) => json!({ "id": id, "ok": true, "result": result }),
+                    Err(error) => json!({ "id": id, "ok": false, "error": error }),
+                }
+            }
+            Err(e) => json!({
+                "id": Value::Null,
+                "ok": false,
+                "error": format!("Invalid request: {}", e),
+            }),
+        };
+
+        let stream = reader.get_mut();
+        if writeln!(stream, "{}", response).and_then(|_| stream.flush()).is_err() {
+            break;
+        }
+    }
+}
+
+fn handle(app: &AppHandle, request: Request) -> Result<Value, String> {
+    let automation = app.state::<Automation>();
+    if !automation.enabled.load(Ordering::SeqCst) {
+        return Err("Automation API is disabled".to_string());
+    }
+    if !COMMANDS.contains(&request.command.as_str()) {
+        return Err(format!("Unknown command: {}", request.command));
+    }
+
+    match request.command.as_str() {
+        "ping" => Ok(json!("pong")),
+        "open" => {
+            let paths = request
+                .params
+                .get("paths")
+                .and_then(Value::as_array)
+                .cloned()
+                .unwrap_or_default();
+            if paths.is_empty() {
+                return Err("open requires a non-empty \"paths\" array".to_string());
+            }
+            for path in &paths {
+                let path = Path::new(path.as_str().unwrap_or(""));
+                if !path.is_absolute() || !path.is_file() {
+                    return Err(format!("Not an absolute path to a file: {:?}", path));
+                }
+            }
+            // Clients of the endpoint act for the user, like a file given on the command line
+            let access = app.state::<FileAccess>();
+            for path in &paths {
+                access.grant(Path::new(path.as_str().unwrap_or("")));
+            }
+            automation.forward(app, "open", json!({ "paths": paths }))
+        }
+        "export" => {
+            // The frontend only returns the content; writing is done here so the
+            // webview never gets to choose a destination path itself
+            let target = request.params.get("path").and_then(Value::as_str).map(PathBuf::from);
+            if let Some(target) = &target {
+                if !target.is_absolute() {
+                    return Err(format!("Export path must be absolute: {:?}", target));
+                }
+            }
+
+            let result = automation.forward(app, "export", request.params)?;
+            match target {
+                Some(target) => write_export(&target, &result),
+                None => Ok(result),
+            }
+        }
+        command => automation.forward(app, command, request.params),
+    }
+}
+
+fn write_export(target: &Path, result: &Value) -> Result<Value, String> {
+    use base64::{Engine as _, engine::general_purpose::STANDARD};
+
+    let content = result
+        .get("contentBase64")
+        .and_then(Value::as_str)
+        .ok_or("Export returned no content")?;
+    let bytes = STANDARD
+        .decode(content)
+        .map_err(|e| format!("Failed to decode export: {}", e))?;
+    std::fs::write(target, &bytes).map_err(|e| format!("Failed to write {:?}: {}", target, e))?;
+
+    Ok(json!({
+        "path": target.to_string_lossy(),
+        "fileName": result.get("fileName").cloned().unwrap_or(Value::Null),
+        "size": bytes.len(),
+    }))
+}
@@ -0,0 +1,311 @@
+use crate::notifications::{self, Notifications};
+use crate::{attachments, cli, folder, zip_export};
+use std::collections::HashMap;
+use std::path::{Path, PathBuf};
+use std::sync::atomic::{AtomicBool, AtomicUsize, Ordering};
+use std::sync::{Arc, Mutex};
+use std::time::{Instant, SystemTime, UNIX_EPOCH};
+use tauri::{AppHandle, Emitter, Manager};
+
+/// Emitted after each file of a batch conversion with a `ConversionProgress`
+pub const PROGRESS_EVENT: &str = "conversion-progress";
+/// Emitted once a batch conversion has finished or was cancelled, with its
+/// `ConversionReport`
+pub const FINISHED_EVENT: &str = "conversion-finished";
+
+/// Output formats of batch conversions, with the extension of the converted files
+pub const FORMATS: [(&str, &str); 4] = [
+    ("eml", "eml"),
+    ("pdf", "pdf"),
+    ("json", "json"),
+    ("text", "txt"),
+];
+
+/// Written to the output folder when a conversion ends
+const REPORT_FILE: &str = "conversion-report.json";
+
+/// Most files converted at the same time; parsing is CPU-bound, writing is not worth
+/// more threads
+const MAX_WORKERS: usize = 8;
+
+/// A started batch conversion
+#[derive(serde::Serialize)]
+#[serde(rename_all = "camelCase")]
+pub struct ConversionJob {
+    pub job_id: u64,
+    pub folder: String,
+    pub output: String,
+    /// Number of files to convert
+    pub total: usize,
+}
+
+/// Result of one file
+#[derive(serde::Serialize, Clone)]
+#[serde(rename_all = "camelCase")]
+pub struct ConversionProgress {
+    pub job_id: u64,
+    pub path: String,
+    /// `converted` or `failed`
+    pub status: &'static str,
+    /// The written file, None if the conversion failed
+    pub output: Option<String>,
+    pub error: Option<String>,
+    /// Files finished so far, including this one
+    pub done: usize,
+    pub total: usize,
+}
+
+#[derive(serde::Serialize, Clone)]
+#[serde(rename_all = "camelCase")]
+pub struct ConversionFailure {
+    pub path: String,
+    pub error: String,
+}
+
+/// Summary of a batch conversion, also written to `conversion-report.json`
+#[derive(serde::Serialize, Clone)]
+#[serde(rename_all = "camelCase")]
+pub struct ConversionReport {
+    pub job_id: u64,
+    pub folder: String,
+    pub output: String,
+    pub format: String,
+    pub finished_at: String,
+    pub elapsed_ms: u64,
+    pub total: usize,
+    pub converted: usize,
+    pub failed: usize,
+    /// True if the job was cancelled before all files were converted
+    pub cancelled: bool,
+    pub failures: Vec<ConversionFailure>,
+    /// Where the report was written, None if writing it failed
+    pub report_path: Option<String>,
+}
+
+/// Batch conversions running in the background, with the flag that cancels each
+pub struct BatchJobs {
+    next_id: Mutex<u64>,
+    running: Mutex<HashMap<u64, Arc<AtomicBool>>>,
+}
+
+/// The extension of converted files, None for an unknown format
+pub fn extension(format: &str) -> Option<&'static str> {
+    FORMATS
+        .iter()
+        .find(|&&(name, _)| name == format)
+        .map(|&(_, extension)| extension)
+}
+
+/// Where a file of the source folder is written: the same relative path under the
+/// output folder, with the extension of the format
+fn output_location(
+    file: &Path,
+    folder: &Path,
+    output: &Path,
+    extension: &str,
+) -> (PathBuf, String) {
+    let relative = file.strip_prefix(folder).unwrap_or(file);
+    let dir = match relative.parent() {
+        Some(parent) => output.join(parent),
+        None => output.to_path_buf(),
+    };
+    let stem = file
+        .file_stem()
+        .map(|stem| stem.to_string_lossy().to_string())
+        .unwrap_or_else(|| "message".to_string());
+    (dir, format!("{}.{}", attachments::safe_file_name(&stem), extension))
+}
+
+/// Convert one file; existing files are never overwritten, the new one gets a number
+fn convert_one(file: &Path, folder: &Path, output: &Path, format: &str) -> Result<PathBuf, String> {
+    let extension = extension(format).unwrap_or(format);
+    let bytes = cli::convert_file(file, format)?;
+    let (dir, name) = output_location(file, folder, output, extension);
+    std::fs::create_dir_all(&dir)
+        .map_err(|e| format!("Failed to create {}: {}", dir.display(), e))?;
+    attachments::write_unique(&dir, &name, &bytes)
+}
+
+fn emit<T: serde::Serialize + Clone>(app: &AppHandle, event: &str, payload: T) {
+    if let Err(e) = app.emit(event, payload) {
+        log_warn!("Failed to emit {} event: {}", event, e);
+    }
+}
+
+impl BatchJobs {
+    pub fn new() -> Self {
+        BatchJobs {
+            next_id: Mutex::new(0),
+            running: Mutex::new(HashMap::new()),
+        }
+    }
+
+    /// Start converting the .msg/.eml files of `folder` (and its subfolders if
+    /// `recursive`) into `output`, mirroring the folder structure. Files already in the
+    /// output folder are not converted again. Progress is reported with events; the
+    /// job runs until all files are done or it is cancelled.
+    pub fn start(
+        &self,
+        app: &AppHandle,
+        folder: &Path,
+        output: &Path,
+        format: &str,
+        recursive: bool,
+    ) -> Result<ConversionJob, String> {
+        if extension(format).is_none() {
+            return Err(format!("Unknown conversion format: {}", format));
+        }
+        let mut files = folder::enumerate(folder, recursive)?;
+        if output != folder {
+            files.retain(|file| !file.starts_with(output));
+        }
+        std::fs::create_dir_all(output)
+            .map_err(|e| format!("Failed to create {}: {}", output.display(), e))?;
+
+        let job_id = {
+            let mut next_id = self.next_id.lock().unwrap();
+            *next_id += 1;
+            *next_id
+        };
+        let cancelled = Arc::new(AtomicBool::new(false));
+        self.running.lock().unwrap().insert(job_id, cancelled.clone());
+
+        let job = ConversionJob {
+            job_id,
+            folder: folder.to_string_lossy().to_string(),
+            output: output.to_string_lossy().to_string(),
+            total: files.len(),
+        };
+        log_info!(
+            "Converting {} files in {} to {} ({})",
+            job.total,
+            job.folder,
+            format,
+            job.output
+        );
+
+        let app = app.clone();
+        let folder = folder.to_path_buf();
+        let output = output.to_path_buf();
+        let format = format.to_string();
+        std::thread::spawn(move || {
+            let report = run(&app, job_id, &files, &folder, &output, &format, &cancelled);
+            app.state::<BatchJobs>().running.lock().unwrap().remove(&job_id);
+            log_info!(
+                "Conversion {} finished: {} converted, {} failed{}",
+                job_id,
+                report.converted,
+                report.failed,
+                if report.cancelled { " (cancelled)" } else { "" }
+            );
+            if !report.cancelled {
+                let notification = notifications::conversion_finished(&report);
+                app.state::<Notifications>().show(&app, notification);
+            }
+            emit(&app, FINISHED_EVENT, report);
+        });
+        Ok(job)
+    }
+
+    /// Stop a running conversion after the files being converted. False if the job is
+    /// not running.
+    pub fn cancel(&self, job_id: u64) -> bool {
+        match self.running.lock().unwrap().get(&job_id) {
+            Some(cancelled) => {
+                cancelled.store(true, Ordering::Relaxed);
+                true
+            }
+            None => false,
+        }
+    }
+}
+
+/// Convert the files with a pool of worker threads taking the next file in turn
+fn run(
+    app: &AppHandle,
+    job_id: u64,
+    files: &[PathBuf],
+    folder: &Path,
+    output: &Path,
+    format: &str,
+    cancelled: &AtomicBool,
+) -> ConversionReport {
+    let started = Instant::now();
+    let next = AtomicUsize::new(0);
+    let done = AtomicUsize::new(0);
+    let failures = Mutex::new(Vec::new());
+    let workers = std::thread::available_parallelism()
+        .map(|count| count.get())
+        .unwrap_or(1)
+        .min(MAX_WORKERS)
+        .min(files.len())
+        .max(1);
+
+    std::thread::scope(|scope| {
+        for _ in 0..workers {
+            scope.spawn(|| {
+                while !cancelled.load(Ordering::Relaxed) {
+                    let index = next.fetch_add(1, Ordering::Relaxed);
+                    let Some(file) = files.get(index) else {
+                        break;
+                    };
+                    let result = convert_one(file, folder, output, format);
+                    let path = file.to_string_lossy().to_string();
+                    if let Err(e) = &result {
+                        failures.lock().unwrap().push(ConversionFailure {
+                            path: path.clone(),
+                            error: e.clone(),
+                        });
+                    }
+                    let (output, error) = match result {
+                        Ok(written) => (Some(written.to_string_lossy().to_string()), None),
+                        Err(e) => (None, Some(e)),
+                    };
+                    emit(
+                        app,
+                        PROGRESS_EVENT,
+                        ConversionProgress {
+                            job_id,
+                            path,
+                            status: if error.is_none() { "converted" } else { "failed" },
+                            output,
+                            error,
+                            done: done.fetch_add(1, Ordering::Relaxed) + 1,
+                            total: files.len(),
+                        },
+                    );
+                }
+            });
+        }
+    });
+
+    let mut failures = failures.into_inner().unwrap();
+    failures.sort_by(|a, b| a.path.cmp(&b.path));
+    let done = done.into_inner();
+    let now = SystemTime::now()
+        .duration_since(UNIX_EPOCH)
+        .map(|duration| duration.as_millis() as i64)
+        .unwrap_or(0);
+    let mut report = ConversionReport {
+        job_id,
+        folder: folder.to_string_lossy().to_string(),
+        output: output.to_string_lossy().to_string(),
+        format: format.to_string(),
+        finished_at: zip_export::iso_time(now),
+        elapsed_ms: started.elapsed().as_millis() as u64,
+        total: files.len(),
+        converted: done - failures.len(),
+        failed: failures.len(),
+        cancelled: done < files.len(),
+        failures,
+        report_path: None,
+    };
+    let written = serde_json::to_vec_pretty(&report)
+        .map_err(|e| format!("Failed to serialize report: {}", e))
+        .and_then(|json| attachments::write_unique(output, REPORT_FILE, &json));
+    match written {
+        Ok(path) => report.report_path = Some(path.to_string_lossy().to_string()),
+        Err(e) => log_warn!("Failed to write conversion report: {}", e),
+    }
+    report
+}
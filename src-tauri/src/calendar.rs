@@ -0,0 +1,669 @@
+use crate::headers::CFB_SIGNATURE;
+use crate::msg;
+use mail_parser::{MessageParser, MimeHeaders};
+use std::time::{SystemTime, UNIX_EPOCH};
+
+const MS_PER_DAY: i64 = 86_400_000;
+
+/// Longest line of an .ics file in octets, without the CRLF (RFC 5545 section 3.1)
+const MAX_LINE_OCTETS: usize = 75;
+
+#[derive(serde::Serialize, Clone, Default)]
+#[serde(rename_all = "camelCase")]
+pub struct Attendee {
+    pub name: String,
+    pub email: String,
+    /// ICS role: `REQ-PARTICIPANT`, `OPT-PARTICIPANT`, `NON-PARTICIPANT` (rooms and
+    /// equipment) or `CHAIR`
+    pub role: String,
+    /// ICS participation status, e.g. `ACCEPTED` or `NEEDS-ACTION`; empty if unknown
+    pub status: String,
+}
+
+/// A meeting request, response or cancellation
+#[derive(serde::Serialize, Default)]
+#[serde(rename_all = "camelCase")]
+pub struct Meeting {
+    /// iTIP method: `REQUEST`, `CANCEL`, `REPLY` or `PUBLISH`
+    pub method: String,
+    pub uid: String,
+    pub sequence: u32,
+    pub summary: String,
+    pub location: String,
+    pub description: String,
+    /// Unix time in milliseconds
+    pub start: Option<i64>,
+    pub end: Option<i64>,
+    /// Start and end are the UTC midnights of the first day and of the day after the last
+    pub all_day: bool,
+    /// Start and end are wall-clock times (as if they were UTC) because the invitation
+    /// does not define their timezone
+    pub floating: bool,
+    /// TZID of the start, empty for UTC and floating times
+    pub time_zone: String,
+    pub organizer: Option<Attendee>,
+    pub attendees: Vec<Attendee>,
+    /// The calendar part of an .eml file, which is exported unchanged
+    #[serde(skip)]
+    pub ics: Option<String>,
+}
+
+/// A content line: uppercase name, parameters and value (RFC 5545 section 3.1)
+struct Line {
+    name: String,
+    params: Vec<(String, String)>,
+    value: String,
+}
+
+impl Line {
+    fn param(&self, name: &str) -> Option<&str> {
+        self.params
+            .iter()
+            .find(|(key, _)| key.eq_ignore_ascii_case(name))
+            .map(|(_, value)| value.as_str())
+    }
+}
+
+/// A STANDARD or DAYLIGHT block of a VTIMEZONE
+#[derive(Default)]
+struct Observance {
+    /// First onset, wall-clock milliseconds in the offset before it
+    start: i64,
+    offset_to: i64,
+    /// Yearly onset: month, week of the month (negative from the end) and weekday
+    /// (0 = Sunday)
+    rule: Option<(u32, i32, i64)>,
+}
+
+struct TimeZone {
+    id: String,
+    observances: Vec<Observance>,
+}
+
+/// A date or date-time value (RFC 5545 section 3.3.4 and 3.3.5)
+enum Time {
+    Utc(i64),
+    /// Wall-clock milliseconds, in the zone of the TZID parameter if there is one
+    Local(i64),
+    Date(i64),
+}
+
+/// Days since 1970-01-01 of a date in the proleptic Gregorian calendar
+fn days_from_civil(year: i64, month: u32, day: u32) -> i64 {
+    let year = if month <= 2 { year - 1 } else { year };
+    let era = year.div_euclid(400);
+    let year_of_era = year - era * 400;
+    let month_index = (month as i64 + 9) % 12;
+    let day_of_year = (153 * month_index + 2) / 5 + day as i64 - 1;
+    let day_of_era = year_of_era * 365 + year_of_era / 4 - year_of_era / 100 + day_of_year;
+    era * 146_097 + day_of_era - 719_468
+}
+
+/// Year, month and day of a number of days since 1970-01-01
+pub(crate) fn civil_from_days(days: i64) -> (i64, u32, u32) {
+    let days = days + 719_468;
+    let era = days.div_euclid(146_097);
+    let day_of_era = days - era * 146_097;
+    let year_of_era =
+        (day_of_era - day_of_era / 1460 + day_of_era / 36_524 - day_of_era / 146_096) / 365;
+    let day_of_year = day_of_era - (365 * year_of_era + year_of_era / 4 - year_of_era / 100);
+    let month_index = (5 * day_of_year + 2) / 153;
+    let day = (day_of_year - (153 * month_index + 2) / 5 + 1) as u32;
+    let month = if month_index < 10 {
+        month_index + 3
+    } else {
+        month_index - 9
+    } as u32;
+    let year = year_of_era + era * 400 + if month <= 2 { 1 } else { 0 };
+    (year, month, day)
+}
+
+/// 0 = Sunday
+fn weekday(days: i64) -> i64 {
+    (days + 4).rem_euclid(7)
+}
+
+/// Day of the `week`th `day` of a month; negative weeks count from the end, and a fifth
+/// week that does not exist means the last one
+fn nth_weekday(year: i64, month: u32, week: i32, day: i64) -> i64 {
+    if week > 0 {
+        let first = days_from_civil(year, month, 1);
+        let next = if month == 12 {
+            days_from_civil(year + 1, 1, 1)
+        } else {
+            days_from_civil(year, month + 1, 1)
+        };
+        let mut found = first + (day - weekday(first)).rem_euclid(7) + (week as i64 - 1) * 7;
+        while found >= next {
+            found -= 7;
+        }
+        found
+    } else {
+        let last = if month == 12 {
+            days_from_civil(year + 1, 1, 1) - 1
+        } else {
+            days_from_civil(year, month + 1, 1) - 1
+        };
+        last - (weekday(last) - day).rem_euclid(7) - (-week as i64 - 1) * 7
+    }
+}
+
+/// UTC midnight closest to a time: all-day events of .msg files start at local midnight,
+/// which is less than 12 hours from the UTC midnight of the same day
+pub(crate) fn nearest_midnight(ms: i64) -> i64 {
+    (ms + MS_PER_DAY / 2).div_euclid(MS_PER_DAY) * MS_PER_DAY
+}
+
+fn digits(value: &str, range: std::ops::Range<usize>) -> Option<u32> {
+    value
+        .get(range)
+        .filter(|part| part.bytes().all(|b| b.is_ascii_digit()))?
+        .parse()
+        .ok()
+}
+
+fn parse_time(value: &str) -> Option<Time> {
+    let value = value.trim();
+    let date = days_from_civil(
+        digits(value, 0..4)? as i64,
+        digits(value, 4..6)?,
+        digits(value, 6..8)?,
+    ) * MS_PER_DAY;
+    if value.len() == 8 {
+        return Some(Time::Date(date));
+    }
+    if value.as_bytes().get(8) != Some(&b'T') {
+        return None;
+    }
+    let seconds =
+        digits(value, 9..11)? * 3600 + digits(value, 11..13)? * 60 + digits(value, 13..15)?;
+    let ms = date + seconds as i64 * 1000;
+    match &value[15..] {
+        "Z" | "z" => Some(Time::Utc(ms)),
+        "" => Some(Time::Local(ms)),
+        _ => None,
+    }
+}
+
+/// `+0100` or `-053000` in milliseconds
+fn parse_offset(value: &str) -> Option<i64> {
+    let value = value.trim();
+    let sign = match value.as_bytes().first()? {
+        b'+' => 1,
+        b'-' => -1,
+        _ => return None,
+    };
+    let seconds =
+        digits(value, 1..3)? * 3600 + digits(value, 3..5)? * 60 + digits(value, 5..7).unwrap_or(0);
+    Some(sign * seconds as i64 * 1000)
+}
+
+/// `P1D`, `PT1H30M` or `-PT15M` in milliseconds
+fn parse_duration(value: &str) -> Option<i64> {
+    let value = value.trim();
+    let (sign, rest) = match value.strip_prefix('-') {
+        Some(rest) => (-1, rest),
+        None => (1, value.strip_prefix('+').unwrap_or(value)),
+    };
+    let rest = rest.strip_prefix('P')?;
+    let mut total = 0i64;
+    let mut number = String::new();
+    for c in rest.chars() {
+        let unit = match c {
+            '0'..='9' => {
+                number.push(c);
+                continue;
+            }
+            'T' => continue,
+            'W' => 7 * MS_PER_DAY,
+            'D' => MS_PER_DAY,
+            'H' => 3_600_000,
+            'M' => 60_000,
+            'S' => 1000,
+            _ => return None,
+        };
+        total += number.parse::<i64>().ok()? * unit;
+        number.clear();
+    }
+    Some(sign * total)
+}
+
+/// `FREQ=YEARLY;BYMONTH=3;BYDAY=-1SU`, the only kind of rule timezones use in practice
+fn parse_rule(value: &str) -> Option<(u32, i32, i64)> {
+    let part = |name: &str| {
+        value
+            .split(';')
+            .filter_map(|part| part.split_once('='))
+            .find(|(key, _)| key.eq_ignore_ascii_case(name))
+            .map(|(_, value)| value.trim())
+    };
+    if !part("FREQ")?.eq_ignore_ascii_case("YEARLY") {
+        return None;
+    }
+    let month = part("BYMONTH")?.parse().ok()?;
+    let by_day = part("BYDAY")?;
+    let (week, day) = by_day.split_at(by_day.len().checked_sub(2)?);
+    let day = ["SU", "MO", "TU", "WE", "TH", "FR", "SA"]
+        .iter()
+        .position(|name| day.eq_ignore_ascii_case(name))? as i64;
+    let week = match week {
+        "" => 1,
+        week => week.trim_start_matches('+').parse().ok()?,
+    };
+    Some((month, week, day))
+}
+
+impl TimeZone {
+    /// Offset from UTC of a wall-clock time in this zone
+    fn offset(&self, local: i64) -> Option<i64> {
+        let (year, _, _) = civil_from_days(local.div_euclid(MS_PER_DAY));
+        self.observances
+            .iter()
+            .flat_map(|observance| {
+                let onsets: Vec<i64> = match observance.rule {
+                    Some((month, week, day)) => {
+                        let (first_year, _, _) =
+                            civil_from_days(observance.start.div_euclid(MS_PER_DAY));
+                        let time_of_day = observance.start.rem_euclid(MS_PER_DAY);
+                        [year - 1, year]
+                            .into_iter()
+                            .filter(|onset_year| *onset_year >= first_year)
+                            .map(|onset_year| {
+                                nth_weekday(onset_year, month, week, day) * MS_PER_DAY + time_of_day
+                            })
+                            .collect()
+                    }
+                    None => vec![observance.start],
+                };
+                onsets
+                    .into_iter()
+                    .map(move |onset| (onset, observance.offset_to))
+            })
+            .filter(|(onset, _)| *onset <= local)
+            .max_by_key(|(onset, _)| *onset)
+            .or_else(|| {
+                // Before the first onset: the earliest observance
+                self.observances
+                    .iter()
+                    .min_by_key(|observance| observance.start)
+                    .map(|observance| (observance.start, observance.offset_to))
+            })
+            .map(|(_, offset)| offset)
+    }
+}
+
+/// Undo the escaping of a TEXT value
+fn unescape(value: &str) -> String {
+    let mut text = String::with_capacity(value.len());
+    let mut chars = value.chars();
+    while let Some(c) = chars.next() {
+        if c != '\\' {
+            text.push(c);
+            continue;
+        }
+        match chars.next() {
+            Some('n' | 'N') => text.push('\n'),
+            Some(other) => text.push(other),
+            None => text.push('\\'),
+        }
+    }
+    text
+}
+
+/// Escape a TEXT value; vCards (see contact.rs) escape the same way
+pub(crate) fn escape(text: &str) -> String {
+    text.replace('\\', "\\\\")
+        .replace(';', "\\;")
+        .replace(',', "\\,")
+        .replace("\r\n", "\\n")
+        .replace('\n', "\\n")
+}
+
+/// Unfolded content lines of an .ics file
+fn lines(text: &str) -> Vec<Line> {
+    let mut unfolded: Vec<String> = Vec::new();
+    for line in text.split('\n') {
+        let line = line.strip_suffix('\r').unwrap_or(line);
+        match line.strip_prefix([' ', '\t']) {
+            Some(continuation) if !unfolded.is_empty() => {
+                unfolded.last_mut().unwrap().push_str(continuation)
+            }
+            _ if !line.trim().is_empty() => unfolded.push(line.to_string()),
+            _ => {}
+        }
+    }
+
+    unfolded
+        .iter()
+        .filter_map(|line| {
+            // The value starts at the first colon outside a quoted parameter value
+            let mut quoted = false;
+            let colon = line.char_indices().find_map(|(i, c)| match c {
+                '"' => {
+                    quoted = !quoted;
+                    None
+                }
+                ':' if !quoted => Some(i),
+                _ => None,
+            })?;
+            let mut quoted = false;
+            let mut parts = vec![String::new()];
+            for c in line[..colon].chars() {
+                match c {
+                    '"' => quoted = !quoted,
+                    ';' if !quoted => parts.push(String::new()),
+                    c => parts.last_mut().unwrap().push(c),
+                }
+            }
+            let name = parts.remove(0).trim().to_uppercase();
+            let params = parts
+                .iter()
+                .filter_map(|param| param.split_once('='))
+                .map(|(key, value)| (key.trim().to_uppercase(), value.to_string()))
+                .collect();
+            Some(Line {
+                name,
+                params,
+                value: line[colon + 1..].to_string(),
+            })
+        })
+        .collect()
+}
+
+fn address(value: &str) -> String {
+    let value = value.trim();
+    match value.get(..7) {
+        Some(scheme) if scheme.eq_ignore_ascii_case("mailto:") => value[7..].to_string(),
+        _ => value.to_string(),
+    }
+}
+
+fn attendee(line: &Line) -> Attendee {
+    Attendee {
+        name: line.param("CN").unwrap_or_default().to_string(),
+        email: address(&line.value),
+        role: line
+            .param("ROLE")
+            .unwrap_or("REQ-PARTICIPANT")
+            .to_uppercase(),
+        status: line.param("PARTSTAT").unwrap_or_default().to_uppercase(),
+    }
+}
+
+/// The first event of an iCalendar object, with the method of the object
+pub fn parse_ics(text: &str) -> Option<Meeting> {
+    let mut meeting = Meeting {
+        ics: Some(text.to_string()),
+        ..Meeting::default()
+    };
+    let mut zones: Vec<TimeZone> = Vec::new();
+    let mut event: Vec<Line> = Vec::new();
+    let mut events = 0;
+    let mut stack: Vec<String> = Vec::new();
+
+    for line in lines(text) {
+        match line.name.as_str() {
+            "BEGIN" => {
+                let component = line.value.trim().to_uppercase();
+                match component.as_str() {
+                    "VTIMEZONE" => zones.push(TimeZone {
+                        id: String::new(),
+                        observances: Vec::new(),
+                    }),
+                    "STANDARD" | "DAYLIGHT" if stack.last().is_some_and(|c| c == "VTIMEZONE") => {
+                        if let Some(zone) = zones.last_mut() {
+                            zone.observances.push(Observance::default());
+                        }
+                    }
+                    "VEVENT" => events += 1,
+                    _ => {}
+                }
+                stack.push(component);
+                continue;
+            }
+            "END" => {
+                stack.pop();
+                continue;
+            }
+            _ => {}
+        }
+
+        let path: Vec<&str> = stack.iter().map(String::as_str).collect();
+        match path.as_slice() {
+            ["VCALENDAR"] if line.name == "METHOD" => {
+                meeting.method = line.value.trim().to_uppercase();
+            }
+            ["VCALENDAR", "VTIMEZONE"] if line.name == "TZID" => {
+                if let Some(zone) = zones.last_mut() {
+                    zone.id = line.value.trim().to_string();
+                }
+            }
+            ["VCALENDAR", "VTIMEZONE", "STANDARD" | "DAYLIGHT"] => {
+                let Some(observance) = zones.last_mut().and_then(|z| z.observances.last_mut())
+                else {
+                    continue;
+                };
+                match line.name.as_str() {
+                    "DTSTART" => {
+                        if let Some(Time::Local(start) | Time::Utc(start)) = parse_time(&line.value)
+                        {
+                            observance.start = start;
+                        }
+                    }
+                    "TZOFFSETTO" => observance.offset_to = parse_offset(&line.value).unwrap_or(0),
+                    "RRULE" => observance.rule = parse_rule(&line.value),
+                    _ => {}
+                }
+            }
+            ["VCALENDAR", "VEVENT"] if events == 1 => event.push(line),
+            _ => {}
+        }
+    }
+
+    if events == 0 {
+        return None;
+    }
+    let resolve = |line: &Line| -> Option<(i64, bool, bool)> {
+        match parse_time(&line.value)? {
+            Time::Utc(ms) => Some((ms, false, false)),
+            Time::Date(ms) => Some((ms, true, false)),
+            Time::Local(ms) => {
+                let zone = line.param("TZID").and_then(|id| {
+                    zones
+                        .iter()
+                        .find(|zone| zone.id.trim_start_matches('/') == id.trim_start_matches('/'))
+                });
+                match zone.and_then(|zone| zone.offset(ms)) {
+                    Some(offset) => Some((ms - offset, false, false)),
+                    None => Some((ms, false, true)),
+                }
+            }
+        }
+    };
+
+    let mut duration = None;
+    for line in &event {
+        match line.name.as_str() {
+            "UID" => meeting.uid = line.value.trim().to_string(),
+            "SEQUENCE" => meeting.sequence = line.value.trim().parse().unwrap_or(0),
+            "SUMMARY" => meeting.summary = unescape(&line.value),
+            "LOCATION" => meeting.location = unescape(&line.value),
+            "DESCRIPTION" => meeting.description = unescape(&line.value),
+            "DTSTART" => {
+                if let Some((start, all_day, floating)) = resolve(line) {
+                    meeting.start = Some(start);
+                    meeting.all_day = all_day;
+                    meeting.floating = floating;
+                    meeting.time_zone = line.param("TZID").unwrap_or_default().to_string();
+                }
+            }
+            "DTEND" => meeting.end = resolve(line).map(|(end, _, _)| end),
+            "DURATION" => duration = parse_duration(&line.value),
+            "ORGANIZER" => {
+                meeting.organizer = Some(Attendee {
+                    role: "CHAIR".to_string(),
+                    ..attendee(line)
+                })
+            }
+            "ATTENDEE" => meeting.attendees.push(attendee(line)),
+            _ => {}
+        }
+    }
+    if meeting.end.is_none() {
+        let default = if meeting.all_day { MS_PER_DAY } else { 0 };
+        meeting.end = meeting
+            .start
+            .map(|start| start + duration.unwrap_or(default));
+    }
+    if meeting.method.is_empty() {
+        meeting.method = "PUBLISH".to_string();
+    }
+    Some(meeting)
+}
+
+/// The text of the first text/calendar part or .ics attachment of an .eml file
+fn calendar_part(data: &[u8]) -> Option<String> {
+    let message = MessageParser::default().parse(data)?;
+    let part = message.parts.iter().find(|part| {
+        let calendar = part.content_type().is_some_and(|content_type| {
+            content_type.ctype().eq_ignore_ascii_case("text")
+                && content_type
+                    .subtype()
+                    .is_some_and(|subtype| subtype.eq_ignore_ascii_case("calendar"))
+        });
+        calendar
+            || part
+                .attachment_name()
+                .is_some_and(|name| name.to_lowercase().ends_with(".ics"))
+    })?;
+    Some(String::from_utf8_lossy(part.contents()).into_owned())
+}
+
+/// The meeting of a message (.eml bytes or .msg file), None if it is not a meeting
+/// request, response or cancellation
+pub fn analyze(data: &[u8]) -> Result<Option<Meeting>, String> {
+    if data.starts_with(&CFB_SIGNATURE) {
+        msg::meeting(data)
+    } else {
+        Ok(calendar_part(data).and_then(|text| parse_ics(&text)))
+    }
+}
+
+/// `YYYYMMDD` of the UTC day of a time
+pub(crate) fn format_date(ms: i64) -> String {
+    let (year, month, day) = civil_from_days(ms.div_euclid(MS_PER_DAY));
+    format!("{:04}{:02}{:02}", year, month, day)
+}
+
+fn format_time(ms: i64) -> String {
+    let seconds = ms.rem_euclid(MS_PER_DAY) / 1000;
+    format!(
+        "{}T{:02}{:02}{:02}Z",
+        format_date(ms),
+        seconds / 3600,
+        seconds / 60 % 60,
+        seconds % 60
+    )
+}
+
+/// Split a content line into lines of at most 75 octets, without breaking characters.
+/// vCards fold their lines the same way.
+pub(crate) fn fold(line: &str) -> String {
+    let mut folded = String::with_capacity(line.len() + line.len() / MAX_LINE_OCTETS * 3);
+    let mut length = 0;
+    for c in line.chars() {
+        if length + c.len_utf8() > MAX_LINE_OCTETS {
+            folded.push_str("\r\n ");
+            length = 1;
+        }
+        folded.push(c);
+        length += c.len_utf8();
+    }
+    folded.push_str("\r\n");
+    folded
+}
+
+fn quote(value: &str) -> String {
+    format!("\"{}\"", value.replace('"', "'"))
+}
+
+/// Write an iCalendar object for a meeting read from an .msg file
+fn write_ics(meeting: &Meeting, now: i64) -> String {
+    let mut lines = vec![
+        "BEGIN:VCALENDAR".to_string(),
+        "VERSION:2.0".to_string(),
+        "PRODID:-//msgReader//Meeting export//EN".to_string(),
+        format!("METHOD:{}", meeting.method),
+        "BEGIN:VEVENT".to_string(),
+        format!("UID:{}", meeting.uid),
+        format!("DTSTAMP:{}", format_time(now)),
+        format!("SEQUENCE:{}", meeting.sequence),
+    ];
+    let time = |name: &str, ms: i64| {
+        if meeting.all_day {
+            format!("{};VALUE=DATE:{}", name, format_date(ms))
+        } else {
+            format!("{}:{}", name, format_time(ms))
+        }
+    };
+    lines.extend(meeting.start.map(|start| time("DTSTART", start)));
+    lines.extend(meeting.end.map(|end| time("DTEND", end)));
+    lines.push(format!("SUMMARY:{}", escape(&meeting.summary)));
+    if !meeting.location.is_empty() {
+        lines.push(format!("LOCATION:{}", escape(&meeting.location)));
+    }
+    if !meeting.description.is_empty() {
+        lines.push(format!(
+            "DESCRIPTION:{}",
+            escape(meeting.description.trim_end())
+        ));
+    }
+    if let Some(organizer) = &meeting.organizer {
+        lines.push(format!(
+            "ORGANIZER;CN={}:mailto:{}",
+            quote(&organizer.name),
+            organizer.email
+        ));
+    }
+    for attendee in &meeting.attendees {
+        let status = if attendee.status.is_empty() {
+            String::new()
+        } else {
+            format!(";PARTSTAT={}", attendee.status)
+        };
+        let cutype = if attendee.role == "NON-PARTICIPANT" {
+            ";CUTYPE=RESOURCE"
+        } else {
+            ""
+        };
+        lines.push(format!(
+            "ATTENDEE;CN={};ROLE={}{}{}:mailto:{}",
+            quote(&attendee.name),
+            attendee.role,
+            cutype,
+            status,
+            attendee.email
+        ));
+    }
+    if meeting.method == "CANCEL" {
+        lines.push("STATUS:CANCELLED".to_string());
+    }
+    lines.push("END:VEVENT".to_string());
+    lines.push("END:VCALENDAR".to_string());
+    lines.iter().map(|line| fold(line)).collect()
+}
+
+/// The meeting as an .ics file: the calendar part of an .eml as it is, or written from
+/// the properties of an .msg file
+pub fn ics(meeting: &Meeting) -> String {
+    match &meeting.ics {
+        Some(text) => text.clone(),
+        None => {
+            let now = SystemTime::now()
+                .duration_since(UNIX_EPOCH)
+                .map(|elapsed| elapsed.as_millis() as i64)
+                .unwrap_or(0);
+            write_ics(meeting, now)
+        }
+    }
+}
@@ -0,0 +1,173 @@
+use encoding_rs::{Encoding, EUC_KR, GBK, ISO_2022_JP};
+
+/// Windows code pages (PR_INTERNET_CPID, PR_MESSAGE_CODEPAGE) by the label encoding_rs
+/// knows them under
+const CODEPAGES: &[(u32, &str)] = &[
+    (437, "ibm866"),
+    (866, "ibm866"),
+    (874, "windows-874"),
+    (932, "shift_jis"),
+    (936, "gbk"),
+    (949, "euc-kr"),
+    (950, "big5"),
+    (1200, "utf-16le"),
+    (1201, "utf-16be"),
+    (1250, "windows-1250"),
+    (1251, "windows-1251"),
+    (1252, "windows-1252"),
+    (1253, "windows-1253"),
+    (1254, "windows-1254"),
+    (1255, "windows-1255"),
+    (1256, "windows-1256"),
+    (1257, "windows-1257"),
+    (1258, "windows-1258"),
+    (10000, "macintosh"),
+    (20127, "us-ascii"),
+    (20866, "koi8-r"),
+    (20932, "euc-jp"),
+    (21866, "koi8-u"),
+    (28591, "iso-8859-1"),
+    (28592, "iso-8859-2"),
+    (28593, "iso-8859-3"),
+    (28594, "iso-8859-4"),
+    (28595, "iso-8859-5"),
+    (28596, "iso-8859-6"),
+    (28597, "iso-8859-7"),
+    (28598, "iso-8859-8"),
+    (28603, "iso-8859-13"),
+    (28605, "iso-8859-15"),
+    (50220, "iso-2022-jp"),
+    (50221, "iso-2022-jp"),
+    (50222, "iso-2022-jp"),
+    (50225, "iso-2022-kr"),
+    (50227, "iso-2022-cn"),
+    (50229, "iso-2022-cn"),
+    (51932, "euc-jp"),
+    (51936, "gbk"),
+    (51949, "euc-kr"),
+    (54936, "gb18030"),
+    (65001, "utf-8"),
+];
+
+/// How text in a charset is decoded
+enum Charset {
+    Encoding(&'static Encoding),
+    /// ISO-2022-KR and ISO-2022-CN, see `decode_shifted`
+    Shifted,
+}
+
+/// The label of a code page given as "949", "cp949", "windows-949" or "ibm866"
+fn codepage_label(charset: &str) -> Option<&'static str> {
+    let number = ["cp", "x-cp", "windows-", "ibm", "ms"]
+        .iter()
+        .find_map(|prefix| charset.strip_prefix(prefix))
+        .unwrap_or(charset);
+    let codepage: u32 = number.parse().ok()?;
+    CODEPAGES
+        .iter()
+        .find(|(known, _)| *known == codepage)
+        .map(|(_, label)| *label)
+}
+
+fn resolve(charset: &str) -> Option<Charset> {
+    let charset = charset.trim().trim_matches(|c| c == '"' || c == '\'').to_ascii_lowercase();
+    let label = codepage_label(&charset).unwrap_or(charset.as_str());
+    match label {
+        "iso-2022-kr" | "csiso2022kr" | "iso-2022-cn" | "iso-2022-cn-ext" => {
+            Some(Charset::Shifted)
+        }
+        // The extensions of ISO-2022-JP mostly carry JIS X 0208 too; what encoding_rs
+        // cannot read comes out as U+FFFD instead of the whole text failing
+        "iso-2022-jp-1" | "iso-2022-jp-2" | "iso-2022-jp-3" | "iso-2022-jp-2004"
+        | "csiso2022jp2" => Some(Charset::Encoding(ISO_2022_JP)),
+        _ => Encoding::for_label(label.as_bytes())
+            .filter(|encoding| *encoding != encoding_rs::REPLACEMENT)
+            .map(Charset::Encoding),
+    }
+}
+
+/// Decode text in a MIME charset or Windows code page ("iso-2022-jp", "gb18030", "50225")
+pub fn decode(data: &[u8], charset: &str) -> Result<String, String> {
+    match resolve(charset) {
+        Some(Charset::Encoding(encoding)) => {
+            Ok(encoding.decode_without_bom_handling(data).0.into_owned())
+        }
+        Some(Charset::Shifted) => Ok(decode_shifted(data)),
+        None => Err(format!("Unsupported charset: {}", charset)),
+    }
+}
+
+/// ISO-2022-KR (RFC 1557) and ISO-2022-CN (RFC 1922), which the WHATWG encodings of
+/// encoding_rs leave out: 7-bit text where SO and SI switch between ASCII and the
+/// double-byte set designated by ESC $ ) F, i.e. EUC-KR or GB2312 without the high bits.
+/// The CNS 11643 planes of ISO-2022-CN have no decoder and come out as U+FFFD.
+fn decode_shifted(data: &[u8]) -> String {
+    let mut text = String::with_capacity(data.len());
+    // Double-byte characters with the high bits set, decoded at the next switch
+    let mut pending: Vec<u8> = Vec::new();
+    let mut g1: Option<&'static Encoding> = None;
+    let mut shifted = false;
+
+    let flush = |text: &mut String, pending: &mut Vec<u8>, g1: Option<&'static Encoding>| {
+        if pending.is_empty() {
+            return;
+        }
+        match g1 {
+            Some(encoding) => text.push_str(&encoding.decode_without_bom_handling(pending).0),
+            None => text.extend(std::iter::repeat('\u{fffd}').take(pending.len() / 2)),
+        }
+        pending.clear();
+    };
+
+    let mut i = 0;
+    while i < data.len() {
+        let byte = data[i];
+        match byte {
+            0x1b => {
+                flush(&mut text, &mut pending, g1);
+                let sequence = &data[i..];
+                if sequence.starts_with(b"\x1b$)") && sequence.len() >= 4 {
+                    g1 = match sequence[3] {
+                        b'C' => Some(EUC_KR),
+                        b'A' => Some(GBK),
+                        _ => None,
+                    };
+                    i += 4;
+                } else if sequence.starts_with(b"\x1b$") && sequence.len() >= 4 {
+                    // G2/G3 designations of ISO-2022-CN (CNS 11643 planes)
+                    i += 4;
+                } else if sequence.starts_with(b"\x1bN") || sequence.starts_with(b"\x1bO") {
+                    // Single shift to G2/G3: one character we cannot decode
+                    text.push('\u{fffd}');
+                    i += 4;
+                } else {
+                    text.push('\u{fffd}');
+                    i += 1;
+                }
+                continue;
+            }
+            0x0e => shifted = true,
+            0x0f => {
+                flush(&mut text, &mut pending, g1);
+                shifted = false;
+            }
+            b'\r' | b'\n' => {
+                flush(&mut text, &mut pending, g1);
+                shifted = false;
+                text.push(byte as char);
+            }
+            0x21..=0x7e if shifted => pending.push(byte | 0x80),
+            0x00..=0x7f => {
+                flush(&mut text, &mut pending, g1);
+                text.push(byte as char);
+            }
+            _ => {
+                flush(&mut text, &mut pending, g1);
+                text.push('\u{fffd}');
+            }
+        }
+        i += 1;
+    }
+    flush(&mut text, &mut pending, g1);
+    text
+}
@@ -0,0 +1,569 @@
+use crate::args::{self, StartupArgs};
+use crate::attachments;
+use crate::file_associations;
+use crate::headers::CFB_SIGNATURE;
+use crate::message::Message;
+use crate::overrides;
+use crate::policy::Policy;
+use crate::{eml, msg, pdf};
+use base64::{engine::general_purpose::STANDARD, Engine as _};
+use std::io::{Read, Write};
+use std::path::{Path, PathBuf};
+
+/// Subcommands that run without a window. `convert` takes the options of the npm
+/// package's `msgreader` (src/js/cli.js), so scripts work with either.
+const COMMANDS: [&str; 3] = ["convert", "extract-attachments", "headers"];
+
+/// Input types of `--type`
+const INPUT_TYPES: [&str; 2] = ["msg", "eml"];
+
+pub const USAGE: &str = "Usage: msgreader [options] [--] [<file>...]
+       msgreader convert (--stdin | <file>) [--type msg|eml] [--to eml|pdf|html|json]
+                         [-o <file>] [--no-attachments]
+       msgreader extract-attachments (--stdin | <file>) [--type msg|eml] [-o <dir>]
+       msgreader headers (--stdin | <file>) [--type msg|eml]
+
+Commands:
+  convert              Convert an .msg or .eml message (default: json)
+  extract-attachments  Save the attachments of a message to a directory (default: the
+                       current one); existing files are not overwritten
+  headers              Print the transport headers of a message
+
+Options of the commands:
+  --stdin              Read the message from standard input (or give - as the file)
+  --type <type>        Input type (default: detected from the content)
+  --to <format>        Output format of convert
+  -o, --output <path>  Write to this file or directory instead of standard output
+  --no-attachments     Omit attachment content from JSON output
+  -h, --help           Show this help
+
+Without a command the app opens its window with the files and msgreader:// links given.
+Files that do not exist or are no message files are skipped with a warning. Arguments
+after -- are files, even if they start with -.
+
+Options of the app:
+  --export <format>          Convert the files to eml, pdf, html or json, saved next to
+                             them; existing files are not overwritten
+  --extract-attachments      Save the attachments of the files to a folder named
+                             \"<name> attachments\" next to each
+  --register-associations    Make msgReader the default app for .msg and .eml (Linux;
+                             Windows and macOS leave that to the user)
+  --no-gui                   Exit after --export, --extract-attachments or
+                             --register-associations instead of opening the window
+  --verbose                  Log debug messages (same as --log-level debug)
+  --log-level <level>        debug, info, warning, error or critical
+  --log-file <file>          Write the log to this file instead of the log folder
+  --profile <name>           Start with this profile
+  --data-dir <dir>           Keep all app data in this directory
+  --offline                  Block all outbound connections
+  --read-only                Keep changed settings for the session only
+  --kiosk                    Viewer-only mode";
+
+/// Options of a subcommand
+struct Command {
+    name: String,
+    /// None for standard input
+    input: Option<PathBuf>,
+    /// `msg` or `eml`, None to detect it from the content
+    kind: Option<String>,
+    to: String,
+    output: Option<PathBuf>,
+    /// JSON output includes the content of attachments
+    attachment_content: bool,
+}
+
+/// Error of a command with its exit code: 2 for invalid arguments, 1 otherwise
+struct Failure {
+    code: i32,
+    message: String,
+}
+
+impl From<String> for Failure {
+    fn from(message: String) -> Self {
+        Failure { code: 1, message }
+    }
+}
+
+fn usage_error(message: impl Into<String>) -> Failure {
+    Failure {
+        code: 2,
+        message: message.into(),
+    }
+}
+
+/// Attach to the console of the shell that started the app, which release builds on
+/// Windows do not get by themselves (they are GUI programs)
+#[cfg(windows)]
+fn attach_console() {
+    extern "system" {
+        fn AttachConsole(process_id: u32) -> i32;
+    }
+    const ATTACH_PARENT_PROCESS: u32 = u32::MAX;
+    unsafe {
+        AttachConsole(ATTACH_PARENT_PROCESS);
+    }
+}
+
+#[cfg(not(windows))]
+fn attach_console() {}
+
+/// Run a subcommand if the arguments start with one, or else the actions of the startup
+/// options. Returns the options to open the window with, or the exit code if the app
+/// exits instead. In kiosk mode nothing is converted or saved.
+pub fn run(args: &[String], policy: &Policy) -> Result<StartupArgs, i32> {
+    match args.get(1) {
+        Some(name) if COMMANDS.contains(&name.as_str()) => Err(run_command(name, args, policy)),
+        _ => startup(args, policy),
+    }
+}
+
+/// Parse the startup options and run `--export`, `--extract-attachments` and
+/// `--register-associations`
+fn startup(args: &[String], policy: &Policy) -> Result<StartupArgs, i32> {
+    let cwd = std::env::current_dir().unwrap_or_default();
+    let startup = match args::parse(args, &cwd) {
+        Ok(startup) => startup,
+        Err(e) => {
+            attach_console();
+            eprintln!("msgreader: {}\n\n{}", e, USAGE);
+            return Err(2);
+        }
+    };
+    if startup.help {
+        attach_console();
+        println!("{}", USAGE);
+        return Err(0);
+    }
+    if !startup.has_actions() {
+        return Ok(startup);
+    }
+
+    attach_console();
+    let mut code = 0;
+    let kiosk = overrides::kiosk_enforced(startup.kiosk, policy);
+    if kiosk && (startup.export.is_some() || startup.extract_attachments) {
+        eprintln!("msgreader: Files are not exported in kiosk mode");
+        code = 1;
+    }
+    if startup.register_associations {
+        match file_associations::make_default() {
+            Ok(true) => println!("msgReader is now the default app for .msg and .eml files"),
+            Ok(false) => println!(
+                "This system lets you choose the default app yourself: open a .msg file \
+                 with \"Open with\" and choose msgReader"
+            ),
+            Err(e) => {
+                eprintln!("msgreader: {}", e);
+                code = 1;
+            }
+        }
+    }
+    if let Some(format) = startup.export.as_ref().filter(|_| !kiosk) {
+        if let Err(failure) = export_files(&startup.files, format) {
+            eprintln!("msgreader: {}", failure.message);
+            code = failure.code;
+        }
+    }
+    if startup.extract_attachments && !kiosk {
+        if let Err(failure) = extract_files(&startup.files) {
+            eprintln!("msgreader: {}", failure.message);
+            code = failure.code;
+        }
+    }
+    if startup.no_gui {
+        Err(code)
+    } else {
+        Ok(startup)
+    }
+}
+
+fn run_command(name: &str, args: &[String], policy: &Policy) -> i32 {
+    attach_console();
+
+    let rest = &args[2..];
+    if rest.iter().any(|arg| arg == "-h" || arg == "--help") {
+        println!("{}", USAGE);
+        return 0;
+    }
+    if overrides::kiosk_enforced(false, policy) {
+        eprintln!("msgreader: {}: Not available in kiosk mode", name);
+        return 1;
+    }
+    let result = parse(name, rest).and_then(|command| match command.name.as_str() {
+        "convert" => convert(&command),
+        "extract-attachments" => extract_attachments(&command),
+        _ => print_headers(&command),
+    });
+    match result {
+        Ok(()) => 0,
+        Err(failure) => {
+            if failure.code == 2 {
+                eprintln!("msgreader: {}\n\n{}", failure.message, USAGE);
+            } else {
+                eprintln!("msgreader: {}", failure.message);
+            }
+            failure.code
+        }
+    }
+}
+
+fn parse(name: &str, args: &[String]) -> Result<Command, Failure> {
+    let mut input = None;
+    let mut stdin = false;
+    let mut kind = None;
+    let mut to = "json".to_string();
+    let mut output = None;
+    let mut attachment_content = true;
+
+    let mut args = args.iter();
+    while let Some(arg) = args.next() {
+        let mut value = || {
+            args.next()
+                .filter(|value| !value.starts_with('-'))
+                .cloned()
+                .ok_or_else(|| usage_error(format!("Missing value for {}", arg)))
+        };
+        match arg.as_str() {
+            "--stdin" => stdin = true,
+            "--type" => kind = Some(value()?.to_lowercase()),
+            "--to" if name == "convert" => to = value()?.to_lowercase(),
+            "-o" | "--output" if name != "headers" => output = Some(PathBuf::from(value()?)),
+            "--no-attachments" if name == "convert" => attachment_content = false,
+            // "-" is the conventional name for standard input
+            "-" => stdin = true,
+            option if option.starts_with('-') => {
+                return Err(usage_error(format!("Unknown option: {}", option)))
+            }
+            _ if input.is_some() => return Err(usage_error("Only one input file is supported")),
+            file => input = Some(PathBuf::from(file)),
+        }
+    }
+
+    if stdin && input.is_some() {
+        return Err(usage_error("Use either --stdin or a file, not both"));
+    }
+    if !stdin && input.is_none() {
+        return Err(usage_error("No input: pass a file or --stdin"));
+    }
+    if let Some(kind) = kind.as_deref().filter(|kind| !INPUT_TYPES.contains(kind)) {
+        return Err(usage_error(format!("Unknown input type: {}", kind)));
+    }
+    if !["eml", "pdf", "html", "json"].contains(&to.as_str()) {
+        return Err(usage_error(format!("Unknown output format: {}", to)));
+    }
+    Ok(Command {
+        name: name.to_string(),
+        input,
+        kind,
+        to,
+        output,
+        attachment_content,
+    })
+}
+
+/// The message of a command, read from its file or standard input
+fn read_input(command: &Command) -> Result<Vec<u8>, String> {
+    let data = match &command.input {
+        Some(path) => read_file(path)?,
+        None => {
+            let mut data = Vec::new();
+            std::io::stdin()
+                .read_to_end(&mut data)
+                .map_err(|e| format!("Failed to read standard input: {}", e))?;
+            data
+        }
+    };
+    if data.is_empty() {
+        return Err("Input is empty".to_string());
+    }
+    Ok(data)
+}
+
+fn read_file(path: &Path) -> Result<Vec<u8>, String> {
+    std::fs::read(path).map_err(|e| format!("Failed to read {}: {}", path.display(), e))
+}
+
+/// Whether message data is an Outlook .msg file: by the type given, or by its content
+fn is_msg(data: &[u8], kind: Option<&str>) -> bool {
+    kind.map_or_else(|| data.starts_with(&CFB_SIGNATURE), |kind| kind == "msg")
+}
+
+fn parse_message(data: &[u8], kind: Option<&str>) -> Result<Message, String> {
+    if is_msg(data, kind) {
+        msg::parse_bytes(data)
+    } else {
+        eml::parse_bytes(data)
+    }
+}
+
+fn write_output(output: Option<&Path>, bytes: &[u8]) -> Result<(), String> {
+    match output {
+        Some(path) => std::fs::write(path, bytes)
+            .map_err(|e| format!("Failed to write {}: {}", path.display(), e)),
+        None => std::io::stdout()
+            .write_all(bytes)
+            .and_then(|_| std::io::stdout().flush())
+            .map_err(|e| format!("Failed to write output: {}", e)),
+    }
+}
+
+fn format_address(name: &str, email: &str) -> String {
+    match (name, email) {
+        ("", email) => email.to_string(),
+        (name, "") => name.to_string(),
+        (name, email) => format!("{} <{}>", name, email),
+    }
+}
+
+/// Header fields shown above the body in PDF and HTML output, empty ones left out
+fn header_fields(message: &Message) -> Vec<(&'static str, String)> {
+    let recipients = |kind: &str| {
+        message
+            .recipients
+            .iter()
+            .filter(|recipient| recipient.kind == kind)
+            .map(|recipient| format_address(&recipient.name, &recipient.email))
+            .collect::<Vec<_>>()
+            .join(", ")
+    };
+    let date = message.date.map(|date| {
+        let (year, month, day) = crate::calendar::civil_from_days(date.div_euclid(86_400_000));
+        let minutes = date.rem_euclid(86_400_000) / 60_000;
+        format!(
+            "{:04}-{:02}-{:02} {:02}:{:02} UTC",
+            year,
+            month,
+            day,
+            minutes / 60,
+            minutes % 60
+        )
+    });
+    let mut fields = vec![
+        ("From", format_address(&message.sender_name, &message.sender_email)),
+        ("To", recipients("to")),
+        ("Cc", recipients("cc")),
+        ("Date", date.unwrap_or_default()),
+        ("Subject", message.subject.clone()),
+        (
+            "Attachments",
+            message
+                .attachments
+                .iter()
+                .map(|attachment| attachment.file_name.as_str())
+                .collect::<Vec<_>>()
+                .join(", "),
+        ),
+    ];
+    fields.retain(|(_, value)| !value.trim().is_empty());
+    fields
+}
+
+/// Header and body of a message as text, for the PDF
+pub(crate) fn message_text(message: &Message) -> String {
+    let mut text: String = header_fields(message)
+        .iter()
+        .map(|(name, value)| format!("{}: {}\n", name, value))
+        .collect();
+    text.push('\n');
+    text.push_str(&message.plain_text());
+    text
+}
+
+/// Styles of the header table in HTML output
+const PREVIEW_STYLE: &str = "\
+    .msgreader-headers { font: 13px sans-serif; border-collapse: collapse; margin-bottom: 12px; }
+    .msgreader-headers th { text-align: left; padding: 2px 12px 2px 0; color: #555; }
+";
+
+fn escape_html(text: &str) -> String {
+    text.replace('&', "&amp;")
+        .replace('<', "&lt;")
+        .replace('>', "&gt;")
+        .replace('"', "&quot;")
+}
+
+/// A standalone HTML page of a message for previews: header fields, then the HTML body
+/// (or the text body) with inline images embedded as data URLs. The policy keeps
+/// scripts and remote content of the message from running or loading.
+fn message_html(message: &Message) -> String {
+    let mut body = if message.body_html.trim().is_empty() {
+        format!("<pre>{}</pre>", escape_html(&message.body_text))
+    } else {
+        message.body_html.clone()
+    };
+    for attachment in &message.attachments {
+        if !attachment.content_id.is_empty() {
+            body = body.replace(
+                &format!("cid:{}", attachment.content_id),
+                &format!("data:{};base64,{}", attachment.mime_type, attachment.content_base64),
+            );
+        }
+    }
+    let headers: String = header_fields(message)
+        .iter()
+        .map(|(name, value)| {
+            format!("<tr><th>{}</th><td>{}</td></tr>\n", name, escape_html(value))
+        })
+        .collect();
+    format!(
+        "<!DOCTYPE html>\n<html><head><meta charset=\"UTF-8\">\n\
+         <meta http-equiv=\"Content-Security-Policy\" content=\"default-src 'none'; \
+         img-src data:; style-src 'unsafe-inline'; font-src data:\">\n\
+         <title>{}</title>\n<style>\n{}</style></head><body>\n\
+         <table class=\"msgreader-headers\">\n{}</table>\n<hr>\n{}\n</body></html>\n",
+        escape_html(&message.subject),
+        PREVIEW_STYLE,
+        headers,
+        body
+    )
+}
+
+/// A message file converted to `eml`, `pdf`, `html`, `text` or `json` (anything else)
+pub(crate) fn convert_file(input: &Path, to: &str) -> Result<Vec<u8>, String> {
+    convert_data(&read_file(input)?, None, to, true)
+}
+
+/// Message data converted like `convert_file`; without `attachment_content` the JSON
+/// output has the attachments without their content
+fn convert_data(
+    data: &[u8],
+    kind: Option<&str>,
+    to: &str,
+    attachment_content: bool,
+) -> Result<Vec<u8>, String> {
+    let mut message = parse_message(data, kind)?;
+    if !attachment_content {
+        for attachment in &mut message.attachments {
+            attachment.content_base64.clear();
+        }
+    }
+    Ok(match to {
+        // An .eml file is passed through unchanged
+        "eml" if !is_msg(data, kind) => data.to_vec(),
+        "eml" => eml::write(&message),
+        "pdf" => pdf::text_document(&message.subject, &message_text(&message)),
+        "html" => message_html(&message).into_bytes(),
+        "text" => message_text(&message).into_bytes(),
+        _ => {
+            let mut json = serde_json::to_vec_pretty(&message)
+                .map_err(|e| format!("Failed to serialize message: {}", e))?;
+            json.push(b'\n');
+            json
+        }
+    })
+}
+
+fn convert(command: &Command) -> Result<(), Failure> {
+    let data = read_input(command)?;
+    let bytes = convert_data(
+        &data,
+        command.kind.as_deref(),
+        &command.to,
+        command.attachment_content,
+    )?;
+    Ok(write_output(command.output.as_deref(), &bytes)?)
+}
+
+/// `--export`: convert every file to `<name>.<format>` next to it, numbered instead of
+/// overwriting an existing file; the paths written are printed
+fn export_files(files: &[String], format: &str) -> Result<(), Failure> {
+    let mut failed = 0;
+    for file in files {
+        let input = Path::new(file);
+        let dir = input.parent().unwrap_or(Path::new("."));
+        let stem = input.file_stem().unwrap_or_default().to_string_lossy();
+        let saved = convert_file(input, format).and_then(|bytes| {
+            attachments::write_unique(dir, &format!("{}.{}", stem, format), &bytes)
+        });
+        match saved {
+            Ok(path) => println!("{}", path.display()),
+            Err(e) => {
+                eprintln!("msgreader: {}: {}", input.display(), e);
+                failed += 1;
+            }
+        }
+    }
+    if failed > 0 {
+        return Err(format!("{} of {} files not exported", failed, files.len()).into());
+    }
+    Ok(())
+}
+
+/// Save the attachments of a message to `dir` without overwriting files, printing the
+/// paths written; one failed attachment does not stop the rest
+fn save_attachments(message: &Message, dir: &Path) -> Result<(), String> {
+    std::fs::create_dir_all(dir)
+        .map_err(|e| format!("Failed to create {}: {}", dir.display(), e))?;
+
+    let mut failed = 0;
+    for attachment in &message.attachments {
+        let saved = STANDARD
+            .decode(&attachment.content_base64)
+            .map_err(|e| format!("Failed to decode base64: {}", e))
+            .and_then(|bytes| {
+                attachments::write_unique(
+                    dir,
+                    &attachments::safe_file_name(&attachment.file_name),
+                    &bytes,
+                )
+            });
+        match saved {
+            Ok(path) => println!("{}", path.display()),
+            Err(e) => {
+                eprintln!("msgreader: {}: {}", attachment.file_name, e);
+                failed += 1;
+            }
+        }
+    }
+    if failed > 0 {
+        let total = message.attachments.len();
+        return Err(format!("{} of {} attachments not saved", failed, total));
+    }
+    Ok(())
+}
+
+fn extract_attachments(command: &Command) -> Result<(), Failure> {
+    let message = parse_message(&read_input(command)?, command.kind.as_deref())?;
+    let dir = command.output.clone().unwrap_or_else(|| PathBuf::from("."));
+    Ok(save_attachments(&message, &dir)?)
+}
+
+/// `--extract-attachments`: save the attachments of every file to `<name> attachments`
+/// next to it (the "Extract attachments here" verb in Explorer). Files are never
+/// overwritten, and no folder is created for a message without attachments.
+fn extract_files(files: &[String]) -> Result<(), Failure> {
+    let mut failed = 0;
+    for file in files {
+        let input = Path::new(file);
+        let dir = input.parent().unwrap_or(Path::new("."));
+        let stem = input.file_stem().unwrap_or_default().to_string_lossy();
+        let saved = read_file(input).and_then(|data| {
+            let message = parse_message(&data, None)?;
+            if message.attachments.is_empty() {
+                return Ok(());
+            }
+            save_attachments(&message, &dir.join(format!("{} attachments", stem)))
+        });
+        if let Err(e) = saved {
+            eprintln!("msgreader: {}: {}", input.display(), e);
+            failed += 1;
+        }
+    }
+    if failed > 0 {
+        return Err(format!("Attachments of {} of {} files not saved", failed, files.len()).into());
+    }
+    Ok(())
+}
+
+fn print_headers(command: &Command) -> Result<(), Failure> {
+    let message = parse_message(&read_input(command)?, command.kind.as_deref())?;
+    if message.headers.trim().is_empty() {
+        let source = match &command.input {
+            Some(path) => path.display().to_string(),
+            None => "The message".to_string(),
+        };
+        return Err(format!("{} has no transport headers", source).into());
+    }
+    let headers = format!("{}\n", message.headers.trim_end());
+    Ok(write_output(None, headers.as_bytes())?)
+}
@@ -0,0 +1,116 @@
+use std::io::Write;
+use std::path::PathBuf;
+use std::process::{Command, Stdio};
+
+/// Put files on the OS clipboard as file objects, so they can be pasted into Explorer,
+/// Finder, a file manager or another app's attachment field: a file drop list (CF_HDROP)
+/// on Windows, file URLs on the macOS pasteboard and a `text/uri-list` on Linux.
+/// The files must stay where they are until they are pasted.
+pub fn copy_files(paths: &[PathBuf]) -> Result<(), String> {
+    if paths.is_empty() {
+        return Ok(());
+    }
+    let (mut command, input) = copy_command(paths)?;
+    let mut child = command
+        .stdin(if input.is_some() { Stdio::piped() } else { Stdio::null() })
+        .stdout(Stdio::null())
+        .stderr(Stdio::null())
+        .spawn()
+        .map_err(|e| format!("Failed to copy to the clipboard: {}", e))?;
+    if let (Some(input), Some(mut stdin)) = (input, child.stdin.take()) {
+        stdin
+            .write_all(input.as_bytes())
+            .map_err(|e| format!("Failed to copy to the clipboard: {}", e))?;
+    }
+    let status = child
+        .wait()
+        .map_err(|e| format!("Failed to copy to the clipboard: {}", e))?;
+    if !status.success() {
+        return Err(format!("Failed to copy to the clipboard: {}", status));
+    }
+    log_info!("Copied {} file(s) to the clipboard", paths.len());
+    Ok(())
+}
+
+/// The command that fills the clipboard, with what it reads from stdin
+#[cfg(windows)]
+fn copy_command(paths: &[PathBuf]) -> Result<(Command, Option<String>), String> {
+    use std::os::windows::process::CommandExt;
+    const CREATE_NO_WINDOW: u32 = 0x0800_0000;
+
+    // The paths are passed through the environment so they are never parsed as script
+    let list = paths
+        .iter()
+        .map(|path| path.display().to_string())
+        .collect::<Vec<_>>()
+        .join("\n");
+    let mut command = Command::new("powershell");
+    command
+        .args(["-NoProfile", "-NonInteractive", "-STA", "-Command"])
+        .arg("Set-Clipboard -LiteralPath ($env:MSGREADER_CLIPBOARD_FILES -split \"`n\")")
+        .env("MSGREADER_CLIPBOARD_FILES", list)
+        .creation_flags(CREATE_NO_WINDOW);
+    Ok((command, None))
+}
+
+#[cfg(target_os = "macos")]
+fn copy_command(paths: &[PathBuf]) -> Result<(Command, Option<String>), String> {
+    // JavaScript for Automation gets the paths as arguments, not as script text
+    const SCRIPT: &str = "ObjC.import('AppKit'); \
+        function run(paths) { \
+            const board = $.NSPasteboard.generalPasteboard; \
+            board.clearContents; \
+            const urls = paths.map((path) => $.NSURL.fileURLWithPath(path)); \
+            if (!board.writeObjects($(urls))) throw new Error('Pasteboard refused the files'); \
+        }";
+    let mut command = Command::new("osascript");
+    command.args(["-l", "JavaScript", "-e", SCRIPT]).args(paths);
+    Ok((command, None))
+}
+
+#[cfg(target_os = "linux")]
+fn copy_command(paths: &[PathBuf]) -> Result<(Command, Option<String>), String> {
+    let uris = paths
+        .iter()
+        .map(|path| {
+            tauri::Url::from_file_path(path)
+                .map(String::from)
+                .map_err(|_| format!("Not an absolute path: {}", path.display()))
+        })
+        .collect::<Result<Vec<_>, _>>()?;
+    let list = uris.join("\r\n") + "\r\n";
+
+    // Both tools read the list from stdin and keep serving it in the background
+    let command = uri_list_command()
+        .ok_or_else(|| "Copying files to the clipboard needs wl-copy or xclip".to_string())?;
+    Ok((command, Some(list)))
+}
+
+/// wl-copy on Wayland, xclip on X11
+#[cfg(target_os = "linux")]
+fn uri_list_command() -> Option<Command> {
+    let installed = |binary: &str, version: &str| {
+        Command::new(binary)
+            .arg(version)
+            .stdout(Stdio::null())
+            .stderr(Stdio::null())
+            .status()
+            .is_ok()
+    };
+    if std::env::var_os("WAYLAND_DISPLAY").is_some() && installed("wl-copy", "--version") {
+        let mut command = Command::new("wl-copy");
+        command.args(["--type", "text/uri-list"]);
+        return Some(command);
+    }
+    if installed("xclip", "-version") {
+        let mut command = Command::new("xclip");
+        command.args(["-selection", "clipboard", "-t", "text/uri-list", "-i"]);
+        return Some(command);
+    }
+    None
+}
+
+#[cfg(not(any(target_os = "macos", target_os = "linux", windows)))]
+fn copy_command(_paths: &[PathBuf]) -> Result<(Command, Option<String>), String> {
+    Err("Copying files to the clipboard is not supported on this platform".to_string())
+}
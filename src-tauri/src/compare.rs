@@ -0,0 +1,675 @@
+use crate::headers;
+use crate::message::{Attachment, Message};
+use base64::{engine::general_purpose::STANDARD, Engine as _};
+use sha2::{Digest, Sha256};
+
+/// Unchanged lines shown around each change of the body
+const CONTEXT_LINES: usize = 3;
+/// Bodies that differ in more lines are shown as replaced as a whole. Myers' algorithm
+/// keeps O(D²) state for D differing lines, so this bounds time and memory.
+const MAX_EDITS: usize = 1000;
+
+/// A message field or header whose value differs, empty on the side that does not have it
+#[derive(serde::Serialize)]
+#[serde(rename_all = "camelCase")]
+pub struct FieldDiff {
+    pub name: String,
+    pub a: String,
+    pub b: String,
+}
+
+/// A person who is a recipient of only one message, or of both with a different type
+#[derive(serde::Serialize)]
+#[serde(rename_all = "camelCase")]
+pub struct RecipientDiff {
+    pub name: String,
+    pub email: String,
+    /// "to", "cc" or "bcc" in each message, None if the person is not a recipient
+    pub kind_a: Option<&'static str>,
+    pub kind_b: Option<&'static str>,
+}
+
+#[derive(serde::Serialize, Clone, Copy, Debug, PartialEq, Eq)]
+#[serde(rename_all = "lowercase")]
+pub enum LineKind {
+    Same,
+    Removed,
+    Added,
+}
+
+#[derive(serde::Serialize)]
+#[serde(rename_all = "camelCase")]
+pub struct DiffLine {
+    pub kind: LineKind,
+    pub text: String,
+}
+
+/// Changed lines of the body with CONTEXT_LINES unchanged ones around them
+#[derive(serde::Serialize)]
+#[serde(rename_all = "camelCase")]
+pub struct DiffHunk {
+    /// Line numbers of the first line of the hunk in each body, starting at 1
+    pub a_start: usize,
+    pub b_start: usize,
+    pub lines: Vec<DiffLine>,
+}
+
+#[derive(serde::Serialize)]
+#[serde(rename_all = "camelCase")]
+pub struct AttachmentInfo {
+    pub file_name: String,
+    pub size: usize,
+    /// Lowercase hex SHA-256 of the content
+    pub sha256: String,
+}
+
+#[derive(serde::Serialize, Clone, Copy, Debug, PartialEq, Eq)]
+#[serde(rename_all = "lowercase")]
+pub enum AttachmentStatus {
+    /// Same content and file name
+    Same,
+    /// Same content under another file name
+    Renamed,
+    /// Same file name with other content
+    Changed,
+    Removed,
+    Added,
+}
+
+/// An attachment of either message and its counterpart in the other one
+#[derive(serde::Serialize)]
+#[serde(rename_all = "camelCase")]
+pub struct AttachmentDiff {
+    pub status: AttachmentStatus,
+    pub a: Option<AttachmentInfo>,
+    pub b: Option<AttachmentInfo>,
+}
+
+/// The differences between two messages, from a to b
+#[derive(serde::Serialize)]
+#[serde(rename_all = "camelCase")]
+pub struct MessageDiff {
+    /// No differences at all, not even in the transport headers
+    pub identical: bool,
+    /// Subject, From, Date and Message-ID as the messages show them
+    pub fields: Vec<FieldDiff>,
+    /// Transport header fields by name, with the values of repeated fields one per line
+    pub headers: Vec<FieldDiff>,
+    pub recipients: Vec<RecipientDiff>,
+    /// Line diff of the text bodies (of the HTML body for messages without one)
+    pub body: Vec<DiffHunk>,
+    /// Every attachment, matched by content first and by file name second
+    pub attachments: Vec<AttachmentDiff>,
+}
+
+fn sender(message: &Message) -> String {
+    match (message.sender_name.trim(), message.sender_email.trim()) {
+        ("", email) => email.to_string(),
+        (name, "") => name.to_string(),
+        (name, email) => format!("{} <{}>", name, email),
+    }
+}
+
+fn field_diffs(a: &Message, b: &Message) -> Vec<FieldDiff> {
+    let date = |message: &Message| message.date.map(crate::eml::format_date).unwrap_or_default();
+    [
+        ("Subject", a.subject.clone(), b.subject.clone()),
+        ("From", sender(a), sender(b)),
+        ("Date", date(a), date(b)),
+        ("Message-ID", a.message_id.clone(), b.message_id.clone()),
+    ]
+    .into_iter()
+    .filter(|(_, a, b)| a.trim() != b.trim())
+    .map(|(name, a, b)| FieldDiff {
+        name: name.to_string(),
+        a,
+        b,
+    })
+    .collect()
+}
+
+/// Header fields as (name as first written, values) in order of first appearance
+fn header_fields(raw: &str) -> Vec<(String, String, Vec<String>)> {
+    let (fields, _) = headers::split_message(raw.as_bytes());
+    let mut grouped: Vec<(String, String, Vec<String>)> = Vec::new();
+    for field in fields {
+        let value = field.value();
+        match grouped.iter_mut().find(|(key, _, _)| *key == field.name) {
+            Some((_, _, values)) => values.push(value),
+            None => {
+                let colon = field.raw.iter().position(|&b| b == b':').unwrap_or(0);
+                let name = String::from_utf8_lossy(&field.raw[..colon]).trim().to_string();
+                grouped.push((field.name, name, vec![value]));
+            }
+        }
+    }
+    grouped
+}
+
+fn header_diffs(a: &Message, b: &Message) -> Vec<FieldDiff> {
+    let fields_a = header_fields(&a.headers);
+    let fields_b = header_fields(&b.headers);
+    let values = |fields: &[(String, String, Vec<String>)], key: &str| {
+        fields
+            .iter()
+            .find(|(name, _, _)| name == key)
+            .map(|(_, _, values)| values.join("\n"))
+            .unwrap_or_default()
+    };
+
+    let only_b = fields_b
+        .iter()
+        .filter(|(key, _, _)| !fields_a.iter().any(|(name, _, _)| name == key));
+    fields_a
+        .iter()
+        .chain(only_b)
+        .filter_map(|(key, name, _)| {
+            let (a, b) = (values(&fields_a, key), values(&fields_b, key));
+            (a != b).then(|| FieldDiff {
+                name: name.clone(),
+                a,
+                b,
+            })
+        })
+        .collect()
+}
+
+fn recipient_diffs(a: &Message, b: &Message) -> Vec<RecipientDiff> {
+    // People are matched by address, by name if they have none
+    let key = |name: &str, email: &str| {
+        if email.trim().is_empty() {
+            name.trim().to_lowercase()
+        } else {
+            email.trim().to_lowercase()
+        }
+    };
+    let kind = |message: &Message, wanted: &str| {
+        message
+            .recipients
+            .iter()
+            .find(|recipient| key(&recipient.name, &recipient.email) == wanted)
+            .map(|recipient| recipient.kind)
+    };
+
+    let mut seen: Vec<String> = Vec::new();
+    let mut diffs = Vec::new();
+    for recipient in a.recipients.iter().chain(&b.recipients) {
+        let wanted = key(&recipient.name, &recipient.email);
+        if wanted.is_empty() || seen.contains(&wanted) {
+            continue;
+        }
+        let (kind_a, kind_b) = (kind(a, &wanted), kind(b, &wanted));
+        if kind_a != kind_b {
+            diffs.push(RecipientDiff {
+                name: recipient.name.clone(),
+                email: recipient.email.clone(),
+                kind_a,
+                kind_b,
+            });
+        }
+        seen.push(wanted);
+    }
+    diffs
+}
+
+/// Myers' diff ("An O(ND) Difference Algorithm", 1986) of two lists of lines: the
+/// steps from a to b in order, or None if more than MAX_EDITS lines differ
+fn myers(a: &[&str], b: &[&str]) -> Option<Vec<LineKind>> {
+    let (n, m) = (a.len() as isize, b.len() as isize);
+    let max = (n + m).min(MAX_EDITS as isize);
+    // The furthest x on each diagonal k = x - y after each round d, for k in -d..=d
+    let mut trace: Vec<Vec<isize>> = Vec::new();
+    for d in 0..=max {
+        let furthest = |k: isize| trace.last().map_or(0, |prev| prev[(k + d - 1) as usize]);
+        let mut v = vec![0; 2 * d as usize + 1];
+        let mut done = false;
+        for k in (-d..=d).step_by(2) {
+            let mut x = if k == -d || (k != d && furthest(k - 1) < furthest(k + 1)) {
+                furthest(k + 1)
+            } else {
+                furthest(k - 1) + 1
+            };
+            let mut y = x - k;
+            while x < n && y < m && a[x as usize] == b[y as usize] {
+                x += 1;
+                y += 1;
+            }
+            v[(k + d) as usize] = x;
+            if x >= n && y >= m {
+                done = true;
+                break;
+            }
+        }
+        trace.push(v);
+        if done {
+            return Some(backtrack(&trace, n, m));
+        }
+    }
+    None
+}
+
+/// The steps of the shortest edit script that `myers` found, from the end back
+fn backtrack(trace: &[Vec<isize>], n: isize, m: isize) -> Vec<LineKind> {
+    let mut steps = Vec::new();
+    let (mut x, mut y) = (n, m);
+    for d in (1..trace.len() as isize).rev() {
+        let prev = &trace[d as usize - 1];
+        let furthest = |k: isize| prev[(k + d - 1) as usize];
+        let k = x - y;
+        let prev_k = if k == -d || (k != d && furthest(k - 1) < furthest(k + 1)) {
+            k + 1
+        } else {
+            k - 1
+        };
+        let prev_x = furthest(prev_k);
+        let prev_y = prev_x - prev_k;
+        while x > prev_x && y > prev_y {
+            steps.push(LineKind::Same);
+            x -= 1;
+            y -= 1;
+        }
+        steps.push(if x == prev_x {
+            LineKind::Added
+        } else {
+            LineKind::Removed
+        });
+        x = prev_x;
+        y = prev_y;
+    }
+    // The lines both start with
+    steps.extend((0..x).map(|_| LineKind::Same));
+    steps.reverse();
+    steps
+}
+
+/// The changes of a line diff with their context, changes that are at most two contexts
+/// apart in one hunk
+fn hunks(a: &[&str], b: &[&str], steps: &[LineKind]) -> Vec<DiffHunk> {
+    // Index in a and b of the line each step is about
+    let mut positions = Vec::with_capacity(steps.len());
+    let (mut i, mut j) = (0, 0);
+    for step in steps {
+        positions.push((i, j));
+        match step {
+            LineKind::Same => {
+                i += 1;
+                j += 1;
+            }
+            LineKind::Removed => i += 1,
+            LineKind::Added => j += 1,
+        }
+    }
+
+    let changes: Vec<usize> = (0..steps.len())
+        .filter(|&index| steps[index] != LineKind::Same)
+        .collect();
+    let mut hunks = Vec::new();
+    let mut next = 0;
+    while next < changes.len() {
+        let start = changes[next].saturating_sub(CONTEXT_LINES);
+        let mut last = changes[next];
+        next += 1;
+        while next < changes.len() && changes[next] - last <= 2 * CONTEXT_LINES + 1 {
+            last = changes[next];
+            next += 1;
+        }
+        let end = (last + CONTEXT_LINES + 1).min(steps.len());
+
+        let lines = (start..end)
+            .map(|index| {
+                let (i, j) = positions[index];
+                let text = match steps[index] {
+                    LineKind::Added => b[j],
+                    _ => a[i],
+                };
+                DiffLine {
+                    kind: steps[index],
+                    text: text.to_string(),
+                }
+            })
+            .collect();
+        hunks.push(DiffHunk {
+            a_start: positions[start].0 + 1,
+            b_start: positions[start].1 + 1,
+            lines,
+        });
+    }
+    hunks
+}
+
+/// Line diff of the bodies; trailing whitespace is ignored, as converting between .msg
+/// and .eml or rewrapping by a journaling server changes it
+fn body_diff(a: &Message, b: &Message) -> Vec<DiffHunk> {
+    let (text_a, text_b) = (a.plain_text(), b.plain_text());
+    let lines_a: Vec<&str> = text_a.lines().map(str::trim_end).collect();
+    let lines_b: Vec<&str> = text_b.lines().map(str::trim_end).collect();
+    let steps = myers(&lines_a, &lines_b).unwrap_or_else(|| {
+        log_debug!("Bodies differ in more than {} lines, not diffing them", MAX_EDITS);
+        let removed = lines_a.iter().map(|_| LineKind::Removed);
+        removed.chain(lines_b.iter().map(|_| LineKind::Added)).collect()
+    });
+    hunks(&lines_a, &lines_b, &steps)
+}
+
+fn attachment_info(attachment: &Attachment) -> Result<AttachmentInfo, String> {
+    let content = STANDARD.decode(&attachment.content_base64).map_err(|e| {
+        format!("Failed to decode attachment {}: {}", attachment.file_name, e)
+    })?;
+    Ok(AttachmentInfo {
+        file_name: attachment.file_name.clone(),
+        size: content.len(),
+        sha256: Sha256::digest(&content)
+            .iter()
+            .map(|byte| format!("{:02x}", byte))
+            .collect(),
+    })
+}
+
+fn attachment_diffs(a: &Message, b: &Message) -> Result<Vec<AttachmentDiff>, String> {
+    let infos_a = a.attachments.iter().map(attachment_info).collect::<Result<Vec<_>, _>>()?;
+    let mut infos_b: Vec<Option<AttachmentInfo>> = b
+        .attachments
+        .iter()
+        .map(|attachment| attachment_info(attachment).map(Some))
+        .collect::<Result<_, _>>()?;
+
+    let mut take_b = |matches: &dyn Fn(&AttachmentInfo) -> bool| {
+        let index = infos_b.iter().position(|info| info.as_ref().is_some_and(matches))?;
+        infos_b[index].take()
+    };
+    // Content first, so a renamed copy is not mistaken for a changed file of the same name
+    let mut by_content: Vec<(AttachmentInfo, Option<AttachmentInfo>)> = infos_a
+        .into_iter()
+        .map(|info| {
+            let other = take_b(&|other: &AttachmentInfo| other.sha256 == info.sha256);
+            (info, other)
+        })
+        .collect();
+    for (info, other) in by_content.iter_mut().filter(|(_, other)| other.is_none()) {
+        *other = take_b(&|other: &AttachmentInfo| {
+            other.file_name.eq_ignore_ascii_case(&info.file_name)
+        });
+    }
+
+    let mut diffs: Vec<AttachmentDiff> = by_content
+        .into_iter()
+        .map(|(info, other)| {
+            let status = match &other {
+                None => AttachmentStatus::Removed,
+                Some(other) if other.sha256 != info.sha256 => AttachmentStatus::Changed,
+                Some(other) if other.file_name != info.file_name => AttachmentStatus::Renamed,
+                Some(_) => AttachmentStatus::Same,
+            };
+            AttachmentDiff {
+                status,
+                a: Some(info),
+                b: other,
+            }
+        })
+        .collect();
+    diffs.extend(infos_b.into_iter().flatten().map(|info| AttachmentDiff {
+        status: AttachmentStatus::Added,
+        a: None,
+        b: Some(info),
+    }));
+    Ok(diffs)
+}
+
+/// Compare two copies of a message, e.g. an original and its journaled or forwarded
+/// copy: the fields, transport headers and recipients that differ, a line diff of the
+/// bodies and the attachments matched by their SHA-256
+pub fn compare(a: &Message, b: &Message) -> Result<MessageDiff, String> {
+    let attachments = attachment_diffs(a, b)?;
+    let fields = field_diffs(a, b);
+    let headers = header_diffs(a, b);
+    let recipients = recipient_diffs(a, b);
+    let body = body_diff(a, b);
+    let identical = fields.is_empty()
+        && headers.is_empty()
+        && recipients.is_empty()
+        && body.is_empty()
+        && attachments.iter().all(|diff| diff.status == AttachmentStatus::Same);
+    Ok(MessageDiff {
+        identical,
+        fields,
+        headers,
+        recipients,
+        body,
+        attachments,
+    })
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::message::Recipient;
+
+    fn message(body: &str) -> Message {
+        Message {
+            subject: "Report".to_string(),
+            sender_email: "alice@example.com".to_string(),
+            body_text: body.to_string(),
+            ..Message::default()
+        }
+    }
+
+    fn recipient(name: &str, email: &str, kind: &'static str) -> Recipient {
+        Recipient {
+            name: name.to_string(),
+            email: email.to_string(),
+            kind,
+        }
+    }
+
+    fn attachment(file_name: &str, content: &[u8]) -> Attachment {
+        Attachment {
+            file_name: file_name.to_string(),
+            mime_type: "application/octet-stream".to_string(),
+            content_id: String::new(),
+            size: content.len(),
+            content_base64: STANDARD.encode(content),
+        }
+    }
+
+    /// The lines of b, rebuilt from a and the steps of a diff
+    fn apply(a: &[&str], b: &[&str], steps: &[LineKind]) -> Vec<String> {
+        let (mut i, mut j) = (0, 0);
+        let mut result = Vec::new();
+        for step in steps {
+            match step {
+                LineKind::Same => {
+                    assert_eq!(a[i], b[j]);
+                    result.push(a[i].to_string());
+                    i += 1;
+                    j += 1;
+                }
+                LineKind::Removed => i += 1,
+                LineKind::Added => {
+                    result.push(b[j].to_string());
+                    j += 1;
+                }
+            }
+        }
+        assert_eq!((i, j), (a.len(), b.len()));
+        result
+    }
+
+    fn edits(steps: &[LineKind]) -> usize {
+        steps.iter().filter(|step| **step != LineKind::Same).count()
+    }
+
+    #[test]
+    fn myers_finds_the_shortest_edit_script() {
+        // The example of Myers' paper: D = 5
+        let a = ["A", "B", "C", "A", "B", "B", "A"];
+        let b = ["C", "B", "A", "B", "A", "C"];
+        let steps = myers(&a, &b).unwrap();
+        assert_eq!(edits(&steps), 5);
+        assert_eq!(apply(&a, &b, &steps), b);
+
+        let steps = myers(&["x", "y"], &["x", "new", "y"]).unwrap();
+        assert_eq!(steps, [LineKind::Same, LineKind::Added, LineKind::Same]);
+        let steps = myers(&["x", "old", "y"], &["x", "y"]).unwrap();
+        assert_eq!(steps, [LineKind::Same, LineKind::Removed, LineKind::Same]);
+    }
+
+    #[test]
+    fn myers_handles_empty_and_equal_input() {
+        assert_eq!(myers(&[], &[]).unwrap(), []);
+        assert_eq!(myers(&["a"], &[]).unwrap(), [LineKind::Removed]);
+        assert_eq!(myers(&[], &["a", "b"]).unwrap(), [LineKind::Added, LineKind::Added]);
+        let same = ["a", "b", "c"];
+        assert_eq!(myers(&same, &same).unwrap(), [LineKind::Same; 3]);
+    }
+
+    #[test]
+    fn myers_gives_up_after_max_edits() {
+        let a: Vec<String> = (0..=MAX_EDITS).map(|i| format!("a{}", i)).collect();
+        let b: Vec<String> = (0..=MAX_EDITS).map(|i| format!("b{}", i)).collect();
+        let a: Vec<&str> = a.iter().map(String::as_str).collect();
+        let b: Vec<&str> = b.iter().map(String::as_str).collect();
+        assert!(myers(&a[..MAX_EDITS / 2], &b[..MAX_EDITS / 2]).is_some());
+        assert!(myers(&a, &b).is_none());
+
+        // The bodies are then shown as replaced as a whole
+        let hunks = body_diff(&message(&a.join("\n")), &message(&b.join("\n")));
+        assert_eq!(hunks.len(), 1);
+        assert_eq!(hunks[0].lines.len(), 2 * (MAX_EDITS + 1));
+        assert!(hunks[0].lines[..=MAX_EDITS].iter().all(|line| line.kind == LineKind::Removed));
+    }
+
+    #[test]
+    fn hunks_have_context_and_line_numbers() {
+        let a: Vec<String> = (1..=20).map(|i| format!("line {}", i)).collect();
+        let mut b = a.clone();
+        b[1] = "changed 2".to_string();
+        b[5] = "changed 6".to_string();
+        b.remove(16);
+        let hunks = body_diff(&message(&a.join("\n")), &message(&b.join("\n")));
+
+        // Changes at most two contexts apart share a hunk
+        assert_eq!(hunks.len(), 2);
+        assert_eq!((hunks[0].a_start, hunks[0].b_start), (1, 1));
+        let texts: Vec<&str> = hunks[0].lines.iter().map(|line| line.text.as_str()).collect();
+        assert_eq!(texts.first(), Some(&"line 1"));
+        assert_eq!(texts.last(), Some(&"line 9"));
+        assert!(texts.contains(&"changed 6"));
+
+        assert_eq!((hunks[1].a_start, hunks[1].b_start), (14, 14));
+        let kinds: Vec<LineKind> = hunks[1].lines.iter().map(|line| line.kind).collect();
+        assert_eq!(kinds.len(), 2 * CONTEXT_LINES + 1);
+        assert_eq!(kinds[CONTEXT_LINES], LineKind::Removed);
+        assert_eq!(hunks[1].lines[CONTEXT_LINES].text, "line 17");
+    }
+
+    #[test]
+    fn trailing_whitespace_is_ignored() {
+        let a = message("Hello  \r\nWorld");
+        let b = message("Hello\nWorld\t");
+        assert!(body_diff(&a, &b).is_empty());
+        assert!(compare(&a, &b).unwrap().identical);
+    }
+
+    #[test]
+    fn fields_headers_and_recipients() {
+        let mut a = message("Body");
+        a.headers = "Received: from a\r\nReceived: from b\r\nX-Old: 1\r\nSubject: Report\r\n\r\n"
+            .to_string();
+        a.recipients = vec![
+            recipient("Bob", "bob@example.com", "to"),
+            recipient("Carol", "", "cc"),
+        ];
+        let mut b = message("Body");
+        b.subject = "FW: Report".to_string();
+        b.headers = "received: from a\r\nReceived: from c\r\nsubject: Report\r\nX-New: 2\r\n\r\n"
+            .to_string();
+        b.recipients = vec![
+            recipient("Robert", "BOB@example.com", "cc"),
+            recipient("carol", "", "cc"),
+            recipient("", "dave@example.com", "bcc"),
+        ];
+        let diff = compare(&a, &b).unwrap();
+        assert!(!diff.identical);
+
+        assert_eq!(diff.fields.len(), 1);
+        assert_eq!(diff.fields[0].name, "Subject");
+        assert_eq!(diff.fields[0].b, "FW: Report");
+
+        let headers: Vec<(&str, &str, &str)> = diff
+            .headers
+            .iter()
+            .map(|field| (field.name.as_str(), field.a.as_str(), field.b.as_str()))
+            .collect();
+        assert_eq!(
+            headers,
+            [
+                ("Received", "from a\nfrom b", "from a\nfrom c"),
+                ("X-Old", "1", ""),
+                ("X-New", "", "2"),
+            ]
+        );
+
+        let recipients: Vec<(&str, Option<&str>, Option<&str>)> = diff
+            .recipients
+            .iter()
+            .map(|diff| (diff.email.as_str(), diff.kind_a, diff.kind_b))
+            .collect();
+        assert_eq!(
+            recipients,
+            [
+                ("bob@example.com", Some("to"), Some("cc")),
+                ("dave@example.com", None, Some("bcc")),
+            ]
+        );
+    }
+
+    #[test]
+    fn attachments_are_matched_by_content_then_name() {
+        let mut a = message("Body");
+        a.attachments = vec![
+            attachment("same.txt", b"same"),
+            attachment("old-name.txt", b"renamed"),
+            attachment("report.txt", b"version 1"),
+            attachment("gone.txt", b"gone"),
+        ];
+        let mut b = message("Body");
+        b.attachments = vec![
+            attachment("REPORT.txt", b"version 2"),
+            attachment("new-name.txt", b"renamed"),
+            attachment("same.txt", b"same"),
+            attachment("new.txt", b"new"),
+        ];
+        let diff = compare(&a, &b).unwrap();
+        let statuses: Vec<(AttachmentStatus, Option<&str>)> = diff
+            .attachments
+            .iter()
+            .map(|diff| (diff.status, diff.b.as_ref().map(|b| b.file_name.as_str())))
+            .collect();
+        assert_eq!(
+            statuses,
+            [
+                (AttachmentStatus::Same, Some("same.txt")),
+                (AttachmentStatus::Renamed, Some("new-name.txt")),
+                (AttachmentStatus::Changed, Some("REPORT.txt")),
+                (AttachmentStatus::Removed, None),
+                (AttachmentStatus::Added, Some("new.txt")),
+            ]
+        );
+        let same = diff.attachments[0].a.as_ref().unwrap();
+        assert_eq!(same.size, 4);
+        assert_eq!(
+            same.sha256,
+            "0967115f2813a3541eaef77de9d9d5773f1c0c04314b0bbfe4ff3b3b1c55b5d5"
+        );
+        assert!(!diff.identical);
+    }
+
+    #[test]
+    fn invalid_attachment_content_is_an_error() {
+        let mut a = message("Body");
+        a.attachments = vec![attachment("ok.txt", b"ok")];
+        a.attachments[0].content_base64 = "not base64!".to_string();
+        let error = compare(&a, &message("Body")).err().unwrap();
+        assert!(error.starts_with("Failed to decode attachment ok.txt"));
+    }
+}
@@ -0,0 +1,106 @@
+use flate2::read::GzDecoder;
+use std::fs::File;
+use std::io::{BufReader, Read};
+use std::path::Path;
+use zip::ZipArchive;
+
+/// Largest message unpacked from a container. Compressed files can unpack to many times
+/// their size, so the output is limited instead of the input.
+const MAX_MESSAGE_SIZE: u64 = 256 * 1024 * 1024;
+
+/// First bytes of a gzip stream (RFC 1952)
+const GZIP_MAGIC: [u8; 2] = [0x1f, 0x8b];
+
+/// Extensions of the ZIP members that are listed, with and without gzip compression
+const MESSAGE_EXTENSIONS: [&str; 4] = [".msg", ".eml", ".msg.gz", ".eml.gz"];
+
+/// A message file in a ZIP archive
+#[derive(serde::Serialize)]
+#[serde(rename_all = "camelCase")]
+pub struct ZipMember {
+    /// Position in the archive, used to read the member
+    pub index: usize,
+    /// Path inside the archive
+    pub name: String,
+    /// Size of the member unpacked, of the .gz file for compressed messages
+    pub size: u64,
+}
+
+/// Read at most MAX_MESSAGE_SIZE bytes
+fn read_limited(reader: impl Read) -> Result<Vec<u8>, String> {
+    let mut bytes = Vec::new();
+    reader
+        .take(MAX_MESSAGE_SIZE + 1)
+        .read_to_end(&mut bytes)
+        .map_err(|e| format!("Failed to decompress: {}", e))?;
+    if bytes.len() as u64 > MAX_MESSAGE_SIZE {
+        return Err(format!(
+            "Message is larger than {} MB unpacked",
+            MAX_MESSAGE_SIZE / 1024 / 1024
+        ));
+    }
+    Ok(bytes)
+}
+
+/// Unpack gzip data, which is checked by its magic bytes rather than the file name
+fn gunzip(reader: impl Read) -> Result<Vec<u8>, String> {
+    let mut reader = BufReader::new(reader);
+    let mut magic = [0; 2];
+    reader
+        .read_exact(&mut magic)
+        .map_err(|e| format!("Failed to read gzip file: {}", e))?;
+    if magic != GZIP_MAGIC {
+        return Err("Not a gzip-compressed file".to_string());
+    }
+    read_limited(GzDecoder::new((&magic[..]).chain(reader)))
+}
+
+/// The message in a gzip-compressed .msg or .eml file
+pub fn read_gzip(path: &Path) -> Result<Vec<u8>, String> {
+    let file = File::open(path).map_err(|e| format!("Failed to open file: {}", e))?;
+    gunzip(file)
+}
+
+fn open_zip(path: &Path) -> Result<ZipArchive<BufReader<File>>, String> {
+    let file = File::open(path).map_err(|e| format!("Failed to open ZIP file: {}", e))?;
+    ZipArchive::new(BufReader::new(file)).map_err(|e| format!("Failed to read ZIP file: {}", e))
+}
+
+/// The .msg and .eml files in a ZIP archive, in archive order. Folders and the resource
+/// forks macOS adds under `__MACOSX/` are left out.
+pub fn zip_members(path: &Path) -> Result<Vec<ZipMember>, String> {
+    let mut archive = open_zip(path)?;
+    let mut members = Vec::new();
+    for index in 0..archive.len() {
+        let file = archive
+            .by_index_raw(index)
+            .map_err(|e| format!("Failed to read ZIP file: {}", e))?;
+        let name = file.name().to_string();
+        let lower = name.to_lowercase();
+        if file.is_dir()
+            || lower.starts_with("__macosx/")
+            || !MESSAGE_EXTENSIONS.iter().any(|ext| lower.ends_with(ext))
+        {
+            continue;
+        }
+        members.push(ZipMember {
+            index,
+            name,
+            size: file.size(),
+        });
+    }
+    Ok(members)
+}
+
+/// A message of a ZIP archive, unpacked; gzip-compressed members are unpacked as well
+pub fn read_zip_member(path: &Path, index: usize) -> Result<Vec<u8>, String> {
+    let mut archive = open_zip(path)?;
+    let file = archive
+        .by_index(index)
+        .map_err(|e| format!("Failed to read ZIP member: {}", e))?;
+    if file.name().to_lowercase().ends_with(".gz") {
+        gunzip(file)
+    } else {
+        read_limited(file)
+    }
+}
@@ -0,0 +1,181 @@
+use crate::calendar;
+use crate::headers::CFB_SIGNATURE;
+use crate::msg;
+
+#[derive(serde::Serialize, Default)]
+#[serde(rename_all = "camelCase")]
+pub struct Phone {
+    /// `work`, `home`, `cell`, `fax`, `pager`, `car`, `main` or `other`
+    pub kind: &'static str,
+    pub number: String,
+}
+
+#[derive(serde::Serialize, Default)]
+#[serde(rename_all = "camelCase")]
+pub struct PostalAddress {
+    /// `work`, `home` or `other`
+    pub kind: &'static str,
+    pub po_box: String,
+    pub street: String,
+    pub city: String,
+    pub region: String,
+    pub postal_code: String,
+    pub country: String,
+}
+
+impl PostalAddress {
+    pub fn is_empty(&self) -> bool {
+        [
+            &self.po_box,
+            &self.street,
+            &self.city,
+            &self.region,
+            &self.postal_code,
+            &self.country,
+        ]
+        .iter()
+        .all(|part| part.trim().is_empty())
+    }
+}
+
+/// An Outlook contact (message class IPM.Contact)
+#[derive(serde::Serialize, Default)]
+#[serde(rename_all = "camelCase")]
+pub struct Contact {
+    pub display_name: String,
+    pub prefix: String,
+    pub given_name: String,
+    pub middle_name: String,
+    pub surname: String,
+    pub suffix: String,
+    pub nickname: String,
+    pub company: String,
+    pub department: String,
+    pub job_title: String,
+    pub emails: Vec<String>,
+    pub phones: Vec<Phone>,
+    pub addresses: Vec<PostalAddress>,
+    /// UTC midnight of the day in Unix milliseconds
+    pub birthday: Option<i64>,
+    pub anniversary: Option<i64>,
+    pub websites: Vec<String>,
+    pub notes: String,
+    /// Contact picture as a data URL
+    pub photo: Option<String>,
+}
+
+/// The contact of an .msg file, None for other files and messages
+pub fn analyze(data: &[u8]) -> Result<Option<Contact>, String> {
+    if data.starts_with(&CFB_SIGNATURE) {
+        msg::contact(data)
+    } else {
+        Ok(None)
+    }
+}
+
+/// Components of a structured value (N, ADR, ORG), separated by semicolons
+fn structured(parts: &[&str]) -> String {
+    parts
+        .iter()
+        .map(|part| calendar::escape(part))
+        .collect::<Vec<_>>()
+        .join(";")
+}
+
+/// The contact as a vCard 4.0 (RFC 6350)
+pub fn vcard(contact: &Contact) -> String {
+    let display_name = if contact.display_name.trim().is_empty() {
+        [&contact.given_name, &contact.surname]
+            .iter()
+            .filter(|part| !part.is_empty())
+            .map(|part| part.as_str())
+            .collect::<Vec<_>>()
+            .join(" ")
+    } else {
+        contact.display_name.clone()
+    };
+
+    let mut lines = vec![
+        "BEGIN:VCARD".to_string(),
+        "VERSION:4.0".to_string(),
+        "PRODID:-//msgReader//Contact export//EN".to_string(),
+        format!("FN:{}", calendar::escape(&display_name)),
+        format!(
+            "N:{}",
+            structured(&[
+                &contact.surname,
+                &contact.given_name,
+                &contact.middle_name,
+                &contact.prefix,
+                &contact.suffix,
+            ])
+        ),
+    ];
+    if !contact.nickname.is_empty() {
+        lines.push(format!("NICKNAME:{}", calendar::escape(&contact.nickname)));
+    }
+    if !contact.company.is_empty() || !contact.department.is_empty() {
+        lines.push(format!(
+            "ORG:{}",
+            structured(&[&contact.company, &contact.department])
+        ));
+    }
+    if !contact.job_title.is_empty() {
+        lines.push(format!("TITLE:{}", calendar::escape(&contact.job_title)));
+    }
+    for (index, email) in contact.emails.iter().enumerate() {
+        let preference = if index == 0 { ";PREF=1" } else { "" };
+        lines.push(format!("EMAIL{}:{}", preference, email));
+    }
+    for phone in &contact.phones {
+        let kind = match phone.kind {
+            "cell" => "cell,voice",
+            "fax" => "work,fax",
+            "pager" => "pager",
+            "home" => "home,voice",
+            "work" | "main" => "work,voice",
+            _ => "voice",
+        };
+        lines.push(format!(
+            "TEL;TYPE=\"{}\":{}",
+            kind,
+            calendar::escape(&phone.number)
+        ));
+    }
+    for address in &contact.addresses {
+        let kind = match address.kind {
+            "work" | "home" => format!(";TYPE={}", address.kind),
+            _ => String::new(),
+        };
+        lines.push(format!(
+            "ADR{}:{}",
+            kind,
+            structured(&[
+                &address.po_box,
+                "",
+                &address.street,
+                &address.city,
+                &address.region,
+                &address.postal_code,
+                &address.country,
+            ])
+        ));
+    }
+    if let Some(birthday) = contact.birthday {
+        lines.push(format!("BDAY:{}", calendar::format_date(birthday)));
+    }
+    if let Some(anniversary) = contact.anniversary {
+        lines.push(format!("ANNIVERSARY:{}", calendar::format_date(anniversary)));
+    }
+    for website in &contact.websites {
+        lines.push(format!("URL:{}", website));
+    }
+    if !contact.notes.trim().is_empty() {
+        lines.push(format!("NOTE:{}", calendar::escape(contact.notes.trim())));
+    }
+    if let Some(photo) = &contact.photo {
+        lines.push(format!("PHOTO:{}", photo));
+    }
+    lines.push("END:VCARD".to_string());
+    lines.iter().map(|line| calendar::fold(line)).collect()
+}
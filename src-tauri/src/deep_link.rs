@@ -0,0 +1,128 @@
+use std::path::{Component, Path, PathBuf, Prefix};
+use tauri::Url;
+
+/// URL scheme of the app, registered by the installers and packages from the `deep-link`
+/// plugin settings in `tauri.conf.json`
+pub const SCHEME: &str = "msgreader";
+
+/// What a `msgreader://open?...` link opens
+#[derive(Debug, PartialEq, Eq)]
+pub enum Target {
+    /// `path=<absolute path>` of a .msg, .eml, .pst, .ost, .mbox, .zip or .gz file on a
+    /// local drive; the user confirms it before it is opened
+    File(PathBuf),
+    /// `archive=<id>` of a message in the archive of the active profile
+    ArchiveMessage(i64),
+}
+
+/// An archived message opened by a link, for the frontend
+#[derive(serde::Serialize, Clone)]
+#[serde(rename_all = "camelCase")]
+pub struct ArchiveMessageLink {
+    pub id: i64,
+    pub file_name: String,
+}
+
+/// Whether a command-line argument is a link rather than a file. Windows and Linux start
+/// the app (or forward to the running one) with the link as its argument.
+pub fn is_link(arg: &str) -> bool {
+    arg.get(..SCHEME.len() + 1)
+        .is_some_and(|prefix| prefix.eq_ignore_ascii_case(&format!("{}:", SCHEME)))
+}
+
+/// Whether a path names a file on this machine. A UNC path (`\\host\share\...`, with
+/// either slash) or a device path (`\\.\...`, `\\?\UNC\...`) would make Windows connect to
+/// the host and send it the user's NTLM credentials; of the paths that start with two
+/// slashes only a verbatim drive path (`\\?\C:\...`) is local.
+fn is_local(path: &Path) -> bool {
+    let text = path.as_os_str().to_string_lossy();
+    let mut start = text.chars().take(2);
+    if !start.all(|c| c == '\\' || c == '/') {
+        return true;
+    }
+    matches!(
+        path.components().next(),
+        Some(Component::Prefix(prefix)) if matches!(prefix.kind(), Prefix::VerbatimDisk(_))
+    )
+}
+
+/// The target of a link. Paths must be absolute, as a link has no working directory, and
+/// local, as any web page can send a link.
+pub fn parse(link: &str) -> Result<Target, String> {
+    let url = Url::parse(link).map_err(|e| format!("Invalid link {}: {}", link, e))?;
+    if !url.scheme().eq_ignore_ascii_case(SCHEME) {
+        return Err(format!("Not a {} link: {}", SCHEME, link));
+    }
+    // `msgreader://open?...` has the action as its host, `msgreader:open?...` as its path
+    let action = url
+        .host_str()
+        .unwrap_or_else(|| url.path())
+        .trim_matches('/');
+    if !action.eq_ignore_ascii_case("open") {
+        return Err(format!("Unknown link action: {}", action));
+    }
+
+    let value = |name: &str| {
+        url.query_pairs()
+            .find(|(key, _)| key == name)
+            .map(|(_, value)| value.into_owned())
+    };
+    if let Some(path) = value("path") {
+        let path = PathBuf::from(path);
+        if !path.is_absolute() {
+            return Err(format!("Linked path is not absolute: {:?}", path));
+        }
+        if !is_local(&path) {
+            return Err(format!("Linked path is not on a local drive: {:?}", path));
+        }
+        return Ok(Target::File(path));
+    }
+    if let Some(id) = value("archive") {
+        return id
+            .parse()
+            .map(Target::ArchiveMessage)
+            .map_err(|_| format!("Invalid archive message id in link: {}", id));
+    }
+    Err(format!("Link names no path or archive message: {}", link))
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn parses_files_and_archive_messages() {
+        assert_eq!(
+            parse("msgreader://open?path=%2Fmail%2Foffer.msg"),
+            Ok(Target::File(PathBuf::from("/mail/offer.msg")))
+        );
+        assert_eq!(
+            parse("MSGREADER:open?archive=42"),
+            Ok(Target::ArchiveMessage(42))
+        );
+        assert!(parse("msgreader://open?path=offer.msg").is_err());
+        assert!(parse("msgreader://delete?archive=42").is_err());
+        assert!(parse("https://open?archive=42").is_err());
+    }
+
+    #[test]
+    fn rejects_network_paths() {
+        for path in [
+            r"\\host\share\offer.msg",
+            "//host/share/offer.msg",
+            r"\\?\UNC\host\x.msg",
+        ] {
+            assert!(!is_local(Path::new(path)), "{}", path);
+        }
+        assert!(parse("msgreader://open?path=%2F%2Fhost%2Fshare%2Foffer.msg").is_err());
+        assert!(is_local(Path::new("/mail/offer.msg")));
+    }
+
+    #[cfg(windows)]
+    #[test]
+    fn accepts_local_windows_paths() {
+        assert!(is_local(Path::new(r"C:\Mail\offer.msg")));
+        assert!(is_local(Path::new(r"\\?\C:\Mail\offer.msg")));
+        assert!(!is_local(Path::new(r"\\.\pipe\offer")));
+    }
+}
@@ -0,0 +1,234 @@
+use crate::headers::{self, Field};
+use mail_parser::DateTime;
+use std::net::IpAddr;
+use std::time::{SystemTime, UNIX_EPOCH};
+
+/// Clause keywords of a Received header (RFC 5321 section 4.4)
+const KEYWORDS: [&str; 6] = ["from", "by", "via", "with", "id", "for"];
+
+/// Servers' clocks disagree by a few seconds; hops that are earlier than the previous
+/// one by more than this are flagged
+const CLOCK_SKEW_TOLERANCE_MS: i64 = 60 * 1000;
+
+/// Dates further ahead of the clock of this machine are flagged
+const FUTURE_TOLERANCE_MS: i64 = 15 * 60 * 1000;
+
+// Anomalies of a hop
+const TIMESTAMP_INVERSION: &str = "timestamp-inversion";
+const PRIVATE_IP: &str = "private-ip";
+const MISSING_DATE: &str = "missing-date";
+const FUTURE_DATE: &str = "future-date";
+
+/// One `Received` header: a server that accepted the message
+#[derive(serde::Serialize)]
+#[serde(rename_all = "camelCase")]
+pub struct Hop {
+    /// Name of the sending host as it introduced itself, empty if not recorded
+    pub from: String,
+    /// Address of the sending host, if recorded
+    pub from_ip: Option<String>,
+    /// Server that added the header
+    pub by: String,
+    /// Protocol, e.g. `ESMTPS` or `LMTP`
+    pub with: String,
+    /// Queue id at the receiving server
+    pub id: String,
+    /// Unix time in milliseconds, None if the header has no valid date
+    pub date: Option<i64>,
+    /// Milliseconds since the previous dated hop, or for the first hop since the Date
+    /// header; negative when the clocks disagree
+    pub delay: Option<i64>,
+    /// `timestamp-inversion`, `private-ip`, `missing-date` or `future-date`
+    pub anomalies: Vec<&'static str>,
+    /// The unfolded header value
+    pub header: String,
+}
+
+#[derive(serde::Serialize)]
+#[serde(rename_all = "camelCase")]
+pub struct DeliveryPath {
+    /// Date header in Unix milliseconds
+    pub sent: Option<i64>,
+    /// Hops in delivery order: the server that received the message first comes first
+    pub hops: Vec<Hop>,
+    /// Milliseconds from the Date header (or the first dated hop) to the last dated hop
+    pub total_delay: Option<i64>,
+}
+
+fn parse_date(value: &str) -> Option<i64> {
+    DateTime::parse_rfc822(value.trim()).map(|date| date.to_timestamp() * 1000)
+}
+
+/// Words and `(comments)` of the clauses before the date, by keyword
+fn clauses(text: &str) -> Vec<(&'static str, Vec<String>)> {
+    let mut tokens = Vec::new();
+    let mut token = String::new();
+    let mut depth = 0;
+    for c in text.chars() {
+        match c {
+            '(' => {
+                if depth == 0 && !token.is_empty() {
+                    tokens.push(std::mem::take(&mut token));
+                }
+                depth += 1;
+                token.push(c);
+            }
+            ')' if depth > 0 => {
+                depth -= 1;
+                token.push(c);
+                if depth == 0 {
+                    tokens.push(std::mem::take(&mut token));
+                }
+            }
+            c if c.is_whitespace() && depth == 0 => {
+                if !token.is_empty() {
+                    tokens.push(std::mem::take(&mut token));
+                }
+            }
+            c => token.push(c),
+        }
+    }
+    if !token.is_empty() {
+        tokens.push(token);
+    }
+
+    let mut clauses: Vec<(&'static str, Vec<String>)> = Vec::new();
+    for token in tokens {
+        let keyword = KEYWORDS
+            .iter()
+            .copied()
+            .find(|keyword| token.eq_ignore_ascii_case(keyword));
+        match (keyword, clauses.last_mut()) {
+            (Some(keyword), _) => clauses.push((keyword, Vec::new())),
+            (None, Some((_, words))) => words.push(token),
+            (None, None) => {}
+        }
+    }
+    clauses
+}
+
+/// First IP address in the tokens, e.g. from `(mail.example.com [203.0.113.5])`
+fn find_ip(tokens: &[String]) -> Option<IpAddr> {
+    tokens
+        .iter()
+        .flat_map(|token| {
+            token.split(|c: char| {
+                c.is_whitespace() || matches!(c, '[' | ']' | '(' | ')' | ',' | ';' | '=')
+            })
+        })
+        .map(|candidate| {
+            candidate
+                .trim_start_matches("IPv6:")
+                .trim_start_matches("ipv6:")
+        })
+        .find_map(|candidate| candidate.parse().ok())
+}
+
+/// Private, loopback, link-local and shared (CGNAT) addresses
+fn is_private(ip: &IpAddr) -> bool {
+    match ip {
+        IpAddr::V4(ip) => {
+            let [first, second, ..] = ip.octets();
+            ip.is_private()
+                || ip.is_loopback()
+                || ip.is_link_local()
+                || (first == 100 && second & 0xC0 == 64)
+        }
+        IpAddr::V6(ip) => {
+            let first = ip.segments()[0];
+            ip.is_loopback() || first & 0xFE00 == 0xFC00 || first & 0xFFC0 == 0xFE80
+        }
+    }
+}
+
+fn hop(value: &str) -> Hop {
+    // The date follows the last semicolon
+    let (text, date) = match value.rfind(';') {
+        Some(end) => (&value[..end], parse_date(&value[end + 1..])),
+        None => (value, None),
+    };
+
+    let clauses = clauses(text);
+    let clause = |keyword: &str| {
+        clauses
+            .iter()
+            .find(|(name, _)| *name == keyword)
+            .map(|(_, words)| words.as_slice())
+            .unwrap_or_default()
+    };
+    let first_word = |keyword: &str| {
+        clause(keyword)
+            .iter()
+            .find(|word| !word.starts_with('('))
+            .cloned()
+            .unwrap_or_default()
+    };
+
+    let from_ip = find_ip(clause("from"));
+    let mut anomalies = Vec::new();
+    if from_ip.as_ref().is_some_and(is_private) {
+        anomalies.push(PRIVATE_IP);
+    }
+    Hop {
+        from: first_word("from"),
+        from_ip: from_ip.map(|ip| ip.to_string()),
+        by: first_word("by"),
+        with: first_word("with"),
+        id: first_word("id"),
+        date,
+        delay: None,
+        anomalies,
+        header: value.to_string(),
+    }
+}
+
+/// The hops of the Received headers, which servers add at the top, in delivery order
+fn delivery_path(fields: &[Field], now: i64) -> DeliveryPath {
+    let sent = fields
+        .iter()
+        .find(|field| field.name == "date")
+        .and_then(|field| parse_date(&field.value()));
+
+    let mut previous = sent;
+    let mut hops = Vec::new();
+    for field in fields.iter().rev().filter(|field| field.name == "received") {
+        let mut hop = hop(&field.value());
+        hop.delay = hop
+            .date
+            .zip(previous)
+            .map(|(date, previous)| date - previous);
+        match hop.date {
+            None => hop.anomalies.push(MISSING_DATE),
+            Some(date) if date > now.saturating_add(FUTURE_TOLERANCE_MS) => {
+                hop.anomalies.push(FUTURE_DATE)
+            }
+            Some(date) => previous = Some(date),
+        }
+        if hop
+            .delay
+            .is_some_and(|delay| delay < -CLOCK_SKEW_TOLERANCE_MS)
+        {
+            hop.anomalies.push(TIMESTAMP_INVERSION);
+        }
+        hops.push(hop);
+    }
+
+    let first = sent.or_else(|| hops.iter().find_map(|hop| hop.date));
+    let last = hops.iter().rev().find_map(|hop| hop.date);
+    DeliveryPath {
+        sent,
+        total_delay: first.zip(last).map(|(first, last)| last - first),
+        hops,
+    }
+}
+
+/// The delivery path of a message (.eml bytes, or the transport headers of an .msg file)
+pub fn analyze(data: &[u8]) -> Result<DeliveryPath, String> {
+    let (message, _) = headers::original_message(data)?;
+    let (fields, _) = headers::split_message(&message);
+    let now = SystemTime::now()
+        .duration_since(UNIX_EPOCH)
+        .map(|elapsed| elapsed.as_millis() as i64)
+        .unwrap_or(i64::MAX);
+    Ok(delivery_path(&fields, now))
+}
@@ -0,0 +1,108 @@
+use crate::headers;
+use crate::message::html_to_text;
+use crate::threads::message_ids;
+use sha2::{Digest, Sha256};
+use std::collections::HashMap;
+
+/// An opened message as the frontend describes it for duplicate detection
+#[derive(serde::Deserialize)]
+#[serde(rename_all = "camelCase")]
+pub struct DuplicateInput {
+    /// Chosen by the frontend to find the message again, returned as is
+    pub key: String,
+    /// Raw transport headers, empty if the message has none
+    #[serde(default)]
+    pub headers: String,
+    /// Message-ID when the headers do not have it
+    #[serde(default)]
+    pub message_id: String,
+    /// Unix time in milliseconds
+    pub date: Option<i64>,
+    #[serde(default)]
+    pub body_text: String,
+    /// Only used when the message has no text body
+    #[serde(default)]
+    pub body_html: String,
+}
+
+/// Messages that are copies of each other
+#[derive(serde::Serialize)]
+#[serde(rename_all = "camelCase")]
+pub struct DuplicateGroup {
+    /// Keys in the order the messages were given; the first is the one to keep
+    pub keys: Vec<String>,
+    /// The Message-ID the copies share, empty if they have none
+    pub message_id: String,
+}
+
+/// Canonical Message-ID: the first `<...>` id of the Message-ID header (or the given
+/// one), lowercase and without angle brackets
+fn canonical_message_id(message: &DuplicateInput) -> String {
+    let (fields, _) = headers::split_message(message.headers.as_bytes());
+    fields
+        .iter()
+        .filter(|field| field.name == "message-id")
+        .flat_map(|field| message_ids(&field.value()))
+        .chain(message_ids(&message.message_id))
+        .next()
+        .or_else(|| {
+            // Some .msg files keep the id without angle brackets
+            let id = message.message_id.trim().to_lowercase();
+            (!id.is_empty()).then_some(id)
+        })
+        .unwrap_or_default()
+}
+
+/// SHA-256 of the body with whitespace runs collapsed, so line endings and wrapping
+/// changed by converting between .msg and .eml do not matter. None for empty bodies.
+fn body_digest(message: &DuplicateInput) -> Option<Vec<u8>> {
+    let text = if message.body_text.trim().is_empty() {
+        html_to_text(&message.body_html)
+    } else {
+        message.body_text.clone()
+    };
+    let words: Vec<&str> = text.split_whitespace().collect();
+    (!words.is_empty()).then(|| Sha256::digest(words.join(" ").as_bytes()).to_vec())
+}
+
+/// The identity of a message: SHA-256 of its canonical Message-ID, its date to the
+/// second (.msg files store milliseconds, Date headers do not) and the digest of its
+/// body. None if the message has neither a Message-ID nor a body to compare.
+fn identity(message: &DuplicateInput) -> Option<(Vec<u8>, String)> {
+    let message_id = canonical_message_id(message);
+    let body = body_digest(message);
+    if message_id.is_empty() && body.is_none() {
+        return None;
+    }
+    let mut hasher = Sha256::new();
+    hasher.update(message_id.as_bytes());
+    hasher.update(b"\n");
+    if let Some(date) = message.date {
+        hasher.update(date.div_euclid(1000).to_string().as_bytes());
+    }
+    hasher.update(b"\n");
+    hasher.update(body.unwrap_or_default());
+    Some((hasher.finalize().to_vec(), message_id))
+}
+
+/// Groups of messages with the same Message-ID, date and body, in the order their first
+/// message was given. Messages without a copy are left out.
+pub fn find(messages: Vec<DuplicateInput>) -> Vec<DuplicateGroup> {
+    let mut groups: Vec<DuplicateGroup> = Vec::new();
+    let mut by_identity: HashMap<Vec<u8>, usize> = HashMap::new();
+    for message in messages {
+        let Some((identity, message_id)) = identity(&message) else {
+            continue;
+        };
+        let index = *by_identity.entry(identity).or_insert_with(|| {
+            groups.push(DuplicateGroup {
+                keys: Vec::new(),
+                message_id,
+            });
+            groups.len() - 1
+        });
+        groups[index].keys.push(message.key);
+    }
+    groups.retain(|group| group.keys.len() > 1);
+    groups
+}
@@ -0,0 +1,364 @@
+use crate::message::{Attachment, Message, MessageSummary, Recipient};
+use base64::{engine::general_purpose::STANDARD, Engine as _};
+use mail_parser::{Address, MessageParser, MessagePart, MimeHeaders};
+use std::io::Read;
+use std::ops::Range;
+use std::path::Path;
+
+/// Bytes read from the start of an .eml file for `summary`; headers are rarely longer
+pub const SUMMARY_READ_LIMIT: u64 = 64 * 1024;
+
+/// Raw header block: everything before the first empty line. Searched in the bytes, so
+/// a large message is not converted to text as a whole.
+fn raw_headers(data: &[u8]) -> String {
+    let find = |separator: &[u8]| data.windows(separator.len()).position(|w| w == separator);
+    let end = [find(b"\r\n\r\n"), find(b"\n\n")]
+        .into_iter()
+        .flatten()
+        .min()
+        .unwrap_or(data.len());
+    String::from_utf8_lossy(&data[..end]).into_owned()
+}
+
+fn recipients(address: Option<&Address>, kind: &'static str) -> Vec<Recipient> {
+    address
+        .map(|address| {
+            address
+                .iter()
+                .map(|addr| Recipient {
+                    name: addr.name().unwrap_or_default().to_string(),
+                    email: addr.address().unwrap_or_default().to_string(),
+                    kind,
+                })
+                .collect()
+        })
+        .unwrap_or_default()
+}
+
+/// Parse an .eml file (RFC 5322 / MIME). Nested multiparts, encoded words and
+/// base64/quoted-printable bodies are decoded by mail-parser, which also tolerates
+/// missing or malformed boundaries.
+pub fn parse(path: &Path) -> Result<Message, String> {
+    let data = std::fs::read(path).map_err(|e| format!("Failed to read EML file: {}", e))?;
+    parse_bytes(&data)
+}
+
+/// Parse an .eml file in memory, see `parse`
+pub fn parse_bytes(data: &[u8]) -> Result<Message, String> {
+    parse_parts(data, usize::MAX).map(|(message, _)| message)
+}
+
+/// Parse an .eml file in memory, keeping only the content of attachments up to
+/// `eager_limit` bytes. Larger attachments are returned without content, together with
+/// their index and the byte range of their MIME part to read it from with
+/// `read_attachment`. mail-parser still decodes every part once while parsing; only
+/// the copies that would be kept for the frontend are saved.
+pub fn parse_lazy(
+    data: &[u8],
+    eager_limit: usize,
+) -> Result<(Message, Vec<(usize, Range<usize>)>), String> {
+    parse_parts(data, eager_limit)
+}
+
+/// Content of an attachment left out by `parse_lazy`: its MIME part, headers included,
+/// is parsed on its own
+pub fn read_attachment(part: &[u8]) -> Result<Vec<u8>, String> {
+    let message = MessageParser::default()
+        .parse(part)
+        .ok_or("Failed to read attachment: invalid MIME part")?;
+    Ok(message.part(0).map(|part| part.contents().to_vec()).unwrap_or_default())
+}
+
+fn attachment(part: &MessagePart, contents: Option<&[u8]>, size: usize) -> Attachment {
+    Attachment {
+        file_name: part.attachment_name().unwrap_or_default().to_string(),
+        mime_type: part
+            .content_type()
+            .map(|content_type| match content_type.subtype() {
+                Some(subtype) => format!("{}/{}", content_type.ctype(), subtype),
+                None => content_type.ctype().to_string(),
+            })
+            .unwrap_or_else(|| "application/octet-stream".to_string()),
+        content_id: part
+            .content_id()
+            .unwrap_or_default()
+            .trim_matches(|c| c == '<' || c == '>')
+            .to_string(),
+        size,
+        content_base64: contents.map(|data| STANDARD.encode(data)).unwrap_or_default(),
+    }
+}
+
+fn parse_parts(
+    data: &[u8],
+    eager_limit: usize,
+) -> Result<(Message, Vec<(usize, Range<usize>)>), String> {
+    let message = MessageParser::default()
+        .parse(data)
+        .ok_or("Not an EML file")?;
+
+    let sender = message.from().and_then(Address::first);
+    let mut all_recipients = recipients(message.to(), "to");
+    all_recipients.extend(recipients(message.cc(), "cc"));
+    all_recipients.extend(recipients(message.bcc(), "bcc"));
+
+    let mut attachments = Vec::new();
+    let mut deferred = Vec::new();
+    for part in message.attachments() {
+        let contents = part.contents();
+        if contents.len() > eager_limit {
+            deferred.push((attachments.len(), part.raw_header_offset()..part.raw_end_offset()));
+            attachments.push(attachment(part, None, contents.len()));
+        } else {
+            attachments.push(attachment(part, Some(contents), contents.len()));
+        }
+    }
+
+    Ok((
+        Message {
+            subject: message.subject().unwrap_or_default().to_string(),
+            sender_name: sender
+                .and_then(|addr| addr.name())
+                .unwrap_or_default()
+                .to_string(),
+            sender_email: sender
+                .and_then(|addr| addr.address())
+                .unwrap_or_default()
+                .to_string(),
+            recipients: all_recipients,
+            date: message.date().map(|date| date.to_timestamp() * 1000),
+            message_id: message.message_id().unwrap_or_default().to_string(),
+            headers: raw_headers(data),
+            body_text: message.body_text(0).unwrap_or_default().into_owned(),
+            body_html: message.body_html(0).unwrap_or_default().into_owned(),
+            attachments,
+        },
+        deferred,
+    ))
+}
+
+/// Subject, sender and date of an .eml file from its header block only (for listing folders)
+pub fn summary(path: &Path) -> Result<MessageSummary, String> {
+    let mut data = Vec::new();
+    std::fs::File::open(path)
+        .and_then(|file| file.take(SUMMARY_READ_LIMIT).read_to_end(&mut data))
+        .map_err(|e| format!("Failed to read EML file: {}", e))?;
+    summary_from_bytes(&data)
+}
+
+/// Like `summary`, for a message that is already in memory (e.g. the start of an mbox
+/// entry); only the header block is parsed
+pub fn summary_from_bytes(data: &[u8]) -> Result<MessageSummary, String> {
+    let headers = format!("{}\r\n\r\n", raw_headers(data));
+    let message = MessageParser::default()
+        .parse(headers.as_bytes())
+        .ok_or("Not an EML file")?;
+
+    let sender = message.from().and_then(Address::first);
+    Ok(MessageSummary {
+        subject: message.subject().unwrap_or_default().to_string(),
+        sender_name: sender.and_then(|addr| addr.name()).unwrap_or_default().to_string(),
+        sender_email: sender.and_then(|addr| addr.address()).unwrap_or_default().to_string(),
+        date: message.date().map(|date| date.to_timestamp() * 1000),
+    })
+}
+
+const WEEKDAYS: [&str; 7] = ["Thu", "Fri", "Sat", "Sun", "Mon", "Tue", "Wed"];
+const MONTHS: [&str; 12] = [
+    "Jan", "Feb", "Mar", "Apr", "May", "Jun", "Jul", "Aug", "Sep", "Oct", "Nov", "Dec",
+];
+
+/// RFC 5322 date in UTC, e.g. `Wed, 10 Jul 2024 09:00:00 +0000`
+pub(crate) fn format_date(ms: i64) -> String {
+    let days = ms.div_euclid(86_400_000);
+    let seconds = ms.rem_euclid(86_400_000) / 1000;
+    let (year, month, day) = crate::calendar::civil_from_days(days);
+    format!(
+        "{}, {} {} {} {:02}:{:02}:{:02} +0000",
+        WEEKDAYS[days.rem_euclid(7) as usize],
+        day,
+        MONTHS[month as usize - 1],
+        year,
+        seconds / 3600,
+        seconds / 60 % 60,
+        seconds % 60
+    )
+}
+
+/// Header text as is if it is printable ASCII, as RFC 2047 encoded words otherwise
+fn encode_words(text: &str) -> String {
+    if text.chars().all(|c| c == ' ' || c.is_ascii_graphic()) {
+        return text.to_string();
+    }
+    // Encoded words may be at most 75 characters, which leaves 45 bytes of text each
+    let mut words = Vec::new();
+    let mut chunk = String::new();
+    for c in text.chars() {
+        if chunk.len() + c.len_utf8() > 45 {
+            words.push(format!("=?UTF-8?B?{}?=", STANDARD.encode(&chunk)));
+            chunk.clear();
+        }
+        chunk.push(c);
+    }
+    words.push(format!("=?UTF-8?B?{}?=", STANDARD.encode(&chunk)));
+    words.join("\r\n ")
+}
+
+/// `"Name" <address>`, or just the address without a name
+fn format_address(name: &str, email: &str) -> String {
+    if name.is_empty() || name == email {
+        return email.to_string();
+    }
+    let name = if name.chars().all(|c| c == ' ' || c.is_ascii_graphic()) {
+        format!("\"{}\"", name.replace('\\', "\\\\").replace('"', "\\\""))
+    } else {
+        encode_words(name)
+    };
+    if email.is_empty() {
+        name
+    } else {
+        format!("{} <{}>", name, email)
+    }
+}
+
+/// Base64 in lines of 76 characters
+fn wrap_base64(data: &[u8]) -> String {
+    let encoded = STANDARD.encode(data);
+    encoded
+        .as_bytes()
+        .chunks(76)
+        .map(|line| String::from_utf8_lossy(line).into_owned())
+        .collect::<Vec<_>>()
+        .join("\r\n")
+}
+
+/// Quote a file name for a MIME parameter
+fn file_name_parameter(name: &str) -> String {
+    let name = name.replace(['"', '\\', '\r', '\n'], "_");
+    encode_words(&name)
+}
+
+fn attachment_part(boundary: &str, attachment: &Attachment, inline: bool) -> String {
+    let file_name = file_name_parameter(if attachment.file_name.is_empty() {
+        "attachment"
+    } else {
+        &attachment.file_name
+    });
+    let data = STANDARD.decode(&attachment.content_base64).unwrap_or_default();
+    let mut part = format!(
+        "--{}\r\nContent-Type: {}; name=\"{}\"\r\nContent-Transfer-Encoding: base64\r\n",
+        boundary, attachment.mime_type, file_name
+    );
+    if inline {
+        part.push_str(&format!("Content-ID: <{}>\r\n", attachment.content_id));
+    }
+    part.push_str(&format!(
+        "Content-Disposition: {}; filename=\"{}\"\r\n\r\n{}\r\n",
+        if inline { "inline" } else { "attachment" },
+        file_name,
+        wrap_base64(&data)
+    ));
+    part
+}
+
+/// Build an .eml file (RFC 5322 / MIME) from a parsed message, structured like the
+/// frontend's EML export: text and HTML body as multipart/alternative, images referenced
+/// by Content-ID in multipart/related, other attachments in multipart/mixed
+pub fn write(message: &Message) -> Vec<u8> {
+    let unique = std::time::SystemTime::now()
+        .duration_since(std::time::UNIX_EPOCH)
+        .map(|elapsed| elapsed.as_nanos())
+        .unwrap_or(0);
+    let boundary_mixed = format!("----=_msgReader_mixed_{:x}", unique);
+    let boundary_related = format!("----=_msgReader_related_{:x}", unique);
+    let boundary_alt = format!("----=_msgReader_alt_{:x}", unique);
+
+    let html = if message.body_html.is_empty() {
+        format!(
+            "<pre>{}</pre>",
+            message
+                .plain_text()
+                .replace('&', "&amp;")
+                .replace('<', "&lt;")
+                .replace('>', "&gt;")
+        )
+    } else {
+        message.body_html.clone()
+    };
+    let (inline, regular): (Vec<&Attachment>, Vec<&Attachment>) =
+        message.attachments.iter().partition(|attachment| {
+            !attachment.content_id.is_empty()
+                && html.contains(&format!("cid:{}", attachment.content_id))
+        });
+
+    let recipients = |kind: &str| {
+        message
+            .recipients
+            .iter()
+            .filter(|recipient| recipient.kind == kind)
+            .map(|recipient| format_address(&recipient.name, &recipient.email))
+            .collect::<Vec<_>>()
+            .join(", ")
+    };
+    let mut headers = vec![
+        ("From", format_address(&message.sender_name, &message.sender_email)),
+        ("To", recipients("to")),
+        ("Cc", recipients("cc")),
+        ("Bcc", recipients("bcc")),
+        ("Subject", encode_words(&message.subject)),
+        ("Date", message.date.map(format_date).unwrap_or_default()),
+        ("Message-ID", message.message_id.clone()),
+    ];
+    headers.retain(|(_, value)| !value.is_empty());
+    let mut eml: String = headers
+        .iter()
+        .map(|(name, value)| format!("{}: {}\r\n", name, value))
+        .collect();
+    eml.push_str("MIME-Version: 1.0\r\n");
+    eml.push_str(&if !regular.is_empty() {
+        format!("Content-Type: multipart/mixed; boundary=\"{}\"\r\n\r\n", boundary_mixed)
+    } else if !inline.is_empty() {
+        format!("Content-Type: multipart/related; boundary=\"{}\"\r\n\r\n", boundary_related)
+    } else {
+        format!("Content-Type: multipart/alternative; boundary=\"{}\"\r\n\r\n", boundary_alt)
+    });
+
+    let alternative = format!(
+        "--{b}\r\nContent-Type: text/plain; charset=UTF-8\r\nContent-Transfer-Encoding: base64\r\n\r\n\
+         {}\r\n\r\n--{b}\r\nContent-Type: text/html; charset=UTF-8\r\n\
+         Content-Transfer-Encoding: base64\r\n\r\n{}\r\n\r\n--{b}--\r\n",
+        wrap_base64(message.plain_text().as_bytes()),
+        wrap_base64(html.as_bytes()),
+        b = boundary_alt
+    );
+    let body = if inline.is_empty() {
+        alternative
+    } else {
+        let mut related = format!(
+            "--{}\r\nContent-Type: multipart/alternative; boundary=\"{}\"\r\n\r\n{}",
+            boundary_related, boundary_alt, alternative
+        );
+        for attachment in &inline {
+            related.push_str(&attachment_part(&boundary_related, attachment, true));
+        }
+        related.push_str(&format!("--{}--\r\n", boundary_related));
+        related
+    };
+
+    if regular.is_empty() {
+        eml.push_str(&body);
+    } else {
+        eml.push_str(&format!(
+            "--{}\r\nContent-Type: multipart/{}; boundary=\"{}\"\r\n\r\n{}",
+            boundary_mixed,
+            if inline.is_empty() { "alternative" } else { "related" },
+            if inline.is_empty() { &boundary_alt } else { &boundary_related },
+            body
+        ));
+        for attachment in &regular {
+            eml.push_str(&attachment_part(&boundary_mixed, attachment, false));
+        }
+        eml.push_str(&format!("--{}--\r\n", boundary_mixed));
+    }
+    eml.into_bytes()
+}
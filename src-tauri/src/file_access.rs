@@ -0,0 +1,77 @@
+use std::collections::HashSet;
+use std::path::{Path, PathBuf};
+use std::sync::Mutex;
+
+/// Files and folders the user opened in this session: chosen in a dialog, dropped onto a
+/// window, passed on the command line or by the OS, reported by a watched folder, or kept
+/// in the recent files and the saved session. The interface renders untrusted HTML, so
+/// the commands that read or write a path given by the frontend accept only these
+/// instead of any path a script asks for. Paths are only ever granted by the backend.
+pub struct FileAccess {
+    files: Mutex<HashSet<PathBuf>>,
+    /// Everything in these folders and their subfolders is allowed
+    folders: Mutex<HashSet<PathBuf>>,
+}
+
+/// The absolute path with symbolic links and `..` resolved, so that a granted path cannot
+/// lead to another one; None if it does not exist
+fn resolve(path: &Path) -> Option<PathBuf> {
+    std::fs::canonicalize(path).ok()
+}
+
+impl FileAccess {
+    pub fn new() -> Self {
+        FileAccess {
+            files: Mutex::new(HashSet::new()),
+            folders: Mutex::new(HashSet::new()),
+        }
+    }
+
+    /// Allow a file, or a folder with everything in it. Paths that do not exist are
+    /// ignored.
+    pub fn grant(&self, path: &Path) {
+        let Some(resolved) = resolve(path) else {
+            return;
+        };
+        if resolved.is_dir() {
+            self.folders.lock().unwrap().insert(resolved);
+        } else {
+            self.files.lock().unwrap().insert(resolved);
+        }
+    }
+
+    fn allows(&self, resolved: &Path) -> bool {
+        self.files.lock().unwrap().contains(resolved)
+            || self
+                .folders
+                .lock()
+                .unwrap()
+                .iter()
+                .any(|folder| resolved.starts_with(folder))
+    }
+
+    /// Whether a path was granted, itself or by a folder it is in
+    pub fn is_allowed(&self, path: &Path) -> bool {
+        resolve(path).is_some_and(|resolved| self.allows(&resolved))
+    }
+
+    /// The path to read if it was granted, an error otherwise
+    pub fn check(&self, path: &str) -> Result<PathBuf, String> {
+        if self.is_allowed(Path::new(path)) {
+            return Ok(PathBuf::from(path));
+        }
+        log_warn!("Refused access to {}, which was not opened in this session", path);
+        Err(format!("Access denied: {} was not opened in this session", path))
+    }
+
+    /// The path of a file to write if it is in a granted folder, an error otherwise
+    pub fn check_target(&self, path: &str) -> Result<PathBuf, String> {
+        let target = PathBuf::from(path);
+        let folder = target.parent().filter(|parent| !parent.as_os_str().is_empty());
+        if folder.is_some_and(|folder| self.is_allowed(folder) && folder.is_dir()) {
+            return Ok(target);
+        }
+        log_warn!("Refused to write {}, which is not in a folder opened in this session", path);
+        Err(format!("Access denied: {} is not in a folder opened in this session", path))
+    }
+}
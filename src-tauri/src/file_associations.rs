@@ -0,0 +1,442 @@
+use std::process::Command;
+
+/// Extensions declared in `bundle.fileAssociations`, with their MIME types
+const EXTENSIONS: [(&str, &str); 2] = [
+    ("msg", "application/vnd.ms-outlook"),
+    ("eml", "message/rfc822"),
+];
+
+/// Whether msgReader opens a file type when it is double-clicked
+#[derive(serde::Serialize)]
+#[serde(rename_all = "camelCase")]
+pub struct AssociationStatus {
+    /// Without the dot, e.g. `msg`
+    pub extension: &'static str,
+    pub is_default: bool,
+    /// The current default as the OS names it (ProgID, application path or .desktop
+    /// file), None if no app is set or it cannot be found out
+    pub handler: Option<String>,
+}
+
+/// The default app of every file type msgReader declares. The associations themselves
+/// are registered and removed by the installer or package (see doc/deployment.md); only
+/// `make_default` on Linux adds a user-level entry for packagings without one, which
+/// `unregister` removes again along with the keys of a per-user Windows installation.
+pub fn status() -> Vec<AssociationStatus> {
+    EXTENSIONS
+        .iter()
+        .map(|&(extension, mime_type)| {
+            let (handler, is_default) = default_handler(extension, mime_type);
+            AssociationStatus {
+                extension,
+                is_default,
+                handler,
+            }
+        })
+        .collect()
+}
+
+fn output_text(command: &mut Command) -> Option<String> {
+    let output = command.output().ok().filter(|output| output.status.success())?;
+    let text = String::from_utf8_lossy(&output.stdout).trim().to_string();
+    (!text.is_empty()).then_some(text)
+}
+
+#[cfg(windows)]
+fn reg_query(key: &str, value: Option<&str>) -> Option<String> {
+    use std::os::windows::process::CommandExt;
+    const CREATE_NO_WINDOW: u32 = 0x0800_0000;
+
+    let mut command = Command::new("reg");
+    command.args(["query", key]).creation_flags(CREATE_NO_WINDOW);
+    match value {
+        Some(value) => command.args(["/v", value]),
+        None => command.arg("/ve"),
+    };
+    // `    ProgId    REG_SZ    msgReader.msg`
+    output_text(&mut command)?.lines().find_map(|line| {
+        ["REG_SZ", "REG_EXPAND_SZ"].iter().find_map(|kind| {
+            line.split_once(kind)
+                .map(|(_, data)| data.trim().to_string())
+                .filter(|data| !data.is_empty())
+        })
+    })
+}
+
+/// ProgIDs the installers register for `bundle.fileAssociations`, per extension
+#[cfg(windows)]
+const PROG_IDS: [(&str, &str); 2] = [("msg", "Outlook Email"), ("eml", "Email Message")];
+
+/// Delete a registry key, or one of its values (`""` for the default value), if it exists
+#[cfg(windows)]
+fn reg_delete(key: &str, value: Option<&str>) -> Result<(), String> {
+    use std::os::windows::process::CommandExt;
+    const CREATE_NO_WINDOW: u32 = 0x0800_0000;
+
+    let command = |action: &str| {
+        let mut command = Command::new("reg");
+        command.args([action, key]).creation_flags(CREATE_NO_WINDOW);
+        match value {
+            Some("") => command.arg("/ve"),
+            Some(value) => command.args(["/v", value]),
+            None => &mut command,
+        };
+        command
+    };
+    let exists = command("query")
+        .output()
+        .is_ok_and(|output| output.status.success());
+    if !exists {
+        return Ok(());
+    }
+    let output = command("delete")
+        .arg("/f")
+        .output()
+        .map_err(|e| format!("Failed to run reg: {}", e))?;
+    if !output.status.success() {
+        return Err(format!(
+            "Failed to remove {}: {}",
+            key,
+            String::from_utf8_lossy(&output.stderr).trim()
+        ));
+    }
+    Ok(())
+}
+
+/// The ProgID chosen by the user in Explorer or Settings, else the machine default, and
+/// whether its open command starts this executable
+#[cfg(windows)]
+fn default_handler(extension: &str, _mime_type: &str) -> (Option<String>, bool) {
+    let user_choice = format!(
+        r"HKCU\Software\Microsoft\Windows\CurrentVersion\Explorer\FileExts\.{}\UserChoice",
+        extension
+    );
+    let Some(prog_id) = reg_query(&user_choice, Some("ProgId"))
+        .or_else(|| reg_query(&format!(r"HKCR\.{}", extension), None))
+    else {
+        return (None, false);
+    };
+    let exe = std::env::current_exe()
+        .map(|path| path.to_string_lossy().to_lowercase())
+        .unwrap_or_default();
+    let is_default = reg_query(&format!(r"HKCR\{}\shell\open\command", prog_id), None)
+        .map(|command| !exe.is_empty() && command.to_lowercase().contains(&exe))
+        // Packaged (MSIX) apps have generated AppX ProgIDs without a command to compare
+        .unwrap_or(false)
+        || prog_id.to_lowercase().contains("msgreader");
+    (Some(prog_id), is_default)
+}
+
+/// The app LaunchServices would open a file of the type with, asked for an empty probe
+/// file since the API works on URLs
+#[cfg(target_os = "macos")]
+fn default_handler(extension: &str, _mime_type: &str) -> (Option<String>, bool) {
+    const SCRIPT: &str = "function run(argv) { ObjC.import('AppKit'); \
+        var url = $.NSWorkspace.sharedWorkspace.URLForApplicationToOpenURL(\
+        $.NSURL.fileURLWithPath(argv[0])); return url.isNil() ? '' : url.path.js; }";
+
+    let probe = std::env::temp_dir().join(format!("msgreader-association.{}", extension));
+    if std::fs::write(&probe, b"").is_err() {
+        return (None, false);
+    }
+    let handler = output_text(
+        Command::new("osascript")
+            .args(["-l", "JavaScript", "-e", SCRIPT])
+            .arg(&probe),
+    );
+    let _ = std::fs::remove_file(&probe);
+
+    // The bundle is the `.app` folder around the executable
+    let bundle = std::env::current_exe().ok().and_then(|exe| {
+        exe.ancestors()
+            .find(|path| path.extension().is_some_and(|ext| ext == "app"))
+            .map(|path| path.to_path_buf())
+    });
+    let is_default = match (&handler, bundle) {
+        (Some(handler), Some(bundle)) => std::path::Path::new(handler) == bundle,
+        _ => false,
+    };
+    (handler, is_default)
+}
+
+/// The .desktop file set as default for the MIME type. Its name depends on the package
+/// (`msgReader.desktop`, `com.rasalas.msgreader.desktop`, ...).
+#[cfg(all(unix, not(target_os = "macos")))]
+fn default_handler(_extension: &str, mime_type: &str) -> (Option<String>, bool) {
+    let handler = output_text(Command::new("xdg-mime").args(["query", "default", mime_type]));
+    let is_default = handler
+        .as_ref()
+        .is_some_and(|handler| handler.to_lowercase().contains("msgreader"));
+    (handler, is_default)
+}
+
+#[cfg(not(any(unix, windows)))]
+fn default_handler(_extension: &str, _mime_type: &str) -> (Option<String>, bool) {
+    (None, false)
+}
+
+/// Name of the .desktop file written by `make_default`
+#[cfg(all(unix, not(target_os = "macos")))]
+const DESKTOP_FILE: &str = "msgreader.desktop";
+
+/// `$XDG_DATA_HOME`, `~/.local/share` by default
+#[cfg(all(unix, not(target_os = "macos")))]
+fn data_home() -> Result<std::path::PathBuf, String> {
+    std::env::var_os("XDG_DATA_HOME")
+        .filter(|dir| !dir.is_empty())
+        .map(std::path::PathBuf::from)
+        .or_else(|| {
+            std::env::var_os("HOME").map(|home| std::path::Path::new(&home).join(".local/share"))
+        })
+        .ok_or_else(|| "HOME is not set".to_string())
+}
+
+#[cfg(all(unix, not(target_os = "macos")))]
+fn write_file(path: &std::path::Path, contents: &[u8]) -> Result<(), String> {
+    if let Some(dir) = path.parent() {
+        std::fs::create_dir_all(dir)
+            .map_err(|e| format!("Failed to create {}: {}", dir.display(), e))?;
+    }
+    std::fs::write(path, contents).map_err(|e| format!("Failed to write {}: {}", path.display(), e))
+}
+
+/// Quote a path for the Exec key of a .desktop file. Backslashes are escaped twice, once
+/// for the quoting and once for the string value.
+#[cfg(all(unix, not(target_os = "macos")))]
+fn desktop_exec(path: &str) -> String {
+    let mut quoted = String::from("\"");
+    for c in path.chars() {
+        match c {
+            '\\' => quoted.push_str("\\\\\\\\"),
+            '"' | '`' | '$' => quoted.push('\\'),
+            '%' => quoted.push('%'),
+            _ => {}
+        }
+        if c != '\\' {
+            quoted.push(c);
+        }
+    }
+    quoted.push('"');
+    quoted
+}
+
+/// Install a .desktop file, the MIME types and scalable icons for the user, for
+/// packagings that come without them (AppImage, or a binary started from anywhere).
+/// Returns the name of the .desktop file to make the default.
+#[cfg(all(unix, not(target_os = "macos")))]
+fn install_desktop_entry() -> Result<String, String> {
+    const APP_ICON: &str = include_str!("../../app-icon.svg");
+    const FILE_ICON: &str = include_str!("../linux/message-file.svg");
+    const MIME_TYPES: &str = include_str!("../linux/msgreader.xml");
+
+    let data = data_home()?;
+    // An AppImage runs from a temporary mount, so the image itself is started
+    let exe = match std::env::var_os("APPIMAGE") {
+        Some(image) => std::path::PathBuf::from(image),
+        None => std::env::current_exe().map_err(|e| format!("Failed to find the app: {}", e))?,
+    };
+    let mime_types: Vec<&str> = EXTENSIONS.iter().map(|&(_, mime_type)| mime_type).collect();
+    let entry = format!(
+        "[Desktop Entry]\nType=Application\nName=msgReader\n\
+         Comment=Viewer for Outlook .msg and .eml files\nExec={} %F\nIcon=msgreader\n\
+         Terminal=false\nCategories=Office;Email;\nMimeType={};\n",
+        desktop_exec(&exe.to_string_lossy()),
+        mime_types.join(";")
+    );
+
+    let icons = data.join("icons/hicolor/scalable");
+    write_file(&icons.join("apps/msgreader.svg"), APP_ICON.as_bytes())?;
+    for mime_type in &mime_types {
+        let name = format!("mimetypes/{}.svg", mime_type.replace('/', "-"));
+        write_file(&icons.join(name), FILE_ICON.as_bytes())?;
+    }
+    write_file(&data.join("mime/packages/msgreader.xml"), MIME_TYPES.as_bytes())?;
+    write_file(&data.join("applications").join(DESKTOP_FILE), entry.as_bytes())?;
+
+    update_desktop_caches(&data);
+    Ok(DESKTOP_FILE.to_string())
+}
+
+/// The files `install_desktop_entry` writes below the data directory
+#[cfg(all(unix, not(target_os = "macos")))]
+fn desktop_entry_files(data: &std::path::Path) -> Vec<std::path::PathBuf> {
+    let icons = data.join("icons/hicolor/scalable");
+    let mut files = vec![
+        data.join("applications").join(DESKTOP_FILE),
+        data.join("mime/packages/msgreader.xml"),
+        icons.join("apps/msgreader.svg"),
+    ];
+    for &(_, mime_type) in &EXTENSIONS {
+        files.push(icons.join(format!("mimetypes/{}.svg", mime_type.replace('/', "-"))));
+    }
+    files
+}
+
+/// Refresh the MIME, .desktop and icon caches where the tools exist; desktops also
+/// rescan by themselves
+#[cfg(all(unix, not(target_os = "macos")))]
+fn update_desktop_caches(data: &std::path::Path) {
+    let _ = Command::new("update-mime-database").arg(data.join("mime")).status();
+    let _ = Command::new("update-desktop-database")
+        .arg(data.join("applications"))
+        .status();
+    let _ = Command::new("gtk-update-icon-cache")
+        .args(["-f", "-t"])
+        .arg(data.join("icons/hicolor"))
+        .status();
+}
+
+/// Make msgReader the default app for its file types where the OS lets apps do that:
+/// on Linux with `xdg-mime default`, after installing a .desktop file and icons unless
+/// the deb/rpm package brought its own. Returns false on Windows and macOS, where the
+/// user chooses the default (see `open_settings`), and an error if `xdg-mime query
+/// default` does not report msgReader afterwards.
+pub fn make_default() -> Result<bool, String> {
+    #[cfg(all(unix, not(target_os = "macos")))]
+    {
+        let desktop_file = match crate::app_info::packaging() {
+            // Flatpak registers its exported .desktop file, named after the app ID
+            "flatpak" => std::env::var("FLATPAK_ID")
+                .map(|id| format!("{}.desktop", id))
+                .map_err(|_| "FLATPAK_ID is not set".to_string())?,
+            "system" => "msgReader.desktop".to_string(),
+            _ => install_desktop_entry()?,
+        };
+        let mime_types = EXTENSIONS.iter().map(|&(_, mime_type)| mime_type);
+        let output = Command::new("xdg-mime")
+            .args(["default", &desktop_file])
+            .args(mime_types)
+            .output()
+            .map_err(|e| format!("Failed to run xdg-mime: {}", e))?;
+        if !output.status.success() {
+            return Err(format!(
+                "xdg-mime failed: {}",
+                String::from_utf8_lossy(&output.stderr).trim()
+            ));
+        }
+
+        if let Some(other) = status().into_iter().find(|status| !status.is_default) {
+            return Err(format!(
+                "The default app for .{} is still {}",
+                other.extension,
+                other.handler.as_deref().unwrap_or("not set")
+            ));
+        }
+        log_info!("Set {} as default app for .msg and .eml", desktop_file);
+        Ok(true)
+    }
+
+    #[cfg(not(all(unix, not(target_os = "macos"))))]
+    {
+        Ok(false)
+    }
+}
+
+/// Remove the file associations of a per-user installation: on Windows the ProgIDs, the
+/// extension keys that point at them, the "Open with" entries, the context-menu verbs
+/// and the Default Programs capability (see windows/hooks.nsh), on Linux the .desktop
+/// file, MIME types and icons written by `make_default`. Returns false where the app
+/// has nothing of its own to remove: per-machine installs need the uninstaller, MSIX
+/// packages, app bundles and Linux packages register through the package.
+pub fn unregister() -> Result<bool, String> {
+    #[cfg(windows)]
+    {
+        use windows::Win32::UI::Shell::{SHChangeNotify, SHCNE_ASSOCCHANGED, SHCNF_IDLIST};
+
+        if crate::app_info::packaging() == "msix" {
+            return Ok(false);
+        }
+        const CLASSES: &str = r"HKCU\Software\Classes";
+        let registered = PROG_IDS.iter().any(|(_, prog_id)| {
+            let command = format!(r"{}\{}\shell\open\command", CLASSES, prog_id);
+            reg_query(&command, None).is_some()
+        });
+        if !registered {
+            return Ok(false);
+        }
+
+        for (extension, prog_id) in PROG_IDS {
+            let extension_key = format!(r"{}\.{}", CLASSES, extension);
+            if reg_query(&extension_key, None).as_deref() == Some(prog_id) {
+                reg_delete(&extension_key, Some(""))?;
+            }
+            let open_with = format!(r"{}\OpenWithProgids", extension_key);
+            reg_delete(&open_with, Some(prog_id))?;
+            reg_delete(&format!(r"{}\{}", CLASSES, prog_id), None)?;
+        }
+        let exe = std::env::current_exe().map_err(|e| format!("Failed to find the app: {}", e))?;
+        if let Some(name) = exe.file_name() {
+            let application = format!(r"{}\Applications\{}", CLASSES, name.to_string_lossy());
+            reg_delete(&application, None)?;
+        }
+        for verb in ["msgReader.ConvertToEml", "msgReader.ExtractAttachments"] {
+            let verb = format!(r"{}\SystemFileAssociations\.msg\shell\{}", CLASSES, verb);
+            reg_delete(&verb, None)?;
+        }
+        reg_delete(r"HKCU\Software\RegisteredApplications", Some("msgReader"))?;
+        reg_delete(r"HKCU\Software\msgReader", None)?;
+
+        // Let Explorer drop the icons and handlers
+        unsafe { SHChangeNotify(SHCNE_ASSOCCHANGED, SHCNF_IDLIST, None, None) };
+        log_info!("Removed the file associations of msgReader");
+        Ok(true)
+    }
+
+    #[cfg(all(unix, not(target_os = "macos")))]
+    {
+        let data = data_home()?;
+        let mut removed = false;
+        for file in desktop_entry_files(&data) {
+            match std::fs::remove_file(&file) {
+                Ok(()) => removed = true,
+                Err(e) if e.kind() == std::io::ErrorKind::NotFound => {}
+                Err(e) => return Err(format!("Failed to remove {}: {}", file.display(), e)),
+            }
+        }
+        if removed {
+            update_desktop_caches(&data);
+            log_info!("Removed the desktop entry of msgReader");
+        }
+        Ok(removed)
+    }
+
+    #[cfg(not(any(windows, all(unix, not(target_os = "macos")))))]
+    {
+        Ok(false)
+    }
+}
+
+/// Open the OS page where the default apps are chosen. Returns false if the OS has no
+/// such page (macOS and Linux set them per file type in the file manager).
+pub fn open_settings() -> Result<bool, String> {
+    #[cfg(windows)]
+    {
+        use std::os::windows::process::CommandExt;
+        const CREATE_NO_WINDOW: u32 = 0x0800_0000;
+
+        // Windows 11 opens the page of msgReader itself when given the name it is
+        // registered under (by the installer, per user or per machine)
+        let registered = |root: &str| {
+            reg_query(&format!(r"{}\Software\RegisteredApplications", root), Some("msgReader"))
+                .is_some()
+        };
+        let page = if registered("HKCU") {
+            "ms-settings:defaultapps?registeredAppUser=msgReader"
+        } else if registered("HKLM") {
+            "ms-settings:defaultapps?registeredAppMachine=msgReader"
+        } else {
+            "ms-settings:defaultapps"
+        };
+        Command::new("cmd")
+            .args(["/c", "start", "", page])
+            .creation_flags(CREATE_NO_WINDOW)
+            .spawn()
+            .map_err(|e| format!("Failed to open settings: {}", e))?;
+        Ok(true)
+    }
+
+    #[cfg(not(windows))]
+    {
+        Ok(false)
+    }
+}
@@ -0,0 +1,176 @@
+use std::collections::HashMap;
+use std::fs::File;
+use std::io::Write;
+use std::path::{Path, PathBuf};
+use std::sync::atomic::{AtomicBool, AtomicU64, Ordering};
+use std::sync::{Arc, Mutex};
+use std::time::{Duration, Instant};
+use tauri::{AppHandle, Emitter, Manager};
+
+/// Emitted while a file is written, with a `SaveProgress`
+pub const PROGRESS_EVENT: &str = "save-progress";
+/// Emitted once a save has finished, failed or was cancelled, with a `SaveFinished`
+pub const FINISHED_EVENT: &str = "save-finished";
+
+/// Bytes written at a time; cancellation is checked between chunks
+const CHUNK_SIZE: usize = 1024 * 1024;
+/// Least time between two progress events, so a fast disk does not flood the frontend
+const PROGRESS_INTERVAL: Duration = Duration::from_millis(100);
+
+/// A started save
+#[derive(serde::Serialize)]
+#[serde(rename_all = "camelCase")]
+pub struct SaveJob {
+    pub save_id: u64,
+    pub path: String,
+    /// Size of the file in bytes
+    pub total: u64,
+}
+
+#[derive(serde::Serialize, Clone)]
+#[serde(rename_all = "camelCase")]
+pub struct SaveProgress {
+    pub save_id: u64,
+    pub written: u64,
+    pub total: u64,
+}
+
+#[derive(serde::Serialize, Clone)]
+#[serde(rename_all = "camelCase")]
+pub struct SaveFinished {
+    pub save_id: u64,
+    pub path: String,
+    /// `saved`, `cancelled` or `failed`
+    pub status: &'static str,
+    pub error: Option<String>,
+}
+
+/// Files being written in the background, with the flag that cancels each
+pub struct FileSaves {
+    next_id: AtomicU64,
+    running: Mutex<HashMap<u64, Arc<AtomicBool>>>,
+}
+
+/// Where a file is written until it is complete: `<name>.part` next to it
+fn partial_path(target: &Path) -> PathBuf {
+    let mut name = target.file_name().unwrap_or_default().to_os_string();
+    name.push(".part");
+    target.with_file_name(name)
+}
+
+/// Write `data` to `path` chunk by chunk, calling `progress` with the bytes written at
+/// most every PROGRESS_INTERVAL. Returns false if `cancelled` was set before the end.
+fn write_chunks(
+    path: &Path,
+    data: &[u8],
+    cancelled: &AtomicBool,
+    mut progress: impl FnMut(u64),
+) -> std::io::Result<bool> {
+    let mut file = File::create(path)?;
+    let mut written = 0;
+    let mut reported = Instant::now();
+    for chunk in data.chunks(CHUNK_SIZE) {
+        if cancelled.load(Ordering::Relaxed) {
+            return Ok(false);
+        }
+        file.write_all(chunk)?;
+        written += chunk.len() as u64;
+        if reported.elapsed() >= PROGRESS_INTERVAL {
+            progress(written);
+            reported = Instant::now();
+        }
+    }
+    file.sync_all()?;
+    progress(written);
+    Ok(true)
+}
+
+fn emit<T: serde::Serialize + Clone>(app: &AppHandle, event: &str, payload: T) {
+    if let Err(e) = app.emit(event, payload) {
+        log_warn!("Failed to emit {} event: {}", event, e);
+    }
+}
+
+impl FileSaves {
+    pub fn new() -> Self {
+        FileSaves {
+            next_id: AtomicU64::new(1),
+            running: Mutex::new(HashMap::new()),
+        }
+    }
+
+    /// Write `data` to `target` on a background thread. The file is written as
+    /// `<target>.part` and renamed when complete, so `target` is only replaced by a
+    /// whole file; a cancelled or failed save removes the partial file. Progress and
+    /// the result are reported with events.
+    pub fn start(&self, app: &AppHandle, target: PathBuf, data: Vec<u8>) -> SaveJob {
+        let save_id = self.next_id.fetch_add(1, Ordering::Relaxed);
+        let cancelled = Arc::new(AtomicBool::new(false));
+        self.running.lock().unwrap().insert(save_id, cancelled.clone());
+
+        let job = SaveJob {
+            save_id,
+            path: target.to_string_lossy().to_string(),
+            total: data.len() as u64,
+        };
+        let app = app.clone();
+        let path = job.path.clone();
+        let total = job.total;
+        std::thread::spawn(move || {
+            let partial = partial_path(&target);
+            let result = write_chunks(&partial, &data, &cancelled, |written| {
+                emit(
+                    &app,
+                    PROGRESS_EVENT,
+                    SaveProgress {
+                        save_id,
+                        written,
+                        total,
+                    },
+                );
+            })
+            .and_then(|complete| {
+                if complete {
+                    std::fs::rename(&partial, &target)?;
+                }
+                Ok(complete)
+            });
+            let (status, error) = match result {
+                Ok(true) => ("saved", None),
+                Ok(false) => ("cancelled", None),
+                Err(e) => ("failed", Some(format!("Failed to write {}: {}", path, e))),
+            };
+            if status != "saved" {
+                let _ = std::fs::remove_file(&partial);
+            }
+            app.state::<FileSaves>().running.lock().unwrap().remove(&save_id);
+            match &error {
+                Some(error) => log_warn!("{}", error),
+                None => log_info!("Save of {} ({} bytes) {}", path, total, status),
+            }
+            emit(
+                &app,
+                FINISHED_EVENT,
+                SaveFinished {
+                    save_id,
+                    path,
+                    status,
+                    error,
+                },
+            );
+        });
+        job
+    }
+
+    /// Stop a running save before its next chunk and remove the partial file. False if
+    /// the save is not running.
+    pub fn cancel(&self, save_id: u64) -> bool {
+        match self.running.lock().unwrap().get(&save_id) {
+            Some(cancelled) => {
+                cancelled.store(true, Ordering::Relaxed);
+                true
+            }
+            None => false,
+        }
+    }
+}
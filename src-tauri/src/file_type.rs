@@ -0,0 +1,181 @@
+use std::io::Read;
+use std::path::Path;
+
+/// Bytes read from the start of a file to tell its type
+const SAMPLE_SIZE: u64 = 64 * 1024;
+
+/// Signature of an OLE compound file; .doc and .xls files have it too
+const CFB_SIGNATURE: [u8; 8] = [0xD0, 0xCF, 0x11, 0xE0, 0xA1, 0xB1, 0x1A, 0xE1];
+/// Stream every .msg file has at its root
+const MSG_PROPERTIES_STREAM: &str = "/__properties_version1.0";
+const PST_SIGNATURE: &[u8] = b"!BDN";
+const ZIP_SIGNATURES: [&[u8]; 2] = [b"PK\x03\x04", b"PK\x05\x06"];
+const GZIP_SIGNATURE: [u8; 2] = [0x1F, 0x8B];
+/// 0x223E9F78 little-endian, the start of a winmail.dat file
+const TNEF_SIGNATURE: [u8; 4] = [0x78, 0x9F, 0x3E, 0x22];
+const UTF8_BOM: &[u8] = b"\xEF\xBB\xBF";
+
+/// Header fields of which a message has at least one in its header
+const MESSAGE_FIELDS: [&str; 16] = [
+    "from",
+    "to",
+    "cc",
+    "subject",
+    "date",
+    "message-id",
+    "received",
+    "return-path",
+    "mime-version",
+    "content-type",
+    "delivered-to",
+    "reply-to",
+    "sender",
+    "in-reply-to",
+    "references",
+    "x-mailer",
+];
+
+/// Types of files the app opens, named like their usual extension. `tnef` is a
+/// winmail.dat file saved on its own.
+pub const TYPES: [&str; 7] = ["msg", "eml", "mbox", "tnef", "pst", "zip", "gz"];
+/// The content is of none of TYPES
+pub const UNKNOWN: &str = "unknown";
+
+/// The type of a file by its content, compared with its extension
+#[derive(serde::Serialize)]
+#[serde(rename_all = "camelCase")]
+pub struct FileType {
+    /// One of TYPES, or UNKNOWN
+    #[serde(rename = "type")]
+    pub kind: &'static str,
+    /// The type the extension stands for, None for other extensions
+    pub extension_type: Option<&'static str>,
+    /// The content is one of TYPES but not the one the extension stands for, e.g. a
+    /// .tmp or .bin file, or an .eml file that is really an .msg file
+    pub misnamed: bool,
+}
+
+/// The type an extension stands for; .ost files are read as .pst files
+fn extension_type(path: &Path) -> Option<&'static str> {
+    let extension = path.extension()?.to_str()?.to_lowercase();
+    match extension.as_str() {
+        "ost" => Some("pst"),
+        "mbx" => Some("mbox"),
+        "dat" if path_is_winmail(path) => Some("tnef"),
+        _ => TYPES.iter().find(|kind| **kind == extension).copied(),
+    }
+}
+
+fn path_is_winmail(path: &Path) -> bool {
+    path.file_stem()
+        .and_then(|stem| stem.to_str())
+        .is_some_and(|stem| stem.eq_ignore_ascii_case("winmail"))
+}
+
+/// Whether the text starts with a message header: fields up to an empty line or the end
+/// of the sample, each `Name: value` or a folded continuation, at least two of them and
+/// one a common message field. Only whole lines are checked.
+fn is_header(data: &[u8]) -> bool {
+    let text = String::from_utf8_lossy(data);
+    let mut lines: Vec<&str> = text.split('\n').collect();
+    if !text.ends_with('\n') && lines.len() > 1 {
+        lines.pop();
+    }
+
+    let mut fields = 0;
+    let mut known = 0;
+    for line in lines {
+        let line = line.trim_end_matches('\r');
+        if line.is_empty() {
+            break;
+        }
+        if line.starts_with(|c| c == ' ' || c == '\t') {
+            if fields == 0 {
+                return false;
+            }
+            continue;
+        }
+        let Some((name, _)) = line.split_once(':') else {
+            return false;
+        };
+        if name.is_empty() || !name.bytes().all(|b| b.is_ascii_graphic()) {
+            return false;
+        }
+        fields += 1;
+        if MESSAGE_FIELDS.contains(&name.to_ascii_lowercase().as_str()) {
+            known += 1;
+        }
+    }
+    fields >= 2 && known >= 1
+}
+
+/// The type of a file by the first bytes of its content. An OLE compound file is
+/// `msg` here; `detect` also checks that it has the streams of a message.
+pub fn detect_bytes(data: &[u8]) -> &'static str {
+    if data.starts_with(&CFB_SIGNATURE) {
+        return "msg";
+    }
+    if data.starts_with(PST_SIGNATURE) {
+        return "pst";
+    }
+    if ZIP_SIGNATURES.iter().any(|signature| data.starts_with(signature)) {
+        return "zip";
+    }
+    if data.starts_with(&GZIP_SIGNATURE) {
+        return "gz";
+    }
+    if data.starts_with(&TNEF_SIGNATURE) {
+        return "tnef";
+    }
+
+    let text = data.strip_prefix(UTF8_BOM).unwrap_or(data);
+    if let Some(rest) = text.strip_prefix(b"From ") {
+        // An mbox file starts with a `From sender date` line and the first message
+        let after_line = rest.iter().position(|&b| b == b'\n').map(|end| &rest[end + 1..]);
+        if after_line.is_some_and(is_header) {
+            return "mbox";
+        }
+    }
+    if is_header(text) {
+        return "eml";
+    }
+    UNKNOWN
+}
+
+/// Detect the type of a file by its content rather than its extension
+pub fn detect(path: &Path) -> Result<FileType, String> {
+    let file = std::fs::File::open(path)
+        .map_err(|e| format!("Failed to open {}: {}", path.display(), e))?;
+    let mut sample = Vec::new();
+    file.take(SAMPLE_SIZE)
+        .read_to_end(&mut sample)
+        .map_err(|e| format!("Failed to read {}: {}", path.display(), e))?;
+
+    let mut kind = detect_bytes(&sample);
+    if kind == "msg" {
+        let is_message = cfb::open(path).is_ok_and(|file| file.is_stream(MSG_PROPERTIES_STREAM));
+        if !is_message {
+            kind = UNKNOWN;
+        }
+    }
+    let extension_type = extension_type(path);
+    Ok(FileType {
+        kind,
+        extension_type,
+        misnamed: kind != UNKNOWN && extension_type != Some(kind),
+    })
+}
+
+/// The type the app opens a file as: the type of its content, or the one of its
+/// extension when the content is not recognized, as for an unusual .eml header. None if
+/// the file cannot be read or neither is one of TYPES.
+pub fn open_type(path: &Path) -> Option<&'static str> {
+    let detected = detect(path).ok()?;
+    if detected.misnamed {
+        log_info!("Opening {} as {} by its content", path.display(), detected.kind);
+    }
+    match detected.kind {
+        UNKNOWN => detected.extension_type,
+        kind => Some(kind),
+    }
+}
@@ -0,0 +1,216 @@
+use crate::message::MessageSummary;
+use crate::{eml, file_type, msg};
+use std::collections::{HashMap, HashSet};
+use std::path::{Path, PathBuf};
+use std::sync::Mutex;
+use std::time::UNIX_EPOCH;
+
+/// Most entries returned by one `page` call
+pub const MAX_PAGE_SIZE: usize = 200;
+
+/// A .msg/.eml file of an opened folder. The message fields come from a quick parse of
+/// the headers; they are empty if the file could not be read.
+#[derive(serde::Serialize)]
+#[serde(rename_all = "camelCase")]
+pub struct FolderEntry {
+    pub path: String,
+    pub name: String,
+    pub size: u64,
+    /// Unix time in milliseconds
+    pub modified: Option<i64>,
+    #[serde(flatten)]
+    pub summary: MessageSummary,
+    /// Why the quick parse failed, None if it worked
+    pub error: Option<String>,
+}
+
+#[derive(serde::Serialize)]
+#[serde(rename_all = "camelCase")]
+pub struct FolderListing {
+    pub folder: String,
+    pub total: usize,
+}
+
+#[derive(serde::Serialize)]
+#[serde(rename_all = "camelCase")]
+pub struct FolderPage {
+    pub offset: usize,
+    pub total: usize,
+    pub entries: Vec<FolderEntry>,
+}
+
+/// Files and folders dropped onto the window, expanded to the files to open
+#[derive(serde::Serialize, Default)]
+#[serde(rename_all = "camelCase")]
+pub struct DroppedFiles {
+    /// Files in the order they were dropped, the files of a folder sorted by path
+    pub files: Vec<String>,
+    /// Number of dropped folders that were searched
+    pub folders: usize,
+    /// Dropped files of other types and files or folders that could not be read
+    pub skipped: usize,
+    /// Files dropped more than once, e.g. on their own and with their folder
+    pub duplicates: usize,
+}
+
+/// Folders opened as a whole. Only the file list is kept; files are summarized one
+/// page at a time and only parsed in full when the user opens them.
+pub struct OpenFolders {
+    listings: Mutex<HashMap<PathBuf, Vec<PathBuf>>>,
+}
+
+fn email_extension(path: &Path) -> Option<String> {
+    path.extension()
+        .and_then(|e| e.to_str())
+        .map(|e| e.to_lowercase())
+        .filter(|e| e == "msg" || e == "eml")
+}
+
+/// All .msg/.eml files of a folder, sorted by path. Symlinked folders are not followed,
+/// so links pointing back up the tree cannot loop; unreadable subfolders are skipped.
+pub(crate) fn enumerate(folder: &Path, recursive: bool) -> Result<Vec<PathBuf>, String> {
+    let mut files = Vec::new();
+    let mut pending = vec![folder.to_path_buf()];
+
+    while let Some(dir) = pending.pop() {
+        let entries = match std::fs::read_dir(&dir) {
+            Ok(entries) => entries,
+            Err(e) if dir == folder => {
+                return Err(format!("Failed to read {}: {}", folder.display(), e));
+            }
+            Err(_) => continue,
+        };
+        for entry in entries.flatten() {
+            let Ok(file_type) = entry.file_type() else {
+                continue;
+            };
+            let path = entry.path();
+            if file_type.is_dir() {
+                if recursive {
+                    pending.push(path);
+                }
+            } else if email_extension(&path).is_some() && path.is_file() {
+                files.push(path);
+            }
+        }
+    }
+
+    files.sort();
+    Ok(files)
+}
+
+/// Expand dropped paths: folders become their .msg/.eml files, subfolders included;
+/// dropped files are kept if the app opens them by their content or extension. A file
+/// reached twice is opened once.
+pub fn expand_dropped(paths: &[PathBuf]) -> DroppedFiles {
+    let mut dropped = DroppedFiles::default();
+    let mut seen = HashSet::new();
+    let mut add = |dropped: &mut DroppedFiles, path: PathBuf| {
+        let key = std::fs::canonicalize(&path).unwrap_or_else(|_| path.clone());
+        if seen.insert(key) {
+            dropped.files.push(path.to_string_lossy().to_string());
+        } else {
+            dropped.duplicates += 1;
+        }
+    };
+
+    for path in paths {
+        if path.is_dir() {
+            match enumerate(path, true) {
+                Ok(files) => {
+                    dropped.folders += 1;
+                    for file in files {
+                        add(&mut dropped, file);
+                    }
+                }
+                Err(e) => {
+                    log_warn!("Skipping dropped folder: {}", e);
+                    dropped.skipped += 1;
+                }
+            }
+            continue;
+        }
+
+        if file_type::open_type(path).is_some() {
+            add(&mut dropped, path.clone());
+        } else {
+            dropped.skipped += 1;
+        }
+    }
+    dropped
+}
+
+fn entry(path: &Path) -> FolderEntry {
+    let metadata = std::fs::metadata(path).ok();
+    let summary = match email_extension(path).as_deref() {
+        Some("msg") => msg::summary(path),
+        _ => eml::summary(path),
+    };
+    let (summary, error) = match summary {
+        Ok(summary) => (summary, None),
+        Err(e) => (MessageSummary::default(), Some(e)),
+    };
+
+    FolderEntry {
+        path: path.to_string_lossy().to_string(),
+        name: path
+            .file_name()
+            .map(|name| name.to_string_lossy().to_string())
+            .unwrap_or_default(),
+        size: metadata.as_ref().map(|m| m.len()).unwrap_or(0),
+        modified: metadata
+            .and_then(|m| m.modified().ok())
+            .and_then(|time| time.duration_since(UNIX_EPOCH).ok())
+            .map(|duration| duration.as_millis() as i64),
+        summary,
+        error,
+    }
+}
+
+impl OpenFolders {
+    pub fn new() -> Self {
+        OpenFolders {
+            listings: Mutex::new(HashMap::new()),
+        }
+    }
+
+    /// List the files of a folder. Opening a folder again lists it anew.
+    pub fn open(&self, folder: &Path, recursive: bool) -> Result<FolderListing, String> {
+        if !folder.is_dir() {
+            return Err(format!("Not a folder: {}", folder.display()));
+        }
+
+        let files = enumerate(folder, recursive)?;
+        let total = files.len();
+        self.listings
+            .lock()
+            .unwrap()
+            .insert(folder.to_path_buf(), files);
+        Ok(FolderListing {
+            folder: folder.to_string_lossy().to_string(),
+            total,
+        })
+    }
+
+    /// Summaries of `limit` files (at most MAX_PAGE_SIZE) starting at `offset`
+    pub fn page(&self, folder: &Path, offset: usize, limit: usize) -> Result<FolderPage, String> {
+        let (paths, total) = {
+            let listings = self.listings.lock().unwrap();
+            let files = listings
+                .get(folder)
+                .ok_or_else(|| format!("Folder is not open: {}", folder.display()))?;
+            let end = offset.saturating_add(limit.min(MAX_PAGE_SIZE)).min(files.len());
+            (files.get(offset..end).unwrap_or_default().to_vec(), files.len())
+        };
+
+        Ok(FolderPage {
+            offset,
+            total,
+            entries: paths.iter().map(|path| entry(path)).collect(),
+        })
+    }
+
+    pub fn close(&self, folder: &Path) {
+        self.listings.lock().unwrap().remove(folder);
+    }
+}
@@ -0,0 +1,78 @@
+use crate::msg;
+
+/// First bytes of an OLE compound file (.msg)
+pub const CFB_SIGNATURE: [u8; 4] = [0xD0, 0xCF, 0x11, 0xE0];
+
+/// A header field with its folding, without the final CRLF, and its lowercase name
+pub struct Field {
+    pub name: String,
+    pub raw: Vec<u8>,
+}
+
+impl Field {
+    /// The unfolded value after the colon
+    pub fn value(&self) -> String {
+        let colon = self.raw.iter().position(|&b| b == b':').unwrap_or(0);
+        String::from_utf8_lossy(&self.raw[colon + 1..])
+            .replace("\r\n", "")
+            .trim()
+            .to_string()
+    }
+}
+
+/// The message with CRLF line endings, as DKIM signs it
+fn crlf(data: &[u8]) -> Vec<u8> {
+    let mut out = Vec::with_capacity(data.len() + data.len() / 32);
+    for (i, &b) in data.iter().enumerate() {
+        if b == b'\n' && (i == 0 || data[i - 1] != b'\r') {
+            out.push(b'\r');
+        }
+        out.push(b);
+    }
+    out
+}
+
+/// Header fields and body of a message with CRLF line endings (see `original_message`)
+pub fn split_message(data: &[u8]) -> (Vec<Field>, &[u8]) {
+    let (header, body) = match data.windows(4).position(|w| w == b"\r\n\r\n") {
+        Some(end) => (&data[..end], &data[end + 4..]),
+        None => (data, &data[data.len()..]),
+    };
+
+    let mut fields: Vec<Field> = Vec::new();
+    for line in header.split(|&b| b == b'\n') {
+        let line = line.strip_suffix(b"\r").unwrap_or(line);
+        if line.is_empty() {
+            continue;
+        }
+        if matches!(line[0], b' ' | b'\t') {
+            if let Some(field) = fields.last_mut() {
+                field.raw.extend_from_slice(b"\r\n");
+                field.raw.extend_from_slice(line);
+            }
+        } else if let Some(colon) = line.iter().position(|&b| b == b':') {
+            fields.push(Field {
+                name: String::from_utf8_lossy(&line[..colon])
+                    .trim()
+                    .to_lowercase(),
+                raw: line.to_vec(),
+            });
+        }
+    }
+    (fields, body)
+}
+
+/// A message file with CRLF line endings: .eml files as they are, .msg files as their
+/// transport headers. The flag is false for .msg files, which do not keep the original
+/// body.
+pub fn original_message(data: &[u8]) -> Result<(Vec<u8>, bool), String> {
+    if data.starts_with(&CFB_SIGNATURE) {
+        let headers = msg::transport_headers(data)?;
+        if headers.trim().is_empty() {
+            return Err("The message has no transport headers".to_string());
+        }
+        Ok((crlf(headers.as_bytes()), false))
+    } else {
+        Ok((crlf(data), true))
+    }
+}
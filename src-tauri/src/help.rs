@@ -0,0 +1,44 @@
+use crate::locale;
+use tauri::{AppHandle, Manager, WebviewUrl, WebviewWindowBuilder};
+
+/// Label of the help window
+const WINDOW_LABEL: &str = "help";
+
+/// Help pages bundled from `res/help/<topic>.html`
+pub const TOPICS: &[&str] = &[
+    "getting-started",
+    "reading-messages",
+    "search",
+    "attachments",
+    "export",
+    "settings",
+    "keyboard-shortcuts",
+];
+
+const DEFAULT_TOPIC: &str = "getting-started";
+
+/// Open the help window on a topic, or navigate the open help window to it, titled in
+/// `language` (a language or locale). Pages are served from the app assets, so no network
+/// connection is needed.
+pub fn show(app: &AppHandle, topic: &str, language: &str) -> Result<(), String> {
+    let topic = if TOPICS.contains(&topic) { topic } else { DEFAULT_TOPIC };
+    let path = format!("help/{}.html", topic);
+
+    if let Some(window) = app.get_webview_window(WINDOW_LABEL) {
+        let current = window.url().map_err(|e| format!("Invalid help URL: {}", e))?;
+        let url = current
+            .join(&format!("/{}", path))
+            .map_err(|e| format!("Invalid help URL: {}", e))?;
+        window.navigate(url).map_err(|e| format!("Failed to open help: {}", e))?;
+        let _ = window.unminimize();
+        return window.set_focus().map_err(|e| format!("Failed to focus help: {}", e));
+    }
+
+    WebviewWindowBuilder::new(app, WINDOW_LABEL, WebviewUrl::App(path.into()))
+        .title(locale::text(language, "help.windowTitle"))
+        .inner_size(760.0, 640.0)
+        .min_inner_size(420.0, 320.0)
+        .build()
+        .map(|_| ())
+        .map_err(|e| format!("Failed to open help: {}", e))
+}
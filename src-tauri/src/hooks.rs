@@ -0,0 +1,101 @@
+use std::collections::HashMap;
+use std::path::Path;
+use std::process::{Command, Stdio};
+
+/// Name of the hook configuration file in the app's config directory
+pub const CONFIG_FILE: &str = "hooks.json";
+
+/// Events the frontend may report
+pub const EVENTS: &[&str] = &["message-opened", "batch-export-finished"];
+
+/// One configured command
+#[derive(serde::Deserialize)]
+#[serde(rename_all = "camelCase")]
+pub struct Hook {
+    pub event: String,
+    pub command: String,
+    #[serde(default)]
+    pub args: Vec<String>,
+    #[serde(default = "default_enabled")]
+    pub enabled: bool,
+}
+
+#[derive(serde::Deserialize, Default)]
+pub struct HookConfig {
+    #[serde(default)]
+    pub hooks: Vec<Hook>,
+}
+
+fn default_enabled() -> bool {
+    true
+}
+
+/// Load the hook configuration. A missing file means no hooks.
+pub fn load(config_path: &Path) -> Result<HookConfig, String> {
+    match std::fs::read_to_string(config_path) {
+        Ok(content) => serde_json::from_str(&content)
+            .map_err(|e| format!("Invalid {}: {}", CONFIG_FILE, e)),
+        Err(e) if e.kind() == std::io::ErrorKind::NotFound => Ok(HookConfig::default()),
+        Err(e) => Err(format!("Failed to read {}: {}", CONFIG_FILE, e)),
+    }
+}
+
+/// Replace `{name}` placeholders with variable values. Unknown placeholders become empty.
+/// Each argument is substituted separately and passed without a shell, so values
+/// containing spaces or quotes cannot inject further arguments.
+pub fn expand(template: &str, variables: &HashMap<String, String>) -> String {
+    let mut result = String::new();
+    let mut rest = template;
+
+    while let Some(start) = rest.find('{') {
+        result.push_str(&rest[..start]);
+        let after = &rest[start + 1..];
+        match after.find('}') {
+            Some(end) if after[..end].chars().all(|c| c.is_ascii_alphanumeric() || c == '_') => {
+                result.push_str(variables.get(&after[..end]).map(String::as_str).unwrap_or(""));
+                rest = &after[end + 1..];
+            }
+            _ => {
+                result.push('{');
+                rest = after;
+            }
+        }
+    }
+
+    result.push_str(rest);
+    result
+}
+
+/// Start all enabled hooks for an event without waiting for them. Each command is
+/// waited for on a thread of its own, so it does not stay a zombie process after it exits.
+/// Variables are also passed as `MSGREADER_<NAME>` environment variables.
+/// Returns the number of started commands.
+pub fn run(config: &HookConfig, event: &str, variables: &HashMap<String, String>) -> usize {
+    let mut started = 0;
+    for hook in &config.hooks {
+        if !hook.enabled || hook.event != event {
+            continue;
+        }
+        let mut command = Command::new(expand(&hook.command, variables));
+        command
+            .args(hook.args.iter().map(|arg| expand(arg, variables)))
+            .env("MSGREADER_EVENT", event)
+            .stdin(Stdio::null())
+            .stdout(Stdio::null())
+            .stderr(Stdio::null());
+        for (name, value) in variables {
+            command.env(format!("MSGREADER_{}", name.to_uppercase()), value);
+        }
+
+        match command.spawn() {
+            Ok(mut child) => {
+                started += 1;
+                std::thread::spawn(move || {
+                    let _ = child.wait();
+                });
+            }
+            Err(e) => log_warn!("Failed to run {} hook {:?}: {}", event, hook.command, e),
+        }
+    }
+    started
+}
@@ -0,0 +1,280 @@
+use crate::eml;
+use crate::keychain;
+use crate::message::MessageSummary;
+use crate::proxy::{self, ProxyConfig};
+use base64::{engine::general_purpose::STANDARD_NO_PAD, Engine as _};
+use imap::types::{Fetch, NameAttribute};
+use std::collections::HashMap;
+use std::net::TcpStream;
+use std::sync::{Arc, Mutex};
+use std::time::Duration;
+
+/// Most entries returned by one `page` call
+pub const MAX_PAGE_SIZE: usize = 200;
+
+/// Time to connect and to wait for each answer of the server
+const TIMEOUT: Duration = Duration::from_secs(30);
+
+type TlsStream = rustls::StreamOwned<rustls::ClientConnection, TcpStream>;
+type Session = imap::Session<TlsStream>;
+
+/// An IMAP mailbox, reached over TLS (IMAPS, usually port 993). Only the server and user
+/// name are kept by the frontend; the password stays in the backend (ImapPasswords).
+#[derive(serde::Deserialize, Clone)]
+#[serde(rename_all = "camelCase")]
+pub struct ImapAccount {
+    pub host: String,
+    pub port: u16,
+    pub username: String,
+}
+
+impl ImapAccount {
+    /// Name of the password in the OS credential store
+    fn keychain_account(&self) -> String {
+        format!("imap:{}@{}:{}", self.username, self.host, self.port)
+    }
+}
+
+#[derive(serde::Serialize)]
+#[serde(rename_all = "camelCase")]
+pub struct ImapFolder {
+    /// Name as the server knows it, passed back to open the folder
+    pub name: String,
+    /// Name decoded from modified UTF-7, for display
+    pub display_name: String,
+    /// False for folders that only contain other folders
+    pub selectable: bool,
+}
+
+/// A message of a folder. The message fields come from its headers; they are empty if
+/// the headers could not be parsed.
+#[derive(serde::Serialize)]
+#[serde(rename_all = "camelCase")]
+pub struct ImapEntry {
+    /// Unique id of the message in its folder, used to read it
+    pub uid: u32,
+    pub size: u32,
+    #[serde(flatten)]
+    pub summary: MessageSummary,
+    /// Why the headers could not be parsed, None if they could
+    pub error: Option<String>,
+}
+
+#[derive(serde::Serialize)]
+#[serde(rename_all = "camelCase")]
+pub struct ImapPage {
+    pub offset: usize,
+    pub total: usize,
+    pub entries: Vec<ImapEntry>,
+}
+
+/// Passwords of the IMAP accounts used since the app started. Passwords the user chose to
+/// remember are kept in the OS credential store instead and read from there.
+pub struct ImapPasswords {
+    passwords: Mutex<HashMap<String, String>>,
+}
+
+impl ImapPasswords {
+    pub fn new() -> Self {
+        ImapPasswords {
+            passwords: Mutex::new(HashMap::new()),
+        }
+    }
+
+    /// The password of this session, or the remembered one
+    pub fn get(&self, account: &ImapAccount) -> Option<String> {
+        let key = account.keychain_account();
+        let password = self.passwords.lock().unwrap().get(&key).cloned();
+        password.or_else(|| keychain::get(&key))
+    }
+
+    /// Keep a password that worked, in the credential store if `remember` is set
+    pub fn set(&self, account: &ImapAccount, password: &str, remember: bool) -> Result<(), String> {
+        let key = account.keychain_account();
+        if remember {
+            keychain::set(&key, password)?;
+        }
+        self.passwords.lock().unwrap().insert(key, password.to_string());
+        Ok(())
+    }
+
+    /// Forget the password of an account, also in the credential store
+    pub fn forget(&self, account: &ImapAccount) -> Result<(), String> {
+        let key = account.keychain_account();
+        self.passwords.lock().unwrap().remove(&key);
+        keychain::delete(&key)
+    }
+}
+
+/// Log in over TLS, through the proxy of the settings if there is one for IMAP; the
+/// server certificate is checked against the OS trust store
+fn connect(account: &ImapAccount, password: &str, proxy: &ProxyConfig) -> Result<Session, String> {
+    let connect_error = |e: String| format!("Failed to connect to {}: {}", account.host, e);
+
+    let mut roots = rustls::RootCertStore::empty();
+    roots.add_parsable_certificates(rustls_native_certs::load_native_certs().certs);
+    let config = rustls::ClientConfig::builder_with_provider(Arc::new(
+        rustls::crypto::ring::default_provider(),
+    ))
+    .with_safe_default_protocol_versions()
+    .map_err(|e| connect_error(e.to_string()))?
+    .with_root_certificates(roots)
+    .with_no_client_auth();
+    let server_name = rustls::pki_types::ServerName::try_from(account.host.clone())
+        .map_err(|_| format!("Invalid server name: {}", account.host))?;
+    let connection = rustls::ClientConnection::new(Arc::new(config), server_name)
+        .map_err(|e| connect_error(e.to_string()))?;
+
+    let tcp = proxy::connect(proxy, "imaps", &account.host, account.port, TIMEOUT)
+        .map_err(connect_error)?;
+
+    let mut client = imap::Client::new(rustls::StreamOwned::new(connection, tcp));
+    client
+        .read_greeting()
+        .map_err(|e| connect_error(e.to_string()))?;
+    client
+        .login(&account.username, password)
+        .map_err(|(e, _)| format!("Login to {} failed: {}", account.host, e))
+}
+
+/// Close the session; the answer of the server does not matter any more
+fn logout(mut session: Session) {
+    if let Err(e) = session.logout() {
+        log_warn!("IMAP logout failed: {}", e);
+    }
+}
+
+/// Decode a folder name from modified UTF-7 (RFC 3501): `&...-` is base64 of UTF-16
+/// with `,` for `/`, and `&-` is `&`
+fn decode_folder_name(name: &str) -> String {
+    let mut result = String::with_capacity(name.len());
+    let mut rest = name;
+    while let Some(start) = rest.find('&') {
+        result.push_str(&rest[..start]);
+        let encoded = &rest[start + 1..];
+        let end = encoded.find('-').unwrap_or(encoded.len());
+        if end == 0 {
+            result.push('&');
+        } else {
+            let Ok(bytes) = STANDARD_NO_PAD.decode(encoded[..end].replace(',', "/")) else {
+                return name.to_string();
+            };
+            let units: Vec<u16> = bytes
+                .chunks_exact(2)
+                .map(|pair| u16::from_be_bytes([pair[0], pair[1]]))
+                .collect();
+            result.push_str(&String::from_utf16_lossy(&units));
+        }
+        rest = encoded.get(end + 1..).unwrap_or("");
+    }
+    result.push_str(rest);
+    result
+}
+
+/// All folders of the mailbox, the inbox first and the others by name
+pub fn folders(
+    account: &ImapAccount,
+    password: &str,
+    proxy: &ProxyConfig,
+) -> Result<Vec<ImapFolder>, String> {
+    let mut session = connect(account, password, proxy)?;
+    let names = session
+        .list(Some(""), Some("*"))
+        .map_err(|e| format!("Failed to list folders: {}", e))?;
+    let mut folders: Vec<ImapFolder> = names
+        .iter()
+        .map(|name| ImapFolder {
+            name: name.name().to_string(),
+            display_name: decode_folder_name(name.name()),
+            selectable: !name
+                .attributes()
+                .iter()
+                .any(|attribute| matches!(attribute, NameAttribute::NoSelect)),
+        })
+        .collect();
+    logout(session);
+
+    folders.sort_by_key(|folder| {
+        (!folder.name.eq_ignore_ascii_case("INBOX"), folder.display_name.to_lowercase())
+    });
+    Ok(folders)
+}
+
+/// Open a folder read-only (EXAMINE), so nothing is marked as read or changed
+fn examine(session: &mut Session, folder: &str) -> Result<usize, String> {
+    session
+        .examine(folder)
+        .map(|mailbox| mailbox.exists as usize)
+        .map_err(|e| format!("Failed to open folder {}: {}", folder, e))
+}
+
+fn entry(fetch: &Fetch) -> Option<ImapEntry> {
+    let (summary, error) = match fetch.header().map(eml::summary_from_bytes) {
+        Some(Ok(summary)) => (summary, None),
+        Some(Err(e)) => (MessageSummary::default(), Some(e)),
+        None => (MessageSummary::default(), Some("No headers".to_string())),
+    };
+    Some(ImapEntry {
+        uid: fetch.uid?,
+        size: fetch.size.unwrap_or(0),
+        summary,
+        error,
+    })
+}
+
+/// Subject, sender, date and size of `limit` messages of a folder, newest first, skipping
+/// the `offset` newest ones. Only the headers are downloaded.
+pub fn page(
+    account: &ImapAccount,
+    password: &str,
+    proxy: &ProxyConfig,
+    folder: &str,
+    offset: usize,
+    limit: usize,
+) -> Result<ImapPage, String> {
+    let mut session = connect(account, password, proxy)?;
+    let total = examine(&mut session, folder)?;
+    let limit = limit.min(MAX_PAGE_SIZE);
+
+    let mut entries = Vec::new();
+    if offset < total && limit > 0 {
+        // Sequence numbers count from the oldest message, starting at 1
+        let last = total - offset;
+        let first = last.saturating_sub(limit) + 1;
+        let fetches = session
+            .fetch(format!("{}:{}", first, last), "(UID RFC822.SIZE RFC822.HEADER)")
+            .map_err(|e| format!("Failed to list folder {}: {}", folder, e))?;
+        let mut fetches: Vec<&Fetch> = fetches.iter().collect();
+        fetches.sort_by_key(|fetch| std::cmp::Reverse(fetch.message));
+        entries = fetches.into_iter().filter_map(entry).collect();
+    }
+    logout(session);
+
+    Ok(ImapPage {
+        offset,
+        total,
+        entries,
+    })
+}
+
+/// A message as .eml file bytes. BODY.PEEK leaves it unread on the server.
+pub fn message(
+    account: &ImapAccount,
+    password: &str,
+    proxy: &ProxyConfig,
+    folder: &str,
+    uid: u32,
+) -> Result<Vec<u8>, String> {
+    let mut session = connect(account, password, proxy)?;
+    examine(&mut session, folder)?;
+    let fetches = session
+        .uid_fetch(uid.to_string(), "BODY.PEEK[]")
+        .map_err(|e| format!("Failed to read message: {}", e))?;
+    let bytes = fetches
+        .iter()
+        .find_map(Fetch::body)
+        .map(<[u8]>::to_vec)
+        .ok_or_else(|| format!("Message {} not found in {}", uid, folder));
+    logout(session);
+    bytes
+}
@@ -0,0 +1,259 @@
+use crate::recent_files::RecentFile;
+use std::path::{Path, PathBuf};
+use tauri::AppHandle;
+
+/// Most recent files in the Jump List and the Dock menu
+const RECENT_LIMIT: usize = 10;
+
+/// The recent files of the taskbar Jump List on Windows and the Dock menu on macOS.
+/// Jump List entries start the app with the file, which the running instance receives
+/// like a double-clicked file; `on_open` is called for Dock menu entries. Elsewhere this
+/// does nothing. Returns the files the user removed from the Jump List, which Windows
+/// reports once and expects to be left out from now on.
+pub fn update(
+    app: &AppHandle,
+    files: &[RecentFile],
+    title: &str,
+    profile: Option<&str>,
+    on_open: fn(&AppHandle, PathBuf),
+) -> Result<Vec<String>, String> {
+    let files: Vec<RecentFile> = files.iter().take(RECENT_LIMIT).cloned().collect();
+
+    #[cfg(windows)]
+    {
+        let _ = (app, on_open);
+        let (title, profile) = (title.to_string(), profile.map(str::to_string));
+        // COM runs on a thread of its own, apart from the apartment of the UI thread
+        std::thread::spawn(move || windows_list::update(&files, &title, profile.as_deref()))
+            .join()
+            .map_err(|_| "Jump List thread panicked".to_string())?
+    }
+
+    #[cfg(target_os = "macos")]
+    {
+        let _ = profile;
+        dock_menu::update(app, &files, title, on_open).map(|_| Vec::new())
+    }
+
+    #[cfg(not(any(windows, target_os = "macos")))]
+    {
+        let _ = (app, files, title, profile, on_open);
+        Ok(Vec::new())
+    }
+}
+
+fn file_name(path: &str) -> String {
+    Path::new(path)
+        .file_name()
+        .map_or(path.to_string(), |name| name.to_string_lossy().to_string())
+}
+
+#[cfg(windows)]
+mod windows_list {
+    use super::file_name;
+    use crate::recent_files::RecentFile;
+    use std::path::Path;
+    use windows::core::{Interface, HSTRING, PROPVARIANT};
+    use windows::Win32::Storage::EnhancedStorage::PKEY_Title;
+    use windows::Win32::System::Com::{
+        CoCreateInstance, CoInitializeEx, CoUninitialize, CLSCTX_INPROC_SERVER,
+        COINIT_APARTMENTTHREADED,
+    };
+    use windows::Win32::UI::Shell::Common::{IObjectArray, IObjectCollection};
+    use windows::Win32::UI::Shell::PropertiesSystem::IPropertyStore;
+    use windows::Win32::UI::Shell::{
+        DestinationList, EnumerableObjectCollection, ICustomDestinationList, IShellLinkW,
+        ShellLink,
+    };
+
+    /// Longest command line Windows keeps for a shell link
+    const MAX_ARGUMENTS: usize = 1024;
+
+    pub fn update(
+        files: &[RecentFile],
+        title: &str,
+        profile: Option<&str>,
+    ) -> Result<Vec<String>, String> {
+        let exe = std::env::current_exe()
+            .map_err(|e| format!("Failed to find the app executable: {}", e))?;
+        unsafe {
+            CoInitializeEx(None, COINIT_APARTMENTTHREADED)
+                .ok()
+                .map_err(|e| format!("Failed to initialize COM: {}", e))?;
+            let result = build(&exe, files, title, profile);
+            CoUninitialize();
+            result.map_err(|e| format!("Failed to update the Jump List: {}", e))
+        }
+    }
+
+    /// The file a Jump List entry opens, from its command line
+    fn link_path(link: &IShellLinkW) -> windows::core::Result<String> {
+        let mut buffer = [0u16; MAX_ARGUMENTS];
+        unsafe { link.GetArguments(&mut buffer)? };
+        let length = buffer.iter().position(|&c| c == 0).unwrap_or(buffer.len());
+        let arguments = String::from_utf16_lossy(&buffer[..length]);
+        // The file is the last argument, after the profile
+        Ok(arguments
+            .rsplit('"')
+            .nth(1)
+            .unwrap_or_default()
+            .to_string())
+    }
+
+    unsafe fn build(
+        exe: &Path,
+        files: &[RecentFile],
+        title: &str,
+        profile: Option<&str>,
+    ) -> windows::core::Result<Vec<String>> {
+        let list: ICustomDestinationList =
+            CoCreateInstance(&DestinationList, None, CLSCTX_INPROC_SERVER)?;
+        let mut slots = 0u32;
+        let removed_links: IObjectArray = list.BeginList(&mut slots)?;
+        let mut removed = Vec::new();
+        for index in 0..removed_links.GetCount()? {
+            removed.push(link_path(&removed_links.GetAt::<IShellLinkW>(index)?)?);
+        }
+
+        let collection: IObjectCollection =
+            CoCreateInstance(&EnumerableObjectCollection, None, CLSCTX_INPROC_SERVER)?;
+        let profile_argument = profile
+            .map(|name| format!("--profile \"{}\" ", name))
+            .unwrap_or_default();
+        let entries = files
+            .iter()
+            .filter(|file| !removed.contains(&file.path))
+            .take(slots as usize);
+        let mut count = 0;
+        for file in entries {
+            let link: IShellLinkW = CoCreateInstance(&ShellLink, None, CLSCTX_INPROC_SERVER)?;
+            link.SetPath(&HSTRING::from(exe.as_os_str()))?;
+            let arguments = format!("{}\"{}\"", profile_argument, file.path);
+            link.SetArguments(&HSTRING::from(arguments))?;
+            link.SetDescription(&HSTRING::from(file.path.as_str()))?;
+            // Entries show the title instead of the name of the app
+            let store: IPropertyStore = link.cast()?;
+            let name = file_name(&file.path);
+            store.SetValue(&PKEY_Title, &PROPVARIANT::from(name.as_str()))?;
+            store.Commit()?;
+            collection.AddObject(&link)?;
+            count += 1;
+        }
+        // An empty category is rejected; committing without one clears the list
+        if count > 0 {
+            list.AppendCategory(&HSTRING::from(title), &collection.cast::<IObjectArray>()?)?;
+        }
+        list.CommitList()?;
+        Ok(removed)
+    }
+}
+
+#[cfg(target_os = "macos")]
+mod dock_menu {
+    use super::file_name;
+    use crate::recent_files::RecentFile;
+    use objc2::runtime::{AnyClass, AnyObject, Imp, Sel};
+    use objc2::{sel, MainThreadMarker};
+    use objc2_app_kit::NSApplication;
+    use std::path::PathBuf;
+    use std::sync::atomic::{AtomicPtr, Ordering};
+    use std::sync::{Mutex, Once};
+    use tauri::menu::{IsMenuItem, Menu, MenuItem};
+    use tauri::{AppHandle, Wry};
+
+    /// Menu item id of a Dock menu entry, followed by its path
+    const ITEM_PREFIX: &str = "dock-recent:";
+
+    /// The NSMenu of MENU, returned to AppKit by `applicationDockMenu:`
+    static NS_MENU: AtomicPtr<AnyObject> = AtomicPtr::new(std::ptr::null_mut());
+    /// Keeps the menu behind NS_MENU alive
+    static MENU: Mutex<Option<Menu<Wry>>> = Mutex::new(None);
+    static SETUP: Once = Once::new();
+
+    extern "C-unwind" fn application_dock_menu(
+        _delegate: &AnyObject,
+        _cmd: Sel,
+        _sender: &AnyObject,
+    ) -> *mut AnyObject {
+        NS_MENU.load(Ordering::Acquire)
+    }
+
+    /// Answer `applicationDockMenu:` in the app delegate of tao, which has no Dock menu
+    fn add_delegate_method(mtm: MainThreadMarker) {
+        let Some(delegate) = NSApplication::sharedApplication(mtm).delegate() else {
+            log_warn!("No app delegate for the Dock menu");
+            return;
+        };
+        let object: &AnyObject = (*delegate).as_ref();
+        let class: *const AnyClass = object.class();
+        unsafe {
+            let imp: Imp = std::mem::transmute(
+                application_dock_menu
+                    as extern "C-unwind" fn(&AnyObject, Sel, &AnyObject) -> *mut AnyObject,
+            );
+            objc2::ffi::class_addMethod(
+                class as *mut AnyClass,
+                sel!(applicationDockMenu:),
+                imp,
+                c"@@:@".as_ptr(),
+            );
+        }
+    }
+
+    fn build_menu(
+        app: &AppHandle,
+        files: &[RecentFile],
+        title: &str,
+    ) -> tauri::Result<Menu<Wry>> {
+        let header = MenuItem::new(app, title, false, None::<&str>)?;
+        let items = files
+            .iter()
+            .map(|file| {
+                let id = format!("{}{}", ITEM_PREFIX, file.path);
+                MenuItem::with_id(app, id, file_name(&file.path), true, None::<&str>)
+            })
+            .collect::<tauri::Result<Vec<_>>>()?;
+        let mut entries: Vec<&dyn IsMenuItem<Wry>> = vec![&header];
+        entries.extend(items.iter().map(|item| item as &dyn IsMenuItem<Wry>));
+        Menu::with_items(app, &entries)
+    }
+
+    pub fn update(
+        app: &AppHandle,
+        files: &[RecentFile],
+        title: &str,
+        on_open: fn(&AppHandle, PathBuf),
+    ) -> Result<(), String> {
+        // Without recent files the Dock shows its own items only
+        let menu = (!files.is_empty())
+            .then(|| build_menu(app, files, title))
+            .transpose()
+            .map_err(|e| format!("Failed to build Dock menu: {}", e))?;
+        let ns_menu = match &menu {
+            Some(menu) => menu
+                .ns_menu()
+                .map_err(|e| format!("Failed to build Dock menu: {}", e))?,
+            None => std::ptr::null_mut(),
+        };
+
+        let app = app.clone();
+        let ns_menu = ns_menu as usize;
+        app.clone()
+            .run_on_main_thread(move || {
+                SETUP.call_once(|| {
+                    if let Some(mtm) = MainThreadMarker::new() {
+                        add_delegate_method(mtm);
+                    }
+                    // Menu events of all menus arrive here; only Dock entries are handled
+                    app.on_menu_event(move |app, event| {
+                        if let Some(path) = event.id().as_ref().strip_prefix(ITEM_PREFIX) {
+                            on_open(app, PathBuf::from(path));
+                        }
+                    });
+                });
+                NS_MENU.store(ns_menu as *mut AnyObject, Ordering::Release);
+                *MENU.lock().unwrap() = menu;
+            })
+            .map_err(|e| format!("Failed to update Dock menu: {}", e))
+    }
+}
@@ -0,0 +1,25 @@
+/// Service name of all entries in the OS credential store (macOS Keychain,
+/// Windows Credential Manager, Secret Service on Linux)
+const SERVICE: &str = "msgReader";
+
+fn entry(account: &str) -> Result<keyring::Entry, String> {
+    keyring::Entry::new(SERVICE, account).map_err(|e| format!("Keychain unavailable: {}", e))
+}
+
+/// Read a secret, None if there is none (or no credential store is available)
+pub fn get(account: &str) -> Option<String> {
+    entry(account).ok()?.get_password().ok()
+}
+
+pub fn set(account: &str, secret: &str) -> Result<(), String> {
+    entry(account)?
+        .set_password(secret)
+        .map_err(|e| format!("Failed to store secret: {}", e))
+}
+
+pub fn delete(account: &str) -> Result<(), String> {
+    match entry(account)?.delete_credential() {
+        Ok(()) | Err(keyring::Error::NoEntry) => Ok(()),
+        Err(e) => Err(format!("Failed to delete secret: {}", e)),
+    }
+}
@@ -0,0 +1,142 @@
+use crate::message::Message;
+use crate::{eml, file_type, msg};
+use memmap2::Mmap;
+use std::collections::HashMap;
+use std::ops::Range;
+use std::path::{Path, PathBuf};
+use std::sync::atomic::{AtomicU64, Ordering};
+use std::sync::{Arc, Mutex};
+
+/// Files from this size on are opened through `LargeFiles`; smaller ones are read whole
+/// and parsed by the frontend
+pub const LARGE_FILE_SIZE: u64 = 50 * 1024 * 1024;
+
+/// Attachments up to this size are sent with the message, so inline images render with
+/// the body; larger ones are read when the user previews, opens or saves them
+const EAGER_ATTACHMENT_SIZE: usize = 1024 * 1024;
+
+/// Where the content of an attachment sent without it is found
+#[derive(Clone)]
+enum Location {
+    /// Stream of the compound file (.msg)
+    Stream(PathBuf),
+    /// Byte range of the MIME part, headers included (.eml)
+    Part(Range<usize>),
+}
+
+struct LargeFile {
+    map: Arc<Mmap>,
+    /// Attachment index -> location of its content
+    deferred: HashMap<usize, Location>,
+}
+
+/// A message opened with `LargeFiles::open`
+#[derive(serde::Serialize)]
+#[serde(rename_all = "camelCase")]
+pub struct LargeMessage {
+    /// Passed to `attachment` and `close`
+    pub handle: u64,
+    pub file_size: u64,
+    /// Indices of the attachments sent without content
+    pub deferred: Vec<usize>,
+    pub message: Message,
+}
+
+/// Messages too large to read into memory and hand to the frontend (hundreds of MB with
+/// video attachments). The file is memory-mapped, only its structure is parsed, and the
+/// content of large attachments is read from the mapping when the frontend asks for it;
+/// the OS only pages in the parts that are touched.
+pub struct LargeFiles {
+    next_handle: AtomicU64,
+    open: Mutex<HashMap<u64, LargeFile>>,
+}
+
+impl LargeFiles {
+    pub fn new() -> Self {
+        LargeFiles {
+            next_handle: AtomicU64::new(1),
+            open: Mutex::new(HashMap::new()),
+        }
+    }
+
+    /// Map and parse a .msg or .eml file, told apart by content; None if it is smaller
+    /// than LARGE_FILE_SIZE
+    pub fn open(&self, path: &Path) -> Result<Option<LargeMessage>, String> {
+        let file = std::fs::File::open(path)
+            .map_err(|e| format!("Failed to open {}: {}", path.display(), e))?;
+        let file_size = file
+            .metadata()
+            .map_err(|e| format!("Failed to read {}: {}", path.display(), e))?
+            .len();
+        if file_size < LARGE_FILE_SIZE {
+            return Ok(None);
+        }
+
+        // Safety: the mapping is only read. Another program truncating the file while
+        // it is open can still crash the read, as with any memory-mapped viewer.
+        let map = unsafe { Mmap::map(&file) }
+            .map_err(|e| format!("Failed to map {}: {}", path.display(), e))?;
+        let is_msg = file_type::detect_bytes(&map) == "msg";
+        let (message, deferred): (Message, HashMap<usize, Location>) = if is_msg {
+            let (message, streams) = msg::parse_lazy(&map, EAGER_ATTACHMENT_SIZE as u64)?;
+            let deferred = streams
+                .into_iter()
+                .map(|(index, stream)| (index, Location::Stream(stream)))
+                .collect();
+            (message, deferred)
+        } else {
+            let (message, parts) = eml::parse_lazy(&map, EAGER_ATTACHMENT_SIZE)?;
+            let deferred = parts
+                .into_iter()
+                .map(|(index, range)| (index, Location::Part(range)))
+                .collect();
+            (message, deferred)
+        };
+
+        let handle = self.next_handle.fetch_add(1, Ordering::Relaxed);
+        let mut indices: Vec<usize> = deferred.keys().copied().collect();
+        indices.sort_unstable();
+        log_info!(
+            "Opened {} ({} bytes) memory-mapped, {} attachments deferred",
+            path.display(),
+            file_size,
+            indices.len()
+        );
+        self.open.lock().unwrap().insert(
+            handle,
+            LargeFile {
+                map: Arc::new(map),
+                deferred,
+            },
+        );
+        Ok(Some(LargeMessage {
+            handle,
+            file_size,
+            deferred: indices,
+            message,
+        }))
+    }
+
+    /// Content of an attachment that `open` sent without it
+    pub fn attachment(&self, handle: u64, index: usize) -> Result<Vec<u8>, String> {
+        // Read outside the lock: large attachments take a while
+        let (map, location) = {
+            let open = self.open.lock().unwrap();
+            let file = open.get(&handle).ok_or("Message is not open")?;
+            let location = file.deferred.get(&index).ok_or("No such attachment")?;
+            (file.map.clone(), location.clone())
+        };
+        match location {
+            Location::Stream(stream) => msg::read_attachment(&map, &stream),
+            Location::Part(range) => {
+                let part = map.get(range).ok_or("Attachment is outside the file")?;
+                eml::read_attachment(part)
+            }
+        }
+    }
+
+    /// Unmap a file opened with `open`
+    pub fn close(&self, handle: u64) {
+        self.open.lock().unwrap().remove(&handle);
+    }
+}
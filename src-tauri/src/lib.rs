@@ -1,42 +1,466 @@
 use std::path::PathBuf;
+use std::sync::atomic::{AtomicBool, Ordering};
 use std::sync::Mutex;
 use tauri::{AppHandle, Emitter, Manager};
 use std::io::Write;
 use tauri_plugin_dialog::DialogExt;
 
+#[macro_use]
+mod logging;
+mod app_info;
+mod archive;
+mod args;
+mod attachments;
+mod authentication;
+mod automation;
+mod batch;
+mod calendar;
+mod charset;
+mod cli;
+mod clipboard;
+mod compare;
+mod compressed;
+mod contact;
+mod deep_link;
+mod delivery;
+mod duplicates;
+mod eml;
+mod file_access;
+mod file_associations;
+mod file_saves;
+mod file_type;
+mod folder;
+mod headers;
+mod help;
+mod hooks;
+mod imap_client;
+mod jump_list;
+mod keychain;
+mod large_files;
+mod locale;
+mod maildir;
+mod mapi;
+mod mbox;
+mod message;
+mod message_window;
+mod msg;
+mod notifications;
+mod ole;
+mod overrides;
+mod pdf;
+mod phishing;
+mod plugins;
+mod policy;
+mod print;
+mod profile;
+mod proxy;
+mod pst;
+mod recent_files;
+mod redact;
+mod remote_images;
+mod reply;
+mod rtf;
+mod sanitize;
+mod scanner;
+mod search;
+mod session;
+mod settings;
+mod smime;
+mod speech;
+mod system_theme;
+mod temp_files;
+mod threads;
+mod thumbnails;
+mod translation;
+mod tray;
+mod updates;
+mod watch;
+mod webhook;
+mod zip_export;
+use app_info::AppInfo;
+use archive::{Archive, ArchiveImport, ArchiveLabels, ArchivePage, ArchiveQuery};
+use attachments::{AttachmentFile, SaveResult};
+use authentication::AuthenticationReport;
+use automation::Automation;
+use batch::{BatchJobs, ConversionJob};
+use calendar::Meeting;
+use compare::MessageDiff;
+use compressed::ZipMember;
+use contact::Contact;
+use deep_link::{ArchiveMessageLink, Target};
+use delivery::DeliveryPath;
+use duplicates::{DuplicateGroup, DuplicateInput};
+use file_access::FileAccess;
+use file_associations::AssociationStatus;
+use file_saves::{FileSaves, SaveJob};
+use file_type::FileType;
+use folder::{DroppedFiles, FolderListing, FolderPage, OpenFolders};
+use imap_client::{ImapAccount, ImapFolder, ImapPage, ImapPasswords};
+use large_files::{LargeFiles, LargeMessage};
+use logging::LogEntry;
+use maildir::{MaildirExport, MaildirItem};
+use mapi::MapiObject;
+use mbox::{MboxFiles, MboxListing, MboxPage};
+use message::{Message, MessageStatus};
+use message_window::{MessageWindows, WindowMessage, MAIN_LABEL};
+use notifications::Notifications;
+use overrides::Overrides;
+use phishing::PhishingReport;
+use plugins::ExportPlugin;
+use policy::Policy;
+use print::PrintOptions;
+use profile::{ActiveProfile, ProfileState};
+use pst::{PstFiles, PstFolder, PstPage};
+use recent_files::{RecentFile, RecentFiles};
+use remote_images::RemoteImages;
+use scanner::{AttachmentCheck, AttachmentScanner};
+use search::{SearchHit, SearchInput, SearchOptions};
+use session::{RestoredSession, Session, SessionStore};
+use settings::{SettingChange, Settings, SettingsStore};
+use speech::{Speech, Voice};
+use temp_files::TempFiles;
+use threads::{Thread, ThreadInput};
+use thumbnails::{Thumbnail, ThumbnailCache};
+use translation::{Translation, TranslationCache, TranslationConfig, TranslationStatus};
+use updates::{DownloadedUpdate, UpdateInfo, Updates};
+use watch::FolderWatcher;
+use zip_export::{ZipExport, ZipItem, ZipOptions};
+
 /// Store pending file paths for when app is launched via file association
-pub struct PendingFiles(pub Mutex<Vec<PathBuf>>);
+pub struct PendingFiles {
+    pub files: Mutex<Vec<PathBuf>>,
+    /// The frontend has asked for the files, so a linked file confirmed later is sent as
+    /// a file-open event instead
+    pub taken: AtomicBool,
+}
 
-/// Read a file from the filesystem and return its bytes
+/// Archive message ids of `msgreader://open?archive=<id>` links the app was launched with
+pub struct PendingArchiveMessages(pub Mutex<Vec<i64>>);
+
+/// Read a file the user opened (see FileAccess) and return its bytes. The bytes are sent
+/// as a raw IPC response (an ArrayBuffer in the frontend) instead of a JSON number array,
+/// which was several times the file size and froze the window on large MSG files.
 #[tauri::command]
-fn read_file_as_bytes(path: String) -> Result<Vec<u8>, String> {
-    std::fs::read(&path).map_err(|e| format!("Failed to read file {}: {}", path, e))
+async fn read_file_as_bytes(
+    access: tauri::State<'_, FileAccess>,
+    path: String,
+) -> Result<tauri::ipc::Response, String> {
+    let path = access.check(&path)?;
+    let bytes = tauri::async_runtime::spawn_blocking(move || {
+        std::fs::read(&path).map_err(|e| format!("Failed to read file {}: {}", path.display(), e))
+    })
+    .await
+    .map_err(|e| format!("Failed to read file: {}", e))??;
+    Ok(tauri::ipc::Response::new(bytes))
 }
 
-/// Save a base64-encoded file to temp directory and open with system viewer
+/// Parse an Outlook .msg file in the backend, for callers that want structured data
+/// without running the frontend parser
 #[tauri::command]
-fn open_file_with_system(base64_content: String, file_name: String) -> Result<(), String> {
-    use base64::{Engine as _, engine::general_purpose::STANDARD};
+async fn parse_msg_file(
+    access: tauri::State<'_, FileAccess>,
+    path: String,
+) -> Result<Message, String> {
+    let path = access.check(&path)?;
+    tauri::async_runtime::spawn_blocking(move || msg::parse(&path))
+        .await
+        .map_err(|e| format!("MSG parser failed: {}", e))?
+}
+
+/// The OLE objects (Excel ranges, equations, packaged files, ...) of an .msg file,
+/// unwrapped into the files they hold; the frontend parser cannot read them
+#[tauri::command]
+async fn read_ole_objects(
+    access: tauri::State<'_, FileAccess>,
+    path: String,
+) -> Result<msg::OleObjects, String> {
+    let path = access.check(&path)?;
+    tauri::async_runtime::spawn_blocking(move || msg::ole_objects(&path))
+        .await
+        .map_err(|e| format!("Failed to read OLE objects: {}", e))?
+}
+
+/// Convert a message exported by the frontend (messageToJson) into an Outlook .msg file,
+/// e.g. to archive an EML message in a system that only accepts MSG
+#[tauri::command]
+async fn export_msg(app: AppHandle, message_json: String) -> Result<tauri::ipc::Response, String> {
+    overrides::ensure_not_kiosk(&app)?;
+    let bytes = tauri::async_runtime::spawn_blocking(move || msg::write(&message_json))
+        .await
+        .map_err(|e| format!("MSG export failed: {}", e))??;
+    Ok(tauri::ipc::Response::new(bytes))
+}
+
+/// Write a redacted copy of a message exported by the frontend (messageToJson) as an .eml
+/// or .msg file, for sharing a message without the people in it, see redact::redact
+#[tauri::command]
+async fn export_redacted(
+    app: AppHandle,
+    message_json: String,
+    format: String,
+    options: redact::RedactionOptions,
+) -> Result<tauri::ipc::Response, String> {
+    overrides::ensure_not_kiosk(&app)?;
+    let bytes = tauri::async_runtime::spawn_blocking(move || {
+        let message = redact::redact(msg::parse_exported(&message_json)?, &options);
+        match format.as_str() {
+            "eml" => Ok(eml::write(&message)),
+            "msg" => msg::write_message(message),
+            _ => Err(format!("Unsupported format: {}", format)),
+        }
+    })
+    .await
+    .map_err(|e| format!("Redacted export failed: {}", e))??;
+    Ok(tauri::ipc::Response::new(bytes))
+}
+
+/// Parse an .eml file in the backend, see parse_msg_file
+#[tauri::command]
+async fn parse_eml_file(
+    access: tauri::State<'_, FileAccess>,
+    path: String,
+) -> Result<Message, String> {
+    let path = access.check(&path)?;
+    tauri::async_runtime::spawn_blocking(move || eml::parse(&path))
+        .await
+        .map_err(|e| format!("EML parser failed: {}", e))?
+}
+
+/// The type of a file by its content: msg, eml, mbox, tnef, pst, zip, gz or unknown,
+/// and whether its extension says otherwise
+#[tauri::command]
+async fn detect_file_type(
+    access: tauri::State<'_, FileAccess>,
+    path: String,
+) -> Result<FileType, String> {
+    let path = access.check(&path)?;
+    tauri::async_runtime::spawn_blocking(move || file_type::detect(&path))
+        .await
+        .map_err(|e| format!("Failed to detect file type: {}", e))?
+}
+
+/// Open a .msg or .eml file of LARGE_FILE_SIZE or more memory-mapped: the structure is
+/// parsed in the backend and large attachments are left out until they are requested.
+/// None for smaller files, which the frontend reads and parses itself.
+#[tauri::command]
+async fn open_large_message(app: AppHandle, path: String) -> Result<Option<LargeMessage>, String> {
+    let path = app.state::<FileAccess>().check(&path)?;
+    tauri::async_runtime::spawn_blocking(move || app.state::<LargeFiles>().open(&path))
+        .await
+        .map_err(|e| format!("Failed to open message: {}", e))?
+}
+
+/// Content of an attachment of a large message as raw bytes, e.g. for a preview
+#[tauri::command]
+async fn read_large_attachment(
+    app: AppHandle,
+    handle: u64,
+    index: usize,
+) -> Result<tauri::ipc::Response, String> {
+    let bytes = tauri::async_runtime::spawn_blocking(move || {
+        app.state::<LargeFiles>().attachment(handle, index)
+    })
+    .await
+    .map_err(|e| format!("Failed to read attachment: {}", e))??;
+    Ok(tauri::ipc::Response::new(bytes))
+}
+
+/// Read an attachment of a large message and scan it off the async runtime threads,
+/// see ensure_scanned
+async fn read_large_scanned(
+    app: &AppHandle,
+    handle: u64,
+    index: usize,
+    file_name: &str,
+) -> Result<Vec<u8>, String> {
+    let app = app.clone();
+    let name = file_name.to_string();
+    tauri::async_runtime::spawn_blocking(move || {
+        let bytes = app.state::<LargeFiles>().attachment(handle, index)?;
+        ensure_scanned(&app, &bytes, &name)?;
+        Ok(bytes)
+    })
+    .await
+    .map_err(|e| format!("Failed to scan {}: {}", file_name, e))?
+}
+
+/// Save an attachment of a large message with a "Save As" dialog and write it in the
+/// background, like start_file_save but without sending the content through the frontend
+#[tauri::command]
+async fn start_large_attachment_save(
+    app: AppHandle,
+    handle: u64,
+    index: usize,
+    file_name: String,
+) -> Result<Option<SaveJob>, String> {
+    overrides::ensure_not_kiosk(&app)?;
+    let bytes = read_large_scanned(&app, handle, index, &file_name).await?;
+    Ok(start_save(&app, bytes, &file_name))
+}
+
+/// Open an attachment of a large message with the system's default app, see
+/// open_file_with_system
+#[tauri::command]
+async fn open_large_attachment(
+    app: AppHandle,
+    temp_files: tauri::State<'_, TempFiles>,
+    handle: u64,
+    index: usize,
+    file_name: String,
+) -> Result<(), String> {
+    overrides::ensure_not_kiosk(&app)?;
+    let bytes = read_large_scanned(&app, handle, index, &file_name).await?;
+    open_with_system(&temp_files, &bytes, &file_name)
+}
+
+#[tauri::command]
+fn close_large_message(large_files: tauri::State<'_, LargeFiles>, handle: u64) {
+    large_files.close(handle);
+}
+
+/// Sanitize the HTML body of a message in the backend before the viewer shows it, see
+/// sanitize::sanitize; `inline_images` maps Content-IDs to the URLs of the images
+#[tauri::command]
+async fn sanitize_html(
+    html: String,
+    inline_images: std::collections::HashMap<String, String>,
+) -> Result<String, String> {
+    tauri::async_runtime::spawn_blocking(move || sanitize::sanitize(&html, inline_images))
+        .await
+        .map_err(|e| format!("HTML sanitizer failed: {}", e))
+}
+
+/// Reply to, reply all or forward a message (base64 .eml or .msg file) with the default
+/// mail client: as a mailto: URL, or as an unsent .eml draft opened in its composer
+#[tauri::command]
+async fn reply_via_default_client(
+    app: AppHandle,
+    temp_files: tauri::State<'_, TempFiles>,
+    data: String,
+    mode: reply::Mode,
+    handoff: reply::Handoff,
+) -> Result<(), String> {
+    use base64::{engine::general_purpose::STANDARD, Engine as _};
+
+    overrides::ensure_not_kiosk(&app)?;
+
+    let draft = tauri::async_runtime::spawn_blocking(move || {
+        let message = STANDARD
+            .decode(data)
+            .map_err(|e| format!("Failed to decode base64: {}", e))?;
+        reply::build_from_bytes(&message, mode)
+    })
+    .await
+    .map_err(|e| format!("Failed to build reply: {}", e))??;
+
+    match handoff {
+        reply::Handoff::Mailto => open_url(&reply::mailto_url(&draft)),
+        reply::Handoff::Draft => open_with_system(
+            &temp_files,
+            &reply::draft_eml(&draft),
+            &reply::draft_file_name(&draft),
+        ),
+    }
+}
+
+/// Decode text in a charset the frontend's decoder lacks (ISO-2022-JP/-KR/-CN, ...) or a
+/// Windows code page number, from base64 bytes
+#[tauri::command]
+fn decode_bytes(data: String, charset: String) -> Result<String, String> {
+    use base64::{engine::general_purpose::STANDARD, Engine as _};
 
-    // Decode base64 content
-    let bytes = STANDARD.decode(&base64_content)
+    let bytes = STANDARD
+        .decode(data)
         .map_err(|e| format!("Failed to decode base64: {}", e))?;
+    charset::decode(&bytes, &charset)
+}
+
+/// Load scanner.json from the app's config directory
+fn load_scanner_config(app: &AppHandle) -> Result<Option<scanner::ScannerConfig>, String> {
+    let config_dir = overrides::config_dir(app)?;
+    scanner::load(&config_dir.join(scanner::CONFIG_FILE))
+}
+
+/// Scan a file before it leaves the app (opened, saved or dragged out) with the
+/// configured antivirus scanner; Err if it must not be written
+fn ensure_scanned(app: &AppHandle, bytes: &[u8], file_name: &str) -> Result<(), String> {
+    let config = load_scanner_config(app)?;
+    app.state::<AttachmentScanner>()
+        .ensure_allowed(config.as_ref(), bytes, file_name)
+}
+
+/// Decode a base64 file and scan it off the async runtime threads, see ensure_scanned
+async fn decode_scanned(
+    app: &AppHandle,
+    base64_content: String,
+    file_name: &str,
+) -> Result<Vec<u8>, String> {
+    use base64::{Engine as _, engine::general_purpose::STANDARD};
+
+    let app = app.clone();
+    let name = file_name.to_string();
+    tauri::async_runtime::spawn_blocking(move || {
+        let bytes = STANDARD
+            .decode(&base64_content)
+            .map_err(|e| format!("Failed to decode base64: {}", e))?;
+        ensure_scanned(&app, &bytes, &name)?;
+        Ok(bytes)
+    })
+    .await
+    .map_err(|e| format!("Failed to scan {}: {}", file_name, e))?
+}
+
+/// SHA-256 and MD5 of an attachment (base64) and the verdict of the configured antivirus
+/// scanner (ClamAV's clamd or AMSI on Windows), shown before the user opens or saves it
+#[tauri::command]
+async fn check_attachment(
+    app: AppHandle,
+    data: String,
+    file_name: String,
+) -> Result<AttachmentCheck, String> {
+    use base64::{engine::general_purpose::STANDARD, Engine as _};
+
+    tauri::async_runtime::spawn_blocking(move || {
+        let bytes = STANDARD
+            .decode(data)
+            .map_err(|e| format!("Failed to decode base64: {}", e))?;
+        let config = load_scanner_config(&app)?;
+        Ok(app
+            .state::<AttachmentScanner>()
+            .check(config.as_ref(), &bytes, &file_name))
+    })
+    .await
+    .map_err(|e| format!("Failed to check attachment: {}", e))?
+}
+
+/// Save a base64-encoded file to a tracked temp file and open with system viewer.
+/// Files the antivirus scanner objects to are not written.
+#[tauri::command]
+async fn open_file_with_system(
+    app: AppHandle,
+    temp_files: tauri::State<'_, TempFiles>,
+    base64_content: String,
+    file_name: String,
+) -> Result<(), String> {
+    overrides::ensure_not_kiosk(&app)?;
 
-    // Create temp file path
-    let temp_dir = std::env::temp_dir();
-    let temp_path = temp_dir.join(&file_name);
+    let bytes = decode_scanned(&app, base64_content, &file_name).await?;
+    open_with_system(&temp_files, &bytes, &file_name)
+}
 
-    // Write to temp file
-    let mut file = std::fs::File::create(&temp_path)
-        .map_err(|e| format!("Failed to create temp file: {}", e))?;
-    file.write_all(&bytes)
-        .map_err(|e| format!("Failed to write temp file: {}", e))?;
+/// Write a file to a tracked temp file and open it with the system's default app
+fn open_with_system(temp_files: &TempFiles, bytes: &[u8], file_name: &str) -> Result<(), String> {
+    // Write to a tracked temp file (removed on exit or when the retention period ends)
+    let temp_path = temp_files.write(file_name, bytes)?;
+    open_path(temp_path.as_os_str())
+}
 
-    // Open with system default application
+/// Open a file with the system's default application
+fn open_path(path: &std::ffi::OsStr) -> Result<(), String> {
     #[cfg(target_os = "macos")]
     {
         std::process::Command::new("open")
-            .arg(&temp_path)
+            .arg(path)
             .spawn()
             .map_err(|e| format!("Failed to open file: {}", e))?;
     }
@@ -51,7 +475,7 @@ fn open_file_with_system(base64_content: String, file_name: String) -> Result<()
         // CREATE_NO_WINDOW prevents the console window from flashing
         std::process::Command::new("cmd")
             .args(["/c", "start", ""])
-            .arg(&temp_path)
+            .arg(path)
             .creation_flags(CREATE_NO_WINDOW)
             .spawn()
             .map_err(|e| format!("Failed to open file: {}", e))?;
@@ -60,7 +484,7 @@ fn open_file_with_system(base64_content: String, file_name: String) -> Result<()
     #[cfg(target_os = "linux")]
     {
         std::process::Command::new("xdg-open")
-            .arg(&temp_path)
+            .arg(path)
             .spawn()
             .map_err(|e| format!("Failed to open file: {}", e))?;
     }
@@ -68,158 +492,2596 @@ fn open_file_with_system(base64_content: String, file_name: String) -> Result<()
     Ok(())
 }
 
-/// Save a file with a "Save As" dialog
+/// Open a URL (mailto:) with the application registered for its scheme
+fn open_url(url: &str) -> Result<(), String> {
+    #[cfg(target_os = "windows")]
+    {
+        use std::os::windows::process::CommandExt;
+        const CREATE_NO_WINDOW: u32 = 0x08000000;
+
+        // Not cmd's start: it would treat the & between URL parameters as a command separator
+        std::process::Command::new("rundll32")
+            .args(["url.dll,FileProtocolHandler", url])
+            .creation_flags(CREATE_NO_WINDOW)
+            .spawn()
+            .map_err(|e| format!("Failed to open URL: {}", e))?;
+        Ok(())
+    }
+
+    #[cfg(not(target_os = "windows"))]
+    {
+        open_path(std::ffi::OsStr::new(url))
+    }
+}
+
+/// Save a file with a "Save As" dialog, returns the chosen path (None if cancelled).
+/// Files the antivirus scanner objects to are refused before the dialog opens.
 #[tauri::command]
 async fn save_file_with_dialog(
     app: AppHandle,
     base64_content: String,
     file_name: String,
-) -> Result<bool, String> {
-    use base64::{Engine as _, engine::general_purpose::STANDARD};
+) -> Result<Option<String>, String> {
+    overrides::ensure_not_kiosk(&app)?;
+    let bytes = decode_scanned(&app, base64_content, &file_name).await?;
+    save_with_dialog(&app, &bytes, &file_name)
+}
+
+/// Save a file with a "Save As" dialog and write it in the background, reporting its
+/// progress with events and allowing to cancel it, see FileSaves::start. Files the
+/// antivirus scanner objects to are refused before the dialog opens. Returns None if
+/// the dialog was cancelled.
+#[tauri::command]
+async fn start_file_save(
+    app: AppHandle,
+    base64_content: String,
+    file_name: String,
+) -> Result<Option<SaveJob>, String> {
+    overrides::ensure_not_kiosk(&app)?;
+    let bytes = decode_scanned(&app, base64_content, &file_name).await?;
+    Ok(start_save(&app, bytes, &file_name))
+}
+
+/// Stop writing a file started with start_file_save or start_large_attachment_save; the
+/// partial file is removed. False if the save is not running.
+#[tauri::command]
+fn cancel_file_save(saves: tauri::State<'_, FileSaves>, save_id: u64) -> bool {
+    saves.cancel(save_id)
+}
+
+/// Ask for the destination of a file with a "Save As" dialog and start writing it there,
+/// None if cancelled
+fn start_save(app: &AppHandle, bytes: Vec<u8>, file_name: &str) -> Option<SaveJob> {
+    let path = pick_save_path(app, file_name)?;
+    Some(app.state::<FileSaves>().start(app, path, bytes))
+}
+
+/// Ask for the destination of a file with a "Save As" dialog, None if cancelled
+fn pick_save_path(app: &AppHandle, file_name: &str) -> Option<PathBuf> {
     use tauri_plugin_dialog::FilePath;
 
     // Extract file extension for filter
-    let extension = std::path::Path::new(&file_name)
+    let extension = std::path::Path::new(file_name)
         .extension()
         .and_then(|e| e.to_str())
         .unwrap_or("")
         .to_string();
 
     // Build the save dialog
-    let file_path = app
+    let mut dialog = app
         .dialog()
         .file()
-        .set_file_name(&file_name)
-        .add_filter("File", &[&extension])
-        .blocking_save_file();
+        .set_file_name(file_name)
+        .add_filter("File", &[&extension]);
+    if let Some(dir) = default_save_directory(app) {
+        dialog = dialog.set_directory(dir);
+    }
+    match dialog.blocking_save_file() {
+        Some(FilePath::Path(path)) => Some(path),
+        _ => None,
+    }
+}
 
-    match file_path {
-        Some(FilePath::Path(path)) => {
-            // Decode base64 content
-            let bytes = STANDARD
-                .decode(&base64_content)
-                .map_err(|e| format!("Failed to decode base64: {}", e))?;
+/// Ask for the destination of a file with a "Save As" dialog and write it there,
+/// returns the chosen path (None if cancelled)
+fn save_with_dialog(
+    app: &AppHandle,
+    bytes: &[u8],
+    file_name: &str,
+) -> Result<Option<String>, String> {
+    let Some(path) = pick_save_path(app, file_name) else {
+        return Ok(None); // User cancelled
+    };
+    let mut file = std::fs::File::create(&path)
+        .map_err(|e| format!("Failed to create file: {}", e))?;
+    file.write_all(bytes).map_err(|e| format!("Failed to write file: {}", e))?;
+    Ok(Some(path.to_string_lossy().to_string()))
+}
+
+/// Save several attachments to a directory chosen in a dialog. Existing files are never
+/// overwritten: conflicting names get a number, e.g. `invoice (1).pdf`.
+/// Returns one result per attachment, None if the dialog was cancelled.
+#[tauri::command]
+async fn save_all_attachments(
+    app: AppHandle,
+    files: Vec<AttachmentFile>,
+) -> Result<Option<Vec<SaveResult>>, String> {
+    use tauri_plugin_dialog::FilePath;
 
-            // Write to the selected file
-            let mut file = std::fs::File::create(&path)
-                .map_err(|e| format!("Failed to create file: {}", e))?;
-            file.write_all(&bytes)
-                .map_err(|e| format!("Failed to write file: {}", e))?;
+    overrides::ensure_not_kiosk(&app)?;
 
-            Ok(true)
-        }
-        _ => Ok(false), // User cancelled
+    let mut dialog = app.dialog().file();
+    if let Some(dir) = default_save_directory(&app) {
+        dialog = dialog.set_directory(dir);
+    }
+
+    match dialog.blocking_pick_folder() {
+        Some(FilePath::Path(dir)) => Ok(Some(
+            tauri::async_runtime::spawn_blocking(move || {
+                attachments::save_all(&dir, &files, |name, bytes| {
+                    ensure_scanned(&app, bytes, name)
+                })
+            })
+            .await
+            .map_err(|e| format!("Failed to save attachments: {}", e))?,
+        )),
+        _ => Ok(None), // User cancelled
     }
 }
 
-/// Get files that were passed to the app on startup
+/// Icon shown under the cursor while dragging files out of the app
+const DRAG_ICON: &[u8] = include_bytes!("../icons/32x32.png");
+
+/// Run a native drag session for files that already exist on disk, returns true if they
+/// were dropped somewhere. The OS file managers (Explorer, Finder, Nautilus and the
+/// like) copy the files when they are dropped.
+async fn drag_files(window: tauri::WebviewWindow, paths: Vec<PathBuf>) -> Result<bool, String> {
+    // Native drag sessions must be started on the main thread
+    let (sender, receiver) = std::sync::mpsc::channel();
+    let drag_window = window.clone();
+    window
+        .run_on_main_thread(move || {
+            #[cfg(target_os = "linux")]
+            let handle = drag_window.gtk_window();
+            #[cfg(not(target_os = "linux"))]
+            let handle = tauri::Result::Ok(drag_window);
+
+            let result = handle.map_err(|e| e.to_string()).and_then(|handle| {
+                drag::start_drag(
+                    &handle,
+                    drag::DragItem::Files(paths),
+                    drag::Image::Raw(DRAG_ICON.to_vec()),
+                    move |result, _cursor| {
+                        let _ = sender.send(matches!(result, drag::DragResult::Dropped));
+                    },
+                    drag::Options::default(),
+                )
+                .map_err(|e| e.to_string())
+            });
+            if let Err(e) = result {
+                log_warn!("Failed to start drag: {}", e);
+            }
+        })
+        .map_err(|e| format!("Failed to start drag: {}", e))?;
+
+    // The sender is dropped without a result if the drag could not be started
+    tauri::async_runtime::spawn_blocking(move || receiver.recv().unwrap_or(false))
+        .await
+        .map_err(|e| format!("Drag task failed: {}", e))
+}
+
+/// Drag a message out of the app as a file.
+/// The converted file is written to a tracked temp file as soon as the drag starts,
+/// because the OS needs a real path to hand to the drop target.
+/// Returns true if the file was dropped somewhere.
 #[tauri::command]
-fn get_pending_files(state: tauri::State<'_, PendingFiles>) -> Vec<String> {
-    let mut pending = state.0.lock().unwrap();
-    let files: Vec<String> = pending.drain(..).map(|p| p.to_string_lossy().to_string()).collect();
-    files
+async fn start_message_drag(
+    window: tauri::WebviewWindow,
+    temp_files: tauri::State<'_, TempFiles>,
+    base64_content: String,
+    file_name: String,
+) -> Result<bool, String> {
+    use base64::{Engine as _, engine::general_purpose::STANDARD};
+
+    overrides::ensure_not_kiosk(window.app_handle())?;
+    let bytes = STANDARD
+        .decode(&base64_content)
+        .map_err(|e| format!("Failed to decode base64: {}", e))?;
+    let path = temp_files.write(&file_name, &bytes)?;
+    drag_files(window, vec![path]).await
 }
 
-/// Handle a file being opened - emit event to frontend
-fn handle_file_open(app: &AppHandle, path: PathBuf) {
-    // Validate file extension
-    let ext = path
-        .extension()
-        .and_then(|e| e.to_str())
-        .map(|e| e.to_lowercase());
+/// Drag several files out of the app at once, e.g. attachments or selected messages.
+/// Every file is scanned first; one the antivirus scanner objects to stops the drag.
+/// Like `start_message_drag`, the files are staged as tracked temp files first.
+#[tauri::command]
+async fn start_files_drag(
+    window: tauri::WebviewWindow,
+    temp_files: tauri::State<'_, TempFiles>,
+    files: Vec<AttachmentFile>,
+) -> Result<bool, String> {
+    overrides::ensure_not_kiosk(window.app_handle())?;
+    if files.is_empty() {
+        return Ok(false);
+    }
+    let mut paths = Vec::with_capacity(files.len());
+    for file in files {
+        let bytes = decode_scanned(window.app_handle(), file.base64_content, &file.file_name)
+            .await?;
+        paths.push(temp_files.write(&attachments::safe_file_name(&file.file_name), &bytes)?);
+    }
+    drag_files(window, paths).await
+}
 
-    match ext.as_deref() {
-        Some("msg") | Some("eml") => {
-            // Emit event to frontend
-            if let Err(e) = app.emit("file-open", path.to_string_lossy().to_string()) {
-                eprintln!("Failed to emit file-open event: {}", e);
-            }
-        }
-        _ => {
-            eprintln!("Unsupported file type: {:?}", path);
-        }
+/// Put attachments or messages on the OS clipboard as files, to paste them into a folder
+/// or another app. Like `start_files_drag`, every file is scanned and staged as a tracked
+/// temp file first, so it only stays pasteable while temp files are kept.
+#[tauri::command]
+async fn copy_files_to_clipboard(
+    app: AppHandle,
+    temp_files: tauri::State<'_, TempFiles>,
+    files: Vec<AttachmentFile>,
+) -> Result<(), String> {
+    overrides::ensure_not_kiosk(&app)?;
+    let mut paths = Vec::with_capacity(files.len());
+    for file in files {
+        let bytes = decode_scanned(&app, file.base64_content, &file.file_name).await?;
+        paths.push(temp_files.write(&attachments::safe_file_name(&file.file_name), &bytes)?);
     }
+    tauri::async_runtime::spawn_blocking(move || clipboard::copy_files(&paths))
+        .await
+        .map_err(|e| format!("Failed to copy to the clipboard: {}", e))?
 }
 
-#[cfg_attr(mobile, tauri::mobile_entry_point)]
-pub fn run() {
-    let builder = tauri::Builder::default()
-        .plugin(tauri_plugin_fs::init())
-        .plugin(tauri_plugin_updater::Builder::new().build())
-        .plugin(tauri_plugin_dialog::init())
-        .plugin(tauri_plugin_process::init())
-        .plugin(tauri_plugin_single_instance::init(|app, args, _cwd| {
-            // Handle file opened when app is already running (Windows/Linux)
-            if args.len() > 1 {
-                let path = PathBuf::from(&args[1]);
-                handle_file_open(app, path);
-            }
-            // Focus the main window
-            if let Some(window) = app.get_webview_window("main") {
-                let _ = window.set_focus();
-            }
-        }))
-        .manage(PendingFiles(Mutex::new(Vec::new())))
-        .setup(|app| {
-            // Check for files passed as command-line arguments on startup (Windows/Linux)
-            let args: Vec<String> = std::env::args().collect();
-            for arg in args.iter().skip(1) {
-                let path = PathBuf::from(arg);
-                let ext = path
-                    .extension()
-                    .and_then(|e| e.to_str())
-                    .map(|e| e.to_lowercase());
-
-                if matches!(ext.as_deref(), Some("msg") | Some("eml")) {
-                    // Store for later retrieval by frontend
-                    app.state::<PendingFiles>()
-                        .0
-                        .lock()
-                        .unwrap()
-                        .push(path);
-                }
-            }
+/// Delete all temp files created by the app, returns the number of entries removed
+#[tauri::command]
+fn clear_temp_files(temp_files: tauri::State<'_, TempFiles>) -> usize {
+    temp_files.clear()
+}
 
-            Ok(())
-        })
-        .invoke_handler(tauri::generate_handler![read_file_as_bytes, get_pending_files, open_file_with_system, save_file_with_dialog]);
+/// Set how long temp files are kept (0 = until the app exits)
+#[tauri::command]
+fn set_temp_file_retention(temp_files: tauri::State<'_, TempFiles>, minutes: u64) -> usize {
+    temp_files.set_retention_minutes(minutes);
+    temp_files.sweep_expired()
+}
 
-    builder
-        .build(tauri::generate_context!())
-        .expect("error while building tauri application")
-        .run(|app, event| {
-            // Handle macOS file open events (double-click on file)
-            // This event only exists on macOS
-            #[cfg(target_os = "macos")]
-            if let tauri::RunEvent::Opened { urls } = &event {
-                for url in urls {
-                    // Convert file:// URL to path
-                    if let Ok(path) = url.to_file_path() {
-                        // Check if app is ready (has windows)
-                        if app.get_webview_window("main").is_some() {
-                            // App is running, emit event
-                            handle_file_open(app, path);
-                        } else {
-                            // App is starting up, store for later
-                            let ext = path
-                                .extension()
-                                .and_then(|e| e.to_str())
-                                .map(|e| e.to_lowercase());
-
-                            if matches!(ext.as_deref(), Some("msg") | Some("eml")) {
-                                app.state::<PendingFiles>()
-                                    .0
-                                    .lock()
-                                    .unwrap()
-                                    .push(path);
-                            }
+/// Directory that holds export plugins (one subdirectory per plugin)
+fn plugins_dir(app: &AppHandle) -> Result<PathBuf, String> {
+    overrides::config_dir(app).map(|dir| dir.join("plugins"))
+}
+
+/// List installed export plugins
+#[tauri::command]
+fn list_export_plugins(app: AppHandle) -> Result<Vec<ExportPlugin>, String> {
+    Ok(plugins::discover(&plugins_dir(&app)?))
+}
+
+/// Run an export plugin with a parsed message and return its output as base64
+#[tauri::command]
+async fn run_export_plugin(
+    app: AppHandle,
+    plugin_id: String,
+    message_json: String,
+) -> Result<String, String> {
+    use base64::{Engine as _, engine::general_purpose::STANDARD};
+
+    overrides::ensure_not_kiosk(&app)?;
+    let plugin = plugins::discover(&plugins_dir(&app)?)
+        .into_iter()
+        .find(|plugin| plugin.id == plugin_id)
+        .ok_or_else(|| format!("Export plugin not found: {}", plugin_id))?;
+
+    // Plugins may take a while; keep them off the async runtime threads
+    let output = tauri::async_runtime::spawn_blocking(move || plugins::run(&plugin, &message_json))
+        .await
+        .map_err(|e| format!("Plugin task failed: {}", e))??;
+
+    Ok(STANDARD.encode(output))
+}
+
+/// Run the user's configured commands for an app event
+#[tauri::command]
+fn run_event_hooks(
+    app: AppHandle,
+    event: String,
+    variables: std::collections::HashMap<String, String>,
+) -> Result<usize, String> {
+    if !hooks::EVENTS.contains(&event.as_str()) {
+        return Err(format!("Unknown hook event: {}", event));
+    }
+
+    let config_dir = overrides::config_dir(&app)?;
+    let config = hooks::load(&config_dir.join(hooks::CONFIG_FILE))?;
+
+    Ok(hooks::run(&config, &event, &variables))
+}
+
+/// Send a notification to the configured webhook.
+/// Returns false when no webhook is configured.
+#[tauri::command]
+async fn send_webhook_notification(app: AppHandle, payload_json: String) -> Result<bool, String> {
+    overrides::ensure_online(&app)?;
+    let config_dir = overrides::config_dir(&app)?;
+    let Some(config) = webhook::load(&config_dir.join(webhook::CONFIG_FILE))? else {
+        return Ok(false);
+    };
+    let proxy = proxy::load(&config_dir.join(proxy::CONFIG_FILE))?;
+
+    // Retries sleep between attempts; keep them off the async runtime threads
+    tauri::async_runtime::spawn_blocking(move || {
+        webhook::deliver(&config, &proxy, &payload_json)
+    })
+    .await
+    .map_err(|e| format!("Webhook task failed: {}", e))??;
+
+    Ok(true)
+}
+
+/// Load proxy.json from the app's config directory
+fn load_proxy_config(app: &AppHandle) -> Result<proxy::ProxyConfig, String> {
+    let config_dir = overrides::config_dir(app)?;
+    proxy::load(&config_dir.join(proxy::CONFIG_FILE))
+}
+
+/// Fail if updates were turned off by the `DisableAutoUpdate` policy
+fn ensure_updates_allowed(app: &AppHandle) -> Result<(), String> {
+    if app.state::<Policy>().disable_auto_update {
+        return Err("Updates are disabled by policy".to_string());
+    }
+    overrides::ensure_online(app)
+}
+
+/// Check the GitHub releases for a newer version, None if the app is up to date
+#[tauri::command]
+async fn check_for_updates(app: AppHandle) -> Result<Option<UpdateInfo>, String> {
+    ensure_updates_allowed(&app)?;
+    let config = load_proxy_config(&app)?;
+    let proxy = tauri::async_runtime::spawn_blocking(move || {
+        proxy::proxy_for_url(&config, updates::UPDATE_HOST_URL)
+    })
+    .await
+    .map_err(|e| format!("Proxy lookup failed: {}", e))??;
+
+    let update = app.state::<Updates>().check(&app, proxy).await?;
+    if let Some(info) = &update {
+        let notification = notifications::update_available(&app, &info.version);
+        app.state::<Notifications>().show(&app, notification);
+    }
+    Ok(update)
+}
+
+/// Download the update found by `check_for_updates` and verify its signature.
+/// Emits `update-download-progress` events; the update is installed on exit.
+#[tauri::command]
+async fn download_update(app: AppHandle) -> Result<DownloadedUpdate, String> {
+    ensure_updates_allowed(&app)?;
+    app.state::<Updates>().download(&app).await
+}
+
+/// Install the downloaded update now and, with `restart`, start the new version
+#[tauri::command]
+fn install_update(app: AppHandle, restart: bool) -> Result<bool, String> {
+    let installed = app.state::<Updates>().install()?;
+    if installed && restart {
+        app.restart();
+    }
+    Ok(installed)
+}
+
+/// Latest log entries of this session, oldest first (at most `limit`, 200 by default)
+#[tauri::command]
+fn get_recent_logs(limit: Option<usize>) -> Vec<LogEntry> {
+    logging::logger().recent(limit.unwrap_or(200))
+}
+
+/// Minimum level of logged messages: debug, info, warning, error or critical
+#[tauri::command]
+fn set_log_level(level: String) -> Result<(), String> {
+    logging::logger().set_level(&level)
+}
+
+/// Add a message of the frontend to the log
+#[tauri::command]
+fn write_log(level: String, message: String) -> Result<(), String> {
+    let level =
+        logging::parse_level(&level).ok_or_else(|| format!("Invalid log level: {}", level))?;
+    logging::logger().log(level, "frontend", message);
+    Ok(())
+}
+
+/// Show the folder with the log files in the file manager
+#[tauri::command]
+fn open_log_folder() -> Result<(), String> {
+    let dir = logging::logger()
+        .dir()
+        .ok_or_else(|| "Log files are not available".to_string())?;
+
+    #[cfg(target_os = "macos")]
+    let command = std::process::Command::new("open").arg(&dir).spawn();
+
+    #[cfg(target_os = "windows")]
+    let command = std::process::Command::new("explorer").arg(&dir).spawn();
+
+    #[cfg(target_os = "linux")]
+    let command = std::process::Command::new("xdg-open").arg(&dir).spawn();
+
+    command.map_err(|e| format!("Failed to open log folder: {}", e))?;
+    Ok(())
+}
+
+/// Whether msgReader is the default app for .msg and .eml files
+#[tauri::command]
+async fn get_file_association_status() -> Vec<AssociationStatus> {
+    // Asking the OS runs external programs, so this stays off the main thread
+    tauri::async_runtime::spawn_blocking(file_associations::status)
+        .await
+        .unwrap_or_default()
+}
+
+/// Open the OS settings page for default apps, false if there is none
+#[tauri::command]
+fn open_default_apps_settings(app: AppHandle) -> Result<bool, String> {
+    overrides::ensure_not_kiosk(&app)?;
+    file_associations::open_settings()
+}
+
+/// Make msgReader the default app for .msg and .eml, false where the OS leaves that to
+/// the user
+#[tauri::command]
+async fn make_default_app(app: AppHandle) -> Result<bool, String> {
+    overrides::ensure_not_kiosk(&app)?;
+    tauri::async_runtime::spawn_blocking(file_associations::make_default)
+        .await
+        .map_err(|e| format!("Failed to set the default app: {}", e))?
+}
+
+/// Remove the file associations the app can remove itself, false if there are none
+#[tauri::command]
+async fn unregister_file_associations(app: AppHandle) -> Result<bool, String> {
+    overrides::ensure_not_kiosk(&app)?;
+    tauri::async_runtime::spawn_blocking(file_associations::unregister)
+        .await
+        .map_err(|e| format!("Failed to remove the file associations: {}", e))?
+}
+
+/// Settings overridden with `MSGREADER_*` environment variables or command-line flags
+#[tauri::command]
+fn get_overrides(overrides: tauri::State<'_, Overrides>) -> Overrides {
+    overrides.inner().clone()
+}
+
+/// Administrative policies of this machine
+#[tauri::command]
+fn get_policy(policy: tauri::State<'_, Policy>) -> Policy {
+    policy.inner().clone()
+}
+
+/// Profile for this session, from `--profile` or chosen earlier in the session
+#[tauri::command]
+fn get_profile(profile: tauri::State<'_, ActiveProfile>) -> ProfileState {
+    profile.get()
+}
+
+/// Switch the profile (None for the default profile). The frontend reloads afterwards.
+#[tauri::command]
+fn set_profile(
+    profile: tauri::State<'_, ActiveProfile>,
+    temp_files: tauri::State<'_, TempFiles>,
+    name: Option<String>,
+) -> Result<(), String> {
+    profile.set(name.clone())?;
+    temp_files.set_profile(name.as_deref());
+    Ok(())
+}
+
+/// Read a secret from the OS credential store, None if there is none
+#[tauri::command]
+fn get_stored_secret(account: String) -> Option<String> {
+    keychain::get(&account)
+}
+
+/// Store a secret in the OS credential store
+#[tauri::command]
+fn store_secret(app: AppHandle, account: String, secret: String) -> Result<(), String> {
+    overrides::ensure_writable(&app)?;
+    keychain::set(&account, &secret)
+}
+
+/// Remove a secret from the OS credential store
+#[tauri::command]
+fn delete_stored_secret(app: AppHandle, account: String) -> Result<(), String> {
+    overrides::ensure_writable(&app)?;
+    keychain::delete(&account)
+}
+
+/// The OS light/dark theme and accent color; changes are emitted as `theme-changed`
+#[tauri::command]
+async fn get_system_theme() -> Result<system_theme::SystemTheme, String> {
+    tauri::async_runtime::spawn_blocking(system_theme::detect)
+        .await
+        .map_err(|e| format!("Failed to read system theme: {}", e))
+}
+
+/// Version, build and platform details plus third-party license notices
+#[tauri::command]
+fn get_app_info(app: AppHandle) -> AppInfo {
+    app_info::app_info(&app)
+}
+
+/// Show the bundled help page for a topic in the help window, titled in `language` (the
+/// UI language, the system's if not given).
+/// Async because creating a window from a sync command deadlocks on Windows.
+#[tauri::command]
+async fn show_help(app: AppHandle, topic: String, language: Option<String>) -> Result<(), String> {
+    let language = language.unwrap_or_else(locale::system_locale);
+    help::show(&app, &topic, &language)
+}
+
+/// The OS locale as a BCP 47 tag, e.g. "de-DE"
+#[tauri::command]
+fn get_system_locale() -> String {
+    locale::system_locale()
+}
+
+/// The bundled UI strings for a language or locale ("de", "fr-CA"), the system's if not
+/// given, with English for missing ones
+#[tauri::command]
+fn get_translations(language: Option<String>) -> locale::Translations {
+    locale::translations(&language.unwrap_or_else(locale::system_locale))
+}
+
+/// Load translation.json from the app's config directory
+fn load_translation_config(app: &AppHandle) -> Result<Option<TranslationConfig>, String> {
+    let config_dir = overrides::config_dir(app)?;
+    translation::load(&config_dir.join(translation::CONFIG_FILE))
+}
+
+/// Report whether a translation service is configured (without its URL or key)
+#[tauri::command]
+fn get_translation_status(app: AppHandle) -> Result<TranslationStatus, String> {
+    if app.state::<Overrides>().offline {
+        return Ok(TranslationStatus::from_config(None));
+    }
+    Ok(TranslationStatus::from_config(load_translation_config(&app)?.as_ref()))
+}
+
+/// Translate text with the configured service. Results are cached for the session.
+#[tauri::command]
+async fn translate_text(
+    app: AppHandle,
+    text: String,
+    target_lang: String,
+    source_lang: Option<String>,
+) -> Result<Translation, String> {
+    overrides::ensure_online(&app)?;
+    let config = load_translation_config(&app)?
+        .ok_or_else(|| "No translation service is configured".to_string())?;
+    let key = translation::cache_key(&config, &text, &target_lang, source_lang.as_deref());
+    if let Some(cached) = app.state::<TranslationCache>().get(&key) {
+        return Ok(cached);
+    }
+
+    let proxy = load_proxy_config(&app)?;
+    let result = tauri::async_runtime::spawn_blocking(move || {
+        translation::translate(&config, &proxy, &text, &target_lang, source_lang.as_deref())
+    })
+    .await
+    .map_err(|e| format!("Translation task failed: {}", e))??;
+
+    app.state::<TranslationCache>().insert(key, result.clone());
+    Ok(result)
+}
+
+/// List the voices installed for the OS speech command
+#[tauri::command]
+async fn list_speech_voices() -> Vec<Voice> {
+    tauri::async_runtime::spawn_blocking(speech::list_voices)
+        .await
+        .unwrap_or_default()
+}
+
+/// Read text aloud with an OS voice, replacing anything currently being read
+#[tauri::command]
+async fn speak(
+    app: AppHandle,
+    speech: tauri::State<'_, Speech>,
+    text: String,
+    voice: Option<String>,
+) -> Result<(), String> {
+    speech.speak(&app, &text, voice.as_deref())
+}
+
+/// Stop reading aloud, returns false if nothing was being read
+#[tauri::command]
+fn stop_speaking(speech: tauri::State<'_, Speech>) -> bool {
+    speech.stop()
+}
+
+/// Enable or disable the local automation endpoint, returns its socket/pipe path
+#[tauri::command]
+fn set_automation_enabled(
+    app: AppHandle,
+    automation: tauri::State<'_, Automation>,
+    enabled: bool,
+) -> Result<String, String> {
+    // Scripts could read message content through the endpoint
+    if enabled {
+        overrides::ensure_not_kiosk(&app)?;
+    }
+    automation.set_enabled(&app, enabled)
+}
+
+/// Answer a request forwarded from the automation endpoint
+#[tauri::command]
+fn automation_respond(
+    automation: tauri::State<'_, Automation>,
+    request_id: u64,
+    result: Option<serde_json::Value>,
+    error: Option<String>,
+) {
+    let result = match error {
+        Some(error) => Err(error),
+        None => Ok(result.unwrap_or(serde_json::Value::Null)),
+    };
+    automation.respond(request_id, result);
+}
+
+/// Get files that were passed to the app on startup
+#[tauri::command]
+fn get_pending_files(state: tauri::State<'_, PendingFiles>) -> Vec<String> {
+    let mut pending = state.files.lock().unwrap();
+    state.taken.store(true, Ordering::SeqCst);
+    let files: Vec<String> = pending.drain(..).map(|p| p.to_string_lossy().to_string()).collect();
+    files
+}
+
+/// Get archived messages linked on startup; ids that are not in the archive are skipped
+#[tauri::command]
+fn get_pending_archive_messages(app: AppHandle) -> Vec<ArchiveMessageLink> {
+    let ids: Vec<i64> = app
+        .state::<PendingArchiveMessages>()
+        .0
+        .lock()
+        .unwrap()
+        .drain(..)
+        .collect();
+    ids.into_iter()
+        .filter_map(|id| match archive_message_link(&app, id) {
+            Ok(link) => Some(link),
+            Err(e) => {
+                log_warn!("{}", e);
+                None
+            }
+        })
+        .collect()
+}
+
+/// Recent files list of the active profile
+fn recent_files_path(app: &AppHandle) -> Result<PathBuf, String> {
+    let profile = app.state::<ActiveProfile>().get();
+    overrides::config_dir(app).map(|dir| recent_files::file_path(&dir, profile.name.as_deref()))
+}
+
+/// Recently opened files that still exist, pinned files first. They were opened by the
+/// user before, so they can be opened again.
+#[tauri::command]
+fn get_recent_files(
+    app: AppHandle,
+    recent_files: tauri::State<'_, RecentFiles>,
+) -> Result<Vec<RecentFile>, String> {
+    let files = recent_files.get(&recent_files_path(&app)?)?;
+    let access = app.state::<FileAccess>();
+    for file in &files {
+        access.grant(std::path::Path::new(&file.path));
+    }
+    Ok(files)
+}
+
+/// Record a file opened from the filesystem. Nothing is recorded in read-only mode.
+#[tauri::command]
+fn add_recent_file(
+    app: AppHandle,
+    recent_files: tauri::State<'_, RecentFiles>,
+    path: String,
+) -> Result<(), String> {
+    if app.state::<Overrides>().read_only {
+        return Ok(());
+    }
+    app.state::<FileAccess>().check(&path)?;
+    recent_files.add(&recent_files_path(&app)?, &path)?;
+    update_tray(&app);
+    update_jump_list(&app);
+    Ok(())
+}
+
+/// Pin or unpin a recent file; pinned files are kept when the list is cleared
+#[tauri::command]
+fn pin_recent_file(
+    app: AppHandle,
+    recent_files: tauri::State<'_, RecentFiles>,
+    path: String,
+    pinned: bool,
+) -> Result<(), String> {
+    overrides::ensure_writable(&app)?;
+    recent_files.pin(&recent_files_path(&app)?, &path, pinned)?;
+    update_tray(&app);
+    update_jump_list(&app);
+    Ok(())
+}
+
+/// Remove all unpinned recent files
+#[tauri::command]
+fn clear_recent_files(
+    app: AppHandle,
+    recent_files: tauri::State<'_, RecentFiles>,
+) -> Result<(), String> {
+    overrides::ensure_writable(&app)?;
+    recent_files.clear(&recent_files_path(&app)?)?;
+    update_tray(&app);
+    update_jump_list(&app);
+    Ok(())
+}
+
+/// Saved session of the active profile
+fn session_path(app: &AppHandle) -> Result<PathBuf, String> {
+    let profile = app.state::<ActiveProfile>().get();
+    overrides::config_dir(app).map(|dir| session::file_path(&dir, profile.name.as_deref()))
+}
+
+/// Record the open messages of the main window, saved when the app exits. Nothing is
+/// recorded in read-only mode. Messages of files the user did not open are left out.
+#[tauri::command]
+fn update_session(
+    app: AppHandle,
+    sessions: tauri::State<'_, SessionStore>,
+    mut session: Session,
+) -> Result<(), String> {
+    if app.state::<Overrides>().read_only {
+        return Ok(());
+    }
+    let access = app.state::<FileAccess>();
+    session.retain(|message| {
+        message.kind == "archive" || access.is_allowed(std::path::Path::new(&message.path))
+    });
+    sessions.update(session_path(&app)?, session);
+    Ok(())
+}
+
+/// The session saved when the app last exited, without the messages whose file has moved
+/// or been deleted and those no longer in the archive
+#[tauri::command]
+fn restore_session(
+    app: AppHandle,
+    sessions: tauri::State<'_, SessionStore>,
+) -> Result<RestoredSession, String> {
+    let archive = archive_path(&app).ok();
+    let restored = sessions.restore(&session_path(&app)?, |message| match message.kind.as_str() {
+        "archive" => match (&archive, message.id) {
+            (Some(path), Some(id)) => app.state::<Archive>().file_name(path, id).is_ok(),
+            _ => false,
+        },
+        _ => session::file_exists(message),
+    })?;
+    // Only files the user opened are saved in a session
+    let access = app.state::<FileAccess>();
+    for message in restored.messages.iter().filter(|message| message.kind != "archive") {
+        access.grant(std::path::Path::new(&message.path));
+    }
+    Ok(restored)
+}
+
+/// Settings file of the active profile
+fn settings_path(app: &AppHandle) -> Result<PathBuf, String> {
+    let profile = app.state::<ActiveProfile>().get();
+    overrides::config_dir(app).map(|dir| settings::file_path(&dir, profile.name.as_deref()))
+}
+
+/// Folder the save dialogs start in, from the settings
+fn default_save_directory(app: &AppHandle) -> Option<PathBuf> {
+    let path = settings_path(app).ok()?;
+    app.state::<SettingsStore>().default_save_directory(&path)
+}
+
+/// Change one setting and tell all windows with `settings-changed`
+fn store_setting(app: &AppHandle, key: String, value: serde_json::Value) -> Result<(), String> {
+    overrides::ensure_writable(app)?;
+    let value = app.state::<SettingsStore>().set(&settings_path(app)?, &key, &value)?;
+    let tray_changed = key == "trayIcon";
+    if let Err(e) = app.emit("settings-changed", SettingChange { key, value }) {
+        log_warn!("Failed to emit settings-changed event: {}", e);
+    }
+    if tray_changed {
+        update_tray(app);
+    }
+    Ok(())
+}
+
+/// Show the tray icon with the current recent files and watched folders, or remove it,
+/// as the `trayIcon` setting says
+fn update_tray(app: &AppHandle) {
+    let visible = settings_path(app)
+        .and_then(|path| app.state::<SettingsStore>().get(&path))
+        .is_ok_and(|settings| settings.tray_icon.as_deref() == Some("show"));
+    if !visible {
+        tray::hide(app);
+        return;
+    }
+
+    let recent_files = recent_files_path(app)
+        .and_then(|path| app.state::<RecentFiles>().get(&path))
+        .unwrap_or_default();
+    let watcher = app.state::<FolderWatcher>();
+    let menu = tray::TrayMenu {
+        recent_files: &recent_files,
+        has_watched_folders: !watcher.folders().is_empty(),
+        watching: !watcher.is_paused(),
+    };
+    if let Err(e) = tray::show(app, &menu, handle_tray_action) {
+        log_warn!("{}", e);
+    }
+}
+
+/// Show the recent files in the taskbar Jump List (Windows) or the Dock menu (macOS).
+/// Files the user removed from the Jump List leave the recent files list as well.
+fn update_jump_list(app: &AppHandle) {
+    let Ok(list_path) = recent_files_path(app) else {
+        return;
+    };
+    let recent_files = app.state::<RecentFiles>();
+    let files = recent_files.get(&list_path).unwrap_or_default();
+    let profile = app.state::<ActiveProfile>().get().name;
+    let title = locale::text(&locale::system_locale(), "tray.recentFiles");
+    let result = jump_list::update(app, &files, &title, profile.as_deref(), open_recent_file);
+    let removed = match result {
+        Ok(removed) => removed,
+        Err(e) => {
+            log_warn!("{}", e);
+            return;
+        }
+    };
+    if app.state::<Overrides>().read_only {
+        return;
+    }
+    for path in removed {
+        if let Err(e) = recent_files.remove(&list_path, &path) {
+            log_warn!("{}", e);
+        }
+    }
+}
+
+/// Open a file chosen from the recent files of the tray icon or the Dock menu
+fn open_recent_file(app: &AppHandle, path: PathBuf) {
+    show_main_window(app);
+    handle_file_open(app, path);
+}
+
+/// Bring the main window to the front for a clicked notification and open its target
+fn handle_notification_click(app: &AppHandle, target: notifications::Target) {
+    show_main_window(app);
+    match target {
+        notifications::Target::File(path) => {
+            let payload = path.to_string_lossy().to_string();
+            if let Err(e) = app.emit_to(MAIN_LABEL, notifications::OPEN_EVENT, payload) {
+                log_warn!("Failed to emit {} event: {}", notifications::OPEN_EVENT, e);
+            }
+        }
+        notifications::Target::Folder(path) => {
+            if let Err(e) = open_path(path.as_os_str()) {
+                log_warn!("{}", e);
+            }
+        }
+        // The update dialog is already waiting in the window
+        notifications::Target::Update => {}
+    }
+}
+
+fn handle_tray_action(app: &AppHandle, action: tray::Action) {
+    match action {
+        tray::Action::Show => show_main_window(app),
+        tray::Action::OpenFile => {
+            let language = locale::system_locale();
+            let app = app.clone();
+            app.dialog()
+                .file()
+                .add_filter(
+                    locale::text(&language, "tray.emailFiles"),
+                    &["msg", "eml", "pst", "ost", "mbox", "zip", "gz"],
+                )
+                .pick_files(move |paths| {
+                    let paths = paths.unwrap_or_default();
+                    if !paths.is_empty() {
+                        show_main_window(&app);
+                    }
+                    for path in paths.into_iter().filter_map(|path| path.into_path().ok()) {
+                        handle_file_open(&app, path);
+                    }
+                });
+        }
+        tray::Action::OpenRecent(path) => open_recent_file(app, path),
+        tray::Action::ToggleWatching => {
+            let watcher = app.state::<FolderWatcher>();
+            watcher.set_paused(!watcher.is_paused());
+            log_info!(
+                "Watched folders {}",
+                if watcher.is_paused() { "paused" } else { "resumed" }
+            );
+            update_tray(app);
+        }
+        tray::Action::Quit => app.exit(0),
+    }
+}
+
+/// Bring the main window to the front, also when it is minimized or hidden
+fn show_main_window(app: &AppHandle) {
+    if let Some(window) = app.get_webview_window(MAIN_LABEL) {
+        let _ = window.show();
+        let _ = window.unminimize();
+        let _ = window.set_focus();
+    }
+}
+
+/// Settings of the active profile
+#[tauri::command]
+fn get_settings(
+    app: AppHandle,
+    settings: tauri::State<'_, SettingsStore>,
+) -> Result<Settings, String> {
+    settings.get(&settings_path(&app)?)
+}
+
+/// Change one setting (null resets it to the default)
+#[tauri::command]
+fn set_setting(app: AppHandle, key: String, value: serde_json::Value) -> Result<(), String> {
+    store_setting(&app, key, value)
+}
+
+/// Base URL of the local proxy for remote images, None if they may not be loaded
+/// (offline mode or the BlockExternalContent policy)
+#[tauri::command]
+fn get_remote_image_proxy(
+    app: AppHandle,
+    images: tauri::State<'_, RemoteImages>,
+) -> Option<String> {
+    if app.state::<Overrides>().offline || app.state::<Policy>().block_external_content {
+        return None;
+    }
+    Some(images.base_url())
+}
+
+/// Delete the cached remote images, returns how many were removed
+#[tauri::command]
+async fn clear_remote_image_cache(app: AppHandle) -> Result<usize, String> {
+    tauri::async_runtime::spawn_blocking(move || remote_images::clear_cache(&app))
+        .await
+        .map_err(|e| format!("Failed to clear image cache: {}", e))?
+}
+
+/// Choose the folder the save dialogs start in. Returns the folder, None if cancelled.
+#[tauri::command]
+async fn pick_default_save_directory(app: AppHandle) -> Result<Option<String>, String> {
+    use tauri_plugin_dialog::FilePath;
+
+    overrides::ensure_writable(&app)?;
+
+    match app.dialog().file().blocking_pick_folder() {
+        Some(FilePath::Path(dir)) => {
+            let dir = dir.to_string_lossy().to_string();
+            store_setting(
+                &app,
+                "defaultSaveDirectory".to_string(),
+                serde_json::Value::String(dir.clone()),
+            )?;
+            Ok(Some(dir))
+        }
+        _ => Ok(None), // User cancelled
+    }
+}
+
+/// Choose a folder, e.g. one to watch. Returns the folder, None if cancelled.
+#[tauri::command]
+async fn pick_folder(app: AppHandle) -> Option<String> {
+    use tauri_plugin_dialog::FilePath;
+
+    match app.dialog().file().blocking_pick_folder() {
+        Some(FilePath::Path(dir)) => {
+            app.state::<FileAccess>().grant(&dir);
+            Some(dir.to_string_lossy().to_string())
+        }
+        _ => None,
+    }
+}
+
+/// Watched folders list of the active profile
+fn watched_folders_path(app: &AppHandle) -> Result<PathBuf, String> {
+    let profile = app.state::<ActiveProfile>().get();
+    overrides::config_dir(app).map(|dir| watch::list_path(&dir, profile.name.as_deref()))
+}
+
+/// Report new .msg/.eml files in a folder with `watched-file` events. The folder must
+/// have been chosen with pick_folder or restored with restore_watched_folders, since
+/// every new file in it is granted. It is added to the list restored after a restart,
+/// except in read-only mode.
+#[tauri::command]
+fn watch_folder(
+    app: AppHandle,
+    watcher: tauri::State<'_, FolderWatcher>,
+    path: String,
+) -> Result<(), String> {
+    let folder = app.state::<FileAccess>().check(&path)?;
+    watcher.watch(&app, &folder)?;
+    update_tray(&app);
+    if !app.state::<Overrides>().read_only {
+        let list_path = watched_folders_path(&app)?;
+        let mut folders = watch::load_list(&list_path);
+        if !folders.contains(&folder) {
+            folders.push(folder);
+            watch::save_list(&list_path, &folders)?;
+        }
+    }
+    Ok(())
+}
+
+#[tauri::command]
+fn unwatch_folder(
+    app: AppHandle,
+    watcher: tauri::State<'_, FolderWatcher>,
+    path: String,
+) -> Result<(), String> {
+    let folder = PathBuf::from(&path);
+    watcher.unwatch(&folder)?;
+    update_tray(&app);
+    if !app.state::<Overrides>().read_only {
+        let list_path = watched_folders_path(&app)?;
+        let mut folders = watch::load_list(&list_path);
+        if folders.contains(&folder) {
+            folders.retain(|watched| *watched != folder);
+            watch::save_list(&list_path, &folders)?;
+        }
+    }
+    Ok(())
+}
+
+/// Grant the folders that were watched before the app was restarted, so the frontend
+/// can watch them again, and return those that still exist. Only folders the user once
+/// chose are in the list, see watch_folder.
+#[tauri::command]
+fn restore_watched_folders(app: AppHandle) -> Result<Vec<String>, String> {
+    let folders = watch::load_list(&watched_folders_path(&app)?);
+    let access = app.state::<FileAccess>();
+    Ok(folders
+        .into_iter()
+        .filter(|folder| folder.is_dir())
+        .map(|folder| {
+            access.grant(&folder);
+            folder.to_string_lossy().to_string()
+        })
+        .collect())
+}
+
+/// Folders watched in this session
+#[tauri::command]
+fn get_watched_folders(watcher: tauri::State<'_, FolderWatcher>) -> Vec<String> {
+    watcher.folders()
+}
+
+/// The files to open for paths dropped onto the window: folders are searched for
+/// .msg/.eml files, other files are kept if the app opens them. Paths that were not
+/// dropped (see FileAccess) count as skipped.
+#[tauri::command]
+async fn expand_dropped_paths(
+    access: tauri::State<'_, FileAccess>,
+    paths: Vec<String>,
+) -> Result<DroppedFiles, String> {
+    let count = paths.len();
+    let paths: Vec<PathBuf> = paths.iter().filter_map(|path| access.check(path).ok()).collect();
+    let denied = count - paths.len();
+    let mut dropped = tauri::async_runtime::spawn_blocking(move || folder::expand_dropped(&paths))
+        .await
+        .map_err(|e| format!("Failed to read dropped files: {}", e))?;
+    dropped.skipped += denied;
+    Ok(dropped)
+}
+
+/// List the .msg/.eml files of a folder (and its subfolders if `recursive`) without
+/// reading them; the summaries are fetched with get_folder_page
+#[tauri::command]
+async fn open_folder(
+    app: AppHandle,
+    path: String,
+    recursive: bool,
+) -> Result<FolderListing, String> {
+    let path = app.state::<FileAccess>().check(&path)?;
+    tauri::async_runtime::spawn_blocking(move || app.state::<OpenFolders>().open(&path, recursive))
+        .await
+        .map_err(|e| format!("Failed to open folder: {}", e))?
+}
+
+/// Name, size, date, subject and sender of a page of files of an opened folder
+#[tauri::command]
+async fn get_folder_page(
+    app: AppHandle,
+    path: String,
+    offset: usize,
+    limit: usize,
+) -> Result<FolderPage, String> {
+    tauri::async_runtime::spawn_blocking(move || {
+        app.state::<OpenFolders>().page(std::path::Path::new(&path), offset, limit)
+    })
+    .await
+    .map_err(|e| format!("Failed to list folder: {}", e))?
+}
+
+/// Forget the file list of an opened folder
+#[tauri::command]
+fn close_folder(folders: tauri::State<'_, OpenFolders>, path: String) {
+    folders.close(std::path::Path::new(&path));
+}
+
+/// Choose an Outlook data file (.pst/.ost). Returns the path, None if cancelled.
+#[tauri::command]
+async fn pick_pst_file(app: AppHandle) -> Option<String> {
+    use tauri_plugin_dialog::FilePath;
+
+    match app
+        .dialog()
+        .file()
+        .add_filter("Outlook data file", &["pst", "ost"])
+        .blocking_pick_file()
+    {
+        Some(FilePath::Path(path)) => {
+            app.state::<FileAccess>().grant(&path);
+            Some(path.to_string_lossy().to_string())
+        }
+        _ => None,
+    }
+}
+
+/// Open a PST/OST file read-only and return its folder tree
+#[tauri::command]
+async fn open_pst_file(app: AppHandle, path: String) -> Result<PstFolder, String> {
+    let path = app.state::<FileAccess>().check(&path)?;
+    tauri::async_runtime::spawn_blocking(move || app.state::<PstFiles>().open(&path))
+        .await
+        .map_err(|e| format!("Failed to open PST file: {}", e))?
+}
+
+/// Subject, sender and date of a page of messages of a PST folder
+#[tauri::command]
+async fn get_pst_messages(
+    app: AppHandle,
+    path: String,
+    folder: u32,
+    offset: usize,
+    limit: usize,
+) -> Result<PstPage, String> {
+    tauri::async_runtime::spawn_blocking(move || {
+        app.state::<PstFiles>().messages(std::path::Path::new(&path), folder, offset, limit)
+    })
+    .await
+    .map_err(|e| format!("Failed to list PST folder: {}", e))?
+}
+
+/// A message of a PST file as .msg file bytes, so the frontend can show and save it like
+/// any other MSG file
+#[tauri::command]
+async fn read_pst_message(
+    app: AppHandle,
+    path: String,
+    id: u32,
+) -> Result<tauri::ipc::Response, String> {
+    let bytes = tauri::async_runtime::spawn_blocking(move || {
+        let message = app.state::<PstFiles>().message(std::path::Path::new(&path), id)?;
+        msg::write_message(message)
+    })
+    .await
+    .map_err(|e| format!("Failed to read PST message: {}", e))??;
+    Ok(tauri::ipc::Response::new(bytes))
+}
+
+#[tauri::command]
+fn close_pst_file(pst_files: tauri::State<'_, PstFiles>, path: String) {
+    pst_files.close(std::path::Path::new(&path));
+}
+
+/// Choose an mbox file. Thunderbird keeps its folders in files without an extension,
+/// so any file can be picked. Returns the path, None if cancelled.
+#[tauri::command]
+async fn pick_mbox_file(app: AppHandle) -> Option<String> {
+    use tauri_plugin_dialog::FilePath;
+
+    match app
+        .dialog()
+        .file()
+        .add_filter("Mailbox", &["mbox", "mbx"])
+        .add_filter("All files", &["*"])
+        .blocking_pick_file()
+    {
+        Some(FilePath::Path(path)) => {
+            app.state::<FileAccess>().grant(&path);
+            Some(path.to_string_lossy().to_string())
+        }
+        _ => None,
+    }
+}
+
+/// Split an mbox file into its messages without parsing them; the summaries are
+/// fetched with get_mbox_messages
+#[tauri::command]
+async fn open_mbox_file(app: AppHandle, path: String) -> Result<MboxListing, String> {
+    let path = app.state::<FileAccess>().check(&path)?;
+    tauri::async_runtime::spawn_blocking(move || app.state::<MboxFiles>().open(&path))
+        .await
+        .map_err(|e| format!("Failed to open mbox file: {}", e))?
+}
+
+/// Subject, sender, date and size of a page of messages of an opened mbox file
+#[tauri::command]
+async fn get_mbox_messages(
+    app: AppHandle,
+    path: String,
+    offset: usize,
+    limit: usize,
+) -> Result<MboxPage, String> {
+    tauri::async_runtime::spawn_blocking(move || {
+        app.state::<MboxFiles>().page(std::path::Path::new(&path), offset, limit)
+    })
+    .await
+    .map_err(|e| format!("Failed to list mbox file: {}", e))?
+}
+
+/// A message of an mbox file as .eml file bytes
+#[tauri::command]
+async fn read_mbox_message(
+    app: AppHandle,
+    path: String,
+    index: usize,
+) -> Result<tauri::ipc::Response, String> {
+    let bytes = tauri::async_runtime::spawn_blocking(move || {
+        app.state::<MboxFiles>().message(std::path::Path::new(&path), index)
+    })
+    .await
+    .map_err(|e| format!("Failed to read mbox message: {}", e))??;
+    Ok(tauri::ipc::Response::new(bytes))
+}
+
+/// Save messages of an mbox file as .eml files to a directory chosen in a dialog.
+/// Returns one result per message, None if the dialog was cancelled.
+#[tauri::command]
+async fn export_mbox_messages(
+    app: AppHandle,
+    path: String,
+    indices: Vec<usize>,
+) -> Result<Option<Vec<SaveResult>>, String> {
+    use tauri_plugin_dialog::FilePath;
+
+    overrides::ensure_not_kiosk(&app)?;
+
+    let mut dialog = app.dialog().file();
+    if let Some(dir) = default_save_directory(&app) {
+        dialog = dialog.set_directory(dir);
+    }
+
+    match dialog.blocking_pick_folder() {
+        Some(FilePath::Path(dir)) => tauri::async_runtime::spawn_blocking(move || {
+            app.state::<MboxFiles>()
+                .export(std::path::Path::new(&path), &indices, &dir)
+                .map(Some)
+        })
+        .await
+        .map_err(|e| format!("Failed to export messages: {}", e))?,
+        _ => Ok(None), // User cancelled
+    }
+}
+
+/// Forget the message index of an opened mbox file
+#[tauri::command]
+fn close_mbox_file(mbox_files: tauri::State<'_, MboxFiles>, path: String) {
+    mbox_files.close(std::path::Path::new(&path));
+}
+
+/// A gzip-compressed .msg or .eml file, unpacked
+#[tauri::command]
+async fn read_gzip_message(
+    access: tauri::State<'_, FileAccess>,
+    path: String,
+) -> Result<tauri::ipc::Response, String> {
+    let path = access.check(&path)?;
+    let bytes = tauri::async_runtime::spawn_blocking(move || compressed::read_gzip(&path))
+        .await
+        .map_err(|e| format!("Failed to read gzip file: {}", e))??;
+    Ok(tauri::ipc::Response::new(bytes))
+}
+
+/// The .msg and .eml files in a ZIP archive, also gzip-compressed ones
+#[tauri::command]
+async fn list_zip_messages(
+    access: tauri::State<'_, FileAccess>,
+    path: String,
+) -> Result<Vec<ZipMember>, String> {
+    let path = access.check(&path)?;
+    tauri::async_runtime::spawn_blocking(move || compressed::zip_members(&path))
+        .await
+        .map_err(|e| format!("Failed to list ZIP file: {}", e))?
+}
+
+/// A message of a ZIP archive, unpacked
+#[tauri::command]
+async fn read_zip_message(
+    access: tauri::State<'_, FileAccess>,
+    path: String,
+    index: usize,
+) -> Result<tauri::ipc::Response, String> {
+    let path = access.check(&path)?;
+    let bytes = tauri::async_runtime::spawn_blocking(move || {
+        compressed::read_zip_member(&path, index)
+    })
+    .await
+    .map_err(|e| format!("Failed to read ZIP member: {}", e))??;
+    Ok(tauri::ipc::Response::new(bytes))
+}
+
+/// The password of an IMAP account passed to connect_imap_account before
+fn imap_password(app: &AppHandle, account: &ImapAccount) -> Result<String, String> {
+    app.state::<ImapPasswords>()
+        .get(account)
+        .ok_or_else(|| format!("Not logged in to {}", account.host))
+}
+
+/// Whether the password of an IMAP account is known, from this session or remembered in
+/// the OS credential store
+#[tauri::command]
+fn has_imap_password(passwords: tauri::State<'_, ImapPasswords>, account: ImapAccount) -> bool {
+    passwords.get(&account).is_some()
+}
+
+/// Log in to an IMAP account and list its folders. Without a password the known one is
+/// used. A password that works is kept for the session, and in the OS credential store
+/// if `remember` is set.
+#[tauri::command]
+async fn connect_imap_account(
+    app: AppHandle,
+    account: ImapAccount,
+    password: Option<String>,
+    remember: bool,
+) -> Result<Vec<ImapFolder>, String> {
+    overrides::ensure_online(&app)?;
+    if remember {
+        overrides::ensure_writable(&app)?;
+    }
+    let password = match password {
+        Some(password) => password,
+        None => imap_password(&app, &account)?,
+    };
+    let proxy = load_proxy_config(&app)?;
+    let folders = {
+        let (account, password) = (account.clone(), password.clone());
+        tauri::async_runtime::spawn_blocking(move || {
+            imap_client::folders(&account, &password, &proxy)
+        })
+        .await
+        .map_err(|e| format!("Failed to connect: {}", e))??
+    };
+    app.state::<ImapPasswords>().set(&account, &password, remember)?;
+    log_info!("Connected to IMAP server {}", account.host);
+    Ok(folders)
+}
+
+/// Subject, sender, date and size of a page of messages of an IMAP folder, newest first
+#[tauri::command]
+async fn get_imap_messages(
+    app: AppHandle,
+    account: ImapAccount,
+    folder: String,
+    offset: usize,
+    limit: usize,
+) -> Result<ImapPage, String> {
+    overrides::ensure_online(&app)?;
+    let password = imap_password(&app, &account)?;
+    let proxy = load_proxy_config(&app)?;
+    tauri::async_runtime::spawn_blocking(move || {
+        imap_client::page(&account, &password, &proxy, &folder, offset, limit)
+    })
+    .await
+    .map_err(|e| format!("Failed to list IMAP folder: {}", e))?
+}
+
+/// A message of an IMAP folder as .eml file bytes; it stays unread on the server
+#[tauri::command]
+async fn read_imap_message(
+    app: AppHandle,
+    account: ImapAccount,
+    folder: String,
+    uid: u32,
+) -> Result<tauri::ipc::Response, String> {
+    overrides::ensure_online(&app)?;
+    let password = imap_password(&app, &account)?;
+    let proxy = load_proxy_config(&app)?;
+    let bytes = tauri::async_runtime::spawn_blocking(move || {
+        imap_client::message(&account, &password, &proxy, &folder, uid)
+    })
+    .await
+    .map_err(|e| format!("Failed to read IMAP message: {}", e))??;
+    Ok(tauri::ipc::Response::new(bytes))
+}
+
+/// Forget the password of an IMAP account, also in the OS credential store
+#[tauri::command]
+fn forget_imap_password(app: AppHandle, account: ImapAccount) -> Result<(), String> {
+    overrides::ensure_writable(&app)?;
+    app.state::<ImapPasswords>().forget(&account)
+}
+
+/// Message archive of the active profile
+fn archive_path(app: &AppHandle) -> Result<PathBuf, String> {
+    let profile = app.state::<ActiveProfile>().get();
+    overrides::local_data_dir(app).map(|dir| archive::file_path(&dir, profile.name.as_deref()))
+}
+
+/// Store a message (base64 .eml or .msg file) in the archive, in a folder (`""` for none)
+#[tauri::command]
+async fn import_to_archive(
+    app: AppHandle,
+    data: String,
+    file_name: String,
+    folder: String,
+) -> Result<ArchiveImport, String> {
+    use base64::{engine::general_purpose::STANDARD, Engine as _};
+
+    overrides::ensure_writable(&app)?;
+    let path = archive_path(&app)?;
+    tauri::async_runtime::spawn_blocking(move || {
+        let data = STANDARD
+            .decode(data)
+            .map_err(|e| format!("Failed to decode base64: {}", e))?;
+        app.state::<Archive>().import(&path, &data, &file_name, &folder)
+    })
+    .await
+    .map_err(|e| format!("Failed to archive message: {}", e))?
+}
+
+/// A page of archived messages matching a query, newest first
+#[tauri::command]
+async fn get_archive_messages(
+    app: AppHandle,
+    query: ArchiveQuery,
+    offset: usize,
+    limit: usize,
+) -> Result<ArchivePage, String> {
+    let path = archive_path(&app)?;
+    tauri::async_runtime::spawn_blocking(move || {
+        app.state::<Archive>().page(&path, &query, offset, limit)
+    })
+    .await
+    .map_err(|e| format!("Failed to list archive: {}", e))?
+}
+
+/// Folders and tags in use in the archive, for filtering
+#[tauri::command]
+async fn get_archive_labels(app: AppHandle) -> Result<ArchiveLabels, String> {
+    let path = archive_path(&app)?;
+    tauri::async_runtime::spawn_blocking(move || app.state::<Archive>().labels(&path))
+        .await
+        .map_err(|e| format!("Failed to list archive: {}", e))?
+}
+
+/// Replace the tags of archived messages
+#[tauri::command]
+fn set_archive_tags(app: AppHandle, ids: Vec<i64>, tags: Vec<String>) -> Result<(), String> {
+    overrides::ensure_writable(&app)?;
+    app.state::<Archive>().set_tags(&archive_path(&app)?, &ids, &tags)
+}
+
+/// Move archived messages to a folder, `""` for none
+#[tauri::command]
+fn move_archive_messages(app: AppHandle, ids: Vec<i64>, folder: String) -> Result<(), String> {
+    overrides::ensure_writable(&app)?;
+    app.state::<Archive>().move_to_folder(&archive_path(&app)?, &ids, &folder)
+}
+
+/// Remove messages from the archive; returns how many were removed
+#[tauri::command]
+fn delete_archive_messages(app: AppHandle, ids: Vec<i64>) -> Result<usize, String> {
+    overrides::ensure_writable(&app)?;
+    app.state::<Archive>().delete(&archive_path(&app)?, &ids)
+}
+
+/// The original file of an archived message
+#[tauri::command]
+async fn read_archive_message(app: AppHandle, id: i64) -> Result<tauri::ipc::Response, String> {
+    let path = archive_path(&app)?;
+    let (_, bytes) = tauri::async_runtime::spawn_blocking(move || {
+        app.state::<Archive>().message(&path, id)
+    })
+    .await
+    .map_err(|e| format!("Failed to read archived message: {}", e))??;
+    Ok(tauri::ipc::Response::new(bytes))
+}
+
+/// Save the original files of archived messages to a directory chosen in a dialog.
+/// Returns one result per message, None if the dialog was cancelled.
+#[tauri::command]
+async fn export_archive_messages(
+    app: AppHandle,
+    ids: Vec<i64>,
+) -> Result<Option<Vec<SaveResult>>, String> {
+    use tauri_plugin_dialog::FilePath;
+
+    overrides::ensure_not_kiosk(&app)?;
+    let path = archive_path(&app)?;
+
+    let mut dialog = app.dialog().file();
+    if let Some(dir) = default_save_directory(&app) {
+        dialog = dialog.set_directory(dir);
+    }
+
+    match dialog.blocking_pick_folder() {
+        Some(FilePath::Path(dir)) => tauri::async_runtime::spawn_blocking(move || {
+            app.state::<Archive>().export(&path, &ids, &dir).map(Some)
+        })
+        .await
+        .map_err(|e| format!("Failed to export messages: {}", e))?,
+        _ => Ok(None), // User cancelled
+    }
+}
+
+/// The .msg/.eml file of an opened message by where the frontend opened it from: a file
+/// path, `archive#<id>`, or `<file>#<id>` for messages of an open PST or mbox file
+fn read_message_source(app: &AppHandle, source: &str) -> Result<Vec<u8>, String> {
+    let path = std::path::Path::new(source);
+    if path.is_file() {
+        let path = app.state::<FileAccess>().check(source)?;
+        return std::fs::read(path).map_err(|e| format!("Failed to read {}: {}", source, e));
+    }
+    let invalid = || format!("Unknown message source: {}", source);
+    let (file, id) = source.rsplit_once('#').ok_or_else(invalid)?;
+    if file == "archive" {
+        let id = id.parse().map_err(|_| invalid())?;
+        let (_, bytes) = app.state::<Archive>().message(&archive_path(app)?, id)?;
+        return Ok(bytes);
+    }
+    if let Some((file, id)) = pst_message_source(source) {
+        let id = id.parse().map_err(|_| invalid())?;
+        msg::write_message(app.state::<PstFiles>().message(file, id)?)
+    } else {
+        let index = id.parse().map_err(|_| invalid())?;
+        app.state::<MboxFiles>().message(std::path::Path::new(file), index)
+    }
+}
+
+/// The .pst/.ost file and the message id of a `<file>#<id>` message source
+fn pst_message_source(source: &str) -> Option<(&std::path::Path, &str)> {
+    let (file, id) = source.rsplit_once('#')?;
+    let file = std::path::Path::new(file);
+    let is_pst = file
+        .extension()
+        .and_then(|e| e.to_str())
+        .is_some_and(|e| e.eq_ignore_ascii_case("pst") || e.eq_ignore_ascii_case("ost"));
+    is_pst.then_some((file, id))
+}
+
+/// Like read_message_source, with the status of PST messages, which their .msg copy
+/// would lose; they are returned as .eml. Files keep their status themselves.
+fn read_message_with_status(
+    app: &AppHandle,
+    source: &str,
+) -> Result<(Vec<u8>, Option<MessageStatus>), String> {
+    if !std::path::Path::new(source).is_file() {
+        if let Some((file, id)) = pst_message_source(source) {
+            let id = id.parse().map_err(|_| format!("Unknown message source: {}", source))?;
+            let (message, status) = app.state::<PstFiles>().message_with_status(file, id)?;
+            return Ok((eml::write(&message), Some(status)));
+        }
+    }
+    read_message_source(app, source).map(|data| (data, None))
+}
+
+/// Write opened messages into one ZIP with a manifest, as their original files or
+/// converted to .eml or PDF. `scope` and `checksums` are recorded in the manifest as by
+/// the frontend's ZIP export. Without a path a save dialog asks for one. Returns None
+/// if the dialog was cancelled.
+#[tauri::command]
+async fn export_messages_zip(
+    app: AppHandle,
+    items: Vec<ZipItem>,
+    format: String,
+    scope: String,
+    checksums: bool,
+    path: Option<String>,
+) -> Result<Option<ZipExport>, String> {
+    use tauri_plugin_dialog::FilePath;
+
+    overrides::ensure_not_kiosk(&app)?;
+    if !zip_export::FORMATS.contains(&format.as_str()) {
+        return Err(format!("Unknown export format: {}", format));
+    }
+
+    let path = match path {
+        Some(path) => app.state::<FileAccess>().check_target(&path)?,
+        None => {
+            let mut dialog = app
+                .dialog()
+                .file()
+                .set_file_name(format!("messages-{}.zip", format))
+                .add_filter("ZIP", &["zip"]);
+            if let Some(dir) = default_save_directory(&app) {
+                dialog = dialog.set_directory(dir);
+            }
+            match dialog.blocking_save_file() {
+                Some(FilePath::Path(path)) => path,
+                _ => return Ok(None), // User cancelled
+            }
+        }
+    };
+
+    tauri::async_runtime::spawn_blocking(move || {
+        let options = ZipOptions {
+            format: &format,
+            scope: &scope,
+            checksums,
+        };
+        let export = zip_export::export(&items, &options, &path, |source| {
+            read_message_source(&app, source)
+        })?;
+        log_info!(
+            "Exported {} messages to {} ({} skipped)",
+            export.exported_count,
+            export.path,
+            export.skipped.len()
+        );
+        Ok(Some(export))
+    })
+    .await
+    .map_err(|e| format!("Failed to export messages: {}", e))?
+}
+
+/// Write opened messages into a Maildir folder chosen in a dialog, e.g. to serve them
+/// with Dovecot; see maildir::export. Returns None if the dialog was cancelled.
+#[tauri::command]
+async fn export_messages_maildir(
+    app: AppHandle,
+    items: Vec<MaildirItem>,
+) -> Result<Option<MaildirExport>, String> {
+    use tauri_plugin_dialog::FilePath;
+
+    overrides::ensure_not_kiosk(&app)?;
+
+    let mut dialog = app.dialog().file();
+    if let Some(dir) = default_save_directory(&app) {
+        dialog = dialog.set_directory(dir);
+    }
+    let destination = match dialog.blocking_pick_folder() {
+        Some(FilePath::Path(dir)) => dir,
+        _ => return Ok(None), // User cancelled
+    };
+
+    tauri::async_runtime::spawn_blocking(move || {
+        let export = maildir::export(&items, &destination, |source| {
+            read_message_with_status(&app, source)
+        })?;
+        log_info!(
+            "Exported {} messages to Maildir {} ({} skipped)",
+            export.exported_count,
+            export.path,
+            export.skipped.len()
+        );
+        Ok(Some(export))
+    })
+    .await
+    .map_err(|e| format!("Failed to export messages: {}", e))?
+}
+
+/// Write a folder of an open PST file with its subfolders into a Maildir++ folder chosen
+/// in a dialog, see maildir::export_pst. Returns None if the dialog was cancelled.
+#[tauri::command]
+async fn export_pst_maildir(
+    app: AppHandle,
+    path: String,
+    folder: u32,
+) -> Result<Option<MaildirExport>, String> {
+    use tauri_plugin_dialog::FilePath;
+
+    overrides::ensure_not_kiosk(&app)?;
+
+    let mut dialog = app.dialog().file();
+    if let Some(dir) = default_save_directory(&app) {
+        dialog = dialog.set_directory(dir);
+    }
+    let destination = match dialog.blocking_pick_folder() {
+        Some(FilePath::Path(dir)) => dir,
+        _ => return Ok(None), // User cancelled
+    };
+
+    tauri::async_runtime::spawn_blocking(move || {
+        let pst_files = app.state::<PstFiles>();
+        let export =
+            maildir::export_pst(&pst_files, std::path::Path::new(&path), folder, &destination)?;
+        log_info!(
+            "Exported {} messages in {} folders of {} to Maildir {} ({} skipped)",
+            export.exported_count,
+            export.folder_count,
+            path,
+            export.path,
+            export.skipped.len()
+        );
+        Ok(Some(export))
+    })
+    .await
+    .map_err(|e| format!("Failed to export PST folder: {}", e))?
+}
+
+/// Group opened messages into conversations, newest conversation first
+#[tauri::command]
+async fn get_threads(messages: Vec<ThreadInput>) -> Result<Vec<Thread>, String> {
+    tauri::async_runtime::spawn_blocking(move || threads::threads(messages))
+        .await
+        .map_err(|e| format!("Failed to group conversations: {}", e))
+}
+
+/// Groups of opened messages that are copies of each other
+#[tauri::command]
+async fn find_duplicates(messages: Vec<DuplicateInput>) -> Result<Vec<DuplicateGroup>, String> {
+    tauri::async_runtime::spawn_blocking(move || duplicates::find(messages))
+        .await
+        .map_err(|e| format!("Failed to find duplicates: {}", e))
+}
+
+/// Search the opened messages for plain text or a regular expression, see search::search
+#[tauri::command]
+async fn search_open_messages(
+    messages: Vec<SearchInput>,
+    pattern: String,
+    options: SearchOptions,
+) -> Result<Vec<SearchHit>, String> {
+    tauri::async_runtime::spawn_blocking(move || search::search(&messages, &pattern, &options))
+        .await
+        .map_err(|e| format!("Failed to search messages: {}", e))?
+}
+
+/// Compare two messages exported by the frontend (messageToJson), e.g. an original and its
+/// journaled or forwarded copy, see compare::compare
+#[tauri::command]
+async fn compare_messages(message_a: String, message_b: String) -> Result<MessageDiff, String> {
+    tauri::async_runtime::spawn_blocking(move || {
+        let a = msg::parse_exported(&message_a)?;
+        let b = msg::parse_exported(&message_b)?;
+        compare::compare(&a, &b)
+    })
+    .await
+    .map_err(|e| format!("Comparison failed: {}", e))?
+}
+
+/// Convert the .msg/.eml files of a folder to `eml`, `pdf`, `json` or `text` in the
+/// background, reporting each file with `conversion-progress` and the summary with
+/// `conversion-finished` events. Folders not given are chosen in dialogs. Returns the
+/// started job, None if a dialog was cancelled.
+#[tauri::command]
+async fn start_batch_conversion(
+    app: AppHandle,
+    jobs: tauri::State<'_, BatchJobs>,
+    folder: Option<String>,
+    output: Option<String>,
+    format: String,
+    recursive: bool,
+) -> Result<Option<ConversionJob>, String> {
+    use tauri_plugin_dialog::FilePath;
+
+    overrides::ensure_not_kiosk(&app)?;
+    if batch::extension(&format).is_none() {
+        return Err(format!("Unknown conversion format: {}", format));
+    }
+
+    let pick = |title: &str, dir: Option<PathBuf>| {
+        let mut dialog = app.dialog().file().set_title(title);
+        if let Some(dir) = dir {
+            dialog = dialog.set_directory(dir);
+        }
+        match dialog.blocking_pick_folder() {
+            Some(FilePath::Path(dir)) => Some(dir),
+            _ => None,
+        }
+    };
+    let folder = match folder {
+        Some(folder) => app.state::<FileAccess>().check(&folder)?,
+        None => match pick("Folder to convert", None) {
+            Some(folder) => folder,
+            None => return Ok(None), // User cancelled
+        },
+    };
+    let output = match output {
+        Some(output) => app.state::<FileAccess>().check(&output)?,
+        None => match pick("Save converted files to", default_save_directory(&app)) {
+            Some(output) => output,
+            None => return Ok(None), // User cancelled
+        },
+    };
+
+    jobs.start(&app, &folder, &output, &format, recursive).map(Some)
+}
+
+/// Stop a batch conversion after the files being converted; the files done so far are
+/// kept. Returns false if the job is not running.
+#[tauri::command]
+fn cancel_batch_conversion(jobs: tauri::State<'_, BatchJobs>, job_id: u64) -> bool {
+    jobs.cancel(job_id)
+}
+
+/// Choose a PKCS#12 certificate file (.pfx/.p12). Returns the path, None if cancelled.
+#[tauri::command]
+async fn pick_certificate_file(app: AppHandle) -> Option<String> {
+    use tauri_plugin_dialog::FilePath;
+
+    match app
+        .dialog()
+        .file()
+        .add_filter("Certificate with private key", &["pfx", "p12"])
+        .blocking_pick_file()
+    {
+        Some(FilePath::Path(path)) => {
+            app.state::<FileAccess>().grant(&path);
+            Some(path.to_string_lossy().to_string())
+        }
+        _ => None,
+    }
+}
+
+/// Whether S/MIME messages can be decrypted with the OS certificate store
+#[tauri::command]
+fn smime_keystore_available() -> bool {
+    smime::keystore_available()
+}
+
+/// Decrypt an S/MIME message (the base64 smime.p7m) with a PKCS#12 file or, without
+/// `certificate_path`, with the OS certificate store. Returns the decrypted MIME entity.
+#[tauri::command]
+async fn decrypt_smime(
+    access: tauri::State<'_, FileAccess>,
+    data: String,
+    certificate_path: Option<String>,
+    password: Option<String>,
+) -> Result<tauri::ipc::Response, String> {
+    use base64::{engine::general_purpose::STANDARD, Engine as _};
+
+    let certificate_path = certificate_path.map(|path| access.check(&path)).transpose()?;
+
+    let bytes = tauri::async_runtime::spawn_blocking(move || {
+        let p7m = STANDARD
+            .decode(data)
+            .map_err(|e| format!("Failed to decode base64: {}", e))?;
+        match certificate_path {
+            Some(path) => {
+                let pfx = std::fs::read(&path)
+                    .map_err(|e| format!("Failed to read certificate file: {}", e))?;
+                smime::decrypt_with_pkcs12(&p7m, &pfx, password.as_deref().unwrap_or(""))
+            }
+            None => smime::decrypt_with_keystore(&p7m),
+        }
+    })
+    .await
+    .map_err(|e| format!("Failed to decrypt message: {}", e))??;
+    Ok(tauri::ipc::Response::new(bytes))
+}
+
+/// Check DKIM, SPF and DMARC of a message (base64 .eml or .msg file). DKIM keys and
+/// DMARC policies are looked up in DNS, except in offline mode.
+#[tauri::command]
+async fn analyze_authentication(
+    app: AppHandle,
+    data: String,
+) -> Result<AuthenticationReport, String> {
+    use base64::{engine::general_purpose::STANDARD, Engine as _};
+
+    let offline = app.state::<Overrides>().offline;
+    tauri::async_runtime::spawn_blocking(move || {
+        let message = STANDARD
+            .decode(data)
+            .map_err(|e| format!("Failed to decode base64: {}", e))?;
+        authentication::analyze(&message, offline)
+    })
+    .await
+    .map_err(|e| format!("Failed to check authentication: {}", e))?
+}
+
+/// Check the links and Reply-To of a message (base64 .eml or .msg file) for signs of
+/// phishing
+#[tauri::command]
+async fn analyze_phishing(data: String) -> Result<PhishingReport, String> {
+    use base64::{engine::general_purpose::STANDARD, Engine as _};
+
+    tauri::async_runtime::spawn_blocking(move || {
+        let message = STANDARD
+            .decode(data)
+            .map_err(|e| format!("Failed to decode base64: {}", e))?;
+        phishing::analyze(&message)
+    })
+    .await
+    .map_err(|e| format!("Failed to check links: {}", e))?
+}
+
+/// Hops of the Received headers of a message (base64 .eml or .msg file) with delays
+/// and anomalies
+#[tauri::command]
+async fn analyze_delivery_path(data: String) -> Result<DeliveryPath, String> {
+    use base64::{engine::general_purpose::STANDARD, Engine as _};
+
+    tauri::async_runtime::spawn_blocking(move || {
+        let message = STANDARD
+            .decode(data)
+            .map_err(|e| format!("Failed to decode base64: {}", e))?;
+        delivery::analyze(&message)
+    })
+    .await
+    .map_err(|e| format!("Failed to analyze delivery path: {}", e))?
+}
+
+/// Downscaled copy of an image attachment (base64) that fits a `size` x `size` square.
+/// Thumbnails are cached, so a message opened again shows them at once.
+#[tauri::command]
+async fn generate_thumbnail(app: AppHandle, data: String, size: u32) -> Result<Thumbnail, String> {
+    use base64::{engine::general_purpose::STANDARD, Engine as _};
+
+    tauri::async_runtime::spawn_blocking(move || {
+        let image = STANDARD
+            .decode(data)
+            .map_err(|e| format!("Failed to decode base64: {}", e))?;
+        let cache = app.state::<ThumbnailCache>();
+        let key = thumbnails::cache_key(&image, size);
+        if let Some(cached) = cache.get(&key) {
+            return Ok(cached);
+        }
+
+        let thumbnail = thumbnails::generate(&image, size)?;
+        cache.insert(key, thumbnail.clone());
+        Ok(thumbnail)
+    })
+    .await
+    .map_err(|e| format!("Failed to generate thumbnail: {}", e))?
+}
+
+/// Meeting request, response or cancellation of a message (base64 .eml or .msg file),
+/// None for other messages
+#[tauri::command]
+async fn parse_meeting(data: String) -> Result<Option<Meeting>, String> {
+    use base64::{engine::general_purpose::STANDARD, Engine as _};
+
+    tauri::async_runtime::spawn_blocking(move || {
+        let message = STANDARD
+            .decode(data)
+            .map_err(|e| format!("Failed to decode base64: {}", e))?;
+        calendar::analyze(&message)
+    })
+    .await
+    .map_err(|e| format!("Failed to read meeting: {}", e))?
+}
+
+/// Save the meeting of a message (base64 .eml or .msg file) as an .ics file, to `path`
+/// or, without one, to a file chosen in a save dialog. Returns the path, None if cancelled.
+#[tauri::command]
+async fn export_to_ics(
+    app: AppHandle,
+    data: String,
+    path: Option<String>,
+) -> Result<Option<String>, String> {
+    use base64::{engine::general_purpose::STANDARD, Engine as _};
+    use tauri_plugin_dialog::FilePath;
+
+    overrides::ensure_not_kiosk(&app)?;
+
+    let meeting = tauri::async_runtime::spawn_blocking(move || {
+        let message = STANDARD
+            .decode(data)
+            .map_err(|e| format!("Failed to decode base64: {}", e))?;
+        calendar::analyze(&message)?.ok_or_else(|| "The message is not a meeting".to_string())
+    })
+    .await
+    .map_err(|e| format!("Failed to read meeting: {}", e))??;
+
+    let path = match path {
+        Some(path) => app.state::<FileAccess>().check_target(&path)?,
+        None => {
+            let name = if meeting.summary.trim().is_empty() {
+                "meeting".to_string()
+            } else {
+                attachments::safe_file_name(&meeting.summary)
+            };
+            let mut dialog = app
+                .dialog()
+                .file()
+                .set_file_name(format!("{}.ics", name))
+                .add_filter("Calendar", &["ics"]);
+            if let Some(dir) = default_save_directory(&app) {
+                dialog = dialog.set_directory(dir);
+            }
+            match dialog.blocking_save_file() {
+                Some(FilePath::Path(path)) => path,
+                _ => return Ok(None), // User cancelled
+            }
+        }
+    };
+
+    std::fs::write(&path, calendar::ics(&meeting))
+        .map_err(|e| format!("Failed to write {}: {}", path.display(), e))?;
+    Ok(Some(path.to_string_lossy().to_string()))
+}
+
+/// Contact of an .msg file (base64, message class IPM.Contact), None for other messages
+#[tauri::command]
+async fn parse_contact(data: String) -> Result<Option<Contact>, String> {
+    use base64::{engine::general_purpose::STANDARD, Engine as _};
+
+    tauri::async_runtime::spawn_blocking(move || {
+        let message = STANDARD
+            .decode(data)
+            .map_err(|e| format!("Failed to decode base64: {}", e))?;
+        contact::analyze(&message)
+    })
+    .await
+    .map_err(|e| format!("Failed to read contact: {}", e))?
+}
+
+/// Save the contact of an .msg file (base64) as a vCard 4.0 .vcf file, to `path` or,
+/// without one, to a file chosen in a save dialog. Returns the path, None if cancelled.
+#[tauri::command]
+async fn export_to_vcf(
+    app: AppHandle,
+    data: String,
+    path: Option<String>,
+) -> Result<Option<String>, String> {
+    use base64::{engine::general_purpose::STANDARD, Engine as _};
+    use tauri_plugin_dialog::FilePath;
+
+    overrides::ensure_not_kiosk(&app)?;
+
+    let contact = tauri::async_runtime::spawn_blocking(move || {
+        let message = STANDARD
+            .decode(data)
+            .map_err(|e| format!("Failed to decode base64: {}", e))?;
+        contact::analyze(&message)?.ok_or_else(|| "The message is not a contact".to_string())
+    })
+    .await
+    .map_err(|e| format!("Failed to read contact: {}", e))??;
+
+    let path = match path {
+        Some(path) => app.state::<FileAccess>().check_target(&path)?,
+        None => {
+            let name = if contact.display_name.trim().is_empty() {
+                "contact".to_string()
+            } else {
+                attachments::safe_file_name(&contact.display_name)
+            };
+            let mut dialog = app
+                .dialog()
+                .file()
+                .set_file_name(format!("{}.vcf", name))
+                .add_filter("vCard", &["vcf"]);
+            if let Some(dir) = default_save_directory(&app) {
+                dialog = dialog.set_directory(dir);
+            }
+            match dialog.blocking_save_file() {
+                Some(FilePath::Path(path)) => path,
+                _ => return Ok(None), // User cancelled
+            }
+        }
+    };
+
+    std::fs::write(&path, contact::vcard(&contact))
+        .map_err(|e| format!("Failed to write {}: {}", path.display(), e))?;
+    Ok(Some(path.to_string_lossy().to_string()))
+}
+
+/// Every MAPI property of an .msg file (base64) with its tag, type, named property and
+/// decoded value, also of its recipients, attachments and attached messages
+#[tauri::command]
+async fn get_mapi_properties(data: String) -> Result<MapiObject, String> {
+    use base64::{engine::general_purpose::STANDARD, Engine as _};
+
+    tauri::async_runtime::spawn_blocking(move || {
+        let message = STANDARD
+            .decode(data)
+            .map_err(|e| format!("Failed to decode base64: {}", e))?;
+        mapi::properties(&message)
+    })
+    .await
+    .map_err(|e| format!("Failed to read properties: {}", e))?
+}
+
+/// Print a rendered message: `html` (a complete document) through the OS print dialog,
+/// or `text` straight to `options.printer` without a dialog
+#[tauri::command]
+async fn print_message(
+    app: AppHandle,
+    temp_files: tauri::State<'_, TempFiles>,
+    html: String,
+    text: String,
+    options: PrintOptions,
+) -> Result<(), String> {
+    overrides::ensure_not_kiosk(&app)?;
+
+    if let Some(printer) = options.printer.clone().filter(|p| !p.trim().is_empty()) {
+        return tauri::async_runtime::spawn_blocking(move || {
+            print::print_silently(&text, &printer, &options)
+        })
+        .await
+        .map_err(|e| format!("Printing failed: {}", e))?;
+    }
+
+    // The print window loads the document from a file, so it gets no access to the app
+    let path = temp_files.write("print.html", print::document(&html, &options).as_bytes())?;
+    let title = if options.file_name.is_empty() {
+        "Message"
+    } else {
+        &options.file_name
+    };
+    print::open_dialog(&app, &path, title)
+}
+
+/// Names of the installed printers for printing without a dialog
+#[tauri::command]
+async fn list_printers() -> Result<Vec<String>, String> {
+    tauri::async_runtime::spawn_blocking(print::list_printers)
+        .await
+        .map_err(|e| format!("Failed to list printers: {}", e))
+}
+
+/// Open a message in a window of its own, e.g. to compare it with the message in the main
+/// window; returns the window label. Async because creating a window from a synchronous
+/// command deadlocks on Windows.
+#[tauri::command]
+async fn open_message_in_new_window(
+    app: AppHandle,
+    message: WindowMessage,
+) -> Result<String, String> {
+    // The WebView data (settings, profile storage) moves along with --data-dir
+    let data_dir = app.state::<Overrides>().data_dir.as_ref().map(|dir| dir.join("webview"));
+    app.state::<MessageWindows>().open(&app, message, data_dir)
+}
+
+/// The message a message window was opened with; None for the main window and once the
+/// message has been taken
+#[tauri::command]
+fn take_window_message(
+    window: tauri::WebviewWindow,
+    windows: tauri::State<'_, MessageWindows>,
+) -> Option<WindowMessage> {
+    windows.take(window.label())
+}
+
+/// Keep a file the app was launched with until the frontend asks for it
+fn queue_pending_file(app: &AppHandle, path: PathBuf) {
+    let ext = path
+        .extension()
+        .and_then(|e| e.to_str())
+        .map(|e| e.to_lowercase());
+
+    if matches!(ext.as_deref(), Some("msg" | "eml" | "pst" | "ost" | "mbox" | "zip" | "gz")) {
+        app.state::<FileAccess>().grant(&path);
+        app.state::<PendingFiles>().files.lock().unwrap().push(path);
+    }
+}
+
+/// An archived message with the name of its file, which the frontend shows
+fn archive_message_link(app: &AppHandle, id: i64) -> Result<ArchiveMessageLink, String> {
+    let file_name = app.state::<Archive>().file_name(&archive_path(app)?, id)?;
+    Ok(ArchiveMessageLink { id, file_name })
+}
+
+/// Ask the user before opening a file a link names, since any web page can send a link
+/// and opening the file grants the frontend access to it. The file is opened like a
+/// double-clicked one once confirmed, or kept until the frontend asks for it if it has
+/// not yet.
+fn confirm_linked_file(app: &AppHandle, path: PathBuf) {
+    use tauri_plugin_dialog::{MessageDialogButtons, MessageDialogKind};
+
+    let language = locale::system_locale();
+    let body =
+        locale::text(&language, "link.openFileBody").replace("{path}", &path.display().to_string());
+    let handle = app.clone();
+    app.dialog()
+        .message(body)
+        .title(locale::text(&language, "link.openFile"))
+        .kind(MessageDialogKind::Warning)
+        .buttons(MessageDialogButtons::OkCancelCustom(
+            locale::text(&language, "link.open"),
+            locale::text(&language, "link.cancel"),
+        ))
+        .show(move |confirmed| {
+            if !confirmed {
+                log_info!("Did not open linked file {:?}", path);
+            } else if handle.state::<PendingFiles>().taken.load(Ordering::SeqCst) {
+                handle_file_open(&handle, path);
+            } else {
+                queue_pending_file(&handle, path);
+            }
+        });
+}
+
+/// Open a `msgreader://` link: files like a double-clicked file after the user confirms
+/// them, archived messages by their id. While the app is starting, archived messages are
+/// kept until the frontend asks for them.
+fn handle_deep_link(app: &AppHandle, link: &str, starting: bool) {
+    log_debug!("Opening link {}", link);
+    match deep_link::parse(link) {
+        Ok(Target::File(path)) => confirm_linked_file(app, path),
+        Ok(Target::ArchiveMessage(id)) if starting => {
+            app.state::<PendingArchiveMessages>().0.lock().unwrap().push(id);
+        }
+        Ok(Target::ArchiveMessage(id)) => match archive_message_link(app, id) {
+            Ok(link) => {
+                if let Err(e) = app.emit_to(MAIN_LABEL, "archive-message-open", link) {
+                    log_warn!("Failed to emit archive-message-open event: {}", e);
+                }
+            }
+            Err(e) => log_warn!("{}", e),
+        },
+        Err(e) => log_warn!("{}", e),
+    }
+}
+
+/// Handle a file being opened - emit event to frontend
+fn handle_file_open(app: &AppHandle, path: PathBuf) {
+    // Check the content, or the extension if the content is not recognized
+    match file_type::open_type(&path) {
+        Some(kind) => {
+            log_debug!("Opening {:?} as {}", path, kind);
+            app.state::<FileAccess>().grant(&path);
+            // Emit event to frontend
+            let payload = path.to_string_lossy().to_string();
+            if let Err(e) = app.emit_to(MAIN_LABEL, "file-open", payload) {
+                log_warn!("Failed to emit file-open event: {}", e);
+            }
+        }
+        None => {
+            log_warn!("Unsupported file type: {:?}", path);
+        }
+    }
+}
+
+#[cfg_attr(mobile, tauri::mobile_entry_point)]
+pub fn run() {
+    let args: Vec<String> = std::env::args().collect();
+    let policy = Policy::load();
+    // Subcommands like `msgreader convert`, invalid options and `--no-gui` exit here
+    let startup = match cli::run(&args, &policy) {
+        Ok(startup) => startup,
+        Err(code) => std::process::exit(code),
+    };
+    let mut overrides = Overrides::from_env_and_args(&startup);
+    if policy.data_dir.is_some() {
+        overrides.data_dir = policy.data_dir.clone();
+    }
+    overrides.kiosk |= policy.kiosk_mode;
+    if let Some(level) = &overrides.log_level {
+        let _ = logging::logger().set_level(level);
+    }
+    let active_profile = ActiveProfile::from_args(&startup);
+    let temp_files = TempFiles::new(active_profile.get().name.as_deref());
+
+    let mut builder = tauri::Builder::default().plugin(tauri_plugin_fs::init());
+    // Without the updater plugin no updates can be checked for or installed
+    if !policy.disable_auto_update {
+        builder = builder.plugin(tauri_plugin_updater::Builder::new().build());
+    }
+    let builder = builder
+        .plugin(tauri_plugin_dialog::init())
+        .plugin(tauri_plugin_process::init())
+        .plugin(tauri_plugin_deep_link::init())
+        .plugin(tauri_plugin_single_instance::init(|app, args, cwd| {
+            // Handle files opened when app is already running (Windows/Linux), e.g. several
+            // files double-clicked at once, each launch forwards its files to this instance.
+            // The running instance keeps its profile and overrides, options are ignored here.
+            // Relative paths are resolved against the working directory of the new launch,
+            // which has checked its arguments before it got here.
+            let files = args::parse(&args, std::path::Path::new(&cwd))
+                .map(|forwarded| forwarded.files)
+                .unwrap_or_default();
+            for arg in &files {
+                if deep_link::is_link(arg) {
+                    handle_deep_link(app, arg, false);
+                } else {
+                    handle_file_open(app, PathBuf::from(arg));
+                }
+            }
+            // Bring the main window to the front
+            show_main_window(app);
+        }))
+        .manage(PendingFiles {
+            files: Mutex::new(Vec::new()),
+            taken: AtomicBool::new(false),
+        })
+        .manage(FileAccess::new())
+        .manage(PendingArchiveMessages(Mutex::new(Vec::new())))
+        .manage(MessageWindows::new())
+        .manage(temp_files)
+        .manage(active_profile)
+        .manage(overrides.clone())
+        .manage(policy)
+        .manage(Automation::new())
+        .manage(TranslationCache::new())
+        .manage(ThumbnailCache::new())
+        .manage(AttachmentScanner::new())
+        .manage(RemoteImages::new())
+        .manage(Speech::new())
+        .manage(RecentFiles::new())
+        .manage(SessionStore::new())
+        .manage(Notifications::new(handle_notification_click))
+        .manage(SettingsStore::new())
+        .manage(FolderWatcher::new())
+        .manage(BatchJobs::new())
+        .manage(FileSaves::new())
+        .manage(OpenFolders::new())
+        .manage(LargeFiles::new())
+        .manage(PstFiles::new())
+        .manage(MboxFiles::new())
+        .manage(ImapPasswords::new())
+        .manage(Archive::new())
+        .manage(Updates::new())
+        // Remote images of messages, loaded once the user allows it
+        .register_asynchronous_uri_scheme_protocol(
+            remote_images::SCHEME,
+            |ctx, request, responder| {
+                let app = ctx.app_handle().clone();
+                std::thread::spawn(move || {
+                    let response = app.state::<RemoteImages>().respond(&app, &request);
+                    responder.respond(response);
+                });
+            },
+        )
+        // With the tray icon shown, closing the window hides it, so watched folders stay
+        // active; Quit in the tray menu ends the app
+        .on_window_event(|window, event| match event {
+            tauri::WindowEvent::CloseRequested { api, .. } => {
+                if window.label() == MAIN_LABEL && tray::is_shown(window.app_handle()) {
+                    api.prevent_close();
+                    let _ = window.hide();
+                }
+            }
+            // Dropped files and folders may be read, before the frontend gets their paths
+            tauri::WindowEvent::DragDrop(tauri::DragDropEvent::Drop { paths, .. }) => {
+                let access = window.app_handle().state::<FileAccess>();
+                for path in paths {
+                    access.grant(path);
+                }
+            }
+            // Message windows do not outlive the main window
+            tauri::WindowEvent::Destroyed => {
+                let windows = window.app_handle().state::<MessageWindows>();
+                if window.label() == MAIN_LABEL {
+                    windows.close_all(window.app_handle());
+                } else if message_window::is_message_window(window.label()) {
+                    windows.closed(window.label());
+                }
+            }
+            _ => {}
+        })
+        .setup(move |app| {
+            // Log files live next to the other machine-local data of the profile, unless
+            // `--log-file` names one
+            let log = match &startup.log_file {
+                Some(path) => logging::logger().init_file(path.clone()),
+                None => overrides::local_data_dir(app.handle())
+                    .and_then(|dir| logging::logger().init(dir.join("logs"))),
+            };
+            if let Err(e) = log {
+                log_error!("{}", e);
+            }
+            log_info!(
+                "{} {} started",
+                app.package_info().name,
+                app.package_info().version
+            );
+
+            match overrides::cache_dir(app.handle()) {
+                Ok(dir) => app.state::<TempFiles>().set_base(dir),
+                Err(e) => log_error!("{}", e),
+            }
+            // Remove expired temp files now (including leftovers from a previous
+            // session) and then periodically in the background
+            let handle = app.handle().clone();
+            std::thread::spawn(move || loop {
+                handle.state::<TempFiles>().sweep_expired();
+                std::thread::sleep(temp_files::SWEEP_INTERVAL);
+            });
+
+            system_theme::watch(app.handle().clone());
+            update_tray(app.handle());
+            update_jump_list(app.handle());
+
+            // Check for files and links passed as command-line arguments on startup
+            // (Windows/Linux), stored for later retrieval by the frontend
+            for arg in &startup.files {
+                if deep_link::is_link(arg) {
+                    handle_deep_link(app.handle(), arg, true);
+                } else {
+                    queue_pending_file(app.handle(), PathBuf::from(arg));
+                }
+            }
+
+            Ok(())
+        })
+        .invoke_handler(tauri::generate_handler![
+            read_file_as_bytes,
+            parse_msg_file,
+            read_ole_objects,
+            parse_eml_file,
+            detect_file_type,
+            open_large_message,
+            read_large_attachment,
+            start_large_attachment_save,
+            open_large_attachment,
+            close_large_message,
+            decode_bytes,
+            sanitize_html,
+            reply_via_default_client,
+            export_msg,
+            export_redacted,
+            get_pending_files,
+            get_pending_archive_messages,
+            open_message_in_new_window,
+            take_window_message,
+            get_recent_files,
+            add_recent_file,
+            pin_recent_file,
+            clear_recent_files,
+            update_session,
+            restore_session,
+            get_settings,
+            set_setting,
+            get_remote_image_proxy,
+            clear_remote_image_cache,
+            pick_default_save_directory,
+            pick_folder,
+            watch_folder,
+            unwatch_folder,
+            get_watched_folders,
+            restore_watched_folders,
+            expand_dropped_paths,
+            open_folder,
+            get_folder_page,
+            close_folder,
+            pick_pst_file,
+            open_pst_file,
+            get_pst_messages,
+            read_pst_message,
+            close_pst_file,
+            pick_mbox_file,
+            open_mbox_file,
+            get_mbox_messages,
+            read_mbox_message,
+            export_mbox_messages,
+            close_mbox_file,
+            read_gzip_message,
+            list_zip_messages,
+            read_zip_message,
+            has_imap_password,
+            connect_imap_account,
+            get_imap_messages,
+            read_imap_message,
+            forget_imap_password,
+            import_to_archive,
+            get_archive_messages,
+            get_archive_labels,
+            set_archive_tags,
+            move_archive_messages,
+            delete_archive_messages,
+            read_archive_message,
+            export_archive_messages,
+            export_messages_zip,
+            export_messages_maildir,
+            export_pst_maildir,
+            get_threads,
+            find_duplicates,
+            search_open_messages,
+            compare_messages,
+            start_batch_conversion,
+            cancel_batch_conversion,
+            pick_certificate_file,
+            smime_keystore_available,
+            decrypt_smime,
+            analyze_authentication,
+            analyze_phishing,
+            analyze_delivery_path,
+            generate_thumbnail,
+            parse_meeting,
+            export_to_ics,
+            parse_contact,
+            export_to_vcf,
+            get_mapi_properties,
+            print_message,
+            list_printers,
+            check_attachment,
+            open_file_with_system,
+            save_file_with_dialog,
+            start_file_save,
+            cancel_file_save,
+            save_all_attachments,
+            start_message_drag,
+            start_files_drag,
+            copy_files_to_clipboard,
+            clear_temp_files,
+            set_temp_file_retention,
+            list_export_plugins,
+            run_export_plugin,
+            run_event_hooks,
+            send_webhook_notification,
+            check_for_updates,
+            download_update,
+            install_update,
+            show_help,
+            get_app_info,
+            get_system_theme,
+            get_system_locale,
+            get_translations,
+            get_overrides,
+            get_file_association_status,
+            open_default_apps_settings,
+            make_default_app,
+            unregister_file_associations,
+            get_recent_logs,
+            set_log_level,
+            write_log,
+            open_log_folder,
+            get_policy,
+            get_profile,
+            set_profile,
+            get_stored_secret,
+            store_secret,
+            delete_stored_secret,
+            get_translation_status,
+            translate_text,
+            list_speech_voices,
+            speak,
+            stop_speaking,
+            set_automation_enabled,
+            automation_respond
+        ]);
+
+    let mut context = tauri::generate_context!();
+    if let Some(dir) = &overrides.data_dir {
+        if let Err(e) = std::fs::create_dir_all(dir) {
+            log_error!("Failed to create data directory {:?}: {}", dir, e);
+        }
+        // Settings live in the WebView storage, so the WebView data moves along
+        for window in context.config_mut().app.windows.iter_mut() {
+            window.data_directory = Some(dir.join("webview"));
+        }
+    }
+
+    builder
+        .build(context)
+        .expect("error while building tauri application")
+        .run(|app, event| {
+            // Remove all temp files and stop reading aloud when the app exits, then apply
+            // a downloaded update so the next start runs the new version
+            if let tauri::RunEvent::Exit = &event {
+                if let Err(e) = app.state::<SessionStore>().save() {
+                    log_error!("{}", e);
+                }
+                app.state::<TempFiles>().clear();
+                app.state::<Speech>().stop();
+                if let Err(e) = app.state::<Updates>().install() {
+                    log_error!("{}", e);
+                }
+            }
+
+            // Handle macOS file open events (double-click on file) and msgreader:// links
+            // This event only exists on macOS
+            #[cfg(target_os = "macos")]
+            if let tauri::RunEvent::Opened { urls } = &event {
+                for url in urls {
+                    // Check if app is ready (has windows), else store for later
+                    let starting = app.get_webview_window(MAIN_LABEL).is_none();
+                    if deep_link::is_link(url.as_str()) {
+                        handle_deep_link(app, url.as_str(), starting);
+                    } else if let Ok(path) = url.to_file_path() {
+                        // Convert file:// URL to path
+                        if starting {
+                            queue_pending_file(app, path);
+                        } else {
+                            handle_file_open(app, path);
                         }
                     }
                 }
             }
 
-            // Suppress unused variable warning on non-macOS
-            #[cfg(not(target_os = "macos"))]
-            let _ = (app, event);
         });
 }
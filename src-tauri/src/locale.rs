@@ -0,0 +1,84 @@
+use serde_json::{Map, Value};
+
+/// Language of the catalog missing strings fall back to
+pub const DEFAULT_LANGUAGE: &str = "en";
+
+/// Locale used when the OS does not report one
+const DEFAULT_LOCALE: &str = "en-US";
+
+/// Translation catalogs bundled from `res/locales/<language>.json`, shared with the web
+/// build: flat keys to strings with `{name}` placeholders
+const CATALOGS: &[(&str, &str)] = &[
+    ("en", include_str!("../../res/locales/en.json")),
+    ("de", include_str!("../../res/locales/de.json")),
+    ("fr", include_str!("../../res/locales/fr.json")),
+    ("ja", include_str!("../../res/locales/ja.json")),
+];
+
+/// The strings of a language, with English for keys it has no translation for
+#[derive(serde::Serialize)]
+#[serde(rename_all = "camelCase")]
+pub struct Translations {
+    /// Language of the catalog, e.g. "de" for a requested "de-AT"
+    pub language: &'static str,
+    pub messages: Map<String, Value>,
+}
+
+/// BCP 47 tag of a POSIX locale (`de_DE.UTF-8@euro` -> `de-DE`)
+fn normalize(locale: &str) -> Option<String> {
+    let tag = locale.split(['.', '@']).next()?.trim().replace('_', "-");
+    // "C" and "POSIX" mean no locale was chosen
+    (!tag.is_empty() && tag != "C" && tag != "POSIX").then_some(tag)
+}
+
+/// The user's locale as a BCP 47 tag, e.g. "de-DE"
+pub fn system_locale() -> String {
+    sys_locale::get_locale()
+        .and_then(|locale| normalize(&locale))
+        .unwrap_or_else(|| DEFAULT_LOCALE.to_string())
+}
+
+/// The bundled language for a locale ("de-AT", "fr", "ja_JP"), English if there is none
+pub fn language(locale: &str) -> &'static str {
+    let primary = locale
+        .split(['-', '_'])
+        .next()
+        .unwrap_or_default()
+        .to_ascii_lowercase();
+    CATALOGS
+        .iter()
+        .find(|(language, _)| *language == primary)
+        .map_or(DEFAULT_LANGUAGE, |(language, _)| language)
+}
+
+fn catalog(language: &str) -> Map<String, Value> {
+    CATALOGS
+        .iter()
+        .find(|(known, _)| *known == language)
+        .and_then(|(_, json)| match serde_json::from_str(json) {
+            Ok(messages) => Some(messages),
+            Err(e) => {
+                log_warn!("Invalid translation catalog {}: {}", language, e);
+                None
+            }
+        })
+        .unwrap_or_default()
+}
+
+/// The translations for a locale, see `Translations`
+pub fn translations(locale: &str) -> Translations {
+    let language = language(locale);
+    let mut messages = catalog(DEFAULT_LANGUAGE);
+    if language != DEFAULT_LANGUAGE {
+        messages.extend(catalog(language));
+    }
+    Translations { language, messages }
+}
+
+/// One string for a locale, the key itself if no catalog has it
+pub fn text(locale: &str, key: &str) -> String {
+    [language(locale), DEFAULT_LANGUAGE]
+        .iter()
+        .find_map(|language| catalog(language).get(key)?.as_str().map(str::to_string))
+        .unwrap_or_else(|| key.to_string())
+}
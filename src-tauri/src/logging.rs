@@ -0,0 +1,238 @@
+use crate::calendar;
+use std::collections::VecDeque;
+use std::fs::{File, OpenOptions};
+use std::io::Write;
+use std::path::{Path, PathBuf};
+use std::sync::atomic::{AtomicUsize, Ordering};
+use std::sync::{Mutex, OnceLock};
+use std::time::{SystemTime, UNIX_EPOCH};
+
+/// Name of the current log file in the log directory; older ones are `msgreader.1.log` etc.
+const FILE_NAME: &str = "msgreader.log";
+/// Size at which the log file is rotated
+const MAX_FILE_BYTES: u64 = 1024 * 1024;
+/// Rotated files kept next to the current one
+const MAX_OLD_FILES: usize = 4;
+/// Entries kept in memory for `recent`
+const MAX_RECENT: usize = 1000;
+
+pub use crate::overrides::LOG_LEVELS as LEVELS;
+
+// Indexes of the levels in LEVELS, from least to most severe
+pub const DEBUG: usize = 0;
+pub const INFO: usize = 1;
+pub const WARNING: usize = 2;
+pub const ERROR: usize = 3;
+
+/// One log message
+#[derive(serde::Serialize, Clone)]
+#[serde(rename_all = "camelCase")]
+pub struct LogEntry {
+    /// Unix milliseconds
+    pub time: i64,
+    pub level: &'static str,
+    /// Module that wrote the entry, `frontend` for messages from the webview
+    pub source: String,
+    pub message: String,
+}
+
+/// Leveled log of the backend (and the frontend, see `write_log`): entries go to stderr, to
+/// rotating files in the app's local data directory (or the file given with `--log-file`)
+/// once `init` was called, and to a buffer of recent entries for bug reports
+pub struct Logger {
+    level: AtomicUsize,
+    /// Current log file
+    path: Mutex<Option<PathBuf>>,
+    file: Mutex<Option<File>>,
+    recent: Mutex<VecDeque<LogEntry>>,
+}
+
+/// The process-wide logger
+pub fn logger() -> &'static Logger {
+    static LOGGER: OnceLock<Logger> = OnceLock::new();
+    LOGGER.get_or_init(|| Logger {
+        level: AtomicUsize::new(INFO),
+        path: Mutex::new(None),
+        file: Mutex::new(None),
+        recent: Mutex::new(VecDeque::new()),
+    })
+}
+
+/// Index of a level name in `LEVELS`
+pub fn parse_level(level: &str) -> Option<usize> {
+    let level = level.trim().to_lowercase();
+    LEVELS.iter().position(|name| *name == level)
+}
+
+fn now_ms() -> i64 {
+    SystemTime::now()
+        .duration_since(UNIX_EPOCH)
+        .map(|elapsed| elapsed.as_millis() as i64)
+        .unwrap_or(0)
+}
+
+/// `2024-07-10T09:00:00.000Z`
+fn format_time(ms: i64) -> String {
+    let (year, month, day) = calendar::civil_from_days(ms.div_euclid(86_400_000));
+    let ms_of_day = ms.rem_euclid(86_400_000);
+    format!(
+        "{:04}-{:02}-{:02}T{:02}:{:02}:{:02}.{:03}Z",
+        year,
+        month,
+        day,
+        ms_of_day / 3_600_000,
+        ms_of_day / 60_000 % 60,
+        ms_of_day / 1000 % 60,
+        ms_of_day % 1000
+    )
+}
+
+fn format_entry(entry: &LogEntry) -> String {
+    format!(
+        "{} {:<8} [{}] {}",
+        format_time(entry.time),
+        entry.level.to_uppercase(),
+        entry.source,
+        entry.message
+    )
+}
+
+/// The log file, or with an index one of the rotated files next to it:
+/// `msgreader.log`, `msgreader.1.log`, ...
+fn log_path(path: &Path, index: usize) -> PathBuf {
+    if index == 0 {
+        return path.to_path_buf();
+    }
+    let stem = path.file_stem().unwrap_or_default().to_string_lossy();
+    match path.extension() {
+        Some(extension) => path.with_file_name(format!(
+            "{}.{}.{}",
+            stem,
+            index,
+            extension.to_string_lossy()
+        )),
+        None => path.with_file_name(format!("{}.{}", stem, index)),
+    }
+}
+
+fn open_log(path: &Path) -> std::io::Result<File> {
+    if let Some(dir) = path.parent() {
+        std::fs::create_dir_all(dir)?;
+    }
+    OpenOptions::new().create(true).append(true).open(path)
+}
+
+/// Shift `msgreader.log` to `msgreader.1.log`, `.1` to `.2` and so on, dropping the oldest
+fn rotate(path: &Path) {
+    let _ = std::fs::remove_file(log_path(path, MAX_OLD_FILES));
+    for index in (0..MAX_OLD_FILES).rev() {
+        let _ = std::fs::rename(log_path(path, index), log_path(path, index + 1));
+    }
+}
+
+impl Logger {
+    /// Write log files to `dir` from now on, starting with the entries logged so far
+    pub fn init(&self, dir: PathBuf) -> Result<(), String> {
+        self.init_file(dir.join(FILE_NAME))
+    }
+
+    /// Write to the log file `path` from now on; it is rotated like the files in the log
+    /// folder
+    pub fn init_file(&self, path: PathBuf) -> Result<(), String> {
+        let mut file =
+            open_log(&path).map_err(|e| format!("Failed to open log file {:?}: {}", path, e))?;
+        for entry in self.recent.lock().unwrap().iter() {
+            let _ = writeln!(file, "{}", format_entry(entry));
+        }
+        *self.file.lock().unwrap() = Some(file);
+        *self.path.lock().unwrap() = Some(path);
+        Ok(())
+    }
+
+    pub fn level(&self) -> &'static str {
+        LEVELS[self.level.load(Ordering::Relaxed)]
+    }
+
+    pub fn set_level(&self, level: &str) -> Result<(), String> {
+        let index = parse_level(level).ok_or_else(|| format!("Invalid log level: {}", level))?;
+        self.level.store(index, Ordering::Relaxed);
+        Ok(())
+    }
+
+    /// Directory of the log files, None before `init`
+    pub fn dir(&self) -> Option<PathBuf> {
+        let path = self.path.lock().unwrap();
+        path.as_deref().and_then(Path::parent).map(Path::to_path_buf)
+    }
+
+    /// Log a message if its level is at least the current one
+    pub fn log(&self, level: usize, source: &str, message: String) {
+        let level = level.min(LEVELS.len() - 1);
+        if level < self.level.load(Ordering::Relaxed) {
+            return;
+        }
+        let entry = LogEntry {
+            time: now_ms(),
+            level: LEVELS[level],
+            // `msg_reader_lib::watch` is logged as `watch`
+            source: source.rsplit("::").next().unwrap_or(source).to_string(),
+            message,
+        };
+        let line = format_entry(&entry);
+        eprintln!("{}", line);
+
+        {
+            let mut recent = self.recent.lock().unwrap();
+            if recent.len() == MAX_RECENT {
+                recent.pop_front();
+            }
+            recent.push_back(entry);
+        }
+
+        let mut file = self.file.lock().unwrap();
+        let Some(current) = file.as_mut() else {
+            return;
+        };
+        let _ = writeln!(current, "{}", line);
+        if current.metadata().map_or(false, |m| m.len() >= MAX_FILE_BYTES) {
+            if let Some(path) = self.path.lock().unwrap().as_deref() {
+                *file = None;
+                rotate(path);
+                *file = open_log(path).ok();
+            }
+        }
+    }
+
+    /// The latest entries, oldest first
+    pub fn recent(&self, limit: usize) -> Vec<LogEntry> {
+        let recent = self.recent.lock().unwrap();
+        recent
+            .iter()
+            .skip(recent.len().saturating_sub(limit))
+            .cloned()
+            .collect()
+    }
+}
+
+/// Log at a level (`logging::DEBUG` .. `logging::ERROR`) with `format!` arguments
+macro_rules! log_at {
+    ($level:expr, $($arg:tt)+) => {
+        $crate::logging::logger().log($level, module_path!(), format!($($arg)+))
+    };
+}
+
+macro_rules! log_debug {
+    ($($arg:tt)+) => { log_at!($crate::logging::DEBUG, $($arg)+) };
+}
+
+macro_rules! log_info {
+    ($($arg:tt)+) => { log_at!($crate::logging::INFO, $($arg)+) };
+}
+
+macro_rules! log_warn {
+    ($($arg:tt)+) => { log_at!($crate::logging::WARNING, $($arg)+) };
+}
+
+macro_rules! log_error {
+    ($($arg:tt)+) => { log_at!($crate::logging::ERROR, $($arg)+) };
+}
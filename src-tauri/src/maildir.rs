@@ -0,0 +1,314 @@
+use crate::headers::CFB_SIGNATURE;
+use crate::message::MessageStatus;
+use crate::pst::{PstFiles, PstFolder};
+use crate::{eml, msg};
+use base64::{engine::general_purpose::STANDARD_NO_PAD, Engine as _};
+use std::fs::File;
+use std::io::Write;
+use std::path::{Path, PathBuf};
+use std::sync::atomic::{AtomicUsize, Ordering};
+use std::time::{Duration, SystemTime, UNIX_EPOCH};
+
+/// Separator of the unique name and the flags of a message in `cur`. Windows does not
+/// allow `:` in file names, so the tools that use Maildir there take `!` instead.
+const INFO_SEPARATOR: char = if cfg!(windows) { '!' } else { ':' };
+
+/// Number of the next message written by this process, part of its unique name
+static DELIVERIES: AtomicUsize = AtomicUsize::new(0);
+
+/// A message to export, by where the frontend opened it from
+#[derive(serde::Deserialize)]
+#[serde(rename_all = "camelCase")]
+pub struct MaildirItem {
+    /// File path, `archive#<id>` or `<pst or mbox file>#<id>`
+    pub source: String,
+    /// Pinned in the viewer; exported as flagged
+    #[serde(default)]
+    pub flagged: bool,
+}
+
+/// A message left out of the Maildir
+#[derive(serde::Serialize)]
+#[serde(rename_all = "camelCase")]
+pub struct MaildirSkipped {
+    pub source: String,
+    pub reason: String,
+}
+
+/// Result of an export
+#[derive(serde::Serialize)]
+#[serde(rename_all = "camelCase")]
+pub struct MaildirExport {
+    pub path: String,
+    pub exported_count: usize,
+    /// Number of Maildir folders written, subfolders included
+    pub folder_count: usize,
+    pub skipped: Vec<MaildirSkipped>,
+}
+
+/// The info flags of a message in ASCII order, as Maildir requires: Draft, Flagged,
+/// Passed (forwarded), Replied, Seen, Trashed
+fn flags(status: &MessageStatus) -> String {
+    [
+        (status.draft, 'D'),
+        (status.flagged, 'F'),
+        (status.forwarded, 'P'),
+        (status.replied, 'R'),
+        (status.read, 'S'),
+        (status.deleted, 'T'),
+    ]
+    .iter()
+    .filter(|(set, _)| *set)
+    .map(|(_, flag)| *flag)
+    .collect()
+}
+
+/// Status of an .eml or mbox message from its `Status` (R read) and `X-Status` (A
+/// answered, F flagged, T draft, D deleted) headers, as mbox tools write them
+fn header_status(data: &[u8]) -> MessageStatus {
+    let mut status = MessageStatus::default();
+    for line in data.split(|&b| b == b'\n') {
+        let line = String::from_utf8_lossy(line.strip_suffix(b"\r").unwrap_or(line));
+        if line.is_empty() {
+            break;
+        }
+        let Some((name, value)) = line.split_once(':') else {
+            continue;
+        };
+        if name.eq_ignore_ascii_case("status") {
+            status.read |= value.contains('R');
+        } else if name.eq_ignore_ascii_case("x-status") {
+            status.replied |= value.contains('A');
+            status.flagged |= value.contains('F');
+            status.draft |= value.contains('T');
+            status.deleted |= value.contains('D');
+        }
+    }
+    status
+}
+
+/// The message as RFC 5322 text with its date. .msg files are converted to .eml, .eml
+/// files are kept as they are.
+fn rfc822(data: Vec<u8>) -> Result<(Vec<u8>, Option<i64>), String> {
+    if data.starts_with(&CFB_SIGNATURE) {
+        let message = msg::parse_bytes(&data)?;
+        Ok((eml::write(&message), message.date))
+    } else {
+        let date = eml::parse_bytes(&data)?.date;
+        Ok((data, date))
+    }
+}
+
+/// `time.M<microseconds>P<pid>Q<count>.host,S=<size>`: unique as long as the host name
+/// is, with the size that Dovecot reads from the name instead of the file
+fn unique_name(size: usize) -> String {
+    let now = SystemTime::now().duration_since(UNIX_EPOCH).unwrap_or_default();
+    let host = std::env::var("HOSTNAME")
+        .or_else(|_| std::env::var("COMPUTERNAME"))
+        .unwrap_or_else(|_| "localhost".to_string());
+    format!(
+        "{}.M{}P{}Q{}.{},S={}",
+        now.as_secs(),
+        now.subsec_micros(),
+        std::process::id(),
+        DELIVERIES.fetch_add(1, Ordering::Relaxed),
+        host.replace('/', "\\057").replace(':', "\\072"),
+        size
+    )
+}
+
+/// Encode a folder name in modified UTF-7 (RFC 3501), as Dovecot stores folder names
+/// on disk: non-ASCII text becomes `&` + base64 of UTF-16 with `,` for `/` + `-`, and
+/// `&` becomes `&-`. `.` and `/` separate folders in Maildir++, so they become `_`.
+fn encode_folder_name(name: &str) -> String {
+    let mut result = String::with_capacity(name.len());
+    let mut pending: Vec<u16> = Vec::new();
+    let flush = |pending: &mut Vec<u16>, result: &mut String| {
+        if pending.is_empty() {
+            return;
+        }
+        let bytes: Vec<u8> = pending.iter().flat_map(|unit| unit.to_be_bytes()).collect();
+        result.push('&');
+        result.push_str(&STANDARD_NO_PAD.encode(bytes).replace('/', ","));
+        result.push('-');
+        pending.clear();
+    };
+    for c in name.chars() {
+        if (' '..='~').contains(&c) {
+            flush(&mut pending, &mut result);
+            match c {
+                '&' => result.push_str("&-"),
+                '.' | '/' => result.push('_'),
+                _ => result.push(c),
+            }
+        } else {
+            let mut units = [0u16; 2];
+            pending.extend_from_slice(c.encode_utf16(&mut units));
+        }
+    }
+    flush(&mut pending, &mut result);
+    result
+}
+
+/// A Maildir with its `tmp`, `new` and `cur` folders
+struct Maildir {
+    path: PathBuf,
+}
+
+impl Maildir {
+    /// Create the folders of a Maildir, or use them if they exist. Subfolders of
+    /// Maildir++ are marked with an empty `maildirfolder` file.
+    fn create(path: &Path, subfolder: bool) -> Result<Self, String> {
+        for dir in ["tmp", "new", "cur"] {
+            std::fs::create_dir_all(path.join(dir))
+                .map_err(|e| format!("Failed to create {}: {}", path.join(dir).display(), e))?;
+        }
+        if subfolder {
+            File::create(path.join("maildirfolder"))
+                .map_err(|e| format!("Failed to create {}: {}", path.display(), e))?;
+        }
+        Ok(Maildir {
+            path: path.to_path_buf(),
+        })
+    }
+
+    /// Write a message to `tmp` and move it into place: to `new` if it has no flags, to
+    /// `cur` with its flags otherwise. The file gets the date of the message as its
+    /// modification time, which Dovecot shows as the received date.
+    fn deliver(
+        &self,
+        data: &[u8],
+        status: &MessageStatus,
+        date: Option<i64>,
+    ) -> Result<(), String> {
+        let name = unique_name(data.len());
+        let temporary = self.path.join("tmp").join(&name);
+        let write = || -> std::io::Result<()> {
+            let mut file = File::create(&temporary)?;
+            file.write_all(data)?;
+            if let Some(date) = date.and_then(|date| u64::try_from(date).ok()) {
+                file.set_modified(UNIX_EPOCH + Duration::from_millis(date))?;
+            }
+            file.sync_all()
+        };
+        let flags = flags(status);
+        let target = if flags.is_empty() {
+            self.path.join("new").join(&name)
+        } else {
+            self.path.join("cur").join(format!("{}{}2,{}", name, INFO_SEPARATOR, flags))
+        };
+        let result = write().and_then(|_| std::fs::rename(&temporary, &target));
+        if let Err(e) = result {
+            let _ = std::fs::remove_file(&temporary);
+            return Err(format!("Failed to write {}: {}", target.display(), e));
+        }
+        Ok(())
+    }
+}
+
+/// Write opened messages into the Maildir at `destination`, which is created if needed;
+/// messages already in it are kept. `read` returns a message file by its source and, for
+/// sources that keep the status outside the file (PST messages), the status; otherwise
+/// it is read from the file. A message that cannot be read is skipped, not fatal.
+pub fn export(
+    items: &[MaildirItem],
+    destination: &Path,
+    read: impl Fn(&str) -> Result<(Vec<u8>, Option<MessageStatus>), String>,
+) -> Result<MaildirExport, String> {
+    let maildir = Maildir::create(destination, false)?;
+    let mut exported_count = 0;
+    let mut skipped = Vec::new();
+    for item in items {
+        let delivered = read(&item.source).and_then(|(data, status)| {
+            let mut status = match status {
+                Some(status) => status,
+                None if data.starts_with(&CFB_SIGNATURE) => msg::status_bytes(&data)?,
+                None => header_status(&data),
+            };
+            status.flagged |= item.flagged;
+            let (data, date) = rfc822(data)?;
+            maildir.deliver(&data, &status, date)
+        });
+        match delivered {
+            Ok(()) => exported_count += 1,
+            Err(reason) => {
+                log_warn!("Left {} out of the Maildir: {}", item.source, reason);
+                skipped.push(MaildirSkipped {
+                    source: item.source.clone(),
+                    reason,
+                });
+            }
+        }
+    }
+    Ok(MaildirExport {
+        path: destination.to_string_lossy().to_string(),
+        exported_count,
+        folder_count: 1,
+        skipped,
+    })
+}
+
+/// Write a folder of an open PST file with its subfolders into the Maildir++ at
+/// `destination`: the folder's messages go into the Maildir itself, those of a subfolder
+/// `Sent/2023` into `.Sent.2023`. The read, flagged, replied and forwarded state of the
+/// messages is kept.
+pub fn export_pst(
+    pst_files: &PstFiles,
+    path: &Path,
+    folder: u32,
+    destination: &Path,
+) -> Result<MaildirExport, String> {
+    let tree = pst_files.folder(path, folder)?;
+    let mut export = MaildirExport {
+        path: destination.to_string_lossy().to_string(),
+        exported_count: 0,
+        folder_count: 0,
+        skipped: Vec::new(),
+    };
+    export_pst_folder(pst_files, path, &tree, destination, None, &mut export)?;
+    Ok(export)
+}
+
+fn export_pst_folder(
+    pst_files: &PstFiles,
+    path: &Path,
+    folder: &PstFolder,
+    destination: &Path,
+    prefix: Option<&str>,
+    export: &mut MaildirExport,
+) -> Result<(), String> {
+    let maildir = match prefix {
+        Some(prefix) => Maildir::create(&destination.join(prefix), true)?,
+        None => Maildir::create(destination, false)?,
+    };
+    export.folder_count += 1;
+
+    for id in pst_files.message_ids(path, folder.id)? {
+        let delivered = pst_files
+            .message_with_status(path, id)
+            .and_then(|(message, status)| {
+                maildir.deliver(&eml::write(&message), &status, message.date)
+            });
+        match delivered {
+            Ok(()) => export.exported_count += 1,
+            Err(reason) => {
+                let source = format!("{}#{}", path.display(), id);
+                log_warn!("Left {} out of the Maildir: {}", source, reason);
+                export.skipped.push(MaildirSkipped { source, reason });
+            }
+        }
+    }
+
+    for child in &folder.children {
+        let name = match child.name.as_str() {
+            "" => "Unnamed".to_string(),
+            name => encode_folder_name(name),
+        };
+        let child_prefix = match prefix {
+            Some(prefix) => format!("{}.{}", prefix, name),
+            None => format!(".{}", name),
+        };
+        export_pst_folder(pst_files, path, child, destination, Some(&child_prefix), export)?;
+    }
+    Ok(())
+}
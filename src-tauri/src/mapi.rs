@@ -0,0 +1,845 @@
+use crate::msg::stream_tag;
+use crate::zip_export::iso_time;
+use cfb::CompoundFile;
+use serde_json::{json, Value};
+use std::collections::{HashMap, HashSet};
+use std::io::{self, Cursor, Read, Seek};
+use std::path::{Path, PathBuf};
+
+/// Length of the header of `__properties_version1.0` in the message, in an attached
+/// message and in recipients and attachments (MS-OXMSG section 2.4.1)
+const MESSAGE_HEADER_LEN: usize = 32;
+const EMBEDDED_HEADER_LEN: usize = 24;
+const SUBOBJECT_HEADER_LEN: usize = 8;
+/// Storage of an attached message or OLE object in an attachment (PR_ATTACH_DATA_OBJ)
+const ATTACH_OBJECT_STORAGE: &str = "__substg1.0_3701000D";
+/// Attached messages read inside each other at most
+const MAX_DEPTH: usize = 8;
+/// Binary values are returned up to this many bytes, `size` has the full length
+const MAX_BINARY_BYTES: usize = 4096;
+/// String values are returned up to this many characters
+const MAX_STRING_CHARS: usize = 100_000;
+/// Milliseconds between 1601-01-01 (FILETIME epoch) and 1970-01-01
+const FILETIME_UNIX_OFFSET_MS: i64 = 11_644_473_600_000;
+
+// Property types (MS-OXCDATA section 2.11.1) that need special handling
+const PT_OBJECT: u16 = 0x000D;
+const PT_STRING8: u16 = 0x001E;
+const PT_UNICODE: u16 = 0x001F;
+const PT_SYSTIME: u16 = 0x0040;
+const PT_CLSID: u16 = 0x0048;
+const PT_BINARY: u16 = 0x0102;
+/// Set on the type of multi-valued properties
+const MV_FLAG: u16 = 0x1000;
+
+/// Verb type of the voting options in PidLidVerbStream
+const VERB_TYPE_VOTING: u32 = 4;
+
+/// GUIDs of the property sets with a name (MS-OXPROPS section 1.3.2)
+const PS_MAPI: &str = "{00020328-0000-0000-C000-000000000046}";
+const PS_PUBLIC_STRINGS: &str = "{00020329-0000-0000-C000-000000000046}";
+const PROPERTY_SETS: &[(&str, &str)] = &[
+    (PS_MAPI, "PS_MAPI"),
+    (PS_PUBLIC_STRINGS, "PS_PUBLIC_STRINGS"),
+    ("{00020386-0000-0000-C000-000000000046}", "PS_INTERNET_HEADERS"),
+    ("{00062002-0000-0000-C000-000000000046}", "PSETID_Appointment"),
+    ("{00062003-0000-0000-C000-000000000046}", "PSETID_Task"),
+    ("{00062004-0000-0000-C000-000000000046}", "PSETID_Address"),
+    ("{00062008-0000-0000-C000-000000000046}", "PSETID_Common"),
+    ("{0006200A-0000-0000-C000-000000000046}", "PSETID_Log"),
+    ("{0006200E-0000-0000-C000-000000000046}", "PSETID_Note"),
+    ("{00062040-0000-0000-C000-000000000046}", "PSETID_Sharing"),
+    ("{6ED8DA90-450B-101B-98DA-00AA003F1305}", "PSETID_Meeting"),
+    ("{41F28F13-83F4-4114-A584-EEDB5A6B0BFF}", "PSETID_Messaging"),
+    ("{71035549-0739-4DCB-9163-00F0580DBBDF}", "PSETID_AirSync"),
+    ("{4442858E-A9E3-4E80-B900-317A210CC15B}", "PSETID_UnifiedMessaging"),
+];
+
+/// Canonical names of common tagged properties (MS-OXPROPS)
+const PROPERTY_NAMES: &[(u16, &str)] = &[
+    (0x0002, "PR_ALTERNATE_RECIPIENT_ALLOWED"),
+    (0x0017, "PR_IMPORTANCE"),
+    (0x001A, "PR_MESSAGE_CLASS"),
+    (0x0023, "PR_ORIGINATOR_DELIVERY_REPORT_REQUESTED"),
+    (0x0026, "PR_PRIORITY"),
+    (0x0029, "PR_READ_RECEIPT_REQUESTED"),
+    (0x002E, "PR_ORIGINAL_SENSITIVITY"),
+    (0x0036, "PR_SENSITIVITY"),
+    (0x0037, "PR_SUBJECT"),
+    (0x0039, "PR_CLIENT_SUBMIT_TIME"),
+    (0x003B, "PR_SENT_REPRESENTING_SEARCH_KEY"),
+    (0x003D, "PR_SUBJECT_PREFIX"),
+    (0x0041, "PR_SENT_REPRESENTING_ENTRYID"),
+    (0x0042, "PR_SENT_REPRESENTING_NAME"),
+    (0x0047, "PR_MESSAGE_SUBMISSION_ID"),
+    (0x0049, "PR_ORIGINAL_SUBJECT"),
+    (0x004F, "PR_REPLY_RECIPIENT_ENTRIES"),
+    (0x0050, "PR_REPLY_RECIPIENT_NAMES"),
+    (0x0057, "PR_MESSAGE_TO_ME"),
+    (0x0058, "PR_MESSAGE_CC_ME"),
+    (0x0060, "PR_START_DATE"),
+    (0x0061, "PR_END_DATE"),
+    (0x0064, "PR_SENT_REPRESENTING_ADDRTYPE"),
+    (0x0065, "PR_SENT_REPRESENTING_EMAIL_ADDRESS"),
+    (0x0070, "PR_CONVERSATION_TOPIC"),
+    (0x0071, "PR_CONVERSATION_INDEX"),
+    (0x007D, "PR_TRANSPORT_MESSAGE_HEADERS"),
+    (0x0C15, "PR_RECIPIENT_TYPE"),
+    (0x0C17, "PR_REPLY_REQUESTED"),
+    (0x0C19, "PR_SENDER_ENTRYID"),
+    (0x0C1A, "PR_SENDER_NAME"),
+    (0x0C1D, "PR_SENDER_SEARCH_KEY"),
+    (0x0C1E, "PR_SENDER_ADDRTYPE"),
+    (0x0C1F, "PR_SENDER_EMAIL_ADDRESS"),
+    (0x0E01, "PR_DELETE_AFTER_SUBMIT"),
+    (0x0E02, "PR_DISPLAY_BCC"),
+    (0x0E03, "PR_DISPLAY_CC"),
+    (0x0E04, "PR_DISPLAY_TO"),
+    (0x0E06, "PR_MESSAGE_DELIVERY_TIME"),
+    (0x0E07, "PR_MESSAGE_FLAGS"),
+    (0x0E08, "PR_MESSAGE_SIZE"),
+    (0x0E1B, "PR_HASATTACH"),
+    (0x0E1D, "PR_NORMALIZED_SUBJECT"),
+    (0x0E1F, "PR_RTF_IN_SYNC"),
+    (0x0E20, "PR_ATTACH_SIZE"),
+    (0x0E21, "PR_ATTACH_NUM"),
+    (0x0FF9, "PR_RECORD_KEY"),
+    (0x0FFE, "PR_OBJECT_TYPE"),
+    (0x0FFF, "PR_ENTRYID"),
+    (0x1000, "PR_BODY"),
+    (0x1009, "PR_RTF_COMPRESSED"),
+    (0x1013, "PR_BODY_HTML"),
+    (0x1035, "PR_INTERNET_MESSAGE_ID"),
+    (0x1039, "PR_INTERNET_REFERENCES"),
+    (0x1042, "PR_IN_REPLY_TO_ID"),
+    (0x1080, "PR_ICON_INDEX"),
+    (0x1081, "PR_LAST_VERB_EXECUTED"),
+    (0x1082, "PR_LAST_VERB_EXECUTION_TIME"),
+    (0x1090, "PR_FLAG_STATUS"),
+    (0x1091, "PR_FLAG_COMPLETE_TIME"),
+    (0x10F4, "PR_ATTR_HIDDEN"),
+    (0x3000, "PR_ROWID"),
+    (0x3001, "PR_DISPLAY_NAME"),
+    (0x3002, "PR_ADDRTYPE"),
+    (0x3003, "PR_EMAIL_ADDRESS"),
+    (0x3007, "PR_CREATION_TIME"),
+    (0x3008, "PR_LAST_MODIFICATION_TIME"),
+    (0x300B, "PR_SEARCH_KEY"),
+    (0x340D, "PR_STORE_SUPPORT_MASK"),
+    (0x3701, "PR_ATTACH_DATA_BIN"),
+    (0x3702, "PR_ATTACH_ENCODING"),
+    (0x3703, "PR_ATTACH_EXTENSION"),
+    (0x3704, "PR_ATTACH_FILENAME"),
+    (0x3705, "PR_ATTACH_METHOD"),
+    (0x3707, "PR_ATTACH_LONG_FILENAME"),
+    (0x370B, "PR_RENDERING_POSITION"),
+    (0x370E, "PR_ATTACH_MIME_TAG"),
+    (0x3712, "PR_ATTACH_CONTENT_ID"),
+    (0x3713, "PR_ATTACH_CONTENT_LOCATION"),
+    (0x3714, "PR_ATTACH_FLAGS"),
+    (0x39FE, "PR_SMTP_ADDRESS"),
+    (0x3A00, "PR_ACCOUNT"),
+    (0x3FDE, "PR_INTERNET_CPID"),
+    (0x3FF1, "PR_MESSAGE_LOCALE_ID"),
+    (0x3FF8, "PR_CREATOR_NAME"),
+    (0x3FFA, "PR_LAST_MODIFIER_NAME"),
+    (0x3FFD, "PR_MESSAGE_CODEPAGE"),
+    (0x5D01, "PR_SENDER_SMTP_ADDRESS"),
+    (0x5D02, "PR_SENT_REPRESENTING_SMTP_ADDRESS"),
+    (0x5FF6, "PR_RECIPIENT_DISPLAY_NAME"),
+    (0x5FF7, "PR_RECIPIENT_ENTRYID"),
+    (0x5FFD, "PR_RECIPIENT_FLAGS"),
+    (0x5FFF, "PR_RECIPIENT_TRACKSTATUS"),
+    (0x7FFE, "PR_ATTACHMENT_HIDDEN"),
+    (0x7FFF, "PR_ATTACHMENT_CONTACTPHOTO"),
+];
+
+/// Canonical names of common named properties: property set, long id and name
+const NAMED_PROPERTY_NAMES: &[(&str, u32, &str)] = &[
+    ("PSETID_Common", 0x8501, "PidLidReminderDelta"),
+    ("PSETID_Common", 0x8502, "PidLidReminderTime"),
+    ("PSETID_Common", 0x8503, "PidLidReminderSet"),
+    ("PSETID_Common", 0x8506, "PidLidPrivate"),
+    ("PSETID_Common", 0x8510, "PidLidSideEffects"),
+    ("PSETID_Common", 0x8516, "PidLidCommonStart"),
+    ("PSETID_Common", 0x8517, "PidLidCommonEnd"),
+    ("PSETID_Common", 0x8520, "PidLidVerbStream"),
+    ("PSETID_Common", 0x8524, "PidLidVerbResponse"),
+    ("PSETID_Common", 0x8530, "PidLidFlagRequest"),
+    ("PSETID_Common", 0x8535, "PidLidBilling"),
+    ("PSETID_Common", 0x8539, "PidLidCompanies"),
+    ("PSETID_Common", 0x8552, "PidLidCurrentVersion"),
+    ("PSETID_Common", 0x8554, "PidLidCurrentVersionName"),
+    ("PSETID_Common", 0x8560, "PidLidReminderSignalTime"),
+    ("PSETID_Common", 0x8580, "PidLidInternetAccountName"),
+    ("PSETID_Common", 0x8581, "PidLidInternetAccountStamp"),
+    ("PSETID_Common", 0x85A0, "PidLidToDoOrdinalDate"),
+    ("PSETID_Common", 0x85A1, "PidLidToDoSubOrdinal"),
+    ("PSETID_Common", 0x85A4, "PidLidToDoTitle"),
+    ("PSETID_Common", 0x85BF, "PidLidValidFlagStringProof"),
+    ("PSETID_Task", 0x8101, "PidLidTaskStatus"),
+    ("PSETID_Task", 0x8102, "PidLidPercentComplete"),
+    ("PSETID_Task", 0x8104, "PidLidTaskStartDate"),
+    ("PSETID_Task", 0x8105, "PidLidTaskDueDate"),
+    ("PSETID_Task", 0x811C, "PidLidTaskComplete"),
+    ("PSETID_Appointment", 0x8201, "PidLidAppointmentSequence"),
+    ("PSETID_Appointment", 0x8205, "PidLidBusyStatus"),
+    ("PSETID_Appointment", 0x8208, "PidLidLocation"),
+    ("PSETID_Appointment", 0x820D, "PidLidAppointmentStartWhole"),
+    ("PSETID_Appointment", 0x820E, "PidLidAppointmentEndWhole"),
+    ("PSETID_Appointment", 0x8215, "PidLidAppointmentSubType"),
+    ("PSETID_Appointment", 0x8217, "PidLidAppointmentStateFlags"),
+    ("PSETID_Appointment", 0x8218, "PidLidResponseStatus"),
+    ("PSETID_Appointment", 0x8223, "PidLidRecurring"),
+    ("PSETID_Appointment", 0x8234, "PidLidTimeZoneDescription"),
+    ("PSETID_Meeting", 0x0003, "PidLidGlobalObjectId"),
+    ("PSETID_Meeting", 0x0023, "PidLidCleanGlobalObjectId"),
+    ("PSETID_Address", 0x8005, "PidLidFileUnder"),
+    ("PSETID_Address", 0x802B, "PidLidHtml"),
+    ("PSETID_Address", 0x8080, "PidLidEmail1DisplayName"),
+    ("PSETID_Address", 0x8082, "PidLidEmail1AddressType"),
+    ("PSETID_Address", 0x8083, "PidLidEmail1EmailAddress"),
+    ("PSETID_Address", 0x8084, "PidLidEmail1OriginalDisplayName"),
+];
+
+/// Bits of PR_MESSAGE_FLAGS
+const MESSAGE_FLAGS: &[(u32, &str)] = &[
+    (0x0001, "Read"),
+    (0x0002, "Unmodified"),
+    (0x0004, "Submitted"),
+    (0x0008, "Unsent"),
+    (0x0010, "Has attachments"),
+    (0x0020, "From me"),
+    (0x0040, "Associated"),
+    (0x0080, "Resend"),
+    (0x0100, "Read receipt pending"),
+    (0x0200, "Non-read receipt pending"),
+];
+/// Bits of PR_ATTACH_FLAGS
+const ATTACH_FLAGS: &[(u32, &str)] = &[
+    (0x0001, "Hidden in HTML"),
+    (0x0002, "Hidden in RTF"),
+    (0x0004, "Referenced by MHTML"),
+];
+/// Bits of PR_RECIPIENT_FLAGS
+const RECIPIENT_FLAGS: &[(u32, &str)] = &[
+    (0x0001, "Sendable"),
+    (0x0002, "Organizer"),
+    (0x0010, "Exception response"),
+    (0x0020, "Exception deleted"),
+    (0x0100, "Original attendee"),
+];
+
+/// A named property (id 0x8000 and up): its property set and long id or string name
+#[derive(serde::Serialize, Clone)]
+#[serde(rename_all = "camelCase")]
+pub struct NamedProperty {
+    /// GUID of the property set, e.g. `{00062008-0000-0000-C000-000000000046}`
+    pub guid: String,
+    /// Name of a well-known property set, e.g. `PSETID_Common`
+    pub set_name: Option<&'static str>,
+    /// Numeric id of the property in its set, None for properties named by a string
+    pub lid: Option<u32>,
+    /// The string name (custom properties, Internet headers), or the canonical name of
+    /// a known numeric one, e.g. `PidLidVerbStream`
+    pub name: Option<String>,
+}
+
+/// A property of a message, recipient or attachment
+#[derive(serde::Serialize)]
+#[serde(rename_all = "camelCase")]
+pub struct MapiProperty {
+    /// Property tag in hex, e.g. `0x0037001F`
+    pub tag: String,
+    pub id: u16,
+    /// e.g. `PT_UNICODE` or `PT_MV_LONG`, the type in hex if unknown
+    pub type_name: String,
+    /// Canonical name of a known property, e.g. `PR_SUBJECT` or `PidLidVerbStream`
+    pub name: Option<String>,
+    pub named: Option<NamedProperty>,
+    /// Numbers, booleans and strings as such, times as ISO 8601 (UTC), GUIDs in braces,
+    /// binary data as hex; an array for multi-valued properties. Null for objects.
+    pub value: Value,
+    /// Meaning of known enumerations, flags and voting buttons, e.g. `High` for
+    /// PR_IMPORTANCE 2
+    pub description: Option<String>,
+    /// Size of the stored value in bytes
+    pub size: usize,
+    /// The value was cut to 4096 bytes or 100,000 characters
+    pub truncated: bool,
+}
+
+/// The properties of a message and of its parts
+#[derive(serde::Serialize)]
+#[serde(rename_all = "camelCase")]
+pub struct MapiObject {
+    /// Ordered by property id
+    pub properties: Vec<MapiProperty>,
+    pub recipients: Vec<MapiObject>,
+    pub attachments: Vec<MapiObject>,
+    /// The attached message of an attachment with PR_ATTACH_METHOD 5
+    pub embedded: Option<Box<MapiObject>>,
+}
+
+/// A decoded value with the size it takes in the file
+struct Decoded {
+    value: Value,
+    size: usize,
+    truncated: bool,
+}
+
+/// Reads little-endian values of PidLidVerbStream
+struct Reader<'a> {
+    data: &'a [u8],
+    offset: usize,
+}
+
+impl<'a> Reader<'a> {
+    fn bytes(&mut self, len: usize) -> Option<&'a [u8]> {
+        let bytes = self.data.get(self.offset..self.offset.checked_add(len)?)?;
+        self.offset += len;
+        Some(bytes)
+    }
+
+    fn u8(&mut self) -> Option<u8> {
+        self.bytes(1).map(|bytes| bytes[0])
+    }
+
+    fn u32(&mut self) -> Option<u32> {
+        self.bytes(4)
+            .map(|bytes| u32::from_le_bytes([bytes[0], bytes[1], bytes[2], bytes[3]]))
+    }
+
+    /// An 8-bit string after its length in one byte
+    fn short_string(&mut self) -> Option<String> {
+        let len = self.u8()? as usize;
+        Some(String::from_utf8_lossy(self.bytes(len)?).to_string())
+    }
+
+    /// A UTF-16 string after its length in characters in one byte
+    fn short_unicode(&mut self) -> Option<String> {
+        let len = self.u8()? as usize;
+        Some(decode_utf16(self.bytes(len * 2)?))
+    }
+}
+
+fn read_stream<F: Read + Seek>(file: &mut CompoundFile<F>, path: &Path) -> io::Result<Vec<u8>> {
+    let mut data = Vec::new();
+    file.open_stream(path)?.read_to_end(&mut data)?;
+    Ok(data)
+}
+
+fn decode_utf16(data: &[u8]) -> String {
+    let units: Vec<u16> = data
+        .chunks_exact(2)
+        .map(|pair| u16::from_le_bytes([pair[0], pair[1]]))
+        .collect();
+    String::from_utf16_lossy(&units).trim_end_matches('\0').to_string()
+}
+
+fn hex(bytes: &[u8]) -> String {
+    bytes.iter().map(|byte| format!("{:02x}", byte)).collect()
+}
+
+/// `{00062008-0000-0000-C000-000000000046}` from the little-endian bytes of a GUID
+fn guid(bytes: &[u8]) -> Option<String> {
+    let bytes = bytes.get(..16)?;
+    Some(format!(
+        "{{{:08X}-{:04X}-{:04X}-{}-{}}}",
+        u32::from_le_bytes([bytes[0], bytes[1], bytes[2], bytes[3]]),
+        u16::from_le_bytes([bytes[4], bytes[5]]),
+        u16::from_le_bytes([bytes[6], bytes[7]]),
+        hex(&bytes[8..10]).to_uppercase(),
+        hex(&bytes[10..16]).to_uppercase()
+    ))
+}
+
+/// `PT_UNICODE`, `PT_MV_LONG`, ...
+fn type_name(kind: u16) -> String {
+    let name = match kind & !MV_FLAG {
+        0x0002 => "PT_SHORT",
+        0x0003 => "PT_LONG",
+        0x0004 => "PT_FLOAT",
+        0x0005 => "PT_DOUBLE",
+        0x0006 => "PT_CURRENCY",
+        0x0007 => "PT_APPTIME",
+        0x000A => "PT_ERROR",
+        0x000B => "PT_BOOLEAN",
+        PT_OBJECT => "PT_OBJECT",
+        0x0014 => "PT_I8",
+        PT_STRING8 => "PT_STRING8",
+        PT_UNICODE => "PT_UNICODE",
+        PT_SYSTIME => "PT_SYSTIME",
+        PT_CLSID => "PT_CLSID",
+        0x00FB => "PT_SVREID",
+        0x00FD => "PT_SRESTRICT",
+        0x00FE => "PT_ACTIONS",
+        PT_BINARY => "PT_BINARY",
+        _ => return format!("0x{:04X}", kind),
+    };
+    if kind & MV_FLAG != 0 {
+        format!("PT_MV_{}", &name[3..])
+    } else {
+        name.to_string()
+    }
+}
+
+/// Size of a value of a fixed-length type, None for variable-length types
+fn fixed_size(kind: u16) -> Option<usize> {
+    match kind {
+        0x0002 => Some(2),
+        0x0003 | 0x0004 | 0x000A | 0x000B => Some(4),
+        0x0005..=0x0007 | 0x0014 | PT_SYSTIME => Some(8),
+        PT_CLSID => Some(16),
+        _ => None,
+    }
+}
+
+/// Whether the value is kept in `__properties_version1.0` itself rather than in a
+/// stream of its own
+fn is_inline(kind: u16) -> bool {
+    kind != PT_CLSID && fixed_size(kind).is_some()
+}
+
+/// A FILETIME as ISO 8601, null if not set
+fn filetime(value: u64) -> Value {
+    if value == 0 {
+        return Value::Null;
+    }
+    json!(iso_time((value / 10_000) as i64 - FILETIME_UNIX_OFFSET_MS))
+}
+
+/// A value of a fixed-length type from its little-endian bytes
+fn fixed_value(kind: u16, bytes: &[u8]) -> Value {
+    if kind == PT_CLSID {
+        return guid(bytes).map(Value::from).unwrap_or(Value::Null);
+    }
+    let mut value = [0u8; 8];
+    let len = bytes.len().min(8);
+    value[..len].copy_from_slice(&bytes[..len]);
+    let value = u64::from_le_bytes(value);
+    match kind {
+        0x0002 => json!(value as u16 as i16),
+        0x0003 => json!(value as u32 as i32),
+        0x0004 => json!(f32::from_bits(value as u32)),
+        0x0005 | 0x0007 => json!(f64::from_bits(value)),
+        // Currency is a fixed-point number with four decimal places
+        0x0006 => json!(value as i64 as f64 / 10_000.0),
+        0x000A => json!(format!("0x{:08X}", value as u32)),
+        0x000B => json!(value & 0xFF != 0),
+        0x0014 => json!(value as i64),
+        PT_SYSTIME => filetime(value),
+        _ => Value::Null,
+    }
+}
+
+/// A string, binary or GUID value from its stream
+fn variable_value(kind: u16, data: &[u8]) -> Decoded {
+    let text = match kind {
+        PT_UNICODE => Some(decode_utf16(data)),
+        PT_STRING8 => Some(String::from_utf8_lossy(data).trim_end_matches('\0').to_string()),
+        _ => None,
+    };
+    let (value, truncated) = match text {
+        Some(text) => match text.char_indices().nth(MAX_STRING_CHARS) {
+            Some((end, _)) => (json!(&text[..end]), true),
+            None => (json!(text), false),
+        },
+        None if kind == PT_CLSID && data.len() >= 16 => (fixed_value(PT_CLSID, data), false),
+        None => (
+            json!(hex(&data[..data.len().min(MAX_BINARY_BYTES)])),
+            data.len() > MAX_BINARY_BYTES,
+        ),
+    };
+    Decoded {
+        value,
+        size: data.len(),
+        truncated,
+    }
+}
+
+/// The values of a multi-valued property: fixed-length ones packed in the property's
+/// stream, strings and binary values in a stream each after a stream of their lengths
+/// (4 bytes per string, 8 per binary value)
+fn multi_value<F: Read + Seek>(
+    file: &mut CompoundFile<F>,
+    storage: &Path,
+    id: u16,
+    kind: u16,
+    data: &[u8],
+) -> Decoded {
+    let base = kind & !MV_FLAG;
+    if let Some(size) = fixed_size(base) {
+        return Decoded {
+            value: data
+                .chunks_exact(size)
+                .map(|bytes| fixed_value(base, bytes))
+                .collect(),
+            size: data.len(),
+            truncated: false,
+        };
+    }
+
+    let count = data.len() / if base == PT_BINARY { 8 } else { 4 };
+    let mut values = Vec::new();
+    let mut size = 0;
+    let mut truncated = false;
+    for index in 0..count {
+        let path = storage.join(format!("__substg1.0_{:04X}{:04X}-{:08X}", id, kind, index));
+        let item = variable_value(base, &read_stream(file, &path).unwrap_or_default());
+        size += item.size;
+        truncated |= item.truncated;
+        values.push(item.value);
+    }
+    Decoded {
+        value: Value::Array(values),
+        size,
+        truncated,
+    }
+}
+
+/// Names of the bits set in `value`, unknown bits in hex
+fn flag_names(value: u32, flags: &[(u32, &str)]) -> String {
+    let mut names: Vec<String> = flags
+        .iter()
+        .filter(|(bit, _)| value & bit != 0)
+        .map(|(_, name)| name.to_string())
+        .collect();
+    let known = flags.iter().fold(0, |all, (bit, _)| all | bit);
+    if value & !known != 0 {
+        names.push(format!("0x{:08X}", value & !known));
+    }
+    if names.is_empty() {
+        "None".to_string()
+    } else {
+        names.join(", ")
+    }
+}
+
+/// Display names of the voting buttons in PidLidVerbStream (MS-OXOMSG section
+/// 2.2.1.73), without the Reply, Reply All, Forward and Reply to Folder verbs that
+/// precede them. The Unicode names that follow the 8-bit ones are used if present.
+fn voting_options(data: &[u8]) -> Option<Vec<String>> {
+    let mut reader = Reader { data, offset: 0 };
+    reader.bytes(2)?; // Version
+    let count = reader.u32()?;
+    let mut verbs = Vec::new();
+    for _ in 0..count {
+        let verb_type = reader.u32()?;
+        let display_name = reader.short_string()?;
+        reader.short_string()?; // Message class
+        reader.short_string()?;
+        reader.short_string()?; // Display name again
+        // Internal fields, US headers, send behavior, id
+        reader.bytes(29)?;
+        verbs.push((verb_type, display_name));
+    }
+    if reader.bytes(2).is_some() {
+        for verb in verbs.iter_mut() {
+            let Some(name) = reader.short_unicode() else {
+                break;
+            };
+            let _ = reader.short_unicode(); // Display name again
+            verb.1 = name;
+        }
+    }
+    Some(
+        verbs
+            .into_iter()
+            .filter(|(verb_type, _)| *verb_type == VERB_TYPE_VOTING)
+            .map(|(_, name)| name)
+            .collect(),
+    )
+}
+
+/// What the value of a known enumeration, flag or voting property means
+fn describe(id: u16, name: Option<&str>, value: &Value, data: &[u8]) -> Option<String> {
+    if name == Some("PidLidVerbStream") {
+        let options = voting_options(data)?;
+        return (!options.is_empty()).then(|| format!("Voting buttons: {}", options.join("; ")));
+    }
+    let number = value.as_i64()?;
+    let pick = |names: &[&str], index: i64| {
+        usize::try_from(index)
+            .ok()
+            .and_then(|index| names.get(index))
+            .map(|name| name.to_string())
+    };
+    match (id, name) {
+        (0x0017, _) => pick(&["Low", "Normal", "High"], number),
+        (0x0026, _) => pick(&["Non-urgent", "Normal", "Urgent"], number + 1),
+        (0x002E | 0x0036, _) => pick(&["Normal", "Personal", "Private", "Confidential"], number),
+        (0x0C15, _) => pick(&["Originator", "To", "Cc", "Bcc"], number & 0x0F),
+        (0x0E07, _) => Some(flag_names(number as u32, MESSAGE_FLAGS)),
+        (0x1081, _) => match number {
+            102 => Some("Replied".to_string()),
+            103 => Some("Replied to all".to_string()),
+            104 => Some("Forwarded".to_string()),
+            _ => None,
+        },
+        (0x1090, _) => pick(&["Not flagged", "Completed", "Flagged"], number),
+        (0x3705, _) => pick(
+            &[
+                "No data",
+                "By value",
+                "By reference",
+                "By reference, resolved",
+                "By reference only",
+                "Attached message",
+                "OLE object",
+                "By web reference",
+            ],
+            number,
+        ),
+        (0x3714, _) => Some(flag_names(number as u32, ATTACH_FLAGS)),
+        (0x5FFD, _) => Some(flag_names(number as u32, RECIPIENT_FLAGS)),
+        (0x5FFF, _) => pick(
+            &["None", "Organizer", "Tentative", "Accepted", "Declined", "Not responded"],
+            number,
+        ),
+        (_, Some("PidLidTaskStatus")) => pick(
+            &["Not started", "In progress", "Complete", "Waiting on someone else", "Deferred"],
+            number,
+        ),
+        (_, Some("PidLidBusyStatus")) => pick(
+            &["Free", "Tentative", "Busy", "Out of office", "Working elsewhere"],
+            number,
+        ),
+        _ => None,
+    }
+}
+
+/// The named properties of the message by property id (MS-OXMSG section 2.2.3). Attached
+/// messages use the mapping of the message that contains them.
+fn named_properties<F: Read + Seek>(file: &mut CompoundFile<F>) -> HashMap<u16, NamedProperty> {
+    let name_id = Path::new("/__nameid_version1.0");
+    let guids = read_stream(file, &name_id.join("__substg1.0_00020102")).unwrap_or_default();
+    let entries = read_stream(file, &name_id.join("__substg1.0_00030102")).unwrap_or_default();
+    let strings = read_stream(file, &name_id.join("__substg1.0_00040102")).unwrap_or_default();
+
+    let mut named = HashMap::new();
+    for entry in entries.chunks_exact(8) {
+        let name_or_offset = u32::from_le_bytes([entry[0], entry[1], entry[2], entry[3]]);
+        let index_and_kind = u16::from_le_bytes([entry[4], entry[5]]);
+        let property_index = u16::from_le_bytes([entry[6], entry[7]]);
+        // 1 and 2 stand for PS_MAPI and PS_PUBLIC_STRINGS; the GUID stream starts at 3
+        let set = match index_and_kind >> 1 {
+            1 => PS_MAPI.to_string(),
+            2 => PS_PUBLIC_STRINGS.to_string(),
+            index => {
+                let Some(set) = (index as usize)
+                    .checked_sub(3)
+                    .and_then(|index| guids.get(index * 16..index * 16 + 16))
+                    .and_then(guid)
+                else {
+                    continue;
+                };
+                set
+            }
+        };
+        let set_name = PROPERTY_SETS
+            .iter()
+            .find(|(known, _)| *known == set)
+            .map(|(_, name)| *name);
+        let (lid, name) = if index_and_kind & 1 == 0 {
+            let name = NAMED_PROPERTY_NAMES
+                .iter()
+                .find(|(known_set, lid, _)| Some(*known_set) == set_name && *lid == name_or_offset)
+                .map(|(_, _, name)| name.to_string());
+            (Some(name_or_offset), name)
+        } else {
+            // A string name: its length in bytes, then UTF-16
+            let offset = name_or_offset as usize;
+            let name = strings
+                .get(offset..offset + 4)
+                .map(|len| u32::from_le_bytes([len[0], len[1], len[2], len[3]]) as usize)
+                .and_then(|len| strings.get(offset + 4..offset + 4 + len))
+                .map(decode_utf16);
+            (None, name)
+        };
+        named.insert(
+            0x8000 + property_index,
+            NamedProperty {
+                guid: set,
+                set_name,
+                lid,
+                name,
+            },
+        );
+    }
+    named
+}
+
+fn property(
+    id: u16,
+    kind: u16,
+    decoded: Decoded,
+    data: &[u8],
+    named: &HashMap<u16, NamedProperty>,
+) -> MapiProperty {
+    let named = if id >= 0x8000 {
+        named.get(&id).cloned()
+    } else {
+        None
+    };
+    let name = match &named {
+        Some(named) => named.name.clone(),
+        None => PROPERTY_NAMES
+            .iter()
+            .find(|(known, _)| *known == id)
+            .map(|(_, name)| name.to_string()),
+    };
+    MapiProperty {
+        tag: format!("0x{:04X}{:04X}", id, kind),
+        id,
+        type_name: type_name(kind),
+        description: describe(id, name.as_deref(), &decoded.value, data),
+        name,
+        named,
+        value: decoded.value,
+        size: decoded.size,
+        truncated: decoded.truncated,
+    }
+}
+
+/// The properties of one storage: the values in `__properties_version1.0` and their
+/// `__substg1.0_IIIITTTT` streams, plus streams the property stream does not list,
+/// which some writers leave out
+fn read_properties<F: Read + Seek>(
+    file: &mut CompoundFile<F>,
+    storage: &Path,
+    header_len: usize,
+    named: &HashMap<u16, NamedProperty>,
+) -> io::Result<Vec<MapiProperty>> {
+    let property_stream = storage.join("__properties_version1.0");
+    let entries = if file.is_stream(&property_stream) {
+        read_stream(file, &property_stream)?
+    } else {
+        Vec::new()
+    };
+    let mut tags: Vec<(u16, u16, Option<[u8; 8]>)> = Vec::new();
+    for entry in entries.get(header_len..).unwrap_or_default().chunks_exact(16) {
+        let kind = u16::from_le_bytes([entry[0], entry[1]]);
+        let id = u16::from_le_bytes([entry[2], entry[3]]);
+        let mut value = [0u8; 8];
+        value.copy_from_slice(&entry[8..16]);
+        tags.push((id, kind, is_inline(kind).then_some(value)));
+    }
+    let listed: HashSet<(u16, u16)> = tags.iter().map(|&(id, kind, _)| (id, kind)).collect();
+    let streams: Vec<(u16, u16)> = file
+        .read_storage(storage)?
+        .filter(|entry| entry.is_stream())
+        .filter_map(|entry| stream_tag(entry.name()))
+        .filter(|tag| !listed.contains(tag))
+        .collect();
+    tags.extend(streams.into_iter().map(|(id, kind)| (id, kind, None)));
+    tags.sort_by_key(|&(id, kind, _)| (id, kind));
+
+    let mut properties = Vec::new();
+    for (id, kind, inline) in tags {
+        if let Some(value) = inline {
+            let size = fixed_size(kind).unwrap_or(8);
+            let decoded = Decoded {
+                value: fixed_value(kind, &value[..size]),
+                size,
+                truncated: false,
+            };
+            properties.push(property(id, kind, decoded, &value, named));
+            continue;
+        }
+        if kind == PT_OBJECT {
+            // An attached message or OLE object is a storage, not a stream
+            let decoded = Decoded {
+                value: Value::Null,
+                size: 0,
+                truncated: false,
+            };
+            properties.push(property(id, kind, decoded, &[], named));
+            continue;
+        }
+        let path = storage.join(format!("__substg1.0_{:04X}{:04X}", id, kind));
+        let data = read_stream(file, &path).unwrap_or_default();
+        let decoded = if kind & MV_FLAG != 0 {
+            multi_value(file, storage, id, kind, &data)
+        } else {
+            variable_value(kind, &data)
+        };
+        properties.push(property(id, kind, decoded, &data, named));
+    }
+    Ok(properties)
+}
+
+/// Substorages of a storage whose names start with a prefix, in order
+fn substorages<F: Read + Seek>(
+    file: &CompoundFile<F>,
+    storage: &Path,
+    prefix: &str,
+) -> io::Result<Vec<PathBuf>> {
+    let mut paths: Vec<PathBuf> = file
+        .read_storage(storage)?
+        .filter(|entry| entry.is_storage() && entry.name().starts_with(prefix))
+        .map(|entry| entry.path().to_path_buf())
+        .collect();
+    paths.sort();
+    Ok(paths)
+}
+
+/// A message with its recipients and attachments, and the messages attached to it
+fn read_message<F: Read + Seek>(
+    file: &mut CompoundFile<F>,
+    storage: &Path,
+    header_len: usize,
+    named: &HashMap<u16, NamedProperty>,
+    depth: usize,
+) -> io::Result<MapiObject> {
+    let properties = read_properties(file, storage, header_len, named)?;
+
+    let mut recipients = Vec::new();
+    for recipient in substorages(file, storage, "__recip_version1.0_")? {
+        recipients.push(MapiObject {
+            properties: read_properties(file, &recipient, SUBOBJECT_HEADER_LEN, named)?,
+            recipients: Vec::new(),
+            attachments: Vec::new(),
+            embedded: None,
+        });
+    }
+
+    let mut attachments = Vec::new();
+    for attachment in substorages(file, storage, "__attach_version1.0_")? {
+        // OLE objects use the same storage, but without a property stream
+        let object = attachment.join(ATTACH_OBJECT_STORAGE);
+        let embedded = if depth < MAX_DEPTH
+            && file.is_stream(object.join("__properties_version1.0"))
+        {
+            let message = read_message(file, &object, EMBEDDED_HEADER_LEN, named, depth + 1)?;
+            Some(Box::new(message))
+        } else {
+            None
+        };
+        attachments.push(MapiObject {
+            properties: read_properties(file, &attachment, SUBOBJECT_HEADER_LEN, named)?,
+            recipients: Vec::new(),
+            attachments: Vec::new(),
+            embedded,
+        });
+    }
+
+    Ok(MapiObject {
+        properties,
+        recipients,
+        attachments,
+        embedded: None,
+    })
+}
+
+/// Every MAPI property of an .msg file in memory, of the message, its recipients and
+/// attachments and the messages attached to it, with tag, type, named property and
+/// decoded value. Unlike `msg::parse` nothing is left out or interpreted, so the result
+/// shows what the file really contains, custom properties included.
+pub fn properties(data: &[u8]) -> Result<MapiObject, String> {
+    let mut file = CompoundFile::open(Cursor::new(data))
+        .map_err(|e| format!("Not an MSG file: {}", e))?;
+    let named = named_properties(&mut file);
+    read_message(&mut file, Path::new("/"), MESSAGE_HEADER_LEN, &named, 0)
+        .map_err(|e| format!("Failed to read MSG file: {}", e))
+}
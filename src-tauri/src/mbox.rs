@@ -0,0 +1,252 @@
+use crate::attachments::{self, SaveResult};
+use crate::eml;
+use crate::message::MessageSummary;
+use std::collections::HashMap;
+use std::fs::File;
+use std::io::{BufRead, BufReader, Read, Seek, SeekFrom};
+use std::ops::Range;
+use std::path::{Path, PathBuf};
+use std::sync::Mutex;
+
+/// Most entries returned by one `page` call
+pub const MAX_PAGE_SIZE: usize = 200;
+
+/// Read buffer for indexing; mbox files are scanned line by line and can be many GB
+const SCAN_BUFFER_SIZE: usize = 1024 * 1024;
+
+/// Longest subject used for the name of an exported file
+const MAX_EXPORT_NAME_CHARS: usize = 100;
+
+/// A message of an opened mbox file. The message fields come from a quick parse of its
+/// headers; they are empty if the headers could not be parsed.
+#[derive(serde::Serialize)]
+#[serde(rename_all = "camelCase")]
+pub struct MboxEntry {
+    /// Position of the message in the file, used to read or export it
+    pub index: usize,
+    pub size: u64,
+    #[serde(flatten)]
+    pub summary: MessageSummary,
+    /// Why the quick parse failed, None if it worked
+    pub error: Option<String>,
+}
+
+#[derive(serde::Serialize)]
+#[serde(rename_all = "camelCase")]
+pub struct MboxListing {
+    pub path: String,
+    pub total: usize,
+}
+
+#[derive(serde::Serialize)]
+#[serde(rename_all = "camelCase")]
+pub struct MboxPage {
+    pub offset: usize,
+    pub total: usize,
+    pub entries: Vec<MboxEntry>,
+}
+
+/// Opened mbox files (Thunderbird folders, Google Takeout exports). Only the byte range
+/// of every message is kept; messages are read from the file when they are listed,
+/// opened or exported.
+pub struct MboxFiles {
+    files: Mutex<HashMap<PathBuf, Vec<Range<u64>>>>,
+}
+
+/// Byte ranges of the messages of an mbox file, without their `From ` separator lines.
+/// A `From ` line only starts a message at the start of the file or after an empty
+/// line, so unescaped `From ` lines in bodies (mboxo) rarely split a message.
+fn index(path: &Path) -> Result<Vec<Range<u64>>, String> {
+    let file = File::open(path).map_err(|e| format!("Failed to open mbox file: {}", e))?;
+    let mut reader = BufReader::with_capacity(SCAN_BUFFER_SIZE, file);
+    let mut ranges = Vec::new();
+    let mut line = Vec::new();
+    let mut offset = 0u64;
+    let mut start = None;
+    let mut after_blank = true;
+
+    loop {
+        line.clear();
+        let read = reader
+            .read_until(b'\n', &mut line)
+            .map_err(|e| format!("Failed to read mbox file: {}", e))?;
+        if read == 0 {
+            break;
+        }
+
+        if after_blank && line.starts_with(b"From ") {
+            if let Some(start) = start {
+                ranges.push(start..offset);
+            }
+            start = Some(offset + read as u64);
+        } else if start.is_none() && offset == 0 {
+            return Err("Not an mbox file".to_string());
+        }
+        after_blank = line == b"\n" || line == b"\r\n";
+        offset += read as u64;
+    }
+
+    if let Some(start) = start {
+        ranges.push(start..offset);
+    }
+    Ok(ranges)
+}
+
+/// Undo the quoting of `From ` lines in bodies (`>From ` -> `From `, `>>From ` ->
+/// `>From `) and drop the empty line that separates the message from the next one
+fn unescape(data: &[u8]) -> Vec<u8> {
+    let mut message = Vec::with_capacity(data.len());
+    for line in data.split_inclusive(|&b| b == b'\n') {
+        let quotes = line.iter().take_while(|&&b| b == b'>').count();
+        if quotes > 0 && line[quotes..].starts_with(b"From ") {
+            message.extend_from_slice(&line[1..]);
+        } else {
+            message.extend_from_slice(line);
+        }
+    }
+
+    if message.ends_with(b"\r\n\r\n") {
+        message.truncate(message.len() - 2);
+    } else if message.ends_with(b"\n\n") {
+        message.truncate(message.len() - 1);
+    }
+    message
+}
+
+fn read_range(file: &mut File, range: &Range<u64>, limit: u64) -> Result<Vec<u8>, String> {
+    let mut data = Vec::new();
+    file.seek(SeekFrom::Start(range.start))
+        .map_err(|e| format!("Failed to read mbox file: {}", e))?;
+    file.take((range.end - range.start).min(limit))
+        .read_to_end(&mut data)
+        .map_err(|e| format!("Failed to read mbox file: {}", e))?;
+    Ok(data)
+}
+
+/// `<subject>.eml`, shortened and made safe for the file system
+fn export_name(message: &[u8]) -> String {
+    let subject = eml::summary_from_bytes(message)
+        .map(|summary| summary.subject)
+        .unwrap_or_default();
+    let subject: String = subject.trim().chars().take(MAX_EXPORT_NAME_CHARS).collect();
+    if subject.is_empty() {
+        "message.eml".to_string()
+    } else {
+        attachments::safe_file_name(&format!("{}.eml", subject))
+    }
+}
+
+impl MboxFiles {
+    pub fn new() -> Self {
+        MboxFiles {
+            files: Mutex::new(HashMap::new()),
+        }
+    }
+
+    /// Index the messages of an mbox file. Opening a file again indexes it anew, so
+    /// messages appended since are found.
+    pub fn open(&self, path: &Path) -> Result<MboxListing, String> {
+        let ranges = index(path)?;
+        let total = ranges.len();
+        self.files.lock().unwrap().insert(path.to_path_buf(), ranges);
+        Ok(MboxListing {
+            path: path.to_string_lossy().to_string(),
+            total,
+        })
+    }
+
+    fn ranges(&self, path: &Path, indices: &[usize]) -> Result<Vec<Range<u64>>, String> {
+        let files = self.files.lock().unwrap();
+        let ranges = files
+            .get(path)
+            .ok_or_else(|| format!("Mbox file is not open: {}", path.display()))?;
+        indices
+            .iter()
+            .map(|&index| {
+                ranges
+                    .get(index)
+                    .cloned()
+                    .ok_or_else(|| format!("No message {} in mbox file", index))
+            })
+            .collect()
+    }
+
+    /// Summaries of `limit` messages (at most MAX_PAGE_SIZE) starting at `offset`
+    pub fn page(&self, path: &Path, offset: usize, limit: usize) -> Result<MboxPage, String> {
+        let (ranges, total) = {
+            let files = self.files.lock().unwrap();
+            let ranges = files
+                .get(path)
+                .ok_or_else(|| format!("Mbox file is not open: {}", path.display()))?;
+            let end = offset.saturating_add(limit.min(MAX_PAGE_SIZE)).min(ranges.len());
+            (ranges.get(offset..end).unwrap_or_default().to_vec(), ranges.len())
+        };
+
+        let mut file = File::open(path).map_err(|e| format!("Failed to open mbox file: {}", e))?;
+        let entries = ranges
+            .iter()
+            .enumerate()
+            .map(|(i, range)| {
+                let summary = read_range(&mut file, range, eml::SUMMARY_READ_LIMIT)
+                    .and_then(|data| eml::summary_from_bytes(&data));
+                let (summary, error) = match summary {
+                    Ok(summary) => (summary, None),
+                    Err(e) => (MessageSummary::default(), Some(e)),
+                };
+                MboxEntry {
+                    index: offset + i,
+                    size: range.end - range.start,
+                    summary,
+                    error,
+                }
+            })
+            .collect();
+
+        Ok(MboxPage {
+            offset,
+            total,
+            entries,
+        })
+    }
+
+    /// One message as .eml file bytes
+    pub fn message(&self, path: &Path, index: usize) -> Result<Vec<u8>, String> {
+        let range = self.ranges(path, &[index])?.remove(0);
+        let mut file = File::open(path).map_err(|e| format!("Failed to open mbox file: {}", e))?;
+        Ok(unescape(&read_range(&mut file, &range, u64::MAX)?))
+    }
+
+    /// Save messages as .eml files named after their subjects. Existing files are never
+    /// overwritten; every message gets a result, so one failure does not stop the rest.
+    pub fn export(
+        &self,
+        path: &Path,
+        indices: &[usize],
+        dir: &Path,
+    ) -> Result<Vec<SaveResult>, String> {
+        let ranges = self.ranges(path, indices)?;
+        let mut file = File::open(path).map_err(|e| format!("Failed to open mbox file: {}", e))?;
+
+        Ok(ranges
+            .iter()
+            .map(|range| {
+                let message = read_range(&mut file, range, u64::MAX).map(|data| unescape(&data));
+                let file_name = message
+                    .as_deref()
+                    .map(export_name)
+                    .unwrap_or_else(|_| "message.eml".to_string());
+                let saved = message
+                    .and_then(|message| attachments::write_unique(dir, &file_name, &message));
+                SaveResult {
+                    file_name,
+                    path: saved.as_ref().ok().map(|path| path.to_string_lossy().to_string()),
+                    error: saved.err(),
+                }
+            })
+            .collect())
+    }
+
+    pub fn close(&self, path: &Path) {
+        self.files.lock().unwrap().remove(path);
+    }
+}
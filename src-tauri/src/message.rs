@@ -0,0 +1,143 @@
+/// A message parsed in the backend (see msg.rs and eml.rs), serialized with the field names of the
+/// frontend's JSON export (messageToJson) so both can be consumed the same way
+#[derive(serde::Serialize, Default)]
+#[serde(rename_all = "camelCase")]
+pub struct Message {
+    pub subject: String,
+    pub sender_name: String,
+    pub sender_email: String,
+    pub recipients: Vec<Recipient>,
+    /// Unix time in milliseconds, None if the message has no date
+    pub date: Option<i64>,
+    pub message_id: String,
+    /// Raw transport headers, empty if the message has none
+    pub headers: String,
+    pub body_text: String,
+    pub body_html: String,
+    pub attachments: Vec<Attachment>,
+}
+
+impl Message {
+    /// The text body, or the text of the HTML body if the message has no text part
+    pub fn plain_text(&self) -> String {
+        if !self.body_text.trim().is_empty() {
+            return self.body_text.trim().to_string();
+        }
+        html_to_text(&self.body_html)
+    }
+}
+
+/// Remove everything between `start` and `end` (case-insensitive), including both
+fn remove_blocks(html: &str, start: &str, end: &str) -> String {
+    let lower = html.to_ascii_lowercase();
+    let mut result = String::with_capacity(html.len());
+    let mut position = 0;
+    while let Some(found) = lower[position..].find(start) {
+        result.push_str(&html[position..position + found]);
+        match lower[position + found..].find(end) {
+            Some(close) => position += found + close + end.len(),
+            None => return result,
+        }
+    }
+    result.push_str(&html[position..]);
+    result
+}
+
+/// Rough text of an HTML body: styles and scripts removed, line breaks for `<br>` and
+/// paragraphs, tags dropped and the common entities decoded
+pub fn html_to_text(html: &str) -> String {
+    let html = remove_blocks(&remove_blocks(html, "<style", "</style>"), "<script", "</script>");
+    let mut text = String::with_capacity(html.len());
+    let mut tag = String::new();
+    let mut in_tag = false;
+    for c in html.chars() {
+        match c {
+            '<' => {
+                in_tag = true;
+                tag.clear();
+            }
+            '>' if in_tag => {
+                in_tag = false;
+                let name = tag.trim_start_matches('/').split_whitespace().next().unwrap_or("");
+                match name.trim_end_matches('/').to_ascii_lowercase().as_str() {
+                    "br" | "tr" | "li" | "div" => text.push('\n'),
+                    "p" | "h1" | "h2" | "h3" | "table" if tag.starts_with('/') => {
+                        text.push_str("\n\n")
+                    }
+                    _ => {}
+                }
+            }
+            c if in_tag => tag.push(c),
+            '\r' => {}
+            c => text.push(c),
+        }
+    }
+    let text = text
+        .replace("&nbsp;", " ")
+        .replace("&lt;", "<")
+        .replace("&gt;", ">")
+        .replace("&quot;", "\"")
+        .replace("&#39;", "'")
+        .replace("&amp;", "&");
+
+    // At most one empty line in a row
+    let mut result = String::with_capacity(text.len());
+    let mut empty_lines = 0;
+    for line in text.lines().map(str::trim_end) {
+        if line.trim().is_empty() {
+            empty_lines += 1;
+            if empty_lines > 1 {
+                continue;
+            }
+        } else {
+            empty_lines = 0;
+        }
+        result.push_str(line);
+        result.push('\n');
+    }
+    result.trim().to_string()
+}
+
+#[derive(serde::Serialize)]
+#[serde(rename_all = "camelCase")]
+pub struct Recipient {
+    pub name: String,
+    pub email: String,
+    /// "to", "cc" or "bcc"
+    #[serde(rename = "type")]
+    pub kind: &'static str,
+}
+
+#[derive(serde::Serialize)]
+#[serde(rename_all = "camelCase")]
+pub struct Attachment {
+    pub file_name: String,
+    pub mime_type: String,
+    /// Content-ID of inline images, empty otherwise
+    pub content_id: String,
+    pub size: usize,
+    pub content_base64: String,
+}
+
+/// What the mailbox a message came from knows about it besides its content: Outlook keeps
+/// it in message properties, mbox files in `Status` and `X-Status` headers
+#[derive(Default, Clone, Copy)]
+pub struct MessageStatus {
+    pub read: bool,
+    pub draft: bool,
+    pub flagged: bool,
+    pub replied: bool,
+    pub forwarded: bool,
+    pub deleted: bool,
+}
+
+/// The fields of a message shown when listing a folder, read without parsing the whole file
+#[derive(serde::Serialize, Default)]
+#[serde(rename_all = "camelCase")]
+pub struct MessageSummary {
+    pub subject: String,
+    pub sender_name: String,
+    pub sender_email: String,
+    /// Unix time in milliseconds, None if the message has no date
+    pub date: Option<i64>,
+}
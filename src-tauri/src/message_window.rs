@@ -0,0 +1,101 @@
+use std::collections::HashMap;
+use std::path::PathBuf;
+use std::sync::atomic::{AtomicU64, Ordering};
+use std::sync::Mutex;
+use tauri::{AppHandle, Manager, WebviewUrl, WebviewWindowBuilder};
+
+/// Label of the window the app starts with; events that open files go to it only
+pub const MAIN_LABEL: &str = "main";
+
+/// Message windows are labelled `message-<n>`
+const LABEL_PREFIX: &str = "message-";
+
+/// The frontend starts in message window mode with this query
+const WINDOW_URL: &str = "index.html?window=message";
+
+/// The message a new window shows: its file on disk (`path`, for messages opened from a
+/// file) or the file content (`data`, base64, e.g. for messages from a PST or the archive)
+#[derive(serde::Serialize, serde::Deserialize, Clone)]
+#[serde(rename_all = "camelCase")]
+pub struct WindowMessage {
+    pub file_name: String,
+    /// "msg" or "eml"
+    pub file_type: String,
+    /// Where the message came from, for the audit log
+    #[serde(default)]
+    pub source_path: String,
+    #[serde(default)]
+    pub path: Option<String>,
+    #[serde(default)]
+    pub data: Option<String>,
+}
+
+/// Additional windows that show one message each, e.g. to compare two messages side by
+/// side. They load the same frontend as the main window; the message waits here until
+/// the window asks for it.
+pub struct MessageWindows {
+    next_id: AtomicU64,
+    messages: Mutex<HashMap<String, WindowMessage>>,
+}
+
+pub fn is_message_window(label: &str) -> bool {
+    label.starts_with(LABEL_PREFIX)
+}
+
+impl MessageWindows {
+    pub fn new() -> Self {
+        MessageWindows {
+            next_id: AtomicU64::new(1),
+            messages: Mutex::new(HashMap::new()),
+        }
+    }
+
+    /// Open a window for a message and return its label. `data_dir` is the WebView data
+    /// directory of the main window, so both share settings and storage.
+    pub fn open(
+        &self,
+        app: &AppHandle,
+        message: WindowMessage,
+        data_dir: Option<PathBuf>,
+    ) -> Result<String, String> {
+        if message.path.is_none() && message.data.is_none() {
+            return Err("The message has neither a file nor content".to_string());
+        }
+        let label = format!("{}{}", LABEL_PREFIX, self.next_id.fetch_add(1, Ordering::SeqCst));
+        let title = format!("{} - {}", message.file_name, app.package_info().name);
+        self.messages.lock().unwrap().insert(label.clone(), message);
+
+        let mut builder = WebviewWindowBuilder::new(app, &label, WebviewUrl::App(WINDOW_URL.into()))
+            .title(title)
+            .inner_size(1000.0, 760.0)
+            .min_inner_size(600.0, 400.0);
+        if let Some(dir) = data_dir {
+            builder = builder.data_directory(dir);
+        }
+        if let Err(e) = builder.build() {
+            self.messages.lock().unwrap().remove(&label);
+            return Err(format!("Failed to open message window: {}", e));
+        }
+        Ok(label)
+    }
+
+    /// The message of a window; it is handed out once
+    pub fn take(&self, label: &str) -> Option<WindowMessage> {
+        self.messages.lock().unwrap().remove(label)
+    }
+
+    /// Forget the message of a window that closed before asking for it
+    pub fn closed(&self, label: &str) {
+        self.messages.lock().unwrap().remove(label);
+    }
+
+    /// Close all message windows, when the main window is gone
+    pub fn close_all(&self, app: &AppHandle) {
+        for (label, window) in app.webview_windows() {
+            if is_message_window(&label) {
+                let _ = window.close();
+            }
+        }
+        self.messages.lock().unwrap().clear();
+    }
+}
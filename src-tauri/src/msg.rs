@@ -0,0 +1,1449 @@
+use crate::calendar::{self, Attendee, Meeting};
+use crate::contact::{Contact, Phone, PostalAddress};
+use crate::message::{Attachment, Message, MessageStatus, MessageSummary, Recipient};
+use crate::ole::{self, OleObject};
+use crate::rtf;
+use base64::{engine::general_purpose::STANDARD, Engine as _};
+use cfb::CompoundFile;
+use std::collections::HashMap;
+use std::io::{self, Cursor, Read, Seek, Write};
+use std::path::{Path, PathBuf};
+
+// Property ids (MS-OXPROPS)
+const PR_MESSAGE_CLASS: u16 = 0x001A;
+pub(crate) const PR_SUBJECT: u16 = 0x0037;
+pub(crate) const PR_CLIENT_SUBMIT_TIME: u16 = 0x0039;
+pub(crate) const PR_SENT_REPRESENTING_NAME: u16 = 0x0042;
+const PR_START_DATE: u16 = 0x0060;
+const PR_END_DATE: u16 = 0x0061;
+const PR_SENT_REPRESENTING_EMAIL_ADDRESS: u16 = 0x0065;
+const PR_CONVERSATION_TOPIC: u16 = 0x0070;
+const PR_CONVERSATION_INDEX: u16 = 0x0071;
+const PR_TRANSPORT_MESSAGE_HEADERS: u16 = 0x007D;
+const PR_RECIPIENT_TYPE: u16 = 0x0C15;
+pub(crate) const PR_SENDER_NAME: u16 = 0x0C1A;
+const PR_SENDER_ADDRTYPE: u16 = 0x0C1E;
+const PR_SENDER_EMAIL_ADDRESS: u16 = 0x0C1F;
+const PR_DISPLAY_CC: u16 = 0x0E03;
+const PR_DISPLAY_TO: u16 = 0x0E04;
+pub(crate) const PR_MESSAGE_DELIVERY_TIME: u16 = 0x0E06;
+pub(crate) const PR_MESSAGE_FLAGS: u16 = 0x0E07;
+const PR_ATTACH_NUM: u16 = 0x0E21;
+const PR_BODY: u16 = 0x1000;
+const PR_RTF_COMPRESSED: u16 = 0x1009;
+const PR_BODY_HTML: u16 = 0x1013;
+const PR_INTERNET_MESSAGE_ID: u16 = 0x1035;
+const PR_LAST_VERB_EXECUTED: u16 = 0x1081;
+const PR_FLAG_STATUS: u16 = 0x1090;
+const PR_ROWID: u16 = 0x3000;
+pub(crate) const PR_DISPLAY_NAME: u16 = 0x3001;
+const PR_ADDRTYPE: u16 = 0x3002;
+const PR_EMAIL_ADDRESS: u16 = 0x3003;
+const PR_STORE_SUPPORT_MASK: u16 = 0x340D;
+const PR_ATTACH_DATA_BIN: u16 = 0x3701;
+const PR_ATTACH_FILENAME: u16 = 0x3704;
+const PR_ATTACH_METHOD: u16 = 0x3705;
+const PR_ATTACH_LONG_FILENAME: u16 = 0x3707;
+const PR_RENDERING_POSITION: u16 = 0x370B;
+const PR_ATTACH_MIME_TAG: u16 = 0x370E;
+const PR_ATTACH_CONTENT_ID: u16 = 0x3712;
+const PR_ATTACH_FLAGS: u16 = 0x3714;
+const PR_SMTP_ADDRESS: u16 = 0x39FE;
+const PR_GENERATION: u16 = 0x3A05;
+const PR_GIVEN_NAME: u16 = 0x3A06;
+const PR_BUSINESS_TELEPHONE_NUMBER: u16 = 0x3A08;
+const PR_HOME_TELEPHONE_NUMBER: u16 = 0x3A09;
+const PR_SURNAME: u16 = 0x3A11;
+const PR_COMPANY_NAME: u16 = 0x3A16;
+const PR_TITLE: u16 = 0x3A17;
+const PR_DEPARTMENT_NAME: u16 = 0x3A18;
+const PR_PRIMARY_TELEPHONE_NUMBER: u16 = 0x3A1A;
+const PR_BUSINESS2_TELEPHONE_NUMBER: u16 = 0x3A1B;
+const PR_MOBILE_TELEPHONE_NUMBER: u16 = 0x3A1C;
+const PR_CAR_TELEPHONE_NUMBER: u16 = 0x3A1E;
+const PR_OTHER_TELEPHONE_NUMBER: u16 = 0x3A1F;
+const PR_PAGER_TELEPHONE_NUMBER: u16 = 0x3A21;
+const PR_BUSINESS_FAX_NUMBER: u16 = 0x3A24;
+const PR_HOME_FAX_NUMBER: u16 = 0x3A25;
+const PR_COUNTRY: u16 = 0x3A26;
+const PR_LOCALITY: u16 = 0x3A27;
+const PR_STATE_OR_PROVINCE: u16 = 0x3A28;
+const PR_STREET_ADDRESS: u16 = 0x3A29;
+const PR_POSTAL_CODE: u16 = 0x3A2A;
+const PR_POST_OFFICE_BOX: u16 = 0x3A2B;
+const PR_HOME2_TELEPHONE_NUMBER: u16 = 0x3A2F;
+const PR_WEDDING_ANNIVERSARY: u16 = 0x3A41;
+const PR_BIRTHDAY: u16 = 0x3A42;
+const PR_MIDDLE_NAME: u16 = 0x3A44;
+const PR_DISPLAY_NAME_PREFIX: u16 = 0x3A45;
+const PR_NICKNAME: u16 = 0x3A4F;
+const PR_PERSONAL_HOME_PAGE: u16 = 0x3A50;
+const PR_BUSINESS_HOME_PAGE: u16 = 0x3A51;
+const PR_COMPANY_MAIN_PHONE_NUMBER: u16 = 0x3A57;
+const PR_HOME_ADDRESS_CITY: u16 = 0x3A59;
+const PR_HOME_ADDRESS_COUNTRY: u16 = 0x3A5A;
+const PR_HOME_ADDRESS_POSTAL_CODE: u16 = 0x3A5B;
+const PR_HOME_ADDRESS_STATE_OR_PROVINCE: u16 = 0x3A5C;
+const PR_HOME_ADDRESS_STREET: u16 = 0x3A5D;
+const PR_HOME_ADDRESS_POST_OFFICE_BOX: u16 = 0x3A5E;
+const PR_OTHER_ADDRESS_CITY: u16 = 0x3A5F;
+const PR_OTHER_ADDRESS_COUNTRY: u16 = 0x3A60;
+const PR_OTHER_ADDRESS_POSTAL_CODE: u16 = 0x3A61;
+const PR_OTHER_ADDRESS_STATE_OR_PROVINCE: u16 = 0x3A62;
+const PR_OTHER_ADDRESS_STREET: u16 = 0x3A63;
+const PR_OTHER_ADDRESS_POST_OFFICE_BOX: u16 = 0x3A64;
+const PR_INTERNET_CPID: u16 = 0x3FDE;
+const PR_SENDER_SMTP_ADDRESS: u16 = 0x5D01;
+const PR_RECIPIENT_FLAGS: u16 = 0x5FFD;
+const PR_RECIPIENT_TRACKSTATUS: u16 = 0x5FFF;
+const PR_ATTACHMENT_CONTACTPHOTO: u16 = 0x7FFF;
+
+/// Stream of PR_ATTACH_DATA_BIN in an attachment storage
+const ATTACH_DATA_STREAM: &str = "__substg1.0_37010102";
+/// Storage of the OLE object of an attachment (PR_ATTACH_DATA_OBJ)
+const ATTACH_OBJECT_STORAGE: &str = "__substg1.0_3701000D";
+
+// Named property sets as stored in the GUID stream (little-endian GUID fields)
+/// PSETID_Appointment {00062002-0000-0000-C000-000000000046}
+const PSETID_APPOINTMENT: [u8; 16] = [
+    0x02, 0x20, 0x06, 0x00, 0x00, 0x00, 0x00, 0x00, 0xC0, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x46,
+];
+/// PSETID_Meeting {6ED8DA90-450B-101B-98DA-00AA003F1305}
+const PSETID_MEETING: [u8; 16] = [
+    0x90, 0xDA, 0xD8, 0x6E, 0x0B, 0x45, 0x1B, 0x10, 0x98, 0xDA, 0x00, 0xAA, 0x00, 0x3F, 0x13, 0x05,
+];
+/// PSETID_Address {00062004-0000-0000-C000-000000000046}
+const PSETID_ADDRESS: [u8; 16] = [
+    0x04, 0x20, 0x06, 0x00, 0x00, 0x00, 0x00, 0x00, 0xC0, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x46,
+];
+
+// Named properties of meeting objects (MS-OXOCAL): property set and long id
+const PID_LID_APPOINTMENT_SEQUENCE: ([u8; 16], u32) = (PSETID_APPOINTMENT, 0x8201);
+const PID_LID_LOCATION: ([u8; 16], u32) = (PSETID_APPOINTMENT, 0x8208);
+const PID_LID_APPOINTMENT_START_WHOLE: ([u8; 16], u32) = (PSETID_APPOINTMENT, 0x820D);
+const PID_LID_APPOINTMENT_END_WHOLE: ([u8; 16], u32) = (PSETID_APPOINTMENT, 0x820E);
+const PID_LID_APPOINTMENT_SUB_TYPE: ([u8; 16], u32) = (PSETID_APPOINTMENT, 0x8215);
+const PID_LID_GLOBAL_OBJECT_ID: ([u8; 16], u32) = (PSETID_MEETING, 0x0003);
+
+// Named properties of contacts (MS-OXOCNTC)
+const PID_LID_HTML: ([u8; 16], u32) = (PSETID_ADDRESS, 0x802B);
+const PID_LID_WORK_ADDRESS_STREET: ([u8; 16], u32) = (PSETID_ADDRESS, 0x8045);
+const PID_LID_WORK_ADDRESS_CITY: ([u8; 16], u32) = (PSETID_ADDRESS, 0x8046);
+const PID_LID_WORK_ADDRESS_STATE: ([u8; 16], u32) = (PSETID_ADDRESS, 0x8047);
+const PID_LID_WORK_ADDRESS_POSTAL_CODE: ([u8; 16], u32) = (PSETID_ADDRESS, 0x8048);
+const PID_LID_WORK_ADDRESS_COUNTRY: ([u8; 16], u32) = (PSETID_ADDRESS, 0x8049);
+const PID_LID_WORK_ADDRESS_POST_OFFICE_BOX: ([u8; 16], u32) = (PSETID_ADDRESS, 0x804A);
+/// Address type, address and original display name of Email1; Email2 and Email3 follow
+/// at offsets of 0x10 and 0x20
+const PID_LID_EMAIL1_ADDRESS_TYPE: ([u8; 16], u32) = (PSETID_ADDRESS, 0x8082);
+const PID_LID_EMAIL1_EMAIL_ADDRESS: ([u8; 16], u32) = (PSETID_ADDRESS, 0x8083);
+const PID_LID_EMAIL1_ORIGINAL_DISPLAY_NAME: ([u8; 16], u32) = (PSETID_ADDRESS, 0x8084);
+
+// Property types
+const PT_LONG: u16 = 0x0003;
+const PT_BOOLEAN: u16 = 0x000B;
+const PT_STRING8: u16 = 0x001E;
+const PT_UNICODE: u16 = 0x001F;
+const PT_SYSTIME: u16 = 0x0040;
+const PT_BINARY: u16 = 0x0102;
+
+/// Size of the property stream header: top-level message, recipient or attachment
+const MESSAGE_HEADER_LEN: usize = 32;
+const SUBOBJECT_HEADER_LEN: usize = 8;
+
+/// Property flags of written properties: readable and writable
+const PROPATTR_READABLE_WRITABLE: u32 = 0x0000_0006;
+/// PR_STORE_SUPPORT_MASK flag: strings are Unicode
+const STORE_UNICODE_OK: u32 = 0x0004_0000;
+/// PR_MESSAGE_FLAGS flag: the message has been read
+const MSGFLAG_READ: u32 = 0x0000_0001;
+/// PR_MESSAGE_FLAGS flag: the message is a draft
+const MSGFLAG_UNSENT: u32 = 0x0000_0008;
+/// PR_FLAG_STATUS value of a message flagged for follow-up
+const FOLLOWUP_FLAGGED: u32 = 2;
+/// PR_LAST_VERB_EXECUTED values: replied to the sender or to all, forwarded
+const EXCHIVERB_REPLYTOSENDER: u32 = 102;
+const EXCHIVERB_REPLYTOALL: u32 = 103;
+const EXCHIVERB_FORWARD: u32 = 104;
+/// PR_ATTACH_METHOD value: the data is in PR_ATTACH_DATA_BIN
+const ATTACH_BY_VALUE: u32 = 1;
+/// PR_ATTACH_METHOD value: the data is an OLE object in PR_ATTACH_DATA_OBJ
+const ATTACH_OLE: u32 = 6;
+/// PR_ATTACH_FLAGS flag: the attachment is referenced by the HTML body
+const ATT_MHTML_REF: u32 = 0x0000_0004;
+/// Code page of the HTML body
+const CP_UTF8: u32 = 65001;
+
+/// PR_RECIPIENT_FLAGS of the organizer of a meeting
+const RECIP_ORGANIZER: u32 = 0x0000_0002;
+
+/// Milliseconds between 1601-01-01 (FILETIME epoch) and 1970-01-01
+const FILETIME_UNIX_OFFSET_MS: i64 = 11_644_473_600_000;
+
+/// The properties of one storage (message, recipient or attachment); also used for the
+/// objects of PST files (see pst.rs)
+#[derive(Default)]
+pub(crate) struct Properties {
+    /// Variable-length values from `__substg1.0_IIIITTTT` streams: id -> (type, bytes)
+    streams: HashMap<u16, (u16, Vec<u8>)>,
+    /// Fixed-length values from `__properties_version1.0`: id -> (type, value)
+    fixed: HashMap<u16, (u16, u64)>,
+}
+
+fn read_stream<F: Read + Seek>(file: &mut CompoundFile<F>, path: &Path) -> io::Result<Vec<u8>> {
+    let mut data = Vec::new();
+    file.open_stream(path)?.read_to_end(&mut data)?;
+    Ok(data)
+}
+
+/// The property id and type of a `__substg1.0_IIIITTTT` stream name, None for other
+/// streams. Names come from the file and need not be ASCII.
+pub(crate) fn stream_tag(name: &str) -> Option<(u16, u16)> {
+    let tag = name.strip_prefix("__substg1.0_")?;
+    if tag.len() != 8 || !tag.bytes().all(|b| b.is_ascii_hexdigit()) {
+        return None;
+    }
+    let id = u16::from_str_radix(&tag[..4], 16).ok()?;
+    let kind = u16::from_str_radix(&tag[4..], 16).ok()?;
+    Some((id, kind))
+}
+
+fn decode_utf16(data: &[u8]) -> String {
+    let units: Vec<u16> = data
+        .chunks_exact(2)
+        .map(|pair| u16::from_le_bytes([pair[0], pair[1]]))
+        .collect();
+    String::from_utf16_lossy(&units).trim_end_matches('\0').to_string()
+}
+
+impl Properties {
+    fn read<F: Read + Seek>(
+        file: &mut CompoundFile<F>,
+        storage: &Path,
+        header_len: usize,
+    ) -> io::Result<Self> {
+        Self::read_filtered(file, storage, header_len, |_| true)
+    }
+
+    /// Like `read`, but only loads the variable-length values whose id passes the filter,
+    /// so large bodies and attachments can be skipped
+    fn read_filtered<F: Read + Seek>(
+        file: &mut CompoundFile<F>,
+        storage: &Path,
+        header_len: usize,
+        wanted: impl Fn(u16) -> bool,
+    ) -> io::Result<Self> {
+        let entries: Vec<(String, PathBuf)> = file
+            .read_storage(storage)?
+            .filter(|entry| entry.is_stream())
+            .map(|entry| (entry.name().to_string(), entry.path().to_path_buf()))
+            .collect();
+
+        let mut streams = HashMap::new();
+        for (name, path) in entries {
+            let Some((id, kind)) = stream_tag(&name) else {
+                continue;
+            };
+            if !wanted(id) {
+                continue;
+            }
+            streams.insert(id, (kind, read_stream(file, &path)?));
+        }
+
+        let mut fixed = HashMap::new();
+        let property_stream = storage.join("__properties_version1.0");
+        if file.is_stream(&property_stream) {
+            let data = read_stream(file, &property_stream)?;
+            for entry in data.get(header_len..).unwrap_or_default().chunks_exact(16) {
+                let kind = u16::from_le_bytes([entry[0], entry[1]]);
+                let id = u16::from_le_bytes([entry[2], entry[3]]);
+                let mut value = [0u8; 8];
+                value.copy_from_slice(&entry[8..16]);
+                fixed.insert(id, (kind, u64::from_le_bytes(value)));
+            }
+        }
+
+        Ok(Self { streams, fixed })
+    }
+
+    /// Add a value read elsewhere, e.g. from a PST file: fixed-length types (PT_SHORT to
+    /// PT_APPTIME, PT_ERROR, PT_BOOLEAN, PT_I8, PT_SYSTIME) as their little-endian bytes,
+    /// variable-length types as is
+    pub(crate) fn insert(&mut self, id: u16, kind: u16, data: Vec<u8>) {
+        if matches!(kind, 0x0002..=0x0007 | 0x000A | 0x000B | 0x0014 | PT_SYSTIME) {
+            let mut value = [0u8; 8];
+            let len = data.len().min(8);
+            value[..len].copy_from_slice(&data[..len]);
+            self.fixed.insert(id, (kind, u64::from_le_bytes(value)));
+        } else {
+            self.streams.insert(id, (kind, data));
+        }
+    }
+
+    /// String property; 8-bit strings are decoded as UTF-8 (lossy), whatever their code page
+    pub(crate) fn string(&self, id: u16) -> String {
+        match self.streams.get(&id) {
+            Some((PT_UNICODE, data)) => decode_utf16(data),
+            Some((PT_STRING8 | PT_BINARY, data)) => String::from_utf8_lossy(data)
+                .trim_end_matches('\0')
+                .to_string(),
+            _ => String::new(),
+        }
+    }
+
+    fn binary(&self, id: u16) -> Option<&[u8]> {
+        match self.streams.get(&id) {
+            Some((PT_BINARY, data)) => Some(data),
+            _ => None,
+        }
+    }
+
+    pub(crate) fn long(&self, id: u16) -> Option<u32> {
+        match self.fixed.get(&id) {
+            Some((PT_LONG, value)) => Some(*value as u32),
+            _ => None,
+        }
+    }
+
+    fn boolean(&self, id: u16) -> Option<bool> {
+        match self.fixed.get(&id) {
+            Some((PT_BOOLEAN, value)) => Some(*value & 0xFF != 0),
+            _ => None,
+        }
+    }
+
+    /// Time property as Unix milliseconds
+    pub(crate) fn time(&self, id: u16) -> Option<i64> {
+        match self.fixed.get(&id) {
+            Some((PT_SYSTIME, value)) if *value > 0 => {
+                Some((*value / 10_000) as i64 - FILETIME_UNIX_OFFSET_MS)
+            }
+            _ => None,
+        }
+    }
+
+    /// First non-empty string of several properties
+    pub(crate) fn first_string(&self, ids: &[u16]) -> String {
+        ids.iter()
+            .map(|id| self.string(*id))
+            .find(|value| !value.is_empty())
+            .unwrap_or_default()
+    }
+}
+
+/// Substorages of the root whose names start with a prefix, in order
+fn substorages<F>(file: &CompoundFile<F>, prefix: &str) -> Vec<PathBuf> {
+    let mut paths: Vec<PathBuf> = file
+        .read_root_storage()
+        .filter(|entry| entry.is_storage() && entry.name().starts_with(prefix))
+        .map(|entry| entry.path().to_path_buf())
+        .collect();
+    paths.sort();
+    paths
+}
+
+pub(crate) fn recipient(properties: &Properties) -> Recipient {
+    Recipient {
+        name: properties.string(PR_DISPLAY_NAME),
+        email: properties.first_string(&[PR_SMTP_ADDRESS, PR_EMAIL_ADDRESS]),
+        kind: match properties.long(PR_RECIPIENT_TYPE) {
+            Some(2) => "cc",
+            Some(3) => "bcc",
+            _ => "to",
+        },
+    }
+}
+
+/// Attachments without binary data (embedded messages, OLE objects) are left out; OLE
+/// objects are unwrapped by `ole_object`
+pub(crate) fn attachment(properties: &Properties) -> Option<Attachment> {
+    let data = properties.binary(PR_ATTACH_DATA_BIN)?;
+    Some(Attachment {
+        content_base64: STANDARD.encode(data),
+        ..attachment_info(properties, data.len())
+    })
+}
+
+/// An attachment without its content
+fn attachment_info(properties: &Properties, size: usize) -> Attachment {
+    let mime_type = properties.string(PR_ATTACH_MIME_TAG);
+    Attachment {
+        file_name: properties.first_string(&[PR_ATTACH_LONG_FILENAME, PR_ATTACH_FILENAME]),
+        mime_type: if mime_type.is_empty() {
+            "application/octet-stream".to_string()
+        } else {
+            mime_type
+        },
+        content_id: properties.string(PR_ATTACH_CONTENT_ID),
+        size,
+        content_base64: String::new(),
+    }
+}
+
+/// An OLE object (Excel range, equation, packaged file, ...) embedded instead of a normal
+/// attachment, unwrapped into the file it holds; None for other attachments
+fn ole_object<F: Read + Seek>(
+    file: &mut CompoundFile<F>,
+    storage: &Path,
+    properties: &Properties,
+) -> Option<Attachment> {
+    if properties.long(PR_ATTACH_METHOD) != Some(ATTACH_OLE) {
+        return None;
+    }
+    let object = storage.join(ATTACH_OBJECT_STORAGE);
+    if !file.is_storage(&object) {
+        return None;
+    }
+    match ole::extract(file, &object) {
+        Ok(object) => Some(ole_attachment(properties, object)),
+        Err(e) => {
+            log_warn!("Skipping OLE object in {}: {}", storage.display(), e);
+            None
+        }
+    }
+}
+
+/// Packaged files keep their name; other objects are named after the attachment, whose
+/// display name is usually the type of the object ("Microsoft Excel Worksheet")
+fn ole_attachment(properties: &Properties, object: OleObject) -> Attachment {
+    let name = properties.first_string(&[
+        PR_ATTACH_LONG_FILENAME,
+        PR_ATTACH_FILENAME,
+        PR_DISPLAY_NAME,
+    ]);
+    let file_name = object.file_name.unwrap_or_else(|| {
+        let stem = Some(name.as_str())
+            .filter(|name| !name.is_empty())
+            .unwrap_or("Embedded object");
+        if ole::extension(stem) == object.extension {
+            stem.to_string()
+        } else {
+            format!("{}.{}", stem, object.extension)
+        }
+    });
+    Attachment {
+        mime_type: ole::mime_type(&ole::extension(&file_name)).to_string(),
+        file_name,
+        content_id: properties.string(PR_ATTACH_CONTENT_ID),
+        size: object.data.len(),
+        content_base64: STANDARD.encode(&object.data),
+    }
+}
+
+/// The OLE objects of an .msg file with their index among its attachments
+#[derive(serde::Serialize)]
+#[serde(rename_all = "camelCase")]
+pub struct OleObjects {
+    /// Number of attachments of the message, OLE objects included
+    pub attachment_count: usize,
+    pub objects: Vec<(usize, Attachment)>,
+}
+
+/// Only the OLE objects of an .msg file, unwrapped as by `parse`, for parsers that show
+/// them as unreadable attachments
+pub fn ole_objects(path: &Path) -> Result<OleObjects, String> {
+    let mut file = cfb::open(path).map_err(|e| format!("Not an MSG file: {}", e))?;
+    let storages = substorages(&file, "__attach_version1.0_");
+    let mut objects = Vec::new();
+    for (index, storage) in storages.iter().enumerate() {
+        let properties =
+            Properties::read_filtered(&mut file, storage, SUBOBJECT_HEADER_LEN, |id| {
+                id != PR_ATTACH_DATA_BIN
+            })
+            .map_err(|e| format!("Failed to read MSG file: {}", e))?;
+        if let Some(attachment) = ole_object(&mut file, storage, &properties) {
+            objects.push((index, attachment));
+        }
+    }
+    Ok(OleObjects {
+        attachment_count: storages.len(),
+        objects,
+    })
+}
+
+/// Parse an Outlook .msg file (OLE compound file, MS-OXMSG).
+/// Covers the common properties. Messages without an HTML body get the HTML of their
+/// compressed RTF body, see `rtf::to_html`; the text body is left as stored.
+pub fn parse(path: &Path) -> Result<Message, String> {
+    let file = cfb::open(path).map_err(|e| format!("Not an MSG file: {}", e))?;
+    parse_compound_file(file, u64::MAX).map(|(message, _)| message)
+}
+
+/// Parse an .msg file in memory, see `parse`
+pub fn parse_bytes(data: &[u8]) -> Result<Message, String> {
+    let file = CompoundFile::open(Cursor::new(data))
+        .map_err(|e| format!("Not an MSG file: {}", e))?;
+    parse_compound_file(file, u64::MAX).map(|(message, _)| message)
+}
+
+/// Parse an .msg file in memory without reading attachments larger than `eager_limit`
+/// bytes: they are returned without content, together with their index and the stream
+/// to read it from with `read_attachment`
+pub fn parse_lazy(
+    data: &[u8],
+    eager_limit: u64,
+) -> Result<(Message, Vec<(usize, PathBuf)>), String> {
+    let file = CompoundFile::open(Cursor::new(data))
+        .map_err(|e| format!("Not an MSG file: {}", e))?;
+    parse_compound_file(file, eager_limit)
+}
+
+/// Content of an attachment left out by `parse_lazy`
+pub fn read_attachment(data: &[u8], stream: &Path) -> Result<Vec<u8>, String> {
+    let mut file = CompoundFile::open(Cursor::new(data))
+        .map_err(|e| format!("Not an MSG file: {}", e))?;
+    read_stream(&mut file, stream).map_err(|e| format!("Failed to read attachment: {}", e))
+}
+
+fn parse_compound_file<F: Read + Seek>(
+    mut file: CompoundFile<F>,
+    eager_limit: u64,
+) -> Result<(Message, Vec<(usize, PathBuf)>), String> {
+    let read_error = |e: io::Error| format!("Failed to read MSG file: {}", e);
+
+    let root =
+        Properties::read(&mut file, Path::new("/"), MESSAGE_HEADER_LEN).map_err(read_error)?;
+
+    let mut recipients = Vec::new();
+    for storage in substorages(&file, "__recip_version1.0_") {
+        let properties =
+            Properties::read(&mut file, &storage, SUBOBJECT_HEADER_LEN).map_err(read_error)?;
+        recipients.push(recipient(&properties));
+    }
+
+    let mut attachments = Vec::new();
+    let mut deferred = Vec::new();
+    for storage in substorages(&file, "__attach_version1.0_") {
+        let data_stream = storage.join(ATTACH_DATA_STREAM);
+        let size = file
+            .entry(&data_stream)
+            .ok()
+            .filter(|entry| entry.is_stream())
+            .map(|entry| entry.len());
+        let defer = size.is_some_and(|size| size > eager_limit);
+        let properties =
+            Properties::read_filtered(&mut file, &storage, SUBOBJECT_HEADER_LEN, |id| {
+                !(defer && id == PR_ATTACH_DATA_BIN)
+            })
+            .map_err(read_error)?;
+        if let Some(object) = ole_object(&mut file, &storage, &properties) {
+            attachments.push(object);
+            continue;
+        }
+        match size {
+            Some(size) if defer => {
+                deferred.push((attachments.len(), data_stream));
+                attachments.push(attachment_info(&properties, size as usize));
+            }
+            _ => attachments.extend(attachment(&properties)),
+        }
+    }
+
+    Ok((message(&root, recipients, attachments), deferred))
+}
+
+/// Message from the top-level properties and the already converted subobjects
+pub(crate) fn message(
+    root: &Properties,
+    recipients: Vec<Recipient>,
+    attachments: Vec<Attachment>,
+) -> Message {
+    Message {
+        subject: root.string(PR_SUBJECT),
+        sender_name: root.string(PR_SENDER_NAME),
+        sender_email: root.first_string(&[PR_SENDER_SMTP_ADDRESS, PR_SENDER_EMAIL_ADDRESS]),
+        recipients,
+        date: root
+            .time(PR_MESSAGE_DELIVERY_TIME)
+            .or_else(|| root.time(PR_CLIENT_SUBMIT_TIME)),
+        message_id: root.string(PR_INTERNET_MESSAGE_ID),
+        headers: root.string(PR_TRANSPORT_MESSAGE_HEADERS),
+        body_text: root.string(PR_BODY),
+        body_html: body_html(root),
+        attachments,
+    }
+}
+
+/// Read, draft, follow-up flag and last reply or forward from the top-level properties
+pub(crate) fn status(root: &Properties) -> MessageStatus {
+    let flags = root.long(PR_MESSAGE_FLAGS).unwrap_or(0);
+    let verb = root.long(PR_LAST_VERB_EXECUTED);
+    MessageStatus {
+        read: flags & MSGFLAG_READ != 0,
+        draft: flags & MSGFLAG_UNSENT != 0,
+        flagged: root.long(PR_FLAG_STATUS) == Some(FOLLOWUP_FLAGGED),
+        replied: matches!(verb, Some(EXCHIVERB_REPLYTOSENDER | EXCHIVERB_REPLYTOALL)),
+        forwarded: verb == Some(EXCHIVERB_FORWARD),
+        deleted: false,
+    }
+}
+
+/// Status of an .msg file in memory, see `status`; only the fixed-length properties
+/// are read
+pub fn status_bytes(data: &[u8]) -> Result<MessageStatus, String> {
+    let mut file = CompoundFile::open(Cursor::new(data))
+        .map_err(|e| format!("Not an MSG file: {}", e))?;
+    let root =
+        Properties::read_filtered(&mut file, Path::new("/"), MESSAGE_HEADER_LEN, |_| false)
+            .map_err(|e| format!("Failed to read MSG file: {}", e))?;
+    Ok(status(&root))
+}
+
+/// PR_BODY_HTML, or the HTML of PR_RTF_COMPRESSED for messages that only have an RTF body
+fn body_html(root: &Properties) -> String {
+    let html = root.string(PR_BODY_HTML);
+    if !html.trim().is_empty() {
+        return html;
+    }
+    let Some(compressed) = root.binary(PR_RTF_COMPRESSED) else {
+        return html;
+    };
+    match rtf::decompress(compressed) {
+        Ok(data) => rtf::to_html(&data),
+        Err(e) => {
+            log_warn!("Failed to decompress RTF body: {}", e);
+            html
+        }
+    }
+}
+
+/// Subject, sender and date of an .msg file without reading bodies, recipients or
+/// attachments (for listing folders)
+pub fn summary(path: &Path) -> Result<MessageSummary, String> {
+    const SUMMARY_PROPERTIES: [u16; 4] = [
+        PR_SUBJECT,
+        PR_SENDER_NAME,
+        PR_SENDER_SMTP_ADDRESS,
+        PR_SENDER_EMAIL_ADDRESS,
+    ];
+
+    let mut file = cfb::open(path).map_err(|e| format!("Not an MSG file: {}", e))?;
+    let root = Properties::read_filtered(&mut file, Path::new("/"), MESSAGE_HEADER_LEN, |id| {
+        SUMMARY_PROPERTIES.contains(&id)
+    })
+    .map_err(|e| format!("Failed to read MSG file: {}", e))?;
+
+    Ok(MessageSummary {
+        subject: root.string(PR_SUBJECT),
+        sender_name: root.string(PR_SENDER_NAME),
+        sender_email: root.first_string(&[PR_SENDER_SMTP_ADDRESS, PR_SENDER_EMAIL_ADDRESS]),
+        date: root
+            .time(PR_MESSAGE_DELIVERY_TIME)
+            .or_else(|| root.time(PR_CLIENT_SUBMIT_TIME)),
+    })
+}
+
+/// Raw transport headers of an .msg file in memory, empty if the message has none
+pub fn transport_headers(data: &[u8]) -> Result<String, String> {
+    let mut file = CompoundFile::open(Cursor::new(data))
+        .map_err(|e| format!("Not an MSG file: {}", e))?;
+    let root = Properties::read_filtered(&mut file, Path::new("/"), MESSAGE_HEADER_LEN, |id| {
+        id == PR_TRANSPORT_MESSAGE_HEADERS
+    })
+    .map_err(|e| format!("Failed to read MSG file: {}", e))?;
+    Ok(root.string(PR_TRANSPORT_MESSAGE_HEADERS))
+}
+
+/// PR_CONVERSATION_INDEX of an .msg file, which Outlook keeps for the whole conversation
+/// (its first 22 bytes are the same in every reply). None if the message has none.
+pub fn conversation_index(path: &Path) -> Result<Option<Vec<u8>>, String> {
+    let mut file = cfb::open(path).map_err(|e| format!("Not an MSG file: {}", e))?;
+    let root = Properties::read_filtered(&mut file, Path::new("/"), MESSAGE_HEADER_LEN, |id| {
+        id == PR_CONVERSATION_INDEX
+    })
+    .map_err(|e| format!("Failed to read MSG file: {}", e))?;
+    Ok(root.binary(PR_CONVERSATION_INDEX).map(|index| index.to_vec()))
+}
+
+/// Property ids of the numeric named properties of a message, by property set and long
+/// id (MS-OXMSG section 2.2.3). Named properties with string names are left out.
+fn named_properties<F: Read + Seek>(file: &mut CompoundFile<F>) -> HashMap<([u8; 16], u32), u16> {
+    let name_id = Path::new("/__nameid_version1.0");
+    let guids = read_stream(file, &name_id.join("__substg1.0_00020102")).unwrap_or_default();
+    let entries = read_stream(file, &name_id.join("__substg1.0_00030102")).unwrap_or_default();
+
+    let mut ids = HashMap::new();
+    for entry in entries.chunks_exact(8) {
+        let name = u32::from_le_bytes([entry[0], entry[1], entry[2], entry[3]]);
+        let index_and_kind = u16::from_le_bytes([entry[4], entry[5]]);
+        let property_index = u16::from_le_bytes([entry[6], entry[7]]);
+        if index_and_kind & 1 != 0 {
+            continue;
+        }
+        // 1 and 2 stand for PS_MAPI and PS_PUBLIC_STRINGS; the GUID stream starts at 3
+        let Some(guid) = ((index_and_kind >> 1) as usize)
+            .checked_sub(3)
+            .and_then(|index| guids.get(index * 16..index * 16 + 16))
+        else {
+            continue;
+        };
+        let mut set = [0u8; 16];
+        set.copy_from_slice(guid);
+        ids.insert((set, name), 0x8000 + property_index);
+    }
+    ids
+}
+
+fn track_status(properties: &Properties) -> &'static str {
+    match properties.long(PR_RECIPIENT_TRACKSTATUS) {
+        Some(2) => "TENTATIVE",
+        Some(3) => "ACCEPTED",
+        Some(4) => "DECLINED",
+        Some(5) => "NEEDS-ACTION",
+        _ => "",
+    }
+}
+
+/// Meeting request, response or cancellation (message class IPM.Schedule.Meeting.*) of
+/// an .msg file in memory; None for other messages
+pub fn meeting(data: &[u8]) -> Result<Option<Meeting>, String> {
+    let mut file = CompoundFile::open(Cursor::new(data))
+        .map_err(|e| format!("Not an MSG file: {}", e))?;
+    let read_error = |e: io::Error| format!("Failed to read MSG file: {}", e);
+
+    let root =
+        Properties::read(&mut file, Path::new("/"), MESSAGE_HEADER_LEN).map_err(read_error)?;
+    let class = root.string(PR_MESSAGE_CLASS);
+    let Some(kind) = class
+        .get(..21)
+        .filter(|prefix| prefix.eq_ignore_ascii_case("IPM.Schedule.Meeting."))
+        .map(|_| class[21..].to_ascii_lowercase())
+    else {
+        return Ok(None);
+    };
+    let (method, response) = match kind.as_str() {
+        "request" => ("REQUEST", ""),
+        "canceled" => ("CANCEL", ""),
+        "resp.pos" => ("REPLY", "ACCEPTED"),
+        "resp.neg" => ("REPLY", "DECLINED"),
+        "resp.tent" => ("REPLY", "TENTATIVE"),
+        _ => ("PUBLISH", ""),
+    };
+
+    let named = named_properties(&mut file);
+    let named_id = |property: ([u8; 16], u32)| named.get(&property).copied();
+    let time = |property, fallback| {
+        named_id(property)
+            .and_then(|id| root.time(id))
+            .or_else(|| root.time(fallback))
+    };
+    let all_day = named_id(PID_LID_APPOINTMENT_SUB_TYPE)
+        .and_then(|id| root.boolean(id))
+        .unwrap_or(false);
+    let mut start = time(PID_LID_APPOINTMENT_START_WHOLE, PR_START_DATE);
+    let mut end = time(PID_LID_APPOINTMENT_END_WHOLE, PR_END_DATE);
+    if all_day {
+        start = start.map(calendar::nearest_midnight);
+        end = end.map(calendar::nearest_midnight);
+    }
+
+    let sender = Attendee {
+        name: root.first_string(&[PR_SENT_REPRESENTING_NAME, PR_SENDER_NAME]),
+        email: root.first_string(&[
+            PR_SENT_REPRESENTING_EMAIL_ADDRESS,
+            PR_SENDER_SMTP_ADDRESS,
+            PR_SENDER_EMAIL_ADDRESS,
+        ]),
+        role: "CHAIR".to_string(),
+        status: String::new(),
+    };
+    let mut organizer = None;
+    let mut attendees = Vec::new();
+    for storage in substorages(&file, "__recip_version1.0_") {
+        let properties =
+            Properties::read(&mut file, &storage, SUBOBJECT_HEADER_LEN).map_err(read_error)?;
+        let recipient = recipient(&properties);
+        let is_organizer = properties.long(PR_RECIPIENT_FLAGS).unwrap_or(0) & RECIP_ORGANIZER != 0;
+        let attendee = Attendee {
+            name: recipient.name,
+            email: recipient.email,
+            role: match recipient.kind {
+                "cc" => "OPT-PARTICIPANT",
+                "bcc" => "NON-PARTICIPANT",
+                _ => "REQ-PARTICIPANT",
+            }
+            .to_string(),
+            status: track_status(&properties).to_string(),
+        };
+        if is_organizer || attendee.email.eq_ignore_ascii_case(&sender.email) {
+            continue;
+        }
+        // A response is sent from an attendee to the organizer
+        if method == "REPLY" && organizer.is_none() {
+            organizer = Some(Attendee {
+                role: "CHAIR".to_string(),
+                ..attendee
+            });
+            continue;
+        }
+        attendees.push(attendee);
+    }
+    if method == "REPLY" {
+        attendees = vec![Attendee {
+            role: "REQ-PARTICIPANT".to_string(),
+            status: response.to_string(),
+            ..sender
+        }];
+    } else {
+        organizer = Some(sender);
+    }
+
+    Ok(Some(Meeting {
+        method: method.to_string(),
+        uid: named_id(PID_LID_GLOBAL_OBJECT_ID)
+            .and_then(|id| root.binary(id))
+            .map(|id| id.iter().map(|byte| format!("{:02X}", byte)).collect())
+            .unwrap_or_else(|| root.string(PR_INTERNET_MESSAGE_ID)),
+        sequence: named_id(PID_LID_APPOINTMENT_SEQUENCE)
+            .and_then(|id| root.long(id))
+            .unwrap_or(0),
+        summary: root.first_string(&[PR_CONVERSATION_TOPIC, PR_SUBJECT]),
+        location: named_id(PID_LID_LOCATION)
+            .map(|id| root.string(id))
+            .unwrap_or_default(),
+        description: root.string(PR_BODY),
+        start,
+        end,
+        all_day,
+        floating: false,
+        time_zone: String::new(),
+        organizer,
+        attendees,
+        ics: None,
+    }))
+}
+
+/// MIME type of a contact photo by the extension of its file name
+fn photo_type(file_name: &str) -> &'static str {
+    let extension = file_name.rsplit('.').next().unwrap_or_default();
+    match extension.to_ascii_lowercase().as_str() {
+        "png" => "image/png",
+        "gif" => "image/gif",
+        "bmp" => "image/bmp",
+        _ => "image/jpeg",
+    }
+}
+
+/// Contact (message class IPM.Contact, MS-OXOCNTC) of an .msg file in memory; None for
+/// other messages
+pub fn contact(data: &[u8]) -> Result<Option<Contact>, String> {
+    let mut file = CompoundFile::open(Cursor::new(data))
+        .map_err(|e| format!("Not an MSG file: {}", e))?;
+    let read_error = |e: io::Error| format!("Failed to read MSG file: {}", e);
+
+    let root =
+        Properties::read(&mut file, Path::new("/"), MESSAGE_HEADER_LEN).map_err(read_error)?;
+    let class = root.string(PR_MESSAGE_CLASS);
+    let is_contact = class
+        .get(..11)
+        .is_some_and(|prefix| prefix.eq_ignore_ascii_case("IPM.Contact"))
+        && matches!(class.as_bytes().get(11), None | Some(b'.'));
+    if !is_contact {
+        return Ok(None);
+    }
+
+    let named = named_properties(&mut file);
+    let named_string = |(set, id): ([u8; 16], u32)| {
+        named
+            .get(&(set, id))
+            .map(|id| root.string(*id))
+            .unwrap_or_default()
+    };
+
+    let mut emails: Vec<String> = Vec::new();
+    for offset in [0x00, 0x10, 0x20] {
+        let (set, id) = PID_LID_EMAIL1_EMAIL_ADDRESS;
+        let address = named_string((set, id + offset));
+        // Exchange contacts keep an X.500 address; the SMTP one is in the display name
+        let address = if named_string((set, PID_LID_EMAIL1_ADDRESS_TYPE.1 + offset))
+            .eq_ignore_ascii_case("EX")
+        {
+            let original = named_string((set, PID_LID_EMAIL1_ORIGINAL_DISPLAY_NAME.1 + offset));
+            original
+                .rsplit(['(', '<'])
+                .next()
+                .unwrap_or_default()
+                .trim_end_matches([')', '>'])
+                .trim()
+                .to_string()
+        } else {
+            address.trim().to_string()
+        };
+        if address.contains('@') && !emails.iter().any(|e| e.eq_ignore_ascii_case(&address)) {
+            emails.push(address);
+        }
+    }
+
+    let phones = [
+        ("work", PR_BUSINESS_TELEPHONE_NUMBER),
+        ("work", PR_BUSINESS2_TELEPHONE_NUMBER),
+        ("main", PR_COMPANY_MAIN_PHONE_NUMBER),
+        ("home", PR_HOME_TELEPHONE_NUMBER),
+        ("home", PR_HOME2_TELEPHONE_NUMBER),
+        ("cell", PR_MOBILE_TELEPHONE_NUMBER),
+        ("car", PR_CAR_TELEPHONE_NUMBER),
+        ("pager", PR_PAGER_TELEPHONE_NUMBER),
+        ("fax", PR_BUSINESS_FAX_NUMBER),
+        ("fax", PR_HOME_FAX_NUMBER),
+        ("other", PR_PRIMARY_TELEPHONE_NUMBER),
+        ("other", PR_OTHER_TELEPHONE_NUMBER),
+    ]
+    .into_iter()
+    .map(|(kind, id)| Phone {
+        kind,
+        number: root.string(id).trim().to_string(),
+    })
+    .filter(|phone| !phone.number.is_empty())
+    .collect();
+
+    // The business address is kept twice; older files only have the PR_ properties
+    let work_string = |property: ([u8; 16], u32), fallback: u16| {
+        let value = named_string(property);
+        if value.is_empty() {
+            root.string(fallback)
+        } else {
+            value
+        }
+    };
+    let addresses = [
+        PostalAddress {
+            kind: "work",
+            po_box: work_string(PID_LID_WORK_ADDRESS_POST_OFFICE_BOX, PR_POST_OFFICE_BOX),
+            street: work_string(PID_LID_WORK_ADDRESS_STREET, PR_STREET_ADDRESS),
+            city: work_string(PID_LID_WORK_ADDRESS_CITY, PR_LOCALITY),
+            region: work_string(PID_LID_WORK_ADDRESS_STATE, PR_STATE_OR_PROVINCE),
+            postal_code: work_string(PID_LID_WORK_ADDRESS_POSTAL_CODE, PR_POSTAL_CODE),
+            country: work_string(PID_LID_WORK_ADDRESS_COUNTRY, PR_COUNTRY),
+        },
+        PostalAddress {
+            kind: "home",
+            po_box: root.string(PR_HOME_ADDRESS_POST_OFFICE_BOX),
+            street: root.string(PR_HOME_ADDRESS_STREET),
+            city: root.string(PR_HOME_ADDRESS_CITY),
+            region: root.string(PR_HOME_ADDRESS_STATE_OR_PROVINCE),
+            postal_code: root.string(PR_HOME_ADDRESS_POSTAL_CODE),
+            country: root.string(PR_HOME_ADDRESS_COUNTRY),
+        },
+        PostalAddress {
+            kind: "other",
+            po_box: root.string(PR_OTHER_ADDRESS_POST_OFFICE_BOX),
+            street: root.string(PR_OTHER_ADDRESS_STREET),
+            city: root.string(PR_OTHER_ADDRESS_CITY),
+            region: root.string(PR_OTHER_ADDRESS_STATE_OR_PROVINCE),
+            postal_code: root.string(PR_OTHER_ADDRESS_POSTAL_CODE),
+            country: root.string(PR_OTHER_ADDRESS_COUNTRY),
+        },
+    ]
+    .into_iter()
+    .filter(|address| !address.is_empty())
+    .collect();
+
+    let mut websites: Vec<String> = Vec::new();
+    for website in [
+        named_string(PID_LID_HTML),
+        root.string(PR_BUSINESS_HOME_PAGE),
+        root.string(PR_PERSONAL_HOME_PAGE),
+    ] {
+        let website = website.trim().to_string();
+        if !website.is_empty() && !websites.contains(&website) {
+            websites.push(website);
+        }
+    }
+
+    let mut photo = None;
+    for storage in substorages(&file, "__attach_version1.0_") {
+        let properties =
+            Properties::read(&mut file, &storage, SUBOBJECT_HEADER_LEN).map_err(read_error)?;
+        if !properties.boolean(PR_ATTACHMENT_CONTACTPHOTO).unwrap_or(false) {
+            continue;
+        }
+        if let Some(data) = properties.binary(PR_ATTACH_DATA_BIN) {
+            let mut mime_type = properties.string(PR_ATTACH_MIME_TAG);
+            if !mime_type.starts_with("image/") {
+                let file_name =
+                    properties.first_string(&[PR_ATTACH_LONG_FILENAME, PR_ATTACH_FILENAME]);
+                mime_type = photo_type(&file_name).to_string();
+            }
+            photo = Some(format!(
+                "data:{};base64,{}",
+                mime_type,
+                STANDARD.encode(data)
+            ));
+            break;
+        }
+    }
+
+    Ok(Some(Contact {
+        display_name: root.first_string(&[PR_DISPLAY_NAME, PR_SUBJECT]),
+        prefix: root.string(PR_DISPLAY_NAME_PREFIX),
+        given_name: root.string(PR_GIVEN_NAME),
+        middle_name: root.string(PR_MIDDLE_NAME),
+        surname: root.string(PR_SURNAME),
+        suffix: root.string(PR_GENERATION),
+        nickname: root.string(PR_NICKNAME),
+        company: root.string(PR_COMPANY_NAME),
+        department: root.string(PR_DEPARTMENT_NAME),
+        job_title: root.string(PR_TITLE),
+        emails,
+        phones,
+        addresses,
+        // Dates are stored as local midnight in UTC
+        birthday: root.time(PR_BIRTHDAY).map(calendar::nearest_midnight),
+        anniversary: root
+            .time(PR_WEDDING_ANNIVERSARY)
+            .map(calendar::nearest_midnight),
+        websites,
+        notes: root.string(PR_BODY),
+        photo,
+    }))
+}
+
+/// Message as exported by the frontend (messageToJson), the input of `write`
+#[derive(serde::Deserialize, Default)]
+#[serde(rename_all = "camelCase", default)]
+struct ExportedMessage {
+    subject: String,
+    sender_name: String,
+    sender_email: String,
+    recipients: Vec<ExportedRecipient>,
+    /// ISO 8601 in UTC, as written by Date.toISOString()
+    date: String,
+    message_id: String,
+    /// Raw transport headers, only sent where they matter, e.g. to compare_messages;
+    /// messageToJson itself has them as a map
+    raw_headers: String,
+    body_text: String,
+    body_html: String,
+    attachments: Vec<ExportedAttachment>,
+}
+
+#[derive(serde::Deserialize, Default)]
+#[serde(default)]
+struct ExportedRecipient {
+    name: String,
+    email: String,
+    #[serde(rename = "type")]
+    kind: String,
+}
+
+#[derive(serde::Deserialize, Default)]
+#[serde(rename_all = "camelCase", default)]
+struct ExportedAttachment {
+    file_name: String,
+    mime_type: String,
+    content_id: String,
+    content_base64: String,
+}
+
+impl From<Message> for ExportedMessage {
+    fn from(message: Message) -> Self {
+        ExportedMessage {
+            subject: message.subject,
+            sender_name: message.sender_name,
+            sender_email: message.sender_email,
+            recipients: message
+                .recipients
+                .into_iter()
+                .map(|recipient| ExportedRecipient {
+                    name: recipient.name,
+                    email: recipient.email,
+                    kind: recipient.kind.to_string(),
+                })
+                .collect(),
+            // Passed to write_exported separately
+            date: String::new(),
+            message_id: message.message_id,
+            raw_headers: message.headers,
+            body_text: message.body_text,
+            body_html: message.body_html,
+            attachments: message
+                .attachments
+                .into_iter()
+                .map(|attachment| ExportedAttachment {
+                    file_name: attachment.file_name,
+                    mime_type: attachment.mime_type,
+                    content_id: attachment.content_id,
+                    content_base64: attachment.content_base64,
+                })
+                .collect(),
+        }
+    }
+}
+
+impl From<ExportedMessage> for Message {
+    fn from(message: ExportedMessage) -> Self {
+        Message {
+            subject: message.subject,
+            sender_name: message.sender_name,
+            sender_email: message.sender_email,
+            recipients: message
+                .recipients
+                .into_iter()
+                .map(|recipient| Recipient {
+                    name: recipient.name,
+                    email: recipient.email,
+                    kind: match recipient.kind.as_str() {
+                        "cc" => "cc",
+                        "bcc" => "bcc",
+                        _ => "to",
+                    },
+                })
+                .collect(),
+            date: parse_iso_millis(&message.date),
+            message_id: message.message_id,
+            headers: message.raw_headers,
+            body_text: message.body_text,
+            body_html: message.body_html,
+            attachments: message
+                .attachments
+                .into_iter()
+                .map(|attachment| Attachment {
+                    size: STANDARD
+                        .decode(&attachment.content_base64)
+                        .map_or(0, |data| data.len()),
+                    file_name: attachment.file_name,
+                    mime_type: attachment.mime_type,
+                    content_id: attachment.content_id,
+                    content_base64: attachment.content_base64,
+                })
+                .collect(),
+        }
+    }
+}
+
+/// Parse `2024-03-01T09:30:00.000Z` into Unix milliseconds
+fn parse_iso_millis(value: &str) -> Option<i64> {
+    let (date, time) = value.trim().strip_suffix('Z')?.split_once('T')?;
+    let mut date_parts = date.split('-').map(|part| part.parse::<i64>().ok());
+    let (year, month, day) = (date_parts.next()??, date_parts.next()??, date_parts.next()??);
+    let (clock, fraction) = time.split_once('.').unwrap_or((time, "0"));
+    let mut clock_parts = clock.split(':').map(|part| part.parse::<i64>().ok());
+    let (hour, minute, second) =
+        (clock_parts.next()??, clock_parts.next()??, clock_parts.next()??);
+    let millis: i64 = format!("{:0<3}", fraction).get(..3)?.parse().ok()?;
+
+    // Days since 1970-01-01 in the proleptic Gregorian calendar
+    let year = if month <= 2 { year - 1 } else { year };
+    let era = year.div_euclid(400);
+    let year_of_era = year - era * 400;
+    let day_of_year = (153 * ((month + 9) % 12) + 2) / 5 + day - 1;
+    let day_of_era = year_of_era * 365 + year_of_era / 4 - year_of_era / 100 + day_of_year;
+    let days = era * 146_097 + day_of_era - 719_468;
+
+    Some((((days * 24 + hour) * 60 + minute) * 60 + second) * 1000 + millis)
+}
+
+/// Properties of one storage, written as its property stream plus one stream per
+/// variable-length value
+#[derive(Default)]
+struct PropertyWriter {
+    fixed: Vec<(u16, u16, u64)>,
+    variable: Vec<(u16, u16, Vec<u8>)>,
+}
+
+impl PropertyWriter {
+    fn string(&mut self, id: u16, value: &str) {
+        if !value.is_empty() {
+            let bytes = value.encode_utf16().flat_map(u16::to_le_bytes).collect();
+            self.variable.push((id, PT_UNICODE, bytes));
+        }
+    }
+
+    fn binary(&mut self, id: u16, value: Vec<u8>) {
+        self.variable.push((id, PT_BINARY, value));
+    }
+
+    fn long(&mut self, id: u16, value: u32) {
+        self.fixed.push((id, PT_LONG, u64::from(value)));
+    }
+
+    fn time(&mut self, id: u16, unix_millis: i64) {
+        let filetime = (unix_millis + FILETIME_UNIX_OFFSET_MS).max(0) as u64 * 10_000;
+        self.fixed.push((id, PT_SYSTIME, filetime));
+    }
+
+    fn write(
+        &self,
+        file: &mut CompoundFile<Cursor<Vec<u8>>>,
+        storage: &Path,
+        header: &[u8],
+    ) -> io::Result<()> {
+        let mut properties = header.to_vec();
+        let mut entry = |kind: u16, id: u16, value: u64| {
+            properties.extend_from_slice(&kind.to_le_bytes());
+            properties.extend_from_slice(&id.to_le_bytes());
+            properties.extend_from_slice(&PROPATTR_READABLE_WRITABLE.to_le_bytes());
+            properties.extend_from_slice(&value.to_le_bytes());
+        };
+
+        for (id, kind, value) in &self.fixed {
+            entry(*kind, *id, *value);
+        }
+        for (id, kind, data) in &self.variable {
+            // The size of a string includes its terminator, which the stream leaves out
+            let terminator = if *kind == PT_UNICODE { 2 } else { 0 };
+            entry(*kind, *id, (data.len() + terminator) as u64);
+            let name = format!("__substg1.0_{:04X}{:04X}", id, kind);
+            file.create_stream(storage.join(name))?.write_all(data)?;
+        }
+
+        file.create_stream(storage.join("__properties_version1.0"))?
+            .write_all(&properties)
+    }
+}
+
+/// Header of a top-level property stream: next recipient and attachment ids and counts
+fn message_header(recipients: usize, attachments: usize) -> Vec<u8> {
+    let mut header = vec![0u8; 8];
+    for count in [recipients, attachments, recipients, attachments] {
+        header.extend_from_slice(&(count as u32).to_le_bytes());
+    }
+    header.resize(MESSAGE_HEADER_LEN, 0);
+    header
+}
+
+/// Display list of recipient names for PR_DISPLAY_TO / PR_DISPLAY_CC
+fn display_list(recipients: &[ExportedRecipient], kind: &str) -> String {
+    recipients
+        .iter()
+        .filter(|recipient| recipient.kind == kind)
+        .map(|recipient| {
+            if recipient.name.is_empty() {
+                recipient.email.as_str()
+            } else {
+                recipient.name.as_str()
+            }
+        })
+        .collect::<Vec<_>>()
+        .join("; ")
+}
+
+/// Build an Outlook .msg file (MS-OXMSG) from a message exported by the frontend.
+/// Bodies are written as Unicode text and UTF-8 HTML, attachments by value; inline
+/// images keep their Content-ID so the HTML body still references them.
+pub fn write(message_json: &str) -> Result<Vec<u8>, String> {
+    let message: ExportedMessage =
+        serde_json::from_str(message_json).map_err(|e| format!("Invalid message: {}", e))?;
+    let date = parse_iso_millis(&message.date);
+    write_exported(&message, date)
+}
+
+/// A message exported by the frontend (messageToJson) as a backend message, so it can go
+/// through the same steps as a parsed one, e.g. redact::redact and eml::write
+pub fn parse_exported(message_json: &str) -> Result<Message, String> {
+    let message: ExportedMessage =
+        serde_json::from_str(message_json).map_err(|e| format!("Invalid message: {}", e))?;
+    Ok(message.into())
+}
+
+/// Build an .msg file from a message parsed in the backend, e.g. one read from a PST file
+pub fn write_message(message: Message) -> Result<Vec<u8>, String> {
+    let date = message.date;
+    write_exported(&ExportedMessage::from(message), date)
+}
+
+fn write_exported(message: &ExportedMessage, date: Option<i64>) -> Result<Vec<u8>, String> {
+    let write_error = |e: io::Error| format!("Failed to write MSG file: {}", e);
+
+    let mut file = CompoundFile::create(Cursor::new(Vec::new())).map_err(write_error)?;
+
+    // Named property mapping, required even when no named properties are used
+    let name_id = Path::new("/__nameid_version1.0");
+    file.create_storage(name_id).map_err(write_error)?;
+    for stream in ["__substg1.0_00020102", "__substg1.0_00030102", "__substg1.0_00040102"] {
+        file.create_stream(name_id.join(stream)).map_err(write_error)?;
+    }
+
+    let mut root = PropertyWriter::default();
+    root.string(PR_MESSAGE_CLASS, "IPM.Note");
+    root.long(PR_STORE_SUPPORT_MASK, STORE_UNICODE_OK);
+    root.long(PR_MESSAGE_FLAGS, MSGFLAG_READ);
+    root.string(PR_SUBJECT, &message.subject);
+    root.string(PR_SENDER_NAME, &message.sender_name);
+    root.string(PR_SENT_REPRESENTING_NAME, &message.sender_name);
+    if !message.sender_email.is_empty() {
+        root.string(PR_SENDER_ADDRTYPE, "SMTP");
+        root.string(PR_SENDER_EMAIL_ADDRESS, &message.sender_email);
+        root.string(PR_SENDER_SMTP_ADDRESS, &message.sender_email);
+        root.string(PR_SENT_REPRESENTING_EMAIL_ADDRESS, &message.sender_email);
+    }
+    root.string(PR_DISPLAY_TO, &display_list(&message.recipients, "to"));
+    root.string(PR_DISPLAY_CC, &display_list(&message.recipients, "cc"));
+    if let Some(date) = date {
+        root.time(PR_CLIENT_SUBMIT_TIME, date);
+        root.time(PR_MESSAGE_DELIVERY_TIME, date);
+    }
+    root.string(PR_INTERNET_MESSAGE_ID, &message.message_id);
+    root.string(PR_BODY, &message.body_text);
+    if !message.body_html.is_empty() {
+        root.long(PR_INTERNET_CPID, CP_UTF8);
+        root.binary(PR_BODY_HTML, message.body_html.as_bytes().to_vec());
+    }
+    root.write(
+        &mut file,
+        Path::new("/"),
+        &message_header(message.recipients.len(), message.attachments.len()),
+    )
+    .map_err(write_error)?;
+
+    for (index, recipient) in message.recipients.iter().enumerate() {
+        let storage = PathBuf::from(format!("/__recip_version1.0_#{:08X}", index));
+        file.create_storage(&storage).map_err(write_error)?;
+
+        let mut properties = PropertyWriter::default();
+        properties.long(PR_ROWID, index as u32);
+        properties.long(
+            PR_RECIPIENT_TYPE,
+            match recipient.kind.as_str() {
+                "cc" => 2,
+                "bcc" => 3,
+                _ => 1,
+            },
+        );
+        let display_name = if recipient.name.is_empty() {
+            &recipient.email
+        } else {
+            &recipient.name
+        };
+        properties.string(PR_DISPLAY_NAME, display_name);
+        properties.string(PR_ADDRTYPE, "SMTP");
+        properties.string(PR_EMAIL_ADDRESS, &recipient.email);
+        properties.string(PR_SMTP_ADDRESS, &recipient.email);
+        properties
+            .write(&mut file, &storage, &[0u8; SUBOBJECT_HEADER_LEN])
+            .map_err(write_error)?;
+    }
+
+    for (index, attachment) in message.attachments.iter().enumerate() {
+        let data = STANDARD
+            .decode(&attachment.content_base64)
+            .map_err(|e| format!("Invalid attachment {}: {}", attachment.file_name, e))?;
+        let storage = PathBuf::from(format!("/__attach_version1.0_#{:08X}", index));
+        file.create_storage(&storage).map_err(write_error)?;
+
+        let mut properties = PropertyWriter::default();
+        properties.long(PR_ATTACH_NUM, index as u32);
+        properties.long(PR_ATTACH_METHOD, ATTACH_BY_VALUE);
+        properties.long(PR_RENDERING_POSITION, u32::MAX);
+        properties.string(PR_DISPLAY_NAME, &attachment.file_name);
+        properties.string(PR_ATTACH_FILENAME, &attachment.file_name);
+        properties.string(PR_ATTACH_LONG_FILENAME, &attachment.file_name);
+        properties.string(PR_ATTACH_MIME_TAG, &attachment.mime_type);
+        if !attachment.content_id.is_empty() {
+            properties.string(PR_ATTACH_CONTENT_ID, &attachment.content_id);
+            properties.long(PR_ATTACH_FLAGS, ATT_MHTML_REF);
+        }
+        properties.binary(PR_ATTACH_DATA_BIN, data);
+        properties
+            .write(&mut file, &storage, &[0u8; SUBOBJECT_HEADER_LEN])
+            .map_err(write_error)?;
+    }
+
+    file.flush().map_err(write_error)?;
+    Ok(file.into_inner().into_inner())
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    const MESSAGE_JSON: &str = r#"{
+        "subject": "Quarterly report",
+        "senderName": "Alice Example",
+        "senderEmail": "alice@example.com",
+        "recipients": [
+            {"name": "Bob", "email": "bob@example.com", "type": "to"},
+            {"name": "", "email": "carol@example.com", "type": "cc"}
+        ],
+        "date": "2024-03-01T12:30:00.000Z",
+        "messageId": "<report@example.com>",
+        "bodyText": "See attached.",
+        "bodyHtml": "<p>See attached.</p>",
+        "attachments": [
+            {"fileName": "report.txt", "mimeType": "text/plain", "contentBase64": "aGVsbG8="}
+        ]
+    }"#;
+
+    #[test]
+    fn stream_tag_reads_id_and_type() {
+        assert_eq!(stream_tag("__substg1.0_0037001F"), Some((0x0037, 0x001F)));
+        assert_eq!(stream_tag("__substg1.0_1000001f"), Some((0x1000, 0x001F)));
+    }
+
+    #[test]
+    fn stream_tag_rejects_other_names() {
+        assert_eq!(stream_tag("__properties_version1.0"), None);
+        assert_eq!(stream_tag("__substg1.0_0037001F-00000000"), None);
+        assert_eq!(stream_tag("__substg1.0_0037"), None);
+        assert_eq!(stream_tag("__substg1.0_+037001F"), None);
+        assert_eq!(stream_tag("__substg1.0_0037G01F"), None);
+    }
+
+    #[test]
+    fn stream_tag_rejects_multibyte_names() {
+        // Eight bytes, with a two-byte character across the id/type boundary
+        assert_eq!(stream_tag("__substg1.0_001é001"), None);
+        assert_eq!(stream_tag("__substg1.0_ééé00"), None);
+    }
+
+    #[test]
+    fn written_message_parses_back() {
+        let data = write(MESSAGE_JSON).unwrap();
+        let message = parse_bytes(&data).unwrap();
+        assert_eq!(message.subject, "Quarterly report");
+        assert_eq!(message.sender_name, "Alice Example");
+        assert_eq!(message.sender_email, "alice@example.com");
+        assert_eq!(message.date, Some(1_709_296_200_000));
+        assert_eq!(message.message_id, "<report@example.com>");
+        assert_eq!(message.body_text, "See attached.");
+        assert_eq!(message.body_html, "<p>See attached.</p>");
+
+        let recipients: Vec<(&str, &str)> = message
+            .recipients
+            .iter()
+            .map(|recipient| (recipient.email.as_str(), recipient.kind))
+            .collect();
+        assert_eq!(
+            recipients,
+            [("bob@example.com", "to"), ("carol@example.com", "cc")]
+        );
+
+        assert_eq!(message.attachments.len(), 1);
+        assert_eq!(message.attachments[0].file_name, "report.txt");
+        assert_eq!(message.attachments[0].size, 5);
+        assert_eq!(message.attachments[0].content_base64, "aGVsbG8=");
+    }
+
+    #[test]
+    fn stream_with_multibyte_name_is_skipped() {
+        let mut file = CompoundFile::create(Cursor::new(Vec::new())).unwrap();
+        let mut root = PropertyWriter::default();
+        root.string(PR_SUBJECT, "Hello");
+        root.write(&mut file, Path::new("/"), &message_header(0, 0))
+            .unwrap();
+        file.create_stream("/__substg1.0_001é001")
+            .unwrap()
+            .write_all(b"data")
+            .unwrap();
+        file.flush().unwrap();
+        let data = file.into_inner().into_inner();
+
+        let message = parse_bytes(&data).unwrap();
+        assert_eq!(message.subject, "Hello");
+    }
+
+    #[test]
+    fn malformed_input_is_an_error() {
+        assert!(parse_bytes(b"").is_err());
+        assert!(parse_bytes(b"From: alice@example.com\r\n\r\nHello").is_err());
+
+        let data = write(MESSAGE_JSON).unwrap();
+        assert!(parse_bytes(&data[..600]).is_err());
+    }
+
+    #[test]
+    fn invalid_export_is_an_error() {
+        assert!(write("not json").is_err());
+        assert!(parse_exported("42").is_err());
+    }
+}
@@ -0,0 +1,222 @@
+use crate::batch::ConversionReport;
+use crate::locale;
+use crate::message_window::MAIN_LABEL;
+use std::path::{Path, PathBuf};
+use tauri::{AppHandle, Manager};
+
+/// Emitted to the main window with the path of a message whose notification was clicked
+pub const OPEN_EVENT: &str = "notification-open";
+
+/// What a click on a notification opens
+pub enum Target {
+    /// A message that arrived in a watched folder
+    File(PathBuf),
+    /// The output folder of a batch conversion
+    Folder(PathBuf),
+    /// The main window, which shows the update dialog
+    Update,
+}
+
+/// An OS notification with its click-through target
+pub struct Notification {
+    pub title: String,
+    pub body: String,
+    pub target: Target,
+}
+
+/// A catalog string in the system language with its {name} placeholders filled in
+fn text(key: &str, params: &[(&str, &str)]) -> String {
+    let language = locale::system_locale();
+    params
+        .iter()
+        .fold(locale::text(&language, key), |text, (name, value)| {
+            text.replace(&format!("{{{}}}", name), value)
+        })
+}
+
+fn file_name(path: &Path) -> String {
+    path.file_name()
+        .map_or_else(|| path.display().to_string(), |name| name.to_string_lossy().to_string())
+}
+
+/// A new .msg/.eml file in a watched folder
+pub fn new_message(path: &Path) -> Notification {
+    let folder = path.parent().map(file_name).unwrap_or_default();
+    Notification {
+        title: text("notification.newMessage", &[]),
+        body: text(
+            "notification.newMessageBody",
+            &[("file", &file_name(path)), ("folder", &folder)],
+        ),
+        target: Target::File(path.to_path_buf()),
+    }
+}
+
+/// A batch conversion that ran to its end
+pub fn conversion_finished(report: &ConversionReport) -> Notification {
+    let key = if report.failed > 0 {
+        "notification.conversionFailedBody"
+    } else {
+        "notification.conversionFinishedBody"
+    };
+    Notification {
+        title: text("notification.conversionFinished", &[]),
+        body: text(
+            key,
+            &[
+                ("converted", &report.converted.to_string()),
+                ("failed", &report.failed.to_string()),
+                ("folder", &file_name(Path::new(&report.output))),
+            ],
+        ),
+        target: Target::Folder(PathBuf::from(&report.output)),
+    }
+}
+
+/// A newer release found by the update check
+pub fn update_available(app: &AppHandle, version: &str) -> Notification {
+    Notification {
+        title: text("notification.updateAvailable", &[]),
+        body: text(
+            "notification.updateAvailableBody",
+            &[("app", &app.package_info().name), ("version", version)],
+        ),
+        target: Target::Update,
+    }
+}
+
+/// OS notifications from the backend: toasts on Windows, the Notification Center on macOS
+/// and the freedesktop notification service on Linux. They are only shown while the main
+/// window is not in front, e.g. hidden in the tray or behind other windows.
+pub struct Notifications {
+    on_click: fn(&AppHandle, Target),
+}
+
+impl Notifications {
+    /// `on_click` is called with the target of a clicked notification
+    pub fn new(on_click: fn(&AppHandle, Target)) -> Self {
+        Notifications { on_click }
+    }
+
+    pub fn show(&self, app: &AppHandle, notification: Notification) {
+        if main_window_in_front(app) {
+            return;
+        }
+        let Notification {
+            title,
+            body,
+            target,
+        } = notification;
+        let on_click = self.on_click;
+        let clicked_app = app.clone();
+        let clicked = move || on_click(&clicked_app, target);
+        if let Err(e) = deliver(app, &title, &body, clicked) {
+            log_warn!("{}", e);
+        }
+    }
+}
+
+fn main_window_in_front(app: &AppHandle) -> bool {
+    app.get_webview_window(MAIN_LABEL).is_some_and(|window| {
+        window.is_visible().unwrap_or(false) && window.is_focused().unwrap_or(false)
+    })
+}
+
+/// Show a notification; `clicked` runs on another thread when the user clicks it
+#[cfg(target_os = "linux")]
+fn deliver(
+    app: &AppHandle,
+    title: &str,
+    body: &str,
+    clicked: impl FnOnce() + Send + 'static,
+) -> Result<(), String> {
+    let open = text("notification.open", &[]);
+    let handle = notify_rust::Notification::new()
+        .appname(&app.package_info().name)
+        .summary(title)
+        .body(body)
+        // Most notification servers invoke the default action for a click on the body
+        .action("default", &open)
+        .show()
+        .map_err(|e| format!("Failed to show notification: {}", e))?;
+    std::thread::spawn(move || {
+        handle.wait_for_action(|action| {
+            if action == "default" {
+                clicked();
+            }
+        });
+    });
+    Ok(())
+}
+
+#[cfg(target_os = "macos")]
+fn deliver(
+    app: &AppHandle,
+    title: &str,
+    body: &str,
+    clicked: impl FnOnce() + Send + 'static,
+) -> Result<(), String> {
+    use mac_notification_sys::{Notification as MacNotification, NotificationResponse};
+    static BUNDLE: std::sync::Once = std::sync::Once::new();
+
+    // Notifications are shown under the app's bundle identifier, which can be set once
+    let identifier = app.config().identifier.clone();
+    BUNDLE.call_once(|| {
+        if let Err(e) = mac_notification_sys::set_application(&identifier) {
+            log_warn!("Failed to set notification bundle: {}", e);
+        }
+    });
+
+    let (title, body) = (title.to_string(), body.to_string());
+    // The notification is sent from its own thread, which waits for the click
+    std::thread::spawn(move || {
+        let mut options = MacNotification::new();
+        options.wait_for_click(true);
+        match mac_notification_sys::send_notification(&title, None, &body, Some(&options)) {
+            Ok(NotificationResponse::Click) => clicked(),
+            Ok(_) => {}
+            Err(e) => log_warn!("Failed to show notification: {}", e),
+        }
+    });
+    Ok(())
+}
+
+#[cfg(windows)]
+fn deliver(
+    app: &AppHandle,
+    title: &str,
+    body: &str,
+    clicked: impl FnOnce() + Send + 'static,
+) -> Result<(), String> {
+    use tauri_winrt_notification::Toast;
+
+    // Toasts need the AppUserModelID the installer registers; development builds show
+    // theirs under PowerShell
+    let app_id = if cfg!(debug_assertions) {
+        Toast::POWERSHELL_APP_ID.to_string()
+    } else {
+        app.config().identifier.clone()
+    };
+    let mut clicked = Some(clicked);
+    Toast::new(&app_id)
+        .title(title)
+        .text1(body)
+        .on_activated(move |_| {
+            if let Some(clicked) = clicked.take() {
+                clicked();
+            }
+            Ok(())
+        })
+        .show()
+        .map_err(|e| format!("Failed to show notification: {:?}", e))
+}
+
+#[cfg(not(any(target_os = "linux", target_os = "macos", windows)))]
+fn deliver(
+    _app: &AppHandle,
+    _title: &str,
+    _body: &str,
+    _clicked: impl FnOnce() + Send + 'static,
+) -> Result<(), String> {
+    Err("Notifications are not supported on this platform".to_string())
+}
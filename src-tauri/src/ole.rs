@@ -0,0 +1,280 @@
+use cfb::CompoundFile;
+use std::io::{self, Cursor, Read, Seek, Write};
+use std::path::{Path, PathBuf};
+
+/// Stream of packaged files ("Package" objects) and of objects of OLE 1.0 servers
+const OLE10_NATIVE: &str = "\u{1}Ole10Native";
+/// Stream with the class, user type and ProgID of an object
+const COMP_OBJ: &str = "\u{1}CompObj";
+/// Stream with an Office Open XML document (Excel, Word and PowerPoint 2007 and later)
+const PACKAGE: &str = "Package";
+/// Stream with the file of other objects, e.g. a PDF document
+const CONTENTS: &str = "CONTENTS";
+
+/// Ole10Native type of a packaged file
+const PACKAGED_FILE: u16 = 2;
+
+/// The payload of an OLE object embedded in a message instead of a normal attachment
+pub struct OleObject {
+    /// Name of a packaged file; None for other objects, which are named after the
+    /// attachment
+    pub file_name: Option<String>,
+    /// Extension of the payload, without the dot
+    pub extension: String,
+    pub data: Vec<u8>,
+}
+
+fn read_stream<F: Read + Seek>(file: &mut CompoundFile<F>, path: &Path) -> io::Result<Vec<u8>> {
+    let mut data = Vec::new();
+    file.open_stream(path)?.read_to_end(&mut data)?;
+    Ok(data)
+}
+
+/// Reads little-endian values and strings of the OLE streams
+struct Reader<'a> {
+    data: &'a [u8],
+    offset: usize,
+}
+
+impl<'a> Reader<'a> {
+    fn bytes(&mut self, len: usize) -> Option<&'a [u8]> {
+        let bytes = self.data.get(self.offset..self.offset.checked_add(len)?)?;
+        self.offset += len;
+        Some(bytes)
+    }
+
+    fn u16(&mut self) -> Option<u16> {
+        self.bytes(2).map(|bytes| u16::from_le_bytes([bytes[0], bytes[1]]))
+    }
+
+    fn u32(&mut self) -> Option<u32> {
+        self.bytes(4)
+            .map(|bytes| u32::from_le_bytes([bytes[0], bytes[1], bytes[2], bytes[3]]))
+    }
+
+    /// A null-terminated 8-bit string, decoded as UTF-8 (lossy)
+    fn c_string(&mut self) -> Option<String> {
+        let rest = self.data.get(self.offset..)?;
+        let len = rest.iter().position(|byte| *byte == 0)?;
+        self.offset += len + 1;
+        Some(String::from_utf8_lossy(&rest[..len]).to_string())
+    }
+
+    /// A string after its length, which includes the terminator
+    fn length_prefixed_string(&mut self) -> Option<String> {
+        let len = self.u32()? as usize;
+        let bytes = self.bytes(len)?;
+        Some(String::from_utf8_lossy(bytes).trim_end_matches('\0').to_string())
+    }
+}
+
+/// The last component of a Windows or Unix path
+fn base_name(path: &str) -> &str {
+    path.rsplit(['\\', '/']).next().unwrap_or(path).trim()
+}
+
+/// Extension of a file name, lowercase and without the dot
+pub fn extension(file_name: &str) -> String {
+    Path::new(file_name)
+        .extension()
+        .map(|extension| extension.to_string_lossy().to_lowercase())
+        .unwrap_or_default()
+}
+
+/// Name and content of a packaged file
+fn packaged_file(native: &[u8]) -> Option<(Option<String>, Vec<u8>)> {
+    let mut reader = Reader {
+        data: native,
+        offset: 0,
+    };
+    if reader.u16()? != PACKAGED_FILE {
+        return None;
+    }
+    let label = reader.c_string()?;
+    let source_path = reader.c_string()?;
+    reader.bytes(8)?;
+    let temp_path = reader.c_string()?;
+    let len = reader.u32()? as usize;
+    let content = reader.bytes(len)?;
+    let name = [&source_path, &temp_path, &label]
+        .into_iter()
+        .map(|path| base_name(path))
+        .find(|name| !name.is_empty())
+        .map(str::to_string);
+    Some((name, content.to_vec()))
+}
+
+/// Content of an Ole10Native stream: a packaged file with its name, or the native data of
+/// the OLE 1.0 server as is
+fn ole10_native(data: &[u8]) -> Option<(Option<String>, Vec<u8>)> {
+    let mut reader = Reader { data, offset: 0 };
+    let size = reader.u32()? as usize;
+    let native = &data[4..4 + size.min(data.len() - 4)];
+    Some(packaged_file(native).unwrap_or_else(|| (None, native.to_vec())))
+}
+
+/// ProgID of an object from its CompObj stream (MS-OLEDS 2.3.8), e.g. "Excel.Sheet.12"
+fn prog_id(comp_obj: &[u8]) -> Option<String> {
+    let mut reader = Reader {
+        data: comp_obj,
+        offset: 28,
+    };
+    reader.length_prefixed_string()?;
+    match reader.u32()? {
+        0 => {}
+        0xFFFF_FFFF | 0xFFFF_FFFE => {
+            reader.u32()?;
+        }
+        len => {
+            reader.bytes(len as usize)?;
+        }
+    }
+    reader.length_prefixed_string().filter(|prog_id| !prog_id.is_empty())
+}
+
+/// Extension of an Office Open XML document by the ProgID of its object
+fn package_extension(prog_id: &str) -> &'static str {
+    let macros = prog_id.contains("MacroEnabled");
+    match prog_id.split('.').next().unwrap_or_default() {
+        "Excel" if macros => "xlsm",
+        "Excel" => "xlsx",
+        "Word" if macros => "docm",
+        "Word" => "docx",
+        "PowerPoint" if macros => "pptm",
+        "PowerPoint" => "pptx",
+        _ => "zip",
+    }
+}
+
+/// Extension of an object kept as a compound file, by the streams of its storage
+fn document_extension(streams: &[String]) -> &'static str {
+    let has = |name: &str| streams.iter().any(|stream| stream == name);
+    if has("Workbook") || has("Book") {
+        "xls"
+    } else if has("WordDocument") {
+        "doc"
+    } else if has("PowerPoint Document") {
+        "ppt"
+    } else if has("VisioDocument") {
+        "vsd"
+    } else {
+        "bin"
+    }
+}
+
+/// A storage with everything in it, as a compound file of its own
+fn copy_storage<F: Read + Seek>(file: &mut CompoundFile<F>, storage: &Path) -> io::Result<Vec<u8>> {
+    let mut copy = CompoundFile::create(Cursor::new(Vec::new()))?;
+    let entries: Vec<(PathBuf, bool, _)> = file
+        .walk_storage(storage)?
+        .map(|entry| (entry.path().to_path_buf(), entry.is_storage(), *entry.clsid()))
+        .collect();
+    for (path, is_storage, clsid) in entries {
+        let Ok(relative) = path.strip_prefix(storage) else {
+            continue;
+        };
+        let target = Path::new("/").join(relative);
+        if is_storage {
+            if target != Path::new("/") {
+                copy.create_storage(&target)?;
+            }
+            copy.set_storage_clsid(&target, clsid)?;
+        } else {
+            let data = read_stream(file, &path)?;
+            copy.create_stream(&target)?.write_all(&data)?;
+        }
+    }
+    copy.flush()?;
+    Ok(copy.into_inner().into_inner())
+}
+
+/// Unwrap the OLE object in `storage` (the PR_ATTACH_DATA_OBJ storage of an attachment
+/// with PR_ATTACH_METHOD ATTACH_OLE): packaged files and PDF documents are returned as
+/// the file they hold, Office documents as .xlsx/.docx/... or .xls/.doc/... files, and
+/// other objects (e.g. equations) as the compound file of the object
+pub fn extract<F: Read + Seek>(
+    file: &mut CompoundFile<F>,
+    storage: &Path,
+) -> Result<OleObject, String> {
+    let read_error = |e: io::Error| format!("Failed to read OLE object: {}", e);
+
+    let streams: Vec<String> = file
+        .read_storage(storage)
+        .map_err(read_error)?
+        .filter(|entry| entry.is_stream())
+        .map(|entry| entry.name().to_string())
+        .collect();
+    let has = |name: &str| streams.iter().any(|stream| stream == name);
+
+    if has(OLE10_NATIVE) {
+        let data = read_stream(file, &storage.join(OLE10_NATIVE)).map_err(read_error)?;
+        let (file_name, data) =
+            ole10_native(&data).ok_or("Invalid OLE object: truncated Ole10Native stream")?;
+        let extension = file_name
+            .as_deref()
+            .map(extension)
+            .filter(|extension| !extension.is_empty())
+            .unwrap_or_else(|| "bin".to_string());
+        return Ok(OleObject {
+            file_name,
+            extension,
+            data,
+        });
+    }
+
+    if has(PACKAGE) {
+        let prog_id = if has(COMP_OBJ) {
+            prog_id(&read_stream(file, &storage.join(COMP_OBJ)).map_err(read_error)?)
+        } else {
+            None
+        };
+        return Ok(OleObject {
+            file_name: None,
+            extension: package_extension(prog_id.as_deref().unwrap_or_default()).to_string(),
+            data: read_stream(file, &storage.join(PACKAGE)).map_err(read_error)?,
+        });
+    }
+
+    if has(CONTENTS) {
+        let data = read_stream(file, &storage.join(CONTENTS)).map_err(read_error)?;
+        let extension = if data.starts_with(b"%PDF-") { "pdf" } else { "bin" };
+        return Ok(OleObject {
+            file_name: None,
+            extension: extension.to_string(),
+            data,
+        });
+    }
+
+    Ok(OleObject {
+        file_name: None,
+        extension: document_extension(&streams).to_string(),
+        data: copy_storage(file, storage).map_err(read_error)?,
+    })
+}
+
+/// MIME type of an extracted object by its extension
+pub fn mime_type(extension: &str) -> &'static str {
+    match extension {
+        "pdf" => "application/pdf",
+        "txt" => "text/plain",
+        "csv" => "text/csv",
+        "htm" | "html" => "text/html",
+        "xml" => "application/xml",
+        "png" => "image/png",
+        "jpg" | "jpeg" => "image/jpeg",
+        "gif" => "image/gif",
+        "bmp" => "image/bmp",
+        "zip" => "application/zip",
+        "xls" => "application/vnd.ms-excel",
+        "doc" => "application/msword",
+        "ppt" => "application/vnd.ms-powerpoint",
+        "vsd" => "application/vnd.visio",
+        "xlsx" => "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+        "xlsm" => "application/vnd.ms-excel.sheet.macroEnabled.12",
+        "docx" => "application/vnd.openxmlformats-officedocument.wordprocessingml.document",
+        "docm" => "application/vnd.ms-word.document.macroEnabled.12",
+        "pptx" => "application/vnd.openxmlformats-officedocument.presentationml.presentation",
+        "pptm" => "application/vnd.ms-powerpoint.presentation.macroEnabled.12",
+        _ => "application/octet-stream",
+    }
+}
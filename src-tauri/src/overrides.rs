@@ -0,0 +1,132 @@
+use crate::args::StartupArgs;
+use crate::policy::Policy;
+use std::path::PathBuf;
+use tauri::{AppHandle, Manager};
+
+/// Levels accepted by `--log-level`, matching the frontend's ErrorLevel
+pub const LOG_LEVELS: [&str; 5] = ["debug", "info", "warning", "error", "critical"];
+
+/// Settings given with `MSGREADER_*` environment variables or command-line flags for
+/// managed deployments and scripted use. They take precedence over persisted settings;
+/// flags take precedence over environment variables.
+#[derive(serde::Serialize, Clone, Default)]
+#[serde(rename_all = "camelCase")]
+pub struct Overrides {
+    /// Replaces the config directory (plugins, hooks, proxy, ...) and holds the WebView data
+    pub data_dir: Option<PathBuf>,
+    /// Minimum level of frontend log messages
+    pub log_level: Option<String>,
+    /// Blocks all outbound connections: updates, webhooks, translation and PAC files
+    pub offline: bool,
+    /// Keeps changed settings for the session only instead of saving them
+    pub read_only: bool,
+    /// Viewer-only mode: no saving, exporting, printing or opening files in other apps
+    pub kiosk: bool,
+}
+
+/// `1`, `true`, `yes` and `on` (any case) enable a switch variable
+fn env_switch(name: &str) -> bool {
+    std::env::var(name).is_ok_and(|value| {
+        matches!(value.trim().to_lowercase().as_str(), "1" | "true" | "yes" | "on")
+    })
+}
+
+fn env_value(name: &str) -> Option<String> {
+    std::env::var(name).ok().filter(|value| !value.trim().is_empty())
+}
+
+impl Overrides {
+    pub fn from_env_and_args(args: &StartupArgs) -> Self {
+        let data_dir = args
+            .data_dir
+            .clone()
+            .or_else(|| env_value("MSGREADER_DATA_DIR"))
+            .map(|dir| {
+                let dir = PathBuf::from(dir);
+                std::env::current_dir().map(|cwd| cwd.join(&dir)).unwrap_or(dir)
+            });
+        // Levels from the command line were checked when parsing it
+        let log_level = args.log_level.clone().or_else(|| {
+            env_value("MSGREADER_LOG_LEVEL")
+                .map(|level| level.trim().to_lowercase())
+                .filter(|level| {
+                    let valid = LOG_LEVELS.contains(&level.as_str());
+                    if !valid {
+                        log_warn!("Ignoring invalid log level: {}", level);
+                    }
+                    valid
+                })
+        });
+
+        Overrides {
+            data_dir,
+            log_level,
+            offline: args.offline || env_switch("MSGREADER_OFFLINE"),
+            read_only: args.read_only || env_switch("MSGREADER_READ_ONLY"),
+            kiosk: args.kiosk || env_switch("MSGREADER_KIOSK"),
+        }
+    }
+}
+
+/// Kiosk mode for the command-line actions, which run before the app state exists:
+/// `--kiosk`, `MSGREADER_KIOSK` or the `KioskMode` policy
+pub fn kiosk_enforced(kiosk_arg: bool, policy: &Policy) -> bool {
+    kiosk_arg || env_switch("MSGREADER_KIOSK") || policy.kiosk_mode
+}
+
+/// Directory for config files (plugins, hooks, proxy, ...), the data directory if one
+/// is given
+pub fn config_dir(app: &AppHandle) -> Result<PathBuf, String> {
+    if let Some(dir) = &app.state::<Overrides>().data_dir {
+        return Ok(dir.clone());
+    }
+    app.path()
+        .app_config_dir()
+        .map_err(|e| format!("Failed to resolve config directory: {}", e))
+}
+
+/// Directory for machine-local runtime files, below the data directory if one is given
+pub fn local_data_dir(app: &AppHandle) -> Result<PathBuf, String> {
+    if let Some(dir) = &app.state::<Overrides>().data_dir {
+        return Ok(dir.join("local"));
+    }
+    app.path()
+        .app_local_data_dir()
+        .map_err(|e| format!("Failed to resolve data directory: {}", e))
+}
+
+/// Directory for cached and temporary files of the user, below the data directory if
+/// one is given
+pub fn cache_dir(app: &AppHandle) -> Result<PathBuf, String> {
+    if let Some(dir) = &app.state::<Overrides>().data_dir {
+        return Ok(dir.join("cache"));
+    }
+    app.path()
+        .app_cache_dir()
+        .map_err(|e| format!("Failed to resolve cache directory: {}", e))
+}
+
+/// Fail if networking was disabled with `--offline` or `MSGREADER_OFFLINE`
+pub fn ensure_online(app: &AppHandle) -> Result<(), String> {
+    if app.state::<Overrides>().offline {
+        return Err("Networking is disabled (offline mode)".to_string());
+    }
+    Ok(())
+}
+
+/// Fail in kiosk mode (`--kiosk`, `MSGREADER_KIOSK` or the `KioskMode` policy), where
+/// no message content may leave the app
+pub fn ensure_not_kiosk(app: &AppHandle) -> Result<(), String> {
+    if app.state::<Overrides>().kiosk {
+        return Err("Not available in kiosk mode".to_string());
+    }
+    Ok(())
+}
+
+/// Fail if persistent changes were disabled with `--read-only` or `MSGREADER_READ_ONLY`
+pub fn ensure_writable(app: &AppHandle) -> Result<(), String> {
+    if app.state::<Overrides>().read_only {
+        return Err("Changes are not saved in read-only mode".to_string());
+    }
+    Ok(())
+}
@@ -0,0 +1,174 @@
+/// A4 in points
+const PAGE_WIDTH: u32 = 595;
+const PAGE_HEIGHT: u32 = 842;
+const MARGIN: u32 = 50;
+const FONT_SIZE: u32 = 10;
+const LEADING: u32 = 13;
+/// Courier is 0.6 em wide, so 82 characters fit between the margins at 10 pt
+const LINE_CHARS: usize = 82;
+/// Lines between the top margin and the page number
+const PAGE_LINES: usize = ((PAGE_HEIGHT - 2 * MARGIN) / LEADING) as usize - 2;
+
+/// Byte of a character in the WinAnsi encoding of the standard fonts, `?` if it has none
+fn win_ansi(c: char) -> u8 {
+    match c {
+        ' '..='~' => c as u8,
+        '\u{A0}'..='\u{FF}' => c as u32 as u8,
+        '€' => 0x80,
+        '‚' => 0x82,
+        '„' => 0x84,
+        '…' => 0x85,
+        '‘' => 0x91,
+        '’' => 0x92,
+        '“' => 0x93,
+        '”' => 0x94,
+        '•' => 0x95,
+        '–' => 0x96,
+        '—' => 0x97,
+        '™' => 0x99,
+        '\t' => b' ',
+        _ => b'?',
+    }
+}
+
+/// A PDF string literal of a line
+fn pdf_string(line: &str) -> Vec<u8> {
+    let mut string = vec![b'('];
+    for byte in line.chars().map(win_ansi) {
+        if matches!(byte, b'(' | b')' | b'\\') {
+            string.push(b'\\');
+        }
+        string.push(byte);
+    }
+    string.push(b')');
+    string
+}
+
+/// Break text into lines of at most `LINE_CHARS` characters, at spaces where possible
+fn wrap(text: &str) -> Vec<String> {
+    let mut lines = Vec::new();
+    for paragraph in text.replace('\t', "    ").lines() {
+        let mut line = String::new();
+        let mut length = 0;
+        for word in paragraph.split(' ') {
+            let word_length = word.chars().count();
+            if length > 0 && length + 1 + word_length > LINE_CHARS {
+                lines.push(std::mem::take(&mut line));
+                length = 0;
+            }
+            if length > 0 {
+                line.push(' ');
+                length += 1;
+            }
+            // Words longer than a line are split
+            for c in word.chars() {
+                if length == LINE_CHARS {
+                    lines.push(std::mem::take(&mut line));
+                    length = 0;
+                }
+                line.push(c);
+                length += 1;
+            }
+        }
+        lines.push(line);
+    }
+    lines
+}
+
+fn page_content(lines: &[String], page: usize, pages: usize) -> Vec<u8> {
+    let mut content = format!(
+        "BT\n/F1 {} Tf\n{} TL\n{} {} Td\n",
+        FONT_SIZE,
+        LEADING,
+        MARGIN,
+        PAGE_HEIGHT - MARGIN - FONT_SIZE
+    )
+    .into_bytes();
+    for line in lines {
+        content.extend(pdf_string(line));
+        content.extend(b" Tj T*\n");
+    }
+    let footer = format!(
+        "ET\nBT\n/F1 8 Tf\n{} {} Td\n",
+        PAGE_WIDTH - MARGIN - 60,
+        MARGIN / 2
+    );
+    content.extend(footer.as_bytes());
+    content.extend(pdf_string(&format!("Page {} of {}", page, pages)));
+    content.extend(b" Tj\nET\n");
+    content
+}
+
+/// Write plain text as a PDF document with A4 pages in Courier and page numbers. Only
+/// characters of the WinAnsi encoding (Western European scripts) can be shown; others
+/// are printed as `?`.
+pub fn text_document(title: &str, text: &str) -> Vec<u8> {
+    let lines = wrap(text);
+    let pages: Vec<&[String]> = if lines.is_empty() {
+        vec![&lines[..]]
+    } else {
+        lines.chunks(PAGE_LINES).collect()
+    };
+
+    // Objects 1-3 are the catalog, the page tree and the font, then a page and its
+    // content for each page, then the document information
+    let mut objects: Vec<Vec<u8>> = vec![
+        b"<< /Type /Catalog /Pages 2 0 R >>".to_vec(),
+        format!(
+            "<< /Type /Pages /Kids [{}] /Count {} >>",
+            (0..pages.len())
+                .map(|index| format!("{} 0 R", 4 + index * 2))
+                .collect::<Vec<_>>()
+                .join(" "),
+            pages.len()
+        )
+        .into_bytes(),
+        b"<< /Type /Font /Subtype /Type1 /BaseFont /Courier /Encoding /WinAnsiEncoding >>"
+            .to_vec(),
+    ];
+    for (index, lines) in pages.iter().enumerate() {
+        objects.push(
+            format!(
+                "<< /Type /Page /Parent 2 0 R /MediaBox [0 0 {} {}] \
+                 /Resources << /Font << /F1 3 0 R >> >> /Contents {} 0 R >>",
+                PAGE_WIDTH,
+                PAGE_HEIGHT,
+                5 + index * 2
+            )
+            .into_bytes(),
+        );
+        let content = page_content(lines, index + 1, pages.len());
+        let mut stream = format!("<< /Length {} >>\nstream\n", content.len()).into_bytes();
+        stream.extend(content);
+        stream.extend(b"endstream");
+        objects.push(stream);
+    }
+    let mut info = b"<< /Title ".to_vec();
+    info.extend(pdf_string(title));
+    info.extend(b" /Producer (msgReader) >>");
+    objects.push(info);
+
+    let mut pdf = b"%PDF-1.4\n%\xE2\xE3\xCF\xD3\n".to_vec();
+    let mut offsets = Vec::with_capacity(objects.len());
+    for (index, object) in objects.iter().enumerate() {
+        offsets.push(pdf.len());
+        pdf.extend(format!("{} 0 obj\n", index + 1).as_bytes());
+        pdf.extend(object);
+        pdf.extend(b"\nendobj\n");
+    }
+    let xref = pdf.len();
+    pdf.extend(format!("xref\n0 {}\n0000000000 65535 f \n", objects.len() + 1).as_bytes());
+    for offset in offsets {
+        pdf.extend(format!("{:010} 00000 n \n", offset).as_bytes());
+    }
+    pdf.extend(
+        format!(
+            "trailer\n<< /Size {} /Root 1 0 R /Info {} 0 R >>\nstartxref\n{}\n%%EOF\n",
+            objects.len() + 1,
+            objects.len(),
+            xref
+        )
+        .as_bytes(),
+    );
+    pdf
+}
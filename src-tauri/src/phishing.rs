@@ -0,0 +1,455 @@
+use crate::authentication::{address_domain, organizational_domain};
+use crate::headers::CFB_SIGNATURE;
+use crate::message::html_to_text;
+use crate::redact::attribute;
+use crate::reply::{addresses, header};
+use crate::{eml, msg};
+use std::collections::HashSet;
+
+/// Last labels of link texts such as `invoice.pdf` that are file names, not domains
+const FILE_EXTENSIONS: [&str; 14] = [
+    "pdf", "doc", "docx", "xls", "xlsx", "ppt", "pptx", "txt", "csv", "zip", "htm", "html",
+    "jpg", "png",
+];
+
+/// Longest part of a link target shown in a finding; data URIs can be megabytes
+const MAX_TARGET_LENGTH: usize = 80;
+
+/// Cyrillic, Greek and other letters that look like Latin ones, with the letter they
+/// imitate
+const LOOKALIKES: [(char, char); 27] = [
+    ('а', 'a'),
+    ('с', 'c'),
+    ('ԁ', 'd'),
+    ('е', 'e'),
+    ('һ', 'h'),
+    ('і', 'i'),
+    ('ј', 'j'),
+    ('ӄ', 'k'),
+    ('ӏ', 'l'),
+    ('о', 'o'),
+    ('р', 'p'),
+    ('ԛ', 'q'),
+    ('ѕ', 's'),
+    ('ԝ', 'w'),
+    ('х', 'x'),
+    ('у', 'y'),
+    ('α', 'a'),
+    ('ε', 'e'),
+    ('ι', 'i'),
+    ('κ', 'k'),
+    ('ο', 'o'),
+    ('ρ', 'p'),
+    ('τ', 't'),
+    ('υ', 'u'),
+    ('ν', 'v'),
+    ('χ', 'x'),
+    ('ɡ', 'g'),
+];
+
+/// Something in a message that phishing messages do
+#[derive(serde::Serialize)]
+#[serde(rename_all = "camelCase")]
+pub struct Finding {
+    /// `mismatched_link`, `homoglyph_domain`, `punycode_domain`, `data_uri_link` or
+    /// `reply_to_mismatch`
+    pub kind: &'static str,
+    /// `high` or `medium`
+    pub severity: &'static str,
+    pub description: String,
+    /// The link target or Reply-To address, shortened
+    pub target: String,
+    /// Text of the link, empty for header findings
+    pub text: String,
+}
+
+#[derive(serde::Serialize)]
+#[serde(rename_all = "camelCase")]
+pub struct PhishingReport {
+    /// Domain of the From address
+    pub sender_domain: String,
+    pub reply_to: Vec<String>,
+    /// Links in the HTML body
+    pub links: usize,
+    /// Each finding once, even if several links share it
+    pub findings: Vec<Finding>,
+    /// `high`, `medium` or `none`: the highest severity of the findings
+    pub risk: &'static str,
+}
+
+/// A link of the HTML body
+struct Link {
+    href: String,
+    /// Text of the link, whitespace collapsed
+    text: String,
+}
+
+/// The `<a href>` links of an HTML body
+fn links(html: &str) -> Vec<Link> {
+    let lower = html.to_ascii_lowercase();
+    let mut links = Vec::new();
+    let mut position = 0;
+    while let Some(found) = lower[position..].find("<a") {
+        let start = position + found;
+        position = start + 2;
+        if !lower[position..].starts_with(|c: char| c.is_ascii_whitespace()) {
+            continue;
+        }
+        let Some(tag_length) = lower[start..].find('>') else {
+            break;
+        };
+        let tag_end = start + tag_length + 1;
+        let close = lower[tag_end..]
+            .find("</a")
+            .map_or(html.len(), |index| tag_end + index);
+        if let Some(href) = attribute(&html[start..tag_end], "href") {
+            let text = html_to_text(&html[tag_end..close]);
+            links.push(Link {
+                href: href.trim().replace("&amp;", "&"),
+                text: text.split_whitespace().collect::<Vec<_>>().join(" "),
+            });
+        }
+        position = close;
+    }
+    links
+}
+
+/// Host of a web link (`http:`, `https:` or `//`), lowercase, without user and port
+fn web_host(url: &str) -> Option<String> {
+    let lower = url.trim().to_lowercase();
+    let rest = lower
+        .strip_prefix("http://")
+        .or_else(|| lower.strip_prefix("https://"))
+        .or_else(|| lower.strip_prefix("//"))?;
+    let authority = rest.split(['/', '\\', '?', '#']).next().unwrap_or("");
+    let host = authority.rsplit_once('@').map_or(authority, |(_, host)| host);
+    let host = host.split(':').next().unwrap_or(host).trim_end_matches('.');
+    (!host.is_empty()).then(|| host.to_string())
+}
+
+fn is_label(label: &str) -> bool {
+    !label.is_empty() && label.chars().all(|c| c.is_alphanumeric() || c == '-')
+}
+
+/// The domain a link text shows if the text is a web address, e.g. `www.example.com`
+/// or `https://example.com/login`; file names like `invoice.pdf` are not
+fn shown_domain(text: &str) -> Option<String> {
+    let text = text.trim().trim_end_matches(['.', ',', ';', ')']);
+    if text.is_empty() || text.contains(char::is_whitespace) {
+        return None;
+    }
+    let (host, bare) = match web_host(text) {
+        Some(host) => (host, false),
+        None if text.contains("://") || text.contains('@') => return None,
+        None => {
+            let host = text.split(['/', '?', '#']).next().unwrap_or("").to_lowercase();
+            let bare = !host.starts_with("www.");
+            (host, bare)
+        }
+    };
+    let labels: Vec<&str> = host.split('.').collect();
+    let last = labels.last().copied().unwrap_or("");
+    let valid = labels.len() >= 2
+        && labels.iter().all(|label| is_label(label))
+        && last.len() >= 2
+        && last.chars().all(char::is_alphabetic)
+        && !(bare && FILE_EXTENSIONS.contains(&last));
+    valid.then_some(host)
+}
+
+/// The domain as it is displayed, with `xn--` labels decoded
+fn unicode_domain(domain: &str) -> String {
+    idna::domain_to_unicode(domain).0
+}
+
+/// The domain with look-alike letters replaced by the Latin ones they imitate
+fn skeleton(domain: &str) -> String {
+    domain
+        .chars()
+        .map(|c| {
+            LOOKALIKES
+                .iter()
+                .find(|(lookalike, _)| *lookalike == c)
+                .map_or(c, |(_, latin)| *latin)
+        })
+        .collect()
+}
+
+/// At most MAX_TARGET_LENGTH characters of a target or link text
+fn shorten(target: &str) -> String {
+    match target.char_indices().nth(MAX_TARGET_LENGTH) {
+        Some((end, _)) => format!("{}...", &target[..end]),
+        None => target.to_string(),
+    }
+}
+
+/// Findings of the domain of a link: look-alike letters, or an internationalized name
+/// that is shown differently than it is written
+fn check_domain(host: &str) -> Option<(&'static str, &'static str, String)> {
+    let unicode = unicode_domain(host);
+    let imitated = skeleton(&unicode);
+    if imitated != unicode && imitated.is_ascii() {
+        return Some((
+            "homoglyph_domain",
+            "high",
+            format!("{} imitates {} with look-alike letters", unicode, imitated),
+        ));
+    }
+    let punycode = host.split('.').any(|label| label.starts_with("xn--"));
+    if punycode || !host.is_ascii() {
+        return Some((
+            "punycode_domain",
+            "medium",
+            format!("The link goes to the internationalized domain {} ({})", unicode, host),
+        ));
+    }
+    None
+}
+
+/// Findings of the links of an HTML body
+fn check_links(links: &[Link]) -> Vec<Finding> {
+    let mut findings = Vec::new();
+    let mut finding = |kind, severity, description, link: &Link| {
+        findings.push(Finding {
+            kind,
+            severity,
+            description,
+            target: shorten(&link.href),
+            text: shorten(&link.text),
+        });
+    };
+
+    for link in links {
+        if link.href.to_ascii_lowercase().starts_with("data:") {
+            let media_type = link.href[5..].split([';', ',']).next().unwrap_or("");
+            let description = format!(
+                "The link opens data embedded in the message ({}) instead of a web page",
+                if media_type.is_empty() { "text/plain" } else { media_type }
+            );
+            finding("data_uri_link", "high", description, link);
+            continue;
+        }
+        let Some(host) = web_host(&link.href) else {
+            continue;
+        };
+        if let Some((kind, severity, description)) = check_domain(&host) {
+            finding(kind, severity, description, link);
+        }
+        if let Some(shown) = shown_domain(&link.text) {
+            let shown = unicode_domain(&shown);
+            let actual = unicode_domain(&host);
+            if organizational_domain(&shown) != organizational_domain(&actual) {
+                let description = format!("The link shows {} but goes to {}", shown, actual);
+                finding("mismatched_link", "high", description, link);
+            }
+        }
+    }
+    findings
+}
+
+/// Findings of the Reply-To addresses: replies that go to another domain than the
+/// one the message claims to come from
+fn check_reply_to(sender_domain: &str, reply_to: &[String]) -> Vec<Finding> {
+    if sender_domain.is_empty() {
+        return Vec::new();
+    }
+    reply_to
+        .iter()
+        .filter(|address| {
+            let domain = address_domain(address);
+            !domain.is_empty()
+                && organizational_domain(&domain) != organizational_domain(sender_domain)
+        })
+        .map(|address| Finding {
+            kind: "reply_to_mismatch",
+            severity: "medium",
+            description: format!(
+                "Replies go to {}, not to the sender's domain {}",
+                address_domain(address),
+                sender_domain
+            ),
+            target: address.clone(),
+            text: String::new(),
+        })
+        .collect()
+}
+
+/// Check a message (.eml or .msg file) for what phishing messages do: links whose text
+/// shows another domain than they go to, domains with look-alike letters or in
+/// punycode, links to data URIs and a Reply-To of another domain than the sender.
+/// Reply-To is read from the transport headers, which .msg files may not have.
+pub fn analyze(data: &[u8]) -> Result<PhishingReport, String> {
+    let message = if data.starts_with(&CFB_SIGNATURE) {
+        msg::parse_bytes(data)?
+    } else {
+        eml::parse_bytes(data)?
+    };
+
+    let sender_domain = match header(&message.headers, "from") {
+        Some(from) => address_domain(&from),
+        None => address_domain(&message.sender_email),
+    };
+    let reply_to = header(&message.headers, "reply-to")
+        .map(|list| addresses(&list))
+        .unwrap_or_default();
+    let links = links(&message.body_html);
+
+    let mut seen = HashSet::new();
+    let findings: Vec<Finding> = check_links(&links)
+        .into_iter()
+        .chain(check_reply_to(&sender_domain, &reply_to))
+        .filter(|finding| seen.insert((finding.kind, finding.description.clone())))
+        .collect();
+    let risk = if findings.iter().any(|finding| finding.severity == "high") {
+        "high"
+    } else if findings.is_empty() {
+        "none"
+    } else {
+        "medium"
+    };
+    Ok(PhishingReport {
+        sender_domain,
+        reply_to,
+        links: links.len(),
+        findings,
+        risk,
+    })
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    fn link(href: &str, text: &str) -> Link {
+        Link {
+            href: href.to_string(),
+            text: text.to_string(),
+        }
+    }
+
+    fn kinds(findings: &[Finding]) -> Vec<&'static str> {
+        findings.iter().map(|finding| finding.kind).collect()
+    }
+
+    #[test]
+    fn links_are_extracted() {
+        let html = "<p><abbr title=\"x\">A</abbr> <A HREF=\"https://example.com/?a=1&amp;b=2\">\
+            <b>Sign</b>\r\n  in</a> <a name=\"top\">anchor</a> <a class=x href='//cdn.example.org'>\
+            www.example.org</a> <a href=mailto:user@example.com>mail";
+        let links = links(html);
+        assert_eq!(links.len(), 3);
+        assert_eq!(links[0].href, "https://example.com/?a=1&b=2");
+        assert_eq!(links[0].text, "Sign in");
+        assert_eq!(links[1].href, "//cdn.example.org");
+        assert_eq!(links[1].text, "www.example.org");
+        assert_eq!(links[2].href, "mailto:user@example.com");
+        assert_eq!(links[2].text, "mail");
+    }
+
+    #[test]
+    fn web_hosts() {
+        let host = |url| web_host(url).unwrap_or_default();
+        assert_eq!(host("https://Example.COM/path"), "example.com");
+        assert_eq!(host(" http://user:pw@example.com:8080/x "), "example.com");
+        assert_eq!(host("//cdn.example.org?x"), "cdn.example.org");
+        assert_eq!(host("https://example.com.\\evil"), "example.com");
+        assert_eq!(host("https://www.example.com@evil.test/"), "evil.test");
+        assert_eq!(web_host("mailto:user@example.com"), None);
+        assert_eq!(web_host("ftp://example.com"), None);
+        assert_eq!(web_host("https:///path"), None);
+    }
+
+    #[test]
+    fn shown_domains() {
+        let shown = |text| shown_domain(text).unwrap_or_default();
+        assert_eq!(shown("www.example.com"), "www.example.com");
+        assert_eq!(shown("https://example.com/login"), "example.com");
+        assert_eq!(shown("example.com/login."), "example.com");
+        assert_eq!(shown("www.invoice.pdf"), "www.invoice.pdf");
+        assert_eq!(shown_domain("Click here"), None);
+        assert_eq!(shown_domain("invoice.pdf"), None);
+        assert_eq!(shown_domain("user@example.com"), None);
+        assert_eq!(shown_domain("version 1.2"), None);
+        assert_eq!(shown_domain("1.2"), None);
+    }
+
+    #[test]
+    fn link_text_of_another_domain_is_a_mismatch() {
+        let findings = check_links(&[link("https://evil.test/login", "www.paypal.com")]);
+        assert_eq!(kinds(&findings), ["mismatched_link"]);
+        assert_eq!(findings[0].severity, "high");
+        let description = "The link shows www.paypal.com but goes to evil.test";
+        assert_eq!(findings[0].description, description);
+        assert_eq!(findings[0].target, "https://evil.test/login");
+
+        // Subdomains of the shown domain are the same organization
+        let same = check_links(&[link("https://login.paypal.com/", "https://www.paypal.com")]);
+        assert!(same.is_empty());
+    }
+
+    #[test]
+    fn link_texts_that_are_no_domains_are_not_mismatches() {
+        let findings = check_links(&[
+            link("https://evil.test/a", "Click here"),
+            link("https://evil.test/b", "invoice.pdf"),
+            link("https://evil.test/c", "Report.DOCX"),
+            link("mailto:user@evil.test", "www.example.com"),
+        ]);
+        assert!(findings.is_empty());
+    }
+
+    #[test]
+    fn internationalized_hosts() {
+        // `pаypal.com` with a Cyrillic `а`
+        let findings = check_links(&[link("https://xn--pypal-4ve.com/", "Log in")]);
+        assert_eq!(kinds(&findings), ["homoglyph_domain"]);
+        assert_eq!(findings[0].severity, "high");
+        assert_eq!(
+            findings[0].description,
+            "pаypal.com imitates paypal.com with look-alike letters"
+        );
+
+        let findings = check_links(&[link("https://xn--mnchen-3ya.de/", "Stadtplan")]);
+        assert_eq!(kinds(&findings), ["punycode_domain"]);
+        assert_eq!(findings[0].severity, "medium");
+        assert_eq!(
+            findings[0].description,
+            "The link goes to the internationalized domain münchen.de (xn--mnchen-3ya.de)"
+        );
+
+        // Hosts written in Unicode, and the same domain shown in Unicode and in punycode
+        let findings = check_links(&[link("https://münchen.de/", "münchen.de")]);
+        assert_eq!(kinds(&findings), ["punycode_domain"]);
+        let findings = check_links(&[link("https://xn--mnchen-3ya.de/", "www.münchen.de")]);
+        assert_eq!(kinds(&findings), ["punycode_domain"]);
+    }
+
+    #[test]
+    fn data_uri_links() {
+        let long = format!("data:text/html;base64,{}", "A".repeat(200));
+        let findings = check_links(&[link(&long, "Open"), link("DATA:,hello", "Open")]);
+        assert_eq!(kinds(&findings), ["data_uri_link", "data_uri_link"]);
+        assert_eq!(
+            findings[0].description,
+            "The link opens data embedded in the message (text/html) instead of a web page"
+        );
+        assert_eq!(findings[0].target.chars().count(), MAX_TARGET_LENGTH + 3);
+        assert!(findings[1].description.contains("(text/plain)"));
+    }
+
+    #[test]
+    fn reply_to_of_another_domain() {
+        let reply_to = [
+            "Support <help@mail.example.com>".to_string(),
+            "collector@evil.test".to_string(),
+        ];
+        let findings = check_reply_to("example.com", &reply_to);
+        assert_eq!(kinds(&findings), ["reply_to_mismatch"]);
+        assert_eq!(findings[0].target, "collector@evil.test");
+        assert_eq!(
+            findings[0].description,
+            "Replies go to evil.test, not to the sender's domain example.com"
+        );
+        assert!(check_reply_to("", &reply_to).is_empty());
+    }
+}
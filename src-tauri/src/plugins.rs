@@ -0,0 +1,156 @@
+use std::io::{Read, Write};
+use std::path::{Path, PathBuf};
+use std::process::{Command, Stdio};
+use std::time::{Duration, Instant};
+
+/// Name of the manifest file inside each plugin directory
+const MANIFEST_FILE: &str = "plugin.json";
+
+/// Protocol version passed to plugins on stdin
+pub const PROTOCOL_VERSION: u32 = 1;
+
+const DEFAULT_TIMEOUT_SECONDS: u64 = 60;
+
+/// Export plugin manifest (`<config dir>/plugins/<name>/plugin.json`)
+#[derive(serde::Deserialize, serde::Serialize, Clone)]
+#[serde(rename_all = "camelCase")]
+pub struct ExportPlugin {
+    pub id: String,
+    pub name: String,
+    pub extension: String,
+    #[serde(default = "default_mime_type")]
+    pub mime_type: String,
+    pub command: String,
+    #[serde(default)]
+    pub args: Vec<String>,
+    #[serde(default)]
+    pub timeout_seconds: Option<u64>,
+    /// Directory the manifest was loaded from (not part of the manifest)
+    #[serde(skip)]
+    pub dir: PathBuf,
+}
+
+fn default_mime_type() -> String {
+    "application/octet-stream".to_string()
+}
+
+/// Load all valid plugin manifests below `plugins_dir`. Invalid manifests are skipped.
+pub fn discover(plugins_dir: &Path) -> Vec<ExportPlugin> {
+    let Ok(entries) = std::fs::read_dir(plugins_dir) else {
+        return Vec::new();
+    };
+
+    let mut plugins: Vec<ExportPlugin> = entries
+        .filter_map(|entry| entry.ok())
+        .map(|entry| entry.path())
+        .filter(|dir| dir.is_dir())
+        .filter_map(|dir| {
+            let manifest = std::fs::read_to_string(dir.join(MANIFEST_FILE)).ok()?;
+            match serde_json::from_str::<ExportPlugin>(&manifest) {
+                Ok(mut plugin) if is_valid_id(&plugin.id) => {
+                    plugin.dir = dir;
+                    Some(plugin)
+                }
+                Ok(plugin) => {
+                    log_warn!("Skipping plugin with invalid id: {:?}", plugin.id);
+                    None
+                }
+                Err(e) => {
+                    log_warn!("Skipping invalid plugin manifest in {:?}: {}", dir, e);
+                    None
+                }
+            }
+        })
+        .collect();
+
+    // Keep the first plugin for each id so the menu never shows ambiguous entries
+    let mut seen = std::collections::HashSet::new();
+    plugins.retain(|plugin| seen.insert(plugin.id.clone()));
+    plugins.sort_by(|a, b| a.name.cmp(&b.name));
+    plugins
+}
+
+fn is_valid_id(id: &str) -> bool {
+    !id.is_empty() && id.chars().all(|c| c.is_ascii_alphanumeric() || c == '-' || c == '_')
+}
+
+/// Resolve the plugin command. Relative paths are resolved against the plugin directory.
+fn resolve_command(plugin: &ExportPlugin) -> PathBuf {
+    let command = Path::new(&plugin.command);
+    if command.is_relative() && command.components().count() > 1 {
+        plugin.dir.join(command)
+    } else {
+        command.to_path_buf()
+    }
+}
+
+/// Run a plugin with the message JSON on stdin and return its stdout.
+/// The plugin is killed if it does not finish within its timeout.
+pub fn run(plugin: &ExportPlugin, message_json: &str) -> Result<Vec<u8>, String> {
+    let input = format!(
+        "{{\"protocolVersion\":{},\"message\":{}}}",
+        PROTOCOL_VERSION, message_json
+    );
+
+    let mut child = Command::new(resolve_command(plugin))
+        .args(&plugin.args)
+        .current_dir(&plugin.dir)
+        .stdin(Stdio::piped())
+        .stdout(Stdio::piped())
+        .stderr(Stdio::piped())
+        .spawn()
+        .map_err(|e| format!("Failed to start plugin {}: {}", plugin.id, e))?;
+
+    // Read output on separate threads so a full pipe never blocks the plugin
+    let mut stdout = child.stdout.take().ok_or("Plugin stdout unavailable")?;
+    let mut stderr = child.stderr.take().ok_or("Plugin stderr unavailable")?;
+    let stdout_reader = std::thread::spawn(move || {
+        let mut buffer = Vec::new();
+        stdout.read_to_end(&mut buffer).map(|_| buffer)
+    });
+    let stderr_reader = std::thread::spawn(move || {
+        let mut buffer = String::new();
+        let _ = stderr.read_to_string(&mut buffer);
+        buffer
+    });
+
+    // Write input on its own thread too, so a plugin that never reads stdin still times out.
+    // A plugin may exit without reading all input; that is reported via its exit code.
+    if let Some(mut stdin) = child.stdin.take() {
+        std::thread::spawn(move || {
+            let _ = stdin.write_all(input.as_bytes());
+        });
+    }
+
+    let timeout = Duration::from_secs(plugin.timeout_seconds.unwrap_or(DEFAULT_TIMEOUT_SECONDS));
+    let started = Instant::now();
+    let status = loop {
+        match child.try_wait() {
+            Ok(Some(status)) => break status,
+            Ok(None) if started.elapsed() >= timeout => {
+                let _ = child.kill();
+                let _ = child.wait();
+                return Err(format!("Plugin {} timed out after {}s", plugin.id, timeout.as_secs()));
+            }
+            Ok(None) => std::thread::sleep(Duration::from_millis(50)),
+            Err(e) => return Err(format!("Failed to wait for plugin {}: {}", plugin.id, e)),
+        }
+    };
+
+    let output = stdout_reader
+        .join()
+        .map_err(|_| "Plugin output reader panicked".to_string())?
+        .map_err(|e| format!("Failed to read plugin output: {}", e))?;
+    let errors = stderr_reader.join().unwrap_or_default();
+
+    if !status.success() {
+        return Err(format!(
+            "Plugin {} failed ({}): {}",
+            plugin.id,
+            status,
+            errors.trim()
+        ));
+    }
+
+    Ok(output)
+}
@@ -0,0 +1,144 @@
+use serde_json::{Map, Value};
+use std::path::PathBuf;
+#[cfg(any(windows, target_os = "macos"))]
+use std::process::Command;
+
+/// Bundle identifier from tauri.conf.json, the name of the macOS managed preferences domain
+#[cfg(target_os = "macos")]
+const BUNDLE_ID: &str = "com.rasalas.msgreader";
+
+/// Policies set by an administrator through Group Policy (Windows), a configuration
+/// profile (macOS) or `/etc/msgreader/policy.json` (Linux). They take precedence over
+/// user settings and over `MSGREADER_*` overrides.
+#[derive(serde::Serialize, Clone, Default)]
+#[serde(rename_all = "camelCase")]
+pub struct Policy {
+    /// `DisableAutoUpdate`: no update checks and no updater
+    pub disable_auto_update: bool,
+    /// `BlockExternalContent`: remote images in messages are never loaded
+    pub block_external_content: bool,
+    /// `DataDir`: pins the data directory like `--data-dir`
+    pub data_dir: Option<PathBuf>,
+    /// `KioskMode`: viewer-only mode like `--kiosk`
+    pub kiosk_mode: bool,
+}
+
+/// Booleans may be stored as REG_DWORD, plist/JSON booleans or strings
+fn is_enabled(value: Option<&Value>) -> bool {
+    match value {
+        Some(Value::Bool(enabled)) => *enabled,
+        Some(Value::Number(number)) => number.as_i64().is_some_and(|n| n != 0),
+        Some(Value::String(text)) => {
+            matches!(text.trim().to_lowercase().as_str(), "1" | "true" | "yes" | "on")
+        }
+        _ => false,
+    }
+}
+
+impl Policy {
+    /// Read the policies of this machine, missing or unreadable sources are skipped
+    pub fn load() -> Self {
+        Self::from_values(&read_values())
+    }
+
+    fn from_values(values: &Map<String, Value>) -> Self {
+        Policy {
+            disable_auto_update: is_enabled(values.get("DisableAutoUpdate")),
+            block_external_content: is_enabled(values.get("BlockExternalContent")),
+            data_dir: values
+                .get("DataDir")
+                .and_then(Value::as_str)
+                .map(str::trim)
+                .filter(|dir| !dir.is_empty())
+                .map(PathBuf::from),
+            kiosk_mode: is_enabled(values.get("KioskMode")),
+        }
+    }
+}
+
+/// Parse the output of `reg query <key>`, e.g. `    DataDir    REG_SZ    D:\msgReader`
+#[cfg(windows)]
+fn parse_reg_query(output: &str) -> Map<String, Value> {
+    let mut values = Map::new();
+    for line in output.lines().filter(|line| line.starts_with("    ")) {
+        let mut parts = line.trim_start().splitn(3, "    ");
+        let (Some(name), Some(kind)) = (parts.next(), parts.next()) else {
+            continue;
+        };
+        let data = parts.next().unwrap_or("").trim();
+        let value = match kind {
+            "REG_DWORD" => i64::from_str_radix(data.trim_start_matches("0x"), 16)
+                .map(Value::from)
+                .unwrap_or(Value::Null),
+            _ => Value::from(data),
+        };
+        values.insert(name.to_string(), value);
+    }
+    values
+}
+
+#[cfg(windows)]
+fn read_values() -> Map<String, Value> {
+    use std::os::windows::process::CommandExt;
+    const CREATE_NO_WINDOW: u32 = 0x0800_0000;
+
+    let mut values = Map::new();
+    // Machine policies win over user policies
+    for key in [
+        r"HKCU\SOFTWARE\Policies\msgReader",
+        r"HKLM\SOFTWARE\Policies\msgReader",
+    ] {
+        let output = Command::new("reg")
+            .args(["query", key])
+            .creation_flags(CREATE_NO_WINDOW)
+            .output()
+            .ok()
+            .filter(|output| output.status.success());
+        if let Some(output) = output {
+            values.extend(parse_reg_query(&String::from_utf8_lossy(&output.stdout)));
+        }
+    }
+    values
+}
+
+/// Read a plist as JSON with `plutil`
+#[cfg(target_os = "macos")]
+fn read_plist(path: &std::path::Path) -> Option<Map<String, Value>> {
+    if !path.exists() {
+        return None;
+    }
+    let output = Command::new("plutil")
+        .args(["-convert", "json", "-o", "-"])
+        .arg(path)
+        .output()
+        .ok()
+        .filter(|output| output.status.success())?;
+    serde_json::from_slice(&output.stdout).ok()
+}
+
+#[cfg(target_os = "macos")]
+fn read_values() -> Map<String, Value> {
+    let managed = PathBuf::from("/Library/Managed Preferences");
+    let file = format!("{}.plist", BUNDLE_ID);
+
+    let mut values = Map::new();
+    // Device profiles win over user profiles
+    if let Ok(user) = std::env::var("USER") {
+        values.extend(read_plist(&managed.join(user).join(&file)).unwrap_or_default());
+    }
+    values.extend(read_plist(&managed.join(&file)).unwrap_or_default());
+    values
+}
+
+#[cfg(target_os = "linux")]
+fn read_values() -> Map<String, Value> {
+    std::fs::read_to_string("/etc/msgreader/policy.json")
+        .ok()
+        .and_then(|text| serde_json::from_str(&text).ok())
+        .unwrap_or_default()
+}
+
+#[cfg(not(any(windows, target_os = "macos", target_os = "linux")))]
+fn read_values() -> Map<String, Value> {
+    Map::new()
+}
@@ -0,0 +1,214 @@
+use std::io::Write;
+use std::path::Path;
+use std::process::{Command, Stdio};
+use std::sync::atomic::{AtomicU64, Ordering};
+use tauri::webview::PageLoadEvent;
+use tauri::{AppHandle, WebviewUrl, WebviewWindowBuilder};
+
+/// Print windows are numbered so several can be open at once
+static PRINT_WINDOWS: AtomicU64 = AtomicU64::new(0);
+
+/// Options of `print_message`
+#[derive(serde::Deserialize)]
+#[serde(rename_all = "camelCase", default)]
+pub struct PrintOptions {
+    /// Print the file name at the top and page numbers at the bottom of each page
+    pub header: bool,
+    /// File name of the message for the header, the document title otherwise
+    pub file_name: String,
+    /// Print to this printer without a dialog; only the text of the message is printed
+    pub printer: Option<String>,
+}
+
+impl Default for PrintOptions {
+    fn default() -> Self {
+        PrintOptions {
+            header: true,
+            file_name: String::new(),
+            printer: None,
+        }
+    }
+}
+
+fn escape_html(text: &str) -> String {
+    text.replace('&', "&amp;")
+        .replace('<', "&lt;")
+        .replace('>', "&gt;")
+        .replace('"', "&quot;")
+}
+
+/// Quote a string for a CSS `content` value
+fn css_string(text: &str) -> String {
+    let escaped: String = text
+        .chars()
+        .map(|c| match c {
+            '"' | '\\' => format!("\\{}", c),
+            c if c.is_control() => " ".to_string(),
+            c => c.to_string(),
+        })
+        .collect();
+    format!("\"{}\"", escaped)
+}
+
+/// Add print styles to a rendered message document: page margins, the header with file
+/// name and page numbers, and rules against images and preformatted text running off the
+/// page. The policy keeps scripts and remote content of the message from running.
+pub fn document(html: &str, options: &PrintOptions) -> String {
+    let mut style = String::from(
+        "@page { size: auto; margin: 18mm 15mm; }\n\
+         html, body { background: white !important; }\n\
+         body { padding: 0 !important; }\n\
+         main { max-width: none !important; border: none !important; padding: 0 !important; }\n\
+         img, svg, video { max-width: 100% !important; height: auto !important; break-inside: avoid; }\n\
+         pre, code { white-space: pre-wrap !important; word-wrap: break-word; }\n\
+         table { max-width: 100% !important; }\n\
+         tr, li, blockquote { break-inside: avoid; }\n\
+         h1, h2, h3 { break-after: avoid; }\n\
+         .attachments a { color: inherit; text-decoration: none; }\n",
+    );
+    if options.header {
+        style.push_str(&format!(
+            "@page {{\n\
+             @top-left {{ content: {}; font: 9pt sans-serif; color: #555; }}\n\
+             @bottom-right {{ content: \"Page \" counter(page) \" of \" counter(pages); \
+             font: 9pt sans-serif; color: #555; }}\n\
+             }}\n",
+            css_string(&options.file_name)
+        ));
+    }
+
+    let head = format!(
+        "<meta http-equiv=\"Content-Security-Policy\" content=\"default-src 'none'; \
+         img-src data:; style-src 'unsafe-inline'; font-src data:\">\n\
+         <style media=\"all\">\n{}</style>\n",
+        style
+    );
+    let lower = html.to_ascii_lowercase();
+    match lower.find("<head>") {
+        Some(index) => format!("{}{}{}", &html[..index + 6], head, &html[index + 6..]),
+        None => format!(
+            "<!DOCTYPE html><html><head><meta charset=\"UTF-8\"><title>{}</title>{}</head>\
+             <body>{}</body></html>",
+            escape_html(&options.file_name),
+            head,
+            html
+        ),
+    }
+}
+
+/// Open a window with a print document and show the OS print dialog once it is loaded.
+/// The window has no access to the app's commands and stays open as a preview until the
+/// user closes it.
+pub fn open_dialog(app: &AppHandle, path: &Path, title: &str) -> Result<(), String> {
+    let url = tauri::Url::from_file_path(path)
+        .map_err(|_| format!("Invalid print file: {}", path.display()))?;
+    let label = format!("print-{}", PRINT_WINDOWS.fetch_add(1, Ordering::Relaxed));
+
+    WebviewWindowBuilder::new(app, label, WebviewUrl::External(url))
+        .title(format!("Print - {}", title))
+        .inner_size(820.0, 1000.0)
+        .on_page_load(|window, payload| {
+            if matches!(payload.event(), PageLoadEvent::Finished) {
+                if let Err(e) = window.print() {
+                    log_warn!("Failed to open print dialog: {}", e);
+                }
+            }
+        })
+        .build()
+        .map_err(|e| format!("Failed to open print window: {}", e))?;
+    Ok(())
+}
+
+#[cfg(windows)]
+fn powershell(script: &str) -> Command {
+    use std::os::windows::process::CommandExt;
+    const CREATE_NO_WINDOW: u32 = 0x0800_0000;
+
+    let mut command = Command::new("powershell");
+    command
+        .args(["-NoProfile", "-NonInteractive", "-Command", script])
+        .creation_flags(CREATE_NO_WINDOW);
+    command
+}
+
+/// Build the command that prints text from stdin: CUPS `lp` on macOS and Linux (its
+/// prettyprint option adds the title and page numbers), Out-Printer on Windows
+fn print_command(printer: &str, title: &str, header: bool) -> Result<Command, String> {
+    #[cfg(unix)]
+    {
+        let mut command = Command::new("lp");
+        command.args(["-d", printer, "-t", title]);
+        if header {
+            command.args(["-o", "prettyprint"]);
+        }
+        Ok(command)
+    }
+
+    #[cfg(windows)]
+    {
+        let _ = (title, header);
+        // The printer is passed through the environment so it is never parsed as script
+        let mut command = powershell(
+            "[Console]::InputEncoding = [Text.Encoding]::UTF8; \
+             [Console]::In.ReadToEnd() | Out-Printer -Name $env:MSGREADER_PRINTER",
+        );
+        command.env("MSGREADER_PRINTER", printer);
+        Ok(command)
+    }
+
+    #[cfg(not(any(unix, windows)))]
+    {
+        let _ = (printer, title, header);
+        Err("Printing without a dialog is not supported on this platform".to_string())
+    }
+}
+
+/// Send the text of a message to a printer without a dialog
+pub fn print_silently(text: &str, printer: &str, options: &PrintOptions) -> Result<(), String> {
+    let mut text = text.to_string();
+    // Out-Printer has no header of its own
+    if cfg!(windows) && options.header && !options.file_name.is_empty() {
+        text = format!("{}\n\n{}", options.file_name, text);
+    }
+
+    let mut child = print_command(printer, &options.file_name, options.header)?
+        .stdin(Stdio::piped())
+        .stdout(Stdio::null())
+        .stderr(Stdio::piped())
+        .spawn()
+        .map_err(|e| format!("Failed to start printing: {}", e))?;
+    if let Some(mut stdin) = child.stdin.take() {
+        stdin
+            .write_all(text.as_bytes())
+            .map_err(|e| format!("Failed to send the message to the printer: {}", e))?;
+    }
+    let output = child
+        .wait_with_output()
+        .map_err(|e| format!("Failed to print: {}", e))?;
+    if !output.status.success() {
+        return Err(format!(
+            "Failed to print: {}",
+            String::from_utf8_lossy(&output.stderr).trim()
+        ));
+    }
+    Ok(())
+}
+
+/// Names of the installed printers, empty if they cannot be listed
+pub fn list_printers() -> Vec<String> {
+    #[cfg(unix)]
+    let output = Command::new("lpstat").arg("-e").output();
+    #[cfg(windows)]
+    let output = powershell("Get-Printer | ForEach-Object { $_.Name }").output();
+    #[cfg(not(any(unix, windows)))]
+    let output: std::io::Result<std::process::Output> = Err(std::io::ErrorKind::Unsupported.into());
+
+    match output {
+        Ok(output) if output.status.success() => String::from_utf8_lossy(&output.stdout)
+            .lines()
+            .map(|line| line.trim().to_string())
+            .filter(|line| !line.is_empty())
+            .collect(),
+        Ok(_) | Err(_) => Vec::new(),
+    }
+}
@@ -0,0 +1,69 @@
+use crate::args::StartupArgs;
+use std::sync::Mutex;
+
+const MAX_NAME_CHARS: usize = 64;
+
+/// The profile this window works with. Settings live in the WebView storage and are
+/// namespaced by the frontend; the backend uses the profile to keep caches apart.
+pub struct ActiveProfile {
+    state: Mutex<ProfileState>,
+}
+
+/// What the frontend needs to know at startup
+#[derive(serde::Serialize, Clone, Default)]
+#[serde(rename_all = "camelCase")]
+pub struct ProfileState {
+    /// Profile name, None for the default profile
+    pub name: Option<String>,
+    /// Whether a profile was given with `--profile` or already chosen in this session,
+    /// in which case the frontend skips the startup chooser
+    pub selected: bool,
+}
+
+/// Profile names become part of directory names, so only letters, digits,
+/// spaces, `-` and `_` are allowed
+pub fn is_valid_name(name: &str) -> bool {
+    let trimmed = name.trim();
+    !trimmed.is_empty()
+        && trimmed == name
+        && name.chars().count() <= MAX_NAME_CHARS
+        && name.chars().all(|c| c.is_alphanumeric() || matches!(c, ' ' | '-' | '_'))
+}
+
+impl ActiveProfile {
+    /// Start with the profile from the command line, if any. Invalid names are
+    /// ignored with a warning so a typo does not stop the app from starting.
+    pub fn from_args(args: &StartupArgs) -> Self {
+        let name = args.profile.clone().filter(|name| {
+            let valid = is_valid_name(name);
+            if !valid {
+                log_warn!("Ignoring invalid profile name: {}", name);
+            }
+            valid
+        });
+        ActiveProfile {
+            state: Mutex::new(ProfileState {
+                selected: name.is_some(),
+                name,
+            }),
+        }
+    }
+
+    pub fn get(&self) -> ProfileState {
+        self.state.lock().unwrap().clone()
+    }
+
+    /// Switch to another profile (None for the default profile)
+    pub fn set(&self, name: Option<String>) -> Result<(), String> {
+        if let Some(name) = &name {
+            if !is_valid_name(name) {
+                return Err(format!("Invalid profile name: {}", name));
+            }
+        }
+        *self.state.lock().unwrap() = ProfileState {
+            name,
+            selected: true,
+        };
+        Ok(())
+    }
+}
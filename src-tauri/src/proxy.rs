@@ -0,0 +1,434 @@
+use base64::{engine::general_purpose::STANDARD, Engine as _};
+use std::io::{Read, Write};
+use std::net::{TcpStream, ToSocketAddrs};
+use std::path::Path;
+use std::time::Duration;
+
+/// Name of the proxy configuration file in the app's config directory
+pub const CONFIG_FILE: &str = "proxy.json";
+
+const PAC_TIMEOUT: Duration = Duration::from_secs(10);
+
+#[derive(serde::Deserialize, Clone, Copy, PartialEq, Eq, Default)]
+#[serde(rename_all = "lowercase")]
+pub enum ProxyMode {
+    /// Environment variables, then the OS proxy settings
+    #[default]
+    System,
+    /// The proxy given in `url`
+    Manual,
+    /// The first `PROXY` directive of the PAC file at `pacUrl`
+    Pac,
+    /// Always connect directly
+    None,
+}
+
+/// Proxy settings (`<config dir>/proxy.json`). A missing file means system mode.
+#[derive(serde::Deserialize, Default)]
+#[serde(rename_all = "camelCase")]
+pub struct ProxyConfig {
+    #[serde(default)]
+    pub mode: ProxyMode,
+    /// `http://host:port`, `https://host:port`, `socks5://host:port`
+    #[serde(default)]
+    pub url: Option<String>,
+    #[serde(default)]
+    pub pac_url: Option<String>,
+    #[serde(default)]
+    pub username: Option<String>,
+    #[serde(default)]
+    pub password: Option<String>,
+    /// Hosts that are always reached directly; `.example.com` also matches subdomains
+    #[serde(default)]
+    pub no_proxy: Vec<String>,
+}
+
+/// Load the proxy configuration
+pub fn load(config_path: &Path) -> Result<ProxyConfig, String> {
+    match std::fs::read_to_string(config_path) {
+        Ok(content) => {
+            let config: ProxyConfig = serde_json::from_str(&content)
+                .map_err(|e| format!("Invalid {}: {}", CONFIG_FILE, e))?;
+            if config.mode == ProxyMode::Manual && config.url.is_none() {
+                return Err(format!("Manual proxy mode requires a url in {}", CONFIG_FILE));
+            }
+            if config.mode == ProxyMode::Pac && config.pac_url.is_none() {
+                return Err(format!("PAC proxy mode requires a pacUrl in {}", CONFIG_FILE));
+            }
+            Ok(config)
+        }
+        Err(e) if e.kind() == std::io::ErrorKind::NotFound => Ok(ProxyConfig::default()),
+        Err(e) => Err(format!("Failed to read {}: {}", CONFIG_FILE, e)),
+    }
+}
+
+fn host_of(url: &str) -> &str {
+    let rest = url.split_once("://").map(|(_, rest)| rest).unwrap_or(url);
+    let authority = rest.split(['/', '?', '#']).next().unwrap_or("");
+    let host = authority.rsplit_once('@').map(|(_, host)| host).unwrap_or(authority);
+    if host.starts_with('[') {
+        host.split_inclusive(']').next().unwrap_or(host)
+    } else {
+        host.split(':').next().unwrap_or(host)
+    }
+}
+
+/// Whether a host matches a no-proxy list (`*`, `example.com`, `.example.com`)
+pub fn bypasses(no_proxy: &[String], url: &str) -> bool {
+    let host = host_of(url).to_lowercase();
+    no_proxy.iter().map(|entry| entry.trim().to_lowercase()).any(|entry| {
+        let domain = entry.trim_start_matches("*.").trim_start_matches('.');
+        entry == "*"
+            || (!domain.is_empty()
+                && (host == domain || host.ends_with(&format!(".{}", domain))))
+            || (entry == "<local>" && !host.contains('.'))
+    })
+}
+
+fn percent_encode(value: &str) -> String {
+    value
+        .bytes()
+        .map(|byte| match byte {
+            b'A'..=b'Z' | b'a'..=b'z' | b'0'..=b'9' | b'-' | b'.' | b'_' | b'~' => {
+                (byte as char).to_string()
+            }
+            _ => format!("%{:02X}", byte),
+        })
+        .collect()
+}
+
+/// Add `username`/`password` to a proxy URL unless it already carries credentials
+fn with_credentials(proxy_url: &str, username: Option<&str>, password: Option<&str>) -> String {
+    let Some(username) = username.filter(|u| !u.is_empty()) else {
+        return proxy_url.to_string();
+    };
+    let (scheme, rest) = proxy_url.split_once("://").unwrap_or(("http", proxy_url));
+    if rest.contains('@') {
+        return proxy_url.to_string();
+    }
+    let credentials = match password {
+        Some(password) => format!("{}:{}", percent_encode(username), percent_encode(password)),
+        None => percent_encode(username),
+    };
+    format!("{}://{}@{}", scheme, credentials, rest)
+}
+
+/// Extract the first `PROXY`/`HTTPS`/`SOCKS` directive from a PAC script.
+/// The script is not executed, so per-URL rules are not evaluated.
+pub fn first_pac_proxy(script: &str) -> Option<String> {
+    script.split(['"', '\'', ';']).find_map(|part| {
+        let mut words = part.split_whitespace();
+        let scheme = match words.next()?.to_uppercase().as_str() {
+            "PROXY" | "HTTP" => "http",
+            "HTTPS" => "https",
+            "SOCKS" | "SOCKS5" => "socks5",
+            _ => return None,
+        };
+        let address = words.next()?;
+        address.contains(':').then(|| format!("{}://{}", scheme, address))
+    })
+}
+
+fn env_proxy(url: &str) -> Option<String> {
+    let names: &[&str] = if url.starts_with("https://") {
+        &["HTTPS_PROXY", "https_proxy", "ALL_PROXY", "all_proxy"]
+    } else if url.starts_with("http://") {
+        &["HTTP_PROXY", "http_proxy", "ALL_PROXY", "all_proxy"]
+    } else {
+        // Other protocols, e.g. IMAP, as curl does
+        &["ALL_PROXY", "all_proxy"]
+    };
+    names
+        .iter()
+        .find_map(|name| std::env::var(name).ok())
+        .filter(|value| !value.is_empty())
+}
+
+fn env_no_proxy() -> Vec<String> {
+    std::env::var("NO_PROXY")
+        .or_else(|_| std::env::var("no_proxy"))
+        .map(|value| value.split(',').map(str::to_string).collect())
+        .unwrap_or_default()
+}
+
+/// Proxy configured in the OS network settings (Windows, macOS, GNOME/KDE)
+fn os_proxy() -> Option<(String, Vec<String>)> {
+    let proxy = sysproxy::Sysproxy::get_system_proxy().ok()?;
+    if !proxy.enable || proxy.host.is_empty() {
+        return None;
+    }
+    let bypass = proxy
+        .bypass
+        .split([',', ';'])
+        .map(str::trim)
+        .filter(|entry| !entry.is_empty())
+        .map(str::to_string)
+        .collect();
+    Some((format!("http://{}:{}", proxy.host, proxy.port), bypass))
+}
+
+/// Resolve the proxy URL (with credentials) to use for a request, None for direct
+pub fn proxy_for_url(config: &ProxyConfig, url: &str) -> Result<Option<String>, String> {
+    if bypasses(&config.no_proxy, url) {
+        return Ok(None);
+    }
+
+    let proxy = match config.mode {
+        ProxyMode::None => None,
+        ProxyMode::Manual => config.url.clone(),
+        ProxyMode::Pac => {
+            let pac_url = config.pac_url.as_deref().unwrap_or("");
+            let script = ureq::AgentBuilder::new()
+                .timeout(PAC_TIMEOUT)
+                .build()
+                .get(pac_url)
+                .call()
+                .map_err(|e| format!("Failed to download PAC file: {}", e))?
+                .into_string()
+                .map_err(|e| format!("Failed to read PAC file: {}", e))?;
+            first_pac_proxy(&script)
+        }
+        ProxyMode::System => match env_proxy(url) {
+            Some(proxy) if !bypasses(&env_no_proxy(), url) => Some(proxy),
+            Some(_) => None,
+            None => os_proxy()
+                .filter(|(_, bypass)| !bypasses(bypass, url))
+                .map(|(proxy, _)| proxy),
+        },
+    };
+
+    Ok(proxy.map(|proxy| {
+        with_credentials(&proxy, config.username.as_deref(), config.password.as_deref())
+    }))
+}
+
+/// Build an HTTP agent for a request to `url` that honors the proxy settings
+pub fn agent_for(
+    config: &ProxyConfig,
+    url: &str,
+    timeout: Duration,
+) -> Result<ureq::Agent, String> {
+    let mut builder = ureq::AgentBuilder::new().timeout(timeout);
+    if let Some(proxy) = proxy_for_url(config, url)? {
+        let proxy = ureq::Proxy::new(&proxy).map_err(|e| format!("Invalid proxy: {}", e))?;
+        builder = builder.proxy(proxy);
+    }
+    Ok(builder.build())
+}
+
+fn percent_decode(value: &str) -> String {
+    let bytes = value.as_bytes();
+    let mut decoded = Vec::with_capacity(bytes.len());
+    let mut index = 0;
+    while index < bytes.len() {
+        let escaped = (bytes[index] == b'%')
+            .then(|| value.get(index + 1..index + 3))
+            .flatten()
+            .and_then(|hex| u8::from_str_radix(hex, 16).ok());
+        match escaped {
+            Some(byte) => {
+                decoded.push(byte);
+                index += 3;
+            }
+            None => {
+                decoded.push(bytes[index]);
+                index += 1;
+            }
+        }
+    }
+    String::from_utf8_lossy(&decoded).into_owned()
+}
+
+/// A proxy URL taken apart, with its credentials decoded
+struct ProxyAddress {
+    scheme: String,
+    host: String,
+    port: u16,
+    username: Option<String>,
+    password: Option<String>,
+}
+
+fn parse_proxy(proxy_url: &str) -> Result<ProxyAddress, String> {
+    let (scheme, rest) = proxy_url.split_once("://").unwrap_or(("http", proxy_url));
+    let authority = rest.split(['/', '?', '#']).next().unwrap_or("");
+    let (credentials, address) = match authority.rsplit_once('@') {
+        Some((credentials, address)) => (Some(credentials), address),
+        None => (None, authority),
+    };
+    let (username, password) = match credentials {
+        Some(credentials) => {
+            let (username, password) = credentials.split_once(':').unwrap_or((credentials, ""));
+            (Some(percent_decode(username)), Some(percent_decode(password)))
+        }
+        None => (None, None),
+    };
+    let host = host_of(address).trim_start_matches('[').trim_end_matches(']');
+    let port = address
+        .rsplit_once(':')
+        .filter(|(_, port)| !port.ends_with(']'))
+        .map(|(_, port)| port.parse::<u16>())
+        .transpose()
+        .map_err(|_| format!("Invalid proxy: {}", host))?
+        .unwrap_or(1080);
+    if host.is_empty() {
+        return Err("Invalid proxy: no host".to_string());
+    }
+    Ok(ProxyAddress {
+        scheme: scheme.to_lowercase(),
+        host: host.to_string(),
+        port,
+        username,
+        password,
+    })
+}
+
+/// Connect to the first address of a host that answers
+fn connect_direct(host: &str, port: u16, timeout: Duration) -> Result<TcpStream, String> {
+    let mut last_error = format!("no address found for {}", host);
+    let addresses = (host, port).to_socket_addrs().map_err(|e| e.to_string())?;
+    for address in addresses {
+        match TcpStream::connect_timeout(&address, timeout) {
+            Ok(stream) => return Ok(stream),
+            Err(e) => last_error = e.to_string(),
+        }
+    }
+    Err(last_error)
+}
+
+/// Open a tunnel to `host:port` with an HTTP CONNECT request
+fn http_connect(
+    stream: &mut TcpStream,
+    proxy: &ProxyAddress,
+    host: &str,
+    port: u16,
+) -> Result<(), String> {
+    let target = if host.contains(':') {
+        format!("[{}]:{}", host, port)
+    } else {
+        format!("{}:{}", host, port)
+    };
+    let mut request = format!("CONNECT {0} HTTP/1.1\r\nHost: {0}\r\n", target);
+    if let Some(username) = &proxy.username {
+        let credentials = format!("{}:{}", username, proxy.password.as_deref().unwrap_or(""));
+        request.push_str(&format!(
+            "Proxy-Authorization: Basic {}\r\n",
+            STANDARD.encode(credentials)
+        ));
+    }
+    request.push_str("\r\n");
+    stream.write_all(request.as_bytes()).map_err(|e| e.to_string())?;
+
+    // Read the answer byte by byte, so nothing after its header is taken from the tunnel
+    let mut response = Vec::new();
+    let mut byte = [0u8; 1];
+    while !response.ends_with(b"\r\n\r\n") {
+        if response.len() > 8192 || stream.read(&mut byte).map_err(|e| e.to_string())? == 0 {
+            return Err("the proxy closed the connection".to_string());
+        }
+        response.push(byte[0]);
+    }
+    let status_line = String::from_utf8_lossy(&response);
+    let status_line = status_line.lines().next().unwrap_or("");
+    let status = status_line.split_whitespace().nth(1).unwrap_or("");
+    if !status.starts_with('2') {
+        return Err(format!("the proxy refused the connection ({})", status_line.trim()));
+    }
+    Ok(())
+}
+
+/// Open a tunnel to `host:port` through a SOCKS5 proxy (RFC 1928), logging in with the
+/// user name and password of the proxy URL if it has them (RFC 1929). The proxy resolves
+/// the host name.
+fn socks5_connect(
+    stream: &mut TcpStream,
+    proxy: &ProxyAddress,
+    host: &str,
+    port: u16,
+) -> Result<(), String> {
+    let io_error = |e: std::io::Error| e.to_string();
+    let method = if proxy.username.is_some() { 0x02 } else { 0x00 };
+    stream.write_all(&[0x05, 0x01, method]).map_err(io_error)?;
+    let mut answer = [0u8; 2];
+    stream.read_exact(&mut answer).map_err(io_error)?;
+    if answer[0] != 0x05 || answer[1] != method {
+        return Err("the SOCKS proxy does not accept this login".to_string());
+    }
+
+    if let Some(username) = &proxy.username {
+        let password = proxy.password.as_deref().unwrap_or("");
+        if username.len() > 255 || password.len() > 255 {
+            return Err("SOCKS user name or password too long".to_string());
+        }
+        let mut login = vec![0x01, username.len() as u8];
+        login.extend_from_slice(username.as_bytes());
+        login.push(password.len() as u8);
+        login.extend_from_slice(password.as_bytes());
+        stream.write_all(&login).map_err(io_error)?;
+        stream.read_exact(&mut answer).map_err(io_error)?;
+        if answer[1] != 0x00 {
+            return Err("SOCKS proxy login failed".to_string());
+        }
+    }
+
+    if host.len() > 255 {
+        return Err("host name too long".to_string());
+    }
+    let mut request = vec![0x05, 0x01, 0x00, 0x03, host.len() as u8];
+    request.extend_from_slice(host.as_bytes());
+    request.extend_from_slice(&port.to_be_bytes());
+    stream.write_all(&request).map_err(io_error)?;
+
+    let mut reply = [0u8; 4];
+    stream.read_exact(&mut reply).map_err(io_error)?;
+    if reply[1] != 0x00 {
+        return Err(format!("the SOCKS proxy refused the connection (code {})", reply[1]));
+    }
+    // The address the proxy bound, which is not needed
+    let address_length = match reply[3] {
+        0x01 => 4,
+        0x04 => 16,
+        0x03 => {
+            let mut length = [0u8; 1];
+            stream.read_exact(&mut length).map_err(io_error)?;
+            usize::from(length[0])
+        }
+        _ => return Err("invalid SOCKS reply".to_string()),
+    };
+    let mut bound = vec![0u8; address_length + 2];
+    stream.read_exact(&mut bound).map_err(io_error)
+}
+
+/// Open a TCP connection to `host:port` for a protocol other than HTTP (e.g. `imaps`),
+/// through the proxy the settings give for it: HTTP proxies tunnel with CONNECT, SOCKS5
+/// proxies directly. Read and write time out after `timeout`.
+pub fn connect(
+    config: &ProxyConfig,
+    scheme: &str,
+    host: &str,
+    port: u16,
+    timeout: Duration,
+) -> Result<TcpStream, String> {
+    let url = format!("{}://{}:{}", scheme, host, port);
+    let proxy = proxy_for_url(config, &url)?.map(|proxy| parse_proxy(&proxy)).transpose()?;
+    let (address, port_to_dial) = match &proxy {
+        Some(proxy) => (proxy.host.as_str(), proxy.port),
+        None => (host, port),
+    };
+    let mut stream = connect_direct(address, port_to_dial, timeout)?;
+    stream
+        .set_read_timeout(Some(timeout))
+        .and_then(|_| stream.set_write_timeout(Some(timeout)))
+        .map_err(|e| e.to_string())?;
+
+    match proxy {
+        None => {}
+        Some(proxy) => match proxy.scheme.as_str() {
+            "http" => http_connect(&mut stream, &proxy, host, port)
+                .map_err(|e| format!("proxy {}: {}", proxy.host, e))?,
+            "socks5" | "socks5h" => socks5_connect(&mut stream, &proxy, host, port)
+                .map_err(|e| format!("proxy {}: {}", proxy.host, e))?,
+            other => return Err(format!("{} proxies are not supported for {}", other, scheme)),
+        },
+    }
+    Ok(stream)
+}
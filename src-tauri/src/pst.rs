@@ -0,0 +1,1041 @@
+use crate::message::{Message, MessageStatus};
+use crate::msg::{
+    self, Properties, PR_CLIENT_SUBMIT_TIME, PR_DISPLAY_NAME, PR_MESSAGE_DELIVERY_TIME,
+    PR_MESSAGE_FLAGS, PR_SENDER_NAME, PR_SENT_REPRESENTING_NAME, PR_SUBJECT,
+};
+use std::collections::{HashMap, HashSet};
+use std::fs::File;
+use std::io::{Read, Seek, SeekFrom};
+use std::path::{Path, PathBuf};
+use std::sync::Mutex;
+
+// Reader for Outlook data files (MS-PST): Unicode PST files and the OST files of
+// Outlook 2003-2010. The three layers of the format are read bottom-up: nodes and
+// blocks (NDB), heaps, property contexts and tables on top of nodes (LTP), and
+// folders and messages made of those (messaging).
+
+// Property ids (MS-OXPROPS) not needed by msg.rs
+const PR_MESSAGE_SIZE: u16 = 0x0E08;
+const PR_CONTENT_COUNT: u16 = 0x3602;
+const PR_LTP_ROW_ID: u16 = 0x67F2;
+
+/// PR_MESSAGE_FLAGS flag: the message has attachments
+const MSGFLAG_HASATTACH: u32 = 0x0000_0010;
+
+/// Header: "!BDN", file format version, encryption and the B-tree roots
+const HEADER_LEN: usize = 564;
+const HEADER_MAGIC: &[u8] = b"!BDN";
+const OFFSET_VERSION: usize = 10;
+const OFFSET_NBT_ROOT: usize = 224;
+const OFFSET_BBT_ROOT: usize = 240;
+const OFFSET_CRYPT_METHOD: usize = 513;
+/// wVer of ANSI files (Outlook 97-2002), of Unicode files and of OST files with 4 KiB
+/// pages (Outlook 2013 and later)
+const VERSION_ANSI_MAX: u16 = 15;
+const VERSION_UNICODE: u16 = 23;
+const VERSION_UNICODE_4K: u16 = 36;
+
+const NDB_CRYPT_NONE: u8 = 0;
+const NDB_CRYPT_PERMUTE: u8 = 1;
+
+/// B-tree pages: 512 bytes with the entry count, entry size and level near the end
+const PAGE_SIZE: usize = 512;
+const OFFSET_PAGE_ENTRY_COUNT: usize = 488;
+const OFFSET_PAGE_ENTRY_SIZE: usize = 490;
+const OFFSET_PAGE_LEVEL: usize = 491;
+const OFFSET_PAGE_TYPE: usize = 496;
+const PTYPE_BBT: u8 = 0x80;
+const PTYPE_NBT: u8 = 0x81;
+/// Deeper B-trees or block trees than this are treated as damaged (loops)
+const MAX_TREE_DEPTH: usize = 16;
+
+/// Block ids with this bit are internal blocks (block and subnode trees), which are never
+/// encrypted
+const BID_INTERNAL: u64 = 0x02;
+const BTYPE_XBLOCK: u8 = 0x01;
+const BTYPE_SLBLOCK: u8 = 0x02;
+
+/// Heap-on-node signature and the client signatures of the structures built on heaps
+const HN_SIGNATURE: u8 = 0xEC;
+const HN_CLIENT_TC: u8 = 0x7C;
+const HN_CLIENT_BTH: u8 = 0xB5;
+const HN_CLIENT_PC: u8 = 0xBC;
+
+// Node ids: the low five bits are the type
+const NID_TYPE_MASK: u32 = 0x1F;
+const NID_TYPE_HIERARCHY_TABLE: u32 = 0x0D;
+const NID_TYPE_CONTENTS_TABLE: u32 = 0x0E;
+const NID_ROOT_FOLDER: u32 = 0x122;
+const NID_ATTACHMENT_TABLE: u32 = 0x671;
+const NID_RECIPIENT_TABLE: u32 = 0x692;
+
+/// Folders nested deeper than this are left out of the folder tree
+const MAX_FOLDER_DEPTH: usize = 32;
+
+/// Most messages returned by one `messages` call
+pub const MAX_PAGE_SIZE: usize = 200;
+
+/// Substitution table of the "compressible" encryption (NDB_CRYPT_PERMUTE); data is
+/// decoded with its inverse
+const PERMUTE_ENCODE: [u8; 256] = [
+    65, 54, 19, 98, 168, 33, 110, 187, 244, 22, 204, 4, 127, 100, 232, 93, 30, 242, 203, 42,
+    116, 197, 94, 53, 210, 149, 71, 158, 150, 45, 154, 136, 76, 125, 132, 63, 219, 172, 49,
+    182, 72, 95, 246, 196, 216, 57, 139, 231, 35, 59, 56, 142, 200, 193, 223, 37, 177, 32,
+    165, 70, 96, 78, 156, 251, 170, 211, 86, 81, 69, 124, 85, 0, 7, 201, 43, 157, 133, 155, 9,
+    160, 143, 173, 179, 15, 99, 171, 137, 75, 215, 167, 21, 90, 113, 102, 66, 191, 38, 74,
+    107, 152, 250, 234, 119, 83, 178, 112, 5, 44, 253, 89, 58, 134, 126, 206, 6, 235, 130,
+    120, 87, 199, 141, 67, 175, 180, 28, 212, 91, 205, 226, 233, 39, 79, 195, 8, 114, 128,
+    207, 176, 239, 245, 40, 109, 190, 48, 77, 52, 146, 213, 14, 60, 34, 50, 229, 228, 249,
+    159, 194, 209, 10, 129, 18, 225, 238, 145, 131, 118, 227, 151, 230, 97, 138, 23, 121,
+    164, 183, 220, 144, 122, 92, 140, 2, 166, 202, 105, 222, 80, 26, 17, 147, 185, 82, 135,
+    88, 252, 237, 29, 55, 73, 27, 106, 224, 41, 51, 153, 189, 108, 217, 148, 243, 64, 84,
+    111, 240, 198, 115, 184, 214, 62, 101, 24, 68, 31, 221, 103, 16, 241, 12, 25, 236, 174,
+    3, 161, 20, 123, 169, 11, 255, 248, 163, 192, 162, 1, 247, 46, 188, 36, 104, 117, 13,
+    254, 186, 47, 181, 208, 218, 61,
+];
+
+const PERMUTE_DECODE: [u8; 256] = {
+    let mut table = [0u8; 256];
+    let mut i = 0;
+    while i < 256 {
+        table[PERMUTE_ENCODE[i] as usize] = i as u8;
+        i += 1;
+    }
+    table
+};
+
+/// A folder of a PST file with its subfolders
+#[derive(serde::Serialize)]
+#[serde(rename_all = "camelCase")]
+pub struct PstFolder {
+    pub id: u32,
+    pub name: String,
+    /// Number of messages, from the folder's properties
+    pub count: u32,
+    pub children: Vec<PstFolder>,
+}
+
+/// A row of a folder's contents table
+#[derive(serde::Serialize, Clone)]
+#[serde(rename_all = "camelCase")]
+pub struct PstMessageSummary {
+    pub id: u32,
+    pub subject: String,
+    pub sender_name: String,
+    /// Unix time in milliseconds
+    pub date: Option<i64>,
+    pub size: u32,
+    pub has_attachments: bool,
+}
+
+#[derive(serde::Serialize)]
+#[serde(rename_all = "camelCase")]
+pub struct PstPage {
+    pub offset: usize,
+    pub total: usize,
+    pub messages: Vec<PstMessageSummary>,
+}
+
+fn damaged() -> String {
+    "Damaged PST file".to_string()
+}
+
+fn bytes(data: &[u8], offset: usize, len: usize) -> Result<&[u8], String> {
+    let end = offset.checked_add(len).ok_or_else(damaged)?;
+    data.get(offset..end).ok_or_else(damaged)
+}
+
+fn u16_at(data: &[u8], offset: usize) -> Result<u16, String> {
+    Ok(u16::from_le_bytes(bytes(data, offset, 2)?.try_into().unwrap()))
+}
+
+fn u32_at(data: &[u8], offset: usize) -> Result<u32, String> {
+    Ok(u32::from_le_bytes(bytes(data, offset, 4)?.try_into().unwrap()))
+}
+
+fn u64_at(data: &[u8], offset: usize) -> Result<u64, String> {
+    Ok(u64::from_le_bytes(bytes(data, offset, 8)?.try_into().unwrap()))
+}
+
+/// Subjects may start with 0x01 and the length of their prefix ("RE: ") as characters
+fn strip_subject_marker(subject: String) -> String {
+    match subject.strip_prefix('\u{1}') {
+        Some(rest) => rest.chars().skip(1).collect(),
+        None => subject,
+    }
+}
+
+/// Fixed-length types stored in the four-byte value slot of a property context
+fn is_inline_type(kind: u16) -> bool {
+    matches!(kind, 0x0002 | 0x0003 | 0x0004 | 0x000A | 0x000B)
+}
+
+/// Fixed-length types stored in the cell of a table row
+fn is_fixed_type(kind: u16) -> bool {
+    is_inline_type(kind) || matches!(kind, 0x0005..=0x0007 | 0x0014 | 0x0040)
+}
+
+/// A node: its data and its subnodes, each a block id
+#[derive(Clone, Copy)]
+struct Node {
+    data: u64,
+    subnodes: u64,
+}
+
+/// Subnodes of a node by node id
+type Subnodes = HashMap<u32, Node>;
+
+/// Heap on node: variable-sized items in the blocks of a node, addressed by HID
+struct Heap {
+    blocks: Vec<Vec<u8>>,
+}
+
+impl Heap {
+    fn new(blocks: Vec<Vec<u8>>, client: u8) -> Result<Self, String> {
+        let first = blocks.first().ok_or_else(damaged)?;
+        if bytes(first, 2, 2)? != [HN_SIGNATURE, client] {
+            return Err(damaged());
+        }
+        Ok(Heap { blocks })
+    }
+
+    fn user_root(&self) -> Result<u32, String> {
+        u32_at(&self.blocks[0], 4)
+    }
+
+    /// Item of a HID: block index in the high 16 bits, 1-based item index above the
+    /// five type bits. HID 0 is an empty item.
+    fn item(&self, hid: u32) -> Result<&[u8], String> {
+        if hid == 0 {
+            return Ok(&[]);
+        }
+        let index = ((hid >> 5) & 0x7FF) as usize;
+        let block = self.blocks.get((hid >> 16) as usize).ok_or_else(damaged)?;
+        let page_map = u16_at(block, 0)? as usize;
+        if index == 0 || index > u16_at(block, page_map)? as usize {
+            return Err(damaged());
+        }
+        let start = u16_at(block, page_map + 4 + (index - 1) * 2)? as usize;
+        let end = u16_at(block, page_map + 4 + index * 2)? as usize;
+        bytes(block, start, end.checked_sub(start).ok_or_else(damaged)?)
+    }
+
+    /// Records (key followed by data) of the B-tree on heap starting at `hid`
+    fn bth_records(&self, hid: u32) -> Result<Vec<Vec<u8>>, String> {
+        let header = bytes(self.item(hid)?, 0, 8)?;
+        if header[0] != HN_CLIENT_BTH {
+            return Err(damaged());
+        }
+        let (key_len, data_len, levels) = (header[1] as usize, header[2] as usize, header[3]);
+        let root = u32_at(header, 4)?;
+
+        let mut records = Vec::new();
+        self.collect_records(root, levels as usize, key_len, data_len, &mut records)?;
+        Ok(records)
+    }
+
+    fn collect_records(
+        &self,
+        hid: u32,
+        level: usize,
+        key_len: usize,
+        data_len: usize,
+        records: &mut Vec<Vec<u8>>,
+    ) -> Result<(), String> {
+        if hid == 0 {
+            return Ok(());
+        }
+        if level > MAX_TREE_DEPTH || key_len == 0 {
+            return Err(damaged());
+        }
+        let item = self.item(hid)?;
+        if level == 0 {
+            records.extend(item.chunks_exact(key_len + data_len).map(<[u8]>::to_vec));
+        } else {
+            for record in item.chunks_exact(key_len + 4) {
+                let child = u32_at(record, key_len)?;
+                self.collect_records(child, level - 1, key_len, data_len, records)?;
+            }
+        }
+        Ok(())
+    }
+}
+
+/// An open PST file
+struct PstReader {
+    file: File,
+    crypt_method: u8,
+    nbt_root: u64,
+    bbt_root: u64,
+}
+
+impl PstReader {
+    fn open(path: &Path) -> Result<Self, String> {
+        let mut file = File::open(path).map_err(|e| format!("Failed to open PST file: {}", e))?;
+        let mut header = vec![0u8; HEADER_LEN];
+        file.read_exact(&mut header).map_err(|_| "Not a PST file")?;
+        if !header.starts_with(HEADER_MAGIC) {
+            return Err("Not a PST file".to_string());
+        }
+
+        match u16_at(&header, OFFSET_VERSION)? {
+            version if version <= VERSION_ANSI_MAX => {
+                return Err("ANSI PST files (Outlook 97-2002) are not supported".to_string());
+            }
+            VERSION_UNICODE_4K => {
+                return Err("OST files of Outlook 2013 and later are not supported".to_string());
+            }
+            version if version < VERSION_UNICODE || version > VERSION_UNICODE_4K => {
+                return Err(format!("Unsupported PST file version {}", version));
+            }
+            _ => {}
+        }
+
+        let crypt_method = header[OFFSET_CRYPT_METHOD];
+        if crypt_method != NDB_CRYPT_NONE && crypt_method != NDB_CRYPT_PERMUTE {
+            return Err("PST files with high encryption are not supported".to_string());
+        }
+
+        Ok(PstReader {
+            file,
+            crypt_method,
+            nbt_root: u64_at(&header, OFFSET_NBT_ROOT)?,
+            bbt_root: u64_at(&header, OFFSET_BBT_ROOT)?,
+        })
+    }
+
+    fn read_at(&mut self, offset: u64, len: usize) -> Result<Vec<u8>, String> {
+        let mut data = vec![0u8; len];
+        self.file
+            .seek(SeekFrom::Start(offset))
+            .and_then(|_| self.file.read_exact(&mut data))
+            .map_err(|e| format!("Failed to read PST file: {}", e))?;
+        Ok(data)
+    }
+
+    /// Leaf entry of the node or block B-tree whose key (first eight bytes, compared
+    /// through `mask`) is `key`
+    fn btree_entry(
+        &mut self,
+        root: u64,
+        page_type: u8,
+        key: u64,
+        mask: u64,
+    ) -> Result<Option<Vec<u8>>, String> {
+        let mut offset = root;
+        for _ in 0..MAX_TREE_DEPTH {
+            let page = self.read_at(offset, PAGE_SIZE)?;
+            if page[OFFSET_PAGE_TYPE] != page_type {
+                return Err(damaged());
+            }
+            let count = page[OFFSET_PAGE_ENTRY_COUNT] as usize;
+            let size = page[OFFSET_PAGE_ENTRY_SIZE] as usize;
+            let entries = (0..count).map(|i| bytes(&page, i * size, size));
+
+            if page[OFFSET_PAGE_LEVEL] == 0 {
+                for entry in entries {
+                    let entry = entry?;
+                    if u64_at(entry, 0)? & mask == key {
+                        return Ok(Some(entry.to_vec()));
+                    }
+                }
+                return Ok(None);
+            }
+
+            // Child page of the last entry whose key is not greater than the wanted one
+            let mut child = None;
+            for entry in entries {
+                let entry = entry?;
+                if u64_at(entry, 0)? & mask > key {
+                    break;
+                }
+                child = Some(u64_at(entry, 16)?);
+            }
+            match child {
+                Some(child) => offset = child,
+                None => return Ok(None),
+            }
+        }
+        Err(damaged())
+    }
+
+    /// Contents of a block, decrypted
+    fn block(&mut self, bid: u64) -> Result<Vec<u8>, String> {
+        let entry = self
+            .btree_entry(self.bbt_root, PTYPE_BBT, bid & !1, !1)?
+            .ok_or_else(damaged)?;
+        let mut data = self.read_at(u64_at(&entry, 8)?, u16_at(&entry, 16)? as usize)?;
+        if bid & BID_INTERNAL == 0 && self.crypt_method == NDB_CRYPT_PERMUTE {
+            for byte in data.iter_mut() {
+                *byte = PERMUTE_DECODE[*byte as usize];
+            }
+        }
+        Ok(data)
+    }
+
+    /// Data blocks of a block tree (a single data block or XBLOCK/XXBLOCK)
+    fn data_blocks(&mut self, bid: u64) -> Result<Vec<Vec<u8>>, String> {
+        let mut blocks = Vec::new();
+        self.collect_blocks(bid, 0, &mut blocks)?;
+        Ok(blocks)
+    }
+
+    fn collect_blocks(
+        &mut self,
+        bid: u64,
+        depth: usize,
+        blocks: &mut Vec<Vec<u8>>,
+    ) -> Result<(), String> {
+        if bid == 0 {
+            return Ok(());
+        }
+        let data = self.block(bid)?;
+        if bid & BID_INTERNAL == 0 {
+            blocks.push(data);
+            return Ok(());
+        }
+        if data.first() != Some(&BTYPE_XBLOCK) || depth > 2 {
+            return Err(damaged());
+        }
+        for i in 0..u16_at(&data, 2)? as usize {
+            self.collect_blocks(u64_at(&data, 8 + i * 8)?, depth + 1, blocks)?;
+        }
+        Ok(())
+    }
+
+    /// Data of a block tree in one piece
+    fn data(&mut self, bid: u64) -> Result<Vec<u8>, String> {
+        Ok(self.data_blocks(bid)?.concat())
+    }
+
+    fn node(&mut self, nid: u32) -> Result<Option<Node>, String> {
+        let entry = self.btree_entry(self.nbt_root, PTYPE_NBT, u64::from(nid), 0xFFFF_FFFF)?;
+        entry
+            .map(|entry| {
+                Ok(Node {
+                    data: u64_at(&entry, 8)?,
+                    subnodes: u64_at(&entry, 16)?,
+                })
+            })
+            .transpose()
+    }
+
+    /// Subnode tree (SLBLOCK/SIBLOCK) of a node
+    fn subnodes(&mut self, bid: u64) -> Result<Subnodes, String> {
+        let mut subnodes = HashMap::new();
+        self.collect_subnodes(bid, 0, &mut subnodes)?;
+        Ok(subnodes)
+    }
+
+    fn collect_subnodes(
+        &mut self,
+        bid: u64,
+        depth: usize,
+        subnodes: &mut Subnodes,
+    ) -> Result<(), String> {
+        if bid == 0 {
+            return Ok(());
+        }
+        let data = self.block(bid)?;
+        if data.first() != Some(&BTYPE_SLBLOCK) || depth > 1 {
+            return Err(damaged());
+        }
+        let count = u16_at(&data, 2)? as usize;
+        if data[1] == 0 {
+            for i in 0..count {
+                let entry = bytes(&data, 8 + i * 24, 24)?;
+                let node = Node {
+                    data: u64_at(entry, 8)?,
+                    subnodes: u64_at(entry, 16)?,
+                };
+                subnodes.insert(u64_at(entry, 0)? as u32, node);
+            }
+        } else {
+            for i in 0..count {
+                let child = u64_at(&data, 8 + i * 16 + 8)?;
+                self.collect_subnodes(child, depth + 1, subnodes)?;
+            }
+        }
+        Ok(())
+    }
+
+    /// Value of an HNID: a heap item, or the data of a subnode for large values
+    fn hnid_value(
+        &mut self,
+        heap: &Heap,
+        hnid: u32,
+        subnodes: &Subnodes,
+    ) -> Result<Vec<u8>, String> {
+        if hnid & NID_TYPE_MASK == 0 {
+            return heap.item(hnid).map(<[u8]>::to_vec);
+        }
+        let node = subnodes.get(&hnid).ok_or_else(damaged)?;
+        self.data(node.data)
+    }
+
+    /// Property context: the properties of a folder, message or attachment
+    fn properties(&mut self, node: Node) -> Result<(Properties, Subnodes), String> {
+        let subnodes = self.subnodes(node.subnodes)?;
+        let heap = Heap::new(self.data_blocks(node.data)?, HN_CLIENT_PC)?;
+
+        let mut properties = Properties::default();
+        for record in heap.bth_records(heap.user_root()?)? {
+            let (id, kind) = (u16_at(&record, 0)?, u16_at(&record, 2)?);
+            let value = bytes(&record, 4, 4)?;
+            let data = if is_inline_type(kind) {
+                value.to_vec()
+            } else {
+                self.hnid_value(&heap, u32_at(value, 0)?, &subnodes)?
+            };
+            properties.insert(id, kind, data);
+        }
+        Ok((properties, subnodes))
+    }
+
+    /// Table context: one set of properties per row
+    fn table(&mut self, node: Node) -> Result<Vec<Properties>, String> {
+        let subnodes = self.subnodes(node.subnodes)?;
+        let heap = Heap::new(self.data_blocks(node.data)?, HN_CLIENT_TC)?;
+        let info = heap.item(heap.user_root()?)?;
+        if info.first() != Some(&HN_CLIENT_TC) {
+            return Err(damaged());
+        }
+
+        // Columns: property tag, offset and size of the cell, bit in the cell existence
+        // bitmap (which follows the cells)
+        let columns = (0..bytes(info, 1, 1)?[0] as usize)
+            .map(|i| bytes(info, 22 + i * 8, 8))
+            .collect::<Result<Vec<_>, _>>()?;
+        let bitmap_offset = u16_at(info, 6)? as usize;
+        let row_size = u16_at(info, 8)? as usize;
+        let rows_hnid = u32_at(info, 14)?;
+        if row_size == 0 {
+            return Err(damaged());
+        }
+
+        // Rows are in one heap item or, for larger tables, in the blocks of a subnode;
+        // rows never span blocks
+        let row_blocks = if rows_hnid == 0 {
+            Vec::new()
+        } else if rows_hnid & NID_TYPE_MASK == 0 {
+            vec![heap.item(rows_hnid)?.to_vec()]
+        } else {
+            let rows_node = *subnodes.get(&rows_hnid).ok_or_else(damaged)?;
+            self.data_blocks(rows_node.data)?
+        };
+
+        let mut rows = Vec::new();
+        for block in &row_blocks {
+            for row in block.chunks_exact(row_size) {
+                let mut properties = Properties::default();
+                for column in &columns {
+                    let (tag, bit) = (u32_at(column, 0)?, column[7] as usize);
+                    let exists = bytes(row, bitmap_offset + bit / 8, 1)?[0] & (0x80 >> (bit % 8));
+                    if exists == 0 {
+                        continue;
+                    }
+                    let (id, kind) = ((tag >> 16) as u16, tag as u16);
+                    let cell = bytes(row, u16_at(column, 4)? as usize, column[6] as usize)?;
+                    let data = if is_fixed_type(kind) {
+                        cell.to_vec()
+                    } else {
+                        self.hnid_value(&heap, u32_at(cell, 0)?, &subnodes)?
+                    };
+                    properties.insert(id, kind, data);
+                }
+                rows.push(properties);
+            }
+        }
+        Ok(rows)
+    }
+
+    /// Table of a folder (hierarchy or contents), empty if the folder has none
+    fn folder_table(&mut self, folder: u32, table_type: u32) -> Result<Vec<Properties>, String> {
+        match self.node((folder & !NID_TYPE_MASK) | table_type)? {
+            Some(node) => self.table(node),
+            None => Ok(Vec::new()),
+        }
+    }
+
+    /// A folder and its subfolders. Damaged subfolders are left out, and so are folders
+    /// already in the tree (`visited`), which a damaged hierarchy table can list again;
+    /// otherwise a folder listing itself twice would double the tree at every level.
+    fn folder(
+        &mut self,
+        id: u32,
+        depth: usize,
+        visited: &mut HashSet<u32>,
+    ) -> Result<PstFolder, String> {
+        visited.insert(id);
+        let node = self.node(id)?.ok_or_else(damaged)?;
+        let (properties, _) = self.properties(node)?;
+
+        let mut children = Vec::new();
+        if depth < MAX_FOLDER_DEPTH {
+            for row in self.folder_table(id, NID_TYPE_HIERARCHY_TABLE)? {
+                let Some(child) = row.long(PR_LTP_ROW_ID) else {
+                    continue;
+                };
+                if !visited.insert(child) {
+                    log_warn!("Skipping PST folder {:#x}, which is listed twice", child);
+                    continue;
+                }
+                match self.folder(child, depth + 1, visited) {
+                    Ok(folder) => children.push(folder),
+                    Err(e) => log_warn!("Skipping PST folder {:#x}: {}", child, e),
+                }
+            }
+        }
+
+        Ok(PstFolder {
+            id,
+            name: properties.string(PR_DISPLAY_NAME),
+            count: properties.long(PR_CONTENT_COUNT).unwrap_or(0),
+            children,
+        })
+    }
+
+    fn message_summaries(&mut self, folder: u32) -> Result<Vec<PstMessageSummary>, String> {
+        Ok(self
+            .folder_table(folder, NID_TYPE_CONTENTS_TABLE)?
+            .iter()
+            .filter_map(|row| {
+                let flags = row.long(PR_MESSAGE_FLAGS).unwrap_or(0);
+                Some(PstMessageSummary {
+                    id: row.long(PR_LTP_ROW_ID)?,
+                    subject: strip_subject_marker(row.string(PR_SUBJECT)),
+                    sender_name: row.first_string(&[PR_SENT_REPRESENTING_NAME, PR_SENDER_NAME]),
+                    date: row
+                        .time(PR_MESSAGE_DELIVERY_TIME)
+                        .or_else(|| row.time(PR_CLIENT_SUBMIT_TIME)),
+                    size: row.long(PR_MESSAGE_SIZE).unwrap_or(0),
+                    has_attachments: flags & MSGFLAG_HASATTACH != 0,
+                })
+            })
+            .collect())
+    }
+
+    /// A message with its recipients and attachments, and its status. Attachments are
+    /// kept in the message's subnodes, each with its own property context.
+    fn message(&mut self, id: u32) -> Result<(Message, MessageStatus), String> {
+        let node = self
+            .node(id)?
+            .ok_or_else(|| format!("Message {:#x} not found in PST file", id))?;
+        let (root, subnodes) = self.properties(node)?;
+
+        let mut recipients = Vec::new();
+        if let Some(table) = subnodes.get(&NID_RECIPIENT_TABLE) {
+            recipients = self.table(*table)?.iter().map(msg::recipient).collect();
+        }
+
+        let mut attachments = Vec::new();
+        if let Some(table) = subnodes.get(&NID_ATTACHMENT_TABLE) {
+            for row in self.table(*table)? {
+                let Some(attachment) = row
+                    .long(PR_LTP_ROW_ID)
+                    .and_then(|nid| subnodes.get(&nid).copied())
+                else {
+                    continue;
+                };
+                let (properties, _) = self.properties(attachment)?;
+                attachments.extend(msg::attachment(&properties));
+            }
+        }
+
+        let mut message = msg::message(&root, recipients, attachments);
+        message.subject = strip_subject_marker(message.subject);
+        Ok((message, msg::status(&root)))
+    }
+}
+
+/// An opened PST file and the contents tables read so far
+struct OpenPst {
+    reader: PstReader,
+    contents: HashMap<u32, Vec<PstMessageSummary>>,
+}
+
+impl OpenPst {
+    /// The contents table of a folder, read on first use
+    fn summaries(&mut self, folder: u32) -> Result<&[PstMessageSummary], String> {
+        if !self.contents.contains_key(&folder) {
+            let summaries = self.reader.message_summaries(folder)?;
+            self.contents.insert(folder, summaries);
+        }
+        Ok(&self.contents[&folder])
+    }
+}
+
+/// PST/OST files opened for browsing. Only the folder tree and the contents tables of
+/// visited folders are kept; messages are read when they are opened.
+pub struct PstFiles {
+    open: Mutex<HashMap<PathBuf, OpenPst>>,
+}
+
+impl PstFiles {
+    pub fn new() -> Self {
+        PstFiles {
+            open: Mutex::new(HashMap::new()),
+        }
+    }
+
+    /// Open a file and return its folder tree. The root folder has no name; its children
+    /// are e.g. "Top of Personal Folders".
+    pub fn open(&self, path: &Path) -> Result<PstFolder, String> {
+        let mut reader = PstReader::open(path)?;
+        let root = reader.folder(NID_ROOT_FOLDER, 0, &mut HashSet::new())?;
+        self.open.lock().unwrap().insert(
+            path.to_path_buf(),
+            OpenPst {
+                reader,
+                contents: HashMap::new(),
+            },
+        );
+        Ok(root)
+    }
+
+    /// `limit` messages (at most MAX_PAGE_SIZE) of a folder starting at `offset`
+    pub fn messages(
+        &self,
+        path: &Path,
+        folder: u32,
+        offset: usize,
+        limit: usize,
+    ) -> Result<PstPage, String> {
+        let mut open = self.open.lock().unwrap();
+        let pst = open.get_mut(path).ok_or("PST file is not open")?;
+        let summaries = pst.summaries(folder)?;
+        let end = offset.saturating_add(limit.min(MAX_PAGE_SIZE)).min(summaries.len());
+        Ok(PstPage {
+            offset,
+            total: summaries.len(),
+            messages: summaries.get(offset..end).unwrap_or_default().to_vec(),
+        })
+    }
+
+    pub fn message(&self, path: &Path, id: u32) -> Result<Message, String> {
+        self.message_with_status(path, id).map(|(message, _)| message)
+    }
+
+    /// A message and whether it was read, flagged, replied to, ... in Outlook
+    pub fn message_with_status(
+        &self,
+        path: &Path,
+        id: u32,
+    ) -> Result<(Message, MessageStatus), String> {
+        let mut open = self.open.lock().unwrap();
+        let pst = open.get_mut(path).ok_or("PST file is not open")?;
+        pst.reader.message(id)
+    }
+
+    /// A folder of an open file with its subfolders, e.g. to export a subtree
+    pub fn folder(&self, path: &Path, folder: u32) -> Result<PstFolder, String> {
+        let mut open = self.open.lock().unwrap();
+        let pst = open.get_mut(path).ok_or("PST file is not open")?;
+        pst.reader.folder(folder, 0, &mut HashSet::new())
+    }
+
+    /// Ids of all messages of a folder
+    pub fn message_ids(&self, path: &Path, folder: u32) -> Result<Vec<u32>, String> {
+        let mut open = self.open.lock().unwrap();
+        let pst = open.get_mut(path).ok_or("PST file is not open")?;
+        Ok(pst.summaries(folder)?.iter().map(|summary| summary.id).collect())
+    }
+
+    pub fn close(&self, path: &Path) {
+        self.open.lock().unwrap().remove(path);
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    const NBT_PAGE: usize = 1024;
+    const BBT_PAGE: usize = NBT_PAGE + PAGE_SIZE;
+    const FIRST_BLOCK: usize = BBT_PAGE + PAGE_SIZE;
+    /// Block of the root folder's property context
+    const ROOT_BID: u64 = 4;
+
+    /// A heap on node in one block; the first item is the user root
+    fn heap_block(client: u8, items: &[&[u8]]) -> Vec<u8> {
+        let mut block = vec![0u8; 12];
+        block[2] = HN_SIGNATURE;
+        block[3] = client;
+        block[4..8].copy_from_slice(&(1u32 << 5).to_le_bytes());
+        let mut offsets = vec![block.len() as u16];
+        for item in items {
+            block.extend_from_slice(item);
+            offsets.push(block.len() as u16);
+        }
+        let page_map = block.len() as u16;
+        block[0..2].copy_from_slice(&page_map.to_le_bytes());
+        block.extend_from_slice(&(items.len() as u16).to_le_bytes());
+        block.extend_from_slice(&[0, 0]);
+        for offset in offsets {
+            block.extend_from_slice(&offset.to_le_bytes());
+        }
+        block
+    }
+
+    /// Property context of a folder named "Inbox" with five messages
+    fn folder_properties() -> Vec<u8> {
+        let header = [&[HN_CLIENT_BTH, 2, 6, 0][..], &(2u32 << 5).to_le_bytes()].concat();
+        let mut records = Vec::new();
+        // Property id, type (PT_UNICODE or PT_LONG) and value or HID
+        let properties: [(u16, u16, u32); 2] =
+            [(PR_DISPLAY_NAME, 0x001F, 3 << 5), (PR_CONTENT_COUNT, 0x0003, 5)];
+        for (id, kind, value) in properties {
+            records.extend_from_slice(&id.to_le_bytes());
+            records.extend_from_slice(&kind.to_le_bytes());
+            records.extend_from_slice(&value.to_le_bytes());
+        }
+        let name: Vec<u8> = "Inbox".encode_utf16().flat_map(u16::to_le_bytes).collect();
+        heap_block(HN_CLIENT_PC, &[&header, &records, &name])
+    }
+
+    /// A Unicode PST file with one leaf page in each B-tree: nodes as (id, data block,
+    /// subnode block), blocks as (id, contents)
+    fn pst_file(
+        crypt_method: u8,
+        nodes: &[(u32, u64, u64)],
+        blocks: &[(u64, Vec<u8>)],
+    ) -> Vec<u8> {
+        let mut file = vec![0u8; FIRST_BLOCK];
+        file[..4].copy_from_slice(HEADER_MAGIC);
+        file[OFFSET_VERSION..OFFSET_VERSION + 2].copy_from_slice(&VERSION_UNICODE.to_le_bytes());
+        let nbt_root = (NBT_PAGE as u64).to_le_bytes();
+        let bbt_root = (BBT_PAGE as u64).to_le_bytes();
+        file[OFFSET_NBT_ROOT..OFFSET_NBT_ROOT + 8].copy_from_slice(&nbt_root);
+        file[OFFSET_BBT_ROOT..OFFSET_BBT_ROOT + 8].copy_from_slice(&bbt_root);
+        file[OFFSET_CRYPT_METHOD] = crypt_method;
+
+        let nbt = &mut file[NBT_PAGE..NBT_PAGE + PAGE_SIZE];
+        for (i, (nid, data, subnodes)) in nodes.iter().enumerate() {
+            let entry = &mut nbt[i * 32..i * 32 + 24];
+            entry[0..8].copy_from_slice(&u64::from(*nid).to_le_bytes());
+            entry[8..16].copy_from_slice(&data.to_le_bytes());
+            entry[16..24].copy_from_slice(&subnodes.to_le_bytes());
+        }
+        nbt[OFFSET_PAGE_ENTRY_COUNT] = nodes.len() as u8;
+        nbt[OFFSET_PAGE_ENTRY_SIZE] = 32;
+        nbt[OFFSET_PAGE_TYPE] = PTYPE_NBT;
+
+        let mut offset = FIRST_BLOCK;
+        let bbt = &mut file[BBT_PAGE..BBT_PAGE + PAGE_SIZE];
+        for (i, (bid, data)) in blocks.iter().enumerate() {
+            let entry = &mut bbt[i * 24..i * 24 + 18];
+            entry[0..8].copy_from_slice(&bid.to_le_bytes());
+            entry[8..16].copy_from_slice(&(offset as u64).to_le_bytes());
+            entry[16..18].copy_from_slice(&(data.len() as u16).to_le_bytes());
+            offset += data.len();
+        }
+        bbt[OFFSET_PAGE_ENTRY_COUNT] = blocks.len() as u8;
+        bbt[OFFSET_PAGE_ENTRY_SIZE] = 24;
+        bbt[OFFSET_PAGE_TYPE] = PTYPE_BBT;
+
+        for (bid, data) in blocks {
+            if crypt_method == NDB_CRYPT_PERMUTE && bid & BID_INTERNAL == 0 {
+                file.extend(data.iter().map(|byte| PERMUTE_ENCODE[*byte as usize]));
+            } else {
+                file.extend_from_slice(data);
+            }
+        }
+        file
+    }
+
+    /// Hierarchy table with a PR_LTP_ROW_ID column and one row per subfolder id
+    fn hierarchy_table(ids: &[u32]) -> Vec<u8> {
+        // TCINFO: 1 column, cells end at 4, the existence bitmap at 4, rows of 5 bytes
+        let mut info = vec![HN_CLIENT_TC, 1, 4, 0, 4, 0, 4, 0, 5, 0, 0, 0, 0, 0];
+        info.extend_from_slice(&(2u32 << 5).to_le_bytes());
+        info.extend_from_slice(&[0; 4]);
+        info.extend_from_slice(&((u32::from(PR_LTP_ROW_ID) << 16) | 0x0003).to_le_bytes());
+        info.extend_from_slice(&[0, 0, 4, 0]);
+        let rows: Vec<u8> = ids
+            .iter()
+            .flat_map(|id| [&id.to_le_bytes()[..], &[0x80]].concat())
+            .collect();
+        heap_block(HN_CLIENT_TC, &[&info, &rows])
+    }
+
+    /// A file with a root folder and nothing else
+    fn root_only(crypt_method: u8) -> Vec<u8> {
+        let nodes = [(NID_ROOT_FOLDER, ROOT_BID, 0)];
+        pst_file(crypt_method, &nodes, &[(ROOT_BID, folder_properties())])
+    }
+
+    /// Write a fixture to a file of its own, removed when dropped
+    struct TestFile(PathBuf);
+
+    impl TestFile {
+        fn new(name: &str, data: &[u8]) -> Self {
+            let file_name = format!("msgreader-pst-{}-{}.pst", std::process::id(), name);
+            let path = std::env::temp_dir().join(file_name);
+            std::fs::write(&path, data).unwrap();
+            TestFile(path)
+        }
+    }
+
+    impl Drop for TestFile {
+        fn drop(&mut self) {
+            let _ = std::fs::remove_file(&self.0);
+        }
+    }
+
+    fn open_error(name: &str, data: &[u8]) -> String {
+        let file = TestFile::new(name, data);
+        PstFiles::new().open(&file.0).err().unwrap()
+    }
+
+    #[test]
+    fn permute_tables_are_inverse() {
+        for byte in 0..=255u8 {
+            assert_eq!(PERMUTE_DECODE[PERMUTE_ENCODE[byte as usize] as usize], byte);
+        }
+    }
+
+    #[test]
+    fn subject_markers_are_stripped() {
+        assert_eq!(strip_subject_marker("\u{1}\u{4}RE: Hello".to_string()), "RE: Hello");
+        assert_eq!(strip_subject_marker("Hello".to_string()), "Hello");
+        assert_eq!(strip_subject_marker("\u{1}".to_string()), "");
+    }
+
+    #[test]
+    fn reads_out_of_range_are_damaged() {
+        let data = [1, 2, 3, 4];
+        assert_eq!(u16_at(&data, 2), Ok(0x0403));
+        assert_eq!(u32_at(&data, 1), Err(damaged()));
+        assert_eq!(bytes(&data, usize::MAX, 2), Err(damaged()));
+    }
+
+    #[test]
+    fn heap_items() {
+        let block = heap_block(HN_CLIENT_PC, &[b"first", b"", b"third"]);
+        let heap = Heap::new(vec![block], HN_CLIENT_PC).unwrap();
+        assert_eq!(heap.user_root(), Ok(1 << 5));
+        assert_eq!(heap.item(1 << 5), Ok(&b"first"[..]));
+        assert_eq!(heap.item(2 << 5), Ok(&b""[..]));
+        assert_eq!(heap.item(3 << 5), Ok(&b"third"[..]));
+        assert_eq!(heap.item(0), Ok(&b""[..]));
+        assert_eq!(heap.item(4 << 5), Err(damaged()));
+        assert_eq!(heap.item(1 << 16 | 1 << 5), Err(damaged()));
+
+        let block = heap_block(HN_CLIENT_TC, &[]);
+        assert!(Heap::new(vec![block.clone()], HN_CLIENT_PC).is_err());
+        assert!(Heap::new(Vec::new(), HN_CLIENT_TC).is_err());
+        assert!(Heap::new(vec![block[..3].to_vec()], HN_CLIENT_TC).is_err());
+    }
+
+    #[test]
+    fn bth_records_of_a_property_context() {
+        let heap = Heap::new(vec![folder_properties()], HN_CLIENT_PC).unwrap();
+        let records = heap.bth_records(heap.user_root().unwrap()).unwrap();
+        assert_eq!(records.len(), 2);
+        assert_eq!(&records[1][..4], &[0x02, 0x36, 0x03, 0x00]);
+
+        // The user root is no B-tree header, or a B-tree deeper than MAX_TREE_DEPTH
+        assert_eq!(heap.bth_records(3 << 5).err(), Some(damaged()));
+        let header = [&[HN_CLIENT_BTH, 2, 6, MAX_TREE_DEPTH as u8 + 1][..], &[0x40, 0, 0, 0]];
+        let block = heap_block(HN_CLIENT_PC, &[&header.concat(), b"x"]);
+        let deep = Heap::new(vec![block], HN_CLIENT_PC).unwrap();
+        assert_eq!(deep.bth_records(1 << 5).err(), Some(damaged()));
+    }
+
+    #[test]
+    fn opens_folders_of_unencrypted_and_permuted_files() {
+        for crypt_method in [NDB_CRYPT_NONE, NDB_CRYPT_PERMUTE] {
+            let file = TestFile::new(&format!("root-{}", crypt_method), &root_only(crypt_method));
+            let files = PstFiles::new();
+            let root = files.open(&file.0).unwrap();
+            assert_eq!(root.id, NID_ROOT_FOLDER);
+            assert_eq!(root.name, "Inbox");
+            assert_eq!(root.count, 5);
+            assert!(root.children.is_empty());
+
+            // A folder without contents table has no messages
+            let page = files.messages(&file.0, NID_ROOT_FOLDER, 0, 10).unwrap();
+            assert_eq!((page.total, page.messages.len()), (0, 0));
+            let error = files.message(&file.0, 0x20_0024).err().unwrap();
+            assert_eq!(error, "Message 0x200024 not found in PST file");
+
+            files.close(&file.0);
+            let error = files.messages(&file.0, NID_ROOT_FOLDER, 0, 10).err().unwrap();
+            assert_eq!(error, "PST file is not open");
+        }
+    }
+
+    #[test]
+    fn folders_listed_twice_are_left_out() {
+        // The root lists itself and a subfolder twice; the subfolder has the root's
+        // properties but no hierarchy table of its own
+        const TABLE_BID: u64 = 8;
+        const SUBFOLDER: u32 = 0x8022;
+        let table_nid = NID_ROOT_FOLDER & !NID_TYPE_MASK | NID_TYPE_HIERARCHY_TABLE;
+        let nodes = [
+            (NID_ROOT_FOLDER, ROOT_BID, 0),
+            (table_nid, TABLE_BID, 0),
+            (SUBFOLDER, ROOT_BID, 0),
+        ];
+        let ids = [NID_ROOT_FOLDER, SUBFOLDER, NID_ROOT_FOLDER, SUBFOLDER];
+        let blocks = [
+            (ROOT_BID, folder_properties()),
+            (TABLE_BID, hierarchy_table(&ids)),
+        ];
+        let file = TestFile::new("cycle", &pst_file(NDB_CRYPT_NONE, &nodes, &blocks));
+
+        let root = PstFiles::new().open(&file.0).unwrap();
+        assert_eq!(root.children.len(), 1);
+        assert_eq!(root.children[0].id, SUBFOLDER);
+        assert!(root.children[0].children.is_empty());
+    }
+
+    #[test]
+    fn rejects_other_files_and_versions() {
+        assert_eq!(open_error("short", b"!BDN"), "Not a PST file");
+        assert_eq!(open_error("magic", &[0u8; HEADER_LEN]), "Not a PST file");
+
+        let with_version = |version: u16| {
+            let mut file = root_only(NDB_CRYPT_NONE);
+            file[OFFSET_VERSION..OFFSET_VERSION + 2].copy_from_slice(&version.to_le_bytes());
+            file
+        };
+        let error = open_error("ansi", &with_version(14));
+        assert_eq!(error, "ANSI PST files (Outlook 97-2002) are not supported");
+        let error = open_error("ost", &with_version(VERSION_UNICODE_4K));
+        assert_eq!(error, "OST files of Outlook 2013 and later are not supported");
+        assert_eq!(open_error("version", &with_version(20)), "Unsupported PST file version 20");
+
+        let mut file = root_only(NDB_CRYPT_NONE);
+        file[OFFSET_CRYPT_METHOD] = 0x10;
+        let error = open_error("encrypted", &file);
+        assert_eq!(error, "PST files with high encryption are not supported");
+    }
+
+    #[test]
+    fn damaged_files_are_errors() {
+        // No root folder
+        let file = pst_file(NDB_CRYPT_NONE, &[], &[]);
+        assert_eq!(open_error("no-root", &file), damaged());
+
+        // A property context that is no heap
+        let nodes = [(NID_ROOT_FOLDER, ROOT_BID, 0)];
+        let file = pst_file(NDB_CRYPT_NONE, &nodes, &[(ROOT_BID, vec![0; 16])]);
+        assert_eq!(open_error("no-heap", &file), damaged());
+
+        // A block beyond the end of the file
+        let mut file = root_only(NDB_CRYPT_NONE);
+        file.truncate(FIRST_BLOCK + 8);
+        assert!(open_error("truncated", &file).starts_with("Failed to read PST file"));
+
+        // A page of the wrong type, and a B-tree page that is its own child
+        let mut file = root_only(NDB_CRYPT_NONE);
+        file[NBT_PAGE + OFFSET_PAGE_TYPE] = PTYPE_BBT;
+        assert_eq!(open_error("page-type", &file), damaged());
+        let mut file = root_only(NDB_CRYPT_NONE);
+        file[NBT_PAGE..NBT_PAGE + 8].copy_from_slice(&0u64.to_le_bytes());
+        file[NBT_PAGE + 16..NBT_PAGE + 24].copy_from_slice(&(NBT_PAGE as u64).to_le_bytes());
+        file[NBT_PAGE + OFFSET_PAGE_LEVEL] = 1;
+        assert_eq!(open_error("loop", &file), damaged());
+    }
+}
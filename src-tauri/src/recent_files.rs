@@ -0,0 +1,150 @@
+use std::path::{Path, PathBuf};
+use std::sync::Mutex;
+use std::time::{SystemTime, UNIX_EPOCH};
+
+/// Name of the recent files list in the app's config directory
+const FILE_NAME: &str = "recent-files.json";
+
+/// Number of unpinned entries kept; pinned entries stay until they are unpinned
+const MAX_ENTRIES: usize = 20;
+
+/// A file opened from the filesystem
+#[derive(serde::Serialize, serde::Deserialize, Clone)]
+#[serde(rename_all = "camelCase")]
+pub struct RecentFile {
+    pub path: String,
+    /// Unix time in milliseconds of the last open
+    pub opened_at: u64,
+    #[serde(default)]
+    pub pinned: bool,
+}
+
+/// Recently opened files (`<config dir>/recent-files.json`, one list per profile), most
+/// recent first. The lock keeps files opened at the same time from dropping each other.
+pub struct RecentFiles {
+    lock: Mutex<()>,
+}
+
+/// Location of the list for a profile (None for the default profile)
+pub fn file_path(config_dir: &Path, profile: Option<&str>) -> PathBuf {
+    match profile {
+        Some(profile) => config_dir.join(format!("recent-files-{}.json", profile)),
+        None => config_dir.join(FILE_NAME),
+    }
+}
+
+fn now_millis() -> u64 {
+    SystemTime::now()
+        .duration_since(UNIX_EPOCH)
+        .map(|d| d.as_millis() as u64)
+        .unwrap_or(0)
+}
+
+#[cfg(windows)]
+fn same_path(a: &str, b: &str) -> bool {
+    a.eq_ignore_ascii_case(b)
+}
+
+#[cfg(not(windows))]
+fn same_path(a: &str, b: &str) -> bool {
+    a == b
+}
+
+fn exists(file: &RecentFile) -> bool {
+    Path::new(&file.path).is_file()
+}
+
+/// A damaged list is started over instead of failing every open
+fn load(list_path: &Path) -> Result<Vec<RecentFile>, String> {
+    match std::fs::read_to_string(list_path) {
+        Ok(content) => Ok(serde_json::from_str(&content).unwrap_or_else(|e| {
+            log_warn!("Ignoring invalid {}: {}", FILE_NAME, e);
+            Vec::new()
+        })),
+        Err(e) if e.kind() == std::io::ErrorKind::NotFound => Ok(Vec::new()),
+        Err(e) => Err(format!("Failed to read {}: {}", FILE_NAME, e)),
+    }
+}
+
+fn save(list_path: &Path, files: &[RecentFile]) -> Result<(), String> {
+    if let Some(dir) = list_path.parent() {
+        std::fs::create_dir_all(dir)
+            .map_err(|e| format!("Failed to create config directory: {}", e))?;
+    }
+    let content = serde_json::to_string_pretty(files)
+        .map_err(|e| format!("Failed to serialize {}: {}", FILE_NAME, e))?;
+    std::fs::write(list_path, content).map_err(|e| format!("Failed to write {}: {}", FILE_NAME, e))
+}
+
+impl RecentFiles {
+    pub fn new() -> Self {
+        RecentFiles {
+            lock: Mutex::new(()),
+        }
+    }
+
+    /// Files that still exist, pinned files first
+    pub fn get(&self, list_path: &Path) -> Result<Vec<RecentFile>, String> {
+        let _guard = self.lock.lock().unwrap();
+        let mut files = load(list_path)?;
+        files.retain(exists);
+        files.sort_by_key(|file| !file.pinned);
+        Ok(files)
+    }
+
+    /// Move a file to the top of the list, keeping its pin. Deleted files are pruned and
+    /// the oldest unpinned entries are dropped beyond MAX_ENTRIES.
+    pub fn add(&self, list_path: &Path, path: &str) -> Result<(), String> {
+        let _guard = self.lock.lock().unwrap();
+        let mut files = load(list_path)?;
+
+        let pinned = files.iter().any(|file| file.pinned && same_path(&file.path, path));
+        files.retain(|file| !same_path(&file.path, path) && exists(file));
+        files.insert(
+            0,
+            RecentFile {
+                path: path.to_string(),
+                opened_at: now_millis(),
+                pinned,
+            },
+        );
+
+        let mut unpinned = 0;
+        files.retain(|file| {
+            if file.pinned {
+                return true;
+            }
+            unpinned += 1;
+            unpinned <= MAX_ENTRIES
+        });
+
+        save(list_path, &files)
+    }
+
+    pub fn pin(&self, list_path: &Path, path: &str, pinned: bool) -> Result<(), String> {
+        let _guard = self.lock.lock().unwrap();
+        let mut files = load(list_path)?;
+        let file = files
+            .iter_mut()
+            .find(|file| same_path(&file.path, path))
+            .ok_or_else(|| format!("Not a recent file: {}", path))?;
+        file.pinned = pinned;
+        save(list_path, &files)
+    }
+
+    /// Remove a file from the list, also if it is pinned
+    pub fn remove(&self, list_path: &Path, path: &str) -> Result<(), String> {
+        let _guard = self.lock.lock().unwrap();
+        let mut files = load(list_path)?;
+        files.retain(|file| !same_path(&file.path, path));
+        save(list_path, &files)
+    }
+
+    /// Remove all unpinned entries
+    pub fn clear(&self, list_path: &Path) -> Result<(), String> {
+        let _guard = self.lock.lock().unwrap();
+        let mut files = load(list_path)?;
+        files.retain(|file| file.pinned);
+        save(list_path, &files)
+    }
+}
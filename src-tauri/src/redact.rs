@@ -0,0 +1,295 @@
+use crate::message::Message;
+use std::collections::HashMap;
+
+/// Text that replaces removed names and addresses
+const REDACTED: &str = "[redacted]";
+/// Domain of pseudonymized addresses, reserved so that mail to them goes nowhere (RFC 2606)
+const PSEUDONYM_DOMAIN: &str = "redacted.invalid";
+/// Shorter names stay in the text, where they would mostly match other words
+const MIN_NAME_LENGTH: usize = 3;
+
+/// What a redacted copy does with the names and addresses of the people in a message
+#[derive(serde::Deserialize, Default, Clone, Copy, PartialEq, Eq)]
+#[serde(rename_all = "lowercase")]
+pub enum AddressMode {
+    Keep,
+    /// Left out of the headers and replaced with [redacted] in subject and body
+    Strip,
+    /// Each person becomes `Person <n>` with `person<n>@redacted.invalid`, the same
+    /// number wherever they appear
+    #[default]
+    Pseudonymize,
+}
+
+/// Options of a redacted copy, as chosen in the frontend
+#[derive(serde::Deserialize, Default)]
+#[serde(rename_all = "camelCase", default)]
+pub struct RedactionOptions {
+    pub addresses: AddressMode,
+    pub drop_attachments: bool,
+    /// Remove remote images of at most 1×1 pixel and hidden ones
+    pub remove_tracking_pixels: bool,
+}
+
+/// A sanitized copy of a message for sharing, e.g. with a support team or in a bug report.
+/// The raw transport headers are always left out: they carry addresses, host names and IP
+/// addresses that the options cannot account for.
+pub fn redact(mut message: Message, options: &RedactionOptions) -> Message {
+    message.headers.clear();
+
+    if options.addresses != AddressMode::Keep {
+        redact_people(&mut message, options.addresses);
+    }
+    if options.drop_attachments {
+        message.attachments.clear();
+        // Inline images would be broken links without their attachments
+        message.body_html = remove_images(&message.body_html, |tag| {
+            let src = attribute(tag, "src").unwrap_or_default();
+            src.trim().to_ascii_lowercase().starts_with("cid:")
+        });
+    }
+    if options.remove_tracking_pixels {
+        message.body_html = remove_images(&message.body_html, is_tracking_pixel);
+    }
+    message
+}
+
+/// Stand-ins for the people of a message, numbered in order of appearance
+struct People {
+    mode: AddressMode,
+    /// Number by lowercase address, or by lowercase name for people without one
+    numbers: HashMap<String, usize>,
+}
+
+impl People {
+    fn number(&mut self, key: &str) -> usize {
+        let next = self.numbers.len() + 1;
+        *self.numbers.entry(key.to_ascii_lowercase()).or_insert(next)
+    }
+
+    /// Name and address of a sender or recipient
+    fn person(&mut self, name: &str, email: &str) -> (String, String) {
+        match self.mode {
+            AddressMode::Keep => (name.to_string(), email.to_string()),
+            AddressMode::Strip => (String::new(), String::new()),
+            AddressMode::Pseudonymize => {
+                let number = self.number(if email.is_empty() { name } else { email });
+                (
+                    format!("Person {}", number),
+                    format!("person{}@{}", number, PSEUDONYM_DOMAIN),
+                )
+            }
+        }
+    }
+
+    /// An address found in the subject or body
+    fn address(&mut self, email: &str) -> String {
+        match self.mode {
+            AddressMode::Keep => email.to_string(),
+            AddressMode::Strip => REDACTED.to_string(),
+            AddressMode::Pseudonymize => {
+                format!("person{}@{}", self.number(email), PSEUDONYM_DOMAIN)
+            }
+        }
+    }
+}
+
+fn redact_people(message: &mut Message, mode: AddressMode) {
+    let mut people = People {
+        mode,
+        numbers: HashMap::new(),
+    };
+    // Names as they appear in the text, with what replaces them there
+    let mut names = Vec::new();
+    let mut stand_in = |original: String, name: &str| {
+        let replacement = if mode == AddressMode::Strip { REDACTED } else { name };
+        if original.chars().count() >= MIN_NAME_LENGTH && !original.contains('@') {
+            names.push((original, replacement.to_string()));
+        }
+    };
+
+    let (name, email) = people.person(&message.sender_name, &message.sender_email);
+    stand_in(std::mem::replace(&mut message.sender_name, name), &message.sender_name);
+    message.sender_email = email;
+    for recipient in &mut message.recipients {
+        let (name, email) = people.person(&recipient.name, &recipient.email);
+        stand_in(std::mem::replace(&mut recipient.name, name), &recipient.name);
+        recipient.email = email;
+    }
+    if mode == AddressMode::Strip {
+        message.recipients.clear();
+    }
+    // Message-IDs name the sender's mail server
+    message.message_id.clear();
+
+    // Longer names first, so "Alice Smith" is not replaced as "Alice" and "Smith"
+    names.sort_by(|a, b| b.0.len().cmp(&a.0.len()));
+    names.dedup_by(|a, b| a.0.eq_ignore_ascii_case(&b.0));
+    let replace_names = |text: &str| {
+        names.iter().fold(text.to_string(), |text, (name, replacement)| {
+            replace_word(&text, name, replacement)
+        })
+    };
+
+    let mut addresses = |text: &str| replace_addresses(text, |email| people.address(email));
+    message.subject = replace_names(&addresses(&message.subject));
+    message.body_text = replace_names(&addresses(&message.body_text));
+    // Addresses also in links and attributes, names only in the text
+    message.body_html = map_html_text(&addresses(&message.body_html), replace_names);
+}
+
+/// Replace every email address in `text` with what `replace` returns for it
+fn replace_addresses(text: &str, mut replace: impl FnMut(&str) -> String) -> String {
+    let is_local = |c: u8| c.is_ascii_alphanumeric() || b"._%+-".contains(&c);
+    let is_domain = |c: u8| c.is_ascii_alphanumeric() || c == b'.' || c == b'-';
+    let bytes = text.as_bytes();
+    let mut result = String::with_capacity(text.len());
+    let mut position = 0;
+    for (at, _) in text.match_indices('@') {
+        if at < position {
+            continue;
+        }
+        let start = bytes[position..at]
+            .iter()
+            .rposition(|&c| !is_local(c))
+            .map_or(position, |index| position + index + 1);
+        let end = bytes[at + 1..]
+            .iter()
+            .position(|&c| !is_domain(c))
+            .map_or(bytes.len(), |index| at + 1 + index);
+        // A dot after the domain ends the sentence
+        let domain = text[at + 1..end].trim_end_matches('.');
+        if start == at || domain.starts_with('.') || !domain.contains('.') {
+            continue;
+        }
+        let end = at + 1 + domain.len();
+        result.push_str(&text[position..start]);
+        result.push_str(&replace(&text[start..end]));
+        position = end;
+    }
+    result.push_str(&text[position..]);
+    result
+}
+
+/// Replace `word` where it is a whole word, ignoring ASCII case
+fn replace_word(text: &str, word: &str, replacement: &str) -> String {
+    let lower = text.to_ascii_lowercase();
+    let word = word.to_ascii_lowercase();
+    let is_letter = |c: Option<char>| c.is_some_and(char::is_alphanumeric);
+    let mut result = String::with_capacity(text.len());
+    let mut position = 0;
+    let mut search = 0;
+    while let Some(found) = lower[search..].find(&word) {
+        let start = search + found;
+        let end = start + word.len();
+        search = end;
+        if is_letter(text[..start].chars().next_back()) || is_letter(text[end..].chars().next()) {
+            continue;
+        }
+        result.push_str(&text[position..start]);
+        result.push_str(replacement);
+        position = end;
+    }
+    result.push_str(&text[position..]);
+    result
+}
+
+/// Apply `map` to the text between the tags of an HTML body; tags, styles and scripts
+/// are kept as they are
+fn map_html_text(html: &str, map: impl Fn(&str) -> String) -> String {
+    let lower = html.to_ascii_lowercase();
+    let mut result = String::with_capacity(html.len());
+    let mut position = 0;
+    while position < html.len() {
+        let tag_start = lower[position..]
+            .find('<')
+            .map_or(html.len(), |index| position + index);
+        result.push_str(&map(&html[position..tag_start]));
+        if tag_start == html.len() {
+            break;
+        }
+        let tag_end = lower[tag_start..]
+            .find('>')
+            .map_or(html.len(), |index| tag_start + index + 1);
+        let end = ["style", "script"]
+            .iter()
+            .find(|name| lower[tag_start + 1..].starts_with(*name))
+            .and_then(|name| lower[tag_end..].find(&format!("</{}", name)))
+            .map_or(tag_end, |index| tag_end + index);
+        result.push_str(&html[tag_start..end]);
+        position = end;
+    }
+    result
+}
+
+/// Value of an attribute of an HTML tag, without its quotes
+pub(crate) fn attribute(tag: &str, name: &str) -> Option<String> {
+    let lower = tag.to_ascii_lowercase();
+    let mut search = 0;
+    while let Some(found) = lower[search..].find(name) {
+        let start = search + found;
+        search = start + name.len();
+        let rest = lower[search..].trim_start();
+        if !lower[..start].ends_with(|c: char| c.is_ascii_whitespace()) || !rest.starts_with('=') {
+            continue;
+        }
+        let value = tag[tag.len() - rest.len() + 1..].trim_start();
+        let value = match value.chars().next() {
+            Some(quote @ ('"' | '\'')) => value[1..].split(quote).next(),
+            _ => value.split(|c: char| c.is_ascii_whitespace() || c == '>').next(),
+        };
+        return value.map(str::to_string);
+    }
+    None
+}
+
+/// Remote images of at most 1×1 pixel or hidden ones, which are there to report that the
+/// message was opened
+fn is_tracking_pixel(tag: &str) -> bool {
+    let remote = attribute(tag, "src").is_some_and(|src| {
+        let src = src.trim().to_ascii_lowercase();
+        src.starts_with("http:") || src.starts_with("https:") || src.starts_with("//")
+    });
+    let tiny = |name: &str| {
+        attribute(tag, name)
+            .and_then(|value| value.trim().trim_end_matches("px").parse::<u32>().ok())
+            .is_some_and(|pixels| pixels <= 1)
+    };
+    let style = attribute(tag, "style")
+        .unwrap_or_default()
+        .to_ascii_lowercase()
+        .replace(' ', "");
+    let hidden = [
+        "display:none",
+        "visibility:hidden",
+        "width:0",
+        "height:0",
+        "width:1px",
+        "height:1px",
+    ]
+    .iter()
+    .any(|rule| style.contains(rule));
+    remote && (tiny("width") || tiny("height") || hidden)
+}
+
+/// Remove the `<img>` tags that `matches` returns true for
+fn remove_images(html: &str, matches: impl Fn(&str) -> bool) -> String {
+    let lower = html.to_ascii_lowercase();
+    let mut result = String::with_capacity(html.len());
+    let mut position = 0;
+    let mut search = 0;
+    while let Some(found) = lower[search..].find("<img") {
+        let start = search + found;
+        let Some(length) = lower[start..].find('>') else {
+            break;
+        };
+        let end = start + length + 1;
+        search = end;
+        if matches(&html[start..end]) {
+            result.push_str(&html[position..start]);
+            position = end;
+        }
+    }
+    result.push_str(&html[position..]);
+    result
+}
@@ -0,0 +1,256 @@
+use crate::overrides::{self, Overrides};
+use crate::policy::Policy;
+use crate::proxy;
+use sha2::{Digest, Sha256};
+use std::collections::hash_map::RandomState;
+use std::hash::{BuildHasher, Hasher};
+use std::io::Read;
+use std::path::{Path, PathBuf};
+use std::time::{Duration, SystemTime, UNIX_EPOCH};
+use tauri::http::{header, Request, Response, StatusCode};
+use tauri::{AppHandle, Manager};
+
+/// URI scheme the message view loads remote images through
+pub const SCHEME: &str = "remote-image";
+
+/// Folder of the image cache in the local data directory
+const CACHE_DIR: &str = "image-cache";
+
+const REQUEST_TIMEOUT: Duration = Duration::from_secs(15);
+
+/// Larger images are not loaded
+const MAX_IMAGE_SIZE: u64 = 10 * 1024 * 1024;
+
+/// The cache is trimmed to this size after each new image, oldest images first
+const MAX_CACHE_SIZE: u64 = 200 * 1024 * 1024;
+
+/// Local proxy for the remote images of messages. The frontend only points images at it
+/// once the user loads them (or allowed the sender), so tracking pixels are not
+/// fetched when a message is opened. Images are fetched without cookies or referrer
+/// through the configured network proxy and kept in an on-disk cache, so a message
+/// opened again does not contact the sender's servers again.
+pub struct RemoteImages {
+    /// Part of every proxy URL, so message HTML cannot point at the proxy by itself
+    token: String,
+}
+
+/// Failure of a proxy request with the status it is answered with
+struct Refusal(StatusCode, String);
+
+fn session_token() -> String {
+    let nanos = SystemTime::now()
+        .duration_since(UNIX_EPOCH)
+        .map(|duration| duration.as_nanos())
+        .unwrap_or(0);
+    // RandomState is seeded from the OS random number generator
+    let random = |salt: u64| {
+        let mut hasher = RandomState::new().build_hasher();
+        hasher.write_u64(salt);
+        hasher.write_u128(nanos);
+        hasher.write_u32(std::process::id());
+        hasher.finish()
+    };
+    format!("{:016x}{:016x}", random(1), random(2))
+}
+
+fn percent_decode(value: &str) -> Option<String> {
+    let bytes = value.as_bytes();
+    let mut decoded = Vec::with_capacity(bytes.len());
+    let mut index = 0;
+    while index < bytes.len() {
+        if bytes[index] == b'%' {
+            let hex = value.get(index + 1..index + 3)?;
+            decoded.push(u8::from_str_radix(hex, 16).ok()?);
+            index += 3;
+        } else {
+            decoded.push(bytes[index]);
+            index += 1;
+        }
+    }
+    String::from_utf8(decoded).ok()
+}
+
+fn cache_dir(app: &AppHandle) -> Result<PathBuf, String> {
+    Ok(overrides::local_data_dir(app)?.join(CACHE_DIR))
+}
+
+fn cache_file(dir: &Path, url: &str) -> PathBuf {
+    let digest: String = Sha256::digest(url.as_bytes())
+        .iter()
+        .map(|byte| format!("{:02x}", byte))
+        .collect();
+    dir.join(digest)
+}
+
+/// A cached image: its MIME type on the first line, then the image bytes
+fn read_cached(path: &Path) -> Option<(String, Vec<u8>)> {
+    let content = std::fs::read(path).ok()?;
+    let newline = content.iter().position(|&byte| byte == b'\n')?;
+    let mime_type = String::from_utf8(content[..newline].to_vec()).ok()?;
+    Some((mime_type, content[newline + 1..].to_vec()))
+}
+
+fn write_cached(dir: &Path, path: &Path, mime_type: &str, bytes: &[u8]) -> Result<(), String> {
+    std::fs::create_dir_all(dir)
+        .map_err(|e| format!("Failed to create {}: {}", dir.display(), e))?;
+    let mut content = Vec::with_capacity(mime_type.len() + 1 + bytes.len());
+    content.extend_from_slice(mime_type.as_bytes());
+    content.push(b'\n');
+    content.extend_from_slice(bytes);
+    // Written under another name first, so a half-written file is never served
+    let part = path.with_extension("part");
+    std::fs::write(&part, content)
+        .and_then(|_| std::fs::rename(&part, path))
+        .map_err(|e| format!("Failed to cache image: {}", e))
+}
+
+/// Remove the oldest images until the cache fits MAX_CACHE_SIZE
+fn trim_cache(dir: &Path) {
+    let Ok(entries) = std::fs::read_dir(dir) else {
+        return;
+    };
+    let mut files: Vec<(SystemTime, u64, PathBuf)> = entries
+        .flatten()
+        .filter_map(|entry| {
+            let metadata = entry.metadata().ok().filter(|metadata| metadata.is_file())?;
+            Some((metadata.modified().ok()?, metadata.len(), entry.path()))
+        })
+        .collect();
+    let mut total: u64 = files.iter().map(|(_, len, _)| len).sum();
+    if total <= MAX_CACHE_SIZE {
+        return;
+    }
+    files.sort();
+    for (_, len, path) in files {
+        if total <= MAX_CACHE_SIZE {
+            break;
+        }
+        if std::fs::remove_file(&path).is_ok() {
+            total -= len;
+        }
+    }
+}
+
+fn fetch(app: &AppHandle, url: &str) -> Result<(String, Vec<u8>), Refusal> {
+    let bad_gateway = |message: String| Refusal(StatusCode::BAD_GATEWAY, message);
+    let config = overrides::config_dir(app)
+        .and_then(|dir| proxy::load(&dir.join(proxy::CONFIG_FILE)))
+        .map_err(bad_gateway)?;
+    let agent = proxy::agent_for(&config, url, REQUEST_TIMEOUT).map_err(bad_gateway)?;
+    let response = agent
+        .get(url)
+        .call()
+        .map_err(|e| bad_gateway(format!("Failed to load {}: {}", url, e)))?;
+
+    // Only images: the proxy must not become a way to load pages or scripts
+    let mime_type = response.content_type().to_lowercase();
+    if !mime_type.starts_with("image/") {
+        return Err(Refusal(
+            StatusCode::UNSUPPORTED_MEDIA_TYPE,
+            format!("Not an image: {}", mime_type),
+        ));
+    }
+    let mut bytes = Vec::new();
+    response
+        .into_reader()
+        .take(MAX_IMAGE_SIZE + 1)
+        .read_to_end(&mut bytes)
+        .map_err(|e| bad_gateway(format!("Failed to load {}: {}", url, e)))?;
+    if bytes.len() as u64 > MAX_IMAGE_SIZE {
+        return Err(Refusal(StatusCode::PAYLOAD_TOO_LARGE, "Image too large".to_string()));
+    }
+    Ok((mime_type, bytes))
+}
+
+impl RemoteImages {
+    pub fn new() -> Self {
+        RemoteImages {
+            token: session_token(),
+        }
+    }
+
+    /// URL the remote URLs are appended to (percent-encoded) to load them through the
+    /// proxy. WebView2 and Android reach custom schemes as `http://<scheme>.localhost`.
+    pub fn base_url(&self) -> String {
+        if cfg!(any(windows, target_os = "android")) {
+            format!("http://{}.localhost/{}/", SCHEME, self.token)
+        } else {
+            format!("{}://localhost/{}/", SCHEME, self.token)
+        }
+    }
+
+    /// The image for a proxy request path, `/<token>/<percent-encoded URL>`, from the
+    /// cache or the network
+    fn load(&self, app: &AppHandle, path: &str) -> Result<(String, Vec<u8>), Refusal> {
+        let forbidden = |message: &str| Refusal(StatusCode::FORBIDDEN, message.to_string());
+        let (token, encoded) = path
+            .trim_start_matches('/')
+            .split_once('/')
+            .ok_or_else(|| forbidden("Invalid proxy URL"))?;
+        if token != self.token {
+            return Err(forbidden("Invalid proxy URL"));
+        }
+        if app.state::<Policy>().block_external_content {
+            return Err(forbidden("External content is blocked by policy"));
+        }
+        let url = percent_decode(encoded)
+            .filter(|url| {
+                let lower = url.to_lowercase();
+                lower.starts_with("http://") || lower.starts_with("https://")
+            })
+            .ok_or_else(|| Refusal(StatusCode::BAD_REQUEST, "Not an http(s) URL".to_string()))?;
+
+        let dir = cache_dir(app).map_err(|e| Refusal(StatusCode::INTERNAL_SERVER_ERROR, e))?;
+        let file = cache_file(&dir, &url);
+        if let Some(cached) = read_cached(&file) {
+            return Ok(cached);
+        }
+        if app.state::<Overrides>().offline {
+            return Err(forbidden("Networking is disabled (offline mode)"));
+        }
+
+        let (mime_type, bytes) = fetch(app, &url)?;
+        match write_cached(&dir, &file, &mime_type, &bytes) {
+            Ok(()) => trim_cache(&dir),
+            Err(e) => log_warn!("{}", e),
+        }
+        Ok((mime_type, bytes))
+    }
+
+    /// Answer a request of the `remote-image` scheme
+    pub fn respond(&self, app: &AppHandle, request: &Request<Vec<u8>>) -> Response<Vec<u8>> {
+        let response = match self.load(app, request.uri().path()) {
+            Ok((mime_type, bytes)) => Response::builder()
+                .status(StatusCode::OK)
+                .header(header::CONTENT_TYPE, mime_type)
+                .header(header::CACHE_CONTROL, "private, max-age=86400")
+                .body(bytes),
+            Err(Refusal(status, message)) => {
+                if status == StatusCode::BAD_GATEWAY {
+                    log_warn!("{}", message);
+                }
+                Response::builder()
+                    .status(status)
+                    .header(header::CONTENT_TYPE, "text/plain")
+                    .body(message.into_bytes())
+            }
+        };
+        response.unwrap_or_else(|_| Response::new(Vec::new()))
+    }
+}
+
+/// Delete all cached images, returns how many were removed
+pub fn clear_cache(app: &AppHandle) -> Result<usize, String> {
+    let dir = cache_dir(app)?;
+    let entries = match std::fs::read_dir(&dir) {
+        Ok(entries) => entries,
+        Err(e) if e.kind() == std::io::ErrorKind::NotFound => return Ok(0),
+        Err(e) => return Err(format!("Failed to read {}: {}", dir.display(), e)),
+    };
+    let removed = entries
+        .flatten()
+        .filter(|entry| std::fs::remove_file(entry.path()).is_ok())
+        .count();
+    log_info!("Removed {} cached images", removed);
+    Ok(removed)
+}
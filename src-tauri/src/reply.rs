@@ -0,0 +1,415 @@
+use crate::eml;
+use crate::headers::CFB_SIGNATURE;
+use crate::message::{Attachment, Message, Recipient};
+use crate::{msg, sanitize};
+use std::collections::HashMap;
+
+/// Length of a mailto: URL that Windows (ShellExecute) and most mail clients still accept
+const MAILTO_LENGTH_LIMIT: usize = 2000;
+
+/// Prefixes of replies and forwards a subject may already have, in several languages
+const REPLY_PREFIXES: &[&str] = &["re:", "aw:", "sv:", "antw:", "r:", "ref:"];
+const FORWARD_PREFIXES: &[&str] = &["fw:", "fwd:", "wg:", "tr:", "vs:", "rv:", "i:"];
+
+#[derive(serde::Deserialize, Clone, Copy, PartialEq, Eq)]
+#[serde(rename_all = "camelCase")]
+pub enum Mode {
+    /// To the sender, or the Reply-To address
+    Reply,
+    /// To the sender, with the other recipients in Cc
+    ReplyAll,
+    /// To nobody yet, with the attachments
+    Forward,
+}
+
+#[derive(serde::Deserialize, Clone, Copy, PartialEq, Eq)]
+#[serde(rename_all = "lowercase")]
+pub enum Handoff {
+    /// A mailto: URL: works with every mail client, but has no HTML, no attachments and
+    /// a body shortened to the URL length limit
+    Mailto,
+    /// An unsent .eml draft (X-Unsent: 1) opened with the default app for .eml files
+    Draft,
+}
+
+/// A reply or forward, ready to hand off
+pub struct Draft {
+    pub message: Message,
+    pub in_reply_to: String,
+    pub references: String,
+}
+
+/// Unfolded value of a header field in a raw header block
+pub(crate) fn header(headers: &str, name: &str) -> Option<String> {
+    let mut value: Option<String> = None;
+    for line in headers.lines() {
+        if line.starts_with([' ', '\t']) {
+            if let Some(value) = value.as_mut() {
+                value.push(' ');
+                value.push_str(line.trim());
+            }
+            continue;
+        }
+        if value.is_some() {
+            break;
+        }
+        if let Some((field, rest)) = line.split_once(':') {
+            if field.trim().eq_ignore_ascii_case(name) {
+                value = Some(rest.trim().to_string());
+            }
+        }
+    }
+    value.filter(|value| !value.is_empty())
+}
+
+/// Addresses of an address list header (`"Name" <a@example.com>, b@example.com`); names
+/// are left out as they may be encoded words
+pub(crate) fn addresses(list: &str) -> Vec<String> {
+    let mut entries = Vec::new();
+    let mut start = 0;
+    let mut quoted = false;
+    for (i, c) in list.char_indices() {
+        match c {
+            '"' => quoted = !quoted,
+            ',' if !quoted => {
+                entries.push(&list[start..i]);
+                start = i + 1;
+            }
+            _ => {}
+        }
+    }
+    entries.push(&list[start..]);
+
+    entries
+        .into_iter()
+        .filter_map(|entry| {
+            let email = match (entry.rfind('<'), entry.rfind('>')) {
+                (Some(open), Some(close)) if open < close => &entry[open + 1..close],
+                _ => entry,
+            };
+            let email = email.trim();
+            email.contains('@').then(|| email.to_string())
+        })
+        .collect()
+}
+
+/// The subject with one reply or forward prefix, not one more for every round
+fn subject(original: &str, mode: Mode) -> String {
+    let (prefix, known) = match mode {
+        Mode::Forward => ("Fw: ", FORWARD_PREFIXES),
+        _ => ("Re: ", REPLY_PREFIXES),
+    };
+    let original = original.trim();
+    let lower = original.to_lowercase();
+    if known.iter().any(|known| lower.starts_with(known)) {
+        original.to_string()
+    } else {
+        format!("{}{}", prefix, original)
+    }
+}
+
+fn recipient(name: &str, email: &str, kind: &'static str) -> Recipient {
+    Recipient {
+        name: name.to_string(),
+        email: email.to_string(),
+        kind,
+    }
+}
+
+/// To and Cc of the reply: the Reply-To addresses or the sender, and for reply all
+/// the original To and Cc recipients, each address once
+fn recipients(original: &Message, mode: Mode) -> Vec<Recipient> {
+    if mode == Mode::Forward {
+        return Vec::new();
+    }
+    let mut result: Vec<Recipient> = match header(&original.headers, "reply-to") {
+        Some(reply_to) if !addresses(&reply_to).is_empty() => addresses(&reply_to)
+            .iter()
+            .map(|email| recipient("", email, "to"))
+            .collect(),
+        _ => vec![recipient(&original.sender_name, &original.sender_email, "to")],
+    };
+    if mode == Mode::ReplyAll {
+        for other in original.recipients.iter().filter(|r| r.kind != "bcc") {
+            let known = result
+                .iter()
+                .any(|known| known.email.eq_ignore_ascii_case(&other.email));
+            if !other.email.is_empty() && !known {
+                result.push(recipient(&other.name, &other.email, "cc"));
+            }
+        }
+    }
+    result.retain(|recipient| !recipient.email.is_empty());
+    result
+}
+
+fn display_address(name: &str, email: &str) -> String {
+    match (name.is_empty(), email.is_empty()) {
+        (true, _) => email.to_string(),
+        (false, true) => name.to_string(),
+        (false, false) => format!("{} <{}>", name, email),
+    }
+}
+
+/// Date of the original as its header has it, or from the date Outlook stored
+fn date_line(original: &Message) -> String {
+    header(&original.headers, "date")
+        .or_else(|| original.date.map(eml::format_date))
+        .unwrap_or_default()
+}
+
+/// Lines above the quoted text: "On <date>, <sender> wrote:" for replies, the original
+/// header for forwards
+fn attribution(original: &Message, mode: Mode) -> Vec<String> {
+    let sender = display_address(&original.sender_name, &original.sender_email);
+    if mode != Mode::Forward {
+        return match date_line(original) {
+            date if date.is_empty() => vec![format!("{} wrote:", sender)],
+            date => vec![format!("On {}, {} wrote:", date, sender)],
+        };
+    }
+
+    let list = |kind: &str| {
+        original
+            .recipients
+            .iter()
+            .filter(|recipient| recipient.kind == kind)
+            .map(|recipient| display_address(&recipient.name, &recipient.email))
+            .collect::<Vec<_>>()
+            .join(", ")
+    };
+    let mut lines = vec!["---------- Forwarded message ----------".to_string()];
+    for (name, value) in [
+        ("From", sender),
+        ("Date", date_line(original)),
+        ("Subject", original.subject.clone()),
+        ("To", list("to")),
+        ("Cc", list("cc")),
+    ] {
+        if !value.is_empty() {
+            lines.push(format!("{}: {}", name, value));
+        }
+    }
+    lines
+}
+
+/// Text body: an empty line to write in, the attribution and the original text,
+/// quoted with "> " for replies
+fn text_body(original: &Message, mode: Mode) -> String {
+    let mut body = String::from("\r\n\r\n");
+    for line in attribution(original, mode) {
+        body.push_str(&line);
+        body.push_str("\r\n");
+    }
+    if mode == Mode::Forward {
+        body.push_str("\r\n");
+    }
+    for line in original.plain_text().lines() {
+        if mode != Mode::Forward {
+            body.push_str(if line.is_empty() { ">" } else { "> " });
+        }
+        body.push_str(line);
+        body.push_str("\r\n");
+    }
+    body
+}
+
+fn escape(text: &str) -> String {
+    text.replace('&', "&amp;").replace('<', "&lt;").replace('>', "&gt;")
+}
+
+/// What is inside <body>, or the whole document if it has none
+fn body_content(html: &str) -> &str {
+    let lower = html.to_ascii_lowercase();
+    let Some(open) = lower.find("<body") else {
+        return html;
+    };
+    let Some(start) = lower[open..].find('>').map(|end| open + end + 1) else {
+        return html;
+    };
+    let end = lower.rfind("</body").filter(|&end| end >= start).unwrap_or(html.len());
+    &html[start..end]
+}
+
+/// HTML body: the attribution and the sanitized original body in a blockquote for
+/// replies, below the forwarded header for forwards. Inline images keep their cid:.
+fn html_body(original: &Message, mode: Mode) -> String {
+    let quoted = if original.body_html.trim().is_empty() {
+        format!(
+            "<pre style=\"white-space:pre-wrap\">{}</pre>",
+            escape(&original.plain_text())
+        )
+    } else {
+        let cids = original
+            .attachments
+            .iter()
+            .filter(|attachment| !attachment.content_id.is_empty())
+            .map(|attachment| {
+                let cid = attachment.content_id.trim_matches(['<', '>']).to_string();
+                (cid.clone(), format!("cid:{}", cid))
+            })
+            .collect::<HashMap<_, _>>();
+        sanitize::sanitize(body_content(&original.body_html), cids)
+    };
+    let attribution = attribution(original, mode)
+        .iter()
+        .map(|line| escape(line))
+        .collect::<Vec<_>>()
+        .join("<br>\r\n");
+
+    if mode == Mode::Forward {
+        format!("<p><br></p>\r\n<div>{}</div>\r\n<br>\r\n{}", attribution, quoted)
+    } else {
+        format!(
+            "<p><br></p>\r\n<div>{}</div>\r\n<blockquote style=\"margin:0 0 0 .8ex;\
+             border-left:1px solid #ccc;padding-left:1ex\">{}</blockquote>",
+            attribution, quoted
+        )
+    }
+}
+
+fn copy_attachment(attachment: &Attachment) -> Attachment {
+    Attachment {
+        file_name: attachment.file_name.clone(),
+        mime_type: attachment.mime_type.clone(),
+        content_id: attachment.content_id.clone(),
+        size: attachment.size,
+        content_base64: attachment.content_base64.clone(),
+    }
+}
+
+/// Build a reply, reply all or forward of a message
+pub fn build(original: &Message, mode: Mode) -> Draft {
+    let body_html = html_body(original, mode);
+    // Forwards carry every attachment, replies only the images the quote shows
+    let attachments = original
+        .attachments
+        .iter()
+        .filter(|attachment| {
+            mode == Mode::Forward
+                || (!attachment.content_id.is_empty()
+                    && body_html.contains(&format!("cid:{}", attachment.content_id)))
+        })
+        .map(copy_attachment)
+        .collect();
+
+    // mail-parser leaves out the angle brackets, Outlook keeps them
+    let message_id = match original.message_id.trim().trim_matches(['<', '>']) {
+        "" => String::new(),
+        id => format!("<{}>", id),
+    };
+    let references = match header(&original.headers, "references") {
+        Some(references) if !message_id.is_empty() => format!("{} {}", references, message_id),
+        Some(references) => references,
+        None => message_id.clone(),
+    };
+    Draft {
+        message: Message {
+            subject: subject(&original.subject, mode),
+            recipients: recipients(original, mode),
+            body_text: text_body(original, mode),
+            body_html,
+            attachments,
+            ..Message::default()
+        },
+        in_reply_to: if mode == Mode::Forward {
+            String::new()
+        } else {
+            message_id
+        },
+        references: if mode == Mode::Forward {
+            String::new()
+        } else {
+            references
+        },
+    }
+}
+
+/// Parse a message (.msg or .eml file) and build a reply or forward of it
+pub fn build_from_bytes(data: &[u8], mode: Mode) -> Result<Draft, String> {
+    let original = if data.starts_with(&CFB_SIGNATURE) {
+        msg::parse_bytes(data)?
+    } else {
+        eml::parse_bytes(data)?
+    };
+    Ok(build(&original, mode))
+}
+
+/// RFC 6068 percent-encoding: everything but unreserved characters
+fn percent_encode(text: &str) -> String {
+    let mut encoded = String::with_capacity(text.len());
+    for byte in text.bytes() {
+        if byte.is_ascii_alphanumeric() || b"-._~".contains(&byte) {
+            encoded.push(byte as char);
+        } else {
+            encoded.push_str(&format!("%{:02X}", byte));
+        }
+    }
+    encoded
+}
+
+/// mailto: URL of a draft. The text body is cut where the URL would exceed
+/// `MAILTO_LENGTH_LIMIT`; attachments cannot be passed this way.
+pub fn mailto_url(draft: &Draft) -> String {
+    let list = |kind: &str| {
+        draft
+            .message
+            .recipients
+            .iter()
+            .filter(|recipient| recipient.kind == kind)
+            .map(|recipient| percent_encode(&recipient.email).replace("%40", "@"))
+            .collect::<Vec<_>>()
+            .join(",")
+    };
+    let mut url = format!("mailto:{}", list("to"));
+    let mut separator = '?';
+    let cc = list("cc");
+    if !cc.is_empty() {
+        url.push_str(&format!("{}cc={}", separator, cc));
+        separator = '&';
+    }
+    url.push_str(&format!(
+        "{}subject={}&body=",
+        separator,
+        percent_encode(&draft.message.subject)
+    ));
+
+    let omission = percent_encode("\r\n[...]");
+    for c in draft.message.body_text.chars() {
+        let encoded = percent_encode(c.encode_utf8(&mut [0; 4]));
+        if url.len() + encoded.len() + omission.len() > MAILTO_LENGTH_LIMIT {
+            url.push_str(&omission);
+            break;
+        }
+        url.push_str(&encoded);
+    }
+    url
+}
+
+/// Unsent .eml draft of a reply or forward. X-Unsent: 1 makes Outlook and other clients
+/// open it in the composer instead of as a received message.
+pub fn draft_eml(draft: &Draft) -> Vec<u8> {
+    let mut headers = String::from("X-Unsent: 1\r\n");
+    if !draft.in_reply_to.is_empty() {
+        headers.push_str(&format!("In-Reply-To: {}\r\n", draft.in_reply_to));
+    }
+    if !draft.references.is_empty() {
+        headers.push_str(&format!("References: {}\r\n", draft.references));
+    }
+    let mut eml = headers.into_bytes();
+    eml.extend(eml::write(&draft.message));
+    eml
+}
+
+/// File name of the draft, from the subject
+pub fn draft_file_name(draft: &Draft) -> String {
+    let name: String = draft
+        .message
+        .subject
+        .chars()
+        .map(|c| if c.is_alphanumeric() || " -_.".contains(c) { c } else { '_' })
+        .take(80)
+        .collect();
+    let name = name.trim();
+    format!("{}.eml", if name.is_empty() { "draft" } else { name })
+}
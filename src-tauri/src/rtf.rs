@@ -0,0 +1,477 @@
+use crate::charset;
+
+/// Dictionary the LZFu compressor starts with (MS-OXRTFCP 2.1.3.1.1)
+const PRELOAD: &[u8] = concat!(
+    "{\\rtf1\\ansi\\mac\\deff0\\deftab720{\\fonttbl;}{\\f0\\fnil \\froman \\fswiss ",
+    "\\fmodern \\fscript \\fdecor MS Sans SerifSymbolArialTimes New RomanCourier",
+    "{\\colortbl\\red0\\green0\\blue0\r\n\\par \\pard\\plain\\f0\\fs20\\b\\i\\u\\tab\\tx",
+)
+.as_bytes();
+
+const DICTIONARY_SIZE: usize = 4096;
+const COMPRESSED: &[u8; 4] = b"LZFu";
+const UNCOMPRESSED: &[u8; 4] = b"MELA";
+/// Most bytes one compressed byte can stand for: a 2-byte reference copies up to 17
+const MAX_EXPANSION: usize = 9;
+
+/// Table of the CRC-32 the header stores (MS-OXRTFCP 2.1.3.2)
+const CRC_TABLE: [u32; 256] = crc_table();
+
+const fn crc_table() -> [u32; 256] {
+    let mut table = [0u32; 256];
+    let mut i = 0;
+    while i < 256 {
+        let mut value = i as u32;
+        let mut bit = 0;
+        while bit < 8 {
+            value = if value & 1 == 1 {
+                (value >> 1) ^ 0xEDB8_8320
+            } else {
+                value >> 1
+            };
+            bit += 1;
+        }
+        table[i] = value;
+        i += 1;
+    }
+    table
+}
+
+/// CRC of the compressed data; unlike the usual CRC-32 it starts at 0 and is not inverted
+fn crc(data: &[u8]) -> u32 {
+    data.iter().fold(0, |crc, &byte| {
+        CRC_TABLE[((crc ^ byte as u32) & 0xFF) as usize] ^ (crc >> 8)
+    })
+}
+
+/// Groups whose text is not part of the document
+const DESTINATIONS: &[&str] = &[
+    "colorschememapping",
+    "datastore",
+    "filetbl",
+    "fldinst",
+    "fonttbl",
+    "footer",
+    "footerl",
+    "footerr",
+    "generator",
+    "header",
+    "headerl",
+    "headerr",
+    "info",
+    "latentstyles",
+    "listoverridetable",
+    "listtable",
+    "object",
+    "pict",
+    "revtbl",
+    "rsidtbl",
+    "stylesheet",
+    "themedata",
+    "xmlnstbl",
+];
+
+/// Decompress PR_RTF_COMPRESSED (MS-OXRTFCP): a 16-byte header, then LZ77 with a 4 KB
+/// dictionary preloaded with common RTF. The sizes and the CRC in the header come from
+/// the file, so they are checked against the data rather than trusted.
+pub fn decompress(data: &[u8]) -> Result<Vec<u8>, String> {
+    if data.len() < 16 {
+        return Err("Compressed RTF is truncated".to_string());
+    }
+    // The compressed size counts the header fields after itself
+    let compressed_size = u32::from_le_bytes([data[0], data[1], data[2], data[3]]) as usize;
+    let raw_size = u32::from_le_bytes([data[4], data[5], data[6], data[7]]) as usize;
+    let stored_crc = u32::from_le_bytes([data[12], data[13], data[14], data[15]]);
+    if compressed_size < 12 {
+        return Err("Compressed RTF has an invalid size".to_string());
+    }
+    let end = compressed_size.saturating_add(4);
+    if end > data.len() {
+        return Err("Compressed RTF is truncated".to_string());
+    }
+
+    match &data[8..12] {
+        kind if kind == UNCOMPRESSED => {
+            return Ok(data[16..end].iter().take(raw_size).copied().collect());
+        }
+        kind if kind == COMPRESSED => {}
+        _ => return Err("Unknown RTF compression".to_string()),
+    }
+    if crc(&data[16..end]) != stored_crc {
+        return Err("Compressed RTF is corrupt (CRC mismatch)".to_string());
+    }
+
+    let mut dictionary = [0u8; DICTIONARY_SIZE];
+    dictionary[..PRELOAD.len()].copy_from_slice(PRELOAD);
+    let mut write = PRELOAD.len();
+    let mut output = Vec::with_capacity(raw_size.min((end - 16) * MAX_EXPANSION));
+    let mut position = 16;
+
+    'blocks: while position < end {
+        let control = data[position];
+        position += 1;
+        for bit in 0..8 {
+            if position >= end {
+                break 'blocks;
+            }
+            if control & (1 << bit) == 0 {
+                let byte = data[position];
+                position += 1;
+                output.push(byte);
+                dictionary[write] = byte;
+                write = (write + 1) % DICTIONARY_SIZE;
+                continue;
+            }
+
+            // Reference: 12-bit dictionary offset and 4-bit length - 2, big-endian
+            if position + 1 >= end {
+                break 'blocks;
+            }
+            let reference = u16::from_be_bytes([data[position], data[position + 1]]);
+            position += 2;
+            let offset = (reference >> 4) as usize;
+            if offset == write {
+                break 'blocks;
+            }
+            for i in 0..(reference & 0xF) as usize + 2 {
+                let byte = dictionary[(offset + i) % DICTIONARY_SIZE];
+                output.push(byte);
+                dictionary[write] = byte;
+                write = (write + 1) % DICTIONARY_SIZE;
+            }
+        }
+    }
+    output.truncate(raw_size);
+    Ok(output)
+}
+
+/// State of an RTF group, inherited by the groups inside it
+#[derive(Clone, Copy, Default)]
+struct Group {
+    /// Inside a destination whose text is dropped
+    skip: bool,
+    /// Inside \htmlrtf of encapsulated HTML: RTF-only content, not part of the HTML
+    html_rtf: bool,
+    bold: bool,
+    italic: bool,
+    underline: bool,
+}
+
+struct Converter {
+    html: String,
+    /// Body is HTML encapsulated by Outlook (\fromhtml1, MS-OXRTFEX)
+    from_html: bool,
+    codepage: u32,
+    /// Text and \'hh bytes in the ANSI code page, decoded before the next control word
+    pending: Vec<u8>,
+    /// Fallback characters of the last \uN still to skip, and how many \uN has (\ucN)
+    skip_chars: usize,
+    uc: usize,
+    /// Bold, italic and underline as the currently open tags apply them
+    open: (bool, bool, bool),
+}
+
+impl Converter {
+    /// Text byte or \'hh
+    fn byte(&mut self, group: &Group, byte: u8) {
+        if self.skip_chars > 0 {
+            self.skip_chars -= 1;
+        } else if !group.skip {
+            self.pending.push(byte);
+        }
+    }
+
+    fn flush(&mut self, group: &Group) {
+        if self.pending.is_empty() {
+            return;
+        }
+        let bytes = std::mem::take(&mut self.pending);
+        let text = charset::decode(&bytes, &self.codepage.to_string())
+            .unwrap_or_else(|_| String::from_utf8_lossy(&bytes).into_owned());
+        self.text(group, &text);
+    }
+
+    /// Text of the document: HTML as is when encapsulated, escaped otherwise
+    fn text(&mut self, group: &Group, text: &str) {
+        if group.skip {
+            return;
+        }
+        if self.from_html {
+            if !group.html_rtf {
+                self.html.push_str(text);
+            }
+            return;
+        }
+
+        self.format(group.bold, group.italic, group.underline);
+        for c in text.chars() {
+            match c {
+                '&' => self.html.push_str("&amp;"),
+                '<' => self.html.push_str("&lt;"),
+                '>' => self.html.push_str("&gt;"),
+                '"' => self.html.push_str("&quot;"),
+                _ => self.html.push(c),
+            }
+        }
+    }
+
+    /// A line break: \par and \line
+    fn line_break(&mut self, group: &Group) {
+        if self.from_html {
+            self.text(group, "\r\n");
+        } else if !group.skip {
+            self.html.push_str("<br>\n");
+        }
+    }
+
+    /// Close and reopen <b>, <i> and <u> where the formatting changed
+    fn format(&mut self, bold: bool, italic: bool, underline: bool) {
+        if self.open == (bold, italic, underline) {
+            return;
+        }
+        let (open_bold, open_italic, open_underline) = self.open;
+        for (is_open, tag) in [(open_underline, "u"), (open_italic, "i"), (open_bold, "b")] {
+            if is_open {
+                self.html.push_str(&format!("</{}>", tag));
+            }
+        }
+        for (is_open, tag) in [(bold, "b"), (italic, "i"), (underline, "u")] {
+            if is_open {
+                self.html.push_str(&format!("<{}>", tag));
+            }
+        }
+        self.open = (bold, italic, underline);
+    }
+}
+
+/// Control word at `start` (after the backslash): name, parameter and the end of it,
+/// past the space that delimits it
+fn control_word(rtf: &[u8], start: usize) -> (&str, Option<i32>, usize) {
+    let mut end = start;
+    while end < rtf.len() && rtf[end].is_ascii_alphabetic() {
+        end += 1;
+    }
+    let name = std::str::from_utf8(&rtf[start..end]).unwrap_or_default();
+
+    let number_start = end;
+    if end < rtf.len() && rtf[end] == b'-' {
+        end += 1;
+    }
+    while end < rtf.len() && rtf[end].is_ascii_digit() {
+        end += 1;
+    }
+    let parameter = std::str::from_utf8(&rtf[number_start..end])
+        .ok()
+        .and_then(|number| number.parse().ok());
+
+    if end < rtf.len() && rtf[end] == b' ' {
+        end += 1;
+    }
+    (name, parameter, end)
+}
+
+/// HTML of an RTF body. HTML that Outlook encapsulated in RTF (\fromhtml1) is extracted
+/// as it was; other RTF becomes simple HTML with line breaks, bold, italic and underline.
+pub fn to_html(rtf: &[u8]) -> String {
+    let from_html = rtf.windows(10).any(|window| window == b"\\fromhtml1");
+    let mut converter = Converter {
+        html: String::with_capacity(rtf.len()),
+        from_html,
+        codepage: 1252,
+        pending: Vec::new(),
+        skip_chars: 0,
+        uc: 1,
+        open: (false, false, false),
+    };
+
+    let mut stack: Vec<Group> = Vec::new();
+    let mut group = Group::default();
+    // The next control word opens the group and may name a destination; \* before it
+    // marks a destination readers that do not know it should skip
+    let mut group_start = false;
+    let mut ignorable = false;
+
+    let mut i = 0;
+    while i < rtf.len() {
+        match rtf[i] {
+            b'{' => {
+                converter.flush(&group);
+                stack.push(group);
+                group_start = true;
+                i += 1;
+            }
+            b'}' => {
+                converter.flush(&group);
+                group = stack.pop().unwrap_or_default();
+                group_start = false;
+                i += 1;
+            }
+            b'\r' | b'\n' => i += 1,
+            b'\\' if i + 1 < rtf.len() => {
+                let symbol = rtf[i + 1];
+                if symbol == b'\'' {
+                    let hex = rtf.get(i + 2..i + 4).and_then(|hex| std::str::from_utf8(hex).ok());
+                    if let Some(byte) = hex.and_then(|hex| u8::from_str_radix(hex, 16).ok()) {
+                        converter.byte(&group, byte);
+                    }
+                    i += 4;
+                    continue;
+                }
+
+                converter.flush(&group);
+                if !symbol.is_ascii_alphabetic() {
+                    match symbol {
+                        b'*' => ignorable = true,
+                        b'\\' | b'{' | b'}' => converter.byte(&group, symbol),
+                        b'~' => converter.text(&group, "\u{a0}"),
+                        b'_' => converter.text(&group, "-"),
+                        b'\r' | b'\n' => converter.line_break(&group),
+                        _ => {}
+                    }
+                    i += 2;
+                    continue;
+                }
+
+                let (word, parameter, end) = control_word(rtf, i + 1);
+                i = end;
+                if group_start {
+                    group_start = false;
+                    if ignorable {
+                        // HTML tags of encapsulated HTML are the one destination we read
+                        group.skip |= !(from_html && word == "htmltag");
+                    } else if DESTINATIONS.contains(&word) {
+                        group.skip = true;
+                    }
+                }
+                ignorable = false;
+                control(&mut converter, &mut group, word, parameter);
+            }
+            byte => {
+                group_start = false;
+                converter.byte(&group, byte);
+                i += 1;
+            }
+        }
+    }
+    converter.flush(&group);
+    converter.format(false, false, false);
+    converter.html
+}
+
+/// Apply a control word that is not a destination
+fn control(converter: &mut Converter, group: &mut Group, word: &str, parameter: Option<i32>) {
+    let on = parameter != Some(0);
+    match word {
+        "ansicpg" => {
+            if let Some(codepage) = parameter.and_then(|codepage| u32::try_from(codepage).ok()) {
+                converter.codepage = codepage;
+            }
+        }
+        "htmlrtf" => group.html_rtf = on,
+        "par" | "line" => converter.line_break(group),
+        "tab" => converter.text(group, "\t"),
+        "uc" => converter.uc = parameter.unwrap_or(1).max(0) as usize,
+        "u" => {
+            // Negative for code points over 32767
+            let code = parameter.unwrap_or(0).rem_euclid(65536) as u32;
+            if let Some(c) = char::from_u32(code) {
+                converter.text(group, &c.to_string());
+            }
+            converter.skip_chars = converter.uc;
+        }
+        "emdash" => converter.text(group, "\u{2014}"),
+        "endash" => converter.text(group, "\u{2013}"),
+        "bullet" => converter.text(group, "\u{2022}"),
+        "lquote" => converter.text(group, "\u{2018}"),
+        "rquote" => converter.text(group, "\u{2019}"),
+        "ldblquote" => converter.text(group, "\u{201c}"),
+        "rdblquote" => converter.text(group, "\u{201d}"),
+        "b" => group.bold = on,
+        "i" => group.italic = on,
+        "ul" => group.underline = on,
+        "ulnone" => group.underline = false,
+        "plain" => {
+            group.bold = false;
+            group.italic = false;
+            group.underline = false;
+        }
+        _ => {}
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    /// Example of MS-OXRTFCP 3.1.1: compressed RTF with literals and references
+    const SIMPLE: &[u8] = &[
+        0x2d, 0x00, 0x00, 0x00, 0x2b, 0x00, 0x00, 0x00, 0x4c, 0x5a, 0x46, 0x75, 0xf1, 0xc5, 0xc7,
+        0xa7, 0x03, 0x00, 0x0a, 0x00, 0x72, 0x63, 0x70, 0x67, 0x31, 0x32, 0x35, 0x42, 0x32, 0x0a,
+        0xf3, 0x20, 0x68, 0x65, 0x6c, 0x09, 0x00, 0x20, 0x62, 0x77, 0x05, 0xb0, 0x6c, 0x64, 0x7d,
+        0x0a, 0x80, 0x0f, 0xa0,
+    ];
+
+    /// Example of MS-OXRTFCP 3.1.2: a reference that overlaps the bytes it writes
+    const CROSSING: &[u8] = &[
+        0x1a, 0x00, 0x00, 0x00, 0x1c, 0x00, 0x00, 0x00, 0x4c, 0x5a, 0x46, 0x75, 0xe2, 0xd4, 0x4b,
+        0x51, 0x41, 0x00, 0x04, 0x20, 0x57, 0x58, 0x59, 0x5a, 0x0d, 0x6e, 0x7d, 0x01, 0x0e, 0xb0,
+    ];
+
+    #[test]
+    fn specification_examples_decompress() {
+        assert_eq!(
+            decompress(SIMPLE).unwrap(),
+            b"{\\rtf1\\ansi\\ansicpg1252\\pard hello world}\r\n"
+        );
+        assert_eq!(
+            decompress(CROSSING).unwrap(),
+            b"{\\rtf1 WXYZWXYZWXYZWXYZWXYZ}"
+        );
+    }
+
+    #[test]
+    fn uncompressed_content_is_returned() {
+        let mut data = vec![0x0e, 0, 0, 0, 0x02, 0, 0, 0];
+        data.extend_from_slice(UNCOMPRESSED);
+        data.extend_from_slice(&[0, 0, 0, 0, b'{', b'}']);
+        assert_eq!(decompress(&data).unwrap(), b"{}");
+    }
+
+    #[test]
+    fn truncated_input_is_an_error() {
+        assert!(decompress(&SIMPLE[..15]).is_err());
+        assert!(decompress(&SIMPLE[..SIMPLE.len() - 1]).is_err());
+
+        let mut invalid = SIMPLE.to_vec();
+        invalid[..4].copy_from_slice(&4u32.to_le_bytes());
+        assert!(decompress(&invalid).is_err());
+    }
+
+    #[test]
+    fn corrupt_input_is_an_error() {
+        let mut corrupt = SIMPLE.to_vec();
+        corrupt[20] ^= 0xFF;
+        assert!(decompress(&corrupt).unwrap_err().contains("CRC"));
+
+        let mut unknown = SIMPLE.to_vec();
+        unknown[8..12].copy_from_slice(b"ABCD");
+        assert!(decompress(&unknown).is_err());
+    }
+
+    #[test]
+    fn oversized_sizes_are_not_trusted() {
+        // A raw size of 4 GB must not be allocated up front
+        let mut oversized = SIMPLE.to_vec();
+        oversized[4..8].copy_from_slice(&u32::MAX.to_le_bytes());
+        assert_eq!(decompress(&oversized).unwrap(), decompress(SIMPLE).unwrap());
+
+        let mut huge = SIMPLE.to_vec();
+        huge[..4].copy_from_slice(&u32::MAX.to_le_bytes());
+        assert!(decompress(&huge).is_err());
+
+        // Output beyond the raw size is dropped
+        let mut short = SIMPLE.to_vec();
+        short[4..8].copy_from_slice(&6u32.to_le_bytes());
+        assert_eq!(decompress(&short).unwrap(), b"{\\rtf1");
+    }
+}
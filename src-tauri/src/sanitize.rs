@@ -0,0 +1,426 @@
+use std::borrow::Cow;
+use std::collections::{HashMap, HashSet};
+
+/// Elements kept in message bodies; the viewer's DOMPurify configuration allows the same
+const TAGS: &[&str] = &[
+    "a", "address", "b", "blockquote", "br", "caption", "center", "code", "col", "colgroup",
+    "dd", "del", "div", "dl", "dt", "em", "font", "h1", "h2", "h3", "h4", "h5", "h6", "hr", "i",
+    "img", "ins", "li", "mark", "ol", "p", "pre", "small", "span", "strong", "style", "sub",
+    "sup", "table", "tbody", "td", "tfoot", "th", "thead", "tr", "u", "ul",
+];
+
+/// Elements removed with their content; other elements that are not allowed (forms,
+/// frames, media) are removed but their text is kept
+const CONTENT_TAGS: &[&str] = &[
+    "applet", "iframe", "noembed", "noframes", "object", "script", "select", "textarea",
+    "title",
+];
+
+/// Attributes allowed on every element. There are no event handlers, and no `id` or
+/// `name`, which could shadow the viewer's own elements.
+const ATTRIBUTES: &[&str] = &[
+    "align", "bgcolor", "border", "cellpadding", "cellspacing", "class", "color", "dir",
+    "face", "height", "lang", "size", "style", "title", "valign", "width",
+];
+
+/// Substrings of a CSS declaration, after unescaping, that make it dangerous: script in
+/// old IE and Firefox, and imports of remote stylesheets
+const DANGEROUS_CSS: &[&str] = &[
+    "expression(",
+    "javascript:",
+    "vbscript:",
+    "behavior:",
+    "-moz-binding",
+    "@import",
+];
+
+/// Sanitize the HTML body of a message before it is shown: scripts, event handlers,
+/// forms, frames and embedded objects are removed, CSS that runs script, imports
+/// stylesheets or positions content over the viewer is dropped, and links keep only
+/// http(s), mailto and tel URLs. `cid:` image references are rewritten with
+/// `inline_images` (Content-ID -> URL, e.g. a data: URL) or removed. Remote images stay
+/// for the viewer's remote content setting, which blocks or proxies them; every other
+/// way of loading external resources (stylesheets, frames, `srcset`, `background`) is
+/// removed.
+pub fn sanitize(html: &str, inline_images: HashMap<String, String>) -> String {
+    let html = sanitize_style_elements(html);
+
+    let mut builder = ammonia::Builder::default();
+    builder
+        .tags(TAGS.iter().copied().collect())
+        .clean_content_tags(CONTENT_TAGS.iter().copied().collect())
+        .generic_attributes(ATTRIBUTES.iter().copied().collect())
+        .tag_attributes(HashMap::from([
+            ("a", HashSet::from(["href", "target"])),
+            ("img", HashSet::from(["alt", "src"])),
+            ("td", HashSet::from(["colspan", "rowspan"])),
+            ("th", HashSet::from(["colspan", "rowspan"])),
+        ]))
+        // Checked in detail by the attribute filter
+        .url_schemes(HashSet::from(["cid", "data", "http", "https", "mailto", "tel"]))
+        .url_relative(ammonia::UrlRelative::Deny)
+        .link_rel(Some("noopener noreferrer"))
+        .attribute_filter(move |element, attribute, value| match (element, attribute) {
+            ("img", "src") => image_source(value, &inline_images),
+            ("a", "href") => link_target(value),
+            (_, "style") => Some(Cow::Owned(sanitize_declarations(value))),
+            _ => Some(Cow::Borrowed(value)),
+        });
+    builder.clean(&html).to_string()
+}
+
+/// src of an image: remote and data:image/ URLs as they are, `cid:` rewritten
+fn image_source<'u>(
+    value: &'u str,
+    inline_images: &HashMap<String, String>,
+) -> Option<Cow<'u, str>> {
+    let url = value.trim();
+    let lower = url.to_ascii_lowercase();
+    if let Some(content_id) = lower.strip_prefix("cid:") {
+        let content_id = content_id.trim_start_matches('<').trim_end_matches('>');
+        return inline_images
+            .iter()
+            .find(|(id, _)| id.trim_matches(['<', '>']).eq_ignore_ascii_case(content_id))
+            .map(|(_, url)| Cow::Owned(url.clone()));
+    }
+    let allowed = ["http://", "https://", "//", "data:image/"]
+        .iter()
+        .any(|prefix| lower.starts_with(prefix));
+    allowed.then_some(Cow::Borrowed(value))
+}
+
+/// href of a link: no data: or script URLs
+fn link_target(value: &str) -> Option<Cow<'_, str>> {
+    let lower = value.trim().to_ascii_lowercase();
+    let allowed = ["http://", "https://", "mailto:", "tel:"]
+        .iter()
+        .any(|prefix| lower.starts_with(prefix));
+    allowed.then_some(Cow::Borrowed(value))
+}
+
+/// CSS with escapes (`\65 xpression`) resolved, comments removed and lowercased, so
+/// the checks see what the browser would
+fn normalize_css(css: &str) -> String {
+    let mut normalized = String::with_capacity(css.len());
+    let mut chars = css.chars().peekable();
+    while let Some(c) = chars.next() {
+        if c == '\\' {
+            let mut hex = String::new();
+            while hex.len() < 6 && chars.peek().is_some_and(|c| c.is_ascii_hexdigit()) {
+                hex.extend(chars.next());
+            }
+            if hex.is_empty() {
+                normalized.extend(chars.next());
+            } else {
+                if chars.peek().is_some_and(|c| c.is_whitespace()) {
+                    chars.next();
+                }
+                let code = u32::from_str_radix(&hex, 16).unwrap_or(0xFFFD);
+                normalized.push(char::from_u32(code).unwrap_or('\u{FFFD}'));
+            }
+        } else {
+            normalized.push(c);
+        }
+    }
+    strip_comments(&normalized)
+        .chars()
+        .filter(|c| !c.is_whitespace())
+        .collect::<String>()
+        .to_lowercase()
+}
+
+fn strip_comments(css: &str) -> String {
+    let mut result = String::with_capacity(css.len());
+    let mut rest = css;
+    while let Some(start) = rest.find("/*") {
+        result.push_str(&rest[..start]);
+        match rest[start + 2..].find("*/") {
+            Some(end) => rest = &rest[start + 2 + end + 2..],
+            None => return result,
+        }
+    }
+    result.push_str(rest);
+    result
+}
+
+fn is_safe_declaration(declaration: &str) -> bool {
+    let normalized = normalize_css(declaration);
+    if DANGEROUS_CSS.iter().any(|pattern| normalized.contains(pattern)) {
+        return false;
+    }
+    // Fixed or absolute content could be placed over the viewer's own controls
+    if normalized.starts_with("position:")
+        && (normalized.contains("fixed") || normalized.contains("absolute"))
+    {
+        return false;
+    }
+    // Images from the web or embedded; no other schemes, no cid: in CSS
+    normalized.split("url(").skip(1).all(|url| {
+        let url = url.trim_start_matches(['"', '\'']);
+        ["http://", "https://", "//", "data:image/"]
+            .iter()
+            .any(|prefix| url.starts_with(prefix))
+    })
+}
+
+/// Declarations split at `;` outside parentheses and strings (data: URLs contain `;`)
+fn split_declarations(css: &str) -> Vec<&str> {
+    let mut declarations = Vec::new();
+    let mut depth = 0usize;
+    let mut quote = None;
+    let mut start = 0;
+    for (i, c) in css.char_indices() {
+        match (c, quote) {
+            (_, Some(open)) if c == open => quote = None,
+            (_, Some(_)) => {}
+            ('"' | '\'', None) => quote = Some(c),
+            ('(', None) => depth += 1,
+            (')', None) => depth = depth.saturating_sub(1),
+            (';', None) if depth == 0 => {
+                declarations.push(&css[start..i]);
+                start = i + 1;
+            }
+            _ => {}
+        }
+    }
+    declarations.push(&css[start..]);
+    declarations
+}
+
+/// The safe declarations of a style attribute or rule
+fn sanitize_declarations(declarations: &str) -> String {
+    split_declarations(declarations)
+        .into_iter()
+        .filter(|declaration| !declaration.trim().is_empty() && is_safe_declaration(declaration))
+        .map(str::trim)
+        .collect::<Vec<_>>()
+        .join("; ")
+}
+
+/// Statements outside rules (`@import`, `@charset`), keeping the selector that follows
+fn remove_statements(css: &str) -> String {
+    css.split_inclusive(';')
+        .filter(|statement| {
+            let normalized = normalize_css(statement);
+            !(normalized.starts_with("@import") || normalized.starts_with("@charset"))
+        })
+        .collect()
+}
+
+/// A style sheet with `@import` removed and every rule's declarations sanitized. Text
+/// inside a block is sanitized as declarations wherever it is, as Chromium and WebView2
+/// support nested rules: before a nested rule (`a{position:fixed; b{}}`), only the
+/// selector after the last `;` is kept as it is, and after one (`a{b{} top:0}`) all of
+/// it is sanitized. In `@media` blocks this leaves the selectors of the rules.
+fn sanitize_stylesheet(css: &str) -> String {
+    let css = strip_comments(css);
+    let mut result = String::with_capacity(css.len());
+    let mut depth = 0usize;
+    let mut rest = css.as_str();
+    while let Some(brace) = rest.find(['{', '}']) {
+        let text = &rest[..brace];
+        let opens = rest[brace..].starts_with('{');
+        if depth == 0 {
+            result.push_str(&remove_statements(text));
+        } else if opens {
+            let mut declarations = split_declarations(text);
+            let selector = declarations.pop().unwrap_or_default();
+            let declarations = sanitize_declarations(&declarations.join(";"));
+            if !declarations.is_empty() {
+                result.push_str(&declarations);
+                result.push_str("; ");
+            }
+            result.push_str(&remove_statements(selector));
+        } else {
+            result.push_str(&sanitize_declarations(text));
+        }
+
+        if opens {
+            result.push('{');
+            depth += 1;
+        } else {
+            result.push('}');
+            depth = depth.saturating_sub(1);
+        }
+        rest = &rest[brace + 1..];
+    }
+    if depth == 0 {
+        result.push_str(&remove_statements(rest));
+    } else {
+        result.push_str(&sanitize_declarations(rest));
+    }
+    result
+}
+
+/// Position of the next `tag` (`<style` or `</style`) in `lower` from `from`, where it
+/// is the whole tag name and not the start of a longer one like `<styles`
+fn find_tag(lower: &str, from: usize, tag: &str) -> Option<usize> {
+    let mut start = from;
+    while let Some(found) = lower[start..].find(tag) {
+        let end = start + found + tag.len();
+        let next = lower[end..].chars().next();
+        if next.map_or(true, |c| c.is_ascii_whitespace() || c == '/' || c == '>') {
+            return Some(start + found);
+        }
+        start = end;
+    }
+    None
+}
+
+/// Sanitize the content of the `<style>` elements, which ammonia keeps as it is
+fn sanitize_style_elements(html: &str) -> String {
+    let lower = html.to_ascii_lowercase();
+    let mut result = String::with_capacity(html.len());
+    let mut position = 0;
+    while let Some(found) = find_tag(&lower, position, "<style") {
+        let Some(open_end) = lower[found..].find('>') else {
+            break;
+        };
+        let content_start = found + open_end + 1;
+        // The content ends at the first end tag; `</styles>` does not end it
+        let content_end = find_tag(&lower, content_start, "</style").unwrap_or(html.len());
+        result.push_str(&html[position..content_start]);
+        result.push_str(&sanitize_stylesheet(&html[content_start..content_end]));
+        position = content_end;
+    }
+    result.push_str(&html[position..]);
+    result
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    fn clean(html: &str) -> String {
+        sanitize(html, HashMap::new())
+    }
+
+    #[test]
+    fn escaped_css_is_checked_as_the_browser_reads_it() {
+        assert_eq!(
+            normalize_css(r"Width: \65 xpression(alert(1))"),
+            "width:expression(alert(1))"
+        );
+        assert_eq!(
+            normalize_css("beh/* x */avior: url(a.htc)"),
+            "behavior:url(a.htc)"
+        );
+
+        let html = clean(r#"<p style="width: \65 xpression(alert(1)); color: red">x</p>"#);
+        assert_eq!(html, r#"<p style="color: red">x</p>"#);
+        let html = clean(r#"<p style="background: url(j\61vascript:alert(1))">x</p>"#);
+        assert!(!html.contains("alert"));
+    }
+
+    #[test]
+    fn links_keep_only_web_mail_and_phone_urls() {
+        assert_eq!(link_target(" JavaScript:alert(1)"), None);
+        assert_eq!(link_target("data:text/html,x"), None);
+        assert!(link_target("mailto:bob@example.com").is_some());
+        assert!(link_target("tel:+1555").is_some());
+
+        let html =
+            clean(r#"<a href="javascript:alert(1)">a</a><a href="https://example.com/">b</a>"#);
+        assert!(!html.contains("javascript"));
+        assert!(html.contains(r#"href="https://example.com/""#));
+        assert!(html.contains(r#"rel="noopener noreferrer""#));
+    }
+
+    #[test]
+    fn cid_images_are_rewritten_or_removed() {
+        let inline = HashMap::from([(
+            "<Logo@Example>".to_string(),
+            "data:image/png;base64,AA".to_string(),
+        )]);
+        assert_eq!(
+            image_source("cid:logo@example", &inline).as_deref(),
+            Some("data:image/png;base64,AA")
+        );
+        assert_eq!(
+            image_source("cid:<logo@example>", &inline).as_deref(),
+            Some("data:image/png;base64,AA")
+        );
+        assert_eq!(image_source("cid:other@example", &inline), None);
+        assert_eq!(image_source("file:///etc/passwd", &inline), None);
+        assert!(image_source("https://example.com/a.png", &inline).is_some());
+
+        let html = sanitize(
+            r#"<img src="cid:logo@example"><img src="cid:gone">"#,
+            inline,
+        );
+        assert_eq!(html, r#"<img src="data:image/png;base64,AA"><img>"#);
+    }
+
+    #[test]
+    fn css_urls_allow_only_web_and_image_data() {
+        assert!(is_safe_declaration(
+            "background: url('https://example.com/a.png')"
+        ));
+        assert!(is_safe_declaration(
+            "background: url(data:image/png;base64,AA)"
+        ));
+        assert!(!is_safe_declaration("background: url(cid:logo)"));
+        assert!(!is_safe_declaration("background: url(file:///c:/a.png)"));
+        assert!(!is_safe_declaration(
+            "background: url(\"javascript:alert(1)\")"
+        ));
+        assert!(!is_safe_declaration("position: fixed"));
+        assert!(!is_safe_declaration(
+            "-moz-binding: url(https://example.com/x.xml)"
+        ));
+
+        // The ; of a data: URL does not split the declaration
+        let style = "color: red; background: url(data:image/png;base64,AA); position: absolute";
+        assert_eq!(
+            sanitize_declarations(style),
+            "color: red; background: url(data:image/png;base64,AA)"
+        );
+    }
+
+    #[test]
+    fn imports_are_removed_from_style_sheets() {
+        let css = "@import url(https://example.com/x.css); @charset \"utf-8\"; p{color:red}";
+        assert_eq!(sanitize_stylesheet(css), " p{color:red}");
+        assert_eq!(sanitize_stylesheet("@\\69mport 'x.css';p{}"), "p{}");
+        assert_eq!(
+            sanitize_stylesheet("/* @import */p{top:0;position:fixed}"),
+            "p{top:0}"
+        );
+    }
+
+    #[test]
+    fn nested_rules_are_sanitized() {
+        assert_eq!(
+            sanitize_stylesheet("a{position:fixed;top:0 b{}}"),
+            "a{top:0 b{}}"
+        );
+        assert_eq!(
+            sanitize_stylesheet("a{color:red;position:fixed;b{top:0}}"),
+            "a{color:red; b{top:0}}"
+        );
+        assert_eq!(sanitize_stylesheet("a{b{} position:fixed}"), "a{b{}}");
+        assert_eq!(
+            sanitize_stylesheet("@media print{a{color:red;position:absolute}}"),
+            "@media print{a{color:red}}"
+        );
+        assert_eq!(sanitize_stylesheet("a{position:fixed"), "a{");
+    }
+
+    #[test]
+    fn only_style_elements_are_style_sheets() {
+        // Not a style element, left to ammonia
+        let html = "<styles>p{position:fixed}</styles>";
+        assert_eq!(sanitize_style_elements(html), html);
+        // </styles> does not end a style element
+        let html = "<style>a{}</styles>b{position:fixed}</style>";
+        assert_eq!(
+            sanitize_style_elements(html),
+            "<style>a{}</styles>b{}</style>"
+        );
+        let html = "<STYLE type=\"text/css\">p{position:fixed;color:red}</STYLE >";
+        assert_eq!(
+            sanitize_style_elements(html),
+            "<STYLE type=\"text/css\">p{color:red}</STYLE >"
+        );
+    }
+}
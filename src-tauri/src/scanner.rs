@@ -0,0 +1,351 @@
+use md5::Md5;
+use sha2::{Digest, Sha256};
+use std::collections::HashMap;
+use std::io::{Read, Write};
+use std::path::Path;
+use std::sync::Mutex;
+use std::time::Duration;
+
+/// Name of the scanner configuration file in the app's config directory
+pub const CONFIG_FILE: &str = "scanner.json";
+
+/// Where clamd listens unless scanner.json names another socket
+#[cfg(unix)]
+const DEFAULT_CLAMD_ADDRESS: &str = "/var/run/clamav/clamd.ctl";
+#[cfg(not(unix))]
+const DEFAULT_CLAMD_ADDRESS: &str = "127.0.0.1:3310";
+
+/// Scanning large archives takes a while, but a hung clamd must not block forever
+const CLAMD_TIMEOUT: Duration = Duration::from_secs(120);
+
+/// Size of the chunks of clamd's INSTREAM command
+const CHUNK_SIZE: usize = 64 * 1024;
+
+/// Verdicts are small, but a session with many large messages should not grow forever
+const MAX_CACHE_ENTRIES: usize = 2000;
+
+#[derive(serde::Deserialize, Clone, Copy, PartialEq, Eq)]
+#[serde(rename_all = "lowercase")]
+pub enum Engine {
+    /// ClamAV daemon, reached through its Unix socket or TCP port
+    Clamd,
+    /// Windows Antimalware Scan Interface, i.e. Defender or the installed antivirus
+    Amsi,
+}
+
+impl Engine {
+    fn name(self) -> &'static str {
+        match self {
+            Engine::Clamd => "clamd",
+            Engine::Amsi => "amsi",
+        }
+    }
+}
+
+/// Scanner settings (`<config dir>/scanner.json`)
+#[derive(serde::Deserialize)]
+#[serde(rename_all = "camelCase")]
+pub struct ScannerConfig {
+    pub engine: Engine,
+    /// clamd socket: a Unix socket path or `host:port`
+    #[serde(default)]
+    pub address: Option<String>,
+    /// Also refuse attachments the scanner could not check, e.g. because clamd is down
+    #[serde(default)]
+    pub block_unscanned: bool,
+    #[serde(default = "default_enabled")]
+    pub enabled: bool,
+}
+
+fn default_enabled() -> bool {
+    true
+}
+
+/// Load the scanner configuration. Returns None if no scanner is configured.
+pub fn load(config_path: &Path) -> Result<Option<ScannerConfig>, String> {
+    match std::fs::read_to_string(config_path) {
+        Ok(content) => {
+            let config: ScannerConfig = serde_json::from_str(&content)
+                .map_err(|e| format!("Invalid {}: {}", CONFIG_FILE, e))?;
+            if config.engine == Engine::Amsi && !cfg!(windows) {
+                return Err("AMSI scanning is only available on Windows".to_string());
+            }
+            Ok(Some(config).filter(|c| c.enabled))
+        }
+        Err(e) if e.kind() == std::io::ErrorKind::NotFound => Ok(None),
+        Err(e) => Err(format!("Failed to read {}: {}", CONFIG_FILE, e)),
+    }
+}
+
+/// Result of scanning one attachment
+#[derive(serde::Serialize, Clone)]
+#[serde(rename_all = "camelCase")]
+pub struct ScanVerdict {
+    /// `clean`, `infected`, `failed` (the scanner could not check it) or `unscanned`
+    /// (no scanner configured)
+    pub status: &'static str,
+    /// `clamd` or `amsi`, None without a scanner
+    pub engine: Option<&'static str>,
+    /// Name of the detected threat
+    pub threat: Option<String>,
+    pub error: Option<String>,
+    /// Opening, saving and dragging the attachment out of the app is refused
+    pub blocked: bool,
+}
+
+/// Digests and scan verdict of an attachment
+#[derive(serde::Serialize, Clone)]
+#[serde(rename_all = "camelCase")]
+pub struct AttachmentCheck {
+    pub sha256: String,
+    pub md5: String,
+    pub size: usize,
+    pub scan: ScanVerdict,
+}
+
+fn hex(digest: &[u8]) -> String {
+    digest.iter().map(|byte| format!("{:02x}", byte)).collect()
+}
+
+/// Lowercase hex SHA-256 and MD5 of the content
+pub fn digests(bytes: &[u8]) -> (String, String) {
+    (hex(&Sha256::digest(bytes)), hex(&Md5::digest(bytes)))
+}
+
+/// Send the content with clamd's INSTREAM command and return its answer, e.g.
+/// `stream: OK` or `stream: Win.Test.EICAR_HDB-1 FOUND`
+fn instream<S: Read + Write>(mut stream: S, bytes: &[u8]) -> std::io::Result<String> {
+    // `z` commands end with a NUL byte, and so do their answers
+    stream.write_all(b"zINSTREAM\0")?;
+    for chunk in bytes.chunks(CHUNK_SIZE) {
+        stream.write_all(&(chunk.len() as u32).to_be_bytes())?;
+        stream.write_all(chunk)?;
+    }
+    stream.write_all(&0u32.to_be_bytes())?;
+    stream.flush()?;
+
+    let mut answer = Vec::new();
+    stream.read_to_end(&mut answer)?;
+    Ok(String::from_utf8_lossy(&answer).trim_end_matches(['\0', '\n']).to_string())
+}
+
+/// The threat clamd found, None if the content is clean
+fn parse_clamd_answer(answer: &str) -> Result<Option<String>, String> {
+    let result = answer.strip_prefix("stream:").unwrap_or(answer).trim();
+    if result == "OK" {
+        Ok(None)
+    } else if let Some(threat) = result.strip_suffix(" FOUND") {
+        Ok(Some(threat.trim().to_string()))
+    } else {
+        // e.g. `INSTREAM size limit exceeded. ERROR` for files above StreamMaxLength
+        Err(format!("clamd: {}", result.trim_end_matches(" ERROR")))
+    }
+}
+
+fn scan_clamd(address: &str, bytes: &[u8]) -> Result<Option<String>, String> {
+    let failed = |e: std::io::Error| format!("Failed to scan with clamd at {}: {}", address, e);
+
+    #[cfg(unix)]
+    if address.starts_with('/') {
+        let stream = std::os::unix::net::UnixStream::connect(address).map_err(failed)?;
+        stream.set_read_timeout(Some(CLAMD_TIMEOUT)).map_err(failed)?;
+        stream.set_write_timeout(Some(CLAMD_TIMEOUT)).map_err(failed)?;
+        return parse_clamd_answer(&instream(stream, bytes).map_err(failed)?);
+    }
+
+    let stream = std::net::TcpStream::connect(address).map_err(failed)?;
+    stream.set_read_timeout(Some(CLAMD_TIMEOUT)).map_err(failed)?;
+    stream.set_write_timeout(Some(CLAMD_TIMEOUT)).map_err(failed)?;
+    parse_clamd_answer(&instream(stream, bytes).map_err(failed)?)
+}
+
+/// Scan with AMSI, which hands the content to the antivirus registered with Windows.
+/// AMSI does not name threats.
+#[cfg(windows)]
+fn scan_amsi(bytes: &[u8], file_name: &str) -> Result<Option<String>, String> {
+    use std::ffi::c_void;
+    use std::ptr::null_mut;
+
+    #[link(name = "amsi")]
+    extern "system" {
+        fn AmsiInitialize(app_name: *const u16, context: *mut *mut c_void) -> i32;
+        fn AmsiUninitialize(context: *mut c_void);
+        fn AmsiOpenSession(context: *mut c_void, session: *mut *mut c_void) -> i32;
+        fn AmsiCloseSession(context: *mut c_void, session: *mut c_void);
+        fn AmsiScanBuffer(
+            context: *mut c_void,
+            buffer: *const c_void,
+            length: u32,
+            content_name: *const u16,
+            session: *mut c_void,
+            result: *mut i32,
+        ) -> i32;
+    }
+    // AMSI_RESULT values from amsi.h
+    const AMSI_RESULT_BLOCKED_BY_ADMIN_START: i32 = 0x4000;
+    const AMSI_RESULT_BLOCKED_BY_ADMIN_END: i32 = 0x4fff;
+    const AMSI_RESULT_DETECTED: i32 = 32768;
+
+    let wide = |text: &str| text.encode_utf16().chain([0]).collect::<Vec<u16>>();
+    let length = u32::try_from(bytes.len())
+        .map_err(|_| "Attachment is too large for AMSI".to_string())?;
+    let app_name = wide("msgReader");
+    let content_name = wide(file_name);
+
+    let mut context = null_mut();
+    let hr = unsafe { AmsiInitialize(app_name.as_ptr(), &mut context) };
+    if hr < 0 {
+        return Err(format!("Failed to initialize AMSI (0x{:08x})", hr));
+    }
+    let mut session = null_mut();
+    let mut result = 0;
+    let hr = unsafe {
+        let hr = AmsiOpenSession(context, &mut session);
+        let hr = if hr < 0 {
+            hr
+        } else {
+            let hr = AmsiScanBuffer(
+                context,
+                bytes.as_ptr().cast(),
+                length,
+                content_name.as_ptr(),
+                session,
+                &mut result,
+            );
+            AmsiCloseSession(context, session);
+            hr
+        };
+        AmsiUninitialize(context);
+        hr
+    };
+    if hr < 0 {
+        return Err(format!("AMSI scan failed (0x{:08x})", hr));
+    }
+
+    let blocked_by_admin =
+        (AMSI_RESULT_BLOCKED_BY_ADMIN_START..=AMSI_RESULT_BLOCKED_BY_ADMIN_END).contains(&result);
+    if result >= AMSI_RESULT_DETECTED || blocked_by_admin {
+        Ok(Some("Malware detected by Windows antimalware".to_string()))
+    } else {
+        Ok(None)
+    }
+}
+
+#[cfg(not(windows))]
+fn scan_amsi(_bytes: &[u8], _file_name: &str) -> Result<Option<String>, String> {
+    Err("AMSI scanning is only available on Windows".to_string())
+}
+
+fn scan(config: &ScannerConfig, bytes: &[u8], file_name: &str) -> ScanVerdict {
+    let result = match config.engine {
+        Engine::Clamd => {
+            scan_clamd(config.address.as_deref().unwrap_or(DEFAULT_CLAMD_ADDRESS), bytes)
+        }
+        Engine::Amsi => scan_amsi(bytes, file_name),
+    };
+    let engine = Some(config.engine.name());
+    match result {
+        Ok(None) => ScanVerdict {
+            status: "clean",
+            engine,
+            threat: None,
+            error: None,
+            blocked: false,
+        },
+        Ok(Some(threat)) => ScanVerdict {
+            status: "infected",
+            engine,
+            threat: Some(threat),
+            error: None,
+            blocked: true,
+        },
+        Err(e) => {
+            log_warn!("{}", e);
+            ScanVerdict {
+                status: "failed",
+                engine,
+                threat: None,
+                error: Some(e),
+                blocked: config.block_unscanned,
+            }
+        }
+    }
+}
+
+/// Scans attachments with the configured scanner before they leave the app. Verdicts are
+/// cached by content and engine for the session, so an attachment checked when its
+/// message was shown is not sent to the scanner again when it is opened or saved.
+pub struct AttachmentScanner {
+    verdicts: Mutex<HashMap<(String, &'static str), ScanVerdict>>,
+}
+
+impl AttachmentScanner {
+    pub fn new() -> Self {
+        AttachmentScanner {
+            verdicts: Mutex::new(HashMap::new()),
+        }
+    }
+
+    /// Digests of an attachment and the scanner's verdict, `unscanned` without a scanner
+    pub fn check(
+        &self,
+        config: Option<&ScannerConfig>,
+        bytes: &[u8],
+        file_name: &str,
+    ) -> AttachmentCheck {
+        let (sha256, md5) = digests(bytes);
+        let scan = match config {
+            Some(config) => {
+                let key = (sha256.clone(), config.engine.name());
+                let cached = self.verdicts.lock().unwrap().get(&key).cloned();
+                cached.unwrap_or_else(|| {
+                    let verdict = scan(config, bytes, file_name);
+                    // Failures are not cached: the scanner may be back for the next try
+                    if verdict.status != "failed" {
+                        let mut verdicts = self.verdicts.lock().unwrap();
+                        if verdicts.len() >= MAX_CACHE_ENTRIES {
+                            verdicts.clear();
+                        }
+                        verdicts.insert(key, verdict.clone());
+                    }
+                    verdict
+                })
+            }
+            None => ScanVerdict {
+                status: "unscanned",
+                engine: None,
+                threat: None,
+                error: None,
+                blocked: false,
+            },
+        };
+        AttachmentCheck {
+            sha256,
+            md5,
+            size: bytes.len(),
+            scan,
+        }
+    }
+
+    /// Err with the reason if the file must not be opened or saved
+    pub fn ensure_allowed(
+        &self,
+        config: Option<&ScannerConfig>,
+        bytes: &[u8],
+        file_name: &str,
+    ) -> Result<(), String> {
+        let check = self.check(config, bytes, file_name);
+        if !check.scan.blocked {
+            return Ok(());
+        }
+        let reason = match (&check.scan.threat, &check.scan.error) {
+            (Some(threat), _) => format!("contains {}", threat),
+            (None, error) => format!(
+                "could not be scanned: {}",
+                error.as_deref().unwrap_or("unknown error")
+            ),
+        };
+        log_warn!("Refused {} (SHA-256 {}): {}", file_name, check.sha256, reason);
+        Err(format!("Blocked by the antivirus scanner: {} {}", file_name, reason))
+    }
+}
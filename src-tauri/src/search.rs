@@ -0,0 +1,202 @@
+use crate::message::html_to_text;
+use regex::{Regex, RegexBuilder};
+
+/// Matches returned per message; the rest are only counted
+const MAX_MATCHES: usize = 100;
+/// Characters of text shown before and after a match
+const CONTEXT_CHARS: usize = 40;
+/// Upper bound for the compiled pattern, so a pattern like `(a{1000}){1000}` fails
+/// instead of taking all memory
+const PATTERN_SIZE_LIMIT: usize = 10 * 1024 * 1024;
+
+/// An opened message as the frontend describes it for searching
+#[derive(serde::Deserialize)]
+#[serde(rename_all = "camelCase")]
+pub struct SearchInput {
+    /// Chosen by the frontend to find the message again, returned as is
+    pub key: String,
+    #[serde(default)]
+    pub subject: String,
+    /// Raw transport headers, empty if the message has none
+    #[serde(default)]
+    pub headers: String,
+    #[serde(default)]
+    pub body_text: String,
+    /// Only searched when the message has no text body
+    #[serde(default)]
+    pub body_html: String,
+    /// File names of the attachments
+    #[serde(default)]
+    pub attachments: Vec<String>,
+}
+
+#[derive(serde::Deserialize)]
+#[serde(rename_all = "camelCase")]
+pub struct SearchOptions {
+    /// The pattern is a regular expression (Rust regex syntax) instead of plain text
+    #[serde(default)]
+    pub regex: bool,
+    #[serde(default)]
+    pub case_sensitive: bool,
+    /// Only match whole words
+    #[serde(default)]
+    pub whole_word: bool,
+}
+
+/// A match in one field of a message. `start` and `end` count UTF-16 code units, as
+/// JavaScript strings do, so the frontend can highlight the match with `slice`.
+#[derive(serde::Serialize)]
+#[serde(rename_all = "camelCase")]
+pub struct SearchMatch {
+    /// "subject", "headers", "body" or "attachment"
+    pub field: &'static str,
+    /// Index of the attachment for "attachment" matches
+    pub attachment: Option<usize>,
+    pub start: usize,
+    pub end: usize,
+    /// Up to 40 characters of text before the match, on the same line
+    pub before: String,
+    pub text: String,
+    /// Up to 40 characters of text after the match, on the same line
+    pub after: String,
+}
+
+/// A message with at least one match
+#[derive(serde::Serialize)]
+#[serde(rename_all = "camelCase")]
+pub struct SearchHit {
+    pub key: String,
+    /// Number of matches, also those left out of `matches`
+    pub match_count: usize,
+    /// The first matches in the order of the fields and the text
+    pub matches: Vec<SearchMatch>,
+}
+
+/// Compile the pattern with the options; plain text is escaped
+fn compile(pattern: &str, options: &SearchOptions) -> Result<Regex, String> {
+    let pattern = if options.regex {
+        pattern.to_string()
+    } else {
+        regex::escape(pattern)
+    };
+    let pattern = if options.whole_word {
+        format!(r"\b(?:{})\b", pattern)
+    } else {
+        pattern
+    };
+    RegexBuilder::new(&pattern)
+        .case_insensitive(!options.case_sensitive)
+        .size_limit(PATTERN_SIZE_LIMIT)
+        .build()
+        .map_err(|e| format!("Invalid search pattern: {}", e))
+}
+
+/// The end of `text` from `from`, cut to `CONTEXT_CHARS` characters and the line
+fn context_after(text: &str, from: usize) -> String {
+    text[from..]
+        .split(['\r', '\n'])
+        .next()
+        .unwrap_or_default()
+        .chars()
+        .take(CONTEXT_CHARS)
+        .collect()
+}
+
+/// The start of `text` up to `to`, cut to `CONTEXT_CHARS` characters and the line
+fn context_before(text: &str, to: usize) -> String {
+    let line = text[..to].rsplit(['\r', '\n']).next().unwrap_or_default();
+    let skip = line.chars().count().saturating_sub(CONTEXT_CHARS);
+    line.chars().skip(skip).collect()
+}
+
+/// Add the matches of `regex` in `text` to `hit`. Empty matches (e.g. of `a*`) are not
+/// counted.
+fn search_field(
+    regex: &Regex,
+    text: &str,
+    field: &'static str,
+    attachment: Option<usize>,
+    hit: &mut SearchHit,
+) {
+    // Byte offset and UTF-16 offset of the end of the previous match
+    let mut position = (0, 0);
+    for found in regex.find_iter(text).filter(|found| !found.is_empty()) {
+        hit.match_count += 1;
+        if hit.matches.len() >= MAX_MATCHES {
+            continue;
+        }
+        let start = position.1 + text[position.0..found.start()].encode_utf16().count();
+        let end = start + found.as_str().encode_utf16().count();
+        position = (found.end(), end);
+        hit.matches.push(SearchMatch {
+            field,
+            attachment,
+            start,
+            end,
+            before: context_before(text, found.start()),
+            text: found.as_str().to_string(),
+            after: context_after(text, found.end()),
+        });
+    }
+}
+
+/// The matches in one message, None if there are none
+fn search_message(regex: &Regex, message: &SearchInput) -> Option<SearchHit> {
+    let mut hit = SearchHit {
+        key: message.key.clone(),
+        match_count: 0,
+        matches: Vec::new(),
+    };
+    search_field(regex, &message.subject, "subject", None, &mut hit);
+    search_field(regex, &message.headers, "headers", None, &mut hit);
+    if message.body_text.trim().is_empty() {
+        search_field(regex, &html_to_text(&message.body_html), "body", None, &mut hit);
+    } else {
+        search_field(regex, &message.body_text, "body", None, &mut hit);
+    }
+    for (index, name) in message.attachments.iter().enumerate() {
+        search_field(regex, name, "attachment", Some(index), &mut hit);
+    }
+    (hit.match_count > 0).then_some(hit)
+}
+
+/// Search the subject, headers, body and attachment names of the messages for `pattern`.
+/// The messages are split among threads; hits are returned in the order the messages
+/// were given. The body of a message without a text body is its HTML as text, so
+/// matches in it are positions in that text, not in the HTML.
+pub fn search(
+    messages: &[SearchInput],
+    pattern: &str,
+    options: &SearchOptions,
+) -> Result<Vec<SearchHit>, String> {
+    if pattern.is_empty() {
+        return Ok(Vec::new());
+    }
+    let regex = compile(pattern, options)?;
+    let workers = std::thread::available_parallelism()
+        .map(|count| count.get())
+        .unwrap_or(1)
+        .min(messages.len())
+        .max(1);
+    let chunk_size = messages.len().div_ceil(workers).max(1);
+
+    let hits = std::thread::scope(|scope| {
+        let handles: Vec<_> = messages
+            .chunks(chunk_size)
+            .map(|chunk| {
+                let regex = &regex;
+                scope.spawn(move || {
+                    chunk
+                        .iter()
+                        .filter_map(|message| search_message(regex, message))
+                        .collect::<Vec<_>>()
+                })
+            })
+            .collect();
+        handles
+            .into_iter()
+            .map(|handle| handle.join().unwrap_or_default())
+            .collect::<Vec<_>>()
+    });
+    Ok(hits.into_iter().flatten().collect())
+}
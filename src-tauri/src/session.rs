@@ -0,0 +1,150 @@
+use std::path::{Path, PathBuf};
+use std::sync::Mutex;
+
+/// Name of the saved session in the app's config directory
+const FILE_NAME: &str = "session.json";
+
+/// An open message, by where it was opened from
+#[derive(serde::Serialize, serde::Deserialize, Clone)]
+#[serde(rename_all = "camelCase")]
+pub struct SessionMessage {
+    /// "file", "pst", "mbox", "zip" or "archive"
+    pub kind: String,
+    /// The .msg/.eml file or the data file the message is in; empty for archived messages
+    #[serde(default)]
+    pub path: String,
+    /// Id of the message in a PST file or the archive, index in an mbox or ZIP file
+    #[serde(default)]
+    pub id: Option<i64>,
+    pub file_name: String,
+}
+
+/// The open messages of the main window and the one shown
+#[derive(serde::Serialize, serde::Deserialize, Clone, Default)]
+#[serde(rename_all = "camelCase")]
+pub struct Session {
+    pub messages: Vec<SessionMessage>,
+    /// Index in `messages` of the message shown
+    #[serde(default)]
+    pub selected: Option<usize>,
+}
+
+impl Session {
+    /// Keep the messages for which `keep` is true, and the shown one if it is kept
+    pub fn retain(&mut self, keep: impl Fn(&SessionMessage) -> bool) {
+        let messages = std::mem::take(&mut self.messages);
+        let selected = self.selected.take();
+        for (index, message) in messages.into_iter().enumerate() {
+            if !keep(&message) {
+                continue;
+            }
+            if selected == Some(index) {
+                self.selected = Some(self.messages.len());
+            }
+            self.messages.push(message);
+        }
+    }
+}
+
+/// A saved session with the messages that can no longer be opened taken out
+#[derive(serde::Serialize)]
+#[serde(rename_all = "camelCase")]
+pub struct RestoredSession {
+    pub messages: Vec<SessionMessage>,
+    /// Index in `messages`; None if the message shown last is gone
+    pub selected: Option<usize>,
+    /// Messages whose file was moved or deleted, or that left the archive
+    pub missing: Vec<SessionMessage>,
+}
+
+/// The session of the running app, written to `<config dir>/session.json` (one file per
+/// profile) when the app exits. The frontend reports every change, so the session is
+/// known even when the window is gone before the app exits.
+pub struct SessionStore {
+    /// Where the session goes, with the session; None until the frontend reports one
+    current: Mutex<Option<(PathBuf, Session)>>,
+}
+
+/// Location of the session of a profile (None for the default profile)
+pub fn file_path(config_dir: &Path, profile: Option<&str>) -> PathBuf {
+    match profile {
+        Some(profile) => config_dir.join(format!("session-{}.json", profile)),
+        None => config_dir.join(FILE_NAME),
+    }
+}
+
+/// Whether the file of a message still exists; archived messages are checked by the caller
+pub fn file_exists(message: &SessionMessage) -> bool {
+    Path::new(&message.path).is_file()
+}
+
+/// A damaged session is dropped instead of failing the start
+fn load(path: &Path) -> Result<Session, String> {
+    match std::fs::read_to_string(path) {
+        Ok(content) => Ok(serde_json::from_str(&content).unwrap_or_else(|e| {
+            log_warn!("Ignoring invalid {}: {}", FILE_NAME, e);
+            Session::default()
+        })),
+        Err(e) if e.kind() == std::io::ErrorKind::NotFound => Ok(Session::default()),
+        Err(e) => Err(format!("Failed to read {}: {}", FILE_NAME, e)),
+    }
+}
+
+fn save(path: &Path, session: &Session) -> Result<(), String> {
+    if let Some(dir) = path.parent() {
+        std::fs::create_dir_all(dir)
+            .map_err(|e| format!("Failed to create config directory: {}", e))?;
+    }
+    let content = serde_json::to_string_pretty(session)
+        .map_err(|e| format!("Failed to serialize {}: {}", FILE_NAME, e))?;
+    std::fs::write(path, content).map_err(|e| format!("Failed to write {}: {}", FILE_NAME, e))
+}
+
+impl SessionStore {
+    pub fn new() -> Self {
+        SessionStore {
+            current: Mutex::new(None),
+        }
+    }
+
+    /// Remember the session of the running app, to be saved at `path` on exit
+    pub fn update(&self, path: PathBuf, session: Session) {
+        *self.current.lock().unwrap() = Some((path, session));
+    }
+
+    /// Write the session of the running app. The saved session stays as it is if the
+    /// frontend never reported one, e.g. when the app exits while starting.
+    pub fn save(&self) -> Result<(), String> {
+        match self.current.lock().unwrap().as_ref() {
+            Some((path, session)) => save(path, session),
+            None => Ok(()),
+        }
+    }
+
+    /// The saved session, split by `exists` into the messages that can be opened and
+    /// those that are missing
+    pub fn restore(
+        &self,
+        path: &Path,
+        exists: impl Fn(&SessionMessage) -> bool,
+    ) -> Result<RestoredSession, String> {
+        let session = load(path)?;
+        let mut restored = RestoredSession {
+            messages: Vec::new(),
+            selected: None,
+            missing: Vec::new(),
+        };
+        for (index, message) in session.messages.into_iter().enumerate() {
+            if !exists(&message) {
+                log_info!("Session message is gone: {} ({})", message.file_name, message.path);
+                restored.missing.push(message);
+                continue;
+            }
+            if session.selected == Some(index) {
+                restored.selected = Some(restored.messages.len());
+            }
+            restored.messages.push(message);
+        }
+        Ok(restored)
+    }
+}
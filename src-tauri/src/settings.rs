@@ -0,0 +1,171 @@
+use serde_json::Value;
+use std::path::{Path, PathBuf};
+use std::sync::Mutex;
+
+/// Name of the settings file in the app's config directory
+const FILE_NAME: &str = "settings.json";
+
+/// User settings the backend needs as well (`<config dir>/settings.json`, one file per
+/// profile). The frontend keeps its copy in the WebView storage and syncs it at startup.
+/// Unset fields mean the built-in default.
+#[derive(serde::Serialize, serde::Deserialize, Default, Clone)]
+#[serde(rename_all = "camelCase")]
+pub struct Settings {
+    /// "system", "light" or "dark"
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub theme: Option<String>,
+    /// "load" or "block"
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub external_content: Option<String>,
+    /// Senders whose remote images load without asking: addresses or `@domain`
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub remote_image_senders: Option<Vec<String>>,
+    /// Folder the save dialogs start in
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub default_save_directory: Option<String>,
+    /// "welcome" or "last-file" (reopen the most recent file when started without files)
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub startup: Option<String>,
+    /// "hide" or "show" (an icon in the system tray or menu bar with quick actions)
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub tray_icon: Option<String>,
+}
+
+/// Payload of the `settings-changed` event
+#[derive(serde::Serialize, Clone)]
+pub struct SettingChange {
+    pub key: String,
+    pub value: Value,
+}
+
+/// Serializes read-modify-write access from several windows
+pub struct SettingsStore {
+    lock: Mutex<()>,
+}
+
+/// Location of the settings of a profile (None for the default profile)
+pub fn file_path(config_dir: &Path, profile: Option<&str>) -> PathBuf {
+    match profile {
+        Some(profile) => config_dir.join(format!("settings-{}.json", profile)),
+        None => config_dir.join(FILE_NAME),
+    }
+}
+
+fn load(path: &Path) -> Result<Settings, String> {
+    match std::fs::read_to_string(path) {
+        Ok(content) => {
+            serde_json::from_str(&content).map_err(|e| format!("Invalid {}: {}", FILE_NAME, e))
+        }
+        Err(e) if e.kind() == std::io::ErrorKind::NotFound => Ok(Settings::default()),
+        Err(e) => Err(format!("Failed to read {}: {}", FILE_NAME, e)),
+    }
+}
+
+fn save(path: &Path, settings: &Settings) -> Result<(), String> {
+    if let Some(dir) = path.parent() {
+        std::fs::create_dir_all(dir)
+            .map_err(|e| format!("Failed to create config directory: {}", e))?;
+    }
+    let content = serde_json::to_string_pretty(settings)
+        .map_err(|e| format!("Failed to serialize {}: {}", FILE_NAME, e))?;
+    std::fs::write(path, content).map_err(|e| format!("Failed to write {}: {}", FILE_NAME, e))
+}
+
+/// A string from the allowed values, None to reset to the default
+fn choice(key: &str, value: &Value, allowed: &[&str]) -> Result<Option<String>, String> {
+    match value {
+        Value::Null => Ok(None),
+        Value::String(s) if allowed.contains(&s.as_str()) => Ok(Some(s.clone())),
+        _ => Err(format!("Invalid value for {}: {}", key, value)),
+    }
+}
+
+/// Lowercase addresses and `@domain` entries without duplicates, None for an empty list
+fn senders(key: &str, value: &Value) -> Result<Option<Vec<String>>, String> {
+    let items = match value {
+        Value::Null => return Ok(None),
+        Value::Array(items) => items,
+        _ => return Err(format!("Invalid value for {}: {}", key, value)),
+    };
+    let mut senders: Vec<String> = Vec::new();
+    for item in items {
+        let sender = match item {
+            Value::String(s) if s.contains('@') => s.trim().to_lowercase(),
+            _ => return Err(format!("Invalid sender for {}: {}", key, item)),
+        };
+        if !senders.contains(&sender) {
+            senders.push(sender);
+        }
+    }
+    Ok(Some(senders).filter(|senders| !senders.is_empty()))
+}
+
+fn directory(key: &str, value: &Value) -> Result<Option<String>, String> {
+    match value {
+        Value::Null => Ok(None),
+        Value::String(s) if s.is_empty() => Ok(None),
+        Value::String(s) if Path::new(s).is_dir() => Ok(Some(s.clone())),
+        Value::String(s) => Err(format!("Not a folder: {}", s)),
+        _ => Err(format!("Invalid value for {}: {}", key, value)),
+    }
+}
+
+impl SettingsStore {
+    pub fn new() -> Self {
+        SettingsStore {
+            lock: Mutex::new(()),
+        }
+    }
+
+    pub fn get(&self, path: &Path) -> Result<Settings, String> {
+        let _guard = self.lock.lock().unwrap();
+        load(path)
+    }
+
+    /// Change one setting (null resets it) and return the stored value
+    pub fn set(&self, path: &Path, key: &str, value: &Value) -> Result<Value, String> {
+        let _guard = self.lock.lock().unwrap();
+        let mut settings = load(path)?;
+        let stored = match key {
+            "theme" => {
+                settings.theme = choice(key, value, &["system", "light", "dark"])?;
+                settings.theme.clone().map(Value::String)
+            }
+            "externalContent" => {
+                settings.external_content = choice(key, value, &["load", "block"])?;
+                settings.external_content.clone().map(Value::String)
+            }
+            "remoteImageSenders" => {
+                settings.remote_image_senders = senders(key, value)?;
+                settings
+                    .remote_image_senders
+                    .clone()
+                    .map(|senders| senders.into_iter().map(Value::String).collect())
+            }
+            "defaultSaveDirectory" => {
+                settings.default_save_directory = directory(key, value)?;
+                settings.default_save_directory.clone().map(Value::String)
+            }
+            "startup" => {
+                settings.startup = choice(key, value, &["welcome", "last-file", "session"])?;
+                settings.startup.clone().map(Value::String)
+            }
+            "trayIcon" => {
+                settings.tray_icon = choice(key, value, &["hide", "show"])?;
+                settings.tray_icon.clone().map(Value::String)
+            }
+            _ => return Err(format!("Unknown setting: {}", key)),
+        };
+        save(path, &settings)?;
+        Ok(stored.unwrap_or(Value::Null))
+    }
+
+    /// Folder the save dialogs start in, None if unset or no longer there
+    pub fn default_save_directory(&self, path: &Path) -> Option<PathBuf> {
+        self.get(path)
+            .ok()?
+            .default_save_directory
+            .map(PathBuf::from)
+            .filter(|dir| dir.is_dir())
+    }
+}
@@ -0,0 +1,551 @@
+use aes::cipher::{block_padding::Pkcs7, BlockDecryptMut, KeyIvInit};
+use rsa::{pkcs8::DecodePrivateKey, Oaep, Pkcs1v15Encrypt, RsaPrivateKey};
+use sha1::{Digest, Sha1};
+use sha2::Sha256;
+
+// Decrypts S/MIME enveloped data (application/pkcs7-mime, smime.p7m) with the RSA keys
+// of a PKCS#12 file or, on Windows and macOS, with the certificates of the OS keystore.
+// KeyTransRecipientInfo with RSA (PKCS#1 v1.5 or OAEP) and AES/3DES-CBC content
+// encryption are supported, which is what Outlook and Thunderbird send. Key agreement
+// (EC) recipients and AES-GCM (AuthEnvelopedData) are not.
+
+const OID_DATA: &[u8] = &[0x2A, 0x86, 0x48, 0x86, 0xF7, 0x0D, 0x01, 0x07, 0x01];
+const OID_SIGNED_DATA: &[u8] = &[0x2A, 0x86, 0x48, 0x86, 0xF7, 0x0D, 0x01, 0x07, 0x02];
+const OID_ENVELOPED_DATA: &[u8] = &[0x2A, 0x86, 0x48, 0x86, 0xF7, 0x0D, 0x01, 0x07, 0x03];
+const OID_ENCRYPTED_DATA: &[u8] = &[0x2A, 0x86, 0x48, 0x86, 0xF7, 0x0D, 0x01, 0x07, 0x06];
+const OID_AUTH_ENVELOPED_DATA: &[u8] =
+    &[0x2A, 0x86, 0x48, 0x86, 0xF7, 0x0D, 0x01, 0x09, 0x10, 0x01, 0x17];
+const OID_RSA_ENCRYPTION: &[u8] = &[0x2A, 0x86, 0x48, 0x86, 0xF7, 0x0D, 0x01, 0x01, 0x01];
+const OID_RSAES_OAEP: &[u8] = &[0x2A, 0x86, 0x48, 0x86, 0xF7, 0x0D, 0x01, 0x01, 0x07];
+const OID_SHA256: &[u8] = &[0x60, 0x86, 0x48, 0x01, 0x65, 0x03, 0x04, 0x02, 0x01];
+const OID_AES128_CBC: &[u8] = &[0x60, 0x86, 0x48, 0x01, 0x65, 0x03, 0x04, 0x01, 0x02];
+const OID_AES192_CBC: &[u8] = &[0x60, 0x86, 0x48, 0x01, 0x65, 0x03, 0x04, 0x01, 0x16];
+const OID_AES256_CBC: &[u8] = &[0x60, 0x86, 0x48, 0x01, 0x65, 0x03, 0x04, 0x01, 0x2A];
+const OID_DES_EDE3_CBC: &[u8] = &[0x2A, 0x86, 0x48, 0x86, 0xF7, 0x0D, 0x03, 0x07];
+const OID_KEY_BAG: &[u8] = &[0x2A, 0x86, 0x48, 0x86, 0xF7, 0x0D, 0x01, 0x0C, 0x0A, 0x01, 0x01];
+const OID_SHROUDED_KEY_BAG: &[u8] =
+    &[0x2A, 0x86, 0x48, 0x86, 0xF7, 0x0D, 0x01, 0x0C, 0x0A, 0x01, 0x02];
+const OID_PBE_SHA1_3DES: &[u8] = &[0x2A, 0x86, 0x48, 0x86, 0xF7, 0x0D, 0x01, 0x0C, 0x01, 0x03];
+const OID_PBES2: &[u8] = &[0x2A, 0x86, 0x48, 0x86, 0xF7, 0x0D, 0x01, 0x05, 0x0D];
+const OID_PBKDF2: &[u8] = &[0x2A, 0x86, 0x48, 0x86, 0xF7, 0x0D, 0x01, 0x05, 0x0C];
+const OID_HMAC_SHA1: &[u8] = &[0x2A, 0x86, 0x48, 0x86, 0xF7, 0x0D, 0x02, 0x07];
+const OID_HMAC_SHA256: &[u8] = &[0x2A, 0x86, 0x48, 0x86, 0xF7, 0x0D, 0x02, 0x09];
+
+const TAG_INTEGER: u8 = 0x02;
+const TAG_OCTET_STRING: u8 = 0x04;
+const TAG_OID: u8 = 0x06;
+const TAG_SEQUENCE: u8 = 0x30;
+const TAG_SET: u8 = 0x31;
+const TAG_CONTEXT_0: u8 = 0xA0;
+/// [0] IMPLICIT OCTET STRING, primitive or (BER) constructed
+const TAG_IMPLICIT_0: u8 = 0x80;
+
+/// Nesting limit for indefinite-length BER, which Outlook uses for S/MIME
+const MAX_DEPTH: usize = 64;
+/// Password-based key derivation gives up above this many iterations
+const MAX_ITERATIONS: u32 = 10_000_000;
+
+fn invalid() -> String {
+    "Invalid S/MIME or certificate data".to_string()
+}
+
+// --- BER ---
+
+/// One BER element: its tag, its contents and the whole encoding
+#[derive(Clone, Copy)]
+struct Tlv<'a> {
+    tag: u8,
+    body: &'a [u8],
+    raw: &'a [u8],
+}
+
+/// Read the element at the start of `data`, returns it and the rest.
+/// Indefinite lengths are resolved by walking the children up to the end-of-contents.
+fn read_tlv(data: &[u8], depth: usize) -> Result<(Tlv<'_>, &[u8]), String> {
+    if depth > MAX_DEPTH {
+        return Err(invalid());
+    }
+    let (&tag, rest) = data.split_first().ok_or_else(invalid)?;
+    if tag & 0x1F == 0x1F {
+        return Err(invalid()); // High tag numbers are not used by CMS or PKCS#12
+    }
+    let (&first, rest) = rest.split_first().ok_or_else(invalid)?;
+
+    if first == 0x80 {
+        if tag & 0x20 == 0 {
+            return Err(invalid());
+        }
+        let mut remaining = rest;
+        while !remaining.starts_with(&[0, 0]) {
+            remaining = read_tlv(remaining, depth + 1)?.1;
+        }
+        let body_len = rest.len() - remaining.len();
+        let raw_len = data.len() - remaining.len() + 2;
+        return Ok((
+            Tlv {
+                tag,
+                body: &rest[..body_len],
+                raw: &data[..raw_len],
+            },
+            &remaining[2..],
+        ));
+    }
+
+    let (len, rest) = if first < 0x80 {
+        (first as usize, rest)
+    } else {
+        let count = (first & 0x7F) as usize;
+        if count > 4 || rest.len() < count {
+            return Err(invalid());
+        }
+        let len = rest[..count]
+            .iter()
+            .fold(0usize, |len, &b| (len << 8) | b as usize);
+        (len, &rest[count..])
+    };
+    if rest.len() < len {
+        return Err(invalid());
+    }
+    let header_len = data.len() - rest.len();
+    Ok((
+        Tlv {
+            tag,
+            body: &rest[..len],
+            raw: &data[..header_len + len],
+        },
+        &rest[len..],
+    ))
+}
+
+/// The children of a constructed element
+fn children(body: &[u8]) -> Result<Vec<Tlv<'_>>, String> {
+    let mut items = Vec::new();
+    let mut rest = body;
+    while !rest.is_empty() {
+        let (item, next) = read_tlv(rest, 0)?;
+        items.push(item);
+        rest = next;
+    }
+    Ok(items)
+}
+
+fn expect(tlv: Tlv<'_>, tag: u8) -> Result<Tlv<'_>, String> {
+    if tlv.tag == tag {
+        Ok(tlv)
+    } else {
+        Err(invalid())
+    }
+}
+
+/// Contents of an OCTET STRING; BER may split it into constructed chunks
+fn octets(tlv: Tlv<'_>) -> Result<Vec<u8>, String> {
+    if tlv.tag & 0x20 == 0 {
+        return Ok(tlv.body.to_vec());
+    }
+    let mut data = Vec::new();
+    for chunk in children(tlv.body)? {
+        data.extend(octets(chunk)?);
+    }
+    Ok(data)
+}
+
+fn integer(tlv: Tlv<'_>) -> Result<u32, String> {
+    let body = expect(tlv, TAG_INTEGER)?.body;
+    if body.is_empty() || body.len() > 5 || (body.len() == 5 && body[0] != 0) {
+        return Err(invalid());
+    }
+    Ok(body.iter().fold(0u32, |value, &b| (value << 8) | b as u32))
+}
+
+/// OID and parameters of an AlgorithmIdentifier
+fn algorithm(tlv: Tlv<'_>) -> Result<(&[u8], Option<Tlv<'_>>), String> {
+    let items = children(expect(tlv, TAG_SEQUENCE)?.body)?;
+    let oid = expect(*items.first().ok_or_else(invalid)?, TAG_OID)?.body;
+    Ok((oid, items.get(1).copied()))
+}
+
+/// Content type and the [0] EXPLICIT content of a ContentInfo
+fn content_info(tlv: Tlv<'_>) -> Result<(&[u8], Option<Tlv<'_>>), String> {
+    let items = children(expect(tlv, TAG_SEQUENCE)?.body)?;
+    let oid = expect(*items.first().ok_or_else(invalid)?, TAG_OID)?.body;
+    let content = match items.get(1) {
+        Some(explicit) => {
+            let inner = children(expect(*explicit, TAG_CONTEXT_0)?.body)?;
+            Some(*inner.first().ok_or_else(invalid)?)
+        }
+        None => None,
+    };
+    Ok((oid, content))
+}
+
+// --- Ciphers ---
+
+fn cbc_decrypt(oid: &[u8], key: &[u8], iv: &[u8], data: &[u8]) -> Result<Vec<u8>, String> {
+    let failed = |_| "Decryption failed".to_string();
+    if oid == OID_AES128_CBC {
+        cbc::Decryptor::<aes::Aes128>::new_from_slices(key, iv)
+            .map_err(|_| invalid())?
+            .decrypt_padded_vec_mut::<Pkcs7>(data)
+            .map_err(failed)
+    } else if oid == OID_AES192_CBC {
+        cbc::Decryptor::<aes::Aes192>::new_from_slices(key, iv)
+            .map_err(|_| invalid())?
+            .decrypt_padded_vec_mut::<Pkcs7>(data)
+            .map_err(failed)
+    } else if oid == OID_AES256_CBC {
+        cbc::Decryptor::<aes::Aes256>::new_from_slices(key, iv)
+            .map_err(|_| invalid())?
+            .decrypt_padded_vec_mut::<Pkcs7>(data)
+            .map_err(failed)
+    } else if oid == OID_DES_EDE3_CBC {
+        cbc::Decryptor::<des::TdesEde3>::new_from_slices(key, iv)
+            .map_err(|_| invalid())?
+            .decrypt_padded_vec_mut::<Pkcs7>(data)
+            .map_err(failed)
+    } else {
+        Err("Unsupported S/MIME encryption algorithm".to_string())
+    }
+}
+
+/// Key size of a content cipher
+fn key_length(oid: &[u8]) -> Option<usize> {
+    if oid == OID_AES128_CBC {
+        Some(16)
+    } else if oid == OID_AES192_CBC || oid == OID_DES_EDE3_CBC {
+        Some(24)
+    } else if oid == OID_AES256_CBC {
+        Some(32)
+    } else {
+        None
+    }
+}
+
+// --- PKCS#12 ---
+
+/// Key derivation of PKCS#12 (RFC 7292, appendix B) with SHA-1; `id` 1 derives the
+/// key, 2 the IV
+fn pkcs12_kdf(password: &[u8], salt: &[u8], id: u8, iterations: u32, len: usize) -> Vec<u8> {
+    const U: usize = 20;
+    const V: usize = 64;
+
+    let fill = |data: &[u8]| -> Vec<u8> {
+        let len = V * data.len().div_ceil(V);
+        data.iter().copied().cycle().take(len).collect()
+    };
+    let mut input = fill(salt);
+    input.extend(fill(password));
+
+    let mut derived = Vec::with_capacity(len + U);
+    loop {
+        let mut hash = Sha1::new().chain_update([id; V]).chain_update(&input).finalize();
+        for _ in 1..iterations {
+            hash = Sha1::digest(hash);
+        }
+        derived.extend_from_slice(&hash);
+        if derived.len() >= len {
+            derived.truncate(len);
+            return derived;
+        }
+
+        // Every block of the input becomes (block + hash repeated + 1) mod 2^512
+        let addend: Vec<u8> = hash.iter().copied().cycle().take(V).collect();
+        for block in input.chunks_mut(V) {
+            let mut carry = 1u16;
+            for i in (0..V).rev() {
+                let sum = block[i] as u16 + addend[i] as u16 + carry;
+                block[i] = sum as u8;
+                carry = sum >> 8;
+            }
+        }
+    }
+}
+
+/// Decrypt password-protected data of a PKCS#12 file (3DES with the PKCS#12 key
+/// derivation, or PBES2 with PBKDF2 and AES as written by current tools)
+fn pbe_decrypt(algorithm_tlv: Tlv<'_>, password: &str, data: &[u8]) -> Result<Vec<u8>, String> {
+    let (oid, params) = algorithm(algorithm_tlv)?;
+    let params = children(expect(params.ok_or_else(invalid)?, TAG_SEQUENCE)?.body)?;
+
+    if oid == OID_PBE_SHA1_3DES {
+        let salt = octets(expect(*params.first().ok_or_else(invalid)?, TAG_OCTET_STRING)?)?;
+        let iterations = integer(*params.get(1).ok_or_else(invalid)?)?;
+        if iterations == 0 || iterations > MAX_ITERATIONS {
+            return Err(invalid());
+        }
+        // BMPString with a terminating zero
+        let mut bmp: Vec<u8> = password.encode_utf16().flat_map(u16::to_be_bytes).collect();
+        bmp.extend([0, 0]);
+        let key = pkcs12_kdf(&bmp, &salt, 1, iterations, 24);
+        let iv = pkcs12_kdf(&bmp, &salt, 2, iterations, 8);
+        return cbc_decrypt(OID_DES_EDE3_CBC, &key, &iv, data);
+    }
+
+    if oid == OID_PBES2 {
+        let (kdf, kdf_params) = algorithm(*params.first().ok_or_else(invalid)?)?;
+        let (cipher, iv) = algorithm(*params.get(1).ok_or_else(invalid)?)?;
+        if kdf != OID_PBKDF2 {
+            return Err("Unsupported certificate key derivation".to_string());
+        }
+        let kdf_params = children(expect(kdf_params.ok_or_else(invalid)?, TAG_SEQUENCE)?.body)?;
+        let salt = octets(expect(*kdf_params.first().ok_or_else(invalid)?, TAG_OCTET_STRING)?)?;
+        let iterations = integer(*kdf_params.get(1).ok_or_else(invalid)?)?;
+        if iterations == 0 || iterations > MAX_ITERATIONS {
+            return Err(invalid());
+        }
+        // keyLength is optional and comes before the optional PRF
+        let prf = kdf_params
+            .iter()
+            .skip(2)
+            .find(|item| item.tag == TAG_SEQUENCE)
+            .map(|item| algorithm(*item))
+            .transpose()?
+            .map(|(prf, _)| prf)
+            .unwrap_or(OID_HMAC_SHA1);
+
+        let mut key = vec![0u8; key_length(cipher).ok_or_else(invalid)?];
+        if prf == OID_HMAC_SHA256 {
+            pbkdf2::pbkdf2_hmac::<Sha256>(password.as_bytes(), &salt, iterations, &mut key);
+        } else if prf == OID_HMAC_SHA1 {
+            pbkdf2::pbkdf2_hmac::<Sha1>(password.as_bytes(), &salt, iterations, &mut key);
+        } else {
+            return Err("Unsupported certificate key derivation".to_string());
+        }
+        let iv = octets(expect(iv.ok_or_else(invalid)?, TAG_OCTET_STRING)?)?;
+        return cbc_decrypt(cipher, &key, &iv, data);
+    }
+
+    Err("Unsupported certificate encryption".to_string())
+}
+
+/// Private keys of the bags of a SafeContents
+fn bag_keys(
+    safe_contents: &[u8],
+    password: &str,
+    keys: &mut Vec<RsaPrivateKey>,
+) -> Result<(), String> {
+    let (sequence, _) = read_tlv(safe_contents, 0)?;
+    for bag in children(expect(sequence, TAG_SEQUENCE)?.body)? {
+        let (oid, value) = content_info(bag)?;
+        let Some(value) = value else {
+            continue;
+        };
+
+        let der = if oid == OID_KEY_BAG {
+            value.raw.to_vec()
+        } else if oid == OID_SHROUDED_KEY_BAG {
+            let items = children(expect(value, TAG_SEQUENCE)?.body)?;
+            let encrypted = octets(*items.get(1).ok_or_else(invalid)?)?;
+            pbe_decrypt(*items.first().ok_or_else(invalid)?, password, &encrypted)
+                .map_err(|_| "Wrong certificate password".to_string())?
+        } else {
+            continue; // Certificates and CRLs are not needed to decrypt
+        };
+
+        // Only RSA keys can decrypt key transport recipients
+        if let Ok(key) = RsaPrivateKey::from_pkcs8_der(&der) {
+            keys.push(key);
+        }
+    }
+    Ok(())
+}
+
+/// RSA private keys of a PKCS#12 (.pfx/.p12) file
+fn pkcs12_keys(pfx: &[u8], password: &str) -> Result<Vec<RsaPrivateKey>, String> {
+    let (pfx, _) = read_tlv(pfx, 0).map_err(|_| "Not a PKCS#12 certificate file".to_string())?;
+    let items = children(expect(pfx, TAG_SEQUENCE)?.body)?;
+    let (oid, auth_safe) = content_info(*items.get(1).ok_or_else(invalid)?)?;
+    if oid != OID_DATA {
+        return Err("Unsupported certificate file (public-key protected)".to_string());
+    }
+
+    let auth_safe = octets(auth_safe.ok_or_else(invalid)?)?;
+    let (sequence, _) = read_tlv(&auth_safe, 0)?;
+    let mut keys = Vec::new();
+    for info in children(expect(sequence, TAG_SEQUENCE)?.body)? {
+        let (oid, content) = content_info(info)?;
+        let Some(content) = content else {
+            continue;
+        };
+        if oid == OID_DATA {
+            bag_keys(&octets(content)?, password, &mut keys)?;
+        } else if oid == OID_ENCRYPTED_DATA {
+            // Usually holds only the certificates, possibly with a legacy cipher; a
+            // failure here is not an error as long as a key is found elsewhere
+            let decrypted = children(expect(content, TAG_SEQUENCE)?.body)
+                .and_then(|items| encrypted_content(*items.get(1).ok_or_else(invalid)?))
+                .and_then(|(cipher, data)| pbe_decrypt(cipher, password, &data));
+            if let Ok(safe_contents) = decrypted {
+                bag_keys(&safe_contents, password, &mut keys)?;
+            }
+        }
+    }
+
+    if keys.is_empty() {
+        Err("The certificate file contains no RSA private key".to_string())
+    } else {
+        Ok(keys)
+    }
+}
+
+// --- CMS ---
+
+/// Algorithm and ciphertext of an EncryptedContentInfo
+fn encrypted_content(tlv: Tlv<'_>) -> Result<(Tlv<'_>, Vec<u8>), String> {
+    let items = children(expect(tlv, TAG_SEQUENCE)?.body)?;
+    let algorithm = *items.get(1).ok_or_else(invalid)?;
+    let content = items
+        .get(2)
+        .filter(|item| item.tag & 0xDF == TAG_IMPLICIT_0)
+        .ok_or_else(|| "The encrypted content is detached".to_string())?;
+    Ok((algorithm, octets(*content)?))
+}
+
+/// Content key of a KeyTransRecipientInfo, if `key` is its private key
+fn content_key(recipient: Tlv<'_>, key: &RsaPrivateKey) -> Result<Vec<u8>, String> {
+    let items = children(expect(recipient, TAG_SEQUENCE)?.body)?;
+    let (oid, params) = algorithm(*items.get(2).ok_or_else(invalid)?)?;
+    let encrypted_key = octets(expect(*items.get(3).ok_or_else(invalid)?, TAG_OCTET_STRING)?)?;
+
+    let decrypted = if oid == OID_RSA_ENCRYPTION {
+        key.decrypt(Pkcs1v15Encrypt, &encrypted_key)
+    } else if oid == OID_RSAES_OAEP {
+        // SHA-1 unless the parameters name SHA-256 (hash and MGF1 use the same digest)
+        let sha256 = params
+            .map(|params| params.raw.windows(OID_SHA256.len()).any(|w| w == OID_SHA256))
+            .unwrap_or(false);
+        if sha256 {
+            key.decrypt(Oaep::new::<Sha256>(), &encrypted_key)
+        } else {
+            key.decrypt(Oaep::new::<Sha1>(), &encrypted_key)
+        }
+    } else {
+        return Err("Unsupported S/MIME key encryption".to_string());
+    };
+    decrypted.map_err(|_| "Not encrypted for this certificate".to_string())
+}
+
+/// The content of a CMS EnvelopedData, decrypted with one of the keys
+fn decrypt_enveloped(p7m: &[u8], keys: &[RsaPrivateKey]) -> Result<Vec<u8>, String> {
+    let (info, _) = read_tlv(p7m, 0)?;
+    let (oid, content) = content_info(info)?;
+    if oid == OID_SIGNED_DATA {
+        return Err("The message is signed, not encrypted".to_string());
+    }
+    if oid == OID_AUTH_ENVELOPED_DATA {
+        return Err("AES-GCM encrypted messages are not supported".to_string());
+    }
+    if oid != OID_ENVELOPED_DATA {
+        return Err("Not an encrypted S/MIME message".to_string());
+    }
+
+    let items = children(expect(content.ok_or_else(invalid)?, TAG_SEQUENCE)?.body)?;
+    // version, [0] originatorInfo (optional), recipientInfos, encryptedContentInfo
+    let mut rest = items.iter().skip(1).filter(|item| item.tag != TAG_CONTEXT_0);
+    let recipients = children(expect(*rest.next().ok_or_else(invalid)?, TAG_SET)?.body)?;
+    let (algorithm_tlv, ciphertext) = encrypted_content(*rest.next().ok_or_else(invalid)?)?;
+    let (cipher, iv) = algorithm(algorithm_tlv)?;
+    let expected_len = key_length(cipher)
+        .ok_or_else(|| "Unsupported S/MIME encryption algorithm".to_string())?;
+    let iv = octets(expect(iv.ok_or_else(invalid)?, TAG_OCTET_STRING)?)?;
+
+    let mut error = "Not encrypted for this certificate".to_string();
+    for recipient in recipients.iter().filter(|item| item.tag == TAG_SEQUENCE) {
+        for key in keys {
+            match content_key(*recipient, key) {
+                Ok(content_key) if content_key.len() == expected_len => {
+                    if let Ok(plaintext) = cbc_decrypt(cipher, &content_key, &iv, &ciphertext) {
+                        return Ok(plaintext);
+                    }
+                }
+                Ok(_) => {}
+                Err(e) if e.starts_with("Unsupported") => error = e,
+                Err(_) => {}
+            }
+        }
+    }
+    Err(error)
+}
+
+/// Decrypt an smime.p7m with the private keys of a PKCS#12 file. Returns the MIME entity
+/// that was encrypted (headers and body).
+pub fn decrypt_with_pkcs12(p7m: &[u8], pfx: &[u8], password: &str) -> Result<Vec<u8>, String> {
+    let keys = pkcs12_keys(pfx, password)?;
+    decrypt_enveloped(p7m, &keys)
+}
+
+/// Whether `decrypt_with_keystore` is available on this OS
+pub fn keystore_available() -> bool {
+    cfg!(any(windows, target_os = "macos"))
+}
+
+/// Decrypt an smime.p7m with the certificates of the current user: the personal
+/// certificate store on Windows (EnvelopedCms via PowerShell), the keychain on macOS
+/// (`security cms`). The OS may ask for permission to use the key.
+pub fn decrypt_with_keystore(p7m: &[u8]) -> Result<Vec<u8>, String> {
+    #[cfg(windows)]
+    {
+        use base64::{engine::general_purpose::STANDARD, Engine as _};
+        use std::os::windows::process::CommandExt;
+        const CREATE_NO_WINDOW: u32 = 0x0800_0000;
+        const SCRIPT: &str = "Add-Type -AssemblyName System.Security; \
+            $data = [Convert]::FromBase64String([Console]::In.ReadToEnd()); \
+            $cms = New-Object System.Security.Cryptography.Pkcs.EnvelopedCms; \
+            $cms.Decode($data); $cms.Decrypt(); \
+            [Console]::Out.Write([Convert]::ToBase64String($cms.ContentInfo.Content))";
+
+        let mut command = std::process::Command::new("powershell");
+        command
+            .args(["-NoProfile", "-NonInteractive", "-Command", SCRIPT])
+            .creation_flags(CREATE_NO_WINDOW);
+        let output = run_with_input(command, STANDARD.encode(p7m).as_bytes())?;
+        STANDARD
+            .decode(String::from_utf8_lossy(&output).trim())
+            .map_err(|e| format!("Failed to decrypt message: {}", e))
+    }
+
+    #[cfg(target_os = "macos")]
+    {
+        let mut command = std::process::Command::new("security");
+        command.args(["cms", "-D"]);
+        run_with_input(command, p7m)
+    }
+
+    #[cfg(not(any(windows, target_os = "macos")))]
+    {
+        let _ = p7m;
+        Err("Decrypting with the system certificates is not supported on this OS".to_string())
+    }
+}
+
+/// Run a command with `input` on stdin; its stdout on success, stderr as the error
+#[cfg(any(windows, target_os = "macos"))]
+fn run_with_input(mut command: std::process::Command, input: &[u8]) -> Result<Vec<u8>, String> {
+    use std::io::Write;
+    use std::process::Stdio;
+
+    let mut child = command
+        .stdin(Stdio::piped())
+        .stdout(Stdio::piped())
+        .stderr(Stdio::piped())
+        .spawn()
+        .map_err(|e| format!("Failed to start decryption: {}", e))?;
+    // Written from a thread so a full stdout pipe cannot block the write
+    let mut stdin = child.stdin.take().ok_or_else(invalid)?;
+    let input = input.to_vec();
+    let writer = std::thread::spawn(move || stdin.write_all(&input));
+    let output = child
+        .wait_with_output()
+        .map_err(|e| format!("Failed to decrypt message: {}", e))?;
+    let _ = writer.join();
+
+    if output.status.success() {
+        Ok(output.stdout)
+    } else {
+        let message = String::from_utf8_lossy(&output.stderr).trim().to_string();
+        Err(if message.is_empty() {
+            "No certificate in the system store can decrypt this message".to_string()
+        } else {
+            format!("Failed to decrypt message: {}", message)
+        })
+    }
+}
@@ -0,0 +1,259 @@
+use std::io::Write;
+use std::process::{Child, Command, Output, Stdio};
+use std::sync::Mutex;
+use std::time::Duration;
+use tauri::{AppHandle, Emitter, Manager};
+
+/// Emitted when speech started by `speak` finishes on its own
+pub const ENDED_EVENT: &str = "speech-ended";
+
+const POLL_INTERVAL: Duration = Duration::from_millis(200);
+
+/// An installed OS voice
+#[derive(serde::Serialize, Clone)]
+#[serde(rename_all = "camelCase")]
+pub struct Voice {
+    /// Value to pass to `speak`
+    pub id: String,
+    pub name: String,
+    /// Language tag as reported by the OS, e.g. "en_US" or "en-gb"
+    pub language: String,
+}
+
+/// Text-to-speech through the OS speech command:
+/// `say` on macOS, `espeak-ng`/`espeak` on Linux, System.Speech via PowerShell on Windows.
+/// Only one utterance plays at a time.
+pub struct Speech {
+    current: Mutex<Option<(u64, Child)>>,
+    next_id: Mutex<u64>,
+}
+
+#[cfg(windows)]
+const POWERSHELL_PRELUDE: &str = "Add-Type -AssemblyName System.Speech; \
+    $s = New-Object System.Speech.Synthesis.SpeechSynthesizer;";
+
+#[cfg(windows)]
+fn powershell(script: &str) -> Command {
+    use std::os::windows::process::CommandExt;
+    const CREATE_NO_WINDOW: u32 = 0x0800_0000;
+
+    let mut command = Command::new("powershell");
+    command
+        .args(["-NoProfile", "-NonInteractive", "-Command"])
+        .arg(format!("{} {}", POWERSHELL_PRELUDE, script))
+        .creation_flags(CREATE_NO_WINDOW);
+    command
+}
+
+#[cfg(target_os = "linux")]
+fn espeak_binary() -> Option<&'static str> {
+    ["espeak-ng", "espeak"].into_iter().find(|binary| {
+        Command::new(binary)
+            .arg("--version")
+            .stdout(Stdio::null())
+            .stderr(Stdio::null())
+            .status()
+            .is_ok()
+    })
+}
+
+/// Build the command that reads text from stdin and speaks it
+fn speak_command(voice: Option<&str>) -> Result<Command, String> {
+    #[cfg(target_os = "macos")]
+    {
+        let mut command = Command::new("say");
+        if let Some(voice) = voice {
+            command.args(["-v", voice]);
+        }
+        command.args(["-f", "-"]);
+        Ok(command)
+    }
+
+    #[cfg(target_os = "linux")]
+    {
+        let binary = espeak_binary()
+            .ok_or_else(|| "Text-to-speech requires espeak-ng or espeak".to_string())?;
+        let mut command = Command::new(binary);
+        if let Some(voice) = voice {
+            command.args(["-v", voice]);
+        }
+        command.arg("--stdin");
+        Ok(command)
+    }
+
+    #[cfg(windows)]
+    {
+        // The voice is passed through the environment so it is never parsed as script
+        let mut command = powershell(
+            "[Console]::InputEncoding = [Text.Encoding]::UTF8; \
+             if ($env:MSGREADER_TTS_VOICE) { $s.SelectVoice($env:MSGREADER_TTS_VOICE) }; \
+             $s.Speak([Console]::In.ReadToEnd())",
+        );
+        command.env("MSGREADER_TTS_VOICE", voice.unwrap_or(""));
+        Ok(command)
+    }
+
+    #[cfg(not(any(target_os = "macos", target_os = "linux", windows)))]
+    {
+        let _ = voice;
+        Err("Text-to-speech is not supported on this platform".to_string())
+    }
+}
+
+/// Parse `say -v '?'` output: `Alex                en_US    # Most people recognize me...`
+#[cfg(target_os = "macos")]
+fn parse_voices(output: &str) -> Vec<Voice> {
+    output
+        .lines()
+        .filter_map(|line| {
+            let description = line.split('#').next()?.trim_end();
+            let (name, language) = description.rsplit_once(char::is_whitespace)?;
+            let name = name.trim();
+            (!name.is_empty()).then(|| Voice {
+                id: name.to_string(),
+                name: name.to_string(),
+                language: language.to_string(),
+            })
+        })
+        .collect()
+}
+
+/// Parse `espeak-ng --voices` output:
+/// ` 5  en-gb          --/M      English_(Great_Britain) gmw/en  (en 2)`
+#[cfg(target_os = "linux")]
+fn parse_voices(output: &str) -> Vec<Voice> {
+    output
+        .lines()
+        .skip(1)
+        .filter_map(|line| {
+            let columns: Vec<&str> = line.split_whitespace().collect();
+            let (language, name) = (columns.get(1)?, columns.get(3)?);
+            Some(Voice {
+                id: language.to_string(),
+                name: name.replace('_', " "),
+                language: language.to_string(),
+            })
+        })
+        .collect()
+}
+
+/// Parse `Name|Culture` lines printed by the PowerShell voice listing
+#[cfg(windows)]
+fn parse_voices(output: &str) -> Vec<Voice> {
+    output
+        .lines()
+        .filter_map(|line| {
+            let (name, language) = line.trim().split_once('|')?;
+            Some(Voice {
+                id: name.to_string(),
+                name: name.to_string(),
+                language: language.to_string(),
+            })
+        })
+        .collect()
+}
+
+#[cfg(not(any(target_os = "macos", target_os = "linux", windows)))]
+fn parse_voices(_output: &str) -> Vec<Voice> {
+    Vec::new()
+}
+
+/// Run the command that prints the installed voices
+fn voices_output() -> std::io::Result<Output> {
+    #[cfg(target_os = "macos")]
+    return Command::new("say").args(["-v", "?"]).output();
+
+    #[cfg(target_os = "linux")]
+    return match espeak_binary() {
+        Some(binary) => Command::new(binary).arg("--voices").output(),
+        None => Err(std::io::ErrorKind::NotFound.into()),
+    };
+
+    #[cfg(windows)]
+    return powershell(
+        "$s.GetInstalledVoices() | Where-Object { $_.Enabled } | \
+         ForEach-Object { $_.VoiceInfo.Name + '|' + $_.VoiceInfo.Culture.Name }",
+    )
+    .output();
+
+    #[cfg(not(any(target_os = "macos", target_os = "linux", windows)))]
+    return Err(std::io::ErrorKind::Unsupported.into());
+}
+
+/// List installed voices. Returns an empty list if the speech command is missing.
+pub fn list_voices() -> Vec<Voice> {
+    match voices_output() {
+        Ok(output) if output.status.success() => {
+            parse_voices(&String::from_utf8_lossy(&output.stdout))
+        }
+        Ok(_) | Err(_) => Vec::new(),
+    }
+}
+
+impl Speech {
+    pub fn new() -> Self {
+        Speech {
+            current: Mutex::new(None),
+            next_id: Mutex::new(0),
+        }
+    }
+
+    /// Speak text with the given voice (or the OS default), replacing any current speech.
+    /// Emits `ENDED_EVENT` when the utterance finishes.
+    pub fn speak(&self, app: &AppHandle, text: &str, voice: Option<&str>) -> Result<(), String> {
+        self.stop();
+
+        let mut child = speak_command(voice.filter(|v| !v.is_empty()))?
+            .stdin(Stdio::piped())
+            .stdout(Stdio::null())
+            .stderr(Stdio::null())
+            .spawn()
+            .map_err(|e| format!("Failed to start text-to-speech: {}", e))?;
+
+        // Text goes through stdin so it is neither visible in the process list
+        // nor limited by the maximum command line length
+        let mut stdin = child.stdin.take().ok_or("Failed to open speech input")?;
+        let text = text.to_string();
+        std::thread::spawn(move || {
+            let _ = stdin.write_all(text.as_bytes());
+        });
+
+        let id = {
+            let mut next_id = self.next_id.lock().unwrap();
+            *next_id += 1;
+            *next_id
+        };
+        *self.current.lock().unwrap() = Some((id, child));
+
+        let app = app.clone();
+        std::thread::spawn(move || loop {
+            std::thread::sleep(POLL_INTERVAL);
+            let speech = app.state::<Speech>();
+            let mut current = speech.current.lock().unwrap();
+            match current.as_mut() {
+                Some((current_id, child)) if *current_id == id => {
+                    if matches!(child.try_wait(), Ok(None)) {
+                        continue;
+                    }
+                    *current = None;
+                    let _ = app.emit(ENDED_EVENT, ());
+                    break;
+                }
+                // Replaced or stopped by the frontend
+                _ => break,
+            }
+        });
+
+        Ok(())
+    }
+
+    /// Stop current speech. Returns true if something was playing.
+    pub fn stop(&self) -> bool {
+        let Some((_, mut child)) = self.current.lock().unwrap().take() else {
+            return false;
+        };
+        let _ = child.kill();
+        let _ = child.wait();
+        true
+    }
+}
@@ -0,0 +1,172 @@
+use std::process::Command;
+use std::time::Duration;
+use tauri::{AppHandle, Emitter};
+
+/// Event emitted with the new `SystemTheme` when the OS theme or accent color changes
+pub const CHANGED_EVENT: &str = "theme-changed";
+
+/// How often the OS settings are read to notice changes. None of the platforms offers a
+/// change notification that works without a native event loop hook on all of them.
+const POLL_INTERVAL: Duration = Duration::from_secs(3);
+
+/// Named accent colors of GNOME 47+ (`org.gnome.desktop.interface accent-color`)
+#[cfg(target_os = "linux")]
+const GNOME_ACCENTS: &[(&str, &str)] = &[
+    ("blue", "#3584e4"),
+    ("teal", "#2190a4"),
+    ("green", "#3a944a"),
+    ("yellow", "#c88800"),
+    ("orange", "#ed5b00"),
+    ("red", "#e62d42"),
+    ("pink", "#d56199"),
+    ("purple", "#9141ac"),
+    ("slate", "#6f8396"),
+];
+
+/// Accent colors of macOS by `AppleAccentColor` (missing means blue, -1 graphite)
+#[cfg(target_os = "macos")]
+const MACOS_ACCENTS: &[(i32, &str)] = &[
+    (-1, "#8c8c8c"),
+    (0, "#ff5257"),
+    (1, "#f7821b"),
+    (2, "#ffc600"),
+    (3, "#62ba46"),
+    (4, "#007aff"),
+    (5, "#a550a7"),
+    (6, "#f74f9e"),
+];
+
+#[derive(serde::Serialize, Clone, PartialEq, Eq)]
+#[serde(rename_all = "camelCase")]
+pub struct SystemTheme {
+    /// "light" or "dark"
+    pub theme: &'static str,
+    /// `#rrggbb`, None where the OS has no accent color or it could not be read
+    pub accent_color: Option<String>,
+}
+
+fn output_text(command: &mut Command) -> Option<String> {
+    let output = command.output().ok().filter(|output| output.status.success())?;
+    let text = String::from_utf8_lossy(&output.stdout).trim().to_string();
+    (!text.is_empty()).then_some(text)
+}
+
+fn theme_name(dark: bool) -> &'static str {
+    if dark {
+        "dark"
+    } else {
+        "light"
+    }
+}
+
+/// A REG_DWORD of the current user, e.g. `    AppsUseLightTheme    REG_DWORD    0x0`
+#[cfg(windows)]
+fn reg_dword(key: &str, value: &str) -> Option<u32> {
+    use std::os::windows::process::CommandExt;
+    const CREATE_NO_WINDOW: u32 = 0x0800_0000;
+
+    let text = output_text(
+        Command::new("reg")
+            .args(["query", key, "/v", value])
+            .creation_flags(CREATE_NO_WINDOW),
+    )?;
+    text.lines().find_map(|line| {
+        let (_, data) = line.split_once("REG_DWORD")?;
+        u32::from_str_radix(data.trim().trim_start_matches("0x"), 16).ok()
+    })
+}
+
+#[cfg(windows)]
+pub fn detect() -> SystemTheme {
+    let light = reg_dword(
+        r"HKCU\Software\Microsoft\Windows\CurrentVersion\Themes\Personalize",
+        "AppsUseLightTheme",
+    );
+    // AccentColor is stored as 0xAABBGGRR
+    let accent_color = reg_dword(r"HKCU\Software\Microsoft\Windows\DWM", "AccentColor")
+        .map(|abgr| {
+            let [red, green, blue, _] = abgr.to_le_bytes();
+            format!("#{:02x}{:02x}{:02x}", red, green, blue)
+        });
+    SystemTheme {
+        theme: theme_name(light == Some(0)),
+        accent_color,
+    }
+}
+
+#[cfg(target_os = "macos")]
+pub fn detect() -> SystemTheme {
+    let read = |key: &str| output_text(Command::new("defaults").args(["read", "-g", key]));
+    // AppleInterfaceStyle only exists in dark mode
+    let dark = read("AppleInterfaceStyle").is_some_and(|style| style.eq_ignore_ascii_case("dark"));
+    let accent = read("AppleAccentColor")
+        .and_then(|accent| accent.parse::<i32>().ok())
+        .unwrap_or(4);
+    SystemTheme {
+        theme: theme_name(dark),
+        accent_color: MACOS_ACCENTS
+            .iter()
+            .find(|(index, _)| *index == accent)
+            .map(|(_, color)| color.to_string()),
+    }
+}
+
+/// The value of a GNOME setting without the quotes gsettings prints
+#[cfg(target_os = "linux")]
+fn gsetting(key: &str) -> Option<String> {
+    output_text(Command::new("gsettings").args(["get", "org.gnome.desktop.interface", key]))
+        .map(|value| value.trim_matches('\'').to_string())
+}
+
+#[cfg(target_os = "linux")]
+pub fn detect() -> SystemTheme {
+    // color-scheme since GNOME 42; before it and on other GTK desktops, a dark GTK theme
+    let dark = match gsetting("color-scheme").as_deref() {
+        Some("prefer-dark") => true,
+        Some("prefer-light") => false,
+        _ => gsetting("gtk-theme")
+            .or_else(|| std::env::var("GTK_THEME").ok())
+            .is_some_and(|theme| theme.to_lowercase().contains("dark")),
+    };
+    let accent_color = gsetting("accent-color").and_then(|accent| {
+        GNOME_ACCENTS
+            .iter()
+            .find(|(name, _)| *name == accent)
+            .map(|(_, color)| color.to_string())
+    });
+    SystemTheme {
+        theme: theme_name(dark),
+        accent_color,
+    }
+}
+
+#[cfg(not(any(windows, target_os = "macos", target_os = "linux")))]
+pub fn detect() -> SystemTheme {
+    SystemTheme {
+        theme: "light",
+        accent_color: None,
+    }
+}
+
+/// Emit `CHANGED_EVENT` whenever the detected theme differs from the last one
+pub fn watch(app: AppHandle) {
+    std::thread::spawn(move || {
+        let mut last = detect();
+        loop {
+            std::thread::sleep(POLL_INTERVAL);
+            let current = detect();
+            if current == last {
+                continue;
+            }
+            log_info!(
+                "System theme changed to {} (accent {})",
+                current.theme,
+                current.accent_color.as_deref().unwrap_or("none")
+            );
+            if let Err(e) = app.emit(CHANGED_EVENT, &current) {
+                log_warn!("Failed to emit {} event: {}", CHANGED_EVENT, e);
+            }
+            last = current;
+        }
+    });
+}
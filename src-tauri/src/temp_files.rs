@@ -0,0 +1,216 @@
+use std::path::{Path, PathBuf};
+use std::sync::atomic::{AtomicU64, Ordering};
+use std::sync::Mutex;
+use std::time::{Duration, SystemTime, UNIX_EPOCH};
+
+/// How often the background sweeper checks for expired temp files
+pub const SWEEP_INTERVAL: Duration = Duration::from_secs(5 * 60);
+
+/// Default retention before a temp file is removed (0 = only on exit)
+const DEFAULT_RETENTION_MINUTES: u64 = 60;
+
+/// Tracks temp files written by the app (e.g. attachments opened externally).
+/// All files live below a dedicated directory so leftovers from a crashed
+/// session are picked up by the next sweep as well. Each profile has its own directory.
+/// The directory is in the user's cache directory, not the shared system temp
+/// directory, where other users could see or replace the files. Each session writes
+/// to a new directory of its own, which is removed on exit.
+pub struct TempFiles {
+    /// Cache directory of the user, known once the app has started
+    base: Mutex<Option<PathBuf>>,
+    profile: Mutex<Option<String>>,
+    /// Directory of this session, created with the first file
+    session: Mutex<Option<PathBuf>>,
+    retention_minutes: Mutex<u64>,
+    counter: AtomicU64,
+}
+
+fn root_for(base: &Path, profile: Option<&str>) -> PathBuf {
+    match profile {
+        Some(profile) => base.join(format!("temp-profile-{}", profile)),
+        None => base.join("temp"),
+    }
+}
+
+/// Create a directory readable only by the user on Unix. Without `recursive` the
+/// directory must not exist yet.
+fn create_private_dir(path: &Path, recursive: bool) -> std::io::Result<()> {
+    let mut builder = std::fs::DirBuilder::new();
+    builder.recursive(recursive);
+    #[cfg(unix)]
+    {
+        use std::os::unix::fs::DirBuilderExt;
+        builder.mode(0o700);
+    }
+    builder.create(path)
+}
+
+/// Subdirectories or files of a directory
+fn children(dir: &Path) -> Vec<PathBuf> {
+    std::fs::read_dir(dir)
+        .map(|dir| dir.filter_map(|entry| entry.ok().map(|e| e.path())).collect())
+        .unwrap_or_default()
+}
+
+impl TempFiles {
+    pub fn new(profile: Option<&str>) -> Self {
+        Self {
+            base: Mutex::new(None),
+            profile: Mutex::new(profile.map(str::to_string)),
+            session: Mutex::new(None),
+            retention_minutes: Mutex::new(DEFAULT_RETENTION_MINUTES),
+            counter: AtomicU64::new(0),
+        }
+    }
+
+    /// Keep the temp files below the user's cache directory
+    pub fn set_base(&self, dir: PathBuf) {
+        *self.base.lock().unwrap() = Some(dir);
+        *self.session.lock().unwrap() = None;
+    }
+
+    /// Use the temp directory of another profile from now on
+    pub fn set_profile(&self, profile: Option<&str>) {
+        *self.profile.lock().unwrap() = profile.map(str::to_string);
+        *self.session.lock().unwrap() = None;
+    }
+
+    fn root(&self) -> Option<PathBuf> {
+        let base = self.base.lock().unwrap().clone()?;
+        Some(root_for(&base, self.profile.lock().unwrap().as_deref()))
+    }
+
+    /// The directory of this session, created if it does not exist. A new name is
+    /// picked rather than using a directory that is already there.
+    fn session_dir(&self) -> Result<PathBuf, String> {
+        let mut session = self.session.lock().unwrap();
+        if let Some(dir) = session.as_ref().filter(|dir| dir.is_dir()) {
+            return Ok(dir.clone());
+        }
+
+        let root = self
+            .root()
+            .ok_or_else(|| "The temp directory is not known yet".to_string())?;
+        create_private_dir(&root, true)
+            .map_err(|e| format!("Failed to create temp directory: {}", e))?;
+        let stamp = SystemTime::now()
+            .duration_since(UNIX_EPOCH)
+            .map(|d| d.as_millis())
+            .unwrap_or(0);
+        let prefix = format!("session-{}-{}", std::process::id(), stamp);
+        let mut attempt = 0;
+        loop {
+            let dir = root.join(format!("{}-{}", prefix, attempt));
+            match create_private_dir(&dir, false) {
+                Ok(()) => {
+                    *session = Some(dir.clone());
+                    return Ok(dir);
+                }
+                Err(e) if e.kind() == std::io::ErrorKind::AlreadyExists => attempt += 1,
+                Err(e) => return Err(format!("Failed to create temp directory: {}", e)),
+            }
+        }
+    }
+
+    pub fn set_retention_minutes(&self, minutes: u64) {
+        *self.retention_minutes.lock().unwrap() = minutes;
+    }
+
+    pub fn retention(&self) -> Option<Duration> {
+        match *self.retention_minutes.lock().unwrap() {
+            0 => None,
+            minutes => Some(Duration::from_secs(minutes * 60)),
+        }
+    }
+
+    /// Write bytes to a new tracked temp file, keeping the original file name.
+    /// Each file gets its own subdirectory so equal names never overwrite each other.
+    pub fn write(&self, file_name: &str, bytes: &[u8]) -> Result<PathBuf, String> {
+        // Strip any directory components so the name cannot escape the temp root
+        let safe_name = Path::new(file_name)
+            .file_name()
+            .map(|n| n.to_string_lossy().to_string())
+            .filter(|n| !n.is_empty())
+            .unwrap_or_else(|| "attachment".to_string());
+
+        let id = self.counter.fetch_add(1, Ordering::Relaxed);
+        let dir = self.session_dir()?.join(id.to_string());
+
+        create_private_dir(&dir, false)
+            .map_err(|e| format!("Failed to create temp directory: {}", e))?;
+        let path = dir.join(safe_name);
+        std::fs::write(&path, bytes).map_err(|e| format!("Failed to write temp file: {}", e))?;
+
+        Ok(path)
+    }
+
+    /// Session directories of this and earlier sessions
+    fn sessions(&self) -> Vec<PathBuf> {
+        self.root().map(|root| children(&root)).unwrap_or_default()
+    }
+
+    /// The directories of the files of all sessions
+    fn entries(&self) -> Vec<PathBuf> {
+        self.sessions().iter().flat_map(|session| children(session)).collect()
+    }
+
+    /// Remove the session directories left empty, except the one of this session
+    /// while it still has files to take
+    fn remove_empty_sessions(&self, keep_current: bool) {
+        let current = self.session.lock().unwrap().clone();
+        for session in self.sessions() {
+            if !(keep_current && current.as_ref() == Some(&session)) {
+                // Fails for directories that still have files
+                let _ = std::fs::remove_dir(&session);
+            }
+        }
+    }
+
+    /// Remove temp entries older than the retention period. Returns the number removed.
+    pub fn sweep_expired(&self) -> usize {
+        let Some(retention) = self.retention() else {
+            return 0;
+        };
+        let now = SystemTime::now();
+
+        let removed = self
+            .entries()
+            .into_iter()
+            .filter(|path| {
+                std::fs::metadata(path)
+                    .and_then(|m| m.modified())
+                    .ok()
+                    .and_then(|modified| now.duration_since(modified).ok())
+                    .map_or(false, |age| age >= retention)
+            })
+            .filter(|path| remove_entry(path))
+            .count();
+        self.remove_empty_sessions(true);
+        removed
+    }
+
+    /// Remove all tracked temp files and the session directories, as on exit. Files
+    /// still locked by another program are skipped.
+    pub fn clear(&self) -> usize {
+        let removed = self
+            .entries()
+            .into_iter()
+            .filter(|path| remove_entry(path))
+            .count();
+        self.remove_empty_sessions(false);
+        removed
+    }
+}
+
+fn remove_entry(path: &Path) -> bool {
+    let result = if path.is_dir() {
+        std::fs::remove_dir_all(path)
+    } else {
+        std::fs::remove_file(path)
+    };
+
+    if let Err(e) = &result {
+        log_warn!("Failed to remove temp file {:?}: {}", path, e);
+    }
+    result.is_ok()
+}
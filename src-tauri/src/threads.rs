@@ -0,0 +1,217 @@
+use crate::headers;
+use crate::msg;
+use base64::{engine::general_purpose::STANDARD, Engine as _};
+use std::collections::HashMap;
+use std::path::Path;
+
+/// Length of the header block of a Thread-Index or PR_CONVERSATION_INDEX: a reserved
+/// byte, the time of the first message and a GUID, the same for the whole conversation
+const CONVERSATION_INDEX_ROOT: usize = 22;
+
+/// Reply and forward prefixes left out of conversation subjects (English and German
+/// Outlook)
+const SUBJECT_PREFIXES: [&str; 6] = ["re", "fw", "fwd", "aw", "wg", "antw"];
+
+/// An opened message as the frontend describes it for threading
+#[derive(serde::Deserialize)]
+#[serde(rename_all = "camelCase")]
+pub struct ThreadInput {
+    /// Chosen by the frontend to find the message again, returned as is
+    pub key: String,
+    /// Raw transport headers, empty if the message has none
+    #[serde(default)]
+    pub headers: String,
+    /// Message-ID when the headers do not have it (.msg files keep it as a property)
+    #[serde(default)]
+    pub message_id: String,
+    #[serde(default)]
+    pub subject: String,
+    /// Unix time in milliseconds
+    pub date: Option<i64>,
+    /// File the message was opened from; the conversation index of .msg files is read
+    /// from it when the headers have no Thread-Index
+    pub path: Option<String>,
+}
+
+/// Messages of one conversation
+#[derive(serde::Serialize)]
+#[serde(rename_all = "camelCase")]
+pub struct Thread {
+    /// Subject of the first message without Re:/Fwd: prefixes
+    pub subject: String,
+    /// Keys of the messages, oldest first; messages without a date come last
+    pub keys: Vec<String>,
+    /// Date of the newest message, Unix time in milliseconds
+    pub latest: Option<i64>,
+}
+
+/// Disjoint sets of message and reference nodes
+struct UnionFind {
+    parents: Vec<usize>,
+}
+
+impl UnionFind {
+    fn add(&mut self) -> usize {
+        self.parents.push(self.parents.len());
+        self.parents.len() - 1
+    }
+
+    fn find(&mut self, mut node: usize) -> usize {
+        while self.parents[node] != node {
+            self.parents[node] = self.parents[self.parents[node]];
+            node = self.parents[node];
+        }
+        node
+    }
+
+    fn union(&mut self, a: usize, b: usize) {
+        let (a, b) = (self.find(a), self.find(b));
+        if a != b {
+            self.parents[b] = a;
+        }
+    }
+}
+
+/// The `<...>` message ids of a Message-ID, In-Reply-To or References value. Ids are
+/// compared without their angle brackets and case-insensitively, since some clients
+/// change the case of the domain.
+pub(crate) fn message_ids(value: &str) -> Vec<String> {
+    value
+        .split('<')
+        .skip(1)
+        .filter_map(|part| part.split_once('>'))
+        .map(|(id, _)| id.trim().to_lowercase())
+        .filter(|id| !id.is_empty())
+        .collect()
+}
+
+/// The subject without reply and forward prefixes, e.g. `Re: AW: Fwd: Offer` -> `Offer`
+fn conversation_subject(subject: &str) -> String {
+    let mut subject = subject.trim();
+    loop {
+        let Some((prefix, rest)) = subject.split_once(':') else {
+            break;
+        };
+        // `Re[2]:` and `Re(2):` count replies
+        let prefix = prefix.trim().to_lowercase();
+        let word = prefix.trim_end_matches(|c: char| "[]()0123456789".contains(c));
+        if !SUBJECT_PREFIXES.contains(&word) {
+            break;
+        }
+        subject = rest.trim_start();
+    }
+    subject.to_string()
+}
+
+/// Message ids and conversation index root of a message
+struct References {
+    own: Vec<String>,
+    referenced: Vec<String>,
+    conversation: Option<Vec<u8>>,
+}
+
+fn references(message: &ThreadInput) -> References {
+    let (fields, _) = headers::split_message(message.headers.as_bytes());
+    let values = |name: &str| {
+        fields
+            .iter()
+            .filter(|field| field.name == name)
+            .map(|field| field.value())
+            .collect::<Vec<_>>()
+    };
+
+    let mut own: Vec<String> = values("message-id")
+        .iter()
+        .flat_map(|value| message_ids(value))
+        .collect();
+    own.extend(message_ids(&message.message_id));
+    let referenced = ["in-reply-to", "references"]
+        .iter()
+        .flat_map(|&name| values(name))
+        .flat_map(|value| message_ids(&value))
+        .collect();
+
+    let conversation = values("thread-index")
+        .first()
+        .and_then(|value| STANDARD.decode(value.replace(char::is_whitespace, "")).ok())
+        .or_else(|| {
+            let path = Path::new(message.path.as_deref()?);
+            let is_msg = path
+                .extension()
+                .is_some_and(|extension| extension.eq_ignore_ascii_case("msg"));
+            if !is_msg {
+                return None;
+            }
+            msg::conversation_index(path).ok().flatten()
+        })
+        .filter(|index| index.len() >= CONVERSATION_INDEX_ROOT)
+        .map(|index| index[..CONVERSATION_INDEX_ROOT].to_vec());
+
+    References {
+        own,
+        referenced,
+        conversation,
+    }
+}
+
+/// Group messages into conversations: a message joins the conversation of the messages
+/// it names in In-Reply-To or References, of the messages naming it, and of those
+/// sharing the root of its Thread-Index (or PR_CONVERSATION_INDEX of .msg files).
+/// References to messages that are not open still link their replies. Messages without
+/// any of these are conversations of their own; subjects alone do not group messages.
+/// Conversations are ordered newest first.
+pub fn threads(messages: Vec<ThreadInput>) -> Vec<Thread> {
+    let mut sets = UnionFind {
+        parents: Vec::with_capacity(messages.len() * 2),
+    };
+    let nodes: Vec<usize> = messages.iter().map(|_| sets.add()).collect();
+    let mut ids: HashMap<String, usize> = HashMap::new();
+    let mut conversations: HashMap<Vec<u8>, usize> = HashMap::new();
+
+    for (message, &node) in messages.iter().zip(&nodes) {
+        let references = references(message);
+        for id in references.own.into_iter().chain(references.referenced) {
+            let id_node = *ids.entry(id).or_insert_with(|| sets.add());
+            sets.union(id_node, node);
+        }
+        if let Some(root) = references.conversation {
+            let root_node = *conversations.entry(root).or_insert_with(|| sets.add());
+            sets.union(root_node, node);
+        }
+    }
+
+    let mut groups: HashMap<usize, Vec<usize>> = HashMap::new();
+    let mut order = Vec::new();
+    for (index, &node) in nodes.iter().enumerate() {
+        let root = sets.find(node);
+        groups
+            .entry(root)
+            .or_insert_with(|| {
+                order.push(root);
+                Vec::new()
+            })
+            .push(index);
+    }
+
+    let mut threads: Vec<Thread> = order
+        .into_iter()
+        .map(|root| {
+            let mut members = groups.remove(&root).unwrap_or_default();
+            members.sort_by_key(|&index| (messages[index].date.is_none(), messages[index].date));
+            let first = members
+                .iter()
+                .map(|&index| &messages[index].subject)
+                .find(|subject| !subject.trim().is_empty());
+            Thread {
+                subject: first.map(|subject| conversation_subject(subject)).unwrap_or_default(),
+                latest: members.iter().filter_map(|&index| messages[index].date).max(),
+                keys: members
+                    .iter()
+                    .map(|&index| messages[index].key.clone())
+                    .collect(),
+            }
+        })
+        .collect();
+    threads.sort_by(|a, b| b.latest.cmp(&a.latest));
+    threads
+}
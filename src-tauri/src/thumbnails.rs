@@ -0,0 +1,138 @@
+use base64::{engine::general_purpose::STANDARD, Engine as _};
+use image::codecs::jpeg::JpegEncoder;
+use image::codecs::png::PngEncoder;
+use image::metadata::Orientation;
+use image::{DynamicImage, ImageDecoder, ImageReader};
+use sha2::{Digest, Sha256};
+use std::collections::{HashMap, VecDeque};
+use std::io::Cursor;
+use std::sync::Mutex;
+
+/// Thumbnails are small (a few KB each), so the cache can hold those of many messages
+const MAX_CACHE_ENTRIES: usize = 500;
+
+/// Largest edge a thumbnail can be requested with
+pub const MAX_THUMBNAIL_SIZE: u32 = 1024;
+
+const JPEG_QUALITY: u8 = 80;
+
+/// Brands of the `ftyp` box of HEIF files (HEIC photos of iPhones), which cannot be decoded
+const HEIF_BRANDS: [&[u8; 4]; 6] = [b"heic", b"heix", b"heim", b"heis", b"hevc", b"mif1"];
+
+#[derive(serde::Serialize, Clone)]
+#[serde(rename_all = "camelCase")]
+pub struct Thumbnail {
+    /// `data:image/jpeg;base64,...`, or PNG for images with transparency
+    pub data_url: String,
+    pub width: u32,
+    pub height: u32,
+    /// Size of the image after applying its EXIF orientation
+    pub original_width: u32,
+    pub original_height: u32,
+}
+
+/// In-memory cache of thumbnails, keyed by a hash of size and image.
+/// Oldest entries are dropped first once the cache is full.
+pub struct ThumbnailCache {
+    entries: Mutex<(HashMap<String, Thumbnail>, VecDeque<String>)>,
+}
+
+impl ThumbnailCache {
+    pub fn new() -> Self {
+        ThumbnailCache {
+            entries: Mutex::new((HashMap::new(), VecDeque::new())),
+        }
+    }
+
+    pub fn get(&self, key: &str) -> Option<Thumbnail> {
+        self.entries.lock().unwrap().0.get(key).cloned()
+    }
+
+    pub fn insert(&self, key: String, thumbnail: Thumbnail) {
+        let mut guard = self.entries.lock().unwrap();
+        let (map, order) = &mut *guard;
+        if map.insert(key.clone(), thumbnail).is_none() {
+            order.push_back(key);
+        }
+        while order.len() > MAX_CACHE_ENTRIES {
+            if let Some(oldest) = order.pop_front() {
+                map.remove(&oldest);
+            }
+        }
+    }
+}
+
+pub fn cache_key(data: &[u8], size: u32) -> String {
+    let mut hasher = Sha256::new();
+    hasher.update(size.to_be_bytes());
+    hasher.update(data);
+    hasher
+        .finalize()
+        .iter()
+        .map(|byte| format!("{:02x}", byte))
+        .collect()
+}
+
+fn is_heif(data: &[u8]) -> bool {
+    data.len() >= 12 && &data[4..8] == b"ftyp" && HEIF_BRANDS.iter().any(|b| &data[8..12] == *b)
+}
+
+fn decode(data: &[u8]) -> Result<DynamicImage, String> {
+    if is_heif(data) {
+        return Err("HEIC images cannot be decoded".to_string());
+    }
+
+    let mut decoder = ImageReader::new(Cursor::new(data))
+        .with_guessed_format()
+        .map_err(|e| format!("Failed to read image: {}", e))?
+        .into_decoder()
+        .map_err(|e| format!("Unsupported image: {}", e))?;
+    // Photos are usually stored as shot and rotated by their EXIF orientation
+    let orientation = decoder.orientation().unwrap_or(Orientation::NoTransforms);
+    let mut image = DynamicImage::from_decoder(decoder)
+        .map_err(|e| format!("Failed to decode image: {}", e))?;
+    image.apply_orientation(orientation);
+    Ok(image)
+}
+
+/// Downscale an image (JPEG, PNG, GIF, BMP, TIFF or WebP) to fit a `size` x `size`
+/// square, keeping its aspect ratio. Smaller images are not enlarged.
+pub fn generate(data: &[u8], size: u32) -> Result<Thumbnail, String> {
+    if size == 0 || size > MAX_THUMBNAIL_SIZE {
+        return Err(format!(
+            "Thumbnail size must be between 1 and {}",
+            MAX_THUMBNAIL_SIZE
+        ));
+    }
+
+    let image = decode(data)?;
+    let (original_width, original_height) = (image.width(), image.height());
+    let thumbnail = if original_width > size || original_height > size {
+        image.thumbnail(size, size)
+    } else {
+        image
+    };
+
+    let mut encoded = Vec::new();
+    let mime_type = if thumbnail.color().has_alpha() {
+        thumbnail
+            .to_rgba8()
+            .write_with_encoder(PngEncoder::new(&mut encoded))
+            .map_err(|e| format!("Failed to encode thumbnail: {}", e))?;
+        "image/png"
+    } else {
+        thumbnail
+            .to_rgb8()
+            .write_with_encoder(JpegEncoder::new_with_quality(&mut encoded, JPEG_QUALITY))
+            .map_err(|e| format!("Failed to encode thumbnail: {}", e))?;
+        "image/jpeg"
+    };
+
+    Ok(Thumbnail {
+        data_url: format!("data:{};base64,{}", mime_type, STANDARD.encode(&encoded)),
+        width: thumbnail.width(),
+        height: thumbnail.height(),
+        original_width,
+        original_height,
+    })
+}
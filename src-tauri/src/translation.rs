@@ -0,0 +1,270 @@
+use crate::proxy::{self, ProxyConfig};
+use sha2::{Digest, Sha256};
+use std::collections::{HashMap, VecDeque};
+use std::path::Path;
+use std::sync::Mutex;
+use std::time::Duration;
+
+/// Name of the translation configuration file in the app's config directory
+pub const CONFIG_FILE: &str = "translation.json";
+
+const DEEPL_FREE_URL: &str = "https://api-free.deepl.com/v2/translate";
+const REQUEST_TIMEOUT: Duration = Duration::from_secs(30);
+/// Texts longer than this are rejected instead of being sent to the service
+pub const MAX_TEXT_CHARS: usize = 50_000;
+const MAX_CACHE_ENTRIES: usize = 200;
+
+#[derive(serde::Deserialize, serde::Serialize, Clone, Copy, PartialEq, Eq)]
+#[serde(rename_all = "lowercase")]
+pub enum Provider {
+    Deepl,
+    Libretranslate,
+}
+
+/// Translation settings (`<config dir>/translation.json`)
+#[derive(serde::Deserialize)]
+#[serde(rename_all = "camelCase")]
+pub struct TranslationConfig {
+    pub provider: Provider,
+    /// Endpoint URL. Optional for DeepL (defaults to the free API), required for LibreTranslate.
+    #[serde(default)]
+    pub url: Option<String>,
+    /// Never sent to the WebView
+    #[serde(default)]
+    pub api_key: Option<String>,
+    #[serde(default)]
+    pub default_target_lang: Option<String>,
+    #[serde(default = "default_enabled")]
+    pub enabled: bool,
+}
+
+fn default_enabled() -> bool {
+    true
+}
+
+impl TranslationConfig {
+    fn endpoint(&self) -> Result<String, String> {
+        match (self.provider, self.url.as_deref()) {
+            (Provider::Deepl, None) => Ok(DEEPL_FREE_URL.to_string()),
+            (Provider::Deepl, Some(url)) => Ok(url.to_string()),
+            // LibreTranslate configs usually point at the server root
+            (Provider::Libretranslate, Some(url)) => {
+                let root = url.trim_end_matches('/');
+                if root.ends_with("/translate") {
+                    Ok(root.to_string())
+                } else {
+                    Ok(format!("{}/translate", root))
+                }
+            }
+            (Provider::Libretranslate, None) => {
+                Err("LibreTranslate requires a url in translation.json".to_string())
+            }
+        }
+    }
+}
+
+/// Load the translation configuration. Returns None if translation is not configured.
+pub fn load(config_path: &Path) -> Result<Option<TranslationConfig>, String> {
+    match std::fs::read_to_string(config_path) {
+        Ok(content) => {
+            let config: TranslationConfig = serde_json::from_str(&content)
+                .map_err(|e| format!("Invalid {}: {}", CONFIG_FILE, e))?;
+            if let Some(url) = &config.url {
+                if !url.starts_with("https://") && !url.starts_with("http://") {
+                    return Err(format!("Translation URL must use http or https: {}", url));
+                }
+            }
+            if config.provider == Provider::Deepl && config.api_key.is_none() {
+                return Err("DeepL requires an apiKey in translation.json".to_string());
+            }
+            Ok(Some(config).filter(|c| c.enabled))
+        }
+        Err(e) if e.kind() == std::io::ErrorKind::NotFound => Ok(None),
+        Err(e) => Err(format!("Failed to read {}: {}", CONFIG_FILE, e)),
+    }
+}
+
+/// What the frontend may know about the configuration (no URL, no key)
+#[derive(serde::Serialize)]
+#[serde(rename_all = "camelCase")]
+pub struct TranslationStatus {
+    pub available: bool,
+    pub provider: Option<Provider>,
+    pub default_target_lang: Option<String>,
+}
+
+impl TranslationStatus {
+    pub fn from_config(config: Option<&TranslationConfig>) -> Self {
+        TranslationStatus {
+            available: config.is_some(),
+            provider: config.map(|c| c.provider),
+            default_target_lang: config.and_then(|c| c.default_target_lang.clone()),
+        }
+    }
+}
+
+#[derive(serde::Serialize, Clone)]
+#[serde(rename_all = "camelCase")]
+pub struct Translation {
+    pub text: String,
+    /// Lowercase language code reported by the service, if any
+    pub detected_source_lang: Option<String>,
+}
+
+/// In-memory cache of translations, keyed by a hash of provider, languages and text.
+/// Oldest entries are dropped first once the cache is full.
+pub struct TranslationCache {
+    entries: Mutex<(HashMap<String, Translation>, VecDeque<String>)>,
+}
+
+impl TranslationCache {
+    pub fn new() -> Self {
+        TranslationCache {
+            entries: Mutex::new((HashMap::new(), VecDeque::new())),
+        }
+    }
+
+    pub fn get(&self, key: &str) -> Option<Translation> {
+        self.entries.lock().unwrap().0.get(key).cloned()
+    }
+
+    pub fn insert(&self, key: String, translation: Translation) {
+        let mut guard = self.entries.lock().unwrap();
+        let (map, order) = &mut *guard;
+        if map.insert(key.clone(), translation).is_none() {
+            order.push_back(key);
+        }
+        while order.len() > MAX_CACHE_ENTRIES {
+            if let Some(oldest) = order.pop_front() {
+                map.remove(&oldest);
+            }
+        }
+    }
+}
+
+/// Cache key for a request. Hashing keeps message text out of the key set.
+pub fn cache_key(
+    config: &TranslationConfig,
+    text: &str,
+    target_lang: &str,
+    source_lang: Option<&str>,
+) -> String {
+    let mut hasher = Sha256::new();
+    let provider = match config.provider {
+        Provider::Deepl => "deepl",
+        Provider::Libretranslate => "libretranslate",
+    };
+    hasher.update(format!("{}\0{}\0{}\0", provider, target_lang, source_lang.unwrap_or("")));
+    hasher.update(text.as_bytes());
+    hasher.finalize().iter().map(|byte| format!("{:02x}", byte)).collect()
+}
+
+#[derive(serde::Deserialize)]
+struct DeeplResponse {
+    translations: Vec<DeeplTranslation>,
+}
+
+#[derive(serde::Deserialize)]
+struct DeeplTranslation {
+    text: String,
+    #[serde(default)]
+    detected_source_language: Option<String>,
+}
+
+#[derive(serde::Deserialize)]
+#[serde(rename_all = "camelCase")]
+struct LibreResponse {
+    translated_text: String,
+    #[serde(default)]
+    detected_language: Option<LibreDetectedLanguage>,
+}
+
+#[derive(serde::Deserialize)]
+struct LibreDetectedLanguage {
+    language: String,
+}
+
+/// LibreTranslate only knows primary language codes ("en", not "en-gb")
+fn primary_language(lang: &str) -> String {
+    lang.split('-').next().unwrap_or(lang).to_lowercase()
+}
+
+fn request_error(error: ureq::Error) -> String {
+    match error {
+        ureq::Error::Status(403, _) | ureq::Error::Status(401, _) => {
+            "Translation service rejected the API key".to_string()
+        }
+        ureq::Error::Status(456, _) => "Translation quota exceeded".to_string(),
+        ureq::Error::Status(code, _) => format!("Translation service returned HTTP {}", code),
+        e => format!("Translation request failed: {}", e),
+    }
+}
+
+/// Translate plain text with the configured service
+pub fn translate(
+    config: &TranslationConfig,
+    proxy: &ProxyConfig,
+    text: &str,
+    target_lang: &str,
+    source_lang: Option<&str>,
+) -> Result<Translation, String> {
+    if text.chars().count() > MAX_TEXT_CHARS {
+        return Err(format!("Text is too long to translate (max {} characters)", MAX_TEXT_CHARS));
+    }
+
+    let endpoint = config.endpoint()?;
+    let agent = proxy::agent_for(proxy, &endpoint, REQUEST_TIMEOUT)?;
+    let request = agent
+        .post(&endpoint)
+        .set("User-Agent", concat!("msgReader/", env!("CARGO_PKG_VERSION")));
+
+    match config.provider {
+        Provider::Deepl => {
+            let mut body = serde_json::json!({
+                "text": [text],
+                "target_lang": target_lang.to_uppercase(),
+            });
+            if let Some(source) = source_lang {
+                body["source_lang"] = source.to_uppercase().into();
+            }
+            let response: DeeplResponse = request
+                .set(
+                    "Authorization",
+                    &format!("DeepL-Auth-Key {}", config.api_key.as_deref().unwrap_or("")),
+                )
+                .send_json(body)
+                .map_err(request_error)?
+                .into_json()
+                .map_err(|e| format!("Invalid translation response: {}", e))?;
+            let first = response
+                .translations
+                .into_iter()
+                .next()
+                .ok_or_else(|| "Translation service returned no text".to_string())?;
+            Ok(Translation {
+                text: first.text,
+                detected_source_lang: first.detected_source_language.map(|l| l.to_lowercase()),
+            })
+        }
+        Provider::Libretranslate => {
+            let mut body = serde_json::json!({
+                "q": text,
+                "source": primary_language(source_lang.unwrap_or("auto")),
+                "target": primary_language(target_lang),
+                "format": "text",
+            });
+            if let Some(key) = config.api_key.as_deref() {
+                body["api_key"] = key.into();
+            }
+            let response: LibreResponse = request
+                .send_json(body)
+                .map_err(request_error)?
+                .into_json()
+                .map_err(|e| format!("Invalid translation response: {}", e))?;
+            Ok(Translation {
+                text: response.translated_text,
+                detected_source_lang: response.detected_language.map(|d| d.language.to_lowercase()),
+            })
+        }
+    }
+}
@@ -0,0 +1,142 @@
+use crate::locale;
+use crate::recent_files::RecentFile;
+use std::path::{Path, PathBuf};
+use tauri::menu::{CheckMenuItem, IsMenuItem, Menu, MenuItem, PredefinedMenuItem, Submenu};
+use tauri::tray::{MouseButton, MouseButtonState, TrayIconBuilder, TrayIconEvent};
+use tauri::{AppHandle, Manager, Wry};
+
+const TRAY_ID: &str = "main";
+
+/// Menu item id of a recent file, followed by its path
+const RECENT_PREFIX: &str = "recent:";
+
+/// Most recent files in the submenu
+const RECENT_LIMIT: usize = 10;
+
+/// A chosen tray menu item (or a click on the icon, which shows the window)
+pub enum Action {
+    Show,
+    OpenFile,
+    OpenRecent(PathBuf),
+    ToggleWatching,
+    Quit,
+}
+
+/// What the tray menu lists
+pub struct TrayMenu<'a> {
+    pub recent_files: &'a [RecentFile],
+    /// Whether any folder is watched; the toggle is disabled without one
+    pub has_watched_folders: bool,
+    pub watching: bool,
+}
+
+fn action(id: &str) -> Option<Action> {
+    if let Some(path) = id.strip_prefix(RECENT_PREFIX) {
+        return Some(Action::OpenRecent(PathBuf::from(path)));
+    }
+    match id {
+        "show" => Some(Action::Show),
+        "open-file" => Some(Action::OpenFile),
+        "watch-folders" => Some(Action::ToggleWatching),
+        "quit" => Some(Action::Quit),
+        _ => None,
+    }
+}
+
+fn build_menu(app: &AppHandle, menu: &TrayMenu) -> tauri::Result<Menu<Wry>> {
+    let language = locale::system_locale();
+    let text = |key: &str| locale::text(&language, key);
+
+    let recent_items = menu
+        .recent_files
+        .iter()
+        .take(RECENT_LIMIT)
+        .map(|file| {
+            let name = Path::new(&file.path)
+                .file_name()
+                .map_or(file.path.clone(), |name| name.to_string_lossy().to_string());
+            let id = format!("{}{}", RECENT_PREFIX, file.path);
+            MenuItem::with_id(app, id, name, true, None::<&str>)
+        })
+        .collect::<tauri::Result<Vec<_>>>()?;
+    let recent = if recent_items.is_empty() {
+        let none = MenuItem::new(app, text("tray.noRecentFiles"), false, None::<&str>)?;
+        Submenu::with_items(app, text("tray.recentFiles"), true, &[&none])?
+    } else {
+        let items: Vec<&dyn IsMenuItem<Wry>> =
+            recent_items.iter().map(|item| item as &dyn IsMenuItem<Wry>).collect();
+        Submenu::with_items(app, text("tray.recentFiles"), true, &items)?
+    };
+
+    Menu::with_items(
+        app,
+        &[
+            &MenuItem::with_id(app, "show", text("tray.show"), true, None::<&str>)?,
+            &PredefinedMenuItem::separator(app)?,
+            &MenuItem::with_id(app, "open-file", text("tray.openFile"), true, None::<&str>)?,
+            &recent,
+            &CheckMenuItem::with_id(
+                app,
+                "watch-folders",
+                text("tray.watchFolders"),
+                menu.has_watched_folders,
+                menu.watching,
+                None::<&str>,
+            )?,
+            &PredefinedMenuItem::separator(app)?,
+            &MenuItem::with_id(app, "quit", text("tray.quit"), true, None::<&str>)?,
+        ],
+    )
+}
+
+/// Show the tray icon (menu bar icon on macOS) with a menu, or update the menu of the
+/// icon already shown. `on_action` is called with the chosen item.
+pub fn show(
+    app: &AppHandle,
+    menu: &TrayMenu,
+    on_action: fn(&AppHandle, Action),
+) -> Result<(), String> {
+    let built = build_menu(app, menu).map_err(|e| format!("Failed to build tray menu: {}", e))?;
+    if let Some(tray) = app.tray_by_id(TRAY_ID) {
+        return tray
+            .set_menu(Some(built))
+            .map_err(|e| format!("Failed to update tray menu: {}", e));
+    }
+
+    let mut builder = TrayIconBuilder::with_id(TRAY_ID)
+        .tooltip(app.package_info().name.as_str())
+        .menu(&built)
+        // The menu opens with a right click; a left click shows the window (Windows, macOS)
+        .show_menu_on_left_click(false)
+        .on_menu_event(move |app, event| {
+            if let Some(action) = action(event.id().as_ref()) {
+                on_action(app, action);
+            }
+        })
+        .on_tray_icon_event(move |tray, event| {
+            if let TrayIconEvent::Click {
+                button: MouseButton::Left,
+                button_state: MouseButtonState::Up,
+                ..
+            } = event
+            {
+                on_action(tray.app_handle(), Action::Show);
+            }
+        });
+    if let Some(icon) = app.default_window_icon() {
+        builder = builder.icon(icon.clone());
+    }
+    builder
+        .build(app)
+        .map(|_| ())
+        .map_err(|e| format!("Failed to show tray icon: {}", e))
+}
+
+pub fn is_shown(app: &AppHandle) -> bool {
+    app.tray_by_id(TRAY_ID).is_some()
+}
+
+/// Remove the tray icon if it is shown
+pub fn hide(app: &AppHandle) {
+    app.remove_tray_by_id(TRAY_ID);
+}
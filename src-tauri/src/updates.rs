@@ -0,0 +1,132 @@
+use sha2::{Digest, Sha256};
+use std::sync::Mutex;
+use tauri::{AppHandle, Emitter, Url};
+use tauri_plugin_updater::{Update, UpdaterExt};
+
+/// Emitted while an update downloads, with a `DownloadProgress`
+pub const PROGRESS_EVENT: &str = "update-download-progress";
+
+/// Host of the updater endpoint configured in tauri.conf.json, for the proxy lookup
+pub const UPDATE_HOST_URL: &str = "https://github.com/";
+
+/// A newer release found by `check`
+#[derive(serde::Serialize, Clone)]
+#[serde(rename_all = "camelCase")]
+pub struct UpdateInfo {
+    pub version: String,
+    pub current_version: String,
+    /// Release date as given in the release manifest
+    pub date: Option<String>,
+    /// Release notes
+    pub notes: String,
+}
+
+#[derive(serde::Serialize, Clone)]
+#[serde(rename_all = "camelCase")]
+pub struct DownloadProgress {
+    pub downloaded: u64,
+    /// Size of the download, None if the server does not send it
+    pub total: Option<u64>,
+}
+
+/// A downloaded update whose signature has been verified
+#[derive(serde::Serialize)]
+#[serde(rename_all = "camelCase")]
+pub struct DownloadedUpdate {
+    pub version: String,
+    pub size: usize,
+    /// SHA-256 of the package, for comparing with the release page
+    pub sha256: String,
+}
+
+/// Update checks against the GitHub releases, downloads and installation.
+/// A downloaded update is installed when the app exits unless it was installed earlier,
+/// so it is applied on the next start.
+pub struct Updates {
+    available: Mutex<Option<Update>>,
+    downloaded: Mutex<Option<(Update, Vec<u8>)>>,
+}
+
+impl Updates {
+    pub fn new() -> Self {
+        Updates {
+            available: Mutex::new(None),
+            downloaded: Mutex::new(None),
+        }
+    }
+
+    /// Ask the release endpoint for a version newer than the running one
+    pub async fn check(
+        &self,
+        app: &AppHandle,
+        proxy: Option<String>,
+    ) -> Result<Option<UpdateInfo>, String> {
+        let mut builder = app.updater_builder();
+        if let Some(proxy) = proxy {
+            let proxy = Url::parse(&proxy).map_err(|e| format!("Invalid proxy: {}", e))?;
+            builder = builder.proxy(proxy);
+        }
+        let update = builder
+            .build()
+            .map_err(|e| format!("Failed to set up the updater: {}", e))?
+            .check()
+            .await
+            .map_err(|e| format!("Update check failed: {}", e))?;
+
+        let info = update.as_ref().map(|update| UpdateInfo {
+            version: update.version.clone(),
+            current_version: update.current_version.clone(),
+            date: update.date.map(|date| date.to_string()),
+            notes: update.body.clone().unwrap_or_default(),
+        });
+        *self.available.lock().unwrap() = update;
+        Ok(info)
+    }
+
+    /// Download the update found by the last check, emitting `PROGRESS_EVENT`. The
+    /// package is only kept if its signature matches the updater key of the app.
+    pub async fn download(&self, app: &AppHandle) -> Result<DownloadedUpdate, String> {
+        let update = self
+            .available
+            .lock()
+            .unwrap()
+            .clone()
+            .ok_or_else(|| "No update available, check for updates first".to_string())?;
+
+        let mut downloaded = 0u64;
+        let bytes = update
+            .download(
+                |chunk, total| {
+                    downloaded += chunk as u64;
+                    let _ = app.emit(PROGRESS_EVENT, DownloadProgress { downloaded, total });
+                },
+                || {},
+            )
+            .await
+            .map_err(|e| format!("Update download failed: {}", e))?;
+
+        let result = DownloadedUpdate {
+            version: update.version.clone(),
+            size: bytes.len(),
+            sha256: Sha256::digest(&bytes)
+                .iter()
+                .map(|byte| format!("{:02x}", byte))
+                .collect(),
+        };
+        *self.downloaded.lock().unwrap() = Some((update, bytes));
+        Ok(result)
+    }
+
+    /// Install a downloaded update. On Windows the installer takes over and the app exits;
+    /// elsewhere the new version starts with the next launch. Returns false if no update
+    /// was downloaded.
+    pub fn install(&self) -> Result<bool, String> {
+        let Some((update, bytes)) = self.downloaded.lock().unwrap().take() else {
+            return Ok(false);
+        };
+        update
+            .install(bytes)
+            .map_err(|e| format!("Failed to install update {}: {}", update.version, e))?;
+        Ok(true)
+    }
+}
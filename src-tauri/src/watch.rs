@@ -0,0 +1,195 @@
+use crate::file_access::FileAccess;
+use crate::message_window::MAIN_LABEL;
+use crate::notifications::{self, Notifications};
+use notify::event::{EventKind, ModifyKind};
+use notify::{RecommendedWatcher, RecursiveMode, Watcher};
+use std::collections::HashSet;
+use std::path::{Path, PathBuf};
+use std::sync::atomic::{AtomicBool, Ordering};
+use std::sync::{Arc, Mutex};
+use std::time::Duration;
+use tauri::{AppHandle, Emitter, Manager};
+
+/// Emitted with the path of a new .msg/.eml file in a watched folder
+pub const FILE_EVENT: &str = "watched-file";
+
+/// Name of the list of watched folders in the app's config directory
+const LIST_NAME: &str = "watched-folders.json";
+
+/// How often and how long to wait for a new file to stop growing
+const SETTLE_INTERVAL: Duration = Duration::from_millis(500);
+const SETTLE_ATTEMPTS: u32 = 120;
+
+/// Watches folders for new email files, e.g. the export folder of a scanner or
+/// journaling system. Files are reported once they have stopped growing.
+pub struct FolderWatcher {
+    watcher: Mutex<Option<RecommendedWatcher>>,
+    folders: Mutex<Vec<PathBuf>>,
+    /// While paused, the folders stay watched but new files are not reported
+    paused: Arc<AtomicBool>,
+    /// Files waiting to settle, so repeated events for the same file are ignored
+    pending: Arc<Mutex<HashSet<PathBuf>>>,
+}
+
+/// Location of the list of folders the user chose to watch, kept by the backend so that
+/// only those are granted again after a restart (None for the default profile)
+pub fn list_path(config_dir: &Path, profile: Option<&str>) -> PathBuf {
+    match profile {
+        Some(profile) => config_dir.join(format!("watched-folders-{}.json", profile)),
+        None => config_dir.join(LIST_NAME),
+    }
+}
+
+/// The folders of the list; a damaged list is started over
+pub fn load_list(list_path: &Path) -> Vec<PathBuf> {
+    match std::fs::read_to_string(list_path) {
+        Ok(content) => serde_json::from_str(&content).unwrap_or_else(|e| {
+            log_warn!("Ignoring invalid {}: {}", LIST_NAME, e);
+            Vec::new()
+        }),
+        Err(_) => Vec::new(),
+    }
+}
+
+pub fn save_list(list_path: &Path, folders: &[PathBuf]) -> Result<(), String> {
+    if let Some(dir) = list_path.parent() {
+        std::fs::create_dir_all(dir)
+            .map_err(|e| format!("Failed to create config directory: {}", e))?;
+    }
+    let content = serde_json::to_string_pretty(folders)
+        .map_err(|e| format!("Failed to serialize {}: {}", LIST_NAME, e))?;
+    std::fs::write(list_path, content).map_err(|e| format!("Failed to write {}: {}", LIST_NAME, e))
+}
+
+fn is_email_file(path: &Path) -> bool {
+    let ext = path
+        .extension()
+        .and_then(|e| e.to_str())
+        .map(|e| e.to_lowercase());
+    matches!(ext.as_deref(), Some("msg") | Some("eml"))
+}
+
+/// Wait until the file size stays the same and the file can be opened (Windows keeps
+/// it locked while it is written). False if the file went away or never settled.
+fn wait_until_complete(path: &Path) -> bool {
+    let mut last_len = None;
+    for _ in 0..SETTLE_ATTEMPTS {
+        std::thread::sleep(SETTLE_INTERVAL);
+        let Ok(metadata) = std::fs::metadata(path) else {
+            return false;
+        };
+        let len = metadata.len();
+        if len > 0 && last_len == Some(len) && std::fs::File::open(path).is_ok() {
+            return true;
+        }
+        last_len = Some(len);
+    }
+    false
+}
+
+impl FolderWatcher {
+    pub fn new() -> Self {
+        FolderWatcher {
+            watcher: Mutex::new(None),
+            folders: Mutex::new(Vec::new()),
+            paused: Arc::new(AtomicBool::new(false)),
+            pending: Arc::new(Mutex::new(HashSet::new())),
+        }
+    }
+
+    fn create_watcher(&self, app: &AppHandle) -> Result<RecommendedWatcher, String> {
+        let app = app.clone();
+        let pending = self.pending.clone();
+        let paused = self.paused.clone();
+        notify::recommended_watcher(move |result: notify::Result<notify::Event>| {
+            let Ok(event) = result else {
+                return;
+            };
+            if paused.load(Ordering::Relaxed) {
+                return;
+            }
+            // New files and files moved into the folder
+            if !matches!(event.kind, EventKind::Create(_) | EventKind::Modify(ModifyKind::Name(_))) {
+                return;
+            }
+            for path in event.paths {
+                if !is_email_file(&path) || !path.is_file() {
+                    continue;
+                }
+                if !pending.lock().unwrap().insert(path.clone()) {
+                    continue;
+                }
+                let app = app.clone();
+                let pending = pending.clone();
+                std::thread::spawn(move || {
+                    if wait_until_complete(&path) {
+                        app.state::<FileAccess>().grant(&path);
+                        let payload = path.to_string_lossy().to_string();
+                        if let Err(e) = app.emit_to(MAIN_LABEL, FILE_EVENT, payload) {
+                            log_warn!("Failed to emit {} event: {}", FILE_EVENT, e);
+                        }
+                        app.state::<Notifications>().show(&app, notifications::new_message(&path));
+                    }
+                    pending.lock().unwrap().remove(&path);
+                });
+            }
+        })
+        .map_err(|e| format!("Failed to start folder watcher: {}", e))
+    }
+
+    /// Start watching a folder (not its subfolders). Watching a folder twice is a no-op.
+    pub fn watch(&self, app: &AppHandle, folder: &Path) -> Result<(), String> {
+        if !folder.is_dir() {
+            return Err(format!("Not a folder: {}", folder.display()));
+        }
+
+        let mut folders = self.folders.lock().unwrap();
+        if folders.iter().any(|watched| watched == folder) {
+            return Ok(());
+        }
+
+        let mut watcher = self.watcher.lock().unwrap();
+        if watcher.is_none() {
+            *watcher = Some(self.create_watcher(app)?);
+        }
+        watcher
+            .as_mut()
+            .unwrap()
+            .watch(folder, RecursiveMode::NonRecursive)
+            .map_err(|e| format!("Failed to watch {}: {}", folder.display(), e))?;
+        folders.push(folder.to_path_buf());
+        Ok(())
+    }
+
+    pub fn unwatch(&self, folder: &Path) -> Result<(), String> {
+        let mut folders = self.folders.lock().unwrap();
+        let Some(position) = folders.iter().position(|watched| watched == folder) else {
+            return Ok(());
+        };
+        if let Some(watcher) = self.watcher.lock().unwrap().as_mut() {
+            watcher
+                .unwatch(folder)
+                .map_err(|e| format!("Failed to stop watching {}: {}", folder.display(), e))?;
+        }
+        folders.remove(position);
+        Ok(())
+    }
+
+    /// Stop or resume reporting new files without forgetting the folders
+    pub fn set_paused(&self, paused: bool) {
+        self.paused.store(paused, Ordering::Relaxed);
+    }
+
+    pub fn is_paused(&self) -> bool {
+        self.paused.load(Ordering::Relaxed)
+    }
+
+    pub fn folders(&self) -> Vec<String> {
+        self.folders
+            .lock()
+            .unwrap()
+            .iter()
+            .map(|folder| folder.to_string_lossy().to_string())
+            .collect()
+    }
+}
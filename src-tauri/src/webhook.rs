@@ -0,0 +1,103 @@
+use hmac::{Hmac, Mac};
+use crate::proxy::{self, ProxyConfig};
+use sha2::Sha256;
+use std::path::Path;
+use std::time::{Duration, SystemTime, UNIX_EPOCH};
+
+/// Name of the webhook configuration file in the app's config directory
+pub const CONFIG_FILE: &str = "webhook.json";
+
+const DEFAULT_MAX_RETRIES: u32 = 3;
+const REQUEST_TIMEOUT: Duration = Duration::from_secs(15);
+
+/// Webhook settings (`<config dir>/webhook.json`)
+#[derive(serde::Deserialize)]
+#[serde(rename_all = "camelCase")]
+pub struct WebhookConfig {
+    pub url: String,
+    /// Shared secret used to sign the request body (HMAC-SHA256)
+    #[serde(default)]
+    pub secret: Option<String>,
+    #[serde(default = "default_max_retries")]
+    pub max_retries: u32,
+    #[serde(default = "default_enabled")]
+    pub enabled: bool,
+}
+
+fn default_max_retries() -> u32 {
+    DEFAULT_MAX_RETRIES
+}
+
+fn default_enabled() -> bool {
+    true
+}
+
+/// Load the webhook configuration. Returns None if no webhook is configured.
+pub fn load(config_path: &Path) -> Result<Option<WebhookConfig>, String> {
+    match std::fs::read_to_string(config_path) {
+        Ok(content) => {
+            let config: WebhookConfig = serde_json::from_str(&content)
+                .map_err(|e| format!("Invalid {}: {}", CONFIG_FILE, e))?;
+            if !config.url.starts_with("https://") && !config.url.starts_with("http://") {
+                return Err(format!("Webhook URL must use http or https: {}", config.url));
+            }
+            Ok(Some(config).filter(|c| c.enabled))
+        }
+        Err(e) if e.kind() == std::io::ErrorKind::NotFound => Ok(None),
+        Err(e) => Err(format!("Failed to read {}: {}", CONFIG_FILE, e)),
+    }
+}
+
+/// Compute the `sha256=<hex>` signature of `timestamp.body`
+pub fn sign(secret: &str, timestamp: u64, body: &str) -> String {
+    let mut mac = Hmac::<Sha256>::new_from_slice(secret.as_bytes())
+        .expect("HMAC accepts keys of any length");
+    mac.update(format!("{}.{}", timestamp, body).as_bytes());
+    let digest = mac.finalize().into_bytes();
+    let hex: String = digest.iter().map(|byte| format!("{:02x}", byte)).collect();
+    format!("sha256={}", hex)
+}
+
+/// POST the JSON body to the webhook, retrying network errors, 429 and 5xx responses
+/// with exponential backoff. Returns the number of attempts made.
+pub fn deliver(config: &WebhookConfig, proxy: &ProxyConfig, body: &str) -> Result<u32, String> {
+    let agent = proxy::agent_for(proxy, &config.url, REQUEST_TIMEOUT)?;
+    let mut last_error = String::new();
+
+    for attempt in 0..=config.max_retries {
+        if attempt > 0 {
+            std::thread::sleep(Duration::from_secs(1 << (attempt - 1).min(5)));
+        }
+
+        let timestamp = SystemTime::now()
+            .duration_since(UNIX_EPOCH)
+            .map(|d| d.as_secs())
+            .unwrap_or(0);
+        let mut request = agent
+            .post(&config.url)
+            .set("Content-Type", "application/json")
+            .set("User-Agent", concat!("msgReader/", env!("CARGO_PKG_VERSION")))
+            .set("X-MsgReader-Timestamp", &timestamp.to_string());
+        if let Some(secret) = config.secret.as_deref().filter(|s| !s.is_empty()) {
+            request = request.set("X-MsgReader-Signature", &sign(secret, timestamp, body));
+        }
+
+        match request.send_string(body) {
+            Ok(_) => return Ok(attempt + 1),
+            Err(ureq::Error::Status(code, _)) if code == 429 || code >= 500 => {
+                last_error = format!("HTTP {}", code);
+            }
+            Err(ureq::Error::Status(code, _)) => {
+                // Client errors will not succeed on retry
+                return Err(format!("Webhook rejected the notification: HTTP {}", code));
+            }
+            Err(e) => last_error = e.to_string(),
+        }
+    }
+
+    Err(format!(
+        "Webhook delivery failed after {} attempts: {}",
+        config.max_retries + 1,
+        last_error
+    ))
+}
@@ -0,0 +1,445 @@
+use crate::headers::CFB_SIGNATURE;
+use crate::message::Message;
+use crate::{attachments, calendar, cli, eml, msg, pdf};
+use sha2::{Digest, Sha256};
+use std::collections::HashSet;
+use std::fs::File;
+use std::io::{BufWriter, Write};
+use std::path::{Path, PathBuf};
+use std::time::{SystemTime, UNIX_EPOCH};
+use zip::write::SimpleFileOptions;
+use zip::{CompressionMethod, ZipWriter};
+
+/// Entry formats: the file as it was opened, or converted
+pub const FORMATS: [&str; 3] = ["original", "eml", "pdf"];
+
+/// Name of the manifest at the root of the ZIP
+const MANIFEST_NAME: &str = "manifest.json";
+/// Folder of the messages in the ZIP, as in the ZIPs the frontend writes
+const EMAILS_FOLDER: &str = "emails/";
+
+/// A message to export, by where the frontend opened it from
+#[derive(serde::Deserialize)]
+#[serde(rename_all = "camelCase")]
+pub struct ZipItem {
+    /// File path, `archive#<id>` or `<pst or mbox file>#<id>`
+    pub source: String,
+    /// Name of the entry in the ZIP; the extension is replaced for converted formats
+    pub file_name: String,
+    /// Hash the frontend identifies the message by, recorded in the manifest
+    #[serde(default)]
+    pub message_hash: String,
+    /// Subject as shown in the frontend, recorded if the message is left out
+    #[serde(default)]
+    pub subject: String,
+}
+
+#[derive(serde::Serialize)]
+struct Checksum {
+    size: u64,
+    sha256: String,
+}
+
+/// A message in the manifest, with the fields of the manifests the frontend writes so
+/// that "Verify exported ZIP" checks both alike
+#[derive(serde::Serialize)]
+#[serde(rename_all = "camelCase")]
+struct ManifestEntry {
+    /// Name in the emails/ folder
+    file_name: String,
+    mime_type: &'static str,
+    /// None if checksums are turned off
+    #[serde(flatten)]
+    checksum: Option<Checksum>,
+    subject: String,
+    sender_name: String,
+    sender_email: String,
+    source_file_name: String,
+    message_delivery_time: String,
+    message_hash: String,
+}
+
+#[derive(serde::Serialize)]
+#[serde(rename_all = "camelCase")]
+struct ManifestSkipped {
+    subject: String,
+    source_file_name: String,
+    message_hash: String,
+    reason: String,
+}
+
+/// A message left out of the ZIP
+#[derive(serde::Serialize)]
+#[serde(rename_all = "camelCase")]
+pub struct ZipSkipped {
+    pub source: String,
+    pub file_name: String,
+    pub reason: String,
+}
+
+#[derive(serde::Serialize)]
+#[serde(rename_all = "camelCase")]
+struct Manifest<'a> {
+    generated_at: String,
+    format: &'a str,
+    scope: &'a str,
+    message_count: usize,
+    exported_count: usize,
+    skipped_count: usize,
+    messages: &'a [ManifestEntry],
+    skipped_messages: &'a [ManifestSkipped],
+}
+
+/// How to write a ZIP
+pub struct ZipOptions<'a> {
+    /// One of FORMATS
+    pub format: &'a str,
+    /// What the frontend exported, e.g. "selected", recorded in the manifest
+    pub scope: &'a str,
+    /// Record the size and SHA-256 of every entry in the manifest
+    pub checksums: bool,
+}
+
+/// Result of an export
+#[derive(serde::Serialize)]
+#[serde(rename_all = "camelCase")]
+pub struct ZipExport {
+    pub path: String,
+    pub exported_count: usize,
+    pub skipped: Vec<ZipSkipped>,
+}
+
+/// `2024-05-01T09:30:00Z`
+pub(crate) fn iso_time(ms: i64) -> String {
+    let (year, month, day) = calendar::civil_from_days(ms.div_euclid(86_400_000));
+    let seconds = ms.rem_euclid(86_400_000) / 1000;
+    format!(
+        "{:04}-{:02}-{:02}T{:02}:{:02}:{:02}Z",
+        year,
+        month,
+        day,
+        seconds / 3600,
+        seconds / 60 % 60,
+        seconds % 60
+    )
+}
+
+fn parse(data: &[u8]) -> Result<Message, String> {
+    if data.starts_with(&CFB_SIGNATURE) {
+        msg::parse_bytes(data)
+    } else {
+        eml::parse_bytes(data)
+    }
+}
+
+fn with_extension(file_name: &str, extension: &str) -> String {
+    let stem = Path::new(file_name)
+        .file_stem()
+        .map(|stem| stem.to_string_lossy().to_string())
+        .filter(|stem| !stem.is_empty())
+        .unwrap_or_else(|| "message".to_string());
+    format!("{}.{}", stem, extension)
+}
+
+/// A message converted for the ZIP
+struct Entry {
+    name: String,
+    mime_type: &'static str,
+    bytes: Vec<u8>,
+    /// The parsed message for the manifest, None for an original file that cannot be
+    /// parsed
+    message: Option<Message>,
+}
+
+/// The entry of a message in a format. Original files are exported even if they cannot
+/// be parsed.
+fn convert(data: Vec<u8>, file_name: &str, format: &str) -> Result<Entry, String> {
+    if format == "original" {
+        let mime_type = if data.starts_with(&CFB_SIGNATURE) {
+            "application/vnd.ms-outlook"
+        } else {
+            "message/rfc822"
+        };
+        let message = parse(&data).ok();
+        return Ok(Entry {
+            name: file_name.to_string(),
+            mime_type,
+            bytes: data,
+            message,
+        });
+    }
+    let message = parse(&data)?;
+    let (mime_type, bytes) = match format {
+        // An .eml file is passed through unchanged
+        "eml" if !data.starts_with(&CFB_SIGNATURE) => ("message/rfc822", data),
+        "eml" => ("message/rfc822", eml::write(&message)),
+        _ => (
+            "application/pdf",
+            pdf::text_document(&message.subject, &cli::message_text(&message)),
+        ),
+    };
+    Ok(Entry {
+        name: with_extension(file_name, format),
+        mime_type,
+        bytes,
+        message: Some(message),
+    })
+}
+
+/// Name not used by an earlier entry, numbered like saved attachments
+fn unique_name(name: &str, used: &mut HashSet<String>) -> String {
+    let name = attachments::safe_file_name(name);
+    let mut number = 0;
+    loop {
+        let candidate = attachments::numbered_name(&name, number);
+        // ZIP tools on Windows and macOS treat names case-insensitively
+        if used.insert(candidate.to_lowercase()) {
+            return candidate;
+        }
+        number += 1;
+    }
+}
+
+/// Write messages into the `emails/` folder of a ZIP at `destination`, with a
+/// `manifest.json` listing every entry, with its SHA-256 if `options.checksums` is set,
+/// and every message left out. The layout and manifest are those of the ZIPs the
+/// frontend writes. Messages are read, converted and compressed one at a time and
+/// written straight to disk, so the size of a selection is only limited by the disk.
+/// The ZIP is written under a temporary name and renamed when complete; a message that
+/// cannot be read or converted is skipped, not fatal.
+pub fn export(
+    items: &[ZipItem],
+    options: &ZipOptions,
+    destination: &Path,
+    read: impl Fn(&str) -> Result<Vec<u8>, String>,
+) -> Result<ZipExport, String> {
+    if !FORMATS.contains(&options.format) {
+        return Err(format!("Unknown export format: {}", options.format));
+    }
+    let mut partial = destination.as_os_str().to_owned();
+    partial.push(".part");
+    let partial = PathBuf::from(partial);
+
+    let result = write_zip(items, options, &partial, read);
+    let result = result.and_then(|export| {
+        std::fs::rename(&partial, destination)
+            .map_err(|e| format!("Failed to write {}: {}", destination.display(), e))?;
+        Ok(export)
+    });
+    if result.is_err() {
+        let _ = std::fs::remove_file(&partial);
+    }
+    result.map(|(exported_count, skipped)| ZipExport {
+        path: destination.to_string_lossy().to_string(),
+        exported_count,
+        skipped,
+    })
+}
+
+fn write_zip(
+    items: &[ZipItem],
+    options: &ZipOptions,
+    path: &Path,
+    read: impl Fn(&str) -> Result<Vec<u8>, String>,
+) -> Result<(usize, Vec<ZipSkipped>), String> {
+    let write_error = |e: &dyn std::fmt::Display| format!("Failed to write ZIP: {}", e);
+    let file =
+        File::create(path).map_err(|e| format!("Failed to create {}: {}", path.display(), e))?;
+    let mut zip = ZipWriter::new(BufWriter::new(file));
+    let file_options = SimpleFileOptions::default().compression_method(CompressionMethod::Deflated);
+
+    let mut used = HashSet::new();
+    let mut entries = Vec::new();
+    let mut skipped = Vec::new();
+    let mut skipped_entries = Vec::new();
+    for item in items {
+        let converted =
+            read(&item.source).and_then(|data| convert(data, &item.file_name, options.format));
+        let entry = match converted {
+            Ok(entry) => entry,
+            Err(reason) => {
+                log_warn!("Left {} out of the ZIP: {}", item.source, reason);
+                skipped_entries.push(ManifestSkipped {
+                    subject: item.subject.clone(),
+                    source_file_name: item.file_name.clone(),
+                    message_hash: item.message_hash.clone(),
+                    reason: reason.clone(),
+                });
+                skipped.push(ZipSkipped {
+                    source: item.source.clone(),
+                    file_name: item.file_name.clone(),
+                    reason,
+                });
+                continue;
+            }
+        };
+        let name = unique_name(&entry.name, &mut used);
+        zip.start_file(format!("{}{}", EMAILS_FOLDER, name), file_options)
+            .map_err(|e| write_error(&e))?;
+        zip.write_all(&entry.bytes).map_err(|e| write_error(&e))?;
+        let checksum = options.checksums.then(|| Checksum {
+            size: entry.bytes.len() as u64,
+            sha256: Sha256::digest(&entry.bytes)
+                .iter()
+                .map(|byte| format!("{:02x}", byte))
+                .collect(),
+        });
+        let message = entry.message.unwrap_or_default();
+        entries.push(ManifestEntry {
+            file_name: name,
+            mime_type: entry.mime_type,
+            checksum,
+            subject: message.subject,
+            sender_name: message.sender_name,
+            sender_email: message.sender_email,
+            source_file_name: item.file_name.clone(),
+            message_delivery_time: message.date.map(iso_time).unwrap_or_default(),
+            message_hash: item.message_hash.clone(),
+        });
+    }
+
+    let now = SystemTime::now()
+        .duration_since(UNIX_EPOCH)
+        .map(|duration| duration.as_millis() as i64)
+        .unwrap_or(0);
+    let manifest = Manifest {
+        generated_at: iso_time(now),
+        format: options.format,
+        scope: options.scope,
+        message_count: items.len(),
+        exported_count: entries.len(),
+        skipped_count: skipped.len(),
+        messages: &entries,
+        skipped_messages: &skipped_entries,
+    };
+    let json = serde_json::to_vec_pretty(&manifest)
+        .map_err(|e| format!("Failed to serialize manifest: {}", e))?;
+    zip.start_file(MANIFEST_NAME, file_options)
+        .map_err(|e| write_error(&e))?;
+    zip.write_all(&json).map_err(|e| write_error(&e))?;
+    let mut writer = zip.finish().map_err(|e| write_error(&e))?;
+    writer.flush().map_err(|e| write_error(&e))?;
+    Ok((entries.len(), skipped))
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use std::io::Read;
+
+    const EML: &[u8] = b"From: Alice Example <alice@example.com>\r\n\
+        To: bob@example.com\r\n\
+        Subject: Quarterly Update\r\n\
+        Date: Fri, 13 Mar 2026 09:15:00 +0000\r\n\
+        \r\n\
+        Hello team\r\n";
+
+    fn item(source: &str, file_name: &str) -> ZipItem {
+        ZipItem {
+            source: source.to_string(),
+            file_name: file_name.to_string(),
+            message_hash: format!("hash-{}", source),
+            subject: String::new(),
+        }
+    }
+
+    /// Export to a file of its own and return the entries of the ZIP by name
+    fn export_entries(name: &str, items: &[ZipItem], checksums: bool) -> Vec<(String, Vec<u8>)> {
+        let file_name = format!("msgreader-zip-{}-{}.zip", std::process::id(), name);
+        let path = std::env::temp_dir().join(file_name);
+        let options = ZipOptions {
+            format: "original",
+            scope: "selected",
+            checksums,
+        };
+        let result = export(items, &options, &path, |source| match source {
+            "missing" => Err("not found".to_string()),
+            _ => Ok(EML.to_vec()),
+        });
+        let data = std::fs::read(&path);
+        let _ = std::fs::remove_file(&path);
+        result.unwrap();
+
+        let mut archive = zip::ZipArchive::new(std::io::Cursor::new(data.unwrap())).unwrap();
+        (0..archive.len())
+            .map(|index| {
+                let mut file = archive.by_index(index).unwrap();
+                let mut content = Vec::new();
+                file.read_to_end(&mut content).unwrap();
+                (file.name().to_string(), content)
+            })
+            .collect()
+    }
+
+    fn manifest(entries: &[(String, Vec<u8>)]) -> serde_json::Value {
+        let (_, json) = entries
+            .iter()
+            .find(|(name, _)| name == MANIFEST_NAME)
+            .unwrap();
+        serde_json::from_slice(json).unwrap()
+    }
+
+    #[test]
+    fn passes_the_frontend_verification() {
+        // The checks of verifyBulkExportZip in bulkExport.js: every listed file is in
+        // emails/ with its size and SHA-256, and no file there is unlisted
+        let items = [item("a.eml", "offer.eml"), item("b.eml", "offer.eml")];
+        let entries = export_entries("verify", &items, true);
+        let manifest = manifest(&entries);
+        let listed = manifest["messages"].as_array().unwrap();
+        assert_eq!(listed.len(), 2);
+
+        for message in listed {
+            let path = format!("emails/{}", message["fileName"].as_str().unwrap());
+            let (_, content) = entries.iter().find(|(name, _)| *name == path).unwrap();
+            let sha256: String = Sha256::digest(content)
+                .iter()
+                .map(|byte| format!("{:02x}", byte))
+                .collect();
+            assert_eq!(message["size"], content.len());
+            assert_eq!(message["sha256"], sha256);
+        }
+        let unlisted = entries.iter().filter(|(name, _)| {
+            name.starts_with(EMAILS_FOLDER)
+                && !listed.iter().any(|message| {
+                    format!("emails/{}", message["fileName"].as_str().unwrap()) == *name
+                })
+        });
+        assert_eq!(unlisted.count(), 0);
+    }
+
+    #[test]
+    fn writes_the_manifest_of_the_frontend() {
+        let items = [item("a.eml", "offer.eml"), item("missing", "gone.msg")];
+        let entries = export_entries("manifest", &items, true);
+        let manifest = manifest(&entries);
+
+        assert_eq!(manifest["format"], "original");
+        assert_eq!(manifest["scope"], "selected");
+        assert_eq!(manifest["exportedCount"], 1);
+        assert_eq!(manifest["skippedCount"], 1);
+        let message = &manifest["messages"][0];
+        assert_eq!(message["fileName"], "offer.eml");
+        assert_eq!(message["mimeType"], "message/rfc822");
+        assert_eq!(message["subject"], "Quarterly Update");
+        assert_eq!(message["senderName"], "Alice Example");
+        assert_eq!(message["senderEmail"], "alice@example.com");
+        assert_eq!(message["sourceFileName"], "offer.eml");
+        assert_eq!(message["messageDeliveryTime"], "2026-03-13T09:15:00Z");
+        assert_eq!(message["messageHash"], "hash-a.eml");
+        let skipped = &manifest["skippedMessages"][0];
+        assert_eq!(skipped["sourceFileName"], "gone.msg");
+        assert_eq!(skipped["messageHash"], "hash-missing");
+        assert_eq!(skipped["reason"], "not found");
+    }
+
+    #[test]
+    fn leaves_out_checksums_when_turned_off() {
+        let entries = export_entries("no-checksums", &[item("a.eml", "offer.eml")], false);
+        let message = &manifest(&entries)["messages"][0];
+
+        assert_eq!(message["fileName"], "offer.eml");
+        assert!(message.get("sha256").is_none());
+        assert!(message.get("size").is_none());
+    }
+}
@@ -0,0 +1,226 @@
+/**
+ * AccessibilityManager - Manages display and accessibility settings
+ * UI scale, minimum font size, reduced motion and high contrast, with
+ * reduced motion and high contrast following the OS preference by default.
+ */
+
+import { storage } from './storage.js';
+
+/**
+ * Available settings
+ */
+export const ACCESSIBILITY_SETTINGS = {
+    UI_SCALE: 'uiScale',
+    MIN_FONT_SIZE: 'minFontSize',
+    REDUCED_MOTION: 'reducedMotion',
+    HIGH_CONTRAST: 'highContrast'
+};
+
+/** UI scale in percent */
+export const UI_SCALES = [90, 100, 110, 125, 150];
+
+/** Minimum font size of message content in pixels, 0 = no minimum */
+export const MIN_FONT_SIZES = [0, 12, 14, 16, 18];
+
+/**
+ * Values for settings that can follow the OS preference
+ */
+export const SYSTEM_TOGGLE = {
+    SYSTEM: 'system',
+    ON: 'on',
+    OFF: 'off'
+};
+
+/**
+ * Storage keys for accessibility preferences
+ */
+const STORAGE_KEYS = {
+    [ACCESSIBILITY_SETTINGS.UI_SCALE]: 'msgReader_uiScale',
+    [ACCESSIBILITY_SETTINGS.MIN_FONT_SIZE]: 'msgReader_minFontSize',
+    [ACCESSIBILITY_SETTINGS.REDUCED_MOTION]: 'msgReader_reducedMotion',
+    [ACCESSIBILITY_SETTINGS.HIGH_CONTRAST]: 'msgReader_highContrast'
+};
+
+const ALLOWED_VALUES = {
+    [ACCESSIBILITY_SETTINGS.UI_SCALE]: UI_SCALES,
+    [ACCESSIBILITY_SETTINGS.MIN_FONT_SIZE]: MIN_FONT_SIZES,
+    [ACCESSIBILITY_SETTINGS.REDUCED_MOTION]: Object.values(SYSTEM_TOGGLE),
+    [ACCESSIBILITY_SETTINGS.HIGH_CONTRAST]: Object.values(SYSTEM_TOGGLE)
+};
+
+const DEFAULTS = {
+    [ACCESSIBILITY_SETTINGS.UI_SCALE]: 100,
+    [ACCESSIBILITY_SETTINGS.MIN_FONT_SIZE]: 0,
+    [ACCESSIBILITY_SETTINGS.REDUCED_MOTION]: SYSTEM_TOGGLE.SYSTEM,
+    [ACCESSIBILITY_SETTINGS.HIGH_CONTRAST]: SYSTEM_TOGGLE.SYSTEM
+};
+
+/**
+ * AccessibilityManager class handles display settings and persistence
+ */
+export class AccessibilityManager {
+    /**
+     * Creates a new AccessibilityManager instance
+     */
+    constructor() {
+        this.mediaQueries = {
+            reducedMotion: window.matchMedia('(prefers-reduced-motion: reduce)'),
+            highContrast: window.matchMedia('(prefers-contrast: more)'),
+            forcedColors: window.matchMedia('(forced-colors: active)')
+        };
+        this.listeners = new Set();
+    }
+
+    /**
+     * Initializes the manager
+     * Applies saved settings and follows OS preference changes
+     */
+    init() {
+        this.apply();
+
+        this.mediaQueries.reducedMotion.addEventListener('change', () => {
+            this.handleSystemChange(ACCESSIBILITY_SETTINGS.REDUCED_MOTION);
+        });
+        [this.mediaQueries.highContrast, this.mediaQueries.forcedColors].forEach((query) => {
+            query.addEventListener('change', () => {
+                this.handleSystemChange(ACCESSIBILITY_SETTINGS.HIGH_CONTRAST);
+            });
+        });
+    }
+
+    /**
+     * Re-applies a setting that follows the OS after the OS preference changed
+     * @param {string} setting - REDUCED_MOTION or HIGH_CONTRAST
+     */
+    handleSystemChange(setting) {
+        if (this.get(setting) !== SYSTEM_TOGGLE.SYSTEM) return;
+
+        this.apply();
+        this.notifyListeners(setting, SYSTEM_TOGGLE.SYSTEM);
+    }
+
+    /**
+     * Gets a saved setting
+     * @param {string} setting - One of ACCESSIBILITY_SETTINGS values
+     * @returns {number|string} Saved value or the default
+     */
+    get(setting) {
+        const savedValue = storage.get(STORAGE_KEYS[setting], DEFAULTS[setting]);
+        return ALLOWED_VALUES[setting]?.includes(savedValue) ? savedValue : DEFAULTS[setting];
+    }
+
+    /**
+     * Saves and applies a setting
+     * @param {string} setting - One of ACCESSIBILITY_SETTINGS values
+     * @param {number|string} value - New value
+     * @returns {boolean} False if the setting or value is invalid
+     */
+    set(setting, value) {
+        if (!ALLOWED_VALUES[setting]?.includes(value)) {
+            console.warn(`AccessibilityManager: Invalid value '${value}' for '${setting}'`);
+            return false;
+        }
+
+        storage.set(STORAGE_KEYS[setting], value);
+        this.apply();
+        this.notifyListeners(setting, value);
+        return true;
+    }
+
+    /**
+     * Resolves a system/on/off setting against the OS preference
+     * @param {string} value - One of SYSTEM_TOGGLE values
+     * @param {boolean} systemValue - OS preference
+     * @returns {boolean} Whether the setting is effectively on
+     */
+    resolveToggle(value, systemValue) {
+        if (value === SYSTEM_TOGGLE.SYSTEM) return systemValue;
+        return value === SYSTEM_TOGGLE.ON;
+    }
+
+    /**
+     * Whether animations and transitions should be reduced
+     * @returns {boolean}
+     */
+    isReducedMotion() {
+        return this.resolveToggle(
+            this.get(ACCESSIBILITY_SETTINGS.REDUCED_MOTION),
+            this.mediaQueries.reducedMotion.matches
+        );
+    }
+
+    /**
+     * Whether the high-contrast palette is used
+     * @returns {boolean}
+     */
+    isHighContrast() {
+        return this.resolveToggle(
+            this.get(ACCESSIBILITY_SETTINGS.HIGH_CONTRAST),
+            this.mediaQueries.highContrast.matches || this.mediaQueries.forcedColors.matches
+        );
+    }
+
+    /**
+     * Applies all settings to the document
+     */
+    apply() {
+        const root = document.documentElement;
+        const scale = this.get(ACCESSIBILITY_SETTINGS.UI_SCALE);
+        const minFontSize = this.get(ACCESSIBILITY_SETTINGS.MIN_FONT_SIZE);
+
+        root.style.zoom = scale === 100 ? '' : String(scale / 100);
+        root.classList.toggle('reduce-motion', this.isReducedMotion());
+        root.classList.toggle('high-contrast', this.isHighContrast());
+        if (minFontSize > 0) {
+            root.dataset.minFontSize = String(minFontSize);
+        } else {
+            delete root.dataset.minFontSize;
+        }
+    }
+
+    /**
+     * Enlarges text below the minimum font size inside rendered email content.
+     * Inline sizes in emails cannot be overridden with a stylesheet rule without
+     * flattening headings, so elements are adjusted one by one.
+     * @param {HTMLElement} container - Element containing the email body
+     */
+    applyMinimumFontSize(container) {
+        const minFontSize = this.get(ACCESSIBILITY_SETTINGS.MIN_FONT_SIZE);
+        if (!container || minFontSize === 0) return;
+
+        [container, ...container.querySelectorAll('*')].forEach((element) => {
+            const fontSize = parseFloat(getComputedStyle(element).fontSize);
+            if (fontSize && fontSize < minFontSize) {
+                element.style.setProperty('font-size', `${minFontSize}px`, 'important');
+            }
+        });
+    }
+
+    /**
+     * Adds a listener for setting changes
+     * @param {Function} callback - Called with (setting: string, value: number|string)
+     */
+    addListener(callback) {
+        this.listeners.add(callback);
+    }
+
+    /**
+     * Removes a setting change listener
+     * @param {Function} callback - The callback to remove
+     */
+    removeListener(callback) {
+        this.listeners.delete(callback);
+    }
+
+    /**
+     * Notifies all listeners of a setting change
+     * @param {string} setting - Changed setting
+     * @param {number|string} value - New saved value
+     */
+    notifyListeners(setting, value) {
+        this.listeners.forEach((callback) => callback(setting, value));
+    }
+}
+
+// Export singleton instance
+export const accessibilityManager = new AccessibilityManager();
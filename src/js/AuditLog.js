@@ -0,0 +1,204 @@
+/**
+ * AuditLog - Optional append-only record of user actions
+ * Used in regulated environments where access to evidence must be documented.
+ * Every entry carries the hash of the previous one so that edits to the stored
+ * log can be detected with verify().
+ */
+
+import { storage } from './storage.js';
+import { getAttachmentSha256, getMessageFileSha256, sha256Hex } from './hashing.js';
+
+/**
+ * Actions that can be recorded
+ */
+export const AUDIT_ACTIONS = {
+    OPEN: 'open',
+    EXPORT: 'export',
+    SAVE_ATTACHMENT: 'save-attachment',
+    REDACT: 'redact',
+    DELETE: 'delete',
+    TRANSLATE: 'translate',
+    DECRYPT: 'decrypt'
+};
+
+export const AUDIT_LOG_STORAGE_KEY = 'msgReader_auditLog';
+export const AUDIT_LOG_ENABLED_STORAGE_KEY = 'msgReader_auditLogEnabled';
+
+/**
+ * Column order used for CSV export
+ */
+export const AUDIT_LOG_CSV_COLUMNS = [
+    'sequence',
+    'timestamp',
+    'action',
+    'fileName',
+    'sourcePath',
+    'fileSha256',
+    'messageHash',
+    'subject',
+    'attachmentName',
+    'attachmentSha256',
+    'format',
+    'detail',
+    'previousHash',
+    'entryHash'
+];
+
+/**
+ * Escapes a value for CSV output.
+ * Values starting with formula characters are prefixed so spreadsheet apps treat them as text.
+ * @param {*} value - Cell value
+ * @returns {string} Escaped cell
+ */
+export function escapeCsvValue(value) {
+    let text = value === null || value === undefined ? '' : String(value);
+    if (/^[=+\-@\t\r]/.test(text)) {
+        text = `'${text}`;
+    }
+    if (/[",\r\n]/.test(text)) {
+        return `"${text.replace(/"/g, '""')}"`;
+    }
+    return text;
+}
+
+/**
+ * Computes the chained hash of an entry (all fields except entryHash)
+ * @param {Object} entry - Audit entry
+ * @returns {string} Hex digest
+ */
+function computeEntryHash(entry) {
+    const payload = AUDIT_LOG_CSV_COLUMNS.filter((column) => column !== 'entryHash')
+        .map((column) => `${column}=${entry[column] ?? ''}`)
+        .join('\n');
+    return sha256Hex(payload);
+}
+
+export class AuditLog {
+    /**
+     * Creates a new AuditLog instance
+     * @param {Storage} [storageInstance] - Optional storage instance for dependency injection
+     */
+    constructor(storageInstance = null) {
+        this.storage = storageInstance || storage;
+    }
+
+    /**
+     * Checks whether actions are currently recorded
+     * @returns {boolean}
+     */
+    isEnabled() {
+        return this.storage.get(AUDIT_LOG_ENABLED_STORAGE_KEY, false) === true;
+    }
+
+    /**
+     * Turns recording on or off. Existing entries are kept either way.
+     * @param {boolean} enabled - Whether to record actions
+     * @returns {boolean} True if the preference was saved
+     */
+    setEnabled(enabled) {
+        return this.storage.set(AUDIT_LOG_ENABLED_STORAGE_KEY, Boolean(enabled));
+    }
+
+    /**
+     * Gets all recorded entries, oldest first
+     * @returns {Array<Object>} Copy of the stored entries
+     */
+    getEntries() {
+        const entries = this.storage.get(AUDIT_LOG_STORAGE_KEY, []);
+        return Array.isArray(entries) ? entries.map((entry) => ({ ...entry })) : [];
+    }
+
+    /**
+     * Appends an entry for an action. Does nothing while the log is disabled.
+     * @param {string} action - One of AUDIT_ACTIONS
+     * @param {Object} [details] - What the action was performed on
+     * @param {Object} [details.message] - Message the action relates to
+     * @param {Object} [details.attachment] - Attachment the action relates to
+     * @param {string} [details.sourcePath] - Filesystem path the message was read from
+     * @param {string} [details.format] - Export format
+     * @param {string} [details.detail] - Free-form description
+     * @param {Date} [details.now] - Timestamp override (testing)
+     * @returns {Object|null} The stored entry, or null when disabled
+     */
+    record(action, details = {}) {
+        if (!this.isEnabled() || !Object.values(AUDIT_ACTIONS).includes(action)) {
+            return null;
+        }
+
+        const {
+            message = null,
+            attachment = null,
+            sourcePath = '',
+            format = '',
+            detail = ''
+        } = details;
+        const now = details.now instanceof Date ? details.now : new Date();
+        const entries = this.getEntries();
+        const previous = entries[entries.length - 1];
+
+        const entry = {
+            sequence: (previous?.sequence || 0) + 1,
+            timestamp: now.toISOString(),
+            action,
+            fileName: message?.fileName || '',
+            sourcePath,
+            fileSha256: getMessageFileSha256(message),
+            messageHash: message?.messageHash || '',
+            subject: message?.subject || '',
+            attachmentName: attachment?.fileName || '',
+            attachmentSha256: getAttachmentSha256(attachment),
+            format,
+            detail,
+            previousHash: previous?.entryHash || ''
+        };
+        entry.entryHash = computeEntryHash(entry);
+
+        entries.push(entry);
+        if (!this.storage.set(AUDIT_LOG_STORAGE_KEY, entries)) {
+            console.error('AuditLog: Failed to persist entry', entry.sequence);
+        }
+
+        return entry;
+    }
+
+    /**
+     * Checks that no stored entry was modified, removed, or reordered
+     * @returns {{valid: boolean, brokenAt: number|null}} brokenAt is the first invalid sequence
+     */
+    verify() {
+        let previousHash = '';
+        let expectedSequence = 1;
+
+        for (const entry of this.getEntries()) {
+            if (
+                entry.sequence !== expectedSequence ||
+                entry.previousHash !== previousHash ||
+                entry.entryHash !== computeEntryHash(entry)
+            ) {
+                return { valid: false, brokenAt: expectedSequence };
+            }
+            previousHash = entry.entryHash;
+            expectedSequence += 1;
+        }
+
+        return { valid: true, brokenAt: null };
+    }
+
+    /**
+     * Serializes the log as CSV with a header row
+     * @returns {string} CSV content
+     */
+    toCsv() {
+        const rows = [
+            AUDIT_LOG_CSV_COLUMNS.join(','),
+            ...this.getEntries().map((entry) =>
+                AUDIT_LOG_CSV_COLUMNS.map((column) => escapeCsvValue(entry[column])).join(',')
+            )
+        ];
+        return `${rows.join('\r\n')}\r\n`;
+    }
+}
+
+// Export singleton instance
+export const auditLog = new AuditLog();
+export default AuditLog;
@@ -1,5 +1,24 @@
-import { SUPPORTED_EMAIL_EXTENSIONS } from './constants.js';
-import { isTauri, readFileFromPath, getFileName } from './tauri-bridge.js';
+import {
+    DATA_FILE_EXTENSIONS,
+    MESSAGE_FILE_TYPES,
+    SUPPORTED_EMAIL_EXTENSIONS
+} from './constants.js';
+import {
+    isTauri,
+    readFileFromPath,
+    getFileName,
+    addRecentFile,
+    detectFileType,
+    expandDroppedPaths
+} from './tauri-bridge.js';
+import { auditLog, AUDIT_ACTIONS } from './AuditLog.js';
+import { usageStats } from './UsageStats.js';
+import { emitHookEvent, getMessageHookVariables, HOOK_EVENTS } from './eventHooks.js';
+import { openLargeMessageFile } from './largeMessage.js';
+import { replaceOleObjects } from './oleObjects.js';
+import { parseWithBackendCharsets } from './charsetDecoding.js';
+import { sanitizeMessageHtml } from './backendSanitizer.js';
+import { wrapTnefAsEml } from './tnef.js';
 
 /**
  * Handles file input via drag-and-drop and file input elements
@@ -21,9 +40,51 @@ class FileHandler {
         this.extractMsg = parsers.extractMsg || null;
         this.extractEml = parsers.extractEml || null;
         this.devModeManager = devModeManager;
+        this.dataFileHandler = null;
         this.setupEventListeners();
     }
 
+    /**
+     * Sets the handler for data files (.pst/.ost/.mbox) opened from a path
+     * @param {function(string, string): void} handler - Called with the file path and
+     *     its type: 'pst' (also for .ost files), 'mbox', 'zip' or 'gz'
+     */
+    setDataFileHandler(handler) {
+        this.dataFileHandler = handler;
+    }
+
+    /**
+     * The type a file opens as: the type of its content, so a message saved as .tmp or a
+     * misnamed export opens as well, or its lowercase extension if the content is not
+     * recognized or outside the desktop app
+     * @param {string} filePath - Absolute path to the file
+     * @returns {Promise<string>} 'msg', 'eml', 'tnef', 'pst', 'mbox', 'zip', 'gz' or the
+     *     extension
+     */
+    async detectType(filePath) {
+        const extension = getFileName(filePath).toLowerCase().split('.').pop();
+        try {
+            const detected = await detectFileType(filePath);
+            if (detected && detected.type !== 'unknown') return detected.type;
+        } catch (error) {
+            console.warn('FileHandler: Could not detect the type of', filePath, error);
+        }
+        return extension;
+    }
+
+    /**
+     * Hands a data file to the data file handler
+     * @param {string} filePath - Absolute path to the file
+     * @param {string} [type] - Type from detectType; defaults to the lowercase extension
+     * @returns {boolean} True if the file is a data file
+     */
+    openDataFile(filePath, type = getFileName(filePath).toLowerCase().split('.').pop()) {
+        if (!DATA_FILE_EXTENSIONS.includes(type)) return false;
+
+        this.dataFileHandler?.(filePath, type);
+        return true;
+    }
+
     setupEventListeners() {
         document.addEventListener('dragover', (e) => {
             e.preventDefault();
@@ -113,8 +174,11 @@ class FileHandler {
                 // Store raw buffer and file type for potential re-parsing in dev mode
                 msgInfo._rawBuffer = fileBuffer;
                 msgInfo._fileType = extension;
+                msgInfo._parsedAt = new Date().toISOString();
 
                 const message = this.messageHandler.addMessage(msgInfo, file.name);
+                auditLog.record(AUDIT_ACTIONS.OPEN, { message });
+                usageStats.recordFileOpened(message._fileType);
 
                 // Hide welcome screen and show app
                 this.uiManager.showAppContainer();
@@ -137,6 +201,46 @@ class FileHandler {
         reader.readAsArrayBuffer(file);
     }
 
+    /**
+     * Parses email files without adding them to the message list.
+     * Used by scans that only need the message content (e.g. folder PII scan).
+     * @param {Iterable<File>} files - Files to parse; unsupported extensions are skipped
+     * @returns {Promise<{messages: Array, errorCount: number}>} Parsed messages and failures
+     */
+    async parseFiles(files) {
+        const messages = [];
+        let errorCount = 0;
+
+        for (const file of Array.from(files)) {
+            const extension = file.name.toLowerCase().split('.').pop();
+            if (!SUPPORTED_EMAIL_EXTENSIONS.includes(extension)) continue;
+
+            try {
+                const fileBuffer = await file.arrayBuffer();
+                const msgInfo = extension === 'msg'
+                    ? this.extractMsg?.(fileBuffer)
+                    : this.extractEml?.(fileBuffer);
+
+                if (!msgInfo) {
+                    throw new Error(`Failed to parse ${extension.toUpperCase()} file`);
+                }
+
+                messages.push({
+                    ...msgInfo,
+                    fileName: file.name,
+                    _sourcePath: file.webkitRelativePath || file.name,
+                    _rawBuffer: fileBuffer,
+                    _fileType: extension
+                });
+            } catch (error) {
+                console.error('FileHandler: Error parsing file:', file.name, error);
+                errorCount++;
+            }
+        }
+
+        return { messages, errorCount };
+    }
+
     /**
      * Processes a file from a filesystem path (Tauri only)
      * This is called when a file is opened via file association (double-click)
@@ -148,40 +252,29 @@ class FileHandler {
             return;
         }
 
+        const type = await this.detectType(filePath);
+        if (this.openDataFile(filePath, type)) return;
+
         try {
             const fileName = getFileName(filePath);
-            const extension = fileName.toLowerCase().split('.').pop();
 
-            if (!SUPPORTED_EMAIL_EXTENSIONS.includes(extension)) {
-                console.error('Unsupported file type:', extension);
+            if (!MESSAGE_FILE_TYPES.includes(type)) {
+                console.error('Unsupported file type:', type);
                 return;
             }
 
-            // Read file from filesystem via Tauri
-            const fileBuffer = await readFileFromPath(filePath);
-
             // Check if dev mode is enabled for debug data collection
             const collectDebugData = this.devModeManager?.isEnabled() || false;
-            const parseOptions = { collectDebugData };
-
-            // Parse the email content
-            let msgInfo = null;
-            if (extension === 'msg' && this.extractMsg) {
-                msgInfo = this.extractMsg(fileBuffer, parseOptions);
-            } else if (extension === 'eml' && this.extractEml) {
-                msgInfo = this.extractEml(fileBuffer, parseOptions);
-            }
-
-            if (!msgInfo) {
-                throw new Error(`Failed to parse ${extension.toUpperCase()} file`);
-            }
-
-            // Store raw buffer and file type for potential re-parsing in dev mode
-            msgInfo._rawBuffer = fileBuffer;
-            msgInfo._fileType = extension;
+            const msgInfo = await this.parseFileFromPath(filePath, type, { collectDebugData });
+            msgInfo._sourcePath = filePath;
+            msgInfo._parsedAt = new Date().toISOString();
 
             // Add to message handler
             const message = this.messageHandler.addMessage(msgInfo, fileName);
+            auditLog.record(AUDIT_ACTIONS.OPEN, { message, sourcePath: filePath });
+            addRecentFile(filePath);
+            usageStats.recordFileOpened(message._fileType);
+            emitHookEvent(HOOK_EVENTS.MESSAGE_OPENED, getMessageHookVariables(message));
 
             // Hide welcome screen and show app
             this.uiManager.showAppContainer();
@@ -199,6 +292,113 @@ class FileHandler {
         }
     }
 
+    /**
+     * Reads and parses an email file from a filesystem path (Tauri only). Very large files
+     * are parsed by the backend instead, without reading them into the webview; those
+     * messages have no `_rawBuffer`. A winmail.dat file opens as a message with it as
+     * the attachment.
+     * @param {string} filePath - Absolute path to the file
+     * @param {string} type - 'msg', 'eml' or 'tnef', see detectType
+     * @param {Object} parseOptions - Options for the parser
+     * @returns {Promise<Object>} Parsed message, with `_fileType` 'msg' or 'eml'
+     */
+    async parseFileFromPath(filePath, type, parseOptions) {
+        const largeMessage = type === 'tnef' ? null : await openLargeMessageFile(filePath);
+        if (largeMessage) {
+            largeMessage._fileType = type;
+            return sanitizeMessageHtml(largeMessage);
+        }
+
+        // Read file from filesystem via Tauri
+        let fileBuffer = await readFileFromPath(filePath);
+        let extension = type;
+        if (type === 'tnef') {
+            fileBuffer = wrapTnefAsEml(fileBuffer, getFileName(filePath));
+            extension = 'eml';
+        }
+
+        // Parse the email content; text in charsets the parser cannot decode is decoded
+        // by the backend
+        const parse = extension === 'msg' ? this.extractMsg : this.extractEml;
+        const msgInfo = parse
+            ? await parseWithBackendCharsets(() => parse(fileBuffer, parseOptions))
+            : null;
+
+        if (!msgInfo) {
+            throw new Error(`Failed to parse ${extension.toUpperCase()} file`);
+        }
+        if (extension === 'msg') {
+            await replaceOleObjects(msgInfo, filePath);
+        }
+
+        // Store raw buffer for potential re-parsing in dev mode
+        msgInfo._rawBuffer = fileBuffer;
+        msgInfo._fileType = extension;
+        return sanitizeMessageHtml(msgInfo);
+    }
+
+    /**
+     * Adds a message that is not a file of its own, e.g. one read from a PST or mbox file
+     * @param {ArrayBuffer} fileBuffer - Content of the .msg or .eml file
+     * @param {string} fileName - Name shown for the message
+     * @param {string} sourcePath - Where the message came from, for the audit log
+     * @param {string} [fileType='msg'] - 'msg' or 'eml'
+     */
+    async handleMessageBuffer(fileBuffer, fileName, sourcePath, fileType = 'msg') {
+        try {
+            const collectDebugData = this.devModeManager?.isEnabled() || false;
+            const parse = fileType === 'eml' ? this.extractEml : this.extractMsg;
+            const msgInfo = parse?.(fileBuffer, { collectDebugData });
+            if (!msgInfo) {
+                throw new Error(`Failed to parse ${fileType.toUpperCase()} file`);
+            }
+            await sanitizeMessageHtml(msgInfo);
+
+            msgInfo._rawBuffer = fileBuffer;
+            msgInfo._fileType = fileType;
+            msgInfo._sourcePath = sourcePath;
+            msgInfo._parsedAt = new Date().toISOString();
+
+            const message = this.messageHandler.addMessage(msgInfo, fileName);
+            auditLog.record(AUDIT_ACTIONS.OPEN, { message, sourcePath });
+            usageStats.recordFileOpened(message._fileType);
+            emitHookEvent(HOOK_EVENTS.MESSAGE_OPENED, getMessageHookVariables(message));
+
+            this.uiManager.showAppContainer();
+            this.uiManager.updateMessageList();
+            this.uiManager.showMessage(message);
+        } catch (error) {
+            console.error('FileHandler: Error processing message:', error);
+            if (this.uiManager.showError) {
+                this.uiManager.showError(`Failed to open: ${fileName}`);
+            }
+        }
+    }
+
+    /**
+     * Opens files and folders dropped onto the window (Tauri only). The backend searches
+     * dropped folders and their subfolders for .msg/.eml files and removes duplicates;
+     * dropped items that cannot be opened are reported instead of ignored.
+     * @param {string[]} paths - Dropped files and folders
+     */
+    async handleDroppedPaths(paths) {
+        const dropped = await expandDroppedPaths(paths);
+        const notify = (message) => this.uiManager.showInfo?.(message);
+
+        if (dropped.folders > 0) {
+            notify(
+                dropped.files.length > 0
+                    ? `Opening ${dropped.files.length} files from ${dropped.folders} folders`
+                    : 'No .msg or .eml files found in the dropped folders'
+            );
+        }
+        if (dropped.skipped > 0) {
+            notify(`${dropped.skipped} dropped items are not messages or could not be read`);
+        }
+
+        await this.handleFilesFromPaths(dropped.files);
+    }
+
     /**
      * Processes multiple files from filesystem paths in batch (Tauri only)
      * Optimized for loading many files at once - reads in parallel, updates UI once
@@ -212,12 +412,15 @@ class FileHandler {
 
         if (!filePaths || filePaths.length === 0) return;
 
-        // Filter to supported extensions
-        const supportedPaths = filePaths.filter(filePath => {
-            const fileName = getFileName(filePath);
-            const extension = fileName.toLowerCase().split('.').pop();
-            return SUPPORTED_EMAIL_EXTENSIONS.includes(extension);
-        });
+        // Outlook data files open in their own browser; other files are kept if their
+        // content or extension is a message
+        const types = await Promise.all(filePaths.map((filePath) => this.detectType(filePath)));
+        const typeOf = new Map(filePaths.map((filePath, index) => [filePath, types[index]]));
+        const supportedPaths = filePaths.filter(
+            (filePath) =>
+                !this.openDataFile(filePath, typeOf.get(filePath)) &&
+                MESSAGE_FILE_TYPES.includes(typeOf.get(filePath))
+        );
 
         if (supportedPaths.length === 0) return;
 
@@ -240,28 +443,15 @@ class FileHandler {
                 batch.map(async (filePath) => {
                     try {
                         const fileName = getFileName(filePath);
-                        const extension = fileName.toLowerCase().split('.').pop();
-
-                        // Read file from filesystem via Tauri
-                        const fileBuffer = await readFileFromPath(filePath);
-
-                        // Parse the email content
-                        let msgInfo = null;
-                        if (extension === 'msg' && this.extractMsg) {
-                            msgInfo = this.extractMsg(fileBuffer, parseOptions);
-                        } else if (extension === 'eml' && this.extractEml) {
-                            msgInfo = this.extractEml(fileBuffer, parseOptions);
-                        }
-
-                        if (!msgInfo) {
-                            throw new Error(`Failed to parse ${extension.toUpperCase()} file`);
-                        }
-
-                        // Store raw buffer and file type for potential re-parsing in dev mode
-                        msgInfo._rawBuffer = fileBuffer;
-                        msgInfo._fileType = extension;
-
-                        return { msgInfo, fileName };
+                        const msgInfo = await this.parseFileFromPath(
+                            filePath,
+                            typeOf.get(filePath),
+                            parseOptions
+                        );
+                        msgInfo._sourcePath = filePath;
+                        msgInfo._parsedAt = new Date().toISOString();
+
+                        return { msgInfo, fileName, filePath };
                     } catch (error) {
                         console.error('FileHandler: Error processing file:', filePath, error);
                         return null;
@@ -273,6 +463,10 @@ class FileHandler {
             for (const result of batchResults) {
                 if (result) {
                     const message = this.messageHandler.addMessage(result.msgInfo, result.fileName);
+                    auditLog.record(AUDIT_ACTIONS.OPEN, { message, sourcePath: result.filePath });
+                    addRecentFile(result.filePath);
+                    usageStats.recordFileOpened(message._fileType);
+                    emitHookEvent(HOOK_EVENTS.MESSAGE_OPENED, getMessageHookVariables(message));
                     messages.push(message);
                 } else {
                     errorCount++;
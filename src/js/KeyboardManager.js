@@ -10,6 +10,8 @@ import {
     IGNORED_KEYS
 } from './KeyboardShortcuts.js';
 import { themeManager } from './ThemeManager.js';
+import { getHelpTopicForContext, openHelp } from './help.js';
+import { usageStats, USAGE_FEATURES } from './UsageStats.js';
 
 class KeyboardManager {
     /**
@@ -74,6 +76,7 @@ class KeyboardManager {
             ['prevAttachment', () => this.navigateAttachment(-1)],
             ['nextAttachment', () => this.navigateAttachment(1)],
             ['showHelp', () => this.showHelpModal()],
+            ['showContextHelp', () => this.showContextHelp()],
             ['toggleTheme', () => this.toggleTheme()]
         ]);
     }
@@ -106,6 +109,13 @@ class KeyboardManager {
      * @param {KeyboardEvent} event
      */
     handleKeyDown(event) {
+        // F1 opens help everywhere, including while typing in the search field
+        if (event.key === 'F1' && !event.ctrlKey && !event.metaKey && !event.altKey) {
+            event.preventDefault();
+            this.showContextHelp();
+            return;
+        }
+
         // Ignore if typing in an input field (unless it's Escape)
         if (this.isInputFocused() && event.key !== 'Escape') {
             return;
@@ -476,6 +486,23 @@ class KeyboardManager {
         }
     }
 
+    /**
+     * Open the help page for the current view
+     */
+    showContextHelp() {
+        const topic = getHelpTopicForContext({
+            helpModalOpen: this.helpModal?.classList.contains('active'),
+            attachmentModalOpen: this.context === KEYBOARD_CONTEXTS.MODAL,
+            settingsOpen: document
+                .getElementById('themeMenuDropdown')
+                ?.classList.contains('active'),
+            searchFocused: document.activeElement?.id === 'search-input',
+            messageOpen: Boolean(this.app.messageHandler.getCurrentMessage())
+        });
+        usageStats.recordFeature(USAGE_FEATURES.HELP);
+        openHelp(topic);
+    }
+
     /**
      * Initialize help modal event listeners
      */
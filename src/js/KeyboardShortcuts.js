@@ -129,6 +129,12 @@ export const SHORTCUTS = {
             action: 'showHelp',
             contexts: [KEYBOARD_CONTEXTS.MAIN, KEYBOARD_CONTEXTS.MODAL],
             description: 'Show keyboard shortcuts'
+        },
+        {
+            keys: ['F1'],
+            action: 'showContextHelp',
+            contexts: [KEYBOARD_CONTEXTS.MAIN, KEYBOARD_CONTEXTS.MODAL],
+            description: 'Show help for the current view'
         }
     ]
 };
@@ -170,17 +176,18 @@ export const HELP_MODAL_SECTIONS = [
     {
         title: 'Help',
         shortcuts: [
-            { keys: ['?'], description: 'Show this help' }
+            { keys: ['?'], description: 'Show this help' },
+            { keys: ['F1'], description: 'Open help for the current view' }
         ]
     }
 ];
 
 /**
  * Keys that should not trigger shortcuts when pressed
- * (typically function keys and system keys)
+ * (typically function keys and system keys; F1 opens help)
  */
 export const IGNORED_KEYS = [
-    'F1', 'F2', 'F3', 'F4', 'F5', 'F6', 'F7', 'F8', 'F9', 'F10', 'F11', 'F12',
+    'F2', 'F3', 'F4', 'F5', 'F6', 'F7', 'F8', 'F9', 'F10', 'F11', 'F12',
     'Tab', 'CapsLock', 'Shift', 'Control', 'Alt', 'Meta'
 ];
 
@@ -3,7 +3,7 @@ import md5 from 'md5';
 
 /**
  * Manages email messages and their state
- * Handles message storage, pinning, sorting, and current selection
+ * Handles message storage, pinning, sorting, conversations, and current selection
  */
 class MessageHandler {
     /**
@@ -16,6 +16,24 @@ class MessageHandler {
         this.currentMessage = null;
         this.pinnedMessages = new Set(this.storage.get('pinnedMessages', []));
         this.selectedMessageHashes = new Set();
+        // messageHash -> { rank, size, position } while grouped by conversation
+        this.threadInfo = null;
+        this.threadsOutdated = false;
+        this.changeListeners = new Set();
+    }
+
+    /**
+     * Registers a callback for added, removed and shown messages
+     * @param {Function} callback - Called without arguments after each change
+     * @returns {Function} Removes the callback
+     */
+    onChange(callback) {
+        this.changeListeners.add(callback);
+        return () => this.changeListeners.delete(callback);
+    }
+
+    notifyChange() {
+        this.changeListeners.forEach((callback) => callback());
     }
 
     /**
@@ -56,15 +74,71 @@ class MessageHandler {
         };
 
         this.messages.unshift(message);
+        this.threadsOutdated = this.threadInfo !== null;
         this.sortMessages();
+        this.notifyChange();
         return message;
     }
 
     /**
-     * Sorts messages by timestamp in descending order (newest first)
+     * Sorts messages by timestamp in descending order (newest first). While grouped by
+     * conversation, the messages of a conversation follow each other, newest
+     * conversation first; messages not grouped yet come before all of them.
      */
     sortMessages() {
         this.messages.sort((a, b) => b.timestamp - a.timestamp);
+        if (this.threadInfo) {
+            const rank = (message) => this.threadInfo.get(message.messageHash)?.rank ?? -1;
+            this.messages.sort((a, b) => rank(a) - rank(b));
+        }
+    }
+
+    /**
+     * Groups the messages by conversation
+     * @param {Array<{keys: string[]}>} threads - Conversations as returned by getThreads,
+     *     newest first, with message hashes oldest first
+     */
+    setThreads(threads) {
+        this.threadInfo = new Map();
+        threads.forEach((thread, rank) => {
+            thread.keys.forEach((key, index) => {
+                this.threadInfo.set(key, {
+                    rank,
+                    size: thread.keys.length,
+                    // 0 for the newest message, which is listed first
+                    position: thread.keys.length - 1 - index
+                });
+            });
+        });
+        this.threadsOutdated = false;
+        this.sortMessages();
+    }
+
+    /**
+     * Shows the messages as a flat list again
+     */
+    clearThreads() {
+        this.threadInfo = null;
+        this.threadsOutdated = false;
+        this.sortMessages();
+    }
+
+    /**
+     * Gets the conversation of a message while grouped by conversation
+     * @param {Object} msgInfo - Message object
+     * @returns {{rank: number, size: number, position: number}|null} Null if the
+     *     messages are not grouped or the message was added since
+     */
+    getThreadInfo(msgInfo) {
+        return this.threadInfo?.get(msgInfo?.messageHash) || null;
+    }
+
+    /**
+     * Checks if messages were added or removed since they were grouped by conversation
+     * @returns {boolean} True if setThreads should be called again
+     */
+    needsThreads() {
+        return this.threadsOutdated;
     }
 
     /**
@@ -77,7 +151,9 @@ class MessageHandler {
         this.messages.splice(index, 1);
         this.pinnedMessages.delete(msgInfo.messageHash);
         this.selectedMessageHashes.delete(msgInfo.messageHash);
+        this.threadsOutdated = this.threadInfo !== null;
         this.savePinnedMessages();
+        this.notifyChange();
 
         if (this.messages.length === 0) {
             return null;
@@ -184,6 +260,7 @@ class MessageHandler {
      */
     setCurrentMessage(message) {
         this.currentMessage = message;
+        this.notifyChange();
     }
 
     /**
@@ -1,6 +1,32 @@
+import { searchOpenMessages } from './tauri-bridge.js';
+
+/**
+ * Weight of a match per field when ranking results. A term at the start of a word
+ * counts twice, e.g. "contract" in "Contract renewal" ranks above "subcontractor".
+ */
+export const SEARCH_FIELD_WEIGHTS = {
+    subject: 4,
+    sender: 3,
+    attachments: 2,
+    recipients: 2,
+    body: 1
+};
+
+/**
+ * Parses a query written as a regular expression: `/pattern/`, or `/pattern/i` to ignore
+ * case
+ * @param {string} query - Search input
+ * @returns {{pattern: string, caseSensitive: boolean}|null} Null for a plain query
+ */
+export function parsePatternQuery(query) {
+    const match = /^\/(.+)\/(i?)$/s.exec((query || '').trim());
+    return match ? { pattern: match[1], caseSensitive: match[2] !== 'i' } : null;
+}
+
 /**
  * Manages email search functionality
- * Provides filtering, debouncing, and query highlighting
+ * Provides filtering, ranking, debouncing, and query highlighting.
+ * The searchable text of each message is built once and cached (the index).
  */
 export class SearchManager {
     /**
@@ -12,6 +38,12 @@ export class SearchManager {
         this.currentQuery = '';
         this.searchTerms = [];
         this.debounceTimer = null;
+        this.index = new WeakMap();
+        // Hits of the last pattern search in the backend:
+        // {query, searched: Set<Object>, matches: Map<Object, Array<Object>>}
+        this.patternHits = null;
+        // Number of the latest debounced search, so an older pattern search is dropped
+        this.searchRun = 0;
     }
 
     /**
@@ -20,19 +52,130 @@ export class SearchManager {
      * @returns {Array} Filtered messages
      */
     search(query) {
-        this.currentQuery = query.toLowerCase().trim();
+        const pattern = parsePatternQuery(query);
+        // Case can matter in a pattern
+        this.currentQuery = pattern ? query.trim() : query.toLowerCase().trim();
         // Split query into individual search terms
-        this.searchTerms = this.currentQuery.split(/\s+/).filter(Boolean);
+        this.searchTerms = pattern ? [] : this.currentQuery.split(/\s+/).filter(Boolean);
 
         if (!this.currentQuery) {
             return this.messageHandler.getMessages();
         }
+        if (pattern) {
+            return this.filterByPattern(pattern);
+        }
 
         return this.messageHandler.getMessages().filter(message =>
             this.matchesQuery(message)
         );
     }
 
+    /**
+     * Searches messages and orders them by relevance, without changing the current query
+     * @param {string} query - Search terms, all must match
+     * @param {Object} [filters] - Additional conditions
+     * @param {string} [filters.sender] - Part of the sender name or address
+     * @param {boolean} [filters.hasAttachments] - Only messages with (or without) attachments
+     * @param {Date|string} [filters.after] - Only messages sent at or after this date
+     * @param {Date|string} [filters.before] - Only messages sent before this date
+     * @returns {Array<{message: Object, score: number}>} Hits, best first; equal scores
+     *     keep the list order
+     */
+    rank(query, filters = {}) {
+        const terms = query.toLowerCase().trim().split(/\s+/).filter(Boolean);
+
+        return this.messageHandler
+            .getMessages()
+            .filter((message) => this.matchesFilters(message, filters))
+            .map((message, order) => {
+                const entry = this.getIndexEntry(message);
+                if (!terms.every((term) => this.entryContains(entry, term))) return null;
+                const score = terms.reduce((sum, term) => sum + this.scoreTerm(entry, term), 0);
+                return { message, score, order };
+            })
+            .filter(Boolean)
+            .sort((a, b) => b.score - a.score || a.order - b.order)
+            .map(({ message, score }) => ({ message, score }));
+    }
+
+    /**
+     * Filters messages by a pattern query. Messages searched by the last backend search
+     * for the query keep its result; the others are matched here, as a JavaScript
+     * regular expression, against the same fields.
+     * @param {{pattern: string, caseSensitive: boolean}} pattern - See parsePatternQuery
+     * @returns {Array} Matching messages; none if the pattern is invalid
+     */
+    filterByPattern({ pattern, caseSensitive }) {
+        const hits = this.patternHits?.query === this.currentQuery ? this.patternHits : null;
+        let regex = null;
+        try {
+            regex = new RegExp(pattern, caseSensitive ? '' : 'i');
+        } catch {
+            // Only the backend result counts
+        }
+
+        return this.messageHandler.getMessages().filter((message) => {
+            if (hits?.searched.has(message)) return hits.matches.has(message);
+            if (!regex) return false;
+            const texts = [
+                message.subject,
+                message._exportMeta?.rawHeaders,
+                message.bodyContent || message.body || this.stripHtml(message.bodyContentHTML),
+                ...(message.attachments || []).map((attachment) => attachment.fileName)
+            ];
+            return texts.some((text) => text && regex.test(text));
+        });
+    }
+
+    /**
+     * Searches the open messages for a pattern query in the backend of the desktop app,
+     * which is much faster than matching large bodies here, and keeps the match
+     * locations for getMatches. Does nothing outside the desktop app or if the backend
+     * rejects the pattern (it has no look-around or back-references).
+     * @param {string} query - Pattern query, see parsePatternQuery
+     * @returns {Promise<void>}
+     */
+    async loadPatternHits(query) {
+        const pattern = parsePatternQuery(query);
+        if (!pattern) return;
+
+        const messages = [...this.messageHandler.getMessages()];
+        let hits;
+        try {
+            hits = await searchOpenMessages(messages, pattern.pattern, {
+                regex: true,
+                caseSensitive: pattern.caseSensitive
+            });
+        } catch (error) {
+            console.warn('Backend search failed, searching here:', error);
+            return;
+        }
+        if (!hits) return;
+
+        const byKey = new Map(hits.map((hit) => [hit.key, hit.matches]));
+        this.patternHits = {
+            query: query.trim(),
+            searched: new Set(messages),
+            matches: new Map(
+                messages
+                    .filter((message) => byKey.has(message.messageHash))
+                    .map((message) => [message, byKey.get(message.messageHash)])
+            )
+        };
+    }
+
+    /**
+     * Gets where the current pattern query matched a message, for highlighting
+     * @param {Object} message - Message object
+     * @returns {Array<{field: string, attachment: ?number, start: number, end: number,
+     *     before: string, text: string, after: string}>} Up to 100 matches, see
+     *     searchOpenMessages; empty if the backend did not search the message
+     */
+    getMatches(message) {
+        if (this.patternHits?.query !== this.currentQuery) return [];
+        return this.patternHits.matches.get(message) || [];
+    }
+
     /**
      * Checks if a message matches the current query
      * All search terms must be found somewhere in the message
@@ -40,34 +183,114 @@ export class SearchManager {
      * @returns {boolean} True if message matches query
      */
     matchesQuery(message) {
-        // Build searchable text from all relevant fields
-        const searchableText = [
-            // Subject
-            message.subject,
+        const entry = this.getIndexEntry(message);
+
+        // All search terms must be found (AND logic)
+        return this.searchTerms.every(term => this.entryContains(entry, term));
+    }
+
+    /**
+     * Gets the searchable text of a message, building it on first use
+     * @param {Object} message - Message object
+     * @returns {{fields: Object<string, string>, text: string, normalizedText: string}}
+     */
+    getIndexEntry(message) {
+        let entry = this.index.get(message);
+        if (entry) return entry;
+
+        const join = (values) => values.filter(Boolean).join(' ').toLowerCase();
+        const fields = {
+            subject: join([message.subject]),
             // Sender fields (MSG and EML formats)
-            message.senderName,
-            message.senderEmail,
-            message.senderSmtpAddress,
-            // Body content (various formats)
-            message.body,
-            message.bodyContent,
-            // Strip HTML tags from HTML body for text search
-            this.stripHtml(message.bodyContentHTML),
+            sender: join([message.senderName, message.senderEmail, message.senderSmtpAddress]),
             // Recipients (support both 'email' and 'address' field names)
-            message.recipients?.map(r =>
-                `${r.name || ''} ${r.email || ''} ${r.address || ''}`
-            ).join(' ')
-        ].filter(Boolean).join(' ').toLowerCase();
+            recipients: join(
+                (message.recipients || []).map(
+                    (r) => `${r.name || ''} ${r.email || ''} ${r.address || ''}`
+                )
+            ),
+            attachments: join((message.attachments || []).map((a) => a.fileName)),
+            // Body content (various formats), HTML without tags
+            body: join([
+                message.body,
+                message.bodyContent,
+                this.stripHtml(message.bodyContentHTML)
+            ])
+        };
+        const text = Object.values(fields).filter(Boolean).join(' ');
 
         // Normalize the combined text for flexible matching
-        const normalizedText = this.normalizeText(searchableText);
+        entry = { fields, text, normalizedText: this.normalizeText(text) };
+        this.index.set(message, entry);
+        return entry;
+    }
 
-        // All search terms must be found (AND logic)
-        return this.searchTerms.every(term => {
-            const normalizedTerm = this.normalizeText(term);
-            return searchableText.includes(term) ||
-                   normalizedText.includes(normalizedTerm);
-        });
+    /**
+     * Drops the cached text of a message whose content changed (e.g. after decrypting it)
+     * @param {Object} message - Message object
+     */
+    forget(message) {
+        this.index.delete(message);
+        this.patternHits?.searched.delete(message);
+        this.patternHits?.matches.delete(message);
+    }
+
+    /**
+     * Checks whether a term occurs in an index entry
+     * @param {Object} entry - See getIndexEntry
+     * @param {string} term - Lowercase search term
+     * @returns {boolean}
+     */
+    entryContains(entry, term) {
+        return (
+            entry.text.includes(term) || entry.normalizedText.includes(this.normalizeText(term))
+        );
+    }
+
+    /**
+     * Scores a term by the fields it occurs in
+     * @param {Object} entry - See getIndexEntry
+     * @param {string} term - Lowercase search term
+     * @returns {number}
+     */
+    scoreTerm(entry, term) {
+        const normalizedTerm = this.normalizeText(term);
+        const wordStart = new RegExp(
+            `(^|[^\\p{L}\\p{N}])${this.escapeRegex(normalizedTerm)}`,
+            'u'
+        );
+
+        return Object.entries(SEARCH_FIELD_WEIGHTS).reduce((score, [field, weight]) => {
+            const text = entry.fields[field];
+            const normalized = this.normalizeText(text);
+            if (!text.includes(term) && !normalized.includes(normalizedTerm)) return score;
+            return score + (wordStart.test(normalized) ? weight * 2 : weight);
+        }, 0);
+    }
+
+    /**
+     * Checks the ranking filters
+     * @param {Object} message - Message object
+     * @param {Object} filters - See rank
+     * @returns {boolean}
+     */
+    matchesFilters(message, { sender, hasAttachments, after, before } = {}) {
+        if (sender && !this.getIndexEntry(message).fields.sender.includes(sender.toLowerCase())) {
+            return false;
+        }
+        if (
+            typeof hasAttachments === 'boolean' &&
+            (message.attachments || []).length > 0 !== hasAttachments
+        ) {
+            return false;
+        }
+        if (after || before) {
+            const time = message.timestamp instanceof Date ? message.timestamp.getTime() : NaN;
+            if (Number.isNaN(time)) return false;
+            if (after && time < new Date(after).getTime()) return false;
+            if (before && time >= new Date(before).getTime()) return false;
+        }
+        return true;
     }
 
     /**
@@ -92,7 +315,8 @@ export class SearchManager {
     }
 
     /**
-     * Performs debounced search for input handlers
+     * Performs debounced search for input handlers. Pattern queries are searched in the
+     * backend first; a search that finishes after a newer one started is dropped.
      * @param {string} query - Search term
      * @param {Function} callback - Callback with search results
      * @param {number} delay - Debounce delay in milliseconds
@@ -100,8 +324,14 @@ export class SearchManager {
     searchDebounced(query, callback, delay = 300) {
         clearTimeout(this.debounceTimer);
         this.debounceTimer = setTimeout(() => {
-            const results = this.search(query);
-            callback(results);
+            const run = ++this.searchRun;
+            if (!parsePatternQuery(query)) {
+                callback(this.search(query));
+                return;
+            }
+            this.loadPatternHits(query).then(() => {
+                if (run === this.searchRun) callback(this.search(query));
+            });
         }, delay);
     }
 
@@ -112,6 +342,8 @@ export class SearchManager {
     clearSearch() {
         this.currentQuery = '';
         this.searchTerms = [];
+        this.patternHits = null;
+        this.searchRun++;
         clearTimeout(this.debounceTimer);
         return this.messageHandler.getMessages();
     }
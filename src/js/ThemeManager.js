@@ -4,6 +4,8 @@
  */
 
 import { storage } from './storage.js';
+import { t } from './i18n.js';
+import { getSystemTheme, isTauri, onSystemThemeChanged } from './tauri-bridge.js';
 
 /**
  * Available theme options
@@ -26,7 +28,7 @@ export const EMAIL_THEMES = {
 /**
  * Storage keys for theme preferences
  */
-const STORAGE_KEYS = {
+export const THEME_STORAGE_KEYS = {
     APP_THEME: 'msgReader_theme',
     EMAIL_THEME: 'msgReader_emailTheme'
 };
@@ -41,6 +43,9 @@ export class ThemeManager {
     constructor() {
         this.mediaQuery = window.matchMedia('(prefers-color-scheme: dark)');
         this.listeners = new Set();
+        // OS theme read by the desktop backend, which also works where the webview does not
+        // report prefers-color-scheme (WebKitGTK); null in the browser
+        this.systemTheme = null;
     }
 
     /**
@@ -53,14 +58,54 @@ export class ThemeManager {
         this.applyEmailTheme(this.getSavedEmailTheme());
 
         // Listen for system theme changes
-        this.mediaQuery.addEventListener('change', () => {
-            if (this.getSavedTheme() === THEMES.SYSTEM) {
-                this.applyTheme(THEMES.SYSTEM);
-            }
-            if (this.getSavedEmailTheme() === EMAIL_THEMES.INHERIT) {
-                this.applyEmailTheme(EMAIL_THEMES.INHERIT);
-            }
-        });
+        this.mediaQuery.addEventListener('change', () => this.refreshSystemTheme());
+        this.followSystemTheme();
+    }
+
+    /**
+     * Reads the OS theme and accent color from the desktop backend and follows its
+     * theme-changed events (Tauri only)
+     * @returns {Promise<void>}
+     */
+    async followSystemTheme() {
+        if (!isTauri()) return;
+
+        try {
+            this.applySystemTheme(await getSystemTheme());
+            await onSystemThemeChanged((systemTheme) => this.applySystemTheme(systemTheme));
+        } catch (error) {
+            console.warn('ThemeManager: Could not read the system theme:', error);
+        }
+    }
+
+    /**
+     * Applies an OS theme reported by the backend
+     * @param {{theme: string, accentColor: string|null}} systemTheme - Theme ('light' or
+     *     'dark') and accent color (#rrggbb), exposed as the --system-accent-color property
+     */
+    applySystemTheme(systemTheme) {
+        this.systemTheme = systemTheme;
+
+        const root = document.documentElement;
+        if (systemTheme?.accentColor) {
+            root.style.setProperty('--system-accent-color', systemTheme.accentColor);
+        } else {
+            root.style.removeProperty('--system-accent-color');
+        }
+        this.refreshSystemTheme();
+    }
+
+    /**
+     * Re-applies the themes that follow the system after it changed
+     */
+    refreshSystemTheme() {
+        if (this.getSavedTheme() === THEMES.SYSTEM) {
+            this.applyTheme(THEMES.SYSTEM);
+            this.notifyListeners('app', THEMES.SYSTEM);
+        }
+        if (this.getSavedEmailTheme() === EMAIL_THEMES.INHERIT) {
+            this.applyEmailTheme(EMAIL_THEMES.INHERIT);
+        }
     }
 
     /**
@@ -68,7 +113,7 @@ export class ThemeManager {
      * @returns {string} The saved theme or 'system' as default
      */
     getSavedTheme() {
-        return storage.get(STORAGE_KEYS.APP_THEME, THEMES.SYSTEM);
+        return storage.get(THEME_STORAGE_KEYS.APP_THEME, THEMES.SYSTEM);
     }
 
     /**
@@ -76,7 +121,7 @@ export class ThemeManager {
      * @returns {string} The saved email theme or 'inherit' as default
      */
     getSavedEmailTheme() {
-        return storage.get(STORAGE_KEYS.EMAIL_THEME, EMAIL_THEMES.INHERIT);
+        return storage.get(THEME_STORAGE_KEYS.EMAIL_THEME, EMAIL_THEMES.INHERIT);
     }
 
     /**
@@ -88,7 +133,7 @@ export class ThemeManager {
             console.warn(`ThemeManager: Invalid theme '${theme}'`);
             return;
         }
-        storage.set(STORAGE_KEYS.APP_THEME, theme);
+        storage.set(THEME_STORAGE_KEYS.APP_THEME, theme);
         this.applyTheme(theme);
 
         // Update email theme if it inherits from app
@@ -108,7 +153,7 @@ export class ThemeManager {
             console.warn(`ThemeManager: Invalid email theme '${theme}'`);
             return;
         }
-        storage.set(STORAGE_KEYS.EMAIL_THEME, theme);
+        storage.set(THEME_STORAGE_KEYS.EMAIL_THEME, theme);
         this.applyEmailTheme(theme);
         this.notifyListeners('email', theme);
     }
@@ -150,6 +195,9 @@ export class ThemeManager {
      */
     resolveTheme(theme) {
         if (theme === THEMES.SYSTEM) {
+            if (this.systemTheme) {
+                return this.systemTheme.theme === THEMES.DARK ? THEMES.DARK : THEMES.LIGHT;
+            }
             return this.mediaQuery.matches ? THEMES.DARK : THEMES.LIGHT;
         }
         return theme;
@@ -233,12 +281,12 @@ export class ThemeManager {
      */
     getThemeLabel(theme) {
         const labels = {
-            [THEMES.LIGHT]: 'Light',
-            [THEMES.DARK]: 'Dark',
-            [THEMES.SYSTEM]: 'System',
-            [EMAIL_THEMES.INHERIT]: 'Same as App'
+            [THEMES.LIGHT]: 'theme.light',
+            [THEMES.DARK]: 'theme.dark',
+            [THEMES.SYSTEM]: 'theme.system',
+            [EMAIL_THEMES.INHERIT]: 'theme.inherit'
         };
-        return labels[theme] || theme;
+        return labels[theme] ? t(labels[theme]) : theme;
     }
 }
 
@@ -0,0 +1,175 @@
+/**
+ * UsageStats - Opt-in, local-only usage statistics
+ * Counts opened files by type and how often features are used. Nothing is
+ * recorded until the user enables it, and nothing is ever sent anywhere: the
+ * counts only leave the app when the user copies them into a bug report.
+ */
+
+import { storage } from './storage.js';
+
+/**
+ * Features whose use is counted
+ */
+export const USAGE_FEATURES = {
+    SEARCH: 'search',
+    EXPORT: 'export',
+    BULK_EXPORT: 'bulk-export',
+    ATTACHMENT_PREVIEW: 'attachment-preview',
+    ATTACHMENT_SAVE: 'attachment-save',
+    PIN: 'pin',
+    TRANSLATE: 'translate',
+    READ_ALOUD: 'read-aloud',
+    HELP: 'help'
+};
+
+/**
+ * Labels shown on the statistics page
+ */
+export const USAGE_FEATURE_LABELS = {
+    [USAGE_FEATURES.SEARCH]: 'Searches',
+    [USAGE_FEATURES.EXPORT]: 'Message exports',
+    [USAGE_FEATURES.BULK_EXPORT]: 'ZIP exports',
+    [USAGE_FEATURES.ATTACHMENT_PREVIEW]: 'Attachment previews',
+    [USAGE_FEATURES.ATTACHMENT_SAVE]: 'Attachments saved',
+    [USAGE_FEATURES.PIN]: 'Messages pinned',
+    [USAGE_FEATURES.TRANSLATE]: 'Translations',
+    [USAGE_FEATURES.READ_ALOUD]: 'Read aloud',
+    [USAGE_FEATURES.HELP]: 'Help opened'
+};
+
+export const USAGE_STATS_STORAGE_KEY = 'msgReader_usageStats';
+export const USAGE_STATS_ENABLED_STORAGE_KEY = 'msgReader_usageStatsEnabled';
+
+/**
+ * Creates an empty statistics record
+ * @param {Date} now - Start of the counting period
+ * @returns {{since: string, filesOpened: Object, features: Object}}
+ */
+function createEmptyStats(now) {
+    return { since: now.toISOString(), filesOpened: {}, features: {} };
+}
+
+export class UsageStats {
+    /**
+     * Creates a new UsageStats instance
+     * @param {Storage} [storageInstance] - Optional storage instance for dependency injection
+     */
+    constructor(storageInstance = null) {
+        this.storage = storageInstance || storage;
+    }
+
+    /**
+     * Checks whether usage is currently counted
+     * @returns {boolean}
+     */
+    isEnabled() {
+        return this.storage.get(USAGE_STATS_ENABLED_STORAGE_KEY, false) === true;
+    }
+
+    /**
+     * Turns counting on or off. Existing counts are kept either way.
+     * @param {boolean} enabled - Whether to count usage
+     * @returns {boolean} True if the preference was saved
+     */
+    setEnabled(enabled) {
+        return this.storage.set(USAGE_STATS_ENABLED_STORAGE_KEY, Boolean(enabled));
+    }
+
+    /**
+     * Gets the collected counts
+     * @returns {{since: string|null, filesOpened: Object<string, number>,
+     *     features: Object<string, number>}} Copy of the stored counts
+     */
+    getStats() {
+        const stats = this.storage.get(USAGE_STATS_STORAGE_KEY, null);
+        if (!stats || typeof stats !== 'object') {
+            return { since: null, filesOpened: {}, features: {} };
+        }
+        return {
+            since: stats.since || null,
+            filesOpened: { ...stats.filesOpened },
+            features: { ...stats.features }
+        };
+    }
+
+    /**
+     * Increments a counter. Does nothing while counting is disabled.
+     * @param {'filesOpened'|'features'} group - Counter group
+     * @param {string} name - Counter name
+     * @param {Date} [now] - Timestamp override (testing)
+     * @returns {boolean} True if the count was stored
+     */
+    increment(group, name, now = new Date()) {
+        if (!this.isEnabled() || !name) return false;
+
+        const stats = this.getStats();
+        if (!stats.since) {
+            Object.assign(stats, createEmptyStats(now));
+        }
+        stats[group][name] = (stats[group][name] || 0) + 1;
+        return this.storage.set(USAGE_STATS_STORAGE_KEY, stats);
+    }
+
+    /**
+     * Counts an opened file
+     * @param {string} fileType - File extension, e.g. "msg" or "eml"
+     * @returns {boolean} True if the count was stored
+     */
+    recordFileOpened(fileType) {
+        return this.increment('filesOpened', String(fileType || 'unknown').toLowerCase());
+    }
+
+    /**
+     * Counts the use of a feature
+     * @param {string} feature - One of USAGE_FEATURES
+     * @returns {boolean} True if the count was stored
+     */
+    recordFeature(feature) {
+        if (!Object.values(USAGE_FEATURES).includes(feature)) return false;
+        return this.increment('features', feature);
+    }
+
+    /**
+     * Deletes all counts
+     * @returns {boolean} True if the counts were removed
+     */
+    reset() {
+        return this.storage.remove(USAGE_STATS_STORAGE_KEY);
+    }
+
+    /**
+     * Formats the counts as plain text for pasting into a bug report
+     * @param {Object} [appInfo] - Result of getAppInfo, adds version and platform lines
+     * @returns {string} Report text
+     */
+    toReport(appInfo = null) {
+        const { since, filesOpened, features } = this.getStats();
+        const lines = ['msgReader usage statistics'];
+        if (appInfo?.version) {
+            const commit = appInfo.commit ? ` (${appInfo.commit})` : '';
+            lines.push(`Version: ${appInfo.version}${commit}`);
+        }
+        if (appInfo?.platform) {
+            lines.push(`Platform: ${appInfo.platform}${appInfo.arch ? ` ${appInfo.arch}` : ''}`);
+        }
+        if (appInfo?.packaging) {
+            lines.push(`Packaging: ${appInfo.packaging}`);
+        }
+        lines.push(`Counting since: ${since || 'never enabled'}`, '', 'Files opened:');
+
+        const fileTypes = Object.keys(filesOpened).sort();
+        if (fileTypes.length === 0) lines.push('  none');
+        fileTypes.forEach((type) => lines.push(`  ${type}: ${filesOpened[type]}`));
+
+        lines.push('', 'Features used:');
+        Object.values(USAGE_FEATURES).forEach((feature) => {
+            lines.push(`  ${USAGE_FEATURE_LABELS[feature]}: ${features[feature] || 0}`);
+        });
+
+        return `${lines.join('\n')}\n`;
+    }
+}
+
+// Export singleton instance
+export const usageStats = new UsageStats();
+export default UsageStats;
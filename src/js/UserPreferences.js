@@ -1,4 +1,5 @@
 import { storage } from './storage.js';
+import { managedPolicy } from './policy.js';
 
 export const PDF_ATTACHMENT_OPEN_MODE = {
     IN_APP: 'in-app',
@@ -29,3 +30,374 @@ export function setPdfAttachmentOpenMode(openMode) {
 export function pdfAttachmentsOpenInApp() {
     return getPdfAttachmentOpenMode() === PDF_ATTACHMENT_OPEN_MODE.IN_APP;
 }
+
+export const EXTERNAL_CONTENT = {
+    LOAD: 'load',
+    BLOCK: 'block'
+};
+
+export const EXTERNAL_CONTENT_STORAGE_KEY = 'msgReader_externalContent';
+
+/** Whether the BlockExternalContent policy decides instead of the user */
+export function externalContentManaged() {
+    return managedPolicy.get('blockExternalContent') === true;
+}
+
+export function getExternalContent() {
+    if (externalContentManaged()) {
+        return EXTERNAL_CONTENT.BLOCK;
+    }
+
+    const savedValue = storage.get(EXTERNAL_CONTENT_STORAGE_KEY, EXTERNAL_CONTENT.BLOCK);
+
+    return Object.values(EXTERNAL_CONTENT).includes(savedValue)
+        ? savedValue
+        : EXTERNAL_CONTENT.BLOCK;
+}
+
+export function setExternalContent(mode) {
+    if (!Object.values(EXTERNAL_CONTENT).includes(mode) || externalContentManaged()) {
+        return false;
+    }
+
+    return storage.set(EXTERNAL_CONTENT_STORAGE_KEY, mode);
+}
+
+export function externalContentBlocked() {
+    return getExternalContent() === EXTERNAL_CONTENT.BLOCK;
+}
+
+export const REMOTE_IMAGE_SENDERS_STORAGE_KEY = 'msgReader_remoteImageSenders';
+
+/**
+ * Senders whose remote images load without asking
+ * @returns {string[]} Lowercase addresses and `@domain` entries
+ */
+export function getRemoteImageSenders() {
+    const savedValue = storage.get(REMOTE_IMAGE_SENDERS_STORAGE_KEY, []);
+    return Array.isArray(savedValue) ? savedValue : [];
+}
+
+export function setRemoteImageSenders(senders) {
+    if (!Array.isArray(senders)) {
+        return false;
+    }
+
+    const normalized = senders
+        .filter((sender) => typeof sender === 'string' && sender.includes('@'))
+        .map((sender) => sender.trim().toLowerCase());
+    return storage.set(REMOTE_IMAGE_SENDERS_STORAGE_KEY, [...new Set(normalized)]);
+}
+
+/**
+ * Whether the remote images of a sender load without asking. Never with the
+ * BlockExternalContent policy.
+ * @param {string} email - Sender address
+ * @returns {boolean}
+ */
+export function remoteImagesAllowedFor(email) {
+    if (!email || externalContentManaged()) {
+        return false;
+    }
+
+    const address = email.trim().toLowerCase();
+    return getRemoteImageSenders().some((sender) =>
+        sender.startsWith('@') ? address.endsWith(sender) : address === sender
+    );
+}
+
+export const EXPORT_CHECKSUM_MODE = {
+    SHA256: 'sha256',
+    NONE: 'none'
+};
+
+export const EXPORT_CHECKSUM_MODE_STORAGE_KEY = 'msgReader_exportChecksumMode';
+
+export function getExportChecksumMode() {
+    const savedValue = storage.get(EXPORT_CHECKSUM_MODE_STORAGE_KEY, EXPORT_CHECKSUM_MODE.SHA256);
+
+    return Object.values(EXPORT_CHECKSUM_MODE).includes(savedValue)
+        ? savedValue
+        : EXPORT_CHECKSUM_MODE.SHA256;
+}
+
+export function setExportChecksumMode(mode) {
+    if (!Object.values(EXPORT_CHECKSUM_MODE).includes(mode)) {
+        return false;
+    }
+
+    return storage.set(EXPORT_CHECKSUM_MODE_STORAGE_KEY, mode);
+}
+
+export function exportChecksumsEnabled() {
+    return getExportChecksumMode() === EXPORT_CHECKSUM_MODE.SHA256;
+}
+
+/** What a redacted copy does with the names and addresses of the people in a message */
+export const REDACTION_ADDRESSES = {
+    PSEUDONYMIZE: 'pseudonymize',
+    STRIP: 'strip',
+    KEEP: 'keep'
+};
+
+export const REDACTION_STORAGE_KEY = 'msgReader_redaction';
+
+const DEFAULT_REDACTION = {
+    addresses: REDACTION_ADDRESSES.PSEUDONYMIZE,
+    dropAttachments: true,
+    removeTrackingPixels: true
+};
+
+export function isRedactionOptions(value) {
+    return (
+        typeof value === 'object' &&
+        value !== null &&
+        Object.values(REDACTION_ADDRESSES).includes(value.addresses) &&
+        typeof value.dropAttachments === 'boolean' &&
+        typeof value.removeTrackingPixels === 'boolean'
+    );
+}
+
+/**
+ * Options of redacted copies, see exportRedacted
+ * @returns {{addresses: string, dropAttachments: boolean, removeTrackingPixels: boolean}}
+ */
+export function getRedactionOptions() {
+    const savedValue = { ...DEFAULT_REDACTION, ...storage.get(REDACTION_STORAGE_KEY, {}) };
+
+    return isRedactionOptions(savedValue) ? savedValue : { ...DEFAULT_REDACTION };
+}
+
+/**
+ * Changes some of the redaction options
+ * @param {Object} changes - Options to change, the others are kept
+ * @returns {boolean} True if saved
+ */
+export function setRedactionOptions(changes) {
+    const options = { ...getRedactionOptions(), ...changes };
+    if (!isRedactionOptions(options)) {
+        return false;
+    }
+
+    return storage.set(REDACTION_STORAGE_KEY, options);
+}
+
+export const TEMP_FILE_RETENTION = {
+    EXIT: 'exit',
+    ONE_HOUR: '1h',
+    ONE_DAY: '24h'
+};
+
+export const TEMP_FILE_RETENTION_MINUTES = {
+    [TEMP_FILE_RETENTION.EXIT]: 0,
+    [TEMP_FILE_RETENTION.ONE_HOUR]: 60,
+    [TEMP_FILE_RETENTION.ONE_DAY]: 24 * 60
+};
+
+export const TEMP_FILE_RETENTION_STORAGE_KEY = 'msgReader_tempFileRetention';
+
+export function getTempFileRetention() {
+    const savedValue = storage.get(TEMP_FILE_RETENTION_STORAGE_KEY, TEMP_FILE_RETENTION.ONE_HOUR);
+
+    return Object.values(TEMP_FILE_RETENTION).includes(savedValue)
+        ? savedValue
+        : TEMP_FILE_RETENTION.ONE_HOUR;
+}
+
+export function setTempFileRetention(retention) {
+    if (!Object.values(TEMP_FILE_RETENTION).includes(retention)) {
+        return false;
+    }
+
+    return storage.set(TEMP_FILE_RETENTION_STORAGE_KEY, retention);
+}
+
+export const AUTOMATION_API = {
+    ENABLED: 'enabled',
+    DISABLED: 'disabled'
+};
+
+export const AUTOMATION_API_STORAGE_KEY = 'msgReader_automationApi';
+
+export function getAutomationApi() {
+    const savedValue = storage.get(AUTOMATION_API_STORAGE_KEY, AUTOMATION_API.DISABLED);
+
+    return Object.values(AUTOMATION_API).includes(savedValue)
+        ? savedValue
+        : AUTOMATION_API.DISABLED;
+}
+
+export function setAutomationApi(state) {
+    if (!Object.values(AUTOMATION_API).includes(state)) {
+        return false;
+    }
+
+    return storage.set(AUTOMATION_API_STORAGE_KEY, state);
+}
+
+export function automationApiEnabled() {
+    return getAutomationApi() === AUTOMATION_API.ENABLED;
+}
+
+/** Empty string means the OS default voice */
+export const SPEECH_VOICE_STORAGE_KEY = 'msgReader_speechVoice';
+
+export function getSpeechVoice() {
+    const savedValue = storage.get(SPEECH_VOICE_STORAGE_KEY, '');
+
+    return typeof savedValue === 'string' ? savedValue : '';
+}
+
+export function setSpeechVoice(voice) {
+    if (typeof voice !== 'string') {
+        return false;
+    }
+
+    return storage.set(SPEECH_VOICE_STORAGE_KEY, voice);
+}
+
+/** What to show when the desktop app starts without files */
+export const STARTUP_BEHAVIOR = {
+    WELCOME: 'welcome',
+    LAST_FILE: 'last-file',
+    SESSION: 'session'
+};
+
+export const STARTUP_BEHAVIOR_STORAGE_KEY = 'msgReader_startup';
+
+export function getStartupBehavior() {
+    const savedValue = storage.get(STARTUP_BEHAVIOR_STORAGE_KEY, STARTUP_BEHAVIOR.WELCOME);
+
+    return Object.values(STARTUP_BEHAVIOR).includes(savedValue)
+        ? savedValue
+        : STARTUP_BEHAVIOR.WELCOME;
+}
+
+export function setStartupBehavior(behavior) {
+    if (!Object.values(STARTUP_BEHAVIOR).includes(behavior)) {
+        return false;
+    }
+
+    return storage.set(STARTUP_BEHAVIOR_STORAGE_KEY, behavior);
+}
+
+/** Whether the desktop app shows an icon in the system tray or menu bar */
+export const TRAY_ICON = {
+    HIDE: 'hide',
+    SHOW: 'show'
+};
+
+export const TRAY_ICON_STORAGE_KEY = 'msgReader_trayIcon';
+
+export function getTrayIcon() {
+    const savedValue = storage.get(TRAY_ICON_STORAGE_KEY, TRAY_ICON.HIDE);
+
+    return Object.values(TRAY_ICON).includes(savedValue) ? savedValue : TRAY_ICON.HIDE;
+}
+
+export function setTrayIcon(value) {
+    if (!Object.values(TRAY_ICON).includes(value)) {
+        return false;
+    }
+
+    return storage.set(TRAY_ICON_STORAGE_KEY, value);
+}
+
+/** How the desktop app lists opened messages */
+export const MESSAGE_LIST_GROUPING = {
+    FLAT: 'flat',
+    CONVERSATIONS: 'conversations'
+};
+
+export const MESSAGE_LIST_GROUPING_STORAGE_KEY = 'msgReader_messageListGrouping';
+
+export function getMessageListGrouping() {
+    const savedValue = storage.get(MESSAGE_LIST_GROUPING_STORAGE_KEY, MESSAGE_LIST_GROUPING.FLAT);
+
+    return Object.values(MESSAGE_LIST_GROUPING).includes(savedValue)
+        ? savedValue
+        : MESSAGE_LIST_GROUPING.FLAT;
+}
+
+export function setMessageListGrouping(grouping) {
+    if (!Object.values(MESSAGE_LIST_GROUPING).includes(grouping)) {
+        return false;
+    }
+
+    return storage.set(MESSAGE_LIST_GROUPING_STORAGE_KEY, grouping);
+}
+
+/** Folder the desktop save dialogs start in, empty string for the OS default */
+export const DEFAULT_SAVE_DIRECTORY_STORAGE_KEY = 'msgReader_defaultSaveDirectory';
+
+export function getDefaultSaveDirectory() {
+    const savedValue = storage.get(DEFAULT_SAVE_DIRECTORY_STORAGE_KEY, '');
+
+    return typeof savedValue === 'string' ? savedValue : '';
+}
+
+export function setDefaultSaveDirectory(directory) {
+    if (typeof directory !== 'string') {
+        return false;
+    }
+
+    return storage.set(DEFAULT_SAVE_DIRECTORY_STORAGE_KEY, directory);
+}
+
+/** Folders the desktop app watches for new email files */
+export const WATCHED_FOLDERS_STORAGE_KEY = 'msgReader_watchedFolders';
+
+export function getWatchedFolders() {
+    const savedValue = storage.get(WATCHED_FOLDERS_STORAGE_KEY, []);
+
+    return Array.isArray(savedValue)
+        ? savedValue.filter((folder) => typeof folder === 'string')
+        : [];
+}
+
+export function setWatchedFolders(folders) {
+    if (!Array.isArray(folders)) {
+        return false;
+    }
+
+    return storage.set(WATCHED_FOLDERS_STORAGE_KEY, [...new Set(folders)]);
+}
+
+/**
+ * IMAP accounts the desktop app can browse, without their passwords, which the backend
+ * keeps in the OS credential store
+ */
+export const IMAP_ACCOUNTS_STORAGE_KEY = 'msgReader_imapAccounts';
+
+export function isImapAccount(account) {
+    return (
+        typeof account?.host === 'string' &&
+        account.host !== '' &&
+        Number.isInteger(account.port) &&
+        account.port > 0 &&
+        account.port < 65536 &&
+        typeof account.username === 'string' &&
+        account.username !== ''
+    );
+}
+
+export function getImapAccounts() {
+    const savedValue = storage.get(IMAP_ACCOUNTS_STORAGE_KEY, []);
+
+    return Array.isArray(savedValue)
+        ? savedValue
+              .filter(isImapAccount)
+              .map(({ host, port, username }) => ({ host, port, username }))
+        : [];
+}
+
+export function setImapAccounts(accounts) {
+    if (!Array.isArray(accounts) || !accounts.every(isImapAccount)) {
+        return false;
+    }
+
+    return storage.set(
+        IMAP_ACCOUNTS_STORAGE_KEY,
+        accounts.map(({ host, port, username }) => ({ host, port, username }))
+    );
+}
@@ -0,0 +1,244 @@
+/**
+ * Address Book Module
+ * Collects every unique sender and recipient across a set of messages,
+ * merges display-name variants per address and exports CSV or vCard.
+ */
+
+import { getContactEmail } from './addressUtils.js';
+import { escapeCsvValue } from './AuditLog.js';
+
+export const ADDRESS_BOOK_FORMATS = {
+    CSV: 'csv',
+    VCARD: 'vcf'
+};
+
+/**
+ * Cleans a display name: trims quotes and whitespace, drops names that are just the address
+ * @param {string} name - Display name
+ * @param {string} email - Address the name belongs to
+ * @returns {string} Cleaned name or empty string
+ */
+export function cleanDisplayName(name, email) {
+    const cleaned = String(name || '')
+        .replace(/^["'\s]+|["'\s]+$/g, '')
+        .replace(/\s+/g, ' ');
+    if (!cleaned || cleaned.toLowerCase() === email.toLowerCase()) return '';
+    return cleaned;
+}
+
+/**
+ * Key under which spelling variants of the same name are merged,
+ * e.g. "Doe, John", "john doe" and "John  Doe" share one key
+ * @param {string} name - Cleaned display name
+ * @returns {string} Variant key
+ */
+export function getNameVariantKey(name) {
+    const commaMatch = name.match(/^([^,]+),\s*([^,]+)$/);
+    const ordered = commaMatch ? `${commaMatch[2]} ${commaMatch[1]}` : name;
+    return ordered.toLowerCase().replace(/[^\p{L}\p{N}]+/gu, ' ').trim();
+}
+
+/**
+ * Splits a display name into family and given name
+ * @param {string} name - Display name
+ * @returns {{family: string, given: string}}
+ */
+export function splitName(name) {
+    const commaMatch = name.match(/^([^,]+),\s*([^,]+)$/);
+    if (commaMatch) {
+        return { family: commaMatch[1].trim(), given: commaMatch[2].trim() };
+    }
+
+    const parts = name.trim().split(/\s+/);
+    if (parts.length < 2) return { family: name.trim(), given: '' };
+    return { family: parts.pop(), given: parts.join(' ') };
+}
+
+/**
+ * Collects all unique addresses from messages
+ * @param {Array<Object>} messages - Parsed messages
+ * @returns {Array<Object>} Contacts sorted by number of messages (most first), each with
+ *     email, displayName, nameVariants, messageCount, sentCount, receivedCount, firstSeen, lastSeen
+ */
+export function collectAddressBook(messages) {
+    const contacts = new Map();
+
+    const addOccurrence = (email, name, message, role) => {
+        const key = email.toLowerCase();
+        if (!contacts.has(key)) {
+            contacts.set(key, {
+                email,
+                names: new Map(),
+                messages: new Set(),
+                sentCount: 0,
+                receivedCount: 0,
+                firstSeen: null,
+                lastSeen: null
+            });
+        }
+
+        const contact = contacts.get(key);
+        const displayName = cleanDisplayName(name, email);
+        if (displayName) {
+            const variantKey = getNameVariantKey(displayName);
+            const variant = contact.names.get(variantKey) || { spellings: new Map(), count: 0 };
+            variant.count++;
+            variant.spellings.set(displayName, (variant.spellings.get(displayName) || 0) + 1);
+            contact.names.set(variantKey, variant);
+        }
+
+        contact.messages.add(message);
+        if (role === 'sender') {
+            contact.sentCount++;
+        } else {
+            contact.receivedCount++;
+        }
+
+        const date = message.timestamp instanceof Date && !isNaN(message.timestamp)
+            ? message.timestamp
+            : null;
+        if (date) {
+            if (!contact.firstSeen || date < contact.firstSeen) contact.firstSeen = date;
+            if (!contact.lastSeen || date > contact.lastSeen) contact.lastSeen = date;
+        }
+    };
+
+    messages.forEach((message) => {
+        const senderEmail = getContactEmail({ email: message.senderEmail });
+        if (senderEmail) {
+            addOccurrence(senderEmail, message.senderName, message, 'sender');
+        }
+
+        (message.recipients || []).forEach((recipient) => {
+            const email = getContactEmail(recipient);
+            if (email) {
+                addOccurrence(email, recipient.name, message, 'recipient');
+            }
+        });
+    });
+
+    // Ties prefer "First Last" over "Last, First", then the longer spelling
+    const mostFrequent = (entries) =>
+        [...entries].sort(
+            (a, b) =>
+                b[1] - a[1] ||
+                Number(a[0].includes(',')) - Number(b[0].includes(',')) ||
+                b[0].length - a[0].length
+        )[0]?.[0] || '';
+
+    return [...contacts.values()]
+        .map((contact) => {
+            // Each variant group is represented by its most common spelling
+            const variants = [...contact.names.values()]
+                .sort((a, b) => b.count - a.count)
+                .map((variant) => mostFrequent(variant.spellings.entries()));
+
+            return {
+                email: contact.email,
+                displayName: variants[0] || '',
+                nameVariants: variants,
+                messageCount: contact.messages.size,
+                sentCount: contact.sentCount,
+                receivedCount: contact.receivedCount,
+                firstSeen: contact.firstSeen,
+                lastSeen: contact.lastSeen
+            };
+        })
+        .sort((a, b) => b.messageCount - a.messageCount || a.email.localeCompare(b.email));
+}
+
+function formatDate(date) {
+    return date ? date.toISOString() : '';
+}
+
+/**
+ * Serializes contacts as CSV
+ * @param {Array<Object>} contacts - Result of collectAddressBook
+ * @returns {string} CSV text
+ */
+export function addressBookToCsv(contacts) {
+    const header = [
+        'Name',
+        'Email',
+        'Other Names',
+        'Messages',
+        'As Sender',
+        'As Recipient',
+        'First Seen',
+        'Last Seen'
+    ];
+    const rows = contacts.map((contact) => [
+        contact.displayName,
+        contact.email,
+        contact.nameVariants.slice(1).join('; '),
+        contact.messageCount,
+        contact.sentCount,
+        contact.receivedCount,
+        formatDate(contact.firstSeen),
+        formatDate(contact.lastSeen)
+    ]);
+
+    return [header, ...rows].map((row) => row.map(escapeCsvValue).join(',')).join('\r\n');
+}
+
+function escapeVCardValue(value) {
+    return String(value || '')
+        .replace(/\\/g, '\\\\')
+        .replace(/\r?\n/g, '\\n')
+        .replace(/,/g, '\\,')
+        .replace(/;/g, '\\;');
+}
+
+/**
+ * Folds a content line to 75 characters as required by RFC 6350
+ * @param {string} line - Unfolded line
+ * @returns {string} Folded line
+ */
+function foldVCardLine(line) {
+    const chars = Array.from(line);
+    if (chars.length <= 75) return line;
+
+    const parts = [chars.slice(0, 75).join('')];
+    for (let i = 75; i < chars.length; i += 74) {
+        parts.push(` ${chars.slice(i, i + 74).join('')}`);
+    }
+    return parts.join('\r\n');
+}
+
+function getVCardName(contact) {
+    if (!contact.displayName) {
+        return { formatted: contact.email, family: '', given: '' };
+    }
+
+    const { family, given } = splitName(contact.displayName);
+    return { formatted: given ? `${given} ${family}` : family, family, given };
+}
+
+/**
+ * Serializes contacts as a set of vCard 3.0 entries
+ * @param {Array<Object>} contacts - Result of collectAddressBook
+ * @returns {string} vCard text
+ */
+export function addressBookToVCard(contacts) {
+    return contacts
+        .map((contact) => {
+            const name = getVCardName(contact);
+            const lines = [
+                'BEGIN:VCARD',
+                'VERSION:3.0',
+                `FN:${escapeVCardValue(name.formatted)}`,
+                `N:${escapeVCardValue(name.family)};${escapeVCardValue(name.given)};;;`,
+                `EMAIL;TYPE=INTERNET:${contact.email}`
+            ];
+            if (contact.nameVariants.length > 1) {
+                lines.push(
+                    `NICKNAME:${contact.nameVariants.slice(1).map(escapeVCardValue).join(',')}`
+                );
+            }
+            lines.push('END:VCARD');
+
+            return lines.map(foldVCardLine).join('\r\n');
+        })
+        .join('\r\n')
+        .concat(contacts.length > 0 ? '\r\n' : '');
+}
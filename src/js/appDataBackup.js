@@ -0,0 +1,177 @@
+/**
+ * App Data Backup Module
+ * Exports the data the app keeps in local storage (settings, pinned messages, audit
+ * log, usage statistics) as a ZIP archive and restores it, e.g. to move to another
+ * machine or after a reinstall. A backup covers the active profile.
+ */
+
+import { storage as defaultStorage } from './storage.js';
+import { isProfileDataKey } from './profiles.js';
+import { ENCRYPTION_STORAGE_KEY } from './dataEncryption.js';
+
+export const APP_DATA_BACKUP_FORMAT = 'msgReader-app-data';
+export const APP_DATA_BACKUP_VERSION = 1;
+
+const MANIFEST_FILE = 'manifest.json';
+const DATA_FILE = 'app-data.json';
+
+async function loadJSZip() {
+    const { default: JSZip } = await import('./jszipLoader.js');
+    return JSZip;
+}
+
+/**
+ * Checks whether a key from a backup may be restored. Encryption metadata belongs
+ * to the data of one machine and is never restored.
+ * @param {string} name - Key as used by the application
+ * @returns {boolean}
+ */
+function isRestorableKey(name) {
+    return isProfileDataKey(name) && name !== ENCRYPTION_STORAGE_KEY;
+}
+
+/**
+ * Lists the keys of the active profile that belong in a backup
+ * @param {Storage} storageInstance - Storage with the active profile's namespace
+ * @returns {string[]} Keys as used by the application, without the namespace
+ */
+function listBackupKeys(storageInstance) {
+    const namespace = storageInstance.namespace;
+    return storageInstance
+        .keysWithPrefix(namespace)
+        .filter((key) => isProfileDataKey(key, namespace))
+        .map((key) => key.slice(namespace.length))
+        .filter(isRestorableKey)
+        .sort();
+}
+
+/**
+ * Collects the stored app data of the active profile
+ * @param {Storage} [storageInstance] - Storage to read (dependency injection)
+ * @returns {Object<string, *>} Values by key
+ */
+export function collectAppData(storageInstance = defaultStorage) {
+    const entries = {};
+    listBackupKeys(storageInstance).forEach((name) => {
+        const value = storageInstance.get(name, undefined);
+        if (value !== undefined) entries[name] = value;
+    });
+    return entries;
+}
+
+/**
+ * Creates a backup archive of the app data
+ * @param {Object} [options]
+ * @param {Storage} [options.storage] - Storage to read (dependency injection)
+ * @param {string|null} [options.profile] - Active profile, recorded in the manifest
+ * @param {string} [options.appVersion] - App version, recorded in the manifest
+ * @param {Date} [options.now] - Timestamp override (testing)
+ * @returns {Promise<{blob: Blob, fileName: string, count: number}>}
+ */
+export async function createAppDataBackup({
+    storage = defaultStorage,
+    profile = null,
+    appVersion = '',
+    now = new Date()
+} = {}) {
+    const entries = collectAppData(storage);
+    const JSZip = await loadJSZip();
+    const zip = new JSZip();
+
+    zip.file(
+        MANIFEST_FILE,
+        JSON.stringify(
+            {
+                format: APP_DATA_BACKUP_FORMAT,
+                version: APP_DATA_BACKUP_VERSION,
+                createdAt: now.toISOString(),
+                appVersion,
+                profile,
+                keys: Object.keys(entries)
+            },
+            null,
+            2
+        )
+    );
+    zip.file(DATA_FILE, JSON.stringify(entries, null, 2));
+
+    const blob = await zip.generateAsync({
+        type: 'blob',
+        compression: 'DEFLATE',
+        compressionOptions: { level: 6 }
+    });
+    const date = now.toISOString().slice(0, 10);
+    const suffix = profile ? `-${profile.replace(/\s+/g, '_')}` : '';
+
+    return {
+        blob,
+        fileName: `msgReader-backup${suffix}-${date}.zip`,
+        count: Object.keys(entries).length
+    };
+}
+
+/**
+ * Reads a backup archive
+ * @param {Blob|ArrayBuffer|Uint8Array} zipData - Archive created by createAppDataBackup
+ * @returns {Promise<{manifest: Object, entries: Object<string, *>}>}
+ * @throws {Error} If the archive is not a supported backup
+ */
+export async function readAppDataBackup(zipData) {
+    const JSZip = await loadJSZip();
+    let zip;
+    try {
+        zip = await JSZip.loadAsync(zipData);
+    } catch {
+        throw new Error('File is not a ZIP archive');
+    }
+
+    const manifestFile = zip.file(MANIFEST_FILE);
+    const dataFile = zip.file(DATA_FILE);
+    if (!manifestFile || !dataFile) {
+        throw new Error('Archive is not a msgReader backup');
+    }
+
+    let manifest;
+    let entries;
+    try {
+        manifest = JSON.parse(await manifestFile.async('string'));
+        entries = JSON.parse(await dataFile.async('string'));
+    } catch {
+        throw new Error('Backup is damaged');
+    }
+
+    if (manifest?.format !== APP_DATA_BACKUP_FORMAT) {
+        throw new Error('Archive is not a msgReader backup');
+    }
+    if (manifest.version > APP_DATA_BACKUP_VERSION) {
+        throw new Error('Backup was created by a newer version of msgReader');
+    }
+    if (!entries || typeof entries !== 'object' || Array.isArray(entries)) {
+        throw new Error('Backup is damaged');
+    }
+
+    return { manifest, entries };
+}
+
+/**
+ * Replaces the app data of the active profile with the data of a backup
+ * Keys not in the backup are removed, unknown keys in the backup are skipped.
+ * @param {Object<string, *>} entries - Values by key, from readAppDataBackup
+ * @param {Storage} [storageInstance] - Storage to write (dependency injection)
+ * @returns {{restored: number, skipped: string[]}}
+ */
+export function restoreAppData(entries, storageInstance = defaultStorage) {
+    const names = Object.keys(entries);
+    const skipped = names.filter((name) => !isRestorableKey(name));
+
+    listBackupKeys(storageInstance)
+        .filter((name) => !Object.prototype.hasOwnProperty.call(entries, name))
+        .forEach((name) => storageInstance.remove(name));
+
+    let restored = 0;
+    names.filter(isRestorableKey).forEach((name) => {
+        if (storageInstance.set(name, entries[name])) restored++;
+    });
+
+    return { restored, skipped };
+}
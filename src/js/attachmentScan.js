@@ -0,0 +1,96 @@
+/**
+ * Attachment Checks
+ * SHA-256/MD5 digests and the antivirus verdict of attachments. The backend of the desktop
+ * app computes them and scans with the scanner configured in scanner.json; it refuses to
+ * open, save or drag out attachments the scanner blocks, so the verdict shown here is only
+ * the explanation, not the enforcement.
+ */
+
+import { checkAttachment, isTauri } from './tauri-bridge.js';
+import { getDataUrlBase64 } from './encoding.js';
+
+/** Start of the errors the backend refuses blocked attachments with */
+const BLOCKED_ERROR_PREFIX = 'Blocked by the antivirus scanner';
+
+/** Names of the scanner engines shown to the user */
+const ENGINE_NAMES = {
+    clamd: 'ClamAV',
+    amsi: 'Windows antimalware'
+};
+
+/** @type {WeakMap<Object, Promise<Object|null>>} */
+const checks = new WeakMap();
+
+/**
+ * Digests and scan verdict of an attachment, requested once per attachment. Checks the
+ * scanner could not finish are requested again next time.
+ * @param {Object} attachment - Attachment with fileName and contentBase64
+ * @returns {Promise<Object|null>} Check from the backend, null outside the desktop app
+ */
+export function getAttachmentCheck(attachment) {
+    if (!isTauri() || !attachment?.contentBase64) {
+        return Promise.resolve(null);
+    }
+
+    if (!checks.has(attachment)) {
+        checks.set(
+            attachment,
+            checkAttachment(getDataUrlBase64(attachment.contentBase64), attachment.fileName)
+                .then((check) => {
+                    if (check.scan.status === 'failed') checks.delete(attachment);
+                    return check;
+                })
+                .catch((err) => {
+                    console.warn(`Could not check ${attachment.fileName}:`, err);
+                    checks.delete(attachment);
+                    return null;
+                })
+        );
+    }
+    return checks.get(attachment);
+}
+
+/**
+ * Text for the verdict line of an attachment
+ * @param {Object} check - Result of getAttachmentCheck
+ * @returns {{text: string, title: string, status: string}} Short verdict, digests for the
+ *     tooltip and the scan status (clean, infected, failed or unscanned)
+ */
+export function describeAttachmentCheck(check) {
+    const { scan } = check;
+    const engine = ENGINE_NAMES[scan.engine] || scan.engine;
+    const digests = `SHA-256: ${check.sha256}\nMD5: ${check.md5}`;
+
+    switch (scan.status) {
+        case 'clean':
+            return { text: `No threats found (${engine})`, title: digests, status: scan.status };
+        case 'infected':
+            return {
+                text: `Threat found: ${scan.threat}`,
+                title: `${engine}: ${scan.threat}\n${digests}`,
+                status: scan.status
+            };
+        case 'failed':
+            return {
+                text: scan.blocked ? 'Not scanned, blocked' : 'Not scanned',
+                title: `${scan.error}\n${digests}`,
+                status: scan.status
+            };
+        default:
+            return {
+                text: `SHA-256 ${check.sha256.slice(0, 12)}…`,
+                title: digests,
+                status: scan.status
+            };
+    }
+}
+
+/**
+ * The message of an error the backend refused a blocked attachment with
+ * @param {Error|string} error - Error of an open, save or drag request
+ * @returns {string|null} The message, null for other errors
+ */
+export function getScanBlockMessage(error) {
+    const message = String(error?.message ?? error ?? '');
+    return message.startsWith(BLOCKED_ERROR_PREFIX) ? message : null;
+}
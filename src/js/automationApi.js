@@ -0,0 +1,200 @@
+/**
+ * Automation API Module
+ * Handles requests from the desktop app's local automation endpoint
+ * (Unix socket / named pipe, see doc/automation.md) against the running app.
+ */
+
+import { Buffer } from 'buffer';
+import {
+    analyzeDeliveryPath,
+    onAutomationRequest,
+    respondAutomationRequest
+} from './tauri-bridge.js';
+import {
+    getExportFileName,
+    getOriginalMessageMimeType,
+    messageToEml,
+    messageToHtmlDocument,
+    messageToJson
+} from './messageExport.js';
+import { arrayBufferToBase64, textToBase64 } from './encoding.js';
+import { auditLog, AUDIT_ACTIONS } from './AuditLog.js';
+import { buildTimeline } from './timeline.js';
+import { SearchManager } from './SearchManager.js';
+
+export const AUTOMATION_EXPORT_FORMATS = ['eml', 'html', 'json', 'original'];
+
+/**
+ * Summarizes a message for automation responses
+ * @param {Object} message - Parsed message
+ * @param {number} index - Index in the message list
+ * @returns {Object} Summary
+ */
+export function summarizeMessage(message, index) {
+    return {
+        index,
+        messageHash: message.messageHash,
+        subject: message.subject || '',
+        senderName: message.senderName || '',
+        senderEmail: message.senderEmail || '',
+        date: message.timestamp instanceof Date ? message.timestamp.toISOString() : '',
+        fileName: message.fileName || '',
+        attachmentCount: (message.attachments || []).length
+    };
+}
+
+/**
+ * Converts a message for the export command
+ * @param {Object} message - Parsed message
+ * @param {string} format - One of AUTOMATION_EXPORT_FORMATS
+ * @returns {{fileName: string, mimeType: string, contentBase64: string}}
+ * @throws {Error} If the format is unsupported or unavailable for the message
+ */
+export function exportMessageContent(message, format) {
+    if (format === 'eml') {
+        return {
+            fileName: getExportFileName(message, 'eml'),
+            mimeType: 'message/rfc822',
+            contentBase64: textToBase64(messageToEml(message))
+        };
+    }
+    if (format === 'html') {
+        return {
+            fileName: getExportFileName(message, 'html'),
+            mimeType: 'text/html',
+            contentBase64: textToBase64(messageToHtmlDocument(message))
+        };
+    }
+    if (format === 'json') {
+        return {
+            fileName: getExportFileName(message, 'json'),
+            mimeType: 'application/json',
+            contentBase64: textToBase64(JSON.stringify(messageToJson(message), null, 2))
+        };
+    }
+    if (format === 'original') {
+        if (!message._rawBuffer) {
+            throw new Error('Original email file is not available');
+        }
+        return {
+            fileName: getExportFileName(message, 'original'),
+            mimeType: getOriginalMessageMimeType(message),
+            contentBase64: Buffer.from(message._rawBuffer).toString('base64')
+        };
+    }
+    throw new Error(`Unsupported export format: ${format}`);
+}
+
+/**
+ * Creates the request handler for an app instance
+ * @param {Object} app - App with messageHandler, uiManager and fileHandler
+ * @returns {Function} Async (command, params) => result
+ */
+export function createAutomationHandler(app) {
+    const summarizeAll = (messages) => {
+        const allMessages = app.messageHandler.getMessages();
+        return messages.map((message) => summarizeMessage(message, allMessages.indexOf(message)));
+    };
+
+    const findMessage = (params) => {
+        if (!params.messageHash) {
+            const current = app.messageHandler.getCurrentMessage();
+            if (!current) throw new Error('No message is open');
+            return current;
+        }
+
+        const message = app.messageHandler
+            .getMessages()
+            .find((candidate) => candidate.messageHash === params.messageHash);
+        if (!message) throw new Error(`Message not found: ${params.messageHash}`);
+        return message;
+    };
+
+    const handlers = {
+        list: () => ({ messages: summarizeAll(app.messageHandler.getMessages()) }),
+
+        open: async ({ paths }) => {
+            const before = app.messageHandler.getMessages().length;
+            await app.fileHandler.handleFilesFromPaths(paths);
+            const total = app.messageHandler.getMessages().length;
+            return { opened: total - before, total };
+        },
+
+        search: ({ query = '' }) => {
+            const results = app.uiManager.applySearch(String(query));
+            return { query: String(query), count: results.length, messages: summarizeAll(results) };
+        },
+
+        find: ({ query = '', sender = '', hasAttachments, after, before, limit }) => {
+            const searchManager =
+                app.uiManager.searchManager || new SearchManager(app.messageHandler);
+            const hits = searchManager.rank(String(query), {
+                sender: String(sender),
+                hasAttachments: typeof hasAttachments === 'boolean' ? hasAttachments : undefined,
+                after,
+                before
+            });
+            const allMessages = app.messageHandler.getMessages();
+            return {
+                query: String(query),
+                count: hits.length,
+                hits: hits.slice(0, Number.isInteger(limit) ? limit : undefined).map(
+                    ({ message, score }) => ({
+                        ...summarizeMessage(message, allMessages.indexOf(message)),
+                        score
+                    })
+                )
+            };
+        },
+
+        timeline: ({ bucket, sender = '', query = '', utc = false }) =>
+            buildTimeline(app.messageHandler.getMessages(), {
+                bucket,
+                sender: String(sender),
+                query: String(query),
+                utc: utc === true
+            }),
+
+        delivery: async (params) => {
+            const message = findMessage(params);
+            if (!message._rawBuffer) {
+                throw new Error('The original file of this message is not available');
+            }
+            return await analyzeDeliveryPath(arrayBufferToBase64(message._rawBuffer));
+        },
+
+        export: ({ format = 'eml', ...params }) => {
+            const message = findMessage(params);
+            const content = exportMessageContent(message, format);
+            auditLog.record(AUDIT_ACTIONS.EXPORT, {
+                message,
+                format,
+                detail: `${content.fileName} (automation)`
+            });
+            return content;
+        }
+    };
+
+    return async (command, params) => {
+        const handler = Object.hasOwn(handlers, command) ? handlers[command] : null;
+        if (!handler) throw new Error(`Unknown command: ${command}`);
+        return await handler(params || {});
+    };
+}
+
+/**
+ * Starts answering automation requests for the app
+ * @param {Object} app - App instance
+ * @returns {Promise<Function>} Unlisten function
+ */
+export async function initAutomationApi(app) {
+    const handle = createAutomationHandler(app);
+
+    return await onAutomationRequest(async ({ requestId, command, params }) => {
+        try {
+            await respondAutomationRequest(requestId, await handle(command, params));
+        } catch (error) {
+            await respondAutomationRequest(requestId, null, error?.message || String(error));
+        }
+    });
+}
@@ -0,0 +1,53 @@
+/**
+ * Backend Sanitizer
+ * In the desktop app the HTML body of a message is sanitized by the backend (ammonia) as
+ * soon as the message is parsed, before the viewer puts any of it into a DOM. Rendering
+ * still runs it through DOMPurify, so the web version and a failed backend call are no
+ * less safe than before.
+ */
+
+import { isTauri, sanitizeHtml } from './tauri-bridge.js';
+import { cleanContentId } from './helpers.js';
+
+/**
+ * URLs of the inline images the body still references with cid: (the parsers replace
+ * the others with data: URLs)
+ * @param {Object} msgInfo - Message
+ * @returns {Object<string, string>} Data URL by Content-ID
+ */
+function inlineImageUrls(msgInfo) {
+    const urls = {};
+    for (const attachment of msgInfo.attachments || []) {
+        const contentId = cleanContentId(attachment.contentId || attachment.pidContentId);
+        if (
+            contentId &&
+            attachment.contentBase64 &&
+            msgInfo.bodyContentHTML.includes(`cid:${contentId}`)
+        ) {
+            urls[contentId] = attachment.contentBase64;
+        }
+    }
+    return urls;
+}
+
+/**
+ * Replaces the HTML body of a message with the one the backend sanitized
+ * @param {Object} msgInfo - Parsed message
+ * @returns {Promise<Object>} The message; unchanged outside the desktop app or if the
+ *     backend failed
+ */
+export async function sanitizeMessageHtml(msgInfo) {
+    if (!isTauri() || !msgInfo?.bodyContentHTML) {
+        return msgInfo;
+    }
+
+    try {
+        msgInfo.bodyContentHTML = await sanitizeHtml(
+            msgInfo.bodyContentHTML,
+            inlineImageUrls(msgInfo)
+        );
+    } catch (err) {
+        console.warn('Could not sanitize the message in the backend:', err);
+    }
+    return msgInfo;
+}
@@ -4,6 +4,7 @@ import {
     messageToEml,
     messageToHtmlDocument
 } from './messageExport.js';
+import { sha256Hex, toBytes } from './hashing.js';
 
 export const BULK_EXPORT_FORMATS = {
     eml: {
@@ -113,9 +114,10 @@ function buildManifest({ messages, exportedEntries, skippedMessages, format, sco
         messageCount: messages.length,
         exportedCount: exportedEntries.length,
         skippedCount: skippedMessages.length,
-        messages: exportedEntries.map(({ message, fileName, mimeType }) => ({
+        messages: exportedEntries.map(({ message, fileName, mimeType, checksum }) => ({
             fileName,
             mimeType,
+            ...checksum,
             subject: message?.subject || '',
             senderName: message?.senderName || '',
             senderEmail: message?.senderEmail || '',
@@ -132,6 +134,19 @@ function buildManifest({ messages, exportedEntries, skippedMessages, format, sco
     };
 }
 
+/**
+ * Computes the size and SHA-256 of an archive entry as it will be stored
+ * @param {string|ArrayBuffer} content - Entry content (strings are stored as UTF-8)
+ * @returns {{size: number, sha256: string}}
+ */
+function computeChecksum(content) {
+    const bytes = toBytes(content);
+    return {
+        size: bytes.byteLength,
+        sha256: sha256Hex(bytes)
+    };
+}
+
 async function loadJSZip() {
     const { default: JSZip } = await import('./jszipLoader.js');
     return JSZip;
@@ -145,7 +160,8 @@ async function loadJSZip() {
  * @param {string} [options.scope='messages'] - Scope label used in archive metadata/name
  * @param {Date} [options.now=new Date()] - Timestamp used for manifest and file name
  * @param {Function} [options.onProgress] - Progress callback from JSZip
- * @returns {Promise<{blob: Blob, fileName: string, exportedCount: number, skippedCount: number}>}
+ * @param {boolean} [options.checksums=true] - Record size and SHA-256 of each file in the manifest
+ * @returns {Promise<{blob: Blob, fileName: string, exportedCount: number, skippedCount: number, exportedMessages: Array}>}
  */
 export async function createBulkExportZipBlob(messages, format, options = {}) {
     if (!BULK_EXPORT_FORMATS[format]) {
@@ -155,6 +171,7 @@ export async function createBulkExportZipBlob(messages, format, options = {}) {
     const requestedNow = options.now instanceof Date ? options.now : new Date();
     const now = Number.isNaN(requestedNow.getTime()) ? new Date() : requestedNow;
     const scope = options.scope || 'messages';
+    const includeChecksums = options.checksums !== false;
     const JSZip = await loadJSZip();
     const zip = new JSZip();
     const emailsFolder = zip.folder('emails');
@@ -174,7 +191,8 @@ export async function createBulkExportZipBlob(messages, format, options = {}) {
         exportedEntries.push({
             message,
             fileName,
-            mimeType: entry.mimeType
+            mimeType: entry.mimeType,
+            checksum: includeChecksums ? computeChecksum(entry.content) : null
         });
     });
 
@@ -215,6 +233,68 @@ export async function createBulkExportZipBlob(messages, format, options = {}) {
         blob,
         fileName,
         exportedCount: exportedEntries.length,
-        skippedCount: skippedMessages.length
+        skippedCount: skippedMessages.length,
+        exportedMessages: exportedEntries.map(({ message }) => message)
+    };
+}
+
+/**
+ * Re-verifies a previously exported ZIP against the checksums in its manifest.
+ * @param {Blob|ArrayBuffer|Uint8Array} zipData - Exported archive
+ * @returns {Promise<{valid: boolean, checkedCount: number, mismatches: Array<{fileName: string, reason: string}>, unlisted: Array<string>}>}
+ *   `reason` is one of `missing`, `size`, `sha256`, or `no-checksum`
+ */
+export async function verifyBulkExportZip(zipData) {
+    const JSZip = await loadJSZip();
+    const zip = await JSZip.loadAsync(zipData);
+    const manifestFile = zip.file('manifest.json');
+    if (!manifestFile) {
+        throw new Error('Archive does not contain a manifest.json');
+    }
+
+    let manifest;
+    try {
+        manifest = JSON.parse(await manifestFile.async('string'));
+    } catch {
+        throw new Error('manifest.json is not valid JSON');
+    }
+
+    const listedEntries = Array.isArray(manifest?.messages) ? manifest.messages : [];
+    const listedNames = new Set();
+    const mismatches = [];
+    let checkedCount = 0;
+
+    for (const listed of listedEntries) {
+        const path = `emails/${listed.fileName}`;
+        listedNames.add(path);
+        const file = zip.file(path);
+
+        if (!file) {
+            mismatches.push({ fileName: listed.fileName, reason: 'missing' });
+            continue;
+        }
+        if (!listed.sha256) {
+            mismatches.push({ fileName: listed.fileName, reason: 'no-checksum' });
+            continue;
+        }
+
+        const actual = computeChecksum(await file.async('uint8array'));
+        checkedCount += 1;
+        if (actual.size !== listed.size) {
+            mismatches.push({ fileName: listed.fileName, reason: 'size' });
+        } else if (actual.sha256 !== listed.sha256) {
+            mismatches.push({ fileName: listed.fileName, reason: 'sha256' });
+        }
+    }
+
+    const unlisted = Object.keys(zip.files).filter(
+        (path) => path.startsWith('emails/') && !zip.files[path].dir && !listedNames.has(path)
+    );
+
+    return {
+        valid: mismatches.length === 0 && unlisted.length === 0,
+        checkedCount,
+        mismatches,
+        unlisted
     };
 }
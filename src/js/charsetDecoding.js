@@ -0,0 +1,34 @@
+/**
+ * Charset Decoding
+ * iconv-lite has no decoder for some charsets mail still uses, notably ISO-2022-JP and the
+ * ISO-2022-KR/-CN variants, and decodes such text as UTF-8 (mojibake). In the desktop app
+ * the parser runs once to collect that text, the backend decodes it with encoding_rs and
+ * the parser runs again with the result.
+ */
+
+import { decodeCharset, isTauri } from './tauri-bridge.js';
+import { collectUnsupportedDecodings, withExternalDecodings } from './encoding.js';
+
+/**
+ * Runs a parser, decoding text in charsets iconv-lite lacks with the backend
+ * @param {Function} parse - Synchronous parser call, e.g. () => extractEml(buffer)
+ * @returns {Promise<Object>} Result of parse; of the second run if the backend decoded text
+ */
+export async function parseWithBackendCharsets(parse) {
+    const { result, unsupported } = collectUnsupportedDecodings(parse);
+    if (unsupported.length === 0 || !isTauri()) {
+        return result;
+    }
+
+    const decodings = new Map();
+    await Promise.all(
+        unsupported.map(async ({ key, charset, base64 }) => {
+            try {
+                decodings.set(key, await decodeCharset(base64, charset));
+            } catch (err) {
+                console.warn(`Could not decode ${charset} text:`, err);
+            }
+        })
+    );
+    return decodings.size > 0 ? withExternalDecodings(decodings, parse) : result;
+}
@@ -0,0 +1,202 @@
+/**
+ * Command Line Module
+ * Headless conversion of MSG/EML data for scripts and pipelines, using the same
+ * parsers and exporters as the app (library.js). Entry point: bin/msgreader.js
+ */
+
+import { INPUT_TYPES, OUTPUT_FORMATS, convert, detectInputType } from './library.js';
+
+export { INPUT_TYPES, OUTPUT_FORMATS, detectInputType };
+
+export const USAGE = `Usage: msgreader [convert] (--stdin | <file>) [--type msg|eml] [--to json|eml|html]
+                 [-o <file>] [--no-attachments]
+
+Options:
+  --stdin            Read the message from standard input
+  --type <type>      Input type (default: file extension, or detected from content)
+  --to <format>      Output format (default: json)
+  -o, --output <file> Write the output to a file instead of standard output
+  --no-attachments   Omit attachment content from JSON output
+  -h, --help         Show this help
+
+The desktop app's msgreader takes the same convert command, and also converts to pdf
+and has the extract-attachments and headers commands.`;
+
+// Commands of the desktop app's command line (src-tauri/src/cli.rs) that need its
+// native parsers
+const DESKTOP_COMMANDS = ['extract-attachments', 'headers'];
+
+/**
+ * Parses command line arguments
+ * @param {string[]} argv - Arguments without the node and script path
+ * @returns {Object} Parsed options
+ * @throws {Error} On unknown or invalid arguments
+ */
+export function parseCliArgs(argv) {
+    if (DESKTOP_COMMANDS.includes(argv[0])) {
+        throw new Error(`${argv[0]} is only available in the desktop app`);
+    }
+    // "convert" is optional here, the desktop app needs it
+    if (argv[0] === 'convert') {
+        argv = argv.slice(1);
+    }
+
+    const options = {
+        stdin: false,
+        input: null,
+        type: null,
+        to: 'json',
+        output: null,
+        includeAttachmentContent: true,
+        help: false
+    };
+
+    const readValue = (index, name, { lowerCase = true } = {}) => {
+        const value = argv[index + 1];
+        if (!value || value.startsWith('--')) {
+            throw new Error(`Missing value for ${name}`);
+        }
+        return lowerCase ? value.toLowerCase() : value;
+    };
+
+    for (let i = 0; i < argv.length; i++) {
+        const arg = argv[i];
+        if (arg === '--stdin') {
+            options.stdin = true;
+        } else if (arg === '--type') {
+            options.type = readValue(i++, arg);
+        } else if (arg === '--to') {
+            options.to = readValue(i++, arg);
+        } else if (arg === '-o' || arg === '--output') {
+            options.output = readValue(i++, arg, { lowerCase: false });
+        } else if (arg === '--no-attachments') {
+            options.includeAttachmentContent = false;
+        } else if (arg === '-h' || arg === '--help') {
+            options.help = true;
+        } else if (arg.startsWith('-') && arg !== '-') {
+            throw new Error(`Unknown option: ${arg}`);
+        } else if (options.input) {
+            throw new Error(`Unexpected argument: ${arg}`);
+        } else {
+            options.input = arg;
+        }
+    }
+
+    if (options.help) return options;
+
+    // "-" is the conventional name for standard input
+    if (options.input === '-') {
+        options.stdin = true;
+        options.input = null;
+    }
+    if (options.stdin && options.input) {
+        throw new Error('Use either --stdin or a file, not both');
+    }
+    if (!options.stdin && !options.input) {
+        throw new Error('No input: pass a file or --stdin');
+    }
+    if (options.type && !INPUT_TYPES.includes(options.type)) {
+        throw new Error(`Unsupported input type: ${options.type}`);
+    }
+    if (!OUTPUT_FORMATS.includes(options.to)) {
+        throw new Error(`Unsupported output format: ${options.to}`);
+    }
+
+    return options;
+}
+
+/**
+ * Parses message data and converts it to the requested format (see convert in
+ * library.js)
+ * @param {ArrayBuffer} buffer - Message data
+ * @param {Object} [options] - Conversion options
+ * @returns {string} Converted message
+ * @throws {Error} If the data cannot be parsed
+ */
+export function convertMessage(buffer, options = {}) {
+    return convert(buffer, options);
+}
+
+/**
+ * Reads a readable stream to an ArrayBuffer
+ * @param {AsyncIterable<Uint8Array|string>} stream - Input stream
+ * @returns {Promise<ArrayBuffer>} Stream content
+ */
+export async function readStream(stream) {
+    const chunks = [];
+    let length = 0;
+    for await (const chunk of stream) {
+        const bytes = typeof chunk === 'string' ? new TextEncoder().encode(chunk) : chunk;
+        chunks.push(bytes);
+        length += bytes.length;
+    }
+
+    const result = new Uint8Array(length);
+    let offset = 0;
+    chunks.forEach((chunk) => {
+        result.set(chunk, offset);
+        offset += chunk.length;
+    });
+    return result.buffer;
+}
+
+/**
+ * Runs the command line interface
+ * @param {string[]} argv - Arguments without the node and script path
+ * @param {Object} io - Streams and file access
+ * @param {AsyncIterable} io.stdin - Standard input
+ * @param {{write: Function}} io.stdout - Standard output
+ * @param {{write: Function}} io.stderr - Standard error
+ * @param {Function} [io.readFile] - Reads a path, resolves to a Buffer/Uint8Array
+ * @param {Function} [io.writeFile] - Writes a string to a path (for --output)
+ * @returns {Promise<number>} Exit code
+ */
+export async function runCli(argv, io) {
+    let options;
+    try {
+        options = parseCliArgs(argv);
+    } catch (error) {
+        io.stderr.write(`msgreader: ${error.message}\n\n${USAGE}\n`);
+        return 2;
+    }
+
+    if (options.help) {
+        io.stdout.write(`${USAGE}\n`);
+        return 0;
+    }
+
+    try {
+        let buffer;
+        let fileName;
+        let type = options.type;
+        if (options.stdin) {
+            buffer = await readStream(io.stdin);
+        } else {
+            const data = await io.readFile(options.input);
+            buffer = data.buffer.slice(data.byteOffset, data.byteOffset + data.byteLength);
+            fileName = options.input.split(/[\\/]/).pop();
+            const extension = fileName.toLowerCase().split('.').pop();
+            if (!type && INPUT_TYPES.includes(extension)) type = extension;
+        }
+
+        if (buffer.byteLength === 0) {
+            throw new Error('Input is empty');
+        }
+
+        const output = convertMessage(buffer, {
+            type,
+            to: options.to,
+            fileName,
+            includeAttachmentContent: options.includeAttachmentContent
+        });
+        if (options.output) {
+            await io.writeFile(options.output, output);
+        } else {
+            io.stdout.write(output);
+        }
+        return 0;
+    } catch (error) {
+        io.stderr.write(`msgreader: ${error.message}\n`);
+        return 1;
+    }
+}
@@ -38,6 +38,12 @@ export const CHARSET_CODES = {
     932: 'shift_jis', // Japanese (Shift_JIS)
     949: 'cp949', // Korean (CP949/EUC-KR)
     928: 'gb2312', // Simplified Chinese (GB2312)
+    20932: 'euc-jp', // Japanese (JIS X 0208-1990 and 0212-1990)
+    50220: 'iso-2022-jp', // Japanese (JIS)
+    50221: 'iso-2022-jp', // Japanese (JIS, 1 byte Kana)
+    50222: 'iso-2022-jp', // Japanese (JIS, 1 byte Kana SO/SI)
+    50225: 'iso-2022-kr', // Korean (ISO-2022-KR)
+    50227: 'iso-2022-cn', // Simplified Chinese (ISO-2022-CN)
     51949: 'euc-kr',
     54936: 'gb18030',
     65001: 'utf-8'
@@ -156,6 +162,19 @@ export const DANGEROUS_EXTENSIONS = new Set([
  */
 export const SUPPORTED_EMAIL_EXTENSIONS = ['msg', 'eml'];
 
+/**
+ * File types, as detected from the content, that open as a single message; 'tnef' is a
+ * winmail.dat file saved on its own
+ */
+export const MESSAGE_FILE_TYPES = ['msg', 'eml', 'tnef'];
+
+/**
+ * Files holding many messages (Outlook data files, mbox files and ZIP archives), opened in
+ * a browser instead of the message list, and gzip-compressed messages, which the backend
+ * unpacks (desktop app only)
+ */
+export const DATA_FILE_EXTENSIONS = ['pst', 'ost', 'mbox', 'zip', 'gz'];
+
 /**
  * Default charset for email content
  */
@@ -0,0 +1,106 @@
+/**
+ * Contact Module
+ * Reads Outlook contacts (.msg files of class IPM.Contact) in the desktop backend, which
+ * also saves them as vCard files that address books and phones can import.
+ */
+
+import { exportToVcf, parseContact } from './tauri-bridge.js';
+import { arrayBufferToBase64 } from './encoding.js';
+
+const PHONE_LABELS = {
+    work: 'Work',
+    main: 'Company',
+    home: 'Home',
+    cell: 'Mobile',
+    car: 'Car',
+    pager: 'Pager',
+    fax: 'Fax',
+    other: 'Other'
+};
+
+const ADDRESS_LABELS = {
+    work: 'Work',
+    home: 'Home',
+    other: 'Other'
+};
+
+/** @type {WeakMap<Object, Promise<Object|null>>} */
+const contacts = new WeakMap();
+
+/**
+ * Whether a message is an Outlook contact
+ * @param {Object} message - Parsed message with its original file (_rawBuffer)
+ * @returns {boolean}
+ */
+export function isContact(message) {
+    if (!message?._rawBuffer || message._fileType !== 'msg') return false;
+    return /^IPM\.Contact(\.|$)/i.test(message.messageClass || '');
+}
+
+/**
+ * Reads the contact of a message once
+ * @param {Object} message - Parsed message with its original file (_rawBuffer)
+ * @returns {Promise<Object|null>} Contact from the backend (see parseContact), null if the
+ *     message is none
+ */
+export function loadContact(message) {
+    if (!isContact(message)) return Promise.resolve(null);
+    if (!contacts.has(message)) {
+        const contact = parseContact(arrayBufferToBase64(message._rawBuffer));
+        // A failed read can be retried when the message is shown again
+        contact.catch(() => contacts.delete(message));
+        contacts.set(message, contact);
+    }
+    return contacts.get(message);
+}
+
+/**
+ * Saves the contact of a message as a .vcf file chosen in a save dialog
+ * @param {Object} message - Parsed message with its original file (_rawBuffer)
+ * @returns {Promise<string|null>} Path of the file, null if cancelled
+ */
+export async function exportContact(message) {
+    if (!message?._rawBuffer) {
+        throw new Error('The original file of this message is not available');
+    }
+    return await exportToVcf(arrayBufferToBase64(message._rawBuffer));
+}
+
+/**
+ * Describes a phone number, e.g. "Mobile: +49 170 1234567"
+ * @param {{kind: string, number: string}} phone
+ * @returns {string}
+ */
+export function describePhone(phone) {
+    return `${PHONE_LABELS[phone.kind] || PHONE_LABELS.other}: ${phone.number}`;
+}
+
+/**
+ * Writes a postal address on one line, e.g. "Work: Main St 1, 12345 Springfield, USA"
+ * @param {Object} address - Address with kind, poBox, street, city, region, postalCode
+ *     and country
+ * @returns {string}
+ */
+export function describeAddress(address) {
+    const place = [address.postalCode, address.city].filter(Boolean).join(' ');
+    const parts = [address.poBox, address.street, place, address.region, address.country]
+        .map((part) => (part || '').replace(/\s*\n\s*/g, ', ').trim())
+        .filter(Boolean);
+    return `${ADDRESS_LABELS[address.kind] || ADDRESS_LABELS.other}: ${parts.join(', ')}`;
+}
+
+/**
+ * A date of a contact (birthday, anniversary), e.g. "Jul 10, 1984"
+ * @param {number|null} date - UTC midnight of the day in ms
+ * @param {string} [locale] - Locale for the date, the user's by default
+ * @returns {string} Empty without a date
+ */
+export function formatContactDate(date, locale = undefined) {
+    if (date == null) return '';
+    return new Date(date).toLocaleDateString(locale, {
+        year: 'numeric',
+        month: 'short',
+        day: 'numeric',
+        timeZone: 'UTC'
+    });
+}
@@ -0,0 +1,129 @@
+/**
+ * Chain-of-Custody Report Module
+ * Documents, for a set of messages, where each file came from, its hashes,
+ * when it was parsed, and which actions the audit log recorded for it.
+ */
+
+import { AUDIT_ACTIONS, auditLog } from './AuditLog.js';
+import { getMessageFileSha256 } from './hashing.js';
+import { createTextPdf } from './pdfWriter.js';
+
+/**
+ * Collects the custody details for each message
+ * @param {Array} messages - Messages to document
+ * @param {Array<Object>} auditEntries - Audit log entries
+ * @returns {Array<Object>} One record per message
+ */
+export function collectCustodyRecords(messages, auditEntries = []) {
+    return messages.map((message) => {
+        const operations = auditEntries.filter(
+            (entry) =>
+                entry.messageHash &&
+                entry.messageHash === message.messageHash &&
+                entry.action !== AUDIT_ACTIONS.OPEN
+        );
+        const openEntry = auditEntries.find(
+            (entry) => entry.messageHash === message.messageHash && entry.action === AUDIT_ACTIONS.OPEN
+        );
+
+        return {
+            subject: message.subject || '(No subject)',
+            fileName: message.fileName || '',
+            sourcePath: message._sourcePath || openEntry?.sourcePath || '',
+            fileSize: message._rawBuffer?.byteLength ?? null,
+            fileSha256: getMessageFileSha256(message),
+            messageHash: message.messageHash || '',
+            parsedAt: message._parsedAt || openEntry?.timestamp || '',
+            operations
+        };
+    });
+}
+
+function describeOperation(entry) {
+    const target = entry.attachmentName ? ` "${entry.attachmentName}"` : '';
+    const format = entry.format ? ` (${entry.format})` : '';
+    return `${entry.timestamp}  #${entry.sequence}  ${entry.action}${format}${target}`;
+}
+
+/**
+ * Builds the report as styled text lines for the PDF writer
+ * @param {Array<Object>} records - Records from collectCustodyRecords
+ * @param {Object} options - Report options
+ * @param {Date} options.now - Report generation time
+ * @param {boolean} options.auditLogEnabled - Whether actions are currently being recorded
+ * @param {{valid: boolean, brokenAt: number|null}} options.integrity - Audit log verification result
+ * @returns {Array<{text: string, style: string}|null>} Report lines
+ */
+export function buildCustodyReportLines(records, { now, auditLogEnabled, integrity }) {
+    const lines = [
+        { text: 'Chain-of-Custody Report', style: 'title' },
+        { text: `Generated: ${now.toISOString()}`, style: 'body' },
+        { text: `Messages: ${records.length}`, style: 'body' },
+        {
+            text: `Audit log: ${auditLogEnabled ? 'recording' : 'not recording'}, integrity ${
+                integrity.valid ? 'verified' : `broken at entry ${integrity.brokenAt}`
+            }`,
+            style: 'body'
+        }
+    ];
+
+    if (!auditLogEnabled) {
+        lines.push({
+            text: 'Export operations are only listed for actions recorded while the audit log was enabled.',
+            style: 'body'
+        });
+    }
+
+    records.forEach((record, index) => {
+        lines.push(null, { text: `${index + 1}. ${record.subject}`, style: 'heading' });
+        lines.push({ text: `File: ${record.fileName || 'unknown'}`, style: 'body' });
+        lines.push({
+            text: `Source path: ${record.sourcePath || 'not available (opened in browser)'}`,
+            style: 'body'
+        });
+        lines.push({
+            text: `Size: ${record.fileSize === null ? 'unknown' : `${record.fileSize} bytes`}`,
+            style: 'body'
+        });
+        lines.push({ text: `Parsed at: ${record.parsedAt || 'unknown'}`, style: 'body' });
+        lines.push({ text: 'File SHA-256:', style: 'body' });
+        lines.push({ text: record.fileSha256 || 'not available', style: 'mono' });
+        lines.push({ text: `Message hash (MD5 of metadata): ${record.messageHash}`, style: 'body' });
+
+        if (record.operations.length === 0) {
+            lines.push({ text: 'Recorded operations: none', style: 'body' });
+        } else {
+            lines.push({ text: 'Recorded operations:', style: 'body' });
+            record.operations.forEach((entry) => {
+                lines.push({ text: describeOperation(entry), style: 'mono' });
+            });
+        }
+    });
+
+    return lines;
+}
+
+/**
+ * Creates a chain-of-custody PDF for the given messages
+ * @param {Array} messages - Messages to document
+ * @param {Object} [options] - Report options
+ * @param {AuditLog} [options.log=auditLog] - Audit log to read operations from
+ * @param {Date} [options.now=new Date()] - Report generation time
+ * @returns {{blob: Blob, fileName: string}} PDF blob and suggested file name
+ */
+export function createCustodyReportPdf(messages, options = {}) {
+    const log = options.log || auditLog;
+    const now = options.now instanceof Date ? options.now : new Date();
+    const records = collectCustodyRecords(messages, log.getEntries());
+    const lines = buildCustodyReportLines(records, {
+        now,
+        auditLogEnabled: log.isEnabled(),
+        integrity: log.verify()
+    });
+    const bytes = createTextPdf(lines, { title: 'Chain-of-Custody Report', now });
+
+    return {
+        blob: new Blob([bytes], { type: 'application/pdf' }),
+        fileName: `msgReader-custody-report-${now.toISOString().slice(0, 10)}.pdf`
+    };
+}
@@ -0,0 +1,448 @@
+/**
+ * Data Encryption Module
+ * Optionally encrypts the app data kept in local storage (settings, pinned messages,
+ * audit log, usage statistics) with a key derived from a user passphrase. Values are
+ * decrypted into memory once when unlocking, so reads stay synchronous; writes are
+ * encrypted in the background before they reach the storage backend.
+ * Encryption is per profile. The key can be kept in the OS keychain (desktop only).
+ */
+
+import { isProfileDataKey } from './profiles.js';
+import { storage as defaultStorage } from './storage.js';
+
+/** Salt and passphrase check of the current profile, stored unencrypted */
+export const ENCRYPTION_STORAGE_KEY = 'msgReader_encryption';
+/** Marks encrypted values in the storage backend */
+export const ENCRYPTED_VALUE_PREFIX = 'enc:v1:';
+export const MIN_PASSPHRASE_LENGTH = 8;
+
+const PBKDF2_ITERATIONS = 310000;
+const SALT_BYTES = 16;
+const IV_BYTES = 12;
+const CHECK_PLAINTEXT = 'msgReader';
+
+/**
+ * Encodes bytes as base64
+ * @param {Uint8Array} bytes
+ * @returns {string}
+ */
+function toBase64(bytes) {
+    let binary = '';
+    bytes.forEach((byte) => {
+        binary += String.fromCharCode(byte);
+    });
+    return btoa(binary);
+}
+
+/**
+ * Decodes base64 into bytes
+ * @param {string} text
+ * @returns {Uint8Array}
+ */
+function fromBase64(text) {
+    return Uint8Array.from(atob(text), (char) => char.charCodeAt(0));
+}
+
+/**
+ * Checks whether a backend key holds app data that is encrypted. The encryption
+ * metadata and the settings shared by all profiles stay readable, and keys of
+ * other applications on the same origin are left alone.
+ * @param {string} key - Backend key
+ * @param {string} [namespace] - Key prefix of the active profile
+ * @returns {boolean}
+ */
+export function isEncryptedKey(key, namespace = '') {
+    return isProfileDataKey(key, namespace) && key !== `${namespace}${ENCRYPTION_STORAGE_KEY}`;
+}
+
+/**
+ * Gets the keychain entry holding the key of a profile
+ * @param {string|null} profile - Profile name, null for the default profile
+ * @returns {string}
+ */
+export function getKeychainAccount(profile) {
+    return profile ? `data-key:${profile}` : 'data-key';
+}
+
+/**
+ * Derives an AES-GCM key from a passphrase
+ * @param {Crypto} cryptoImpl - Web Crypto implementation
+ * @param {string} passphrase - User passphrase
+ * @param {Uint8Array} salt - Random salt
+ * @param {Object} [options]
+ * @param {number} [options.iterations] - PBKDF2 iterations
+ * @param {boolean} [options.extractable] - Whether the key can be exported to the keychain
+ * @returns {Promise<CryptoKey>}
+ */
+export async function deriveKey(
+    cryptoImpl,
+    passphrase,
+    salt,
+    { iterations = PBKDF2_ITERATIONS, extractable = false } = {}
+) {
+    const material = await cryptoImpl.subtle.importKey(
+        'raw',
+        new TextEncoder().encode(passphrase),
+        'PBKDF2',
+        false,
+        ['deriveKey']
+    );
+    return cryptoImpl.subtle.deriveKey(
+        { name: 'PBKDF2', salt, iterations, hash: 'SHA-256' },
+        material,
+        { name: 'AES-GCM', length: 256 },
+        extractable,
+        ['encrypt', 'decrypt']
+    );
+}
+
+/**
+ * Encrypts a string
+ * @param {Crypto} cryptoImpl - Web Crypto implementation
+ * @param {CryptoKey} key - AES-GCM key
+ * @param {string} text - Plain text
+ * @returns {Promise<string>} ENCRYPTED_VALUE_PREFIX, base64 IV, ':' and base64 ciphertext
+ */
+export async function encryptValue(cryptoImpl, key, text) {
+    const iv = cryptoImpl.getRandomValues(new Uint8Array(IV_BYTES));
+    const data = await cryptoImpl.subtle.encrypt(
+        { name: 'AES-GCM', iv },
+        key,
+        new TextEncoder().encode(text)
+    );
+    return `${ENCRYPTED_VALUE_PREFIX}${toBase64(iv)}:${toBase64(new Uint8Array(data))}`;
+}
+
+/**
+ * Decrypts a string produced by encryptValue
+ * @param {Crypto} cryptoImpl - Web Crypto implementation
+ * @param {CryptoKey} key - AES-GCM key
+ * @param {string} value - Encrypted value
+ * @returns {Promise<string>} Plain text
+ * @throws {Error} If the value is malformed or the key is wrong
+ */
+export async function decryptValue(cryptoImpl, key, value) {
+    if (typeof value !== 'string' || !value.startsWith(ENCRYPTED_VALUE_PREFIX)) {
+        throw new Error('Value is not encrypted');
+    }
+    const [iv, data] = value.slice(ENCRYPTED_VALUE_PREFIX.length).split(':');
+    const text = await cryptoImpl.subtle.decrypt(
+        { name: 'AES-GCM', iv: fromBase64(iv || '') },
+        key,
+        fromBase64(data || '')
+    );
+    return new TextDecoder().decode(text);
+}
+
+/**
+ * Storage backend that keeps app data encrypted in another backend
+ * Implements the part of the Web Storage interface used by Storage.
+ */
+export class EncryptedBackend {
+    /**
+     * @param {Object} backend - Backend holding the encrypted values, e.g. localStorage
+     * @param {CryptoKey} key - AES-GCM key
+     * @param {string} namespace - Key prefix of the active profile
+     * @param {Crypto} cryptoImpl - Web Crypto implementation
+     */
+    constructor(backend, key, namespace, cryptoImpl) {
+        this.backend = backend;
+        this.key = key;
+        this.namespace = namespace;
+        this.crypto = cryptoImpl;
+        this.values = new Map();
+        this.writes = new Set();
+    }
+
+    /**
+     * Decrypts all encrypted values of the profile into memory
+     * @returns {Promise<void>}
+     * @throws {Error} If a value cannot be decrypted with the key
+     */
+    async load() {
+        for (const key of this.encryptedKeys()) {
+            const raw = this.backend.getItem(key);
+            if (raw !== null && raw.startsWith(ENCRYPTED_VALUE_PREFIX)) {
+                this.values.set(key, await decryptValue(this.crypto, this.key, raw));
+            }
+        }
+    }
+
+    /**
+     * Lists backend keys whose values are encrypted
+     * @returns {string[]}
+     */
+    encryptedKeys() {
+        const keys = [];
+        for (let i = 0; i < this.backend.length; i++) {
+            const key = this.backend.key(i);
+            if (isEncryptedKey(key, this.namespace)) keys.push(key);
+        }
+        return keys;
+    }
+
+    getItem(key) {
+        if (this.values.has(key)) return this.values.get(key);
+
+        // Values written before encryption was turned on are read as they are
+        const raw = this.backend.getItem(key);
+        return raw !== null && raw.startsWith(ENCRYPTED_VALUE_PREFIX) ? null : raw;
+    }
+
+    setItem(key, value) {
+        if (!isEncryptedKey(key, this.namespace)) {
+            this.backend.setItem(key, value);
+            return;
+        }
+
+        const text = String(value);
+        this.values.set(key, text);
+        const write = encryptValue(this.crypto, this.key, text)
+            .then((encrypted) => {
+                // Skip if the value changed or was removed in the meantime
+                if (this.values.get(key) === text) this.backend.setItem(key, encrypted);
+            })
+            .catch((error) => {
+                console.error(`EncryptedBackend: Error writing '${key}':`, error);
+            })
+            .finally(() => this.writes.delete(write));
+        this.writes.add(write);
+    }
+
+    removeItem(key) {
+        this.values.delete(key);
+        this.backend.removeItem(key);
+    }
+
+    key(index) {
+        return this.backend.key(index);
+    }
+
+    get length() {
+        return this.backend.length;
+    }
+
+    clear() {
+        this.values.clear();
+        this.backend.clear();
+    }
+
+    /**
+     * Waits until all pending writes reached the backend
+     * @returns {Promise<void>}
+     */
+    async flush() {
+        await Promise.all([...this.writes]);
+    }
+}
+
+export class DataEncryption {
+    /**
+     * @param {Storage} [storageInstance] - Storage to encrypt (dependency injection)
+     * @param {Crypto} [cryptoImpl] - Web Crypto implementation (dependency injection)
+     */
+    constructor(storageInstance = null, cryptoImpl = null) {
+        this.storage = storageInstance || defaultStorage;
+        this.crypto = cryptoImpl || globalThis.crypto || null;
+        this.key = null;
+    }
+
+    /**
+     * Checks whether Web Crypto is available (it needs a secure context)
+     * @returns {boolean}
+     */
+    isSupported() {
+        return Boolean(this.crypto?.subtle);
+    }
+
+    /**
+     * Gets the encryption metadata of the active profile
+     * @returns {{version: number, iterations: number, salt: string, check: string}|null}
+     */
+    getMetadata() {
+        const metadata = this.storage.get(ENCRYPTION_STORAGE_KEY, null);
+        return metadata?.salt && metadata?.check ? metadata : null;
+    }
+
+    /**
+     * Checks whether the data of the active profile is encrypted
+     * @returns {boolean}
+     */
+    isEnabled() {
+        return this.getMetadata() !== null;
+    }
+
+    /**
+     * Checks whether the data has been decrypted for this session
+     * @returns {boolean}
+     */
+    isUnlocked() {
+        return this.storage.backend instanceof EncryptedBackend;
+    }
+
+    /**
+     * Unlocks the data with a passphrase
+     * @param {string} passphrase - User passphrase
+     * @param {Object} [options]
+     * @param {boolean} [options.extractable] - Whether the key can be exported afterwards
+     * @returns {Promise<boolean>} False if the passphrase is wrong
+     */
+    async unlock(passphrase, { extractable = false } = {}) {
+        const metadata = this.getMetadata();
+        if (!metadata || !this.isSupported()) return false;
+
+        const key = await deriveKey(this.crypto, passphrase, fromBase64(metadata.salt), {
+            iterations: metadata.iterations,
+            extractable
+        });
+        return this.unlockWithKey(key);
+    }
+
+    /**
+     * Unlocks the data with a key exported by exportKey, e.g. from the keychain
+     * @param {string} rawKey - Base64 key
+     * @returns {Promise<boolean>} False if the key is wrong
+     */
+    async unlockWithRawKey(rawKey) {
+        if (!this.isSupported()) return false;
+
+        try {
+            const key = await this.crypto.subtle.importKey(
+                'raw',
+                fromBase64(rawKey),
+                'AES-GCM',
+                true,
+                ['encrypt', 'decrypt']
+            );
+            return await this.unlockWithKey(key);
+        } catch {
+            return false;
+        }
+    }
+
+    /**
+     * Verifies a key and decrypts the data into memory
+     * @param {CryptoKey} key - AES-GCM key
+     * @returns {Promise<boolean>} False if the key is wrong
+     */
+    async unlockWithKey(key) {
+        const metadata = this.getMetadata();
+        if (!metadata || this.isUnlocked()) return false;
+
+        const backend = new EncryptedBackend(
+            this.storage.backend,
+            key,
+            this.storage.namespace,
+            this.crypto
+        );
+        try {
+            if ((await decryptValue(this.crypto, key, metadata.check)) !== CHECK_PLAINTEXT) {
+                return false;
+            }
+            await backend.load();
+        } catch {
+            return false;
+        }
+
+        this.storage.backend = backend;
+        this.key = key;
+        return true;
+    }
+
+    /**
+     * Turns on encryption for the active profile and encrypts its existing data
+     * @param {string} passphrase - New passphrase, at least MIN_PASSPHRASE_LENGTH characters
+     * @param {Object} [options]
+     * @param {boolean} [options.extractable] - Whether the key can be exported to the keychain
+     * @returns {Promise<boolean>} False if already enabled, unsupported or the passphrase is
+     *     too short
+     */
+    async enable(passphrase, { extractable = false } = {}) {
+        if (this.isEnabled() || !this.isSupported()) return false;
+        if (typeof passphrase !== 'string' || passphrase.length < MIN_PASSPHRASE_LENGTH) {
+            return false;
+        }
+
+        const salt = this.crypto.getRandomValues(new Uint8Array(SALT_BYTES));
+        const key = await deriveKey(this.crypto, passphrase, salt, { extractable });
+        const backend = new EncryptedBackend(
+            this.storage.backend,
+            key,
+            this.storage.namespace,
+            this.crypto
+        );
+
+        // Metadata first: data still in plain text after an interruption stays readable
+        this.storage.set(ENCRYPTION_STORAGE_KEY, {
+            version: 1,
+            iterations: PBKDF2_ITERATIONS,
+            salt: toBase64(salt),
+            check: await encryptValue(this.crypto, key, CHECK_PLAINTEXT)
+        });
+        backend.encryptedKeys().forEach((name) => {
+            const raw = backend.backend.getItem(name);
+            if (raw !== null) backend.setItem(name, raw);
+        });
+        await backend.flush();
+
+        this.storage.backend = backend;
+        this.key = key;
+        return true;
+    }
+
+    /**
+     * Turns off encryption and writes the data back in plain text
+     * @returns {Promise<boolean>} False if the data is not unlocked
+     */
+    async disable() {
+        if (!this.isUnlocked()) return false;
+
+        const backend = this.storage.backend;
+        await backend.flush();
+        backend.values.forEach((value, name) => backend.backend.setItem(name, value));
+
+        this.storage.backend = backend.backend;
+        this.storage.remove(ENCRYPTION_STORAGE_KEY);
+        this.key = null;
+        return true;
+    }
+
+    /**
+     * Waits until pending encrypted writes are stored, e.g. before reloading the app
+     * @returns {Promise<void>}
+     */
+    async flush() {
+        if (this.isUnlocked()) await this.storage.backend.flush();
+    }
+
+    /**
+     * Exports the key for the keychain
+     * @returns {Promise<string|null>} Base64 key, null if locked or not extractable
+     */
+    async exportKey() {
+        if (!this.key?.extractable) return null;
+
+        const raw = await this.crypto.subtle.exportKey('raw', this.key);
+        return toBase64(new Uint8Array(raw));
+    }
+
+    /**
+     * Deletes the encrypted data of the active profile, for a forgotten passphrase
+     * @returns {boolean} True if the data was removed
+     */
+    reset() {
+        if (this.isUnlocked()) return false;
+
+        const namespace = this.storage.namespace;
+        this.storage
+            .keysWithPrefix(namespace)
+            .filter((key) => isEncryptedKey(key, namespace))
+            .filter((key) => this.storage.backend.getItem(key)?.startsWith(ENCRYPTED_VALUE_PREFIX))
+            .forEach((key) => this.storage.backend.removeItem(key));
+        return this.storage.remove(ENCRYPTION_STORAGE_KEY);
+    }
+}
+
+// Export singleton instance
+export const dataEncryption = new DataEncryption();
+export default DataEncryption;
@@ -24,6 +24,12 @@ const CHARSET_ALIASES = {
     win1252: 'windows-1252'
 };
 
+// Text decoded outside iconv-lite (by the desktop backend), keyed by decodingKey; see
+// withExternalDecodings
+let externalDecodings = null;
+// Bytes in charsets iconv-lite lacks that decodeBytes met; see collectUnsupportedDecodings
+let unsupportedDecodings = null;
+
 function toBuffer(bytes) {
     if (!bytes) return Buffer.from([]);
     return Buffer.isBuffer(bytes) ? bytes : Buffer.from(bytes);
@@ -93,6 +99,10 @@ function resolveSupportedCharset(charset) {
     return iconvLite.encodingExists(normalizedCharset) ? normalizedCharset : DEFAULT_CHARSET;
 }
 
+function decodingKey(charset, base64) {
+    return `${charset}:${base64}`;
+}
+
 function decodeWithCharset(buffer, charset) {
     return iconvLite.decode(buffer, resolveSupportedCharset(charset));
 }
@@ -100,6 +110,16 @@ function decodeWithCharset(buffer, charset) {
 export function decodeBytes(bytes, charset = DEFAULT_CHARSET, options = {}) {
     const buffer = toBuffer(bytes);
     const requestedCharset = normalizeCharset(charset);
+    if (!iconvLite.encodingExists(requestedCharset)) {
+        const base64 = buffer.toString('base64');
+        const key = decodingKey(requestedCharset, base64);
+        const external = externalDecodings?.get(key);
+        if (external !== undefined) {
+            return options.trim ? external.trim() : external;
+        }
+        unsupportedDecodings?.set(key, { key, charset: requestedCharset, base64 });
+    }
+
     const effectiveCharset = resolveSupportedCharset(requestedCharset);
     const decoded = decodeWithCharset(buffer, requestedCharset);
     const fallbackCharset = normalizeCharset(options.fallbackCharset || FALLBACK_CHARSET);
@@ -115,6 +135,39 @@ export function decodeBytes(bytes, charset = DEFAULT_CHARSET, options = {}) {
     return options.trim ? result.trim() : result;
 }
 
+/**
+ * Runs fn and collects the bytes it had decodeBytes decode in charsets iconv-lite lacks;
+ * those were decoded as DEFAULT_CHARSET
+ * @param {Function} fn - Synchronous function, e.g. a parser
+ * @returns {{result: *, unsupported: Array<{key: string, charset: string, base64: string}>}}
+ */
+export function collectUnsupportedDecodings(fn) {
+    const previous = unsupportedDecodings;
+    unsupportedDecodings = new Map();
+    try {
+        const result = fn();
+        return { result, unsupported: [...unsupportedDecodings.values()] };
+    } finally {
+        unsupportedDecodings = previous;
+    }
+}
+
+/**
+ * Runs fn with text decoded elsewhere for the bytes collectUnsupportedDecodings reported
+ * @param {Map<string, string>} decodings - Decoded text by the `key` of the reports
+ * @param {Function} fn - Synchronous function, e.g. a parser
+ * @returns {*} Result of fn
+ */
+export function withExternalDecodings(decodings, fn) {
+    const previous = externalDecodings;
+    externalDecodings = decodings;
+    try {
+        return fn();
+    } finally {
+        externalDecodings = previous;
+    }
+}
+
 export function binaryStringToBuffer(value = '') {
     return Buffer.from(value || '', 'binary');
 }
@@ -131,6 +184,10 @@ export function textToBase64(value = '') {
     return Buffer.from(value || '', 'utf-8').toString('base64');
 }
 
+export function arrayBufferToBase64(value = new ArrayBuffer(0)) {
+    return Buffer.from(new Uint8Array(value)).toString('base64');
+}
+
 export function base64ToBuffer(value = '') {
     return Buffer.from((value || '').replace(/\s/g, ''), 'base64');
 }
@@ -3,6 +3,8 @@
  * Centralized error handling with optional user-facing notifications
  */
 
+import { writeLog } from './tauri-bridge.js';
+
 /**
  * Error severity levels
  */
@@ -89,6 +91,11 @@ export class ErrorHandler {
             return; // Skip logs below current level
         }
 
+        // Warnings and errors also go to the log files of the desktop app
+        if (messageLevelIndex >= levels.indexOf(ErrorLevel.WARNING)) {
+            writeLog(level, stack ? `${message}\n${stack}` : message);
+        }
+
         switch (level) {
             case ErrorLevel.DEBUG:
             case ErrorLevel.INFO:
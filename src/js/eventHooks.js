@@ -0,0 +1,51 @@
+/**
+ * Event Hooks Module
+ * Reports app events to the backend, which runs the user's configured commands
+ * (see doc/hooks.md). Only active in the desktop app.
+ */
+
+import { isTauri, runEventHooks } from './tauri-bridge.js';
+import { getMessageFileSha256 } from './hashing.js';
+
+export const HOOK_EVENTS = {
+    MESSAGE_OPENED: 'message-opened',
+    BATCH_EXPORT_FINISHED: 'batch-export-finished'
+};
+
+/**
+ * Builds the template variables describing a message
+ * @param {Object} message - Parsed message
+ * @returns {Object<string, string>} Variables
+ */
+export function getMessageHookVariables(message) {
+    return {
+        path: message?._sourcePath || '',
+        fileName: message?.fileName || '',
+        subject: message?.subject || '',
+        senderName: message?.senderName || '',
+        senderEmail: message?.senderEmail || '',
+        date: message?.timestamp instanceof Date ? message.timestamp.toISOString() : '',
+        messageHash: message?.messageHash || '',
+        sha256: getMessageFileSha256(message)
+    };
+}
+
+/**
+ * Reports an event to the configured hooks. Failures are logged and never thrown,
+ * so a broken hook cannot interrupt the action that triggered it.
+ * @param {string} event - One of HOOK_EVENTS
+ * @param {Object<string, string|number>} variables - Template variables
+ */
+export async function emitHookEvent(event, variables = {}) {
+    if (!isTauri()) return;
+
+    const stringVariables = Object.fromEntries(
+        Object.entries(variables).map(([name, value]) => [name, String(value ?? '')])
+    );
+
+    try {
+        await runEventHooks(event, stringVariables);
+    } catch (error) {
+        console.error(`Failed to run ${event} hooks:`, error);
+    }
+}
@@ -0,0 +1,95 @@
+/**
+ * File Saves
+ * The desktop app writes saved attachments in the background: the backend reports how
+ * far each save got and can cancel it, leaving no partial file behind.
+ */
+
+import { cancelFileSave, onSaveFinished, onSaveProgress } from './tauri-bridge.js';
+
+/** Saves from this size on show their progress */
+export const SAVE_PROGRESS_SIZE = 10 * 1024 * 1024;
+
+/** Results kept for saves not known yet; others are from saves of other windows */
+const MAX_EARLY_RESULTS = 20;
+
+/** @type {Map<number, {onProgress: Function, settle: Function}>} Running saves by saveId */
+const pending = new Map();
+/**
+ * Results that arrived before the save was known here: small files are written before
+ * the command that started them returns
+ * @type {Map<number, Object>}
+ */
+const early = new Map();
+/** @type {Promise|null} */
+let listening = null;
+
+/**
+ * Follows the save events of the backend, once
+ * @returns {Promise}
+ */
+function listen() {
+    if (!listening) {
+        listening = Promise.all([
+            onSaveProgress((progress) => pending.get(progress.saveId)?.onProgress(progress)),
+            onSaveFinished((result) => {
+                const save = pending.get(result.saveId);
+                if (save) {
+                    pending.delete(result.saveId);
+                    save.settle(result);
+                    return;
+                }
+                early.set(result.saveId, result);
+                if (early.size > MAX_EARLY_RESULTS) early.delete(early.keys().next().value);
+            })
+        ]);
+    }
+    return listening;
+}
+
+/**
+ * Runs a background save to its end
+ * @param {function(): Promise<{saveId: number, path: string, total: number}|null>} start -
+ *     Starts the save, e.g. startFileSave
+ * @param {Object} [callbacks]
+ * @param {function({saveId: number, path: string, total: number}): void} [callbacks.onStart] -
+ *     Called when the backend starts writing, not for saves that finished at once
+ * @param {function({saveId: number, written: number, total: number}): void}
+ *     [callbacks.onProgress] - Called while the file is written
+ * @returns {Promise<string|false>} Path of the saved file, false if the dialog or the
+ *     save was cancelled; rejects if writing failed
+ */
+export async function runSave(start, { onStart = () => {}, onProgress = () => {} } = {}) {
+    await listen();
+    const job = await start();
+    if (!job) return false;
+
+    return new Promise((resolve, reject) => {
+        const settle = (result) => {
+            if (result.status === 'saved') {
+                resolve(result.path);
+            } else if (result.status === 'cancelled') {
+                resolve(false);
+            } else {
+                reject(new Error(result.error || `Failed to write ${result.path}`));
+            }
+        };
+
+        const result = early.get(job.saveId);
+        if (result) {
+            early.delete(job.saveId);
+            settle(result);
+            return;
+        }
+        pending.set(job.saveId, { onProgress, settle });
+        onStart(job);
+    });
+}
+
+/**
+ * Stops a running save; runSave then resolves with false
+ * @param {number} saveId - Id of the save
+ * @returns {Promise<boolean>} False if the save is not running
+ */
+export function cancelSave(saveId) {
+    return cancelFileSave(saveId);
+}
@@ -0,0 +1,146 @@
+/**
+ * Hashing Module
+ * Synchronous SHA-256 used for evidence hashes (audit log, export manifests).
+ * Kept dependency-free so it works the same in the browser, in Tauri and in tests,
+ * where crypto.subtle is not always available.
+ */
+
+const SHA256_K = new Uint32Array([
+    0x428a2f98, 0x71374491, 0xb5c0fbcf, 0xe9b5dba5, 0x3956c25b, 0x59f111f1, 0x923f82a4,
+    0xab1c5ed5, 0xd807aa98, 0x12835b01, 0x243185be, 0x550c7dc3, 0x72be5d74, 0x80deb1fe,
+    0x9bdc06a7, 0xc19bf174, 0xe49b69c1, 0xefbe4786, 0x0fc19dc6, 0x240ca1cc, 0x2de92c6f,
+    0x4a7484aa, 0x5cb0a9dc, 0x76f988da, 0x983e5152, 0xa831c66d, 0xb00327c8, 0xbf597fc7,
+    0xc6e00bf3, 0xd5a79147, 0x06ca6351, 0x14292967, 0x27b70a85, 0x2e1b2138, 0x4d2c6dfc,
+    0x53380d13, 0x650a7354, 0x766a0abb, 0x81c2c92e, 0x92722c85, 0xa2bfe8a1, 0xa81a664b,
+    0xc24b8b70, 0xc76c51a3, 0xd192e819, 0xd6990624, 0xf40e3585, 0x106aa070, 0x19a4c116,
+    0x1e376c08, 0x2748774c, 0x34b0bcb5, 0x391c0cb3, 0x4ed8aa4a, 0x5b9cca4f, 0x682e6ff3,
+    0x748f82ee, 0x78a5636f, 0x84c87814, 0x8cc70208, 0x90befffa, 0xa4506ceb, 0xbef9a3f7,
+    0xc67178f2
+]);
+
+/**
+ * Normalizes supported inputs to a Uint8Array
+ * @param {ArrayBuffer|ArrayBufferView|string} data - Data to hash
+ * @returns {Uint8Array} Byte view of the data
+ */
+export function toBytes(data) {
+    if (data instanceof Uint8Array) return data;
+    if (data instanceof ArrayBuffer) return new Uint8Array(data);
+    if (ArrayBuffer.isView(data)) {
+        return new Uint8Array(data.buffer, data.byteOffset, data.byteLength);
+    }
+    if (typeof data === 'string') return new TextEncoder().encode(data);
+    return new Uint8Array(0);
+}
+
+/**
+ * Computes the SHA-256 digest of the given data
+ * @param {ArrayBuffer|ArrayBufferView|string} data - Data to hash (strings are UTF-8 encoded)
+ * @returns {string} Lowercase hex digest
+ */
+export function sha256Hex(data) {
+    const bytes = toBytes(data);
+    const bitLength = bytes.length * 8;
+    const paddedLength = Math.ceil((bytes.length + 9) / 64) * 64;
+    const padded = new Uint8Array(paddedLength);
+    padded.set(bytes);
+    padded[bytes.length] = 0x80;
+
+    const view = new DataView(padded.buffer);
+    view.setUint32(paddedLength - 8, Math.floor(bitLength / 0x100000000));
+    view.setUint32(paddedLength - 4, bitLength >>> 0);
+
+    const hash = new Uint32Array([
+        0x6a09e667, 0xbb67ae85, 0x3c6ef372, 0xa54ff53a, 0x510e527f, 0x9b05688c, 0x1f83d9ab,
+        0x5be0cd19
+    ]);
+    const w = new Uint32Array(64);
+
+    for (let offset = 0; offset < paddedLength; offset += 64) {
+        for (let i = 0; i < 16; i++) {
+            w[i] = view.getUint32(offset + i * 4);
+        }
+        for (let i = 16; i < 64; i++) {
+            const s0 =
+                ((w[i - 15] >>> 7) | (w[i - 15] << 25)) ^
+                ((w[i - 15] >>> 18) | (w[i - 15] << 14)) ^
+                (w[i - 15] >>> 3);
+            const s1 =
+                ((w[i - 2] >>> 17) | (w[i - 2] << 15)) ^
+                ((w[i - 2] >>> 19) | (w[i - 2] << 13)) ^
+                (w[i - 2] >>> 10);
+            w[i] = (w[i - 16] + s0 + w[i - 7] + s1) >>> 0;
+        }
+
+        let [a, b, c, d, e, f, g, h] = hash;
+        for (let i = 0; i < 64; i++) {
+            const S1 = ((e >>> 6) | (e << 26)) ^ ((e >>> 11) | (e << 21)) ^ ((e >>> 25) | (e << 7));
+            const ch = (e & f) ^ (~e & g);
+            const temp1 = (h + S1 + ch + SHA256_K[i] + w[i]) >>> 0;
+            const S0 = ((a >>> 2) | (a << 30)) ^ ((a >>> 13) | (a << 19)) ^ ((a >>> 22) | (a << 10));
+            const maj = (a & b) ^ (a & c) ^ (b & c);
+            const temp2 = (S0 + maj) >>> 0;
+
+            h = g;
+            g = f;
+            f = e;
+            e = (d + temp1) >>> 0;
+            d = c;
+            c = b;
+            b = a;
+            a = (temp1 + temp2) >>> 0;
+        }
+
+        hash[0] += a;
+        hash[1] += b;
+        hash[2] += c;
+        hash[3] += d;
+        hash[4] += e;
+        hash[5] += f;
+        hash[6] += g;
+        hash[7] += h;
+    }
+
+    return Array.from(hash, (word) => word.toString(16).padStart(8, '0')).join('');
+}
+
+/**
+ * Gets the SHA-256 of a message's original file bytes, caching it on the message
+ * @param {Object} message - Message with `_rawBuffer`
+ * @returns {string} Hex digest or empty string when the original file is unavailable
+ */
+export function getMessageFileSha256(message) {
+    if (!message?._rawBuffer) return '';
+    if (!message._fileSha256) {
+        message._fileSha256 = sha256Hex(message._rawBuffer);
+    }
+    return message._fileSha256;
+}
+
+/**
+ * Decodes the binary payload of a data URL
+ * @param {string} dataUrl - Base64 data URL (data:mime/type;base64,...)
+ * @returns {Uint8Array} Decoded bytes
+ */
+export function dataUrlToBytes(dataUrl) {
+    const base64 = (dataUrl || '').split(',')[1] || '';
+    const binary = atob(base64.replace(/\s+/g, ''));
+    const bytes = new Uint8Array(binary.length);
+    for (let i = 0; i < binary.length; i++) {
+        bytes[i] = binary.charCodeAt(i);
+    }
+    return bytes;
+}
+
+/**
+ * Gets the SHA-256 of an attachment's content, caching it on the attachment
+ * @param {Object} attachment - Attachment with `contentBase64`
+ * @returns {string} Hex digest or empty string when no content is available
+ */
+export function getAttachmentSha256(attachment) {
+    if (!attachment?.contentBase64) return '';
+    if (!attachment._sha256) {
+        attachment._sha256 = sha256Hex(dataUrlToBytes(attachment.contentBase64));
+    }
+    return attachment._sha256;
+}
@@ -0,0 +1,65 @@
+/**
+ * Help Module
+ * Opens the bundled help pages (res/help) for the current context.
+ * Pages ship with the app, so help works without an internet connection.
+ */
+
+import { showHelp as showHelpWindow } from './tauri-bridge.js';
+import { getLanguage } from './i18n.js';
+
+/**
+ * Available help pages, one per file in res/help
+ */
+export const HELP_TOPICS = {
+    GETTING_STARTED: 'getting-started',
+    READING_MESSAGES: 'reading-messages',
+    SEARCH: 'search',
+    ATTACHMENTS: 'attachments',
+    EXPORT: 'export',
+    SETTINGS: 'settings',
+    KEYBOARD_SHORTCUTS: 'keyboard-shortcuts'
+};
+
+/**
+ * Picks the help topic for what the user is currently doing
+ * @param {Object} state - Current UI state
+ * @param {boolean} [state.helpModalOpen] - Keyboard shortcut overview is shown
+ * @param {boolean} [state.attachmentModalOpen] - Attachment preview is shown
+ * @param {boolean} [state.settingsOpen] - Settings menu is open
+ * @param {boolean} [state.searchFocused] - Search input has focus
+ * @param {boolean} [state.messageOpen] - A message is displayed
+ * @returns {string} One of HELP_TOPICS values
+ */
+export function getHelpTopicForContext(state = {}) {
+    if (state.helpModalOpen) return HELP_TOPICS.KEYBOARD_SHORTCUTS;
+    if (state.attachmentModalOpen) return HELP_TOPICS.ATTACHMENTS;
+    if (state.settingsOpen) return HELP_TOPICS.SETTINGS;
+    if (state.searchFocused) return HELP_TOPICS.SEARCH;
+    if (state.messageOpen) return HELP_TOPICS.READING_MESSAGES;
+    return HELP_TOPICS.GETTING_STARTED;
+}
+
+/**
+ * Gets the URL of a help page relative to the app root
+ * @param {string} topic - One of HELP_TOPICS values, unknown topics fall back to getting started
+ * @returns {string}
+ */
+export function getHelpUrl(topic) {
+    const known = Object.values(HELP_TOPICS).includes(topic);
+    return `help/${known ? topic : HELP_TOPICS.GETTING_STARTED}.html`;
+}
+
+/**
+ * Shows a help page: in the help window on desktop, in a new tab on the web
+ * @param {string} topic - One of HELP_TOPICS values
+ * @returns {Promise<void>}
+ */
+export async function openHelp(topic) {
+    try {
+        if (await showHelpWindow(topic, getLanguage())) return;
+    } catch (error) {
+        console.error('Failed to open help window:', error);
+    }
+
+    window.open(getHelpUrl(topic), 'msgReaderHelp', 'noopener');
+}
@@ -0,0 +1,201 @@
+/**
+ * Localization Module
+ * UI strings come from the catalogs in res/locales (one JSON file per language, flat keys
+ * with {name} placeholders), which the desktop backend embeds and the web build serves as
+ * static files. English is bundled here, so t() works before a catalog is loaded and
+ * for keys a translation lacks. Dates and sizes are formatted for the UI language.
+ */
+
+import english from '../../res/locales/en.json';
+import { DEFAULT_LOCALE } from './constants.js';
+import { storage } from './storage.js';
+import { getSystemLocale, getTranslations, isTauri } from './tauri-bridge.js';
+
+/**
+ * Languages with a catalog, by their name in the language itself
+ */
+export const SUPPORTED_LANGUAGES = {
+    en: 'English',
+    de: 'Deutsch',
+    fr: 'Français',
+    ja: '日本語'
+};
+
+/** Preference value for following the OS (desktop) or browser language */
+export const SYSTEM_LANGUAGE = 'system';
+
+export const LANGUAGE_STORAGE_KEY = 'msgReader_language';
+
+let messages = english;
+let language = 'en';
+let locale = DEFAULT_LOCALE;
+
+/**
+ * The supported language of a locale: 'de' for 'de-AT', 'en' if there is no catalog
+ * @param {string} value - Language or BCP 47 locale
+ * @returns {string} One of the SUPPORTED_LANGUAGES keys
+ */
+export function resolveLanguage(value) {
+    const primary = String(value || '')
+        .split(/[-_]/)[0]
+        .toLowerCase();
+    return Object.hasOwn(SUPPORTED_LANGUAGES, primary) ? primary : 'en';
+}
+
+/**
+ * Gets the language preference
+ * @returns {string} A SUPPORTED_LANGUAGES key or SYSTEM_LANGUAGE (default)
+ */
+export function getLanguagePreference() {
+    const value = storage.get(LANGUAGE_STORAGE_KEY, SYSTEM_LANGUAGE);
+    return Object.hasOwn(SUPPORTED_LANGUAGES, value) ? value : SYSTEM_LANGUAGE;
+}
+
+/**
+ * Saves the language preference; loadTranslations applies it
+ * @param {string} value - A SUPPORTED_LANGUAGES key or SYSTEM_LANGUAGE
+ * @returns {boolean} True if the preference changed
+ */
+export function setLanguagePreference(value) {
+    const next = Object.hasOwn(SUPPORTED_LANGUAGES, value) ? value : SYSTEM_LANGUAGE;
+    if (next === getLanguagePreference()) return false;
+    storage.set(LANGUAGE_STORAGE_KEY, next);
+    return true;
+}
+
+/**
+ * The locale of the OS (desktop) or browser
+ * @returns {Promise<string>}
+ */
+async function detectSystemLocale() {
+    if (isTauri()) {
+        try {
+            return await getSystemLocale();
+        } catch (error) {
+            console.warn('Could not read the system locale:', error);
+        }
+    }
+    return globalThis.navigator?.language || DEFAULT_LOCALE;
+}
+
+/**
+ * Fetches a catalog the web build serves from res/locales
+ * @param {string} code - A SUPPORTED_LANGUAGES key
+ * @returns {Promise<Object<string, string>>}
+ */
+async function fetchCatalog(code) {
+    const response = await fetch(`locales/${code}.json`);
+    if (!response.ok) {
+        throw new Error(`Failed to load ${code} translations: ${response.status}`);
+    }
+    return { ...english, ...(await response.json()) };
+}
+
+/**
+ * Loads the strings of the preferred language, from the backend on desktop
+ * @returns {Promise<string>} The language now in use
+ */
+export async function loadTranslations() {
+    const preference = getLanguagePreference();
+    const systemLocale = await detectSystemLocale();
+    const requested = preference === SYSTEM_LANGUAGE ? systemLocale : preference;
+    const code = resolveLanguage(requested);
+
+    let loaded = english;
+    if (code !== 'en') {
+        try {
+            loaded = isTauri() ? (await getTranslations(code)).messages : await fetchCatalog(code);
+        } catch (error) {
+            console.warn(`Could not load ${code} translations:`, error);
+        }
+    }
+
+    messages = loaded;
+    language = loaded === english ? 'en' : code;
+    // Regional formats of the system locale if it has the same language
+    locale = resolveLanguage(systemLocale) === language ? systemLocale : requested;
+    if (typeof document !== 'undefined') {
+        document.documentElement.lang = language;
+    }
+    return language;
+}
+
+/**
+ * The language of the strings in use
+ * @returns {string} A SUPPORTED_LANGUAGES key
+ */
+export function getLanguage() {
+    return language;
+}
+
+/**
+ * The locale for dates and numbers, e.g. 'de-CH'
+ * @returns {string}
+ */
+export function getLocale() {
+    return locale;
+}
+
+/**
+ * Translates a key
+ * @param {string} key - Catalog key, e.g. 'reply.forward'
+ * @param {Object<string, string|number>} [params] - Values for {name} placeholders
+ * @returns {string} The string, the key itself if no catalog has it
+ */
+export function t(key, params = {}) {
+    const text = messages[key] ?? english[key] ?? key;
+    return text.replace(/\{(\w+)\}/g, (placeholder, name) =>
+        Object.hasOwn(params, name) ? String(params[name]) : placeholder
+    );
+}
+
+/**
+ * Formats a date and time for the UI language
+ * @param {Date|number|string} date
+ * @param {Intl.DateTimeFormatOptions} [options] - Date and time by default
+ * @returns {string} Empty for invalid dates
+ */
+export function formatDate(date, options = { dateStyle: 'medium', timeStyle: 'short' }) {
+    const value = date instanceof Date ? date : new Date(date);
+    if (Number.isNaN(value.getTime())) return '';
+    return new Intl.DateTimeFormat(locale, options).format(value);
+}
+
+/**
+ * Formats a file size for the UI language, e.g. "1.5 KB" or "1,5 Ko"
+ * @param {number} bytes
+ * @returns {string}
+ */
+export function formatSize(bytes) {
+    const units = ['size.bytes', 'size.kilobytes', 'size.megabytes', 'size.gigabytes'];
+    let value = Number(bytes) || 0;
+    let unit = 0;
+    while (value >= 1024 && unit < units.length - 1) {
+        value /= 1024;
+        unit++;
+    }
+    const digits = unit === 0 ? 0 : 1;
+    const number = new Intl.NumberFormat(locale, {
+        minimumFractionDigits: digits,
+        maximumFractionDigits: digits
+    }).format(value);
+    return `${number} ${t(units[unit])}`;
+}
+
+/**
+ * Translates the static markup: the text of elements with data-i18n and the title and
+ * aria-label of elements with data-i18n-title
+ * @param {ParentNode} [root=document]
+ */
+export function translateElements(root = document) {
+    root.querySelectorAll('[data-i18n]').forEach((element) => {
+        element.textContent = t(element.dataset.i18n);
+    });
+    root.querySelectorAll('[data-i18n-title]').forEach((element) => {
+        const text = t(element.dataset.i18nTitle);
+        element.title = text;
+        if (element.hasAttribute('aria-label')) {
+            element.setAttribute('aria-label', text);
+        }
+    });
+}
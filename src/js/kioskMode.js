@@ -0,0 +1,101 @@
+/**
+ * Kiosk Mode Module
+ * Viewer-only mode for secure review rooms, turned on with `--kiosk`, MSGREADER_KIOSK
+ * or the KioskMode policy. Messages and attachments can be opened and read, but
+ * nothing leaves the app: no saving, exporting, printing, copying attachments to the
+ * clipboard, dragging content out or opening it in other apps. The backend rejects the
+ * corresponding commands as well; this module hides the UI and blocks browser paths.
+ */
+
+/** Areas whose links must not open outside the app */
+const PROTECTED_CONTENT = '.email-content, .attachment-modal';
+
+export class KioskMode {
+    constructor() {
+        this.enabled = false;
+        this.doc = null;
+
+        this.handleKeyDown = this.handleKeyDown.bind(this);
+        this.handleCopy = this.handleCopy.bind(this);
+        this.blockEvent = this.blockEvent.bind(this);
+        this.handleClick = this.handleClick.bind(this);
+    }
+
+    /**
+     * @returns {boolean} True if kiosk mode is on
+     */
+    isEnabled() {
+        return this.enabled;
+    }
+
+    /**
+     * Turns kiosk mode on for the rest of the session
+     * @param {Document} [doc=document] - Document to protect (dependency injection)
+     */
+    enable(doc = typeof document !== 'undefined' ? document : null) {
+        if (this.enabled || !doc) return;
+
+        this.enabled = true;
+        this.doc = doc;
+        doc.body?.classList.add('kiosk-mode');
+        doc.addEventListener('keydown', this.handleKeyDown, true);
+        doc.addEventListener('copy', this.handleCopy, true);
+        doc.addEventListener('cut', this.handleCopy, true);
+        doc.addEventListener('dragstart', this.blockEvent, true);
+        doc.addEventListener('contextmenu', this.blockEvent, true);
+        doc.addEventListener('click', this.handleClick, true);
+    }
+
+    /**
+     * Blocks the print and save shortcuts
+     * @param {KeyboardEvent} event
+     */
+    handleKeyDown(event) {
+        const key = event.key?.toLowerCase();
+        if ((event.ctrlKey || event.metaKey) && (key === 'p' || key === 's')) {
+            this.blockEvent(event);
+        }
+    }
+
+    /**
+     * Blocks copying a selection that includes images or attachment content
+     * @param {ClipboardEvent} event
+     */
+    handleCopy(event) {
+        const selection = this.doc.getSelection?.();
+        if (!selection || selection.rangeCount === 0) return;
+
+        for (let i = 0; i < selection.rangeCount; i++) {
+            const fragment = selection.getRangeAt(i).cloneContents();
+            const start = selection.getRangeAt(i).startContainer;
+            const startElement = start.nodeType === 1 ? start : start.parentElement;
+            if (fragment.querySelector?.('img') || startElement?.closest?.('.attachment-modal')) {
+                this.blockEvent(event);
+                return;
+            }
+        }
+    }
+
+    /**
+     * Keeps links in messages from opening in the browser or another app
+     * @param {MouseEvent} event
+     */
+    handleClick(event) {
+        const link = event.target?.closest?.('a[href]');
+        if (link && link.closest(PROTECTED_CONTENT)) {
+            this.blockEvent(event);
+        }
+    }
+
+    /**
+     * @param {Event} event
+     */
+    blockEvent(event) {
+        event.preventDefault();
+        event.stopPropagation();
+    }
+}
+
+// Export singleton instance
+export const kioskMode = new KioskMode();
+export default kioskMode;
@@ -0,0 +1,165 @@
+/**
+ * Large Messages
+ * Files of 50 MB or more (e.g. with video attachments) are not read into the webview:
+ * the backend of the desktop app memory-maps them and parses only their structure.
+ * Attachments over 1 MB come without content; a preview reads it from the backend, and
+ * opening or saving goes from the mapped file straight to disk.
+ */
+
+import {
+    closeLargeMessage,
+    openLargeAttachment,
+    openLargeMessage,
+    openWithSystemViewer,
+    readLargeAttachment,
+    startFileSave,
+    startLargeAttachmentSave
+} from './tauri-bridge.js';
+import { arrayBufferToBase64 } from './encoding.js';
+import { runSave } from './fileSaves.js';
+import { parseEmailHeaders } from './utils.js';
+import { replaceCidReferences } from './cidReplacer.js';
+
+/** @type {WeakMap<Object, Promise<Object>>} */
+const loads = new WeakMap();
+
+/**
+ * Converts a message opened by the backend into the shape of extractMsg/extractEml
+ * @param {{handle: number, fileSize: number, deferred: number[], message: Object}} large -
+ *     Result of openLargeMessage
+ * @returns {Object} Message; attachments sent without content have `_largeFile`, which
+ *     they keep once loadAttachmentContent filled it in
+ */
+export function largeMessageToMsgInfo(large) {
+    const { handle, deferred, message } = large;
+
+    const attachments = (message.attachments || []).map((attachment, index) => {
+        const mimeType = attachment.mimeType || 'application/octet-stream';
+        const isDeferred = deferred.includes(index);
+        return {
+            fileName: attachment.fileName || 'attachment',
+            attachMimeTag: mimeType,
+            contentLength: attachment.size,
+            ...(attachment.contentId ? { contentId: attachment.contentId } : {}),
+            contentBase64: isDeferred
+                ? ''
+                : `data:${mimeType};base64,${attachment.contentBase64}`,
+            ...(isDeferred ? { _largeFile: { handle, index } } : {})
+        };
+    });
+    const headerMap = message.headers ? parseEmailHeaders(message.headers) : {};
+
+    return {
+        subject: message.subject || '',
+        senderName: message.senderName || message.senderEmail,
+        senderEmail: message.senderEmail,
+        recipients: (message.recipients || []).map((recipient) => ({
+            name: recipient.name || recipient.email,
+            email: recipient.email,
+            recipType: recipient.type
+        })),
+        messageDeliveryTime: message.date ? new Date(message.date).toISOString() : undefined,
+        bodyContent: message.bodyText || '',
+        bodyContentHTML: replaceCidReferences(message.bodyHtml || message.bodyText, attachments),
+        attachments,
+        _exportMeta: {
+            rawHeaders: message.headers || '',
+            headerMap: {
+                ...(message.messageId ? { 'message-id': message.messageId } : {}),
+                ...headerMap
+            }
+        },
+        _largeFileHandle: handle
+    };
+}
+
+/**
+ * Opens a file through the backend if it is too large to read whole
+ * @param {string} filePath - Absolute path to a .msg or .eml file
+ * @returns {Promise<Object|null>} The message, null for files to read and parse here
+ */
+export async function openLargeMessageFile(filePath) {
+    const large = await openLargeMessage(filePath);
+    return large ? largeMessageToMsgInfo(large) : null;
+}
+
+/**
+ * Whether an attachment of a large message has no content yet
+ * @param {Object} attachment - Attachment
+ * @returns {boolean}
+ */
+export function isDeferredAttachment(attachment) {
+    return Boolean(attachment?._largeFile) && !attachment.contentBase64;
+}
+
+/**
+ * Reads the content of an attachment of a large message into `contentBase64`, once;
+ * other attachments are returned as they are
+ * @param {Object} attachment - Attachment
+ * @returns {Promise<Object>} The attachment
+ */
+export function loadAttachmentContent(attachment) {
+    if (!isDeferredAttachment(attachment)) {
+        return Promise.resolve(attachment);
+    }
+
+    if (!loads.has(attachment)) {
+        const { handle, index } = attachment._largeFile;
+        loads.set(
+            attachment,
+            readLargeAttachment(handle, index)
+                .then((buffer) => {
+                    const base64 = arrayBufferToBase64(buffer);
+                    attachment.contentBase64 = `data:${attachment.attachMimeTag};base64,${base64}`;
+                    return attachment;
+                })
+                .catch((err) => {
+                    loads.delete(attachment);
+                    throw err;
+                })
+        );
+    }
+    return loads.get(attachment);
+}
+
+/**
+ * Saves an attachment with a "Save As" dialog; the backend writes it in the background,
+ * see runSave. Attachments of large messages are written from the mapped file.
+ * @param {Object} attachment - Attachment
+ * @param {Object} [callbacks] - onStart and onProgress, see runSave
+ * @returns {Promise<string|false>} Path of the saved file, false if the user cancelled
+ */
+export function saveAttachmentWithDialog(attachment, callbacks = {}) {
+    return runSave(() => {
+        if (attachment._largeFile) {
+            const { handle, index } = attachment._largeFile;
+            return startLargeAttachmentSave(handle, index, attachment.fileName);
+        }
+        return startFileSave(attachment.contentBase64, attachment.fileName);
+    }, callbacks);
+}
+
+/**
+ * Opens an attachment with the system's default app, see saveAttachmentWithDialog
+ * @param {Object} attachment - Attachment
+ * @returns {Promise<void>}
+ */
+export function openAttachmentWithSystem(attachment) {
+    if (attachment._largeFile) {
+        const { handle, index } = attachment._largeFile;
+        return openLargeAttachment(handle, index, attachment.fileName);
+    }
+    return openWithSystemViewer(attachment.contentBase64, attachment.fileName);
+}
+
+/**
+ * Lets the backend unmap the file of a large message that was closed
+ * @param {Object} message - Closed message
+ */
+export function releaseLargeMessage(message) {
+    if (message?._largeFileHandle === undefined) return;
+
+    closeLargeMessage(message._largeFileHandle).catch((err) => {
+        console.warn('Could not release message file:', err);
+    });
+}
@@ -0,0 +1,117 @@
+/**
+ * Library Module
+ * Stable entry point to the message parsing and conversion code for other programs:
+ * the command line interface, scripts and services. It has no UI or Tauri
+ * dependencies and never touches persisted app state. See doc/library.md.
+ */
+
+import { extractEml, extractMsg } from './utils.js';
+import MessageHandler from './MessageHandler.js';
+import { MemoryBackend, Storage } from './storage.js';
+import { messageToEml, messageToHtmlDocument, messageToJson } from './messageExport.js';
+
+/** Incremented when the API changes in an incompatible way */
+export const LIBRARY_API_VERSION = 1;
+
+export const INPUT_TYPES = ['msg', 'eml'];
+export const OUTPUT_FORMATS = ['json', 'eml', 'html'];
+
+// OLE compound file signature used by Outlook .msg files
+const MSG_SIGNATURE = [0xd0, 0xcf, 0x11, 0xe0, 0xa1, 0xb1, 0x1a, 0xe1];
+
+/**
+ * Normalizes input data to an ArrayBuffer
+ * @param {ArrayBuffer|Uint8Array} data - Message data, e.g. a Node.js Buffer
+ * @returns {ArrayBuffer}
+ */
+function toArrayBuffer(data) {
+    if (data instanceof ArrayBuffer) return data;
+    if (ArrayBuffer.isView(data)) {
+        return data.buffer.slice(data.byteOffset, data.byteOffset + data.byteLength);
+    }
+    throw new TypeError('Message data must be an ArrayBuffer or Uint8Array');
+}
+
+/**
+ * Detects the input type from the data
+ * @param {ArrayBuffer|Uint8Array} data - Message data
+ * @returns {string} 'msg' or 'eml'
+ */
+export function detectInputType(data) {
+    const buffer = toArrayBuffer(data);
+    const bytes = new Uint8Array(buffer, 0, Math.min(buffer.byteLength, MSG_SIGNATURE.length));
+    const isMsg =
+        bytes.length === MSG_SIGNATURE.length &&
+        MSG_SIGNATURE.every((byte, index) => bytes[index] === byte);
+    return isMsg ? 'msg' : 'eml';
+}
+
+/**
+ * Parses an MSG or EML message
+ * @param {ArrayBuffer|Uint8Array} data - Message data
+ * @param {Object} [options] - Parse options
+ * @param {string} [options.type] - 'msg' or 'eml', detected when omitted
+ * @param {string} [options.fileName] - Source file name, used for export file names
+ * @returns {Object} Parsed message as used by the app (subject, sender, recipients,
+ *     timestamp, bodyContent, bodyContentHTML, attachments, ...)
+ * @throws {Error} If the type is unsupported or the data cannot be parsed
+ */
+export function parse(data, options = {}) {
+    const buffer = toArrayBuffer(data);
+    const type = options.type || detectInputType(buffer);
+    if (!INPUT_TYPES.includes(type)) {
+        throw new Error(`Unsupported input type: ${type}`);
+    }
+
+    const msgInfo = type === 'msg' ? extractMsg(buffer) : extractEml(buffer);
+    if (!msgInfo) {
+        throw new Error(`Failed to parse ${type.toUpperCase()} data`);
+    }
+    msgInfo._fileType = type;
+
+    // Handler on an empty in-memory storage, so library use neither reads nor writes the
+    // app's data, e.g. the pinned messages in the browser's localStorage
+    return new MessageHandler(new Storage(new MemoryBackend())).addMessage(
+        msgInfo,
+        options.fileName || `message.${type}`
+    );
+}
+
+/**
+ * Renders a parsed message in an output format
+ * @param {Object} message - Result of parse
+ * @param {string} [format='html'] - 'html' (standalone document), 'eml' or 'json'
+ * @param {Object} [options] - Render options
+ * @param {boolean} [options.includeAttachmentContent=true] - Include attachment data in JSON
+ * @returns {string} Rendered message
+ * @throws {Error} If the format is unsupported
+ */
+export function render(message, format = 'html', options = {}) {
+    if (format === 'html') return messageToHtmlDocument(message);
+    if (format === 'eml') return messageToEml(message);
+    if (format === 'json') {
+        const json = messageToJson(message, {
+            includeAttachmentContent: options.includeAttachmentContent
+        });
+        return `${JSON.stringify(json, null, 2)}\n`;
+    }
+    throw new Error(`Unsupported output format: ${format}`);
+}
+
+/**
+ * Parses message data and renders it in the requested format
+ * @param {ArrayBuffer|Uint8Array} data - Message data
+ * @param {Object} [options] - Options of parse and render
+ * @param {string} [options.type] - Input type, detected when omitted
+ * @param {string} [options.to='json'] - Output format
+ * @param {string} [options.fileName] - Source file name
+ * @param {boolean} [options.includeAttachmentContent=true] - Include attachment data in JSON
+ * @returns {string} Converted message
+ * @throws {Error} If the data cannot be parsed or a format is unsupported
+ */
+export function convert(data, options = {}) {
+    const message = parse(data, { type: options.type, fileName: options.fileName });
+    return render(message, options.to || 'json', {
+        includeAttachmentContent: options.includeAttachmentContent
+    });
+}
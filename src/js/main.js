@@ -4,18 +4,174 @@ import UIManager from './UIManager.js';
 import FileHandler from './FileHandler.js';
 import KeyboardManager from './KeyboardManager.js';
 import { extractMsg, extractEml } from './utils.js';
-import { isTauri, getPendingFiles, onFileOpen, onFileDrop, checkForUpdates } from './tauri-bridge.js';
-import { themeManager } from './ThemeManager.js';
+import {
+    isTauri,
+    isMessageWindow,
+    getPendingFiles,
+    getPendingArchiveMessages,
+    getTranslationStatus,
+    onFileOpen,
+    onArchiveMessageOpen,
+    onFileDrop,
+    checkForUpdates,
+    clearTempFiles,
+    listExportPlugins,
+    listSpeechVoices,
+    isSmimeKeystoreAvailable,
+    onSpeechEnded,
+    setAutomationEnabled,
+    getProfile,
+    setProfile,
+    getOverrides,
+    getPolicy,
+    getAppInfo,
+    getStoredSecret,
+    storeSecret,
+    deleteStoredSecret,
+    getFileAssociationStatus,
+    getFileName,
+    getRecentLogs,
+    onSettingsChanged,
+    openDefaultAppsSettings,
+    makeDefaultApp,
+    unregisterFileAssociations,
+    openLogFolder,
+    onWatchedFile,
+    onNotificationOpen,
+    pickDefaultSaveDirectory,
+    pickFolder,
+    pickPstFile,
+    readPstMessage,
+    pickMboxFile,
+    readMboxMessage,
+    readImapMessage,
+    readGzipMessage,
+    readZipMessage,
+    importToArchive,
+    getArchiveMessages,
+    readArchiveMessage,
+    getThreads,
+    findDuplicates,
+    compareMessages,
+    startBatchConversion,
+    cancelBatchConversion,
+    onConversionProgress,
+    onConversionFinished,
+    getRemoteImageProxy,
+    clearRemoteImageCache,
+    restoreWatchedFolders,
+    unwatchFolder,
+    watchFolder,
+    setTempFileRetention as applyTempFileRetention
+} from './tauri-bridge.js';
+import { themeManager, THEMES, THEME_STORAGE_KEYS } from './ThemeManager.js';
+import {
+    getLanguagePreference,
+    loadTranslations,
+    setLanguagePreference,
+    translateElements
+} from './i18n.js';
+import { errorHandler } from './errorHandler.js';
+import { storage, ReadOnlyBackend } from './storage.js';
+import { managedPolicy } from './policy.js';
+import { kioskMode } from './kioskMode.js';
+import { accessibilityManager, ACCESSIBILITY_SETTINGS } from './AccessibilityManager.js';
 import {
     getInlineImageAttachmentVisibility,
     setInlineImageAttachmentVisibility
 } from './InlineImagePreference.js';
 import {
+    DEFAULT_SAVE_DIRECTORY_STORAGE_KEY,
+    EXTERNAL_CONTENT,
+    EXTERNAL_CONTENT_STORAGE_KEY,
+    MESSAGE_LIST_GROUPING,
+    REMOTE_IMAGE_SENDERS_STORAGE_KEY,
+    STARTUP_BEHAVIOR,
+    STARTUP_BEHAVIOR_STORAGE_KEY,
+    TEMP_FILE_RETENTION_MINUTES,
+    TRAY_ICON,
+    TRAY_ICON_STORAGE_KEY,
+    automationApiEnabled,
+    externalContentManaged,
+    getAutomationApi,
+    getDefaultSaveDirectory,
+    getExportChecksumMode,
+    getExternalContent,
+    getMessageListGrouping,
     getPdfAttachmentOpenMode,
-    setPdfAttachmentOpenMode
+    getRedactionOptions,
+    getRemoteImageSenders,
+    getSpeechVoice,
+    getStartupBehavior,
+    getTempFileRetention,
+    getTrayIcon,
+    getWatchedFolders,
+    setAutomationApi,
+    setDefaultSaveDirectory,
+    setExportChecksumMode,
+    setExternalContent,
+    setMessageListGrouping,
+    setPdfAttachmentOpenMode,
+    setRedactionOptions,
+    setRemoteImageSenders,
+    setSpeechVoice,
+    setStartupBehavior,
+    setTempFileRetention,
+    setTrayIcon,
+    setWatchedFolders
 } from './UserPreferences.js';
 import { devModeManager } from './DevModeManager.js';
+import { auditLog, AUDIT_ACTIONS } from './AuditLog.js';
+import { usageStats, USAGE_FEATURES } from './UsageStats.js';
+import {
+    getEnabledPiiDetectors,
+    piiReportToCsv,
+    scanMessagesForPii,
+    setEnabledPiiDetectors
+} from './piiScanner.js';
+import {
+    ADDRESS_BOOK_FORMATS,
+    addressBookToCsv,
+    addressBookToVCard,
+    collectAddressBook
+} from './addressBook.js';
+import {
+    METADATA_EXPORT_FORMATS,
+    collectMessageMetadata,
+    metadataToCsv,
+    metadataToJson
+} from './metadataExport.js';
+import { messageToJson } from './messageExport.js';
+import { initAutomationApi } from './automationApi.js';
 import { DevPanel } from './ui/DevPanel.js';
+import { UsageStatsModal } from './ui/UsageStatsModal.js';
+import { ComparisonModal } from './ui/ComparisonModal.js';
+import { ProfileChooser } from './ui/ProfileChooser.js';
+import { PassphrasePrompt } from './ui/PassphrasePrompt.js';
+import { getProfileFromUrl, profileManager, resolveStartupProfile } from './profiles.js';
+import { dataEncryption, getKeychainAccount } from './dataEncryption.js';
+import { createAppDataBackup, readAppDataBackup, restoreAppData } from './appDataBackup.js';
+import {
+    applySettingsImport,
+    createSettingsBundle,
+    parseSettingsBundle,
+    planSettingsImport
+} from './settingsBundle.js';
+import { SettingsImportModal } from './ui/SettingsImportModal.js';
+import { RecentFilesList } from './ui/RecentFilesList.js';
+import { FolderBrowser } from './ui/FolderBrowser.js';
+import { PstBrowser } from './ui/PstBrowser.js';
+import { MboxBrowser } from './ui/MboxBrowser.js';
+import { ImapBrowser } from './ui/ImapBrowser.js';
+import { ZipBrowser, zipMemberFileName } from './ui/ZipBrowser.js';
+import { ArchiveBrowser } from './ui/ArchiveBrowser.js';
+import { arrayBufferToBase64 } from './encoding.js';
+import { detectInputType } from './library.js';
+import { SettingsSync } from './settingsSync.js';
+import { escapeHTML } from './sanitizer.js';
+import { releaseLargeMessage } from './largeMessage.js';
+import { loadWindowMessage } from './messageWindow.js';
+import { reopenSession, trackSession } from './session.js';
 
 /**
  * Main application class
@@ -41,6 +197,28 @@ class App {
         // Connect keyboard manager to UI manager for modal context changes
         this.uiManager.setKeyboardManager(this.keyboardManager);
 
+        this.usageStatsModal = new UsageStatsModal(document.getElementById('usageStatsModal'), {
+            notify: (message, type) => this.uiManager.showToast(message, type)
+        });
+        this.settingsImportModal = new SettingsImportModal(
+            document.getElementById('settingsImportModal')
+        );
+        this.comparisonModal = new ComparisonModal(document.getElementById('comparisonModal'));
+
+        // Recent files on the welcome screen and the folder, PST, mbox, ZIP, IMAP and
+        // archive browsers, set up with the Tauri file handling
+        this.recentFiles = null;
+        this.folderBrowser = null;
+        this.pstBrowser = null;
+        this.mboxBrowser = null;
+        this.zipBrowser = null;
+        this.imapBrowser = null;
+        this.archiveBrowser = null;
+
+        // Conversations are grouped by the backend (desktop app only)
+        this.groupingConversations = false;
+        this.applyMessageListGrouping();
+
         // Initialize DevPanel if dev mode is enabled
         this.devPanel = null;
         this.initDevMode();
@@ -137,6 +315,9 @@ class App {
      */
     togglePin(index) {
         const message = this.messageHandler.togglePin(index);
+        if (this.messageHandler.isPinned(message)) {
+            usageStats.recordFeature(USAGE_FEATURES.PIN);
+        }
         this.uiManager.updateMessageList();
         this.uiManager.showMessage(message);
     }
@@ -166,8 +347,11 @@ class App {
             }
         }
 
+        auditLog.record(AUDIT_ACTIONS.DELETE, { message: messageToDelete });
+
         // Perform the deletion
         const nextMessage = this.messageHandler.deleteMessage(index);
+        releaseLargeMessage(messageToDelete);
         this.uiManager.updateMessageList();
 
         // Show the appropriate next message
@@ -177,143 +361,1723 @@ class App {
             this.uiManager.showMessage(messageToShow);
         } else {
             this.uiManager.showWelcomeScreen();
+            this.recentFiles?.refresh();
         }
     }
-}
 
-/**
- * Initialize Tauri-specific file handling
- * Called after app initialization when running in Tauri
- */
-async function initTauriFileHandling() {
-    // Check for files passed on app startup (double-click to open)
-    const pendingFiles = await getPendingFiles();
-    if (pendingFiles.length > 0) {
-        // Use batch method for multiple files
-        await window.app.fileHandler.handleFilesFromPaths(pendingFiles);
+    /**
+     * Scans messages for personal data and downloads the findings as CSV
+     * @param {Array} messages - Messages to scan
+     * @param {string} scopeLabel - Label used in the report file name
+     */
+    async downloadPiiReport(messages, scopeLabel) {
+        const report = scanMessagesForPii(messages);
+        const date = report.generatedAt.slice(0, 10);
+        const affected = new Set(report.findings.map((finding) => finding.messageHash)).size;
+
+        this.uiManager.showInfo(
+            `${report.findings.length} finding(s) in ${affected} of ${messages.length} email(s)`
+        );
+        await this.uiManager.downloadBlob(
+            this.uiManager.createTextBlob(piiReportToCsv(report), 'text/csv'),
+            `msgReader-pii-${scopeLabel}-${date}.csv`,
+            'Scan report saved successfully',
+            'Failed to save scan report'
+        );
     }
 
-    // Listen for files opened while app is running (double-click)
-    await onFileOpen((filePath) => {
-        window.app.fileHandler.handleFileFromPath(filePath);
-    });
+    /**
+     * Lets the user pick a folder and parses all emails in it.
+     * The parsed emails are not added to the message list.
+     * @param {Function} onMessages - Called with the parsed messages if there are any
+     */
+    pickFolderMessages(onMessages) {
+        const input = document.createElement('input');
+        input.type = 'file';
+        input.webkitdirectory = true;
+        input.addEventListener('change', async () => {
+            const { messages, errorCount } = await this.fileHandler.parseFiles(input.files || []);
+            if (errorCount > 0) {
+                this.uiManager.showWarning(`${errorCount} file(s) could not be read`);
+            }
+            if (messages.length === 0) {
+                this.uiManager.showError('No emails found in this folder');
+                return;
+            }
+            await onMessages(messages);
+        });
+        input.click();
+    }
 
-    // Listen for drag & drop events (Tauri-specific)
-    await onFileDrop({
-        onDrop: async (filePaths) => {
-            // Use batch method for multiple dropped files
-            await window.app.fileHandler.handleFilesFromPaths(filePaths);
-        },
-        onEnter: () => {
-            window.app.uiManager.showDropOverlay();
-        },
-        onLeave: () => {
-            window.app.uiManager.hideDropOverlay();
-        },
-    });
+    /**
+     * Lets the user pick a folder and scans all emails in it for personal data
+     */
+    scanFolderForPii() {
+        this.pickFolderMessages((messages) => this.downloadPiiReport(messages, 'folder'));
+    }
 
-    // Check for updates (runs in background, shows dialog if update available)
-    checkForUpdates();
-}
+    /**
+     * Downloads all unique senders and recipients of the given messages
+     * @param {Array<Object>} messages - Messages to collect addresses from
+     * @param {string} scopeLabel - Scope used in the file name
+     * @param {string} [format=ADDRESS_BOOK_FORMATS.CSV] - csv or vcf
+     */
+    async downloadAddressBook(messages, scopeLabel, format = ADDRESS_BOOK_FORMATS.CSV) {
+        const contacts = collectAddressBook(messages);
+        if (contacts.length === 0) {
+            this.uiManager.showError('No email addresses found');
+            return;
+        }
 
-/**
- * Initialize theme functionality
- * Sets up theme toggle, dropdown menu, and icon updates
- */
-function initTheme() {
-    // Initialize theme manager
-    themeManager.init();
+        const date = new Date().toISOString().slice(0, 10);
+        const blob =
+            format === ADDRESS_BOOK_FORMATS.VCARD
+                ? this.uiManager.createTextBlob(addressBookToVCard(contacts), 'text/vcard')
+                : this.uiManager.createTextBlob(addressBookToCsv(contacts), 'text/csv');
 
-    // Theme toggle button (quick toggle)
-    const themeToggle = document.getElementById('themeToggle');
-    const themeMenuDropdown = document.getElementById('themeMenuDropdown');
+        this.uiManager.showInfo(`${contacts.length} address(es) in ${messages.length} email(s)`);
+        await this.uiManager.downloadBlob(
+            blob,
+            `msgReader-address-book-${scopeLabel}-${date}.${format}`,
+            'Address book saved successfully',
+            'Failed to save address book'
+        );
+    }
 
-    // Toggle dropdown on button click
-    themeToggle?.addEventListener('click', (e) => {
-        e.stopPropagation();
-        themeMenuDropdown?.classList.toggle('active');
-    });
+    /**
+     * Lets the user pick a folder and exports the addresses of all emails in it
+     * @param {string} [format=ADDRESS_BOOK_FORMATS.CSV] - csv or vcf
+     */
+    extractFolderAddressBook(format = ADDRESS_BOOK_FORMATS.CSV) {
+        this.pickFolderMessages((messages) => this.downloadAddressBook(messages, 'folder', format));
+    }
 
-    // Close dropdown when clicking outside
-    document.addEventListener('click', (e) => {
-        if (!e.target.closest('#themeMenu')) {
-            themeMenuDropdown?.classList.remove('active');
+    /**
+     * Downloads the metadata of the given messages for review tools (load file)
+     * @param {Array<Object>} messages - Messages to describe
+     * @param {string} scopeLabel - Scope used in the file name and the JSON
+     * @param {string} [format=METADATA_EXPORT_FORMATS.CSV] - csv or json
+     */
+    async downloadMetadata(messages, scopeLabel, format = METADATA_EXPORT_FORMATS.CSV) {
+        const records = collectMessageMetadata(messages);
+        const date = new Date().toISOString().slice(0, 10);
+        const blob =
+            format === METADATA_EXPORT_FORMATS.JSON
+                ? this.uiManager.createTextBlob(
+                      metadataToJson(records, { scope: scopeLabel }),
+                      'application/json'
+                  )
+                : this.uiManager.createTextBlob(metadataToCsv(records), 'text/csv');
+
+        await this.uiManager.downloadBlob(
+            blob,
+            `msgReader-metadata-${scopeLabel}-${date}.${format}`,
+            'Metadata saved successfully',
+            'Failed to save metadata'
+        );
+    }
+
+    /**
+     * Lets the user pick a folder and exports the metadata of all emails in it
+     * @param {string} [format=METADATA_EXPORT_FORMATS.CSV] - csv or json
+     */
+    exportFolderMetadata(format = METADATA_EXPORT_FORMATS.CSV) {
+        this.pickFolderMessages((messages) => this.downloadMetadata(messages, 'folder', format));
+    }
+
+    /**
+     * Lets the user pick a folder and lists all emails in it (desktop app only).
+     * Unlike pickFolderMessages, nothing is parsed until an email is clicked.
+     * @param {boolean} [recursive=false] - Include subfolders
+     */
+    async openFolder(recursive = false) {
+        if (!this.folderBrowser) return;
+
+        const folder = await pickFolder();
+        if (folder) await this.folderBrowser.open(folder, recursive);
+    }
+
+    /**
+     * Lets the user pick an Outlook data file and browses its folders (desktop app only)
+     */
+    async openPstFile() {
+        if (!this.pstBrowser) return;
+
+        const path = await pickPstFile();
+        if (path) await this.pstBrowser.open(path);
+    }
+
+    /**
+     * Opens a message of a PST file like an .msg file
+     * @param {string} path - Path of the PST file
+     * @param {{id: number, subject: string}} message - Message from the PST browser
+     */
+    async openPstMessage(path, message) {
+        const fileName = `${message.subject || 'message'}.msg`;
+        try {
+            const buffer = await readPstMessage(path, message.id);
+            await this.fileHandler.handleMessageBuffer(buffer, fileName, `${path}#${message.id}`);
+        } catch (error) {
+            console.error('Failed to read PST message:', error);
+            this.uiManager.showError(`Failed to open: ${fileName}`);
         }
-    });
+    }
 
-    // Handle menu item clicks
-    document.querySelectorAll('.theme-menu-item').forEach(item => {
-        item.addEventListener('click', () => {
-            const theme = item.dataset.theme;
-            const type = item.dataset.type;
+    /**
+     * Lets the user pick an mbox file and lists its messages (desktop app only)
+     */
+    async openMboxFile() {
+        if (!this.mboxBrowser) return;
 
-            if (type === 'app') {
-                themeManager.setTheme(theme);
-            } else if (type === 'email') {
-                themeManager.setEmailTheme(theme);
-            } else if (type === 'inline-images') {
-                setInlineImageAttachmentVisibility(item.dataset.inlineImages);
-                document.dispatchEvent(new CustomEvent('inline-image-attachment-visibility-change', {
-                    detail: { visibility: item.dataset.inlineImages }
-                }));
-            } else if (type === 'pdf-attachments') {
-                setPdfAttachmentOpenMode(item.dataset.pdfOpenMode);
-            }
+        const path = await pickMboxFile();
+        if (path) await this.mboxBrowser.open(path);
+    }
 
-            updateThemeUI();
-            themeMenuDropdown?.classList.remove('active');
-        });
-    });
+    /**
+     * Opens a message of an mbox file like an .eml file
+     * @param {string} path - Path of the mbox file
+     * @param {{index: number, subject: string}} message - Message from the mbox browser
+     */
+    async openMboxMessage(path, message) {
+        const fileName = `${message.subject || 'message'}.eml`;
+        try {
+            const buffer = await readMboxMessage(path, message.index);
+            await this.fileHandler.handleMessageBuffer(
+                buffer,
+                fileName,
+                `${path}#${message.index}`,
+                'eml'
+            );
+        } catch (error) {
+            console.error('Failed to read mbox message:', error);
+            this.uiManager.showError(`Failed to open: ${fileName}`);
+        }
+    }
 
-    // Listen for theme changes
-    themeManager.addListener(() => {
-        updateThemeUI();
-    });
+    /**
+     * Opens a message of a ZIP archive; its type is detected from its content
+     * @param {string} path - Path of the ZIP file
+     * @param {{index: number, name: string}} member - Message from the ZIP browser
+     */
+    async openZipMessage(path, member) {
+        const fileName = zipMemberFileName(member.name);
+        try {
+            const buffer = await readZipMessage(path, member.index);
+            await this.fileHandler.handleMessageBuffer(
+                buffer,
+                fileName,
+                `${path}#${member.index}`,
+                detectInputType(buffer)
+            );
+        } catch (error) {
+            console.error('Failed to read ZIP member:', error);
+            this.uiManager.showError(`Failed to open: ${fileName}`);
+        }
+    }
 
-    document.addEventListener('inline-image-attachment-visibility-change', () => {
-        updateThemeUI();
-    });
+    /**
+     * Opens a gzip-compressed .msg or .eml file; its type is detected from its content
+     * @param {string} path - Path of the .gz file
+     */
+    async openGzipMessage(path) {
+        const fileName = getFileName(path).replace(/\.gz$/i, '');
+        try {
+            const buffer = await readGzipMessage(path);
+            await this.fileHandler.handleMessageBuffer(
+                buffer,
+                fileName,
+                path,
+                detectInputType(buffer)
+            );
+        } catch (error) {
+            console.error('Failed to read gzip file:', error);
+            this.uiManager.showError(`Failed to open: ${fileName}`);
+        }
+    }
 
-    // Initial UI update
-    updateThemeUI();
-}
+    /**
+     * Shows the IMAP accounts or the mailbox that was open last (desktop app only)
+     */
+    openImapMailbox() {
+        this.imapBrowser?.open();
+    }
 
-/**
- * Update settings UI elements
- * Updates active states in dropdown menu
- */
-function updateThemeUI() {
-    const savedTheme = themeManager.getSavedTheme();
-    const savedEmailTheme = themeManager.getSavedEmailTheme();
-    const inlineImageVisibility = getInlineImageAttachmentVisibility();
-    const pdfAttachmentOpenMode = getPdfAttachmentOpenMode();
+    /**
+     * Downloads a message from an IMAP server and opens it like an .eml file
+     * @param {{host: string, port: number, username: string}} account
+     * @param {string} folder - Folder name on the server
+     * @param {{uid: number, subject: string}} message - Message from the IMAP browser
+     */
+    async openImapMessage(account, folder, message) {
+        const fileName = `${message.subject || 'message'}.eml`;
+        const { host, port, username } = account;
+        try {
+            const buffer = await readImapMessage(account, folder, message.uid);
+            await this.fileHandler.handleMessageBuffer(
+                buffer,
+                fileName,
+                `imap://${username}@${host}:${port}/${folder}#${message.uid}`,
+                'eml'
+            );
+        } catch (error) {
+            console.error('Failed to read IMAP message:', error);
+            this.uiManager.showError(`Failed to open: ${fileName}`);
+        }
+    }
 
-    // Update active states in dropdown menu
-    document.querySelectorAll('.theme-menu-item[data-type="app"]').forEach(item => {
-        item.classList.toggle('active', item.dataset.theme === savedTheme);
-    });
+    /**
+     * Stores the current message with its original file in the local archive (desktop
+     * app only). The archive is not encrypted, so nothing is added to it while data
+     * encryption is on.
+     */
+    async archiveCurrentMessage() {
+        if (dataEncryption.isEnabled()) {
+            this.uiManager.showError(
+                'The archive is not encrypted, messages cannot be added while data encryption is on'
+            );
+            return;
+        }
+        const message = this.messageHandler.getCurrentMessage();
+        if (!message?._rawBuffer) {
+            this.uiManager.showError('Open a message to archive it');
+            return;
+        }
 
-    document.querySelectorAll('.theme-menu-item[data-type="email"]').forEach(item => {
-        item.classList.toggle('active', item.dataset.theme === savedEmailTheme);
-    });
+        try {
+            const { added } = await importToArchive(
+                arrayBufferToBase64(message._rawBuffer),
+                message.fileName
+            );
+            this.uiManager.showInfo(
+                added ? 'Message added to the archive' : 'Message is already in the archive'
+            );
+        } catch (error) {
+            console.error('Failed to archive message:', error);
+            this.uiManager.showError(`Failed to archive message: ${error}`);
+        }
+    }
 
-    document.querySelectorAll('.theme-menu-item[data-type="inline-images"]').forEach(item => {
-        item.classList.toggle('active', item.dataset.inlineImages === inlineImageVisibility);
-    });
+    /**
+     * Lists the archived messages (desktop app only)
+     */
+    async openArchive() {
+        if (this.archiveBrowser) await this.archiveBrowser.open();
+    }
 
-    document.querySelectorAll('.theme-menu-item[data-type="pdf-attachments"]').forEach(item => {
-        item.classList.toggle('active', item.dataset.pdfOpenMode === pdfAttachmentOpenMode);
-    });
-}
+    /**
+     * Opens an archived message from its stored file
+     * @param {{id: number, fileName: string}} entry - Entry from the archive browser
+     */
+    async openArchivedMessage(entry) {
+        try {
+            const buffer = await readArchiveMessage(entry.id);
+            await this.fileHandler.handleMessageBuffer(
+                buffer,
+                entry.fileName,
+                `archive#${entry.id}`,
+                detectInputType(buffer)
+            );
+        } catch (error) {
+            console.error('Failed to read archived message:', error);
+            this.uiManager.showError(`Failed to open: ${entry.fileName}`);
+        }
+    }
 
-// Initialize the app when the DOM is loaded
-if (typeof window !== 'undefined') {
-    window.App = App;
-    document.addEventListener('DOMContentLoaded', async () => {
-        // Initialize theme first to prevent flash of wrong theme
-        initTheme();
+    /**
+     * Reopens the messages that were open when the app last exited (desktop app only)
+     */
+    async restoreSession() {
+        try {
+            const missing = await reopenSession(this);
+            if (missing.length > 0) {
+                const names = missing.map((entry) => entry.fileName).join(', ');
+                this.uiManager.showWarning(`No longer found: ${names}`, 6000);
+            }
+        } catch (error) {
+            console.error('Failed to restore the session:', error);
+            this.uiManager.showError(`Failed to restore the last session: ${error}`);
+        }
+    }
 
-        window.app = new App();
+    /**
+     * Lists the messages by conversation or as a flat list, as chosen in the settings.
+     * Conversations are only grouped in the desktop app.
+     */
+    applyMessageListGrouping() {
+        const byConversation =
+            getMessageListGrouping() === MESSAGE_LIST_GROUPING.CONVERSATIONS;
+        if (isTauri() && byConversation) {
+            this.groupConversations();
+        } else {
+            this.messageHandler.clearThreads();
+            if (this.messageHandler.getMessages().length > 0) {
+                this.uiManager.updateMessageList();
+            }
+        }
+    }
+
+    /**
+     * Groups the loaded messages into conversations. Called again by the message list
+     * when messages were added or removed since.
+     */
+    async groupConversations() {
+        if (this.groupingConversations) return;
+
+        this.groupingConversations = true;
+        try {
+            const threads = await getThreads(this.messageHandler.getMessages());
+            // The setting may have been changed back while waiting
+            if (
+                threads &&
+                getMessageListGrouping() === MESSAGE_LIST_GROUPING.CONVERSATIONS
+            ) {
+                this.messageHandler.setThreads(threads);
+            }
+        } catch (error) {
+            console.error('Failed to group conversations:', error);
+            this.uiManager.showError('Failed to group conversations');
+            this.messageHandler.clearThreads();
+        } finally {
+            this.groupingConversations = false;
+        }
+        if (this.messageHandler.getMessages().length > 0) {
+            this.uiManager.updateMessageList();
+        }
+    }
+
+    /**
+     * Finds loaded messages that are copies of each other (desktop app only) and offers
+     * to close the copies, keeping the first of each group. Otherwise the copies are
+     * selected so they can be reviewed.
+     */
+    async findDuplicateMessages() {
+        const messages = this.messageHandler.getMessages();
+        let groups;
+        try {
+            groups = await findDuplicates(messages);
+        } catch (error) {
+            console.error('Failed to find duplicates:', error);
+            this.uiManager.showError('Failed to find duplicates');
+            return;
+        }
+        if (!groups) return;
+        if (groups.length === 0) {
+            this.uiManager.showInfo('No duplicate messages found');
+            return;
+        }
+
+        const copyHashes = new Set(groups.flatMap((group) => group.keys.slice(1)));
+        const copies = messages.filter((message) => copyHashes.has(message.messageHash));
+        const confirmed = window.confirm(
+            `${copies.length} of ${messages.length} messages are copies of another one ` +
+                `(${groups.length} ${groups.length === 1 ? 'group' : 'groups'}). ` +
+                'Close the copies and keep the first of each?'
+        );
+        if (!confirmed) {
+            this.messageHandler.clearSelection();
+            this.messageHandler.selectMessages(copies);
+            this.uiManager.updateMessageList();
+            return;
+        }
+
+        const currentMessage = this.messageHandler.getCurrentMessage();
+        copies.forEach((message) => {
+            auditLog.record(AUDIT_ACTIONS.DELETE, { message });
+            this.messageHandler.deleteMessage(this.messageHandler.getMessages().indexOf(message));
+            releaseLargeMessage(message);
+        });
+        this.uiManager.updateMessageList();
+        if (copies.includes(currentMessage)) {
+            this.uiManager.showMessage(this.messageHandler.getMessages()[0]);
+        }
+        this.uiManager.showInfo(`Closed ${copies.length} duplicate message(s)`);
+    }
+
+    /**
+     * Shows how the two selected messages differ (desktop app only), e.g. an original and
+     * its journaled or forwarded copy
+     */
+    async compareSelectedMessages() {
+        const selected = this.messageHandler.getSelectedMessages();
+        if (selected.length !== 2) return;
+
+        const [a, b] = selected;
+        const toInput = (message) => ({
+            ...messageToJson(message),
+            rawHeaders: message._exportMeta?.rawHeaders || ''
+        });
+        let diff;
+        try {
+            diff = await compareMessages(toInput(a), toInput(b));
+        } catch (error) {
+            console.error('Failed to compare messages:', error);
+            this.uiManager.showError(`Failed to compare messages: ${error}`);
+            return;
+        }
+        const label = (message) => message.fileName || message.subject || '(no subject)';
+        this.comparisonModal.open(diff, label(a), label(b));
+    }
+
+    /**
+     * Loads remote images of a sender without asking from now on
+     * @param {string} email - Sender address
+     */
+    allowRemoteImagesFrom(email) {
+        const senders = [...getRemoteImageSenders(), email];
+        if (!setRemoteImageSenders(senders)) return;
+
+        settingsSync.publish('remoteImageSenders', getRemoteImageSenders());
+        updateThemeUI();
+        const currentMessage = this.messageHandler.getCurrentMessage();
+        if (currentMessage) this.uiManager.showMessage(currentMessage);
+        this.uiManager.showInfo(`Remote images of ${email} are loaded from now on`);
+    }
+
+    /**
+     * Shows the locally collected usage statistics
+     */
+    showUsageStats() {
+        this.usageStatsModal.open();
+    }
+
+    /**
+     * Saves the settings and data of the active profile as a backup ZIP
+     */
+    async exportAppData() {
+        try {
+            const appInfo = await getAppInfo();
+            const backup = await createAppDataBackup({
+                profile: profileManager.getActiveProfile(),
+                appVersion: appInfo?.version || ''
+            });
+            if (dataEncryption.isEnabled()) {
+                this.uiManager.showWarning('The backup is not encrypted, store it safely');
+            }
+            await this.uiManager.downloadBlob(
+                backup.blob,
+                backup.fileName,
+                'Backup saved successfully',
+                'Failed to save backup'
+            );
+        } catch (error) {
+            console.error('Failed to create backup:', error);
+            this.uiManager.showError('Failed to create backup');
+        }
+    }
+
+    /**
+     * Opens a file picker for a backup ZIP and restores it
+     */
+    pickAppDataBackup() {
+        const input = document.createElement('input');
+        input.type = 'file';
+        input.accept = '.zip,application/zip';
+        input.addEventListener('change', () => {
+            if (input.files?.[0]) {
+                this.importAppData(input.files[0]);
+            }
+        });
+        input.click();
+    }
+
+    /**
+     * Replaces the settings and data of the active profile with a backup and
+     * restarts the app so every setting takes effect
+     * @param {Blob} file - Backup created by exportAppData
+     * @returns {Promise<boolean>} True if the backup was restored
+     */
+    async importAppData(file) {
+        let backup;
+        try {
+            backup = await readAppDataBackup(file);
+        } catch (error) {
+            console.error('Failed to read backup:', error);
+            this.uiManager.showError(error.message || 'Failed to read backup');
+            return false;
+        }
+
+        const date = String(backup.manifest.createdAt || '').slice(0, 10) || 'an unknown date';
+        const confirmed = window.confirm(
+            `Replace the settings and data of this profile with the backup from ${date}?`
+        );
+        if (!confirmed) return false;
+
+        const { skipped } = restoreAppData(backup.entries);
+        if (skipped.length > 0) {
+            console.warn('Skipped unknown backup entries:', skipped);
+        }
+        await dataEncryption.flush();
+        window.location.reload();
+        return true;
+    }
+
+    /**
+     * Saves the shareable configuration as a settings bundle
+     */
+    async exportSettingsBundle() {
+        const appInfo = await getAppInfo();
+        const bundle = createSettingsBundle({ appVersion: appInfo?.version || '' });
+        await this.uiManager.downloadBlob(
+            this.uiManager.createTextBlob(JSON.stringify(bundle, null, 2), 'application/json'),
+            `msgReader-settings-${bundle.createdAt.slice(0, 10)}.json`,
+            'Settings bundle saved successfully',
+            'Failed to save settings bundle'
+        );
+    }
+
+    /**
+     * Opens a file picker for a settings bundle and imports it
+     */
+    pickSettingsBundle() {
+        const input = document.createElement('input');
+        input.type = 'file';
+        input.accept = '.json,application/json';
+        input.addEventListener('change', () => {
+            if (input.files?.[0]) {
+                this.importSettingsBundle(input.files[0]);
+            }
+        });
+        input.click();
+    }
+
+    /**
+     * Shows which settings a bundle would change and applies them after confirmation
+     * @param {Blob} file - Bundle created by exportSettingsBundle
+     * @returns {Promise<number>} Number of settings changed
+     */
+    async importSettingsBundle(file) {
+        let plan;
+        try {
+            plan = planSettingsImport(parseSettingsBundle(await file.text()));
+        } catch (error) {
+            console.error('Failed to read settings bundle:', error);
+            this.uiManager.showError(error.message || 'Failed to read settings bundle');
+            return 0;
+        }
+
+        if (!(await this.settingsImportModal.review(plan))) return 0;
+
+        const changed = applySettingsImport(plan);
+        if (isTauri()) {
+            applyTempFileRetention(TEMP_FILE_RETENTION_MINUTES[getTempFileRetention()]);
+        }
+        document.dispatchEvent(new CustomEvent('inline-image-attachment-visibility-change', {
+            detail: { visibility: getInlineImageAttachmentVisibility() }
+        }));
+        updateThemeUI();
+        this.uiManager.showInfo(`${changed} setting(s) imported`);
+        return changed;
+    }
+
+    /**
+     * Downloads the audit log as a CSV file
+     */
+    async exportAuditLog() {
+        const date = new Date().toISOString().slice(0, 10);
+        const { valid, brokenAt } = auditLog.verify();
+        if (!valid) {
+            this.uiManager.showWarning(`Audit log integrity check failed at entry ${brokenAt}`);
+        }
+
+        await this.uiManager.downloadBlob(
+            this.uiManager.createTextBlob(auditLog.toCsv(), 'text/csv'),
+            `msgReader-audit-log-${date}.csv`,
+            'Audit log exported successfully',
+            'Failed to export audit log'
+        );
+    }
+}
+
+/**
+ * Settings overridden with MSGREADER_* environment variables or command-line flags
+ * @type {{dataDir: string|null, logLevel: string|null, offline: boolean, readOnly: boolean,
+ *     kiosk: boolean}}
+ */
+let overrides = { dataDir: null, logLevel: null, offline: false, readOnly: false, kiosk: false };
+
+/**
+ * Applies the administrative policies and the environment and command-line overrides
+ * before any setting is read
+ */
+async function initOverrides() {
+    managedPolicy.apply(await getPolicy().catch(() => null));
+    overrides = await getOverrides().catch(() => overrides);
+
+    if (overrides.logLevel) {
+        errorHandler.setLogLevel(overrides.logLevel);
+    }
+    if (overrides.readOnly && storage.backend) {
+        storage.backend = new ReadOnlyBackend(storage.backend);
+    }
+    if (overrides.kiosk) {
+        kioskMode.enable();
+    }
+}
+
+/**
+ * Selects the profile before any setting is read: the one given with --profile
+ * (desktop) or ?profile= (web), otherwise the startup chooser if enabled
+ */
+async function initProfile() {
+    let requested;
+    if (isTauri()) {
+        const state = await getProfile();
+        if (state?.selected) requested = state.name;
+    } else {
+        requested = getProfileFromUrl(window.location.search);
+    }
+
+    let { profile, choose } = resolveStartupProfile({
+        requested,
+        profiles: profileManager.getProfiles(),
+        chooserEnabled: profileManager.isChooserEnabled()
+    });
+    if (choose) {
+        const chooser = new ProfileChooser(document.getElementById('profileChooserModal'));
+        profile = await chooser.choose(profileManager.getProfiles());
+        await setProfile(profile);
+    }
+
+    profileManager.activate(profile);
+    // Message windows are titled after their message
+    if (profile && !isMessageWindow()) {
+        document.title = `msgReader - ${profile}`;
+    }
+}
+
+/**
+ * Unlocks the encrypted data of the active profile with the key from the OS
+ * keychain or, if there is none, the passphrase
+ */
+async function initEncryption() {
+    if (!dataEncryption.isEnabled()) return;
+
+    const account = getKeychainAccount(profileManager.getActiveProfile());
+    const storedKey = await getStoredSecret(account).catch(() => null);
+    if (storedKey && (await dataEncryption.unlockWithRawKey(storedKey))) return;
+
+    const prompt = new PassphrasePrompt(document.getElementById('passphraseModal'));
+    let error = '';
+    for (;;) {
+        const result = await prompt.unlock({ error });
+        if (result === null) {
+            dataEncryption.reset();
+            await deleteStoredSecret(account).catch(() => {});
+            return;
+        }
+        if (await dataEncryption.unlock(result.passphrase)) return;
+        error = 'Wrong passphrase, please try again.';
+    }
+}
+
+/**
+ * Asks for a passphrase and encrypts the data of the active profile
+ */
+async function enableEncryption() {
+    if (dataEncryption.isEnabled()) return;
+
+    const prompt = new PassphrasePrompt(document.getElementById('passphraseModal'));
+    const result = await prompt.create({ canRemember: isTauri() });
+    if (!result) return;
+
+    const ok = await dataEncryption.enable(result.passphrase, { extractable: result.remember });
+    if (!ok) {
+        window.app?.uiManager.showError('Could not turn on encryption');
+        return;
+    }
+    if (result.remember) {
+        const account = getKeychainAccount(profileManager.getActiveProfile());
+        try {
+            await storeSecret(account, await dataEncryption.exportKey());
+        } catch (error) {
+            console.error('Failed to store key in keychain:', error);
+            window.app?.uiManager.showError('Could not store the key in the OS keychain');
+        }
+    }
+    updateThemeUI();
+    window.app?.uiManager.showInfo('Local data is now encrypted');
+
+    const archived = await getArchiveMessages({}, 0, 1).catch(() => null);
+    if (archived?.total > 0) {
+        window.app?.uiManager.showWarning(
+            'Messages in the archive are not encrypted, delete them in the archive browser',
+            6000
+        );
+    }
+}
+
+/**
+ * Writes the data of the active profile back in plain text
+ */
+async function disableEncryption() {
+    if (!dataEncryption.isUnlocked()) return;
+
+    await dataEncryption.disable();
+    await deleteStoredSecret(getKeychainAccount(profileManager.getActiveProfile())).catch(
+        () => {}
+    );
+    updateThemeUI();
+    window.app?.uiManager.showInfo('Local data is no longer encrypted');
+}
+
+/**
+ * Lets the user pick another profile and restarts the app with it
+ */
+async function switchProfile() {
+    const chooser = new ProfileChooser(document.getElementById('profileChooserModal'));
+    const activeProfile = profileManager.getActiveProfile();
+    const profile = await chooser.choose(profileManager.getProfiles(), {
+        activeProfile,
+        cancellable: true
+    });
+    if (profile === undefined || profile === activeProfile) return;
+
+    profileManager.addProfile(profile);
+    if (isTauri()) {
+        await setProfile(profile);
+        window.location.reload();
+        return;
+    }
+
+    const url = new URL(window.location.href);
+    url.searchParams.set('profile', profile || '');
+    window.location.assign(url);
+}
+
+/**
+ * Settings the backend keeps in its settings file, see settingsSync.js
+ */
+const settingsSync = new SettingsSync({
+    theme: {
+        storageKey: THEME_STORAGE_KEYS.APP_THEME,
+        apply: (value) => themeManager.setTheme(value || THEMES.SYSTEM)
+    },
+    externalContent: {
+        storageKey: EXTERNAL_CONTENT_STORAGE_KEY,
+        apply: (value) => {
+            if (setExternalContent(value || EXTERNAL_CONTENT.BLOCK)) {
+                const currentMessage = window.app?.messageHandler.getCurrentMessage();
+                if (currentMessage) window.app.uiManager.showMessage(currentMessage);
+            }
+        }
+    },
+    remoteImageSenders: {
+        storageKey: REMOTE_IMAGE_SENDERS_STORAGE_KEY,
+        apply: (value) => {
+            if (setRemoteImageSenders(value || [])) {
+                const currentMessage = window.app?.messageHandler.getCurrentMessage();
+                if (currentMessage) window.app.uiManager.showMessage(currentMessage);
+            }
+        }
+    },
+    defaultSaveDirectory: {
+        storageKey: DEFAULT_SAVE_DIRECTORY_STORAGE_KEY,
+        apply: (value) => setDefaultSaveDirectory(value || '')
+    },
+    startup: {
+        storageKey: STARTUP_BEHAVIOR_STORAGE_KEY,
+        apply: (value) => setStartupBehavior(value || STARTUP_BEHAVIOR.WELCOME)
+    },
+    trayIcon: {
+        storageKey: TRAY_ICON_STORAGE_KEY,
+        apply: (value) => setTrayIcon(value || TRAY_ICON.HIDE)
+    }
+});
+
+/**
+ * Initialize Tauri-specific file handling
+ * Called after app initialization when running in Tauri
+ */
+async function initTauriFileHandling() {
+    // Apply the temp file retention preference to the backend sweeper
+    applyTempFileRetention(TEMP_FILE_RETENTION_MINUTES[getTempFileRetention()]);
+
+    // Settings shared with the backend: the settings file wins over the WebView storage
+    await settingsSync.load();
+    await onSettingsChanged((change) => {
+        if (settingsSync.handleChange(change)) updateThemeUI();
+    });
+    updateThemeUI();
+
+    // Offer recently opened files on the welcome screen
+    window.app.recentFiles = new RecentFilesList(document.getElementById('recentFiles'), {
+        onOpen: (filePath) => window.app.fileHandler.handleFileFromPath(filePath),
+        onError: (message) => window.app.uiManager.showError(message)
+    });
+    await window.app.recentFiles.refresh();
+
+    // Open whole folders of emails from the welcome screen and the settings menu
+    window.app.folderBrowser = new FolderBrowser(document.getElementById('folderBrowserModal'), {
+        onOpen: (filePath) => window.app.fileHandler.handleFileFromPath(filePath),
+        onError: (message) => window.app.uiManager.showError(message)
+    });
+    window.app.pstBrowser = new PstBrowser(document.getElementById('pstBrowserModal'), {
+        onOpen: (path, message) => window.app.openPstMessage(path, message),
+        onInfo: (message) => window.app.uiManager.showInfo(message),
+        onError: (message) => window.app.uiManager.showError(message)
+    });
+    window.app.mboxBrowser = new MboxBrowser(document.getElementById('mboxBrowserModal'), {
+        onOpen: (path, message) => window.app.openMboxMessage(path, message),
+        onInfo: (message) => window.app.uiManager.showInfo(message),
+        onError: (message) => window.app.uiManager.showError(message)
+    });
+    window.app.zipBrowser = new ZipBrowser(document.getElementById('zipBrowserModal'), {
+        onOpen: (path, member) => window.app.openZipMessage(path, member),
+        onError: (message) => window.app.uiManager.showError(message)
+    });
+    window.app.imapBrowser = new ImapBrowser(document.getElementById('imapBrowserModal'), {
+        onOpen: (account, folder, message) => window.app.openImapMessage(account, folder, message),
+        onError: (message) => window.app.uiManager.showError(message)
+    });
+    window.app.archiveBrowser = new ArchiveBrowser(
+        document.getElementById('archiveBrowserModal'),
+        {
+            onOpen: (entry) => window.app.openArchivedMessage(entry),
+            onInfo: (message) => window.app.uiManager.showInfo(message),
+            onError: (message) => window.app.uiManager.showError(message)
+        }
+    );
+    window.app.fileHandler.setDataFileHandler((path, extension) => {
+        if (extension === 'gz') {
+            window.app.openGzipMessage(path);
+            return;
+        }
+        const browsers = { mbox: window.app.mboxBrowser, zip: window.app.zipBrowser };
+        const browser = browsers[extension] || window.app.pstBrowser;
+        browser.open(path);
+    });
+    const openFolderOption = document.getElementById('openFolderOption');
+    if (openFolderOption) {
+        openFolderOption.hidden = false;
+        openFolderOption.querySelector('button')?.addEventListener('click', () => {
+            window.app.openFolder();
+        });
+    }
+
+    // A message window shows the message it was opened with; files opened from outside
+    // the app, watched folders, automation and updates are handled by the main window
+    const messageWindow = isMessageWindow();
+    if (messageWindow) {
+        await loadWindowMessage(window.app.fileHandler);
+    } else {
+        await openStartupFiles();
+    }
+
+    // Listen for drag & drop events (Tauri-specific)
+    await onFileDrop({
+        onDrop: async (filePaths) => {
+            // Ignore a message dragged out of the app and released over its own window
+            if (window.app.uiManager.isDraggingMessageOut()) return;
+
+            // Folders are expanded by the backend, files opened in one batch
+            await window.app.fileHandler.handleDroppedPaths(filePaths);
+        },
+        onEnter: () => {
+            if (window.app.uiManager.isDraggingMessageOut()) return;
+            window.app.uiManager.showDropOverlay();
+        },
+        onLeave: () => {
+            window.app.uiManager.hideDropOverlay();
+        },
+    });
+
+    // Load remote images through the backend proxy once the user allows them
+    window.app.uiManager.setRemoteImageProxy(await getRemoteImageProxy());
+
+    // Open new files from watched folders
+    if (!messageWindow) await initWatchedFolders();
+
+    // Report the progress and result of folder conversions
+    await initBatchConversions();
+
+    // Offer installed export plugins in the export menu
+    window.app.uiManager.setExportPlugins(await listExportPlugins());
+
+    // Offer "translate" if a translation service is configured in the backend
+    window.app.uiManager.setTranslationStatus(await getTranslationStatus());
+
+    // Read messages aloud with OS voices
+    window.app.uiManager.setReadAloudAvailable(true);
+    await onSpeechEnded(() => window.app.uiManager.handleSpeechEnded());
+    initSpeechVoices();
+
+    // Decrypt S/MIME messages with the OS certificate store where the backend supports it
+    window.app.uiManager.setSmimeKeystoreAvailable(await isSmimeKeystoreAvailable());
+
+    if (messageWindow) return;
+
+    // Answer requests from the local automation endpoint (opt-in)
+    await initAutomationApi(window.app);
+    if (automationApiEnabled()) {
+        await applyAutomationApi(true);
+    }
+
+    // Check for updates (runs in background, shows dialog if update available)
+    if (!overrides.offline && !managedPolicy.get('disableAutoUpdate')) {
+        let downloadAnnounced = false;
+        checkForUpdates({
+            onProgress: () => {
+                if (downloadAnnounced) return;
+                downloadAnnounced = true;
+                window.app?.uiManager.showInfo('Downloading update...');
+            }
+        });
+    }
+}
+
+/**
+ * Opens the files and archived messages the app was started with (double-click to open
+ * or msgreader:// link), or the last file, and those opened while it runs
+ */
+async function openStartupFiles() {
+    const pendingFiles = await getPendingFiles();
+    const pendingArchiveMessages = await getPendingArchiveMessages();
+    if (pendingFiles.length > 0) {
+        // Use batch method for multiple files
+        await window.app.fileHandler.handleFilesFromPaths(pendingFiles);
+    } else if (pendingArchiveMessages.length === 0) {
+        const startupBehavior = getStartupBehavior();
+        if (startupBehavior === STARTUP_BEHAVIOR.LAST_FILE) {
+            const [lastFile] = window.app.recentFiles.files;
+            if (lastFile) await window.app.fileHandler.handleFileFromPath(lastFile.path);
+        } else if (startupBehavior === STARTUP_BEHAVIOR.SESSION) {
+            await window.app.restoreSession();
+        }
+    }
+    for (const entry of pendingArchiveMessages) {
+        await window.app.openArchivedMessage(entry);
+    }
+
+    // Record what is open from now on; the backend saves it when the app exits
+    trackSession(window.app.messageHandler);
+
+    // Listen for files opened while app is running (double-click or msgreader:// link)
+    await onFileOpen((filePath) => {
+        window.app.fileHandler.handleFileFromPath(filePath);
+    });
+    await onArchiveMessageOpen((entry) => {
+        window.app.openArchivedMessage(entry);
+    });
+}
+
+/**
+ * Watches the saved folders and opens the files that appear in them
+ */
+async function initWatchedFolders() {
+    await onWatchedFile((filePath) => {
+        window.app.fileHandler.handleFileFromPath(filePath);
+        window.app.uiManager.showInfo(`New file: ${getFileName(filePath)}`);
+    });
+    // A clicked notification shows the new message, which is open already unless it was
+    // closed since
+    await onNotificationOpen((filePath) => {
+        const message = window.app.messageHandler
+            .getMessages()
+            .find((candidate) => candidate._sourcePath === filePath);
+        if (message) {
+            window.app.uiManager.showMessage(message);
+        } else {
+            window.app.fileHandler.handleFileFromPath(filePath);
+        }
+    });
+
+    // Only the folders the backend restores can be watched again
+    await restoreWatchedFolders();
+    for (const folder of getWatchedFolders()) {
+        try {
+            await watchFolder(folder);
+        } catch (error) {
+            console.error(`Failed to watch ${folder}:`, error);
+            window.app.uiManager.showWarning(`Cannot watch ${folder}`);
+        }
+    }
+
+    document.getElementById('watchedFolderList')?.addEventListener('click', (e) => {
+        const item = e.target.closest('[data-watched-folder]');
+        if (item) removeWatchedFolder(item.dataset.watchedFolder);
+    });
+    renderWatchedFolders();
+}
+
+/**
+ * Asks for a folder and starts watching it
+ */
+async function addWatchedFolder() {
+    const folder = await pickFolder();
+    if (!folder || getWatchedFolders().includes(folder)) return;
+
+    try {
+        await watchFolder(folder);
+        setWatchedFolders([...getWatchedFolders(), folder]);
+        renderWatchedFolders();
+        window.app?.uiManager.showInfo(`Watching ${folder}`);
+    } catch (error) {
+        console.error('Failed to watch folder:', error);
+        window.app?.uiManager.showError('Failed to watch folder');
+    }
+}
+
+/**
+ * Stops watching a folder
+ * @param {string} folder - Watched folder
+ */
+async function removeWatchedFolder(folder) {
+    try {
+        await unwatchFolder(folder);
+    } catch (error) {
+        console.error(`Failed to stop watching ${folder}:`, error);
+    }
+    setWatchedFolders(getWatchedFolders().filter((watched) => watched !== folder));
+    renderWatchedFolders();
+}
+
+/**
+ * Lists the watched folders in the settings menu; clicking one stops watching it
+ */
+function renderWatchedFolders() {
+    const list = document.getElementById('watchedFolderList');
+    if (!list) return;
+
+    list.innerHTML = getWatchedFolders()
+        .map(
+            (folder) => `
+            <button class="theme-menu-item watched-folder-item" data-watched-folder="${escapeHTML(folder)}"
+                    title="Stop watching ${escapeHTML(folder)}">
+                <svg xmlns="http://www.w3.org/2000/svg" fill="none" viewBox="0 0 24 24" stroke-width="1.5" stroke="currentColor">
+                    <path stroke-linecap="round" stroke-linejoin="round" d="M6 18 18 6M6 6l12 12" />
+                </svg>
+                <span>${escapeHTML(folder)}</span>
+            </button>`
+        )
+        .join('');
+}
+
+/** Job of the running folder conversion, null if none runs */
+let runningConversion = null;
+
+/**
+ * Follows folder conversions: failed files are logged, the summary is shown when a job ends
+ */
+async function initBatchConversions() {
+    await onConversionProgress((progress) => {
+        if (runningConversion?.jobId === progress.jobId) runningConversion.done = progress.done;
+        if (progress.status === 'failed') {
+            console.warn(`Failed to convert ${progress.path}: ${progress.error}`);
+        }
+    });
+    await onConversionFinished((report) => {
+        if (runningConversion?.jobId === report.jobId) runningConversion = null;
+        // Empty folders were already reported when the job started
+        if (report.total === 0) return;
+
+        const summary =
+            `Converted ${report.converted} of ${report.total} file(s)` +
+            (report.failed > 0 ? `, ${report.failed} failed` : '') +
+            (report.cancelled ? ' (cancelled)' : '');
+        if (report.failed > 0 || report.cancelled) {
+            window.app?.uiManager.showWarning(summary);
+        } else {
+            window.app?.uiManager.showInfo(summary);
+        }
+    });
+}
+
+/**
+ * Asks for a folder and a destination and converts the messages in it and its
+ * subfolders. Only one conversion runs at a time; asking again offers to cancel it.
+ * @param {string} format - 'eml', 'pdf', 'json' or 'text'
+ */
+async function convertFolder(format) {
+    if (runningConversion) {
+        const { jobId, done, total } = runningConversion;
+        if (window.confirm(`A folder conversion is running (${done} of ${total}). Cancel it?`)) {
+            await cancelBatchConversion(jobId);
+        }
+        return;
+    }
+
+    try {
+        const job = await startBatchConversion(format, { recursive: true });
+        if (!job) return;
+        if (job.total === 0) {
+            window.app?.uiManager.showInfo('No .msg or .eml files in this folder');
+            return;
+        }
+        runningConversion = { jobId: job.jobId, done: 0, total: job.total };
+        window.app?.uiManager.showInfo(`Converting ${job.total} file(s) to ${job.output}`);
+    } catch (error) {
+        console.error('Failed to convert folder:', error);
+        window.app?.uiManager.showError('Failed to convert folder');
+    }
+}
+
+/**
+ * Lists the senders whose remote images load without asking; clicking one removes it
+ * @param {boolean} locked - Whether a policy blocks remote images anyway
+ */
+function renderRemoteImageSenders(locked) {
+    const list = document.getElementById('remoteImageSenderList');
+    if (!list) return;
+
+    list.innerHTML = locked
+        ? ''
+        : getRemoteImageSenders()
+              .map(
+                  (sender) => `
+            <button class="theme-menu-item" data-remote-image-sender="${escapeHTML(sender)}"
+                    title="Ask again before loading images of ${escapeHTML(sender)}">
+                <svg xmlns="http://www.w3.org/2000/svg" fill="none" viewBox="0 0 24 24" stroke-width="1.5" stroke="currentColor">
+                    <path stroke-linecap="round" stroke-linejoin="round" d="M6 18 18 6M6 6l12 12" />
+                </svg>
+                <span>${escapeHTML(sender)}</span>
+            </button>`
+              )
+              .join('');
+}
+
+/**
+ * Asks again before loading the remote images of a sender
+ * @param {string} sender - Entry of the allowed senders
+ */
+function removeRemoteImageSender(sender) {
+    const senders = getRemoteImageSenders().filter((allowed) => allowed !== sender);
+    if (!setRemoteImageSenders(senders)) return;
+
+    settingsSync.publish('remoteImageSenders', senders.length > 0 ? senders : null);
+    updateThemeUI();
+    const currentMessage = window.app?.messageHandler.getCurrentMessage();
+    if (currentMessage) window.app.uiManager.showMessage(currentMessage);
+}
+
+/**
+ * Shows in the settings menu whether msgReader opens .msg and .eml files by default
+ */
+async function renderFileAssociations() {
+    const list = document.getElementById('fileAssociationList');
+    if (!list) return;
+
+    const associations = await getFileAssociationStatus().catch((error) => {
+        console.error('Failed to read file associations:', error);
+        return [];
+    });
+    list.innerHTML = associations
+        .map(
+            ({ extension, isDefault, handler }) => `
+            <div class="theme-menu-item file-association-item" title="${escapeHTML(handler || 'No default app')}">
+                <span>.${escapeHTML(extension)}</span>
+                <span class="file-association-status">${isDefault ? 'msgReader' : 'Other app'}</span>
+            </div>`
+        )
+        .join('');
+}
+
+/**
+ * Makes msgReader the default app where the OS allows it, otherwise opens the OS settings
+ * for default apps or explains where to change them
+ */
+async function changeDefaultApps() {
+    try {
+        if (await makeDefaultApp()) {
+            window.app?.uiManager.showInfo('msgReader now opens .msg and .eml files');
+            renderFileAssociations();
+        } else if (!(await openDefaultAppsSettings())) {
+            window.app?.uiManager.showInfo(
+                'To change the default app, choose "Open With" for a .msg or .eml file ' +
+                    'in your file manager'
+            );
+        }
+    } catch (error) {
+        console.error('Failed to change the default app:', error);
+        window.app?.uiManager.showError(`Failed to change the default app: ${error}`);
+    }
+}
+
+/**
+ * Removes the file associations msgReader can remove itself, after asking
+ */
+async function removeFileAssociations() {
+    if (!window.confirm('Stop opening .msg and .eml files with msgReader?')) return;
+
+    try {
+        if (await unregisterFileAssociations()) {
+            window.app?.uiManager.showInfo('msgReader no longer opens .msg and .eml files');
+            renderFileAssociations();
+        } else {
+            window.app?.uiManager.showInfo(
+                'The file associations were added by the installer or package; ' +
+                    'uninstall msgReader to remove them'
+            );
+        }
+    } catch (error) {
+        console.error('Failed to remove the file associations:', error);
+        window.app?.uiManager.showError(`Failed to remove the file associations: ${error}`);
+    }
+}
+
+/**
+ * Copies the latest log entries of the desktop app for a bug report
+ */
+async function copyRecentLogs() {
+    try {
+        const entries = await getRecentLogs(500);
+        const text = entries
+            .map((entry) => {
+                const time = new Date(entry.time).toISOString();
+                return `${time} ${entry.level.toUpperCase()} [${entry.source}] ${entry.message}`;
+            })
+            .join('\n');
+        await navigator.clipboard.writeText(text);
+        window.app?.uiManager.showInfo(`Copied ${entries.length} log entries to clipboard`);
+    } catch (error) {
+        console.error('Failed to copy log entries:', error);
+        window.app?.uiManager.showError('Failed to copy log entries');
+    }
+}
+
+/**
+ * Fills the read aloud voice selector with the installed OS voices
+ */
+async function initSpeechVoices() {
+    const select = document.getElementById('speechVoiceSelect');
+    if (!select) return;
+
+    const voices = await listSpeechVoices();
+    voices
+        .slice()
+        .sort((a, b) => a.language.localeCompare(b.language) || a.name.localeCompare(b.name))
+        .forEach((voice) => {
+            const option = document.createElement('option');
+            option.value = voice.id;
+            option.textContent = `${voice.name} (${voice.language})`;
+            select.appendChild(option);
+        });
+
+    // Fall back to the system default if the saved voice was uninstalled
+    const savedVoice = getSpeechVoice();
+    select.value = voices.some((voice) => voice.id === savedVoice) ? savedVoice : '';
+    select.addEventListener('change', () => setSpeechVoice(select.value));
+}
+
+/**
+ * Enables or disables the automation endpoint in the backend
+ * @param {boolean} enabled - Whether requests are accepted
+ * @param {boolean} [notify=false] - Show the endpoint path to the user
+ */
+async function applyAutomationApi(enabled, notify = false) {
+    try {
+        const endpoint = await setAutomationEnabled(enabled);
+        if (notify && enabled) {
+            window.app?.uiManager.showInfo(`Automation API listening on ${endpoint}`, 6000);
+        }
+    } catch (error) {
+        console.error('Failed to configure automation API:', error);
+        window.app?.uiManager.showError('Failed to start automation API');
+    }
+}
+
+/**
+ * Connects the accessibility selectors in the settings menu
+ */
+function initAccessibilitySettings() {
+    const selects = document.querySelectorAll('select[data-accessibility-setting]');
+    const numericSettings = [ACCESSIBILITY_SETTINGS.UI_SCALE, ACCESSIBILITY_SETTINGS.MIN_FONT_SIZE];
+
+    selects.forEach((select) => {
+        const setting = select.dataset.accessibilitySetting;
+        select.value = String(accessibilityManager.get(setting));
+        select.addEventListener('change', () => {
+            const value = numericSettings.includes(setting) ? Number(select.value) : select.value;
+            accessibilityManager.set(setting, value);
+        });
+    });
+
+    accessibilityManager.addListener((setting) => {
+        selects.forEach((select) => {
+            select.value = String(accessibilityManager.get(select.dataset.accessibilitySetting));
+        });
+
+        // Re-render the open message so its text picks up the new minimum size
+        const currentMessage = window.app?.messageHandler.getCurrentMessage();
+        if (setting === ACCESSIBILITY_SETTINGS.MIN_FONT_SIZE && currentMessage) {
+            window.app.uiManager.showMessage(currentMessage);
+        }
+    });
+}
+
+/**
+ * Sets up the language choice of the settings menu
+ */
+function initLanguageSetting() {
+    const select = document.getElementById('languageSelect');
+    if (!select) return;
+
+    select.value = getLanguagePreference();
+    select.addEventListener('change', async () => {
+        if (!setLanguagePreference(select.value)) return;
+
+        await loadTranslations();
+        translateElements();
+        updateThemeUI();
+        const currentMessage = window.app?.messageHandler.getCurrentMessage();
+        if (currentMessage) window.app.uiManager.showMessage(currentMessage);
+    });
+}
+
+/**
+ * Initialize theme functionality
+ * Sets up theme toggle, dropdown menu, and icon updates
+ */
+function initTheme() {
+    // Initialize theme manager
+    themeManager.init();
+    accessibilityManager.init();
+    initAccessibilitySettings();
+    initLanguageSetting();
+
+    // Theme toggle button (quick toggle)
+    const themeToggle = document.getElementById('themeToggle');
+    const themeMenuDropdown = document.getElementById('themeMenuDropdown');
+
+    // Toggle dropdown on button click
+    themeToggle?.addEventListener('click', (e) => {
+        e.stopPropagation();
+        themeMenuDropdown?.classList.toggle('active');
+        // The default app may have been changed in the OS since the menu was last open
+        if (isTauri() && themeMenuDropdown?.classList.contains('active')) {
+            renderFileAssociations();
+        }
+    });
+
+    // Close dropdown when clicking outside
+    document.addEventListener('click', (e) => {
+        if (!e.target.closest('#themeMenu')) {
+            themeMenuDropdown?.classList.remove('active');
+        }
+    });
+
+    // Temp files are only created by the desktop app
+    document.getElementById('tempFilesMenuSection')?.classList.toggle('hidden', !isTauri());
+    document.getElementById('automationMenuSection')?.classList.toggle('hidden', !isTauri());
+    document.getElementById('diagnosticsMenuSection')?.classList.toggle('hidden', !isTauri());
+    document.getElementById('fileAssociationMenuSection')?.classList.toggle('hidden', !isTauri());
+    document.getElementById('speechMenuSection')?.classList.toggle('hidden', !isTauri());
+    document.getElementById('startupMenuSection')?.classList.toggle('hidden', !isTauri());
+    document.getElementById('trayIconMenuSection')?.classList.toggle('hidden', !isTauri());
+    document.getElementById('messageListMenuSection')?.classList.toggle('hidden', !isTauri());
+    document.getElementById('saveDirectoryMenuSection')?.classList.toggle('hidden', !isTauri());
+    document.getElementById('openFolderMenuSection')?.classList.toggle('hidden', !isTauri());
+    document.getElementById('archiveMenuSection')?.classList.toggle('hidden', !isTauri());
+    document.getElementById('watchFolderMenuSection')?.classList.toggle('hidden', !isTauri());
+    document.getElementById('batchConvertMenuSection')?.classList.toggle('hidden', !isTauri());
+    document.getElementById('redactionMenuSection')?.classList.toggle('hidden', !isTauri());
+    document.getElementById('remoteImageCacheClear')?.classList.toggle('hidden', !isTauri());
+    document
+        .getElementById('encryptionMenuSection')
+        ?.classList.toggle('hidden', !dataEncryption.isSupported());
+
+    // Allowed senders are rendered later, so their clicks are handled by the list
+    document.getElementById('remoteImageSenderList')?.addEventListener('click', (e) => {
+        const item = e.target.closest('[data-remote-image-sender]');
+        if (item) removeRemoteImageSender(item.dataset.remoteImageSender);
+    });
+
+    // Handle menu item clicks
+    document.querySelectorAll('.theme-menu-item').forEach(item => {
+        item.addEventListener('click', () => {
+            const theme = item.dataset.theme;
+            const type = item.dataset.type;
+
+            if (type === 'app') {
+                themeManager.setTheme(theme);
+                settingsSync.publish('theme', theme);
+            } else if (type === 'email') {
+                themeManager.setEmailTheme(theme);
+            } else if (type === 'inline-images') {
+                setInlineImageAttachmentVisibility(item.dataset.inlineImages);
+                document.dispatchEvent(new CustomEvent('inline-image-attachment-visibility-change', {
+                    detail: { visibility: item.dataset.inlineImages }
+                }));
+            } else if (type === 'external-content') {
+                if (setExternalContent(item.dataset.externalContent)) {
+                    settingsSync.publish('externalContent', item.dataset.externalContent);
+                    const currentMessage = window.app?.messageHandler.getCurrentMessage();
+                    if (currentMessage) window.app.uiManager.showMessage(currentMessage);
+                }
+            } else if (type === 'remote-image-cache-clear') {
+                clearRemoteImageCache().then((count) => {
+                    window.app?.uiManager.showInfo(`Removed ${count} cached image(s)`);
+                });
+            } else if (type === 'pdf-attachments') {
+                setPdfAttachmentOpenMode(item.dataset.pdfOpenMode);
+            } else if (type === 'export-checksums') {
+                setExportChecksumMode(item.dataset.checksumMode);
+            } else if (type === 'redaction-addresses') {
+                setRedactionOptions({ addresses: item.dataset.redactionAddresses });
+            } else if (type === 'redaction-option') {
+                const option = item.dataset.redactionOption;
+                setRedactionOptions({ [option]: !getRedactionOptions()[option] });
+            } else if (type === 'pii-detector') {
+                const enabled = new Set(getEnabledPiiDetectors());
+                if (enabled.has(item.dataset.detector)) {
+                    enabled.delete(item.dataset.detector);
+                } else {
+                    enabled.add(item.dataset.detector);
+                }
+                setEnabledPiiDetectors([...enabled]);
+            } else if (type === 'message-list-grouping') {
+                if (setMessageListGrouping(item.dataset.grouping)) {
+                    window.app?.applyMessageListGrouping();
+                }
+            } else if (type === 'find-duplicates') {
+                window.app?.findDuplicateMessages();
+            } else if (type === 'startup') {
+                if (setStartupBehavior(item.dataset.startup)) {
+                    settingsSync.publish('startup', item.dataset.startup);
+                }
+            } else if (type === 'tray-icon') {
+                if (setTrayIcon(item.dataset.trayIcon)) {
+                    settingsSync.publish('trayIcon', item.dataset.trayIcon);
+                }
+            } else if (type === 'save-directory-pick') {
+                // The backend stores the folder and reports it with settings-changed
+                pickDefaultSaveDirectory().catch((error) => {
+                    console.error('Failed to choose save folder:', error);
+                    window.app?.uiManager.showError('Failed to choose save folder');
+                });
+            } else if (type === 'save-directory-reset') {
+                setDefaultSaveDirectory('');
+                settingsSync.publish('defaultSaveDirectory', null);
+            } else if (type === 'open-pst') {
+                window.app?.openPstFile();
+            } else if (type === 'open-mbox') {
+                window.app?.openMboxFile();
+            } else if (type === 'open-imap') {
+                window.app?.openImapMailbox();
+            } else if (type === 'open-folder') {
+                window.app?.openFolder(item.dataset.recursive === 'true');
+            } else if (type === 'watch-folder-add') {
+                addWatchedFolder();
+            } else if (type === 'batch-convert') {
+                convertFolder(item.dataset.format);
+            } else if (type === 'temp-retention') {
+                setTempFileRetention(item.dataset.retention);
+                applyTempFileRetention(TEMP_FILE_RETENTION_MINUTES[item.dataset.retention]);
+            } else if (type === 'temp-clear') {
+                clearTempFiles().then((count) => {
+                    window.app?.uiManager.showInfo(`Removed ${count} temporary file(s)`);
+                });
+            } else if (type === 'automation-api') {
+                setAutomationApi(item.dataset.automationApi);
+                applyAutomationApi(item.dataset.automationApi === 'enabled', true);
+            } else if (type === 'archive-add') {
+                window.app?.archiveCurrentMessage();
+            } else if (type === 'archive-open') {
+                window.app?.openArchive();
+            } else if (type === 'default-apps-settings') {
+                changeDefaultApps();
+            } else if (type === 'file-associations-remove') {
+                removeFileAssociations();
+            } else if (type === 'log-folder-open') {
+                openLogFolder().catch((error) => {
+                    console.error('Failed to open log folder:', error);
+                    window.app?.uiManager.showError('Failed to open log folder');
+                });
+            } else if (type === 'logs-copy') {
+                copyRecentLogs();
+            } else if (type === 'audit-log') {
+                auditLog.setEnabled(item.dataset.auditLog === 'enabled');
+            } else if (type === 'audit-log-export') {
+                window.app?.exportAuditLog();
+            } else if (type === 'usage-stats') {
+                usageStats.setEnabled(item.dataset.usageStats === 'enabled');
+            } else if (type === 'usage-stats-show') {
+                window.app?.showUsageStats();
+            } else if (type === 'app-data-export') {
+                window.app?.exportAppData();
+            } else if (type === 'app-data-import') {
+                window.app?.pickAppDataBackup();
+            } else if (type === 'settings-bundle-export') {
+                window.app?.exportSettingsBundle();
+            } else if (type === 'settings-bundle-import') {
+                window.app?.pickSettingsBundle();
+            } else if (type === 'profile-switch') {
+                switchProfile();
+            } else if (type === 'profile-chooser') {
+                profileManager.setChooserEnabled(!profileManager.isChooserEnabled());
+            } else if (type === 'encryption') {
+                if (item.dataset.encryption === 'enabled') {
+                    enableEncryption();
+                } else {
+                    disableEncryption();
+                }
+            }
+
+            updateThemeUI();
+            themeMenuDropdown?.classList.remove('active');
+        });
+    });
+
+    // Listen for theme changes
+    themeManager.addListener(() => {
+        updateThemeUI();
+    });
+
+    document.addEventListener('inline-image-attachment-visibility-change', () => {
+        updateThemeUI();
+    });
+
+    // Initial UI update
+    updateThemeUI();
+}
+
+/**
+ * Update settings UI elements
+ * Updates active states in dropdown menu
+ */
+function updateThemeUI() {
+    const savedTheme = themeManager.getSavedTheme();
+    const savedEmailTheme = themeManager.getSavedEmailTheme();
+    const inlineImageVisibility = getInlineImageAttachmentVisibility();
+    const externalContent = getExternalContent();
+    const externalContentLocked = externalContentManaged();
+    const pdfAttachmentOpenMode = getPdfAttachmentOpenMode();
+    const exportChecksumMode = getExportChecksumMode();
+    const redactionOptions = getRedactionOptions();
+    const enabledPiiDetectors = getEnabledPiiDetectors();
+    const tempFileRetention = getTempFileRetention();
+    const startupBehavior = getStartupBehavior();
+    const trayIcon = getTrayIcon();
+    const messageListGrouping = getMessageListGrouping();
+    const defaultSaveDirectory = getDefaultSaveDirectory();
+    const auditLogState = auditLog.isEnabled() ? 'enabled' : 'disabled';
+    const usageStatsState = usageStats.isEnabled() ? 'enabled' : 'disabled';
+    const activeProfile = profileManager.getActiveProfile();
+    const encryptionState = dataEncryption.isEnabled() ? 'enabled' : 'disabled';
+    const automationApiState = getAutomationApi();
+
+    // Update active states in dropdown menu
+    document.querySelectorAll('.theme-menu-item[data-type="app"]').forEach(item => {
+        item.classList.toggle('active', item.dataset.theme === savedTheme);
+    });
+
+    document.querySelectorAll('.theme-menu-item[data-type="email"]').forEach(item => {
+        item.classList.toggle('active', item.dataset.theme === savedEmailTheme);
+    });
+
+    document.querySelectorAll('.theme-menu-item[data-type="inline-images"]').forEach(item => {
+        item.classList.toggle('active', item.dataset.inlineImages === inlineImageVisibility);
+    });
+
+    document.querySelectorAll('.theme-menu-item[data-type="external-content"]').forEach(item => {
+        item.classList.toggle('active', item.dataset.externalContent === externalContent);
+        item.disabled = externalContentLocked;
+        item.title = externalContentLocked ? 'Set by your administrator' : '';
+    });
+    renderRemoteImageSenders(externalContentLocked);
+
+    document.querySelectorAll('.theme-menu-item[data-type="pdf-attachments"]').forEach(item => {
+        item.classList.toggle('active', item.dataset.pdfOpenMode === pdfAttachmentOpenMode);
+    });
+
+    document.querySelectorAll('.theme-menu-item[data-type="export-checksums"]').forEach(item => {
+        item.classList.toggle('active', item.dataset.checksumMode === exportChecksumMode);
+    });
+
+    document.querySelectorAll('.theme-menu-item[data-type="redaction-addresses"]').forEach(item => {
+        item.classList.toggle(
+            'active',
+            item.dataset.redactionAddresses === redactionOptions.addresses
+        );
+    });
+
+    document.querySelectorAll('.theme-menu-item[data-type="redaction-option"]').forEach(item => {
+        item.classList.toggle('active', redactionOptions[item.dataset.redactionOption] === true);
+    });
+
+    document.querySelectorAll('.theme-menu-item[data-type="pii-detector"]').forEach(item => {
+        item.classList.toggle('active', enabledPiiDetectors.includes(item.dataset.detector));
+    });
+
+    document.querySelectorAll('.theme-menu-item[data-type="startup"]').forEach(item => {
+        item.classList.toggle('active', item.dataset.startup === startupBehavior);
+    });
+
+    document.querySelectorAll('.theme-menu-item[data-type="tray-icon"]').forEach(item => {
+        item.classList.toggle('active', item.dataset.trayIcon === trayIcon);
+    });
+
+    document
+        .querySelectorAll('.theme-menu-item[data-type="message-list-grouping"]')
+        .forEach(item => {
+            item.classList.toggle('active', item.dataset.grouping === messageListGrouping);
+        });
+
+    const saveDirectoryName = document.getElementById('saveDirectoryName');
+    if (saveDirectoryName) {
+        saveDirectoryName.textContent = defaultSaveDirectory || 'System default';
+        saveDirectoryName.title = defaultSaveDirectory;
+    }
+
+    document.querySelectorAll('.theme-menu-item[data-type="temp-retention"]').forEach(item => {
+        item.classList.toggle('active', item.dataset.retention === tempFileRetention);
+    });
+
+    document.querySelectorAll('.theme-menu-item[data-type="audit-log"]').forEach(item => {
+        item.classList.toggle('active', item.dataset.auditLog === auditLogState);
+    });
+
+    const profileMenuName = document.getElementById('profileMenuName');
+    if (profileMenuName) {
+        profileMenuName.textContent = activeProfile ? `Profile: ${activeProfile}` : 'Default profile';
+    }
+
+    document.querySelectorAll('.theme-menu-item[data-type="profile-chooser"]').forEach(item => {
+        item.classList.toggle('active', profileManager.isChooserEnabled());
+    });
+
+    document.querySelectorAll('.theme-menu-item[data-type="encryption"]').forEach(item => {
+        item.classList.toggle('active', item.dataset.encryption === encryptionState);
+    });
+
+    // The archive is not encrypted
+    document
+        .querySelector('.theme-menu-item[data-type="archive-add"]')
+        ?.classList.toggle('hidden', encryptionState === 'enabled');
+
+    document.querySelectorAll('.theme-menu-item[data-type="usage-stats"]').forEach(item => {
+        item.classList.toggle('active', item.dataset.usageStats === usageStatsState);
+    });
+
+    document.querySelectorAll('.theme-menu-item[data-type="automation-api"]').forEach(item => {
+        item.classList.toggle('active', item.dataset.automationApi === automationApiState);
+    });
+}
+
+// Initialize the app when the DOM is loaded
+if (typeof window !== 'undefined') {
+    window.App = App;
+    document.addEventListener('DOMContentLoaded', async () => {
+        // Overrides decide where settings are read from and whether they are saved
+        await initOverrides();
+
+        // Settings are stored per profile, so the profile has to be known first
+        await initProfile();
+        await initEncryption();
+
+        // UI strings of the preferred language, before anything is rendered
+        await loadTranslations();
+        translateElements();
+
+        // Initialize theme before the app to prevent flash of wrong theme
+        initTheme();
+
+        window.app = new App();
+        if (overrides.readOnly) {
+            window.app.uiManager.showInfo('Read-only mode: changes are not saved');
+        }
+        if (kioskMode.isEnabled()) {
+            window.app.uiManager.showInfo('Kiosk mode: messages can be viewed but not saved');
+        }
 
         // Initialize Tauri file handling if running in Tauri
         if (isTauri()) {
@@ -0,0 +1,137 @@
+/**
+ * Meeting Module
+ * Reads meeting requests, responses and cancellations - Outlook messages of class
+ * IPM.Schedule.Meeting.* and text/calendar parts of .eml files - in the desktop backend,
+ * which also saves them as .ics files that any calendar app can import.
+ */
+
+import { exportToIcs, parseMeeting } from './tauri-bridge.js';
+import { arrayBufferToBase64 } from './encoding.js';
+
+export const MEETING_METHOD_LABELS = {
+    REQUEST: 'Meeting request',
+    CANCEL: 'Meeting canceled',
+    REPLY: 'Meeting response',
+    PUBLISH: 'Calendar event'
+};
+
+const ATTENDEE_STATUS_LABELS = {
+    ACCEPTED: 'accepted',
+    DECLINED: 'declined',
+    TENTATIVE: 'tentative',
+    'NEEDS-ACTION': 'no response yet',
+    DELEGATED: 'delegated'
+};
+
+const ATTENDEE_ROLE_LABELS = {
+    'OPT-PARTICIPANT': 'optional',
+    'NON-PARTICIPANT': 'resource'
+};
+
+/** @type {WeakMap<Object, Promise<Object|null>>} */
+const meetings = new WeakMap();
+
+/**
+ * Whether a message may contain a meeting. The frontend parser drops inline calendar parts
+ * of .eml files, so the original file is searched for one.
+ * @param {Object} message - Parsed message with its original file (_rawBuffer)
+ * @returns {boolean}
+ */
+export function mayContainMeeting(message) {
+    if (!message?._rawBuffer) return false;
+    if (/^IPM\.Schedule\.Meeting\./i.test(message.messageClass || '')) return true;
+
+    const calendarAttachment = (message.attachments || []).some(
+        (attachment) =>
+            /^(text\/calendar|application\/ics)\b/i.test(attachment.attachMimeTag || '') ||
+            /\.ics$/i.test(attachment.fileName || '')
+    );
+    if (calendarAttachment) return true;
+    if (message._fileType !== 'eml') return false;
+
+    return new TextDecoder('latin1')
+        .decode(message._rawBuffer)
+        .toLowerCase()
+        .includes('text/calendar');
+}
+
+/**
+ * Reads the meeting of a message once
+ * @param {Object} message - Parsed message with its original file (_rawBuffer)
+ * @returns {Promise<Object|null>} Meeting from the backend (see parseMeeting), null if the
+ *     message has none
+ */
+export function loadMeeting(message) {
+    if (!mayContainMeeting(message)) return Promise.resolve(null);
+    if (!meetings.has(message)) {
+        const meeting = parseMeeting(arrayBufferToBase64(message._rawBuffer));
+        // A failed read can be retried when the message is shown again
+        meeting.catch(() => meetings.delete(message));
+        meetings.set(message, meeting);
+    }
+    return meetings.get(message);
+}
+
+/**
+ * Saves the meeting of a message as an .ics file chosen in a save dialog
+ * @param {Object} message - Parsed message with its original file (_rawBuffer)
+ * @returns {Promise<string|null>} Path of the file, null if cancelled
+ */
+export async function exportMeeting(message) {
+    if (!message?._rawBuffer) {
+        throw new Error('The original file of this message is not available');
+    }
+    return await exportToIcs(arrayBufferToBase64(message._rawBuffer));
+}
+
+/**
+ * When a meeting takes place, e.g. "Wed, Jul 10, 2024, 9:00 AM - 10:30 AM"
+ * @param {Object} meeting - Meeting from loadMeeting
+ * @param {string} [locale] - Locale for dates and times, the user's by default
+ * @returns {string} Empty if the meeting has no start
+ */
+export function formatMeetingTime(meeting, locale = undefined) {
+    if (meeting?.start == null) return '';
+
+    const day = { weekday: 'short', year: 'numeric', month: 'short', day: 'numeric' };
+    const time = { hour: 'numeric', minute: '2-digit' };
+    const start = new Date(meeting.start);
+    const end = meeting.end != null && meeting.end > meeting.start ? new Date(meeting.end) : null;
+
+    if (meeting.allDay) {
+        const format = (date) => date.toLocaleDateString(locale, { ...day, timeZone: 'UTC' });
+        // The end of an all-day event is the day after the last
+        const last = end ? new Date(end.getTime() - 86400000) : start;
+        return last > start ? `${format(start)} - ${format(last)}` : format(start);
+    }
+
+    // Times in an undefined timezone are shown as written in the invitation
+    const timeZone = meeting.floating ? 'UTC' : undefined;
+    const suffix = meeting.floating && meeting.timeZone ? ` (${meeting.timeZone})` : '';
+    const startText = start.toLocaleString(locale, { ...day, ...time, timeZone });
+    if (!end) return startText + suffix;
+
+    const sameDay =
+        start.toLocaleDateString(locale, { timeZone }) ===
+        end.toLocaleDateString(locale, { timeZone });
+    const endText = sameDay
+        ? end.toLocaleTimeString(locale, { ...time, timeZone })
+        : end.toLocaleString(locale, { ...day, ...time, timeZone });
+    return `${startText} - ${endText}${suffix}`;
+}
+
+/**
+ * Describes an attendee, e.g. "Bob <bob@example.com> (optional, accepted)"
+ * @param {{name: string, email: string, role: string, status: string}} attendee
+ * @returns {string}
+ */
+export function describeAttendee(attendee) {
+    const name =
+        attendee.name && attendee.email && attendee.name !== attendee.email
+            ? `${attendee.name} <${attendee.email}>`
+            : attendee.name || attendee.email;
+    const details = [ATTENDEE_ROLE_LABELS[attendee.role], ATTENDEE_STATUS_LABELS[attendee.status]]
+        .filter(Boolean)
+        .join(', ');
+    return details ? `${name} (${details})` : name;
+}
@@ -106,7 +106,12 @@ function replaceInlineDataUrlsWithCid(bodyHtml, inlineAttachments) {
     return rewrittenHtml;
 }
 
-function createPlainTextFallback(message) {
+/**
+ * Plain-text body of a message, derived from the HTML body if there is no text part
+ * @param {Object} message - Parsed message
+ * @returns {string} Body text
+ */
+export function getMessagePlainText(message) {
     if (message?.bodyContent) {
         return message.bodyContent.trim();
     }
@@ -195,14 +200,25 @@ function buildTopLevelHeaders(message) {
         .map(([name, value]) => `${name}: ${value}`);
 }
 
-export function getExportFileName(message, format) {
+/**
+ * Builds the export file name for a message
+ * @param {Object} message - Message object
+ * @param {string} format - Export format (eml, html, json, msg, original)
+ * @param {string} [extension] - Explicit extension, used for plugin formats
+ * @returns {string} File name
+ */
+export function getExportFileName(message, format, extension = '') {
     const extensionMap = {
         eml: 'eml',
         html: 'html',
+        json: 'json',
+        msg: 'msg',
+        'redacted-eml': 'redacted.eml',
+        'redacted-msg': 'redacted.msg',
         original: message?._fileType || 'msg'
     };
 
-    return `${getBaseName(message)}.${extensionMap[format] || 'dat'}`;
+    return `${getBaseName(message)}.${extension || extensionMap[format] || 'dat'}`;
 }
 
 export function getOriginalMessageMimeType(message) {
@@ -213,7 +229,7 @@ export function messageToEml(message) {
     const boundaryMixed = `----=_msgReader_mixed_${Math.random().toString(16).slice(2)}`;
     const boundaryRelated = `----=_msgReader_related_${Math.random().toString(16).slice(2)}`;
     const boundaryAlt = `----=_msgReader_alt_${Math.random().toString(16).slice(2)}`;
-    const bodyText = createPlainTextFallback(message);
+    const bodyText = getMessagePlainText(message);
     const originalHtml = message?.bodyContentHTML || `<pre>${escapeHTML(bodyText)}</pre>`;
     const { inlineAttachments, regularAttachments } = partitionAttachments(
         originalHtml,
@@ -388,3 +404,49 @@ export function messageToHtmlDocument(message) {
 </body>
 </html>`;
 }
+
+/**
+ * Converts a parsed message into a plain JSON-serializable object.
+ * Used by export plugins and other consumers that need the parsed content
+ * without the app's internal fields.
+ * @param {Object} message - Parsed message
+ * @param {Object} [options] - Conversion options
+ * @param {boolean} [options.includeAttachmentContent=true] - Include base64 attachment data
+ * @returns {Object} Message data
+ */
+export function messageToJson(message, options = {}) {
+    const includeAttachmentContent = options.includeAttachmentContent !== false;
+
+    return {
+        subject: message?.subject || '',
+        senderName: message?.senderName || '',
+        senderEmail: message?.senderEmail || '',
+        recipients: (message?.recipients || []).map((recipient) => ({
+            name: recipient.name || '',
+            email: getContactEmail(recipient) || '',
+            type: recipient.recipType || 'to'
+        })),
+        date: message?.timestamp instanceof Date && !Number.isNaN(message.timestamp.getTime())
+            ? message.timestamp.toISOString()
+            : message?.messageDeliveryTime || '',
+        messageId: getRawHeader(message, 'message-id') || message?.messageId || '',
+        headers: { ...getHeaderMap(message) },
+        bodyText: message?.bodyContent || '',
+        bodyHtml: message?.bodyContentHTML || '',
+        attachments: (message?.attachments || []).map((attachment) => {
+            const base64 = getAttachmentBase64(attachment);
+            return {
+                fileName: attachment.fileName || '',
+                mimeType: attachment.attachMimeTag || 'application/octet-stream',
+                contentId: attachment.pidContentId || attachment.contentId || '',
+                size: Math.floor((base64.length * 3) / 4) - (base64.match(/=+$/)?.[0].length || 0),
+                ...(includeAttachmentContent ? { contentBase64: base64 } : {})
+            };
+        }),
+        source: {
+            fileName: message?.fileName || '',
+            fileType: message?._fileType || '',
+            messageHash: message?.messageHash || ''
+        }
+    };
+}
@@ -0,0 +1,63 @@
+/**
+ * Message Window Module
+ * Opens messages in windows of their own, e.g. to compare two messages side by side. The
+ * backend keeps the message until the new window, which loads the same app, asks for it.
+ */
+
+import { isMessageWindow, openMessageInNewWindow, takeWindowMessage } from './tauri-bridge.js';
+import { arrayBufferToBase64, base64ToArrayBuffer } from './encoding.js';
+
+/**
+ * Whether a message can be opened in a new window: it needs its original file, in memory
+ * or (for very large messages) on disk
+ * @param {Object} message - Parsed message
+ * @returns {boolean}
+ */
+export function canOpenInNewWindow(message) {
+    if (isMessageWindow() || !['eml', 'msg'].includes(message?._fileType)) return false;
+    return Boolean(message._rawBuffer || message._sourcePath);
+}
+
+/**
+ * Opens a message in a new window
+ * @param {Object} message - Parsed message
+ * @returns {Promise<string>} Label of the new window
+ */
+export async function openInNewWindow(message) {
+    if (!canOpenInNewWindow(message)) {
+        throw new Error('This message cannot be opened in a new window');
+    }
+    const source = {
+        fileName: message.fileName,
+        fileType: message._fileType,
+        sourcePath: message._sourcePath || ''
+    };
+    if (message._rawBuffer) {
+        source.data = arrayBufferToBase64(message._rawBuffer);
+    } else {
+        source.path = message._sourcePath;
+    }
+    return await openMessageInNewWindow(source);
+}
+
+/**
+ * Shows the message a message window was opened with
+ * @param {import('./FileHandler.js').FileHandler} fileHandler
+ * @returns {Promise<boolean>} False if there was no message for this window
+ */
+export async function loadWindowMessage(fileHandler) {
+    const message = await takeWindowMessage();
+    if (!message) return false;
+
+    if (message.path) {
+        await fileHandler.handleFileFromPath(message.path);
+    } else {
+        await fileHandler.handleMessageBuffer(
+            base64ToArrayBuffer(message.data),
+            message.fileName,
+            message.sourcePath,
+            message.fileType
+        );
+    }
+    return true;
+}
@@ -0,0 +1,149 @@
+/**
+ * Metadata Export Module
+ * Builds load-file style metadata for a set of messages (sender, recipients, subject,
+ * dates, Message-ID, sizes and SHA-256 of the message and its attachments) as JSON or
+ * CSV, for review tools that should not need each message opened.
+ */
+
+import { formatContact, getContactEmail } from './addressUtils.js';
+import { escapeCsvValue } from './AuditLog.js';
+import { base64ToBuffer } from './encoding.js';
+import { sha256Hex } from './hashing.js';
+
+export const METADATA_EXPORT_FORMATS = {
+    JSON: 'json',
+    CSV: 'csv'
+};
+
+function toIsoDate(value) {
+    if (!value) return '';
+    const date = value instanceof Date ? value : new Date(value);
+    return Number.isNaN(date.getTime()) ? '' : date.toISOString();
+}
+
+function getRecipients(message, type) {
+    return (message.recipients || [])
+        .filter((recipient) => (recipient.recipType || 'to') === type)
+        .map((recipient) => ({ name: recipient.name || '', email: getContactEmail(recipient) }));
+}
+
+/**
+ * Size and SHA-256 of an attachment; attachments of large messages that were not read
+ * yet have their size but no hash
+ * @param {Object} attachment - Attachment of a parsed message
+ * @returns {{fileName: string, mimeType: string, size: number, sha256: string}}
+ */
+function describeAttachment(attachment) {
+    const base64 = attachment.contentBase64?.split(',')[1];
+    const bytes = base64 === undefined ? null : base64ToBuffer(base64);
+    return {
+        fileName: attachment.fileName || '',
+        mimeType: attachment.attachMimeTag || 'application/octet-stream',
+        size: bytes ? bytes.byteLength : Number(attachment.contentLength) || 0,
+        sha256: bytes ? sha256Hex(bytes) : ''
+    };
+}
+
+/**
+ * Collects the metadata of each message
+ * @param {Array<Object>} messages - Parsed messages
+ * @returns {Array<Object>} One record per message with fileName, sourcePath, subject,
+ *     senderName, senderEmail, to, cc, bcc, sent, received, messageId, size, sha256 and
+ *     attachments; size and sha256 are those of the original file (null without one)
+ */
+export function collectMessageMetadata(messages) {
+    return messages.map((message) => {
+        const original = message._rawBuffer || null;
+        return {
+            fileName: message.fileName || '',
+            sourcePath: message._sourcePath || '',
+            subject: message.subject || '',
+            senderName: message.senderName || '',
+            senderEmail: getContactEmail({ email: message.senderEmail }),
+            to: getRecipients(message, 'to'),
+            cc: getRecipients(message, 'cc'),
+            bcc: getRecipients(message, 'bcc'),
+            sent: toIsoDate(message.clientSubmitTime),
+            received: toIsoDate(message.messageDeliveryTime || message.timestamp),
+            messageId:
+                message.messageId || message._exportMeta?.headerMap?.['message-id'] || '',
+            size: original ? original.byteLength : null,
+            sha256: original ? sha256Hex(original) : null,
+            attachments: (message.attachments || []).map(describeAttachment)
+        };
+    });
+}
+
+/**
+ * Serializes metadata records as JSON
+ * @param {Array<Object>} records - Result of collectMessageMetadata
+ * @param {Object} [options]
+ * @param {string} [options.scope='messages'] - What the records were collected from
+ * @param {Date} [options.now=new Date()] - Time of the export
+ * @returns {string} JSON text
+ */
+export function metadataToJson(records, { scope = 'messages', now = new Date() } = {}) {
+    return JSON.stringify(
+        {
+            generatedAt: now.toISOString(),
+            scope,
+            messageCount: records.length,
+            messages: records
+        },
+        null,
+        2
+    );
+}
+
+function formatAddresses(addresses) {
+    return addresses.map(({ name, email }) => formatContact(name, email)).join('; ');
+}
+
+/**
+ * Serializes metadata records as CSV, one row per message. Attachment names, sizes and
+ * hashes are `; `-separated lists in the same order.
+ * @param {Array<Object>} records - Result of collectMessageMetadata
+ * @returns {string} CSV text
+ */
+export function metadataToCsv(records) {
+    const header = [
+        'File Name',
+        'Source Path',
+        'Subject',
+        'From Name',
+        'From Email',
+        'To',
+        'Cc',
+        'Bcc',
+        'Sent',
+        'Received',
+        'Message-ID',
+        'Size',
+        'SHA-256',
+        'Attachments',
+        'Attachment Names',
+        'Attachment Sizes',
+        'Attachment SHA-256'
+    ];
+    const rows = records.map((record) => [
+        record.fileName,
+        record.sourcePath,
+        record.subject,
+        record.senderName,
+        record.senderEmail,
+        formatAddresses(record.to),
+        formatAddresses(record.cc),
+        formatAddresses(record.bcc),
+        record.sent,
+        record.received,
+        record.messageId,
+        record.size,
+        record.sha256,
+        record.attachments.length,
+        record.attachments.map((attachment) => attachment.fileName).join('; '),
+        record.attachments.map((attachment) => attachment.size).join('; '),
+        record.attachments.map((attachment) => attachment.sha256).join('; ')
+    ]);
+
+    return [header, ...rows].map((row) => row.map(escapeCsvValue).join(',')).join('\r\n');
+}
@@ -0,0 +1,59 @@
+/**
+ * OLE Objects
+ * Outlook embeds some content as OLE objects instead of normal attachments: Excel ranges,
+ * equations, files inserted as an object. The frontend MSG parser cannot read them, so
+ * the desktop app replaces them with the files the backend unwraps from them.
+ */
+
+import { readOleObjects } from './tauri-bridge.js';
+
+/**
+ * Converts an OLE object read by the backend into an attachment of extractMsg
+ * @param {Object} attachment - Attachment in the shape of parseMsgFile
+ * @returns {Object} Attachment
+ */
+export function oleObjectToAttachment(attachment) {
+    const mimeType = attachment.mimeType || 'application/octet-stream';
+    return {
+        fileName: attachment.fileName,
+        attachMimeTag: mimeType,
+        contentLength: attachment.size,
+        ...(attachment.contentId ? { contentId: attachment.contentId } : {}),
+        contentBase64: `data:${mimeType};base64,${attachment.contentBase64}`
+    };
+}
+
+/**
+ * Replaces the OLE objects among the attachments of a parsed .msg file with the files
+ * they hold. The attachments are matched by position, so nothing is replaced if the
+ * parser listed more or fewer attachments than the file has (e.g. after unpacking a
+ * winmail.dat).
+ * @param {Object} msgInfo - Result of extractMsg
+ * @param {string} filePath - Path of the .msg file
+ * @returns {Promise<Object>} The message
+ */
+export async function replaceOleObjects(msgInfo, filePath) {
+    let result;
+    try {
+        result = await readOleObjects(filePath);
+    } catch (error) {
+        console.warn('Failed to read OLE objects:', error);
+        return msgInfo;
+    }
+    if (!result || result.objects.length === 0) return msgInfo;
+
+    const attachments = msgInfo.attachments || [];
+    if (attachments.length !== result.attachmentCount) {
+        console.warn(
+            `OLE objects not shown: ${attachments.length} attachments parsed, ` +
+                `${result.attachmentCount} in the file`
+        );
+        return msgInfo;
+    }
+
+    msgInfo.attachments = [...attachments];
+    for (const [index, attachment] of result.objects) {
+        msgInfo.attachments[index] = oleObjectToAttachment(attachment);
+    }
+    return msgInfo;
+}
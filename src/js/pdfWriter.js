@@ -0,0 +1,193 @@
+/**
+ * PDF Writer Module
+ * Minimal dependency-free writer for text-only PDF reports (A4, standard fonts).
+ * Supports headings, wrapped body text and monospaced lines for hashes and paths.
+ */
+
+const PAGE_WIDTH = 595;
+const PAGE_HEIGHT = 842;
+const MARGIN = 50;
+
+/**
+ * Line styles: font resource, size, leading, and wrap width in characters
+ */
+const LINE_STYLES = {
+    title: { font: 'F2', size: 18, leading: 26, maxChars: 50 },
+    heading: { font: 'F2', size: 12, leading: 20, maxChars: 75 },
+    body: { font: 'F1', size: 10, leading: 14, maxChars: 95 },
+    mono: { font: 'F3', size: 8, leading: 11, maxChars: 98 }
+};
+
+const FONTS = {
+    F1: 'Helvetica',
+    F2: 'Helvetica-Bold',
+    F3: 'Courier'
+};
+
+/**
+ * Converts text to a PDF literal string in WinAnsi (Latin-1) encoding.
+ * Characters outside Latin-1 are replaced with '?'.
+ * @param {string} text - Text to encode
+ * @returns {string} Escaped literal including parentheses
+ */
+export function toPdfString(text) {
+    const latin1 = Array.from(String(text ?? ''))
+        .map((char) => {
+            const code = char.charCodeAt(0);
+            if (char.length > 1 || code > 0xff) return '?';
+            if (code < 0x20) return ' ';
+            return char;
+        })
+        .join('');
+    return `(${latin1.replace(/[\\()]/g, (char) => `\\${char}`)})`;
+}
+
+/**
+ * Wraps text at word boundaries; words longer than the limit are hard-split
+ * @param {string} text - Text to wrap
+ * @param {number} maxChars - Maximum characters per line
+ * @returns {string[]} Wrapped lines
+ */
+export function wrapText(text, maxChars) {
+    const lines = [];
+    String(text ?? '')
+        .split(/\r?\n/)
+        .forEach((paragraph) => {
+            let line = '';
+            paragraph.split(/\s+/).forEach((word) => {
+                while (word.length > maxChars) {
+                    if (line) {
+                        lines.push(line);
+                        line = '';
+                    }
+                    lines.push(word.slice(0, maxChars));
+                    word = word.slice(maxChars);
+                }
+                if (!line) {
+                    line = word;
+                } else if (line.length + 1 + word.length <= maxChars) {
+                    line += ` ${word}`;
+                } else {
+                    lines.push(line);
+                    line = word;
+                }
+            });
+            lines.push(line);
+        });
+    return lines;
+}
+
+function formatPdfDate(date) {
+    const pad = (value) => String(value).padStart(2, '0');
+    return `D:${date.getUTCFullYear()}${pad(date.getUTCMonth() + 1)}${pad(date.getUTCDate())}${pad(
+        date.getUTCHours()
+    )}${pad(date.getUTCMinutes())}${pad(date.getUTCSeconds())}Z`;
+}
+
+/**
+ * Lays out lines into pages of content stream operators
+ * @param {Array<{text: string, style?: string}|null>} lines - Lines; null adds vertical space
+ * @returns {string[]} One content stream per page
+ */
+function layoutPages(lines) {
+    const pages = [];
+    let operators = [];
+    let y = PAGE_HEIGHT - MARGIN;
+
+    const newPage = () => {
+        pages.push(operators.join('\n'));
+        operators = [];
+        y = PAGE_HEIGHT - MARGIN;
+    };
+
+    lines.forEach((line) => {
+        if (!line) {
+            y -= LINE_STYLES.body.leading / 2;
+            return;
+        }
+
+        const style = LINE_STYLES[line.style] || LINE_STYLES.body;
+        wrapText(line.text, style.maxChars).forEach((segment) => {
+            if (y - style.leading < MARGIN) {
+                newPage();
+            }
+            y -= style.leading;
+            operators.push(
+                `BT /${style.font} ${style.size} Tf ${MARGIN} ${y} Td ${toPdfString(segment)} Tj ET`
+            );
+        });
+    });
+
+    if (operators.length > 0 || pages.length === 0) {
+        pages.push(operators.join('\n'));
+    }
+
+    return pages.map(
+        (content, index) =>
+            `${content}\nBT /F1 8 Tf ${PAGE_WIDTH - MARGIN - 60} ${MARGIN / 2} Td ${toPdfString(
+                `Page ${index + 1} of ${pages.length}`
+            )} Tj ET`
+    );
+}
+
+/**
+ * Creates a PDF document from lines of text
+ * @param {Array<{text: string, style?: string}|null>} lines - Lines ('title', 'heading', 'body', 'mono')
+ * @param {Object} [options] - Document options
+ * @param {string} [options.title] - Document title metadata
+ * @param {Date} [options.now=new Date()] - Creation date metadata
+ * @returns {Uint8Array} PDF file bytes
+ */
+export function createTextPdf(lines, options = {}) {
+    const now = options.now instanceof Date ? options.now : new Date();
+    const pageContents = layoutPages(lines);
+    const fontIds = Object.keys(FONTS);
+    const firstFontId = 4;
+    const firstPageId = firstFontId + fontIds.length;
+    const objects = [];
+
+    objects[1] = '<< /Type /Catalog /Pages 2 0 R >>';
+    const pageIds = pageContents.map((_, index) => firstPageId + index * 2);
+    objects[2] = `<< /Type /Pages /Kids [${pageIds.map((id) => `${id} 0 R`).join(' ')}] /Count ${
+        pageIds.length
+    } >>`;
+    objects[3] = `<< /Title ${toPdfString(options.title || '')} /Producer (msgReader) /CreationDate ${toPdfString(
+        formatPdfDate(now)
+    )} >>`;
+
+    const fontResources = fontIds
+        .map((name, index) => `/${name} ${firstFontId + index} 0 R`)
+        .join(' ');
+    fontIds.forEach((name, index) => {
+        objects[firstFontId + index] =
+            `<< /Type /Font /Subtype /Type1 /BaseFont /${FONTS[name]} /Encoding /WinAnsiEncoding >>`;
+    });
+
+    pageContents.forEach((content, index) => {
+        const pageId = pageIds[index];
+        objects[pageId] =
+            `<< /Type /Page /Parent 2 0 R /MediaBox [0 0 ${PAGE_WIDTH} ${PAGE_HEIGHT}] ` +
+            `/Resources << /Font << ${fontResources} >> >> /Contents ${pageId + 1} 0 R >>`;
+        objects[pageId + 1] = `<< /Length ${content.length} >>\nstream\n${content}\nendstream`;
+    });
+
+    let output = '%PDF-1.4\n';
+    const offsets = [];
+    for (let id = 1; id < objects.length; id++) {
+        offsets[id] = output.length;
+        output += `${id} 0 obj\n${objects[id]}\nendobj\n`;
+    }
+
+    const xrefOffset = output.length;
+    output += `xref\n0 ${objects.length}\n0000000000 65535 f \n`;
+    for (let id = 1; id < objects.length; id++) {
+        output += `${String(offsets[id]).padStart(10, '0')} 00000 n \n`;
+    }
+    output += `trailer\n<< /Size ${objects.length} /Root 1 0 R /Info 3 0 R >>\nstartxref\n${xrefOffset}\n%%EOF\n`;
+
+    const bytes = new Uint8Array(output.length);
+    for (let i = 0; i < output.length; i++) {
+        bytes[i] = output.charCodeAt(i);
+    }
+    return bytes;
+}
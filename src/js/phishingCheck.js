@@ -0,0 +1,55 @@
+/**
+ * Phishing Check Module
+ * Checks the links and Reply-To of the open message in the desktop backend, which reads
+ * the HTML body of the original file, and sums the findings up into one verdict.
+ */
+
+import { analyzePhishing } from './tauri-bridge.js';
+import { arrayBufferToBase64 } from './encoding.js';
+
+export const PHISHING_STATUS = {
+    PASS: 'pass',
+    WARNING: 'warning',
+    FAIL: 'fail'
+};
+
+/**
+ * Whether the original file of a message is available to check
+ * @param {Object} message - Parsed message
+ * @returns {boolean}
+ */
+export function canCheckPhishing(message) {
+    return Boolean(message?._rawBuffer && message._fileType);
+}
+
+/**
+ * Checks the links and Reply-To of a message
+ * @param {Object} message - Parsed message with its original file (_rawBuffer)
+ * @returns {Promise<Object>} Report from the backend (see analyzePhishing)
+ */
+export async function checkPhishing(message) {
+    if (!canCheckPhishing(message)) {
+        throw new Error('The original file of this message is not available');
+    }
+    return await analyzePhishing(arrayBufferToBase64(message._rawBuffer));
+}
+
+/**
+ * Sums up a report by its risk: high findings fail, medium ones are a warning
+ * @param {Object} report - Result of checkPhishing
+ * @returns {{status: string, text: string}} One of PHISHING_STATUS and a sentence
+ */
+export function getPhishingVerdict(report) {
+    const count = report?.findings?.length || 0;
+    if (count === 0) {
+        const links = report?.links || 0;
+        return {
+            status: PHISHING_STATUS.PASS,
+            text: `No suspicious links found (${links} ${links === 1 ? 'link' : 'links'} checked)`
+        };
+    }
+    return {
+        status: report.risk === 'high' ? PHISHING_STATUS.FAIL : PHISHING_STATUS.WARNING,
+        text: `${count} ${count === 1 ? 'sign' : 'signs'} of phishing found`
+    };
+}
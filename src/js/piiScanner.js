@@ -0,0 +1,254 @@
+/**
+ * PII Scanner Module
+ * Runs configurable detectors for personal data over message bodies and
+ * text attachments and produces a findings report with message references.
+ */
+
+import { storage } from './storage.js';
+import { dataUrlToBytes } from './hashing.js';
+import { escapeCsvValue } from './AuditLog.js';
+
+export const PII_DETECTORS_STORAGE_KEY = 'msgReader_piiDetectors';
+
+/**
+ * Checks an IBAN candidate with the ISO 13616 mod-97 algorithm
+ * @param {string} value - IBAN with or without spaces
+ * @returns {boolean}
+ */
+export function isValidIban(value) {
+    const iban = value.replace(/\s+/g, '').toUpperCase();
+    if (!/^[A-Z]{2}\d{2}[A-Z0-9]{11,30}$/.test(iban)) return false;
+
+    const rearranged = iban.slice(4) + iban.slice(0, 4);
+    let remainder = 0;
+    for (const char of rearranged) {
+        const digits = /\d/.test(char) ? char : String(char.charCodeAt(0) - 55);
+        for (const digit of digits) {
+            remainder = (remainder * 10 + Number(digit)) % 97;
+        }
+    }
+    return remainder === 1;
+}
+
+function isValidSsn(value) {
+    const [area, group, serial] = value.split('-');
+    return area !== '000' && area !== '666' && area[0] !== '9' && group !== '00' && serial !== '0000';
+}
+
+/**
+ * Built-in detectors, most specific first. `validate` filters false positives of the pattern.
+ */
+export const PII_DETECTORS = {
+    email: {
+        label: 'Email addresses',
+        pattern: /[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}/g
+    },
+    iban: {
+        label: 'IBANs',
+        pattern: /\b[A-Z]{2}\d{2}(?: ?[A-Z0-9]{4}){2,7}(?: ?[A-Z0-9]{1,4})?\b/g,
+        validate: isValidIban
+    },
+    nationalId: {
+        label: 'National IDs (US SSN, UK NI number)',
+        pattern: /\b(?:\d{3}-\d{2}-\d{4}|[A-CEGHJ-PR-TW-Z]{2} ?\d{2} ?\d{2} ?\d{2} ?[A-D])\b/g,
+        validate: (value) => !/^\d{3}-/.test(value) || isValidSsn(value)
+    },
+    phone: {
+        label: 'Phone numbers',
+        pattern: /(?<![\w+])(?:\+|00)?\d{1,3}[\s./-]?(?:\(\d{1,5}\)[\s./-]?)?\d{2,5}[\s./-]?\d{3,8}(?![\w-])/g,
+        validate: (value) => {
+            const digits = value.replace(/\D/g, '');
+            const isDate = /^(?:\d{4}[-./]\d{2}[-./]\d{2}|\d{2}[-./]\d{2}[-./]\d{4})$/.test(value);
+            return !isDate && digits.length >= 8 && digits.length <= 15;
+        }
+    }
+};
+
+const TEXT_ATTACHMENT_EXTENSIONS = ['txt', 'csv', 'tsv', 'htm', 'html', 'xml', 'json', 'eml', 'md', 'log'];
+
+/**
+ * Gets the ids of the enabled detectors
+ * @returns {string[]} Enabled detector ids (all by default)
+ */
+export function getEnabledPiiDetectors() {
+    const saved = storage.get(PII_DETECTORS_STORAGE_KEY, null);
+    if (!Array.isArray(saved)) return Object.keys(PII_DETECTORS);
+    return saved.filter((id) => PII_DETECTORS[id]);
+}
+
+/**
+ * Saves the enabled detectors
+ * @param {string[]} detectorIds - Detector ids to enable
+ * @returns {boolean} True if saved
+ */
+export function setEnabledPiiDetectors(detectorIds) {
+    if (!Array.isArray(detectorIds)) return false;
+    return storage.set(
+        PII_DETECTORS_STORAGE_KEY,
+        detectorIds.filter((id) => PII_DETECTORS[id])
+    );
+}
+
+/**
+ * Masks a finding so the report does not repeat the full personal data
+ * @param {string} value - Matched value
+ * @returns {string} Masked value, keeping the first two and last two characters
+ */
+export function maskValue(value) {
+    if (value.length <= 4) return '*'.repeat(value.length);
+    return `${value.slice(0, 2)}${'*'.repeat(value.length - 4)}${value.slice(-2)}`;
+}
+
+/**
+ * Runs detectors over a text.
+ * Detectors run in declaration order and a match overlapping an earlier match is
+ * dropped, so e.g. the digits of an IBAN are not reported again as a phone number.
+ * @param {string} text - Text to scan
+ * @param {string[]} [detectorIds] - Detectors to run (defaults to the enabled ones)
+ * @returns {Array<{detector: string, value: string, index: number}>} Findings in text order
+ */
+export function scanText(text, detectorIds = getEnabledPiiDetectors()) {
+    if (!text) return [];
+
+    const findings = [];
+    Object.keys(PII_DETECTORS)
+        .filter((id) => detectorIds.includes(id))
+        .forEach((id) => {
+            const detector = PII_DETECTORS[id];
+            for (const match of text.matchAll(detector.pattern)) {
+                const value = match[0].trim();
+                const start = match.index;
+                const end = start + match[0].length;
+                const overlaps = findings.some(
+                    (finding) => start < finding.index + finding.length && finding.index < end
+                );
+                if (!overlaps && (!detector.validate || detector.validate(value))) {
+                    findings.push({ detector: id, value, index: start, length: match[0].length });
+                }
+            }
+        });
+
+    return findings
+        .sort((a, b) => a.index - b.index)
+        .map(({ detector, value, index }) => ({ detector, value, index }));
+}
+
+function htmlToText(html) {
+    return html.replace(/<[^>]*>/g, ' ').replace(/&nbsp;/gi, ' ');
+}
+
+function getAttachmentText(attachment) {
+    const extension = (attachment.fileName || '').toLowerCase().split('.').pop();
+    const isText =
+        attachment.attachMimeTag?.startsWith('text/') ||
+        TEXT_ATTACHMENT_EXTENSIONS.includes(extension);
+    if (!isText || !attachment.contentBase64) return '';
+
+    try {
+        return new TextDecoder('utf-8').decode(dataUrlToBytes(attachment.contentBase64));
+    } catch {
+        return '';
+    }
+}
+
+/**
+ * Lists the scannable text parts of a message
+ * @param {Object} message - Parsed message
+ * @returns {Array<{location: string, text: string}>}
+ */
+function getMessageTextParts(message) {
+    const parts = [
+        { location: 'subject', text: message.subject || '' },
+        {
+            location: 'body',
+            text: message.bodyContent || htmlToText(message.bodyContentHTML || '')
+        }
+    ];
+
+    (message.attachments || []).forEach((attachment) => {
+        parts.push({
+            location: `attachment:${attachment.fileName || 'unnamed'}`,
+            text: getAttachmentText(attachment)
+        });
+    });
+
+    return parts;
+}
+
+/**
+ * Scans a set of messages and builds a findings report
+ * @param {Array} messages - Parsed messages
+ * @param {Object} [options] - Scan options
+ * @param {string[]} [options.detectors] - Detector ids (defaults to the enabled ones)
+ * @param {Date} [options.now=new Date()] - Report timestamp
+ * @returns {{generatedAt: string, detectors: string[], messageCount: number, findings: Array}}
+ */
+export function scanMessagesForPii(messages, options = {}) {
+    const detectorIds = options.detectors || getEnabledPiiDetectors();
+    const now = options.now instanceof Date ? options.now : new Date();
+    const findings = [];
+
+    messages.forEach((message) => {
+        const reference = {
+            fileName: message.fileName || '',
+            sourcePath: message._sourcePath || '',
+            subject: message.subject || '',
+            messageHash: message.messageHash || ''
+        };
+
+        getMessageTextParts(message).forEach(({ location, text }) => {
+            const grouped = new Map();
+            scanText(text, detectorIds).forEach(({ detector, value }) => {
+                const key = `${detector}\u0000${value}`;
+                const existing = grouped.get(key);
+                if (existing) {
+                    existing.count += 1;
+                } else {
+                    grouped.set(key, { detector, value, count: 1 });
+                }
+            });
+
+            grouped.forEach(({ detector, value, count }) => {
+                findings.push({
+                    ...reference,
+                    location,
+                    detector,
+                    maskedValue: maskValue(value),
+                    count
+                });
+            });
+        });
+    });
+
+    return {
+        generatedAt: now.toISOString(),
+        detectors: detectorIds,
+        messageCount: messages.length,
+        findings
+    };
+}
+
+/**
+ * Serializes a findings report as CSV
+ * @param {Object} report - Report from scanMessagesForPii
+ * @returns {string} CSV content
+ */
+export function piiReportToCsv(report) {
+    const columns = [
+        'fileName',
+        'sourcePath',
+        'subject',
+        'messageHash',
+        'location',
+        'detector',
+        'maskedValue',
+        'count'
+    ];
+    const rows = [
+        columns.join(','),
+        ...report.findings.map((finding) =>
+            columns.map((column) => escapeCsvValue(finding[column])).join(',')
+        )
+    ];
+    return `${rows.join('\r\n')}\r\n`;
+}
@@ -0,0 +1,40 @@
+/**
+ * Managed Policy Module
+ * Holds the policies an administrator set for this machine (Group Policy on Windows,
+ * configuration profiles on macOS, /etc/msgreader/policy.json on Linux). The backend
+ * reads them at startup; a policy takes precedence over the user's own setting.
+ */
+
+export const DEFAULT_POLICY = {
+    disableAutoUpdate: false,
+    blockExternalContent: false,
+    dataDir: null,
+    kioskMode: false
+};
+
+export class ManagedPolicy {
+    constructor() {
+        this.policy = { ...DEFAULT_POLICY };
+    }
+
+    /**
+     * Replaces the policies, e.g. with the result of getPolicy()
+     * @param {Object|null} policy - Policies by name, missing ones use the defaults
+     */
+    apply(policy) {
+        this.policy = { ...DEFAULT_POLICY, ...(policy || {}) };
+    }
+
+    /**
+     * Gets a policy value
+     * @param {string} name - Policy name, e.g. 'blockExternalContent'
+     * @returns {*}
+     */
+    get(name) {
+        return this.policy[name];
+    }
+}
+
+// Export singleton instance
+export const managedPolicy = new ManagedPolicy();
+export default managedPolicy;
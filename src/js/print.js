@@ -0,0 +1,70 @@
+/**
+ * Print Module
+ * Prints messages through the desktop backend, which opens the OS print dialog with page
+ * headers and print-friendly pagination, or sends the text straight to a printer.
+ */
+
+import { printMessage as printDocument } from './tauri-bridge.js';
+import { getMessagePlainText, messageToHtmlDocument } from './messageExport.js';
+import { sanitizeHTML } from './sanitizer.js';
+import { formatContact, getContactEmail } from './addressUtils.js';
+
+/**
+ * Builds the document to print: the message as exported to HTML, with the body as shown
+ * in the viewer
+ * @param {Object} message - Parsed message
+ * @param {string|null} [renderedBody] - Sanitized body HTML of the viewer, with inline
+ *     images resolved; the message body is sanitized here without one
+ * @returns {string} Complete HTML document
+ */
+export function buildPrintDocument(message, renderedBody = null) {
+    const bodyContentHTML =
+        renderedBody ?? (message?.bodyContentHTML ? sanitizeHTML(message.bodyContentHTML) : '');
+    return messageToHtmlDocument({ ...message, bodyContentHTML });
+}
+
+/**
+ * Builds the text printed without a dialog: the main headers and the plain-text body
+ * @param {Object} message - Parsed message
+ * @returns {string}
+ */
+export function buildPrintText(message) {
+    const recipients = (type) =>
+        (message?.recipients || [])
+            .filter((recipient) => recipient.recipType === type)
+            .map((recipient) => formatContact(recipient.name || '', getContactEmail(recipient)))
+            .join(', ');
+    const date = message?.messageDeliveryTime
+        ? new Date(message.messageDeliveryTime).toLocaleString()
+        : '';
+
+    const lines = [
+        ['Subject', message?.subject || ''],
+        ['From', formatContact(message?.senderName || '', message?.senderEmail || '')],
+        ['To', recipients('to')],
+        ['CC', recipients('cc')],
+        ['Date', date]
+    ]
+        .filter(([, value]) => value)
+        .map(([label, value]) => `${label}: ${value}`);
+
+    return `${lines.join('\n')}\n\n${getMessagePlainText(message)}\n`;
+}
+
+/**
+ * Prints a message
+ * @param {Object} message - Parsed message
+ * @param {Object} [options]
+ * @param {string|null} [options.renderedBody] - Body HTML as shown in the viewer
+ * @param {boolean} [options.header=true] - Print the file name and page numbers
+ * @param {string|null} [options.printer] - Print to this printer without a dialog
+ * @returns {Promise<void>}
+ */
+export async function printMessage(message, options = {}) {
+    const { renderedBody = null, header = true, printer = null } = options;
+    await printDocument(buildPrintDocument(message, renderedBody), buildPrintText(message), {
+        header,
+        fileName: message?.fileName || message?.subject || '',
+        printer
+    });
+}
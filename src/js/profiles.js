@@ -0,0 +1,203 @@
+/**
+ * Profiles Module
+ * Named profiles keep settings, pinned messages, logs and caches apart, e.g. on
+ * shared machines or for separate cases. Every profile stores its data under its
+ * own key prefix; the default profile uses the unprefixed keys of earlier versions.
+ * Desktop: select with `--profile <name>`. Web: select with `?profile=<name>`.
+ */
+
+import { PROFILE_KEY_PREFIX, storage as defaultStorage } from './storage.js';
+
+/** List of profile names, stored outside any profile */
+export const PROFILES_STORAGE_KEY = 'msgReader_profiles';
+/** Whether to ask for a profile at startup, stored outside any profile */
+export const PROFILE_CHOOSER_STORAGE_KEY = 'msgReader_profileChooser';
+
+const MAX_PROFILE_NAME_LENGTH = 64;
+
+/**
+ * Checks a profile name. Names end up in directory names on desktop, so only
+ * letters, digits, spaces, '-' and '_' are allowed.
+ * @param {string} name - Profile name
+ * @returns {boolean}
+ */
+export function isValidProfileName(name) {
+    return (
+        typeof name === 'string' &&
+        name.trim() === name &&
+        name.length > 0 &&
+        [...name].length <= MAX_PROFILE_NAME_LENGTH &&
+        /^[\p{L}\p{N} _-]+$/u.test(name)
+    );
+}
+
+/**
+ * Gets the storage key prefix of a profile
+ * @param {string|null} name - Profile name, null for the default profile
+ * @returns {string}
+ */
+export function getProfileStoragePrefix(name) {
+    return name ? `${PROFILE_KEY_PREFIX}${name}:` : '';
+}
+
+/**
+ * Checks whether a storage backend key holds data of a profile, as opposed to the
+ * settings shared by all profiles or keys of other applications on the same origin
+ * @param {string} key - Backend key
+ * @param {string} [namespace] - Key prefix of the profile, see getProfileStoragePrefix
+ * @returns {boolean}
+ */
+export function isProfileDataKey(key, namespace = '') {
+    if (typeof key !== 'string' || !key.startsWith(namespace)) return false;
+
+    const name = key.slice(namespace.length);
+    if (
+        name.startsWith(PROFILE_KEY_PREFIX) ||
+        name === PROFILES_STORAGE_KEY ||
+        name === PROFILE_CHOOSER_STORAGE_KEY
+    ) {
+        return false;
+    }
+    return name.startsWith('msgReader_') || name === 'pinnedMessages';
+}
+
+/**
+ * Reads the profile from a URL query string (web version)
+ * @param {string} search - location.search
+ * @returns {string|null|undefined} Name, null for an explicit default profile
+ *     (`?profile=`), undefined if no profile was given
+ */
+export function getProfileFromUrl(search) {
+    const params = new URLSearchParams(search || '');
+    if (!params.has('profile')) return undefined;
+    return params.get('profile') || null;
+}
+
+/**
+ * Decides which profile to start with
+ * @param {Object} options
+ * @param {string|null|undefined} options.requested - Profile from the command line or URL,
+ *     undefined if none was given
+ * @param {string[]} options.profiles - Known profiles
+ * @param {boolean} options.chooserEnabled - Whether to ask at startup
+ * @returns {{profile: string|null, choose: boolean}} choose is true if the user should pick
+ */
+export function resolveStartupProfile({ requested, profiles, chooserEnabled }) {
+    if (requested === null) return { profile: null, choose: false };
+    if (requested !== undefined && isValidProfileName(requested)) {
+        return { profile: requested, choose: false };
+    }
+    return { profile: null, choose: chooserEnabled && profiles.length > 0 };
+}
+
+export class ProfileManager {
+    /**
+     * @param {Storage} [storageInstance] - Storage to namespace (dependency injection)
+     */
+    constructor(storageInstance = null) {
+        this.storage = storageInstance || defaultStorage;
+        this.activeProfile = null;
+    }
+
+    /**
+     * Runs a storage operation outside any profile
+     * @param {Function} callback - Operation
+     * @returns {*} Result of the callback
+     */
+    withoutNamespace(callback) {
+        const namespace = this.storage.namespace;
+        this.storage.setNamespace('');
+        try {
+            return callback();
+        } finally {
+            this.storage.setNamespace(namespace);
+        }
+    }
+
+    /**
+     * Gets all named profiles
+     * @returns {string[]} Profile names, sorted
+     */
+    getProfiles() {
+        const profiles = this.withoutNamespace(() => this.storage.get(PROFILES_STORAGE_KEY, []));
+        return Array.isArray(profiles) ? profiles.filter(isValidProfileName).sort() : [];
+    }
+
+    /**
+     * Adds a profile
+     * @param {string} name - Profile name
+     * @returns {boolean} False if the name is invalid or could not be saved
+     */
+    addProfile(name) {
+        if (!isValidProfileName(name)) return false;
+
+        const profiles = this.getProfiles();
+        if (profiles.includes(name)) return true;
+        return this.withoutNamespace(() =>
+            this.storage.set(PROFILES_STORAGE_KEY, [...profiles, name].sort())
+        );
+    }
+
+    /**
+     * Deletes a profile and all data stored in it
+     * @param {string} name - Profile name, the active profile cannot be deleted
+     * @returns {boolean} True if the profile was deleted
+     */
+    deleteProfile(name) {
+        if (!name || name === this.activeProfile) return false;
+
+        const backend = this.storage.backend;
+        this.storage.keysWithPrefix(getProfileStoragePrefix(name)).forEach((key) => {
+            backend.removeItem(key);
+        });
+        const profiles = this.getProfiles().filter((profile) => profile !== name);
+        return this.withoutNamespace(() => this.storage.set(PROFILES_STORAGE_KEY, profiles));
+    }
+
+    /**
+     * Checks whether the profile chooser is shown at startup
+     * @returns {boolean}
+     */
+    isChooserEnabled() {
+        return this.withoutNamespace(
+            () => this.storage.get(PROFILE_CHOOSER_STORAGE_KEY, false) === true
+        );
+    }
+
+    /**
+     * Turns the startup chooser on or off
+     * @param {boolean} enabled - Whether to ask at startup
+     * @returns {boolean} True if the preference was saved
+     */
+    setChooserEnabled(enabled) {
+        return this.withoutNamespace(() =>
+            this.storage.set(PROFILE_CHOOSER_STORAGE_KEY, Boolean(enabled))
+        );
+    }
+
+    /**
+     * Makes a profile active for all storage access
+     * @param {string|null} name - Profile name, null for the default profile
+     * @returns {boolean} False if the name is invalid
+     */
+    activate(name) {
+        if (name && !isValidProfileName(name)) return false;
+        if (name) this.addProfile(name);
+
+        this.activeProfile = name || null;
+        this.storage.setNamespace(getProfileStoragePrefix(this.activeProfile));
+        return true;
+    }
+
+    /**
+     * Gets the active profile
+     * @returns {string|null} Name, null for the default profile
+     */
+    getActiveProfile() {
+        return this.activeProfile;
+    }
+}
+
+// Export singleton instance
+export const profileManager = new ProfileManager();
+export default ProfileManager;
@@ -0,0 +1,42 @@
+/**
+ * Reply Module
+ * Hands replies and forwards to the user's mail client: the backend builds the reply
+ * (recipients, subject prefix, quoted body) from the original file and opens it either as
+ * an unsent .eml draft or as a mailto: URL.
+ */
+
+import { replyViaDefaultClient } from './tauri-bridge.js';
+import { arrayBufferToBase64 } from './encoding.js';
+
+export const REPLY_MODES = ['reply', 'replyAll', 'forward'];
+
+export const REPLY_HANDOFFS = ['draft', 'mailto'];
+
+/**
+ * Whether a reply can be built for a message, which needs its original file
+ * @param {Object} message - Parsed message
+ * @returns {boolean}
+ */
+export function canReply(message) {
+    return Boolean(message?._rawBuffer && ['eml', 'msg'].includes(message._fileType));
+}
+
+/**
+ * Opens a reply, reply all or forward of a message in the default mail client
+ * @param {Object} message - Parsed message with its original file (_rawBuffer)
+ * @param {string} mode - 'reply', 'replyAll' or 'forward'
+ * @param {string} [handoff='draft'] - 'draft' (.eml with HTML and attachments) or 'mailto'
+ * @returns {Promise<void>}
+ */
+export async function replyToMessage(message, mode, handoff = 'draft') {
+    if (!REPLY_MODES.includes(mode)) {
+        throw new Error(`Unknown reply mode: ${mode}`);
+    }
+    if (!REPLY_HANDOFFS.includes(handoff)) {
+        throw new Error(`Unknown reply handoff: ${handoff}`);
+    }
+    if (!canReply(message)) {
+        throw new Error('The original file of this message is not available');
+    }
+    await replyViaDefaultClient(arrayBufferToBase64(message._rawBuffer), mode, handoff);
+}
@@ -99,6 +99,112 @@ export function sanitizeHTML(html) {
     return cleanHTML;
 }
 
+/** Absolute or protocol-relative http(s) URL */
+const REMOTE_URL = /^\s*(https?:)?\/\//i;
+/** CSS url() pointing to a remote resource */
+const CSS_REMOTE_URL = /url\(\s*['"]?\s*(https?:)?\/\/[^)]*\)/gi;
+/** CSS @import of a remote stylesheet given as a string */
+const CSS_REMOTE_IMPORT = /@import\s+(['"])\s*(https?:)?\/\/[^'"]*\1[^;]*;?/gi;
+
+/**
+ * Removes references to remote content (images, CSS backgrounds and imports) from
+ * sanitized HTML, so that opening a message does not contact the sender's servers
+ * @param {string} html - HTML returned by sanitizeHTML
+ * @returns {string} HTML without remote references
+ */
+export function blockExternalContent(html) {
+    if (!html || typeof document === 'undefined') return html || '';
+
+    const template = document.createElement('template');
+    template.innerHTML = html;
+    const content = template.content;
+
+    content.querySelectorAll('img[src]').forEach((img) => {
+        if (REMOTE_URL.test(img.getAttribute('src'))) {
+            img.removeAttribute('src');
+        }
+    });
+    content.querySelectorAll('[style]').forEach((element) => {
+        const style = element.getAttribute('style');
+        element.setAttribute('style', style.replace(CSS_REMOTE_URL, 'none'));
+    });
+    content.querySelectorAll('style').forEach((style) => {
+        style.textContent = style.textContent
+            .replace(CSS_REMOTE_IMPORT, '')
+            .replace(CSS_REMOTE_URL, 'none');
+    });
+
+    return template.innerHTML;
+}
+
+/**
+ * Whether sanitized HTML references remote content that blockExternalContent removes
+ * @param {string} html - HTML returned by sanitizeHTML
+ * @returns {boolean}
+ */
+export function containsExternalContent(html) {
+    if (!html || typeof document === 'undefined') return false;
+
+    const template = document.createElement('template');
+    template.innerHTML = html;
+    const content = template.content;
+    // Fresh expressions: test() on the global ones would keep their lastIndex
+    const matchesCss = (text) =>
+        new RegExp(CSS_REMOTE_URL.source, 'i').test(text) ||
+        new RegExp(CSS_REMOTE_IMPORT.source, 'i').test(text);
+
+    return (
+        [...content.querySelectorAll('img[src]')].some((img) =>
+            REMOTE_URL.test(img.getAttribute('src'))
+        ) ||
+        [...content.querySelectorAll('[style]')].some((element) =>
+            matchesCss(element.getAttribute('style'))
+        ) ||
+        [...content.querySelectorAll('style')].some((style) => matchesCss(style.textContent))
+    );
+}
+
+/**
+ * Points remote images and CSS backgrounds of sanitized HTML at the local image proxy
+ * of the desktop app, which fetches them without cookies or referrer and caches them.
+ * Remote stylesheet imports are removed.
+ * @param {string} html - HTML returned by sanitizeHTML
+ * @param {string} proxyBase - Base URL of the proxy; the remote URL is appended encoded
+ * @returns {string} HTML loading remote images through the proxy
+ */
+export function proxyExternalContent(html, proxyBase) {
+    if (!html || typeof document === 'undefined') return html || '';
+
+    const toProxy = (url) => {
+        const absolute = url.trim().startsWith('//') ? `https:${url.trim()}` : url.trim();
+        return proxyBase + encodeURIComponent(absolute);
+    };
+    const proxyCss = (css) =>
+        css.replace(CSS_REMOTE_URL, (match) => {
+            const url = match.replace(/^url\(\s*['"]?\s*/i, '').replace(/\s*['"]?\s*\)$/, '');
+            return `url("${toProxy(url)}")`;
+        });
+
+    const template = document.createElement('template');
+    template.innerHTML = html;
+    const content = template.content;
+
+    content.querySelectorAll('img[src]').forEach((img) => {
+        const src = img.getAttribute('src');
+        if (REMOTE_URL.test(src)) {
+            img.setAttribute('src', toProxy(src));
+        }
+    });
+    content.querySelectorAll('[style]').forEach((element) => {
+        element.setAttribute('style', proxyCss(element.getAttribute('style')));
+    });
+    content.querySelectorAll('style').forEach((style) => {
+        style.textContent = proxyCss(style.textContent.replace(CSS_REMOTE_IMPORT, ''));
+    });
+
+    return template.innerHTML;
+}
+
 /**
  * Escapes HTML special characters
  * @param {string} text - Plain text to escape
@@ -106,6 +212,10 @@ export function sanitizeHTML(html) {
  */
 export function escapeHTML(text) {
     if (!text) return '';
+    if (typeof document === 'undefined') {
+        // Headless use (CLI): same escaping as innerHTML serialization
+        return String(text).replace(/&/g, '&amp;').replace(/</g, '&lt;').replace(/>/g, '&gt;');
+    }
     const div = document.createElement('div');
     div.textContent = text;
     return div.innerHTML;
@@ -0,0 +1,77 @@
+/**
+ * Sender Authentication Module
+ * Checks DKIM, SPF and DMARC of the open message in the desktop backend, which verifies
+ * DKIM signatures against the original file and looks up keys and policies in DNS, and
+ * sums the report up into one verdict.
+ */
+
+import { analyzeAuthentication } from './tauri-bridge.js';
+import { arrayBufferToBase64 } from './encoding.js';
+
+export const AUTHENTICATION_STATUS = {
+    PASS: 'pass',
+    WARNING: 'warning',
+    FAIL: 'fail',
+    UNKNOWN: 'unknown'
+};
+
+/**
+ * Whether the original file of a message is available to check
+ * @param {Object} message - Parsed message
+ * @returns {boolean}
+ */
+export function canCheckAuthentication(message) {
+    return Boolean(message?._rawBuffer && message._fileType);
+}
+
+/**
+ * Checks the sender authentication of a message
+ * @param {Object} message - Parsed message with its original file (_rawBuffer)
+ * @returns {Promise<Object>} Report from the backend (see analyzeAuthentication)
+ */
+export async function checkAuthentication(message) {
+    if (!canCheckAuthentication(message)) {
+        throw new Error('The original file of this message is not available');
+    }
+    return await analyzeAuthentication(arrayBufferToBase64(message._rawBuffer));
+}
+
+/**
+ * Sums up a report: DMARC decides when the From domain publishes a policy, otherwise an
+ * aligned DKIM signature counts as a pass and any failed check as a warning. Offline the
+ * backend looks up nothing in DNS, so signatures and DMARC stay unchecked.
+ * @param {Object} report - Result of checkAuthentication
+ * @returns {{status: string, text: string}} One of AUTHENTICATION_STATUS and a sentence
+ */
+export function getAuthenticationVerdict(report) {
+    const domain = report?.fromDomain;
+    if (!domain) {
+        return { status: AUTHENTICATION_STATUS.UNKNOWN, text: 'The message has no sender domain' };
+    }
+
+    const { dmarc, dkim = [], spf } = report;
+    if (dmarc?.result === 'pass') {
+        return { status: AUTHENTICATION_STATUS.PASS, text: `Sent by ${domain}` };
+    }
+    if (dmarc?.result === 'fail') {
+        const enforced = dmarc.policy === 'reject' || dmarc.policy === 'quarantine';
+        return {
+            status: enforced ? AUTHENTICATION_STATUS.FAIL : AUTHENTICATION_STATUS.WARNING,
+            text: `Not verified as sent by ${domain}`
+        };
+    }
+
+    if (dkim.some((signature) => signature.result === 'pass' && signature.aligned)) {
+        return { status: AUTHENTICATION_STATUS.PASS, text: `Signed by ${domain}` };
+    }
+    const failed =
+        dkim.some((signature) => signature.result === 'fail') ||
+        ['fail', 'softfail'].includes(spf?.result);
+    if (failed) {
+        return { status: AUTHENTICATION_STATUS.WARNING, text: `Not verified as sent by ${domain}` };
+    }
+    if (report.dnsChecked === false) {
+        return { status: AUTHENTICATION_STATUS.UNKNOWN, text: 'Not checked (offline)' };
+    }
+    return { status: AUTHENTICATION_STATUS.UNKNOWN, text: `${domain} cannot be verified` };
+}
@@ -0,0 +1,134 @@
+/**
+ * Session Module
+ * Keeps the backend informed of the messages open in the main window, which it saves when
+ * the desktop app exits, and opens them again on the next start ("Restore last session"
+ * at startup). Messages are recorded by where they were opened from: a file, a message of
+ * a PST, mbox or ZIP file, or an archived message. Messages from IMAP servers are not
+ * recorded; reopening them would need the network and a login.
+ */
+
+import { restoreSession, updateSession } from './tauri-bridge.js';
+
+/** Source paths of messages from data files and the archive: `<path>#<id>` */
+const CONTAINED_SOURCE = /^(.*)#(\d+)$/;
+
+const PST_EXTENSIONS = ['pst', 'ost'];
+
+/** Source paths of messages downloaded from an IMAP server */
+const IMAP_SOURCE = /^imap:\/\//;
+
+/** Changes in a row are reported once */
+const UPDATE_DELAY_MS = 500;
+
+/**
+ * Where a message was opened from, as the backend saves it
+ * @param {Object} message - Message from the MessageHandler
+ * @returns {{kind: string, path: string, id: number|null, fileName: string}|null} Null for
+ *     messages without a source on disk, e.g. files opened through the file picker or
+ *     downloaded from an IMAP server
+ */
+export function describeMessage(message) {
+    const source = message?._sourcePath;
+    if (!source || IMAP_SOURCE.test(source)) return null;
+
+    const fileName = message.fileName;
+    const [, container, id] = source.match(CONTAINED_SOURCE) || [];
+    if (container === 'archive') {
+        return { kind: 'archive', path: '', id: Number(id), fileName };
+    }
+    if (container) {
+        const extension = container.toLowerCase().split('.').pop();
+        let kind = 'mbox';
+        if (PST_EXTENSIONS.includes(extension)) kind = 'pst';
+        if (extension === 'zip') kind = 'zip';
+        return { kind, path: container, id: Number(id), fileName };
+    }
+    return { kind: 'file', path: source, id: null, fileName };
+}
+
+/**
+ * The open messages and the index of the one shown
+ * @param {import('./MessageHandler.js').default} messageHandler
+ * @returns {{messages: Array<Object>, selected: number|null}}
+ */
+export function captureSession(messageHandler) {
+    const current = messageHandler.getCurrentMessage();
+    const messages = [];
+    let selected = null;
+    for (const message of messageHandler.getMessages()) {
+        const entry = describeMessage(message);
+        if (!entry) continue;
+        if (message === current) selected = messages.length;
+        messages.push(entry);
+    }
+    return { messages, selected };
+}
+
+/**
+ * Reports the session now and after every change of the open messages
+ * @param {import('./MessageHandler.js').default} messageHandler
+ * @returns {Function} Stops tracking
+ */
+export function trackSession(messageHandler) {
+    let reported = null;
+    let timer = null;
+
+    const report = () => {
+        timer = null;
+        const session = captureSession(messageHandler);
+        const serialized = JSON.stringify(session);
+        if (serialized === reported) return;
+        reported = serialized;
+        updateSession(session).catch((error) => {
+            console.warn('Could not record the session:', error);
+        });
+    };
+
+    report();
+    const unsubscribe = messageHandler.onChange(() => {
+        if (!timer) timer = setTimeout(report, UPDATE_DELAY_MS);
+    });
+    return () => {
+        clearTimeout(timer);
+        unsubscribe();
+    };
+}
+
+function isSameMessage(a, b) {
+    return a?.kind === b.kind && a.path === b.path && (a.id ?? null) === (b.id ?? null);
+}
+
+/**
+ * Opens the messages of the last session and shows the message shown then
+ * @param {Object} app - The App, for its ways to open files and messages
+ * @returns {Promise<Array<Object>>} Messages whose file has moved or been deleted
+ */
+export async function reopenSession(app) {
+    const session = await restoreSession();
+
+    for (const entry of session.messages) {
+        // Names of messages from data files were made from their subject
+        const subject = entry.fileName.replace(/\.(msg|eml)$/i, '');
+        if (entry.kind === 'archive') {
+            await app.openArchivedMessage({ id: entry.id, fileName: entry.fileName });
+        } else if (entry.kind === 'pst') {
+            await app.openPstMessage(entry.path, { id: entry.id, subject });
+        } else if (entry.kind === 'mbox') {
+            await app.openMboxMessage(entry.path, { index: entry.id, subject });
+        } else if (entry.kind === 'zip') {
+            await app.openZipMessage(entry.path, { index: entry.id, name: entry.fileName });
+        } else {
+            await app.fileHandler.handleFileFromPath(entry.path);
+        }
+    }
+
+    const selected = session.messages[session.selected];
+    const message =
+        selected &&
+        app.messageHandler
+            .getMessages()
+            .find((candidate) => isSameMessage(describeMessage(candidate), selected));
+    if (message) app.uiManager.showMessage(message);
+
+    return session.missing;
+}
@@ -0,0 +1,213 @@
+/**
+ * Settings Bundle Module
+ * Exports the shareable configuration (attachment, export, scan and retention
+ * policies) as a small JSON file that teammates can import. Unlike a backup
+ * (appDataBackup.js) a bundle carries no personal data and no appearance settings.
+ * Importing compares the bundle with the current settings first, so the user can
+ * review every setting that would change before applying it.
+ */
+
+import {
+    EXPORT_CHECKSUM_MODE,
+    EXTERNAL_CONTENT,
+    PDF_ATTACHMENT_OPEN_MODE,
+    TEMP_FILE_RETENTION,
+    getExportChecksumMode,
+    getExternalContent,
+    getPdfAttachmentOpenMode,
+    getRedactionOptions,
+    getTempFileRetention,
+    isRedactionOptions,
+    setExportChecksumMode,
+    setExternalContent,
+    setPdfAttachmentOpenMode,
+    setRedactionOptions,
+    setTempFileRetention
+} from './UserPreferences.js';
+import {
+    INLINE_IMAGE_ATTACHMENT_VISIBILITY,
+    getInlineImageAttachmentVisibility,
+    setInlineImageAttachmentVisibility
+} from './InlineImagePreference.js';
+import { PII_DETECTORS, getEnabledPiiDetectors, setEnabledPiiDetectors } from './piiScanner.js';
+import { auditLog } from './AuditLog.js';
+
+export const SETTINGS_BUNDLE_FORMAT = 'msgReader-settings';
+export const SETTINGS_BUNDLE_VERSION = 1;
+
+/**
+ * Creates a validator for a set of allowed values
+ * @param {Object} values - Enum object
+ * @returns {function(*): boolean}
+ */
+const oneOf = (values) => (value) => Object.values(values).includes(value);
+
+/**
+ * Settings included in a bundle, by the id used in the bundle file.
+ * The automation API is left out on purpose: an imported file must not open a
+ * local endpoint for scripts.
+ */
+export const BUNDLE_SETTINGS = {
+    externalContent: {
+        label: 'External images',
+        get: getExternalContent,
+        set: setExternalContent,
+        validate: oneOf(EXTERNAL_CONTENT)
+    },
+    pdfAttachmentOpenMode: {
+        label: 'PDF attachments',
+        get: getPdfAttachmentOpenMode,
+        set: setPdfAttachmentOpenMode,
+        validate: oneOf(PDF_ATTACHMENT_OPEN_MODE)
+    },
+    inlineImageAttachments: {
+        label: 'Inline image attachments',
+        get: getInlineImageAttachmentVisibility,
+        set: setInlineImageAttachmentVisibility,
+        validate: oneOf(INLINE_IMAGE_ATTACHMENT_VISIBILITY)
+    },
+    exportChecksumMode: {
+        label: 'Export checksums',
+        get: getExportChecksumMode,
+        set: setExportChecksumMode,
+        validate: oneOf(EXPORT_CHECKSUM_MODE)
+    },
+    redaction: {
+        label: 'Redacted copies',
+        get: getRedactionOptions,
+        set: setRedactionOptions,
+        validate: isRedactionOptions
+    },
+    piiDetectors: {
+        label: 'Personal data scan',
+        get: () => [...getEnabledPiiDetectors()].sort(),
+        set: setEnabledPiiDetectors,
+        validate: (value) => Array.isArray(value) && value.every((id) => PII_DETECTORS[id])
+    },
+    tempFileRetention: {
+        label: 'Temporary files',
+        get: getTempFileRetention,
+        set: setTempFileRetention,
+        validate: oneOf(TEMP_FILE_RETENTION)
+    },
+    auditLog: {
+        label: 'Audit log',
+        get: () => auditLog.isEnabled(),
+        set: (enabled) => auditLog.setEnabled(enabled),
+        validate: (value) => typeof value === 'boolean'
+    }
+};
+
+/**
+ * Normalizes a value for comparison
+ * @param {*} value
+ * @returns {string}
+ */
+function comparable(value) {
+    return JSON.stringify(Array.isArray(value) ? [...value].sort() : value);
+}
+
+/**
+ * Formats a setting value for the import report
+ * @param {*} value
+ * @returns {string}
+ */
+export function formatSettingValue(value) {
+    if (typeof value === 'boolean') return value ? 'On' : 'Off';
+    if (Array.isArray(value)) return value.length ? value.join(', ') : 'None';
+    if (value && typeof value === 'object') {
+        return Object.entries(value)
+            .map(([key, item]) => `${key}: ${formatSettingValue(item)}`)
+            .join(', ');
+    }
+    return String(value);
+}
+
+/**
+ * Creates a bundle of the current settings
+ * @param {Object} [options]
+ * @param {string} [options.appVersion] - App version, recorded in the bundle
+ * @param {Date} [options.now] - Timestamp override (testing)
+ * @returns {{format: string, version: number, createdAt: string, appVersion: string,
+ *     settings: Object<string, *>}}
+ */
+export function createSettingsBundle({ appVersion = '', now = new Date() } = {}) {
+    const settings = {};
+    Object.entries(BUNDLE_SETTINGS).forEach(([id, setting]) => {
+        settings[id] = setting.get();
+    });
+    return {
+        format: SETTINGS_BUNDLE_FORMAT,
+        version: SETTINGS_BUNDLE_VERSION,
+        createdAt: now.toISOString(),
+        appVersion,
+        settings
+    };
+}
+
+/**
+ * Parses a bundle file
+ * @param {string} text - File content
+ * @returns {Object} Bundle
+ * @throws {Error} If the file is not a supported bundle
+ */
+export function parseSettingsBundle(text) {
+    let bundle;
+    try {
+        bundle = JSON.parse(text);
+    } catch {
+        throw new Error('File is not valid JSON');
+    }
+
+    if (bundle?.format !== SETTINGS_BUNDLE_FORMAT) {
+        throw new Error('File is not a msgReader settings bundle');
+    }
+    if (bundle.version > SETTINGS_BUNDLE_VERSION) {
+        throw new Error('Settings bundle was created by a newer version of msgReader');
+    }
+    if (!bundle.settings || typeof bundle.settings !== 'object' || Array.isArray(bundle.settings)) {
+        throw new Error('Settings bundle contains no settings');
+    }
+    return bundle;
+}
+
+/**
+ * Compares a bundle with the current settings
+ * @param {Object} bundle - Result of parseSettingsBundle
+ * @returns {{changes: Array<{id: string, label: string, current: *, incoming: *}>,
+ *     unchanged: string[], invalid: string[], unknown: string[]}}
+ *     changes are settings the bundle would overwrite; invalid ones have values this
+ *     version does not accept; unknown ones are not supported by this version
+ */
+export function planSettingsImport(bundle) {
+    const plan = { changes: [], unchanged: [], invalid: [], unknown: [] };
+
+    Object.entries(bundle.settings).forEach(([id, incoming]) => {
+        const setting = Object.prototype.hasOwnProperty.call(BUNDLE_SETTINGS, id)
+            ? BUNDLE_SETTINGS[id]
+            : null;
+        if (!setting) {
+            plan.unknown.push(id);
+        } else if (!setting.validate(incoming)) {
+            plan.invalid.push(id);
+        } else {
+            const current = setting.get();
+            if (comparable(current) === comparable(incoming)) {
+                plan.unchanged.push(id);
+            } else {
+                plan.changes.push({ id, label: setting.label, current, incoming });
+            }
+        }
+    });
+
+    return plan;
+}
+
+/**
+ * Applies the changes of an import plan
+ * @param {Object} plan - Result of planSettingsImport
+ * @returns {number} Number of settings changed
+ */
+export function applySettingsImport(plan) {
+    return plan.changes.filter(({ id, incoming }) => BUNDLE_SETTINGS[id].set(incoming)).length;
+}
@@ -0,0 +1,90 @@
+/**
+ * Settings Sync Module
+ * Keeps the settings the desktop backend needs as well (theme, external images, save
+ * folder, startup, tray icon) in step with the backend's settings file. The file wins at startup;
+ * values that only exist in the WebView storage are copied over once. Changes made in
+ * other windows arrive as settings-changed events.
+ */
+
+import { getSettings, setSetting } from './tauri-bridge.js';
+import { storage as defaultStorage } from './storage.js';
+
+/**
+ * Compares setting values; lists (e.g. remoteImageSenders) by content
+ * @param {*} a
+ * @param {*} b
+ * @returns {boolean}
+ */
+function sameValue(a, b) {
+    return a === b || JSON.stringify(a) === JSON.stringify(b);
+}
+
+export class SettingsSync {
+    /**
+     * @param {Object<string, {storageKey: string, apply: function(*): void}>} settings -
+     *     Backend setting name to the storage key holding it and a function that stores
+     *     and applies a value (null for the default)
+     * @param {Object} [options]
+     * @param {{getSettings: Function, setSetting: Function}} [options.bridge]
+     * @param {import('./storage.js').Storage} [options.storage]
+     */
+    constructor(settings, { bridge = { getSettings, setSetting }, storage = defaultStorage } = {}) {
+        this.settings = settings;
+        this.bridge = bridge;
+        this.storage = storage;
+    }
+
+    /**
+     * Applies the backend values and copies local-only values to the backend
+     * @returns {Promise<string[]>} Names of the settings that changed locally
+     */
+    async load() {
+        let stored = {};
+        try {
+            stored = await this.bridge.getSettings();
+        } catch (error) {
+            console.error('Failed to load settings:', error);
+            return [];
+        }
+
+        const changed = [];
+        for (const [key, { storageKey, apply }] of Object.entries(this.settings)) {
+            const value = stored[key];
+            if (value !== undefined && value !== null) {
+                if (!sameValue(this.storage.get(storageKey), value)) {
+                    apply(value);
+                    changed.push(key);
+                }
+            } else if (this.storage.has(storageKey)) {
+                this.publish(key, this.storage.get(storageKey));
+            }
+        }
+        return changed;
+    }
+
+    /**
+     * Stores a changed setting in the backend
+     * @param {string} key - Backend setting name
+     * @param {*} value
+     */
+    publish(key, value) {
+        if (!this.settings[key]) return;
+
+        this.bridge.setSetting(key, value).catch((error) => {
+            console.error(`Failed to save setting ${key}:`, error);
+        });
+    }
+
+    /**
+     * Applies a settings-changed event
+     * @param {{key: string, value: *}} change
+     * @returns {boolean} True if the local value changed
+     */
+    handleChange({ key, value }) {
+        const setting = this.settings[key];
+        if (!setting || sameValue(this.storage.get(setting.storageKey), value)) return false;
+
+        setting.apply(value);
+        return true;
+    }
+}
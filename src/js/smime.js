@@ -0,0 +1,46 @@
+/**
+ * S/MIME Module
+ * Finds the encrypted part (smime.p7m) of an S/MIME message and shows the decrypted
+ * content in its place. The decryption itself happens in the backend, with a PKCS#12
+ * certificate file or the OS certificate store.
+ */
+
+import { extractEml } from './utils.js';
+
+/** MIME types of S/MIME enveloped (or opaque signed) content */
+export const PKCS7_MIME_TYPES = ['application/pkcs7-mime', 'application/x-pkcs7-mime'];
+
+/**
+ * Gets the encrypted part of an S/MIME message. Such a message carries nothing but the
+ * smime.p7m, both as .eml (the whole body) and as .msg (IPM.Note.SMIME).
+ * @param {Object} message - Parsed message
+ * @returns {Object|null} The smime.p7m attachment, null if the message is not encrypted
+ *     or already decrypted
+ */
+export function getEncryptedSmimePart(message) {
+    if (!message || message._smimeDecrypted) return null;
+
+    const attachments = message.attachments || [];
+    if (attachments.length !== 1) return null;
+
+    const [part] = attachments;
+    const mimeType = (part.attachMimeTag || '').toLowerCase().split(';')[0].trim();
+    const isPkcs7 = PKCS7_MIME_TYPES.includes(mimeType) || /\.p7m$/i.test(part.fileName || '');
+    return isPkcs7 ? part : null;
+}
+
+/**
+ * Replaces the body and attachments of an encrypted message with the decrypted content.
+ * Sender, recipients and subject stay those of the outer message.
+ * @param {Object} message - Message to update
+ * @param {ArrayBuffer} decrypted - Decrypted MIME entity as returned by the backend
+ * @returns {Object} The updated message
+ */
+export function applyDecryptedContent(message, decrypted) {
+    const content = extractEml(decrypted);
+    message.bodyContent = content.bodyContent;
+    message.bodyContentHTML = content.bodyContentHTML;
+    message.attachments = content.attachments;
+    message._smimeDecrypted = true;
+    return message;
+}
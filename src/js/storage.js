@@ -2,8 +2,12 @@
  * Storage Module
  * Provides an abstraction layer for localStorage with error handling
  * Makes the application more testable and allows swapping storage backends
+ * A namespace prefix keeps the data of user profiles apart (see profiles.js)
  */
 
+/** Start of the namespace of every named profile, see getProfileStoragePrefix */
+export const PROFILE_KEY_PREFIX = 'msgReader_profile:';
+
 export class Storage {
     /**
      * Creates a new Storage instance
@@ -11,6 +15,47 @@ export class Storage {
      */
     constructor(backend = null) {
         this.backend = backend || (typeof localStorage !== 'undefined' ? localStorage : null);
+        this.namespace = '';
+    }
+
+    /**
+     * Sets the prefix added to every key, '' for the default profile
+     * @param {string} namespace - Key prefix
+     */
+    setNamespace(namespace) {
+        this.namespace = namespace || '';
+    }
+
+    /**
+     * Gets the backend key for a key in the current namespace
+     * @param {string} key - Key as used by the application
+     * @returns {string}
+     */
+    resolveKey(key) {
+        return `${this.namespace}${key}`;
+    }
+
+    /**
+     * Lists backend keys starting with a prefix
+     * @param {string} prefix - Key prefix
+     * @returns {string[]}
+     */
+    keysWithPrefix(prefix) {
+        if (!this.backend) {
+            return [];
+        }
+
+        try {
+            const keys = [];
+            for (let i = 0; i < this.backend.length; i++) {
+                const key = this.backend.key(i);
+                if (key !== null && key.startsWith(prefix)) keys.push(key);
+            }
+            return keys;
+        } catch (error) {
+            console.error('Storage: Error listing keys:', error);
+            return [];
+        }
     }
 
     /**
@@ -25,7 +70,7 @@ export class Storage {
         }
 
         try {
-            const item = this.backend.getItem(key);
+            const item = this.backend.getItem(this.resolveKey(key));
             if (item === null) {
                 return defaultValue;
             }
@@ -48,7 +93,7 @@ export class Storage {
         }
 
         try {
-            this.backend.setItem(key, JSON.stringify(value));
+            this.backend.setItem(this.resolveKey(key), JSON.stringify(value));
             return true;
         } catch (error) {
             console.error(`Storage: Error writing '${key}':`, error);
@@ -67,7 +112,7 @@ export class Storage {
         }
 
         try {
-            this.backend.removeItem(key);
+            this.backend.removeItem(this.resolveKey(key));
             return true;
         } catch (error) {
             console.error(`Storage: Error removing '${key}':`, error);
@@ -86,14 +131,15 @@ export class Storage {
         }
 
         try {
-            return this.backend.getItem(key) !== null;
+            return this.backend.getItem(this.resolveKey(key)) !== null;
         } catch {
             return false;
         }
     }
 
     /**
-     * Clears all storage data
+     * Clears the data of the current namespace. The default namespace keeps the
+     * keys of named profiles.
      * @returns {boolean} True if successful, false on error
      */
     clear() {
@@ -102,7 +148,10 @@ export class Storage {
         }
 
         try {
-            this.backend.clear();
+            const keys = this.keysWithPrefix(this.namespace);
+            keys
+                .filter((key) => this.namespace || !key.startsWith(PROFILE_KEY_PREFIX))
+                .forEach((key) => this.backend.removeItem(key));
             return true;
         } catch (error) {
             console.error('Storage: Error clearing storage:', error);
@@ -111,5 +160,95 @@ export class Storage {
     }
 }
 
+/**
+ * Storage backend that never writes to the wrapped backend. Changes are kept in
+ * memory and are lost when the app closes (read-only mode, `--read-only`).
+ */
+export class ReadOnlyBackend {
+    /**
+     * @param {Object} backend - Backend to read from, e.g. localStorage
+     */
+    constructor(backend) {
+        this.backend = backend;
+        // Changed values by key, null for removed keys
+        this.changes = new Map();
+    }
+
+    /**
+     * Lists the keys visible through this backend
+     * @returns {string[]}
+     */
+    keys() {
+        const keys = [];
+        for (let i = 0; i < this.backend.length; i++) {
+            const key = this.backend.key(i);
+            if (key !== null && !this.changes.has(key)) keys.push(key);
+        }
+        this.changes.forEach((value, key) => {
+            if (value !== null) keys.push(key);
+        });
+        return keys;
+    }
+
+    getItem(key) {
+        if (this.changes.has(key)) return this.changes.get(key);
+        return this.backend.getItem(key);
+    }
+
+    setItem(key, value) {
+        this.changes.set(key, String(value));
+    }
+
+    removeItem(key) {
+        this.changes.set(key, null);
+    }
+
+    clear() {
+        this.keys().forEach((key) => this.changes.set(key, null));
+    }
+
+    key(index) {
+        return this.keys()[index] ?? null;
+    }
+
+    get length() {
+        return this.keys().length;
+    }
+}
+
+/**
+ * Storage backend that keeps everything in memory and starts empty, for code that
+ * must not read or write the app's data (see library.js)
+ */
+export class MemoryBackend {
+    constructor() {
+        this.values = new Map();
+    }
+
+    getItem(key) {
+        return this.values.has(key) ? this.values.get(key) : null;
+    }
+
+    setItem(key, value) {
+        this.values.set(key, String(value));
+    }
+
+    removeItem(key) {
+        this.values.delete(key);
+    }
+
+    clear() {
+        this.values.clear();
+    }
+
+    key(index) {
+        return [...this.values.keys()][index] ?? null;
+    }
+
+    get length() {
+        return this.values.size;
+    }
+}
+
 // Export singleton instance for convenience
 export const storage = new Storage();
@@ -35,7 +35,8 @@ async function getTauriApis() {
 }
 
 /**
- * Read a file from the filesystem using Tauri
+ * Read a file from the filesystem using Tauri. The backend only reads files the user
+ * opened in this session (chosen, dropped, passed on the command line or recent).
  * @param {string} filePath - Absolute path to the file
  * @returns {Promise<ArrayBuffer>} File contents as ArrayBuffer
  */
@@ -45,97 +46,2201 @@ export async function readFileFromPath(filePath) {
         throw new Error('Tauri API not available');
     }
 
-    // Call Rust command to read file bytes
+    // Call Rust command to read file bytes (sent as a raw ArrayBuffer response)
     const bytes = await apis.invoke('read_file_as_bytes', { path: filePath });
-    // Convert array to ArrayBuffer
-    return new Uint8Array(bytes).buffer;
+    return bytes instanceof ArrayBuffer ? bytes : new Uint8Array(bytes).buffer;
+}
+
+/**
+ * Parse an Outlook .msg file with the backend parser (Tauri only)
+ * @param {string} filePath - Absolute path to the file
+ * @returns {Promise<Object>} Message with the fields of the JSON export
+ *     (subject, senderName, senderEmail, recipients, date, bodyText, bodyHtml,
+ *     attachments with contentBase64)
+ */
+export async function parseMsgFile(filePath) {
+    const apis = await getTauriApis();
+    if (!apis) {
+        throw new Error('Tauri API not available');
+    }
+
+    return apis.invoke('parse_msg_file', { path: filePath });
+}
+
+/**
+ * Read the OLE objects (Excel ranges, equations, packaged files) of an .msg file, unwrapped
+ * by the backend into the files they hold (Tauri only)
+ * @param {string} filePath - Absolute path to the file
+ * @returns {Promise<{attachmentCount: number, objects: Array<[number, Object]>}|null>} The
+ *     number of attachments of the message and each OLE object with its index among them,
+ *     as an attachment in the shape of parseMsgFile; null outside the desktop app
+ */
+export async function readOleObjects(filePath) {
+    const apis = await getTauriApis();
+    if (!apis) return null;
+
+    return apis.invoke('read_ole_objects', { path: filePath });
+}
+
+/**
+ * Parse an .eml file with the backend parser (Tauri only)
+ * @param {string} filePath - Absolute path to the file
+ * @returns {Promise<Object>} Message in the same shape as parseMsgFile
+ */
+export async function parseEmlFile(filePath) {
+    const apis = await getTauriApis();
+    if (!apis) {
+        throw new Error('Tauri API not available');
+    }
+
+    return apis.invoke('parse_eml_file', { path: filePath });
+}
+
+/**
+ * Detect the type of a file by its content rather than its extension (Tauri only)
+ * @param {string} filePath - Absolute path to the file
+ * @returns {Promise<{type: string, extensionType: string|null, misnamed: boolean}|null>}
+ *     `type` is 'msg', 'eml', 'mbox', 'tnef' (a winmail.dat file), 'pst', 'zip', 'gz' or
+ *     'unknown'; `misnamed` is true if the extension stands for another type. Null
+ *     outside the desktop app.
+ */
+export async function detectFileType(filePath) {
+    const apis = await getTauriApis();
+    if (!apis) return null;
+
+    return await apis.invoke('detect_file_type', { path: filePath });
+}
+
+/**
+ * Open a very large .msg or .eml file memory-mapped in the backend (Tauri only).
+ * Attachments over 1 MB are sent without content and read with readLargeAttachment.
+ * @param {string} filePath - Absolute path to the file
+ * @returns {Promise<{handle: number, fileSize: number, deferred: number[],
+ *     message: Object}|null>} The message in the shape of parseMsgFile and the indices
+ *     of the attachments without content; null for files small enough to read whole
+ */
+export async function openLargeMessage(filePath) {
+    const apis = await getTauriApis();
+    if (!apis) return null;
+
+    return await apis.invoke('open_large_message', { path: filePath });
+}
+
+/**
+ * Read the content of an attachment of a large message (Tauri only)
+ * @param {number} handle - Handle returned by openLargeMessage
+ * @param {number} index - Index of the attachment
+ * @returns {Promise<ArrayBuffer>} Attachment content
+ */
+export async function readLargeAttachment(handle, index) {
+    const apis = await getTauriApis();
+    if (!apis) {
+        throw new Error('Large messages can only be opened in the desktop app');
+    }
+
+    const bytes = await apis.invoke('read_large_attachment', { handle, index });
+    return bytes instanceof ArrayBuffer ? bytes : new Uint8Array(bytes).buffer;
+}
+
+/**
+ * Save an attachment of a large message with a "Save As" dialog; the backend writes it
+ * from the mapped file in the background, see startFileSave (Tauri only)
+ * @param {number} handle - Handle returned by openLargeMessage
+ * @param {number} index - Index of the attachment
+ * @param {string} fileName - Suggested filename
+ * @returns {Promise<{saveId: number, path: string, total: number}|null>} The started
+ *     save, null if the user cancelled the dialog
+ */
+export async function startLargeAttachmentSave(handle, index, fileName) {
+    const apis = await getTauriApis();
+    if (!apis) {
+        throw new Error('Large messages can only be opened in the desktop app');
+    }
+
+    return await apis.invoke('start_large_attachment_save', { handle, index, fileName });
+}
+
+/**
+ * Open an attachment of a large message with the system's default app (Tauri only)
+ * @param {number} handle - Handle returned by openLargeMessage
+ * @param {number} index - Index of the attachment
+ * @param {string} fileName - Name of the temp file
+ */
+export async function openLargeAttachment(handle, index, fileName) {
+    const apis = await getTauriApis();
+    if (!apis) {
+        throw new Error('Large messages can only be opened in the desktop app');
+    }
+
+    await apis.invoke('open_large_attachment', { handle, index, fileName });
+}
+
+/**
+ * Release a message opened with openLargeMessage (Tauri only)
+ * @param {number} handle - Handle returned by openLargeMessage
+ */
+export async function closeLargeMessage(handle) {
+    const apis = await getTauriApis();
+    if (!apis) return;
+
+    await apis.invoke('close_large_message', { handle });
+}
+
+/**
+ * Sanitize the HTML body of a message with the backend: scripts, event handlers, forms,
+ * dangerous CSS and external resources other than images are removed (Tauri only)
+ * @param {string} html - Untrusted HTML body
+ * @param {Object<string, string>} [inlineImages={}] - URLs of inline images by Content-ID,
+ *     for the cid: references of the body
+ * @returns {Promise<string>} Sanitized HTML
+ */
+export async function sanitizeHtml(html, inlineImages = {}) {
+    const apis = await getTauriApis();
+    if (!apis) {
+        throw new Error('HTML can only be sanitized by the desktop app');
+    }
+
+    return await apis.invoke('sanitize_html', { html, inlineImages });
+}
+
+/**
+ * Decode text in a charset iconv-lite does not support, e.g. ISO-2022-JP, ISO-2022-KR or
+ * ISO-2022-CN (Tauri only)
+ * @param {string} base64Content - The bytes as base64
+ * @param {string} charset - MIME charset or Windows code page number
+ * @returns {Promise<string>} Decoded text
+ */
+export async function decodeCharset(base64Content, charset) {
+    const apis = await getTauriApis();
+    if (!apis) {
+        throw new Error('Charsets can only be decoded in the desktop app');
+    }
+
+    return await apis.invoke('decode_bytes', { data: base64Content, charset });
+}
+
+/**
+ * Convert a message into an Outlook .msg file with the backend (Tauri only)
+ * @param {Object} messageData - JSON-serializable message (see messageToJson)
+ * @returns {Promise<Uint8Array>} MSG file content
+ */
+export async function exportMsg(messageData) {
+    const apis = await getTauriApis();
+    if (!apis) {
+        throw new Error('MSG export is only available in Tauri');
+    }
+
+    const bytes = await apis.invoke('export_msg', {
+        messageJson: JSON.stringify(messageData),
+    });
+    return new Uint8Array(bytes);
+}
+
+/**
+ * Write a redacted copy of a message with the backend (Tauri only): people pseudonymized
+ * or removed, attachments and tracking pixels dropped as chosen, transport headers left out
+ * @param {Object} messageData - JSON-serializable message (see messageToJson)
+ * @param {string} format - 'eml' or 'msg'
+ * @param {{addresses: string, dropAttachments: boolean, removeTrackingPixels: boolean}} options
+ *     Redaction options (see getRedactionOptions)
+ * @returns {Promise<Uint8Array>} File content
+ */
+export async function exportRedacted(messageData, format, options) {
+    const apis = await getTauriApis();
+    if (!apis) {
+        throw new Error('Redacted copies can only be exported in the desktop app');
+    }
+
+    const bytes = await apis.invoke('export_redacted', {
+        messageJson: JSON.stringify(messageData),
+        format,
+        options
+    });
+    return new Uint8Array(bytes);
 }
 
 /**
  * Get files that were passed to app on startup
  * @returns {Promise<string[]>} Array of file paths
  */
-export async function getPendingFiles() {
+export async function getPendingFiles() {
+    const apis = await getTauriApis();
+    if (!apis) return [];
+
+    return await apis.invoke('get_pending_files');
+}
+
+/**
+ * Listen for file open events from Tauri
+ * @param {function(string): void} callback - Called with file path
+ * @returns {Promise<function(): void>} Unlisten function
+ */
+export async function onFileOpen(callback) {
+    const apis = await getTauriApis();
+    if (!apis) return () => {};
+
+    return await apis.listen('file-open', (event) => {
+        if (event.payload) {
+            callback(event.payload);
+        }
+    });
+}
+
+/**
+ * Get archived messages linked with msgreader://open?archive=<id> when the app was launched
+ * @returns {Promise<Array<{id: number, fileName: string}>>}
+ */
+export async function getPendingArchiveMessages() {
+    const apis = await getTauriApis();
+    if (!apis) return [];
+
+    return await apis.invoke('get_pending_archive_messages');
+}
+
+/**
+ * Listen for archived messages opened by a msgreader:// link while the app is running
+ * @param {function({id: number, fileName: string}): void} callback - Called with the entry
+ * @returns {Promise<function(): void>} Unlisten function
+ */
+export async function onArchiveMessageOpen(callback) {
+    const apis = await getTauriApis();
+    if (!apis) return () => {};
+
+    return await apis.listen('archive-message-open', (event) => {
+        if (event.payload) {
+            callback(event.payload);
+        }
+    });
+}
+
+/**
+ * Whether this is a message window opened with openMessageInNewWindow rather than the
+ * main window
+ * @returns {boolean}
+ */
+export function isMessageWindow() {
+    return isTauri() && new URLSearchParams(window.location.search).get('window') === 'message';
+}
+
+/**
+ * Open a message in a window of its own (Tauri only)
+ * @param {{fileName: string, fileType: string, sourcePath?: string, path?: string,
+ *     data?: string}} message - The file of the message (`path`) or its base64 content
+ * @returns {Promise<string>} Label of the new window
+ */
+export async function openMessageInNewWindow(message) {
+    const apis = await getTauriApis();
+    if (!apis) {
+        throw new Error('Message windows are only available in the desktop app');
+    }
+
+    return await apis.invoke('open_message_in_new_window', { message });
+}
+
+/**
+ * The message this window was opened with; null in the main window and once taken
+ * @returns {Promise<{fileName: string, fileType: string, sourcePath: string,
+ *     path: string|null, data: string|null}|null>}
+ */
+export async function takeWindowMessage() {
+    const apis = await getTauriApis();
+    if (!apis) return null;
+
+    return await apis.invoke('take_window_message');
+}
+
+/**
+ * Extract filename from a file path
+ * @param {string} filePath - Full file path
+ * @returns {string} Just the filename
+ */
+export function getFileName(filePath) {
+    // Handle both Windows and Unix paths
+    const parts = filePath.split(/[/\\]/);
+    return parts[parts.length - 1];
+}
+
+/**
+ * Open a file with the system's default application (Tauri only)
+ * Saves the file to a temp location and opens it
+ * @param {string} base64Data - Base64 data URL (data:mime/type;base64,...)
+ * @param {string} fileName - Original filename
+ * @returns {Promise<void>}
+ */
+export async function openWithSystemViewer(base64Data, fileName) {
+    if (!isTauri()) {
+        throw new Error('openWithSystemViewer is only available in Tauri');
+    }
+
+    const { invoke } = await import('@tauri-apps/api/core');
+
+    // Extract the base64 content (remove data:mime/type;base64, prefix)
+    const base64Content = base64Data.split(',')[1];
+
+    // Call Rust command to save and open the file
+    await invoke('open_file_with_system', {
+        base64Content,
+        fileName,
+    });
+}
+
+/**
+ * Save a file with a "Save As" dialog (Tauri only)
+ * @param {string} base64Data - Base64 data URL (data:mime/type;base64,...)
+ * @param {string} fileName - Suggested filename
+ * @returns {Promise<string|false>} Path of the saved file, false if user cancelled
+ */
+export async function saveFileWithDialog(base64Data, fileName) {
+    if (!isTauri()) {
+        throw new Error('saveFileWithDialog is only available in Tauri');
+    }
+
+    const { invoke } = await import('@tauri-apps/api/core');
+
+    // Extract the base64 content (remove data:mime/type;base64, prefix)
+    const base64Content = base64Data.split(',')[1];
+
+    // Call Rust command to show save dialog and save file
+    const savedPath = await invoke('save_file_with_dialog', {
+        base64Content,
+        fileName,
+    });
+    return savedPath || false;
+}
+
+/**
+ * Save a file with a "Save As" dialog and let the backend write it in the background
+ * (Tauri only). Follow the save with onSaveProgress and onSaveFinished; until it
+ * finishes, the file is written as `<name>.part`, which is removed if the save is
+ * cancelled or fails.
+ * @param {string} base64Data - Base64 data URL (data:mime/type;base64,...)
+ * @param {string} fileName - Suggested filename
+ * @returns {Promise<{saveId: number, path: string, total: number}|null>} The started
+ *     save, null if the user cancelled the dialog
+ */
+export async function startFileSave(base64Data, fileName) {
+    const apis = await getTauriApis();
+    if (!apis) {
+        throw new Error('Files can only be saved in the background by the desktop app');
+    }
+
+    return await apis.invoke('start_file_save', {
+        base64Content: base64Data.split(',')[1],
+        fileName
+    });
+}
+
+/**
+ * Stop a save started with startFileSave or startLargeAttachmentSave and remove the
+ * partial file (Tauri only)
+ * @param {number} saveId - Id of the started save
+ * @returns {Promise<boolean>} False if the save is not running
+ */
+export async function cancelFileSave(saveId) {
+    const apis = await getTauriApis();
+    if (!apis) return false;
+
+    return await apis.invoke('cancel_file_save', { saveId });
+}
+
+/**
+ * Listen for the progress of background saves, reported at most every 100 ms (Tauri only)
+ * @param {function({saveId: number, written: number, total: number}): void} callback
+ * @returns {Promise<function(): void>} Unlisten function
+ */
+export async function onSaveProgress(callback) {
+    const apis = await getTauriApis();
+    if (!apis) return () => {};
+
+    return await apis.listen('save-progress', (event) => callback(event.payload));
+}
+
+/**
+ * Listen for background saves that finished, failed or were cancelled (Tauri only)
+ * @param {function({saveId: number, path: string, status: 'saved'|'cancelled'|'failed',
+ *     error: ?string}): void} callback
+ * @returns {Promise<function(): void>} Unlisten function
+ */
+export async function onSaveFinished(callback) {
+    const apis = await getTauriApis();
+    if (!apis) return () => {};
+
+    return await apis.listen('save-finished', (event) => callback(event.payload));
+}
+
+/**
+ * Save several attachments to a folder chosen in a dialog (Tauri only).
+ * Existing files are kept; conflicting names are numbered, e.g. "invoice (1).pdf".
+ * @param {Array<{fileName: string, contentBase64: string}>} attachments - Attachments
+ *     with base64 data URLs
+ * @returns {Promise<Array<{fileName: string, path: string|null, error: string|null}>|null>}
+ *     One result per attachment, null if the user cancelled
+ */
+export async function saveAllAttachments(attachments) {
+    if (!isTauri()) {
+        throw new Error('saveAllAttachments is only available in Tauri');
+    }
+
+    const { invoke } = await import('@tauri-apps/api/core');
+
+    return await invoke('save_all_attachments', {
+        files: attachments.map((attachment) => ({
+            fileName: attachment.fileName,
+            base64Content: attachment.contentBase64.split(',')[1] || '',
+        })),
+    });
+}
+
+/**
+ * Start a native drag of a file out of the app window (Tauri only)
+ * @param {string} base64Content - File content as plain base64
+ * @param {string} fileName - File name shown to the drop target
+ * @returns {Promise<boolean>} True if the file was dropped somewhere
+ */
+export async function startFileDrag(base64Content, fileName) {
+    const apis = await getTauriApis();
+    if (!apis) return false;
+
+    return await apis.invoke('start_message_drag', {
+        base64Content,
+        fileName,
+    });
+}
+
+/**
+ * Start a native drag of several files out of the app window (Tauri only)
+ * @param {Array<{fileName: string, base64Content: string}>} files - Files with their
+ *     content as plain base64
+ * @returns {Promise<boolean>} True if the files were dropped somewhere
+ */
+export async function startFilesDrag(files) {
+    const apis = await getTauriApis();
+    if (!apis) return false;
+
+    return await apis.invoke('start_files_drag', { files });
+}
+
+/**
+ * Put files on the OS clipboard as file objects, to paste them into a folder or another
+ * app (Tauri only)
+ * @param {Array<{fileName: string, base64Content: string}>} files - Files with their
+ *     content as plain base64
+ * @returns {Promise<void>}
+ */
+export async function copyFilesToClipboard(files) {
+    const apis = await getTauriApis();
+    if (!apis) throw new Error('Files can only be copied to the clipboard in the desktop app');
+
+    await apis.invoke('copy_files_to_clipboard', { files });
+}
+
+/**
+ * Delete all temp files created by the app (Tauri only)
+ * @returns {Promise<number>} Number of removed entries (0 outside Tauri)
+ */
+export async function clearTempFiles() {
+    const apis = await getTauriApis();
+    if (!apis) return 0;
+
+    return await apis.invoke('clear_temp_files');
+}
+
+/**
+ * Set how long temp files are kept before the backend removes them (Tauri only)
+ * @param {number} minutes - Retention in minutes, 0 keeps them until the app exits
+ * @returns {Promise<number>} Number of entries removed by the immediate sweep
+ */
+export async function setTempFileRetention(minutes) {
+    const apis = await getTauriApis();
+    if (!apis) return 0;
+
+    return await apis.invoke('set_temp_file_retention', { minutes });
+}
+
+/**
+ * List recently opened files that still exist, pinned files first (Tauri only)
+ * @returns {Promise<Array<{path: string, openedAt: number, pinned: boolean}>>}
+ *     Empty outside Tauri or if the list cannot be read
+ */
+export async function getRecentFiles() {
+    const apis = await getTauriApis();
+    if (!apis) return [];
+
+    try {
+        return await apis.invoke('get_recent_files');
+    } catch (error) {
+        console.error('Failed to load recent files:', error);
+        return [];
+    }
+}
+
+/**
+ * Record a file opened from the filesystem in the recent files list (Tauri only)
+ * @param {string} path - Absolute file path
+ */
+export async function addRecentFile(path) {
+    const apis = await getTauriApis();
+    if (!apis) return;
+
+    try {
+        await apis.invoke('add_recent_file', { path });
+    } catch (error) {
+        console.error('Failed to record recent file:', error);
+    }
+}
+
+/**
+ * Pin or unpin a recent file; pinned files stay when the list is cleared (Tauri only)
+ * @param {string} path - Path from getRecentFiles
+ * @param {boolean} pinned
+ */
+export async function pinRecentFile(path, pinned) {
+    const apis = await getTauriApis();
+    if (!apis) return;
+
+    await apis.invoke('pin_recent_file', { path, pinned });
+}
+
+/**
+ * Remove all unpinned files from the recent files list (Tauri only)
+ */
+export async function clearRecentFiles() {
+    const apis = await getTauriApis();
+    if (!apis) return;
+
+    await apis.invoke('clear_recent_files');
+}
+
+/**
+ * Record the open messages and the one shown; the backend saves them when the app exits
+ * (Tauri only)
+ * @param {{messages: Array<{kind: string, path: string, id: number|null, fileName: string}>,
+ *     selected: number|null}} session
+ */
+export async function updateSession(session) {
+    const apis = await getTauriApis();
+    if (!apis) return;
+
+    await apis.invoke('update_session', { session });
+}
+
+/**
+ * The session saved when the app last exited (Tauri only)
+ * @returns {Promise<{messages: Array<Object>, selected: number|null, missing: Array<Object>}>}
+ *     Messages that can still be opened and those whose file is gone; empty outside Tauri
+ */
+export async function restoreSession() {
+    const apis = await getTauriApis();
+    if (!apis) return { messages: [], selected: null, missing: [] };
+
+    return await apis.invoke('restore_session');
+}
+
+/**
+ * Get the settings stored in the backend for the active profile (Tauri only)
+ * @returns {Promise<{theme?: string, externalContent?: string, remoteImageSenders?: string[],
+ *     defaultSaveDirectory?: string, startup?: string}>} Set values only, empty outside Tauri
+ */
+export async function getSettings() {
+    const apis = await getTauriApis();
+    if (!apis) return {};
+
+    return await apis.invoke('get_settings');
+}
+
+/**
+ * Change a backend setting; all windows get a settings-changed event (Tauri only)
+ * @param {string} key - Setting name, see getSettings
+ * @param {*} value - New value, null for the default
+ */
+export async function setSetting(key, value) {
+    const apis = await getTauriApis();
+    if (!apis) return;
+
+    await apis.invoke('set_setting', { key, value });
+}
+
+/**
+ * Base URL of the local proxy that loads remote images of messages without cookies or
+ * referrer and caches them on disk (Tauri only). Append the encoded remote URL.
+ * @returns {Promise<string|null>} Null outside Tauri, offline or when a policy blocks
+ *     remote content
+ */
+export async function getRemoteImageProxy() {
+    const apis = await getTauriApis();
+    if (!apis) return null;
+
+    return await apis.invoke('get_remote_image_proxy');
+}
+
+/**
+ * Delete the remote images cached by the proxy (Tauri only)
+ * @returns {Promise<number>} Number of removed images
+ */
+export async function clearRemoteImageCache() {
+    const apis = await getTauriApis();
+    if (!apis) return 0;
+
+    return await apis.invoke('clear_remote_image_cache');
+}
+
+/**
+ * Choose the folder the save dialogs start in (Tauri only)
+ * @returns {Promise<string|null>} Chosen folder, null if cancelled
+ */
+export async function pickDefaultSaveDirectory() {
+    const apis = await getTauriApis();
+    if (!apis) return null;
+
+    return await apis.invoke('pick_default_save_directory');
+}
+
+/**
+ * Listen for changed backend settings, including changes from other windows (Tauri only)
+ * @param {function({key: string, value: *}): void} callback - Called with the stored value
+ * @returns {Promise<function(): void>} Unlisten function
+ */
+export async function onSettingsChanged(callback) {
+    const apis = await getTauriApis();
+    if (!apis) return () => {};
+
+    return await apis.listen('settings-changed', (event) => callback(event.payload));
+}
+
+/**
+ * Choose a folder in a dialog (Tauri only)
+ * @returns {Promise<string|null>} Chosen folder, null if cancelled or outside Tauri
+ */
+export async function pickFolder() {
+    const apis = await getTauriApis();
+    if (!apis) return null;
+
+    return await apis.invoke('pick_folder');
+}
+
+/**
+ * Allow the folders watched before the app was restarted to be watched again (Tauri
+ * only). The backend keeps its own list of the folders the user chose.
+ * @returns {Promise<Array<string>>} Restored folders that still exist
+ */
+export async function restoreWatchedFolders() {
+    const apis = await getTauriApis();
+    if (!apis) return [];
+
+    return await apis.invoke('restore_watched_folders');
+}
+
+/**
+ * Watch a folder for new .msg/.eml files, reported through onWatchedFile (Tauri only).
+ * The folder must come from pickFolder or restoreWatchedFolders.
+ * @param {string} path - Folder path
+ */
+export async function watchFolder(path) {
+    const apis = await getTauriApis();
+    if (!apis) return;
+
+    await apis.invoke('watch_folder', { path });
+}
+
+/**
+ * Stop watching a folder (Tauri only)
+ * @param {string} path - Folder path
+ */
+export async function unwatchFolder(path) {
+    const apis = await getTauriApis();
+    if (!apis) return;
+
+    await apis.invoke('unwatch_folder', { path });
+}
+
+/**
+ * Listen for new files in watched folders. Files are reported once they have been
+ * completely written (Tauri only).
+ * @param {function(string): void} callback - Called with the file path
+ * @returns {Promise<function(): void>} Unlisten function
+ */
+export async function onWatchedFile(callback) {
+    const apis = await getTauriApis();
+    if (!apis) return () => {};
+
+    return await apis.listen('watched-file', (event) => callback(event.payload));
+}
+
+/**
+ * Listen for clicks on the OS notification of a new file in a watched folder; the
+ * backend has brought the main window to the front (Tauri only)
+ * @param {function(string): void} callback - Called with the file path
+ * @returns {Promise<function(): void>} Unlisten function
+ */
+export async function onNotificationOpen(callback) {
+    const apis = await getTauriApis();
+    if (!apis) return () => {};
+
+    return await apis.listen('notification-open', (event) => callback(event.payload));
+}
+
+/**
+ * List the .msg/.eml files of a folder without reading them (Tauri only)
+ * @param {string} path - Folder path
+ * @param {boolean} [recursive=false] - Include subfolders
+ * @returns {Promise<{folder: string, total: number}|null>} Null outside Tauri
+ */
+export async function openFolder(path, recursive = false) {
+    const apis = await getTauriApis();
+    if (!apis) return null;
+
+    return await apis.invoke('open_folder', { path, recursive });
+}
+
+/**
+ * Get the summaries of a page of files of an opened folder (Tauri only)
+ * @param {string} path - Folder passed to openFolder
+ * @param {number} offset - Index of the first file
+ * @param {number} limit - Number of files (at most 200)
+ * @returns {Promise<{offset: number, total: number, entries: Array<{path: string, name: string,
+ *     size: number, modified: ?number, subject: string, senderName: string, senderEmail: string,
+ *     date: ?number, error: ?string}>}>}
+ */
+export async function getFolderPage(path, offset, limit) {
+    const apis = await getTauriApis();
+    if (!apis) return { offset, total: 0, entries: [] };
+
+    return await apis.invoke('get_folder_page', { path, offset, limit });
+}
+
+/**
+ * Forget the file list of an opened folder (Tauri only)
+ * @param {string} path - Folder passed to openFolder
+ */
+export async function closeFolder(path) {
+    const apis = await getTauriApis();
+    if (!apis) return;
+
+    await apis.invoke('close_folder', { path });
+}
+
+/**
+ * Choose an Outlook data file (Tauri only)
+ * @returns {Promise<string|null>} Path of the .pst/.ost file, null if cancelled
+ */
+export async function pickPstFile() {
+    const apis = await getTauriApis();
+    if (!apis) return null;
+
+    return await apis.invoke('pick_pst_file');
+}
+
+/**
+ * Open a PST/OST file read-only (Tauri only)
+ * @param {string} path - Path of the file
+ * @returns {Promise<{id: number, name: string, count: number, children: Array}>} Root folder
+ *     with its subfolders
+ */
+export async function openPstFile(path) {
+    const apis = await getTauriApis();
+    if (!apis) {
+        throw new Error('PST files can only be opened in the desktop app');
+    }
+
+    return await apis.invoke('open_pst_file', { path });
+}
+
+/**
+ * Get a page of the messages of a PST folder (Tauri only)
+ * @param {string} path - File passed to openPstFile
+ * @param {number} folder - Folder id
+ * @param {number} offset - Index of the first message
+ * @param {number} limit - Number of messages (at most 200)
+ * @returns {Promise<{offset: number, total: number, messages: Array<{id: number,
+ *     subject: string, senderName: string, date: ?number, size: number,
+ *     hasAttachments: boolean}>}>}
+ */
+export async function getPstMessages(path, folder, offset, limit) {
+    const apis = await getTauriApis();
+    if (!apis) return { offset, total: 0, messages: [] };
+
+    return await apis.invoke('get_pst_messages', { path, folder, offset, limit });
+}
+
+/**
+ * Read a message of a PST file as an Outlook .msg file (Tauri only)
+ * @param {string} path - File passed to openPstFile
+ * @param {number} id - Message id
+ * @returns {Promise<ArrayBuffer>} MSG file content
+ */
+export async function readPstMessage(path, id) {
+    const apis = await getTauriApis();
+    if (!apis) {
+        throw new Error('PST files can only be opened in the desktop app');
+    }
+
+    const bytes = await apis.invoke('read_pst_message', { path, id });
+    return bytes instanceof ArrayBuffer ? bytes : new Uint8Array(bytes).buffer;
+}
+
+/**
+ * Close a PST file opened with openPstFile (Tauri only)
+ * @param {string} path - Path of the file
+ */
+export async function closePstFile(path) {
+    const apis = await getTauriApis();
+    if (!apis) return;
+
+    await apis.invoke('close_pst_file', { path });
+}
+
+/**
+ * Let the user choose an mbox file (Tauri only)
+ * @returns {Promise<string|null>} Path of the file, null if cancelled
+ */
+export async function pickMboxFile() {
+    const apis = await getTauriApis();
+    if (!apis) return null;
+
+    return await apis.invoke('pick_mbox_file');
+}
+
+/**
+ * Split an mbox file into its messages (Tauri only)
+ * @param {string} path - Path of the file
+ * @returns {Promise<{path: string, total: number}>} Number of messages found
+ */
+export async function openMboxFile(path) {
+    const apis = await getTauriApis();
+    if (!apis) {
+        throw new Error('Mbox files can only be opened in the desktop app');
+    }
+
+    return await apis.invoke('open_mbox_file', { path });
+}
+
+/**
+ * Get a page of the messages of an mbox file (Tauri only)
+ * @param {string} path - File passed to openMboxFile
+ * @param {number} offset - Index of the first message
+ * @param {number} limit - Number of messages (at most 200)
+ * @returns {Promise<{offset: number, total: number, entries: Array<{index: number,
+ *     size: number, subject: string, senderName: string, senderEmail: string,
+ *     date: ?number, error: ?string}>}>}
+ */
+export async function getMboxMessages(path, offset, limit) {
+    const apis = await getTauriApis();
+    if (!apis) return { offset, total: 0, entries: [] };
+
+    return await apis.invoke('get_mbox_messages', { path, offset, limit });
+}
+
+/**
+ * Read a message of an mbox file (Tauri only)
+ * @param {string} path - File passed to openMboxFile
+ * @param {number} index - Message index
+ * @returns {Promise<ArrayBuffer>} EML file content
+ */
+export async function readMboxMessage(path, index) {
+    const apis = await getTauriApis();
+    if (!apis) {
+        throw new Error('Mbox files can only be opened in the desktop app');
+    }
+
+    const bytes = await apis.invoke('read_mbox_message', { path, index });
+    return bytes instanceof ArrayBuffer ? bytes : new Uint8Array(bytes).buffer;
+}
+
+/**
+ * Save messages of an mbox file as .eml files to a chosen folder (Tauri only).
+ * Conflicting names are numbered.
+ * @param {string} path - File passed to openMboxFile
+ * @param {number[]} indices - Message indices
+ * @returns {Promise<Array<{fileName: string, path: ?string, error: ?string}>|null>} One
+ *     result per message, null if cancelled
+ */
+export async function exportMboxMessages(path, indices) {
+    const apis = await getTauriApis();
+    if (!apis) {
+        throw new Error('Mbox files can only be exported in the desktop app');
+    }
+
+    return await apis.invoke('export_mbox_messages', { path, indices });
+}
+
+/**
+ * Close an mbox file opened with openMboxFile (Tauri only)
+ * @param {string} path - Path of the file
+ */
+export async function closeMboxFile(path) {
+    const apis = await getTauriApis();
+    if (!apis) return;
+
+    await apis.invoke('close_mbox_file', { path });
+}
+
+/**
+ * Read a gzip-compressed .msg or .eml file (Tauri only)
+ * @param {string} path - Absolute path of the .gz file
+ * @returns {Promise<ArrayBuffer>} Unpacked message file content
+ */
+export async function readGzipMessage(path) {
+    const apis = await getTauriApis();
+    if (!apis) {
+        throw new Error('Compressed files can only be opened in the desktop app');
+    }
+
+    const bytes = await apis.invoke('read_gzip_message', { path });
+    return bytes instanceof ArrayBuffer ? bytes : new Uint8Array(bytes).buffer;
+}
+
+/**
+ * List the .msg and .eml files in a ZIP archive, also gzip-compressed ones (Tauri only)
+ * @param {string} path - Absolute path of the ZIP file
+ * @returns {Promise<Array<{index: number, name: string, size: number}>>} Members in
+ *     archive order
+ */
+export async function listZipMessages(path) {
+    const apis = await getTauriApis();
+    if (!apis) {
+        throw new Error('Compressed files can only be opened in the desktop app');
+    }
+
+    return await apis.invoke('list_zip_messages', { path });
+}
+
+/**
+ * Read a message of a ZIP archive (Tauri only)
+ * @param {string} path - Absolute path of the ZIP file
+ * @param {number} index - Member index from listZipMessages
+ * @returns {Promise<ArrayBuffer>} Unpacked message file content
+ */
+export async function readZipMessage(path, index) {
+    const apis = await getTauriApis();
+    if (!apis) {
+        throw new Error('Compressed files can only be opened in the desktop app');
+    }
+
+    const bytes = await apis.invoke('read_zip_message', { path, index });
+    return bytes instanceof ArrayBuffer ? bytes : new Uint8Array(bytes).buffer;
+}
+
+/**
+ * Whether the backend knows the password of an IMAP account, from this session or
+ * remembered in the OS credential store (Tauri only)
+ * @param {{host: string, port: number, username: string}} account - IMAP account
+ * @returns {Promise<boolean>}
+ */
+export async function hasImapPassword(account) {
+    const apis = await getTauriApis();
+    if (!apis) return false;
+
+    return await apis.invoke('has_imap_password', { account });
+}
+
+/**
+ * Log in to an IMAP account over TLS and list its folders (Tauri only)
+ * @param {{host: string, port: number, username: string}} account - IMAP account
+ * @param {?string} password - Password, null to use the known one
+ * @param {boolean} remember - Keep the password in the OS credential store
+ * @returns {Promise<Array<{name: string, displayName: string, selectable: boolean}>>}
+ *     Folders, the inbox first
+ */
+export async function connectImapAccount(account, password, remember) {
+    const apis = await getTauriApis();
+    if (!apis) {
+        throw new Error('IMAP mailboxes can only be opened in the desktop app');
+    }
+
+    return await apis.invoke('connect_imap_account', { account, password, remember });
+}
+
+/**
+ * Get a page of the messages of an IMAP folder, newest first (Tauri only)
+ * @param {{host: string, port: number, username: string}} account - Connected account
+ * @param {string} folder - Folder name as listed by connectImapAccount
+ * @param {number} offset - Number of newer messages to skip
+ * @param {number} limit - Number of messages (at most 200)
+ * @returns {Promise<{offset: number, total: number, entries: Array<{uid: number,
+ *     size: number, subject: string, senderName: string, senderEmail: string,
+ *     date: ?number, error: ?string}>}>}
+ */
+export async function getImapMessages(account, folder, offset, limit) {
+    const apis = await getTauriApis();
+    if (!apis) return { offset, total: 0, entries: [] };
+
+    return await apis.invoke('get_imap_messages', { account, folder, offset, limit });
+}
+
+/**
+ * Download a message of an IMAP folder; it stays unread on the server (Tauri only)
+ * @param {{host: string, port: number, username: string}} account - Connected account
+ * @param {string} folder - Folder name
+ * @param {number} uid - Message uid
+ * @returns {Promise<ArrayBuffer>} EML file content
+ */
+export async function readImapMessage(account, folder, uid) {
+    const apis = await getTauriApis();
+    if (!apis) {
+        throw new Error('IMAP mailboxes can only be opened in the desktop app');
+    }
+
+    const bytes = await apis.invoke('read_imap_message', { account, folder, uid });
+    return bytes instanceof ArrayBuffer ? bytes : new Uint8Array(bytes).buffer;
+}
+
+/**
+ * Forget the password of an IMAP account, also in the OS credential store (Tauri only)
+ * @param {{host: string, port: number, username: string}} account - IMAP account
+ */
+export async function forgetImapPassword(account) {
+    const apis = await getTauriApis();
+    if (!apis) return;
+
+    await apis.invoke('forget_imap_password', { account });
+}
+
+/**
+ * Store a message in the local archive (Tauri only). The same file is stored only once.
+ * @param {string} base64Content - The .eml or .msg file as base64
+ * @param {string} fileName - Name the file is exported under later
+ * @param {string} [folder=''] - Archive folder, empty for none
+ * @returns {Promise<{entry: Object, added: boolean}>} The archive entry; added is false if
+ *     the file was archived before
+ */
+export async function importToArchive(base64Content, fileName, folder = '') {
+    const apis = await getTauriApis();
+    if (!apis) {
+        throw new Error('The archive is only available in the desktop app');
+    }
+
+    return await apis.invoke('import_to_archive', { data: base64Content, fileName, folder });
+}
+
+/**
+ * Get a page of archived messages, newest first (Tauri only)
+ * @param {{folder?: string, tag?: string, text?: string}} query - Filters that must all
+ *     match; text words are searched in subject, sender, file name and text body
+ * @param {number} offset - Index of the first entry
+ * @param {number} limit - Number of entries (at most 200)
+ * @returns {Promise<{offset: number, total: number, entries: Array<{id: number,
+ *     fileName: string, folder: string, tags: string[], size: number, importedAt: number,
+ *     subject: string, senderName: string, senderEmail: string, date: ?number}>}>}
+ */
+export async function getArchiveMessages(query, offset, limit) {
+    const apis = await getTauriApis();
+    if (!apis) {
+        return { offset, total: 0, entries: [] };
+    }
+
+    return await apis.invoke('get_archive_messages', { query, offset, limit });
+}
+
+/**
+ * Get the folders and tags used in the archive (Tauri only)
+ * @returns {Promise<{folders: string[], tags: string[]}>} Sorted, empty outside Tauri
+ */
+export async function getArchiveLabels() {
+    const apis = await getTauriApis();
+    if (!apis) return { folders: [], tags: [] };
+
+    return await apis.invoke('get_archive_labels');
+}
+
+/**
+ * Replace the tags of archived messages (Tauri only)
+ * @param {number[]} ids - Archive entry ids
+ * @param {string[]} tags - New tags; empty ones and repeats are dropped
+ */
+export async function setArchiveTags(ids, tags) {
+    const apis = await getTauriApis();
+    if (!apis) {
+        throw new Error('The archive is only available in the desktop app');
+    }
+
+    await apis.invoke('set_archive_tags', { ids, tags });
+}
+
+/**
+ * Move archived messages to a folder (Tauri only)
+ * @param {number[]} ids - Archive entry ids
+ * @param {string} folder - Folder name, empty for none
+ */
+export async function moveArchiveMessages(ids, folder) {
+    const apis = await getTauriApis();
+    if (!apis) {
+        throw new Error('The archive is only available in the desktop app');
+    }
+
+    await apis.invoke('move_archive_messages', { ids, folder });
+}
+
+/**
+ * Remove messages from the archive (Tauri only)
+ * @param {number[]} ids - Archive entry ids
+ * @returns {Promise<number>} Number of removed messages
+ */
+export async function deleteArchiveMessages(ids) {
+    const apis = await getTauriApis();
+    if (!apis) {
+        throw new Error('The archive is only available in the desktop app');
+    }
+
+    return await apis.invoke('delete_archive_messages', { ids });
+}
+
+/**
+ * Read the original file of an archived message (Tauri only)
+ * @param {number} id - Archive entry id
+ * @returns {Promise<ArrayBuffer>} The .msg or .eml file as it was imported
+ */
+export async function readArchiveMessage(id) {
+    const apis = await getTauriApis();
+    if (!apis) {
+        throw new Error('The archive is only available in the desktop app');
+    }
+
+    const bytes = await apis.invoke('read_archive_message', { id });
+    return bytes instanceof ArrayBuffer ? bytes : new Uint8Array(bytes).buffer;
+}
+
+/**
+ * Save the original files of archived messages to a chosen folder (Tauri only).
+ * Conflicting names are numbered.
+ * @param {number[]} ids - Archive entry ids
+ * @returns {Promise<Array<{fileName: string, path: ?string, error: ?string}>|null>} One
+ *     result per message, null if cancelled
+ */
+export async function exportArchiveMessages(ids) {
+    const apis = await getTauriApis();
+    if (!apis) {
+        throw new Error('The archive is only available in the desktop app');
+    }
+
+    return await apis.invoke('export_archive_messages', { ids });
+}
+
+/**
+ * Write opened messages into one ZIP with a manifest.json (Tauri only), laid out like
+ * the ZIPs of createBulkExportZipBlob so verifyBulkExportZip checks them. The backend
+ * reads, converts and compresses one message at a time, so large selections are not
+ * held in memory; messages that cannot be read are listed as skipped.
+ * @param {Array<Object>} messages - Messages of the message handler with a _sourcePath
+ * @param {string} format - 'original', 'eml' or 'pdf'
+ * @param {Object} [options]
+ * @param {string} [options.scope='messages'] - Scope label recorded in the manifest
+ * @param {boolean} [options.checksums=true] - Record size and SHA-256 of each file
+ * @param {?string} [options.path=null] - ZIP file to write, null to ask with a save dialog
+ * @returns {Promise<{path: string, exportedCount: number, skipped: Array<{source:
+ *     string, fileName: string, reason: string}>}|null>} Null if cancelled
+ */
+export async function exportMessagesAsZip(
+    messages,
+    format,
+    { scope = 'messages', checksums = true, path = null } = {}
+) {
+    const apis = await getTauriApis();
+    if (!apis) {
+        throw new Error('ZIP files can only be written by the desktop app');
+    }
+
+    const items = messages
+        .filter((message) => message._sourcePath)
+        .map((message) => ({
+            source: message._sourcePath,
+            fileName: message.fileName || '',
+            messageHash: message.messageHash || '',
+            subject: message.subject || ''
+        }));
+    return await apis.invoke('export_messages_zip', { items, format, scope, checksums, path });
+}
+
+/**
+ * Write opened messages into a Maildir folder chosen in a dialog (Tauri only), e.g. to
+ * serve them with Dovecot. .msg files are converted to .eml; read, flagged, replied and
+ * forwarded messages keep their flags. Messages that cannot be read are listed as skipped.
+ * @param {Array<Object>} messages - Messages of the message handler with a _sourcePath
+ * @param {function(Object): boolean} [isFlagged] - Messages to flag in any case, e.g.
+ *     the pinned ones
+ * @returns {Promise<{path: string, exportedCount: number, folderCount: number,
+ *     skipped: Array<{source: string, reason: string}>}|null>} Null if cancelled
+ */
+export async function exportMessagesAsMaildir(messages, isFlagged = () => false) {
+    const apis = await getTauriApis();
+    if (!apis) {
+        throw new Error('Maildir folders can only be written by the desktop app');
+    }
+
+    const items = messages
+        .filter((message) => message._sourcePath)
+        .map((message) => ({ source: message._sourcePath, flagged: isFlagged(message) }));
+    return await apis.invoke('export_messages_maildir', { items });
+}
+
+/**
+ * Write a folder of an open PST file and its subfolders into a Maildir++ folder chosen
+ * in a dialog (Tauri only); subfolders become `.Parent.Child` folders
+ * @param {string} path - Path of the .pst/.ost file
+ * @param {number} folderId - Id of the folder
+ * @returns {Promise<{path: string, exportedCount: number, folderCount: number,
+ *     skipped: Array<{source: string, reason: string}>}|null>} Null if cancelled
+ */
+export async function exportPstFolderAsMaildir(path, folderId) {
+    const apis = await getTauriApis();
+    if (!apis) {
+        throw new Error('Maildir folders can only be written by the desktop app');
+    }
+
+    return await apis.invoke('export_pst_maildir', { path, folder: folderId });
+}
+
+/**
+ * Group opened messages into conversations by Message-ID, In-Reply-To, References and
+ * Thread-Index (or the conversation index of .msg files) (Tauri only)
+ * @param {Array<Object>} messages - Messages of the message handler
+ * @returns {Promise<Array<{subject: string, keys: string[], latest: ?number}>|null>}
+ *     Conversations newest first, with the messageHash of their messages oldest first;
+ *     null outside Tauri
+ */
+export async function getThreads(messages) {
+    const apis = await getTauriApis();
+    if (!apis) return null;
+
+    const inputs = messages.map((message) => ({
+        key: message.messageHash,
+        headers: message._exportMeta?.rawHeaders || '',
+        messageId: message.messageId || message._exportMeta?.headerMap?.['message-id'] || '',
+        subject: message.subject || '',
+        date: message.timestamp ? message.timestamp.getTime() : null,
+        path: message._sourcePath || null
+    }));
+    return await apis.invoke('get_threads', { messages: inputs });
+}
+
+/**
+ * Find opened messages that are copies of each other: same Message-ID, date (to the
+ * second) and body text (Tauri only)
+ * @param {Array<Object>} messages - Messages of the message handler
+ * @returns {Promise<Array<{keys: string[], messageId: string}>|null>} Groups of copies
+ *     with the messageHash of their messages in the given order; null outside Tauri
+ */
+export async function findDuplicates(messages) {
+    const apis = await getTauriApis();
+    if (!apis) return null;
+
+    const inputs = messages.map((message) => ({
+        key: message.messageHash,
+        headers: message._exportMeta?.rawHeaders || '',
+        messageId: message.messageId || message._exportMeta?.headerMap?.['message-id'] || '',
+        date: message.timestamp ? message.timestamp.getTime() : null,
+        bodyText: message.bodyContent || message.body || '',
+        bodyHtml: message.bodyContentHTML || ''
+    }));
+    return await apis.invoke('find_duplicates', { messages: inputs });
+}
+
+/**
+ * Search the subject, transport headers, body and attachment names of opened messages
+ * in the backend, which is much faster than searching large HTML bodies here (Tauri only)
+ * @param {Array<Object>} messages - Messages of the message handler
+ * @param {string} pattern - Text to find, or a regular expression with options.regex
+ * @param {Object} [options]
+ * @param {boolean} [options.regex=false] - The pattern is a regular expression (Rust syntax)
+ * @param {boolean} [options.caseSensitive=false]
+ * @param {boolean} [options.wholeWord=false] - Only match whole words
+ * @returns {Promise<Array<{key: string, matchCount: number,
+ *     matches: Array<{field: 'subject'|'headers'|'body'|'attachment', attachment: ?number,
+ *         start: number, end: number, before: string, text: string, after: string}>}>|null>}
+ *     Messages with matches by messageHash, in the given order, with up to 100 matches
+ *     each; null outside Tauri. Rejects if the pattern is invalid.
+ */
+export async function searchOpenMessages(messages, pattern, options = {}) {
+    const apis = await getTauriApis();
+    if (!apis) return null;
+
+    const inputs = messages.map((message) => ({
+        key: message.messageHash,
+        subject: message.subject || '',
+        headers: message._exportMeta?.rawHeaders || '',
+        bodyText: message.bodyContent || message.body || '',
+        bodyHtml: message.bodyContentHTML || '',
+        attachments: (message.attachments || []).map((attachment) => attachment.fileName || '')
+    }));
+    return await apis.invoke('search_open_messages', {
+        messages: inputs,
+        pattern,
+        options: {
+            regex: Boolean(options.regex),
+            caseSensitive: Boolean(options.caseSensitive),
+            wholeWord: Boolean(options.wholeWord)
+        }
+    });
+}
+
+/**
+ * Compare two copies of a message in the backend, e.g. an original and its journaled or
+ * forwarded copy (Tauri only)
+ * @param {Object} messageA - JSON-serializable message (see messageToJson) with the raw
+ *     transport headers as rawHeaders
+ * @param {Object} messageB - The message to compare it with, in the same shape
+ * @returns {Promise<{identical: boolean,
+ *     fields: Array<{name: string, a: string, b: string}>,
+ *     headers: Array<{name: string, a: string, b: string}>,
+ *     recipients: Array<{name: string, email: string, kindA: ?string, kindB: ?string}>,
+ *     body: Array<{aStart: number, bStart: number,
+ *         lines: Array<{kind: 'same'|'removed'|'added', text: string}>}>,
+ *     attachments: Array<{status: 'same'|'renamed'|'changed'|'removed'|'added',
+ *         a: ?{fileName: string, size: number, sha256: string}, b: ?Object}>}>}
+ *     Differences from messageA to messageB
+ */
+export async function compareMessages(messageA, messageB) {
+    const apis = await getTauriApis();
+    if (!apis) {
+        throw new Error('Messages can only be compared in the desktop app');
+    }
+
+    return await apis.invoke('compare_messages', {
+        messageA: JSON.stringify(messageA),
+        messageB: JSON.stringify(messageB)
+    });
+}
+
+/**
+ * Convert all .msg/.eml files of a folder in the backend (Tauri only). The job runs in
+ * the background; follow it with onConversionProgress and onConversionFinished.
+ * @param {string} format - 'eml', 'pdf', 'json' or 'text'
+ * @param {Object} [options]
+ * @param {string} [options.folder] - Folder to convert; chosen in a dialog if omitted
+ * @param {string} [options.output] - Folder for the converted files; chosen in a dialog
+ *     if omitted. Existing files are never overwritten.
+ * @param {boolean} [options.recursive=false] - Include subfolders
+ * @returns {Promise<{jobId: number, folder: string, output: string, total: number}|null>}
+ *     The started job, null if a dialog was cancelled
+ */
+export async function startBatchConversion(format, options = {}) {
+    const apis = await getTauriApis();
+    if (!apis) {
+        throw new Error('Folders can only be converted in the desktop app');
+    }
+
+    return await apis.invoke('start_batch_conversion', {
+        folder: options.folder ?? null,
+        output: options.output ?? null,
+        format,
+        recursive: options.recursive ?? false
+    });
+}
+
+/**
+ * Stop a batch conversion after the files being converted (Tauri only)
+ * @param {number} jobId
+ * @returns {Promise<boolean>} False if the job is not running
+ */
+export async function cancelBatchConversion(jobId) {
+    const apis = await getTauriApis();
+    if (!apis) return false;
+
+    return await apis.invoke('cancel_batch_conversion', { jobId });
+}
+
+/**
+ * Read the OS light/dark theme and accent color (Tauri only)
+ * @returns {Promise<{theme: string, accentColor: string|null}>} 'light' or 'dark', and the
+ *     accent color as #rrggbb where the OS has one
+ */
+export async function getSystemTheme() {
+    const apis = await getTauriApis();
+    if (!apis) {
+        throw new Error('The system theme can only be read in the desktop app');
+    }
+
+    return await apis.invoke('get_system_theme');
+}
+
+/**
+ * Listen for changes of the OS theme or accent color (Tauri only)
+ * @param {function({theme: string, accentColor: string|null}): void} callback - Called with
+ *     the new system theme
+ * @returns {Promise<function(): void>} Unlisten function
+ */
+export async function onSystemThemeChanged(callback) {
+    const apis = await getTauriApis();
+    if (!apis) return () => {};
+
+    return await apis.listen('theme-changed', (event) => callback(event.payload));
+}
+
+/**
+ * Listen for files converted by batch conversions (Tauri only)
+ * @param {function({jobId: number, path: string, status: string, output: string|null,
+ *     error: string|null, done: number, total: number}): void} callback - Called per file;
+ *     status is 'converted' or 'failed'
+ * @returns {Promise<function(): void>} Unlisten function
+ */
+export async function onConversionProgress(callback) {
+    const apis = await getTauriApis();
+    if (!apis) return () => {};
+
+    return await apis.listen('conversion-progress', (event) => callback(event.payload));
+}
+
+/**
+ * Listen for batch conversions that finished or were cancelled (Tauri only)
+ * @param {function({jobId: number, total: number, converted: number, failed: number,
+ *     cancelled: boolean, elapsedMs: number, failures: Array<{path: string, error: string}>,
+ *     reportPath: string|null}): void} callback - Called with the summary report
+ * @returns {Promise<function(): void>} Unlisten function
+ */
+export async function onConversionFinished(callback) {
+    const apis = await getTauriApis();
+    if (!apis) return () => {};
+
+    return await apis.listen('conversion-finished', (event) => callback(event.payload));
+}
+
+/**
+ * Let the user choose a PKCS#12 certificate file (.pfx/.p12, Tauri only)
+ * @returns {Promise<string|null>} Path of the file, null if cancelled
+ */
+export async function pickCertificateFile() {
+    const apis = await getTauriApis();
+    if (!apis) return null;
+
+    return await apis.invoke('pick_certificate_file');
+}
+
+/**
+ * Whether S/MIME messages can be decrypted with the OS certificate store (Windows and
+ * macOS desktop app)
+ * @returns {Promise<boolean>}
+ */
+export async function isSmimeKeystoreAvailable() {
+    const apis = await getTauriApis();
+    if (!apis) return false;
+
+    try {
+        return await apis.invoke('smime_keystore_available');
+    } catch {
+        return false;
+    }
+}
+
+/**
+ * Decrypt an S/MIME message (Tauri only)
+ * @param {string} base64Content - The smime.p7m as plain base64
+ * @param {Object} [certificate] - PKCS#12 file to use; the OS certificate store if omitted
+ * @param {string} [certificate.path] - Path of the .pfx/.p12 file
+ * @param {string} [certificate.password] - Its password
+ * @returns {Promise<ArrayBuffer>} The decrypted MIME entity
+ */
+export async function decryptSmime(base64Content, certificate = {}) {
+    const apis = await getTauriApis();
+    if (!apis) {
+        throw new Error('S/MIME messages can only be decrypted in the desktop app');
+    }
+
+    const bytes = await apis.invoke('decrypt_smime', {
+        data: base64Content,
+        certificatePath: certificate.path ?? null,
+        password: certificate.password ?? null
+    });
+    return bytes instanceof ArrayBuffer ? bytes : new Uint8Array(bytes).buffer;
+}
+
+/**
+ * Check the DKIM signatures, SPF and DMARC of a message (Tauri only). DKIM keys and
+ * DMARC policies are looked up in DNS, except in offline mode; for .msg files only the
+ * headers can be checked.
+ * @param {string} base64Content - The .eml or .msg file as base64
+ * @returns {Promise<Object>} Report with fromDomain, recorded, dkim, spf, dmarc, bodyChecked
+ *     and dnsChecked
+ */
+export async function analyzeAuthentication(base64Content) {
+    const apis = await getTauriApis();
+    if (!apis) {
+        throw new Error('Sender authentication can only be checked in the desktop app');
+    }
+
+    return await apis.invoke('analyze_authentication', { data: base64Content });
+}
+
+/**
+ * Check the links and Reply-To of a message for signs of phishing (Tauri only): link texts
+ * showing another domain than the link goes to, look-alike or punycode domains, data URI
+ * links and a Reply-To of another domain than the sender
+ * @param {string} base64Content - The .eml or .msg file as base64
+ * @returns {Promise<Object>} Report with senderDomain, replyTo, links, findings
+ *     ({kind, severity, description, target, text}) and risk ('high', 'medium' or 'none')
+ */
+export async function analyzePhishing(base64Content) {
+    const apis = await getTauriApis();
+    if (!apis) {
+        throw new Error('Links can only be checked in the desktop app');
+    }
+
+    return await apis.invoke('analyze_phishing', { data: base64Content });
+}
+
+/**
+ * Analyze the Received headers of a message (Tauri only)
+ * @param {string} base64Content - The .eml or .msg file as base64
+ * @returns {Promise<{sent: number|null, hops: Array<Object>, totalDelay: number|null}>} Hops
+ *     in delivery order with from, fromIp, by, with, id, date, delay (ms) and anomalies
+ */
+export async function analyzeDeliveryPath(base64Content) {
+    const apis = await getTauriApis();
+    if (!apis) {
+        throw new Error('The delivery path can only be analyzed in the desktop app');
+    }
+
+    return await apis.invoke('analyze_delivery_path', { data: base64Content });
+}
+
+/**
+ * Downscale an image attachment in the backend (Tauri only). Decodes JPEG, PNG, GIF, BMP,
+ * TIFF and WebP, applies the EXIF orientation and caches the result.
+ * @param {string} base64Content - The image as base64 (without data: prefix)
+ * @param {number} size - Largest width and height of the thumbnail in pixels
+ * @returns {Promise<{dataUrl: string, width: number, height: number, originalWidth: number,
+ *     originalHeight: number}>}
+ */
+export async function generateThumbnail(base64Content, size) {
+    const apis = await getTauriApis();
+    if (!apis) {
+        throw new Error('Thumbnails can only be generated in the desktop app');
+    }
+
+    return await apis.invoke('generate_thumbnail', { data: base64Content, size });
+}
+
+/**
+ * SHA-256 and MD5 of an attachment and the verdict of the antivirus scanner configured in
+ * scanner.json (ClamAV's clamd or AMSI on Windows). Verdicts are cached by the backend,
+ * which also refuses to open, save or drag out attachments the scanner blocks (Tauri only).
+ * @param {string} base64Content - The attachment as base64 (without data: prefix)
+ * @param {string} fileName - Attachment name, passed to the scanner
+ * @returns {Promise<{sha256: string, md5: string, size: number, scan: {status: string,
+ *     engine: string|null, threat: string|null, error: string|null, blocked: boolean}}>}
+ *     status is clean, infected, failed or unscanned (no scanner configured)
+ */
+export async function checkAttachment(base64Content, fileName) {
+    const apis = await getTauriApis();
+    if (!apis) {
+        throw new Error('Attachments can only be checked in the desktop app');
+    }
+
+    return await apis.invoke('check_attachment', { data: base64Content, fileName });
+}
+
+/**
+ * Read the meeting request, response or cancellation of a message (Tauri only)
+ * @param {string} base64Content - The .eml or .msg file as base64
+ * @returns {Promise<Object|null>} Meeting with method, uid, summary, location, start, end
+ *     (ms), allDay, floating, timeZone, organizer and attendees, null for other messages
+ */
+export async function parseMeeting(base64Content) {
+    const apis = await getTauriApis();
+    if (!apis) {
+        throw new Error('Meetings can only be read in the desktop app');
+    }
+
+    return await apis.invoke('parse_meeting', { data: base64Content });
+}
+
+/**
+ * Save the meeting of a message as an .ics file (Tauri only)
+ * @param {string} base64Content - The .eml or .msg file as base64
+ * @param {string|null} [path] - Target file; without one a save dialog is shown
+ * @returns {Promise<string|null>} Path of the saved file, null if cancelled
+ */
+export async function exportToIcs(base64Content, path = null) {
+    const apis = await getTauriApis();
+    if (!apis) {
+        throw new Error('Meetings can only be exported in the desktop app');
+    }
+
+    return await apis.invoke('export_to_ics', { data: base64Content, path });
+}
+
+/**
+ * Reply to, reply all or forward a message with the default mail client (Tauri only)
+ * @param {string} base64Content - The .eml or .msg file as base64
+ * @param {string} mode - 'reply', 'replyAll' or 'forward'
+ * @param {string} [handoff='draft'] - 'draft' opens an unsent .eml with the quoted HTML
+ *     and attachments in the composer; 'mailto' opens a mailto: URL with the quoted text,
+ *     shortened to fit, and no attachments
+ * @returns {Promise<void>}
+ */
+export async function replyViaDefaultClient(base64Content, mode, handoff = 'draft') {
+    const apis = await getTauriApis();
+    if (!apis) {
+        throw new Error('Replies can only be handed to a mail client in the desktop app');
+    }
+
+    await apis.invoke('reply_via_default_client', { data: base64Content, mode, handoff });
+}
+
+/**
+ * Read the contact of an Outlook contact (.msg of class IPM.Contact) (Tauri only)
+ * @param {string} base64Content - The .msg file as base64
+ * @returns {Promise<Object|null>} Contact with displayName, name parts, company, emails,
+ *     phones, addresses, birthday (ms) and photo (data URL), null for other messages
+ */
+export async function parseContact(base64Content) {
+    const apis = await getTauriApis();
+    if (!apis) {
+        throw new Error('Contacts can only be read in the desktop app');
+    }
+
+    return await apis.invoke('parse_contact', { data: base64Content });
+}
+
+/**
+ * Save an Outlook contact as a vCard 4.0 .vcf file (Tauri only)
+ * @param {string} base64Content - The .msg file as base64
+ * @param {string|null} [path] - Target file; without one a save dialog is shown
+ * @returns {Promise<string|null>} Path of the saved file, null if cancelled
+ */
+export async function exportToVcf(base64Content, path = null) {
+    const apis = await getTauriApis();
+    if (!apis) {
+        throw new Error('Contacts can only be exported in the desktop app');
+    }
+
+    return await apis.invoke('export_to_vcf', { data: base64Content, path });
+}
+
+/**
+ * Every MAPI property of an .msg file, for looking into what a message really contains
+ * (Tauri only)
+ * @param {string} base64Content - The .msg file as base64
+ * @returns {Promise<Object>} The message as {properties, recipients, attachments, embedded};
+ *     recipients and attachments have properties of their own, and attachments that are
+ *     attached messages have them as embedded. Each property has tag, id, typeName, name,
+ *     named ({guid, setName, lid, name} for ids from 0x8000), value (times as ISO 8601,
+ *     binary data as hex, arrays for multi-valued properties), description, size and
+ *     truncated
+ */
+export async function getMapiProperties(base64Content) {
+    const apis = await getTauriApis();
+    if (!apis) {
+        throw new Error('MAPI properties can only be read in the desktop app');
+    }
+
+    return await apis.invoke('get_mapi_properties', { data: base64Content });
+}
+
+/**
+ * Print a message through the OS print dialog or straight to a printer (Tauri only)
+ * @param {string} html - The message as a complete HTML document
+ * @param {string} text - The message as text, printed when a printer is given
+ * @param {{header?: boolean, fileName?: string, printer?: string|null}} [options] -
+ *     header prints the file name and page numbers on each page
+ * @returns {Promise<void>}
+ */
+export async function printMessage(html, text, options = {}) {
+    const apis = await getTauriApis();
+    if (!apis) {
+        throw new Error('Printing is only available in the desktop app');
+    }
+
+    await apis.invoke('print_message', { html, text, options });
+}
+
+/**
+ * List the installed printers (Tauri only)
+ * @returns {Promise<string[]>} Printer names, empty outside the desktop app
+ */
+export async function listPrinters() {
+    const apis = await getTauriApis();
+    if (!apis) return [];
+
+    return await apis.invoke('list_printers');
+}
+
+/**
+ * List installed export plugins (Tauri only)
+ * @returns {Promise<Array<{id: string, name: string, extension: string, mimeType: string}>>}
+ */
+export async function listExportPlugins() {
+    const apis = await getTauriApis();
+    if (!apis) return [];
+
+    try {
+        return await apis.invoke('list_export_plugins');
+    } catch (error) {
+        console.error('Failed to list export plugins:', error);
+        return [];
+    }
+}
+
+/**
+ * Run an export plugin with a parsed message (Tauri only)
+ * @param {string} pluginId - Plugin id from its manifest
+ * @param {Object} messageData - JSON-serializable message (see messageToJson)
+ * @returns {Promise<Uint8Array>} Plugin output
+ */
+export async function runExportPlugin(pluginId, messageData) {
+    const apis = await getTauriApis();
+    if (!apis) {
+        throw new Error('Export plugins are only available in Tauri');
+    }
+
+    const base64 = await apis.invoke('run_export_plugin', {
+        pluginId,
+        messageJson: JSON.stringify(messageData),
+    });
+    const binary = atob(base64);
+    const bytes = new Uint8Array(binary.length);
+    for (let i = 0; i < binary.length; i++) {
+        bytes[i] = binary.charCodeAt(i);
+    }
+    return bytes;
+}
+
+/**
+ * Run the user's configured hook commands for an event (Tauri only)
+ * @param {string} event - Event name (message-opened, batch-export-finished)
+ * @param {Object<string, string>} variables - Template variables for the commands
+ * @returns {Promise<number>} Number of started commands
+ */
+export async function runEventHooks(event, variables) {
+    const apis = await getTauriApis();
+    if (!apis) return 0;
+
+    return await apis.invoke('run_event_hooks', { event, variables });
+}
+
+/**
+ * Send a JSON notification to the webhook configured in webhook.json (Tauri only)
+ * @param {Object} payload - JSON-serializable payload
+ * @returns {Promise<boolean>} True if delivered, false if no webhook is configured
+ */
+export async function sendWebhookNotification(payload) {
+    const apis = await getTauriApis();
+    if (!apis) return false;
+
+    return await apis.invoke('send_webhook_notification', {
+        payloadJson: JSON.stringify(payload),
+    });
+}
+
+/**
+ * Check whether a translation service is configured in translation.json (Tauri only)
+ * @returns {Promise<{available: boolean, provider: string|null, defaultTargetLang: string|null}>}
+ */
+export async function getTranslationStatus() {
+    const unavailable = { available: false, provider: null, defaultTargetLang: null };
+    const apis = await getTauriApis();
+    if (!apis) return unavailable;
+
+    try {
+        return await apis.invoke('get_translation_status');
+    } catch (error) {
+        console.error('Failed to read translation settings:', error);
+        return unavailable;
+    }
+}
+
+/**
+ * Translate plain text with the configured service (Tauri only).
+ * The API key stays in the backend.
+ * @param {string} text - Text to translate
+ * @param {string} targetLang - Target language code, e.g. "de"
+ * @param {string|null} [sourceLang] - Source language code, detected if omitted
+ * @returns {Promise<{text: string, detectedSourceLang: string|null}>}
+ */
+export async function translateText(text, targetLang, sourceLang = null) {
+    const apis = await getTauriApis();
+    if (!apis) {
+        throw new Error('Translation is only available in the desktop app');
+    }
+
+    return await apis.invoke('translate_text', {
+        text,
+        targetLang,
+        sourceLang,
+    });
+}
+
+/**
+ * List the voices available for reading aloud (Tauri only)
+ * @returns {Promise<Array<{id: string, name: string, language: string}>>}
+ */
+export async function listSpeechVoices() {
     const apis = await getTauriApis();
     if (!apis) return [];
 
-    return await apis.invoke('get_pending_files');
+    try {
+        return await apis.invoke('list_speech_voices');
+    } catch (error) {
+        console.error('Failed to list speech voices:', error);
+        return [];
+    }
 }
 
 /**
- * Listen for file open events from Tauri
- * @param {function(string): void} callback - Called with file path
+ * Read text aloud with an OS voice, replacing current speech (Tauri only)
+ * @param {string} text - Text to read
+ * @param {string} [voice] - Voice id from listSpeechVoices, OS default if empty
+ * @returns {Promise<void>}
+ */
+export async function speak(text, voice = '') {
+    const apis = await getTauriApis();
+    if (!apis) {
+        throw new Error('Reading aloud is only available in the desktop app');
+    }
+
+    await apis.invoke('speak', {
+        text,
+        voice: voice || null,
+    });
+}
+
+/**
+ * Stop reading aloud (Tauri only)
+ * @returns {Promise<boolean>} True if something was being read
+ */
+export async function stopSpeaking() {
+    const apis = await getTauriApis();
+    if (!apis) return false;
+
+    return await apis.invoke('stop_speaking');
+}
+
+/**
+ * Listen for speech that finished on its own (Tauri only)
+ * @param {function(): void} callback - Called when reading aloud ends
  * @returns {Promise<function(): void>} Unlisten function
  */
-export async function onFileOpen(callback) {
+export async function onSpeechEnded(callback) {
     const apis = await getTauriApis();
     if (!apis) return () => {};
 
-    return await apis.listen('file-open', (event) => {
-        if (event.payload) {
-            callback(event.payload);
-        }
-    });
+    return await apis.listen('speech-ended', () => callback());
 }
 
 /**
- * Extract filename from a file path
- * @param {string} filePath - Full file path
- * @returns {string} Just the filename
+ * Get version, build and platform details plus third-party license notices.
+ * Outside Tauri only the version shown in the page is known.
+ * @returns {Promise<{name: string, version: string, commit: string|null,
+ *     buildDate: string|null, platform: string, arch: string|null,
+ *     packaging: string|null, tauriVersion: string|null, thirdPartyNotices: Array<{ecosystem: string,
+ *     name: string, version: string, license: string|null, repository: string|null,
+ *     licenseText: string|null}>}>}
  */
-export function getFileName(filePath) {
-    // Handle both Windows and Unix paths
-    const parts = filePath.split(/[/\\]/);
-    return parts[parts.length - 1];
+export async function getAppInfo() {
+    const apis = await getTauriApis();
+    if (!apis) {
+        const versionTag =
+            typeof document !== 'undefined' ? document.querySelector('.version-tag') : null;
+        return {
+            name: 'msgReader',
+            version: versionTag?.textContent.trim() || '',
+            commit: null,
+            buildDate: null,
+            platform: 'web',
+            arch: null,
+            packaging: null,
+            tauriVersion: null,
+            thirdPartyNotices: [],
+        };
+    }
+
+    return await apis.invoke('get_app_info');
 }
 
 /**
- * Open a file with the system's default application (Tauri only)
- * Saves the file to a temp location and opens it
- * @param {string} base64Data - Base64 data URL (data:mime/type;base64,...)
- * @param {string} fileName - Original filename
- * @returns {Promise<void>}
+ * Get the settings overridden with MSGREADER_* environment variables or command-line
+ * flags. Outside Tauri nothing is overridden.
+ * @returns {Promise<{dataDir: string|null, logLevel: string|null, offline: boolean,
+ *     readOnly: boolean, kiosk: boolean}>}
  */
-export async function openWithSystemViewer(base64Data, fileName) {
-    if (!isTauri()) {
-        throw new Error('openWithSystemViewer is only available in Tauri');
+export async function getOverrides() {
+    const apis = await getTauriApis();
+    if (!apis) {
+        return {
+            dataDir: null,
+            logLevel: null,
+            offline: false,
+            readOnly: false,
+            kiosk: false,
+        };
     }
 
-    const { invoke } = await import('@tauri-apps/api/core');
+    return await apis.invoke('get_overrides');
+}
 
-    // Extract the base64 content (remove data:mime/type;base64, prefix)
-    const base64Content = base64Data.split(',')[1];
+/**
+ * Get the administrative policies of this machine. Outside Tauri no policy is set.
+ * @returns {Promise<{disableAutoUpdate: boolean, blockExternalContent: boolean,
+ *     dataDir: string|null, kioskMode: boolean}>}
+ */
+export async function getPolicy() {
+    const apis = await getTauriApis();
+    if (!apis) {
+        return {
+            disableAutoUpdate: false,
+            blockExternalContent: false,
+            dataDir: null,
+            kioskMode: false,
+        };
+    }
 
-    // Call Rust command to save and open the file
-    await invoke('open_file_with_system', {
-        base64Content,
-        fileName,
+    return await apis.invoke('get_policy');
+}
+
+/**
+ * Get the profile of this session (Tauri only)
+ * @returns {Promise<{name: string|null, selected: boolean}|null>} Null outside Tauri
+ */
+export async function getProfile() {
+    const apis = await getTauriApis();
+    if (!apis) return null;
+
+    return await apis.invoke('get_profile');
+}
+
+/**
+ * Switch the backend to another profile, e.g. for its temp directory (Tauri only)
+ * @param {string|null} name - Profile name, null for the default profile
+ * @returns {Promise<void>}
+ */
+export async function setProfile(name) {
+    const apis = await getTauriApis();
+    if (!apis) return;
+
+    await apis.invoke('set_profile', {
+        name: name || null,
     });
 }
 
 /**
- * Save a file with a "Save As" dialog (Tauri only)
- * @param {string} base64Data - Base64 data URL (data:mime/type;base64,...)
- * @param {string} fileName - Suggested filename
- * @returns {Promise<boolean>} True if file was saved, false if user cancelled
+ * Read a secret from the OS keychain (Tauri only)
+ * @param {string} account - Entry name
+ * @returns {Promise<string|null>} Secret, null if there is none or outside Tauri
  */
-export async function saveFileWithDialog(base64Data, fileName) {
-    if (!isTauri()) {
-        throw new Error('saveFileWithDialog is only available in Tauri');
+export async function getStoredSecret(account) {
+    const apis = await getTauriApis();
+    if (!apis) return null;
+
+    return await apis.invoke('get_stored_secret', { account });
+}
+
+/**
+ * Store a secret in the OS keychain (Tauri only)
+ * @param {string} account - Entry name
+ * @param {string} secret - Secret to store
+ * @returns {Promise<boolean>} False outside Tauri
+ */
+export async function storeSecret(account, secret) {
+    const apis = await getTauriApis();
+    if (!apis) return false;
+
+    await apis.invoke('store_secret', { account, secret });
+    return true;
+}
+
+/**
+ * Remove a secret from the OS keychain (Tauri only)
+ * @param {string} account - Entry name
+ * @returns {Promise<void>}
+ */
+export async function deleteStoredSecret(account) {
+    const apis = await getTauriApis();
+    if (!apis) return;
+
+    await apis.invoke('delete_stored_secret', { account });
+}
+
+/**
+ * Show a bundled help page in the help window (Tauri only)
+ * @param {string} topic - One of HELP_TOPICS values
+ * @param {string} [language] - UI language for the window title, the system's by default
+ * @returns {Promise<boolean>} False outside Tauri, where the caller opens the page itself
+ */
+export async function showHelp(topic, language = null) {
+    const apis = await getTauriApis();
+    if (!apis) return false;
+
+    await apis.invoke('show_help', { topic, language });
+    return true;
+}
+
+/**
+ * Read the OS locale (Tauri only)
+ * @returns {Promise<string>} BCP 47 tag, e.g. 'de-DE'
+ */
+export async function getSystemLocale() {
+    const apis = await getTauriApis();
+    if (!apis) {
+        throw new Error('The system locale can only be read in the desktop app');
     }
 
-    const { invoke } = await import('@tauri-apps/api/core');
+    return await apis.invoke('get_system_locale');
+}
 
-    // Extract the base64 content (remove data:mime/type;base64, prefix)
-    const base64Content = base64Data.split(',')[1];
+/**
+ * Load the bundled UI strings of a language (Tauri only)
+ * @param {string} [language] - Language or locale, e.g. 'de' or 'fr-CA'; the system's by
+ *     default
+ * @returns {Promise<{language: string, messages: Object<string, string>}>} The catalog's
+ *     language ('en' if there is none for the request) and its strings, with English for
+ *     missing ones
+ */
+export async function getTranslations(language = null) {
+    const apis = await getTauriApis();
+    if (!apis) {
+        throw new Error('Translations can only be loaded from the desktop app');
+    }
 
-    // Call Rust command to show save dialog and save file
-    return await invoke('save_file_with_dialog', {
-        base64Content,
-        fileName,
+    return await apis.invoke('get_translations', { language });
+}
+
+/**
+ * Enable or disable the local automation endpoint (Tauri only)
+ * @param {boolean} enabled - Whether requests are accepted
+ * @returns {Promise<string|null>} Socket or pipe path, null outside Tauri
+ */
+export async function setAutomationEnabled(enabled) {
+    const apis = await getTauriApis();
+    if (!apis) return null;
+
+    return await apis.invoke('set_automation_enabled', { enabled });
+}
+
+/**
+ * Listen for requests arriving on the automation endpoint (Tauri only)
+ * @param {Function} callback - Called with {requestId, command, params}
+ * @returns {Promise<Function>} Unlisten function
+ */
+export async function onAutomationRequest(callback) {
+    const apis = await getTauriApis();
+    if (!apis) return () => {};
+
+    return await apis.listen('automation-request', (event) => {
+        if (event.payload) {
+            callback(event.payload);
+        }
     });
 }
 
+/**
+ * Answer an automation request (Tauri only)
+ * @param {number} requestId - Id from the request event
+ * @param {*} result - JSON-serializable result
+ * @param {string|null} [error] - Error message, sent instead of the result
+ */
+export async function respondAutomationRequest(requestId, result, error = null) {
+    const apis = await getTauriApis();
+    if (!apis) return;
+
+    await apis.invoke('automation_respond', {
+        requestId,
+        result: error ? null : result,
+        error,
+    });
+}
+
+/**
+ * Whether msgReader is the default app for .msg and .eml files (Tauri only)
+ * @returns {Promise<Array<{extension: string, isDefault: boolean, handler: string|null}>>}
+ *     One entry per extension, empty outside Tauri
+ */
+export async function getFileAssociationStatus() {
+    const apis = await getTauriApis();
+    if (!apis) return [];
+
+    return await apis.invoke('get_file_association_status');
+}
+
+/**
+ * Open the OS settings page for default apps (Tauri only)
+ * @returns {Promise<boolean>} False if the OS has no such page (macOS, Linux)
+ */
+export async function openDefaultAppsSettings() {
+    const apis = await getTauriApis();
+    if (!apis) return false;
+
+    return await apis.invoke('open_default_apps_settings');
+}
+
+/**
+ * Make msgReader the default app for .msg and .eml files (Tauri only). Only Linux lets
+ * apps do this; the user's choice is verified with `xdg-mime query default`.
+ * @returns {Promise<boolean>} False if the OS leaves the choice to the user (Windows, macOS)
+ */
+export async function makeDefaultApp() {
+    const apis = await getTauriApis();
+    if (!apis) return false;
+
+    return await apis.invoke('make_default_app');
+}
+
+/**
+ * Remove the file associations of msgReader (Tauri only): on Windows the ProgIDs,
+ * extension keys and "Open with" entries of a per-user installation, on Linux the
+ * .desktop file, MIME types and icons written by makeDefaultApp
+ * @returns {Promise<boolean>} False if the app has nothing of its own to remove; the
+ *     installer or package does it on uninstall
+ */
+export async function unregisterFileAssociations() {
+    const apis = await getTauriApis();
+    if (!apis) return false;
+
+    return await apis.invoke('unregister_file_associations');
+}
+
+/**
+ * Get the latest log entries of the backend and the frontend (Tauri only)
+ * @param {number} [limit=200] - Maximum number of entries
+ * @returns {Promise<Array<{time: number, level: string, source: string, message: string}>>}
+ *     Oldest first, empty outside Tauri
+ */
+export async function getRecentLogs(limit = 200) {
+    const apis = await getTauriApis();
+    if (!apis) return [];
+
+    return await apis.invoke('get_recent_logs', { limit });
+}
+
+/**
+ * Set the minimum level of messages written to the log files (Tauri only)
+ * @param {string} level - debug, info, warning, error or critical
+ * @returns {Promise<void>}
+ */
+export async function setLogLevel(level) {
+    const apis = await getTauriApis();
+    if (!apis) return;
+
+    await apis.invoke('set_log_level', { level });
+}
+
+/**
+ * Add a frontend message to the log files (Tauri only); failures are ignored
+ * @param {string} level - debug, info, warning, error or critical
+ * @param {string} message
+ * @returns {Promise<void>}
+ */
+export async function writeLog(level, message) {
+    const apis = await getTauriApis();
+    if (!apis) return;
+
+    try {
+        await apis.invoke('write_log', { level, message });
+    } catch {
+        // Logging must never cause errors of its own
+    }
+}
+
+/**
+ * Show the folder with the log files in the file manager (Tauri only)
+ * @returns {Promise<void>}
+ */
+export async function openLogFolder() {
+    const apis = await getTauriApis();
+    if (!apis) {
+        throw new Error('Log files are only written by the desktop app');
+    }
+
+    await apis.invoke('open_log_folder');
+}
+
 /**
  * Parses a release-style semantic version from tags and git-describe strings.
  * Examples: "1.8.0", "v1.8.0", "v1.8.0-4-g5e7b327b"
@@ -182,41 +2287,96 @@ function getDisplayedAppVersion() {
     return document.querySelector('.version-tag')?.textContent?.trim() || '';
 }
 
+/**
+ * Check the GitHub releases for a newer version in the backend (Tauri only)
+ * @returns {Promise<{version: string, currentVersion: string, date: string|null,
+ *     notes: string}|null>} Null if the app is up to date or outside Tauri
+ */
+export async function findUpdate() {
+    const apis = await getTauriApis();
+    if (!apis) return null;
+
+    return await apis.invoke('check_for_updates');
+}
+
+/**
+ * Download the update found by findUpdate and verify its signature (Tauri only).
+ * Without a restart the update is installed when the app exits.
+ * @param {function({downloaded: number, total: number|null}): void} [onProgress]
+ * @returns {Promise<{version: string, size: number, sha256: string}>}
+ */
+export async function downloadUpdate(onProgress) {
+    const apis = await getTauriApis();
+    if (!apis) {
+        throw new Error('Updates are only available in the desktop app');
+    }
+
+    const unlisten = onProgress
+        ? await apis.listen('update-download-progress', (event) => onProgress(event.payload))
+        : () => {};
+    try {
+        return await apis.invoke('download_update');
+    } finally {
+        unlisten();
+    }
+}
+
+/**
+ * Install the downloaded update now and restart the app with the new version (Tauri only)
+ * @returns {Promise<boolean>} False if no update was downloaded
+ */
+export async function installUpdateAndRestart() {
+    const apis = await getTauriApis();
+    if (!apis) return false;
+
+    return await apis.invoke('install_update', { restart: true });
+}
+
 /**
  * Check for app updates and prompt user to install
+ * @param {Object} [options]
+ * @param {function({downloaded: number, total: number|null}): void} [options.onProgress] -
+ *     Called while the update downloads
  * @returns {Promise<void>}
  */
-export async function checkForUpdates() {
+export async function checkForUpdates(options = {}) {
     if (!isTauri()) return;
 
     try {
-        const { check } = await import('@tauri-apps/plugin-updater');
         const { ask } = await import('@tauri-apps/plugin-dialog');
 
-        const update = await check();
+        // The backend honors proxy.json / system proxy settings for the check and the download
+        const update = await findUpdate();
+        if (!update) return;
 
-        if (update) {
-            const currentVersion = getDisplayedAppVersion();
-            if (isCurrentVersionAtLeastUpdate(currentVersion, update.version)) {
-                return;
-            }
+        const currentVersion = getDisplayedAppVersion();
+        if (isCurrentVersionAtLeastUpdate(currentVersion, update.version)) {
+            return;
+        }
 
-            const yes = await ask(
-                `Version ${update.version} is available!\n\nWould you like to update now?`,
-                {
-                    title: 'Update available',
-                    kind: 'info',
-                    okLabel: 'Update',
-                    cancelLabel: 'Later',
-                }
-            );
+        const yes = await ask(
+            `Version ${update.version} is available!\n\nWould you like to update now?`,
+            {
+                title: 'Update available',
+                kind: 'info',
+                okLabel: 'Update',
+                cancelLabel: 'Later',
+            }
+        );
+        if (!yes) return;
 
-            if (yes) {
-                await update.downloadAndInstall();
-                // Restart the app after update
-                const { relaunch } = await import('@tauri-apps/plugin-process');
-                await relaunch();
+        await downloadUpdate(options.onProgress);
+        const restart = await ask(
+            `Version ${update.version} has been downloaded.\n\nRestart now to finish the update? Otherwise it is installed when you close the app.`,
+            {
+                title: 'Update ready',
+                kind: 'info',
+                okLabel: 'Restart',
+                cancelLabel: 'Later',
             }
+        );
+        if (restart) {
+            await installUpdateAndRestart();
         }
     } catch (error) {
         console.error('Update check failed:', error);
@@ -263,3 +2423,18 @@ export async function onFileDrop(callbacks) {
         }
     });
 }
+
+/**
+ * Expand paths dropped onto the window (Tauri only): folders are searched for .msg/.eml
+ * files with their subfolders, dropped files are kept if the app opens them, and files
+ * reached twice are listed once
+ * @param {string[]} paths - Dropped files and folders
+ * @returns {Promise<{files: string[], folders: number, skipped: number, duplicates: number}>}
+ *     Files in drop order; outside Tauri the paths as they are
+ */
+export async function expandDroppedPaths(paths) {
+    const apis = await getTauriApis();
+    if (!apis) return { files: paths, folders: 0, skipped: 0, duplicates: 0 };
+
+    return await apis.invoke('expand_dropped_paths', { paths });
+}
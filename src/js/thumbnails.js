@@ -0,0 +1,59 @@
+/**
+ * Attachment Thumbnails
+ * Small previews of image attachments for the attachment list. In the desktop app the
+ * backend decodes and downscales the images, which is much faster and lighter than letting
+ * the webview decode every full size photo of a message, and also reads formats the webview
+ * cannot show, such as TIFF.
+ */
+
+import { generateThumbnail, isTauri } from './tauri-bridge.js';
+import { getDataUrlBase64 } from './encoding.js';
+import { PREVIEWABLE_IMAGE_TYPES } from './constants.js';
+
+/** Edge of the thumbnails in the attachment list: 40 CSS pixels at twice the density */
+export const THUMBNAIL_SIZE = 80;
+
+/** @type {WeakMap<Object, Map<number, Promise<string|null>>>} */
+const thumbnails = new WeakMap();
+
+/**
+ * Whether the webview can show an attachment as it is
+ * @param {Object} attachment - Attachment with attachMimeTag and contentBase64
+ * @returns {boolean}
+ */
+function isDisplayable(attachment) {
+    const mimeType = (attachment.attachMimeTag || '').toLowerCase();
+    return Boolean(attachment.contentBase64) && PREVIEWABLE_IMAGE_TYPES.includes(mimeType);
+}
+
+/**
+ * Thumbnail of an image attachment. The backend generates it once per attachment and size;
+ * outside the desktop app, or for images the backend cannot decode, the attachment itself
+ * is used if the webview can show it.
+ * @param {Object} attachment - Attachment with attachMimeTag and contentBase64
+ * @param {number} [size] - Largest width and height in pixels
+ * @returns {Promise<string|null>} Data URL, or null to keep the file icon
+ */
+export function getAttachmentThumbnail(attachment, size = THUMBNAIL_SIZE) {
+    const fallback = isDisplayable(attachment) ? attachment.contentBase64 : null;
+    if (!isTauri() || !attachment.contentBase64) {
+        return Promise.resolve(fallback);
+    }
+
+    if (!thumbnails.has(attachment)) {
+        thumbnails.set(attachment, new Map());
+    }
+    const bySize = thumbnails.get(attachment);
+    if (!bySize.has(size)) {
+        bySize.set(
+            size,
+            generateThumbnail(getDataUrlBase64(attachment.contentBase64), size)
+                .then((thumbnail) => thumbnail.dataUrl)
+                .catch((err) => {
+                    console.warn(`No thumbnail for ${attachment.fileName}:`, err);
+                    return fallback;
+                })
+        );
+    }
+    return bySize.get(size);
+}
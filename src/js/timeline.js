@@ -0,0 +1,152 @@
+/**
+ * Timeline Module
+ * Buckets messages by hour, day or week into series data for activity charts,
+ * optionally narrowed to one sender or a search query.
+ */
+
+import { SearchManager } from './SearchManager.js';
+
+export const TIMELINE_BUCKETS = {
+    HOUR: 'hour',
+    DAY: 'day',
+    WEEK: 'week'
+};
+
+const HOUR_MS = 60 * 60 * 1000;
+const DAY_MS = 24 * HOUR_MS;
+
+/** Upper bound on returned buckets so a stray date cannot produce a huge series */
+export const MAX_TIMELINE_BUCKETS = 10000;
+
+/**
+ * Returns the start of the bucket containing a date. Weeks start on Monday.
+ * @param {Date} date - Date to bucket
+ * @param {string} bucket - One of TIMELINE_BUCKETS
+ * @param {boolean} utc - Bucket in UTC instead of local time
+ * @returns {Date} Bucket start
+ */
+export function getBucketStart(date, bucket, utc = false) {
+    const start = new Date(date.getTime());
+    if (utc) {
+        start.setUTCMinutes(0, 0, 0);
+        if (bucket !== TIMELINE_BUCKETS.HOUR) start.setUTCHours(0);
+        if (bucket === TIMELINE_BUCKETS.WEEK) {
+            start.setUTCDate(start.getUTCDate() - ((start.getUTCDay() + 6) % 7));
+        }
+    } else {
+        start.setMinutes(0, 0, 0);
+        if (bucket !== TIMELINE_BUCKETS.HOUR) start.setHours(0);
+        if (bucket === TIMELINE_BUCKETS.WEEK) {
+            start.setDate(start.getDate() - ((start.getDay() + 6) % 7));
+        }
+    }
+    return start;
+}
+
+/**
+ * Returns the start of the following bucket. Uses calendar arithmetic so
+ * local-time buckets stay aligned across daylight saving changes.
+ * @param {Date} start - Bucket start
+ * @param {string} bucket - One of TIMELINE_BUCKETS
+ * @param {boolean} utc - Bucket in UTC instead of local time
+ * @returns {Date} Next bucket start
+ */
+function getNextBucketStart(start, bucket, utc) {
+    if (bucket === TIMELINE_BUCKETS.HOUR) {
+        return new Date(start.getTime() + HOUR_MS);
+    }
+
+    const next = new Date(start.getTime());
+    const days = bucket === TIMELINE_BUCKETS.WEEK ? 7 : 1;
+    if (utc) {
+        next.setUTCDate(next.getUTCDate() + days);
+    } else {
+        next.setDate(next.getDate() + days);
+    }
+    return next;
+}
+
+/**
+ * Filters messages for the timeline
+ * @param {Array<Object>} messages - Messages
+ * @param {Object} filter - Filter options
+ * @param {string} [filter.sender] - Sender email address (case-insensitive)
+ * @param {string} [filter.query] - Search query, same syntax as the search box
+ * @returns {Array<Object>} Matching messages
+ */
+export function filterTimelineMessages(messages, { sender = '', query = '' } = {}) {
+    let result = messages;
+
+    const senderEmail = sender.trim().toLowerCase();
+    if (senderEmail) {
+        result = result.filter(
+            (message) => (message.senderEmail || '').toLowerCase() === senderEmail
+        );
+    }
+
+    if (query.trim()) {
+        result = new SearchManager({ getMessages: () => result }).search(query);
+    }
+
+    return result;
+}
+
+/**
+ * Aggregates messages into a timeline series
+ * @param {Array<Object>} messages - Messages with `timestamp` dates
+ * @param {Object} [options] - Aggregation options
+ * @param {string} [options.bucket='day'] - One of TIMELINE_BUCKETS
+ * @param {string} [options.sender] - Only count messages from this address
+ * @param {string} [options.query] - Only count messages matching this search query
+ * @param {boolean} [options.utc=false] - Bucket in UTC instead of local time
+ * @returns {{bucket: string, total: number, undated: number, from: string|null,
+ *     to: string|null, series: Array<{start: string, count: number}>}}
+ * @throws {Error} For unknown bucket sizes or ranges exceeding MAX_TIMELINE_BUCKETS
+ */
+export function buildTimeline(messages, options = {}) {
+    const { bucket = TIMELINE_BUCKETS.DAY, utc = false } = options;
+    if (!Object.values(TIMELINE_BUCKETS).includes(bucket)) {
+        throw new Error(`Unknown timeline bucket: ${bucket}`);
+    }
+
+    const matching = filterTimelineMessages(messages, options);
+    const dates = matching
+        .map((message) => message.timestamp)
+        .filter((date) => date instanceof Date && !isNaN(date.getTime()));
+
+    const result = {
+        bucket,
+        total: matching.length,
+        undated: matching.length - dates.length,
+        from: null,
+        to: null,
+        series: []
+    };
+    if (dates.length === 0) return result;
+
+    const counts = new Map();
+    dates.forEach((date) => {
+        const key = getBucketStart(date, bucket, utc).getTime();
+        counts.set(key, (counts.get(key) || 0) + 1);
+    });
+
+    const keys = [...counts.keys()];
+    const first = new Date(keys.reduce((min, key) => Math.min(min, key), Infinity));
+    const last = new Date(keys.reduce((max, key) => Math.max(max, key), -Infinity));
+    const bucketMs = { hour: HOUR_MS, day: DAY_MS, week: 7 * DAY_MS }[bucket];
+    if ((last - first) / bucketMs + 1 > MAX_TIMELINE_BUCKETS) {
+        throw new Error('Timeline range is too large for this bucket size');
+    }
+
+    // Include empty buckets so the series can be charted directly
+    for (let start = first; start <= last; start = getNextBucketStart(start, bucket, utc)) {
+        result.series.push({
+            start: start.toISOString(),
+            count: counts.get(start.getTime()) || 0
+        });
+    }
+
+    result.from = result.series[0].start;
+    result.to = getNextBucketStart(last, bucket, utc).toISOString();
+    return result;
+}
@@ -0,0 +1,290 @@
+/**
+ * TNEF Module
+ * Decodes winmail.dat (application/ms-tnef) attachments. Outlook sends them for
+ * messages in "Rich Text" format, and the real attachments and the message body are
+ * packed inside (MS-OXTNEF). The parsers replace such an attachment with its content.
+ * A winmail.dat file opened on its own is wrapped in a message first.
+ */
+
+import { arrayBufferToBase64, decodeBytes } from './encoding.js';
+
+export const TNEF_SIGNATURE = 0x223e9f78;
+
+/** MIME types and file name used for TNEF attachments */
+export const TNEF_MIME_TYPES = ['application/ms-tnef', 'application/vnd.ms-tnef'];
+export const TNEF_FILE_NAME = 'winmail.dat';
+
+const LEVEL_MESSAGE = 1;
+const LEVEL_ATTACHMENT = 2;
+
+/** TNEF attribute ids (low word of the attribute tag) */
+const ATTRIBUTE = {
+    BODY: 0x800c,
+    MAPI_PROPS: 0x9003,
+    ATTACH_REND_DATA: 0x9002,
+    ATTACH_DATA: 0x800f,
+    ATTACH_TITLE: 0x8010,
+    ATTACHMENT: 0x9005
+};
+
+/** MAPI property ids */
+const PROPERTY = {
+    BODY: 0x1000,
+    RTF_COMPRESSED: 0x1009,
+    BODY_HTML: 0x1013,
+    ATTACH_DATA: 0x3701,
+    ATTACH_FILENAME: 0x3704,
+    ATTACH_LONG_FILENAME: 0x3707,
+    ATTACH_MIME_TAG: 0x370e,
+    ATTACH_CONTENT_ID: 0x3712
+};
+
+/** MAPI property types */
+const TYPE = {
+    OBJECT: 0x000d,
+    STRING8: 0x001e,
+    UNICODE: 0x001f,
+    BINARY: 0x0102,
+    MULTI_VALUE: 0x1000
+};
+
+const VARIABLE_TYPES = [TYPE.OBJECT, TYPE.STRING8, TYPE.UNICODE, TYPE.BINARY];
+
+/** Sizes of fixed-length property values in a TNEF stream (padded to 4 bytes) */
+const FIXED_SIZES = {
+    0x0001: 4, // PT_NULL
+    0x0002: 4, // PT_SHORT
+    0x0003: 4, // PT_LONG
+    0x0004: 4, // PT_FLOAT
+    0x0005: 8, // PT_DOUBLE
+    0x0006: 8, // PT_CURRENCY
+    0x0007: 8, // PT_APPTIME
+    0x000a: 4, // PT_ERROR
+    0x000b: 4, // PT_BOOLEAN
+    0x0014: 8, // PT_I8
+    0x0040: 8, // PT_SYSTIME
+    0x0048: 16 // PT_CLSID
+};
+
+/**
+ * @param {number} length
+ * @returns {number} Length rounded up to a multiple of 4
+ */
+function padded(length) {
+    return (length + 3) & ~3;
+}
+
+/**
+ * @param {Uint8Array} bytes
+ * @returns {DataView}
+ */
+function viewOf(bytes) {
+    return new DataView(bytes.buffer, bytes.byteOffset, bytes.byteLength);
+}
+
+/**
+ * Decodes a NUL-terminated 8-bit string
+ * @param {Uint8Array} bytes
+ * @returns {string}
+ */
+function cString(bytes) {
+    const end = bytes.indexOf(0);
+    return decodeBytes(end === -1 ? bytes : bytes.subarray(0, end)).trim();
+}
+
+/**
+ * @param {{type: number, values: Uint8Array[]}} [property]
+ * @returns {string} String value, '' if the property is missing
+ */
+function propertyString(property) {
+    if (!property) return '';
+    const [value] = property.values;
+    if (property.type === TYPE.UNICODE) {
+        return decodeBytes(value, 'utf-16le').replace(/\0+$/, '');
+    }
+    return value.indexOf(0) === -1 ? decodeBytes(value) : cString(value);
+}
+
+/**
+ * @param {{type: number, values: Uint8Array[]}} [property]
+ * @returns {Uint8Array|null} Binary value, null if the property is missing
+ */
+function propertyBinary(property) {
+    return property?.type === TYPE.BINARY ? property.values[0] : null;
+}
+
+/**
+ * Reads a MAPI property list (attMAPIProps, attAttachment).
+ * Named properties are skipped, only standard properties are returned.
+ * @param {Uint8Array} bytes - Attribute value
+ * @returns {Map<number, {type: number, values: Uint8Array[]}>} Properties by id
+ */
+function readMapiProperties(bytes) {
+    const view = viewOf(bytes);
+    const properties = new Map();
+    let offset = 0;
+
+    const count = view.getUint32(offset, true);
+    offset += 4;
+
+    for (let i = 0; i < count; i++) {
+        const type = view.getUint16(offset, true);
+        const id = view.getUint16(offset + 2, true);
+        offset += 4;
+
+        const isNamed = id >= 0x8000;
+        if (isNamed) {
+            // GUID, kind, then a numeric id or a UTF-16 name
+            const kind = view.getUint32(offset + 16, true);
+            offset += 20;
+            offset += kind === 0 ? 4 : 4 + padded(view.getUint32(offset, true));
+        }
+
+        const baseType = type & ~TYPE.MULTI_VALUE;
+        const isVariable = VARIABLE_TYPES.includes(baseType);
+        let valueCount = 1;
+        if (isVariable || type & TYPE.MULTI_VALUE) {
+            valueCount = view.getUint32(offset, true);
+            offset += 4;
+        }
+
+        const values = [];
+        for (let v = 0; v < valueCount; v++) {
+            let size = FIXED_SIZES[baseType];
+            if (isVariable) {
+                size = view.getUint32(offset, true);
+                offset += 4;
+            } else if (!size) {
+                throw new Error(`Unsupported MAPI property type 0x${baseType.toString(16)}`);
+            }
+            if (offset + size > bytes.length) {
+                throw new Error('Truncated MAPI property');
+            }
+            values.push(bytes.subarray(offset, offset + size));
+            offset += isVariable ? padded(size) : size;
+        }
+
+        if (!isNamed) {
+            properties.set(id, { type: baseType, values });
+        }
+    }
+
+    return properties;
+}
+
+/**
+ * Checks for the TNEF signature
+ * @param {ArrayBuffer|Uint8Array} data
+ * @returns {boolean}
+ */
+export function isTnef(data) {
+    const bytes = data instanceof Uint8Array ? data : new Uint8Array(data);
+    return bytes.length >= 6 && viewOf(bytes).getUint32(0, true) === TNEF_SIGNATURE;
+}
+
+/**
+ * Checks whether a parsed attachment is a TNEF container
+ * @param {{fileName?: string, attachMimeTag?: string}} attachment
+ * @returns {boolean}
+ */
+export function isTnefAttachment(attachment) {
+    const mimeType = (attachment?.attachMimeTag || '').toLowerCase();
+    const fileName = (attachment?.fileName || '').toLowerCase();
+    return TNEF_MIME_TYPES.includes(mimeType) || fileName === TNEF_FILE_NAME;
+}
+
+/**
+ * Decodes TNEF data
+ * @param {ArrayBuffer|Uint8Array} data - Content of a winmail.dat attachment
+ * @returns {{bodyText: string, bodyHTML: string, compressedRtf: Uint8Array|null,
+ *     attachments: Array<{fileName: string, mimeType: string, contentId: string,
+ *     content: Uint8Array}>}} Body parts and attachments; embedded messages and OLE
+ *     objects are left out
+ * @throws {Error} If the data is not TNEF or is damaged
+ */
+export function decodeTnef(data) {
+    const bytes = data instanceof Uint8Array ? data : new Uint8Array(data);
+    if (!isTnef(bytes)) {
+        throw new Error('Not TNEF data');
+    }
+
+    const view = viewOf(bytes);
+    const result = { bodyText: '', bodyHTML: '', compressedRtf: null };
+    const attachments = [];
+    let attachment = null;
+
+    // Signature (4 bytes) and legacy key (2 bytes), then level, tag, length, value, checksum
+    let offset = 6;
+    while (offset + 9 <= bytes.length) {
+        const level = bytes[offset];
+        const id = view.getUint32(offset + 1, true) & 0xffff;
+        const length = view.getUint32(offset + 5, true);
+        const value = bytes.subarray(offset + 9, offset + 9 + length);
+        if (value.length !== length) {
+            throw new Error('Truncated TNEF data');
+        }
+        offset += 9 + length + 2;
+
+        if (level === LEVEL_MESSAGE) {
+            if (id === ATTRIBUTE.BODY) {
+                result.bodyText = cString(value);
+            } else if (id === ATTRIBUTE.MAPI_PROPS) {
+                const properties = readMapiProperties(value);
+                result.bodyHTML = propertyString(properties.get(PROPERTY.BODY_HTML));
+                result.compressedRtf = propertyBinary(properties.get(PROPERTY.RTF_COMPRESSED));
+                result.bodyText ||= propertyString(properties.get(PROPERTY.BODY));
+            }
+        } else if (level === LEVEL_ATTACHMENT) {
+            if (id === ATTRIBUTE.ATTACH_REND_DATA) {
+                // Every attachment starts with its rendering data
+                attachment = { fileName: '', mimeType: '', contentId: '', content: null };
+                attachments.push(attachment);
+            } else if (!attachment) {
+                continue;
+            } else if (id === ATTRIBUTE.ATTACH_TITLE) {
+                attachment.fileName = cString(value);
+            } else if (id === ATTRIBUTE.ATTACH_DATA) {
+                attachment.content = value;
+            } else if (id === ATTRIBUTE.ATTACHMENT) {
+                const properties = readMapiProperties(value);
+                attachment.fileName =
+                    propertyString(properties.get(PROPERTY.ATTACH_LONG_FILENAME)) ||
+                    attachment.fileName ||
+                    propertyString(properties.get(PROPERTY.ATTACH_FILENAME));
+                attachment.mimeType = propertyString(properties.get(PROPERTY.ATTACH_MIME_TAG));
+                attachment.contentId = propertyString(properties.get(PROPERTY.ATTACH_CONTENT_ID));
+                attachment.content ||= propertyBinary(properties.get(PROPERTY.ATTACH_DATA));
+            }
+        }
+    }
+
+    return { ...result, attachments: attachments.filter((item) => item.content) };
+}
+
+/**
+ * Wraps a winmail.dat file opened on its own in a message with it as the only attachment,
+ * so the EML parser unpacks it like one that came with a message
+ * @param {ArrayBuffer|Uint8Array} data - Content of the TNEF file
+ * @param {string} fileName - Name of the file, used as the subject
+ * @returns {ArrayBuffer} The message as an .eml file
+ */
+export function wrapTnefAsEml(data, fileName) {
+    const bytes = data instanceof Uint8Array ? data : new Uint8Array(data);
+    const boundary = 'tnef-file';
+    const subject = String(fileName || TNEF_FILE_NAME).replace(/[\r\n]/g, ' ');
+    const eml = [
+        `Subject: ${subject}`,
+        'MIME-Version: 1.0',
+        `Content-Type: multipart/mixed; boundary="${boundary}"`,
+        '',
+        `--${boundary}`,
+        `Content-Type: ${TNEF_MIME_TYPES[0]}; name="${TNEF_FILE_NAME}"`,
+        `Content-Disposition: attachment; filename="${TNEF_FILE_NAME}"`,
+        'Content-Transfer-Encoding: base64',
+        '',
+        ...(arrayBufferToBase64(bytes).match(/.{1,76}/g) || []),
+        `--${boundary}--`,
+        ''
+    ].join('\r\n');
+    return new TextEncoder().encode(eml).buffer;
+}
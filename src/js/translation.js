@@ -0,0 +1,56 @@
+/**
+ * Translation Module
+ * Translates the open message through the desktop backend, which holds the
+ * service endpoint and API key (see doc/translation.md).
+ */
+
+import { translateText } from './tauri-bridge.js';
+import { getMessagePlainText } from './messageExport.js';
+
+/**
+ * Picks the language to translate into: the configured default, otherwise the UI language
+ * @param {{defaultTargetLang?: string|null}} [status] - Result of getTranslationStatus
+ * @param {string} [uiLanguage] - BCP 47 language tag, defaults to navigator.language
+ * @returns {string} Lowercase language code, e.g. "de" or "en-gb"
+ */
+export function getTranslationTargetLang(status = {}, uiLanguage) {
+    if (status.defaultTargetLang) {
+        return status.defaultTargetLang.toLowerCase();
+    }
+
+    const language =
+        uiLanguage || (typeof navigator !== 'undefined' && navigator.language) || 'en';
+    // Services accept regional variants only for some languages, e.g. en-gb or pt-br
+    const [primary, region] = language.toLowerCase().split('-');
+    return ['en', 'pt'].includes(primary) && region ? `${primary}-${region}` : primary;
+}
+
+/**
+ * Translates subject and body of a message
+ * @param {Object} message - Parsed message
+ * @param {string} targetLang - Target language code
+ * @param {Function} [translate] - (text, targetLang) => Promise<{text, detectedSourceLang}>
+ * @returns {Promise<{subject: string, body: string, targetLang: string,
+ *     detectedSourceLang: string|null}>}
+ * @throws {Error} If the message has no text or the service fails
+ */
+export async function translateMessage(message, targetLang, translate = translateText) {
+    const subject = (message.subject || '').trim();
+    const body = getMessagePlainText(message);
+    if (!subject && !body) {
+        throw new Error('This message has no text to translate');
+    }
+
+    const [subjectResult, bodyResult] = await Promise.all([
+        subject ? translate(subject, targetLang) : null,
+        body ? translate(body, targetLang) : null
+    ]);
+
+    return {
+        subject: subjectResult?.text || '',
+        body: bodyResult?.text || '',
+        targetLang,
+        detectedSourceLang:
+            bodyResult?.detectedSourceLang || subjectResult?.detectedSourceLang || null
+    };
+}
@@ -0,0 +1,424 @@
+/**
+ * ArchiveBrowser UI Component
+ * Searches the local message archive (desktop app only) by text, folder and tag, opens
+ * archived messages and tags, moves, exports or removes the selected ones. Messages are
+ * stored with their original file, so they open and export without it.
+ */
+
+import {
+    deleteArchiveMessages,
+    exportArchiveMessages,
+    getArchiveLabels,
+    getArchiveMessages,
+    moveArchiveMessages,
+    setArchiveTags
+} from '../tauri-bridge.js';
+import { formatSize, getLocale } from '../i18n.js';
+import { escapeHTML } from '../sanitizer.js';
+
+/** Messages listed per page */
+export const ARCHIVE_PAGE_SIZE = 100;
+
+/** Delay before a typed search is run */
+const SEARCH_DELAY_MS = 300;
+
+export class ArchiveBrowser {
+    /**
+     * @param {HTMLElement} modalElement - #archiveBrowserModal
+     * @param {Object} callbacks
+     * @param {function({id: number, fileName: string}): void} callbacks.onOpen - Called with
+     *     the clicked entry
+     * @param {function(string): void} [callbacks.onInfo] - Called with a message after a change
+     * @param {function(string): void} [callbacks.onError] - Called with a message on failure
+     */
+    constructor(modalElement, { onOpen, onInfo = () => {}, onError = () => {} }) {
+        this.modal = modalElement;
+        this.onOpen = onOpen;
+        this.onInfo = onInfo;
+        this.onError = onError;
+        this.content = modalElement?.querySelector('.help-modal-content') || null;
+        this.title = modalElement?.querySelector('.help-modal-title') || null;
+        this.query = { text: '', folder: null, tag: null };
+        this.labels = { folders: [], tags: [] };
+        this.total = 0;
+        this.entries = [];
+        this.selected = new Set();
+        this.loading = false;
+        this.searchTimer = null;
+
+        this.handleKeyDown = this.handleKeyDown.bind(this);
+        const backdrop = this.modal?.querySelector('.help-modal-backdrop');
+        const closeBtn = this.modal?.querySelector('.help-modal-close');
+        backdrop?.addEventListener('click', () => this.close());
+        closeBtn?.addEventListener('click', () => this.close());
+        this.content?.addEventListener('click', (e) => this.handleClick(e));
+        this.content?.addEventListener('change', (e) => this.handleChange(e));
+        this.content?.addEventListener('input', (e) => this.handleInput(e));
+    }
+
+    /**
+     * Shows the archive with the previous filters
+     */
+    async open() {
+        if (!this.modal) return;
+
+        try {
+            await this.reload();
+        } catch (error) {
+            console.error('Failed to read archive:', error);
+            this.onError(`Failed to read archive: ${error}`);
+            return;
+        }
+
+        this.modal.classList.add('active');
+        document.body.style.overflow = 'hidden';
+        document.addEventListener('keydown', this.handleKeyDown, true);
+        this.content?.querySelector('[data-archive-search]')?.focus();
+    }
+
+    /**
+     * Hides the modal
+     */
+    close() {
+        if (!this.modal) return;
+
+        this.modal.classList.remove('active');
+        document.body.style.overflow = '';
+        document.removeEventListener('keydown', this.handleKeyDown, true);
+        clearTimeout(this.searchTimer);
+        this.entries = [];
+        this.selected.clear();
+    }
+
+    /**
+     * Whether the modal is shown
+     * @returns {boolean}
+     */
+    isOpen() {
+        return Boolean(this.modal?.classList.contains('active'));
+    }
+
+    /**
+     * Closes on Escape before the global shortcuts see the key
+     * @param {KeyboardEvent} event
+     */
+    handleKeyDown(event) {
+        if (event.key === 'Escape') {
+            event.preventDefault();
+            event.stopPropagation();
+            this.close();
+        }
+    }
+
+    /**
+     * Fetches the folders and tags and the first page for the current filters
+     */
+    async reload() {
+        this.labels = await getArchiveLabels();
+        if (this.query.folder && !this.labels.folders.includes(this.query.folder)) {
+            this.query.folder = null;
+        }
+        if (this.query.tag && !this.labels.tags.includes(this.query.tag)) {
+            this.query.tag = null;
+        }
+        this.entries = [];
+        this.total = 0;
+        this.selected.clear();
+        await this.loadMore(true);
+    }
+
+    /**
+     * Fetches and renders the next page of entries
+     * @param {boolean} [first=false] - Load the first page even if nothing is listed
+     */
+    async loadMore(first = false) {
+        if (this.loading || (!first && this.entries.length >= this.total)) return;
+
+        this.loading = true;
+        try {
+            const page = await getArchiveMessages(
+                this.query,
+                this.entries.length,
+                ARCHIVE_PAGE_SIZE
+            );
+            this.total = page.total;
+            this.entries.push(...page.entries);
+        } finally {
+            this.loading = false;
+        }
+        this.render();
+    }
+
+    /**
+     * Ids of the selected entries in list order
+     * @returns {number[]}
+     */
+    selectedIds() {
+        return this.entries.map((entry) => entry.id).filter((id) => this.selected.has(id));
+    }
+
+    /**
+     * Asks for tags and replaces those of the selected messages
+     */
+    async tagSelected() {
+        const ids = this.selectedIds();
+        if (ids.length === 0) return;
+
+        const current = this.entries.find((entry) => entry.id === ids[0])?.tags || [];
+        const input = window.prompt('Tags, separated by commas:', current.join(', '));
+        if (input === null) return;
+
+        await setArchiveTags(ids, input.split(','));
+        await this.reload();
+    }
+
+    /**
+     * Asks for a folder and moves the selected messages there
+     */
+    async moveSelected() {
+        const ids = this.selectedIds();
+        if (ids.length === 0) return;
+
+        const input = window.prompt('Move to folder (empty for none):', this.query.folder || '');
+        if (input === null) return;
+
+        await moveArchiveMessages(ids, input.trim());
+        await this.reload();
+    }
+
+    /**
+     * Saves the original files of the selected messages to a folder the user chooses
+     */
+    async exportSelected() {
+        const ids = this.selectedIds();
+        if (ids.length === 0) return;
+
+        const results = await exportArchiveMessages(ids);
+        if (!results) return;
+
+        const failed = results.filter((result) => result.error);
+        if (failed.length === 0) {
+            this.onInfo(`${results.length} messages exported`);
+        } else {
+            failed.forEach((result) => console.error(`${result.fileName}: ${result.error}`));
+            this.onError(`${failed.length} of ${results.length} messages could not be exported`);
+        }
+    }
+
+    /**
+     * Removes the selected messages from the archive after asking
+     */
+    async deleteSelected() {
+        const ids = this.selectedIds();
+        if (ids.length === 0) return;
+
+        const confirmed = window.confirm(
+            `Remove ${ids.length} messages from the archive? This cannot be undone.`
+        );
+        if (!confirmed) return;
+
+        const removed = await deleteArchiveMessages(ids);
+        this.onInfo(`${removed} messages removed from the archive`);
+        await this.reload();
+    }
+
+    /**
+     * Renders the filters, the loaded entries and the selection controls
+     */
+    render() {
+        if (!this.content) return;
+
+        if (this.title) {
+            this.title.textContent = `Archive (${this.total})`;
+        }
+
+        const option = (value, label, selected) => `
+            <option value="${escapeHTML(value)}" ${selected ? 'selected' : ''}>
+                ${escapeHTML(label)}
+            </option>`;
+        const folders = [
+            option('*', 'All folders', this.query.folder === null),
+            option('', 'No folder', this.query.folder === ''),
+            ...this.labels.folders.map((folder) =>
+                option(folder, folder, this.query.folder === folder)
+            )
+        ].join('');
+        const tags = [
+            option('', 'All tags', !this.query.tag),
+            ...this.labels.tags.map((tag) => option(tag, tag, this.query.tag === tag))
+        ].join('');
+
+        const rows = this.entries
+            .map((entry) => {
+                const sender = entry.senderName || entry.senderEmail;
+                const meta = [
+                    sender,
+                    entry.date ? new Date(entry.date).toLocaleString(getLocale()) : '',
+                    entry.folder,
+                    formatSize(entry.size)
+                ].filter(Boolean);
+                const checked = this.selected.has(entry.id) ? 'checked' : '';
+                return `
+                <li class="mbox-entry">
+                    <input type="checkbox" data-archive-select="${entry.id}" ${checked}
+                           aria-label="Select message">
+                    <button type="button" class="folder-entry" data-archive-entry="${entry.id}"
+                            title="${escapeHTML(entry.fileName)}">
+                        <span class="folder-entry-subject">
+                            ${escapeHTML(entry.subject || '(no subject)')}
+                        </span>
+                        <span class="folder-entry-meta">
+                            ${meta.map((part) => `<span>${escapeHTML(part)}</span>`).join('')}
+                            ${entry.tags
+                                .map((tag) => `<span class="archive-tag">${escapeHTML(tag)}</span>`)
+                                .join('')}
+                        </span>
+                    </button>
+                </li>`;
+            })
+            .join('');
+
+        const remaining = this.total - this.entries.length;
+        this.content.innerHTML = `
+            <div class="archive-filters">
+                <input type="search" data-archive-search placeholder="Search archive"
+                       aria-label="Search archive" value="${escapeHTML(this.query.text)}">
+                <select data-archive-folder aria-label="Folder">${folders}</select>
+                <select data-archive-tag aria-label="Tag">${tags}</select>
+            </div>
+            <div class="mbox-actions">${this.renderActions()}</div>
+            ${
+                this.entries.length === 0
+                    ? '<p class="usage-stats-note">No archived messages found.</p>'
+                    : `<ul class="folder-entries">${rows}</ul>`
+            }
+            ${
+                remaining > 0
+                    ? `<button class="help-modal-close-btn" data-action="archive-more">
+                           Show ${Math.min(remaining, ARCHIVE_PAGE_SIZE)} more of ${remaining}
+                       </button>`
+                    : ''
+            }`;
+    }
+
+    /**
+     * Selection buttons
+     * @returns {string} HTML
+     */
+    renderActions() {
+        const allSelected = this.entries.length > 0 && this.selected.size === this.entries.length;
+        const disabled = this.selected.size === 0 ? 'disabled' : '';
+        return `
+            <button class="help-modal-close-btn" data-action="archive-select-all">
+                ${allSelected ? 'Select none' : 'Select all loaded'}
+            </button>
+            <button class="help-modal-close-btn" data-action="archive-tag" ${disabled}>
+                Tags…
+            </button>
+            <button class="help-modal-close-btn" data-action="archive-move" ${disabled}>
+                Move…
+            </button>
+            <button class="help-modal-close-btn" data-action="archive-export" ${disabled}>
+                Export ${this.selected.size || ''}…
+            </button>
+            <button class="help-modal-close-btn" data-action="archive-delete" ${disabled}>
+                Remove
+            </button>`;
+    }
+
+    /**
+     * Runs a search shortly after typing stops
+     * @param {Event} e
+     */
+    handleInput(e) {
+        if (!e.target.matches('[data-archive-search]')) return;
+
+        clearTimeout(this.searchTimer);
+        this.searchTimer = setTimeout(() => {
+            this.query.text = e.target.value;
+            this.reloadKeepingFocus();
+        }, SEARCH_DELAY_MS);
+    }
+
+    /**
+     * Reloads after a filter change and puts the focus back into the search field
+     */
+    async reloadKeepingFocus() {
+        try {
+            await this.reload();
+        } catch (error) {
+            console.error('Failed to read archive:', error);
+            this.onError(`Failed to read archive: ${error}`);
+        }
+        const search = this.content?.querySelector('[data-archive-search]');
+        if (search && this.query.text) {
+            search.focus();
+            search.setSelectionRange(search.value.length, search.value.length);
+        }
+    }
+
+    /**
+     * Applies a folder or tag filter, or tracks the selection of a message. For the
+     * selection only the buttons are redrawn, so the checkbox keeps the focus.
+     * @param {Event} e
+     */
+    handleChange(e) {
+        if (e.target.matches('[data-archive-folder]')) {
+            this.query.folder = e.target.value === '*' ? null : e.target.value;
+            this.reloadKeepingFocus();
+            return;
+        }
+        if (e.target.matches('[data-archive-tag]')) {
+            this.query.tag = e.target.value || null;
+            this.reloadKeepingFocus();
+            return;
+        }
+
+        const checkbox = e.target.closest('[data-archive-select]');
+        if (!checkbox) return;
+
+        const id = Number(checkbox.dataset.archiveSelect);
+        if (checkbox.checked) {
+            this.selected.add(id);
+        } else {
+            this.selected.delete(id);
+        }
+        const actions = this.content.querySelector('.mbox-actions');
+        if (actions) actions.innerHTML = this.renderActions();
+    }
+
+    /**
+     * Opens a clicked message or runs an action on the selection
+     * @param {MouseEvent} e
+     */
+    async handleClick(e) {
+        const entry = e.target.closest('[data-archive-entry]');
+        if (entry) {
+            const id = Number(entry.dataset.archiveEntry);
+            this.onOpen(this.entries.find((item) => item.id === id));
+            return;
+        }
+
+        const action = e.target.closest('[data-action]')?.dataset.action;
+        try {
+            if (action === 'archive-select-all') {
+                const allSelected = this.selected.size === this.entries.length;
+                this.selected = new Set(allSelected ? [] : this.entries.map((item) => item.id));
+                this.render();
+            } else if (action === 'archive-tag') {
+                await this.tagSelected();
+            } else if (action === 'archive-move') {
+                await this.moveSelected();
+            } else if (action === 'archive-export') {
+                await this.exportSelected();
+            } else if (action === 'archive-delete') {
+                await this.deleteSelected();
+            } else if (action === 'archive-more') {
+                await this.loadMore();
+            }
+        } catch (error) {
+            console.error('Failed to change archive:', error);
+            this.onError(`Failed to change archive: ${error}`);
+        }
+    }
+}
@@ -1,8 +1,17 @@
-import { isTauri, openWithSystemViewer, saveFileWithDialog } from '../tauri-bridge.js';
+import { isTauri } from '../tauri-bridge.js';
 import { extractEml } from '../utils.js';
 import { dataUrlToArrayBuffer, decodeDataUrlText, getDataUrlBase64 } from '../encoding.js';
 import { formatContact, getContactEmail } from '../addressUtils.js';
 import { pdfAttachmentsOpenInApp } from '../UserPreferences.js';
+import { kioskMode } from '../kioskMode.js';
+import { getScanBlockMessage } from '../attachmentScan.js';
+import { formatSize } from '../i18n.js';
+import {
+    isDeferredAttachment,
+    loadAttachmentContent,
+    openAttachmentWithSystem,
+    saveAttachmentWithDialog
+} from '../largeMessage.js';
 
 /**
  * Manages the attachment preview modal
@@ -47,6 +56,7 @@ export class AttachmentModalManager {
         this.imageViewerFallbackHeight = 720;
         this.inlineImageMetadataBySource = new Map();
         this._pdfPreviewObjectUrl = null;
+        this._deferredPreview = null;
 
         // Navigation stack for nested content (e.g., attachments within nested emails)
         this.navigationStack = [];
@@ -264,19 +274,21 @@ export class AttachmentModalManager {
      * @param {Object} attachment - Attachment object with contentBase64 and fileName
      */
     async downloadAttachment(attachment) {
+        if (kioskMode.isEnabled()) {
+            this.showToast?.('Saving files is disabled in kiosk mode', 'warning');
+            return;
+        }
+
         if (isTauri()) {
             try {
-                const saved = await saveFileWithDialog(
-                    attachment.contentBase64,
-                    attachment.fileName
-                );
+                const saved = await saveAttachmentWithDialog(attachment);
                 if (saved && this.showToast) {
                     this.showToast('File saved successfully', 'info');
                 }
             } catch (error) {
                 console.error('Failed to save file:', error);
                 if (this.showToast) {
-                    this.showToast('Failed to save file', 'error');
+                    this.showToast(getScanBlockMessage(error) || 'Failed to save file', 'error');
                 }
             }
         } else {
@@ -386,7 +398,8 @@ export class AttachmentModalManager {
     _shouldOpenPdfExternally(attachment) {
         return (
             this.isPdf(attachment.attachMimeTag, attachment.fileName) &&
-            !pdfAttachmentsOpenInApp()
+            !pdfAttachmentsOpenInApp() &&
+            !kioskMode.isEnabled()
         );
     }
 
@@ -396,10 +409,10 @@ export class AttachmentModalManager {
      */
     _openPdfExternally(attachment) {
         if (isTauri()) {
-            openWithSystemViewer(attachment.contentBase64, attachment.fileName).catch((err) => {
+            openAttachmentWithSystem(attachment).catch((err) => {
                 console.error('Failed to open PDF with system viewer:', err);
                 if (this.showToast) {
-                    this.showToast('Failed to open PDF', 'error');
+                    this.showToast(getScanBlockMessage(err) || 'Failed to open PDF', 'error');
                 }
             });
             return;
@@ -469,6 +482,7 @@ export class AttachmentModalManager {
      * @param {Object} attachment - Attachment object to render
      */
     renderAttachmentPreview(attachment) {
+        this._deferredPreview = null;
         this.resetImagePreviewState();
         this._revokePdfPreviewObjectUrl();
 
@@ -486,6 +500,12 @@ export class AttachmentModalManager {
         this.attachmentModalContent.innerHTML = '';
         this.attachmentModalContent.classList.remove('attachment-modal-content--image');
 
+        if (isDeferredAttachment(attachment)) {
+            this.renderDeferredPreview(attachment);
+            this.updateNavButtons();
+            return;
+        }
+
         // Render appropriate preview
         if (this.isPreviewableImage(attachment.attachMimeTag)) {
             this.renderImagePreview(attachment);
@@ -519,6 +539,33 @@ export class AttachmentModalManager {
         this.updateNavButtons();
     }
 
+    /**
+     * Shows a loading note while the content of an attachment of a large message is read
+     * from the backend, then renders the preview unless another attachment was shown
+     * in the meantime
+     * @param {Object} attachment - Attachment without content yet
+     */
+    renderDeferredPreview(attachment) {
+        const status = document.createElement('div');
+        status.className = 'text-center p-4 text-gray-500';
+        status.textContent = `Loading ${attachment.fileName}…`;
+        this.attachmentModalContent.appendChild(status);
+        this._deferredPreview = attachment;
+
+        loadAttachmentContent(attachment)
+            .then(() => {
+                if (this._deferredPreview === attachment) {
+                    this.renderAttachmentPreview(attachment);
+                }
+            })
+            .catch((err) => {
+                console.error('Failed to read attachment:', err);
+                if (this._deferredPreview === attachment) {
+                    status.textContent = 'Unable to preview this file';
+                }
+            });
+    }
+
     /**
      * Creates an object URL for a PDF attachment
      * @param {Object} attachment - PDF attachment object
@@ -1144,9 +1191,7 @@ export class AttachmentModalManager {
      */
     formatFileSize(bytes) {
         if (!bytes) return '';
-        if (bytes < 1024) return bytes + ' B';
-        if (bytes < 1024 * 1024) return (bytes / 1024).toFixed(1) + ' KB';
-        return (bytes / (1024 * 1024)).toFixed(1) + ' MB';
+        return formatSize(bytes);
     }
 
     /**
@@ -0,0 +1,215 @@
+/**
+ * ComparisonModal UI Component
+ * Shows how two copies of a message differ (desktop app only), e.g. an original and its
+ * journaled or forwarded copy. The diff itself is computed by the backend.
+ */
+
+import { formatSize } from '../i18n.js';
+import { escapeHTML } from '../sanitizer.js';
+
+/** Labels of the attachment states of the backend diff */
+const ATTACHMENT_STATUS_LABELS = {
+    same: 'Same',
+    renamed: 'Renamed',
+    changed: 'Changed',
+    removed: 'Only in A',
+    added: 'Only in B'
+};
+
+/** Prefixes of the body diff lines, as in a unified diff */
+const LINE_PREFIXES = { same: ' ', removed: '-', added: '+' };
+
+export class ComparisonModal {
+    /**
+     * @param {HTMLElement} modalElement - #comparisonModal
+     */
+    constructor(modalElement) {
+        this.modal = modalElement;
+        this.content = modalElement?.querySelector('.help-modal-content') || null;
+
+        this.handleKeyDown = this.handleKeyDown.bind(this);
+        const backdrop = this.modal?.querySelector('.help-modal-backdrop');
+        const closeBtn = this.modal?.querySelector('.help-modal-close');
+        backdrop?.addEventListener('click', () => this.close());
+        closeBtn?.addEventListener('click', () => this.close());
+    }
+
+    /**
+     * Renders a diff and shows the modal
+     * @param {Object} diff - Result of compareMessages
+     * @param {string} nameA - Label of the first message
+     * @param {string} nameB - Label of the second message
+     */
+    open(diff, nameA, nameB) {
+        if (!this.modal) return;
+
+        this.render(diff, nameA, nameB);
+        this.modal.classList.add('active');
+        document.body.style.overflow = 'hidden';
+        document.addEventListener('keydown', this.handleKeyDown, true);
+        this.modal.querySelector('.help-modal-close')?.focus();
+    }
+
+    /**
+     * Hides the modal
+     */
+    close() {
+        if (!this.modal) return;
+
+        this.modal.classList.remove('active');
+        document.body.style.overflow = '';
+        document.removeEventListener('keydown', this.handleKeyDown, true);
+    }
+
+    /**
+     * Whether the modal is shown
+     * @returns {boolean}
+     */
+    isOpen() {
+        return Boolean(this.modal?.classList.contains('active'));
+    }
+
+    /**
+     * Closes on Escape before the global shortcuts see the key
+     * @param {KeyboardEvent} event
+     */
+    handleKeyDown(event) {
+        if (event.key === 'Escape') {
+            event.preventDefault();
+            event.stopPropagation();
+            this.close();
+        }
+    }
+
+    /**
+     * Builds a section of values that differ, A above B
+     * @param {string} title - Section heading
+     * @param {Array<{name: string, a: string, b: string}>} rows
+     * @returns {string} HTML, empty if nothing differs
+     */
+    renderFields(title, rows) {
+        if (rows.length === 0) return '';
+
+        const items = rows
+            .map(
+                (row) => `
+                <div class="comparison-field">
+                    <dt>${escapeHTML(row.name)}</dt>
+                    <dd class="comparison-removed">${escapeHTML(row.a) || '<em>none</em>'}</dd>
+                    <dd class="comparison-added">${escapeHTML(row.b) || '<em>none</em>'}</dd>
+                </div>`
+            )
+            .join('');
+        return `
+            <div class="help-section">
+                <h3>${escapeHTML(title)}</h3>
+                <dl>${items}</dl>
+            </div>`;
+    }
+
+    /**
+     * @param {Array<Object>} recipients - Recipients whose type differs
+     * @returns {string} HTML, empty if the recipients are the same
+     */
+    renderRecipients(recipients) {
+        if (recipients.length === 0) return '';
+
+        const items = recipients
+            .map((recipient) => {
+                const person = recipient.email
+                    ? `${recipient.name} <${recipient.email}>`.trim()
+                    : recipient.name;
+                const kindA = recipient.kindA?.toUpperCase() || 'not a recipient';
+                const kindB = recipient.kindB?.toUpperCase() || 'not a recipient';
+                return `<li>${escapeHTML(person)}: A ${kindA}, B ${kindB}</li>`;
+            })
+            .join('');
+        return `
+            <div class="help-section">
+                <h3>Recipients</h3>
+                <ul>${items}</ul>
+            </div>`;
+    }
+
+    /**
+     * @param {Array<Object>} hunks - Changed parts of the body
+     * @returns {string} HTML, empty if the bodies are the same
+     */
+    renderBody(hunks) {
+        if (hunks.length === 0) return '';
+
+        const blocks = hunks
+            .map((hunk) => {
+                const lines = hunk.lines
+                    .map(
+                        (line) =>
+                            `<span class="comparison-${line.kind}">` +
+                            `${LINE_PREFIXES[line.kind]} ${escapeHTML(line.text)}</span>`
+                    )
+                    .join('\n');
+                return `<pre class="comparison-hunk"><span class="comparison-hunk-header">` +
+                    `@@ A ${hunk.aStart}, B ${hunk.bStart} @@</span>\n${lines}</pre>`;
+            })
+            .join('');
+        return `
+            <div class="help-section">
+                <h3>Body</h3>
+                ${blocks}
+            </div>`;
+    }
+
+    /**
+     * @param {Array<Object>} attachments - All attachments of both messages
+     * @returns {string} HTML, empty if neither message has attachments
+     */
+    renderAttachments(attachments) {
+        if (attachments.length === 0) return '';
+
+        const describe = (info) =>
+            info
+                ? `${escapeHTML(info.fileName)} (${formatSize(info.size)}, ` +
+                  `<code title="SHA-256">${info.sha256.slice(0, 12)}</code>)`
+                : '';
+        const items = attachments
+            .map(
+                (attachment) => `
+                <div class="comparison-field">
+                    <dt>${ATTACHMENT_STATUS_LABELS[attachment.status]}</dt>
+                    <dd>${[describe(attachment.a), describe(attachment.b)]
+                        .filter(Boolean)
+                        .join(' → ')}</dd>
+                </div>`
+            )
+            .join('');
+        return `
+            <div class="help-section">
+                <h3>Attachments</h3>
+                <dl>${items}</dl>
+            </div>`;
+    }
+
+    /**
+     * Renders the diff into the modal content
+     * @param {Object} diff - Result of compareMessages
+     * @param {string} nameA
+     * @param {string} nameB
+     */
+    render(diff, nameA, nameB) {
+        if (!this.content) return;
+
+        const summary = diff.identical
+            ? 'The messages are identical.'
+            : 'Values of A are shown above those of B.';
+        this.content.innerHTML = `
+            <p class="usage-stats-note">
+                A: ${escapeHTML(nameA)}<br>
+                B: ${escapeHTML(nameB)}<br>
+                ${summary}
+            </p>
+            ${this.renderFields('Message', diff.fields)}
+            ${this.renderRecipients(diff.recipients)}
+            ${this.renderBody(diff.body)}
+            ${this.renderAttachments(diff.attachments)}
+            ${this.renderFields('Transport headers', diff.headers)}`;
+    }
+}
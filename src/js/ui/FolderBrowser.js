@@ -0,0 +1,195 @@
+/**
+ * FolderBrowser UI Component
+ * Lists the emails of a whole folder (desktop app only). The backend enumerates the
+ * files and reads only their headers, one page at a time; a message is parsed in full
+ * when it is clicked.
+ */
+
+import { closeFolder, getFolderPage, openFolder } from '../tauri-bridge.js';
+import { formatSize, getLocale } from '../i18n.js';
+import { escapeHTML } from '../sanitizer.js';
+
+/** Files summarized per page */
+export const FOLDER_PAGE_SIZE = 100;
+
+export class FolderBrowser {
+    /**
+     * @param {HTMLElement} modalElement - #folderBrowserModal
+     * @param {Object} callbacks
+     * @param {function(string): void} callbacks.onOpen - Called with the path of a clicked file
+     * @param {function(string): void} [callbacks.onError] - Called with a message if listing failed
+     */
+    constructor(modalElement, { onOpen, onError = () => {} }) {
+        this.modal = modalElement;
+        this.onOpen = onOpen;
+        this.onError = onError;
+        this.content = modalElement?.querySelector('.help-modal-content') || null;
+        this.title = modalElement?.querySelector('.help-modal-title') || null;
+        this.folder = null;
+        this.total = 0;
+        this.entries = [];
+        this.loading = false;
+
+        this.handleKeyDown = this.handleKeyDown.bind(this);
+        const backdrop = this.modal?.querySelector('.help-modal-backdrop');
+        const closeBtn = this.modal?.querySelector('.help-modal-close');
+        backdrop?.addEventListener('click', () => this.close());
+        closeBtn?.addEventListener('click', () => this.close());
+        this.content?.addEventListener('click', (e) => this.handleClick(e));
+    }
+
+    /**
+     * Lists a folder and shows the first page
+     * @param {string} folder - Folder path
+     * @param {boolean} [recursive=false] - Include subfolders
+     */
+    async open(folder, recursive = false) {
+        if (!this.modal) return;
+
+        try {
+            const listing = await openFolder(folder, recursive);
+            if (!listing) return;
+            if (listing.total === 0) {
+                await closeFolder(listing.folder);
+                this.onError('No emails found in this folder');
+                return;
+            }
+
+            if (this.folder && this.folder !== listing.folder) closeFolder(this.folder);
+            this.folder = listing.folder;
+            this.total = listing.total;
+            this.entries = [];
+            await this.loadMore();
+        } catch (error) {
+            console.error('Failed to open folder:', error);
+            this.onError('Failed to open folder');
+            return;
+        }
+
+        this.modal.classList.add('active');
+        document.body.style.overflow = 'hidden';
+        document.addEventListener('keydown', this.handleKeyDown, true);
+        this.modal.querySelector('.help-modal-close')?.focus();
+    }
+
+    /**
+     * Hides the modal and releases the file list in the backend
+     */
+    close() {
+        if (!this.modal) return;
+
+        this.modal.classList.remove('active');
+        document.body.style.overflow = '';
+        document.removeEventListener('keydown', this.handleKeyDown, true);
+
+        if (this.folder) {
+            closeFolder(this.folder);
+            this.folder = null;
+            this.entries = [];
+        }
+    }
+
+    /**
+     * Whether the modal is shown
+     * @returns {boolean}
+     */
+    isOpen() {
+        return Boolean(this.modal?.classList.contains('active'));
+    }
+
+    /**
+     * Closes on Escape before the global shortcuts see the key
+     * @param {KeyboardEvent} event
+     */
+    handleKeyDown(event) {
+        if (event.key === 'Escape') {
+            event.preventDefault();
+            event.stopPropagation();
+            this.close();
+        }
+    }
+
+    /**
+     * Fetches and renders the next page of summaries
+     */
+    async loadMore() {
+        if (this.loading || !this.folder || this.entries.length >= this.total) return;
+
+        this.loading = true;
+        try {
+            const page = await getFolderPage(this.folder, this.entries.length, FOLDER_PAGE_SIZE);
+            this.total = page.total;
+            this.entries.push(...page.entries);
+        } finally {
+            this.loading = false;
+        }
+        this.render();
+    }
+
+    /**
+     * Renders the loaded entries
+     */
+    render() {
+        if (!this.content) return;
+
+        if (this.title) {
+            this.title.textContent = `${this.folder} (${this.total})`;
+        }
+
+        const rows = this.entries
+            .map((entry, index) => {
+                const sender = entry.senderName || entry.senderEmail;
+                const date = entry.date ?? entry.modified;
+                const meta = [
+                    sender,
+                    date ? new Date(date).toLocaleString(getLocale()) : '',
+                    formatSize(entry.size)
+                ].filter(Boolean);
+                return `
+                <li>
+                    <button type="button" class="folder-entry" data-folder-entry="${index}"
+                            title="${escapeHTML(entry.error || entry.path)}">
+                        <span class="folder-entry-subject">
+                            ${escapeHTML(entry.subject || entry.name)}
+                        </span>
+                        <span class="folder-entry-meta">
+                            ${meta.map((part) => `<span>${escapeHTML(part)}</span>`).join('')}
+                        </span>
+                    </button>
+                </li>`;
+            })
+            .join('');
+
+        const remaining = this.total - this.entries.length;
+        this.content.innerHTML = `
+            <ul class="folder-entries">${rows}</ul>
+            ${
+                remaining > 0
+                    ? `<button class="help-modal-close-btn" data-action="folder-more">
+                           Show ${Math.min(remaining, FOLDER_PAGE_SIZE)} more of ${remaining}
+                       </button>`
+                    : ''
+            }`;
+    }
+
+    /**
+     * Opens a clicked entry or loads the next page
+     * @param {MouseEvent} e
+     */
+    async handleClick(e) {
+        const entry = e.target.closest('[data-folder-entry]');
+        if (entry) {
+            this.onOpen(this.entries[Number(entry.dataset.folderEntry)].path);
+            return;
+        }
+
+        if (e.target.closest('[data-action="folder-more"]')) {
+            try {
+                await this.loadMore();
+            } catch (error) {
+                console.error('Failed to list folder:', error);
+                this.onError('Failed to list folder');
+            }
+        }
+    }
+}
@@ -0,0 +1,453 @@
+/**
+ * ImapBrowser UI Component
+ * Browses an IMAP mailbox read-only (desktop app only), for audits without setting up a
+ * mail client. The backend logs in, keeps the password (in the OS credential store if
+ * the user chooses so) and opens folders with EXAMINE, so nothing on the server changes.
+ * Message lists only download headers; a message is downloaded when it is opened and
+ * then opens like an .eml file.
+ */
+
+import {
+    connectImapAccount,
+    forgetImapPassword,
+    getImapMessages,
+    hasImapPassword
+} from '../tauri-bridge.js';
+import { getImapAccounts, isImapAccount, setImapAccounts } from '../UserPreferences.js';
+import { formatSize, getLocale } from '../i18n.js';
+import { escapeHTML } from '../sanitizer.js';
+
+/** Messages listed per page */
+export const IMAP_PAGE_SIZE = 100;
+
+/** Port of IMAP over TLS */
+export const IMAPS_PORT = 993;
+
+/**
+ * Label of an account, e.g. `alice@imap.example.com`
+ * @param {{host: string, port: number, username: string}} account
+ * @returns {string}
+ */
+export function formatImapAccount(account) {
+    const port = account.port === IMAPS_PORT ? '' : `:${account.port}`;
+    return `${account.username}@${account.host}${port}`;
+}
+
+function sameAccount(a, b) {
+    return a.host === b.host && a.port === b.port && a.username === b.username;
+}
+
+export class ImapBrowser {
+    /**
+     * @param {HTMLElement} modalElement - #imapBrowserModal
+     * @param {Object} callbacks
+     * @param {function(Object, string, {uid: number, subject: string}): Promise<void>}
+     *     callbacks.onOpen - Called with the account, the folder and the message to open
+     * @param {function(string): void} [callbacks.onError] - Called with a message if
+     *     connecting or reading failed
+     */
+    constructor(modalElement, { onOpen, onError = () => {} }) {
+        this.modal = modalElement;
+        this.onOpen = onOpen;
+        this.onError = onError;
+        this.content = modalElement?.querySelector('.help-modal-content') || null;
+        this.title = modalElement?.querySelector('.help-modal-title') || null;
+        this.account = null;
+        this.folders = [];
+        this.folder = null;
+        this.entries = [];
+        this.total = 0;
+        this.selected = new Set();
+        this.loading = false;
+
+        this.handleKeyDown = this.handleKeyDown.bind(this);
+        const backdrop = this.modal?.querySelector('.help-modal-backdrop');
+        const closeBtn = this.modal?.querySelector('.help-modal-close');
+        backdrop?.addEventListener('click', () => this.close());
+        closeBtn?.addEventListener('click', () => this.close());
+        this.content?.addEventListener('click', (e) => this.handleClick(e));
+        this.content?.addEventListener('change', (e) => this.handleChange(e));
+        this.content?.addEventListener('submit', (e) => this.handleSubmit(e));
+    }
+
+    /**
+     * Shows the mailbox that was open last, or the saved accounts
+     */
+    open() {
+        if (!this.modal) return;
+
+        this.render();
+        this.modal.classList.add('active');
+        document.body.style.overflow = 'hidden';
+        document.addEventListener('keydown', this.handleKeyDown, true);
+        this.modal.querySelector('.help-modal-close')?.focus();
+    }
+
+    /**
+     * Hides the modal. The mailbox stays selected, so messages can still be picked after
+     * reopening the browser.
+     */
+    close() {
+        if (!this.modal) return;
+
+        this.modal.classList.remove('active');
+        document.body.style.overflow = '';
+        document.removeEventListener('keydown', this.handleKeyDown, true);
+    }
+
+    /**
+     * Whether the modal is shown
+     * @returns {boolean}
+     */
+    isOpen() {
+        return Boolean(this.modal?.classList.contains('active'));
+    }
+
+    /**
+     * Closes on Escape before the global shortcuts see the key
+     * @param {KeyboardEvent} event
+     */
+    handleKeyDown(event) {
+        if (event.key === 'Escape') {
+            event.preventDefault();
+            event.stopPropagation();
+            this.close();
+        }
+    }
+
+    /**
+     * Logs in and lists the folders; the account is saved once the login worked
+     * @param {{host: string, port: number, username: string}} account
+     * @param {?string} password - Null to use the password the backend knows
+     * @param {boolean} [remember=false] - Keep the password in the OS credential store
+     * @returns {Promise<boolean>} True if connected
+     */
+    async connect(account, password, remember = false) {
+        try {
+            this.folders = await connectImapAccount(account, password, remember);
+        } catch (error) {
+            console.error('Failed to connect to IMAP server:', error);
+            this.onError(`${error}`);
+            return false;
+        }
+
+        const others = getImapAccounts().filter((saved) => !sameAccount(saved, account));
+        setImapAccounts([account, ...others]);
+        this.account = account;
+        this.folder = null;
+        this.entries = [];
+        this.total = 0;
+        this.selected.clear();
+        this.render();
+
+        const inbox = this.folders.find((folder) => folder.selectable);
+        if (inbox) await this.selectFolder(inbox.name);
+        return true;
+    }
+
+    /**
+     * Connects to a saved account, asking for the password if the backend has none
+     * @param {{host: string, port: number, username: string}} account
+     */
+    async connectSaved(account) {
+        if (await hasImapPassword(account)) {
+            await this.connect(account, null);
+        } else {
+            this.render(account);
+            this.content?.querySelector('input[name="password"]')?.focus();
+        }
+    }
+
+    /**
+     * Removes a saved account and its password
+     * @param {{host: string, port: number, username: string}} account
+     */
+    async forget(account) {
+        await forgetImapPassword(account);
+        setImapAccounts(getImapAccounts().filter((saved) => !sameAccount(saved, account)));
+        if (this.account && sameAccount(this.account, account)) this.account = null;
+        this.render();
+    }
+
+    /**
+     * Shows the first page of a folder's messages
+     * @param {string} name - Folder name
+     */
+    async selectFolder(name) {
+        if (name === this.folder && this.loading) return;
+
+        this.folder = name;
+        this.entries = [];
+        this.total = 0;
+        this.selected.clear();
+        await this.loadMore(true);
+    }
+
+    /**
+     * Fetches and renders the next page of the selected folder. A page that arrives
+     * after another folder was selected is dropped.
+     * @param {boolean} [first=false] - First page of a newly selected folder
+     */
+    async loadMore(first = false) {
+        if (this.folder === null) return;
+        if (!first && (this.loading || this.entries.length >= this.total)) return;
+
+        const folder = this.folder;
+        this.loading = true;
+        this.render();
+        try {
+            const offset = this.entries.length;
+            const page = await getImapMessages(this.account, folder, offset, IMAP_PAGE_SIZE);
+            if (folder !== this.folder) return;
+            this.total = page.total;
+            this.entries.push(...page.entries);
+        } finally {
+            if (folder === this.folder) this.loading = false;
+        }
+        this.render();
+    }
+
+    /**
+     * Downloads and opens the selected messages, oldest first so the newest ends up shown
+     */
+    async openSelected() {
+        const entries = this.entries.filter((entry) => this.selected.has(entry.uid)).reverse();
+        this.close();
+        for (const entry of entries) {
+            await this.onOpen(this.account, this.folder, entry);
+        }
+        this.selected.clear();
+    }
+
+    /**
+     * Renders the mailbox, or the accounts if none is connected
+     * @param {Object} [account] - Account to fill in the login form with
+     */
+    render(account = null) {
+        if (!this.content) return;
+
+        if (this.account && !account) {
+            this.renderMailbox();
+        } else {
+            this.renderAccounts(account);
+        }
+    }
+
+    /**
+     * Renders the saved accounts and the login form
+     * @param {?Object} account - Account to fill in the form with
+     */
+    renderAccounts(account) {
+        if (this.title) this.title.textContent = 'IMAP Mailbox';
+
+        const accounts = getImapAccounts()
+            .map(
+                (saved, index) => `
+                <li class="mbox-entry">
+                    <button type="button" class="folder-entry" data-imap-account="${index}">
+                        <span class="folder-entry-subject">
+                            ${escapeHTML(formatImapAccount(saved))}
+                        </span>
+                    </button>
+                    <button type="button" class="help-modal-close-btn"
+                            data-imap-forget="${index}">Forget</button>
+                </li>`
+            )
+            .join('');
+
+        this.content.innerHTML = `
+            ${accounts ? `<ul class="folder-entries">${accounts}</ul>` : ''}
+            <form class="imap-login" autocomplete="off">
+                <input name="host" placeholder="Server (imap.example.com)" required
+                       value="${escapeHTML(account?.host || '')}" aria-label="Server">
+                <input name="port" type="number" min="1" max="65535" required
+                       value="${account?.port || IMAPS_PORT}" aria-label="Port">
+                <input name="username" placeholder="User name" required
+                       value="${escapeHTML(account?.username || '')}" aria-label="User name">
+                <input name="password" type="password" placeholder="Password" required
+                       aria-label="Password">
+                <label>
+                    <input name="remember" type="checkbox">
+                    Remember password in the system keychain
+                </label>
+                <button type="submit" class="help-modal-close-btn">Connect</button>
+            </form>
+            <p class="usage-stats-note">
+                Connects over TLS. Folders are opened read-only: messages stay unread and
+                nothing on the server is changed.
+            </p>`;
+    }
+
+    /**
+     * Renders the folders and the messages of the selected folder
+     */
+    renderMailbox() {
+        if (this.title) this.title.textContent = formatImapAccount(this.account);
+
+        const folders = this.folders
+            .map(
+                (folder) => `
+                <li>
+                    <button type="button"
+                            class="pst-folder ${folder.name === this.folder ? 'active' : ''}"
+                            data-imap-folder="${escapeHTML(folder.name)}"
+                            ${folder.selectable ? '' : 'disabled'}>
+                        <span>${escapeHTML(folder.displayName)}</span>
+                    </button>
+                </li>`
+            )
+            .join('');
+
+        const entries = this.entries
+            .map((entry, index) => {
+                const sender = entry.senderName || entry.senderEmail;
+                const meta = [
+                    sender,
+                    entry.date ? new Date(entry.date).toLocaleString(getLocale()) : '',
+                    formatSize(entry.size)
+                ].filter(Boolean);
+                const checked = this.selected.has(entry.uid) ? 'checked' : '';
+                return `
+                <li class="mbox-entry">
+                    <input type="checkbox" data-imap-select="${entry.uid}" ${checked}
+                           aria-label="Select message">
+                    <button type="button" class="folder-entry" data-imap-entry="${index}"
+                            title="${escapeHTML(entry.error || '')}">
+                        <span class="folder-entry-subject">
+                            ${escapeHTML(entry.subject || '(no subject)')}
+                        </span>
+                        <span class="folder-entry-meta">
+                            ${meta.map((part) => `<span>${escapeHTML(part)}</span>`).join('')}
+                        </span>
+                    </button>
+                </li>`;
+            })
+            .join('');
+
+        const remaining = this.total - this.entries.length;
+        let messagePane = '<p class="usage-stats-note">Choose a folder.</p>';
+        if (this.loading && this.entries.length === 0) {
+            messagePane = '<p class="usage-stats-note">Loading…</p>';
+        } else if (this.folder !== null) {
+            messagePane = this.total
+                ? `<ul class="folder-entries">${entries}</ul>`
+                : '<p class="usage-stats-note">This folder has no messages.</p>';
+        }
+
+        this.content.innerHTML = `
+            <div class="mbox-actions">${this.renderActions()}</div>
+            <div class="pst-browser">
+                <ul class="pst-folders">${folders}</ul>
+                <div class="pst-messages">
+                    ${messagePane}
+                    ${
+                        remaining > 0 && this.entries.length > 0
+                            ? `<button class="help-modal-close-btn" data-action="imap-more">
+                                   Show ${Math.min(remaining, IMAP_PAGE_SIZE)} more of ${remaining}
+                               </button>`
+                            : ''
+                    }
+                </div>
+            </div>`;
+    }
+
+    /**
+     * Selection and account buttons
+     * @returns {string} HTML
+     */
+    renderActions() {
+        const allSelected = this.entries.length > 0 && this.selected.size === this.entries.length;
+        return `
+            <button class="help-modal-close-btn" data-action="imap-select-all">
+                ${allSelected ? 'Select none' : 'Select all loaded'}
+            </button>
+            <button class="help-modal-close-btn" data-action="imap-open-selected"
+                    ${this.selected.size === 0 ? 'disabled' : ''}>
+                Open ${this.selected.size || ''} selected
+            </button>
+            <button class="help-modal-close-btn" data-action="imap-accounts">Accounts</button>`;
+    }
+
+    /**
+     * Tracks the selection of a message. Only the buttons are redrawn, so the checkbox
+     * keeps the focus.
+     * @param {Event} e
+     */
+    handleChange(e) {
+        const checkbox = e.target.closest('[data-imap-select]');
+        if (!checkbox) return;
+
+        const uid = Number(checkbox.dataset.imapSelect);
+        if (checkbox.checked) {
+            this.selected.add(uid);
+        } else {
+            this.selected.delete(uid);
+        }
+        const actions = this.content.querySelector('.mbox-actions');
+        if (actions) actions.innerHTML = this.renderActions();
+    }
+
+    /**
+     * Connects with the login form
+     * @param {SubmitEvent} e
+     */
+    async handleSubmit(e) {
+        const form = e.target.closest('.imap-login');
+        if (!form) return;
+        e.preventDefault();
+
+        const data = new FormData(form);
+        const account = {
+            host: String(data.get('host')).trim(),
+            port: Number(data.get('port')),
+            username: String(data.get('username')).trim()
+        };
+        if (!isImapAccount(account)) {
+            this.onError('Enter the server, port and user name');
+            return;
+        }
+        await this.connect(account, String(data.get('password')), data.get('remember') === 'on');
+    }
+
+    /**
+     * Connects to or forgets an account, selects a folder, opens messages or loads the
+     * next page
+     * @param {MouseEvent} e
+     */
+    async handleClick(e) {
+        const savedAccount = e.target.closest('[data-imap-account]');
+        const forget = e.target.closest('[data-imap-forget]');
+        const folder = e.target.closest('[data-imap-folder]');
+        const entry = e.target.closest('[data-imap-entry]');
+        const action = e.target.closest('[data-action]')?.dataset.action;
+
+        try {
+            if (savedAccount) {
+                const index = Number(savedAccount.dataset.imapAccount);
+                await this.connectSaved(getImapAccounts()[index]);
+            } else if (forget) {
+                await this.forget(getImapAccounts()[Number(forget.dataset.imapForget)]);
+            } else if (folder) {
+                await this.selectFolder(folder.dataset.imapFolder);
+            } else if (entry) {
+                this.close();
+                const message = this.entries[Number(entry.dataset.imapEntry)];
+                await this.onOpen(this.account, this.folder, message);
+            } else if (action === 'imap-select-all') {
+                const allSelected = this.selected.size === this.entries.length;
+                this.selected = new Set(allSelected ? [] : this.entries.map((item) => item.uid));
+                this.render();
+            } else if (action === 'imap-open-selected') {
+                await this.openSelected();
+            } else if (action === 'imap-accounts') {
+                this.account = null;
+                this.render();
+            } else if (action === 'imap-more') {
+                await this.loadMore();
+            }
+        } catch (error) {
+            console.error('Failed to read IMAP mailbox:', error);
+            this.onError(`Failed to read mailbox: ${error}`);
+        }
+    }
+}
@@ -0,0 +1,272 @@
+/**
+ * MboxBrowser UI Component
+ * Lists the messages of an mbox file (Thunderbird folders, Google Takeout exports;
+ * desktop app only). The backend splits the file and reads only the headers, one page at
+ * a time; a message is read in full when it is clicked or exported.
+ */
+
+import {
+    closeMboxFile,
+    exportMboxMessages,
+    getFileName,
+    getMboxMessages,
+    openMboxFile
+} from '../tauri-bridge.js';
+import { formatSize, getLocale } from '../i18n.js';
+import { escapeHTML } from '../sanitizer.js';
+
+/** Messages summarized per page */
+export const MBOX_PAGE_SIZE = 100;
+
+export class MboxBrowser {
+    /**
+     * @param {HTMLElement} modalElement - #mboxBrowserModal
+     * @param {Object} callbacks
+     * @param {function(string, {index: number, subject: string}): void} callbacks.onOpen -
+     *     Called with the file path and the clicked message
+     * @param {function(string): void} [callbacks.onInfo] - Called with a message after exporting
+     * @param {function(string): void} [callbacks.onError] - Called with a message if reading failed
+     */
+    constructor(modalElement, { onOpen, onInfo = () => {}, onError = () => {} }) {
+        this.modal = modalElement;
+        this.onOpen = onOpen;
+        this.onInfo = onInfo;
+        this.onError = onError;
+        this.content = modalElement?.querySelector('.help-modal-content') || null;
+        this.title = modalElement?.querySelector('.help-modal-title') || null;
+        this.path = null;
+        this.total = 0;
+        this.entries = [];
+        this.selected = new Set();
+        this.loading = false;
+
+        this.handleKeyDown = this.handleKeyDown.bind(this);
+        const backdrop = this.modal?.querySelector('.help-modal-backdrop');
+        const closeBtn = this.modal?.querySelector('.help-modal-close');
+        backdrop?.addEventListener('click', () => this.close());
+        closeBtn?.addEventListener('click', () => this.close());
+        this.content?.addEventListener('click', (e) => this.handleClick(e));
+        this.content?.addEventListener('change', (e) => this.handleChange(e));
+    }
+
+    /**
+     * Splits an mbox file and shows the first page
+     * @param {string} path - Path of the mbox file
+     */
+    async open(path) {
+        if (!this.modal) return;
+
+        try {
+            const listing = await openMboxFile(path);
+            if (listing.total === 0) {
+                await closeMboxFile(listing.path);
+                this.onError(`No messages found in ${getFileName(path)}`);
+                return;
+            }
+
+            if (this.path && this.path !== listing.path) closeMboxFile(this.path);
+            this.path = listing.path;
+            this.total = listing.total;
+            this.entries = [];
+            this.selected.clear();
+            await this.loadMore();
+        } catch (error) {
+            console.error('Failed to open mbox file:', error);
+            this.onError(`Failed to open ${getFileName(path)}: ${error}`);
+            return;
+        }
+
+        this.modal.classList.add('active');
+        document.body.style.overflow = 'hidden';
+        document.addEventListener('keydown', this.handleKeyDown, true);
+        this.modal.querySelector('.help-modal-close')?.focus();
+    }
+
+    /**
+     * Hides the modal and releases the message index in the backend
+     */
+    close() {
+        if (!this.modal) return;
+
+        this.modal.classList.remove('active');
+        document.body.style.overflow = '';
+        document.removeEventListener('keydown', this.handleKeyDown, true);
+
+        if (this.path) {
+            closeMboxFile(this.path);
+            this.path = null;
+            this.entries = [];
+            this.selected.clear();
+        }
+    }
+
+    /**
+     * Whether the modal is shown
+     * @returns {boolean}
+     */
+    isOpen() {
+        return Boolean(this.modal?.classList.contains('active'));
+    }
+
+    /**
+     * Closes on Escape before the global shortcuts see the key
+     * @param {KeyboardEvent} event
+     */
+    handleKeyDown(event) {
+        if (event.key === 'Escape') {
+            event.preventDefault();
+            event.stopPropagation();
+            this.close();
+        }
+    }
+
+    /**
+     * Fetches and renders the next page of summaries
+     */
+    async loadMore() {
+        if (this.loading || !this.path || this.entries.length >= this.total) return;
+
+        this.loading = true;
+        try {
+            const page = await getMboxMessages(this.path, this.entries.length, MBOX_PAGE_SIZE);
+            this.total = page.total;
+            this.entries.push(...page.entries);
+        } finally {
+            this.loading = false;
+        }
+        this.render();
+    }
+
+    /**
+     * Saves the selected messages as .eml files to a folder the user chooses
+     */
+    async exportSelected() {
+        if (this.selected.size === 0) return;
+
+        const indices = [...this.selected].sort((a, b) => a - b);
+        const results = await exportMboxMessages(this.path, indices);
+        if (!results) return;
+
+        const failed = results.filter((result) => result.error);
+        if (failed.length === 0) {
+            this.onInfo(`${results.length} messages exported`);
+            this.selected.clear();
+            this.render();
+        } else {
+            failed.forEach((result) => console.error(`${result.fileName}: ${result.error}`));
+            this.onError(`${failed.length} of ${results.length} messages could not be exported`);
+        }
+    }
+
+    /**
+     * Renders the loaded entries and the export controls
+     */
+    render() {
+        if (!this.content) return;
+
+        if (this.title) {
+            this.title.textContent = `${getFileName(this.path)} (${this.total})`;
+        }
+
+        const rows = this.entries
+            .map((entry) => {
+                const sender = entry.senderName || entry.senderEmail;
+                const meta = [
+                    sender,
+                    entry.date ? new Date(entry.date).toLocaleString(getLocale()) : '',
+                    formatSize(entry.size)
+                ].filter(Boolean);
+                const checked = this.selected.has(entry.index) ? 'checked' : '';
+                return `
+                <li class="mbox-entry">
+                    <input type="checkbox" data-mbox-select="${entry.index}" ${checked}
+                           aria-label="Select message">
+                    <button type="button" class="folder-entry" data-mbox-entry="${entry.index}"
+                            title="${escapeHTML(entry.error || '')}">
+                        <span class="folder-entry-subject">
+                            ${escapeHTML(entry.subject || '(no subject)')}
+                        </span>
+                        <span class="folder-entry-meta">
+                            ${meta.map((part) => `<span>${escapeHTML(part)}</span>`).join('')}
+                        </span>
+                    </button>
+                </li>`;
+            })
+            .join('');
+
+        const remaining = this.total - this.entries.length;
+        this.content.innerHTML = `
+            <div class="mbox-actions">${this.renderActions()}</div>
+            <ul class="folder-entries">${rows}</ul>
+            ${
+                remaining > 0
+                    ? `<button class="help-modal-close-btn" data-action="mbox-more">
+                           Show ${Math.min(remaining, MBOX_PAGE_SIZE)} more of ${remaining}
+                       </button>`
+                    : ''
+            }`;
+    }
+
+    /**
+     * Selection and export buttons
+     * @returns {string} HTML
+     */
+    renderActions() {
+        const allSelected = this.entries.length > 0 && this.selected.size === this.entries.length;
+        return `
+            <button class="help-modal-close-btn" data-action="mbox-select-all">
+                ${allSelected ? 'Select none' : 'Select all loaded'}
+            </button>
+            <button class="help-modal-close-btn" data-action="mbox-export"
+                    ${this.selected.size === 0 ? 'disabled' : ''}>
+                Export ${this.selected.size || ''} as .eml…
+            </button>`;
+    }
+
+    /**
+     * Tracks the selection of a message. Only the buttons are redrawn, so the checkbox
+     * keeps the focus.
+     * @param {Event} e
+     */
+    handleChange(e) {
+        const checkbox = e.target.closest('[data-mbox-select]');
+        if (!checkbox) return;
+
+        const index = Number(checkbox.dataset.mboxSelect);
+        if (checkbox.checked) {
+            this.selected.add(index);
+        } else {
+            this.selected.delete(index);
+        }
+        const actions = this.content.querySelector('.mbox-actions');
+        if (actions) actions.innerHTML = this.renderActions();
+    }
+
+    /**
+     * Opens a clicked message, changes the selection, exports or loads the next page
+     * @param {MouseEvent} e
+     */
+    async handleClick(e) {
+        const entry = e.target.closest('[data-mbox-entry]');
+        if (entry) {
+            this.onOpen(this.path, this.entries[Number(entry.dataset.mboxEntry)]);
+            return;
+        }
+
+        const action = e.target.closest('[data-action]')?.dataset.action;
+        try {
+            if (action === 'mbox-select-all') {
+                const allSelected = this.selected.size === this.entries.length;
+                this.selected = new Set(allSelected ? [] : this.entries.map((item) => item.index));
+                this.render();
+            } else if (action === 'mbox-export') {
+                await this.exportSelected();
+            } else if (action === 'mbox-more') {
+                await this.loadMore();
+            }
+        } catch (error) {
+            console.error('Failed to read mbox file:', error);
+            this.onError(`Failed to read mbox file: ${error}`);
+        }
+    }
+}
@@ -1,4 +1,10 @@
-import { escapeHTML, sanitizeHTML } from '../sanitizer.js';
+import {
+    blockExternalContent,
+    containsExternalContent,
+    escapeHTML,
+    proxyExternalContent,
+    sanitizeHTML
+} from '../sanitizer.js';
 import { formatContact, getContactEmail } from '../addressUtils.js';
 import { parseColor, getContrastRatio, adjustColorForContrast } from '../colorUtils.js';
 import { isInlineImageAttachment } from '../helpers.js';
@@ -7,6 +13,35 @@ import {
     inlineImageAttachmentsExpandedByDefault,
     setInlineImageAttachmentVisibility
 } from '../InlineImagePreference.js';
+import { accessibilityManager } from '../AccessibilityManager.js';
+import {
+    externalContentBlocked,
+    externalContentManaged,
+    remoteImagesAllowedFor
+} from '../UserPreferences.js';
+import { isTauri } from '../tauri-bridge.js';
+import { getEncryptedSmimePart } from '../smime.js';
+import { canCheckAuthentication, getAuthenticationVerdict } from '../senderAuthentication.js';
+import { canCheckPhishing, getPhishingVerdict } from '../phishingCheck.js';
+import { canOpenInNewWindow } from '../messageWindow.js';
+import { getAttachmentThumbnail } from '../thumbnails.js';
+import { canReply } from '../reply.js';
+import { t } from '../i18n.js';
+import { describeAttachmentCheck, getAttachmentCheck } from '../attachmentScan.js';
+import {
+    MEETING_METHOD_LABELS,
+    describeAttendee,
+    formatMeetingTime,
+    loadMeeting,
+    mayContainMeeting
+} from '../meeting.js';
+import {
+    describeAddress,
+    describePhone,
+    formatContactDate,
+    isContact,
+    loadContact
+} from '../contact.js';
 
 /**
  * Renders message content in the main viewer area
@@ -25,11 +60,84 @@ export class MessageContentRenderer {
         this.attachmentModal = attachmentModal;
         this.realAttachments = [];
         this.inlineImageAttachments = [];
+        this.exportPlugins = [];
+        this.translationAvailable = false;
+        this.readAloudAvailable = false;
+        this.readAloudMessage = null;
+        this.smimeKeystoreAvailable = false;
+        this.remoteImageProxy = null;
+        // Messages whose remote images the user loaded in this session
+        this.remoteImagesLoaded = new WeakSet();
+        this.externalContentHidden = false;
 
         this.initInlineImageEventListeners();
         this.initInlineAttachmentPreferenceListener();
     }
 
+    /**
+     * Sets the export plugins offered in the export menu
+     * @param {Array<{id: string, name: string}>} plugins - Installed export plugins
+     */
+    setExportPlugins(plugins) {
+        this.exportPlugins = Array.isArray(plugins) ? plugins : [];
+    }
+
+    /**
+     * Shows or hides the translate button in the message header
+     * @param {boolean} available - Whether a translation service is configured
+     */
+    setTranslationAvailable(available) {
+        this.translationAvailable = Boolean(available);
+    }
+
+    /**
+     * Shows or hides the read aloud button in the message header
+     * @param {boolean} available - Whether OS text-to-speech can be used
+     */
+    setReadAloudAvailable(available) {
+        this.readAloudAvailable = Boolean(available);
+    }
+
+    /**
+     * Offers decrypting S/MIME messages with the OS certificate store
+     * @param {boolean} available - Whether the backend can use the OS certificate store
+     */
+    setSmimeKeystoreAvailable(available) {
+        this.smimeKeystoreAvailable = Boolean(available);
+    }
+
+    /**
+     * Marks the message that is currently read aloud
+     * @param {Object|null} message - Message being read, null when speech stopped
+     */
+    setReadAloudMessage(message) {
+        this.readAloudMessage = message;
+        const button = this.container?.querySelector('[data-action="read-aloud"]');
+        if (!button) return;
+
+        const active = message !== null && this.messageHandler.getCurrentMessage() === message;
+        button.classList.toggle('active', active);
+        button.setAttribute('aria-pressed', active ? 'true' : 'false');
+        button.title = active ? 'stop reading' : 'read aloud';
+    }
+
+    /**
+     * Sets the local proxy remote images are loaded through in the desktop app
+     * @param {string|null} proxyBase - Base URL from getRemoteImageProxy, null to load
+     *     remote images directly
+     */
+    setRemoteImageProxy(proxyBase) {
+        this.remoteImageProxy = proxyBase;
+    }
+
+    /**
+     * Loads the remote images of a message for the rest of the session
+     * @param {Object} msgInfo - Message object
+     */
+    loadRemoteImages(msgInfo) {
+        this.remoteImagesLoaded.add(msgInfo);
+    }
+
     /**
      * Displays a message in the main viewer area
      * @param {Object} msgInfo - Message object to display
@@ -46,29 +154,83 @@ export class MessageContentRenderer {
         const messageIndex = this.messageHandler.getMessages().indexOf(msgInfo);
         const isPinned = this.messageHandler.isPinned(msgInfo);
         const canDownloadOriginal = Boolean(msgInfo._rawBuffer && msgInfo._fileType);
+        // MSG files are written by the backend; for MSG messages the original is offered
+        const canExportMsg = isTauri() && msgInfo._fileType !== 'msg';
+        const isReadingAloud = this.readAloudMessage === msgInfo;
+        const pluginItems = this.exportPlugins
+            .map((plugin) => `<button data-action="export-message" data-index="${messageIndex}" data-format="plugin:${escapeHTML(plugin.id)}" class="message-export-item">${escapeHTML(plugin.name)}</button>`)
+            .join('');
 
         const messageContent = `
             <div class="message-header">
                 <div class="message-title pl-6">${msgInfo.subject}</div>
                 <div class="message-actions pr-4">
+                    ${isTauri() && canReply(msgInfo) ? `<div class="message-export-menu">
+                        <button data-action="toggle-export-menu" data-index="${messageIndex}" class="action-button rounded-full" title="${t('reply.menu')}">
+                            <svg xmlns="http://www.w3.org/2000/svg" fill="none" viewBox="0 0 24 24" stroke-width="1.5" stroke="currentColor">
+                                <path stroke-linecap="round" stroke-linejoin="round" d="M9 15 3 9m0 0 6-6M3 9h12a6 6 0 0 1 0 12h-3" />
+                            </svg>
+                        </button>
+                        <div class="message-export-dropdown">
+                            <button data-action="reply-message" data-index="${messageIndex}" data-mode="reply" class="message-export-item">${t('reply.reply')}</button>
+                            <button data-action="reply-message" data-index="${messageIndex}" data-mode="replyAll" class="message-export-item">${t('reply.replyAll')}</button>
+                            <button data-action="reply-message" data-index="${messageIndex}" data-mode="forward" class="message-export-item">${t('reply.forward')}</button>
+                            <button data-action="reply-message" data-index="${messageIndex}" data-mode="reply" data-handoff="mailto" class="message-export-item" title="${t('reply.mailtoHint')}">${t('reply.mailto')}</button>
+                        </div>
+                    </div>` : ''}
                     <div class="message-export-menu">
-                        <button data-action="toggle-export-menu" data-index="${messageIndex}" class="action-button rounded-full" title="export message">
+                        <button data-action="toggle-export-menu" data-index="${messageIndex}" class="action-button rounded-full" title="${t('message.export')}">
                             <svg xmlns="http://www.w3.org/2000/svg" fill="none" viewBox="0 0 24 24" stroke-width="1.5" stroke="currentColor">
                                 <path stroke-linecap="round" stroke-linejoin="round" d="M3 16.5v2.25A2.25 2.25 0 0 0 5.25 21h13.5A2.25 2.25 0 0 0 21 18.75V16.5M16.5 12 12 16.5m0 0L7.5 12m4.5 4.5V3" />
                             </svg>
                         </button>
                         <div class="message-export-dropdown">
-                            <button data-action="export-message" data-index="${messageIndex}" data-format="eml" class="message-export-item">Export as EML</button>
-                            <button data-action="export-message" data-index="${messageIndex}" data-format="html" class="message-export-item">Export as HTML</button>
-                            ${canDownloadOriginal ? `<button data-action="export-message" data-index="${messageIndex}" data-format="original" class="message-export-item">Download original ${msgInfo._fileType.toUpperCase()}</button>` : ''}
+                            <button data-action="export-message" data-index="${messageIndex}" data-format="eml" class="message-export-item">${t('message.exportEml')}</button>
+                            <button data-action="export-message" data-index="${messageIndex}" data-format="html" class="message-export-item">${t('message.exportHtml')}</button>
+                            ${canExportMsg ? `<button data-action="export-message" data-index="${messageIndex}" data-format="msg" class="message-export-item">${t('message.exportMsg')}</button>` : ''}
+                            ${isTauri() ? `<button data-action="export-message" data-index="${messageIndex}" data-format="redacted-eml" class="message-export-item">${t('message.exportRedactedEml')}</button>
+                            <button data-action="export-message" data-index="${messageIndex}" data-format="redacted-msg" class="message-export-item">${t('message.exportRedactedMsg')}</button>` : ''}
+                            ${canDownloadOriginal ? `<button data-action="export-message" data-index="${messageIndex}" data-format="original" class="message-export-item">${t('message.downloadOriginal', { type: msgInfo._fileType.toUpperCase() })}</button>` : ''}
+                            ${pluginItems}
+                            ${isTauri() ? `<button data-action="copy-message-file" data-index="${messageIndex}" class="message-export-item">${t('message.copyAsFile')}</button>` : ''}
                         </div>
                     </div>
-                    <button data-action="pin" data-index="${messageIndex}" class="action-button rounded-full ${isPinned ? 'pinned' : ''}" title="bookmark message">
+                    ${isTauri() ? `<button data-action="print-message" data-index="${messageIndex}" class="action-button rounded-full" title="${t('message.print')}">
+                        <svg xmlns="http://www.w3.org/2000/svg" fill="none" viewBox="0 0 24 24" stroke-width="1.5" stroke="currentColor">
+                            <path stroke-linecap="round" stroke-linejoin="round" d="M6.72 13.829c-.24.03-.48.062-.72.096m.72-.096a42.415 42.415 0 0 1 10.56 0m-10.56 0L6.34 18m10.94-4.171c.24.03.48.062.72.096m-.72-.096L17.66 18m0 0 .229 2.523a1.125 1.125 0 0 1-1.12 1.227H7.231c-.662 0-1.18-.568-1.12-1.227L6.34 18m11.318 0h1.091A2.25 2.25 0 0 0 21 15.75V9.456c0-1.081-.768-2.015-1.837-2.175a48.055 48.055 0 0 0-1.913-.247M6.34 18H5.25A2.25 2.25 0 0 1 3 15.75V9.456c0-1.081.768-2.015 1.837-2.175a48.041 48.041 0 0 1 1.913-.247m10.5 0a48.536 48.536 0 0 0-10.5 0m10.5 0V3.375c0-.621-.504-1.125-1.125-1.125h-8.25c-.621 0-1.125.504-1.125 1.125v3.659M18 10.5h.008v.008H18V10.5Zm-3 0h.008v.008H15V10.5Z" />
+                        </svg>
+                    </button>` : ''}
+                    ${isTauri() && canOpenInNewWindow(msgInfo) ? `<button data-action="open-in-new-window" data-index="${messageIndex}" class="action-button rounded-full" title="${t('message.openInNewWindow')}">
+                        <svg xmlns="http://www.w3.org/2000/svg" fill="none" viewBox="0 0 24 24" stroke-width="1.5" stroke="currentColor">
+                            <path stroke-linecap="round" stroke-linejoin="round" d="M13.5 6H5.25A2.25 2.25 0 0 0 3 8.25v10.5A2.25 2.25 0 0 0 5.25 21h10.5A2.25 2.25 0 0 0 18 18.75V10.5m-10.5 6L21 3m0 0h-5.25M21 3v5.25" />
+                        </svg>
+                    </button>` : ''}
+                    ${this.readAloudAvailable ? `<button data-action="read-aloud" data-index="${messageIndex}" class="action-button rounded-full ${isReadingAloud ? 'active' : ''}" aria-pressed="${isReadingAloud}" title="${t(isReadingAloud ? 'message.stopReading' : 'message.readAloud')}">
+                        <svg xmlns="http://www.w3.org/2000/svg" fill="none" viewBox="0 0 24 24" stroke-width="1.5" stroke="currentColor">
+                            <path stroke-linecap="round" stroke-linejoin="round" d="M19.114 5.636a9 9 0 0 1 0 12.728M16.463 8.288a5.25 5.25 0 0 1 0 7.424M6.75 8.25l4.72-4.72a.75.75 0 0 1 1.28.53v15.88a.75.75 0 0 1-1.28.53l-4.72-4.72H4.51c-.88 0-1.704-.507-1.938-1.354A9.009 9.009 0 0 1 2.25 12c0-.83.112-1.633.322-2.396C2.806 8.756 3.63 8.25 4.51 8.25H6.75Z" />
+                        </svg>
+                    </button>` : ''}
+                    ${isTauri() && canCheckAuthentication(msgInfo) ? `<button data-action="check-authentication" data-index="${messageIndex}" class="action-button rounded-full" title="${t('message.checkAuthentication')}">
+                        <svg xmlns="http://www.w3.org/2000/svg" fill="none" viewBox="0 0 24 24" stroke-width="1.5" stroke="currentColor">
+                            <path stroke-linecap="round" stroke-linejoin="round" d="M9 12.75 11.25 15 15 9.75m-3-7.036A11.959 11.959 0 0 1 3.598 6 11.99 11.99 0 0 0 3 9.749c0 5.592 3.824 10.29 9 11.623 5.176-1.332 9-6.03 9-11.622 0-1.31-.21-2.571-.598-3.751h-.152c-3.196 0-6.1-1.248-8.25-3.285Z" />
+                        </svg>
+                    </button>` : ''}
+                    ${isTauri() && canCheckPhishing(msgInfo) ? `<button data-action="check-phishing" data-index="${messageIndex}" class="action-button rounded-full" title="${t('message.checkPhishing')}">
+                        <svg xmlns="http://www.w3.org/2000/svg" fill="none" viewBox="0 0 24 24" stroke-width="1.5" stroke="currentColor">
+                            <path stroke-linecap="round" stroke-linejoin="round" d="M13.19 8.688a4.5 4.5 0 0 1 1.242 7.244l-4.5 4.5a4.5 4.5 0 0 1-6.364-6.364l1.757-1.757m13.35-.622 1.757-1.757a4.5 4.5 0 0 0-6.364-6.364l-4.5 4.5a4.5 4.5 0 0 0 1.242 7.244" />
+                        </svg>
+                    </button>` : ''}
+                    ${this.translationAvailable ? `<button data-action="translate" data-index="${messageIndex}" class="action-button rounded-full" title="${t('message.translate')}">
+                        <svg xmlns="http://www.w3.org/2000/svg" fill="none" viewBox="0 0 24 24" stroke-width="1.5" stroke="currentColor">
+                            <path stroke-linecap="round" stroke-linejoin="round" d="m10.5 21 5.25-11.25L21 21m-9-3h7.5M3 5.621a48.474 48.474 0 0 1 6-.371m0 0c1.12 0 2.233.038 3.334.114M9 5.25V3m3.334 2.364C11.176 10.658 7.69 15.08 3 17.502m9.334-12.138c.896.061 1.785.147 2.666.257m-4.589 8.495a18.023 18.023 0 0 1-3.827-5.802" />
+                        </svg>
+                    </button>` : ''}
+                    <button data-action="pin" data-index="${messageIndex}" class="action-button rounded-full ${isPinned ? 'pinned' : ''}" title="${t('message.bookmark')}">
                         <svg xmlns="http://www.w3.org/2000/svg" fill="none" viewBox="0 0 24 24" stroke-width="1.5" stroke="currentColor">
                             <path stroke-linecap="round" stroke-linejoin="round" d="M17.593 3.322c1.1.128 1.907 1.077 1.907 2.185V21L12 17.25 4.5 21V5.507c0-1.108.806-2.057 1.907-2.185a48.507 48.507 0 0 1 11.186 0Z" />
                         </svg>
                     </button>
-                    <button data-action="delete" data-index="${messageIndex}" class="action-button rounded-full" title="remove message">
+                    <button data-action="delete" data-index="${messageIndex}" class="action-button rounded-full" title="${t('message.remove')}">
                         <svg xmlns="http://www.w3.org/2000/svg" fill="none" viewBox="0 0 24 24" stroke-width="1.5" stroke="currentColor">
                             <path stroke-linecap="round" stroke-linejoin="round" d="m14.74 9-.346 9m-4.788 0L9.26 9m9.968-3.21c.342.052.682.107 1.022.166m-1.022-.165L18.16 19.673a2.25 2.25 0 0 1-2.244 2.077H8.084a2.25 2.25 0 0 1-2.244-2.077L4.772 5.79m14.456 0a48.108 48.108 0 0 0-3.478-.397m-12 .562c.34-.059.68-.114 1.022-.165m0 0a48.11 48.11 0 0 1 3.478-.397m7.5 0v-.916c0-1.18-.91-2.164-2.09-2.201a51.964 51.964 0 0 0-3.32 0c-1.18.037-2.09 1.022-2.09 2.201v.916m7.5 0a48.667 48.667 0 0 0-7.5 0" />
                         </svg>
@@ -77,11 +239,18 @@ export class MessageContentRenderer {
             </div>
             <div class="message-card">
                 <div class="mb-4">
-                    <div class="message-meta"><strong>From:</strong> ${from}</div>
-                    ${toRecipients ? `<div class="message-meta"><strong>To:</strong> ${toRecipients}</div>` : ''}
-                    ${ccRecipients ? `<div class="message-meta"><strong>CC:</strong> ${ccRecipients}</div>` : ''}
+                    <div class="message-meta"><strong>${t('message.from')}</strong> ${from}</div>
+                    ${toRecipients ? `<div class="message-meta"><strong>${t('message.to')}</strong> ${toRecipients}</div>` : ''}
+                    ${ccRecipients ? `<div class="message-meta"><strong>${t('message.cc')}</strong> ${ccRecipients}</div>` : ''}
                     <div class="message-timestamp">${msgInfo.timestamp.toLocaleString()}</div>
                 </div>
+                ${this.renderSmimeNotice(msgInfo, messageIndex)}
+                ${this.renderExternalContentNotice(msgInfo, messageIndex)}
+                <div class="message-meeting hidden" aria-live="polite"></div>
+                <div class="message-contact hidden" aria-live="polite"></div>
+                <div class="message-authentication hidden" aria-live="polite"></div>
+                <div class="message-phishing hidden" aria-live="polite"></div>
+                <div class="message-translation hidden" aria-live="polite"></div>
                 <div class="prose max-w-none">
                     <div class="email-content" style="position: relative; isolation: isolate;">
                         ${emailContent}
@@ -95,7 +264,287 @@ export class MessageContentRenderer {
 
         // Fix low-contrast text colors in dark mode
         this.fixLowContrastColors();
+        accessibilityManager.applyMinimumFontSize(this.container.querySelector('.email-content'));
         this.enhanceInlineImages(msgInfo);
+        this.loadAttachmentThumbnails();
+        this.loadAttachmentChecks();
+        if (isTauri() && mayContainMeeting(msgInfo)) {
+            this.loadMeetingPanel(msgInfo, messageIndex);
+        }
+        if (isTauri() && isContact(msgInfo)) {
+            this.loadContactPanel(msgInfo, messageIndex);
+        }
+    }
+
+    /**
+     * Reads the meeting of a message in the backend and shows it above the body
+     * @param {Object} msgInfo - Message object
+     * @param {number} messageIndex - Index of the message in the list
+     */
+    async loadMeetingPanel(msgInfo, messageIndex) {
+        const panel = this.container.querySelector('.message-meeting');
+        try {
+            const meeting = await loadMeeting(msgInfo);
+            // Another message was opened meanwhile
+            if (meeting && panel.isConnected) {
+                this.showMeeting(panel, meeting, messageIndex);
+            }
+        } catch (error) {
+            console.warn('Could not read meeting:', error);
+        }
+    }
+
+    /**
+     * Reads the contact of an Outlook contact file in the backend and shows it above the body
+     * @param {Object} msgInfo - Message object
+     * @param {number} messageIndex - Index of the message in the list
+     */
+    async loadContactPanel(msgInfo, messageIndex) {
+        const panel = this.container.querySelector('.message-contact');
+        try {
+            const contact = await loadContact(msgInfo);
+            // Another message was opened meanwhile
+            if (contact && panel.isConnected) {
+                this.showContact(panel, contact, messageIndex);
+            }
+        } catch (error) {
+            console.warn('Could not read contact:', error);
+        }
+    }
+
+    /**
+     * Shows the names, addresses and numbers of a contact and a button to save it as a vCard
+     * @param {HTMLElement} panel - The .message-contact element of the displayed message
+     * @param {Object} contact - Contact from loadContact (contact.js)
+     * @param {number} messageIndex - Index of the message in the list
+     */
+    showContact(panel, contact, messageIndex) {
+        const row = (label, value) =>
+            value ? `<li><strong>${label}</strong> ${escapeHTML(value)}</li>` : '';
+        const organization = [contact.jobTitle, contact.department, contact.company]
+            .filter(Boolean)
+            .join(', ');
+        const photo = /^data:image\/[\w.+-]+;base64,/.test(contact.photo || '')
+            ? `<img class="message-contact-photo" src="${escapeHTML(contact.photo)}" alt="">`
+            : '';
+
+        panel.innerHTML = `
+            <div class="message-translation-header">
+                <span class="message-meeting-title">Contact: ${escapeHTML(contact.displayName)}</span>
+                <button data-action="export-vcf" data-index="${messageIndex}" class="message-translation-close">Save as vCard (.vcf)</button>
+            </div>
+            <div class="message-contact-body">
+                ${photo}
+                <ul class="message-meeting-details">
+                    ${row('Organization', organization)}
+                    ${contact.emails.map((email) => row('Email', email)).join('')}
+                    ${contact.phones.map((phone) => `<li>${escapeHTML(describePhone(phone))}</li>`).join('')}
+                    ${contact.addresses.map((address) => `<li>${escapeHTML(describeAddress(address))}</li>`).join('')}
+                    ${contact.websites.map((website) => row('Web', website)).join('')}
+                    ${row('Birthday', formatContactDate(contact.birthday))}
+                    ${row('Anniversary', formatContactDate(contact.anniversary))}
+                </ul>
+            </div>
+        `;
+        panel.classList.remove('hidden');
+    }
+
+    /**
+     * Shows when and where a meeting takes place, who takes part, and a button to save it
+     * for a calendar app
+     * @param {HTMLElement} panel - The .message-meeting element of the displayed message
+     * @param {Object} meeting - Meeting from loadMeeting (meeting.js)
+     * @param {number} messageIndex - Index of the message in the list
+     */
+    showMeeting(panel, meeting, messageIndex) {
+        const row = (label, value) =>
+            value ? `<li><strong>${label}</strong> ${escapeHTML(value)}</li>` : '';
+        const attendees = meeting.attendees
+            .map((attendee) => `<li>${escapeHTML(describeAttendee(attendee))}</li>`)
+            .join('');
+
+        panel.innerHTML = `
+            <div class="message-translation-header">
+                <span class="message-meeting-title${meeting.method === 'CANCEL' ? ' canceled' : ''}">${escapeHTML(MEETING_METHOD_LABELS[meeting.method] || MEETING_METHOD_LABELS.PUBLISH)}: ${escapeHTML(meeting.summary)}</span>
+                <button data-action="export-ics" data-index="${messageIndex}" class="message-translation-close">Add to calendar (.ics)</button>
+            </div>
+            <ul class="message-meeting-details">
+                ${row('When', formatMeetingTime(meeting))}
+                ${row('Where', meeting.location)}
+                ${row('Organizer', meeting.organizer ? describeAttendee(meeting.organizer) : '')}
+            </ul>
+            ${attendees ? `<details><summary>${meeting.attendees.length} ${meeting.attendees.length === 1 ? 'attendee' : 'attendees'}</summary><ul class="message-meeting-details">${attendees}</ul></details>` : ''}
+        `;
+        panel.classList.remove('hidden');
+    }
+
+    /**
+     * Renders the notice above the body of an S/MIME encrypted message
+     * @param {Object} msgInfo - Message object
+     * @param {number} messageIndex - Index of the message in the list
+     * @returns {string} HTML string, empty for messages that are not encrypted
+     */
+    renderSmimeNotice(msgInfo, messageIndex) {
+        if (msgInfo._smimeDecrypted) {
+            return '<div class="message-smime-notice">Decrypted S/MIME message</div>';
+        }
+        if (!getEncryptedSmimePart(msgInfo)) return '';
+
+        if (!isTauri()) {
+            return `
+                <div class="message-smime-notice">
+                    This message is encrypted (S/MIME). It can be decrypted in the desktop app.
+                </div>`;
+        }
+        return `
+            <div class="message-smime-notice">
+                <span>This message is encrypted (S/MIME).</span>
+                <button data-action="decrypt-smime" data-index="${messageIndex}" data-source="file" class="message-translation-close">Decrypt with certificate file…</button>
+                ${this.smimeKeystoreAvailable ? `<button data-action="decrypt-smime" data-index="${messageIndex}" data-source="keystore" class="message-translation-close">Decrypt with system certificate</button>` : ''}
+            </div>`;
+    }
+
+    /**
+     * Tells that remote images were blocked and offers to load them, for this message or
+     * always for its sender. Nothing is offered when a policy blocks remote content.
+     * @param {Object} msgInfo - Message object
+     * @param {number} messageIndex - Index of the message in the list
+     * @returns {string} HTML string, empty if nothing was blocked
+     */
+    renderExternalContentNotice(msgInfo, messageIndex) {
+        if (!this.externalContentHidden) return '';
+
+        if (externalContentManaged()) {
+            return `
+                <div class="message-external-content-notice">
+                    Remote images are blocked by your administrator.
+                </div>`;
+        }
+        const sender = msgInfo.senderEmail || '';
+        return `
+            <div class="message-external-content-notice">
+                <span>Remote images were blocked to protect your privacy.</span>
+                <button data-action="load-remote-images" data-index="${messageIndex}" class="message-translation-close">Load images</button>
+                ${sender ? `<button data-action="allow-remote-image-sender" data-index="${messageIndex}" class="message-translation-close">Always load from ${escapeHTML(sender)}</button>` : ''}
+            </div>`;
+    }
+
+    /**
+     * Shows the sender authentication report of the displayed message above its body
+     * @param {Object} report - Result of checkAuthentication (senderAuthentication.js)
+     */
+    showAuthentication(report) {
+        const panel = this.container?.querySelector('.message-authentication');
+        if (!panel) return;
+
+        const verdict = getAuthenticationVerdict(report);
+        const detail = (reason) => (reason ? ` - ${escapeHTML(reason)}` : '');
+        const alignment = (aligned) => (aligned ? 'aligned' : 'not aligned');
+        const checks = [
+            ...report.dkim.map(
+                (dkim) => `<li><strong>DKIM</strong> ${escapeHTML(dkim.result)} for ${escapeHTML(dkim.domain)} (selector ${escapeHTML(dkim.selector)}, ${alignment(dkim.aligned)})${detail(dkim.reason)}</li>`
+            ),
+            report.dkim.length ? '' : '<li><strong>DKIM</strong> not signed</li>',
+            report.spf
+                ? `<li><strong>SPF</strong> ${escapeHTML(report.spf.result)} for ${escapeHTML(report.spf.domain || 'unknown domain')}${report.spf.clientIp ? ` from ${escapeHTML(report.spf.clientIp)}` : ''} (${alignment(report.spf.aligned)})</li>`
+                : '<li><strong>SPF</strong> not recorded by the receiving server</li>',
+            `<li><strong>DMARC</strong> ${escapeHTML(report.dmarc.result)}${report.dmarc.policy ? ` (policy ${escapeHTML(report.dmarc.policy)})` : ''}${detail(report.dmarc.reason)}</li>`
+        ].join('');
+        const recorded = report.recorded
+            .map(
+                (result) => `<li>${escapeHTML(result.authservId)}: ${escapeHTML(result.method)}=${escapeHTML(result.result)}${detail(result.reason)}</li>`
+            )
+            .join('');
+
+        panel.innerHTML = `
+            <div class="message-translation-header">
+                <span class="message-authentication-verdict ${verdict.status}">${escapeHTML(verdict.text)}</span>
+                <button data-action="hide-authentication" class="message-translation-close">Close</button>
+            </div>
+            <ul class="message-authentication-checks">${checks}</ul>
+            ${recorded ? `<details><summary>Recorded by receiving servers</summary><ul class="message-authentication-checks">${recorded}</ul></details>` : ''}
+        `;
+        panel.classList.remove('hidden');
+    }
+
+    /**
+     * Removes the sender authentication panel of the displayed message
+     */
+    hideAuthentication() {
+        const panel = this.container?.querySelector('.message-authentication');
+        if (!panel) return;
+
+        panel.innerHTML = '';
+        panel.classList.add('hidden');
+    }
+
+    /**
+     * Shows the phishing check of the displayed message above its body
+     * @param {Object} report - Result of checkPhishing (phishingCheck.js)
+     */
+    showPhishingCheck(report) {
+        const panel = this.container?.querySelector('.message-phishing');
+        if (!panel) return;
+
+        const verdict = getPhishingVerdict(report);
+        const findings = report.findings
+            .map(
+                (finding) => `<li class="${escapeHTML(finding.severity)}"><strong>${escapeHTML(finding.description)}</strong>${finding.text ? `<div>Text: ${escapeHTML(finding.text)}</div>` : ''}<div class="message-phishing-target">${escapeHTML(finding.target)}</div></li>`
+            )
+            .join('');
+
+        panel.innerHTML = `
+            <div class="message-translation-header">
+                <span class="message-authentication-verdict ${verdict.status}">${escapeHTML(verdict.text)}</span>
+                <button data-action="hide-phishing" class="message-translation-close">Close</button>
+            </div>
+            ${findings ? `<ul class="message-authentication-checks">${findings}</ul>` : ''}
+        `;
+        panel.classList.remove('hidden');
+    }
+
+    /**
+     * Removes the phishing check panel of the displayed message
+     */
+    hidePhishingCheck() {
+        const panel = this.container?.querySelector('.message-phishing');
+        if (!panel) return;
+
+        panel.innerHTML = '';
+        panel.classList.add('hidden');
+    }
+
+    /**
+     * Shows a translation of the displayed message above its body
+     * @param {Object} translation - Result of translateMessage (translation.js)
+     */
+    showTranslation(translation) {
+        const panel = this.container?.querySelector('.message-translation');
+        if (!panel) return;
+
+        const from = translation.detectedSourceLang
+            ? `${escapeHTML(translation.detectedSourceLang.toUpperCase())} → `
+            : '';
+        panel.innerHTML = `
+            <div class="message-translation-header">
+                <span>Translated (${from}${escapeHTML(translation.targetLang.toUpperCase())})</span>
+                <button data-action="hide-translation" class="message-translation-close">Show original only</button>
+            </div>
+            ${translation.subject ? `<div class="message-translation-subject">${escapeHTML(translation.subject)}</div>` : ''}
+            <div class="message-translation-body">${escapeHTML(translation.body)}</div>
+        `;
+        panel.classList.remove('hidden');
+    }
+
+    /**
+     * Removes the translation panel of the displayed message
+     */
+    hideTranslation() {
+        const panel = this.container?.querySelector('.message-translation');
+        if (!panel) return;
+
+        panel.innerHTML = '';
+        panel.classList.add('hidden');
     }
 
     /**
@@ -370,8 +819,23 @@ export class MessageContentRenderer {
         }
 
         // Scope styles and sanitize
-        emailContent = this.scopeEmailStyles(emailContent);
-        return sanitizeHTML(emailContent);
+        emailContent = sanitizeHTML(this.scopeEmailStyles(emailContent));
+
+        // Remote images are blocked until the user loads them or allowed the sender; the
+        // desktop app loads them through its proxy
+        const loadExternalContent =
+            !externalContentBlocked() ||
+            (!externalContentManaged() &&
+                (this.remoteImagesLoaded.has(msgInfo) ||
+                    remoteImagesAllowedFor(msgInfo.senderEmail)));
+        this.externalContentHidden =
+            !loadExternalContent && containsExternalContent(emailContent);
+        if (!loadExternalContent) {
+            return blockExternalContent(emailContent);
+        }
+        return this.remoteImageProxy
+            ? proxyExternalContent(emailContent, this.remoteImageProxy)
+            : emailContent;
     }
 
     /**
@@ -409,7 +873,10 @@ export class MessageContentRenderer {
                     items: visibleAttachments,
                     label: `${visibleAttachments.length} ${visibleAttachments.length === 1 ? 'Attachment' : 'Attachments'}`,
                     icon: this.getAttachmentSectionIcon(),
-                    sectionClassName: 'attachment-section'
+                    sectionClassName: 'attachment-section',
+                    headerAction: visibleAttachments.length > 1
+                        ? `<button type="button" data-action="save-all-attachments" data-index="${this.messageHandler.getMessages().indexOf(msgInfo)}" class="attachment-section-toggle attachment-save-all-btn">Save all</button>`
+                        : ''
                 })
                 : '';
         const inlineImageAttachmentsHtml =
@@ -458,6 +925,7 @@ export class MessageContentRenderer {
      * @param {string} options.sectionClassName - CSS classes for the section
      * @param {boolean} [options.collapsible=false] - Whether the section can be collapsed
      * @param {boolean} [options.collapsed=false] - Whether the section starts collapsed
+     * @param {string} [options.headerAction=''] - Button markup shown in the section header
      * @returns {string} Rendered HTML
      */
     renderAttachmentSection({
@@ -466,7 +934,8 @@ export class MessageContentRenderer {
         icon,
         sectionClassName,
         collapsible = false,
-        collapsed = false
+        collapsed = false,
+        headerAction = ''
     }) {
         const expanded = !collapsed;
         const toggleButton = collapsible
@@ -485,7 +954,7 @@ export class MessageContentRenderer {
                         ${icon}
                         <span class="attachment-label">${label}</span>
                     </div>
-                    ${toggleButton}
+                    ${headerAction}${toggleButton}
                 </div>
                 <div class="flex flex-wrap gap-4" ${collapsible ? 'data-inline-images-content' : ''} ${collapsed ? 'hidden' : ''}>
                     ${this.renderAttachmentItems(items)}
@@ -506,24 +975,36 @@ export class MessageContentRenderer {
                     attachment.attachMimeTag,
                     attachment.fileName
                 );
+                const openButton =
+                    this.renderOpenExternallyButton(index) + this.renderCopyButton(index);
+                const thumbnailSlot = this.usesBackendThumbnail(attachment)
+                    ? ` data-thumbnail-index="${index}"`
+                    : '';
+                // Filled in by loadAttachmentChecks
+                const scanSlot = isTauri()
+                    ? `<p class="attachment-scan" data-scan-index="${index}"></p>`
+                    : '';
 
                 if (isPreviewable) {
                     return `
                     <div class="cursor-pointer min-w-[250px] max-w-fit"
                          data-action="preview"
                          data-attachment-index="${index}"
+                         draggable="true"
                          title="Click to preview">
                         <div class="attachment-item flex items-center space-x-2">
-                            <div class="attachment-thumbnail w-10 h-10 shrink-0 flex items-center justify-center overflow-hidden">
+                            <div class="attachment-thumbnail w-10 h-10 shrink-0 flex items-center justify-center overflow-hidden"${thumbnailSlot}>
                                 ${this.getAttachmentItemIcon(attachment)}
                             </div>
                             <div>
                                 <p class="attachment-filename">${attachment.fileName}</p>
                                 <p class="attachment-meta">${attachment.attachMimeTag} - ${attachment.contentLength} bytes</p>
+                                ${scanSlot}
                             </div>
+                            ${openButton}
                             <button data-action="download"
                                     data-attachment-index="${index}"
-                                    class="ml-auto pl-2 attachment-download-btn"
+                                    class="${openButton ? '' : 'ml-auto '}pl-2 attachment-download-btn"
                                     title="Download">
                                 <svg xmlns="http://www.w3.org/2000/svg" fill="none" viewBox="0 0 24 24" stroke-width="1.5" stroke="currentColor" class="w-4 h-4">
                                     <path stroke-linecap="round" stroke-linejoin="round" d="M3 16.5v2.25A2.25 2.25 0 0 0 5.25 21h13.5A2.25 2.25 0 0 0 21 18.75V16.5M16.5 12 12 16.5m0 0L7.5 12m4.5 4.5V3" />
@@ -538,16 +1019,19 @@ export class MessageContentRenderer {
                 <div class="cursor-pointer min-w-[250px] max-w-fit"
                      data-action="download"
                      data-attachment-index="${index}"
+                     draggable="true"
                      title="Click to download">
                     <div class="attachment-item flex items-center space-x-2">
-                        <div class="attachment-thumbnail w-10 h-10 shrink-0 flex items-center justify-center overflow-hidden">
+                        <div class="attachment-thumbnail w-10 h-10 shrink-0 flex items-center justify-center overflow-hidden"${thumbnailSlot}>
                             ${this.getAttachmentItemIcon(attachment)}
                         </div>
                         <div>
                             <p class="attachment-filename">${attachment.fileName}</p>
                             <p class="attachment-meta">${attachment.attachMimeTag} - ${attachment.contentLength} bytes</p>
+                            ${scanSlot}
                         </div>
-                        <div class="ml-auto pl-2 attachment-download-btn">
+                        ${openButton}
+                        <div class="${openButton ? '' : 'ml-auto '}pl-2 attachment-download-btn">
                             <svg xmlns="http://www.w3.org/2000/svg" fill="none" viewBox="0 0 24 24" stroke-width="1.5" stroke="currentColor" class="w-4 h-4">
                                 <path stroke-linecap="round" stroke-linejoin="round" d="M3 16.5v2.25A2.25 2.25 0 0 0 5.25 21h13.5A2.25 2.25 0 0 0 21 18.75V16.5M16.5 12 12 16.5m0 0L7.5 12m4.5 4.5V3" />
                             </svg>
@@ -559,6 +1043,92 @@ export class MessageContentRenderer {
             .join('');
     }
 
+    /**
+     * Whether the thumbnail of an attachment comes from the backend (desktop app only)
+     * @param {Object} attachment - Attachment object
+     * @returns {boolean}
+     */
+    usesBackendThumbnail(attachment) {
+        return (
+            isTauri() && Boolean(this.attachmentModal?.isPreviewableImage(attachment.attachMimeTag))
+        );
+    }
+
+    /**
+     * Replaces the placeholder icons of image attachments with thumbnails from the backend,
+     * one image at a time so a message with dozens of photos is not decoded all at once
+     */
+    async loadAttachmentThumbnails() {
+        const attachments = [...this.realAttachments, ...this.inlineImageAttachments];
+        const slots = this.container.querySelectorAll('[data-thumbnail-index]');
+        for (const slot of slots) {
+            const attachment = attachments[Number(slot.dataset.thumbnailIndex)];
+            const thumbnail = attachment ? await getAttachmentThumbnail(attachment) : null;
+            // Another message was opened meanwhile
+            if (!slot.isConnected) return;
+            if (thumbnail) {
+                slot.innerHTML = `<img src="${thumbnail}" alt="Attachment" class="w-10 h-10 object-cover">`;
+            }
+        }
+    }
+
+    /**
+     * Shows the digests and antivirus verdict of each attachment from the backend, one
+     * attachment at a time so large messages do not queue up all of them in the scanner
+     */
+    async loadAttachmentChecks() {
+        const attachments = [...this.realAttachments, ...this.inlineImageAttachments];
+        const slots = this.container.querySelectorAll('[data-scan-index]');
+        for (const slot of slots) {
+            const attachment = attachments[Number(slot.dataset.scanIndex)];
+            const check = attachment ? await getAttachmentCheck(attachment) : null;
+            // Another message was opened meanwhile
+            if (!slot.isConnected) return;
+            if (check) {
+                const { text, title, status } = describeAttachmentCheck(check);
+                slot.textContent = text;
+                slot.title = title;
+                slot.dataset.scanStatus = status;
+            }
+        }
+    }
+
+    /**
+     * Renders the button that opens an attachment with the system's default app
+     * @param {number} index - Attachment index
+     * @returns {string} Button markup, empty outside the desktop app
+     */
+    renderOpenExternallyButton(index) {
+        if (!isTauri()) return '';
+
+        return `<button data-action="open-external"
+                        data-attachment-index="${index}"
+                        class="ml-auto pl-2 attachment-download-btn attachment-open-btn"
+                        title="Open with default app">
+                    <svg xmlns="http://www.w3.org/2000/svg" fill="none" viewBox="0 0 24 24" stroke-width="1.5" stroke="currentColor" class="w-4 h-4">
+                        <path stroke-linecap="round" stroke-linejoin="round" d="M13.5 6H5.25A2.25 2.25 0 0 0 3 8.25v10.5A2.25 2.25 0 0 0 5.25 21h10.5A2.25 2.25 0 0 0 18 18.75V10.5m-10.5 6L21 3m0 0h-5.25M21 3v5.25" />
+                    </svg>
+                </button>`;
+    }
+
+    /**
+     * Renders the button that copies an attachment to the clipboard as a file
+     * @param {number} index - Attachment index
+     * @returns {string} Button markup, empty outside the desktop app
+     */
+    renderCopyButton(index) {
+        if (!isTauri()) return '';
+
+        return `<button data-action="copy-attachment"
+                        data-attachment-index="${index}"
+                        class="pl-2 attachment-download-btn attachment-copy-btn"
+                        title="${t('message.copyAsFile')}">
+                    <svg xmlns="http://www.w3.org/2000/svg" fill="none" viewBox="0 0 24 24" stroke-width="1.5" stroke="currentColor" class="w-4 h-4">
+                        <path stroke-linecap="round" stroke-linejoin="round" d="M15.75 17.25v3.375c0 .621-.504 1.125-1.125 1.125h-9.75a1.125 1.125 0 0 1-1.125-1.125V7.875c0-.621.504-1.125 1.125-1.125H6.75a9.06 9.06 0 0 1 1.5.124m7.5 10.376h3.375c.621 0 1.125-.504 1.125-1.125V11.25c0-4.46-3.243-8.161-7.5-9.376a9.06 9.06 0 0 0-1.5-.124H9.375c-.621 0-1.125.504-1.125 1.125v3.5m7.5 10.375H9.375a1.125 1.125 0 0 1-1.125-1.125v-9.25m12 6.625v-1.875a3.375 3.375 0 0 0-3.375-3.375h-1.5a1.125 1.125 0 0 1-1.125-1.125v-1.5a3.375 3.375 0 0 0-3.375-3.375H9.75" />
+                    </svg>
+                </button>`;
+    }
+
     /**
      * Returns the appropriate icon markup for an attachment item
      * @param {Object} attachment - Attachment object
@@ -570,6 +1140,13 @@ export class MessageContentRenderer {
         const isText = this.attachmentModal?.isText(attachment.attachMimeTag);
         const isEml = this.attachmentModal?.isPreviewableEml(attachment.attachMimeTag);
 
+        if (isImage && this.usesBackendThumbnail(attachment)) {
+            // Placeholder until loadAttachmentThumbnails fills in the thumbnail
+            return `<svg xmlns="http://www.w3.org/2000/svg" fill="none" viewBox="0 0 24 24" stroke-width="1.5" stroke="currentColor" class="w-6 h-6 attachment-icon">
+                        <path stroke-linecap="round" stroke-linejoin="round" d="m2.25 15.75 5.159-5.159a2.25 2.25 0 0 1 3.182 0l5.159 5.159m-1.5-1.5 1.409-1.409a2.25 2.25 0 0 1 3.182 0l2.159 2.159M3.75 19.5h16.5A1.5 1.5 0 0 0 21.75 18V6A1.5 1.5 0 0 0 20.25 4.5H3.75A1.5 1.5 0 0 0 2.25 6v12a1.5 1.5 0 0 0 1.5 1.5Zm11.25-10.5h.008v.008H15V9Z" />
+                    </svg>`;
+        }
+
         if (isImage) {
             return `<img src="${attachment.contentBase64}" alt="Attachment" class="w-10 h-10 object-cover">`;
         }
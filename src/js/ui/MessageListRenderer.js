@@ -1,4 +1,4 @@
-import { DEFAULT_LOCALE } from '../constants.js';
+import { getLocale } from '../i18n.js';
 import { isInlineImageAttachment } from '../helpers.js';
 import { VirtualList } from './VirtualList.js';
 
@@ -167,12 +167,16 @@ export class MessageListRenderer {
         const isActive = msg === currentMessage;
         const isPinned = this.messageHandler.isPinned(msg);
         const isSelected = this.messageHandler.isSelected?.(msg) || false;
+        // Replies are indented below the newest message of their conversation
+        const thread = this.messageHandler.getThreadInfo?.(msg);
+        const inThread = thread?.size > 1;
+        const threadClass = inThread && thread.position > 0 ? 'conversation-reply' : '';
 
         const hiddenFromAT = this.selectionMode ? 'false' : 'true';
         const checkboxTabIndex = this.selectionMode ? '0' : '-1';
 
         return `
-            <div class="message-item ${isActive ? 'active' : ''} ${isPinned ? 'pinned' : ''} ${isSelected ? 'selected' : ''}"
+            <div class="message-item ${isActive ? 'active' : ''} ${isPinned ? 'pinned' : ''} ${isSelected ? 'selected' : ''} ${threadClass}"
                  id="message-${index}"
                  role="option"
                  aria-selected="${isActive}"
@@ -180,6 +184,7 @@ export class MessageListRenderer {
                  aria-posinset="${index + 1}"
                  data-message-index="${originalIndex}"
                  tabindex="${isActive ? '0' : '-1'}"
+                 draggable="true"
                  title="${escapeAttribute(msg.fileName)}">
                 <label class="message-select-control"
                        data-selection-toggle
@@ -201,6 +206,7 @@ export class MessageListRenderer {
                     <div class="message-subject-line">
                         <span class="message-subject grow">${msg.subject}</span>
                         <div class="shrink-0">
+                            ${inThread && thread.position === 0 ? `<span class="conversation-count" title="${thread.size} messages in this conversation">${thread.size}</span>` : ''}
                             ${hasRealAttachments ? '<span class="attachment-icon" aria-label="Has attachments"><svg xmlns="http://www.w3.org/2000/svg" fill="none" viewBox="0 0 24 24" stroke-width="1.5" stroke="currentColor"><path stroke-linecap="round" stroke-linejoin="round" d="m18.375 12.739-7.693 7.693a4.5 4.5 0 0 1-6.364-6.364l10.94-10.94A3 3 0 1 1 19.5 7.372L8.552 18.32m.009-.01-.01.01m5.699-9.941-7.81 7.81a1.5 1.5 0 0 0 2.112 2.13" /></svg></span>' : ''}
                         </div>
                     </div>
@@ -233,11 +239,11 @@ export class MessageListRenderer {
         yesterday.setDate(yesterday.getDate() - 1);
 
         if (date.toDateString() === now.toDateString()) {
-            return date.toLocaleTimeString(DEFAULT_LOCALE, { hour: '2-digit', minute: '2-digit' });
+            return date.toLocaleTimeString(getLocale(), { hour: '2-digit', minute: '2-digit' });
         } else if (date.toDateString() === yesterday.toDateString()) {
             return 'Yesterday';
         } else if (date.getFullYear() === now.getFullYear()) {
-            return date.toLocaleDateString(DEFAULT_LOCALE, { month: 'short', day: 'numeric' });
+            return date.toLocaleDateString(getLocale(), { month: 'short', day: 'numeric' });
         } else {
             return date.toISOString().split('T')[0];
         }
@@ -0,0 +1,227 @@
+/**
+ * PassphrasePrompt UI Component
+ * Asks for the passphrase of the encrypted local data, for a new one or for the
+ * password of a certificate file
+ */
+
+import { MIN_PASSPHRASE_LENGTH } from '../dataEncryption.js';
+import { escapeHTML } from '../sanitizer.js';
+
+export class PassphrasePrompt {
+    /**
+     * @param {HTMLElement} modalElement - #passphraseModal
+     */
+    constructor(modalElement) {
+        this.modal = modalElement;
+        this.content = modalElement?.querySelector('.help-modal-content') || null;
+        this.title = modalElement?.querySelector('.help-modal-title') || null;
+        this.closeBtn = modalElement?.querySelector('.help-modal-close') || null;
+        this.resolve = null;
+        this.mode = null;
+
+        this.handleKeyDown = this.handleKeyDown.bind(this);
+        this.modal?.querySelector('.help-modal-backdrop')?.addEventListener('click', () => {
+            if (this.mode !== 'unlock') this.finish(undefined);
+        });
+        this.closeBtn?.addEventListener('click', () => this.finish(undefined));
+        this.content?.addEventListener('submit', (e) => {
+            e.preventDefault();
+            this.submit();
+        });
+        this.content?.addEventListener('click', (e) => {
+            if (e.target.closest('[data-action="forget"]')) this.forget();
+        });
+    }
+
+    /**
+     * Asks for the passphrase at startup. Cannot be dismissed: the data stays
+     * locked until the passphrase is entered or the encrypted data is deleted.
+     * @param {Object} [options]
+     * @param {string} [options.error] - Message shown after a wrong passphrase
+     * @returns {Promise<{passphrase: string}|null>} Null if the user chose to delete the data
+     */
+    unlock({ error = '' } = {}) {
+        return this.open('unlock', 'Unlock Data', `
+            <p class="passphrase-note">
+                The settings and data of this profile are encrypted. Enter the passphrase to
+                continue.
+            </p>
+            <form class="passphrase-form">
+                <label class="profile-chooser-label" for="passphraseInput">Passphrase</label>
+                <input id="passphraseInput" class="profile-chooser-input" type="password"
+                       autocomplete="current-password" required>
+                <p class="profile-chooser-error ${error ? '' : 'hidden'}" role="alert">
+                    ${escapeHTML(error)}
+                </p>
+                <div class="passphrase-actions">
+                    <button type="submit" class="help-modal-close-btn">Unlock</button>
+                    <button type="button" class="help-modal-close-btn" data-action="forget">
+                        Forgot passphrase
+                    </button>
+                </div>
+            </form>`);
+    }
+
+    /**
+     * Asks for a new passphrase
+     * @param {Object} [options]
+     * @param {boolean} [options.canRemember] - Offer to keep the key in the OS keychain
+     * @returns {Promise<{passphrase: string, remember: boolean}|undefined>} Undefined if
+     *     cancelled
+     */
+    create({ canRemember = false } = {}) {
+        return this.open('create', 'Encrypt Data', `
+            <p class="passphrase-note">
+                Settings, pinned messages, the audit log and usage statistics of this profile
+                will be encrypted. There is no way to recover them without the passphrase.
+            </p>
+            <form class="passphrase-form">
+                <label class="profile-chooser-label" for="passphraseInput">Passphrase</label>
+                <input id="passphraseInput" class="profile-chooser-input" type="password"
+                       autocomplete="new-password" minlength="${MIN_PASSPHRASE_LENGTH}" required>
+                <label class="profile-chooser-label" for="passphraseConfirm">Repeat</label>
+                <input id="passphraseConfirm" class="profile-chooser-input" type="password"
+                       autocomplete="new-password" required>
+                ${canRemember ? `
+                <label class="passphrase-remember">
+                    <input id="passphraseRemember" type="checkbox">
+                    Unlock automatically with the OS keychain
+                </label>` : ''}
+                <p class="profile-chooser-error hidden" role="alert"></p>
+                <div class="passphrase-actions">
+                    <button type="submit" class="help-modal-close-btn">Encrypt</button>
+                </div>
+            </form>`);
+    }
+
+    /**
+     * Asks for the password of a certificate file (S/MIME decryption)
+     * @param {Object} options
+     * @param {string} options.fileName - Name of the .pfx/.p12 file
+     * @param {string} [options.error] - Message shown after a failed attempt
+     * @returns {Promise<{passphrase: string}|undefined>} Undefined if cancelled
+     */
+    certificatePassword({ fileName, error = '' }) {
+        return this.open('certificate', 'Certificate Password', `
+            <p class="passphrase-note">
+                Enter the password of ${escapeHTML(fileName)} to decrypt the message. Leave
+                it empty if the file has no password.
+            </p>
+            <form class="passphrase-form">
+                <label class="profile-chooser-label" for="passphraseInput">Password</label>
+                <input id="passphraseInput" class="profile-chooser-input" type="password"
+                       autocomplete="off">
+                <p class="profile-chooser-error ${error ? '' : 'hidden'}" role="alert">
+                    ${escapeHTML(error)}
+                </p>
+                <div class="passphrase-actions">
+                    <button type="submit" class="help-modal-close-btn">Decrypt</button>
+                </div>
+            </form>`);
+    }
+
+    /**
+     * Shows the prompt
+     * @param {'unlock'|'create'|'certificate'} mode - Prompt type
+     * @param {string} title - Modal title
+     * @param {string} html - Modal content
+     * @returns {Promise<*>} Resolved by finish
+     */
+    open(mode, title, html) {
+        if (!this.modal) return Promise.resolve(mode === 'unlock' ? null : undefined);
+
+        this.mode = mode;
+        if (this.title) this.title.textContent = title;
+        this.closeBtn?.classList.toggle('hidden', mode === 'unlock');
+        if (this.content) this.content.innerHTML = html;
+        this.modal.classList.add('active');
+        document.body.style.overflow = 'hidden';
+        document.addEventListener('keydown', this.handleKeyDown, true);
+        this.content?.querySelector('#passphraseInput')?.focus();
+
+        return new Promise((resolve) => {
+            this.resolve = resolve;
+        });
+    }
+
+    /**
+     * Validates the form and resolves with the passphrase
+     */
+    submit() {
+        const passphrase = this.content?.querySelector('#passphraseInput')?.value || '';
+        if (this.mode === 'unlock') {
+            if (passphrase) this.finish({ passphrase });
+            return;
+        }
+        if (this.mode === 'certificate') {
+            this.finish({ passphrase });
+            return;
+        }
+
+        const confirmation = this.content?.querySelector('#passphraseConfirm')?.value || '';
+        let error = '';
+        if (passphrase.length < MIN_PASSPHRASE_LENGTH) {
+            error = `Use at least ${MIN_PASSPHRASE_LENGTH} characters.`;
+        } else if (passphrase !== confirmation) {
+            error = 'The passphrases do not match.';
+        }
+        if (error) {
+            this.showError(error);
+            return;
+        }
+
+        const remember = this.content?.querySelector('#passphraseRemember')?.checked === true;
+        this.finish({ passphrase, remember });
+    }
+
+    /**
+     * Deletes the encrypted data after a second confirmation
+     */
+    forget() {
+        const confirmed = window.confirm(
+            'Delete the encrypted settings and data of this profile? This cannot be undone.'
+        );
+        if (confirmed) this.finish(null);
+    }
+
+    /**
+     * Shows a validation message
+     * @param {string} message - Message text
+     */
+    showError(message) {
+        const error = this.content?.querySelector('.profile-chooser-error');
+        if (!error) return;
+        error.textContent = message;
+        error.classList.remove('hidden');
+    }
+
+    /**
+     * Escape cancels a new passphrase or a certificate password; the startup prompt
+     * ignores it
+     * @param {KeyboardEvent} event
+     */
+    handleKeyDown(event) {
+        if (event.key === 'Escape') {
+            event.preventDefault();
+            event.stopPropagation();
+            if (this.mode !== 'unlock') this.finish(undefined);
+        }
+    }
+
+    /**
+     * Hides the prompt and resolves the pending request
+     * @param {*} result - Result
+     */
+    finish(result) {
+        if (this.mode === 'unlock' && result === undefined) return;
+
+        this.modal?.classList.remove('active');
+        document.body.style.overflow = '';
+        document.removeEventListener('keydown', this.handleKeyDown, true);
+        this.mode = null;
+
+        const resolve = this.resolve;
+        this.resolve = null;
+        resolve?.(result);
+    }
+}
@@ -0,0 +1,132 @@
+/**
+ * ProfileChooser UI Component
+ * Lets the user pick or create a profile, at startup or from the settings menu
+ */
+
+import { isValidProfileName } from '../profiles.js';
+import { escapeHTML } from '../sanitizer.js';
+
+export class ProfileChooser {
+    /**
+     * @param {HTMLElement} modalElement - #profileChooserModal
+     */
+    constructor(modalElement) {
+        this.modal = modalElement;
+        this.content = modalElement?.querySelector('.help-modal-content') || null;
+        this.resolve = null;
+        this.cancellable = false;
+
+        this.handleKeyDown = this.handleKeyDown.bind(this);
+        const backdrop = this.modal?.querySelector('.help-modal-backdrop');
+        const closeBtn = this.modal?.querySelector('.help-modal-close');
+        backdrop?.addEventListener('click', () => this.dismiss());
+        closeBtn?.addEventListener('click', () => this.dismiss());
+        this.content?.addEventListener('click', (e) => {
+            const item = e.target.closest('[data-profile]');
+            if (item) this.finish(item.dataset.profile || null);
+        });
+        this.content?.addEventListener('submit', (e) => {
+            e.preventDefault();
+            this.createProfile();
+        });
+    }
+
+    /**
+     * Shows the chooser and waits for a selection
+     * @param {string[]} profiles - Existing profiles
+     * @param {Object} [options]
+     * @param {string|null} [options.activeProfile] - Profile to mark as current
+     * @param {boolean} [options.cancellable] - Whether closing keeps the current profile
+     * @returns {Promise<string|null|undefined>} Chosen name, null for the default profile,
+     *     undefined if cancelled
+     */
+    choose(profiles, { activeProfile = null, cancellable = false } = {}) {
+        if (!this.modal) return Promise.resolve(cancellable ? undefined : null);
+
+        this.cancellable = cancellable;
+        this.render(profiles, activeProfile);
+        this.modal.classList.add('active');
+        document.body.style.overflow = 'hidden';
+        document.addEventListener('keydown', this.handleKeyDown, true);
+        this.content?.querySelector('[data-profile]')?.focus();
+
+        return new Promise((resolve) => {
+            this.resolve = resolve;
+        });
+    }
+
+    /**
+     * Renders the profile list and the form for a new profile
+     * @param {string[]} profiles - Existing profiles
+     * @param {string|null} activeProfile - Current profile
+     */
+    render(profiles, activeProfile) {
+        if (!this.content) return;
+
+        const item = (name, label) => `
+            <button type="button" class="profile-chooser-item ${name === activeProfile ? 'active' : ''}"
+                    data-profile="${escapeHTML(name || '')}">${escapeHTML(label)}</button>`;
+
+        this.content.innerHTML = `
+            <div class="profile-chooser-list">
+                ${item(null, 'Default profile')}
+                ${profiles.map((name) => item(name, name)).join('')}
+            </div>
+            <form class="profile-chooser-form">
+                <label class="profile-chooser-label" for="profileChooserName">New profile</label>
+                <div class="profile-chooser-create">
+                    <input id="profileChooserName" class="profile-chooser-input" type="text"
+                           maxlength="64" autocomplete="off" placeholder="e.g. Case 2024-17">
+                    <button type="submit" class="help-modal-close-btn">Create</button>
+                </div>
+                <p class="profile-chooser-error hidden" role="alert">
+                    Use letters, digits, spaces, - and _ only.
+                </p>
+            </form>`;
+    }
+
+    /**
+     * Creates and selects the profile typed into the form
+     */
+    createProfile() {
+        const input = this.content?.querySelector('#profileChooserName');
+        const name = input?.value.trim() || '';
+        const valid = isValidProfileName(name);
+
+        this.content?.querySelector('.profile-chooser-error')?.classList.toggle('hidden', valid);
+        if (valid) this.finish(name);
+    }
+
+    /**
+     * Escape closes the chooser before the global shortcuts see the key
+     * @param {KeyboardEvent} event
+     */
+    handleKeyDown(event) {
+        if (event.key === 'Escape') {
+            event.preventDefault();
+            event.stopPropagation();
+            this.dismiss();
+        }
+    }
+
+    /**
+     * Closes without a selection: cancels, or picks the default profile at startup
+     */
+    dismiss() {
+        this.finish(this.cancellable ? undefined : null);
+    }
+
+    /**
+     * Hides the chooser and resolves the pending selection
+     * @param {string|null|undefined} result - Selection
+     */
+    finish(result) {
+        this.modal?.classList.remove('active');
+        document.body.style.overflow = '';
+        document.removeEventListener('keydown', this.handleKeyDown, true);
+
+        const resolve = this.resolve;
+        this.resolve = null;
+        resolve?.(result);
+    }
+}
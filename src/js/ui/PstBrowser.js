@@ -0,0 +1,283 @@
+/**
+ * PstBrowser UI Component
+ * Browses the folders of an Outlook data file (.pst/.ost, desktop app only). The
+ * backend reads the folder tree and the message lists; a message is only read when it
+ * is clicked and then opens like an .msg file.
+ */
+
+import {
+    closePstFile,
+    exportPstFolderAsMaildir,
+    getFileName,
+    getPstMessages,
+    openPstFile
+} from '../tauri-bridge.js';
+import { getLocale } from '../i18n.js';
+import { escapeHTML } from '../sanitizer.js';
+
+/** Messages listed per page */
+export const PST_PAGE_SIZE = 100;
+
+/**
+ * Flattens a folder tree into display order, skipping the unnamed root
+ * @param {{id: number, name: string, count: number, children: Array}} root
+ * @returns {Array<{id: number, name: string, count: number, depth: number}>}
+ */
+export function flattenPstFolders(root) {
+    const folders = [];
+    const visit = (folder, depth) => {
+        folders.push({ id: folder.id, name: folder.name, count: folder.count, depth });
+        folder.children.forEach((child) => visit(child, depth + 1));
+    };
+    root.children.forEach((child) => visit(child, 0));
+    return folders;
+}
+
+export class PstBrowser {
+    /**
+     * @param {HTMLElement} modalElement - #pstBrowserModal
+     * @param {Object} callbacks
+     * @param {function(string, {id: number, subject: string}): void} callbacks.onOpen -
+     *     Called with the file path and the clicked message
+     * @param {function(string): void} [callbacks.onInfo] - Called with a message after exporting
+     * @param {function(string): void} [callbacks.onError] - Called with a message if reading failed
+     */
+    constructor(modalElement, { onOpen, onInfo = () => {}, onError = () => {} }) {
+        this.modal = modalElement;
+        this.onOpen = onOpen;
+        this.onInfo = onInfo;
+        this.onError = onError;
+        this.content = modalElement?.querySelector('.help-modal-content') || null;
+        this.title = modalElement?.querySelector('.help-modal-title') || null;
+        this.path = null;
+        this.folders = [];
+        this.folderId = null;
+        this.messages = [];
+        this.total = 0;
+        this.loading = false;
+
+        this.handleKeyDown = this.handleKeyDown.bind(this);
+        const backdrop = this.modal?.querySelector('.help-modal-backdrop');
+        const closeBtn = this.modal?.querySelector('.help-modal-close');
+        backdrop?.addEventListener('click', () => this.close());
+        closeBtn?.addEventListener('click', () => this.close());
+        this.content?.addEventListener('click', (e) => this.handleClick(e));
+    }
+
+    /**
+     * Opens a data file and shows its folders
+     * @param {string} path - Path of the .pst/.ost file
+     */
+    async open(path) {
+        if (!this.modal) return;
+
+        let root;
+        try {
+            root = await openPstFile(path);
+        } catch (error) {
+            console.error('Failed to open PST file:', error);
+            this.onError(`Failed to open ${getFileName(path)}: ${error}`);
+            return;
+        }
+
+        if (this.path && this.path !== path) closePstFile(this.path);
+        this.path = path;
+        this.folders = flattenPstFolders(root);
+        this.folderId = null;
+        this.messages = [];
+        this.total = 0;
+        this.render();
+
+        this.modal.classList.add('active');
+        document.body.style.overflow = 'hidden';
+        document.addEventListener('keydown', this.handleKeyDown, true);
+        this.modal.querySelector('.help-modal-close')?.focus();
+    }
+
+    /**
+     * Hides the modal. The file stays open until another one is opened, so messages can
+     * still be picked after reopening the browser.
+     */
+    close() {
+        if (!this.modal) return;
+
+        this.modal.classList.remove('active');
+        document.body.style.overflow = '';
+        document.removeEventListener('keydown', this.handleKeyDown, true);
+    }
+
+    /**
+     * Whether the modal is shown
+     * @returns {boolean}
+     */
+    isOpen() {
+        return Boolean(this.modal?.classList.contains('active'));
+    }
+
+    /**
+     * Closes on Escape before the global shortcuts see the key
+     * @param {KeyboardEvent} event
+     */
+    handleKeyDown(event) {
+        if (event.key === 'Escape') {
+            event.preventDefault();
+            event.stopPropagation();
+            this.close();
+        }
+    }
+
+    /**
+     * Shows the first page of a folder's messages
+     * @param {number} folderId
+     */
+    async selectFolder(folderId) {
+        if (folderId === this.folderId && this.loading) return;
+
+        this.folderId = folderId;
+        this.messages = [];
+        this.total = 0;
+        await this.loadMore(true);
+    }
+
+    /**
+     * Fetches and renders the next page of the selected folder. A page that arrives
+     * after another folder was selected is dropped.
+     * @param {boolean} [first=false] - First page of a newly selected folder
+     */
+    async loadMore(first = false) {
+        if (this.folderId === null) return;
+        if (!first && (this.loading || this.messages.length >= this.total)) return;
+
+        const folderId = this.folderId;
+        this.loading = true;
+        try {
+            const offset = this.messages.length;
+            const page = await getPstMessages(this.path, folderId, offset, PST_PAGE_SIZE);
+            if (folderId !== this.folderId) return;
+            this.total = page.total;
+            this.messages.push(...page.messages);
+        } finally {
+            if (folderId === this.folderId) this.loading = false;
+        }
+        this.render();
+    }
+
+    /**
+     * Writes the selected folder with its subfolders to a Maildir the user chooses
+     */
+    async exportFolder() {
+        if (this.folderId === null) return;
+
+        const result = await exportPstFolderAsMaildir(this.path, this.folderId);
+        if (!result) return;
+
+        if (result.skipped.length === 0) {
+            this.onInfo(`${result.exportedCount} messages exported to ${result.path}`);
+        } else {
+            result.skipped.forEach((item) => console.error(`${item.source}: ${item.reason}`));
+            this.onError(
+                `${result.skipped.length} of ${result.exportedCount + result.skipped.length} ` +
+                    'messages could not be exported'
+            );
+        }
+    }
+
+    /**
+     * Renders the folder list and the messages of the selected folder
+     */
+    render() {
+        if (!this.content) return;
+
+        if (this.title) {
+            this.title.textContent = getFileName(this.path);
+        }
+
+        const folders = this.folders
+            .map(
+                (folder) => `
+                <li>
+                    <button type="button" class="pst-folder ${folder.id === this.folderId ? 'active' : ''}"
+                            data-pst-folder="${folder.id}" style="padding-left: ${0.5 + folder.depth}rem">
+                        <span>${escapeHTML(folder.name || '(unnamed)')}</span>
+                        ${folder.count ? `<span class="pst-folder-count">${folder.count}</span>` : ''}
+                    </button>
+                </li>`
+            )
+            .join('');
+
+        const messages = this.messages
+            .map((message, index) => {
+                const meta = [
+                    message.senderName,
+                    message.date ? new Date(message.date).toLocaleString(getLocale()) : '',
+                    message.hasAttachments ? 'attachments' : ''
+                ].filter(Boolean);
+                return `
+                <li>
+                    <button type="button" class="folder-entry" data-pst-message="${index}">
+                        <span class="folder-entry-subject">
+                            ${escapeHTML(message.subject || '(no subject)')}
+                        </span>
+                        <span class="folder-entry-meta">
+                            ${meta.map((part) => `<span>${escapeHTML(part)}</span>`).join('')}
+                        </span>
+                    </button>
+                </li>`;
+            })
+            .join('');
+
+        const remaining = this.total - this.messages.length;
+        let messagePane = '<p class="usage-stats-note">Choose a folder.</p>';
+        if (this.folderId !== null) {
+            messagePane = this.total
+                ? `<ul class="folder-entries">${messages}</ul>`
+                : '<p class="usage-stats-note">This folder has no messages.</p>';
+            messagePane = `
+                <div class="mbox-actions">
+                    <button class="help-modal-close-btn" data-action="pst-maildir">
+                        Export folder to Maildir…
+                    </button>
+                </div>
+                ${messagePane}`;
+        }
+
+        this.content.innerHTML = `
+            <div class="pst-browser">
+                <ul class="pst-folders">${folders}</ul>
+                <div class="pst-messages">
+                    ${messagePane}
+                    ${
+                        remaining > 0
+                            ? `<button class="help-modal-close-btn" data-action="pst-more">
+                                   Show ${Math.min(remaining, PST_PAGE_SIZE)} more of ${remaining}
+                               </button>`
+                            : ''
+                    }
+                </div>
+            </div>`;
+    }
+
+    /**
+     * Selects a folder, opens a message, exports the folder or loads the next page
+     * @param {MouseEvent} e
+     */
+    async handleClick(e) {
+        const folder = e.target.closest('[data-pst-folder]');
+        const message = e.target.closest('[data-pst-message]');
+
+        try {
+            if (folder) {
+                await this.selectFolder(Number(folder.dataset.pstFolder));
+            } else if (message) {
+                this.onOpen(this.path, this.messages[Number(message.dataset.pstMessage)]);
+            } else if (e.target.closest('[data-action="pst-more"]')) {
+                await this.loadMore();
+            } else if (e.target.closest('[data-action="pst-maildir"]')) {
+                await this.exportFolder();
+            }
+        } catch (error) {
+            console.error('Failed to read PST file:', error);
+            this.onError('Failed to read PST file');
+        }
+    }
+}
@@ -0,0 +1,100 @@
+/**
+ * RecentFilesList UI Component
+ * Shows the recently opened files on the welcome screen (desktop app only)
+ */
+
+import { clearRecentFiles, getFileName, getRecentFiles, pinRecentFile } from '../tauri-bridge.js';
+import { escapeHTML } from '../sanitizer.js';
+
+export class RecentFilesList {
+    /**
+     * @param {HTMLElement} container - #recentFiles
+     * @param {Object} callbacks
+     * @param {Function} callbacks.onOpen - Called with the path of a clicked file
+     * @param {Function} [callbacks.onError] - Called with a message if a change failed
+     */
+    constructor(container, { onOpen, onError = () => {} }) {
+        this.container = container;
+        this.onOpen = onOpen;
+        this.onError = onError;
+        this.files = [];
+
+        this.container?.addEventListener('click', (e) => this.handleClick(e));
+    }
+
+    /**
+     * Reloads the list from the backend
+     */
+    async refresh() {
+        this.render(await getRecentFiles());
+    }
+
+    /**
+     * Renders the list, hidden when there are no recent files
+     * @param {Array<{path: string, openedAt: number, pinned: boolean}>} files
+     */
+    render(files) {
+        if (!this.container) return;
+
+        this.files = files;
+        this.container.hidden = files.length === 0;
+        if (files.length === 0) {
+            this.container.innerHTML = '';
+            return;
+        }
+
+        const hasUnpinned = files.some((file) => !file.pinned);
+        const items = files
+            .map(
+                (file, index) => `
+                <li class="recent-file ${file.pinned ? 'pinned' : ''}">
+                    <button type="button" class="recent-file-open" data-recent-open="${index}"
+                            title="${escapeHTML(file.path)}">
+                        <span class="recent-file-name">${escapeHTML(getFileName(file.path))}</span>
+                        <span class="recent-file-path">${escapeHTML(file.path)}</span>
+                    </button>
+                    <button type="button" class="recent-file-pin" data-recent-pin="${index}"
+                            aria-pressed="${file.pinned}" title="${file.pinned ? 'unpin' : 'pin'}">
+                        <svg xmlns="http://www.w3.org/2000/svg" fill="${file.pinned ? 'currentColor' : 'none'}" viewBox="0 0 24 24" stroke-width="1.5" stroke="currentColor" class="w-4 h-4">
+                            <path stroke-linecap="round" stroke-linejoin="round" d="M17.593 3.322c1.1.128 1.907 1.077 1.907 2.185V21L12 17.25 4.5 21V5.507c0-1.108.806-2.057 1.907-2.185a48.507 48.507 0 0 1 11.186 0Z" />
+                        </svg>
+                    </button>
+                </li>`
+            )
+            .join('');
+
+        this.container.innerHTML = `
+            <div class="recent-files-header">
+                <span>Recent</span>
+                ${hasUnpinned ? '<button type="button" class="recent-files-clear" data-recent-clear>Clear</button>' : ''}
+            </div>
+            <ul class="recent-files-list">${items}</ul>
+        `;
+    }
+
+    /**
+     * Opens, pins or clears depending on the clicked button
+     * @param {MouseEvent} e
+     */
+    async handleClick(e) {
+        const open = e.target.closest('[data-recent-open]');
+        const pin = e.target.closest('[data-recent-pin]');
+        const clear = e.target.closest('[data-recent-clear]');
+
+        try {
+            if (open) {
+                this.onOpen(this.files[Number(open.dataset.recentOpen)].path);
+            } else if (pin) {
+                const file = this.files[Number(pin.dataset.recentPin)];
+                await pinRecentFile(file.path, !file.pinned);
+                await this.refresh();
+            } else if (clear) {
+                await clearRecentFiles();
+                await this.refresh();
+            }
+        } catch (error) {
+            console.error('Failed to update recent files:', error);
+            this.onError('Failed to update recent files');
+        }
+    }
+}
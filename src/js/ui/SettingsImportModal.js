@@ -0,0 +1,133 @@
+/**
+ * SettingsImportModal UI Component
+ * Lists the settings a bundle would change, plus entries it cannot apply,
+ * and lets the user confirm the import
+ */
+
+import { BUNDLE_SETTINGS, formatSettingValue } from '../settingsBundle.js';
+import { escapeHTML } from '../sanitizer.js';
+
+export class SettingsImportModal {
+    /**
+     * @param {HTMLElement} modalElement - #settingsImportModal
+     */
+    constructor(modalElement) {
+        this.modal = modalElement;
+        this.content = modalElement?.querySelector('.help-modal-content') || null;
+        this.resolve = null;
+
+        this.handleKeyDown = this.handleKeyDown.bind(this);
+        const backdrop = this.modal?.querySelector('.help-modal-backdrop');
+        const closeBtn = this.modal?.querySelector('.help-modal-close');
+        backdrop?.addEventListener('click', () => this.finish(false));
+        closeBtn?.addEventListener('click', () => this.finish(false));
+        this.content?.addEventListener('click', (e) => {
+            const action = e.target.closest('[data-action]')?.dataset.action;
+            if (action === 'apply-settings') this.finish(true);
+            if (action === 'cancel-settings') this.finish(false);
+        });
+    }
+
+    /**
+     * Shows the import report and waits for the decision
+     * @param {Object} plan - Result of planSettingsImport
+     * @returns {Promise<boolean>} True if the changes should be applied
+     */
+    review(plan) {
+        if (!this.modal) return Promise.resolve(false);
+
+        this.render(plan);
+        this.modal.classList.add('active');
+        document.body.style.overflow = 'hidden';
+        document.addEventListener('keydown', this.handleKeyDown, true);
+        this.modal.querySelector('.help-modal-close')?.focus();
+
+        return new Promise((resolve) => {
+            this.resolve = resolve;
+        });
+    }
+
+    /**
+     * Builds a list section
+     * @param {string} title - Section heading
+     * @param {Array<[string, string]>} rows - Label and value pairs
+     * @returns {string} HTML
+     */
+    renderSection(title, rows) {
+        if (rows.length === 0) return '';
+
+        const items = rows
+            .map(
+                ([label, value]) => `
+                <div class="shortcut-item">
+                    <dt>${escapeHTML(label)}</dt>
+                    <dd>${escapeHTML(value)}</dd>
+                </div>`
+            )
+            .join('');
+        return `
+            <div class="help-section">
+                <h3>${escapeHTML(title)}</h3>
+                <dl class="shortcut-list">${items}</dl>
+            </div>`;
+    }
+
+    /**
+     * Renders the report into the modal content
+     * @param {Object} plan - Result of planSettingsImport
+     */
+    render(plan) {
+        if (!this.content) return;
+
+        const changeRows = plan.changes.map(({ label, current, incoming }) => [
+            label,
+            `${formatSettingValue(current)} → ${formatSettingValue(incoming)}`
+        ]);
+        const invalidRows = plan.invalid.map((id) => [BUNDLE_SETTINGS[id].label, 'Invalid value']);
+        const unknownRows = plan.unknown.map((id) => [id, 'Not supported by this version']);
+        const count = plan.changes.length;
+        const note = count
+            ? `${count} setting(s) differ from the bundle and will be overwritten.`
+            : 'All settings already match the bundle.';
+
+        this.content.innerHTML = `
+            <p class="usage-stats-note">${escapeHTML(note)}</p>
+            ${this.renderSection('Changes', changeRows)}
+            ${this.renderSection('Skipped', [...invalidRows, ...unknownRows])}
+            <div class="usage-stats-actions">
+                ${count ? `
+                <button class="help-modal-close-btn" data-action="apply-settings">
+                    Apply changes
+                </button>` : ''}
+                <button class="help-modal-close-btn" data-action="cancel-settings">
+                    ${count ? 'Cancel' : 'Close'}
+                </button>
+            </div>`;
+    }
+
+    /**
+     * Cancels on Escape before the global shortcuts see the key
+     * @param {KeyboardEvent} event
+     */
+    handleKeyDown(event) {
+        if (event.key === 'Escape') {
+            event.preventDefault();
+            event.stopPropagation();
+            this.finish(false);
+        }
+    }
+
+    /**
+     * Hides the modal and resolves the pending review
+     * @param {boolean} apply - Whether to apply the changes
+     */
+    finish(apply) {
+        this.modal?.classList.remove('active');
+        document.body.style.overflow = '';
+        document.removeEventListener('keydown', this.handleKeyDown, true);
+
+        const resolve = this.resolve;
+        this.resolve = null;
+        resolve?.(apply);
+    }
+}
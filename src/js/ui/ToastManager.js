@@ -84,6 +84,44 @@ export class ToastManager {
         }, duration);
     }
 
+    /**
+     * Shows a toast that stays until it is closed, e.g. while a file is written
+     * @param {string} message - Text to display
+     * @param {Function} [onCancel] - Adds a Cancel button that calls it
+     * @returns {{update: function(string): void, close: function(): void}} Changes the
+     *     text or removes the toast
+     */
+    progress(message, onCancel) {
+        const container = this.getContainer();
+
+        const toast = document.createElement('div');
+        toast.className = `toast toast-progress flex items-center gap-3 px-4 py-3 rounded-lg shadow-lg transform transition-all duration-300 translate-x-full opacity-0`;
+        toast.className += ` ${TOAST_COLORS.info}`;
+        toast.setAttribute('role', 'status');
+        toast.innerHTML = `
+            <span class="grow"></span>
+            ${onCancel ? '<button class="ml-2 underline hover:opacity-75 focus:outline-none" data-action="cancel-toast">Cancel</button>' : ''}
+        `;
+        const text = toast.querySelector('span');
+        text.textContent = message;
+        toast.querySelector('[data-action="cancel-toast"]')?.addEventListener('click', onCancel);
+
+        container.appendChild(toast);
+        requestAnimationFrame(() => {
+            toast.classList.remove('translate-x-full', 'opacity-0');
+        });
+
+        return {
+            update: (next) => {
+                text.textContent = next;
+            },
+            close: () => {
+                toast.classList.add('translate-x-full', 'opacity-0');
+                setTimeout(() => toast.remove(), 300);
+            }
+        };
+    }
+
     /**
      * Shows an error toast notification
      * @param {string} message - Error message to display
@@ -3,14 +3,68 @@ import { MessageContentRenderer } from './MessageContentRenderer.js';
 import { AttachmentModalManager } from './AttachmentModalManager.js';
 import { ToastManager } from './ToastManager.js';
 import { SearchManager } from '../SearchManager.js';
-import { isTauri, saveFileWithDialog } from '../tauri-bridge.js';
+import {
+    copyFilesToClipboard,
+    decryptSmime,
+    exportMessagesAsMaildir,
+    exportMessagesAsZip,
+    exportMsg,
+    exportRedacted,
+    getFileName,
+    isTauri,
+    pickCertificateFile,
+    runExportPlugin,
+    saveAllAttachments,
+    saveFileWithDialog,
+    speak,
+    startFileDrag,
+    startFilesDrag,
+    stopSpeaking
+} from '../tauri-bridge.js';
+import { arrayBufferToBase64, textToBase64 } from '../encoding.js';
+import { kioskMode } from '../kioskMode.js';
 import {
     getExportFileName,
+    getMessagePlainText,
     getOriginalMessageMimeType,
     messageToEml,
-    messageToHtmlDocument
+    messageToHtmlDocument,
+    messageToJson
 } from '../messageExport.js';
-import { BULK_EXPORT_FORMATS, createBulkExportZipBlob } from '../bulkExport.js';
+import {
+    BULK_EXPORT_FORMATS,
+    createBulkExportZipBlob,
+    verifyBulkExportZip
+} from '../bulkExport.js';
+import {
+    exportChecksumsEnabled,
+    getRedactionOptions,
+    getSpeechVoice
+} from '../UserPreferences.js';
+import { createCustodyReportPdf } from '../custodyReport.js';
+import { emitHookEvent, HOOK_EVENTS } from '../eventHooks.js';
+import { auditLog, AUDIT_ACTIONS } from '../AuditLog.js';
+import { usageStats, USAGE_FEATURES } from '../UsageStats.js';
+import { getTranslationTargetLang, translateMessage } from '../translation.js';
+import { isInlineImageAttachment } from '../helpers.js';
+import { getScanBlockMessage } from '../attachmentScan.js';
+import {
+    isDeferredAttachment,
+    loadAttachmentContent,
+    openAttachmentWithSystem,
+    saveAttachmentWithDialog
+} from '../largeMessage.js';
+import { cancelSave, SAVE_PROGRESS_SIZE } from '../fileSaves.js';
+import { applyDecryptedContent, getEncryptedSmimePart } from '../smime.js';
+import { checkAuthentication } from '../senderAuthentication.js';
+import { checkPhishing } from '../phishingCheck.js';
+import { exportMeeting } from '../meeting.js';
+import { exportContact } from '../contact.js';
+import { printMessage } from '../print.js';
+import { replyToMessage } from '../reply.js';
+import { openInNewWindow } from '../messageWindow.js';
+import { t } from '../i18n.js';
+import { PassphrasePrompt } from './PassphrasePrompt.js';
 
 // Debounce time for attachment clicks (Windows double-click interval)
 const ATTACHMENT_CLICK_DEBOUNCE_MS = 500;
@@ -24,10 +78,16 @@ class UIManager {
         this.messageHandler = messageHandler;
         this.lastAttachmentClickTime = 0;
         this.isBulkExporting = false;
+        /** Status shown in the bulk menu while exporting, if not the ZIP one */
+        this.bulkExportStatus = '';
         this.isSelectionMode = false;
         this.selectionAnchorMessage = null;
         this.longPressTimer = null;
         this.ignoreNextMessageClick = false;
+        this.messageDragActive = false;
+        this.messageDrag = null;
+        this.translationStatus = { available: false };
+        this.readAloudMessage = null;
 
         // Screen elements
         this.welcomeScreen = document.getElementById('welcomeScreen');
@@ -168,6 +228,15 @@ class UIManager {
             messageItems?.addEventListener(eventName, () => this.clearLongPressTimer());
         });
 
+        // Drag a message out of the app as an .eml file
+        messageItems?.addEventListener('dragstart', (e) => this.handleMessageDragStart(e));
+        messageItems?.addEventListener('dragend', (e) => this.handleMessageDragEnd(e));
+
+        // Drag an attachment out of the app as a file
+        document
+            .getElementById('messageViewer')
+            ?.addEventListener('dragstart', (e) => this.handleAttachmentDragStart(e));
+
         this.bulkActionsToggle?.addEventListener('click', (e) => {
             e.stopPropagation();
             this.toggleBulkMenu();
@@ -199,6 +268,43 @@ class UIManager {
                 this.updateBulkActions();
             } else if (action === 'download-zip') {
                 this.downloadBulkZip(button.dataset.format);
+            } else if (action === 'maildir') {
+                this.exportBulkMaildir();
+            } else if (action === 'pii-scan') {
+                this.closeBulkMenu();
+                const scope = this.getBulkExportScope();
+                if (scope?.messages.length > 0) {
+                    window.app?.downloadPiiReport(scope.messages, scope.type);
+                }
+            } else if (action === 'pii-scan-folder') {
+                this.closeBulkMenu();
+                window.app?.scanFolderForPii();
+            } else if (action === 'address-book') {
+                this.closeBulkMenu();
+                const scope = this.getBulkExportScope();
+                if (scope?.messages.length > 0) {
+                    window.app?.downloadAddressBook(scope.messages, scope.type, button.dataset.format);
+                }
+            } else if (action === 'address-book-folder') {
+                this.closeBulkMenu();
+                window.app?.extractFolderAddressBook(button.dataset.format);
+            } else if (action === 'metadata') {
+                this.closeBulkMenu();
+                const scope = this.getBulkExportScope();
+                if (scope?.messages.length > 0) {
+                    window.app?.downloadMetadata(scope.messages, scope.type, button.dataset.format);
+                }
+            } else if (action === 'metadata-folder') {
+                this.closeBulkMenu();
+                window.app?.exportFolderMetadata(button.dataset.format);
+            } else if (action === 'compare') {
+                this.closeBulkMenu();
+                window.app?.compareSelectedMessages();
+            } else if (action === 'custody-report') {
+                this.downloadCustodyReport();
+            } else if (action === 'verify-zip') {
+                this.closeBulkMenu();
+                this.pickBulkZipToVerify();
             }
         });
 
@@ -230,7 +336,91 @@ class UIManager {
                     this.exportMessage(message, btn.dataset.format);
                 }
                 this.closeExportMenus();
-            } else if (action === 'preview' || action === 'download') {
+            } else if (action === 'translate') {
+                const message = this.messageHandler.getMessages()[index];
+                if (message) {
+                    this.translateMessage(message, btn);
+                }
+            } else if (action === 'read-aloud') {
+                const message = this.messageHandler.getMessages()[index];
+                if (message) {
+                    this.toggleReadAloud(message);
+                }
+            } else if (action === 'check-authentication') {
+                const message = this.messageHandler.getMessages()[index];
+                if (message) {
+                    this.checkSenderAuthentication(message, btn);
+                }
+            } else if (action === 'check-phishing') {
+                const message = this.messageHandler.getMessages()[index];
+                if (message) {
+                    this.checkMessagePhishing(message, btn);
+                }
+            } else if (action === 'export-ics') {
+                const message = this.messageHandler.getMessages()[index];
+                if (message) {
+                    this.exportMeetingToIcs(message, btn);
+                }
+            } else if (action === 'reply-message') {
+                const message = this.messageHandler.getMessages()[index];
+                if (message) {
+                    this.replyToMessage(message, btn.dataset.mode, btn.dataset.handoff);
+                }
+                this.closeExportMenus();
+            } else if (action === 'print-message') {
+                const message = this.messageHandler.getMessages()[index];
+                if (message) {
+                    this.printMessage(message, btn);
+                }
+            } else if (action === 'open-in-new-window') {
+                const message = this.messageHandler.getMessages()[index];
+                if (message) {
+                    this.openInNewWindow(message, btn);
+                }
+            } else if (action === 'copy-message-file') {
+                const message = this.messageHandler.getMessages()[index];
+                if (message) {
+                    this.copyMessageToClipboard(message);
+                }
+                this.closeExportMenus();
+            } else if (action === 'export-vcf') {
+                const message = this.messageHandler.getMessages()[index];
+                if (message) {
+                    this.exportContactToVcf(message, btn);
+                }
+            } else if (action === 'decrypt-smime') {
+                const message = this.messageHandler.getMessages()[index];
+                if (message) {
+                    this.decryptSmimeMessage(message, btn.dataset.source);
+                }
+            } else if (action === 'load-remote-images') {
+                const message = this.messageHandler.getMessages()[index];
+                if (message) {
+                    this.messageContent.loadRemoteImages(message);
+                    this.showMessage(message);
+                }
+            } else if (action === 'allow-remote-image-sender') {
+                const message = this.messageHandler.getMessages()[index];
+                if (message?.senderEmail) {
+                    window.app.allowRemoteImagesFrom(message.senderEmail);
+                }
+            } else if (action === 'save-all-attachments') {
+                const message = this.messageHandler.getMessages()[index];
+                if (message) {
+                    this.saveAllAttachments(message);
+                }
+            } else if (action === 'hide-translation') {
+                this.messageContent.hideTranslation();
+            } else if (action === 'hide-authentication') {
+                this.messageContent.hideAuthentication();
+            } else if (action === 'hide-phishing') {
+                this.messageContent.hidePhishingCheck();
+            } else if (
+                action === 'preview' ||
+                action === 'download' ||
+                action === 'open-external' ||
+                action === 'copy-attachment'
+            ) {
                 // Debounce attachment clicks to prevent double-open from Outlook habits
                 const now = Date.now();
                 if (now - this.lastAttachmentClickTime < ATTACHMENT_CLICK_DEBOUNCE_MS) {
@@ -244,6 +434,12 @@ class UIManager {
 
                 if (action === 'preview') {
                     this.modal.open(attachments[attIdx]);
+                } else if (action === 'open-external') {
+                    e.stopPropagation();
+                    this.openAttachmentExternally(attachments[attIdx]);
+                } else if (action === 'copy-attachment') {
+                    e.stopPropagation();
+                    this.copyAttachmentToClipboard(attachments[attIdx]);
                 } else {
                     e.stopPropagation();
                     this.downloadAttachment(attachments[attIdx]);
@@ -396,6 +592,149 @@ class UIManager {
         this.updateBulkActions();
     }
 
+    /**
+     * Starts dragging a message out of the app as an .eml file.
+     * The desktop app hands the file to a native drag session; browsers that support
+     * the DownloadURL drag type (Chromium) create the file at the drop target.
+     * @param {DragEvent} e - Drag event from the message list
+     */
+    handleMessageDragStart(e) {
+        const item = e.target.closest?.('[data-message-index]');
+        const message = item
+            ? this.messageHandler.getMessages()[parseInt(item.dataset.messageIndex, 10)]
+            : null;
+        if (!message) return;
+
+        this.clearLongPressTimer();
+        const fileName = getExportFileName(message, 'eml');
+        const eml = messageToEml(message);
+
+        // Dragging one of several selected messages drags all of them
+        const selected = this.messageHandler.getSelectedMessages?.() || [];
+        if (isTauri() && selected.length > 1 && selected.includes(message)) {
+            e.preventDefault();
+            this.dragFilesOut(
+                selected.map((selectedMessage) => ({
+                    fileName: getExportFileName(selectedMessage, 'eml'),
+                    base64Content: textToBase64(messageToEml(selectedMessage))
+                })),
+                (dropped) =>
+                    selected.forEach((selectedMessage) =>
+                        this.recordExport(dropped, selectedMessage, 'eml')
+                    ),
+                'Failed to drag emails'
+            );
+            return;
+        }
+
+        if (isTauri()) {
+            // Replace the webview's drag with a native file drag
+            e.preventDefault();
+            this.messageDragActive = true;
+            startFileDrag(textToBase64(eml), fileName)
+                .then((dropped) => this.recordExport(dropped, message, 'eml', fileName))
+                .catch((error) => {
+                    console.error('Failed to drag message:', error);
+                    this.showError('Failed to drag email');
+                })
+                .finally(() => {
+                    this.messageDragActive = false;
+                });
+            return;
+        }
+
+        if (!e.dataTransfer) return;
+        const url = URL.createObjectURL(this.createTextBlob(eml, 'message/rfc822'));
+        this.messageDrag = { message, fileName, url };
+        e.dataTransfer.effectAllowed = 'copy';
+        e.dataTransfer.setData('DownloadURL', `message/rfc822:${fileName}:${url}`);
+        e.dataTransfer.setData('text/plain', message.subject || fileName);
+    }
+
+    /**
+     * Starts dragging an attachment out of the app, like handleMessageDragStart does for
+     * messages
+     * @param {DragEvent} e - Drag event from the message viewer
+     */
+    handleAttachmentDragStart(e) {
+        const item = e.target.closest?.('[data-attachment-index]');
+        const attachment = item
+            ? this.modal.getAttachments()?.[parseInt(item.dataset.attachmentIndex, 10)]
+            : null;
+        if (!attachment?.contentBase64 && !isDeferredAttachment(attachment)) return;
+
+        const fileName = attachment.fileName || 'attachment';
+        if (isTauri()) {
+            e.preventDefault();
+            loadAttachmentContent(attachment)
+                .then(() =>
+                    this.dragFilesOut(
+                        [{ fileName, base64Content: attachment.contentBase64.split(',')[1] || '' }],
+                        (dropped) => {
+                            if (dropped) this.recordAttachmentSave(attachment);
+                        },
+                        'Failed to drag attachment'
+                    )
+                )
+                .catch((error) => {
+                    console.error('Failed to read attachment:', error);
+                    this.showError(`Failed to read ${fileName}`);
+                });
+            return;
+        }
+
+        if (!e.dataTransfer) return;
+        const mimeType = attachment.attachMimeTag || 'application/octet-stream';
+        e.dataTransfer.effectAllowed = 'copy';
+        e.dataTransfer.setData(
+            'DownloadURL',
+            `${mimeType}:${fileName}:${attachment.contentBase64}`
+        );
+        e.dataTransfer.setData('text/plain', fileName);
+    }
+
+    /**
+     * Hands files to a native drag session of the desktop app
+     * @param {Array<{fileName: string, base64Content: string}>} files - Files to drag
+     * @param {function(boolean): void} onDone - Called with whether the files were dropped
+     * @param {string} errorMessage - Shown if the drag fails
+     */
+    dragFilesOut(files, onDone, errorMessage) {
+        this.messageDragActive = true;
+        startFilesDrag(files)
+            .then(onDone)
+            .catch((error) => {
+                console.error(`${errorMessage}:`, error);
+                this.showError(getScanBlockMessage(error) || errorMessage);
+            })
+            .finally(() => {
+                this.messageDragActive = false;
+            });
+    }
+
+    /**
+     * Finishes a browser drag started by handleMessageDragStart
+     * @param {DragEvent} e - Drag event from the message list
+     */
+    handleMessageDragEnd(e) {
+        if (!this.messageDrag) return;
+
+        const { message, fileName, url } = this.messageDrag;
+        this.messageDrag = null;
+        this.recordExport(e.dataTransfer?.dropEffect !== 'none', message, 'eml', fileName);
+        // Give the browser time to fetch the blob for the drop target
+        setTimeout(() => URL.revokeObjectURL(url), 60000);
+    }
+
+    /**
+     * Whether a message or attachment is currently being dragged out of the desktop app.
+     * Drops onto the app's own window during that time are the dragged file.
+     * @returns {boolean}
+     */
+    isDraggingMessageOut() {
+        return this.messageDragActive;
+    }
+
     /**
      * Initialize search input event listeners
      */
@@ -405,6 +744,9 @@ class UIManager {
         // Search input handler with debounce
         this.searchInput.addEventListener('input', (e) => {
             const query = e.target.value;
+            if (query.trim() && !this.searchManager.isSearchActive()) {
+                usageStats.recordFeature(USAGE_FEATURES.SEARCH);
+            }
             this.updateSearchUI(query);
 
             this.searchManager.searchDebounced(query, (results) => {
@@ -508,6 +850,28 @@ class UIManager {
         this.updateBulkActions();
     }
 
+    /**
+     * Runs a search as if it was typed into the search input
+     * @param {string} query - Search query, empty clears the search
+     * @returns {Array} Matching messages
+     */
+    applySearch(query) {
+        if (!query.trim()) {
+            this.clearSearch();
+            return this.messageHandler.getMessages();
+        }
+
+        if (this.searchInput) {
+            this.searchInput.value = query;
+        }
+        this.updateSearchUI(query);
+        const results = this.searchManager.search(query);
+        this.messageList.renderFiltered(results);
+        this.updateSearchResultsCount(results.length, query);
+        this.updateBulkActions();
+        return results;
+    }
+
     /**
      * Focus the search input
      */
@@ -560,9 +924,10 @@ class UIManager {
         const headerAction = this.renderBulkHeaderAction(scope);
         const itemsDisabled = scope.messages.length === 0 || this.isBulkExporting;
         const canDownloadOriginal = scope.messages.some((msg) => msg?._rawBuffer && msg?._fileType);
+        const canExportPdf = this.canExportZipInBackend(scope.messages);
 
         const body = this.isBulkExporting
-            ? '<div class="bulk-actions-status">Preparing ZIP…</div>'
+            ? `<div class="bulk-actions-status">${this.bulkExportStatus || 'Preparing ZIP…'}</div>`
             : Object.keys(BULK_EXPORT_FORMATS)
                 .map((format) => {
                     const disabled =
@@ -578,19 +943,116 @@ class UIManager {
                 </button>
             `;
                 })
-                .join('');
+                .join('') +
+              (canExportPdf
+                  ? `
+                <button type="button"
+                        class="bulk-export-item"
+                        data-bulk-action="download-zip"
+                        data-format="pdf"
+                        ${itemsDisabled ? 'disabled' : ''}>
+                    <span>${this.getBulkItemLabel('pdf')}</span>
+                    <span class="bulk-export-item-ext" aria-hidden="true">ZIP</span>
+                </button>
+                <button type="button"
+                        class="bulk-export-item"
+                        data-bulk-action="maildir"
+                        ${itemsDisabled ? 'disabled' : ''}>
+                    <span>Maildir folder…</span>
+                </button>
+            `
+                  : '');
 
         const tip =
             !this.isBulkExporting && scope.type === 'all'
                 ? '<div class="bulk-actions-tip">Select rows or use search to narrow this list</div>'
                 : '';
 
+        const compare =
+            isTauri() && selectedMessages.length === 2
+                ? `
+                <button type="button" class="bulk-export-item" data-bulk-action="compare">
+                    <span>Compare the 2 selected</span>
+                </button>`
+                : '';
+        const verify = this.isBulkExporting
+            ? ''
+            : `${compare}
+                <button type="button"
+                        class="bulk-export-item"
+                        data-bulk-action="custody-report"
+                        ${itemsDisabled ? 'disabled' : ''}>
+                    <span>Chain-of-custody report</span>
+                    <span class="bulk-export-item-ext" aria-hidden="true">PDF</span>
+                </button>
+                <button type="button"
+                        class="bulk-export-item"
+                        data-bulk-action="pii-scan"
+                        ${itemsDisabled ? 'disabled' : ''}>
+                    <span>Scan for personal data</span>
+                    <span class="bulk-export-item-ext" aria-hidden="true">CSV</span>
+                </button>
+                <button type="button" class="bulk-export-item" data-bulk-action="pii-scan-folder">
+                    <span>Scan a folder for personal data…</span>
+                </button>
+                <button type="button"
+                        class="bulk-export-item"
+                        data-bulk-action="address-book"
+                        data-format="csv"
+                        ${itemsDisabled ? 'disabled' : ''}>
+                    <span>Address book</span>
+                    <span class="bulk-export-item-ext" aria-hidden="true">CSV</span>
+                </button>
+                <button type="button"
+                        class="bulk-export-item"
+                        data-bulk-action="address-book"
+                        data-format="vcf"
+                        ${itemsDisabled ? 'disabled' : ''}>
+                    <span>Address book</span>
+                    <span class="bulk-export-item-ext" aria-hidden="true">VCF</span>
+                </button>
+                <button type="button"
+                        class="bulk-export-item"
+                        data-bulk-action="address-book-folder"
+                        data-format="csv">
+                    <span>Address book from a folder…</span>
+                    <span class="bulk-export-item-ext" aria-hidden="true">CSV</span>
+                </button>
+                <button type="button"
+                        class="bulk-export-item"
+                        data-bulk-action="metadata"
+                        data-format="csv"
+                        ${itemsDisabled ? 'disabled' : ''}>
+                    <span>Metadata</span>
+                    <span class="bulk-export-item-ext" aria-hidden="true">CSV</span>
+                </button>
+                <button type="button"
+                        class="bulk-export-item"
+                        data-bulk-action="metadata"
+                        data-format="json"
+                        ${itemsDisabled ? 'disabled' : ''}>
+                    <span>Metadata</span>
+                    <span class="bulk-export-item-ext" aria-hidden="true">JSON</span>
+                </button>
+                <button type="button"
+                        class="bulk-export-item"
+                        data-bulk-action="metadata-folder"
+                        data-format="csv">
+                    <span>Metadata of a folder…</span>
+                    <span class="bulk-export-item-ext" aria-hidden="true">CSV</span>
+                </button>
+                <button type="button" class="bulk-export-item" data-bulk-action="verify-zip">
+                    <span>Verify exported ZIP…</span>
+                </button>
+            `;
+
         this.bulkActions.innerHTML = `
             <div class="bulk-actions-header">
                 <span>${scopeLabel}</span>
                 ${headerAction}
             </div>
             ${body}
+            ${verify}
             ${tip}
         `;
     }
@@ -619,6 +1081,7 @@ class UIManager {
         if (format === 'eml') return 'Export as EML';
         if (format === 'html') return 'Export as HTML';
         if (format === 'original') return 'Download originals';
+        if (format === 'pdf') return 'Export as PDF';
         return BULK_EXPORT_FORMATS[format]?.label || format;
     }
 
@@ -680,13 +1143,19 @@ class UIManager {
         const scope = this.getBulkExportScope();
         if (!scope || scope.messages.length === 0 || this.isBulkExporting) return;
 
+        if (format !== 'html' && this.canExportZipInBackend(scope.messages)) {
+            await this.downloadBulkZipInBackend(scope, format);
+            return;
+        }
+
         const exportFormat = BULK_EXPORT_FORMATS[format] ? format : 'eml';
         this.isBulkExporting = true;
         this.updateBulkActions();
 
         try {
             const result = await createBulkExportZipBlob(scope.messages, exportFormat, {
-                scope: scope.type
+                scope: scope.type,
+                checksums: exportChecksumsEnabled()
             });
 
             if (!result.blob || result.exportedCount === 0) {
@@ -694,12 +1163,30 @@ class UIManager {
                 return;
             }
 
-            await this.downloadBlob(
+            const saved = await this.downloadBlob(
                 result.blob,
                 result.fileName,
                 'ZIP exported successfully',
                 'Failed to export ZIP'
             );
+            if (saved) {
+                usageStats.recordFeature(USAGE_FEATURES.BULK_EXPORT);
+                result.exportedMessages.forEach((message) => {
+                    auditLog.record(AUDIT_ACTIONS.EXPORT, {
+                        message,
+                        format: `zip:${exportFormat}`,
+                        detail: result.fileName
+                    });
+                });
+                emitHookEvent(HOOK_EVENTS.BATCH_EXPORT_FINISHED, {
+                    exportPath: typeof saved === 'string' ? saved : '',
+                    fileName: result.fileName,
+                    format: exportFormat,
+                    scope: scope.type,
+                    count: result.exportedCount,
+                    skipped: result.skippedCount
+                });
+            }
 
             if (result.skippedCount > 0) {
                 this.showWarning(`${result.skippedCount} email(s) could not be included`);
@@ -713,6 +1200,126 @@ class UIManager {
         }
     }
 
+    /**
+     * Whether the desktop app can write a ZIP of messages itself: every message must
+     * have been opened from a file, an archive, a PST or an mbox file it can read again
+     * @param {Array} messages - Messages to export
+     * @returns {boolean}
+     */
+    canExportZipInBackend(messages) {
+        return isTauri() && messages.length > 0 && messages.every((msg) => msg?._sourcePath);
+    }
+
+    /**
+     * Exports messages as a ZIP written by the desktop app, which streams it to disk
+     * instead of building it in memory
+     * @param {{type: string, messages: Array}} scope - Active export scope
+     * @param {string} format - 'original', 'eml' or 'pdf'
+     */
+    async downloadBulkZipInBackend(scope, format) {
+        this.isBulkExporting = true;
+        this.updateBulkActions();
+
+        try {
+            const result = await exportMessagesAsZip(scope.messages, format, {
+                scope: scope.type,
+                checksums: exportChecksumsEnabled()
+            });
+            if (!result) return; // Cancelled
+
+            const fileName = getFileName(result.path);
+            if (result.exportedCount === 0) {
+                this.showError('No emails are available for this export');
+                return;
+            }
+            this.showInfo('ZIP exported successfully');
+            usageStats.recordFeature(USAGE_FEATURES.BULK_EXPORT);
+            const skippedSources = new Set(result.skipped.map((entry) => entry.source));
+            scope.messages
+                .filter((message) => !skippedSources.has(message._sourcePath))
+                .forEach((message) => {
+                    auditLog.record(AUDIT_ACTIONS.EXPORT, {
+                        message,
+                        format: `zip:${format}`,
+                        detail: fileName
+                    });
+                });
+            emitHookEvent(HOOK_EVENTS.BATCH_EXPORT_FINISHED, {
+                exportPath: result.path,
+                fileName,
+                format,
+                scope: scope.type,
+                count: result.exportedCount,
+                skipped: result.skipped.length
+            });
+
+            if (result.skipped.length > 0) {
+                this.showWarning(`${result.skipped.length} email(s) could not be included`);
+            }
+        } catch (error) {
+            console.error('Failed to export ZIP:', error);
+            this.showError('Failed to export ZIP');
+        } finally {
+            this.isBulkExporting = false;
+            this.updateBulkActions();
+        }
+    }
+
+    /**
+     * Writes the messages of the bulk scope into a Maildir folder (desktop app only);
+     * pinned messages are flagged
+     */
+    async exportBulkMaildir() {
+        const scope = this.getBulkExportScope();
+        if (!scope || scope.messages.length === 0 || this.isBulkExporting) return;
+
+        this.isBulkExporting = true;
+        this.bulkExportStatus = 'Writing Maildir folder…';
+        this.updateBulkActions();
+
+        try {
+            const result = await exportMessagesAsMaildir(scope.messages, (message) =>
+                this.messageHandler.isPinned(message)
+            );
+            if (!result) return; // Cancelled
+
+            if (result.exportedCount === 0) {
+                this.showError('No emails are available for this export');
+                return;
+            }
+            this.showInfo(`${result.exportedCount} email(s) written to the Maildir folder`);
+            const skippedSources = new Set(result.skipped.map((entry) => entry.source));
+            scope.messages
+                .filter((message) => !skippedSources.has(message._sourcePath))
+                .forEach((message) => {
+                    auditLog.record(AUDIT_ACTIONS.EXPORT, {
+                        message,
+                        format: 'maildir',
+                        detail: result.path
+                    });
+                });
+            emitHookEvent(HOOK_EVENTS.BATCH_EXPORT_FINISHED, {
+                exportPath: result.path,
+                fileName: getFileName(result.path),
+                format: 'maildir',
+                scope: scope.type,
+                count: result.exportedCount,
+                skipped: result.skipped.length
+            });
+
+            if (result.skipped.length > 0) {
+                this.showWarning(`${result.skipped.length} email(s) could not be included`);
+            }
+        } catch (error) {
+            console.error('Failed to export Maildir:', error);
+            this.showError('Failed to export Maildir folder');
+        } finally {
+            this.isBulkExporting = false;
+            this.bulkExportStatus = '';
+            this.updateBulkActions();
+        }
+    }
+
     // Screen management
     showWelcomeScreen() {
         this.welcomeScreen.style.display = 'flex';
@@ -727,6 +1334,10 @@ class UIManager {
     // Message rendering - delegated
     updateMessageList() {
         this.syncSelectionMode();
+        // Messages added or removed while grouped by conversation are grouped again
+        if (this.messageHandler.needsThreads?.()) {
+            window.app?.groupConversations();
+        }
         // If search is active, render filtered results, otherwise render all
         if (this.searchManager.isSearchActive()) {
             const results = this.searchManager.search(this.searchManager.getQuery());
@@ -826,14 +1437,81 @@ class UIManager {
         });
     }
 
+    /**
+     * Downloads a chain-of-custody PDF for the current bulk scope
+     */
+    async downloadCustodyReport() {
+        const scope = this.getBulkExportScope();
+        if (!scope || scope.messages.length === 0) return;
+
+        this.closeBulkMenu();
+        try {
+            const report = createCustodyReportPdf(scope.messages);
+            await this.downloadBlob(
+                report.blob,
+                report.fileName,
+                'Report saved successfully',
+                'Failed to save report'
+            );
+        } catch (error) {
+            console.error('Failed to create custody report:', error);
+            this.showError('Failed to create report');
+        }
+    }
+
+    /**
+     * Opens a file picker for a previously exported ZIP and verifies it
+     */
+    pickBulkZipToVerify() {
+        const input = document.createElement('input');
+        input.type = 'file';
+        input.accept = '.zip,application/zip';
+        input.addEventListener('change', () => {
+            if (input.files?.[0]) {
+                this.verifyBulkZip(input.files[0]);
+            }
+        });
+        input.click();
+    }
+
+    /**
+     * Verifies an exported ZIP against its manifest and reports the result
+     * @param {Blob} file - Exported archive
+     * @returns {Promise<Object|null>} Verification result, or null if the archive could not be read
+     */
+    async verifyBulkZip(file) {
+        try {
+            const result = await verifyBulkExportZip(file);
+            if (result.valid) {
+                this.showInfo(`All ${result.checkedCount} file(s) match the manifest`);
+            } else {
+                const problems = result.mismatches.length + result.unlisted.length;
+                this.showError(`${problems} file(s) do not match the manifest`);
+                console.warn('Export verification failed:', result);
+            }
+            return result;
+        } catch (error) {
+            console.error('Failed to verify ZIP:', error);
+            this.showError(error.message || 'Failed to verify ZIP');
+            return null;
+        }
+    }
+
     /**
      * Saves or downloads a blob depending on runtime
      * @param {Blob} blob - Blob to download
      * @param {string} fileName - File name
      * @param {string} successMessage - Toast text on successful Tauri save
      * @param {string} errorMessage - Toast text on failure
+     * @returns {Promise<string|boolean>} Saved path in Tauri, true after a browser download,
+     *   false if cancelled or failed
      */
     async downloadBlob(blob, fileName, successMessage, errorMessage) {
+        if (kioskMode.isEnabled()) {
+            this.showWarning('Saving files is disabled in kiosk mode');
+            return false;
+        }
+
         if (isTauri()) {
             try {
                 const dataUrl = await this.blobToDataUrl(blob);
@@ -841,11 +1519,12 @@ class UIManager {
                 if (saved) {
                     this.showInfo(successMessage);
                 }
+                return saved || false;
             } catch (error) {
                 console.error(`Failed to save ${fileName}:`, error);
                 this.showError(errorMessage);
+                return false;
             }
-            return;
         }
 
         const objectUrl = URL.createObjectURL(blob);
@@ -856,6 +1535,7 @@ class UIManager {
         link.click();
         document.body.removeChild(link);
         URL.revokeObjectURL(objectUrl);
+        return true;
     }
 
     /**
@@ -864,6 +1544,11 @@ class UIManager {
      * @param {string} format - Export format
      */
     async exportMessage(message, format) {
+        if (format?.startsWith('plugin:')) {
+            await this.exportWithPlugin(message, format.slice('plugin:'.length));
+            return;
+        }
+
         if (format === 'original') {
             if (!message?._rawBuffer || !message?._fileType) {
                 this.showError('Original email file is not available');
@@ -874,32 +1559,453 @@ class UIManager {
                 type: getOriginalMessageMimeType(message)
             });
 
-            await this.downloadBlob(
+            const saved = await this.downloadBlob(
                 originalBlob,
                 getExportFileName(message, 'original'),
                 'Email saved successfully',
                 'Failed to save original email'
             );
+            this.recordExport(saved, message, format);
             return;
         }
 
         if (format === 'eml') {
-            await this.downloadBlob(
+            const saved = await this.downloadBlob(
                 this.createTextBlob(messageToEml(message), 'message/rfc822'),
                 getExportFileName(message, 'eml'),
                 'EML exported successfully',
                 'Failed to export EML'
             );
+            this.recordExport(saved, message, format);
+            return;
+        }
+
+        if (format === 'msg') {
+            try {
+                const output = await exportMsg(messageToJson(message));
+                const saved = await this.downloadBlob(
+                    new Blob([output], { type: 'application/vnd.ms-outlook' }),
+                    getExportFileName(message, 'msg'),
+                    'MSG exported successfully',
+                    'Failed to export MSG'
+                );
+                this.recordExport(saved, message, format);
+            } catch (error) {
+                console.error('MSG export failed:', error);
+                this.showError('Failed to export MSG');
+            }
+            return;
+        }
+
+        if (format === 'redacted-eml' || format === 'redacted-msg') {
+            await this.exportRedactedCopy(message, format);
             return;
         }
 
         if (format === 'html') {
-            await this.downloadBlob(
+            const saved = await this.downloadBlob(
                 this.createTextBlob(messageToHtmlDocument(message), 'text/html'),
                 getExportFileName(message, 'html'),
                 'HTML exported successfully',
                 'Failed to export HTML'
             );
+            this.recordExport(saved, message, format);
+        }
+    }
+
+    /**
+     * Exports a sanitized copy of a message with the redaction options of the settings
+     * @param {Object} message - Message object
+     * @param {string} format - 'redacted-eml' or 'redacted-msg'
+     */
+    async exportRedactedCopy(message, format) {
+        const isMsg = format === 'redacted-msg';
+        try {
+            const output = await exportRedacted(
+                messageToJson(message),
+                isMsg ? 'msg' : 'eml',
+                getRedactionOptions()
+            );
+            const saved = await this.downloadBlob(
+                new Blob([output], {
+                    type: isMsg ? 'application/vnd.ms-outlook' : 'message/rfc822'
+                }),
+                getExportFileName(message, format),
+                'Redacted copy exported successfully',
+                'Failed to export redacted copy'
+            );
+            this.recordExport(saved, message, format);
+        } catch (error) {
+            console.error('Redacted export failed:', error);
+            this.showError('Failed to export redacted copy');
+        }
+    }
+
+    /**
+     * Sets the installed export plugins and re-renders the export menu
+     * @param {Array<{id: string, name: string, extension: string, mimeType: string}>} plugins
+     */
+    setExportPlugins(plugins) {
+        this.exportPlugins = Array.isArray(plugins) ? plugins : [];
+        this.messageContent.setExportPlugins(this.exportPlugins);
+    }
+
+    /**
+     * Sets the translation service status reported by the backend
+     * @param {{available: boolean, defaultTargetLang: string|null}} status - Translation status
+     */
+    setTranslationStatus(status) {
+        this.translationStatus = status || { available: false };
+        this.messageContent.setTranslationAvailable(this.translationStatus.available);
+    }
+
+    /**
+     * Translates the displayed message and shows the result above its body
+     * @param {Object} message - Message object
+     * @param {HTMLElement} [button] - Translate button, disabled while the request runs
+     */
+    async translateMessage(message, button) {
+        const targetLang = getTranslationTargetLang(this.translationStatus);
+        if (button) button.disabled = true;
+
+        try {
+            const translation = await translateMessage(message, targetLang);
+            usageStats.recordFeature(USAGE_FEATURES.TRANSLATE);
+            auditLog.record(AUDIT_ACTIONS.TRANSLATE, {
+                message,
+                detail: `${translation.detectedSourceLang || 'auto'} -> ${targetLang}`
+            });
+            // The user may have opened another message in the meantime
+            if (this.messageHandler.getCurrentMessage() === message) {
+                this.messageContent.showTranslation(translation);
+            }
+        } catch (error) {
+            console.error('Translation failed:', error);
+            this.showError(error?.message || 'Translation failed');
+        } finally {
+            if (button) button.disabled = false;
+        }
+    }
+
+    /**
+     * Checks DKIM, SPF and DMARC of the displayed message and shows the report above its body
+     * @param {Object} message - Message object
+     * @param {HTMLElement} [button] - Check button, disabled while the check runs
+     */
+    async checkSenderAuthentication(message, button) {
+        if (button) button.disabled = true;
+
+        try {
+            const report = await checkAuthentication(message);
+            // The user may have opened another message in the meantime
+            if (this.messageHandler.getCurrentMessage() === message) {
+                this.messageContent.showAuthentication(report);
+            }
+        } catch (error) {
+            console.error('Sender authentication check failed:', error);
+            this.showError(error?.message || String(error));
+        } finally {
+            if (button) button.disabled = false;
+        }
+    }
+
+    /**
+     * Checks the links and Reply-To of the displayed message for signs of phishing and
+     * shows the findings above its body
+     * @param {Object} message - Message object
+     * @param {HTMLElement} [button] - Check button, disabled while the check runs
+     */
+    async checkMessagePhishing(message, button) {
+        if (button) button.disabled = true;
+
+        try {
+            const report = await checkPhishing(message);
+            // The user may have opened another message in the meantime
+            if (this.messageHandler.getCurrentMessage() === message) {
+                this.messageContent.showPhishingCheck(report);
+            }
+        } catch (error) {
+            console.error('Phishing check failed:', error);
+            this.showError(error?.message || String(error));
+        } finally {
+            if (button) button.disabled = false;
+        }
+    }
+
+    /**
+     * Saves the meeting of a message as an .ics file for a calendar app
+     * @param {Object} message - Message object
+     * @param {HTMLElement} [button] - Export button, disabled while saving
+     */
+    async exportMeetingToIcs(message, button) {
+        if (kioskMode.isEnabled()) {
+            this.showWarning('Saving files is disabled in kiosk mode');
+            return;
+        }
+        if (button) button.disabled = true;
+
+        try {
+            const path = await exportMeeting(message);
+            if (path) this.showInfo('Meeting saved for your calendar');
+        } catch (error) {
+            console.error('Meeting export failed:', error);
+            this.showError(error?.message || String(error));
+        } finally {
+            if (button) button.disabled = false;
+        }
+    }
+
+    /**
+     * Prints a message through the OS print dialog, with the body as shown in the viewer
+     * @param {Object} message - Message object
+     * @param {HTMLElement} [button] - Print button, disabled while the document is prepared
+     */
+    async printMessage(message, button) {
+        if (kioskMode.isEnabled()) {
+            this.showWarning('Printing is disabled in kiosk mode');
+            return;
+        }
+        if (button) button.disabled = true;
+
+        try {
+            const renderedBody =
+                this.messageHandler.getCurrentMessage() === message
+                    ? document.querySelector('#messageViewer .email-content')?.innerHTML
+                    : null;
+            await printMessage(message, { renderedBody: renderedBody ?? null });
+        } catch (error) {
+            console.error('Printing failed:', error);
+            this.showError(error?.message || String(error));
+        } finally {
+            if (button) button.disabled = false;
+        }
+    }
+
+    /**
+     * Opens a message in a window of its own
+     * @param {Object} message - Message object
+     * @param {HTMLElement} [button] - Button, disabled while the window opens
+     */
+    async openInNewWindow(message, button) {
+        if (button) button.disabled = true;
+
+        try {
+            await openInNewWindow(message);
+        } catch (error) {
+            console.error('Opening a message window failed:', error);
+            this.showError(error?.message || String(error));
+        } finally {
+            if (button) button.disabled = false;
+        }
+    }
+
+    /**
+     * Opens a reply or forward of a message in the default mail client
+     * @param {Object} message - Message object
+     * @param {string} mode - 'reply', 'replyAll' or 'forward'
+     * @param {string} [handoff='draft'] - 'draft' or 'mailto'
+     */
+    async replyToMessage(message, mode, handoff = 'draft') {
+        if (kioskMode.isEnabled()) {
+            this.showWarning(t('reply.kioskDisabled'));
+            return;
+        }
+
+        try {
+            await replyToMessage(message, mode, handoff);
+        } catch (error) {
+            console.error('Reply failed:', error);
+            this.showError(error?.message || String(error));
+        }
+    }
+
+    /**
+     * Saves an Outlook contact as a vCard file
+     * @param {Object} message - Message object
+     * @param {HTMLElement} [button] - Export button, disabled while saving
+     */
+    async exportContactToVcf(message, button) {
+        if (kioskMode.isEnabled()) {
+            this.showWarning('Saving files is disabled in kiosk mode');
+            return;
+        }
+        if (button) button.disabled = true;
+
+        try {
+            const path = await exportContact(message);
+            if (path) this.showInfo('Contact saved as vCard');
+        } catch (error) {
+            console.error('Contact export failed:', error);
+            this.showError(error?.message || String(error));
+        } finally {
+            if (button) button.disabled = false;
+        }
+    }
+
+    /**
+     * Offers decrypting S/MIME messages with the OS certificate store
+     * @param {boolean} available - Whether the backend can use the OS certificate store
+     */
+    setSmimeKeystoreAvailable(available) {
+        this.messageContent.setSmimeKeystoreAvailable(available);
+    }
+
+    /**
+     * Loads remote images through the local proxy of the desktop app
+     * @param {string|null} proxyBase - Base URL from getRemoteImageProxy
+     */
+    setRemoteImageProxy(proxyBase) {
+        this.messageContent.setRemoteImageProxy(proxyBase);
+    }
+
+    /**
+     * Decrypts an S/MIME encrypted message and shows its content. With a certificate
+     * file the password is asked for until it works or the user cancels.
+     * @param {Object} message - Message object
+     * @param {'file'|'keystore'} source - Where the private key comes from
+     */
+    async decryptSmimeMessage(message, source) {
+        const part = getEncryptedSmimePart(message);
+        if (!part) return;
+
+        const p7m = part.contentBase64.split(',')[1] || '';
+        let decrypted = null;
+        try {
+            if (source === 'keystore') {
+                decrypted = await decryptSmime(p7m);
+            } else {
+                decrypted = await this.decryptSmimeWithFile(p7m);
+                if (!decrypted) return;
+            }
+            applyDecryptedContent(message, decrypted);
+        } catch (error) {
+            console.error('S/MIME decryption failed:', error);
+            this.showError(error?.message || String(error));
+            return;
+        }
+
+        auditLog.record(AUDIT_ACTIONS.DECRYPT, {
+            message,
+            detail: source === 'keystore' ? 'system certificate store' : 'certificate file'
+        });
+        this.searchManager.forget(message);
+        this.updateMessageList();
+        if (this.messageHandler.getCurrentMessage() === message) {
+            this.showMessage(message);
+        }
+    }
+
+    /**
+     * Lets the user pick a certificate file and asks for its password
+     * @param {string} p7m - The smime.p7m as plain base64
+     * @returns {Promise<ArrayBuffer|null>} Decrypted MIME entity, null if cancelled
+     */
+    async decryptSmimeWithFile(p7m) {
+        const path = await pickCertificateFile();
+        if (!path) return null;
+
+        const prompt = new PassphrasePrompt(document.getElementById('passphraseModal'));
+        const fileName = getFileName(path);
+        let error = '';
+        for (;;) {
+            const result = await prompt.certificatePassword({ fileName, error });
+            if (!result) return null;
+            try {
+                return await decryptSmime(p7m, { path, password: result.passphrase });
+            } catch (decryptError) {
+                error = decryptError?.message || String(decryptError);
+            }
+        }
+    }
+
+    /**
+     * Offers reading messages aloud with OS voices
+     * @param {boolean} available - Whether text-to-speech is available
+     */
+    setReadAloudAvailable(available) {
+        this.messageContent.setReadAloudAvailable(available);
+    }
+
+    /**
+     * Starts reading a message aloud, or stops if it is already being read
+     * @param {Object} message - Message object
+     */
+    async toggleReadAloud(message) {
+        if (this.readAloudMessage === message) {
+            this.handleSpeechEnded();
+            await stopSpeaking();
+            return;
+        }
+
+        const body = getMessagePlainText(message);
+        const text = [message.subject, body].filter(Boolean).join('.\n\n');
+        if (!text.trim()) {
+            this.showError('This message has no text to read');
+            return;
+        }
+
+        try {
+            await speak(text, getSpeechVoice());
+            usageStats.recordFeature(USAGE_FEATURES.READ_ALOUD);
+            this.readAloudMessage = message;
+            this.messageContent.setReadAloudMessage(message);
+        } catch (error) {
+            console.error('Failed to read message aloud:', error);
+            this.showError(error?.message || 'Failed to read message aloud');
+        }
+    }
+
+    /**
+     * Resets the read aloud state after speech finished or was stopped
+     */
+    handleSpeechEnded() {
+        this.readAloudMessage = null;
+        this.messageContent.setReadAloudMessage(null);
+    }
+
+    /**
+     * Exports a message through an external export plugin
+     * @param {Object} message - Message object
+     * @param {string} pluginId - Plugin id
+     */
+    async exportWithPlugin(message, pluginId) {
+        const plugin = this.exportPlugins?.find((candidate) => candidate.id === pluginId);
+        if (!plugin) {
+            this.showError('Export plugin is not available');
+            return;
+        }
+
+        try {
+            const output = await runExportPlugin(plugin.id, messageToJson(message));
+            const fileName = getExportFileName(message, 'plugin', plugin.extension);
+            const saved = await this.downloadBlob(
+                new Blob([output], { type: plugin.mimeType }),
+                fileName,
+                `${plugin.name} finished`,
+                `Failed to save ${plugin.name} output`
+            );
+            this.recordExport(saved, message, `plugin:${plugin.id}`, fileName);
+        } catch (error) {
+            console.error(`Export plugin ${plugin.id} failed:`, error);
+            this.showError(`${plugin.name} failed`);
+        }
+    }
+
+    /**
+     * Records a completed single-message export in the audit log and usage statistics
+     * @param {boolean} saved - Whether the export was written
+     * @param {Object} message - Exported message
+     * @param {string} format - Export format
+     * @param {string} [fileName] - Exported file name (defaults to the format's name)
+     */
+    recordExport(saved, message, format, fileName = getExportFileName(message, format)) {
+        if (saved) {
+            usageStats.recordFeature(USAGE_FEATURES.EXPORT);
+            auditLog.record(AUDIT_ACTIONS.EXPORT, {
+                message,
+                format,
+                detail: fileName
+            });
         }
     }
 
@@ -908,18 +2014,36 @@ class UIManager {
      * @param {Object} attachment - Attachment object with contentBase64 and fileName
      */
     async downloadAttachment(attachment) {
+        if (kioskMode.isEnabled()) {
+            this.showWarning('Saving files is disabled in kiosk mode');
+            return;
+        }
+
         if (isTauri()) {
+            // Shown for large files while the backend writes them
+            let progress = null;
+            const label = `Saving ${attachment.fileName}…`;
             try {
-                const saved = await saveFileWithDialog(
-                    attachment.contentBase64,
-                    attachment.fileName
-                );
+                const saved = await saveAttachmentWithDialog(attachment, {
+                    onStart: (job) => {
+                        if (job.total < SAVE_PROGRESS_SIZE) return;
+                        progress = this.toasts.progress(label, () => cancelSave(job.saveId));
+                    },
+                    onProgress: ({ written, total }) => {
+                        progress?.update(`${label} ${Math.floor((written / total) * 100)}%`);
+                    }
+                });
                 if (saved) {
                     this.showInfo('File saved successfully');
+                    this.recordAttachmentSave(attachment);
+                } else if (progress) {
+                    this.showInfo('Saving cancelled');
                 }
             } catch (error) {
                 console.error('Failed to save file:', error);
-                this.showError('Failed to save file');
+                this.showError(getScanBlockMessage(error) || 'Failed to save file');
+            } finally {
+                progress?.close();
             }
         } else {
             // Browser fallback: use traditional download
@@ -929,11 +2053,142 @@ class UIManager {
             document.body.appendChild(link);
             link.click();
             document.body.removeChild(link);
+            this.recordAttachmentSave(attachment);
+        }
+    }
+
+    /**
+     * Opens an attachment with the system's default app. The file is written to the
+     * app's temp directory and removed on exit or when the retention period ends.
+     * @param {Object} attachment - Attachment object
+     */
+    async openAttachmentExternally(attachment) {
+        if (kioskMode.isEnabled()) {
+            this.showWarning('Opening files is disabled in kiosk mode');
+            return;
+        }
+
+        try {
+            await openAttachmentWithSystem(attachment);
+        } catch (error) {
+            console.error('Failed to open attachment:', error);
+            this.showError(getScanBlockMessage(error) || `Failed to open ${attachment.fileName}`);
+        }
+    }
+
+    /**
+     * Puts an attachment on the OS clipboard as a file, to paste it into a folder or
+     * another app (desktop app only)
+     * @param {Object} attachment - Attachment object
+     */
+    async copyAttachmentToClipboard(attachment) {
+        if (kioskMode.isEnabled()) {
+            this.showWarning('Copying files is disabled in kiosk mode');
+            return;
+        }
+
+        const fileName = attachment.fileName || 'attachment';
+        try {
+            await loadAttachmentContent(attachment);
+            await copyFilesToClipboard([
+                { fileName, base64Content: attachment.contentBase64.split(',')[1] || '' }
+            ]);
+            this.showInfo(t('message.copiedAsFile', { name: fileName }));
+            this.recordAttachmentSave(attachment);
+        } catch (error) {
+            console.error('Failed to copy attachment:', error);
+            this.showError(getScanBlockMessage(error) || `Failed to copy ${fileName}`);
+        }
+    }
+
+    /**
+     * Puts a message on the OS clipboard as a file: the original .msg/.eml if it is
+     * available, otherwise an .eml built from it (desktop app only)
+     * @param {Object} message - Message object
+     */
+    async copyMessageToClipboard(message) {
+        if (kioskMode.isEnabled()) {
+            this.showWarning('Copying files is disabled in kiosk mode');
+            return;
+        }
+
+        const format = message._rawBuffer && message._fileType ? 'original' : 'eml';
+        const fileName = getExportFileName(message, format);
+        const base64Content =
+            format === 'original'
+                ? arrayBufferToBase64(message._rawBuffer)
+                : textToBase64(messageToEml(message));
+        try {
+            await copyFilesToClipboard([{ fileName, base64Content }]);
+            this.showInfo(t('message.copiedAsFile', { name: fileName }));
+            this.recordExport(true, message, format, fileName);
+        } catch (error) {
+            console.error('Failed to copy message:', error);
+            this.showError(getScanBlockMessage(error) || 'Failed to copy email');
+        }
+    }
+
+    /**
+     * Saves all attachments of a message (without inline images) into one folder
+     * @param {Object} message - Message object
+     */
+    async saveAllAttachments(message) {
+        if (kioskMode.isEnabled()) {
+            this.showWarning('Saving files is disabled in kiosk mode');
+            return;
         }
+
+        const attachments = (message.attachments || []).filter(
+            (attachment) => !isInlineImageAttachment(attachment)
+        );
+        if (attachments.length === 0) return;
+
+        if (!isTauri()) {
+            // Browsers save each download themselves and number duplicate names
+            attachments.forEach((attachment) => this.downloadAttachment(attachment));
+            return;
+        }
+
+        try {
+            // Attachments of large messages are sent to the backend like all others here
+            await Promise.all(attachments.map(loadAttachmentContent));
+            const results = await saveAllAttachments(attachments);
+            if (!results) return;
+
+            results.forEach((result, i) => {
+                if (!result.error) this.recordAttachmentSave(attachments[i]);
+            });
+            const failed = results.filter((result) => result.error);
+            if (failed.length === 0) {
+                this.showInfo(`${results.length} attachments saved`);
+            } else {
+                failed.forEach((result) => console.error(`${result.fileName}: ${result.error}`));
+                this.showError(
+                    `${failed.length} of ${results.length} attachments could not be saved: ` +
+                        failed.map((result) => result.fileName).join(', ')
+                );
+            }
+        } catch (error) {
+            console.error('Failed to save attachments:', error);
+            this.showError('Failed to save attachments');
+        }
+    }
+
+    /**
+     * Records a saved attachment in the audit log
+     * @param {Object} attachment - Saved attachment
+     */
+    recordAttachmentSave(attachment) {
+        usageStats.recordFeature(USAGE_FEATURES.ATTACHMENT_SAVE);
+        auditLog.record(AUDIT_ACTIONS.SAVE_ATTACHMENT, {
+            message: this.messageHandler.getCurrentMessage(),
+            attachment
+        });
     }
 
     // Attachment modal - delegated
     openAttachmentModal(attachment) {
+        usageStats.recordFeature(USAGE_FEATURES.ATTACHMENT_PREVIEW);
         this.modal.open(attachment);
     }
 
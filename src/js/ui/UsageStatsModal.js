@@ -0,0 +1,165 @@
+/**
+ * UsageStatsModal UI Component
+ * Shows the locally collected usage statistics and lets the user copy them
+ * into a bug report or delete them
+ */
+
+import {
+    usageStats as defaultUsageStats,
+    USAGE_FEATURES,
+    USAGE_FEATURE_LABELS
+} from '../UsageStats.js';
+import { escapeHTML } from '../sanitizer.js';
+import { getAppInfo } from '../tauri-bridge.js';
+
+export class UsageStatsModal {
+    /**
+     * @param {HTMLElement} modalElement - #usageStatsModal
+     * @param {Object} [options]
+     * @param {UsageStats} [options.stats] - Statistics store (dependency injection)
+     * @param {function(string, string): void} [options.notify] - (message, type) toast callback
+     */
+    constructor(modalElement, { stats = defaultUsageStats, notify = () => {} } = {}) {
+        this.modal = modalElement;
+        this.stats = stats;
+        this.notify = notify;
+        this.content = modalElement?.querySelector('.help-modal-content') || null;
+
+        this.handleKeyDown = this.handleKeyDown.bind(this);
+        const backdrop = this.modal?.querySelector('.help-modal-backdrop');
+        const closeBtn = this.modal?.querySelector('.help-modal-close');
+        backdrop?.addEventListener('click', () => this.close());
+        closeBtn?.addEventListener('click', () => this.close());
+        this.content?.addEventListener('click', (e) => {
+            const action = e.target.closest('[data-action]')?.dataset.action;
+            if (action === 'copy-usage-stats') this.copyReport();
+            if (action === 'reset-usage-stats') this.reset();
+        });
+    }
+
+    /**
+     * Whether the modal is shown
+     * @returns {boolean}
+     */
+    isOpen() {
+        return Boolean(this.modal?.classList.contains('active'));
+    }
+
+    /**
+     * Renders the current counts and shows the modal
+     */
+    open() {
+        if (!this.modal) return;
+
+        this.render();
+        this.modal.classList.add('active');
+        document.body.style.overflow = 'hidden';
+        document.addEventListener('keydown', this.handleKeyDown, true);
+        this.modal.querySelector('.help-modal-close')?.focus();
+    }
+
+    /**
+     * Hides the modal
+     */
+    close() {
+        if (!this.modal) return;
+
+        this.modal.classList.remove('active');
+        document.body.style.overflow = '';
+        document.removeEventListener('keydown', this.handleKeyDown, true);
+    }
+
+    /**
+     * Closes on Escape before the global shortcuts see the key
+     * @param {KeyboardEvent} event
+     */
+    handleKeyDown(event) {
+        if (event.key === 'Escape') {
+            event.preventDefault();
+            event.stopPropagation();
+            this.close();
+        }
+    }
+
+    /**
+     * Builds a list section
+     * @param {string} title - Section heading
+     * @param {Array<[string, number]>} rows - Label and count pairs
+     * @returns {string} HTML
+     */
+    renderSection(title, rows) {
+        const items = rows.length
+            ? rows
+                  .map(
+                      ([label, count]) => `
+                <div class="shortcut-item">
+                    <dt>${escapeHTML(label)}</dt>
+                    <dd>${count}</dd>
+                </div>`
+                  )
+                  .join('')
+            : '<div class="shortcut-item"><dt>None yet</dt><dd></dd></div>';
+
+        return `
+            <div class="help-section">
+                <h3>${escapeHTML(title)}</h3>
+                <dl class="shortcut-list">${items}</dl>
+            </div>`;
+    }
+
+    /**
+     * Renders the statistics into the modal content
+     */
+    render() {
+        if (!this.content) return;
+
+        const { since, filesOpened, features } = this.stats.getStats();
+        const status = this.stats.isEnabled()
+            ? `Counting on this device since ${since ? new Date(since).toLocaleString() : 'now'}.`
+            : 'Counting is off. Enable it in the settings menu.';
+
+        const fileRows = Object.keys(filesOpened)
+            .sort()
+            .map((type) => [`.${type} files`, filesOpened[type]]);
+        const featureRows = Object.values(USAGE_FEATURES)
+            .filter((feature) => features[feature])
+            .map((feature) => [USAGE_FEATURE_LABELS[feature], features[feature]]);
+
+        this.content.innerHTML = `
+            <p class="usage-stats-note">${escapeHTML(status)} These numbers never leave this
+                device unless you copy them yourself.</p>
+            ${this.renderSection('Files opened', fileRows)}
+            ${this.renderSection('Features used', featureRows)}
+            <div class="usage-stats-actions">
+                <button class="help-modal-close-btn" data-action="copy-usage-stats">
+                    Copy for bug report
+                </button>
+                <button class="help-modal-close-btn" data-action="reset-usage-stats">
+                    Delete statistics
+                </button>
+            </div>`;
+    }
+
+    /**
+     * Copies the statistics as plain text to the clipboard
+     */
+    async copyReport() {
+        try {
+            const appInfo = await getAppInfo();
+            await navigator.clipboard.writeText(this.stats.toReport(appInfo));
+            this.notify('Usage statistics copied to clipboard', 'info');
+        } catch (error) {
+            console.error('Failed to copy usage statistics:', error);
+            this.notify('Failed to copy usage statistics', 'error');
+        }
+    }
+
+    /**
+     * Deletes all counts and re-renders
+     */
+    reset() {
+        this.stats.reset();
+        this.render();
+        this.notify('Usage statistics deleted', 'info');
+    }
+}
@@ -0,0 +1,220 @@
+/**
+ * ZipBrowser UI Component
+ * Lists the .msg and .eml files in a ZIP archive (desktop app only), e.g. messages
+ * collected for a case or sent as a bundle. The backend reads the archive directory and
+ * unpacks a message only when it is opened, so nothing has to be extracted by hand.
+ */
+
+import { getFileName, listZipMessages } from '../tauri-bridge.js';
+import { formatSize } from '../i18n.js';
+import { escapeHTML } from '../sanitizer.js';
+
+/**
+ * File name of an archive member without its folders and a `.gz` extension
+ * @param {string} name - Path inside the archive
+ * @returns {string}
+ */
+export function zipMemberFileName(name) {
+    return name.split('/').pop().replace(/\.gz$/i, '');
+}
+
+export class ZipBrowser {
+    /**
+     * @param {HTMLElement} modalElement - #zipBrowserModal
+     * @param {Object} callbacks
+     * @param {function(string, {index: number, name: string}): Promise<void>}
+     *     callbacks.onOpen - Called with the archive path and the message to open
+     * @param {function(string): void} [callbacks.onError] - Called with a message if reading failed
+     */
+    constructor(modalElement, { onOpen, onError = () => {} }) {
+        this.modal = modalElement;
+        this.onOpen = onOpen;
+        this.onError = onError;
+        this.content = modalElement?.querySelector('.help-modal-content') || null;
+        this.title = modalElement?.querySelector('.help-modal-title') || null;
+        this.path = null;
+        this.members = [];
+        this.selected = new Set();
+
+        this.handleKeyDown = this.handleKeyDown.bind(this);
+        const backdrop = this.modal?.querySelector('.help-modal-backdrop');
+        const closeBtn = this.modal?.querySelector('.help-modal-close');
+        backdrop?.addEventListener('click', () => this.close());
+        closeBtn?.addEventListener('click', () => this.close());
+        this.content?.addEventListener('click', (e) => this.handleClick(e));
+        this.content?.addEventListener('change', (e) => this.handleChange(e));
+    }
+
+    /**
+     * Lists the messages of an archive; one that holds a single message opens it directly
+     * @param {string} path - Path of the ZIP file
+     */
+    async open(path) {
+        if (!this.modal) return;
+
+        let members;
+        try {
+            members = await listZipMessages(path);
+        } catch (error) {
+            console.error('Failed to open ZIP file:', error);
+            this.onError(`Failed to open ${getFileName(path)}: ${error}`);
+            return;
+        }
+        if (members.length === 0) {
+            this.onError(`No .msg or .eml files found in ${getFileName(path)}`);
+            return;
+        }
+        if (members.length === 1) {
+            await this.onOpen(path, members[0]);
+            return;
+        }
+
+        this.path = path;
+        this.members = members;
+        this.selected.clear();
+        this.render();
+        this.modal.classList.add('active');
+        document.body.style.overflow = 'hidden';
+        document.addEventListener('keydown', this.handleKeyDown, true);
+        this.modal.querySelector('.help-modal-close')?.focus();
+    }
+
+    /**
+     * Hides the modal
+     */
+    close() {
+        if (!this.modal) return;
+
+        this.modal.classList.remove('active');
+        document.body.style.overflow = '';
+        document.removeEventListener('keydown', this.handleKeyDown, true);
+        this.path = null;
+        this.members = [];
+        this.selected.clear();
+    }
+
+    /**
+     * Whether the modal is shown
+     * @returns {boolean}
+     */
+    isOpen() {
+        return Boolean(this.modal?.classList.contains('active'));
+    }
+
+    /**
+     * Closes on Escape before the global shortcuts see the key
+     * @param {KeyboardEvent} event
+     */
+    handleKeyDown(event) {
+        if (event.key === 'Escape') {
+            event.preventDefault();
+            event.stopPropagation();
+            this.close();
+        }
+    }
+
+    /**
+     * Opens the selected messages in archive order
+     */
+    async openSelected() {
+        const path = this.path;
+        const members = this.members.filter((member) => this.selected.has(member.index));
+        this.close();
+        for (const member of members) {
+            await this.onOpen(path, member);
+        }
+    }
+
+    /**
+     * Renders the members with their folder inside the archive
+     */
+    render() {
+        if (!this.content) return;
+
+        if (this.title) {
+            this.title.textContent = `${getFileName(this.path)} (${this.members.length})`;
+        }
+
+        const rows = this.members
+            .map((member) => {
+                const folder = member.name.split('/').slice(0, -1).join('/');
+                const meta = [folder, formatSize(member.size)].filter(Boolean);
+                const checked = this.selected.has(member.index) ? 'checked' : '';
+                return `
+                <li class="mbox-entry">
+                    <input type="checkbox" data-zip-select="${member.index}" ${checked}
+                           aria-label="Select message">
+                    <button type="button" class="folder-entry" data-zip-entry="${member.index}">
+                        <span class="folder-entry-subject">
+                            ${escapeHTML(zipMemberFileName(member.name))}
+                        </span>
+                        <span class="folder-entry-meta">
+                            ${meta.map((part) => `<span>${escapeHTML(part)}</span>`).join('')}
+                        </span>
+                    </button>
+                </li>`;
+            })
+            .join('');
+
+        this.content.innerHTML = `
+            <div class="mbox-actions">${this.renderActions()}</div>
+            <ul class="folder-entries">${rows}</ul>`;
+    }
+
+    /**
+     * Selection buttons
+     * @returns {string} HTML
+     */
+    renderActions() {
+        const allSelected = this.selected.size === this.members.length;
+        return `
+            <button class="help-modal-close-btn" data-action="zip-select-all">
+                ${allSelected ? 'Select none' : 'Select all'}
+            </button>
+            <button class="help-modal-close-btn" data-action="zip-open-selected"
+                    ${this.selected.size === 0 ? 'disabled' : ''}>
+                Open ${this.selected.size || ''} selected
+            </button>`;
+    }
+
+    /**
+     * Tracks the selection of a message. Only the buttons are redrawn, so the checkbox
+     * keeps the focus.
+     * @param {Event} e
+     */
+    handleChange(e) {
+        const checkbox = e.target.closest('[data-zip-select]');
+        if (!checkbox) return;
+
+        const index = Number(checkbox.dataset.zipSelect);
+        if (checkbox.checked) {
+            this.selected.add(index);
+        } else {
+            this.selected.delete(index);
+        }
+        const actions = this.content.querySelector('.mbox-actions');
+        if (actions) actions.innerHTML = this.renderActions();
+    }
+
+    /**
+     * Opens a clicked message or the selected ones, or changes the selection
+     * @param {MouseEvent} e
+     */
+    async handleClick(e) {
+        const entry = e.target.closest('[data-zip-entry]');
+        if (entry) {
+            const index = Number(entry.dataset.zipEntry);
+            await this.onOpen(this.path, this.members.find((member) => member.index === index));
+            return;
+        }
+
+        const action = e.target.closest('[data-action]')?.dataset.action;
+        if (action === 'zip-select-all') {
+            const allSelected = this.selected.size === this.members.length;
+            this.selected = new Set(allSelected ? [] : this.members.map((member) => member.index));
+            this.render();
+        } else if (action === 'zip-open-selected') {
+            await this.openSelected();
+        }
+    }
+}
@@ -9,3 +9,8 @@ export { MessageContentRenderer } from './MessageContentRenderer.js';
 export { AttachmentModalManager } from './AttachmentModalManager.js';
 export { ToastManager } from './ToastManager.js';
 export { VirtualList } from './VirtualList.js';
+export { UsageStatsModal } from './UsageStatsModal.js';
+export { ProfileChooser } from './ProfileChooser.js';
+export { PassphrasePrompt } from './PassphrasePrompt.js';
+export { SettingsImportModal } from './SettingsImportModal.js';
+export { ComparisonModal } from './ComparisonModal.js';
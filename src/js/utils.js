@@ -13,8 +13,10 @@ import {
 import { BASE64_SIZE_FACTOR, DEFAULT_CHARSET } from './constants.js';
 import { replaceCidReferences } from './cidReplacer.js';
 import { parseAddressHeader } from './addressUtils.js';
+import { decodeTnef, isTnefAttachment } from './tnef.js';
 import {
     binaryStringToBase64,
+    dataUrlToArrayBuffer,
     decodeBase64Text,
     decodeBinaryString,
     decodeBytes,
@@ -357,6 +359,56 @@ function createAttachment(filename, mimeType, base64Content, contentId = null) {
     return attachment;
 }
 
+/**
+ * Replaces winmail.dat (TNEF) attachments with the files packed inside. A body carried
+ * in the TNEF data is used when the message has none of its own. TNEF attachments that
+ * cannot be decoded are kept as they are.
+ * @param {{bodyHTML: string, bodyText: string, attachments: Array}} results - Parsed
+ *     content, updated in place
+ * @returns {{bodyHTML: string, bodyText: string, attachments: Array}} The same object
+ */
+function expandTnefAttachments(results) {
+    if (!results.attachments.some(isTnefAttachment)) return results;
+
+    const attachments = [];
+    results.attachments.forEach((attachment) => {
+        if (!isTnefAttachment(attachment)) {
+            attachments.push(attachment);
+            return;
+        }
+
+        try {
+            const tnef = decodeTnef(new Uint8Array(dataUrlToArrayBuffer(attachment.contentBase64)));
+            tnef.attachments.forEach((item) => {
+                attachments.push(
+                    createAttachment(
+                        item.fileName,
+                        item.mimeType || 'application/octet-stream',
+                        Buffer.from(item.content).toString('base64'),
+                        item.contentId
+                    )
+                );
+            });
+
+            if (!results.bodyHTML && !tnef.bodyHTML && tnef.compressedRtf) {
+                try {
+                    tnef.bodyHTML = convertRTFToHTML(decompressRTF(tnef.compressedRtf));
+                } catch (error) {
+                    console.error('Failed to convert TNEF RTF body:', error);
+                }
+            }
+            results.bodyHTML ||= tnef.bodyHTML;
+            results.bodyText ||= tnef.bodyText;
+        } catch (error) {
+            console.error('Failed to decode TNEF attachment:', error);
+            attachments.push(attachment);
+        }
+    });
+
+    results.attachments = attachments;
+    return results;
+}
+
 /**
  * Parses multipart email content recursively
  * @param {string} content - Raw multipart content
@@ -709,7 +761,13 @@ export function extractMsg(fileBuffer, options = {}) {
 
     // Process attachments
     if (msgInfo.attachments && msgInfo.attachments.length > 0) {
-        msgInfo.attachments = processMsgAttachments(msgReader, msgInfo.attachments);
+        const expanded = expandTnefAttachments({
+            bodyHTML: emailBodyContentHTML,
+            bodyText: emailBodyContent,
+            attachments: processMsgAttachments(msgReader, msgInfo.attachments)
+        });
+        msgInfo.attachments = expanded.attachments;
+        emailBodyContentHTML = expanded.bodyHTML;
 
         if (debugData) {
             debugData.htmlBeforeCid = emailBodyContentHTML;
@@ -892,7 +950,9 @@ export function extractEml(fileBuffer, options = {}) {
 
         if (boundary) {
             // Multipart email
-            results = parseMultipartContent(bodyContent, boundary, 0, detectedCharset);
+            results = expandTnefAttachments(
+                parseMultipartContent(bodyContent, boundary, 0, detectedCharset)
+            );
 
             if (debugData) {
                 debugData.mimeStructure = buildMimeStructure(bodyContent, boundary);
@@ -912,12 +972,14 @@ export function extractEml(fileBuffer, options = {}) {
             }
         } else {
             // Single-part email
-            results = handleSinglePartContent(
-                bodyContent,
-                contentType,
-                headers['content-transfer-encoding'] || '',
-                headers['content-disposition'] || '',
-                detectedCharset
+            results = expandTnefAttachments(
+                handleSinglePartContent(
+                    bodyContent,
+                    contentType,
+                    headers['content-transfer-encoding'] || '',
+                    headers['content-disposition'] || '',
+                    detectedCharset
+                )
             );
             htmlBeforeCid = results.bodyHTML;
         }
@@ -0,0 +1,68 @@
+/**
+ * Webhook Notifier Module
+ * Builds the JSON summary sent to the configured webhook when the watch folder
+ * imports a message (see doc/webhook.md). Delivery, retries and signing are
+ * handled by the desktop backend.
+ */
+
+import { isTauri, sendWebhookNotification } from './tauri-bridge.js';
+import { getAttachmentSha256, getMessageFileSha256 } from './hashing.js';
+import { getContactEmail } from './addressUtils.js';
+
+export const WEBHOOK_EVENTS = {
+    MESSAGE_IMPORTED: 'message.imported'
+};
+
+/**
+ * Builds the webhook payload for an imported message
+ * @param {Object} message - Parsed message
+ * @param {Object} [options] - Payload options
+ * @param {Date} [options.now=new Date()] - Event time
+ * @returns {Object} Payload
+ */
+export function buildWebhookPayload(message, options = {}) {
+    const now = options.now instanceof Date ? options.now : new Date();
+
+    return {
+        event: WEBHOOK_EVENTS.MESSAGE_IMPORTED,
+        occurredAt: now.toISOString(),
+        message: {
+            subject: message?.subject || '',
+            sender: {
+                name: message?.senderName || '',
+                email: message?.senderEmail || ''
+            },
+            recipients: (message?.recipients || []).map((recipient) => ({
+                name: recipient.name || '',
+                email: getContactEmail(recipient) || '',
+                type: recipient.recipType || 'to'
+            })),
+            date: message?.timestamp instanceof Date ? message.timestamp.toISOString() : '',
+            messageHash: message?.messageHash || '',
+            fileName: message?.fileName || '',
+            sourcePath: message?._sourcePath || '',
+            fileSha256: getMessageFileSha256(message),
+            attachments: (message?.attachments || []).map((attachment) => ({
+                fileName: attachment.fileName || '',
+                mimeType: attachment.attachMimeTag || 'application/octet-stream',
+                sha256: getAttachmentSha256(attachment)
+            }))
+        }
+    };
+}
+
+/**
+ * Notifies the webhook about an imported message. Failures are logged, not thrown.
+ * @param {Object} message - Parsed message
+ * @returns {Promise<boolean>} True if the webhook accepted the notification
+ */
+export async function notifyMessageImported(message) {
+    if (!isTauri()) return false;
+
+    try {
+        return await sendWebhookNotification(buildWebhookPayload(message));
+    } catch (error) {
+        console.error('Webhook notification failed:', error);
+        return false;
+    }
+}
@@ -0,0 +1,150 @@
+/**
+ * Tests for AccessibilityManager.js
+ */
+import {
+    AccessibilityManager,
+    ACCESSIBILITY_SETTINGS,
+    SYSTEM_TOGGLE
+} from '../src/js/AccessibilityManager.js';
+
+describe('AccessibilityManager', () => {
+    let manager;
+    let mediaQueries;
+
+    beforeEach(() => {
+        mediaQueries = {};
+        window.matchMedia = jest.fn((query) => {
+            mediaQueries[query] = {
+                matches: false,
+                media: query,
+                addEventListener: jest.fn(),
+                removeEventListener: jest.fn()
+            };
+            return mediaQueries[query];
+        });
+
+        const root = document.documentElement;
+        root.classList.remove('reduce-motion', 'high-contrast');
+        root.style.zoom = '';
+        delete root.dataset.minFontSize;
+
+        manager = new AccessibilityManager();
+    });
+
+    describe('get', () => {
+        test('returns defaults when nothing is saved', () => {
+            expect(manager.get(ACCESSIBILITY_SETTINGS.UI_SCALE)).toBe(100);
+            expect(manager.get(ACCESSIBILITY_SETTINGS.MIN_FONT_SIZE)).toBe(0);
+            expect(manager.get(ACCESSIBILITY_SETTINGS.REDUCED_MOTION)).toBe(SYSTEM_TOGGLE.SYSTEM);
+            expect(manager.get(ACCESSIBILITY_SETTINGS.HIGH_CONTRAST)).toBe(SYSTEM_TOGGLE.SYSTEM);
+        });
+
+        test('ignores invalid saved values', () => {
+            localStorage.setItem('msgReader_uiScale', JSON.stringify(333));
+            expect(manager.get(ACCESSIBILITY_SETTINGS.UI_SCALE)).toBe(100);
+        });
+    });
+
+    describe('set', () => {
+        test('saves, applies and notifies listeners', () => {
+            const listener = jest.fn();
+            manager.addListener(listener);
+
+            expect(manager.set(ACCESSIBILITY_SETTINGS.UI_SCALE, 125)).toBe(true);
+
+            expect(localStorage.setItem).toHaveBeenCalledWith('msgReader_uiScale', '125');
+            expect(document.documentElement.style.zoom).toBe('1.25');
+            expect(listener).toHaveBeenCalledWith(ACCESSIBILITY_SETTINGS.UI_SCALE, 125);
+        });
+
+        test('rejects invalid values', () => {
+            const consoleSpy = jest.spyOn(console, 'warn').mockImplementation();
+
+            expect(manager.set(ACCESSIBILITY_SETTINGS.MIN_FONT_SIZE, 13)).toBe(false);
+            expect(manager.set('unknown', 'on')).toBe(false);
+            expect(localStorage.setItem).not.toHaveBeenCalled();
+            consoleSpy.mockRestore();
+        });
+
+        test('stores the minimum font size on the document', () => {
+            manager.set(ACCESSIBILITY_SETTINGS.MIN_FONT_SIZE, 14);
+            expect(document.documentElement.dataset.minFontSize).toBe('14');
+
+            manager.set(ACCESSIBILITY_SETTINGS.MIN_FONT_SIZE, 0);
+            expect(document.documentElement.dataset.minFontSize).toBeUndefined();
+        });
+    });
+
+    describe('OS preferences', () => {
+        test('follows the OS when set to system', () => {
+            mediaQueries['(prefers-reduced-motion: reduce)'].matches = true;
+            mediaQueries['(forced-colors: active)'].matches = true;
+            manager.apply();
+
+            expect(manager.isReducedMotion()).toBe(true);
+            expect(manager.isHighContrast()).toBe(true);
+            expect(document.documentElement.classList.contains('reduce-motion')).toBe(true);
+            expect(document.documentElement.classList.contains('high-contrast')).toBe(true);
+        });
+
+        test('explicit settings override the OS', () => {
+            mediaQueries['(prefers-contrast: more)'].matches = true;
+            manager.set(ACCESSIBILITY_SETTINGS.HIGH_CONTRAST, SYSTEM_TOGGLE.OFF);
+            manager.set(ACCESSIBILITY_SETTINGS.REDUCED_MOTION, SYSTEM_TOGGLE.ON);
+
+            expect(manager.isHighContrast()).toBe(false);
+            expect(manager.isReducedMotion()).toBe(true);
+        });
+
+        test('re-applies and notifies when the OS preference changes', () => {
+            const listener = jest.fn();
+            manager.addListener(listener);
+            manager.init();
+
+            const query = mediaQueries['(prefers-reduced-motion: reduce)'];
+            query.matches = true;
+            query.addEventListener.mock.calls[0][1]();
+
+            expect(document.documentElement.classList.contains('reduce-motion')).toBe(true);
+            expect(listener).toHaveBeenCalledWith(
+                ACCESSIBILITY_SETTINGS.REDUCED_MOTION,
+                SYSTEM_TOGGLE.SYSTEM
+            );
+        });
+
+        test('ignores OS changes for explicit settings', () => {
+            manager.set(ACCESSIBILITY_SETTINGS.REDUCED_MOTION, SYSTEM_TOGGLE.OFF);
+            const listener = jest.fn();
+            manager.addListener(listener);
+
+            manager.handleSystemChange(ACCESSIBILITY_SETTINGS.REDUCED_MOTION);
+
+            expect(listener).not.toHaveBeenCalled();
+        });
+    });
+
+    describe('applyMinimumFontSize', () => {
+        test('enlarges text below the minimum only', () => {
+            const container = document.createElement('div');
+            container.innerHTML =
+                '<p style="font-size: 9px">small</p><h1 style="font-size: 24px">big</h1>';
+            document.body.appendChild(container);
+            manager.set(ACCESSIBILITY_SETTINGS.MIN_FONT_SIZE, 14);
+
+            manager.applyMinimumFontSize(container);
+
+            expect(container.querySelector('p').style.fontSize).toBe('14px');
+            expect(container.querySelector('h1').style.fontSize).toBe('24px');
+            container.remove();
+        });
+
+        test('does nothing without a minimum', () => {
+            const container = document.createElement('div');
+            container.innerHTML = '<p style="font-size: 9px">small</p>';
+
+            manager.applyMinimumFontSize(container);
+
+            expect(container.querySelector('p').style.fontSize).toBe('9px');
+        });
+    });
+});
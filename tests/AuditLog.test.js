@@ -0,0 +1,145 @@
+/**
+ * Tests for AuditLog.js
+ */
+import {
+    AUDIT_ACTIONS,
+    AUDIT_LOG_STORAGE_KEY,
+    AuditLog,
+    escapeCsvValue
+} from '../src/js/AuditLog.js';
+import { Storage } from '../src/js/storage.js';
+import { sha256Hex } from '../src/js/hashing.js';
+
+describe('AuditLog', () => {
+    let storage;
+    let log;
+    const now = new Date('2024-03-01T10:00:00.000Z');
+    const message = {
+        fileName: 'evidence.msg',
+        subject: 'Quarterly numbers',
+        messageHash: 'abc123',
+        _rawBuffer: new TextEncoder().encode('raw message').buffer
+    };
+
+    beforeEach(() => {
+        storage = new Storage();
+        log = new AuditLog(storage);
+    });
+
+    test('is disabled by default and records nothing', () => {
+        expect(log.isEnabled()).toBe(false);
+        expect(log.record(AUDIT_ACTIONS.OPEN, { message })).toBeNull();
+        expect(log.getEntries()).toEqual([]);
+    });
+
+    test('records entries with file hash and chained hashes when enabled', () => {
+        log.setEnabled(true);
+
+        const first = log.record(AUDIT_ACTIONS.OPEN, {
+            message,
+            sourcePath: '/cases/evidence.msg',
+            now
+        });
+        const second = log.record(AUDIT_ACTIONS.EXPORT, { message, format: 'eml', now });
+
+        expect(first).toMatchObject({
+            sequence: 1,
+            timestamp: '2024-03-01T10:00:00.000Z',
+            action: 'open',
+            fileName: 'evidence.msg',
+            sourcePath: '/cases/evidence.msg',
+            fileSha256: sha256Hex('raw message'),
+            previousHash: ''
+        });
+        expect(second.sequence).toBe(2);
+        expect(second.previousHash).toBe(first.entryHash);
+        expect(log.getEntries()).toHaveLength(2);
+    });
+
+    test('records attachment hashes', () => {
+        log.setEnabled(true);
+
+        const entry = log.record(AUDIT_ACTIONS.SAVE_ATTACHMENT, {
+            message,
+            attachment: { fileName: 'a.txt', contentBase64: 'data:text/plain;base64,YWJj' },
+            now
+        });
+
+        expect(entry.attachmentName).toBe('a.txt');
+        expect(entry.attachmentSha256).toBe(sha256Hex('abc'));
+    });
+
+    test('ignores unknown actions', () => {
+        log.setEnabled(true);
+        expect(log.record('print', { message })).toBeNull();
+    });
+
+    test('keeps entries when disabled again', () => {
+        log.setEnabled(true);
+        log.record(AUDIT_ACTIONS.DELETE, { message, now });
+        log.setEnabled(false);
+
+        expect(log.getEntries()).toHaveLength(1);
+    });
+
+    describe('verify', () => {
+        beforeEach(() => {
+            log.setEnabled(true);
+            log.record(AUDIT_ACTIONS.OPEN, { message, now });
+            log.record(AUDIT_ACTIONS.EXPORT, { message, format: 'html', now });
+            log.record(AUDIT_ACTIONS.DELETE, { message, now });
+        });
+
+        test('accepts an untouched log', () => {
+            expect(log.verify()).toEqual({ valid: true, brokenAt: null });
+        });
+
+        test('detects modified entries', () => {
+            const entries = log.getEntries();
+            entries[1].format = 'eml';
+            storage.set(AUDIT_LOG_STORAGE_KEY, entries);
+
+            expect(log.verify()).toEqual({ valid: false, brokenAt: 2 });
+        });
+
+        test('detects removed entries', () => {
+            const entries = log.getEntries();
+            entries.splice(1, 1);
+            storage.set(AUDIT_LOG_STORAGE_KEY, entries);
+
+            expect(log.verify()).toEqual({ valid: false, brokenAt: 2 });
+        });
+    });
+
+    describe('toCsv', () => {
+        test('writes a header row for an empty log', () => {
+            const lines = log.toCsv().split('\r\n');
+            expect(lines[0]).toContain('sequence,timestamp,action,fileName');
+            expect(lines[1]).toBe('');
+        });
+
+        test('writes one row per entry', () => {
+            log.setEnabled(true);
+            log.record(AUDIT_ACTIONS.OPEN, { message: { ...message, subject: 'Hi, there' }, now });
+
+            const lines = log.toCsv().trim().split('\r\n');
+            expect(lines).toHaveLength(2);
+            expect(lines[1]).toContain('"Hi, there"');
+        });
+    });
+
+    describe('escapeCsvValue', () => {
+        test('quotes separators and quotes', () => {
+            expect(escapeCsvValue('a,"b"')).toBe('"a,""b"""');
+        });
+
+        test('neutralizes spreadsheet formulas', () => {
+            expect(escapeCsvValue('=SUM(A1)')).toBe("'=SUM(A1)");
+        });
+
+        test('renders empty values', () => {
+            expect(escapeCsvValue(null)).toBe('');
+            expect(escapeCsvValue(undefined)).toBe('');
+        });
+    });
+});
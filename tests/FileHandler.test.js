@@ -313,4 +313,88 @@ describe('FileHandler', () => {
             expect(mockUIManager.showError).toHaveBeenCalled();
         });
     });
+
+    describe('dropped paths', () => {
+        test('opens the dropped files in one batch', async () => {
+            const open = jest.spyOn(fileHandler, 'handleFilesFromPaths').mockResolvedValue();
+            mockUIManager.showInfo = jest.fn();
+
+            await fileHandler.handleDroppedPaths(['/mail/a.msg', '/mail/b.eml']);
+
+            expect(open).toHaveBeenCalledWith(['/mail/a.msg', '/mail/b.eml']);
+            expect(mockUIManager.showInfo).not.toHaveBeenCalled();
+        });
+    });
+
+    describe('data files', () => {
+        test('hands .pst, .ost and .mbox paths to the data file handler', () => {
+            const handler = jest.fn();
+            fileHandler.setDataFileHandler(handler);
+
+            expect(fileHandler.openDataFile('/mail/archive.PST')).toBe(true);
+            expect(fileHandler.openDataFile('C:\\Outlook\\cache.ost')).toBe(true);
+            expect(fileHandler.openDataFile('/takeout/All mail.mbox')).toBe(true);
+            expect(fileHandler.openDataFile('/mail/message.msg')).toBe(false);
+            expect(handler).toHaveBeenCalledTimes(3);
+            expect(handler).toHaveBeenCalledWith('/mail/archive.PST', 'pst');
+            expect(handler).toHaveBeenCalledWith('/takeout/All mail.mbox', 'mbox');
+        });
+
+        test('hands ZIP archives and gzip-compressed messages to the data file handler', () => {
+            const handler = jest.fn();
+            fileHandler.setDataFileHandler(handler);
+
+            expect(fileHandler.openDataFile('/cases/bundle.ZIP')).toBe(true);
+            expect(fileHandler.openDataFile('/mail/message.eml.gz')).toBe(true);
+            expect(handler).toHaveBeenCalledWith('/cases/bundle.ZIP', 'zip');
+            expect(handler).toHaveBeenCalledWith('/mail/message.eml.gz', 'gz');
+        });
+
+        test('hands a data file by its detected type', () => {
+            const handler = jest.fn();
+            fileHandler.setDataFileHandler(handler);
+
+            expect(fileHandler.openDataFile('/tmp/export.bin', 'mbox')).toBe(true);
+            expect(fileHandler.openDataFile('/mail/archive.pst', 'msg')).toBe(false);
+            expect(handler).toHaveBeenCalledTimes(1);
+            expect(handler).toHaveBeenCalledWith('/tmp/export.bin', 'mbox');
+        });
+
+        test('falls back to the extension when the type cannot be detected', async () => {
+            await expect(fileHandler.detectType('/mail/Message.EML')).resolves.toBe('eml');
+            await expect(fileHandler.detectType('/mail/archive.ost')).resolves.toBe('ost');
+        });
+
+        test('adds a message read from a data file', () => {
+            const buffer = new ArrayBuffer(8);
+
+            fileHandler.handleMessageBuffer(buffer, 'Hello.msg', '/mail/archive.pst#2097188');
+
+            expect(mockParsers.extractMsg).toHaveBeenCalledWith(buffer, { collectDebugData: false });
+            const [msgInfo, fileName] = mockMessageHandler.addMessage.mock.calls[0];
+            expect(fileName).toBe('Hello.msg');
+            expect(msgInfo._fileType).toBe('msg');
+            expect(msgInfo._sourcePath).toBe('/mail/archive.pst#2097188');
+            expect(mockUIManager.showMessage).toHaveBeenCalled();
+        });
+
+        test('parses a message read from an mbox file as EML', () => {
+            const buffer = new ArrayBuffer(8);
+
+            fileHandler.handleMessageBuffer(buffer, 'Hello.eml', '/mail/Inbox#3', 'eml');
+
+            expect(mockParsers.extractEml).toHaveBeenCalledWith(buffer, { collectDebugData: false });
+            expect(mockParsers.extractMsg).not.toHaveBeenCalled();
+            expect(mockMessageHandler.addMessage.mock.calls[0][0]._fileType).toBe('eml');
+        });
+
+        test('shows an error if the message cannot be parsed', () => {
+            mockParsers.extractMsg.mockReturnValue(null);
+
+            fileHandler.handleMessageBuffer(new ArrayBuffer(8), 'Hello.msg', '/mail/archive.pst#1');
+
+            expect(mockMessageHandler.addMessage).not.toHaveBeenCalled();
+            expect(mockUIManager.showError).toHaveBeenCalledWith('Failed to open: Hello.msg');
+        });
+    });
 });
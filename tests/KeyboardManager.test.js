@@ -629,7 +629,7 @@ describe('KeyboardManager', () => {
 
     describe('edge cases - ignored keys', () => {
         test('function keys are ignored', () => {
-            const event = createKeyEvent('F1');
+            const event = createKeyEvent('F2');
             const preventDefaultSpy = jest.spyOn(event, 'preventDefault');
             keyboardManager.handleKeyDown(event);
             expect(preventDefaultSpy).not.toHaveBeenCalled();
@@ -673,6 +673,30 @@ describe('KeyboardManager', () => {
         });
     });
 
+    describe('context help', () => {
+        test('F1 opens context help', () => {
+            const spy = jest.spyOn(keyboardManager, 'showContextHelp').mockImplementation(() => {});
+            const event = createKeyEvent('F1');
+            const preventDefaultSpy = jest.spyOn(event, 'preventDefault');
+            keyboardManager.handleKeyDown(event);
+            expect(spy).toHaveBeenCalled();
+            expect(preventDefaultSpy).toHaveBeenCalled();
+        });
+
+        test('F1 opens context help while typing in an input', () => {
+            const spy = jest.spyOn(keyboardManager, 'showContextHelp').mockImplementation(() => {});
+            document.getElementById('textInput').focus();
+            keyboardManager.handleKeyDown(createKeyEvent('F1'));
+            expect(spy).toHaveBeenCalled();
+        });
+
+        test('F1 with a modifier is left to the browser', () => {
+            const spy = jest.spyOn(keyboardManager, 'showContextHelp').mockImplementation(() => {});
+            keyboardManager.handleKeyDown(createKeyEvent('F1', { ctrlKey: true }));
+            expect(spy).not.toHaveBeenCalled();
+        });
+    });
+
     describe('accessibility - announcements', () => {
         test('announce sets screen reader text', () => {
             keyboardManager.announce('Test message');
@@ -141,6 +141,64 @@ describe('MessageHandler', () => {
         });
     });
 
+    describe('conversations', () => {
+        beforeEach(() => {
+            messageHandler.messages = [
+                { messageHash: 'a1', timestamp: new Date('2024-01-01') },
+                { messageHash: 'b1', timestamp: new Date('2024-01-15') },
+                { messageHash: 'a2', timestamp: new Date('2024-01-10') },
+                { messageHash: 'c1', timestamp: new Date('2024-01-05') }
+            ];
+            messageHandler.setThreads([
+                { keys: ['b1'] },
+                { keys: ['a1', 'a2'] },
+                { keys: ['c1'] }
+            ]);
+        });
+
+        test('lists the messages of a conversation together, newest first', () => {
+            expect(messageHandler.messages.map((m) => m.messageHash)).toEqual([
+                'b1',
+                'a2',
+                'a1',
+                'c1'
+            ]);
+            expect(messageHandler.getThreadInfo(messageHandler.messages[1])).toEqual({
+                rank: 1,
+                size: 2,
+                position: 0
+            });
+            expect(messageHandler.getThreadInfo(messageHandler.messages[2]).position).toBe(1);
+        });
+
+        test('needs grouping again after messages are added or removed', () => {
+            expect(messageHandler.needsThreads()).toBe(false);
+
+            messageHandler.addMessage({ messageDeliveryTime: '2023-12-01' }, 'new.eml');
+
+            expect(messageHandler.needsThreads()).toBe(true);
+            expect(messageHandler.messages[0].fileName).toBe('new.eml');
+
+            messageHandler.setThreads([{ keys: ['a1'] }]);
+            messageHandler.deleteMessage(0);
+
+            expect(messageHandler.needsThreads()).toBe(true);
+        });
+
+        test('restores the flat list', () => {
+            messageHandler.clearThreads();
+
+            expect(messageHandler.messages.map((m) => m.messageHash)).toEqual([
+                'b1',
+                'a2',
+                'c1',
+                'a1'
+            ]);
+            expect(messageHandler.getThreadInfo(messageHandler.messages[0])).toBeNull();
+            expect(messageHandler.needsThreads()).toBe(false);
+        });
+    });
+
     describe('deleteMessage', () => {
         beforeEach(() => {
             messageHandler.messages = [
@@ -333,6 +391,22 @@ describe('MessageHandler', () => {
         });
     });
 
+    describe('onChange', () => {
+        test('reports added, removed and shown messages until removed', () => {
+            const listener = jest.fn();
+            const unsubscribe = messageHandler.onChange(listener);
+
+            const message = messageHandler.addMessage({ subject: 'Test' }, 'test.msg');
+            messageHandler.setCurrentMessage(message);
+            messageHandler.deleteMessage(0);
+            expect(listener).toHaveBeenCalledTimes(3);
+
+            unsubscribe();
+            messageHandler.addMessage({ subject: 'Other' }, 'other.msg');
+            expect(listener).toHaveBeenCalledTimes(3);
+        });
+    });
+
     describe('getMessages', () => {
         test('returns messages array', () => {
             messageHandler.messages = [{ subject: 'Test' }];
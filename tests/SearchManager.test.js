@@ -3,7 +3,12 @@
  * Tests search functionality, filtering, debouncing, and highlighting
  */
 
-import { SearchManager } from '../src/js/SearchManager.js';
+jest.mock('../src/js/tauri-bridge.js', () => ({
+    searchOpenMessages: jest.fn(() => Promise.resolve(null))
+}));
+
+import { searchOpenMessages } from '../src/js/tauri-bridge.js';
+import { SearchManager, parsePatternQuery } from '../src/js/SearchManager.js';
 
 describe('SearchManager', () => {
     let searchManager;
@@ -368,6 +373,17 @@ describe('SearchManager', () => {
         });
     });
 
+    describe('forget', () => {
+        test('reindexes a message whose content changed', () => {
+            expect(searchManager.search('decrypted')).toHaveLength(0);
+
+            mockMessages[0].bodyContent = 'Decrypted secret content';
+            searchManager.forget(mockMessages[0]);
+
+            expect(searchManager.search('decrypted')).toEqual([mockMessages[0]]);
+        });
+    });
+
     describe('getQuery', () => {
         test('returns current query', () => {
             searchManager.search('test');
@@ -441,6 +457,51 @@ describe('SearchManager', () => {
         });
     });
 
+    describe('rank', () => {
+        test('orders hits by the field the term was found in', () => {
+            mockMessages.push({
+                subject: 'Status',
+                senderName: 'Invoice Bot',
+                senderEmail: 'bot@example.com',
+                body: '',
+                recipients: []
+            });
+
+            const hits = searchManager.rank('invoice');
+
+            expect(hits.map((hit) => hit.message)).toEqual([mockMessages[1], mockMessages[3]]);
+            expect(hits[0].score).toBeGreaterThan(hits[1].score);
+        });
+
+        test('finds attachment names', () => {
+            mockMessages[0].attachments = [{ fileName: 'contract-draft.pdf' }];
+
+            expect(searchManager.rank('contract draft').map((hit) => hit.message)).toEqual([
+                mockMessages[0]
+            ]);
+        });
+
+        test('applies filters', () => {
+            mockMessages[0].timestamp = new Date('2024-01-10T10:00:00Z');
+            mockMessages[1].timestamp = new Date('2024-02-10T10:00:00Z');
+            mockMessages[1].attachments = [{ fileName: 'invoice.pdf' }];
+
+            expect(searchManager.rank('', { sender: 'company.com' })).toHaveLength(2);
+            expect(searchManager.rank('', { hasAttachments: true })[0].message).toBe(
+                mockMessages[1]
+            );
+            expect(
+                searchManager.rank('', { after: '2024-01-01', before: '2024-02-01' })
+            ).toEqual([{ message: mockMessages[0], score: 0 }]);
+        });
+
+        test('does not change the current query', () => {
+            searchManager.rank('invoice');
+
+            expect(searchManager.isSearchActive()).toBe(false);
+        });
+    });
+
     describe('getResultCount', () => {
         test('returns total message count when no search active', () => {
             expect(searchManager.getResultCount()).toBe(3);
@@ -456,4 +517,88 @@ describe('SearchManager', () => {
             expect(searchManager.getResultCount()).toBe(0);
         });
     });
+
+    describe('pattern queries', () => {
+        test('are written between slashes, with i to ignore case', () => {
+            expect(parsePatternQuery('/inv\\w+ #\\d{5}/')).toEqual({
+                pattern: 'inv\\w+ #\\d{5}',
+                caseSensitive: true
+            });
+            expect(parsePatternQuery(' /meeting/i ')).toEqual({
+                pattern: 'meeting',
+                caseSensitive: false
+            });
+            expect(parsePatternQuery('meeting')).toBeNull();
+            expect(parsePatternQuery('//')).toBeNull();
+        });
+
+        test('are matched here outside the desktop app', () => {
+            expect(searchManager.search('/#\\d{5}/')).toEqual([mockMessages[1]]);
+            expect(searchManager.search('/^welcome/')).toEqual([]);
+            expect(searchManager.search('/^welcome/i')).toEqual([mockMessages[2]]);
+            expect(searchManager.getQuery()).toBe('/^welcome/i');
+        });
+
+        test('match nothing if invalid', () => {
+            expect(searchManager.search('/(unclosed/')).toEqual([]);
+        });
+
+        test('use the backend hits with their match locations', async () => {
+            mockMessages.forEach((message, index) => {
+                message.messageHash = `hash-${index}`;
+            });
+            const match = {
+                field: 'body',
+                attachment: null,
+                start: 18,
+                end: 25,
+                before: 'Please find attached the ',
+                text: 'invoice',
+                after: ' for last month.'
+            };
+            searchOpenMessages.mockResolvedValueOnce([
+                { key: 'hash-2', matchCount: 1, matches: [match] }
+            ]);
+
+            await searchManager.loadPatternHits('/invoice/');
+
+            expect(searchOpenMessages).toHaveBeenCalledWith(mockMessages, 'invoice', {
+                regex: true,
+                caseSensitive: true
+            });
+            expect(searchManager.search('/invoice/')).toEqual([mockMessages[2]]);
+            expect(searchManager.getMatches(mockMessages[2])).toEqual([match]);
+            expect(searchManager.getMatches(mockMessages[1])).toEqual([]);
+        });
+
+        test('are matched here if the backend rejects them', async () => {
+            searchOpenMessages.mockRejectedValueOnce('Invalid search pattern: look-around');
+            const warn = jest.spyOn(console, 'warn').mockImplementation(() => {});
+
+            await searchManager.loadPatternHits('/invoice(?= for)/');
+
+            expect(searchManager.search('/invoice(?= for)/')).toEqual([mockMessages[1]]);
+            warn.mockRestore();
+        });
+
+        test('drop a debounced result that finishes after a newer search', async () => {
+            let resolveSlow;
+            searchOpenMessages.mockReturnValueOnce(
+                new Promise((resolve) => {
+                    resolveSlow = resolve;
+                })
+            );
+            const callback = jest.fn();
+
+            searchManager.searchDebounced('/invoice/', callback, 0);
+            await new Promise((resolve) => setTimeout(resolve, 0));
+            searchManager.searchDebounced('Meeting', callback, 0);
+            await new Promise((resolve) => setTimeout(resolve, 0));
+            resolveSlow([]);
+            await new Promise((resolve) => setTimeout(resolve, 0));
+
+            expect(callback).toHaveBeenCalledTimes(1);
+            expect(callback).toHaveBeenCalledWith([mockMessages[0]]);
+        });
+    });
 });
@@ -282,6 +282,46 @@ describe('ThemeManager', () => {
         });
     });
 
+    describe('applySystemTheme', () => {
+        afterEach(() => {
+            document.documentElement.style.removeProperty('--system-accent-color');
+        });
+
+        test('resolves the system theme with the backend instead of the webview', () => {
+            mockMediaQuery.matches = false;
+            themeManager.applySystemTheme({ theme: 'dark', accentColor: null });
+
+            expect(themeManager.resolveTheme(THEMES.SYSTEM)).toBe(THEMES.DARK);
+            expect(document.documentElement.classList.contains('dark')).toBe(true);
+            expect(document.documentElement.dataset.emailTheme).toBe('dark');
+        });
+
+        test('keeps an explicitly chosen theme', () => {
+            localStorage.setItem('msgReader_theme', JSON.stringify(THEMES.LIGHT));
+            themeManager.applySystemTheme({ theme: 'dark', accentColor: null });
+
+            expect(themeManager.getActiveTheme()).toBe(THEMES.LIGHT);
+        });
+
+        test('exposes the accent color as a CSS property', () => {
+            const style = document.documentElement.style;
+            themeManager.applySystemTheme({ theme: 'light', accentColor: '#0078d4' });
+            expect(style.getPropertyValue('--system-accent-color')).toBe('#0078d4');
+
+            themeManager.applySystemTheme({ theme: 'light', accentColor: null });
+            expect(style.getPropertyValue('--system-accent-color')).toBe('');
+        });
+
+        test('notifies listeners when the app follows the system', () => {
+            const callback = jest.fn();
+            themeManager.addListener(callback);
+
+            themeManager.applySystemTheme({ theme: 'dark', accentColor: null });
+
+            expect(callback).toHaveBeenCalledWith('app', THEMES.SYSTEM);
+        });
+    });
+
     describe('init', () => {
         test('applies saved theme on init', () => {
             localStorage.setItem('msgReader_theme', JSON.stringify(THEMES.DARK));
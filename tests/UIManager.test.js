@@ -6,8 +6,18 @@
 // Mock the tauri-bridge module
 jest.mock('../src/js/tauri-bridge.js', () => ({
     isTauri: jest.fn(() => false),
+    exportMessagesAsZip: jest.fn(() =>
+        Promise.resolve({ path: '/exports/messages.zip', exportedCount: 1, skipped: [] })
+    ),
+    copyFilesToClipboard: jest.fn(() => Promise.resolve()),
+    getFileName: jest.fn((path) => path.split('/').pop()),
     saveFileWithDialog: jest.fn(() => Promise.resolve(true)),
-    openWithSystemViewer: jest.fn(() => Promise.resolve())
+    saveAllAttachments: jest.fn(() => Promise.resolve(null)),
+    openWithSystemViewer: jest.fn(() => Promise.resolve()),
+    startFileDrag: jest.fn(() => Promise.resolve(true)),
+    startFilesDrag: jest.fn(() => Promise.resolve(true)),
+    speak: jest.fn(() => Promise.resolve()),
+    stopSpeaking: jest.fn(() => Promise.resolve(true))
 }));
 
 // Mock DOMPurify
@@ -20,7 +30,18 @@ import { ToastManager } from '../src/js/ui/ToastManager.js';
 import { AttachmentModalManager } from '../src/js/ui/AttachmentModalManager.js';
 import { MessageListRenderer } from '../src/js/ui/MessageListRenderer.js';
 import { MessageContentRenderer } from '../src/js/ui/MessageContentRenderer.js';
-import { isTauri, openWithSystemViewer, saveFileWithDialog } from '../src/js/tauri-bridge.js';
+import {
+    copyFilesToClipboard,
+    exportMessagesAsZip,
+    isTauri,
+    openWithSystemViewer,
+    saveAllAttachments,
+    saveFileWithDialog,
+    speak,
+    startFileDrag,
+    startFilesDrag,
+    stopSpeaking
+} from '../src/js/tauri-bridge.js';
 import { setPdfAttachmentOpenMode } from '../src/js/UserPreferences.js';
 
 /**
@@ -515,6 +536,306 @@ describe('UIManager (Facade)', () => {
                 'Failed to export ZIP'
             );
         });
+
+        test('lets the desktop app write ZIPs of messages opened from files', async () => {
+            isTauri.mockReturnValue(true);
+            const message = createMockMessage({ _sourcePath: '/mail/offer.msg' });
+            mockMessageHandler.getSelectedMessages.mockReturnValue([message]);
+            const downloadSpy = jest.spyOn(uiManager, 'downloadBlob').mockResolvedValue();
+
+            uiManager.updateBulkActions();
+            expect(document.getElementById('bulkActions').textContent).toContain('Export as PDF');
+
+            await uiManager.downloadBulkZip('pdf');
+
+            expect(exportMessagesAsZip).toHaveBeenCalledWith([message], 'pdf', {
+                scope: 'selected',
+                checksums: true
+            });
+            expect(downloadSpy).not.toHaveBeenCalled();
+        });
+    });
+
+    describe('Save all attachments', () => {
+        const pdf = {
+            fileName: 'invoice.pdf',
+            attachMimeTag: 'application/pdf',
+            contentBase64: 'data:application/pdf;base64,JVBERg=='
+        };
+        const inlineImage = {
+            fileName: 'logo.png',
+            attachMimeTag: 'image/png',
+            contentId: 'logo',
+            contentBase64: 'data:image/png;base64,iVBORw=='
+        };
+
+        test('saves all attachments except inline images in one folder', async () => {
+            isTauri.mockReturnValue(true);
+            saveAllAttachments.mockResolvedValue([
+                { fileName: 'invoice.pdf', path: '/tmp/invoice.pdf', error: null },
+                { fileName: 'invoice.pdf', path: '/tmp/invoice (1).pdf', error: null }
+            ]);
+            const showInfoSpy = jest.spyOn(uiManager, 'showInfo').mockImplementation(() => {});
+            const message = createMockMessage({ attachments: [pdf, inlineImage, pdf] });
+
+            await uiManager.saveAllAttachments(message);
+
+            expect(saveAllAttachments).toHaveBeenCalledWith([pdf, pdf]);
+            expect(showInfoSpy).toHaveBeenCalledWith('2 attachments saved');
+        });
+
+        test('reports attachments that could not be saved', async () => {
+            isTauri.mockReturnValue(true);
+            saveAllAttachments.mockResolvedValue([
+                { fileName: 'invoice.pdf', path: '/tmp/invoice.pdf', error: null },
+                { fileName: 'notes.txt', path: null, error: 'Failed to create file' }
+            ]);
+            const showErrorSpy = jest.spyOn(uiManager, 'showError').mockImplementation(() => {});
+            const message = createMockMessage({
+                attachments: [pdf, { ...pdf, fileName: 'notes.txt' }]
+            });
+
+            await uiManager.saveAllAttachments(message);
+
+            expect(showErrorSpy).toHaveBeenCalledWith(
+                '1 of 2 attachments could not be saved: notes.txt'
+            );
+        });
+
+        test('does nothing when the folder dialog is cancelled', async () => {
+            isTauri.mockReturnValue(true);
+            saveAllAttachments.mockResolvedValue(null);
+            const showInfoSpy = jest.spyOn(uiManager, 'showInfo').mockImplementation(() => {});
+
+            await uiManager.saveAllAttachments(createMockMessage({ attachments: [pdf, pdf] }));
+
+            expect(showInfoSpy).not.toHaveBeenCalled();
+        });
+    });
+
+    describe('Open attachment with default app', () => {
+        const pdf = {
+            fileName: 'invoice.pdf',
+            attachMimeTag: 'application/pdf',
+            contentBase64: 'data:application/pdf;base64,JVBERg=='
+        };
+
+        test('opens the attachment through the system viewer', async () => {
+            await uiManager.openAttachmentExternally(pdf);
+
+            expect(openWithSystemViewer).toHaveBeenCalledWith(pdf.contentBase64, 'invoice.pdf');
+        });
+
+        test('reports attachments that could not be opened', async () => {
+            openWithSystemViewer.mockRejectedValueOnce(new Error('No handler'));
+            const showErrorSpy = jest.spyOn(uiManager, 'showError').mockImplementation(() => {});
+
+            await uiManager.openAttachmentExternally(pdf);
+
+            expect(showErrorSpy).toHaveBeenCalledWith('Failed to open invoice.pdf');
+        });
+    });
+
+    describe('Copy as file', () => {
+        test('copies an attachment to the clipboard', async () => {
+            const pdf = {
+                fileName: 'invoice.pdf',
+                attachMimeTag: 'application/pdf',
+                contentBase64: 'data:application/pdf;base64,JVBERg=='
+            };
+            const showInfoSpy = jest.spyOn(uiManager, 'showInfo').mockImplementation(() => {});
+
+            await uiManager.copyAttachmentToClipboard(pdf);
+
+            expect(copyFilesToClipboard).toHaveBeenCalledWith([
+                { fileName: 'invoice.pdf', base64Content: 'JVBERg==' }
+            ]);
+            expect(showInfoSpy).toHaveBeenCalledWith('invoice.pdf copied to the clipboard');
+        });
+
+        test('copies the original message file if available', async () => {
+            const message = createMockMessage({
+                _rawBuffer: new TextEncoder().encode('raw').buffer,
+                _fileType: 'msg'
+            });
+
+            await uiManager.copyMessageToClipboard(message);
+
+            expect(copyFilesToClipboard).toHaveBeenCalledWith([
+                { fileName: 'test.msg', base64Content: 'cmF3' }
+            ]);
+        });
+
+        test('copies an EML built from the message otherwise', async () => {
+            await uiManager.copyMessageToClipboard(createMockMessage());
+
+            const [[file]] = copyFilesToClipboard.mock.calls[0];
+            expect(file.fileName).toBe('test.eml');
+            const eml = Buffer.from(file.base64Content, 'base64').toString('utf-8');
+            expect(eml).toContain('Subject: Test Subject');
+        });
+
+        test('reports messages that could not be copied', async () => {
+            copyFilesToClipboard.mockRejectedValueOnce(new Error('needs wl-copy or xclip'));
+            const showErrorSpy = jest.spyOn(uiManager, 'showError').mockImplementation(() => {});
+
+            await uiManager.copyMessageToClipboard(createMockMessage());
+
+            expect(showErrorSpy).toHaveBeenCalledWith('Failed to copy email');
+        });
+    });
+
+    describe('Message drag out', () => {
+        function createDragEvent(type, target) {
+            const event = new Event(type, { bubbles: true, cancelable: true });
+            event.dataTransfer = {
+                data: {},
+                dropEffect: 'copy',
+                setData(format, value) {
+                    this.data[format] = value;
+                }
+            };
+            target.dispatchEvent(event);
+            return event;
+        }
+
+        function renderItem(message) {
+            mockMessageHandler.getMessages.mockReturnValue([message]);
+            uiManager.messageList.renderFiltered([message]);
+            return document.querySelector('[data-message-index="0"]');
+        }
+
+        beforeEach(() => {
+            URL.createObjectURL = jest.fn(() => 'blob:drag-url');
+            URL.revokeObjectURL = jest.fn();
+        });
+
+        test('renders message rows as draggable', () => {
+            expect(renderItem(createMockMessage()).getAttribute('draggable')).toBe('true');
+        });
+
+        test('offers the message as an .eml download in the browser', () => {
+            const item = renderItem(createMockMessage());
+
+            const event = createDragEvent('dragstart', item);
+
+            expect(event.defaultPrevented).toBe(false);
+            expect(event.dataTransfer.data.DownloadURL).toBe(
+                'message/rfc822:test.eml:blob:drag-url'
+            );
+            expect(startFileDrag).not.toHaveBeenCalled();
+        });
+
+        test('starts a native file drag in Tauri', async () => {
+            isTauri.mockReturnValue(true);
+            const item = renderItem(createMockMessage());
+
+            const event = createDragEvent('dragstart', item);
+
+            expect(event.defaultPrevented).toBe(true);
+            expect(uiManager.isDraggingMessageOut()).toBe(true);
+            expect(startFileDrag).toHaveBeenCalledWith(expect.any(String), 'test.eml');
+            const eml = Buffer.from(startFileDrag.mock.calls[0][0], 'base64').toString('utf-8');
+            expect(eml).toContain('Subject: Test Subject');
+
+            await new Promise((resolve) => setTimeout(resolve, 0));
+            expect(uiManager.isDraggingMessageOut()).toBe(false);
+        });
+
+        test('drags all selected messages in Tauri', () => {
+            isTauri.mockReturnValue(true);
+            const first = createMockMessage();
+            const second = createMockMessage({ subject: 'Second', fileName: 'second.msg' });
+            const item = renderItem(first);
+            mockMessageHandler.getSelectedMessages.mockReturnValue([first, second]);
+
+            createDragEvent('dragstart', item);
+
+            expect(startFileDrag).not.toHaveBeenCalled();
+            expect(startFilesDrag).toHaveBeenCalledWith([
+                { fileName: 'test.eml', base64Content: expect.any(String) },
+                { fileName: 'second.eml', base64Content: expect.any(String) }
+            ]);
+        });
+
+        describe('attachments', () => {
+            const pdf = {
+                fileName: 'invoice.pdf',
+                attachMimeTag: 'application/pdf',
+                contentBase64: 'data:application/pdf;base64,JVBERg=='
+            };
+
+            function renderAttachment() {
+                jest.spyOn(uiManager.modal, 'getAttachments').mockReturnValue([pdf]);
+                document.getElementById('messageViewer').innerHTML =
+                    '<div data-action="download" data-attachment-index="0" draggable="true"></div>';
+                return document.querySelector('[data-attachment-index="0"]');
+            }
+
+            test('offers the attachment as a download in the browser', () => {
+                isTauri.mockReturnValue(false);
+
+                const event = createDragEvent('dragstart', renderAttachment());
+
+                expect(event.dataTransfer.data.DownloadURL).toBe(
+                    `application/pdf:invoice.pdf:${pdf.contentBase64}`
+                );
+                expect(startFilesDrag).not.toHaveBeenCalled();
+            });
+
+            test('starts a native file drag in Tauri', () => {
+                isTauri.mockReturnValue(true);
+
+                const event = createDragEvent('dragstart', renderAttachment());
+
+                expect(event.defaultPrevented).toBe(true);
+                expect(startFilesDrag).toHaveBeenCalledWith([
+                    { fileName: 'invoice.pdf', base64Content: 'JVBERg==' }
+                ]);
+            });
+        });
+    });
+
+    describe('Read aloud', () => {
+        function showReadableMessage() {
+            const message = createMockMessage();
+            mockMessageHandler.getMessages.mockReturnValue([message]);
+            mockMessageHandler.getCurrentMessage.mockReturnValue(message);
+            uiManager.setReadAloudAvailable(true);
+            uiManager.showMessage(message);
+            return message;
+        }
+
+        test('hides the read aloud button unless available', () => {
+            const message = createMockMessage();
+            mockMessageHandler.getMessages.mockReturnValue([message]);
+            uiManager.showMessage(message);
+
+            expect(document.querySelector('[data-action="read-aloud"]')).toBeNull();
+        });
+
+        test('reads subject and body with the saved voice', async () => {
+            const message = showReadableMessage();
+
+            await uiManager.toggleReadAloud(message);
+
+            expect(speak).toHaveBeenCalledWith('Test Subject.\n\nPlain text body', '');
+            const button = document.querySelector('[data-action="read-aloud"]');
+            expect(button.classList.contains('active')).toBe(true);
+            expect(button.getAttribute('aria-pressed')).toBe('true');
+        });
+
+        test('stops reading when toggled again', async () => {
+            const message = showReadableMessage();
+
+            await uiManager.toggleReadAloud(message);
+            await uiManager.toggleReadAloud(message);
+
+            expect(stopSpeaking).toHaveBeenCalled();
+            expect(uiManager.readAloudMessage).toBeNull();
+            const button = document.querySelector('[data-action="read-aloud"]');
+            expect(button.classList.contains('active')).toBe(false);
+        });
     });
 
     describe('Edge cases', () => {
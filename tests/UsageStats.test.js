@@ -0,0 +1,101 @@
+/**
+ * Tests for UsageStats.js
+ */
+import {
+    USAGE_FEATURES,
+    USAGE_STATS_STORAGE_KEY,
+    UsageStats
+} from '../src/js/UsageStats.js';
+import { Storage } from '../src/js/storage.js';
+
+describe('UsageStats', () => {
+    let storage;
+    let stats;
+    const now = new Date('2024-03-01T10:00:00.000Z');
+
+    beforeEach(() => {
+        storage = new Storage();
+        stats = new UsageStats(storage);
+    });
+
+    test('is disabled by default and counts nothing', () => {
+        expect(stats.isEnabled()).toBe(false);
+        expect(stats.recordFileOpened('msg')).toBe(false);
+        expect(stats.recordFeature(USAGE_FEATURES.SEARCH)).toBe(false);
+        expect(storage.get(USAGE_STATS_STORAGE_KEY)).toBeNull();
+    });
+
+    test('counts opened files by type and features when enabled', () => {
+        stats.setEnabled(true);
+
+        stats.increment('filesOpened', 'msg', now);
+        stats.recordFileOpened('MSG');
+        stats.recordFileOpened('eml');
+        stats.recordFeature(USAGE_FEATURES.EXPORT);
+
+        expect(stats.getStats()).toEqual({
+            since: '2024-03-01T10:00:00.000Z',
+            filesOpened: { msg: 2, eml: 1 },
+            features: { export: 1 }
+        });
+    });
+
+    test('ignores unknown features', () => {
+        stats.setEnabled(true);
+        expect(stats.recordFeature('keystrokes')).toBe(false);
+        expect(stats.getStats().features).toEqual({});
+    });
+
+    test('keeps counts when disabled again', () => {
+        stats.setEnabled(true);
+        stats.recordFileOpened('eml');
+        stats.setEnabled(false);
+        stats.recordFileOpened('eml');
+
+        expect(stats.getStats().filesOpened).toEqual({ eml: 1 });
+    });
+
+    test('reset deletes all counts', () => {
+        stats.setEnabled(true);
+        stats.recordFeature(USAGE_FEATURES.SEARCH);
+        stats.reset();
+
+        expect(stats.getStats()).toEqual({ since: null, filesOpened: {}, features: {} });
+    });
+
+    test('returns empty stats for corrupted storage', () => {
+        storage.set(USAGE_STATS_STORAGE_KEY, 'broken');
+        expect(stats.getStats()).toEqual({ since: null, filesOpened: {}, features: {} });
+    });
+
+    test('toReport lists version, files and every feature', () => {
+        stats.setEnabled(true);
+        stats.increment('filesOpened', 'msg', now);
+        stats.recordFeature(USAGE_FEATURES.TRANSLATE);
+
+        const report = stats.toReport({
+            version: '1.8.0',
+            commit: '5e7b327',
+            platform: 'linux',
+            arch: 'x86_64',
+            packaging: 'appimage'
+        });
+
+        expect(report).toContain('Version: 1.8.0 (5e7b327)');
+        expect(report).toContain('Platform: linux x86_64');
+        expect(report).toContain('Packaging: appimage');
+        expect(report).toContain('Counting since: 2024-03-01T10:00:00.000Z');
+        expect(report).toContain('  msg: 1');
+        expect(report).toContain('  Translations: 1');
+        expect(report).toContain('  Searches: 0');
+    });
+
+    test('toReport works without any counts', () => {
+        const report = stats.toReport();
+
+        expect(report).toContain('Counting since: never enabled');
+        expect(report).toContain('Files opened:\n  none');
+        expect(report).not.toContain('Version:');
+        expect(report).not.toContain('Platform:');
+    });
+});
@@ -0,0 +1,150 @@
+/**
+ * Tests for addressBook.js
+ */
+import {
+    addressBookToCsv,
+    addressBookToVCard,
+    cleanDisplayName,
+    collectAddressBook,
+    getNameVariantKey,
+    splitName
+} from '../src/js/addressBook.js';
+
+function createMessage(overrides = {}) {
+    return {
+        senderName: 'John Doe',
+        senderEmail: 'john@example.com',
+        recipients: [{ name: 'Jane Roe', email: 'jane@example.com', recipType: 'to' }],
+        timestamp: new Date('2024-03-01T09:00:00Z'),
+        ...overrides
+    };
+}
+
+describe('addressBook', () => {
+    describe('name helpers', () => {
+        test('cleans quotes and drops names that repeat the address', () => {
+            expect(cleanDisplayName('"John  Doe"', 'john@example.com')).toBe('John Doe');
+            expect(cleanDisplayName('JOHN@example.com', 'john@example.com')).toBe('');
+            expect(cleanDisplayName(undefined, 'john@example.com')).toBe('');
+        });
+
+        test('merges "Last, First" and case variants', () => {
+            expect(getNameVariantKey('Doe, John')).toBe(getNameVariantKey('john doe'));
+            expect(getNameVariantKey('John Doe')).not.toBe(getNameVariantKey('John Smith'));
+        });
+
+        test('splits names into family and given name', () => {
+            expect(splitName('Doe, John')).toEqual({ family: 'Doe', given: 'John' });
+            expect(splitName('Mary Ann Smith')).toEqual({ family: 'Smith', given: 'Mary Ann' });
+            expect(splitName('Support')).toEqual({ family: 'Support', given: '' });
+        });
+    });
+
+    describe('collectAddressBook', () => {
+        test('collects unique senders and recipients case-insensitively', () => {
+            const contacts = collectAddressBook([
+                createMessage(),
+                createMessage({
+                    senderName: 'Jane Roe',
+                    senderEmail: 'Jane@Example.com',
+                    recipients: [
+                        { name: 'Doe, John', email: 'john@example.com', recipType: 'to' },
+                        { name: 'Team', smtpAddress: 'team@example.com', recipType: 'cc' }
+                    ],
+                    timestamp: new Date('2024-04-01T09:00:00Z')
+                })
+            ]);
+
+            expect(contacts.map((contact) => contact.email)).toEqual([
+                'jane@example.com',
+                'john@example.com',
+                'team@example.com'
+            ]);
+
+            const john = contacts.find((contact) => contact.email === 'john@example.com');
+            expect(john).toMatchObject({
+                displayName: 'John Doe',
+                nameVariants: ['John Doe'],
+                messageCount: 2,
+                sentCount: 1,
+                receivedCount: 1
+            });
+            expect(john.firstSeen.toISOString()).toBe('2024-03-01T09:00:00.000Z');
+            expect(john.lastSeen.toISOString()).toBe('2024-04-01T09:00:00.000Z');
+        });
+
+        test('keeps distinct names as variants, most frequent first', () => {
+            const contacts = collectAddressBook([
+                createMessage({ senderName: 'J. Doe' }),
+                createMessage(),
+                createMessage()
+            ]);
+
+            const john = contacts.find((contact) => contact.email === 'john@example.com');
+            expect(john.displayName).toBe('John Doe');
+            expect(john.nameVariants).toEqual(['John Doe', 'J. Doe']);
+        });
+
+        test('skips entries without an SMTP address', () => {
+            const contacts = collectAddressBook([
+                createMessage({
+                    senderEmail: '/O=EXCHANGE/OU=FIRST/CN=RECIPIENTS/CN=JOHN',
+                    recipients: [{ name: 'Nobody', email: '' }]
+                })
+            ]);
+
+            expect(contacts).toEqual([]);
+        });
+    });
+
+    describe('addressBookToCsv', () => {
+        test('writes one row per contact', () => {
+            const csv = addressBookToCsv(
+                collectAddressBook([createMessage({ senderName: 'Doe, John' })])
+            );
+            const lines = csv.split('\r\n');
+
+            expect(lines[0]).toBe(
+                'Name,Email,Other Names,Messages,As Sender,As Recipient,First Seen,Last Seen'
+            );
+            expect(lines[1]).toBe(
+                'Jane Roe,jane@example.com,,1,0,1,2024-03-01T09:00:00.000Z,2024-03-01T09:00:00.000Z'
+            );
+            expect(lines[2]).toContain('"Doe, John",john@example.com');
+        });
+    });
+
+    describe('addressBookToVCard', () => {
+        test('writes vCard 3.0 entries', () => {
+            const vcard = addressBookToVCard(
+                collectAddressBook([
+                    createMessage({ senderName: 'Doe, John' }),
+                    createMessage({ senderName: 'Johnny' })
+                ])
+            );
+
+            expect(vcard).toContain('BEGIN:VCARD\r\nVERSION:3.0\r\nFN:John Doe\r\nN:Doe;John;;;');
+            expect(vcard).toContain('EMAIL;TYPE=INTERNET:john@example.com');
+            expect(vcard).toContain('NICKNAME:Johnny');
+            expect(vcard.match(/BEGIN:VCARD/g)).toHaveLength(2);
+            expect(vcard.endsWith('END:VCARD\r\n')).toBe(true);
+        });
+
+        test('uses the address when there is no name and folds long lines', () => {
+            const longName = 'A'.repeat(100);
+            const vcard = addressBookToVCard(
+                collectAddressBook([
+                    createMessage({ senderName: '', recipients: [] }),
+                    createMessage({
+                        senderEmail: 'long@example.com',
+                        senderName: longName,
+                        recipients: []
+                    })
+                ])
+            );
+
+            expect(vcard).toContain('FN:john@example.com');
+            expect(vcard).not.toMatch(/[^\r\n]{76}/);
+        });
+    });
+});
@@ -0,0 +1,120 @@
+/**
+ * Tests for appDataBackup.js
+ */
+import JSZip from 'jszip';
+import {
+    APP_DATA_BACKUP_FORMAT,
+    collectAppData,
+    createAppDataBackup,
+    readAppDataBackup,
+    restoreAppData
+} from '../src/js/appDataBackup.js';
+import { MemoryBackend, Storage } from '../src/js/storage.js';
+
+describe('appDataBackup', () => {
+    const now = new Date('2026-10-14T08:00:00.000Z');
+    let backend;
+    let storage;
+
+    beforeEach(() => {
+        backend = new MemoryBackend();
+        storage = new Storage(backend);
+        storage.set('msgReader_theme', 'dark');
+        storage.set('pinnedMessages', ['abc']);
+        storage.set('msgReader_encryption', { salt: 'x', check: 'y' });
+        storage.set('msgReader_profiles', ['Work']);
+        storage.set('otherApp_setting', 'foreign');
+    });
+
+    test('collects the app data of the active profile only', () => {
+        storage.setNamespace('msgReader_profile:Work:');
+        storage.set('msgReader_theme', 'light');
+        storage.setNamespace('');
+
+        expect(collectAppData(storage)).toEqual({
+            msgReader_theme: 'dark',
+            pinnedMessages: ['abc']
+        });
+    });
+
+    test('creates an archive with a manifest', async () => {
+        const backup = await createAppDataBackup({ storage, appVersion: '1.2.3', now });
+
+        expect(backup.fileName).toBe('msgReader-backup-2026-10-14.zip');
+        expect(backup.count).toBe(2);
+
+        const zip = await JSZip.loadAsync(backup.blob);
+        const manifest = JSON.parse(await zip.file('manifest.json').async('string'));
+        expect(manifest).toMatchObject({
+            format: APP_DATA_BACKUP_FORMAT,
+            version: 1,
+            createdAt: now.toISOString(),
+            appVersion: '1.2.3',
+            profile: null,
+            keys: ['msgReader_theme', 'pinnedMessages']
+        });
+    });
+
+    test('names the archive after the profile', async () => {
+        const backup = await createAppDataBackup({ storage, profile: 'Case 17', now });
+        expect(backup.fileName).toBe('msgReader-backup-Case_17-2026-10-14.zip');
+    });
+
+    test('reads an archive it created', async () => {
+        const backup = await createAppDataBackup({ storage, now });
+        const { manifest, entries } = await readAppDataBackup(backup.blob);
+
+        expect(manifest.format).toBe(APP_DATA_BACKUP_FORMAT);
+        expect(entries.pinnedMessages).toEqual(['abc']);
+    });
+
+    test('rejects archives that are not backups', async () => {
+        const zip = new JSZip();
+        zip.file('manifest.json', JSON.stringify({ format: 'something-else' }));
+        zip.file('app-data.json', '{}');
+        const blob = await zip.generateAsync({ type: 'blob' });
+
+        await expect(readAppDataBackup(blob)).rejects.toThrow('not a msgReader backup');
+    });
+
+    test('rejects backups from newer versions', async () => {
+        const zip = new JSZip();
+        zip.file('manifest.json', JSON.stringify({ format: APP_DATA_BACKUP_FORMAT, version: 99 }));
+        zip.file('app-data.json', '{}');
+        const blob = await zip.generateAsync({ type: 'blob' });
+
+        await expect(readAppDataBackup(blob)).rejects.toThrow('newer version');
+    });
+
+    test('restores entries and removes data missing from the backup', () => {
+        const result = restoreAppData(
+            {
+                msgReader_theme: 'light',
+                msgReader_encryption: { salt: 'z', check: 'z' },
+                msgReader_profiles: ['Other'],
+                otherApp_setting: 'changed'
+            },
+            storage
+        );
+
+        expect(result.restored).toBe(1);
+        expect(result.skipped).toEqual([
+            'msgReader_encryption',
+            'msgReader_profiles',
+            'otherApp_setting'
+        ]);
+        expect(storage.get('msgReader_theme')).toBe('light');
+        expect(storage.get('pinnedMessages')).toBeNull();
+        expect(storage.get('msgReader_encryption')).toEqual({ salt: 'x', check: 'y' });
+        expect(storage.get('msgReader_profiles')).toEqual(['Work']);
+        expect(storage.get('otherApp_setting')).toBe('foreign');
+    });
+
+    test('restores into the active profile', () => {
+        storage.setNamespace('msgReader_profile:Work:');
+        restoreAppData({ pinnedMessages: ['work'] }, storage);
+
+        expect(backend.getItem('msgReader_profile:Work:pinnedMessages')).toBe('["work"]');
+        expect(backend.getItem('pinnedMessages')).toBe('["abc"]');
+    });
+});
@@ -0,0 +1,114 @@
+/**
+ * Tests for attachmentScan.js
+ */
+jest.mock('../src/js/tauri-bridge.js', () => ({
+    isTauri: jest.fn(() => true),
+    checkAttachment: jest.fn()
+}));
+
+import { checkAttachment, isTauri } from '../src/js/tauri-bridge.js';
+import {
+    describeAttachmentCheck,
+    getAttachmentCheck,
+    getScanBlockMessage
+} from '../src/js/attachmentScan.js';
+
+const SHA256 = '2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824';
+const MD5 = '5d41402abc4b2a76b9719d911017c592';
+
+/**
+ * Builds a backend check result
+ * @param {Object} scan - Scan verdict fields
+ * @returns {Object}
+ */
+function check(scan) {
+    return {
+        sha256: SHA256,
+        md5: MD5,
+        size: 5,
+        scan: {
+            status: 'unscanned',
+            engine: null,
+            threat: null,
+            error: null,
+            blocked: false,
+            ...scan
+        }
+    };
+}
+
+/**
+ * Builds an attachment
+ * @returns {Object}
+ */
+function attachment() {
+    return {
+        fileName: 'hello.txt',
+        attachMimeTag: 'text/plain',
+        contentBase64: 'data:text/plain;base64,aGVsbG8='
+    };
+}
+
+describe('attachmentScan', () => {
+    beforeEach(() => {
+        jest.clearAllMocks();
+        isTauri.mockReturnValue(true);
+    });
+
+    test('checks an attachment once', async () => {
+        checkAttachment.mockResolvedValue(check({ status: 'clean', engine: 'clamd' }));
+        const file = attachment();
+
+        expect((await getAttachmentCheck(file)).scan.status).toBe('clean');
+        await getAttachmentCheck(file);
+
+        expect(checkAttachment).toHaveBeenCalledTimes(1);
+        expect(checkAttachment).toHaveBeenCalledWith('aGVsbG8=', 'hello.txt');
+    });
+
+    test('checks again when the scanner could not finish', async () => {
+        checkAttachment.mockResolvedValue(
+            check({ status: 'failed', engine: 'clamd', error: 'Connection refused' })
+        );
+        const file = attachment();
+
+        await getAttachmentCheck(file);
+        await getAttachmentCheck(file);
+
+        expect(checkAttachment).toHaveBeenCalledTimes(2);
+    });
+
+    test('has no check outside the desktop app', async () => {
+        isTauri.mockReturnValue(false);
+
+        expect(await getAttachmentCheck(attachment())).toBeNull();
+        expect(checkAttachment).not.toHaveBeenCalled();
+    });
+
+    test('describes verdicts with the digests as tooltip', () => {
+        const clean = describeAttachmentCheck(check({ status: 'clean', engine: 'clamd' }));
+        expect(clean.text).toBe('No threats found (ClamAV)');
+        expect(clean.title).toBe(`SHA-256: ${SHA256}\nMD5: ${MD5}`);
+
+        const infected = describeAttachmentCheck(
+            check({ status: 'infected', engine: 'amsi', threat: 'Eicar-Signature', blocked: true })
+        );
+        expect(infected.text).toBe('Threat found: Eicar-Signature');
+        expect(infected.status).toBe('infected');
+
+        const failed = describeAttachmentCheck(
+            check({ status: 'failed', error: 'Timeout', blocked: true })
+        );
+        expect(failed.text).toBe('Not scanned, blocked');
+        expect(failed.title).toContain('Timeout');
+        expect(describeAttachmentCheck(check({})).text).toBe('SHA-256 2cf24dba5fb0…');
+    });
+
+    test('recognizes errors of blocked attachments', () => {
+        const blocked = 'Blocked by the antivirus scanner: hello.txt contains Eicar-Signature';
+
+        expect(getScanBlockMessage(blocked)).toBe(blocked);
+        expect(getScanBlockMessage(new Error(blocked))).toBe(blocked);
+        expect(getScanBlockMessage('Failed to create file')).toBeNull();
+    });
+});
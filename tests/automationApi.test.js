@@ -0,0 +1,180 @@
+/**
+ * Tests for automationApi.js
+ */
+import {
+    createAutomationHandler,
+    exportMessageContent,
+    summarizeMessage
+} from '../src/js/automationApi.js';
+
+function decodeBase64(value) {
+    return Buffer.from(value, 'base64').toString('utf-8');
+}
+
+function createMessage(overrides = {}) {
+    return {
+        subject: 'Invoice 42',
+        senderName: 'Alice',
+        senderEmail: 'alice@example.com',
+        recipients: [{ name: 'Bob', email: 'bob@example.com', recipType: 'to' }],
+        bodyContent: 'Please pay.',
+        attachments: [],
+        timestamp: new Date('2024-03-01T09:30:00Z'),
+        messageHash: 'hash-1',
+        fileName: 'invoice.msg',
+        _fileType: 'msg',
+        ...overrides
+    };
+}
+
+function createApp(messages) {
+    return {
+        messageHandler: {
+            getMessages: jest.fn(() => messages),
+            getCurrentMessage: jest.fn(() => messages[0] || null)
+        },
+        uiManager: {
+            applySearch: jest.fn((query) => messages.filter((m) => m.subject.includes(query)))
+        },
+        fileHandler: {
+            handleFilesFromPaths: jest.fn(async (paths) => {
+                paths.forEach((path, index) =>
+                    messages.push(createMessage({ messageHash: `new-${index}`, fileName: path }))
+                );
+            })
+        }
+    };
+}
+
+describe('automationApi', () => {
+    describe('summarizeMessage', () => {
+        test('returns the fields scripts need to pick a message', () => {
+            expect(summarizeMessage(createMessage(), 3)).toEqual({
+                index: 3,
+                messageHash: 'hash-1',
+                subject: 'Invoice 42',
+                senderName: 'Alice',
+                senderEmail: 'alice@example.com',
+                date: '2024-03-01T09:30:00.000Z',
+                fileName: 'invoice.msg',
+                attachmentCount: 0
+            });
+        });
+    });
+
+    describe('exportMessageContent', () => {
+        test('exports EML as base64', () => {
+            const content = exportMessageContent(createMessage(), 'eml');
+
+            expect(content.fileName).toMatch(/\.eml$/);
+            expect(content.mimeType).toBe('message/rfc822');
+            expect(decodeBase64(content.contentBase64)).toContain('Subject: Invoice 42');
+        });
+
+        test('exports JSON with a .json file name', () => {
+            const content = exportMessageContent(createMessage(), 'json');
+
+            expect(content.fileName).toMatch(/\.json$/);
+            expect(JSON.parse(decodeBase64(content.contentBase64)).subject).toBe('Invoice 42');
+        });
+
+        test('exports the original file bytes', () => {
+            const raw = new TextEncoder().encode('raw bytes').buffer;
+            const content = exportMessageContent(createMessage({ _rawBuffer: raw }), 'original');
+
+            expect(content.fileName).toMatch(/\.msg$/);
+            expect(decodeBase64(content.contentBase64)).toBe('raw bytes');
+        });
+
+        test('rejects unavailable or unknown formats', () => {
+            expect(() => exportMessageContent(createMessage(), 'original')).toThrow('not available');
+            expect(() => exportMessageContent(createMessage(), 'pdf')).toThrow('Unsupported');
+        });
+    });
+
+    describe('createAutomationHandler', () => {
+        test('lists loaded messages', async () => {
+            const handle = createAutomationHandler(createApp([createMessage()]));
+
+            const result = await handle('list', {});
+            expect(result.messages).toHaveLength(1);
+            expect(result.messages[0].messageHash).toBe('hash-1');
+        });
+
+        test('opens files and reports how many were added', async () => {
+            const app = createApp([createMessage()]);
+            const handle = createAutomationHandler(app);
+
+            await expect(handle('open', { paths: ['/tmp/a.msg', '/tmp/b.eml'] })).resolves.toEqual({
+                opened: 2,
+                total: 3
+            });
+            expect(app.fileHandler.handleFilesFromPaths).toHaveBeenCalledWith([
+                '/tmp/a.msg',
+                '/tmp/b.eml'
+            ]);
+        });
+
+        test('runs searches through the UI', async () => {
+            const messages = [createMessage(), createMessage({ subject: 'Lunch', messageHash: 'h2' })];
+            const app = createApp(messages);
+            const handle = createAutomationHandler(app);
+
+            const result = await handle('search', { query: 'Lunch' });
+            expect(app.uiManager.applySearch).toHaveBeenCalledWith('Lunch');
+            expect(result.count).toBe(1);
+            expect(result.messages[0]).toMatchObject({ index: 1, messageHash: 'h2' });
+        });
+
+        test('finds messages ranked by relevance without changing the view', async () => {
+            const messages = [
+                createMessage({
+                    subject: 'Lunch',
+                    bodyContent: 'About the contract',
+                    messageHash: 'h1'
+                }),
+                createMessage({ subject: 'Contract renewal', messageHash: 'h2' }),
+                createMessage({ subject: 'Holiday', messageHash: 'h3' })
+            ];
+            const app = createApp(messages);
+            const handle = createAutomationHandler(app);
+
+            const result = await handle('find', { query: 'contract', limit: 1 });
+            expect(app.uiManager.applySearch).not.toHaveBeenCalled();
+            expect(result.count).toBe(2);
+            expect(result.hits).toHaveLength(1);
+            expect(result.hits[0]).toMatchObject({ index: 1, messageHash: 'h2' });
+            expect(result.hits[0].score).toBeGreaterThan(0);
+        });
+
+        test('exports the open message by default and others by hash', async () => {
+            const messages = [createMessage(), createMessage({ subject: 'Lunch', messageHash: 'h2' })];
+            const handle = createAutomationHandler(createApp(messages));
+
+            const current = await handle('export', { format: 'html' });
+            expect(decodeBase64(current.contentBase64)).toContain('Invoice 42');
+
+            const byHash = await handle('export', { messageHash: 'h2' });
+            expect(decodeBase64(byHash.contentBase64)).toContain('Subject: Lunch');
+
+            await expect(handle('export', { messageHash: 'missing' })).rejects.toThrow(
+                'Message not found'
+            );
+        });
+
+        test('analyzes the delivery path of the original file in the backend', async () => {
+            const original = createMessage({ _rawBuffer: new ArrayBuffer(8), messageHash: 'h2' });
+            const handle = createAutomationHandler(createApp([createMessage(), original]));
+
+            await expect(handle('delivery', {})).rejects.toThrow('original file');
+            await expect(handle('delivery', { messageHash: 'h2' })).rejects.toThrow('desktop app');
+        });
+
+        test('rejects unknown commands', async () => {
+            const handle = createAutomationHandler(createApp([]));
+
+            await expect(handle('shutdown', {})).rejects.toThrow('Unknown command');
+            await expect(handle('toString', {})).rejects.toThrow('Unknown command');
+        });
+    });
+});
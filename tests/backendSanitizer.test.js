@@ -0,0 +1,70 @@
+/**
+ * Tests for backendSanitizer.js
+ */
+jest.mock('../src/js/tauri-bridge.js', () => ({
+    isTauri: jest.fn(() => true),
+    sanitizeHtml: jest.fn((html) => Promise.resolve(`clean:${html}`))
+}));
+
+import { isTauri, sanitizeHtml } from '../src/js/tauri-bridge.js';
+import { sanitizeMessageHtml } from '../src/js/backendSanitizer.js';
+
+describe('backendSanitizer', () => {
+    beforeEach(() => {
+        jest.clearAllMocks();
+        isTauri.mockReturnValue(true);
+    });
+
+    test('replaces the body with the sanitized HTML', async () => {
+        const msgInfo = { bodyContentHTML: '<p onclick="x()">Hi</p>', attachments: [] };
+
+        await expect(sanitizeMessageHtml(msgInfo)).resolves.toBe(msgInfo);
+
+        expect(msgInfo.bodyContentHTML).toBe('clean:<p onclick="x()">Hi</p>');
+        expect(sanitizeHtml).toHaveBeenCalledWith('<p onclick="x()">Hi</p>', {});
+    });
+
+    test('passes the images still referenced with cid:', async () => {
+        const msgInfo = {
+            bodyContentHTML: '<img src="cid:logo@example.com"><img src="data:image/png;base64,AQ">',
+            attachments: [
+                { contentId: '<logo@example.com>', contentBase64: 'data:image/png;base64,AAAA' },
+                { contentId: 'photo', contentBase64: 'data:image/png;base64,AQ' },
+                { fileName: 'report.pdf', contentBase64: 'data:application/pdf;base64,JVBE' }
+            ]
+        };
+
+        await sanitizeMessageHtml(msgInfo);
+
+        expect(sanitizeHtml.mock.calls[0][1]).toEqual({
+            'logo@example.com': 'data:image/png;base64,AAAA'
+        });
+    });
+
+    test('keeps the body if the backend fails', async () => {
+        sanitizeHtml.mockRejectedValueOnce(new Error('HTML sanitizer failed'));
+        jest.spyOn(console, 'warn').mockImplementation(() => {});
+        const msgInfo = { bodyContentHTML: '<p>Hi</p>' };
+
+        await sanitizeMessageHtml(msgInfo);
+
+        expect(msgInfo.bodyContentHTML).toBe('<p>Hi</p>');
+        console.warn.mockRestore();
+    });
+
+    test('leaves messages alone outside the desktop app', async () => {
+        isTauri.mockReturnValue(false);
+        const msgInfo = { bodyContentHTML: '<p>Hi</p>' };
+
+        await sanitizeMessageHtml(msgInfo);
+
+        expect(sanitizeHtml).not.toHaveBeenCalled();
+        expect(msgInfo.bodyContentHTML).toBe('<p>Hi</p>');
+    });
+
+    test('skips messages without an HTML body', async () => {
+        await sanitizeMessageHtml({ bodyContent: 'Plain' });
+
+        expect(sanitizeHtml).not.toHaveBeenCalled();
+    });
+});
@@ -1,5 +1,6 @@
 import JSZip from 'jszip';
-import { createBulkExportZipBlob } from '../src/js/bulkExport.js';
+import { createBulkExportZipBlob, verifyBulkExportZip } from '../src/js/bulkExport.js';
+import { sha256Hex } from '../src/js/hashing.js';
 
 describe('bulk export helpers', () => {
     const now = new Date('2026-05-13T08:30:00.000Z');
@@ -96,4 +97,73 @@ describe('bulk export helpers', () => {
         expect(result.exportedCount).toBe(0);
         expect(result.skippedCount).toBe(1);
     });
+
+    test('records size and SHA-256 of each exported file', async () => {
+        const result = await createBulkExportZipBlob([baseMessage], 'original', {
+            scope: 'selected',
+            now
+        });
+        const zip = await JSZip.loadAsync(result.blob);
+        const manifest = JSON.parse(await zip.file('manifest.json').async('string'));
+
+        expect(manifest.messages[0].size).toBe(3);
+        expect(manifest.messages[0].sha256).toBe(sha256Hex('MSG'));
+        expect(manifest.messages[0].messageHash).toBe('hash1');
+    });
+
+    test('omits checksums when disabled', async () => {
+        const result = await createBulkExportZipBlob([baseMessage], 'eml', {
+            scope: 'selected',
+            now,
+            checksums: false
+        });
+        const zip = await JSZip.loadAsync(result.blob);
+        const manifest = JSON.parse(await zip.file('manifest.json').async('string'));
+
+        expect(manifest.messages[0].sha256).toBeUndefined();
+        expect(manifest.messages[0].size).toBeUndefined();
+    });
+
+    describe('verifyBulkExportZip', () => {
+        const createArchive = async () => {
+            const result = await createBulkExportZipBlob(
+                [baseMessage, { ...baseMessage, fileName: 'second.msg', messageHash: 'hash2' }],
+                'eml',
+                { scope: 'selected', now }
+            );
+            return JSZip.loadAsync(result.blob);
+        };
+
+        test('accepts an untouched archive', async () => {
+            const zip = await createArchive();
+            const result = await verifyBulkExportZip(await zip.generateAsync({ type: 'uint8array' }));
+
+            expect(result).toEqual({ valid: true, checkedCount: 2, mismatches: [], unlisted: [] });
+        });
+
+        test('reports modified, missing and unlisted files', async () => {
+            const zip = await createArchive();
+            zip.file('emails/quarterly.eml', 'tampered');
+            zip.remove('emails/second.eml');
+            zip.file('emails/extra.eml', 'extra');
+
+            const result = await verifyBulkExportZip(await zip.generateAsync({ type: 'uint8array' }));
+
+            expect(result.valid).toBe(false);
+            expect(result.mismatches).toEqual([
+                { fileName: 'quarterly.eml', reason: 'size' },
+                { fileName: 'second.eml', reason: 'missing' }
+            ]);
+            expect(result.unlisted).toEqual(['emails/extra.eml']);
+        });
+
+        test('rejects archives without a manifest', async () => {
+            const zip = new JSZip();
+            zip.file('emails/a.eml', 'a');
+
+            await expect(
+                verifyBulkExportZip(await zip.generateAsync({ type: 'uint8array' }))
+            ).rejects.toThrow('manifest.json');
+        });
+    });
 });
@@ -0,0 +1,58 @@
+/**
+ * Tests for charsetDecoding.js
+ */
+jest.mock('../src/js/tauri-bridge.js', () => ({
+    decodeCharset: jest.fn(),
+    isTauri: jest.fn(() => true)
+}));
+
+import { decodeCharset, isTauri } from '../src/js/tauri-bridge.js';
+import { decodeMimeWords } from '../src/js/encoding.js';
+import { parseWithBackendCharsets } from '../src/js/charsetDecoding.js';
+
+/** "にほん" in ISO-2022-JP */
+const JIS_BASE64 = 'GyRCJEskWyRzGyhC';
+
+describe('charsetDecoding', () => {
+    beforeEach(() => {
+        jest.clearAllMocks();
+        isTauri.mockReturnValue(true);
+    });
+
+    test('parses once when every charset is supported', async () => {
+        const parse = jest.fn(() => decodeMimeWords('=?UTF-8?Q?Gr=C3=BC=C3=9Fe?='));
+
+        await expect(parseWithBackendCharsets(parse)).resolves.toBe('Grüße');
+        expect(parse).toHaveBeenCalledTimes(1);
+        expect(decodeCharset).not.toHaveBeenCalled();
+    });
+
+    test('parses again with text the backend decoded', async () => {
+        decodeCharset.mockResolvedValue('にほん');
+        const parse = jest.fn(() => decodeMimeWords(`=?ISO-2022-JP?B?${JIS_BASE64}?=`));
+
+        await expect(parseWithBackendCharsets(parse)).resolves.toBe('にほん');
+        expect(decodeCharset).toHaveBeenCalledWith(JIS_BASE64, 'iso-2022-jp');
+        expect(parse).toHaveBeenCalledTimes(2);
+    });
+
+    test('keeps the first result if the backend fails', async () => {
+        decodeCharset.mockRejectedValue(new Error('Unsupported charset: x-unknown'));
+        jest.spyOn(console, 'warn').mockImplementation(() => {});
+        const parse = jest.fn(() => decodeMimeWords('=?x-unknown?Q?abc?='));
+
+        await expect(parseWithBackendCharsets(parse)).resolves.toBe('abc');
+        expect(parse).toHaveBeenCalledTimes(1);
+        console.warn.mockRestore();
+    });
+
+    test('does not ask the backend outside the desktop app', async () => {
+        isTauri.mockReturnValue(false);
+        const parse = jest.fn(() => decodeMimeWords(`=?ISO-2022-JP?B?${JIS_BASE64}?=`));
+
+        await parseWithBackendCharsets(parse);
+
+        expect(decodeCharset).not.toHaveBeenCalled();
+        expect(parse).toHaveBeenCalledTimes(1);
+    });
+});
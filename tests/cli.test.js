@@ -0,0 +1,152 @@
+/**
+ * Tests for cli.js
+ */
+import { convertMessage, detectInputType, parseCliArgs, runCli } from '../src/js/cli.js';
+
+const SAMPLE_EML = [
+    'From: Alice <alice@example.com>',
+    'To: Bob <bob@example.com>',
+    'Subject: Pipeline test',
+    'Date: Fri, 01 Mar 2024 09:30:00 +0000',
+    'Content-Type: text/plain; charset=utf-8',
+    '',
+    'Hello from stdin.',
+    ''
+].join('\r\n');
+
+function toArrayBuffer(text) {
+    return new TextEncoder().encode(text).buffer;
+}
+
+function createIo(input = '') {
+    const output = { stdout: '', stderr: '' };
+    return {
+        output,
+        io: {
+            stdin: (async function* () {
+                yield new TextEncoder().encode(input);
+            })(),
+            stdout: { write: (chunk) => (output.stdout += chunk) },
+            stderr: { write: (chunk) => (output.stderr += chunk) },
+            readFile: jest.fn(() => Promise.resolve(new TextEncoder().encode(input))),
+            writeFile: jest.fn(() => Promise.resolve())
+        }
+    };
+}
+
+describe('cli', () => {
+    describe('parseCliArgs', () => {
+        test('parses stdin conversion options', () => {
+            expect(parseCliArgs(['--stdin', '--type', 'msg', '--to', 'json'])).toMatchObject({
+                stdin: true,
+                input: null,
+                type: 'msg',
+                to: 'json'
+            });
+        });
+
+        test('treats "-" as standard input', () => {
+            expect(parseCliArgs(['-']).stdin).toBe(true);
+        });
+
+        test('defaults to JSON output for a file', () => {
+            expect(parseCliArgs(['mail.eml'])).toMatchObject({ input: 'mail.eml', to: 'json' });
+        });
+
+        test('keeps the case of the output path', () => {
+            expect(parseCliArgs(['Mail.msg', '-o', 'Out/Mail.eml']).output).toBe('Out/Mail.eml');
+            expect(parseCliArgs(['Mail.msg', '--output', 'x.eml']).output).toBe('x.eml');
+            expect(() => parseCliArgs(['Mail.msg', '--output'])).toThrow('Missing value');
+        });
+
+        test('accepts the convert command of the desktop app', () => {
+            expect(parseCliArgs(['convert', 'mail.msg', '--to', 'eml'])).toMatchObject({
+                input: 'mail.msg',
+                to: 'eml'
+            });
+            expect(parseCliArgs(['convert', '--stdin']).stdin).toBe(true);
+        });
+
+        test('rejects commands only the desktop app has', () => {
+            expect(() => parseCliArgs(['headers', 'mail.eml'])).toThrow('desktop app');
+            expect(() => parseCliArgs(['extract-attachments', 'mail.msg'])).toThrow('desktop app');
+        });
+
+        test('rejects missing input, unknown options and formats', () => {
+            expect(() => parseCliArgs([])).toThrow('No input');
+            expect(() => parseCliArgs(['--stdin', 'mail.eml'])).toThrow('either --stdin');
+            expect(() => parseCliArgs(['--stdin', '--verbose'])).toThrow('Unknown option');
+            expect(() => parseCliArgs(['--stdin', '--to', 'pdf'])).toThrow('Unsupported output');
+            expect(() => parseCliArgs(['--stdin', '--type', 'pst'])).toThrow('Unsupported input');
+            expect(() => parseCliArgs(['--stdin', '--to'])).toThrow('Missing value');
+        });
+    });
+
+    describe('detectInputType', () => {
+        test('detects the OLE signature of MSG files', () => {
+            const msg = new Uint8Array([0xd0, 0xcf, 0x11, 0xe0, 0xa1, 0xb1, 0x1a, 0xe1, 0]);
+            expect(detectInputType(msg.buffer)).toBe('msg');
+            expect(detectInputType(toArrayBuffer(SAMPLE_EML))).toBe('eml');
+        });
+    });
+
+    describe('convertMessage', () => {
+        test('converts EML data to JSON', () => {
+            const json = JSON.parse(convertMessage(toArrayBuffer(SAMPLE_EML), { type: 'eml' }));
+
+            expect(json.subject).toBe('Pipeline test');
+            expect(json.senderEmail).toBe('alice@example.com');
+            expect(json.bodyText).toContain('Hello from stdin.');
+            expect(json.source).toMatchObject({ fileName: 'message.eml', fileType: 'eml' });
+            expect(json.source.messageHash).toBeTruthy();
+        });
+
+        test('converts to HTML', () => {
+            const html = convertMessage(toArrayBuffer(SAMPLE_EML), { to: 'html' });
+            expect(html).toContain('Pipeline test');
+        });
+    });
+
+    describe('runCli', () => {
+        test('reads the message from stdin and writes JSON to stdout', async () => {
+            const { io, output } = createIo(SAMPLE_EML);
+
+            await expect(runCli(['--stdin', '--type', 'eml', '--to', 'json'], io)).resolves.toBe(0);
+            expect(JSON.parse(output.stdout).subject).toBe('Pipeline test');
+            expect(io.readFile).not.toHaveBeenCalled();
+        });
+
+        test('reads files and uses their name', async () => {
+            const { io, output } = createIo(SAMPLE_EML);
+
+            await expect(runCli(['/tmp/in/mail.eml'], io)).resolves.toBe(0);
+            expect(io.readFile).toHaveBeenCalledWith('/tmp/in/mail.eml');
+            expect(JSON.parse(output.stdout).source.fileName).toBe('mail.eml');
+        });
+
+        test('writes the output to a file with --output', async () => {
+            const { io, output } = createIo(SAMPLE_EML);
+
+            await expect(runCli(['mail.eml', '--to', 'eml', '-o', 'out.eml'], io)).resolves.toBe(
+                0
+            );
+            expect(output.stdout).toBe('');
+            expect(io.writeFile).toHaveBeenCalledWith('out.eml', expect.any(String));
+            expect(io.writeFile.mock.calls[0][1]).toContain('Subject: Pipeline test');
+        });
+
+        test('reports usage errors with exit code 2', async () => {
+            const { io, output } = createIo();
+
+            await expect(runCli([], io)).resolves.toBe(2);
+            expect(output.stderr).toContain('Usage:');
+        });
+
+        test('fails on empty input', async () => {
+            const { io, output } = createIo('');
+
+            await expect(runCli(['--stdin'], io)).resolves.toBe(1);
+            expect(output.stderr).toContain('Input is empty');
+        });
+    });
+});
@@ -0,0 +1,87 @@
+/**
+ * Tests for contact.js
+ */
+import {
+    describeAddress,
+    describePhone,
+    exportContact,
+    formatContactDate,
+    isContact,
+    loadContact
+} from '../src/js/contact.js';
+
+/**
+ * Builds an .msg message with an original file
+ * @param {Object} [overrides]
+ * @returns {Object}
+ */
+function msgMessage(overrides = {}) {
+    return {
+        _rawBuffer: new ArrayBuffer(4),
+        _fileType: 'msg',
+        messageClass: 'IPM.Contact',
+        attachments: [],
+        ...overrides
+    };
+}
+
+describe('contact', () => {
+    describe('isContact', () => {
+        test('recognizes Outlook contact classes', () => {
+            expect(isContact(msgMessage())).toBe(true);
+            expect(isContact(msgMessage({ messageClass: 'ipm.contact.Custom' }))).toBe(true);
+            expect(isContact(msgMessage({ messageClass: 'IPM.ContactGroup' }))).toBe(false);
+            expect(isContact(msgMessage({ messageClass: 'IPM.Note' }))).toBe(false);
+        });
+
+        test('needs the original .msg file', () => {
+            expect(isContact(msgMessage({ _rawBuffer: null }))).toBe(false);
+            expect(isContact(msgMessage({ _fileType: 'eml' }))).toBe(false);
+            expect(isContact(null)).toBe(false);
+        });
+    });
+
+    describe('loadContact', () => {
+        test('does not ask the backend about other messages', async () => {
+            await expect(loadContact(msgMessage({ messageClass: 'IPM.Note' }))).resolves.toBeNull();
+        });
+
+        test('is only available in the desktop app', async () => {
+            await expect(loadContact(msgMessage())).rejects.toThrow('desktop app');
+            await expect(exportContact(msgMessage())).rejects.toThrow('desktop app');
+        });
+    });
+
+    describe('describePhone', () => {
+        test('labels the kind of number', () => {
+            expect(describePhone({ kind: 'cell', number: '+49 170 1234567' })).toBe(
+                'Mobile: +49 170 1234567'
+            );
+            expect(describePhone({ kind: 'unknown', number: '123' })).toBe('Other: 123');
+        });
+    });
+
+    describe('describeAddress', () => {
+        test('writes the address on one line', () => {
+            const address = {
+                kind: 'work',
+                poBox: '',
+                street: 'Main St 1\r\nBuilding B',
+                city: 'Springfield',
+                region: '',
+                postalCode: '12345',
+                country: 'USA'
+            };
+            expect(describeAddress(address)).toBe(
+                'Work: Main St 1, Building B, 12345 Springfield, USA'
+            );
+        });
+    });
+
+    describe('formatContactDate', () => {
+        test('shows the day in UTC', () => {
+            expect(formatContactDate(Date.UTC(1984, 6, 10), 'en-US')).toBe('Jul 10, 1984');
+            expect(formatContactDate(null)).toBe('');
+        });
+    });
+});
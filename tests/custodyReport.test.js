@@ -0,0 +1,86 @@
+/**
+ * Tests for custodyReport.js and pdfWriter.js
+ */
+import { AUDIT_ACTIONS, AuditLog } from '../src/js/AuditLog.js';
+import { buildCustodyReportLines, collectCustodyRecords } from '../src/js/custodyReport.js';
+import { createTextPdf, toPdfString, wrapText } from '../src/js/pdfWriter.js';
+import { Storage } from '../src/js/storage.js';
+import { sha256Hex } from '../src/js/hashing.js';
+
+const decode = (bytes) => String.fromCharCode(...bytes);
+
+describe('custody report', () => {
+    const now = new Date('2024-03-01T10:00:00.000Z');
+    const message = {
+        subject: 'Invoice',
+        fileName: 'invoice.eml',
+        messageHash: 'hash1',
+        _sourcePath: '/cases/42/invoice.eml',
+        _parsedAt: '2024-03-01T09:00:00.000Z',
+        _rawBuffer: new TextEncoder().encode('raw').buffer
+    };
+
+    test('collects source, hashes, and recorded operations per message', () => {
+        const log = new AuditLog(new Storage());
+        log.setEnabled(true);
+        log.record(AUDIT_ACTIONS.OPEN, { message, now });
+        log.record(AUDIT_ACTIONS.EXPORT, { message, format: 'eml', now });
+        log.record(AUDIT_ACTIONS.EXPORT, { message: { messageHash: 'other' }, now });
+
+        const [record] = collectCustodyRecords([message], log.getEntries());
+
+        expect(record).toMatchObject({
+            sourcePath: '/cases/42/invoice.eml',
+            fileSize: 3,
+            fileSha256: sha256Hex('raw'),
+            parsedAt: '2024-03-01T09:00:00.000Z'
+        });
+        expect(record.operations).toHaveLength(1);
+        expect(record.operations[0].format).toBe('eml');
+    });
+
+    test('notes when the audit log is not recording', () => {
+        const lines = buildCustodyReportLines(collectCustodyRecords([message]), {
+            now,
+            auditLogEnabled: false,
+            integrity: { valid: true, brokenAt: null }
+        });
+        const text = lines.filter(Boolean).map((line) => line.text).join('\n');
+
+        expect(text).toContain('Audit log: not recording');
+        expect(text).toContain('Source path: /cases/42/invoice.eml');
+        expect(text).toContain('Recorded operations: none');
+    });
+});
+
+describe('pdfWriter', () => {
+    test('escapes PDF string delimiters and replaces non-Latin-1 characters', () => {
+        expect(toPdfString('a(b)\\c')).toBe('(a\\(b\\)\\\\c)');
+        expect(toPdfString('Grüße ✓')).toBe('(Grüße ?)');
+    });
+
+    test('wraps long text and splits long words', () => {
+        expect(wrapText('aaa bbb ccc', 7)).toEqual(['aaa bbb', 'ccc']);
+        expect(wrapText('abcdefghij', 4)).toEqual(['abcd', 'efgh', 'ij']);
+    });
+
+    test('writes a PDF with a valid cross-reference table', () => {
+        const bytes = createTextPdf([{ text: 'Hello', style: 'title' }], { now: new Date(0) });
+        const text = decode(bytes);
+        const startxref = Number(text.match(/startxref\n(\d+)/)[1]);
+        const firstOffset = Number(text.match(/xref\n0 \d+\n0000000000 65535 f \n(\d{10})/)[1]);
+
+        expect(text.startsWith('%PDF-1.4')).toBe(true);
+        expect(text).toContain('(Hello) Tj');
+        expect(text.slice(startxref, startxref + 4)).toBe('xref');
+        expect(text.slice(firstOffset, firstOffset + 7)).toBe('1 0 obj');
+    });
+
+    test('starts a new page when content overflows', () => {
+        const lines = Array.from({ length: 120 }, (_, i) => ({ text: `Line ${i}` }));
+        const text = decode(createTextPdf(lines));
+
+        expect(text).toContain('/Count 3');
+        expect(text).toContain('(Page 3 of 3)');
+    });
+});
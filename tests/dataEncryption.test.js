@@ -0,0 +1,155 @@
+/**
+ * Tests for dataEncryption.js
+ */
+import { webcrypto } from 'crypto';
+import {
+    DataEncryption,
+    ENCRYPTED_VALUE_PREFIX,
+    ENCRYPTION_STORAGE_KEY,
+    getKeychainAccount,
+    isEncryptedKey
+} from '../src/js/dataEncryption.js';
+import { MemoryBackend, Storage } from '../src/js/storage.js';
+
+const PASSPHRASE = 'correct horse battery';
+
+describe('dataEncryption', () => {
+    describe('isEncryptedKey', () => {
+        test('encrypts app keys', () => {
+            expect(isEncryptedKey('msgReader_auditLog')).toBe(true);
+            expect(isEncryptedKey('pinnedMessages')).toBe(true);
+        });
+
+        test('keeps metadata, shared profile settings and foreign keys readable', () => {
+            expect(isEncryptedKey(ENCRYPTION_STORAGE_KEY)).toBe(false);
+            expect(isEncryptedKey('msgReader_profiles')).toBe(false);
+            expect(isEncryptedKey('msgReader_profile:Work:msgReader_theme')).toBe(false);
+            expect(isEncryptedKey('otherApp_setting')).toBe(false);
+        });
+
+        test('only encrypts keys of the given namespace', () => {
+            const namespace = 'msgReader_profile:Work:';
+            expect(isEncryptedKey(`${namespace}msgReader_theme`, namespace)).toBe(true);
+            expect(isEncryptedKey('msgReader_theme', namespace)).toBe(false);
+        });
+    });
+
+    describe('getKeychainAccount', () => {
+        test('uses one entry per profile', () => {
+            expect(getKeychainAccount(null)).toBe('data-key');
+            expect(getKeychainAccount('Work')).toBe('data-key:Work');
+        });
+    });
+
+    describe('DataEncryption', () => {
+        let backend;
+        let storage;
+        let encryption;
+
+        beforeEach(() => {
+            backend = new MemoryBackend();
+            storage = new Storage(backend);
+            encryption = new DataEncryption(storage, webcrypto);
+        });
+
+        /**
+         * Simulates a restart: a fresh Storage on the same backend
+         */
+        function restart() {
+            storage = new Storage(backend);
+            encryption = new DataEncryption(storage, webcrypto);
+        }
+
+        test('encrypts existing data when enabled', async () => {
+            storage.set('pinnedMessages', ['abc']);
+            storage.set('otherApp_setting', 'plain');
+
+            expect(await encryption.enable(PASSPHRASE)).toBe(true);
+
+            expect(backend.getItem('pinnedMessages').startsWith(ENCRYPTED_VALUE_PREFIX)).toBe(true);
+            expect(backend.getItem('pinnedMessages')).not.toContain('abc');
+            expect(backend.getItem('otherApp_setting')).toBe('"plain"');
+            expect(storage.get('pinnedMessages')).toEqual(['abc']);
+            expect(encryption.isEnabled()).toBe(true);
+        });
+
+        test('rejects short passphrases', async () => {
+            expect(await encryption.enable('short')).toBe(false);
+            expect(encryption.isEnabled()).toBe(false);
+        });
+
+        test('encrypts values written after enabling', async () => {
+            await encryption.enable(PASSPHRASE);
+            storage.set('msgReader_theme', 'dark');
+            await storage.backend.flush();
+
+            expect(
+                backend.getItem('msgReader_theme').startsWith(ENCRYPTED_VALUE_PREFIX)
+            ).toBe(true);
+            expect(storage.get('msgReader_theme')).toBe('dark');
+        });
+
+        test('unlocks after a restart with the right passphrase only', async () => {
+            storage.set('pinnedMessages', ['abc']);
+            await encryption.enable(PASSPHRASE);
+            restart();
+
+            expect(await encryption.unlock('wrong passphrase')).toBe(false);
+            expect(encryption.isUnlocked()).toBe(false);
+            expect(await encryption.unlock(PASSPHRASE)).toBe(true);
+            expect(storage.get('pinnedMessages')).toEqual(['abc']);
+        });
+
+        test('unlocks with an exported key', async () => {
+            storage.set('pinnedMessages', ['abc']);
+            await encryption.enable(PASSPHRASE, { extractable: true });
+            const rawKey = await encryption.exportKey();
+            restart();
+
+            expect(await encryption.unlockWithRawKey(rawKey)).toBe(true);
+            expect(storage.get('pinnedMessages')).toEqual(['abc']);
+        });
+
+        test('does not export a non-extractable key', async () => {
+            await encryption.enable(PASSPHRASE);
+            expect(await encryption.exportKey()).toBeNull();
+        });
+
+        test('writes plain text again when disabled', async () => {
+            storage.set('pinnedMessages', ['abc']);
+            await encryption.enable(PASSPHRASE);
+
+            expect(await encryption.disable()).toBe(true);
+            expect(backend.getItem('pinnedMessages')).toBe('["abc"]');
+            expect(encryption.isEnabled()).toBe(false);
+            expect(encryption.isUnlocked()).toBe(false);
+        });
+
+        test('reset deletes the encrypted data', async () => {
+            storage.set('pinnedMessages', ['abc']);
+            storage.set('otherApp_setting', 'plain');
+            await encryption.enable(PASSPHRASE);
+            restart();
+
+            expect(encryption.reset()).toBe(true);
+            expect(backend.getItem('pinnedMessages')).toBeNull();
+            expect(backend.getItem('otherApp_setting')).toBe('"plain"');
+            expect(encryption.isEnabled()).toBe(false);
+        });
+
+        test('keeps profiles apart', async () => {
+            storage.set('pinnedMessages', ['default']);
+            storage.setNamespace('msgReader_profile:Work:');
+            await encryption.enable(PASSPHRASE);
+
+            expect(backend.getItem('pinnedMessages')).toBe('["default"]');
+            storage.set('pinnedMessages', ['work']);
+            await storage.backend.flush();
+            expect(
+                backend.getItem('msgReader_profile:Work:pinnedMessages').startsWith(
+                    ENCRYPTED_VALUE_PREFIX
+                )
+            ).toBe(true);
+        });
+    });
+});
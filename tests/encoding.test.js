@@ -1,9 +1,13 @@
 import {
+    arrayBufferToBase64,
+    base64ToArrayBuffer,
+    collectUnsupportedDecodings,
     decodeBase64Text,
     decodeBinaryString,
     decodeMimeWords,
     decodePercentEncodedText,
-    textToBase64
+    textToBase64,
+    withExternalDecodings
 } from '../src/js/encoding.js';
 
 describe('encoding helpers', () => {
@@ -39,4 +43,45 @@ describe('encoding helpers', () => {
     test('decodes RFC 2231 percent-encoded bytes with charset', () => {
         expect(decodePercentEncodedText('Gr%FC%DFe.txt', 'iso-8859-1')).toBe('Grüße.txt');
     });
+
+    test('round-trips binary ArrayBuffers through base64', () => {
+        const bytes = new Uint8Array([0xd0, 0xcf, 0x11, 0xe0, 0x00, 0xff]);
+        const base64 = arrayBufferToBase64(bytes.buffer);
+        expect(base64).toBe('0M8R4AD/');
+        expect(new Uint8Array(base64ToArrayBuffer(base64))).toEqual(bytes);
+    });
+
+    test('reports text in charsets iconv-lite lacks', () => {
+        // "にほん" in ISO-2022-JP
+        const base64 = 'GyRCJEskWyRzGyhC';
+
+        const { result, unsupported } = collectUnsupportedDecodings(() =>
+            decodeBase64Text(base64, 'ISO-2022-JP')
+        );
+
+        expect(result).toBe('\x1b$B$K$[$s\x1b(B');
+        expect(unsupported).toEqual([
+            { key: `iso-2022-jp:${base64}`, charset: 'iso-2022-jp', base64 }
+        ]);
+    });
+
+    test('does not report supported charsets', () => {
+        const { unsupported } = collectUnsupportedDecodings(() =>
+            decodeMimeWords('=?UTF-8?Q?Gr=C3=BC=C3=9Fe?= =?gb18030?B?xOO6ww==?=')
+        );
+
+        expect(unsupported).toEqual([]);
+    });
+
+    test('uses text decoded elsewhere for reported bytes', () => {
+        const base64 = 'GyRCJEskWyRzGyhC';
+        const decodings = new Map([[`iso-2022-jp:${base64}`, 'にほん']]);
+
+        expect(
+            withExternalDecodings(decodings, () =>
+                decodeMimeWords(`=?iso-2022-jp?B?${base64}?= mail`)
+            )
+        ).toBe('にほん mail');
+        expect(decodeBase64Text(base64, 'iso-2022-jp')).not.toBe('にほん');
+    });
 });
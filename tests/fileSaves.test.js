@@ -0,0 +1,85 @@
+/**
+ * Tests for fileSaves.js
+ */
+const mockListeners = {};
+
+jest.mock('../src/js/tauri-bridge.js', () => ({
+    cancelFileSave: jest.fn(() => Promise.resolve(true)),
+    onSaveProgress: jest.fn((callback) => {
+        mockListeners.progress = callback;
+        return Promise.resolve(() => {});
+    }),
+    onSaveFinished: jest.fn((callback) => {
+        mockListeners.finished = callback;
+        return Promise.resolve(() => {});
+    })
+}));
+
+import { cancelFileSave } from '../src/js/tauri-bridge.js';
+import { cancelSave, runSave } from '../src/js/fileSaves.js';
+
+/**
+ * Lets the promise callbacks run
+ * @returns {Promise<void>}
+ */
+function flush() {
+    return new Promise((resolve) => setTimeout(resolve, 0));
+}
+
+describe('fileSaves', () => {
+    test('resolves with the path once the file is written', async () => {
+        const job = { saveId: 1, path: '/tmp/video.mp4', total: 300 };
+        const onStart = jest.fn();
+        const onProgress = jest.fn();
+
+        const saved = runSave(() => Promise.resolve(job), { onStart, onProgress });
+        await flush();
+        mockListeners.progress({ saveId: 1, written: 100, total: 300 });
+        mockListeners.progress({ saveId: 99, written: 5, total: 10 });
+        mockListeners.finished({ saveId: 1, path: '/tmp/video.mp4', status: 'saved', error: null });
+
+        await expect(saved).resolves.toBe('/tmp/video.mp4');
+        expect(onStart).toHaveBeenCalledWith(job);
+        expect(onProgress).toHaveBeenCalledTimes(1);
+        expect(onProgress).toHaveBeenCalledWith({ saveId: 1, written: 100, total: 300 });
+    });
+
+    test('resolves with false if the dialog or the save was cancelled', async () => {
+        await expect(runSave(() => Promise.resolve(null))).resolves.toBe(false);
+
+        const saved = runSave(() => Promise.resolve({ saveId: 2, path: '/tmp/a.zip', total: 1 }));
+        await flush();
+        await cancelSave(2);
+        mockListeners.finished({ saveId: 2, path: '/tmp/a.zip', status: 'cancelled', error: null });
+
+        await expect(saved).resolves.toBe(false);
+        expect(cancelFileSave).toHaveBeenCalledWith(2);
+    });
+
+    test('rejects if writing failed', async () => {
+        const saved = runSave(() => Promise.resolve({ saveId: 3, path: '/usb/a.zip', total: 1 }));
+        await flush();
+        mockListeners.finished({
+            saveId: 3,
+            path: '/usb/a.zip',
+            status: 'failed',
+            error: 'Failed to write /usb/a.zip: No space left on device'
+        });
+
+        await expect(saved).rejects.toThrow('No space left on device');
+    });
+
+    test('keeps the result of a save that finished before it was started here', async () => {
+        await runSave(() => Promise.resolve(null));
+        mockListeners.finished({ saveId: 4, path: '/tmp/logo.png', status: 'saved', error: null });
+        const onStart = jest.fn();
+
+        const saved = runSave(
+            () => Promise.resolve({ saveId: 4, path: '/tmp/logo.png', total: 3 }),
+            { onStart }
+        );
+
+        await expect(saved).resolves.toBe('/tmp/logo.png');
+        expect(onStart).not.toHaveBeenCalled();
+    });
+});
@@ -0,0 +1,64 @@
+/**
+ * Tests for hashing.js
+ */
+import {
+    dataUrlToBytes,
+    getAttachmentSha256,
+    getMessageFileSha256,
+    sha256Hex
+} from '../src/js/hashing.js';
+
+describe('hashing', () => {
+    describe('sha256Hex', () => {
+        test('hashes the empty input', () => {
+            expect(sha256Hex('')).toBe(
+                'e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855'
+            );
+        });
+
+        test('hashes a short string', () => {
+            expect(sha256Hex('abc')).toBe(
+                'ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad'
+            );
+        });
+
+        test('hashes input spanning multiple blocks', () => {
+            expect(sha256Hex('abcdbcdecdefdefgefghfghighijhijkijkljklmklmnlmnomnopnopq')).toBe(
+                '248d6a61d20638b8e5c026930c3e6039a33ce45964ff2167f6ecedd419db06c1'
+            );
+        });
+
+        test('treats strings and their UTF-8 bytes the same', () => {
+            const bytes = new TextEncoder().encode('Grüße');
+            expect(sha256Hex(bytes.buffer)).toBe(sha256Hex('Grüße'));
+        });
+    });
+
+    describe('getMessageFileSha256', () => {
+        test('returns empty string without original file bytes', () => {
+            expect(getMessageFileSha256({ subject: 'Test' })).toBe('');
+            expect(getMessageFileSha256(null)).toBe('');
+        });
+
+        test('hashes and caches the raw buffer', () => {
+            const message = { _rawBuffer: new TextEncoder().encode('abc').buffer };
+            const hash = getMessageFileSha256(message);
+
+            expect(hash).toBe(sha256Hex('abc'));
+            expect(message._fileSha256).toBe(hash);
+        });
+    });
+
+    describe('getAttachmentSha256', () => {
+        test('decodes data URLs before hashing', () => {
+            const attachment = { contentBase64: 'data:text/plain;base64,YWJj' };
+
+            expect(Array.from(dataUrlToBytes(attachment.contentBase64))).toEqual([97, 98, 99]);
+            expect(getAttachmentSha256(attachment)).toBe(sha256Hex('abc'));
+        });
+
+        test('returns empty string without content', () => {
+            expect(getAttachmentSha256({ fileName: 'a.txt' })).toBe('');
+        });
+    });
+});
@@ -0,0 +1,51 @@
+/**
+ * Tests for help.js
+ */
+
+import { HELP_TOPICS, getHelpTopicForContext, getHelpUrl } from '../src/js/help.js';
+
+describe('getHelpTopicForContext', () => {
+    test('defaults to getting started', () => {
+        expect(getHelpTopicForContext()).toBe(HELP_TOPICS.GETTING_STARTED);
+        expect(getHelpTopicForContext({})).toBe(HELP_TOPICS.GETTING_STARTED);
+    });
+
+    test('open message shows reading help', () => {
+        expect(getHelpTopicForContext({ messageOpen: true })).toBe(HELP_TOPICS.READING_MESSAGES);
+    });
+
+    test('focused search shows search help', () => {
+        expect(getHelpTopicForContext({ messageOpen: true, searchFocused: true })).toBe(
+            HELP_TOPICS.SEARCH
+        );
+    });
+
+    test('settings menu shows settings help', () => {
+        expect(getHelpTopicForContext({ settingsOpen: true, searchFocused: true })).toBe(
+            HELP_TOPICS.SETTINGS
+        );
+    });
+
+    test('attachment preview shows attachment help', () => {
+        expect(getHelpTopicForContext({ attachmentModalOpen: true, messageOpen: true })).toBe(
+            HELP_TOPICS.ATTACHMENTS
+        );
+    });
+
+    test('shortcut overview shows keyboard help', () => {
+        expect(getHelpTopicForContext({ helpModalOpen: true, attachmentModalOpen: true })).toBe(
+            HELP_TOPICS.KEYBOARD_SHORTCUTS
+        );
+    });
+});
+
+describe('getHelpUrl', () => {
+    test('builds a relative page URL', () => {
+        expect(getHelpUrl(HELP_TOPICS.SEARCH)).toBe('help/search.html');
+    });
+
+    test('falls back to getting started for unknown topics', () => {
+        expect(getHelpUrl('../secret')).toBe('help/getting-started.html');
+        expect(getHelpUrl(undefined)).toBe('help/getting-started.html');
+    });
+});
@@ -0,0 +1,166 @@
+/**
+ * Tests for i18n.js
+ */
+import {
+    formatDate,
+    formatSize,
+    getLanguage,
+    getLanguagePreference,
+    getLocale,
+    LANGUAGE_STORAGE_KEY,
+    loadTranslations,
+    resolveLanguage,
+    setLanguagePreference,
+    SYSTEM_LANGUAGE,
+    t,
+    translateElements
+} from '../src/js/i18n.js';
+import english from '../res/locales/en.json';
+import german from '../res/locales/de.json';
+import french from '../res/locales/fr.json';
+import japanese from '../res/locales/ja.json';
+
+/**
+ * Answers fetch with a catalog
+ * @param {Object} catalog
+ */
+function serveCatalog(catalog) {
+    global.fetch = jest.fn(() =>
+        Promise.resolve({ ok: true, json: () => Promise.resolve(catalog) })
+    );
+}
+
+describe('i18n', () => {
+    afterEach(async () => {
+        localStorage.removeItem(LANGUAGE_STORAGE_KEY);
+        setLanguagePreference('en');
+        await loadTranslations();
+        localStorage.removeItem(LANGUAGE_STORAGE_KEY);
+        delete global.fetch;
+    });
+
+    describe('catalogs', () => {
+        test('translate every English key', () => {
+            const keys = Object.keys(english).sort();
+            for (const catalog of [german, french, japanese]) {
+                expect(Object.keys(catalog).sort()).toEqual(keys);
+            }
+        });
+    });
+
+    describe('resolveLanguage', () => {
+        test('maps locales to a bundled language', () => {
+            expect(resolveLanguage('de-AT')).toBe('de');
+            expect(resolveLanguage('fr_CA')).toBe('fr');
+            expect(resolveLanguage('JA')).toBe('ja');
+            expect(resolveLanguage('pt-BR')).toBe('en');
+            expect(resolveLanguage(undefined)).toBe('en');
+        });
+    });
+
+    describe('language preference', () => {
+        test('follows the system by default', () => {
+            expect(getLanguagePreference()).toBe(SYSTEM_LANGUAGE);
+        });
+
+        test('stores supported languages only', () => {
+            expect(setLanguagePreference('fr')).toBe(true);
+            expect(getLanguagePreference()).toBe('fr');
+            expect(setLanguagePreference('fr')).toBe(false);
+
+            setLanguagePreference('xx');
+            expect(getLanguagePreference()).toBe(SYSTEM_LANGUAGE);
+        });
+    });
+
+    describe('t', () => {
+        test('returns English before a catalog is loaded', () => {
+            expect(t('reply.forward')).toBe('Forward');
+        });
+
+        test('fills placeholders and keeps unknown ones', () => {
+            expect(t('message.downloadOriginal', { type: 'MSG' })).toBe('Download original MSG');
+            expect(t('message.downloadOriginal')).toBe('Download original {type}');
+        });
+
+        test('returns unknown keys as they are', () => {
+            expect(t('no.such.key')).toBe('no.such.key');
+        });
+    });
+
+    describe('loadTranslations', () => {
+        test('loads the preferred catalog in the browser', async () => {
+            serveCatalog({ 'reply.forward': 'Weiterleiten' });
+            setLanguagePreference('de');
+
+            await expect(loadTranslations()).resolves.toBe('de');
+
+            expect(global.fetch).toHaveBeenCalledWith('locales/de.json');
+            expect(getLanguage()).toBe('de');
+            expect(getLocale()).toBe('de');
+            expect(document.documentElement.lang).toBe('de');
+            expect(t('reply.forward')).toBe('Weiterleiten');
+            // Missing strings fall back to English
+            expect(t('reply.reply')).toBe('Reply');
+        });
+
+        test('stays in English if the catalog cannot be loaded', async () => {
+            global.fetch = jest.fn(() => Promise.resolve({ ok: false, status: 404 }));
+            const warn = jest.spyOn(console, 'warn').mockImplementation(() => {});
+            setLanguagePreference('ja');
+
+            await expect(loadTranslations()).resolves.toBe('en');
+            expect(t('reply.forward')).toBe('Forward');
+            warn.mockRestore();
+        });
+
+        test('uses the browser language for the system preference', async () => {
+            await expect(loadTranslations()).resolves.toBe('en');
+            expect(getLocale()).toBe(navigator.language);
+        });
+    });
+
+    describe('formatSize', () => {
+        test('formats sizes with English units', () => {
+            expect(formatSize(512)).toBe('512 B');
+            expect(formatSize(1536)).toBe('1.5 KB');
+            expect(formatSize(5 * 1024 * 1024)).toBe('5.0 MB');
+            expect(formatSize(3 * 1024 ** 3)).toBe('3.0 GB');
+        });
+
+        test('uses the units and decimal separator of the language', async () => {
+            serveCatalog(french);
+            setLanguagePreference('fr');
+            await loadTranslations();
+
+            expect(formatSize(1536)).toBe('1,5 Ko');
+        });
+    });
+
+    describe('formatDate', () => {
+        test('returns an empty string for invalid dates', () => {
+            expect(formatDate('not a date')).toBe('');
+        });
+
+        test('formats with the given options', () => {
+            const date = Date.UTC(2024, 6, 10, 9, 0);
+            expect(formatDate(date, { year: 'numeric', timeZone: 'UTC' })).toBe('2024');
+        });
+    });
+
+    describe('translateElements', () => {
+        test('translates text, titles and labels', () => {
+            document.body.innerHTML = `
+                <span data-i18n="reply.reply">x</span>
+                <button data-i18n-title="settings.title" aria-label="x" title="x"></button>
+            `;
+
+            translateElements();
+
+            expect(document.querySelector('span').textContent).toBe('Reply');
+            const button = document.querySelector('button');
+            expect(button.title).toBe('Settings');
+            expect(button.getAttribute('aria-label')).toBe('Settings');
+        });
+    });
+});
@@ -0,0 +1,75 @@
+/**
+ * Tests for kioskMode.js
+ */
+import { KioskMode } from '../src/js/kioskMode.js';
+
+describe('KioskMode', () => {
+    let doc;
+    let kiosk;
+
+    /**
+     * Dispatches an event and reports whether it was blocked
+     * @param {EventTarget} target
+     * @param {Event} event
+     * @returns {boolean}
+     */
+    function isBlocked(target, event) {
+        target.dispatchEvent(event);
+        return event.defaultPrevented;
+    }
+
+    beforeEach(() => {
+        doc = document.implementation.createHTMLDocument('kiosk');
+        doc.body.innerHTML = `
+            <div class="email-content"><a id="mailLink" href="https://example.com">link</a></div>
+            <a id="appLink" href="#help">help</a>
+        `;
+        kiosk = new KioskMode();
+    });
+
+    /**
+     * @param {string} key
+     * @param {Object} [modifiers]
+     * @returns {KeyboardEvent}
+     */
+    const keydown = (key, modifiers = {}) =>
+        new KeyboardEvent('keydown', { key, cancelable: true, ...modifiers });
+
+    /**
+     * @returns {MouseEvent}
+     */
+    const click = () => new MouseEvent('click', { bubbles: true, cancelable: true });
+
+    test('is off until enabled', () => {
+        expect(kiosk.isEnabled()).toBe(false);
+        expect(isBlocked(doc.body, keydown('p', { ctrlKey: true }))).toBe(false);
+    });
+
+    test('marks the page for the kiosk styles', () => {
+        kiosk.enable(doc);
+        expect(kiosk.isEnabled()).toBe(true);
+        expect(doc.body.classList.contains('kiosk-mode')).toBe(true);
+    });
+
+    test('blocks the print and save shortcuts', () => {
+        kiosk.enable(doc);
+
+        expect(isBlocked(doc.body, keydown('p', { ctrlKey: true }))).toBe(true);
+        expect(isBlocked(doc.body, keydown('S', { metaKey: true }))).toBe(true);
+        expect(isBlocked(doc.body, keydown('f', { ctrlKey: true }))).toBe(false);
+    });
+
+    test('blocks dragging content out and the context menu', () => {
+        kiosk.enable(doc);
+
+        expect(isBlocked(doc.body, new Event('dragstart', { cancelable: true }))).toBe(true);
+        expect(isBlocked(doc.body, new Event('contextmenu', { cancelable: true }))).toBe(true);
+    });
+
+    test('keeps links in messages from opening', () => {
+        kiosk.enable(doc);
+
+        expect(isBlocked(doc.getElementById('mailLink'), click())).toBe(true);
+        expect(isBlocked(doc.getElementById('appLink'), click())).toBe(false);
+    });
+});
@@ -0,0 +1,188 @@
+/**
+ * Tests for largeMessage.js
+ */
+jest.mock('../src/js/tauri-bridge.js', () => ({
+    closeLargeMessage: jest.fn(() => Promise.resolve()),
+    openLargeAttachment: jest.fn(() => Promise.resolve()),
+    openLargeMessage: jest.fn(),
+    openWithSystemViewer: jest.fn(() => Promise.resolve()),
+    readLargeAttachment: jest.fn(),
+    startFileSave: jest.fn(() => Promise.resolve({ saveId: 1, path: '/tmp/logo.png', total: 3 })),
+    startLargeAttachmentSave: jest.fn(() =>
+        Promise.resolve({ saveId: 2, path: '/tmp/video.mp4', total: 3 })
+    )
+}));
+jest.mock('../src/js/fileSaves.js', () => ({
+    runSave: jest.fn((start) => start().then((job) => (job ? job.path : false)))
+}));
+
+import {
+    closeLargeMessage,
+    openLargeAttachment,
+    openLargeMessage,
+    openWithSystemViewer,
+    readLargeAttachment,
+    startFileSave,
+    startLargeAttachmentSave
+} from '../src/js/tauri-bridge.js';
+import {
+    isDeferredAttachment,
+    largeMessageToMsgInfo,
+    loadAttachmentContent,
+    openAttachmentWithSystem,
+    openLargeMessageFile,
+    releaseLargeMessage,
+    saveAttachmentWithDialog
+} from '../src/js/largeMessage.js';
+
+/**
+ * Builds a result of openLargeMessage: an inline image sent with the message and a
+ * video sent without content
+ * @returns {Object}
+ */
+function largeMessage() {
+    return {
+        handle: 7,
+        fileSize: 300 * 1024 * 1024,
+        deferred: [1],
+        message: {
+            subject: 'Holiday video',
+            senderName: 'Alice',
+            senderEmail: 'alice@example.com',
+            recipients: [
+                { name: 'Bob', email: 'bob@example.com', type: 'to' },
+                { name: '', email: 'carol@example.com', type: 'cc' }
+            ],
+            date: Date.UTC(2024, 6, 10, 9, 0, 0),
+            messageId: '<holiday@example.com>',
+            headers: 'Subject: Holiday video\r\nX-Mailer: Test',
+            bodyText: 'See attached',
+            bodyHtml: '<p>See attached</p><img src="cid:logo">',
+            attachments: [
+                {
+                    fileName: 'logo.png',
+                    mimeType: 'image/png',
+                    contentId: 'logo',
+                    size: 3,
+                    contentBase64: 'AQID'
+                },
+                {
+                    fileName: 'video.mp4',
+                    mimeType: 'video/mp4',
+                    contentId: '',
+                    size: 250 * 1024 * 1024,
+                    contentBase64: ''
+                }
+            ]
+        }
+    };
+}
+
+describe('largeMessage', () => {
+    beforeEach(() => {
+        jest.clearAllMocks();
+    });
+
+    test('converts a backend message into the parser shape', () => {
+        const msgInfo = largeMessageToMsgInfo(largeMessage());
+
+        expect(msgInfo.subject).toBe('Holiday video');
+        expect(msgInfo.senderName).toBe('Alice');
+        expect(msgInfo.recipients).toEqual([
+            { name: 'Bob', email: 'bob@example.com', recipType: 'to' },
+            { name: 'carol@example.com', email: 'carol@example.com', recipType: 'cc' }
+        ]);
+        expect(msgInfo.messageDeliveryTime).toBe('2024-07-10T09:00:00.000Z');
+        expect(msgInfo.bodyContent).toBe('See attached');
+        expect(msgInfo._exportMeta.headerMap['x-mailer']).toBe('Test');
+        expect(msgInfo._exportMeta.headerMap['message-id']).toBe('<holiday@example.com>');
+        expect(msgInfo._largeFileHandle).toBe(7);
+        expect(msgInfo._rawBuffer).toBeUndefined();
+    });
+
+    test('keeps small attachments and defers large ones', () => {
+        const [image, video] = largeMessageToMsgInfo(largeMessage()).attachments;
+
+        expect(image.contentBase64).toBe('data:image/png;base64,AQID');
+        expect(image.contentId).toBe('logo');
+        expect(isDeferredAttachment(image)).toBe(false);
+        expect(video.contentBase64).toBe('');
+        expect(video.contentLength).toBe(250 * 1024 * 1024);
+        expect(video._largeFile).toEqual({ handle: 7, index: 1 });
+        expect(isDeferredAttachment(video)).toBe(true);
+    });
+
+    test('resolves inline images of the body', () => {
+        const msgInfo = largeMessageToMsgInfo(largeMessage());
+
+        expect(msgInfo.bodyContentHTML).toContain('data:image/png;base64,AQID');
+        expect(msgInfo.bodyContentHTML).not.toContain('cid:logo');
+    });
+
+    test('returns null for files the frontend reads itself', async () => {
+        openLargeMessage.mockResolvedValue(null);
+
+        await expect(openLargeMessageFile('/mail/small.msg')).resolves.toBeNull();
+    });
+
+    test('opens large files through the backend', async () => {
+        openLargeMessage.mockResolvedValue(largeMessage());
+
+        const msgInfo = await openLargeMessageFile('/mail/large.msg');
+
+        expect(openLargeMessage).toHaveBeenCalledWith('/mail/large.msg');
+        expect(msgInfo.attachments).toHaveLength(2);
+    });
+
+    test('reads deferred content once', async () => {
+        readLargeAttachment.mockResolvedValue(new Uint8Array([1, 2, 3]).buffer);
+        const [, video] = largeMessageToMsgInfo(largeMessage()).attachments;
+
+        await Promise.all([loadAttachmentContent(video), loadAttachmentContent(video)]);
+
+        expect(readLargeAttachment).toHaveBeenCalledTimes(1);
+        expect(readLargeAttachment).toHaveBeenCalledWith(7, 1);
+        expect(video.contentBase64).toBe('data:video/mp4;base64,AQID');
+        expect(isDeferredAttachment(video)).toBe(false);
+    });
+
+    test('reads deferred content again after a failure', async () => {
+        readLargeAttachment.mockRejectedValueOnce(new Error('Message is not open'));
+        readLargeAttachment.mockResolvedValueOnce(new Uint8Array([1]).buffer);
+        const [, video] = largeMessageToMsgInfo(largeMessage()).attachments;
+
+        await expect(loadAttachmentContent(video)).rejects.toThrow('Message is not open');
+        await loadAttachmentContent(video);
+
+        expect(readLargeAttachment).toHaveBeenCalledTimes(2);
+    });
+
+    test('does not read attachments that have content', async () => {
+        const [image] = largeMessageToMsgInfo(largeMessage()).attachments;
+
+        await expect(loadAttachmentContent(image)).resolves.toBe(image);
+        expect(readLargeAttachment).not.toHaveBeenCalled();
+    });
+
+    test('saves and opens deferred attachments in the backend', async () => {
+        const [image, video] = largeMessageToMsgInfo(largeMessage()).attachments;
+
+        await expect(saveAttachmentWithDialog(video)).resolves.toBe('/tmp/video.mp4');
+        await openAttachmentWithSystem(video);
+        await saveAttachmentWithDialog(image);
+        await openAttachmentWithSystem(image);
+
+        expect(startLargeAttachmentSave).toHaveBeenCalledWith(7, 1, 'video.mp4');
+        expect(openLargeAttachment).toHaveBeenCalledWith(7, 1, 'video.mp4');
+        expect(startFileSave).toHaveBeenCalledWith(image.contentBase64, 'logo.png');
+        expect(openWithSystemViewer).toHaveBeenCalledWith(image.contentBase64, 'logo.png');
+    });
+
+    test('releases the file of a closed large message', () => {
+        releaseLargeMessage(largeMessageToMsgInfo(largeMessage()));
+        releaseLargeMessage({ subject: 'Small message' });
+
+        expect(closeLargeMessage).toHaveBeenCalledTimes(1);
+        expect(closeLargeMessage).toHaveBeenCalledWith(7);
+    });
+});
@@ -0,0 +1,78 @@
+/**
+ * Tests for library.js
+ */
+import {
+    INPUT_TYPES,
+    LIBRARY_API_VERSION,
+    OUTPUT_FORMATS,
+    convert,
+    detectInputType,
+    parse,
+    render
+} from '../src/js/library.js';
+
+const SAMPLE_EML = [
+    'From: Alice <alice@example.com>',
+    'To: Bob <bob@example.com>',
+    'Subject: Library test',
+    'Date: Fri, 01 Mar 2024 09:30:00 +0000',
+    'Content-Type: text/plain; charset=utf-8',
+    '',
+    'Hello from the library.',
+    ''
+].join('\r\n');
+
+const encode = (text) => new TextEncoder().encode(text);
+
+describe('library', () => {
+    test('exposes the supported types and formats', () => {
+        expect(LIBRARY_API_VERSION).toBe(1);
+        expect(INPUT_TYPES).toEqual(['msg', 'eml']);
+        expect(OUTPUT_FORMATS).toEqual(['json', 'eml', 'html']);
+    });
+
+    test('detects MSG data by its compound file signature', () => {
+        const msg = new Uint8Array([0xd0, 0xcf, 0x11, 0xe0, 0xa1, 0xb1, 0x1a, 0xe1, 0]);
+        expect(detectInputType(msg)).toBe('msg');
+        expect(detectInputType(encode(SAMPLE_EML))).toBe('eml');
+    });
+
+    test('parses a message from a Uint8Array or an ArrayBuffer', () => {
+        const fromBytes = parse(encode(SAMPLE_EML), { fileName: 'test.eml' });
+        const fromBuffer = parse(encode(SAMPLE_EML).buffer);
+
+        expect(fromBytes.subject).toBe('Library test');
+        expect(fromBytes.fileName).toBe('test.eml');
+        expect(fromBytes._fileType).toBe('eml');
+        expect(fromBuffer.subject).toBe('Library test');
+    });
+
+    test('does not read or write the app data in localStorage', () => {
+        localStorage.getItem.mockClear();
+        localStorage.setItem.mockClear();
+
+        parse(encode(SAMPLE_EML));
+        expect(localStorage.getItem).not.toHaveBeenCalled();
+        expect(localStorage.setItem).not.toHaveBeenCalled();
+    });
+
+    test('renders a parsed message in every output format', () => {
+        const message = parse(encode(SAMPLE_EML));
+
+        expect(JSON.parse(render(message, 'json')).subject).toBe('Library test');
+        expect(render(message, 'eml')).toContain('Subject: Library test');
+        expect(render(message)).toContain('<!DOCTYPE html>');
+    });
+
+    test('converts in one step', () => {
+        const json = JSON.parse(convert(encode(SAMPLE_EML)));
+        expect(json.senderEmail).toBe('alice@example.com');
+        expect(json.bodyText).toContain('Hello from the library.');
+    });
+
+    test('rejects unsupported input', () => {
+        expect(() => parse('text')).toThrow(TypeError);
+        expect(() => parse(encode(SAMPLE_EML), { type: 'pst' })).toThrow('Unsupported input');
+        expect(() => render(parse(encode(SAMPLE_EML)), 'pdf')).toThrow('Unsupported output');
+    });
+});
@@ -0,0 +1,134 @@
+/**
+ * Tests for meeting.js
+ */
+import {
+    describeAttendee,
+    exportMeeting,
+    formatMeetingTime,
+    loadMeeting,
+    mayContainMeeting
+} from '../src/js/meeting.js';
+
+/**
+ * Builds an .eml message with its original file
+ * @param {string} raw - File content
+ * @param {Object} [overrides]
+ * @returns {Object}
+ */
+function emlMessage(raw, overrides = {}) {
+    return {
+        _rawBuffer: new TextEncoder().encode(raw).buffer,
+        _fileType: 'eml',
+        attachments: [],
+        ...overrides
+    };
+}
+
+/**
+ * Some ICU versions put narrow no-break spaces before AM/PM
+ * @param {string} text
+ * @returns {string}
+ */
+function plain(text) {
+    return text.replace(/\s/g, ' ');
+}
+
+describe('meeting', () => {
+    describe('mayContainMeeting', () => {
+        test('recognizes Outlook meeting classes', () => {
+            const request = { _rawBuffer: new ArrayBuffer(4), _fileType: 'msg' };
+            expect(
+                mayContainMeeting({ ...request, messageClass: 'IPM.Schedule.Meeting.Request' })
+            ).toBe(true);
+            expect(mayContainMeeting({ ...request, messageClass: 'IPM.Note' })).toBe(false);
+        });
+
+        test('finds calendar parts in .eml files', () => {
+            const invite = emlMessage('Content-Type: text/calendar; method=REQUEST\r\n\r\n');
+            expect(mayContainMeeting(invite)).toBe(true);
+            expect(mayContainMeeting(emlMessage('Content-Type: text/plain\r\n\r\nHi'))).toBe(false);
+        });
+
+        test('recognizes .ics attachments', () => {
+            const message = emlMessage('', {
+                attachments: [{ fileName: 'invite.ics', attachMimeTag: 'application/octet-stream' }]
+            });
+            expect(mayContainMeeting(message)).toBe(true);
+        });
+
+        test('needs the original file', () => {
+            expect(mayContainMeeting({ messageClass: 'IPM.Schedule.Meeting.Request' })).toBe(false);
+            expect(mayContainMeeting(null)).toBe(false);
+        });
+    });
+
+    describe('loadMeeting', () => {
+        test('does not ask the backend about other messages', async () => {
+            await expect(loadMeeting(emlMessage('Subject: Hi\r\n\r\nHello'))).resolves.toBeNull();
+        });
+
+        test('is only available in the desktop app', async () => {
+            const invite = emlMessage('Content-Type: text/calendar\r\n\r\n');
+            await expect(loadMeeting(invite)).rejects.toThrow('desktop app');
+            await expect(exportMeeting(invite)).rejects.toThrow('desktop app');
+        });
+    });
+
+    describe('formatMeetingTime', () => {
+        test('shows the end time only when the meeting ends the same day', () => {
+            const start = new Date(2024, 6, 10, 9, 0).getTime();
+            const text = plain(
+                formatMeetingTime(
+                    { start, end: start + 90 * 60000, allDay: false, floating: false },
+                    'en-US'
+                )
+            );
+            expect(text).toBe('Wed, Jul 10, 2024, 9:00 AM - 10:30 AM');
+        });
+
+        test('shows the days of all-day events', () => {
+            const start = Date.UTC(2024, 6, 10);
+            const oneDay = { start, end: start + 86400000, allDay: true };
+            const twoDays = { start, end: start + 2 * 86400000, allDay: true };
+            expect(formatMeetingTime(oneDay, 'en-US')).toBe('Wed, Jul 10, 2024');
+            expect(formatMeetingTime(twoDays, 'en-US')).toBe(
+                'Wed, Jul 10, 2024 - Thu, Jul 11, 2024'
+            );
+        });
+
+        test('shows floating times as written with their timezone', () => {
+            const meeting = {
+                start: Date.UTC(2024, 6, 10, 9),
+                end: null,
+                allDay: false,
+                floating: true,
+                timeZone: 'Romance Standard Time'
+            };
+            expect(plain(formatMeetingTime(meeting, 'en-US'))).toBe(
+                'Wed, Jul 10, 2024, 9:00 AM (Romance Standard Time)'
+            );
+            expect(formatMeetingTime({ start: null })).toBe('');
+        });
+    });
+
+    describe('describeAttendee', () => {
+        test('adds role and response', () => {
+            expect(
+                describeAttendee({
+                    name: 'Bob',
+                    email: 'bob@example.com',
+                    role: 'OPT-PARTICIPANT',
+                    status: 'ACCEPTED'
+                })
+            ).toBe('Bob <bob@example.com> (optional, accepted)');
+            expect(
+                describeAttendee({
+                    name: '',
+                    email: 'room@example.com',
+                    role: 'REQ-PARTICIPANT',
+                    status: ''
+                })
+            ).toBe('room@example.com');
+        });
+    });
+});
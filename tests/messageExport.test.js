@@ -2,7 +2,8 @@ import {
     getExportFileName,
     getOriginalMessageMimeType,
     messageToEml,
-    messageToHtmlDocument
+    messageToHtmlDocument,
+    messageToJson
 } from '../src/js/messageExport.js';
 
 describe('message export helpers', () => {
@@ -31,6 +32,7 @@ describe('message export helpers', () => {
     test('builds export file names by format', () => {
         expect(getExportFileName(message, 'eml')).toBe('quarterly.eml');
         expect(getExportFileName(message, 'html')).toBe('quarterly.html');
+        expect(getExportFileName({ ...message, _fileType: 'eml' }, 'msg')).toBe('quarterly.msg');
         expect(getExportFileName(message, 'original')).toBe('quarterly.msg');
     });
 
@@ -201,4 +203,31 @@ describe('message export helpers', () => {
         expect(eml).toContain('Content-Disposition: attachment; filename="forwarded.eml"');
         expect(eml).toContain('Content-Type: message/rfc822; name="forwarded.eml"');
     });
+
+    test('converts a message to plain JSON data', () => {
+        const data = messageToJson({ ...message, messageHash: 'hash1', _rawBuffer: new ArrayBuffer(3) });
+
+        expect(data.subject).toBe('Quarterly Update');
+        expect(data.recipients).toEqual([
+            { name: 'Bob Example', email: 'bob@example.com', type: 'to' },
+            { name: 'Carla Example', email: 'carla@example.com', type: 'cc' }
+        ]);
+        expect(data.attachments[0]).toMatchObject({
+            fileName: 'report.pdf',
+            mimeType: 'application/pdf',
+            size: 3,
+            contentBase64: 'QUJD'
+        });
+        expect(data.source).toEqual({ fileName: 'quarterly.msg', fileType: 'msg', messageHash: 'hash1' });
+        expect(JSON.parse(JSON.stringify(data))).toEqual(data);
+    });
+
+    test('can omit attachment content from JSON data', () => {
+        const data = messageToJson(message, { includeAttachmentContent: false });
+        expect(data.attachments[0].contentBase64).toBeUndefined();
+    });
+
+    test('uses an explicit extension for plugin formats', () => {
+        expect(getExportFileName(message, 'plugin', 'pdf')).toBe('quarterly.pdf');
+    });
 });
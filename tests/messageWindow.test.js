@@ -0,0 +1,132 @@
+/**
+ * Tests for messageWindow.js
+ */
+import { canOpenInNewWindow, loadWindowMessage, openInNewWindow } from '../src/js/messageWindow.js';
+import {
+    isMessageWindow,
+    openMessageInNewWindow,
+    takeWindowMessage
+} from '../src/js/tauri-bridge.js';
+
+jest.mock('../src/js/tauri-bridge.js', () => ({
+    isMessageWindow: jest.fn(() => false),
+    openMessageInNewWindow: jest.fn(),
+    takeWindowMessage: jest.fn()
+}));
+
+/**
+ * Builds a message with an original file
+ * @param {Object} [overrides]
+ * @returns {Object}
+ */
+function message(overrides = {}) {
+    return {
+        fileName: 'hello.eml',
+        _rawBuffer: new TextEncoder().encode('Subject: Hi').buffer,
+        _fileType: 'eml',
+        ...overrides
+    };
+}
+
+describe('messageWindow', () => {
+    beforeEach(() => {
+        isMessageWindow.mockReturnValue(false);
+        openMessageInNewWindow.mockReset();
+        openMessageInNewWindow.mockResolvedValue('message-1');
+        takeWindowMessage.mockReset();
+    });
+
+    describe('canOpenInNewWindow', () => {
+        test('needs the original .eml or .msg file in memory or on disk', () => {
+            expect(canOpenInNewWindow(message())).toBe(true);
+            expect(canOpenInNewWindow(message({ _rawBuffer: null, _sourcePath: '/a.msg' }))).toBe(
+                true
+            );
+            expect(canOpenInNewWindow(message({ _rawBuffer: null }))).toBe(false);
+            expect(canOpenInNewWindow(message({ _fileType: 'pst' }))).toBe(false);
+            expect(canOpenInNewWindow(null)).toBe(false);
+        });
+
+        test('is not offered in a message window', () => {
+            isMessageWindow.mockReturnValue(true);
+            expect(canOpenInNewWindow(message())).toBe(false);
+        });
+    });
+
+    describe('openInNewWindow', () => {
+        test('hands the file content to the backend', async () => {
+            await expect(openInNewWindow(message({ _sourcePath: '/mail/a.pst#3' }))).resolves.toBe(
+                'message-1'
+            );
+            expect(openMessageInNewWindow).toHaveBeenCalledWith({
+                fileName: 'hello.eml',
+                fileType: 'eml',
+                sourcePath: '/mail/a.pst#3',
+                data: 'U3ViamVjdDogSGk='
+            });
+        });
+
+        test('hands over the path of messages kept on disk', async () => {
+            await openInNewWindow(message({ _rawBuffer: null, _sourcePath: '/mail/big.eml' }));
+            expect(openMessageInNewWindow).toHaveBeenCalledWith({
+                fileName: 'hello.eml',
+                fileType: 'eml',
+                sourcePath: '/mail/big.eml',
+                path: '/mail/big.eml'
+            });
+        });
+
+        test('rejects messages without their file', async () => {
+            await expect(openInNewWindow(message({ _rawBuffer: null }))).rejects.toThrow(
+                'cannot be opened'
+            );
+            expect(openMessageInNewWindow).not.toHaveBeenCalled();
+        });
+    });
+
+    describe('loadWindowMessage', () => {
+        const fileHandler = () => ({
+            handleFileFromPath: jest.fn(() => Promise.resolve()),
+            handleMessageBuffer: jest.fn(() => Promise.resolve())
+        });
+
+        test('opens the file of the window message', async () => {
+            const handler = fileHandler();
+            takeWindowMessage.mockResolvedValue({
+                fileName: 'big.eml',
+                fileType: 'eml',
+                sourcePath: '/mail/big.eml',
+                path: '/mail/big.eml',
+                data: null
+            });
+
+            await expect(loadWindowMessage(handler)).resolves.toBe(true);
+            expect(handler.handleFileFromPath).toHaveBeenCalledWith('/mail/big.eml');
+        });
+
+        test('parses the content of the window message', async () => {
+            const handler = fileHandler();
+            takeWindowMessage.mockResolvedValue({
+                fileName: 'hello.msg',
+                fileType: 'msg',
+                sourcePath: '/mail/a.pst#3',
+                path: null,
+                data: 'U3ViamVjdDogSGk='
+            });
+
+            await loadWindowMessage(handler);
+            const [buffer, ...rest] = handler.handleMessageBuffer.mock.calls[0];
+            expect(new TextDecoder().decode(buffer)).toBe('Subject: Hi');
+            expect(rest).toEqual(['hello.msg', '/mail/a.pst#3', 'msg']);
+        });
+
+        test('does nothing without a window message', async () => {
+            const handler = fileHandler();
+            takeWindowMessage.mockResolvedValue(null);
+
+            await expect(loadWindowMessage(handler)).resolves.toBe(false);
+            expect(handler.handleFileFromPath).not.toHaveBeenCalled();
+            expect(handler.handleMessageBuffer).not.toHaveBeenCalled();
+        });
+    });
+});
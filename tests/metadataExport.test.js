@@ -0,0 +1,142 @@
+/**
+ * Tests for metadataExport.js
+ */
+import {
+    collectMessageMetadata,
+    metadataToCsv,
+    metadataToJson
+} from '../src/js/metadataExport.js';
+import { sha256Hex } from '../src/js/hashing.js';
+
+const original = new TextEncoder().encode('From: alice@example.com\r\n\r\nHi').buffer;
+
+function createMessage(overrides = {}) {
+    return {
+        fileName: 'Quarterly report.msg',
+        _sourcePath: '/evidence/Quarterly report.msg',
+        subject: 'Quarterly report',
+        senderName: 'Alice Example',
+        senderEmail: 'alice@example.com',
+        recipients: [
+            { name: 'Bob', email: 'bob@example.com', recipType: 'to' },
+            { name: 'carol@example.com', email: 'carol@example.com', recipType: 'to' },
+            { name: 'Dave', email: 'dave@example.com', recipType: 'cc' },
+            { name: 'Eve', smtpAddress: 'eve@example.com', recipType: 'bcc' }
+        ],
+        clientSubmitTime: '2024-03-01T09:00:00Z',
+        messageDeliveryTime: '2024-03-01T09:00:05Z',
+        messageId: '<report@example.com>',
+        _rawBuffer: original,
+        attachments: [
+            {
+                fileName: 'report.pdf',
+                attachMimeTag: 'application/pdf',
+                contentBase64: 'data:application/pdf;base64,JVBERg=='
+            }
+        ],
+        ...overrides
+    };
+}
+
+describe('metadataExport', () => {
+    describe('collectMessageMetadata', () => {
+        test('describes sender, recipients, dates and hashes', () => {
+            const [record] = collectMessageMetadata([createMessage()]);
+
+            expect(record).toMatchObject({
+                fileName: 'Quarterly report.msg',
+                sourcePath: '/evidence/Quarterly report.msg',
+                subject: 'Quarterly report',
+                senderName: 'Alice Example',
+                senderEmail: 'alice@example.com',
+                to: [
+                    { name: 'Bob', email: 'bob@example.com' },
+                    { name: 'carol@example.com', email: 'carol@example.com' }
+                ],
+                cc: [{ name: 'Dave', email: 'dave@example.com' }],
+                bcc: [{ name: 'Eve', email: 'eve@example.com' }],
+                sent: '2024-03-01T09:00:00.000Z',
+                received: '2024-03-01T09:00:05.000Z',
+                messageId: '<report@example.com>',
+                size: original.byteLength,
+                sha256: sha256Hex(original)
+            });
+            expect(record.attachments).toEqual([
+                {
+                    fileName: 'report.pdf',
+                    mimeType: 'application/pdf',
+                    size: 4,
+                    sha256: sha256Hex(new Uint8Array([0x25, 0x50, 0x44, 0x46]))
+                }
+            ]);
+        });
+
+        test('falls back for messages without original file or read attachments', () => {
+            const [record] = collectMessageMetadata([
+                createMessage({
+                    _rawBuffer: undefined,
+                    messageId: undefined,
+                    _exportMeta: { headerMap: { 'message-id': '<header@example.com>' } },
+                    clientSubmitTime: 'not a date',
+                    attachments: [{ fileName: 'big.zip', contentLength: 2048 }]
+                })
+            ]);
+
+            expect(record.size).toBeNull();
+            expect(record.sha256).toBeNull();
+            expect(record.messageId).toBe('<header@example.com>');
+            expect(record.sent).toBe('');
+            expect(record.attachments).toEqual([
+                {
+                    fileName: 'big.zip',
+                    mimeType: 'application/octet-stream',
+                    size: 2048,
+                    sha256: ''
+                }
+            ]);
+        });
+    });
+
+    describe('metadataToCsv', () => {
+        test('writes one row per message', () => {
+            const csv = metadataToCsv(collectMessageMetadata([createMessage()]));
+            const [header, row] = csv.split('\r\n');
+
+            expect(header).toBe(
+                'File Name,Source Path,Subject,From Name,From Email,To,Cc,Bcc,Sent,Received,' +
+                    'Message-ID,Size,SHA-256,Attachments,Attachment Names,Attachment Sizes,' +
+                    'Attachment SHA-256'
+            );
+            expect(row).toContain('Bob <bob@example.com>; carol@example.com');
+            expect(row).toContain(',Dave <dave@example.com>,Eve <eve@example.com>,');
+            expect(row).toContain(`,${sha256Hex(original)},1,report.pdf,4,`);
+        });
+
+        test('lists several attachments in the same order', () => {
+            const message = createMessage({
+                attachments: [
+                    { fileName: 'a.txt', contentBase64: 'data:text/plain;base64,YQ==' },
+                    { fileName: 'b, c.txt', contentBase64: 'data:text/plain;base64,YmM=' }
+                ]
+            });
+            const [, row] = metadataToCsv(collectMessageMetadata([message])).split('\r\n');
+
+            expect(row).toContain(',2,"a.txt; b, c.txt",1; 2,');
+        });
+    });
+
+    describe('metadataToJson', () => {
+        test('wraps the records with scope and time', () => {
+            const records = collectMessageMetadata([createMessage()]);
+            const now = new Date('2024-04-01T00:00:00Z');
+            const json = JSON.parse(metadataToJson(records, { scope: 'selected', now }));
+
+            expect(json).toMatchObject({
+                generatedAt: '2024-04-01T00:00:00.000Z',
+                scope: 'selected',
+                messageCount: 1
+            });
+            expect(json.messages[0].attachments[0].fileName).toBe('report.pdf');
+        });
+    });
+});
@@ -0,0 +1,81 @@
+/**
+ * Tests for oleObjects.js
+ */
+jest.mock('../src/js/tauri-bridge.js', () => ({
+    readOleObjects: jest.fn()
+}));
+
+import { readOleObjects } from '../src/js/tauri-bridge.js';
+import { oleObjectToAttachment, replaceOleObjects } from '../src/js/oleObjects.js';
+
+const WORKSHEET = {
+    fileName: 'Microsoft Excel Worksheet.xlsx',
+    mimeType: 'application/vnd.openxmlformats-officedocument.spreadsheetml.sheet',
+    contentId: '',
+    size: 3,
+    contentBase64: 'UEsD'
+};
+
+/**
+ * Builds a parsed message with a normal attachment and an unreadable OLE object
+ * @returns {Object}
+ */
+function parsedMessage() {
+    return {
+        subject: 'Figures',
+        attachments: [
+            { fileName: 'notes.txt', attachMimeTag: 'text/plain', contentBase64: 'data:,' },
+            { fileName: 'attachment', attachMimeTag: '', contentBase64: '' }
+        ]
+    };
+}
+
+describe('oleObjects', () => {
+    beforeEach(() => {
+        jest.clearAllMocks();
+    });
+
+    test('converts a backend OLE object into an attachment', () => {
+        expect(oleObjectToAttachment(WORKSHEET)).toEqual({
+            fileName: 'Microsoft Excel Worksheet.xlsx',
+            attachMimeTag: WORKSHEET.mimeType,
+            contentLength: 3,
+            contentBase64: `data:${WORKSHEET.mimeType};base64,UEsD`
+        });
+    });
+
+    test('replaces the OLE objects by their index', async () => {
+        readOleObjects.mockResolvedValue({ attachmentCount: 2, objects: [[1, WORKSHEET]] });
+
+        const msgInfo = await replaceOleObjects(parsedMessage(), '/mail/figures.msg');
+
+        expect(readOleObjects).toHaveBeenCalledWith('/mail/figures.msg');
+        expect(msgInfo.attachments.map((a) => a.fileName)).toEqual([
+            'notes.txt',
+            'Microsoft Excel Worksheet.xlsx'
+        ]);
+    });
+
+    test('keeps the attachments if the counts differ', async () => {
+        const warn = jest.spyOn(console, 'warn').mockImplementation(() => {});
+        readOleObjects.mockResolvedValue({ attachmentCount: 3, objects: [[1, WORKSHEET]] });
+
+        const msgInfo = await replaceOleObjects(parsedMessage(), '/mail/figures.msg');
+
+        expect(msgInfo.attachments[1].fileName).toBe('attachment');
+        warn.mockRestore();
+    });
+
+    test('keeps the message outside the desktop app and on errors', async () => {
+        const warn = jest.spyOn(console, 'warn').mockImplementation(() => {});
+        readOleObjects.mockResolvedValueOnce(null);
+        readOleObjects.mockRejectedValueOnce(new Error('Access denied'));
+
+        const first = await replaceOleObjects(parsedMessage(), '/mail/a.msg');
+        const second = await replaceOleObjects(parsedMessage(), '/mail/b.msg');
+
+        expect(first.attachments[1].fileName).toBe('attachment');
+        expect(second.attachments[1].fileName).toBe('attachment');
+        warn.mockRestore();
+    });
+});
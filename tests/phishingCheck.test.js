@@ -0,0 +1,76 @@
+/**
+ * Tests for phishingCheck.js
+ */
+import {
+    canCheckPhishing,
+    checkPhishing,
+    getPhishingVerdict,
+    PHISHING_STATUS
+} from '../src/js/phishingCheck.js';
+
+/**
+ * Builds a backend report
+ * @param {Object} [overrides]
+ * @returns {Object}
+ */
+function report(overrides = {}) {
+    return {
+        senderDomain: 'example.com',
+        replyTo: [],
+        links: 3,
+        findings: [],
+        risk: 'none',
+        ...overrides
+    };
+}
+
+const mismatchedLink = {
+    kind: 'mismatched_link',
+    severity: 'high',
+    description: 'The link shows paypal.com but goes to paypal-login.example.net',
+    target: 'https://paypal-login.example.net/signin',
+    text: 'paypal.com'
+};
+
+const replyToMismatch = {
+    kind: 'reply_to_mismatch',
+    severity: 'medium',
+    description: "Replies go to mailbox.example.org, not to the sender's domain example.com",
+    target: 'ceo@mailbox.example.org',
+    text: ''
+};
+
+describe('phishingCheck', () => {
+    describe('checkPhishing', () => {
+        test('needs the original file', async () => {
+            const message = { _rawBuffer: new ArrayBuffer(4), _fileType: 'msg' };
+            expect(canCheckPhishing(message)).toBe(true);
+            expect(canCheckPhishing({ _fileType: 'msg' })).toBe(false);
+            await expect(checkPhishing({ subject: 'Pasted' })).rejects.toThrow('original file');
+        });
+
+        test('is only available in the desktop app', async () => {
+            const message = { _rawBuffer: new ArrayBuffer(4), _fileType: 'eml' };
+            await expect(checkPhishing(message)).rejects.toThrow('desktop app');
+        });
+    });
+
+    describe('getPhishingVerdict', () => {
+        test('passes without findings', () => {
+            expect(getPhishingVerdict(report())).toEqual({
+                status: PHISHING_STATUS.PASS,
+                text: 'No suspicious links found (3 links checked)'
+            });
+        });
+
+        test('fails on high findings and warns about medium ones', () => {
+            const high = report({ findings: [mismatchedLink, replyToMismatch], risk: 'high' });
+            const medium = report({ findings: [replyToMismatch], risk: 'medium' });
+            expect(getPhishingVerdict(high)).toEqual({
+                status: PHISHING_STATUS.FAIL,
+                text: '2 signs of phishing found'
+            });
+            expect(getPhishingVerdict(medium).status).toBe(PHISHING_STATUS.WARNING);
+        });
+    });
+});
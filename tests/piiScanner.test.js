@@ -0,0 +1,112 @@
+/**
+ * Tests for piiScanner.js
+ */
+import {
+    getEnabledPiiDetectors,
+    isValidIban,
+    maskValue,
+    piiReportToCsv,
+    scanMessagesForPii,
+    scanText,
+    setEnabledPiiDetectors
+} from '../src/js/piiScanner.js';
+
+describe('piiScanner', () => {
+    describe('scanText', () => {
+        test('finds each built-in detector type', () => {
+            const text =
+                'Mail john.doe@example.com, call +49 30 12345678, ' +
+                'pay DE89 3704 0044 0532 0130 00, SSN 123-45-6789, NI AB 12 34 56 C.';
+
+            expect(scanText(text).map((finding) => finding.detector)).toEqual([
+                'email',
+                'phone',
+                'iban',
+                'nationalId',
+                'nationalId'
+            ]);
+        });
+
+        test('does not report IBAN digits or dates as phone numbers', () => {
+            const findings = scanText('IBAN GB82 WEST 1234 5698 7654 32 on 2024-03-01');
+
+            expect(findings).toHaveLength(1);
+            expect(findings[0].detector).toBe('iban');
+        });
+
+        test('ignores IBANs with a wrong checksum and invalid SSNs', () => {
+            expect(scanText('DE89 3704 0044 0532 0130 01', ['iban'])).toEqual([]);
+            expect(scanText('000-12-3456', ['nationalId'])).toEqual([]);
+        });
+
+        test('only runs the requested detectors', () => {
+            const findings = scanText('a@b.de +49 30 12345678', ['phone']);
+            expect(findings.map((finding) => finding.detector)).toEqual(['phone']);
+        });
+    });
+
+    test('validates IBANs with mod-97', () => {
+        expect(isValidIban('GB82WEST12345698765432')).toBe(true);
+        expect(isValidIban('GB82WEST12345698765433')).toBe(false);
+    });
+
+    test('masks values', () => {
+        expect(maskValue('john@example.com')).toBe('jo************om');
+        expect(maskValue('abc')).toBe('***');
+    });
+
+    test('stores the enabled detectors', () => {
+        expect(getEnabledPiiDetectors()).toEqual(['email', 'iban', 'nationalId', 'phone']);
+        setEnabledPiiDetectors(['iban', 'unknown']);
+        expect(getEnabledPiiDetectors()).toEqual(['iban']);
+    });
+
+    describe('scanMessagesForPii', () => {
+        const message = {
+            fileName: 'a.eml',
+            subject: 'Account for bob@example.com',
+            messageHash: 'hash1',
+            bodyContent: 'Send to bob@example.com and bob@example.com',
+            attachments: [
+                {
+                    fileName: 'list.csv',
+                    attachMimeTag: 'text/csv',
+                    contentBase64: `data:text/csv;base64,${btoa('name,ssn\nBob,123-45-6789')}`
+                },
+                {
+                    fileName: 'photo.png',
+                    attachMimeTag: 'image/png',
+                    contentBase64: `data:image/png;base64,${btoa('123-45-6789')}`
+                }
+            ]
+        };
+
+        test('reports grouped findings per location with message references', () => {
+            const report = scanMessagesForPii([message], {
+                now: new Date('2024-01-01T00:00:00.000Z')
+            });
+
+            expect(report.messageCount).toBe(1);
+            expect(report.findings).toMatchObject([
+                { location: 'subject', detector: 'email', count: 1 },
+                { location: 'body', detector: 'email', count: 2 },
+                {
+                    location: 'attachment:list.csv',
+                    detector: 'nationalId',
+                    messageHash: 'hash1',
+                    maskedValue: '12*******89'
+                }
+            ]);
+        });
+
+        test('serializes findings as CSV', () => {
+            const csv = piiReportToCsv(scanMessagesForPii([message]));
+            const lines = csv.trim().split('\r\n');
+
+            expect(lines[0]).toBe(
+                'fileName,sourcePath,subject,messageHash,location,detector,maskedValue,count'
+            );
+            expect(lines).toHaveLength(4);
+        });
+    });
+});
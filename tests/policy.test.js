@@ -0,0 +1,76 @@
+/**
+ * Tests for policy.js
+ */
+import { DEFAULT_POLICY, ManagedPolicy, managedPolicy } from '../src/js/policy.js';
+import {
+    EXTERNAL_CONTENT,
+    externalContentBlocked,
+    externalContentManaged,
+    getExternalContent,
+    getRemoteImageSenders,
+    remoteImagesAllowedFor,
+    setExternalContent,
+    setRemoteImageSenders
+} from '../src/js/UserPreferences.js';
+
+describe('ManagedPolicy', () => {
+    test('uses the defaults until policies are applied', () => {
+        const policy = new ManagedPolicy();
+        expect(policy.get('disableAutoUpdate')).toBe(false);
+        expect(policy.get('dataDir')).toBeNull();
+    });
+
+    test('fills missing policies with the defaults', () => {
+        const policy = new ManagedPolicy();
+        policy.apply({ disableAutoUpdate: true });
+        expect(policy.get('disableAutoUpdate')).toBe(true);
+        expect(policy.get('blockExternalContent')).toBe(DEFAULT_POLICY.blockExternalContent);
+
+        policy.apply(null);
+        expect(policy.get('disableAutoUpdate')).toBe(false);
+    });
+});
+
+describe('external content preference', () => {
+    afterEach(() => {
+        managedPolicy.apply(null);
+        localStorage.clear();
+    });
+
+    test('blocks external content by default', () => {
+        expect(getExternalContent()).toBe(EXTERNAL_CONTENT.BLOCK);
+        expect(externalContentBlocked()).toBe(true);
+    });
+
+    test('stores the user choice', () => {
+        expect(setExternalContent(EXTERNAL_CONTENT.BLOCK)).toBe(true);
+        expect(externalContentBlocked()).toBe(true);
+        expect(setExternalContent('sometimes')).toBe(false);
+    });
+
+    test('policy blocks external content regardless of the user choice', () => {
+        setExternalContent(EXTERNAL_CONTENT.LOAD);
+        managedPolicy.apply({ blockExternalContent: true });
+
+        expect(externalContentManaged()).toBe(true);
+        expect(externalContentBlocked()).toBe(true);
+        expect(setExternalContent(EXTERNAL_CONTENT.LOAD)).toBe(false);
+    });
+
+    test('loads remote images of allowed senders and domains', () => {
+        expect(setRemoteImageSenders([' News@Shop.example ', '@bank.example', 'no-at'])).toBe(true);
+
+        expect(getRemoteImageSenders()).toEqual(['news@shop.example', '@bank.example']);
+        expect(remoteImagesAllowedFor('NEWS@shop.example')).toBe(true);
+        expect(remoteImagesAllowedFor('alerts@bank.example')).toBe(true);
+        expect(remoteImagesAllowedFor('other@shop.example')).toBe(false);
+        expect(remoteImagesAllowedFor('')).toBe(false);
+    });
+
+    test('policy blocks remote images of allowed senders', () => {
+        setRemoteImageSenders(['@bank.example']);
+        managedPolicy.apply({ blockExternalContent: true });
+
+        expect(remoteImagesAllowedFor('alerts@bank.example')).toBe(false);
+    });
+});
@@ -0,0 +1,54 @@
+/**
+ * Tests for print.js
+ */
+import { buildPrintDocument, buildPrintText, printMessage } from '../src/js/print.js';
+
+const message = {
+    fileName: 'report.msg',
+    subject: 'Quarterly report',
+    senderName: 'Alice',
+    senderEmail: 'alice@example.com',
+    recipients: [
+        { name: 'Bob', email: 'bob@example.com', recipType: 'to' },
+        { name: 'Carol', email: 'carol@example.com', recipType: 'cc' }
+    ],
+    bodyContent: 'Numbers attached.',
+    bodyContentHTML: '<p>Numbers attached.</p><script>alert(1)</script>',
+    attachments: []
+};
+
+describe('print', () => {
+    describe('buildPrintDocument', () => {
+        test('sanitizes the message body', () => {
+            const html = buildPrintDocument(message);
+            expect(html).toContain('<p>Numbers attached.</p>');
+            expect(html).not.toContain('<script>');
+            expect(html).toContain('<title>Quarterly report</title>');
+        });
+
+        test('uses the body as rendered in the viewer', () => {
+            const html = buildPrintDocument(message, '<p>Rendered</p>');
+            expect(html).toContain('<p>Rendered</p>');
+            expect(html).not.toContain('Numbers attached');
+        });
+    });
+
+    describe('buildPrintText', () => {
+        test('starts with the main headers', () => {
+            const text = buildPrintText({ ...message, messageDeliveryTime: null });
+            expect(text).toBe(
+                'Subject: Quarterly report\n' +
+                    'From: Alice <alice@example.com>\n' +
+                    'To: Bob <bob@example.com>\n' +
+                    'CC: Carol <carol@example.com>\n\n' +
+                    'Numbers attached.\n'
+            );
+        });
+    });
+
+    describe('printMessage', () => {
+        test('is only available in the desktop app', async () => {
+            await expect(printMessage(message)).rejects.toThrow('desktop app');
+        });
+    });
+});
@@ -0,0 +1,151 @@
+/**
+ * Tests for profiles.js
+ */
+import {
+    ProfileManager,
+    PROFILES_STORAGE_KEY,
+    getProfileFromUrl,
+    getProfileStoragePrefix,
+    isValidProfileName,
+    resolveStartupProfile
+} from '../src/js/profiles.js';
+import { MemoryBackend, Storage } from '../src/js/storage.js';
+
+describe('profiles', () => {
+    describe('isValidProfileName', () => {
+        test('accepts letters, digits, spaces, dashes and underscores', () => {
+            expect(isValidProfileName('Work')).toBe(true);
+            expect(isValidProfileName('Case 2024-17_b')).toBe(true);
+            expect(isValidProfileName('Büro')).toBe(true);
+        });
+
+        test('rejects empty, padded, too long and path-like names', () => {
+            expect(isValidProfileName('')).toBe(false);
+            expect(isValidProfileName(' Work')).toBe(false);
+            expect(isValidProfileName('a'.repeat(65))).toBe(false);
+            expect(isValidProfileName('../etc')).toBe(false);
+            expect(isValidProfileName('a:b')).toBe(false);
+            expect(isValidProfileName(null)).toBe(false);
+        });
+    });
+
+    describe('getProfileStoragePrefix', () => {
+        test('uses no prefix for the default profile', () => {
+            expect(getProfileStoragePrefix(null)).toBe('');
+        });
+
+        test('prefixes named profiles', () => {
+            expect(getProfileStoragePrefix('Work')).toBe('msgReader_profile:Work:');
+        });
+    });
+
+    describe('getProfileFromUrl', () => {
+        test('reads the profile parameter', () => {
+            expect(getProfileFromUrl('?profile=Work')).toBe('Work');
+        });
+
+        test('returns null for an empty parameter', () => {
+            expect(getProfileFromUrl('?profile=')).toBeNull();
+        });
+
+        test('returns undefined without a parameter', () => {
+            expect(getProfileFromUrl('')).toBeUndefined();
+        });
+    });
+
+    describe('resolveStartupProfile', () => {
+        test('uses a requested profile without asking', () => {
+            expect(
+                resolveStartupProfile({ requested: 'Work', profiles: [], chooserEnabled: true })
+            ).toEqual({ profile: 'Work', choose: false });
+        });
+
+        test('asks when enabled and profiles exist', () => {
+            expect(
+                resolveStartupProfile({
+                    requested: undefined,
+                    profiles: ['Work'],
+                    chooserEnabled: true
+                })
+            ).toEqual({ profile: null, choose: true });
+        });
+
+        test('does not ask without profiles or when disabled', () => {
+            expect(
+                resolveStartupProfile({ requested: undefined, profiles: [], chooserEnabled: true })
+                    .choose
+            ).toBe(false);
+            expect(
+                resolveStartupProfile({
+                    requested: undefined,
+                    profiles: ['Work'],
+                    chooserEnabled: false
+                }).choose
+            ).toBe(false);
+        });
+
+        test('an explicit default profile skips the chooser', () => {
+            expect(
+                resolveStartupProfile({ requested: null, profiles: ['Work'], chooserEnabled: true })
+            ).toEqual({ profile: null, choose: false });
+        });
+    });
+
+    describe('ProfileManager', () => {
+        let backend;
+        let storage;
+        let manager;
+
+        beforeEach(() => {
+            backend = new MemoryBackend();
+            storage = new Storage(backend);
+            manager = new ProfileManager(storage);
+        });
+
+        test('activating a profile namespaces storage and remembers the profile', () => {
+            storage.set('setting', 'default');
+            expect(manager.activate('Work')).toBe(true);
+
+            expect(storage.get('setting')).toBeNull();
+            storage.set('setting', 'work');
+            expect(backend.getItem('msgReader_profile:Work:setting')).toBe('"work"');
+            expect(backend.getItem('setting')).toBe('"default"');
+            expect(manager.getProfiles()).toEqual(['Work']);
+            expect(manager.getActiveProfile()).toBe('Work');
+        });
+
+        test('rejects invalid names', () => {
+            expect(manager.activate('../x')).toBe(false);
+            expect(manager.addProfile('')).toBe(false);
+            expect(manager.getActiveProfile()).toBeNull();
+        });
+
+        test('keeps the profile list outside any profile', () => {
+            manager.activate('Work');
+            manager.addProfile('Home');
+            expect(JSON.parse(backend.getItem(PROFILES_STORAGE_KEY))).toEqual(['Home', 'Work']);
+        });
+
+        test('deletes a profile with its data', () => {
+            manager.activate('Work');
+            storage.set('setting', 'work');
+            manager.activate(null);
+
+            expect(manager.deleteProfile('Work')).toBe(true);
+            expect(backend.getItem('msgReader_profile:Work:setting')).toBeNull();
+            expect(manager.getProfiles()).toEqual([]);
+        });
+
+        test('does not delete the active profile', () => {
+            manager.activate('Work');
+            expect(manager.deleteProfile('Work')).toBe(false);
+        });
+
+        test('stores the chooser preference outside any profile', () => {
+            manager.activate('Work');
+            manager.setChooserEnabled(true);
+            manager.activate(null);
+            expect(manager.isChooserEnabled()).toBe(true);
+        });
+    });
+});
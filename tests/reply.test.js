@@ -0,0 +1,73 @@
+/**
+ * Tests for reply.js
+ */
+import { canReply, replyToMessage } from '../src/js/reply.js';
+import { replyViaDefaultClient } from '../src/js/tauri-bridge.js';
+
+jest.mock('../src/js/tauri-bridge.js', () => ({
+    replyViaDefaultClient: jest.fn()
+}));
+
+/**
+ * Builds a message with an original file
+ * @param {Object} [overrides]
+ * @returns {Object}
+ */
+function message(overrides = {}) {
+    return {
+        _rawBuffer: new TextEncoder().encode('Subject: Hi').buffer,
+        _fileType: 'eml',
+        ...overrides
+    };
+}
+
+describe('reply', () => {
+    beforeEach(() => {
+        replyViaDefaultClient.mockReset();
+        replyViaDefaultClient.mockResolvedValue(undefined);
+    });
+
+    describe('canReply', () => {
+        test('needs the original .eml or .msg file', () => {
+            expect(canReply(message())).toBe(true);
+            expect(canReply(message({ _fileType: 'msg' }))).toBe(true);
+            expect(canReply(message({ _rawBuffer: null }))).toBe(false);
+            expect(canReply(message({ _fileType: 'pst' }))).toBe(false);
+            expect(canReply(null)).toBe(false);
+        });
+    });
+
+    describe('replyToMessage', () => {
+        test('hands the original file to the backend as a draft by default', async () => {
+            await replyToMessage(message(), 'replyAll');
+
+            expect(replyViaDefaultClient).toHaveBeenCalledWith(
+                'U3ViamVjdDogSGk=',
+                'replyAll',
+                'draft'
+            );
+        });
+
+        test('passes the mailto handoff', async () => {
+            await replyToMessage(message(), 'forward', 'mailto');
+
+            expect(replyViaDefaultClient).toHaveBeenCalledWith(
+                'U3ViamVjdDogSGk=',
+                'forward',
+                'mailto'
+            );
+        });
+
+        test('rejects unknown modes and handoffs', async () => {
+            await expect(replyToMessage(message(), 'redirect')).rejects.toThrow('reply mode');
+            await expect(replyToMessage(message(), 'reply', 'smtp')).rejects.toThrow('handoff');
+            expect(replyViaDefaultClient).not.toHaveBeenCalled();
+        });
+
+        test('needs the original file', async () => {
+            await expect(replyToMessage(message({ _rawBuffer: null }), 'reply')).rejects.toThrow(
+                'original file'
+            );
+        });
+    });
+});
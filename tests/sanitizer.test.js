@@ -2,7 +2,15 @@
  * Tests for sanitizer.js
  * Ensures XSS protection and HTML sanitization work correctly
  */
-import { sanitizeHTML, escapeHTML, sanitizeURL, SANITIZE_CONFIG } from '../src/js/sanitizer.js';
+import {
+    sanitizeHTML,
+    escapeHTML,
+    sanitizeURL,
+    blockExternalContent,
+    containsExternalContent,
+    proxyExternalContent,
+    SANITIZE_CONFIG
+} from '../src/js/sanitizer.js';
 
 describe('SANITIZE_CONFIG', () => {
     test('has required allowed tags', () => {
@@ -208,3 +216,85 @@ describe('sanitizeURL', () => {
         expect(sanitizeURL(url)).toBe(url);
     });
 });
+
+describe('blockExternalContent', () => {
+    test('removes remote image sources', () => {
+        const html = blockExternalContent(
+            '<img src="https://tracker.example/pixel.gif" alt="pixel"><img src="//cdn.example/a.png">'
+        );
+        expect(html).not.toContain('tracker.example');
+        expect(html).not.toContain('cdn.example');
+        expect(html).toContain('alt="pixel"');
+    });
+
+    test('keeps embedded images', () => {
+        const html = blockExternalContent('<img src="data:image/png;base64,iVBORw0KGgo=">');
+        expect(html).toContain('data:image/png;base64,iVBORw0KGgo=');
+    });
+
+    test('removes remote CSS backgrounds and imports', () => {
+        const html = blockExternalContent(
+            '<style>@import "https://example.com/a.css"; td { background: url(https://example.com/b.png); }</style>' +
+                '<div style="background-image: url(\'http://example.com/c.png\')">text</div>'
+        );
+        expect(html).not.toContain('example.com');
+        expect(html).toContain('text');
+    });
+
+    test('leaves links untouched', () => {
+        const html = blockExternalContent('<a href="https://example.com">link</a>');
+        expect(html).toContain('href="https://example.com"');
+    });
+
+    test('handles empty input', () => {
+        expect(blockExternalContent('')).toBe('');
+        expect(blockExternalContent(null)).toBe('');
+    });
+});
+
+describe('containsExternalContent', () => {
+    test('finds remote images and CSS backgrounds', () => {
+        expect(containsExternalContent('<img src="https://tracker.example/pixel.gif">')).toBe(true);
+        expect(
+            containsExternalContent('<div style="background: url(//cdn.example/a.png)">x</div>')
+        ).toBe(true);
+        expect(containsExternalContent('<style>@import "https://example.com/a.css";</style>')).toBe(
+            true
+        );
+    });
+
+    test('ignores embedded images and links', () => {
+        expect(containsExternalContent('<img src="data:image/png;base64,iVBORw0KGgo=">')).toBe(
+            false
+        );
+        expect(containsExternalContent('<a href="https://example.com">link</a>')).toBe(false);
+        expect(containsExternalContent('')).toBe(false);
+    });
+});
+
+describe('proxyExternalContent', () => {
+    const proxy = 'remote-image://localhost/token/';
+
+    test('loads remote images through the proxy', () => {
+        const html = proxyExternalContent(
+            '<img src="https://cdn.example/a.png?x=1"><img src="//cdn.example/b.png">',
+            proxy
+        );
+        expect(html).toContain(`src="${proxy}${encodeURIComponent('https://cdn.example/a.png?x=1')}"`);
+        expect(html).toContain(`src="${proxy}${encodeURIComponent('https://cdn.example/b.png')}"`);
+    });
+
+    test('proxies CSS backgrounds and drops remote imports', () => {
+        const html = proxyExternalContent(
+            '<style>@import "https://example.com/a.css"; td { background: url(https://example.com/b.png); }</style>',
+            proxy
+        );
+        expect(html).not.toContain('@import');
+        expect(html).toContain(`url("${proxy}${encodeURIComponent('https://example.com/b.png')}")`);
+    });
+
+    test('keeps embedded images', () => {
+        const html = proxyExternalContent('<img src="data:image/png;base64,iVBORw0KGgo=">', proxy);
+        expect(html).toContain('data:image/png;base64,iVBORw0KGgo=');
+    });
+});
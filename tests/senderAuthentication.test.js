@@ -0,0 +1,108 @@
+/**
+ * Tests for senderAuthentication.js
+ */
+import {
+    AUTHENTICATION_STATUS,
+    canCheckAuthentication,
+    checkAuthentication,
+    getAuthenticationVerdict
+} from '../src/js/senderAuthentication.js';
+
+/**
+ * Builds a backend report
+ * @param {Object} [overrides]
+ * @returns {Object}
+ */
+function report(overrides = {}) {
+    return {
+        fromDomain: 'example.com',
+        recorded: [],
+        dkim: [],
+        spf: null,
+        dmarc: { result: 'none', policy: '', reason: '' },
+        bodyChecked: true,
+        dnsChecked: true,
+        ...overrides
+    };
+}
+
+describe('senderAuthentication', () => {
+    describe('canCheckAuthentication', () => {
+        test('needs the original file', () => {
+            const message = { _rawBuffer: new ArrayBuffer(4), _fileType: 'eml' };
+            expect(canCheckAuthentication(message)).toBe(true);
+            expect(canCheckAuthentication({ _fileType: 'eml' })).toBe(false);
+            expect(canCheckAuthentication(null)).toBe(false);
+        });
+    });
+
+    describe('checkAuthentication', () => {
+        test('rejects messages without their original file', async () => {
+            await expect(checkAuthentication({ subject: 'Pasted' })).rejects.toThrow(
+                'original file'
+            );
+        });
+
+        test('is only available in the desktop app', async () => {
+            const message = { _rawBuffer: new ArrayBuffer(4), _fileType: 'eml' };
+            await expect(checkAuthentication(message)).rejects.toThrow('desktop app');
+        });
+    });
+
+    describe('getAuthenticationVerdict', () => {
+        test('passes on DMARC pass', () => {
+            const verdict = getAuthenticationVerdict(
+                report({ dmarc: { result: 'pass', policy: 'reject', reason: '' } })
+            );
+            expect(verdict).toEqual({
+                status: AUTHENTICATION_STATUS.PASS,
+                text: 'Sent by example.com'
+            });
+        });
+
+        test('fails on DMARC fail only when the policy is enforced', () => {
+            const rejected = report({ dmarc: { result: 'fail', policy: 'reject', reason: '' } });
+            const monitored = report({ dmarc: { result: 'fail', policy: 'none', reason: '' } });
+            expect(getAuthenticationVerdict(rejected).status).toBe(AUTHENTICATION_STATUS.FAIL);
+            expect(getAuthenticationVerdict(monitored).status).toBe(AUTHENTICATION_STATUS.WARNING);
+        });
+
+        test('accepts an aligned DKIM signature without a DMARC policy', () => {
+            const signed = report({
+                dkim: [{ domain: 'example.com', result: 'pass', aligned: true }]
+            });
+            const thirdParty = report({
+                dkim: [{ domain: 'mailer.net', result: 'pass', aligned: false }]
+            });
+            expect(getAuthenticationVerdict(signed).status).toBe(AUTHENTICATION_STATUS.PASS);
+            expect(getAuthenticationVerdict(thirdParty).status).toBe(AUTHENTICATION_STATUS.UNKNOWN);
+        });
+
+        test('warns about failed checks without a DMARC policy', () => {
+            const tampered = report({
+                dkim: [{ domain: 'example.com', result: 'fail', aligned: true }]
+            });
+            const softfail = report({ spf: { result: 'softfail', domain: 'example.com' } });
+            expect(getAuthenticationVerdict(tampered).status).toBe(AUTHENTICATION_STATUS.WARNING);
+            expect(getAuthenticationVerdict(softfail).status).toBe(AUTHENTICATION_STATUS.WARNING);
+        });
+
+        test('reports offline checks as not checked', () => {
+            const offline = report({
+                dkim: [{ domain: 'example.com', result: 'temperror', aligned: true }],
+                dmarc: { result: 'temperror', policy: '', reason: 'Not checked (offline)' },
+                dnsChecked: false
+            });
+            expect(getAuthenticationVerdict(offline)).toEqual({
+                status: AUTHENTICATION_STATUS.UNKNOWN,
+                text: 'Not checked (offline)'
+            });
+        });
+
+        test('cannot judge a message without sender domain', () => {
+            expect(getAuthenticationVerdict(report({ fromDomain: '' })).status).toBe(
+                AUTHENTICATION_STATUS.UNKNOWN
+            );
+        });
+    });
+});
@@ -0,0 +1,193 @@
+/**
+ * Tests for session.js
+ */
+import {
+    captureSession,
+    describeMessage,
+    reopenSession,
+    trackSession
+} from '../src/js/session.js';
+import { restoreSession, updateSession } from '../src/js/tauri-bridge.js';
+import MessageHandler from '../src/js/MessageHandler.js';
+
+jest.mock('../src/js/tauri-bridge.js', () => ({
+    restoreSession: jest.fn(),
+    updateSession: jest.fn(() => Promise.resolve())
+}));
+
+const storage = { get: jest.fn(() => []), set: jest.fn() };
+
+/**
+ * A MessageHandler with messages opened from the given sources, newest first
+ * @param {Array<[string, string]>} sources - Source path and file name of each message
+ * @returns {MessageHandler}
+ */
+function handlerWith(sources) {
+    const handler = new MessageHandler(storage);
+    sources.forEach(([sourcePath, fileName], index) => {
+        handler.addMessage(
+            { _sourcePath: sourcePath, messageDeliveryTime: `2024-01-0${9 - index}T10:00:00Z` },
+            fileName
+        );
+    });
+    return handler;
+}
+
+describe('session', () => {
+    beforeEach(() => {
+        restoreSession.mockReset();
+        updateSession.mockClear();
+    });
+
+    describe('describeMessage', () => {
+        test('records where a message was opened from', () => {
+            expect(describeMessage({ _sourcePath: '/mail/a.msg', fileName: 'a.msg' })).toEqual({
+                kind: 'file',
+                path: '/mail/a.msg',
+                id: null,
+                fileName: 'a.msg'
+            });
+            const pstMessage = { _sourcePath: 'C:\\mail\\Outlook.PST#42', fileName: 'Hi.msg' };
+            expect(describeMessage(pstMessage)).toEqual({
+                kind: 'pst',
+                path: 'C:\\mail\\Outlook.PST',
+                id: 42,
+                fileName: 'Hi.msg'
+            });
+            expect(describeMessage({ _sourcePath: '/mail/Inbox#7', fileName: 'Hi.eml' })).toEqual({
+                kind: 'mbox',
+                path: '/mail/Inbox',
+                id: 7,
+                fileName: 'Hi.eml'
+            });
+            const zipMessage = { _sourcePath: '/cases/bundle.zip#2', fileName: 'Hi.msg' };
+            expect(describeMessage(zipMessage)).toEqual({
+                kind: 'zip',
+                path: '/cases/bundle.zip',
+                id: 2,
+                fileName: 'Hi.msg'
+            });
+            expect(describeMessage({ _sourcePath: 'archive#3', fileName: 'a.eml' })).toEqual({
+                kind: 'archive',
+                path: '',
+                id: 3,
+                fileName: 'a.eml'
+            });
+        });
+
+        test('skips messages without a source on disk', () => {
+            expect(describeMessage({ fileName: 'picked.msg' })).toBeNull();
+            const imapMessage = {
+                _sourcePath: 'imap://alice@imap.example.com:993/INBOX#12',
+                fileName: 'Hi.eml'
+            };
+            expect(describeMessage(imapMessage)).toBeNull();
+            expect(describeMessage(null)).toBeNull();
+        });
+    });
+
+    describe('captureSession', () => {
+        test('lists the messages and the one shown', () => {
+            const handler = handlerWith([
+                ['/mail/a.msg', 'a.msg'],
+                ['', 'picked.msg'],
+                ['archive#3', 'c.eml']
+            ]);
+            handler.setCurrentMessage(handler.getMessages()[2]);
+
+            const session = captureSession(handler);
+            expect(session.messages.map((entry) => entry.fileName)).toEqual(['a.msg', 'c.eml']);
+            expect(session.selected).toBe(1);
+        });
+
+        test('has no selection without a message shown', () => {
+            expect(captureSession(handlerWith([]))).toEqual({ messages: [], selected: null });
+        });
+    });
+
+    describe('trackSession', () => {
+        beforeEach(() => jest.useFakeTimers());
+        afterEach(() => jest.useRealTimers());
+
+        test('reports the session at once and after changes', () => {
+            const handler = handlerWith([]);
+            const stop = trackSession(handler);
+            expect(updateSession).toHaveBeenCalledWith({ messages: [], selected: null });
+
+            const message = handler.addMessage({ _sourcePath: '/mail/a.msg' }, 'a.msg');
+            handler.setCurrentMessage(message);
+            jest.runAllTimers();
+            expect(updateSession).toHaveBeenCalledTimes(2);
+            expect(updateSession).toHaveBeenLastCalledWith({
+                messages: [{ kind: 'file', path: '/mail/a.msg', id: null, fileName: 'a.msg' }],
+                selected: 0
+            });
+
+            // Unchanged sessions are not reported again
+            handler.setCurrentMessage(message);
+            jest.runAllTimers();
+            expect(updateSession).toHaveBeenCalledTimes(2);
+
+            stop();
+            handler.deleteMessage(0);
+            jest.runAllTimers();
+            expect(updateSession).toHaveBeenCalledTimes(2);
+        });
+    });
+
+    describe('reopenSession', () => {
+        test('opens each message and shows the one shown last', async () => {
+            const handler = handlerWith([]);
+            const open = (sourcePath, fileName) =>
+                handler.addMessage({ _sourcePath: sourcePath }, fileName);
+            const app = {
+                messageHandler: handler,
+                fileHandler: { handleFileFromPath: jest.fn(async (path) => open(path, 'a.msg')) },
+                openPstMessage: jest.fn(async (path, { id }) => open(`${path}#${id}`, 'Hi.msg')),
+                openMboxMessage: jest.fn(async (path, { index }) => open(`${path}#${index}`, 'x')),
+                openZipMessage: jest.fn(async (path, { index }) => open(`${path}#${index}`, 'y')),
+                openArchivedMessage: jest.fn(async ({ id }) => open(`archive#${id}`, 'c.eml')),
+                uiManager: { showMessage: jest.fn() }
+            };
+            const missing = [{ kind: 'file', path: '/gone.msg', id: null, fileName: 'gone.msg' }];
+            restoreSession.mockResolvedValue({
+                messages: [
+                    { kind: 'file', path: '/mail/a.msg', id: null, fileName: 'a.msg' },
+                    { kind: 'pst', path: '/mail/a.pst', id: 42, fileName: 'Hi.msg' },
+                    { kind: 'mbox', path: '/mail/Inbox', id: 7, fileName: 'Re: Hi.eml' },
+                    { kind: 'zip', path: '/cases/bundle.zip', id: 2, fileName: 'y.msg' },
+                    { kind: 'archive', path: '', id: 3, fileName: 'c.eml' }
+                ],
+                selected: 1,
+                missing
+            });
+
+            await expect(reopenSession(app)).resolves.toBe(missing);
+            expect(app.fileHandler.handleFileFromPath).toHaveBeenCalledWith('/mail/a.msg');
+            expect(app.openPstMessage).toHaveBeenCalledWith('/mail/a.pst', {
+                id: 42,
+                subject: 'Hi'
+            });
+            expect(app.openMboxMessage).toHaveBeenCalledWith('/mail/Inbox', {
+                index: 7,
+                subject: 'Re: Hi'
+            });
+            expect(app.openZipMessage).toHaveBeenCalledWith('/cases/bundle.zip', {
+                index: 2,
+                name: 'y.msg'
+            });
+            expect(app.openArchivedMessage).toHaveBeenCalledWith({ id: 3, fileName: 'c.eml' });
+            expect(app.uiManager.showMessage).toHaveBeenCalledWith(
+                expect.objectContaining({ _sourcePath: '/mail/a.pst#42' })
+            );
+        });
+
+        test('shows nothing if the message shown last is gone', async () => {
+            const app = { messageHandler: handlerWith([]), uiManager: { showMessage: jest.fn() } };
+            restoreSession.mockResolvedValue({ messages: [], selected: null, missing: [] });
+
+            await expect(reopenSession(app)).resolves.toEqual([]);
+            expect(app.uiManager.showMessage).not.toHaveBeenCalled();
+        });
+    });
+});
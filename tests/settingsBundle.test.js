@@ -0,0 +1,125 @@
+/**
+ * Tests for settingsBundle.js
+ */
+import {
+    SETTINGS_BUNDLE_FORMAT,
+    applySettingsImport,
+    createSettingsBundle,
+    formatSettingValue,
+    parseSettingsBundle,
+    planSettingsImport
+} from '../src/js/settingsBundle.js';
+import {
+    getExportChecksumMode,
+    getPdfAttachmentOpenMode,
+    setExportChecksumMode
+} from '../src/js/UserPreferences.js';
+import { auditLog } from '../src/js/AuditLog.js';
+
+describe('settingsBundle', () => {
+    const now = new Date('2026-10-14T08:00:00.000Z');
+
+    test('creates a bundle of the current settings', () => {
+        setExportChecksumMode('none');
+        const bundle = createSettingsBundle({ appVersion: '1.2.3', now });
+
+        expect(bundle).toMatchObject({
+            format: SETTINGS_BUNDLE_FORMAT,
+            version: 1,
+            createdAt: now.toISOString(),
+            appVersion: '1.2.3'
+        });
+        expect(bundle.settings.exportChecksumMode).toBe('none');
+        expect(bundle.settings.pdfAttachmentOpenMode).toBe('in-app');
+        expect(bundle.settings.auditLog).toBe(false);
+        expect(bundle.settings).not.toHaveProperty('automationApi');
+    });
+
+    test('round-trips through JSON', () => {
+        const bundle = createSettingsBundle({ now });
+        expect(parseSettingsBundle(JSON.stringify(bundle))).toEqual(bundle);
+    });
+
+    test('rejects files that are not bundles', () => {
+        expect(() => parseSettingsBundle('not json')).toThrow('not valid JSON');
+        expect(() => parseSettingsBundle('{"format":"other"}')).toThrow('not a msgReader');
+        expect(() =>
+            parseSettingsBundle(JSON.stringify({ format: SETTINGS_BUNDLE_FORMAT, version: 99 }))
+        ).toThrow('newer version');
+    });
+
+    test('reports changes, unchanged, invalid and unknown settings', () => {
+        const plan = planSettingsImport({
+            settings: {
+                exportChecksumMode: 'none',
+                pdfAttachmentOpenMode: 'in-app',
+                tempFileRetention: 'forever',
+                watchFolders: ['C:\\Mail']
+            }
+        });
+
+        expect(plan.changes).toEqual([
+            {
+                id: 'exportChecksumMode',
+                label: 'Export checksums',
+                current: 'sha256',
+                incoming: 'none'
+            }
+        ]);
+        expect(plan.unchanged).toEqual(['pdfAttachmentOpenMode']);
+        expect(plan.invalid).toEqual(['tempFileRetention']);
+        expect(plan.unknown).toEqual(['watchFolders']);
+    });
+
+    test('validates redaction options', () => {
+        const plan = planSettingsImport({
+            settings: {
+                redaction: { addresses: 'strip', dropAttachments: true, removeTrackingPixels: true }
+            }
+        });
+        expect(plan.changes.map(({ id }) => id)).toEqual(['redaction']);
+
+        const invalid = planSettingsImport({
+            settings: { redaction: { addresses: 'hide', dropAttachments: true } }
+        });
+        expect(invalid.invalid).toEqual(['redaction']);
+    });
+
+    test('ignores the order of list settings', () => {
+        const plan = planSettingsImport({
+            settings: { piiDetectors: ['phone', 'nationalId', 'iban', 'email'] }
+        });
+        expect(plan.unchanged).toEqual(['piiDetectors']);
+    });
+
+    test('does not treat inherited object properties as settings', () => {
+        const plan = planSettingsImport({ settings: JSON.parse('{"toString": "x"}') });
+        expect(plan.unknown).toEqual(['toString']);
+    });
+
+    test('applies only the planned changes', () => {
+        const plan = planSettingsImport({
+            settings: {
+                exportChecksumMode: 'none',
+                pdfAttachmentOpenMode: 'external',
+                auditLog: true
+            }
+        });
+        plan.changes = plan.changes.filter(({ id }) => id !== 'pdfAttachmentOpenMode');
+
+        expect(applySettingsImport(plan)).toBe(2);
+        expect(getExportChecksumMode()).toBe('none');
+        expect(getPdfAttachmentOpenMode()).toBe('in-app');
+        expect(auditLog.isEnabled()).toBe(true);
+    });
+
+    test('formats values for the report', () => {
+        expect(formatSettingValue(true)).toBe('On');
+        expect(formatSettingValue([])).toBe('None');
+        expect(formatSettingValue(['email', 'iban'])).toBe('email, iban');
+        expect(formatSettingValue('1h')).toBe('1h');
+        expect(formatSettingValue({ addresses: 'strip', dropAttachments: false })).toBe(
+            'addresses: strip, dropAttachments: Off'
+        );
+    });
+});
@@ -0,0 +1,80 @@
+/**
+ * Tests for settingsSync.js
+ */
+import { SettingsSync } from '../src/js/settingsSync.js';
+import { Storage } from '../src/js/storage.js';
+
+function createMemoryStorage(items = {}) {
+    const store = { ...items };
+    return new Storage({
+        getItem: (key) => (key in store ? store[key] : null),
+        setItem: (key, value) => {
+            store[key] = String(value);
+        },
+        removeItem: (key) => {
+            delete store[key];
+        }
+    });
+}
+
+describe('SettingsSync', () => {
+    let storage;
+    let bridge;
+    let sync;
+
+    beforeEach(() => {
+        storage = createMemoryStorage({ msgReader_theme: JSON.stringify('light') });
+        bridge = {
+            getSettings: jest.fn(() => Promise.resolve({})),
+            setSetting: jest.fn(() => Promise.resolve())
+        };
+        const apply = (storageKey) => jest.fn((value) => storage.set(storageKey, value));
+        sync = new SettingsSync(
+            {
+                theme: { storageKey: 'msgReader_theme', apply: apply('msgReader_theme') },
+                startup: { storageKey: 'msgReader_startup', apply: apply('msgReader_startup') }
+            },
+            { bridge, storage }
+        );
+    });
+
+    test('applies values from the backend', async () => {
+        bridge.getSettings.mockResolvedValue({ theme: 'dark' });
+
+        const changed = await sync.load();
+
+        expect(changed).toEqual(['theme']);
+        expect(sync.settings.theme.apply).toHaveBeenCalledWith('dark');
+        expect(storage.get('msgReader_theme')).toBe('dark');
+    });
+
+    test('copies values only stored locally to the backend', async () => {
+        const changed = await sync.load();
+
+        expect(changed).toEqual([]);
+        expect(bridge.setSetting).toHaveBeenCalledWith('theme', 'light');
+        expect(bridge.setSetting).toHaveBeenCalledTimes(1);
+    });
+
+    test('applies changes from other windows once', () => {
+        expect(sync.handleChange({ key: 'startup', value: 'last-file' })).toBe(true);
+        expect(sync.handleChange({ key: 'startup', value: 'last-file' })).toBe(false);
+        expect(sync.handleChange({ key: 'unknown', value: 'x' })).toBe(false);
+        expect(storage.get('msgReader_startup')).toBe('last-file');
+    });
+
+    test('compares lists by content', () => {
+        storage.set('msgReader_startup', ['a@example.com']);
+
+        expect(sync.handleChange({ key: 'startup', value: ['a@example.com'] })).toBe(false);
+        expect(sync.handleChange({ key: 'startup', value: ['b@example.com'] })).toBe(true);
+    });
+
+    test('publishes known settings only', () => {
+        sync.publish('startup', 'welcome');
+        sync.publish('unknown', 'x');
+
+        expect(bridge.setSetting).toHaveBeenCalledTimes(1);
+        expect(bridge.setSetting).toHaveBeenCalledWith('startup', 'welcome');
+    });
+});
@@ -0,0 +1,94 @@
+import { applyDecryptedContent, getEncryptedSmimePart } from '../src/js/smime.js';
+
+const encoder = new TextEncoder();
+
+/**
+ * Builds an attachment as the parsers return it
+ * @param {string} fileName
+ * @param {string} mimeType
+ * @returns {Object}
+ */
+function attachment(fileName, mimeType) {
+    return {
+        fileName,
+        attachMimeTag: mimeType,
+        contentBase64: `data:${mimeType};base64,AAAA`
+    };
+}
+
+describe('getEncryptedSmimePart', () => {
+    test('finds the smime.p7m of an encrypted message', () => {
+        const part = attachment('smime.p7m', 'application/pkcs7-mime');
+        expect(getEncryptedSmimePart({ attachments: [part] })).toBe(part);
+    });
+
+    test('accepts the x- MIME type and a .p7m name without MIME type', () => {
+        const legacy = attachment('smime.p7m', 'application/x-pkcs7-mime');
+        const unnamed = attachment('message.P7M', '');
+        expect(getEncryptedSmimePart({ attachments: [legacy] })).toBe(legacy);
+        expect(getEncryptedSmimePart({ attachments: [unnamed] })).toBe(unnamed);
+    });
+
+    test('ignores ordinary messages and messages with more parts', () => {
+        const p7m = attachment('smime.p7m', 'application/pkcs7-mime');
+        const pdf = attachment('invoice.pdf', 'application/pdf');
+        expect(getEncryptedSmimePart({ attachments: [pdf] })).toBeNull();
+        expect(getEncryptedSmimePart({ attachments: [p7m, pdf] })).toBeNull();
+        expect(getEncryptedSmimePart({ attachments: [] })).toBeNull();
+        expect(getEncryptedSmimePart(null)).toBeNull();
+    });
+
+    test('ignores a message that was already decrypted', () => {
+        const part = attachment('smime.p7m', 'application/pkcs7-mime');
+        expect(getEncryptedSmimePart({ attachments: [part], _smimeDecrypted: true })).toBeNull();
+    });
+});
+
+describe('applyDecryptedContent', () => {
+    test('shows the decrypted body and keeps the outer headers', () => {
+        const message = {
+            subject: 'Quarterly figures',
+            senderEmail: 'alice@example.com',
+            bodyContent: '',
+            attachments: [attachment('smime.p7m', 'application/pkcs7-mime')]
+        };
+        const decrypted = encoder.encode(
+            'Content-Type: text/plain; charset=utf-8\r\n\r\nThe figures are attached.\r\n'
+        ).buffer;
+
+        applyDecryptedContent(message, decrypted);
+
+        expect(message.bodyContent).toContain('The figures are attached.');
+        expect(message.attachments).toEqual([]);
+        expect(message.subject).toBe('Quarterly figures');
+        expect(message.senderEmail).toBe('alice@example.com');
+        expect(message._smimeDecrypted).toBe(true);
+        expect(getEncryptedSmimePart(message)).toBeNull();
+    });
+
+    test('keeps the attachments of the decrypted content', () => {
+        const message = { attachments: [attachment('smime.p7m', 'application/pkcs7-mime')] };
+        const decrypted = encoder.encode(
+            [
+                'Content-Type: multipart/mixed; boundary="b1"',
+                '',
+                '--b1',
+                'Content-Type: text/plain',
+                '',
+                'See attachment',
+                '--b1',
+                'Content-Type: text/plain; name="notes.txt"',
+                'Content-Disposition: attachment; filename="notes.txt"',
+                '',
+                'Notes',
+                '--b1--',
+                ''
+            ].join('\r\n')
+        ).buffer;
+
+        applyDecryptedContent(message, decrypted);
+
+        expect(message.bodyContent).toContain('See attachment');
+        expect(message.attachments.map((a) => a.fileName)).toEqual(['notes.txt']);
+    });
+});
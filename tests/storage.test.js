@@ -1,7 +1,13 @@
 /**
  * Tests for storage.js
  */
-import { Storage, storage } from '../src/js/storage.js';
+import {
+    MemoryBackend,
+    PROFILE_KEY_PREFIX,
+    ReadOnlyBackend,
+    Storage,
+    storage
+} from '../src/js/storage.js';
 
 describe('Storage', () => {
     describe('get', () => {
@@ -77,9 +83,13 @@ describe('Storage', () => {
     });
 
     describe('clear', () => {
-        test('clears all storage', () => {
-            storage.clear();
-            expect(localStorage.clear).toHaveBeenCalled();
+        test('clears the default namespace but keeps the keys of profiles', () => {
+            const backend = new MemoryBackend();
+            backend.setItem('key', '1');
+            backend.setItem(`${PROFILE_KEY_PREFIX}Work:key`, '2');
+            new Storage(backend).clear();
+            expect(backend.getItem('key')).toBeNull();
+            expect(backend.getItem(`${PROFILE_KEY_PREFIX}Work:key`)).toBe('2');
         });
 
         test('returns true on success', () => {
@@ -117,4 +127,130 @@ describe('Storage', () => {
             expect(nullStorage.clear()).toBe(false);
         });
     });
+
+    describe('namespace', () => {
+        let backend;
+        let namespaced;
+
+        beforeEach(() => {
+            const store = {};
+            backend = {
+                getItem: (key) => (key in store ? store[key] : null),
+                setItem: (key, value) => {
+                    store[key] = String(value);
+                },
+                removeItem: (key) => {
+                    delete store[key];
+                },
+                clear: () => Object.keys(store).forEach((key) => delete store[key]),
+                key: (index) => Object.keys(store)[index] ?? null,
+                get length() {
+                    return Object.keys(store).length;
+                }
+            };
+            namespaced = new Storage(backend);
+        });
+
+        test('prefixes keys with the namespace', () => {
+            namespaced.setNamespace('ns:');
+            namespaced.set('key', 1);
+            expect(backend.getItem('ns:key')).toBe('1');
+            expect(backend.getItem('key')).toBeNull();
+            expect(namespaced.get('key')).toBe(1);
+            expect(namespaced.has('key')).toBe(true);
+        });
+
+        test('keeps namespaces apart', () => {
+            namespaced.set('key', 'default');
+            namespaced.setNamespace('ns:');
+            expect(namespaced.get('key')).toBeNull();
+            namespaced.setNamespace('');
+            expect(namespaced.get('key')).toBe('default');
+        });
+
+        test('lists keys with a prefix', () => {
+            backend.setItem('ns:a', '1');
+            backend.setItem('ns:b', '2');
+            backend.setItem('other', '3');
+            expect(namespaced.keysWithPrefix('ns:').sort()).toEqual(['ns:a', 'ns:b']);
+        });
+
+        test('clear only removes keys of the namespace', () => {
+            backend.setItem('ns:a', '1');
+            backend.setItem('other', '2');
+            namespaced.setNamespace('ns:');
+            expect(namespaced.clear()).toBe(true);
+            expect(backend.getItem('ns:a')).toBeNull();
+            expect(backend.getItem('other')).toBe('2');
+        });
+    });
+
+    describe('read-only backend', () => {
+        let store;
+        let readOnly;
+
+        beforeEach(() => {
+            store = { kept: '"stored"', other: '1' };
+            const backend = {
+                getItem: (key) => (key in store ? store[key] : null),
+                setItem: (key, value) => {
+                    store[key] = String(value);
+                },
+                removeItem: (key) => {
+                    delete store[key];
+                },
+                clear: () => Object.keys(store).forEach((key) => delete store[key]),
+                key: (index) => Object.keys(store)[index] ?? null,
+                get length() {
+                    return Object.keys(store).length;
+                }
+            };
+            readOnly = new Storage(new ReadOnlyBackend(backend));
+        });
+
+        test('reads stored values', () => {
+            expect(readOnly.get('kept')).toBe('stored');
+        });
+
+        test('keeps changes in memory only', () => {
+            readOnly.set('kept', 'changed');
+            readOnly.set('added', true);
+            expect(readOnly.get('kept')).toBe('changed');
+            expect(readOnly.get('added')).toBe(true);
+            expect(store).toEqual({ kept: '"stored"', other: '1' });
+        });
+
+        test('hides removed and cleared keys without deleting them', () => {
+            readOnly.remove('kept');
+            expect(readOnly.has('kept')).toBe(false);
+            readOnly.clear();
+            expect(readOnly.get('other')).toBeNull();
+            expect(Object.keys(store)).toEqual(['kept', 'other']);
+        });
+
+        test('lists stored and added keys', () => {
+            readOnly.remove('other');
+            readOnly.set('added', 1);
+            expect(readOnly.keysWithPrefix('').sort()).toEqual(['added', 'kept']);
+        });
+    });
+
+    describe('memory backend', () => {
+        test('starts empty and never touches localStorage', () => {
+            localStorage.setItem('kept', '"stored"');
+            localStorage.getItem.mockClear();
+            localStorage.setItem.mockClear();
+
+            const memory = new Storage(new MemoryBackend());
+            expect(memory.get('kept')).toBeNull();
+            memory.set('added', { a: 1 });
+            expect(memory.get('added')).toEqual({ a: 1 });
+            expect(memory.keysWithPrefix('')).toEqual(['added']);
+            memory.clear();
+            expect(memory.has('added')).toBe(false);
+
+            expect(localStorage.getItem).not.toHaveBeenCalled();
+            expect(localStorage.setItem).not.toHaveBeenCalled();
+        });
+    });
 });
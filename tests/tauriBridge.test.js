@@ -1,6 +1,64 @@
 import {
+    cancelBatchConversion,
+    cancelFileSave,
+    checkAttachment,
+    clearRemoteImageCache,
+    compareMessages,
+    connectImapAccount,
+    copyFilesToClipboard,
+    decodeCharset,
+    detectFileType,
+    downloadUpdate,
+    exportArchiveMessages,
+    exportRedacted,
+    expandDroppedPaths,
+    exportMessagesAsMaildir,
+    exportPstFolderAsMaildir,
+    findDuplicates,
+    findUpdate,
+    getAppInfo,
+    getArchiveLabels,
+    getArchiveMessages,
+    getFileAssociationStatus,
+    getImapMessages,
+    getMapiProperties,
+    getPendingArchiveMessages,
+    getRecentLogs,
+    getRemoteImageProxy,
+    getThreads,
+    hasImapPassword,
+    importToArchive,
+    installUpdateAndRestart,
     isCurrentVersionAtLeastUpdate,
-    parseReleaseVersion
+    isMessageWindow,
+    listZipMessages,
+    makeDefaultApp,
+    onArchiveMessageOpen,
+    onConversionFinished,
+    onConversionProgress,
+    onSaveFinished,
+    onSaveProgress,
+    onNotificationOpen,
+    openDefaultAppsSettings,
+    openLargeMessage,
+    openLogFolder,
+    openMessageInNewWindow,
+    parseReleaseVersion,
+    readGzipMessage,
+    readImapMessage,
+    readLargeAttachment,
+    readZipMessage,
+    replyViaDefaultClient,
+    restoreSession,
+    sanitizeHtml,
+    setLogLevel,
+    startBatchConversion,
+    startFileSave,
+    startLargeAttachmentSave,
+    takeWindowMessage,
+    unregisterFileAssociations,
+    updateSession,
+    writeLog
 } from '../src/js/tauri-bridge.js';
 
 describe('tauri-bridge version helpers', () => {
@@ -25,3 +83,264 @@ describe('tauri-bridge version helpers', () => {
         expect(isCurrentVersionAtLeastUpdate('v1.8.0-rc.1', '1.8.0')).toBe(false);
     });
 });
+
+describe('tauri-bridge updates', () => {
+    test('are only checked and installed in the desktop app', async () => {
+        await expect(findUpdate()).resolves.toBeNull();
+        await expect(downloadUpdate()).rejects.toThrow('desktop app');
+        await expect(installUpdateAndRestart()).resolves.toBe(false);
+    });
+});
+
+describe('tauri-bridge file associations', () => {
+    test('are only reported by the desktop app', async () => {
+        await expect(getFileAssociationStatus()).resolves.toEqual([]);
+        await expect(openDefaultAppsSettings()).resolves.toBe(false);
+        await expect(makeDefaultApp()).resolves.toBe(false);
+        await expect(unregisterFileAssociations()).resolves.toBe(false);
+    });
+});
+
+describe('tauri-bridge archive', () => {
+    test('is only kept by the desktop app', async () => {
+        await expect(getArchiveMessages({ text: 'invoice' }, 0, 100)).resolves.toEqual({
+            offset: 0,
+            total: 0,
+            entries: []
+        });
+        await expect(getArchiveLabels()).resolves.toEqual({ folders: [], tags: [] });
+        await expect(importToArchive('', 'mail.eml')).rejects.toThrow('desktop app');
+        await expect(exportArchiveMessages([1])).rejects.toThrow('desktop app');
+    });
+});
+
+describe('tauri-bridge conversations', () => {
+    test('are only grouped by the desktop app', async () => {
+        await expect(getThreads([{ messageHash: 'a', subject: 'Offer' }])).resolves.toBeNull();
+    });
+});
+
+describe('tauri-bridge duplicates', () => {
+    test('are only found by the desktop app', async () => {
+        await expect(findDuplicates([{ messageHash: 'a', bodyContent: 'Hi' }])).resolves.toBeNull();
+    });
+});
+
+describe('tauri-bridge message comparison', () => {
+    test('only runs in the desktop app', async () => {
+        const message = { subject: 'Offer', bodyText: 'Hi', rawHeaders: '' };
+        await expect(compareMessages(message, message)).rejects.toThrow('desktop app');
+    });
+});
+
+describe('tauri-bridge remote images', () => {
+    test('are only proxied by the desktop app', async () => {
+        await expect(getRemoteImageProxy()).resolves.toBeNull();
+        await expect(clearRemoteImageCache()).resolves.toBe(0);
+    });
+});
+
+describe('tauri-bridge attachment checks', () => {
+    test('are only made by the desktop app', async () => {
+        await expect(checkAttachment('aGVsbG8=', 'hello.txt')).rejects.toThrow('desktop app');
+    });
+});
+
+describe('tauri-bridge MAPI properties', () => {
+    test('are only read by the desktop app', async () => {
+        await expect(getMapiProperties('0M8R4KGxGuE=')).rejects.toThrow('desktop app');
+    });
+});
+
+describe('tauri-bridge file types', () => {
+    test('are only detected by the desktop app', async () => {
+        await expect(detectFileType('/tmp/~WRL0001.tmp')).resolves.toBeNull();
+    });
+});
+
+describe('tauri-bridge large messages', () => {
+    test('are only opened by the desktop app', async () => {
+        await expect(openLargeMessage('/mail/video.msg')).resolves.toBeNull();
+        await expect(readLargeAttachment(1, 0)).rejects.toThrow('desktop app');
+        await expect(startLargeAttachmentSave(1, 0, 'video.mp4')).rejects.toThrow('desktop app');
+    });
+});
+
+describe('tauri-bridge HTML sanitization', () => {
+    test('is only available in the desktop app', async () => {
+        await expect(sanitizeHtml('<p>Hi</p>')).rejects.toThrow('desktop app');
+    });
+});
+
+describe('tauri-bridge charset decoding', () => {
+    test('is only available in the desktop app', async () => {
+        await expect(decodeCharset('GyRCJEskWyRzGyhC', 'iso-2022-jp')).rejects.toThrow(
+            'desktop app'
+        );
+    });
+});
+
+describe('tauri-bridge replies', () => {
+    test('are only handed to a mail client in the desktop app', async () => {
+        await expect(replyViaDefaultClient('UmU6', 'reply')).rejects.toThrow('desktop app');
+    });
+});
+
+describe('tauri-bridge deep links', () => {
+    test('only open archived messages in the desktop app', async () => {
+        await expect(getPendingArchiveMessages()).resolves.toEqual([]);
+        const unlisten = await onArchiveMessageOpen(jest.fn());
+        expect(typeof unlisten).toBe('function');
+    });
+});
+
+describe('tauri-bridge message windows', () => {
+    test('only open in the desktop app', async () => {
+        expect(isMessageWindow()).toBe(false);
+        await expect(takeWindowMessage()).resolves.toBeNull();
+        const message = { fileName: 'a.eml', fileType: 'eml', path: '/mail/a.eml' };
+        await expect(openMessageInNewWindow(message)).rejects.toThrow('desktop app');
+    });
+});
+
+describe('tauri-bridge session', () => {
+    test('is only kept by the desktop app', async () => {
+        await expect(updateSession({ messages: [], selected: null })).resolves.toBeUndefined();
+        await expect(restoreSession()).resolves.toEqual({
+            messages: [],
+            selected: null,
+            missing: []
+        });
+    });
+});
+
+describe('tauri-bridge notifications', () => {
+    test('are only clicked in the desktop app', async () => {
+        const unlisten = await onNotificationOpen(jest.fn());
+        expect(() => unlisten()).not.toThrow();
+    });
+});
+
+describe('tauri-bridge clipboard files', () => {
+    test('are only copied by the desktop app', async () => {
+        const files = [{ fileName: 'a.pdf', base64Content: 'JVBERg==' }];
+        await expect(copyFilesToClipboard(files)).rejects.toThrow('desktop app');
+    });
+});
+
+describe('tauri-bridge redacted copies', () => {
+    test('are only exported by the desktop app', async () => {
+        const options = { addresses: 'strip', dropAttachments: true, removeTrackingPixels: true };
+        await expect(exportRedacted({ subject: 'Hi' }, 'eml', options)).rejects.toThrow(
+            'desktop app'
+        );
+    });
+});
+
+describe('tauri-bridge background saves', () => {
+    test('only run in the desktop app', async () => {
+        await expect(startFileSave('data:text/plain;base64,aGk=', 'hi.txt')).rejects.toThrow(
+            'desktop app'
+        );
+        await expect(cancelFileSave(1)).resolves.toBe(false);
+
+        const unlistenProgress = await onSaveProgress(() => {});
+        const unlistenFinished = await onSaveFinished(() => {});
+        expect(() => unlistenProgress()).not.toThrow();
+        expect(() => unlistenFinished()).not.toThrow();
+    });
+});
+
+describe('tauri-bridge Maildir export', () => {
+    test('is only written by the desktop app', async () => {
+        await expect(exportMessagesAsMaildir([{ subject: 'Hi' }])).rejects.toThrow('desktop app');
+        await expect(exportPstFolderAsMaildir('/mail/archive.pst', 42)).rejects.toThrow(
+            'desktop app'
+        );
+    });
+});
+
+describe('tauri-bridge dropped paths', () => {
+    test('are opened as they are outside the desktop app', async () => {
+        await expect(expandDroppedPaths(['/mail/a.msg'])).resolves.toEqual({
+            files: ['/mail/a.msg'],
+            folders: 0,
+            skipped: 0,
+            duplicates: 0
+        });
+    });
+});
+
+describe('tauri-bridge compressed files', () => {
+    test('are only unpacked by the desktop app', async () => {
+        await expect(readGzipMessage('/mail/a.eml.gz')).rejects.toThrow('desktop app');
+        await expect(listZipMessages('/cases/bundle.zip')).rejects.toThrow('desktop app');
+        await expect(readZipMessage('/cases/bundle.zip', 0)).rejects.toThrow('desktop app');
+    });
+});
+
+describe('tauri-bridge IMAP', () => {
+    const account = { host: 'imap.example.com', port: 993, username: 'alice' };
+
+    test('mailboxes are only opened by the desktop app', async () => {
+        await expect(connectImapAccount(account, 'secret', false)).rejects.toThrow(
+            'desktop app'
+        );
+        await expect(readImapMessage(account, 'INBOX', 1)).rejects.toThrow('desktop app');
+    });
+
+    test('has no passwords or messages outside the desktop app', async () => {
+        await expect(hasImapPassword(account)).resolves.toBe(false);
+        await expect(getImapMessages(account, 'INBOX', 0, 100)).resolves.toEqual({
+            offset: 0,
+            total: 0,
+            entries: []
+        });
+    });
+});
+
+describe('tauri-bridge batch conversion', () => {
+    test('only runs in the desktop app', async () => {
+        await expect(startBatchConversion('pdf', { folder: '/mail' })).rejects.toThrow('desktop app');
+        await expect(cancelBatchConversion(1)).resolves.toBe(false);
+
+        const unlistenProgress = await onConversionProgress(() => {});
+        const unlistenFinished = await onConversionFinished(() => {});
+        expect(() => unlistenProgress()).not.toThrow();
+        expect(() => unlistenFinished()).not.toThrow();
+    });
+});
+
+describe('tauri-bridge logs', () => {
+    test('are only kept by the desktop app', async () => {
+        await expect(getRecentLogs()).resolves.toEqual([]);
+        await expect(setLogLevel('debug')).resolves.toBeUndefined();
+        await expect(writeLog('error', 'Something failed')).resolves.toBeUndefined();
+        await expect(openLogFolder()).rejects.toThrow('desktop app');
+    });
+});
+
+describe('tauri-bridge app info', () => {
+    afterEach(() => {
+        document.body.innerHTML = '';
+    });
+
+    test('reports the page version on the web', async () => {
+        document.body.innerHTML = '<span class="version-tag"> v1.8.0 </span>';
+
+        const info = await getAppInfo();
+
+        expect(info).toMatchObject({
+            name: 'msgReader',
+            version: 'v1.8.0',
+            commit: null,
+            platform: 'web',
+            packaging: null,
+            thirdPartyNotices: []
+        });
+    });
+
+    test('reports an empty version without a version tag', async () => {
+        expect((await getAppInfo()).version).toBe('');
+    });
+});
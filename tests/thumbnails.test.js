@@ -0,0 +1,64 @@
+/**
+ * Tests for thumbnails.js
+ */
+jest.mock('../src/js/tauri-bridge.js', () => ({
+    isTauri: jest.fn(() => true),
+    generateThumbnail: jest.fn(() =>
+        Promise.resolve({ dataUrl: 'data:image/jpeg;base64,dGh1bWI=', width: 80, height: 60 })
+    )
+}));
+
+import { generateThumbnail, isTauri } from '../src/js/tauri-bridge.js';
+import { THUMBNAIL_SIZE, getAttachmentThumbnail } from '../src/js/thumbnails.js';
+
+/**
+ * Builds an image attachment
+ * @param {string} [mimeType]
+ * @returns {Object}
+ */
+function image(mimeType = 'image/jpeg') {
+    return {
+        fileName: 'photo',
+        attachMimeTag: mimeType,
+        contentBase64: `data:${mimeType};base64,cGhvdG8=`
+    };
+}
+
+describe('thumbnails', () => {
+    beforeEach(() => {
+        jest.clearAllMocks();
+        isTauri.mockReturnValue(true);
+    });
+
+    test('generates a thumbnail once per attachment and size', async () => {
+        const attachment = image();
+
+        expect(await getAttachmentThumbnail(attachment)).toBe('data:image/jpeg;base64,dGh1bWI=');
+        await getAttachmentThumbnail(attachment);
+        await getAttachmentThumbnail(attachment, 200);
+
+        expect(generateThumbnail).toHaveBeenCalledTimes(2);
+        expect(generateThumbnail).toHaveBeenCalledWith('cGhvdG8=', THUMBNAIL_SIZE);
+        expect(generateThumbnail).toHaveBeenCalledWith('cGhvdG8=', 200);
+    });
+
+    test('falls back to the attachment when the webview can show it', async () => {
+        generateThumbnail.mockRejectedValue(new Error('Unsupported image'));
+        jest.spyOn(console, 'warn').mockImplementation(() => {});
+
+        const png = image('image/png');
+        expect(await getAttachmentThumbnail(png)).toBe(png.contentBase64);
+        expect(await getAttachmentThumbnail(image('image/heic'))).toBeNull();
+
+        console.warn.mockRestore();
+    });
+
+    test('uses the attachment itself outside the desktop app', async () => {
+        isTauri.mockReturnValue(false);
+        const gif = image('image/gif');
+
+        expect(await getAttachmentThumbnail(gif)).toBe(gif.contentBase64);
+        expect(await getAttachmentThumbnail(image('image/tiff'))).toBeNull();
+        expect(generateThumbnail).not.toHaveBeenCalled();
+    });
+});
@@ -0,0 +1,140 @@
+/**
+ * Tests for timeline.js
+ */
+import {
+    MAX_TIMELINE_BUCKETS,
+    TIMELINE_BUCKETS,
+    buildTimeline,
+    filterTimelineMessages,
+    getBucketStart
+} from '../src/js/timeline.js';
+
+function createMessage(date, overrides = {}) {
+    return {
+        subject: 'Status update',
+        senderName: 'Alice',
+        senderEmail: 'alice@example.com',
+        recipients: [],
+        timestamp: date ? new Date(date) : null,
+        ...overrides
+    };
+}
+
+describe('timeline', () => {
+    describe('getBucketStart', () => {
+        const date = new Date('2024-03-07T14:35:12Z'); // Thursday
+
+        test('truncates to the hour, day or Monday of the week', () => {
+            expect(getBucketStart(date, TIMELINE_BUCKETS.HOUR, true).toISOString()).toBe(
+                '2024-03-07T14:00:00.000Z'
+            );
+            expect(getBucketStart(date, TIMELINE_BUCKETS.DAY, true).toISOString()).toBe(
+                '2024-03-07T00:00:00.000Z'
+            );
+            expect(getBucketStart(date, TIMELINE_BUCKETS.WEEK, true).toISOString()).toBe(
+                '2024-03-04T00:00:00.000Z'
+            );
+        });
+
+        test('keeps Sundays in the week that started on Monday', () => {
+            expect(
+                getBucketStart(new Date('2024-03-10T23:00:00Z'), TIMELINE_BUCKETS.WEEK, true)
+                    .toISOString()
+            ).toBe('2024-03-04T00:00:00.000Z');
+        });
+    });
+
+    describe('filterTimelineMessages', () => {
+        const messages = [
+            createMessage('2024-03-01T10:00:00Z'),
+            createMessage('2024-03-02T10:00:00Z', {
+                senderEmail: 'Bob@Example.com',
+                subject: 'Invoice'
+            })
+        ];
+
+        test('filters by sender case-insensitively', () => {
+            expect(filterTimelineMessages(messages, { sender: 'bob@example.com' })).toEqual([
+                messages[1]
+            ]);
+        });
+
+        test('filters by search query', () => {
+            expect(filterTimelineMessages(messages, { query: 'invoice' })).toEqual([messages[1]]);
+            expect(filterTimelineMessages(messages, {})).toBe(messages);
+        });
+    });
+
+    describe('buildTimeline', () => {
+        test('counts messages per day including empty days', () => {
+            const timeline = buildTimeline(
+                [
+                    createMessage('2024-03-01T08:00:00Z'),
+                    createMessage('2024-03-01T20:00:00Z'),
+                    createMessage('2024-03-03T09:00:00Z'),
+                    createMessage(null)
+                ],
+                { utc: true }
+            );
+
+            expect(timeline).toEqual({
+                bucket: 'day',
+                total: 4,
+                undated: 1,
+                from: '2024-03-01T00:00:00.000Z',
+                to: '2024-03-04T00:00:00.000Z',
+                series: [
+                    { start: '2024-03-01T00:00:00.000Z', count: 2 },
+                    { start: '2024-03-02T00:00:00.000Z', count: 0 },
+                    { start: '2024-03-03T00:00:00.000Z', count: 1 }
+                ]
+            });
+        });
+
+        test('buckets by hour and week', () => {
+            const messages = [
+                createMessage('2024-03-04T10:15:00Z'),
+                createMessage('2024-03-04T12:45:00Z'),
+                createMessage('2024-03-19T12:45:00Z')
+            ];
+
+            expect(
+                buildTimeline(messages, { bucket: 'hour', utc: true }).series.map((p) => p.count)
+            ).toEqual([1, 0, 1, ...new Array(24 * 15 - 1).fill(0), 1]);
+            expect(buildTimeline(messages, { bucket: 'week', utc: true }).series).toEqual([
+                { start: '2024-03-04T00:00:00.000Z', count: 2 },
+                { start: '2024-03-11T00:00:00.000Z', count: 0 },
+                { start: '2024-03-18T00:00:00.000Z', count: 1 }
+            ]);
+        });
+
+        test('applies the sender filter before aggregating', () => {
+            const timeline = buildTimeline(
+                [
+                    createMessage('2024-03-01T08:00:00Z'),
+                    createMessage('2024-03-01T09:00:00Z', { senderEmail: 'bob@example.com' })
+                ],
+                { sender: 'bob@example.com', utc: true }
+            );
+
+            expect(timeline.total).toBe(1);
+            expect(timeline.series).toEqual([{ start: '2024-03-01T00:00:00.000Z', count: 1 }]);
+        });
+
+        test('returns an empty series without dated messages', () => {
+            expect(buildTimeline([createMessage(null)]).series).toEqual([]);
+            expect(buildTimeline([]).from).toBeNull();
+        });
+
+        test('rejects unknown buckets and oversized ranges', () => {
+            expect(() => buildTimeline([], { bucket: 'month' })).toThrow('Unknown timeline bucket');
+
+            const span = [
+                createMessage('2000-01-01T00:00:00Z'),
+                createMessage('2024-01-01T00:00:00Z')
+            ];
+            expect(MAX_TIMELINE_BUCKETS).toBeLessThan(24 * 365 * 24);
+            expect(() => buildTimeline(span, { bucket: 'hour' })).toThrow('too large');
+        });
+    });
+});
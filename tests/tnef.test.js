@@ -0,0 +1,173 @@
+import {
+    decodeTnef,
+    isTnef,
+    isTnefAttachment,
+    TNEF_SIGNATURE,
+    wrapTnefAsEml
+} from '../src/js/tnef.js';
+import { extractEml } from '../src/js/utils.js';
+
+const encoder = new TextEncoder();
+
+/**
+ * Builds one TNEF attribute
+ * @param {number} level - 1 message, 2 attachment
+ * @param {number} tag - Attribute tag (type and id)
+ * @param {Uint8Array} value
+ * @returns {Uint8Array}
+ */
+function attribute(level, tag, value) {
+    const bytes = new Uint8Array(9 + value.length + 2);
+    const view = new DataView(bytes.buffer);
+    bytes[0] = level;
+    view.setUint32(1, tag, true);
+    view.setUint32(5, value.length, true);
+    bytes.set(value, 9);
+    view.setUint16(9 + value.length, value.reduce((sum, byte) => (sum + byte) & 0xffff, 0), true);
+    return bytes;
+}
+
+/**
+ * Builds a MAPI property list with variable-length values
+ * @param {Array<{type: number, id: number, value: Uint8Array}>} properties
+ * @returns {Uint8Array}
+ */
+function mapiProperties(properties) {
+    const parts = [];
+    const count = new Uint8Array(4);
+    new DataView(count.buffer).setUint32(0, properties.length, true);
+    parts.push(count);
+    properties.forEach(({ type, id, value }) => {
+        const header = new Uint8Array(12);
+        const view = new DataView(header.buffer);
+        view.setUint16(0, type, true);
+        view.setUint16(2, id, true);
+        view.setUint32(4, 1, true);
+        view.setUint32(8, value.length, true);
+        parts.push(header, value, new Uint8Array((4 - (value.length % 4)) % 4));
+    });
+    return concat(parts);
+}
+
+function concat(parts) {
+    const bytes = new Uint8Array(parts.reduce((sum, part) => sum + part.length, 0));
+    let offset = 0;
+    parts.forEach((part) => {
+        bytes.set(part, offset);
+        offset += part.length;
+    });
+    return bytes;
+}
+
+function tnef(...attributes) {
+    const header = new Uint8Array(6);
+    const view = new DataView(header.buffer);
+    view.setUint32(0, TNEF_SIGNATURE, true);
+    view.setUint16(4, 0x0001, true);
+    return concat([header, ...attributes]);
+}
+
+const cString = (text) => encoder.encode(`${text}\0`);
+
+const sample = () =>
+    tnef(
+        attribute(1, 0x0002800c, cString('Plain body')),
+        attribute(
+            1,
+            0x00069003,
+            mapiProperties([{ type: 0x0102, id: 0x1013, value: encoder.encode('<p>Hello</p>') }])
+        ),
+        attribute(2, 0x00069002, new Uint8Array(14)),
+        attribute(2, 0x00018010, cString('REPORT~1.PDF')),
+        attribute(2, 0x0006800f, encoder.encode('%PDF-1.4')),
+        attribute(
+            2,
+            0x00069005,
+            mapiProperties([
+                { type: 0x001e, id: 0x3707, value: cString('Quarterly report.pdf') },
+                { type: 0x001e, id: 0x370e, value: cString('application/pdf') }
+            ])
+        ),
+        attribute(2, 0x00069002, new Uint8Array(14)),
+        attribute(2, 0x00018010, cString('notes.txt')),
+        attribute(2, 0x0006800f, encoder.encode('notes'))
+    );
+
+describe('TNEF decoding', () => {
+    test('recognizes TNEF data and attachments', () => {
+        expect(isTnef(sample())).toBe(true);
+        expect(isTnef(encoder.encode('not tnef'))).toBe(false);
+        expect(isTnefAttachment({ fileName: 'WINMAIL.DAT', attachMimeTag: '' })).toBe(true);
+        expect(isTnefAttachment({ fileName: 'x.dat', attachMimeTag: 'application/ms-tnef' })).toBe(
+            true
+        );
+        expect(isTnefAttachment({ fileName: 'x.dat', attachMimeTag: 'application/pdf' })).toBe(
+            false
+        );
+    });
+
+    test('extracts attachments with their MAPI names and types', () => {
+        const result = decodeTnef(sample());
+
+        expect(result.attachments.map(({ fileName, mimeType }) => ({ fileName, mimeType }))).toEqual(
+            [
+                { fileName: 'Quarterly report.pdf', mimeType: 'application/pdf' },
+                { fileName: 'notes.txt', mimeType: '' }
+            ]
+        );
+        expect(new TextDecoder().decode(result.attachments[0].content)).toBe('%PDF-1.4');
+    });
+
+    test('extracts the message body', () => {
+        const result = decodeTnef(sample());
+
+        expect(result.bodyText).toBe('Plain body');
+        expect(result.bodyHTML).toBe('<p>Hello</p>');
+        expect(result.compressedRtf).toBeNull();
+    });
+
+    test('rejects data that is not TNEF or is truncated', () => {
+        expect(() => decodeTnef(encoder.encode('not tnef at all'))).toThrow('Not TNEF data');
+        expect(() => decodeTnef(sample().subarray(0, 40))).toThrow('Truncated TNEF data');
+    });
+
+    test('replaces winmail.dat in EML messages with its attachments', () => {
+        const winmail = Buffer.from(sample()).toString('base64');
+        const eml = [
+            'From: Sender <sender@example.com>',
+            'To: recipient@example.com',
+            'Subject: Rich text',
+            'Content-Type: multipart/mixed; boundary="b"',
+            '',
+            '--b',
+            'Content-Type: application/ms-tnef; name="winmail.dat"',
+            'Content-Disposition: attachment; filename="winmail.dat"',
+            'Content-Transfer-Encoding: base64',
+            '',
+            winmail,
+            '--b--',
+            ''
+        ].join('\r\n');
+
+        const message = extractEml(encoder.encode(eml).buffer);
+
+        expect(message.attachments.map((a) => a.fileName)).toEqual([
+            'Quarterly report.pdf',
+            'notes.txt'
+        ]);
+        expect(message.attachments[0].attachMimeTag).toBe('application/pdf');
+        expect(message.bodyContentHTML).toBe('<p>Hello</p>');
+        expect(message.bodyContent).toBe('Plain body');
+    });
+
+    test('opens a winmail.dat file on its own as a message', () => {
+        const message = extractEml(wrapTnefAsEml(sample().buffer, 'winmail (2).dat'));
+
+        expect(message.subject).toBe('winmail (2).dat');
+        expect(message.attachments.map((a) => a.fileName)).toEqual([
+            'Quarterly report.pdf',
+            'notes.txt'
+        ]);
+        expect(message.bodyContent).toBe('Plain body');
+    });
+});
@@ -0,0 +1,75 @@
+/**
+ * Tests for translation.js
+ */
+import { getTranslationTargetLang, translateMessage } from '../src/js/translation.js';
+
+describe('translation', () => {
+    describe('getTranslationTargetLang', () => {
+        test('prefers the configured default language', () => {
+            expect(getTranslationTargetLang({ defaultTargetLang: 'DE' }, 'fr-FR')).toBe('de');
+        });
+
+        test('falls back to the primary UI language', () => {
+            expect(getTranslationTargetLang({}, 'fr-FR')).toBe('fr');
+            expect(getTranslationTargetLang({ defaultTargetLang: null }, 'de')).toBe('de');
+        });
+
+        test('keeps the region for English and Portuguese', () => {
+            expect(getTranslationTargetLang({}, 'en-GB')).toBe('en-gb');
+            expect(getTranslationTargetLang({}, 'pt-BR')).toBe('pt-br');
+        });
+    });
+
+    describe('translateMessage', () => {
+        const translate = jest.fn((text, targetLang) =>
+            Promise.resolve({ text: `[${targetLang}] ${text}`, detectedSourceLang: 'en' })
+        );
+
+        beforeEach(() => {
+            translate.mockClear();
+        });
+
+        test('translates subject and plain-text body', async () => {
+            const result = await translateMessage(
+                { subject: 'Hello', bodyContent: 'How are you?\n' },
+                'de',
+                translate
+            );
+
+            expect(result).toEqual({
+                subject: '[de] Hello',
+                body: '[de] How are you?',
+                targetLang: 'de',
+                detectedSourceLang: 'en'
+            });
+            expect(translate).toHaveBeenCalledTimes(2);
+        });
+
+        test('falls back to the HTML body as text', async () => {
+            const result = await translateMessage(
+                { subject: '', bodyContentHTML: '<p>Hi<br>there</p>' },
+                'fr',
+                translate
+            );
+
+            expect(result.subject).toBe('');
+            expect(translate).toHaveBeenCalledTimes(1);
+            expect(translate.mock.calls[0][0]).toBe('Hi\nthere');
+        });
+
+        test('rejects messages without text', async () => {
+            await expect(translateMessage({ subject: ' ' }, 'de', translate)).rejects.toThrow(
+                'no text to translate'
+            );
+            expect(translate).not.toHaveBeenCalled();
+        });
+
+        test('passes service errors through', async () => {
+            const failing = jest.fn(() => Promise.reject(new Error('Translation quota exceeded')));
+
+            await expect(
+                translateMessage({ subject: 'Hi', bodyContent: 'Body' }, 'de', failing)
+            ).rejects.toThrow('Translation quota exceeded');
+        });
+    });
+});
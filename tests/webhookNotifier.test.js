@@ -0,0 +1,115 @@
+/**
+ * Tests for webhookNotifier.js
+ */
+jest.mock('../src/js/tauri-bridge.js', () => ({
+    isTauri: jest.fn(() => false),
+    sendWebhookNotification: jest.fn(() => Promise.resolve(true))
+}));
+
+import { isTauri, sendWebhookNotification } from '../src/js/tauri-bridge.js';
+import {
+    WEBHOOK_EVENTS,
+    buildWebhookPayload,
+    notifyMessageImported
+} from '../src/js/webhookNotifier.js';
+
+const message = {
+    subject: 'Quarterly report',
+    senderName: 'Alice',
+    senderEmail: 'alice@example.com',
+    recipients: [
+        { name: 'Bob', email: 'bob@example.com', recipType: 'to' },
+        { name: 'Carol', smtpAddress: 'carol@example.com', recipType: 'cc' }
+    ],
+    timestamp: new Date('2024-03-01T09:30:00Z'),
+    messageHash: 'hash-1',
+    fileName: 'report.msg',
+    _sourcePath: '/inbox/report.msg',
+    _rawBuffer: new TextEncoder().encode('abc').buffer,
+    attachments: [
+        {
+            fileName: 'report.pdf',
+            attachMimeTag: 'application/pdf',
+            contentBase64: 'data:application/pdf;base64,YWJj'
+        },
+        { fileName: 'notes.txt' }
+    ]
+};
+
+describe('webhookNotifier', () => {
+    beforeEach(() => {
+        jest.clearAllMocks();
+    });
+
+    describe('buildWebhookPayload', () => {
+        test('summarizes sender, subject, hash and attachments', () => {
+            const payload = buildWebhookPayload(message, { now: new Date('2024-03-02T00:00:00Z') });
+
+            expect(payload.event).toBe(WEBHOOK_EVENTS.MESSAGE_IMPORTED);
+            expect(payload.occurredAt).toBe('2024-03-02T00:00:00.000Z');
+            expect(payload.message).toMatchObject({
+                subject: 'Quarterly report',
+                sender: { name: 'Alice', email: 'alice@example.com' },
+                date: '2024-03-01T09:30:00.000Z',
+                messageHash: 'hash-1',
+                fileName: 'report.msg',
+                sourcePath: '/inbox/report.msg'
+            });
+            expect(payload.message.recipients).toEqual([
+                { name: 'Bob', email: 'bob@example.com', type: 'to' },
+                { name: 'Carol', email: 'carol@example.com', type: 'cc' }
+            ]);
+            expect(payload.message.attachments.map((a) => a.fileName)).toEqual([
+                'report.pdf',
+                'notes.txt'
+            ]);
+            expect(payload.message.fileSha256).toBe(
+                'ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad'
+            );
+            expect(payload.message.attachments[0].sha256).toBe(payload.message.fileSha256);
+            expect(payload.message.attachments[1]).toEqual({
+                fileName: 'notes.txt',
+                mimeType: 'application/octet-stream',
+                sha256: ''
+            });
+        });
+
+        test('handles sparse messages', () => {
+            const payload = buildWebhookPayload({});
+
+            expect(payload.message.subject).toBe('');
+            expect(payload.message.recipients).toEqual([]);
+            expect(payload.message.attachments).toEqual([]);
+            expect(payload.message.date).toBe('');
+        });
+    });
+
+    describe('notifyMessageImported', () => {
+        test('does nothing outside the desktop app', async () => {
+            isTauri.mockReturnValue(false);
+
+            await expect(notifyMessageImported(message)).resolves.toBe(false);
+            expect(sendWebhookNotification).not.toHaveBeenCalled();
+        });
+
+        test('sends the payload through the backend', async () => {
+            isTauri.mockReturnValue(true);
+
+            await expect(notifyMessageImported(message)).resolves.toBe(true);
+            expect(sendWebhookNotification).toHaveBeenCalledTimes(1);
+            expect(sendWebhookNotification.mock.calls[0][0].message.subject).toBe(
+                'Quarterly report'
+            );
+        });
+
+        test('logs delivery failures instead of throwing', async () => {
+            isTauri.mockReturnValue(true);
+            sendWebhookNotification.mockRejectedValueOnce(new Error('HTTP 500'));
+            const consoleSpy = jest.spyOn(console, 'error').mockImplementation(() => {});
+
+            await expect(notifyMessageImported(message)).resolves.toBe(false);
+            expect(consoleSpy).toHaveBeenCalled();
+            consoleSpy.mockRestore();
+        });
+    });
+});